@@ -0,0 +1,119 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package activitymetrics publishes the management activity of the instance, the commands and the sessions it runs,
+// as custom metrics of Amazon CloudWatch, so that autoscaling and dashboards can react to it.
+// The publishing is disabled unless it is enabled in the agent configuration, then every function of the package is a no-op.
+package activitymetrics
+
+import (
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Names of the published metrics
+const (
+	// CommandsStarted is the number of commands and associations started
+	CommandsStarted = "CommandsStarted"
+	// CommandsSucceeded is the number of commands and associations which finished successfully
+	CommandsSucceeded = "CommandsSucceeded"
+	// CommandsFailed is the number of commands and associations which finished with a failed or timed out status
+	CommandsFailed = "CommandsFailed"
+	// SessionsActive is the number of Session Manager sessions running on the instance
+	SessionsActive = "SessionsActive"
+	// BytesForwarded is the number of bytes the sessions sent and received over their data channels
+	BytesForwarded = "BytesForwarded"
+)
+
+// gauges are the metrics whose current value is published, the other metrics publish the sum of their counts since
+// the last flush
+var gauges = map[string]bool{SessionsActive: true}
+
+var (
+	lock      sync.Mutex
+	publisher *metricPublisher
+	// values are the metrics recorded by the process, a metric is published at every flush once it was recorded,
+	// with a count of 0 when nothing happened since the last flush
+	values = make(map[string]int64)
+)
+
+// Start begins publishing the activity metrics of the process when it is enabled by the configuration
+func Start(log log.T, config appconfig.ActivityMetricsCfg, instanceID string) {
+	if !config.Enabled {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if publisher != nil {
+		return
+	}
+	publisher = newMetricPublisher(log, config, instanceID)
+	log.Infof("Publishing activity metrics to the %v CloudWatch namespace every %v seconds", config.Namespace, config.FlushIntervalSeconds)
+	go publisher.run()
+}
+
+// Stop publishes the metrics recorded since the last flush and stops the publishing
+func Stop() {
+	lock.Lock()
+	current := publisher
+	publisher = nil
+	lock.Unlock()
+	if current != nil {
+		current.stop()
+	}
+}
+
+// Enabled returns whether the activity metrics are published
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return publisher != nil
+}
+
+// Add adds a value to the count of a metric since the last flush, or to the current value of a gauge
+func Add(metric string, value int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	if publisher == nil {
+		return
+	}
+	values[metric] += value
+}
+
+// takeValues returns the values of the recorded metrics and starts the counts of the next flush from 0
+func takeValues() map[string]int64 {
+	lock.Lock()
+	defer lock.Unlock()
+	taken := make(map[string]int64, len(values))
+	for metric, value := range values {
+		taken[metric] = value
+		if !gauges[metric] {
+			values[metric] = 0
+		}
+	}
+	return taken
+}
+
+// requeueCounts adds back the counts whose publishing failed to the counts of the next flush, the gauges publish their
+// value of the next flush instead
+func requeueCounts(failed map[string]int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	for metric, value := range failed {
+		if !gauges[metric] {
+			values[metric] += value
+		}
+	}
+}
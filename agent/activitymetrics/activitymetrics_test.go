@@ -0,0 +1,130 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package activitymetrics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/stretchr/testify/assert"
+)
+
+// metricStore records the batches sent to it and fails the sends while err is set
+type metricStore struct {
+	batches []*cloudwatch.PutMetricDataInput
+	err     error
+}
+
+func (store *metricStore) send(input *cloudwatch.PutMetricDataInput) error {
+	if store.err != nil {
+		return store.err
+	}
+	store.batches = append(store.batches, input)
+	return nil
+}
+
+// published returns the values of the metrics of a batch by name
+func (store *metricStore) published(batch int) map[string]float64 {
+	published := make(map[string]float64)
+	for _, datum := range store.batches[batch].MetricData {
+		published[aws.StringValue(datum.MetricName)] = aws.Float64Value(datum.Value)
+	}
+	return published
+}
+
+func startTestMetrics(store *metricStore) {
+	values = make(map[string]int64)
+	newSender = func() sendFunc { return store.send }
+	Start(log.NewMockLog(), appconfig.ActivityMetricsCfg{
+		Enabled:              true,
+		Namespace:            "Fleet",
+		FlushIntervalSeconds: 3600,
+	}, "i-123")
+}
+
+func TestDisabled(t *testing.T) {
+	values = make(map[string]int64)
+	Start(log.NewMockLog(), appconfig.ActivityMetricsCfg{FlushIntervalSeconds: 60}, "i-123")
+
+	Add(CommandsStarted, 1)
+	assert.False(t, Enabled())
+	assert.Empty(t, values)
+}
+
+func TestPublishAggregatedMetrics(t *testing.T) {
+	store := &metricStore{}
+	startTestMetrics(store)
+	defer Stop()
+
+	Add(CommandsStarted, 1)
+	Add(CommandsStarted, 1)
+	Add(CommandsFailed, 1)
+	Add(SessionsActive, 1)
+	Add(BytesForwarded, 512)
+	publisher.flush(true)
+
+	assert.Len(t, store.batches, 1)
+	assert.Equal(t, "Fleet", aws.StringValue(store.batches[0].Namespace))
+	assert.Equal(t, map[string]float64{CommandsStarted: 2, CommandsFailed: 1, SessionsActive: 1, BytesForwarded: 512}, store.published(0))
+	for _, datum := range store.batches[0].MetricData {
+		assert.Equal(t, instanceIDDimension, aws.StringValue(datum.Dimensions[0].Name))
+		assert.Equal(t, "i-123", aws.StringValue(datum.Dimensions[0].Value))
+		if aws.StringValue(datum.MetricName) == BytesForwarded {
+			assert.Equal(t, cloudwatch.StandardUnitBytes, aws.StringValue(datum.Unit))
+		} else {
+			assert.Equal(t, cloudwatch.StandardUnitCount, aws.StringValue(datum.Unit))
+		}
+	}
+
+	// the counts start from 0 at every flush while the gauges keep their value
+	Add(SessionsActive, 1)
+	publisher.flush(true)
+	assert.Equal(t, map[string]float64{CommandsStarted: 0, CommandsFailed: 0, SessionsActive: 2, BytesForwarded: 0}, store.published(1))
+}
+
+func TestFailedCountsArePublishedAtTheNextFlush(t *testing.T) {
+	store := &metricStore{err: errors.New("throttled")}
+	startTestMetrics(store)
+
+	Add(CommandsSucceeded, 3)
+	Add(SessionsActive, 1)
+	publisher.flush(true)
+	assert.Empty(t, store.batches)
+
+	store.err = nil
+	Add(CommandsSucceeded, 1)
+	Stop()
+
+	assert.Len(t, store.batches, 1)
+	assert.Equal(t, map[string]float64{CommandsSucceeded: 4, SessionsActive: 1}, store.published(0))
+}
+
+func TestPublishInBatches(t *testing.T) {
+	store := &metricStore{}
+	startTestMetrics(store)
+
+	for i := 0; i < maxBatchDatums+5; i++ {
+		Add(fmt.Sprintf("Metric%02d", i), 1)
+	}
+	Stop()
+
+	assert.Len(t, store.batches, 2)
+	assert.Len(t, store.batches[0].MetricData, maxBatchDatums)
+	assert.Len(t, store.batches[1].MetricData, 5)
+}
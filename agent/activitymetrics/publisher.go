@@ -0,0 +1,153 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package activitymetrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+)
+
+// maxBatchDatums is the largest number of metric datums accepted by one PutMetricData call
+const maxBatchDatums = 20
+
+// instanceIDDimension is the dimension of the metrics identifying the instance
+const instanceIDDimension = "InstanceId"
+
+// units are the units of the metrics which are not counts
+var units = map[string]string{BytesForwarded: cloudwatch.StandardUnitBytes}
+
+// sendFunc posts a batch of metric datums to CloudWatch
+type sendFunc func(input *cloudwatch.PutMetricDataInput) error
+
+// newSender returns the function posting the metrics, it is replaced by the tests
+var newSender = func() sendFunc {
+	appConfig, _ := appconfig.Config(false)
+	sess := session.New(sdkutil.AwsConfig())
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
+	client := cloudwatch.New(sess)
+	return func(input *cloudwatch.PutMetricDataInput) error {
+		_, err := client.PutMetricData(input)
+		return err
+	}
+}
+
+// metricPublisher publishes the metrics aggregated since the last flush in batches at every interval
+type metricPublisher struct {
+	log        log.T
+	send       sendFunc
+	namespace  string
+	instanceID string
+	interval   time.Duration
+	failing    bool
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	done       chan struct{}
+}
+
+func newMetricPublisher(log log.T, config appconfig.ActivityMetricsCfg, instanceID string) *metricPublisher {
+	return &metricPublisher{
+		log:        log,
+		send:       newSender(),
+		namespace:  config.Namespace,
+		instanceID: instanceID,
+		interval:   time.Duration(config.FlushIntervalSeconds) * time.Second,
+		stopChan:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// run flushes at every interval until the publisher is stopped, then flushes one last time
+func (p *metricPublisher) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.flush(true)
+		case <-p.stopChan:
+			p.flush(false)
+			return
+		}
+	}
+}
+
+func (p *metricPublisher) stop() {
+	p.stopOnce.Do(func() { close(p.stopChan) })
+	<-p.done
+}
+
+// flush publishes the recorded metrics, the counts of the batches that could not be sent are added to the next flush
+// when requeue is set. A failed publishing is logged once until a publishing succeeds again.
+func (p *metricPublisher) flush(requeue bool) {
+	values := takeValues()
+	metrics := make([]string, 0, len(values))
+	for metric := range values {
+		metrics = append(metrics, metric)
+	}
+	sort.Strings(metrics)
+
+	now := time.Now()
+	var err error
+	unsent := make(map[string]int64)
+	for start := 0; start < len(metrics); start += maxBatchDatums {
+		end := start + maxBatchDatums
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		batch := metrics[start:end]
+		if sendErr := p.send(p.batchInput(batch, values, now)); sendErr != nil {
+			err = sendErr
+			for _, metric := range batch {
+				unsent[metric] = values[metric]
+			}
+		}
+	}
+	if requeue && len(unsent) > 0 {
+		requeueCounts(unsent)
+	}
+	if err != nil && !p.failing {
+		p.log.Warnf("Failed to publish activity metrics to CloudWatch: %v", err)
+	} else if err == nil && p.failing {
+		p.log.Infof("Publishing activity metrics to CloudWatch again")
+	}
+	p.failing = err != nil
+}
+
+// batchInput builds the PutMetricData input of a batch of metrics, with the instance id as their dimension
+func (p *metricPublisher) batchInput(batch []string, values map[string]int64, timestamp time.Time) *cloudwatch.PutMetricDataInput {
+	input := &cloudwatch.PutMetricDataInput{Namespace: aws.String(p.namespace)}
+	for _, metric := range batch {
+		unit, found := units[metric]
+		if !found {
+			unit = cloudwatch.StandardUnitCount
+		}
+		input.MetricData = append(input.MetricData, &cloudwatch.MetricDatum{
+			MetricName: aws.String(metric),
+			Dimensions: []*cloudwatch.Dimension{{Name: aws.String(instanceIDDimension), Value: aws.String(p.instanceID)}},
+			Timestamp:  aws.Time(timestamp),
+			Unit:       aws.String(unit),
+			Value:      aws.Float64(float64(values[metric])),
+		})
+	}
+	return input
+}
@@ -22,17 +22,35 @@ import (
 	"syscall"
 
 	"github.com/aws/amazon-ssm-agent/agent/agent"
+	"github.com/aws/amazon-ssm-agent/agent/agentstate"
 
+	"github.com/aws/amazon-ssm-agent/agent/activitymetrics"
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/auditexport"
+	"github.com/aws/amazon-ssm-agent/agent/bootdocuments"
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/elevation"
+	"github.com/aws/amazon-ssm-agent/agent/errordedup"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremodules"
 	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/hibernation"
+	"github.com/aws/amazon-ssm-agent/agent/liveness"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage"
+	"github.com/aws/amazon-ssm-agent/agent/provisioning"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
+	"github.com/aws/amazon-ssm-agent/agent/rolemonitor"
+	"github.com/aws/amazon-ssm-agent/agent/selfintegrity"
+	"github.com/aws/amazon-ssm-agent/agent/session"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/telemetry"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+	"github.com/aws/amazon-ssm-agent/agent/webhooks"
 )
 
 const (
@@ -50,14 +68,24 @@ var (
 	register, clear, force, fpFlag       bool
 	similarityThreshold                  int
 	registrationFile                     = filepath.Join(appconfig.DefaultDataStorePath, "registration")
+	provisioned                          provisioning.Overrides
 )
 
 func start(log logger.T, instanceIDPtr *string, regionPtr *string, shouldCheckHibernation bool) (ssmAgent agent.ISSMAgent, err error) {
+	// an instance launched from an image of another instance must not reuse its registration
+	agentstate.ScrubOnInstanceChange(log)
+
 	config, err := appconfig.Config(true)
 	if err != nil {
 		log.Debugf("appconfig could not be loaded - %v", err)
 		return
 	}
+	// every outbound connection, including iot registration, uses the configured tls policy
+	network.ApplyTLSPolicy(log)
+
+	// edge devices register with their AWS IoT identity before the agent starts
+	registerIotDevice(log)
+
 	context := context.Default(log, config)
 
 	//Initializing the health module to send empty health pings to the service.
@@ -93,11 +121,42 @@ func startAgent(ssmAgent agent.ISSMAgent, context context.T, log logger.T, insta
 	}
 	ssmAgent.SetCoreManager(cpm)
 
+	// the upload queue runs and telemetry is exported from the start, so the boot documents are included
+	instanceID, _ := platform.InstanceID()
+	uploadqueue.Start(log, context.AppConfig().Uploads, instanceID)
+	errordedup.Configure(context.AppConfig().ErrorReporting)
+	telemetry.Start(log, context.AppConfig().Telemetry, instanceID)
+	agentevents.Start(log, context.AppConfig().Events, instanceID)
+	activitymetrics.Start(log, context.AppConfig().ActivityMetrics, instanceID)
+	webhooks.Start(log, context.AppConfig().Webhooks, instanceID)
+	auditexport.Start(log, context.AppConfig().AuditExport, instanceID)
+
+	// binaries that do not match their signed manifest are reported, and the agent does not start when it is enforced
+	if err = selfintegrity.Check(log, context.AppConfig().SelfIntegrity); err != nil {
+		agentevents.Stop()
+		return
+	}
+
+	// the liveness signal is given from here on, the loops of the core modules register as they start
+	liveness.Start(log, context.AppConfig().Liveness)
+	rolemonitor.Start(log, context.AppConfig().RoleMonitor)
+	elevation.Check(log, context.AppConfig().Rootless)
+
+	// the sockets and users left by a crashed agent are reclaimed before any document creates its channel
+	session.ReclaimStaleResources(log)
+
+	// boot documents run before the core modules start polling for work
+	bootdocuments.Run(context)
+
+	// packages left behind by earlier runs are cleaned up in the background
+	go configurepackage.CollectRepositoryGarbage(context)
+
 	ssmAgent.Start()
 	return
 }
 
-func blockUntilSignaled(log logger.T) {
+// blockUntilSignaled returns once the agent is asked to exit, or with true once it is unhealthy
+func blockUntilSignaled(log logger.T) (unhealthy bool) {
 	// Below channel will handle all machine initiated shutdown/reboot requests.
 
 	// Set up channel on which to receive signal notifications.
@@ -110,8 +169,31 @@ func blockUntilSignaled(log logger.T) {
 	// Otherwise we will continue execution and exit the program.
 	signal.Notify(c, os.Interrupt, os.Kill, syscall.SIGTERM)
 
-	s := <-c
-	log.Info("Got signal:", s, " value:", s.Signal)
+	select {
+	case s := <-c:
+		log.Info("Got signal:", s, " value:", s.Signal)
+		return false
+	case <-liveness.Unhealthy():
+		log.Error("Stopping the unhealthy agent, it exits with a failure to be restarted")
+		return true
+	}
+}
+
+// applyContainerMode exports the container mode flag to the workers and makes instance metadata reachable from the container.
+// It returns true when the agent runs in container mode.
+func applyContainerMode(log logger.T) bool {
+	if config, _ := appconfig.Config(false); config.Agent.ContainerMode {
+		os.Setenv(appconfig.ContainerModeEnvVar, "true")
+	}
+	if !platform.IsContainerMode(log) {
+		return false
+	}
+
+	log.Info("Running in container mode, plugins managing the host are disabled")
+	if err := platform.SetupContainerNetworking(log); err != nil {
+		log.Warnf("%v, instance metadata may not be reachable, check that the metadata hop limit allows container requests", err)
+	}
+	return true
 }
 
 // Run as a single process. Used by Unix systems and when running agent from console.
@@ -131,6 +213,10 @@ func run(log logger.T) {
 		log.Errorf("error occurred when starting amazon-ssm-agent: %v", err)
 		return
 	}
-	blockUntilSignaled(log)
+	unhealthy := blockUntilSignaled(log)
 	agent.Stop()
+	if unhealthy {
+		log.Flush()
+		os.Exit(appconfig.ErrorExitCode)
+	}
 }
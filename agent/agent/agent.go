@@ -17,11 +17,19 @@ package agent
 import (
 	"runtime"
 
+	"github.com/aws/amazon-ssm-agent/agent/activitymetrics"
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
+	"github.com/aws/amazon-ssm-agent/agent/auditexport"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/framework/coremanager"
 	"github.com/aws/amazon-ssm-agent/agent/health"
 	"github.com/aws/amazon-ssm-agent/agent/hibernation"
+	"github.com/aws/amazon-ssm-agent/agent/liveness"
+	"github.com/aws/amazon-ssm-agent/agent/rolemonitor"
+	"github.com/aws/amazon-ssm-agent/agent/telemetry"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
 	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/aws/amazon-ssm-agent/agent/webhooks"
 )
 
 type ISSMAgent interface {
@@ -100,6 +108,16 @@ func (agent *SSMAgent) Stop() {
 	}
 
 	agent.coreManager.Stop()
+	// the telemetry and the events of the documents stopped with the core modules is exported before the agent exits
+	telemetry.Stop()
+	agentevents.Stop()
+	activitymetrics.Stop()
+	webhooks.Stop()
+	auditexport.Stop()
+	liveness.Stop()
+	rolemonitor.Stop()
+	// the uploads that don't complete in time resume when the agent starts again
+	uploadqueue.Stop()
 	log.Info("Bye.")
 	log.Flush()
 }
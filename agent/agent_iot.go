@@ -0,0 +1,86 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package main represents the entry point of the agent.
+// Iot contains the registration of edge devices with their AWS IoT identity
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	logger "github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/iotcreds"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+const iotActivationExpiry = time.Hour
+
+// registerIotDevice registers the device as a managed instance with the credentials of its AWS IoT role alias,
+// when the agent is configured with an IoT identity and the device is not registered yet.
+// The role alias creates a single use activation for the device, so no activation needs to be distributed.
+func registerIotDevice(log logger.T) {
+	config, err := appconfig.Config(false)
+	if err != nil || !config.Iot.Enabled() || registration.InstanceID() != "" {
+		return
+	}
+
+	log.Infof("Registering IoT thing %v as a managed instance", config.Iot.ThingName)
+	creds, err := iotcreds.NewCredentials(config.Iot)
+	if err != nil {
+		log.Errorf("IoT registration failed due to %v", err)
+		return
+	}
+	iotActivationCode, iotActivationID, err := createIotActivation(config, creds)
+	if err != nil {
+		log.Errorf("IoT registration failed due to %v", err)
+		return
+	}
+	managedInstanceID, err := registerManagedInstance(iotActivationCode, iotActivationID, config.Iot.Region)
+	if err != nil {
+		log.Errorf("IoT registration failed due to %v", err)
+		return
+	}
+	log.Infof("Successfully registered IoT thing %v with AWS SSM using Managed instance-id: %s", config.Iot.ThingName, managedInstanceID)
+}
+
+// createIotActivation creates an activation for the device, limited to one registration.
+func createIotActivation(config appconfig.SsmagentConfig, creds *credentials.Credentials) (code string, id string, err error) {
+	awsConfig := aws.NewConfig().WithRegion(config.Iot.Region).WithCredentials(creds)
+	if config.Ssm.Endpoint != "" {
+		awsConfig.Endpoint = &config.Ssm.Endpoint
+	} else if defaultEndpoint := appconfig.GetDefaultEndPoint(config.Iot.Region, "ssm"); defaultEndpoint != "" {
+		awsConfig.Endpoint = &defaultEndpoint
+	}
+
+	input := &ssm.CreateActivationInput{
+		IamRole:           aws.String(config.Iot.ServiceRole),
+		RegistrationLimit: aws.Int64(1),
+		ExpirationDate:    aws.Time(time.Now().Add(iotActivationExpiry)),
+		Description:       aws.String(fmt.Sprintf("AWS IoT thing %v", config.Iot.ThingName)),
+	}
+	if config.Iot.ThingName != "" {
+		input.DefaultInstanceName = aws.String(config.Iot.ThingName)
+	}
+
+	output, err := ssm.New(session.New(awsConfig)).CreateActivation(input)
+	if err != nil {
+		return "", "", fmt.Errorf("error creating the device activation. %v", err)
+	}
+	return *output.ActivationCode, *output.ActivationId, nil
+}
@@ -85,6 +85,9 @@ func flagUsage() {
 
 // processRegistration handles flags related to the registration category
 func processRegistration(log logger.T) (exitCode int) {
+	if region == "" {
+		region = provisioned.Region
+	}
 	if activationCode == "" || activationID == "" || region == "" {
 		// clear registration
 		if clear {
@@ -108,7 +111,7 @@ func processRegistration(log logger.T) (exitCode int) {
 		}
 	}
 
-	managedInstanceID, err := registerManagedInstance()
+	managedInstanceID, err := registerManagedInstance(activationCode, activationID, region)
 	if err != nil {
 		log.Errorf("Registration failed due to %v", err)
 		return 1
@@ -129,7 +132,7 @@ func processFingerprint(log logger.T) (exitCode int) {
 }
 
 // registerManagedInstance checks for activation credentials and performs managed instance registration when present
-func registerManagedInstance() (managedInstanceID string, err error) {
+func registerManagedInstance(activationCode, activationID, region string) (managedInstanceID string, err error) {
 	// try to activate the instance with the activation credentials
 	publicKey, privateKey, keyType, err := registration.GenerateKeyPair()
 	if err != nil {
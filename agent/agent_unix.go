@@ -2,7 +2,10 @@
 
 package main
 
-import logger "github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+import (
+	logger "github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/provisioning"
+)
 
 func main() {
 	// initialize logger
@@ -10,6 +13,11 @@ func main() {
 	defer log.Close()
 	defer log.Flush()
 
+	// apply the overrides supplied at instance launch before registration
+	provisioned = provisioning.Apply(log)
+
+	applyContainerMode(log)
+
 	// parse input parameters
 	parseFlags(log)
 
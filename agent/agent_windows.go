@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/liveness"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/cloudwatch"
+	"github.com/aws/amazon-ssm-agent/agent/provisioning"
 	"github.com/aws/amazon-ssm-agent/agent/proxyconfig"
 	"golang.org/x/sys/windows/registry"
 	"golang.org/x/sys/windows/svc"
@@ -54,6 +56,12 @@ func main() {
 
 	proxyconfig.SetProxySettings(log)
 
+	// apply the overrides supplied at instance launch before registration
+	provisioned = provisioning.Apply(log)
+
+	// containers have no service control manager, the agent runs in the foreground
+	containerMode := applyContainerMode(log)
+
 	log.Infof("Proxy environment variables:")
 	for _, name := range []string{"http_proxy", "https_proxy", "no_proxy"} {
 		log.Infof(name + ": " + os.Getenv(name))
@@ -70,7 +78,7 @@ func main() {
 
 	// isIntSess is false by default (after declaration), this fits the use
 	// case that agent is running as Windows service most of times
-	switch isIntSess {
+	switch isIntSess || containerMode {
 	case true:
 		run(log)
 	case false:
@@ -196,7 +204,16 @@ loop:
 	// using an infinite loop to wait for ChangeRequests
 	for {
 		// block and wait for ChangeRequests
-		c := <-r
+		var c svc.ChangeRequest
+		select {
+		case c = <-r:
+		case <-liveness.Unhealthy():
+			// the service ends without reporting it stopped, so that its recovery actions restart it
+			log.Error("Stopping the unhealthy agent, it exits with a failure to be restarted")
+			agent.Stop()
+			log.Flush()
+			os.Exit(appconfig.ErrorExitCode)
+		}
 
 		// handle ChangeRequest, svc.Pause is not supported
 		switch c.Cmd {
@@ -0,0 +1,164 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package agentevents publishes the significant events of the agent to an Amazon EventBridge event bus.
+// The publishing is disabled unless it is enabled in the agent configuration, then every function of the package is a no-op.
+package agentevents
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Detail types of the published events
+const (
+	// CommandFailed is published when a command or an association finishes with a failed or timed out status
+	CommandFailed = "Command Failed"
+	// SessionStarted is published when a Session Manager session starts on the instance
+	SessionStarted = "Session Started"
+	// RebootPending is published when a document requests a reboot of the instance
+	RebootPending = "Reboot Pending"
+	// UpdateApplied is published when the agent is updated to a new version
+	UpdateApplied = "Update Applied"
+	// ErrorsSuppressed is published at a flush for every error whose events were suppressed since the last flush,
+	// with the count and the first and last occurrence of the suppressed events
+	ErrorsSuppressed = "Errors Suppressed"
+	// IntegrityViolation is published at startup when the agent binaries do not match their signed manifest
+	IntegrityViolation = "Integrity Violation"
+)
+
+// ErrorDetail is the key of the error in the detail of an event, the events of the same error are deduplicated
+const ErrorDetail = "error"
+
+// maxBufferedEvents is the number of events kept between two flushes, further events are dropped
+const maxBufferedEvents = 1000
+
+// event is a published event waiting for the next flush
+type event struct {
+	detailType string
+	detail     string
+	time       time.Time
+}
+
+var (
+	lock      sync.Mutex
+	publisher *eventPublisher
+	pending   []event
+	dropped   int64
+)
+
+// Start begins publishing the events of the agent when it is enabled by the configuration
+func Start(log log.T, config appconfig.EventsCfg, instanceID string) {
+	if !config.Enabled {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if publisher != nil {
+		return
+	}
+	publisher = newEventPublisher(log, config, instanceID)
+	log.Infof("Publishing agent events to EventBridge every %v seconds", config.FlushIntervalSeconds)
+	go publisher.run()
+}
+
+// Stop publishes the remaining events and stops the publishing
+func Stop() {
+	lock.Lock()
+	current := publisher
+	publisher = nil
+	lock.Unlock()
+	if current != nil {
+		current.stop()
+	}
+}
+
+// Enabled returns whether events are published
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return publisher != nil
+}
+
+// Publish buffers an event until the next flush, unless its detail type is filtered out by the configuration.
+// The instance id and the agent version are added to the detail. The events of an error beyond the events of the
+// window are suppressed, and summarized by an ErrorsSuppressed event.
+func Publish(detailType string, detail map[string]string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if publisher == nil || !publisher.accepts(detailType) {
+		return
+	}
+	if message, found := detail[ErrorDetail]; found && !publisher.errorsOf(detailType).Report(message, time.Now()) {
+		return
+	}
+	appendEvent(publisher, detailType, detail)
+}
+
+// appendEvent buffers an event of a publisher, the lock must be held
+func appendEvent(p *eventPublisher, detailType string, detail map[string]string) {
+	if len(pending) >= maxBufferedEvents {
+		dropped++
+		return
+	}
+	content, err := json.Marshal(p.eventDetail(detail))
+	if err != nil {
+		p.log.Warnf("Failed to serialize the %v event: %v", detailType, err)
+		return
+	}
+	pending = append(pending, event{detailType: detailType, detail: string(content), time: time.Now()})
+}
+
+// appendSuppressedErrors buffers an ErrorsSuppressed event for every error whose events were suppressed
+func appendSuppressedErrors(p *eventPublisher) {
+	lock.Lock()
+	defer lock.Unlock()
+	for detailType, errors := range p.errors {
+		for _, summary := range errors.Flush() {
+			appendEvent(p, ErrorsSuppressed, map[string]string{
+				"detailType":  detailType,
+				"fingerprint": summary.Fingerprint,
+				"count":       strconv.FormatInt(summary.Count, 10),
+				"firstTime":   summary.First.UTC().Format(time.RFC3339),
+				"lastTime":    summary.Last.UTC().Format(time.RFC3339),
+				"firstError":  summary.FirstMessage,
+				"lastError":   summary.LastMessage,
+			})
+		}
+	}
+}
+
+// takeEvents returns the buffered events and the number of events dropped since the last flush
+func takeEvents() (buffered []event, droppedEvents int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	buffered, pending = pending, nil
+	droppedEvents, dropped = dropped, 0
+	return buffered, droppedEvents
+}
+
+// requeueEvents puts back events whose publishing failed ahead of the events buffered since, within the buffer limit
+func requeueEvents(failed []event) {
+	lock.Lock()
+	defer lock.Unlock()
+	if room := maxBufferedEvents - len(pending); len(failed) > room {
+		dropped += int64(len(failed) - room)
+		failed = failed[len(failed)-room:]
+	}
+	pending = append(append([]event{}, failed...), pending...)
+}
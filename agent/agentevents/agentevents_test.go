@@ -0,0 +1,159 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package agentevents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventBus records the batches sent to it and fails the sends while err is set
+type eventBus struct {
+	batches []*putEventsInput
+	err     error
+}
+
+func (bus *eventBus) send(input *putEventsInput) (*putEventsOutput, error) {
+	if bus.err != nil {
+		return nil, bus.err
+	}
+	bus.batches = append(bus.batches, input)
+	return &putEventsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func startTestEvents(bus *eventBus, detailTypes []string) {
+	pending, dropped = nil, 0
+	newSender = func() sendFunc { return bus.send }
+	Start(log.NewMockLog(), appconfig.EventsCfg{
+		Enabled:              true,
+		EventBusName:         "fleet",
+		Source:               appconfig.DefaultEventsSource,
+		DetailTypes:          detailTypes,
+		FlushIntervalSeconds: 300,
+	}, "i-123")
+}
+
+func TestDisabled(t *testing.T) {
+	pending = nil
+	Start(log.NewMockLog(), appconfig.EventsCfg{FlushIntervalSeconds: 1}, "i-123")
+
+	Publish(CommandFailed, map[string]string{"commandId": "c-1"})
+	assert.False(t, Enabled())
+	assert.Empty(t, pending)
+}
+
+func TestPublishInBatches(t *testing.T) {
+	bus := &eventBus{}
+	startTestEvents(bus, nil)
+
+	for i := 0; i < 12; i++ {
+		Publish(CommandFailed, map[string]string{"commandId": "c-1", "status": "Failed"})
+	}
+	Stop()
+
+	assert.Len(t, bus.batches, 2)
+	assert.Len(t, bus.batches[0].Entries, maxBatchEntries)
+	assert.Len(t, bus.batches[1].Entries, 2)
+	entry := bus.batches[0].Entries[0]
+	assert.Equal(t, CommandFailed, aws.StringValue(entry.DetailType))
+	assert.Equal(t, "fleet", aws.StringValue(entry.EventBusName))
+	assert.Equal(t, appconfig.DefaultEventsSource, aws.StringValue(entry.Source))
+	assert.NotNil(t, entry.Time)
+	var detail map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(entry.Detail)), &detail))
+	assert.Equal(t, "i-123", detail["instanceId"])
+	assert.Equal(t, "c-1", detail["commandId"])
+	assert.Equal(t, "Failed", detail["status"])
+	assert.NotEmpty(t, detail["agentVersion"])
+}
+
+func TestPublishFiltersDetailTypes(t *testing.T) {
+	bus := &eventBus{}
+	startTestEvents(bus, []string{RebootPending})
+
+	Publish(CommandFailed, nil)
+	Publish(RebootPending, nil)
+	Stop()
+
+	assert.Len(t, bus.batches, 1)
+	assert.Len(t, bus.batches[0].Entries, 1)
+	assert.Equal(t, RebootPending, aws.StringValue(bus.batches[0].Entries[0].DetailType))
+}
+
+func TestFailedBatchesAreRequeued(t *testing.T) {
+	bus := &eventBus{err: errors.New("throttled")}
+	startTestEvents(bus, nil)
+	Publish(SessionStarted, map[string]string{"sessionId": "s-1"})
+
+	publisher.flush(true)
+	assert.Empty(t, bus.batches)
+	assert.Len(t, pending, 1)
+
+	bus.err = nil
+	Publish(UpdateApplied, nil)
+	Stop()
+	assert.Len(t, bus.batches, 1)
+	assert.Len(t, bus.batches[0].Entries, 2)
+	assert.Equal(t, SessionStarted, aws.StringValue(bus.batches[0].Entries[0].DetailType))
+	assert.Equal(t, UpdateApplied, aws.StringValue(bus.batches[0].Entries[1].DetailType))
+}
+
+func TestEventsAboveBufferAreDropped(t *testing.T) {
+	bus := &eventBus{}
+	startTestEvents(bus, nil)
+
+	for i := 0; i < maxBufferedEvents+5; i++ {
+		Publish(CommandFailed, nil)
+	}
+	assert.Len(t, pending, maxBufferedEvents)
+	assert.Equal(t, int64(5), dropped)
+	Stop()
+
+	assert.Len(t, bus.batches, maxBufferedEvents/maxBatchEntries)
+	assert.Equal(t, int64(0), dropped)
+}
+
+func TestRepeatedErrorsAreSuppressed(t *testing.T) {
+	bus := &eventBus{}
+	startTestEvents(bus, nil)
+
+	for i := 0; i < appconfig.DefaultErrorReportsPerWindow+5; i++ {
+		Publish(CommandFailed, map[string]string{"commandId": fmt.Sprintf("c-%v", i), ErrorDetail: "AccessDenied: status code: 403"})
+	}
+	Publish(CommandFailed, map[string]string{"commandId": "c-other", ErrorDetail: "exit status 1"})
+	assert.Len(t, pending, appconfig.DefaultErrorReportsPerWindow+1)
+	Stop()
+
+	var entries []*putEventsEntry
+	for _, batch := range bus.batches {
+		entries = append(entries, batch.Entries...)
+	}
+	assert.Len(t, entries, appconfig.DefaultErrorReportsPerWindow+2)
+	summary := entries[len(entries)-1]
+	assert.Equal(t, ErrorsSuppressed, aws.StringValue(summary.DetailType))
+	var detail map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(summary.Detail)), &detail))
+	assert.Equal(t, CommandFailed, detail["detailType"])
+	assert.Equal(t, "AccessDenied: status code: <n>", detail["fingerprint"])
+	assert.Equal(t, "5", detail["count"])
+	assert.Equal(t, "AccessDenied: status code: 403", detail["lastError"])
+	assert.Equal(t, "i-123", detail["instanceId"])
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package agentevents
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/errordedup"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+)
+
+// maxBatchEntries is the largest number of entries accepted by one PutEvents call
+const maxBatchEntries = 10
+
+// putEventsInput is the input of the EventBridge PutEvents API with the event bus of the entries,
+// which the sdk of the agent predates
+type putEventsInput struct {
+	_       struct{}          `type:"structure"`
+	Entries []*putEventsEntry `min:"1" type:"list" required:"true"`
+}
+
+// putEventsEntry is an event of the PutEvents input
+type putEventsEntry struct {
+	_            struct{}   `type:"structure"`
+	Detail       *string    `type:"string"`
+	DetailType   *string    `type:"string"`
+	EventBusName *string    `type:"string"`
+	Source       *string    `type:"string"`
+	Time         *time.Time `type:"timestamp" timestampFormat:"unix"`
+}
+
+// putEventsOutput is the output of the EventBridge PutEvents API
+type putEventsOutput struct {
+	_                struct{}                                 `type:"structure"`
+	Entries          []*cloudwatchevents.PutEventsResultEntry `type:"list"`
+	FailedEntryCount *int64                                   `type:"integer"`
+}
+
+// sendFunc posts a batch of events to EventBridge
+type sendFunc func(input *putEventsInput) (*putEventsOutput, error)
+
+// newSender returns the function posting the events, it is replaced by the tests
+var newSender = func() sendFunc {
+	appConfig, _ := appconfig.Config(false)
+	sess := session.New(sdkutil.AwsConfig())
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
+	client := cloudwatchevents.New(sess)
+	return func(input *putEventsInput) (*putEventsOutput, error) {
+		output := &putEventsOutput{}
+		operation := &request.Operation{Name: "PutEvents", HTTPMethod: "POST", HTTPPath: "/"}
+		return output, client.NewRequest(operation, input, output).Send()
+	}
+}
+
+// eventPublisher publishes the buffered events in batches at every interval
+type eventPublisher struct {
+	log          log.T
+	send         sendFunc
+	eventBusName string
+	source       string
+	detailTypes  map[string]bool
+	instanceID   string
+	interval     time.Duration
+	failing      bool
+	stopChan     chan struct{}
+	stopOnce     sync.Once
+	done         chan struct{}
+	// errors limits the events of every detail type with the same error, they are guarded by the lock of the events
+	errors map[string]*errordedup.Deduplicator
+}
+
+func newEventPublisher(log log.T, config appconfig.EventsCfg, instanceID string) *eventPublisher {
+	var detailTypes map[string]bool
+	if len(config.DetailTypes) > 0 {
+		detailTypes = make(map[string]bool)
+		for _, detailType := range config.DetailTypes {
+			detailTypes[detailType] = true
+		}
+	}
+	return &eventPublisher{
+		log:          log,
+		send:         newSender(),
+		eventBusName: config.EventBusName,
+		source:       config.Source,
+		detailTypes:  detailTypes,
+		instanceID:   instanceID,
+		interval:     time.Duration(config.FlushIntervalSeconds) * time.Second,
+		errors:       make(map[string]*errordedup.Deduplicator),
+		stopChan:     make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// accepts returns whether events of the detail type are published, all of them are without a filter
+func (p *eventPublisher) accepts(detailType string) bool {
+	return p.detailTypes == nil || p.detailTypes[detailType]
+}
+
+// errorsOf returns the deduplicator of the errors of a detail type
+func (p *eventPublisher) errorsOf(detailType string) *errordedup.Deduplicator {
+	errors, found := p.errors[detailType]
+	if !found {
+		errors = errordedup.New()
+		p.errors[detailType] = errors
+	}
+	return errors
+}
+
+// eventDetail adds the identity of the agent to the detail of an event
+func (p *eventPublisher) eventDetail(detail map[string]string) map[string]string {
+	content := map[string]string{
+		"instanceId":   p.instanceID,
+		"agentVersion": version.Version,
+	}
+	for key, value := range detail {
+		content[key] = value
+	}
+	return content
+}
+
+// run flushes at every interval until the publisher is stopped, then flushes one last time
+func (p *eventPublisher) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.flush(true)
+		case <-p.stopChan:
+			p.flush(false)
+			return
+		}
+	}
+}
+
+func (p *eventPublisher) stop() {
+	p.stopOnce.Do(func() { close(p.stopChan) })
+	<-p.done
+}
+
+// flush publishes the buffered events, the batches that could not be sent are kept for the next flush when requeue is set.
+// A failed publishing is logged once until a publishing succeeds again.
+func (p *eventPublisher) flush(requeue bool) {
+	appendSuppressedErrors(p)
+	buffered, droppedEvents := takeEvents()
+	if droppedEvents > 0 {
+		p.log.Warnf("Dropped %v agent events, more than %v events were buffered", droppedEvents, maxBufferedEvents)
+	}
+	var err error
+	var unsent []event
+	for start := 0; start < len(buffered); start += maxBatchEntries {
+		end := start + maxBatchEntries
+		if end > len(buffered) {
+			end = len(buffered)
+		}
+		batch := buffered[start:end]
+		output, sendErr := p.send(p.batchInput(batch))
+		if sendErr != nil {
+			err = sendErr
+			unsent = append(unsent, batch...)
+			continue
+		}
+		if failed := aws.Int64Value(output.FailedEntryCount); failed > 0 {
+			err = fmt.Errorf("%v of %v events were rejected: %v", failed, len(batch), firstEntryError(output))
+		}
+	}
+	if requeue && len(unsent) > 0 {
+		requeueEvents(unsent)
+	}
+	if err != nil && !p.failing {
+		p.log.Warnf("Failed to publish agent events to EventBridge: %v", err)
+	} else if err == nil && p.failing {
+		p.log.Infof("Publishing agent events to EventBridge again")
+	}
+	p.failing = err != nil
+}
+
+// batchInput builds the PutEvents input of a batch of events
+func (p *eventPublisher) batchInput(batch []event) *putEventsInput {
+	input := &putEventsInput{}
+	for _, e := range batch {
+		entry := &putEventsEntry{
+			Detail:     aws.String(e.detail),
+			DetailType: aws.String(e.detailType),
+			Source:     aws.String(p.source),
+			Time:       aws.Time(e.time),
+		}
+		if p.eventBusName != "" {
+			entry.EventBusName = aws.String(p.eventBusName)
+		}
+		input.Entries = append(input.Entries, entry)
+	}
+	return input
+}
+
+// firstEntryError returns the error of the first rejected entry of a PutEvents output
+func firstEntryError(output *putEventsOutput) string {
+	for _, entry := range output.Entries {
+		if entry != nil && entry.ErrorCode != nil {
+			return fmt.Sprintf("%v %v", aws.StringValue(entry.ErrorCode), aws.StringValue(entry.ErrorMessage))
+		}
+	}
+	return "unknown error"
+}
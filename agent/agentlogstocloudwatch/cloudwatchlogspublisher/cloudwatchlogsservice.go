@@ -25,6 +25,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -51,6 +52,8 @@ type CloudWatchLogsService struct {
 	stopPolicy           *sdkutil.StopPolicy
 	IsFileComplete       bool
 	IsUploadComplete     bool
+	// UploadPriority orders the events streamed by StreamData in the upload queue, command output by default
+	UploadPriority uploadqueue.Priority
 }
 
 // createCloudWatchStopPolicy creates a new policy for cloudwatchlogs
@@ -79,7 +82,7 @@ func createCloudWatchClientWithConfig(config *aws.Config) cloudwatchlogsinterfac
 
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 	return cloudwatchlogs.New(sess)
 }
 
@@ -475,7 +478,10 @@ func (service *CloudWatchLogsService) StreamData(log log.T, logGroupName string,
 
 		sequenceToken := service.GetSequenceTokenForStream(log, logGroupName, logStreamName)
 
-		_, err := service.PutLogEvents(log, events, logGroupName, logStreamName, sequenceToken)
+		err := uploadqueue.Run(log, service.UploadPriority, "events of "+absoluteFilePath+" to CloudWatch", eventsSize(events), func() error {
+			_, err := service.PutLogEvents(log, events, logGroupName, logStreamName, sequenceToken)
+			return err
+		})
 		if err == nil {
 			// Set the last known line to current since the upload was successful.
 			lastKnownLineUploadedToCWL = currentLineNumber
@@ -488,6 +494,16 @@ func (service *CloudWatchLogsService) StreamData(log log.T, logGroupName string,
 	}
 }
 
+// eventsSize returns the size of the messages of the events
+func eventsSize(events []*cloudwatchlogs.InputLogEvent) (size int64) {
+	for _, event := range events {
+		if event.Message != nil {
+			size += int64(len(*event.Message))
+		}
+	}
+	return size
+}
+
 //getNextMessage gets the next message to be uploaded to cloudwatch.
 func (service *CloudWatchLogsService) getNextMessage(log log.T, absoluteFilePath string, lastKnownLineUploadedToCWL *int64, currentLineNumber *int64) (allEvents []*cloudwatchlogs.InputLogEvent, eof bool) {
 	// Open file to read.
@@ -0,0 +1,232 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package agentstate exports and imports the relocatable state of the agent, and scrubs the identity state
+// copied with an image when the agent starts on a different instance.
+package agentstate
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const manifestEntryName = "agentstate.json"
+
+// stateDirectory is a directory of relocatable state and the name it is archived under
+type stateDirectory struct {
+	Name string
+	Path string
+}
+
+// stateDirectories are the directories holding state that is not tied to the identity of the instance.
+// The registration, the vault and the per instance document state are never exported.
+var stateDirectories = []stateDirectory{
+	{Name: "packages", Path: appconfig.PackageRoot},
+	{Name: "packageartifacts", Path: appconfig.PackageArtifactCacheDirectory},
+	{Name: "packageintegrity", Path: appconfig.PackageIntegrityDirectory},
+	{Name: "sbom", Path: appconfig.SbomDirectory},
+	{Name: "manifests", Path: appconfig.ManifestCacheDirectory},
+	{Name: "daemons", Path: appconfig.DaemonRoot},
+	{Name: "localschedules", Path: appconfig.LocalScheduleRoot},
+}
+
+// manifest describes the content of an agent state archive
+type manifest struct {
+	AgentVersion string   `json:"agentVersion"`
+	ExportTime   string   `json:"exportTime"`
+	Directories  []string `json:"directories"`
+}
+
+// Export writes the relocatable state of the agent to a zip archive at archivePath
+// and returns the names of the directories that were exported.
+func Export(log log.T, archivePath string) (exported []string, err error) {
+	archive, err := os.OpenFile(archivePath, appconfig.FileFlagsCreateOrTruncate, appconfig.ReadWriteAccess)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %v: %v", archivePath, err)
+	}
+	defer func() {
+		if closeErr := archive.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	writer := zip.NewWriter(archive)
+	defer func() {
+		if closeErr := writer.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	exported = []string{}
+	for _, dir := range stateDirectories {
+		if !fileutil.Exists(dir.Path) {
+			continue
+		}
+		count := 0
+		walkErr := filepath.Walk(dir.Path, func(filePath string, info os.FileInfo, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, relErr := filepath.Rel(dir.Path, filePath)
+			if relErr != nil {
+				return relErr
+			}
+			count++
+			return addFile(writer, dir.Name+"/"+filepath.ToSlash(rel), filePath, info)
+		})
+		if walkErr != nil {
+			return exported, fmt.Errorf("failed to export %v: %v", dir.Path, walkErr)
+		}
+		log.Debugf("Exported %v files from %v", count, dir.Path)
+		exported = append(exported, dir.Name)
+	}
+
+	data, err := json.Marshal(manifest{
+		AgentVersion: version.Version,
+		ExportTime:   times.ToIso8601UTC(time.Now()),
+		Directories:  exported,
+	})
+	if err != nil {
+		return exported, err
+	}
+	w, err := writer.Create(manifestEntryName)
+	if err != nil {
+		return exported, err
+	}
+	_, err = w.Write(data)
+	return exported, err
+}
+
+// Import restores the state in the zip archive at archivePath written by Export and returns the names of the directories that were imported.
+// Files in the archive replace the files with the same path, other files are left in place.
+// The whole archive is validated before anything is written.
+func Import(log log.T, archivePath string) (imported []string, err error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %v: %v", archivePath, err)
+	}
+	defer reader.Close()
+
+	targets := make(map[*zip.File]string)
+	dirs := make(map[string]bool)
+	hasManifest := false
+	for _, f := range reader.File {
+		if f.Name == manifestEntryName {
+			hasManifest = true
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		target, dirName, err := targetPath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		targets[f] = target
+		dirs[dirName] = true
+	}
+	if !hasManifest {
+		return nil, fmt.Errorf("%v is not an agent state archive", archivePath)
+	}
+
+	for f, target := range targets {
+		if err = extractFile(f, target); err != nil {
+			return nil, fmt.Errorf("failed to import %v: %v", f.Name, err)
+		}
+	}
+	log.Debugf("Imported %v files from %v", len(targets), archivePath)
+
+	imported = make([]string, 0, len(dirs))
+	for name := range dirs {
+		imported = append(imported, name)
+	}
+	sort.Strings(imported)
+	return imported, nil
+}
+
+// targetPath maps the name of an archive entry to the file it is imported to
+func targetPath(entryName string) (target string, dirName string, err error) {
+	parts := strings.SplitN(entryName, "/", 2)
+	if len(parts) == 2 && parts[1] != "" {
+		for _, dir := range stateDirectories {
+			if dir.Name != parts[0] {
+				continue
+			}
+			target = filepath.Join(dir.Path, filepath.FromSlash(parts[1]))
+			if strings.HasPrefix(target, filepath.Clean(dir.Path)+string(filepath.Separator)) {
+				return target, dir.Name, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("archive entry %v is not agent state", entryName)
+}
+
+// addFile adds the file at filePath to the archive under the given entry name
+func addFile(writer *zip.Writer, name string, filePath string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// extractFile writes the content of an archive entry to target
+func extractFile(f *zip.File, target string) (err error) {
+	if err = fileutil.MakeDirs(filepath.Dir(target)); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(target, appconfig.FileFlagsCreateOrTruncate, f.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := dst.Close(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package agentstate
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// useTempStateDirectories points the state directories at a temporary directory and returns a func restoring them
+func useTempStateDirectories(root string) func() {
+	saved := stateDirectories
+	stateDirectories = []stateDirectory{
+		{Name: "packages", Path: filepath.Join(root, "packages")},
+		{Name: "daemons", Path: filepath.Join(root, "daemons")},
+	}
+	return func() { stateDirectories = saved }
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func TestExportImport(t *testing.T) {
+	source, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(source)
+	defer useTempStateDirectories(source)()
+	writeTestFile(t, filepath.Join(source, "packages", "pkg", "1.0", "install.sh"), "install")
+	writeTestFile(t, filepath.Join(source, "packages", "pkg", "installstate"), "Installed")
+	archivePath := filepath.Join(source, "state.zip")
+
+	exported, err := Export(log.NewMockLog(), archivePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"packages"}, exported)
+
+	target, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(target)
+	useTempStateDirectories(target)
+	writeTestFile(t, filepath.Join(target, "packages", "other", "installstate"), "Installed")
+
+	imported, err := Import(log.NewMockLog(), archivePath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"packages"}, imported)
+	content, err := ioutil.ReadFile(filepath.Join(target, "packages", "pkg", "1.0", "install.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "install", string(content))
+	assert.True(t, fileExists(filepath.Join(target, "packages", "other", "installstate")))
+}
+
+func writeTestArchive(t *testing.T, path string, names ...string) {
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	writer := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := writer.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte("content"))
+	}
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, f.Close())
+}
+
+func TestImportRejectsEntriesOutsideTheStateDirectories(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	defer useTempStateDirectories(root)()
+
+	for _, name := range []string{"Vault/Store/RegistrationKey", "packages/../../escape", "daemons"} {
+		archivePath := filepath.Join(root, "state.zip")
+		writeTestArchive(t, archivePath, manifestEntryName, "packages/pkg/installstate", name)
+
+		_, err := Import(log.NewMockLog(), archivePath)
+		assert.Error(t, err, name)
+		assert.False(t, fileExists(filepath.Join(root, "packages", "pkg", "installstate")), name)
+	}
+}
+
+func TestImportRequiresManifest(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	defer useTempStateDirectories(root)()
+	archivePath := filepath.Join(root, "state.zip")
+	writeTestArchive(t, archivePath, "packages/pkg/installstate")
+
+	_, err := Import(log.NewMockLog(), archivePath)
+	assert.Error(t, err)
+	assert.False(t, fileExists(filepath.Join(root, "packages", "pkg", "installstate")))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package agentstate
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fingerprint"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+)
+
+const (
+	identityFileName     = "instanceidentity.json"
+	registrationFileName = "registration"
+)
+
+// instanceIdentity records the EC2 instance the agent last started on.
+// An empty EC2InstanceID means the agent was not running on EC2 when the identity was recorded.
+type instanceIdentity struct {
+	EC2InstanceID string `json:"ec2InstanceId"`
+}
+
+var dataStorePath = appconfig.DefaultDataStorePath
+
+var getEC2InstanceID = platform.EC2InstanceID
+
+var getManagedInstanceID = registration.InstanceID
+
+var clearRegistration = func() error {
+	if err := registration.ClearServerInfo(); err != nil {
+		return err
+	}
+	return fingerprint.ClearFingerprint()
+}
+
+// ScrubOnInstanceChange compares the EC2 instance id with the one recorded at the previous start.
+// When it changed, the agent runs from an image of another instance, so the managed instance registration
+// and the document state of the previous instance are removed to avoid two instances sharing one identity.
+// It returns true when identity state was scrubbed. It must run before the instance id is first read.
+func ScrubOnInstanceChange(log log.T) bool {
+	identityPath := filepath.Join(dataStorePath, identityFileName)
+	var recorded instanceIdentity
+	hasRecord := false
+	if content, err := fileutil.ReadAllText(identityPath); err == nil {
+		if err = json.Unmarshal([]byte(content), &recorded); err != nil {
+			log.Warnf("Failed to parse %v, recording the instance identity again: %v", identityPath, err)
+		} else {
+			hasRecord = true
+		}
+	}
+	if hasRecord && recorded.EC2InstanceID == "" {
+		// the agent was not on EC2 when the identity was recorded, there is no instance id to compare
+		return false
+	}
+
+	currentID, err := getEC2InstanceID()
+	if err != nil {
+		if hasRecord {
+			log.Warnf("Failed to get the EC2 instance id, the instance identity is not checked: %v", err)
+			return false
+		}
+		currentID = ""
+	}
+	if hasRecord && currentID == recorded.EC2InstanceID {
+		return false
+	}
+
+	if hasRecord {
+		log.Warnf("Instance id changed from %v to %v, removing the identity state of the previous instance",
+			recorded.EC2InstanceID, currentID)
+		scrubIdentity(log, recorded.EC2InstanceID)
+	}
+
+	content, _ := json.Marshal(instanceIdentity{EC2InstanceID: currentID})
+	if err = fileutil.WriteAllText(identityPath, string(content)); err != nil {
+		log.Warnf("Failed to record the instance identity: %v", err)
+	}
+	return hasRecord
+}
+
+// scrubIdentity removes the managed instance registration and the per instance state of the previous instance
+func scrubIdentity(log log.T, previousInstanceID string) {
+	instanceDirs := []string{previousInstanceID}
+	if managedInstanceID := getManagedInstanceID(); managedInstanceID != "" {
+		log.Warnf("Removing the registration of managed instance %v, register the instance again if it should be a managed instance",
+			managedInstanceID)
+		if err := clearRegistration(); err != nil {
+			log.Errorf("Failed to remove the managed instance registration: %v", err)
+		}
+		if registrationFile := filepath.Join(dataStorePath, registrationFileName); fileutil.Exists(registrationFile) {
+			if err := fileutil.DeleteFile(registrationFile); err != nil {
+				log.Warnf("Failed to remove the registration file: %v", err)
+			}
+		}
+		instanceDirs = append(instanceDirs, managedInstanceID)
+	}
+
+	for _, instanceID := range instanceDirs {
+		if instanceID == "" || strings.ContainsAny(instanceID, `/\.`) {
+			continue
+		}
+		if err := fileutil.DeleteDirectory(filepath.Join(dataStorePath, instanceID)); err != nil {
+			log.Warnf("Failed to remove the state of instance %v: %v", instanceID, err)
+		}
+	}
+}
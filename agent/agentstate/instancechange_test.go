@@ -0,0 +1,119 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package agentstate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type instanceChangeTest struct {
+	root              string
+	ec2InstanceID     string
+	ec2Err            error
+	managedInstanceID string
+	cleared           bool
+}
+
+// setup replaces the dependencies of ScrubOnInstanceChange and returns a func restoring them
+func (test *instanceChangeTest) setup() func() {
+	savedPath, savedEC2, savedManaged, savedClear := dataStorePath, getEC2InstanceID, getManagedInstanceID, clearRegistration
+	dataStorePath = test.root
+	getEC2InstanceID = func() (string, error) { return test.ec2InstanceID, test.ec2Err }
+	getManagedInstanceID = func() string { return test.managedInstanceID }
+	clearRegistration = func() error {
+		test.cleared = true
+		return nil
+	}
+	return func() {
+		dataStorePath, getEC2InstanceID, getManagedInstanceID, clearRegistration = savedPath, savedEC2, savedManaged, savedClear
+	}
+}
+
+func TestScrubOnInstanceChange_RecordsIdentityAtFirstStart(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	test := &instanceChangeTest{root: root, ec2InstanceID: "i-1", managedInstanceID: "mi-1"}
+	defer test.setup()()
+
+	assert.False(t, ScrubOnInstanceChange(log.NewMockLog()))
+	assert.False(t, test.cleared)
+	content, err := ioutil.ReadFile(filepath.Join(root, identityFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ec2InstanceId":"i-1"}`, string(content))
+}
+
+func TestScrubOnInstanceChange_SameInstance(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	writeTestFile(t, filepath.Join(root, identityFileName), `{"ec2InstanceId":"i-1"}`)
+	writeTestFile(t, filepath.Join(root, "i-1", "document"), "state")
+	test := &instanceChangeTest{root: root, ec2InstanceID: "i-1", managedInstanceID: "mi-1"}
+	defer test.setup()()
+
+	assert.False(t, ScrubOnInstanceChange(log.NewMockLog()))
+	assert.False(t, test.cleared)
+	assert.True(t, fileExists(filepath.Join(root, "i-1", "document")))
+}
+
+func TestScrubOnInstanceChange_InstanceChanged(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	writeTestFile(t, filepath.Join(root, identityFileName), `{"ec2InstanceId":"i-1"}`)
+	writeTestFile(t, filepath.Join(root, "i-1", "document"), "state")
+	writeTestFile(t, filepath.Join(root, "mi-1", "document"), "state")
+	writeTestFile(t, filepath.Join(root, registrationFileName), "mi-1")
+	writeTestFile(t, filepath.Join(root, "packages", "pkg", "installstate"), "Installed")
+	test := &instanceChangeTest{root: root, ec2InstanceID: "i-2", managedInstanceID: "mi-1"}
+	defer test.setup()()
+
+	assert.True(t, ScrubOnInstanceChange(log.NewMockLog()))
+	assert.True(t, test.cleared)
+	assert.False(t, fileExists(filepath.Join(root, "i-1")))
+	assert.False(t, fileExists(filepath.Join(root, "mi-1")))
+	assert.False(t, fileExists(filepath.Join(root, registrationFileName)))
+	assert.True(t, fileExists(filepath.Join(root, "packages", "pkg", "installstate")))
+	content, _ := ioutil.ReadFile(filepath.Join(root, identityFileName))
+	assert.Equal(t, `{"ec2InstanceId":"i-2"}`, string(content))
+}
+
+func TestScrubOnInstanceChange_MetadataUnavailable(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	writeTestFile(t, filepath.Join(root, identityFileName), `{"ec2InstanceId":"i-1"}`)
+	test := &instanceChangeTest{root: root, ec2Err: errors.New("timeout"), managedInstanceID: "mi-1"}
+	defer test.setup()()
+
+	assert.False(t, ScrubOnInstanceChange(log.NewMockLog()))
+	assert.False(t, test.cleared)
+	content, _ := ioutil.ReadFile(filepath.Join(root, identityFileName))
+	assert.Equal(t, `{"ec2InstanceId":"i-1"}`, string(content))
+}
+
+func TestScrubOnInstanceChange_NotOnEC2(t *testing.T) {
+	root, _ := ioutil.TempDir("", "agentstate")
+	defer os.RemoveAll(root)
+	writeTestFile(t, filepath.Join(root, identityFileName), `{"ec2InstanceId":""}`)
+	test := &instanceChangeTest{root: root, ec2InstanceID: "i-2", managedInstanceID: "mi-1"}
+	defer test.setup()()
+
+	assert.False(t, ScrubOnInstanceChange(log.NewMockLog()))
+	assert.False(t, test.cleared)
+}
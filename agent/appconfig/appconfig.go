@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 
@@ -112,6 +113,10 @@ func DefaultConfig() SsmagentConfig {
 	var mgs = MgsConfig{
 		SessionWorkersLimit: DefaultSessionWorkersLimit,
 		StopTimeoutMillis:   DefaultStopTimeoutMillis,
+		SessionStartHook: SessionStartHookCfg{
+			TimeoutSeconds: DefaultSessionStartHookTimeoutSeconds,
+		},
+		IdleWorkerTimeoutMinutes: DefaultIdleWorkerTimeoutMinutes,
 	}
 	var ssm = SsmCfg{
 		HealthFrequencyMinutes:                DefaultSsmHealthFrequencyMinutes,
@@ -121,26 +126,189 @@ func DefaultConfig() SsmagentConfig {
 		AssociationLogsRetentionDurationHours: DefaultAssociationLogsRetentionDurationHours,
 		RunCommandLogsRetentionDurationHours:  DefaultRunCommandLogsRetentionDurationHours,
 		SessionLogsRetentionDurationHours:     DefaultSessionLogsRetentionDurationHours,
+		InteractivePrompt: InteractivePromptCfg{
+			Action:      InteractivePromptActionNone,
+			IdleSeconds: DefaultInteractivePromptIdleSeconds,
+		},
+		Workspace: WorkspaceCfg{
+			OverwritePasses: DefaultWorkspaceOverwritePasses,
+		},
+		StepRebootLimit: DefaultStepRebootLimit,
+		Inventory: InventoryCfg{
+			DeltaUploads:     true,
+			FullRefreshHours: DefaultInventoryFullRefreshHours,
+		},
+		DocumentCache: DocumentCacheCfg{
+			Enabled:    true,
+			MaxEntries: DefaultDocumentCacheMaxEntries,
+		},
 	}
 	var agent = AgentInfo{
 		Name:                 "amazon-ssm-agent",
 		OrchestrationRootDir: defaultOrchestrationRootDirName,
+		IpcTransport:         IpcTransportFile,
+		DualStack:            DualStackAuto,
+		WorkerLaunch:         WorkerLaunchProcess,
+		WorkerOutputBufferKB: DefaultWorkerOutputBufferKB,
 	}
 	var os = OsInfo{
 		Lang:    "en-US",
 		Version: "1",
 	}
-	var birdwatcher BirdwatcherCfg
+	var birdwatcher = BirdwatcherCfg{
+		ManifestCache: ManifestCacheCfg{
+			Backend:    ManifestCacheBackendFile,
+			TtlHours:   DefaultManifestCacheTtlHours,
+			MaxEntries: DefaultManifestCacheMaxEntries,
+			Directory:  ManifestCacheDirectory,
+		},
+		Sbom: SbomCfg{
+			Format: SbomFormatCycloneDX,
+		},
+		Integrity: PackageIntegrityCfg{
+			FrequencyMinutes: DefaultPackageIntegrityFrequencyMinutes,
+		},
+		Trace: PackageTraceCfg{
+			CollapseRepeatedSections: true,
+			MaxSectionOutputBytes:    DefaultPackageTraceMaxSectionOutputBytes,
+			MetricNamespace:          DefaultPackageTraceMetricNamespace,
+		},
+		InstallLogs: PackageInstallLogsCfg{
+			MaxFileSizeKB: DefaultPackageInstallLogMaxFileSizeKB,
+			MaxFiles:      DefaultPackageInstallLogMaxFiles,
+		},
+		Failover: FacadeFailoverCfg{
+			FailureThreshold: DefaultFacadeFailoverThreshold,
+		},
+		LockWaitSeconds:     DefaultPackageLockWaitSeconds,
+		DownloadParallelism: DefaultPackageDownloadParallelism,
+		ResultRetryMinutes:  DefaultPackageResultRetryMinutes,
+		Retention: PackageRetentionCfg{
+			KeepVersions: DefaultPackageKeepVersions,
+		},
+		PlatformAliases: map[string]string{
+			"rocky":     "redhat",
+			"almalinux": "redhat",
+			"mac_os_x":  "darwin",
+		},
+	}
+	var throttle = ThrottleCfg{
+		ApiThrottlingThreshold: DefaultApiThrottlingThreshold,
+		MaxBackoffFactor:       DefaultMaxBackoffFactor,
+	}
+	var boot = BootCfg{
+		DocumentTimeoutSeconds: DefaultBootDocumentTimeoutSeconds,
+	}
+	var readiness = ReadinessCfg{
+		ProbeTimeoutSeconds: DefaultReadinessProbeTimeoutSeconds,
+		OnFailure:           ReadinessOnFailureDefer,
+		DeferTimeoutSeconds: DefaultReadinessDeferTimeoutSeconds,
+	}
+	var telemetry = TelemetryCfg{
+		Endpoint:              DefaultTelemetryEndpoint,
+		ExportIntervalSeconds: DefaultTelemetryExportIntervalSeconds,
+	}
+	var events = EventsCfg{
+		Source:               DefaultEventsSource,
+		FlushIntervalSeconds: DefaultEventsFlushIntervalSeconds,
+	}
+	var activityMetrics = ActivityMetricsCfg{
+		Namespace:            DefaultActivityMetricsNamespace,
+		FlushIntervalSeconds: DefaultActivityMetricsFlushIntervalSeconds,
+	}
+	var errorReporting = ErrorReportingCfg{
+		MaxReportsPerError: DefaultErrorReportsPerWindow,
+		WindowSeconds:      DefaultErrorReportingWindowSeconds,
+	}
+	var selfIntegrity = SelfIntegrityCfg{
+		Mode: SelfIntegrityOff,
+	}
+	var liveness = LivenessCfg{
+		HeartbeatFile:    filepath.Join(DefaultDataStorePath, LivenessHeartbeatFileName),
+		IntervalSeconds:  DefaultLivenessIntervalSeconds,
+		StuckLoopMinutes: DefaultStuckLoopMinutes,
+		MaxLoopRestarts:  DefaultMaxLoopRestarts,
+	}
+	var roleMonitor = RoleMonitorCfg{
+		Enabled:         true,
+		IntervalSeconds: DefaultRoleMonitorIntervalSeconds,
+	}
+	var rootless = RootlessCfg{
+		ElevationHelper: DefaultRootlessElevationHelper,
+		Operations:      []string{ElevationOperationPackageInstall, ElevationOperationServiceControl},
+		AuditLogPath:    DefaultRootlessAuditLogPath,
+	}
+	var webhooks = WebhooksCfg{
+		TimeoutSeconds: DefaultWebhookTimeoutSeconds,
+		MaxAttempts:    DefaultWebhookMaxAttempts,
+	}
+	var auditExport = AuditExportCfg{
+		Format:       AuditExportFormatCef,
+		SyslogSocket: DefaultAuditSyslogSocket,
+	}
+	var artifactCache = ArtifactCacheCfg{
+		Enabled:   true,
+		MaxSizeMB: DefaultArtifactCacheMaxSizeMB,
+	}
+	var execPlugins = ExecPluginsCfg{
+		HandshakeTimeoutSeconds: DefaultExecPluginHandshakeTimeoutSeconds,
+		HeartbeatTimeoutSeconds: DefaultExecPluginHeartbeatTimeoutSeconds,
+	}
+	var preconditionChecks = PreconditionChecksCfg{
+		TimeoutSeconds: DefaultPreconditionCheckTimeoutSeconds,
+		CacheSeconds:   DefaultPreconditionCheckCacheSeconds,
+	}
+	var fanOut = FanOutCfg{
+		MaxConcurrency: DefaultFanOutMaxConcurrency,
+	}
+
+	var status = StatusEndpointCfg{
+		Path:                DefaultStatusEndpointPath,
+		StaleContactSeconds: DefaultStatusEndpointStaleContactSeconds,
+	}
+
+	var uploads = UploadsCfg{
+		MaxConcurrentUploads: DefaultMaxConcurrentUploads,
+		MaxAttempts:          DefaultUploadMaxAttempts,
+		MaxQueuedUploads:     DefaultMaxQueuedUploads,
+		Spool: SpoolCfg{
+			MaxSizeMB: DefaultSpoolMaxSizeMB,
+		},
+	}
+
+	var logCfg = LogCfg{
+		Format: LogFormatText,
+	}
 
 	var ssmagentCfg = SsmagentConfig{
-		Profile:     credsProfile,
-		Mds:         mds,
-		Ssm:         ssm,
-		Mgs:         mgs,
-		Agent:       agent,
-		Os:          os,
-		S3:          s3,
-		Birdwatcher: birdwatcher,
+		Profile:            credsProfile,
+		Mds:                mds,
+		Ssm:                ssm,
+		Mgs:                mgs,
+		Agent:              agent,
+		Os:                 os,
+		S3:                 s3,
+		Birdwatcher:        birdwatcher,
+		Throttle:           throttle,
+		Boot:               boot,
+		Readiness:          readiness,
+		SelfIntegrity:      selfIntegrity,
+		Liveness:           liveness,
+		RoleMonitor:        roleMonitor,
+		Rootless:           rootless,
+		Telemetry:          telemetry,
+		ArtifactCache:      artifactCache,
+		Events:             events,
+		ActivityMetrics:    activityMetrics,
+		ErrorReporting:     errorReporting,
+		Webhooks:           webhooks,
+		AuditExport:        auditExport,
+		ExecPlugins:        execPlugins,
+		PreconditionChecks: preconditionChecks,
+		FanOut:             fanOut,
+		Status:             status,
+		Uploads:            uploads,
+		Log:                logCfg,
 	}
 
 	return ssmagentCfg
@@ -17,6 +17,8 @@ package appconfig
 
 import (
 	"log"
+	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -28,6 +30,31 @@ func parser(config *SsmagentConfig) {
 	config.Agent.Name = getStringValue(config.Agent.Name, DefaultAgentName)
 	config.Agent.OrchestrationRootDir = getStringValue(config.Agent.OrchestrationRootDir, defaultOrchestrationRootDirName)
 	config.Agent.Region = getStringValue(config.Agent.Region, "")
+	if config.Agent.IpcTransport != IpcTransportSocket {
+		config.Agent.IpcTransport = IpcTransportFile
+	}
+	switch {
+	case strings.EqualFold(config.Agent.DualStack, DualStackEnabled):
+		config.Agent.DualStack = DualStackEnabled
+	case strings.EqualFold(config.Agent.DualStack, DualStackDisabled):
+		config.Agent.DualStack = DualStackDisabled
+	default:
+		config.Agent.DualStack = DualStackAuto
+	}
+	config.Agent.UserAgentSuffix = userAgentSuffix(config.Agent.UserAgentSuffix)
+	switch {
+	case strings.EqualFold(config.Agent.WorkerLaunch, WorkerLaunchTaskScheduler):
+		config.Agent.WorkerLaunch = WorkerLaunchTaskScheduler
+	case strings.EqualFold(config.Agent.WorkerLaunch, WorkerLaunchFallback):
+		config.Agent.WorkerLaunch = WorkerLaunchFallback
+	default:
+		config.Agent.WorkerLaunch = WorkerLaunchProcess
+	}
+	config.Agent.WorkerOutputBufferKB = getNumericValue(
+		config.Agent.WorkerOutputBufferKB,
+		DefaultWorkerOutputBufferKBMin,
+		DefaultWorkerOutputBufferKBMax,
+		DefaultWorkerOutputBufferKB)
 
 	// MDS config
 	config.Mds.CommandWorkersLimit = getNumericValue(
@@ -67,12 +94,516 @@ func parser(config *SsmagentConfig) {
 		config.Ssm.RunCommandLogsRetentionDurationHours,
 		DefaultStateOrchestrationLogsRetentionDurationHoursMin,
 		DefaultRunCommandLogsRetentionDurationHours)
+	if config.Ssm.DownloadRateLimitBytesPerSecond < 0 {
+		config.Ssm.DownloadRateLimitBytesPerSecond = 0
+	}
+	switch {
+	case strings.EqualFold(config.Ssm.InteractivePrompt.Action, InteractivePromptActionFail):
+		config.Ssm.InteractivePrompt.Action = InteractivePromptActionFail
+	case strings.EqualFold(config.Ssm.InteractivePrompt.Action, InteractivePromptActionRespond):
+		config.Ssm.InteractivePrompt.Action = InteractivePromptActionRespond
+	default:
+		config.Ssm.InteractivePrompt.Action = InteractivePromptActionNone
+	}
+	config.Ssm.InteractivePrompt.ResponseFile = strings.TrimSpace(config.Ssm.InteractivePrompt.ResponseFile)
+	config.Ssm.InteractivePrompt.IdleSeconds = getNumericValue(
+		config.Ssm.InteractivePrompt.IdleSeconds,
+		DefaultInteractivePromptIdleSecondsMin,
+		DefaultInteractivePromptIdleSecondsMax,
+		DefaultInteractivePromptIdleSeconds)
+	config.Ssm.Workspace.RootDirectory = strings.TrimSpace(config.Ssm.Workspace.RootDirectory)
+	config.Ssm.Workspace.OverwritePasses = getNumericValue(
+		config.Ssm.Workspace.OverwritePasses,
+		DefaultWorkspaceOverwritePassesMin,
+		DefaultWorkspaceOverwritePassesMax,
+		DefaultWorkspaceOverwritePasses)
+	config.Ssm.StepRebootLimit = getNumericValue(
+		config.Ssm.StepRebootLimit,
+		DefaultStepRebootLimitMin,
+		DefaultStepRebootLimitMax,
+		DefaultStepRebootLimit)
+	config.Ssm.ConsoleSession.ScreenshotS3BucketName = strings.TrimSpace(config.Ssm.ConsoleSession.ScreenshotS3BucketName)
+	config.Ssm.ConsoleSession.ScreenshotS3KeyPrefix = strings.Trim(strings.TrimSpace(config.Ssm.ConsoleSession.ScreenshotS3KeyPrefix), "/")
+	if config.Ssm.ConsoleSession.ScreenshotS3BucketName == "" {
+		config.Ssm.ConsoleSession.CaptureScreenshot = false
+	}
+	config.Ssm.Inventory.FullRefreshHours = getNumericValue(
+		config.Ssm.Inventory.FullRefreshHours,
+		DefaultInventoryFullRefreshHoursMin,
+		DefaultInventoryFullRefreshHoursMax,
+		DefaultInventoryFullRefreshHours)
+	config.Ssm.DocumentCache.MaxEntries = getNumericValue(
+		config.Ssm.DocumentCache.MaxEntries,
+		DefaultDocumentCacheMaxEntriesMin,
+		DefaultDocumentCacheMaxEntriesMax,
+		DefaultDocumentCacheMaxEntries)
+
+	// MGS config
+	config.Mgs.SessionStartHook.TimeoutSeconds = getNumericValue(
+		config.Mgs.SessionStartHook.TimeoutSeconds,
+		DefaultSessionStartHookTimeoutSecondsMin,
+		DefaultSessionStartHookTimeoutSecondsMax,
+		DefaultSessionStartHookTimeoutSeconds)
+	config.Mgs.IdleWorkerTimeoutMinutes = getNumericValue(
+		config.Mgs.IdleWorkerTimeoutMinutes,
+		DefaultIdleWorkerTimeoutMinutesMin,
+		DefaultIdleWorkerTimeoutMinutesMax,
+		DefaultIdleWorkerTimeoutMinutes)
+
+	// S3 config
+	config.S3.OutputPartSizeMB = getNumericValue(
+		config.S3.OutputPartSizeMB,
+		0,
+		DefaultOutputPartSizeMBMax,
+		0)
+
+	// Birdwatcher config
+	if !strings.EqualFold(config.Birdwatcher.ManifestCache.Backend, ManifestCacheBackendMemory) {
+		config.Birdwatcher.ManifestCache.Backend = ManifestCacheBackendFile
+	} else {
+		config.Birdwatcher.ManifestCache.Backend = ManifestCacheBackendMemory
+	}
+	config.Birdwatcher.ManifestCache.TtlHours = getNumericValueAboveMin(
+		config.Birdwatcher.ManifestCache.TtlHours,
+		0,
+		DefaultManifestCacheTtlHours)
+	config.Birdwatcher.ManifestCache.MaxEntries = getNumericValueAboveMin(
+		config.Birdwatcher.ManifestCache.MaxEntries,
+		0,
+		DefaultManifestCacheMaxEntries)
+	config.Birdwatcher.ManifestCache.Directory = getStringValue(
+		strings.TrimSpace(config.Birdwatcher.ManifestCache.Directory),
+		ManifestCacheDirectory)
+	if strings.EqualFold(config.Birdwatcher.Sbom.Format, SbomFormatSPDX) {
+		config.Birdwatcher.Sbom.Format = SbomFormatSPDX
+	} else {
+		config.Birdwatcher.Sbom.Format = SbomFormatCycloneDX
+	}
+	config.Birdwatcher.Sbom.S3BucketName = strings.TrimSpace(config.Birdwatcher.Sbom.S3BucketName)
+	config.Birdwatcher.Sbom.S3KeyPrefix = strings.Trim(strings.TrimSpace(config.Birdwatcher.Sbom.S3KeyPrefix), "/")
+	config.Birdwatcher.Integrity.FrequencyMinutes = getNumericValue(
+		config.Birdwatcher.Integrity.FrequencyMinutes,
+		DefaultPackageIntegrityFrequencyMinutesMin,
+		DefaultPackageIntegrityFrequencyMinutesMax,
+		DefaultPackageIntegrityFrequencyMinutes)
+	config.Birdwatcher.Trace.MaxSectionOutputBytes = getNumericValueAboveMin(
+		config.Birdwatcher.Trace.MaxSectionOutputBytes,
+		0,
+		DefaultPackageTraceMaxSectionOutputBytes)
+	config.Birdwatcher.Trace.ExportLogGroup = strings.TrimSpace(config.Birdwatcher.Trace.ExportLogGroup)
+	config.Birdwatcher.Trace.MetricNamespace = strings.TrimSpace(config.Birdwatcher.Trace.MetricNamespace)
+	if config.Birdwatcher.Trace.MetricNamespace == "" {
+		config.Birdwatcher.Trace.MetricNamespace = DefaultPackageTraceMetricNamespace
+	}
+	config.Birdwatcher.InstallLogs.MaxFileSizeKB = getNumericValue(
+		config.Birdwatcher.InstallLogs.MaxFileSizeKB,
+		DefaultPackageInstallLogMaxFileSizeKBMin,
+		DefaultPackageInstallLogMaxFileSizeKBMax,
+		DefaultPackageInstallLogMaxFileSizeKB)
+	config.Birdwatcher.InstallLogs.MaxFiles = getNumericValue(
+		config.Birdwatcher.InstallLogs.MaxFiles,
+		DefaultPackageInstallLogMaxFilesMin,
+		DefaultPackageInstallLogMaxFilesMax,
+		DefaultPackageInstallLogMaxFiles)
+	config.Birdwatcher.Failover.Endpoints = getStringValues(config.Birdwatcher.Failover.Endpoints)
+	config.Birdwatcher.Failover.Regions = getStringValues(config.Birdwatcher.Failover.Regions)
+	config.Birdwatcher.Failover.FailureThreshold = getNumericValue(
+		config.Birdwatcher.Failover.FailureThreshold,
+		DefaultFacadeFailoverThresholdMin,
+		DefaultFacadeFailoverThresholdMax,
+		DefaultFacadeFailoverThreshold)
+	config.Birdwatcher.LockWaitSeconds = getNumericValue(
+		config.Birdwatcher.LockWaitSeconds,
+		0,
+		DefaultPackageLockWaitSecondsMax,
+		DefaultPackageLockWaitSeconds)
+	config.Birdwatcher.DownloadParallelism = getNumericValue(
+		config.Birdwatcher.DownloadParallelism,
+		DefaultPackageDownloadParallelismMin,
+		DefaultPackageDownloadParallelismMax,
+		DefaultPackageDownloadParallelism)
+	config.Birdwatcher.ResultRetryMinutes = getNumericValue(
+		config.Birdwatcher.ResultRetryMinutes,
+		DefaultPackageResultRetryMinutesMin,
+		DefaultPackageResultRetryMinutesMax,
+		DefaultPackageResultRetryMinutes)
+	config.Birdwatcher.Retention.KeepVersions = getNumericValue(
+		config.Birdwatcher.Retention.KeepVersions,
+		DefaultPackageKeepVersionsMin,
+		DefaultPackageKeepVersionsMax,
+		DefaultPackageKeepVersions)
+	config.Birdwatcher.Retention.MaxTotalMB = getNumericValueAboveMin(config.Birdwatcher.Retention.MaxTotalMB, 0, 0)
+	config.Birdwatcher.Retention.MaxAgeDays = getNumericValueAboveMin(config.Birdwatcher.Retention.MaxAgeDays, 0, 0)
+	config.Birdwatcher.PlatformAliases = getPlatformAliases(config.Birdwatcher.PlatformAliases)
+	config.Birdwatcher.Channel = strings.ToLower(strings.TrimSpace(config.Birdwatcher.Channel))
+	config.Birdwatcher.ArtifactScanners = getArtifactScanners(config.Birdwatcher.ArtifactScanners)
+	config.Birdwatcher.DownloadMirrors = getDownloadMirrors(config.Birdwatcher.DownloadMirrors)
+	config.Birdwatcher.ResultAttributes.InstanceTags = getStringValues(config.Birdwatcher.ResultAttributes.InstanceTags)
+
+	// Throttle config
+	config.Throttle.PeakHours = strings.TrimSpace(config.Throttle.PeakHours)
+	config.Throttle.PeakDays = strings.TrimSpace(config.Throttle.PeakDays)
+	config.Throttle.PollDelaySeconds = getNumericValue(
+		config.Throttle.PollDelaySeconds,
+		0,
+		DefaultThrottlePollDelaySecondsMax,
+		0)
+	config.Throttle.MaxConcurrentExecutions = getNumericValueAboveMin(config.Throttle.MaxConcurrentExecutions, 0, 0)
+	config.Throttle.DownloadBandwidthKBps = getNumericValueAboveMin(config.Throttle.DownloadBandwidthKBps, 0, 0)
+	config.Throttle.ApiThrottlingThreshold = getNumericValueAboveMin(
+		config.Throttle.ApiThrottlingThreshold,
+		0,
+		DefaultApiThrottlingThreshold)
+	config.Throttle.MaxBackoffFactor = getNumericValue(
+		config.Throttle.MaxBackoffFactor,
+		DefaultMaxBackoffFactorMin,
+		DefaultMaxBackoffFactorMax,
+		DefaultMaxBackoffFactor)
+
+	// Boot config
+	for i := range config.Boot.Documents {
+		document := &config.Boot.Documents[i]
+		if !strings.EqualFold(document.RunOn, BootDocumentRunOnEveryBoot) {
+			document.RunOn = BootDocumentRunOnFirstBoot
+		} else {
+			document.RunOn = BootDocumentRunOnEveryBoot
+		}
+		if !strings.EqualFold(document.OnFailure, BootDocumentOnFailureStop) {
+			document.OnFailure = BootDocumentOnFailureContinue
+		} else {
+			document.OnFailure = BootDocumentOnFailureStop
+		}
+	}
+	config.Boot.DocumentTimeoutSeconds = getNumericValue(
+		config.Boot.DocumentTimeoutSeconds,
+		DefaultBootDocumentTimeoutSecondsMin,
+		DefaultBootDocumentTimeoutSecondsMax,
+		DefaultBootDocumentTimeoutSeconds)
+
+	// Readiness config
+	config.Readiness.MinFreeDiskMB = getNumericValueAboveMin(config.Readiness.MinFreeDiskMB, 0, 0)
+	if config.Readiness.MaxLoadPerCpu < 0 {
+		config.Readiness.MaxLoadPerCpu = 0
+	}
+	config.Readiness.ProbeCommand = strings.TrimSpace(config.Readiness.ProbeCommand)
+	config.Readiness.ProbeTimeoutSeconds = getNumericValue(
+		config.Readiness.ProbeTimeoutSeconds,
+		DefaultReadinessProbeTimeoutSecondsMin,
+		DefaultReadinessProbeTimeoutSecondsMax,
+		DefaultReadinessProbeTimeoutSeconds)
+	if !strings.EqualFold(config.Readiness.OnFailure, ReadinessOnFailureFail) {
+		config.Readiness.OnFailure = ReadinessOnFailureDefer
+	} else {
+		config.Readiness.OnFailure = ReadinessOnFailureFail
+	}
+	config.Readiness.DeferTimeoutSeconds = getNumericValue(
+		config.Readiness.DeferTimeoutSeconds,
+		0,
+		DefaultReadinessDeferTimeoutSecondsMax,
+		DefaultReadinessDeferTimeoutSeconds)
+
+	// Namespaces config
+	var namespaces []NamespaceCfg
+	for _, namespace := range config.Namespaces {
+		if namespace.Name == "" || len(namespace.DocumentNames) == 0 {
+			continue
+		}
+		if namespace.MaxExecutionSeconds < 0 {
+			namespace.MaxExecutionSeconds = 0
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	config.Namespaces = namespaces
+
+	// Iot config
+	config.Iot.Region = getStringValue(config.Iot.Region, config.Agent.Region)
+
+	// Tls config
+	config.Tls.MinVersion = strings.TrimSpace(config.Tls.MinVersion)
+	config.Tls.Service.CABundlePath = strings.TrimSpace(config.Tls.Service.CABundlePath)
+	config.Tls.Artifacts.CABundlePath = strings.TrimSpace(config.Tls.Artifacts.CABundlePath)
+	config.ArtifactProxy.Url = strings.TrimSpace(config.ArtifactProxy.Url)
+	config.ArtifactProxy.NoProxy = getStringValues(config.ArtifactProxy.NoProxy)
+	switch {
+	case strings.EqualFold(config.Tls.Revocation.Mode, TlsRevocationSoftFail):
+		config.Tls.Revocation.Mode = TlsRevocationSoftFail
+	case strings.EqualFold(config.Tls.Revocation.Mode, TlsRevocationHardFail):
+		config.Tls.Revocation.Mode = TlsRevocationHardFail
+	default:
+		config.Tls.Revocation.Mode = TlsRevocationOff
+	}
+	config.Tls.Revocation.CrlCacheMinutes = getNumericValueAboveMin(
+		config.Tls.Revocation.CrlCacheMinutes,
+		1,
+		DefaultCrlCacheMinutes)
+
+	// Self integrity config
+	switch {
+	case strings.EqualFold(config.SelfIntegrity.Mode, SelfIntegrityAlert):
+		config.SelfIntegrity.Mode = SelfIntegrityAlert
+	case strings.EqualFold(config.SelfIntegrity.Mode, SelfIntegrityEnforce):
+		config.SelfIntegrity.Mode = SelfIntegrityEnforce
+	default:
+		config.SelfIntegrity.Mode = SelfIntegrityOff
+	}
+	config.SelfIntegrity.ManifestPath = strings.TrimSpace(config.SelfIntegrity.ManifestPath)
+	config.SelfIntegrity.PublicKeyPath = strings.TrimSpace(config.SelfIntegrity.PublicKeyPath)
+
+	// Liveness config
+	config.Liveness.HeartbeatFile = getStringValue(strings.TrimSpace(config.Liveness.HeartbeatFile),
+		filepath.Join(DefaultDataStorePath, LivenessHeartbeatFileName))
+	config.Liveness.IntervalSeconds = getNumericValue(
+		config.Liveness.IntervalSeconds,
+		DefaultLivenessIntervalSecondsMin,
+		DefaultLivenessIntervalSecondsMax,
+		DefaultLivenessIntervalSeconds)
+	config.Liveness.StuckLoopMinutes = getNumericValue(
+		config.Liveness.StuckLoopMinutes,
+		DefaultStuckLoopMinutesMin,
+		DefaultStuckLoopMinutesMax,
+		DefaultStuckLoopMinutes)
+	config.Liveness.MaxLoopRestarts = getNumericValue(
+		config.Liveness.MaxLoopRestarts,
+		DefaultMaxLoopRestartsMin,
+		DefaultMaxLoopRestartsMax,
+		DefaultMaxLoopRestarts)
 
+	// RoleMonitor config
+	config.RoleMonitor.IntervalSeconds = getNumericValue(
+		config.RoleMonitor.IntervalSeconds,
+		DefaultRoleMonitorIntervalSecondsMin,
+		DefaultRoleMonitorIntervalSecondsMax,
+		DefaultRoleMonitorIntervalSeconds)
+
+	// Rootless config
+	config.Rootless.ElevationHelper = getStringValue(strings.TrimSpace(config.Rootless.ElevationHelper), DefaultRootlessElevationHelper)
+	config.Rootless.AuditLogPath = getStringValue(strings.TrimSpace(config.Rootless.AuditLogPath), DefaultRootlessAuditLogPath)
+	var operations []string
+	for _, operation := range config.Rootless.Operations {
+		for _, known := range []string{ElevationOperationPackageInstall, ElevationOperationServiceControl} {
+			if strings.EqualFold(strings.TrimSpace(operation), known) {
+				operations = append(operations, known)
+			}
+		}
+	}
+	config.Rootless.Operations = operations
+
+	// Telemetry config
+	config.Telemetry.Endpoint = strings.TrimRight(getStringValue(strings.TrimSpace(config.Telemetry.Endpoint), DefaultTelemetryEndpoint), "/")
+	config.Telemetry.ExportIntervalSeconds = getNumericValue(
+		config.Telemetry.ExportIntervalSeconds,
+		DefaultTelemetryExportIntervalSecondsMin,
+		DefaultTelemetryExportIntervalSecondsMax,
+		DefaultTelemetryExportIntervalSeconds)
+
+	// Events config
+	config.Events.EventBusName = strings.TrimSpace(config.Events.EventBusName)
+	config.Events.Source = getStringValue(strings.TrimSpace(config.Events.Source), DefaultEventsSource)
+	if strings.HasPrefix(strings.ToLower(config.Events.Source), "aws.") {
+		config.Events.Source = DefaultEventsSource
+	}
+	detailTypes := []string{}
+	for _, detailType := range config.Events.DetailTypes {
+		if detailType = strings.TrimSpace(detailType); detailType != "" {
+			detailTypes = append(detailTypes, detailType)
+		}
+	}
+	config.Events.DetailTypes = detailTypes
+	config.Events.FlushIntervalSeconds = getNumericValue(
+		config.Events.FlushIntervalSeconds,
+		DefaultEventsFlushIntervalSecondsMin,
+		DefaultEventsFlushIntervalSecondsMax,
+		DefaultEventsFlushIntervalSeconds)
+
+	// Activity metrics config
+	config.ActivityMetrics.Namespace = getStringValue(strings.TrimSpace(config.ActivityMetrics.Namespace), DefaultActivityMetricsNamespace)
+	if strings.HasPrefix(strings.ToLower(config.ActivityMetrics.Namespace), "aws/") {
+		config.ActivityMetrics.Namespace = DefaultActivityMetricsNamespace
+	}
+	config.ActivityMetrics.FlushIntervalSeconds = getNumericValue(
+		config.ActivityMetrics.FlushIntervalSeconds,
+		DefaultActivityMetricsFlushIntervalSecondsMin,
+		DefaultActivityMetricsFlushIntervalSecondsMax,
+		DefaultActivityMetricsFlushIntervalSeconds)
+
+	// Error reporting config
+	config.ErrorReporting.MaxReportsPerError = getNumericValue(
+		config.ErrorReporting.MaxReportsPerError,
+		DefaultErrorReportsPerWindowMin,
+		DefaultErrorReportsPerWindowMax,
+		DefaultErrorReportsPerWindow)
+	config.ErrorReporting.WindowSeconds = getNumericValue(
+		config.ErrorReporting.WindowSeconds,
+		DefaultErrorReportingWindowSecondsMin,
+		DefaultErrorReportingWindowSecondsMax,
+		DefaultErrorReportingWindowSeconds)
+
+	// Webhooks config, only https endpoints are called back
+	var endpoints []WebhookEndpointCfg
+	for _, endpoint := range config.Webhooks.Endpoints {
+		endpoint.UrlTemplate = strings.TrimSpace(endpoint.UrlTemplate)
+		if !strings.HasPrefix(strings.ToLower(endpoint.UrlTemplate), "https://") {
+			continue
+		}
+		var events []string
+		for _, event := range getStringValues(endpoint.Events) {
+			switch {
+			case strings.EqualFold(event, WebhookEventDocumentCompleted):
+				events = append(events, WebhookEventDocumentCompleted)
+			case strings.EqualFold(event, WebhookEventStepCompleted):
+				events = append(events, WebhookEventStepCompleted)
+			}
+		}
+		if len(endpoint.Events) > 0 && len(events) == 0 {
+			// the endpoint only subscribes to unknown events
+			continue
+		}
+		endpoint.Events = events
+		endpoint.SecretPath = strings.TrimSpace(endpoint.SecretPath)
+		endpoints = append(endpoints, endpoint)
+	}
+	config.Webhooks.Endpoints = endpoints
+	config.Webhooks.TimeoutSeconds = getNumericValue(
+		config.Webhooks.TimeoutSeconds,
+		DefaultWebhookTimeoutSecondsMin,
+		DefaultWebhookTimeoutSecondsMax,
+		DefaultWebhookTimeoutSeconds)
+	config.Webhooks.MaxAttempts = getNumericValue(
+		config.Webhooks.MaxAttempts,
+		DefaultWebhookMaxAttemptsMin,
+		DefaultWebhookMaxAttemptsMax,
+		DefaultWebhookMaxAttempts)
+
+	// Audit export config
+	if strings.EqualFold(strings.TrimSpace(config.AuditExport.Format), AuditExportFormatLeef) {
+		config.AuditExport.Format = AuditExportFormatLeef
+	} else {
+		config.AuditExport.Format = AuditExportFormatCef
+	}
+	config.AuditExport.SyslogSocket = getStringValue(strings.TrimSpace(config.AuditExport.SyslogSocket), DefaultAuditSyslogSocket)
+	config.AuditExport.FilePath = strings.TrimSpace(config.AuditExport.FilePath)
+
+	// Artifact cache config
+	config.ArtifactCache.MaxSizeMB = getNumericValue(
+		config.ArtifactCache.MaxSizeMB,
+		DefaultArtifactCacheMaxSizeMBMin,
+		DefaultArtifactCacheMaxSizeMBMax,
+		DefaultArtifactCacheMaxSizeMB)
+
+	// Exec plugins config
+	config.ExecPlugins.Directory = strings.TrimSpace(config.ExecPlugins.Directory)
+	config.ExecPlugins.HandshakeTimeoutSeconds = getNumericValue(
+		config.ExecPlugins.HandshakeTimeoutSeconds,
+		DefaultExecPluginHandshakeTimeoutSecondsMin,
+		DefaultExecPluginHandshakeTimeoutSecondsMax,
+		DefaultExecPluginHandshakeTimeoutSeconds)
+	config.ExecPlugins.HeartbeatTimeoutSeconds = getNumericValue(
+		config.ExecPlugins.HeartbeatTimeoutSeconds,
+		DefaultExecPluginHeartbeatTimeoutSecondsMin,
+		DefaultExecPluginHeartbeatTimeoutSecondsMax,
+		DefaultExecPluginHeartbeatTimeoutSeconds)
+
+	// Precondition checks config
+	config.PreconditionChecks.Directory = strings.TrimSpace(config.PreconditionChecks.Directory)
+	config.PreconditionChecks.TimeoutSeconds = getNumericValue(
+		config.PreconditionChecks.TimeoutSeconds,
+		DefaultPreconditionCheckTimeoutSecondsMin,
+		DefaultPreconditionCheckTimeoutSecondsMax,
+		DefaultPreconditionCheckTimeoutSeconds)
+	config.PreconditionChecks.CacheSeconds = getNumericValue(
+		config.PreconditionChecks.CacheSeconds,
+		DefaultPreconditionCheckCacheSecondsMin,
+		DefaultPreconditionCheckCacheSecondsMax,
+		DefaultPreconditionCheckCacheSeconds)
+
+	// Fan-out config, the targets without a name, a known type or an address are dropped, and so are the duplicate names
+	var targets []FanOutTargetCfg
+	targetNames := make(map[string]bool)
+	for _, target := range config.FanOut.Targets {
+		target.Name = strings.TrimSpace(target.Name)
+		target.Type = strings.ToLower(strings.TrimSpace(target.Type))
+		target.Address = strings.TrimSpace(target.Address)
+		target.User = strings.TrimSpace(target.User)
+		target.IdentityFile = strings.TrimSpace(target.IdentityFile)
+		target.ContainerRuntime = strings.TrimSpace(target.ContainerRuntime)
+		if target.Name == "" || target.Address == "" || targetNames[target.Name] || target.Port < 0 || target.Port > 65535 {
+			continue
+		}
+		switch target.Type {
+		case FanOutTargetSsh, FanOutTargetWinRm, FanOutTargetContainer:
+		default:
+			continue
+		}
+		targetNames[target.Name] = true
+		targets = append(targets, target)
+	}
+	config.FanOut.Targets = targets
+	config.FanOut.MaxConcurrency = getNumericValue(
+		config.FanOut.MaxConcurrency,
+		DefaultFanOutMaxConcurrencyMin,
+		DefaultFanOutMaxConcurrencyMax,
+		DefaultFanOutMaxConcurrency)
+
+	// Status endpoint config
+	config.Status.Path = getStringValue(strings.TrimSpace(config.Status.Path), DefaultStatusEndpointPath)
+	config.Status.StaleContactSeconds = getNumericValue(
+		config.Status.StaleContactSeconds,
+		DefaultStatusEndpointStaleContactSecondsMin,
+		DefaultStatusEndpointStaleContactSecondsMax,
+		DefaultStatusEndpointStaleContactSeconds)
+
+	// Upload queue config
+	config.Uploads.MaxConcurrentUploads = getNumericValue(
+		config.Uploads.MaxConcurrentUploads,
+		DefaultMaxConcurrentUploadsMin,
+		DefaultMaxConcurrentUploadsMax,
+		DefaultMaxConcurrentUploads)
+	config.Uploads.BandwidthKBps = getNumericValueAboveMin(config.Uploads.BandwidthKBps, 0, 0)
+	config.Uploads.MaxAttempts = getNumericValue(
+		config.Uploads.MaxAttempts,
+		DefaultUploadMaxAttemptsMin,
+		DefaultUploadMaxAttemptsMax,
+		DefaultUploadMaxAttempts)
+	config.Uploads.MaxQueuedUploads = getNumericValue(
+		config.Uploads.MaxQueuedUploads,
+		DefaultMaxQueuedUploadsMin,
+		DefaultMaxQueuedUploadsMax,
+		DefaultMaxQueuedUploads)
+	config.Uploads.Spool.SigningKeyPath = strings.TrimSpace(config.Uploads.Spool.SigningKeyPath)
+	config.Uploads.Spool.MaxSizeMB = getNumericValue(
+		config.Uploads.Spool.MaxSizeMB,
+		DefaultSpoolMaxSizeMBMin,
+		DefaultSpoolMaxSizeMBMax,
+		DefaultSpoolMaxSizeMB)
+	config.Uploads.Presigned.SignerEndpoint = strings.TrimSpace(config.Uploads.Presigned.SignerEndpoint)
+	if config.Uploads.Presigned.SignerEndpoint == "" {
+		// without a signer the output is uploaded with the credentials of the instance
+		config.Uploads.Presigned.Enabled = false
+	}
+
+	// Log config
+	if strings.EqualFold(config.Log.Format, LogFormatJson) {
+		config.Log.Format = LogFormatJson
+	} else {
+		config.Log.Format = LogFormatText
+	}
+	subsystemLevels := make(map[string]string)
+	for subsystem, level := range config.Log.SubsystemLevels {
+		subsystem = strings.Trim(strings.Replace(strings.TrimSpace(subsystem), "\\", "/", -1), "/")
+		if subsystem != "" {
+			subsystemLevels[subsystem] = strings.ToLower(strings.TrimSpace(level))
+		}
+	}
+	config.Log.SubsystemLevels = subsystemLevels
 }
 
 // TODO https://sim.amazon.com/issues/SSM-3439
 // getDefaultEndPoint returns the default endpoint for a service, it should be empty unless it's a china region
+// or the agent uses the dualstack endpoints
 func GetDefaultEndPoint(region string, service string) string {
+	if UseDualStackEndpoints() {
+		return GetDualStackEndPoint(region, service)
+	}
 	endpoint := ""
 
 	parts := strings.Split(region, "-")
@@ -91,6 +622,94 @@ func getStringValue(configValue string, defaultValue string) string {
 	return configValue
 }
 
+// getStringValues returns the config values without surrounding spaces, dropping the empty ones
+func getStringValues(configValues []string) []string {
+	var values []string
+	for _, value := range configValues {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getPlatformAliases lower cases the platforms and drops the aliases without a platform
+func getPlatformAliases(configValues map[string]string) map[string]string {
+	values := make(map[string]string)
+	for platform, alias := range configValues {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if platform != "" && alias != "" {
+			values[platform] = alias
+		}
+	}
+	return values
+}
+
+// getArtifactScanners drops the scanners without command, names the others after their command when they have no
+// name and bounds their timeouts
+func getArtifactScanners(scanners []ArtifactScannerCfg) []ArtifactScannerCfg {
+	var values []ArtifactScannerCfg
+	for _, scanner := range scanners {
+		scanner.Command = strings.TrimSpace(scanner.Command)
+		if scanner.Command == "" {
+			continue
+		}
+		scanner.Name = strings.TrimSpace(scanner.Name)
+		if scanner.Name == "" {
+			scanner.Name = filepath.Base(scanner.Command)
+		}
+		scanner.TimeoutSeconds = getNumericValue(
+			scanner.TimeoutSeconds,
+			DefaultArtifactScannerTimeoutSecondsMin,
+			DefaultArtifactScannerTimeoutSecondsMax,
+			DefaultArtifactScannerTimeoutSeconds)
+		values = append(values, scanner)
+	}
+	return values
+}
+
+// getDownloadMirrors drops the mirrors without mirror url, without prefix or pattern, or with an invalid pattern,
+// a mirror with both uses its pattern
+func getDownloadMirrors(mirrors []DownloadMirrorCfg) []DownloadMirrorCfg {
+	var values []DownloadMirrorCfg
+	for _, mirror := range mirrors {
+		mirror.Mirror = strings.TrimSpace(mirror.Mirror)
+		mirror.Prefix = strings.TrimSpace(mirror.Prefix)
+		mirror.Pattern = strings.TrimSpace(mirror.Pattern)
+		if mirror.Mirror == "" {
+			continue
+		}
+		if mirror.Pattern != "" {
+			if _, err := regexp.Compile(mirror.Pattern); err != nil {
+				continue
+			}
+			mirror.Prefix = ""
+		} else if mirror.Prefix == "" {
+			continue
+		}
+		values = append(values, mirror)
+	}
+	return values
+}
+
+// userAgentSuffix keeps the printable ascii characters of the suffix, its spaces are replaced by dashes so the
+// suffix stays one product token of the user agent
+func userAgentSuffix(suffix string) string {
+	var sanitized []byte
+	for _, c := range []byte(strings.TrimSpace(suffix)) {
+		if c == ' ' {
+			sanitized = append(sanitized, '-')
+		} else if c > ' ' && c < 0x7f {
+			sanitized = append(sanitized, c)
+		}
+	}
+	if len(sanitized) > UserAgentSuffixMaxLength {
+		sanitized = sanitized[:UserAgentSuffixMaxLength]
+	}
+	return string(sanitized)
+}
+
 // getNumericValueAboveMin returns the default if config is below minimum
 func getNumericValueAboveMin(configValue int, minValue int, defaultValue int) int {
 	if configValue < minValue {
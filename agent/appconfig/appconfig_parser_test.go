@@ -14,6 +14,8 @@
 package appconfig
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -59,6 +61,7 @@ var (
 )
 
 func TestGetDefaultEndPoint(t *testing.T) {
+	defer useIPv4Route(true)()
 	for _, test := range getDefaultEndPointTests {
 		output := GetDefaultEndPoint(test.Region, test.Service)
 		assert.Equal(t, test.Output, output)
@@ -116,3 +119,339 @@ func TestGetNumeric64Value(t *testing.T) {
 		assert.Equal(t, test.Output, output)
 	}
 }
+
+func TestParseWebhooks(t *testing.T) {
+	config := DefaultConfig()
+	config.Webhooks = WebhooksCfg{
+		Enabled: true,
+		Endpoints: []WebhookEndpointCfg{
+			{UrlTemplate: " https://cmdb.example.com/{InstanceId} ", Events: []string{" stepcompleted", ""}, SecretPath: " /etc/secret "},
+			{UrlTemplate: "http://cmdb.example.com/insecure"},
+			{UrlTemplate: "https://cmdb.example.com/unknown", Events: []string{"Started"}},
+			{UrlTemplate: "HTTPS://cmdb.example.com/all"},
+		},
+		TimeoutSeconds: 600,
+	}
+
+	parser(&config)
+
+	assert.Equal(t, []WebhookEndpointCfg{
+		{UrlTemplate: "https://cmdb.example.com/{InstanceId}", Events: []string{WebhookEventStepCompleted}, SecretPath: "/etc/secret"},
+		{UrlTemplate: "HTTPS://cmdb.example.com/all"},
+	}, config.Webhooks.Endpoints)
+	assert.Equal(t, DefaultWebhookTimeoutSeconds, config.Webhooks.TimeoutSeconds)
+	assert.Equal(t, DefaultWebhookMaxAttempts, config.Webhooks.MaxAttempts)
+}
+
+func TestParseAuditExport(t *testing.T) {
+	config := DefaultConfig()
+	config.AuditExport = AuditExportCfg{Enabled: true, Format: " leef ", FilePath: " /var/log/ssm-audit.log "}
+
+	parser(&config)
+
+	assert.Equal(t, AuditExportCfg{Enabled: true, Format: AuditExportFormatLeef, SyslogSocket: DefaultAuditSyslogSocket, FilePath: "/var/log/ssm-audit.log"}, config.AuditExport)
+
+	config.AuditExport.Format = "json"
+	parser(&config)
+	assert.Equal(t, AuditExportFormatCef, config.AuditExport.Format)
+}
+
+func TestParseStatusEndpoint(t *testing.T) {
+	config := DefaultConfig()
+	config.Status = StatusEndpointCfg{Enabled: true, Path: " ", StaleContactSeconds: 5}
+
+	parser(&config)
+
+	assert.Equal(t, DefaultStatusEndpointPath, config.Status.Path)
+	assert.Equal(t, DefaultStatusEndpointStaleContactSeconds, config.Status.StaleContactSeconds)
+}
+
+func TestParseSelfIntegrity(t *testing.T) {
+	config := DefaultConfig()
+	config.SelfIntegrity = SelfIntegrityCfg{Mode: "enforce", ManifestPath: " /opt/ssm/agent.manifest ", PublicKeyPath: " /opt/ssm/key.pem"}
+
+	parser(&config)
+
+	assert.Equal(t, SelfIntegrityEnforce, config.SelfIntegrity.Mode)
+	assert.Equal(t, "/opt/ssm/agent.manifest", config.SelfIntegrity.ManifestPath)
+	assert.Equal(t, "/opt/ssm/key.pem", config.SelfIntegrity.PublicKeyPath)
+
+	config.SelfIntegrity.Mode = "audit"
+	parser(&config)
+	assert.Equal(t, SelfIntegrityOff, config.SelfIntegrity.Mode)
+}
+
+func TestParseLiveness(t *testing.T) {
+	config := DefaultConfig()
+	config.Liveness = LivenessCfg{Enabled: true, HeartbeatFile: " ", IntervalSeconds: 1, StuckLoopMinutes: 60, MaxLoopRestarts: 0}
+
+	parser(&config)
+
+	assert.Equal(t, LivenessCfg{
+		Enabled:          true,
+		HeartbeatFile:    filepath.Join(DefaultDataStorePath, LivenessHeartbeatFileName),
+		IntervalSeconds:  DefaultLivenessIntervalSeconds,
+		StuckLoopMinutes: 60,
+		MaxLoopRestarts:  0,
+	}, config.Liveness)
+}
+
+func TestParseRoleMonitor(t *testing.T) {
+	config := DefaultConfig()
+	assert.True(t, config.RoleMonitor.Enabled)
+	config.RoleMonitor.IntervalSeconds = 1
+
+	parser(&config)
+
+	assert.Equal(t, DefaultRoleMonitorIntervalSeconds, config.RoleMonitor.IntervalSeconds)
+}
+
+func TestParseIdleWorkerTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.Mgs.IdleWorkerTimeoutMinutes = -1
+
+	parser(&config)
+	assert.Equal(t, DefaultIdleWorkerTimeoutMinutes, config.Mgs.IdleWorkerTimeoutMinutes)
+
+	config.Mgs.IdleWorkerTimeoutMinutes = 0
+	parser(&config)
+	assert.Equal(t, 0, config.Mgs.IdleWorkerTimeoutMinutes)
+}
+
+func TestParseRootless(t *testing.T) {
+	config := DefaultConfig()
+	assert.False(t, config.Rootless.Enabled)
+	config.Rootless = RootlessCfg{
+		Enabled:    true,
+		Operations: []string{"packageinstall", "reboot"},
+	}
+
+	parser(&config)
+
+	assert.Equal(t, DefaultRootlessElevationHelper, config.Rootless.ElevationHelper)
+	assert.Equal(t, DefaultRootlessAuditLogPath, config.Rootless.AuditLogPath)
+	assert.Equal(t, []string{ElevationOperationPackageInstall}, config.Rootless.Operations)
+}
+
+func TestParseFanOut(t *testing.T) {
+	config := DefaultConfig()
+	config.FanOut = FanOutCfg{
+		Targets: []FanOutTargetCfg{
+			{Name: " vm-1 ", Type: "SSH", Address: "10.0.0.2", User: "ec2-user"},
+			{Name: "vm-1", Type: "ssh", Address: "10.0.0.3"},
+			{Name: "app", Type: "container", Address: " app "},
+			{Name: "unknown", Type: "telnet", Address: "10.0.0.4"},
+			{Name: "no-address", Type: "winrm"},
+		},
+		MaxConcurrency: 1000,
+	}
+
+	parser(&config)
+
+	assert.Equal(t, []FanOutTargetCfg{
+		{Name: "vm-1", Type: FanOutTargetSsh, Address: "10.0.0.2", User: "ec2-user"},
+		{Name: "app", Type: FanOutTargetContainer, Address: "app"},
+	}, config.FanOut.Targets)
+	assert.Equal(t, DefaultFanOutMaxConcurrency, config.FanOut.MaxConcurrency)
+}
+
+func TestParseUploads(t *testing.T) {
+	config := DefaultConfig()
+	config.Uploads = UploadsCfg{MaxConcurrentUploads: 100, BandwidthKBps: -1, MaxAttempts: 0, MaxQueuedUploads: 50}
+
+	parser(&config)
+
+	assert.Equal(t, DefaultMaxConcurrentUploads, config.Uploads.MaxConcurrentUploads)
+	assert.Equal(t, 0, config.Uploads.BandwidthKBps)
+	assert.Equal(t, DefaultUploadMaxAttempts, config.Uploads.MaxAttempts)
+	assert.Equal(t, 50, config.Uploads.MaxQueuedUploads)
+	assert.Equal(t, DefaultSpoolMaxSizeMB, config.Uploads.Spool.MaxSizeMB)
+	assert.False(t, config.Uploads.Presigned.Enabled)
+
+	config.Uploads.Presigned = PresignedUploadsCfg{Enabled: true, SignerEndpoint: " "}
+	parser(&config)
+	assert.False(t, config.Uploads.Presigned.Enabled)
+
+	config.Uploads.Presigned = PresignedUploadsCfg{Enabled: true, SignerEndpoint: " https://signer.example.com/presign "}
+	parser(&config)
+	assert.True(t, config.Uploads.Presigned.Enabled)
+	assert.Equal(t, "https://signer.example.com/presign", config.Uploads.Presigned.SignerEndpoint)
+}
+
+func TestParseUserAgentSuffix(t *testing.T) {
+	config := DefaultConfig()
+	config.Agent.UserAgentSuffix = " fleet/web tier\t\n "
+
+	parser(&config)
+
+	assert.Equal(t, "fleet/web-tier", config.Agent.UserAgentSuffix)
+
+	config.Agent.UserAgentSuffix = strings.Repeat("a", UserAgentSuffixMaxLength+10)
+	parser(&config)
+	assert.Equal(t, UserAgentSuffixMaxLength, len(config.Agent.UserAgentSuffix))
+}
+
+func TestParseArtifactScanners(t *testing.T) {
+	config := DefaultConfig()
+	config.Birdwatcher.ArtifactScanners = []ArtifactScannerCfg{
+		{Command: " /usr/bin/clamscan ", Arguments: []string{"--no-summary"}},
+		{Name: "empty", Command: " "},
+		{Name: "inhouse", Command: "/opt/scan", TimeoutSeconds: 7200},
+	}
+
+	parser(&config)
+
+	assert.Equal(t, []ArtifactScannerCfg{
+		{Name: "clamscan", Command: "/usr/bin/clamscan", Arguments: []string{"--no-summary"}, TimeoutSeconds: DefaultArtifactScannerTimeoutSeconds},
+		{Name: "inhouse", Command: "/opt/scan", TimeoutSeconds: DefaultArtifactScannerTimeoutSeconds},
+	}, config.Birdwatcher.ArtifactScanners)
+}
+
+func TestParseWorkerLaunch(t *testing.T) {
+	for value, expected := range map[string]string{
+		"":              WorkerLaunchProcess,
+		"process":       WorkerLaunchProcess,
+		"taskscheduler": WorkerLaunchTaskScheduler,
+		"Fallback":      WorkerLaunchFallback,
+		"other":         WorkerLaunchProcess,
+	} {
+		config := DefaultConfig()
+		config.Agent.WorkerLaunch = value
+		parser(&config)
+		assert.Equal(t, expected, config.Agent.WorkerLaunch, value)
+	}
+}
+
+func TestParseWorkerOutputBuffer(t *testing.T) {
+	config := DefaultConfig()
+	config.Agent.WorkerOutputBufferKB = -1
+	parser(&config)
+	assert.Equal(t, DefaultWorkerOutputBufferKB, config.Agent.WorkerOutputBufferKB)
+
+	config.Agent.WorkerOutputBufferKB = 0
+	parser(&config)
+	assert.Equal(t, 0, config.Agent.WorkerOutputBufferKB)
+}
+
+func TestParsePreconditionChecks(t *testing.T) {
+	config := DefaultConfig()
+	config.PreconditionChecks.Directory = " /etc/amazon/ssm/preconditions "
+	config.PreconditionChecks.TimeoutSeconds = 0
+	config.PreconditionChecks.CacheSeconds = 0
+	parser(&config)
+
+	assert.Equal(t, "/etc/amazon/ssm/preconditions", config.PreconditionChecks.Directory)
+	assert.Equal(t, DefaultPreconditionCheckTimeoutSeconds, config.PreconditionChecks.TimeoutSeconds)
+	assert.Equal(t, 0, config.PreconditionChecks.CacheSeconds)
+}
+
+func TestParseManifestCacheDirectory(t *testing.T) {
+	config := DefaultConfig()
+	config.Birdwatcher.ManifestCache.Directory = " "
+	parser(&config)
+	assert.Equal(t, ManifestCacheDirectory, config.Birdwatcher.ManifestCache.Directory)
+
+	config.Birdwatcher.ManifestCache.Directory = " /mnt/ramdisk/manifests "
+	parser(&config)
+	assert.Equal(t, "/mnt/ramdisk/manifests", config.Birdwatcher.ManifestCache.Directory)
+}
+
+func TestParseDownloadMirrors(t *testing.T) {
+	config := DefaultConfig()
+	config.Birdwatcher.DownloadMirrors = []DownloadMirrorCfg{
+		{Prefix: " https://s3.amazonaws.com/ ", Mirror: " https://mirror.example.com/s3/ ", Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Prefix: "https://s3.amazonaws.com/"},
+		{Mirror: "https://mirror.example.com/"},
+		{Pattern: "^https://([^.]+)\\.s3\\.amazonaws\\.com/", Prefix: "https://", Mirror: "https://mirror.example.com/$1/"},
+		{Pattern: "([", Mirror: "https://mirror.example.com/"},
+	}
+
+	parser(&config)
+
+	assert.Equal(t, []DownloadMirrorCfg{
+		{Prefix: "https://s3.amazonaws.com/", Mirror: "https://mirror.example.com/s3/", Headers: map[string]string{"Authorization": "Bearer token"}},
+		{Pattern: "^https://([^.]+)\\.s3\\.amazonaws\\.com/", Mirror: "https://mirror.example.com/$1/"},
+	}, config.Birdwatcher.DownloadMirrors)
+}
+
+func TestParseConsoleSession(t *testing.T) {
+	config := DefaultConfig()
+	config.Ssm.ConsoleSession = ConsoleSessionCfg{Enabled: true, CaptureScreenshot: true, ScreenshotS3KeyPrefix: "screenshots"}
+	parser(&config)
+	assert.False(t, config.Ssm.ConsoleSession.CaptureScreenshot)
+
+	config.Ssm.ConsoleSession = ConsoleSessionCfg{Enabled: true, CaptureScreenshot: true, ScreenshotS3BucketName: " bucket ", ScreenshotS3KeyPrefix: " /gui/screenshots/ "}
+	parser(&config)
+	assert.Equal(t, ConsoleSessionCfg{Enabled: true, CaptureScreenshot: true, ScreenshotS3BucketName: "bucket", ScreenshotS3KeyPrefix: "gui/screenshots"}, config.Ssm.ConsoleSession)
+}
+
+func TestParseInventory(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, InventoryCfg{DeltaUploads: true, FullRefreshHours: DefaultInventoryFullRefreshHours}, config.Ssm.Inventory)
+
+	config.Ssm.Inventory = InventoryCfg{DeltaUploads: false, FullRefreshHours: 1000}
+	parser(&config)
+	assert.Equal(t, InventoryCfg{DeltaUploads: false, FullRefreshHours: DefaultInventoryFullRefreshHours}, config.Ssm.Inventory)
+
+	config.Ssm.Inventory.FullRefreshHours = 6
+	parser(&config)
+	assert.Equal(t, 6, config.Ssm.Inventory.FullRefreshHours)
+}
+
+func TestParseDocumentCache(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, DocumentCacheCfg{Enabled: true, MaxEntries: DefaultDocumentCacheMaxEntries}, config.Ssm.DocumentCache)
+
+	config.Ssm.DocumentCache.MaxEntries = 0
+	parser(&config)
+	assert.Equal(t, DefaultDocumentCacheMaxEntries, config.Ssm.DocumentCache.MaxEntries)
+
+	config.Ssm.DocumentCache.MaxEntries = 50
+	parser(&config)
+	assert.Equal(t, 50, config.Ssm.DocumentCache.MaxEntries)
+}
+
+func TestParseLog(t *testing.T) {
+	config := DefaultConfig()
+	config.Log = LogCfg{
+		Format: "JSON",
+		SubsystemLevels: map[string]string{
+			" plugins\\configurepackage/ ": "Debug",
+			"/":                            "trace",
+		},
+	}
+
+	parser(&config)
+
+	assert.Equal(t, LogFormatJson, config.Log.Format)
+	assert.Equal(t, map[string]string{"plugins/configurepackage": "debug"}, config.Log.SubsystemLevels)
+
+	config.Log = LogCfg{Format: "yaml"}
+	parser(&config)
+	assert.Equal(t, LogFormatText, config.Log.Format)
+}
+
+func TestParseActivityMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.ActivityMetrics = ActivityMetricsCfg{Namespace: " Fleet/Management ", FlushIntervalSeconds: 5}
+
+	parser(&config)
+
+	assert.Equal(t, "Fleet/Management", config.ActivityMetrics.Namespace)
+	assert.Equal(t, DefaultActivityMetricsFlushIntervalSeconds, config.ActivityMetrics.FlushIntervalSeconds)
+
+	config.ActivityMetrics.Namespace = "AWS/SSM"
+	parser(&config)
+	assert.Equal(t, DefaultActivityMetricsNamespace, config.ActivityMetrics.Namespace)
+}
+
+func TestParsePackageInstallLogs(t *testing.T) {
+	config := DefaultConfig()
+	config.Birdwatcher.InstallLogs = PackageInstallLogsCfg{Enabled: true, MaxFileSizeKB: 1, MaxFiles: 1000}
+
+	parser(&config)
+
+	assert.True(t, config.Birdwatcher.InstallLogs.Enabled)
+	assert.Equal(t, DefaultPackageInstallLogMaxFileSizeKB, config.Birdwatcher.InstallLogs.MaxFileSizeKB)
+	assert.Equal(t, DefaultPackageInstallLogMaxFiles, config.Birdwatcher.InstallLogs.MaxFiles)
+}
@@ -67,10 +67,20 @@ const (
 	FileInventoryRootDirName     = "file"
 	RoleInventoryRootDirName     = "role"
 	InventoryContentHashFileName = "contentHash"
+	InventoryCheckpointFileName  = "checkpoint"
+
+	// LivenessHeartbeatFileName is the heartbeat file of the agent in its data store
+	LivenessHeartbeatFileName = "liveness"
 
 	//aws-ssm-agent bookkeeping constants for failed sent replies
 	RepliesRootDirName = "replies"
 
+	// DeadLetterRootDirName is the directory of the results the agent could not deliver
+	DeadLetterRootDirName = "deadletter"
+
+	// DocumentCacheRootDirName is the directory of the cached SSM documents
+	DocumentCacheRootDirName = "documentcache"
+
 	//aws-ssm-agent bookkeeping constants for compliance
 	ComplianceRootDirName         = "compliance"
 	ComplianceContentHashFileName = "contentHash"
@@ -156,8 +166,39 @@ const (
 	// PluginNameAwsApplications is the name of the Applications plugin
 	PluginNameAwsApplications = "aws:applications"
 
-	AppConfigFileName    = "amazon-ssm-agent.json"
-	SeelogConfigFileName = "seelog.xml"
+	// PluginNameAwsConfigureDefender is the name of the Windows Defender configuration plugin
+	PluginNameAwsConfigureDefender = "aws:configureDefender"
+
+	// PluginNameAwsInstallCertificate is the name of the certificate installation plugin
+	PluginNameAwsInstallCertificate = "aws:installCertificate"
+
+	// PluginNameAwsConfigureFirewall is the name of the host firewall rules plugin
+	PluginNameAwsConfigureFirewall = "aws:configureFirewall"
+
+	// PluginNameAwsKubernetesNode is the name of the Kubernetes node maintenance plugin
+	PluginNameAwsKubernetesNode = "aws:kubernetesNode"
+
+	// PluginNameAwsConfigureVolume is the name of the EBS volume formatting and mounting plugin
+	PluginNameAwsConfigureVolume = "aws:configureVolume"
+
+	// PluginNameAwsConfigureTimeSync is the name of the time synchronization plugin
+	PluginNameAwsConfigureTimeSync = "aws:configureTimeSync"
+
+	// PluginNameAwsConfigureDns is the name of the DNS settings plugin
+	PluginNameAwsConfigureDns = "aws:configureDns"
+
+	// PluginNameAwsLifecycleAction is the name of the Auto Scaling lifecycle hook action plugin
+	PluginNameAwsLifecycleAction = "aws:lifecycleAction"
+
+	// PluginNameAwsFanOut is the name of the plugin running a sub-document against the local targets of the agent
+	PluginNameAwsFanOut = "aws:fanOut"
+
+	// PluginNameAwsLogTailer is the name of the long running plugin streaming files and journald units to CloudWatch Logs
+	PluginNameAwsLogTailer = "aws:logTailer"
+
+	AppConfigFileName          = "amazon-ssm-agent.json"
+	SeelogConfigFileName       = "seelog.xml"
+	ProvisioningConfigFileName = "amazon-ssm-agent.provisioning.json"
 
 	// Output truncation limits
 	MaxStdoutLength = 24000
@@ -170,8 +211,362 @@ const (
 	// PluginNameStandardStream is the name for session manager standard stream plugin aka shell.
 	PluginNameStandardStream = "Standard_Stream"
 
+	// Session start hook defaults
+	DefaultSessionStartHookTimeoutSeconds    = 30
+	DefaultSessionStartHookTimeoutSecondsMin = 1
+	DefaultSessionStartHookTimeoutSecondsMax = 300
+
+	// DefaultIdleWorkerTimeoutMinutes is how long the worker of a session abandoned by its client is kept
+	DefaultIdleWorkerTimeoutMinutes    = 20
+	DefaultIdleWorkerTimeoutMinutesMin = 0
+	DefaultIdleWorkerTimeoutMinutesMax = 1440
+
+	// DefaultWorkerOutputBufferKB is how much of the latest stdout and stderr of a worker is kept to be written to the
+	// diagnostics directory when the worker exits abnormally, 0 does not capture the output of the workers
+	DefaultWorkerOutputBufferKB    = 64
+	DefaultWorkerOutputBufferKBMin = 0
+	DefaultWorkerOutputBufferKBMax = 4096
+
+	// DefaultOutputPartSizeMBMax is the largest size of a part of the plugin output uploaded to s3
+	DefaultOutputPartSizeMBMax = 1024
+
+	// DefaultThrottlePollDelaySecondsMax is the longest extra delay between two message polls during peak hours
+	DefaultThrottlePollDelaySecondsMax = 3600
+
+	// DefaultApiThrottlingThreshold is the number of throttled api calls within five minutes that stretches the non-critical schedules
+	DefaultApiThrottlingThreshold = 10
+	// Throttling backoff factor defaults
+	DefaultMaxBackoffFactor    = 4
+	DefaultMaxBackoffFactorMin = 1
+	DefaultMaxBackoffFactorMax = 16
+
+	// Boot documents defaults
+	BootDocumentRunOnFirstBoot           = "FirstBoot"
+	BootDocumentRunOnEveryBoot           = "EveryBoot"
+	BootDocumentOnFailureContinue        = "Continue"
+	BootDocumentOnFailureStop            = "Stop"
+	DefaultBootDocumentTimeoutSeconds    = 3600
+	DefaultBootDocumentTimeoutSecondsMin = 60
+	DefaultBootDocumentTimeoutSecondsMax = 86400
+
+	// Readiness gates defaults
+	ReadinessOnFailureDefer                = "Defer"
+	ReadinessOnFailureFail                 = "Fail"
+	DefaultReadinessProbeTimeoutSeconds    = 30
+	DefaultReadinessProbeTimeoutSecondsMin = 1
+	DefaultReadinessProbeTimeoutSecondsMax = 600
+	DefaultReadinessDeferTimeoutSeconds    = 1800
+	DefaultReadinessDeferTimeoutSecondsMax = 86400
+
 	// Session default RunAs user name
 	DefaultRunAsUserName = "ssm-user"
+
+	// ManifestCacheBackendFile keeps cached package manifests in the manifest cache directory
+	ManifestCacheBackendFile = "file"
+
+	// ManifestCacheBackendMemory keeps cached package manifests in memory of the document worker
+	ManifestCacheBackendMemory = "memory"
+
+	// DefaultManifestCacheTtlHours is how long a cached package manifest is used to detect package changes
+	DefaultManifestCacheTtlHours = 720
+
+	// DefaultManifestCacheMaxEntries is the number of package manifests kept in the cache
+	DefaultManifestCacheMaxEntries = 500
+
+	// SbomFormatCycloneDX writes package SBOMs as CycloneDX JSON
+	SbomFormatCycloneDX = "cyclonedx"
+
+	// SbomFormatSPDX writes package SBOMs as SPDX JSON
+	SbomFormatSPDX = "spdx"
+
+	// DefaultPackageIntegrityFrequencyMinutes is the interval between verifications of the installed package files
+	DefaultPackageIntegrityFrequencyMinutes    = 60
+	DefaultPackageIntegrityFrequencyMinutesMin = 5
+	DefaultPackageIntegrityFrequencyMinutesMax = 10080
+
+	// DefaultPackageTraceMaxSectionOutputBytes is the output kept for each section of a package install trace
+	DefaultPackageTraceMaxSectionOutputBytes = 4096
+
+	// DefaultPackageTraceMetricNamespace is the CloudWatch namespace of the metrics exported for package operations
+	DefaultPackageTraceMetricNamespace = "SSMAgent/ConfigurePackage"
+
+	// DefaultPackageInstallLogMaxFileSizeKB is the size the install log file of a package is rotated at
+	DefaultPackageInstallLogMaxFileSizeKB    = 1024
+	DefaultPackageInstallLogMaxFileSizeKBMin = 16
+	DefaultPackageInstallLogMaxFileSizeKBMax = 102400
+
+	// DefaultPackageInstallLogMaxFiles is the number of rotated install log files kept for each package
+	DefaultPackageInstallLogMaxFiles    = 5
+	DefaultPackageInstallLogMaxFilesMin = 1
+	DefaultPackageInstallLogMaxFilesMax = 100
+
+	// DefaultArtifactScannerTimeoutSeconds bounds each scan of a package artifact by an external scanner
+	DefaultArtifactScannerTimeoutSeconds    = 300
+	DefaultArtifactScannerTimeoutSecondsMin = 1
+	DefaultArtifactScannerTimeoutSecondsMax = 3600
+
+	// ArtifactScannerPathPlaceholder is replaced by the path of the scanned artifact in the scanner arguments
+	ArtifactScannerPathPlaceholder = "{artifact}"
+
+	// DefaultPackageLockWaitSeconds is how long ConfigurePackage waits for another operation on the same package
+	DefaultPackageLockWaitSeconds    = 60
+	DefaultPackageLockWaitSecondsMax = 3600
+
+	// DefaultFacadeFailoverThreshold is the number of unreachable attempts in a row before failing over to the next endpoint
+	DefaultFacadeFailoverThreshold    = 3
+	DefaultFacadeFailoverThresholdMin = 1
+	DefaultFacadeFailoverThresholdMax = 20
+
+	// DefaultPackageDownloadParallelism is the number of packages downloaded at the same time by ConfigurePackage
+	DefaultPackageDownloadParallelism    = 3
+	DefaultPackageDownloadParallelismMin = 1
+	DefaultPackageDownloadParallelismMax = 16
+
+	// DefaultPackageResultRetryMinutes is the interval between submissions of the queued package results
+	DefaultPackageResultRetryMinutes    = 15
+	DefaultPackageResultRetryMinutesMin = 1
+	DefaultPackageResultRetryMinutesMax = 1440
+
+	// DefaultPackageKeepVersions is the number of versions of each package kept in the local package repository
+	DefaultPackageKeepVersions    = 2
+	DefaultPackageKeepVersionsMin = 1
+	DefaultPackageKeepVersionsMax = 100
+
+	// TlsRevocationOff disables revocation checking of server certificates
+	TlsRevocationOff = "Off"
+
+	// TlsRevocationSoftFail rejects revoked certificates and allows the ones whose status is unknown
+	TlsRevocationSoftFail = "SoftFail"
+
+	// TlsRevocationHardFail rejects revoked certificates and the ones whose status is unknown
+	TlsRevocationHardFail = "HardFail"
+
+	// SelfIntegrityOff disables the verification of the agent binaries at startup
+	SelfIntegrityOff = "Off"
+
+	// SelfIntegrityAlert reports the agent binaries that do not match their manifest and starts the agent
+	SelfIntegrityAlert = "Alert"
+
+	// SelfIntegrityEnforce refuses to start the agent when its binaries do not match their manifest
+	SelfIntegrityEnforce = "Enforce"
+
+	// SelfIntegrityManifestFileName is the manifest of the agent binaries installed next to the agent executable
+	SelfIntegrityManifestFileName = "amazon-ssm-agent.manifest"
+
+	// DefaultCrlCacheMinutes is the longest time a fetched CRL is used
+	DefaultCrlCacheMinutes = 60
+
+	// DefaultTelemetryEndpoint is the OTLP/HTTP receiver of a collector running on the instance
+	DefaultTelemetryEndpoint = "http://localhost:4318"
+
+	// DefaultTelemetryExportIntervalSeconds is how often the traces and metrics are exported to the collector
+	DefaultTelemetryExportIntervalSeconds    = 10
+	DefaultTelemetryExportIntervalSecondsMin = 1
+	DefaultTelemetryExportIntervalSecondsMax = 300
+
+	// DefaultEventsSource is the source of the events published to EventBridge
+	DefaultEventsSource = "ssm-agent"
+
+	// DefaultEventsFlushIntervalSeconds is how often the events are published to EventBridge
+	DefaultEventsFlushIntervalSeconds    = 5
+	DefaultEventsFlushIntervalSecondsMin = 1
+	DefaultEventsFlushIntervalSecondsMax = 300
+
+	// DefaultActivityMetricsNamespace is the CloudWatch namespace of the activity metrics
+	DefaultActivityMetricsNamespace = "SSMAgent"
+
+	// DefaultActivityMetricsFlushIntervalSeconds is how often the activity metrics are published to CloudWatch
+	DefaultActivityMetricsFlushIntervalSeconds    = 60
+	DefaultActivityMetricsFlushIntervalSecondsMin = 10
+	DefaultActivityMetricsFlushIntervalSecondsMax = 3600
+
+	// DefaultErrorReportsPerWindow is how many times an error is reported in the telemetry and the events by window
+	DefaultErrorReportsPerWindow    = 10
+	DefaultErrorReportsPerWindowMin = 1
+	DefaultErrorReportsPerWindowMax = 1000
+
+	// DefaultErrorReportingWindowSeconds is the window the reports of an error are limited in
+	DefaultErrorReportingWindowSeconds    = 300
+	DefaultErrorReportingWindowSecondsMin = 10
+	DefaultErrorReportingWindowSecondsMax = 86400
+
+	// WebhookEventDocumentCompleted is the callback of a document that completed, with its final status
+	WebhookEventDocumentCompleted = "DocumentCompleted"
+
+	// WebhookEventStepCompleted is the callback of a step of a document that completed
+	WebhookEventStepCompleted = "StepCompleted"
+
+	// DefaultWebhookTimeoutSeconds is the timeout of a webhook callback request
+	DefaultWebhookTimeoutSeconds    = 10
+	DefaultWebhookTimeoutSecondsMin = 1
+	DefaultWebhookTimeoutSecondsMax = 60
+
+	// DefaultWebhookMaxAttempts is how many times a webhook callback is sent before it is dropped
+	DefaultWebhookMaxAttempts    = 3
+	DefaultWebhookMaxAttemptsMin = 1
+	DefaultWebhookMaxAttemptsMax = 10
+
+	// DefaultLivenessIntervalSeconds is how often the liveness signal is given and the loops are checked
+	DefaultLivenessIntervalSeconds    = 30
+	DefaultLivenessIntervalSecondsMin = 5
+	DefaultLivenessIntervalSecondsMax = 300
+
+	// DefaultStuckLoopMinutes is how long a loop can go without completing an iteration beyond its period
+	DefaultStuckLoopMinutes    = 30
+	DefaultStuckLoopMinutesMin = 5
+	DefaultStuckLoopMinutesMax = 1440
+
+	// DefaultMaxLoopRestarts is how many times a stuck loop is restarted before the agent is unhealthy
+	DefaultMaxLoopRestarts    = 3
+	DefaultMaxLoopRestartsMin = 0
+	DefaultMaxLoopRestartsMax = 10
+
+	// DefaultRoleMonitorIntervalSeconds is how often the role of the instance is read from the instance metadata
+	DefaultRoleMonitorIntervalSeconds    = 60
+	DefaultRoleMonitorIntervalSecondsMin = 10
+	DefaultRoleMonitorIntervalSecondsMax = 3600
+
+	// DefaultRootlessElevationHelper is the setuid helper running the elevated operations, it never prompts
+	DefaultRootlessElevationHelper = "/usr/bin/sudo -n --"
+
+	// ElevationOperation* are the operations needing root when the agent does not run as root
+	ElevationOperationPackageInstall = "PackageInstall"
+	ElevationOperationServiceControl = "ServiceControl"
+
+	// AuditExportFormat* are the formats of the exported audit events
+	AuditExportFormatCef  = "CEF"
+	AuditExportFormatLeef = "LEEF"
+
+	// FanOutTarget* are the types of the local targets of the aws:fanOut plugin
+	FanOutTargetSsh       = "ssh"
+	FanOutTargetWinRm     = "winrm"
+	FanOutTargetContainer = "container"
+
+	// DefaultFanOutMaxConcurrency is how many targets a fan-out step runs against at the same time
+	DefaultFanOutMaxConcurrency    = 5
+	DefaultFanOutMaxConcurrencyMin = 1
+	DefaultFanOutMaxConcurrencyMax = 100
+
+	// DefaultArtifactCacheMaxSizeMB is the size of the shared artifact cache
+	DefaultArtifactCacheMaxSizeMB    = 1024
+	DefaultArtifactCacheMaxSizeMBMin = 64
+	DefaultArtifactCacheMaxSizeMBMax = 102400
+
+	// DefaultExecPluginHandshakeTimeoutSeconds is how long a plugin binary has to describe its plugin
+	DefaultExecPluginHandshakeTimeoutSeconds    = 5
+	DefaultExecPluginHandshakeTimeoutSecondsMin = 1
+	DefaultExecPluginHandshakeTimeoutSecondsMax = 60
+
+	// DefaultExecPluginHeartbeatTimeoutSeconds is how long a running plugin can stay silent
+	DefaultExecPluginHeartbeatTimeoutSeconds    = 300
+	DefaultExecPluginHeartbeatTimeoutSecondsMin = 10
+	DefaultExecPluginHeartbeatTimeoutSecondsMax = 3600
+
+	// DefaultPreconditionCheckTimeoutSeconds is how long a precondition check can run
+	DefaultPreconditionCheckTimeoutSeconds    = 10
+	DefaultPreconditionCheckTimeoutSecondsMin = 1
+	DefaultPreconditionCheckTimeoutSecondsMax = 300
+
+	// DefaultPreconditionCheckCacheSeconds is how long the result of a precondition check is reused
+	DefaultPreconditionCheckCacheSeconds    = 60
+	DefaultPreconditionCheckCacheSecondsMin = 0
+	DefaultPreconditionCheckCacheSecondsMax = 3600
+
+	// DefaultStatusEndpointStaleContactSeconds is how long the agent can go without reaching MDS or MGS
+	// before the status endpoint reports it as disconnected
+	DefaultStatusEndpointStaleContactSeconds    = 600
+	DefaultStatusEndpointStaleContactSecondsMin = 60
+	DefaultStatusEndpointStaleContactSecondsMax = 86400
+
+	// DefaultMaxConcurrentUploads is the number of uploads of the upload queue running at the same time
+	DefaultMaxConcurrentUploads    = 2
+	DefaultMaxConcurrentUploadsMin = 1
+	DefaultMaxConcurrentUploadsMax = 16
+
+	// DefaultUploadMaxAttempts is how many times a queued upload is tried before it is dropped
+	DefaultUploadMaxAttempts    = 3
+	DefaultUploadMaxAttemptsMin = 1
+	DefaultUploadMaxAttemptsMax = 10
+
+	// DefaultMaxQueuedUploads is the number of uploads waiting in the upload queue
+	DefaultMaxQueuedUploads    = 500
+	DefaultMaxQueuedUploadsMin = 10
+	DefaultMaxQueuedUploadsMax = 10000
+
+	// DefaultSpoolMaxSizeMB is the size of the uploads spooled on a disconnected instance
+	DefaultSpoolMaxSizeMB    = 1024
+	DefaultSpoolMaxSizeMBMin = 10
+	DefaultSpoolMaxSizeMBMax = 102400
+
+	// LogFormatText writes the agent logs as lines of text
+	LogFormatText = "text"
+
+	// LogFormatJson writes every agent log entry as a json object
+	LogFormatJson = "json"
+
+	// InteractivePromptActionNone leaves the scripts waiting for input until they time out
+	InteractivePromptActionNone = "None"
+
+	// InteractivePromptActionFail stops the scripts waiting for input
+	InteractivePromptActionFail = "Fail"
+
+	// InteractivePromptActionRespond answers the prompts of the scripts from a response file
+	InteractivePromptActionRespond = "Respond"
+
+	// DefaultInteractivePromptIdleSeconds is how long a script waits after a prompt before it is considered prompting
+	DefaultInteractivePromptIdleSeconds    = 30
+	DefaultInteractivePromptIdleSecondsMin = 5
+	DefaultInteractivePromptIdleSecondsMax = 3600
+
+	// DefaultWorkspaceOverwritePasses is how many times the files of a workspace are overwritten when it is securely deleted
+	DefaultWorkspaceOverwritePasses    = 1
+	DefaultWorkspaceOverwritePassesMin = 1
+	DefaultWorkspaceOverwritePassesMax = 7
+
+	// DefaultStepRebootLimit is how many reboots a step can request before it fails, which stops reboot loops
+	DefaultStepRebootLimit    = 10
+	DefaultStepRebootLimitMin = 1
+	DefaultStepRebootLimitMax = 100
+
+	// DefaultInventoryFullRefreshHours is how often the inventory types are uploaded in full between the delta uploads
+	DefaultInventoryFullRefreshHours    = 24
+	DefaultInventoryFullRefreshHoursMin = 1
+	DefaultInventoryFullRefreshHoursMax = 168
+
+	// DefaultDocumentCacheMaxEntries is how many SSM documents the agent caches
+	DefaultDocumentCacheMaxEntries    = 200
+	DefaultDocumentCacheMaxEntriesMin = 1
+	DefaultDocumentCacheMaxEntriesMax = 10000
+
+	// IpcTransportFile is the file based ipc transport between agent and document/session workers
+	IpcTransportFile = "file"
+
+	// IpcTransportSocket is the local socket based ipc transport between agent and document/session workers
+	IpcTransportSocket = "socket"
+
+	// DualStackAuto uses the dualstack endpoints when the host has no IPv4 route
+	DualStackAuto = "Auto"
+
+	// DualStackEnabled always uses the dualstack endpoints
+	DualStackEnabled = "Enabled"
+
+	// DualStackDisabled never uses the dualstack endpoints
+	DualStackDisabled = "Disabled"
+
+	// WorkerLaunchProcess starts the workers as child processes of the agent
+	WorkerLaunchProcess = "Process"
+
+	// WorkerLaunchTaskScheduler starts the workers with the Task Scheduler, on Windows only
+	WorkerLaunchTaskScheduler = "TaskScheduler"
+
+	// WorkerLaunchFallback starts the workers with the Task Scheduler when they cannot be started as child processes
+	WorkerLaunchFallback = "Fallback"
+
+	// UserAgentSuffixMaxLength is the maximum length of the user agent suffix, longer suffixes are truncated
+	UserAgentSuffixMaxLength = 128
+
+	// ContainerModeEnvVar enables container mode when set to true, the agent sets it for the workers it starts
+	ContainerModeEnvVar = "AWS_SSM_AGENT_CONTAINER_MODE"
 )
 
 // Document versions that are supported by this Agent version.
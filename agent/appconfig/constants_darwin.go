@@ -23,12 +23,22 @@ const (
 	// AppConfigPath is the path of the AppConfig
 	AppConfigPath = DefaultProgramFolder + AppConfigFileName
 
+	// ProvisioningConfigPath is the path of the configuration overrides supplied at instance launch
+	ProvisioningConfigPath = DefaultProgramFolder + ProvisioningConfigFileName
+
 	// PackageRoot specifies the directory under which packages will be downloaded and installed
 	PackageRoot = DefaultProgramFolder + "packages"
 
 	// PackageLockRoot specifies the directory under which package lock files will reside
 	PackageLockRoot = DefaultProgramFolder + "locks/packages"
 
+	// PackageStagingRoot specifies the directory packages are extracted to before they are moved to the PackageRoot,
+	// it must be on the same file system as the PackageRoot
+	PackageStagingRoot = DefaultProgramFolder + "staging/packages"
+
+	// PackageSnapshotRoot specifies the directory the installed version of a package is copied to before an upgrade
+	PackageSnapshotRoot = DefaultProgramFolder + "snapshots/packages"
+
 	// PackagePlatform is the platform name to use when looking for packages
 	PackagePlatform = "darwin"
 
@@ -47,12 +57,24 @@ const (
 	// are moved if the service cannot validate the document (generally impossible via cli)
 	LocalCommandRootInvalid = DefaultProgramFolder + "localcommands/invalid"
 
+	// LocalScheduleRoot specifies the directory where documents scheduled to run locally are registered
+	LocalScheduleRoot = DefaultProgramFolder + "localschedules"
+
 	// DownloadRoot specifies the directory under which files will be downloaded
 	DownloadRoot = DefaultProgramFolder + "download/"
 
 	// DefaultDataStorePath represents the directory for storing system data
 	DefaultDataStorePath = DefaultProgramFolder + "data/"
 
+	// DefaultStatusEndpointPath is the unix socket the local status endpoint listens on
+	DefaultStatusEndpointPath = DefaultDataStorePath + "status.sock"
+
+	// DefaultRootlessAuditLogPath is the file the elevations of the operations needing root are appended to
+	DefaultRootlessAuditLogPath = DefaultDataStorePath + "elevation-audit.log"
+
+	// DefaultAuditSyslogSocket is the local syslog socket the audit events are exported to
+	DefaultAuditSyslogSocket = "/var/run/syslog"
+
 	// EC2ConfigDataStorePath represents the directory for storing ec2 config data
 	EC2ConfigDataStorePath = "/var/lib/amazon/ec2config/"
 
@@ -62,12 +84,37 @@ const (
 	// UpdaterArtifactsRoot represents the directory for storing update related information
 	UpdaterArtifactsRoot = DefaultProgramFolder + "update/"
 
+	// AgentInstallRoot represents the directory for side-by-side installations of the agent
+	AgentInstallRoot = DefaultProgramFolder + "install/"
+
 	// DefaultPluginPath represents the directory for storing plugins in SSM
 	DefaultPluginPath = DefaultProgramFolder + "plugins"
 
 	// ManifestCacheDirectory represents the directory for storing all downloaded manifest files
 	ManifestCacheDirectory = DefaultProgramFolder + "manifests"
 
+	// SbomDirectory represents the directory for storing the SBOMs of installed packages
+	SbomDirectory = DefaultProgramFolder + "sbom"
+
+	// PackageIntegrityDirectory represents the directory for storing the file checksums of installed packages
+	PackageIntegrityDirectory = DefaultProgramFolder + "packageintegrity"
+
+	// PackageResultQueueDirectory represents the directory for storing package results not yet reported to the service
+	PackageResultQueueDirectory = DefaultProgramFolder + "packageresults"
+
+	// PackageArtifactCacheDirectory represents the directory for storing the last downloaded artifact of each package,
+	// the base of the deltas of its next versions
+	PackageArtifactCacheDirectory = DefaultProgramFolder + "packageartifacts"
+
+	// ArtifactCacheDirectory represents the directory of the downloaded artifacts shared by the downloads with the same checksum
+	ArtifactCacheDirectory = DefaultProgramFolder + "artifactcache"
+
+	// PackageLogsDirectory represents the directory of the install log files of each package
+	PackageLogsDirectory = "/var/log/amazon/ssm/packages"
+
+	// WorkerDiagnosticsDirectory represents the directory the last output of the workers which exited abnormally is written to
+	WorkerDiagnosticsDirectory = "/var/log/amazon/ssm/diagnostics"
+
 	// List all plugin names, unfortunately golang doesn't support const arrays of strings
 
 	// RebootExitCode that would trigger a Soft Reboot
@@ -28,6 +28,13 @@ const (
 	// PackageLockRoot specifies the directory under which package lock files will reside
 	PackageLockRoot = "/var/lib/amazon/ssm/locks/packages"
 
+	// PackageStagingRoot specifies the directory packages are extracted to before they are moved to the PackageRoot,
+	// it must be on the same file system as the PackageRoot
+	PackageStagingRoot = "/var/lib/amazon/ssm/staging/packages"
+
+	// PackageSnapshotRoot specifies the directory the installed version of a package is copied to before an upgrade
+	PackageSnapshotRoot = "/var/lib/amazon/ssm/snapshots/packages"
+
 	// PackagePlatform is the platform name to use when looking for packages
 	PackagePlatform = "linux"
 
@@ -46,12 +53,24 @@ const (
 	// are moved if the service cannot validate the document (generally impossible via cli)
 	LocalCommandRootInvalid = "/var/lib/amazon/ssm/localcommands/invalid"
 
+	// LocalScheduleRoot specifies the directory where documents scheduled to run locally are registered
+	LocalScheduleRoot = "/var/lib/amazon/ssm/localschedules"
+
 	// DownloadRoot specifies the directory under which files will be downloaded
 	DownloadRoot = "/var/log/amazon/ssm/download/"
 
 	// DefaultDataStorePath represents the directory for storing system data
 	DefaultDataStorePath = "/var/lib/amazon/ssm/"
 
+	// DefaultStatusEndpointPath is the unix socket the local status endpoint listens on
+	DefaultStatusEndpointPath = DefaultDataStorePath + "status.sock"
+
+	// DefaultRootlessAuditLogPath is the file the elevations of the operations needing root are appended to
+	DefaultRootlessAuditLogPath = DefaultDataStorePath + "elevation-audit.log"
+
+	// DefaultAuditSyslogSocket is the local syslog socket the audit events are exported to
+	DefaultAuditSyslogSocket = "/dev/log"
+
 	// EC2ConfigDataStorePath represents the directory for storing ec2 config data
 	EC2ConfigDataStorePath = "/var/lib/amazon/ec2config/"
 
@@ -61,12 +80,37 @@ const (
 	// UpdaterArtifactsRoot represents the directory for storing update related information
 	UpdaterArtifactsRoot = "/var/lib/amazon/ssm/update/"
 
+	// AgentInstallRoot represents the directory for side-by-side installations of the agent
+	AgentInstallRoot = "/opt/amazon/ssm/"
+
 	// DefaultPluginPath represents the directory for storing plugins in SSM
 	DefaultPluginPath = "/var/lib/amazon/ssm/plugins"
 
 	// ManifestCacheDirectory represents the directory for storing all downloaded manifest files
 	ManifestCacheDirectory = "/var/lib/amazon/ssm/manifests"
 
+	// SbomDirectory represents the directory for storing the SBOMs of installed packages
+	SbomDirectory = "/var/lib/amazon/ssm/sbom"
+
+	// PackageIntegrityDirectory represents the directory for storing the file checksums of installed packages
+	PackageIntegrityDirectory = "/var/lib/amazon/ssm/packageintegrity"
+
+	// PackageResultQueueDirectory represents the directory for storing package results not yet reported to the service
+	PackageResultQueueDirectory = "/var/lib/amazon/ssm/packageresults"
+
+	// PackageArtifactCacheDirectory represents the directory for storing the last downloaded artifact of each package,
+	// the base of the deltas of its next versions
+	PackageArtifactCacheDirectory = "/var/lib/amazon/ssm/packageartifacts"
+
+	// ArtifactCacheDirectory represents the directory of the downloaded artifacts shared by the downloads with the same checksum
+	ArtifactCacheDirectory = "/var/lib/amazon/ssm/artifactcache"
+
+	// PackageLogsDirectory represents the directory of the install log files of each package
+	PackageLogsDirectory = "/var/log/amazon/ssm/packages"
+
+	// WorkerDiagnosticsDirectory represents the directory the last output of the workers which exited abnormally is written to
+	WorkerDiagnosticsDirectory = "/var/log/amazon/ssm/diagnostics"
+
 	// List all plugin names, unfortunately golang doesn't support const arrays of strings
 
 	// RebootExitCode that would trigger a Soft Reboot
@@ -100,6 +144,9 @@ var DefaultSessionLogger = "/usr/bin/ssm-session-logger"
 // AppConfigPath is the path of the AppConfig
 var AppConfigPath = DefaultProgramFolder + AppConfigFileName
 
+// ProvisioningConfigPath is the path of the configuration overrides supplied at instance launch
+var ProvisioningConfigPath = DefaultProgramFolder + ProvisioningConfigFileName
+
 func init() {
 	/*
 	   Powershell command used to be poweshell in alpha versions, now it's pwsh in prod versions
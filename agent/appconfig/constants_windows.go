@@ -38,6 +38,12 @@ const (
 	// ManifestCacheFolder path under local app data
 	ManifestCacheFolder = "Amazon\\SSM\\Manifests"
 
+	// DefaultStatusEndpointPath is the named pipe the local status endpoint listens on
+	DefaultStatusEndpointPath = `\\.\pipe\amazon-ssm-agent-status`
+
+	// DefaultAuditSyslogSocket is empty, Windows has no local syslog and the audit events are exported to a file
+	DefaultAuditSyslogSocket = ""
+
 	// Exit Code that would trigger a Soft Reboot
 	RebootExitCode = 3010
 
@@ -85,15 +91,28 @@ var DefaultSessionLogger string
 // AppConfig Path
 var AppConfigPath string
 
+// ProvisioningConfigPath is the path of the configuration overrides supplied at instance launch
+var ProvisioningConfigPath string
+
 // DefaultDataStorePath represents the directory for storing system data
 var DefaultDataStorePath string
 
+// DefaultRootlessAuditLogPath is the file the elevations of the operations needing root are appended to
+var DefaultRootlessAuditLogPath string
+
 // PackageRoot specifies the directory under which packages will be downloaded and installed
 var PackageRoot string
 
 // PackageLockRoot specifies the directory under which package lock files will reside
 var PackageLockRoot string
 
+// PackageStagingRoot specifies the directory packages are extracted to before they are moved to the PackageRoot,
+// it must be on the same volume as the PackageRoot
+var PackageStagingRoot string
+
+// PackageSnapshotRoot specifies the directory the installed version of a package is copied to before an upgrade
+var PackageSnapshotRoot string
+
 // DaemonRoot specifies the directory where daemon registration information is stored
 var DaemonRoot string
 
@@ -109,18 +128,46 @@ var LocalCommandRootCompleted string
 // are moved if the service cannot validate the document (generally impossible via cli)
 var LocalCommandRootInvalid string
 
+// LocalScheduleRoot specifies the directory where documents scheduled to run locally are registered
+var LocalScheduleRoot string
+
 // DefaultPluginPath represents the directory for storing plugins in SSM
 var DefaultPluginPath string
 
 // ManifestCacheDirectory represents the directory for storing all downloaded manifest files
 var ManifestCacheDirectory string
 
+// SbomDirectory represents the directory for storing the SBOMs of installed packages
+var SbomDirectory string
+
+// PackageIntegrityDirectory represents the directory for storing the file checksums of installed packages
+var PackageIntegrityDirectory string
+
+// PackageResultQueueDirectory represents the directory for storing package results not yet reported to the service
+var PackageResultQueueDirectory string
+
+// PackageArtifactCacheDirectory represents the directory for storing the last downloaded artifact of each package,
+// the base of the deltas of its next versions
+var PackageArtifactCacheDirectory string
+
+// ArtifactCacheDirectory represents the directory of the downloaded artifacts shared by the downloads with the same checksum
+var ArtifactCacheDirectory string
+
+// WorkerDiagnosticsDirectory represents the directory the last output of the workers which exited abnormally is written to
+var WorkerDiagnosticsDirectory string
+
+// PackageLogsDirectory represents the directory of the install log files of each package
+var PackageLogsDirectory string
+
 // DownloadRoot specifies the directory under which files will be downloaded
 var DownloadRoot string
 
 // UpdaterArtifactsRoot represents the directory for storing update related information
 var UpdaterArtifactsRoot string
 
+// AgentInstallRoot represents the directory for side-by-side installations of the agent
+var AgentInstallRoot string
+
 // EC2ConfigDataStorePath represents the directory for storing ec2 config data
 var EC2ConfigDataStorePath string
 
@@ -176,16 +223,29 @@ func init() {
 	DefaultSessionLogger = fmt.Sprintf("&'%s'", filepath.Join(DefaultProgramFolder, "ssm-session-logger.exe"))
 	ManifestCacheDirectory = filepath.Join(EnvProgramFiles, ManifestCacheFolder)
 	AppConfigPath = filepath.Join(DefaultProgramFolder, AppConfigFileName)
+	ProvisioningConfigPath = filepath.Join(DefaultProgramFolder, ProvisioningConfigFileName)
 	DefaultDataStorePath = filepath.Join(SSMDataPath, "InstanceData")
+	DefaultRootlessAuditLogPath = filepath.Join(DefaultDataStorePath, "elevation-audit.log")
 	PackageRoot = filepath.Join(SSMDataPath, "Packages")
+	SbomDirectory = filepath.Join(SSMDataPath, "Sbom")
+	PackageIntegrityDirectory = filepath.Join(SSMDataPath, "PackageIntegrity")
+	PackageResultQueueDirectory = filepath.Join(SSMDataPath, "PackageResults")
+	PackageArtifactCacheDirectory = filepath.Join(SSMDataPath, "PackageArtifacts")
+	ArtifactCacheDirectory = filepath.Join(SSMDataPath, "ArtifactCache")
+	WorkerDiagnosticsDirectory = filepath.Join(SSMDataPath, "Logs", "Diagnostics")
+	PackageLogsDirectory = filepath.Join(SSMDataPath, "Logs", "Packages")
 	PackageLockRoot = filepath.Join(SSMDataPath, "Locks\\Packages")
+	PackageStagingRoot = filepath.Join(SSMDataPath, "Staging\\Packages")
+	PackageSnapshotRoot = filepath.Join(SSMDataPath, "Snapshots\\Packages")
 	DaemonRoot = filepath.Join(SSMDataPath, "Daemons")
 	LocalCommandRoot = filepath.Join(SSMDataPath, "LocalCommands")
 	LocalCommandRootSubmitted = filepath.Join(LocalCommandRoot, "Submitted")
 	LocalCommandRootCompleted = filepath.Join(LocalCommandRoot, "Completed")
 	LocalCommandRootInvalid = filepath.Join(LocalCommandRoot, "Invalid")
+	LocalScheduleRoot = filepath.Join(SSMDataPath, "LocalSchedules")
 	DownloadRoot = filepath.Join(temp, SSMFolder, "Download")
 	UpdaterArtifactsRoot = filepath.Join(temp, SSMFolder, "Update")
+	AgentInstallRoot = filepath.Join(SSMDataPath, "Install")
 	EC2UpdateArtifactsRoot = filepath.Join(EnvWinDir, EC2ConfigServiceFolder, "Update")
 	EC2UpdaterDownloadRoot = filepath.Join(temp, EC2ConfigAppDataFolder, "Download")
 
@@ -43,6 +43,68 @@ type SsmCfg struct {
 	AssociationLogsRetentionDurationHours int
 	RunCommandLogsRetentionDurationHours  int
 	SessionLogsRetentionDurationHours     int
+	// DownloadRateLimitBytesPerSecond caps the combined throughput of the artifact downloads of the agent, such as
+	// packages, downloaded content and agent updates. 0 does not limit them
+	DownloadRateLimitBytesPerSecond int64
+	InteractivePrompt               InteractivePromptCfg
+	Workspace                       WorkspaceCfg
+	// StepRebootLimit is how many reboots a step can request to be run again before it fails
+	StepRebootLimit int
+	// DeferDisruptiveStepsOnActiveNode skips the steps marked disruptive while the instance owns a role of its
+	// failover cluster, so they only run once the roles moved to another node
+	DeferDisruptiveStepsOnActiveNode bool
+	ConsoleSession                   ConsoleSessionCfg
+	Inventory                        InventoryCfg
+	DocumentCache                    DocumentCacheCfg
+}
+
+// DocumentCacheCfg represents the local cache of the SSM documents the agent downloads to run them, such as the
+// documents of aws:runDocument steps
+type DocumentCacheCfg struct {
+	// Enabled serves the documents from the cache while the service reports they did not change, and when the
+	// service can't be reached
+	Enabled bool
+	// MaxEntries is how many documents are cached, the least recently written are removed above it
+	MaxEntries int
+}
+
+// InventoryCfg represents how the inventory plugin uploads the inventory of the instance
+type InventoryCfg struct {
+	// DeltaUploads only uploads the entries added to and removed from an inventory type since the last upload the
+	// service acknowledged, instead of all the entries of the type
+	DeltaUploads bool
+	// FullRefreshHours is how often all the entries of a type are uploaded again when the uploads are deltas
+	FullRefreshHours int
+}
+
+// ConsoleSessionCfg represents the steps run in the session of the user logged on to the console of a Windows instance,
+// such as GUI installers that refuse to run silently
+type ConsoleSessionCfg struct {
+	// Enabled allows the steps to request the console session, they fail when it is disabled
+	Enabled bool
+	// CaptureScreenshot uploads a screenshot of the console to ScreenshotS3BucketName when such a step fails
+	CaptureScreenshot      bool
+	ScreenshotS3BucketName string
+	ScreenshotS3KeyPrefix  string
+}
+
+// WorkspaceCfg represents the ephemeral workspace directory created for each document execution
+type WorkspaceCfg struct {
+	// RootDirectory is where the workspaces are created, the temporary directory of the system when empty
+	RootDirectory string
+	// SecureDelete overwrites the files of the workspace OverwritePasses times before removing them
+	SecureDelete    bool
+	OverwritePasses int
+}
+
+// InteractivePromptCfg represents the handling of scripts that stop to prompt for input
+type InteractivePromptCfg struct {
+	// Action is None to let the script wait until it times out, Fail to stop it as soon as a prompt is detected
+	// or Respond to answer the prompts with the lines of ResponseFile
+	Action       string
+	ResponseFile string
+	// IdleSeconds is how long a script has to wait without output after writing a prompt to be considered prompting
+	IdleSeconds int
 }
 
 // AgentInfo represents metadata for amazon-ssm-agent
@@ -52,6 +114,25 @@ type AgentInfo struct {
 	Region               string
 	OrchestrationRootDir string
 	DownloadRootDir      string
+	IpcTransport         string
+	// ContainerMode runs the agent in the foreground and disables the plugins that manage the host
+	ContainerMode bool
+	// DualStack selects the dualstack endpoints, reachable over IPv6, of the AWS services without an endpoint in the
+	// configuration: Auto when the host has no IPv4 route, Enabled or Disabled
+	DualStack string
+	// UserAgentSuffix is appended to the user agent of the AWS requests of the agent, such as a team or fleet
+	// identifier attributing the requests in CloudTrail
+	UserAgentSuffix string
+	// WorkerLaunch selects how the document and session workers are started on Windows: Process creates them as
+	// child processes, TaskScheduler runs them as on demand hidden scheduled tasks for hosts whose endpoint security
+	// blocks the child processes of the agent, Fallback uses a scheduled task when creating the process fails
+	WorkerLaunch string
+	// WorkerOutputBufferKB is how much of the latest stdout and stderr of each worker is kept in memory and written to
+	// the diagnostics directory when the worker exits abnormally, 0 does not capture the output of the workers
+	WorkerOutputBufferKB int
+	// Simulation runs every document without side effects: the scripts are echoed instead of run, the files that
+	// would be written are compared with the files on disk and the packages are downloaded but not installed
+	Simulation bool
 }
 
 // MgsConfig represents configuration for Message Gateway service
@@ -60,6 +141,20 @@ type MgsConfig struct {
 	Endpoint            string
 	StopTimeoutMillis   int64
 	SessionWorkersLimit int
+	// EphemeralSessionUsers runs every shell session as a dedicated local user created and removed with the session
+	EphemeralSessionUsers bool
+	SessionStartHook      SessionStartHookCfg
+	// IdleWorkerTimeoutMinutes terminates the worker of a session which exchanged no data for that long while its
+	// client is gone, 0 keeps the workers until their session ends
+	IdleWorkerTimeoutMinutes int
+}
+
+// SessionStartHookCfg represents the local authorization hooks invoked before a session is started
+type SessionStartHookCfg struct {
+	RequireJustification bool
+	ScriptPath           string
+	WebhookUrl           string
+	TimeoutSeconds       int
 }
 
 // OsInfo represents os related information
@@ -75,23 +170,604 @@ type S3Cfg struct {
 	Region    string
 	LogBucket string
 	LogKey    string
+	// OutputPartSizeMB splits plugin output uploaded to s3 in ordered parts of this size, 0 uploads a single object
+	OutputPartSizeMB int
+}
+
+// ResultAttributesCfg represents the operator defined attributes reported with the package results, such as the
+// environment, the team or the stack of the instance, which the attributes of a document override
+type ResultAttributesCfg struct {
+	// Static attributes are reported as is
+	Static map[string]string
+	// InstanceTags are the keys of the instance tags reported as attributes, read from the instance metadata which
+	// the instance must allow access to its tags
+	InstanceTags []string
 }
 
 // BirdwatcherCfg represents configuration related to ConfigurePackage Birdwatcher integration
 type BirdwatcherCfg struct {
-	ForceEnable bool
+	ForceEnable     bool
+	ManifestSigning ManifestSigningCfg
+	ArtifactSigning ArtifactSigningCfg
+	ManifestCache   ManifestCacheCfg
+	Sbom            SbomCfg
+	Integrity       PackageIntegrityCfg
+	Trace           PackageTraceCfg
+	InstallLogs     PackageInstallLogsCfg
+	Failover        FacadeFailoverCfg
+	// AntiRollback prevents installing a version older than the installed version of any package,
+	// packages can also opt in with their manifest. A document can still downgrade a package with force
+	AntiRollback bool
+	// LocalArchivePath is a directory or file url (such as a mounted share) packages are installed from
+	// instead of the service, for instances without connectivity to it
+	LocalArchivePath string
+	// LockWaitSeconds is how long an operation on a package waits for another operation on the same package,
+	// started by another document, to complete before failing. 0 fails right away
+	LockWaitSeconds int
+	// DownloadParallelism is the number of packages, such as the dependencies of a package, downloaded at the same
+	// time, the packages are still installed one at a time
+	DownloadParallelism int
+	// ResultRetryMinutes is how often the package results that could not be reported are submitted again,
+	// they are also submitted after each result reported successfully
+	ResultRetryMinutes int
+	// Retention limits the versions and downloads kept in the local package repository
+	Retention PackageRetentionCfg
+	// ResultAttributes are reported with the package results to the service
+	ResultAttributes ResultAttributesCfg
+	// PlatformAliases maps a platform, such as a RHEL derivative, to the platform whose packages it installs when
+	// a manifest has no packages for the platform itself. An empty value removes a default alias
+	PlatformAliases map[string]string
+	// Channel is the rollout channel, such as beta or canary, whose version is installed when a document installs
+	// the latest version of a package and its manifest advertises a version to the channel. Empty follows stable
+	Channel string
+	// ArtifactScanners run in order on each downloaded package artifact before it is extracted, an artifact
+	// rejected by one of them is not installed
+	ArtifactScanners []ArtifactScannerCfg
+	// DownloadMirrors redirect the package artifact downloads to internal mirrors, such as an Artifactory or S3
+	// mirror reachable from the VPC. The first mirror matching an artifact url is tried before the url itself
+	DownloadMirrors []DownloadMirrorCfg
+}
+
+// DownloadMirrorCfg rewrites the artifact urls starting with Prefix, replacing the prefix with Mirror, or the urls
+// matching the regular expression Pattern, replacing the match with Mirror expanded with its submatches ($1).
+// Headers, such as an authorization, are sent with the requests to the mirror
+type DownloadMirrorCfg struct {
+	Prefix  string
+	Pattern string
+	Mirror  string
+	Headers map[string]string
+}
+
+// ArtifactScannerCfg represents an external command scanning package artifacts, such as clamscan. The path of the
+// artifact replaces {artifact} in the arguments, or is appended to them when none contains it. The artifact is
+// rejected when the command exits with a code other than 0 or runs longer than TimeoutSeconds
+type ArtifactScannerCfg struct {
+	Name           string
+	Command        string
+	Arguments      []string
+	TimeoutSeconds int
+}
+
+// PackageRetentionCfg represents which versions of the packages and cached downloads are removed from the local
+// package repository after each install and when the agent starts. The version in the install state of a package
+// and its last installed version are always kept, and downloads interrupted by a failure are always removed
+type PackageRetentionCfg struct {
+	// KeepVersions is the number of most recently downloaded versions of each package kept
+	KeepVersions int
+	// MaxTotalMB removes the oldest versions and cached downloads until the repository is below this size,
+	// 0 disables the limit
+	MaxTotalMB int
+	// MaxAgeDays removes the versions and cached downloads not downloaded again for this many days, 0 disables the purge
+	MaxAgeDays int
+}
+
+// PackageTraceCfg represents how the traces of a package install are compacted before they are written to the
+// plugin output and reported, sections with an error are always kept verbatim
+type PackageTraceCfg struct {
+	// CollapseRepeatedSections replaces consecutive identical sections by one section with a repeat count
+	CollapseRepeatedSections bool
+	// MaxSectionOutputBytes truncates the output of a section above this size, 0 keeps the whole output
+	MaxSectionOutputBytes int
+	// LocalTimeAnnotation adds the local time of the instance to the UTC timestamps of the output
+	LocalTimeAnnotation bool
+	// IncludeDurations adds the duration of each section to the output
+	IncludeDurations bool
+	// ExportLogGroup is the CloudWatch Logs group the sections of each package operation are written to
+	// as structured JSON, empty disables the export
+	ExportLogGroup string
+	// ExportMetrics also writes the download time, install time and failure count of each operation to the
+	// log group in the embedded metric format, so CloudWatch extracts them as metrics
+	ExportMetrics bool
+	// MetricNamespace is the CloudWatch namespace of the exported metrics
+	MetricNamespace string
+}
+
+// PackageInstallLogsCfg represents the log file of each package the output of its install, uninstall and validate
+// scripts is appended to, in addition to the trace. The log files are in a directory of each package under the
+// package logs directory and are read with the tail-package-log command of ssm-cli
+type PackageInstallLogsCfg struct {
+	Enabled bool
+	// MaxFileSizeKB rotates the log file of a package once appending the output of a script would exceed this size
+	MaxFileSizeKB int
+	// MaxFiles is the number of rotated log files kept for each package besides the current one, the oldest are
+	// removed first
+	MaxFiles int
+}
+
+// PackageIntegrityCfg represents the periodic verification of the files of installed packages against the
+// checksums recorded when they were installed
+type PackageIntegrityCfg struct {
+	Enabled          bool
+	FrequencyMinutes int
+	// Packages limits the verification to these package names or arns, empty verifies all installed packages
+	Packages []string
+}
+
+// SbomCfg represents the software bill of materials written for each package installed by ConfigurePackage
+type SbomCfg struct {
+	Enabled bool
+	// Format is "cyclonedx" or "spdx", both are written as JSON
+	Format string
+	// S3BucketName uploads the SBOM to this bucket in addition to the SBOM directory, empty only keeps it locally
+	S3BucketName string
+	S3KeyPrefix  string
+}
+
+// FacadeFailoverCfg represents the endpoints the package service calls fail over to when the ssm endpoint is unreachable
+type FacadeFailoverCfg struct {
+	// Endpoints are endpoint urls, such as VPC endpoints, tried in order after the ssm endpoint of the instance region
+	Endpoints []string
+	// Regions are regions whose ssm endpoint is tried in order after Endpoints
+	Regions []string
+	// FailureThreshold is the number of attempts in a row an endpoint is unreachable before failing over to the next one,
+	// the calls stick to the endpoint they failed over to as long as it is reachable
+	FailureThreshold int
+}
+
+// ManifestCacheCfg represents the storage and eviction of cached package manifests
+type ManifestCacheCfg struct {
+	// Backend is "file" to keep manifests across executions or "memory" to keep them for a single execution,
+	// an in memory cache reports every manifest as changed
+	Backend string
+	// TtlHours expires manifests cached longer ago than this, 0 keeps them until they are evicted
+	TtlHours int
+	// MaxEntries evicts the least recently used manifests above this count, 0 keeps all of them
+	MaxEntries int
+	// Directory is where the file backend keeps manifests, such as a RAM disk or a larger volume, empty keeps them
+	// in the default manifest cache directory. The manifests cached in the previous directory are moved on a change
+	Directory string
+}
+
+// TelemetryCfg represents the export of the traces and metrics of the agent to an OpenTelemetry collector
+type TelemetryCfg struct {
+	Enabled bool
+	// Endpoint is the base url of the OTLP/HTTP receiver of the collector, the traces and metrics are posted
+	// to its /v1/traces and /v1/metrics paths
+	Endpoint string
+	// ExportIntervalSeconds is how often the buffered spans and the metrics are exported
+	ExportIntervalSeconds int
+}
+
+// EventsCfg represents the publishing of the significant events of the agent to an Amazon EventBridge event bus
+type EventsCfg struct {
+	Enabled bool
+	// EventBusName is the name or the ARN of the event bus, empty publishes to the default event bus of the account
+	EventBusName string
+	// Source is the source of the published events, the sources starting with "aws." are reserved to AWS services
+	Source string
+	// DetailTypes restricts the published events to these detail types, empty publishes all of them
+	DetailTypes []string
+	// FlushIntervalSeconds is how often the buffered events are published
+	FlushIntervalSeconds int
+}
+
+// ActivityMetricsCfg represents the publishing of the management activity of the instance, the commands and the
+// sessions it runs, as custom metrics of Amazon CloudWatch
+type ActivityMetricsCfg struct {
+	Enabled bool
+	// Namespace is the CloudWatch namespace of the metrics, the namespaces starting with "AWS/" are reserved to AWS services
+	Namespace string
+	// FlushIntervalSeconds is how often the metrics aggregated since the last flush are published
+	FlushIntervalSeconds int
+}
+
+// ErrorReportingCfg represents the deduplication of the errors the agent reports in its telemetry and events.
+// The errors that only differ by their ids and numbers are reported MaxReportsPerError times by window, the
+// further occurrences are summarized with their count and their first and last occurrence.
+type ErrorReportingCfg struct {
+	MaxReportsPerError int
+	WindowSeconds      int
+}
+
+// WebhooksCfg represents the HTTPS callbacks the agent sends when documents and their steps complete
+type WebhooksCfg struct {
+	Enabled   bool
+	Endpoints []WebhookEndpointCfg
+	// TimeoutSeconds is the timeout of a callback request
+	TimeoutSeconds int
+	// MaxAttempts is how many times a callback is sent before it is dropped, failed requests are retried with a backoff
+	MaxAttempts int
+}
+
+// WebhookEndpointCfg represents an HTTPS endpoint called back with the events it subscribes to
+type WebhookEndpointCfg struct {
+	// UrlTemplate is the https url of the callback, its placeholders {Event}, {InstanceId}, {DocumentName},
+	// {CommandId}, {AssociationId}, {StepName} and {Status} are replaced by the escaped values of the event
+	UrlTemplate string
+	// Events restricts the callbacks to DocumentCompleted or StepCompleted events, empty calls back on both
+	Events []string
+	// SecretPath is the file of the key the callbacks are signed with using HMAC-SHA256, empty sends unsigned callbacks
+	SecretPath string
+}
+
+// LivenessCfg represents the liveness signal of the agent and the detection of its stuck internal loops. While the
+// loops are healthy the agent updates a heartbeat file and, on Windows, sets a named event. A stuck loop is restarted,
+// and once its restarts do not help the agent withholds the signal and exits with a failure, which the recovery
+// actions of the Windows service act on. Independently, the agent pings the systemd watchdog when its unit sets
+// WatchdogSec with NotifyAccess=main, until a loop is found stuck.
+type LivenessCfg struct {
+	Enabled bool
+	// HeartbeatFile is updated with the time of every signal
+	HeartbeatFile string
+	// IntervalSeconds is how often the signal is given and the loops are checked
+	IntervalSeconds int
+	// StuckLoopMinutes is how long a loop can go without completing an iteration beyond its period
+	StuckLoopMinutes int
+	// MaxLoopRestarts is how many times a stuck loop is restarted before the agent is unhealthy
+	MaxLoopRestarts int
+}
+
+// RoleMonitorCfg represents the detection of the instance profile or the role of an EC2 instance changing while the
+// agent runs. On a change the credentials of the agent are renewed at once and the long running clients are rebuilt.
+type RoleMonitorCfg struct {
+	Enabled bool
+	// IntervalSeconds is how often the role of the instance is read from the instance metadata
+	IntervalSeconds int
+}
+
+// RootlessCfg represents the agent running as a user other than root, for the security baselines forbidding root
+// daemons. The operations needing root, such as installing a package or controlling a service, are run through a
+// setuid helper and each elevation is audited.
+type RootlessCfg struct {
+	Enabled bool
+	// ElevationHelper is the setuid helper and its arguments the elevated commands are passed to, such as sudo -n
+	ElevationHelper string
+	// Operations are the operations which are elevated, the other commands run as the agent user
+	Operations []string
+	// AuditLogPath is the file each elevation is appended to
+	AuditLogPath string
+}
+
+// AuditExportCfg represents the export of the command and the session audit events in CEF or LEEF, the formats the
+// syslog connectors of the SIEMs ingest
+type AuditExportCfg struct {
+	Enabled bool
+	// Format is CEF or LEEF
+	Format string
+	// SyslogSocket is the local syslog socket the events are sent to, unless they are written to FilePath
+	SyslogSocket string
+	// FilePath is the file the events are appended to, one per line, instead of the syslog socket
+	FilePath string
+}
+
+// ExecPluginsCfg represents the out-of-tree plugins, binaries run by the agent through the exec plugin protocol
+type ExecPluginsCfg struct {
+	// Directory is searched for plugin binaries, on Linux and macOS it and the binaries must be owned by root or the
+	// user of the agent and must not be writable by other users. Empty disables the exec plugins.
+	Directory string
+	// HandshakeTimeoutSeconds is how long a binary has to describe the plugin it implements
+	HandshakeTimeoutSeconds int
+	// HeartbeatTimeoutSeconds is how long a running plugin can stay silent before it is stopped and its step fails
+	HeartbeatTimeoutSeconds int
+}
+
+// FanOutCfg represents the local targets the aws:fanOut plugin runs sub-documents against, such as the containers and
+// the virtual machines managed through the agent of an edge gateway. Documents can only name the targets configured here.
+type FanOutCfg struct {
+	Targets []FanOutTargetCfg
+	// MaxConcurrency is how many targets a step runs the sub-document against at the same time
+	MaxConcurrency int
+}
+
+// FanOutTargetCfg represents a local target of the aws:fanOut plugin
+type FanOutTargetCfg struct {
+	// Name identifies the target in the documents and in the results
+	Name string
+	// Type is "ssh", "winrm" or "container"
+	Type string
+	// Address is the host of the ssh and winrm targets, and the name or the id of the container targets
+	Address string
+	// Port is the ssh or winrm port, the default port of the protocol when it is 0
+	Port int
+	// User is the remote user of the ssh targets and the user the commands run as in the container targets
+	User string
+	// IdentityFile is the private key of the ssh targets, whose host keys must be known to the agent
+	IdentityFile string
+	// ContainerRuntime is the command line of the container targets, docker when it is empty
+	ContainerRuntime string
+}
+
+// StatusEndpointCfg represents the local status endpoint of the agent, an HTTP server reporting the health of the
+// agent, its last contact with MDS and MGS, the documents running and the installed packages. It listens on a unix
+// socket only accessible to root on Linux and macOS, and on a named pipe on Windows.
+type StatusEndpointCfg struct {
+	Enabled bool
+	// Path is the unix socket or the named pipe the endpoint listens on
+	Path string
+	// StaleContactSeconds is how long the agent can go without reaching MDS or MGS before it is reported disconnected
+	StaleContactSeconds int
+}
+
+// UploadsCfg represents the queue the agent uploads through. Command results go first, then session logs, inventory
+// and telemetry, and the queued uploads other than telemetry are saved so that they resume after a restart.
+type UploadsCfg struct {
+	// MaxConcurrentUploads is the number of uploads running at the same time
+	MaxConcurrentUploads int
+	// BandwidthKBps caps the combined throughput of the uploads to s3 and the telemetry collector, 0 disables the cap
+	BandwidthKBps int
+	// MaxAttempts is how many times an upload is tried before it is dropped, failed uploads are retried with a backoff
+	MaxAttempts int
+	// MaxQueuedUploads is the number of uploads waiting in the queue, further uploads replace queued uploads of a
+	// lower priority or are rejected
+	MaxQueuedUploads int
+	Spool            SpoolCfg
+	Presigned        PresignedUploadsCfg
+}
+
+// PresignedUploadsCfg represents the uploads of the command output and the output artifacts through presigned urls,
+// so that the role of the instance does not need to write to the output buckets. The urls are requested from the
+// signer endpoint, which authenticates the instance by its signed identity document.
+type PresignedUploadsCfg struct {
+	Enabled bool
+	// SignerEndpoint is the https url of the signer, it answers a POST of the bucket and the key of an upload with
+	// the url to PUT the object to
+	SignerEndpoint string
+}
+
+// SpoolCfg represents the spool of a disconnected instance. The saved uploads and the telemetry are kept in the
+// data folder of the instance instead of being sent, until an operator exports them to a bundle with the ssm-cli
+// and imports the bundle on a connected network.
+type SpoolCfg struct {
+	Enabled bool
+	// SigningKeyPath is the file of the secret the bundles are signed with, the import verifies them with the same secret
+	SigningKeyPath string
+	// MaxSizeMB caps the size of the spooled uploads, further uploads are rejected until a bundle is exported
+	MaxSizeMB int
+}
+
+// LogCfg represents the encoding of the agent logs and the log levels of the agent subsystems
+type LogCfg struct {
+	// Format is "text" for the line oriented logs or "json" for one json object per log entry
+	Format string
+	// SubsystemLevels maps a source directory of the agent, for example "plugins/configurepackage", to the minimum
+	// log level of the code below it
+	SubsystemLevels map[string]string
+}
+
+// ArtifactCacheCfg represents the cache of downloaded artifacts shared by the package installs, aws:downloadContent
+// and the agent updates. Artifacts are cached by checksum, so only the downloads declaring a sha256, sha384 or
+// sha512 checksum use it, and a cached artifact is verified again before it is reused.
+type ArtifactCacheCfg struct {
+	Enabled bool
+	// MaxSizeMB is the size of the cache, the least recently used artifacts are removed above it
+	MaxSizeMB int
+}
+
+// TlsCfg represents the transport security policy of all outbound connections of the agent
+type TlsCfg struct {
+	// MinVersion is the minimum TLS version, for example "1.2", empty keeps the default of the Go runtime
+	MinVersion string
+	// CipherSuites restricts the TLS 1.0-1.2 cipher suites by their IANA names, empty allows the defaults
+	CipherSuites []string
+	// FipsMode restricts connections to FIPS approved protocols, cipher suites, curves and checksums
+	FipsMode bool
+	// Service is the trust of connections to the SSM and other AWS APIs
+	Service TlsTrustCfg
+	// Artifacts is the trust of connections to the servers documents download artifacts from
+	Artifacts TlsTrustCfg
+	// Revocation is the revocation checking of server certificates
+	Revocation TlsRevocationCfg
+}
+
+// TlsRevocationCfg represents how the revocation of server certificates is checked
+type TlsRevocationCfg struct {
+	// Mode is "Off", "SoftFail" to allow certificates whose revocation status cannot be determined,
+	// or "HardFail" to reject them
+	Mode string
+	// RequireOcspStapling rejects servers that do not staple an OCSP response to the handshake when
+	// revocation is checked
+	RequireOcspStapling bool
+	// CrlCacheMinutes is the longest time a fetched CRL is used, a CRL is never used after its next update
+	CrlCacheMinutes int
+}
+
+// SelfIntegrityCfg represents the verification of the agent binaries at startup against the signed manifest
+// installed with them
+type SelfIntegrityCfg struct {
+	// Mode is "Off", "Alert" to report the binaries that do not match the manifest, or "Enforce" to also refuse to start
+	Mode string
+	// ManifestPath is the manifest listing the sha256 of the binaries relative to its folder, it defaults to the
+	// manifest next to the agent executable. The manifest is signed by the detached signature at ManifestPath.sig
+	ManifestPath string
+	// PublicKeyPath is the PEM encoded public key the manifest signature is verified with
+	PublicKeyPath string
+}
+
+// TlsTrustCfg represents how the servers of an endpoint class are trusted
+type TlsTrustCfg struct {
+	// CABundlePath is a PEM file of certificate authorities trusted in addition to the system roots,
+	// the file is reloaded when it changes
+	CABundlePath string
+	// PinnedPublicKeys are base64 encoded sha256 digests of subject public keys, when set one
+	// certificate of the verified chain must have one of these keys
+	PinnedPublicKeys []string
+}
+
+// ArtifactProxyCfg represents the proxy of the artifact downloads, such as packages, downloaded content and
+// agent updates
+type ArtifactProxyCfg struct {
+	// Url is the proxy of the http and https downloads, the HTTPS_PROXY and HTTP_PROXY environment variables
+	// are used when it is empty
+	Url string
+	// NoProxy are downloaded from directly in addition to the NO_PROXY environment variable: "*", domain names
+	// which also match their subdomains, host:port pairs, IP addresses, CIDR ranges or urls
+	NoProxy []string
+	// InjectEnvironment sets the proxy the agent uses, and its no proxy entries, in the environment of the
+	// plugins and scripts it runs, in lower and upper case
+	InjectEnvironment bool
+}
+
+// ManifestSigningCfg represents the verification of detached package manifest signatures
+type ManifestSigningCfg struct {
+	// Enabled rejects manifests that are unsigned or whose signature does not verify
+	Enabled bool
+	// PublicKeyPath is the PEM encoded public key manifests are signed with,
+	// manifests signed with an asymmetric KMS key are verified with the public key exported from KMS
+	PublicKeyPath string
+}
+
+// ArtifactSigningCfg represents the verification of the detached signatures of package artifacts.
+// Artifacts whose manifest entry references a signature are verified when a key of its format is configured.
+type ArtifactSigningCfg struct {
+	// RequireSigned rejects the artifacts that are unsigned or whose signature cannot be verified
+	RequireSigned bool
+	// GpgKeyringPath is the armored or binary OpenPGP public keyring the gpg signatures are verified with
+	GpgKeyringPath string
+	// SigstorePublicKeyPath is the PEM encoded public key the Sigstore bundles are verified with
+	SigstorePublicKeyPath string
+}
+
+// ThrottleCfg represents the self throttling applied by the agent during peak hours
+type ThrottleCfg struct {
+	// PeakHours is the daily local time window in the "HH:MM-HH:MM" format, empty disables throttling
+	PeakHours string
+	// PeakDays restricts the window to some days of the week, for example "Mon-Fri" or "Sat,Sun", empty means every day
+	PeakDays                string
+	PollDelaySeconds        int
+	MaxConcurrentExecutions int
+	DownloadBandwidthKBps   int
+	// ApiThrottlingThreshold is the number of throttled api calls within five minutes that stretches the
+	// inventory and association refresh schedules, 0 disables the stretching
+	ApiThrottlingThreshold int
+	// MaxBackoffFactor is the most the schedules are stretched by while the api calls are throttled
+	MaxBackoffFactor int
+}
+
+// ReadinessCfg represents the gates a host must pass before a document runs its first step, no gate is configured by default
+type ReadinessCfg struct {
+	// MinFreeDiskMB is the free space required on the volume of the agent data, 0 disables the gate
+	MinFreeDiskMB int
+	// MaxLoadPerCpu is the highest one minute load average divided by the number of cpus, on Windows the
+	// highest average cpu utilization between 0 and 1. 0 disables the gate
+	MaxLoadPerCpu float64
+	// NoPendingReboot requires that the operating system is not waiting for a reboot to complete an update
+	NoPendingReboot bool
+	// ProbeCommand is run by the shell, PowerShell on Windows, the host is ready when it exits with 0
+	ProbeCommand        string
+	ProbeTimeoutSeconds int
+	// OnFailure is either Defer to evaluate the gates again until DeferTimeoutSeconds expires, or Fail to fail
+	// the document right away
+	OnFailure           string
+	DeferTimeoutSeconds int
+}
+
+// BootDocumentCfg designates a local command document the agent runs at boot, before it polls for work
+type BootDocumentCfg struct {
+	Name string
+	// Path is the path of the command document on the instance
+	Path string
+	// RunOn is either FirstBoot to run the document once per instance or EveryBoot
+	RunOn string
+	// OnFailure is either Continue to run the next boot documents or Stop to skip them until the next boot
+	OnFailure string
+}
+
+// BootCfg represents the documents the agent runs at boot, in order
+type BootCfg struct {
+	Documents              []BootDocumentCfg
+	DocumentTimeoutSeconds int
+}
+
+// NamespaceCfg represents the policy enforced on the documents of one team sharing the host
+type NamespaceCfg struct {
+	Name string
+	// DocumentNames are the name patterns of the documents in the namespace, for example "TeamA-*"
+	DocumentNames []string
+	// AllowedPlugins restricts the plugins the documents can run, empty allows every plugin
+	AllowedPlugins []string
+	// OutputS3BucketName replaces the output bucket requested by the documents, empty keeps the requested bucket
+	OutputS3BucketName string
+	// MaxExecutionSeconds cancels the documents running longer, 0 disables the limit
+	MaxExecutionSeconds int
+}
+
+// IotCfg represents the AWS IoT identity of an edge device, the agent registers the device as a managed instance
+// with the credentials of the IoT role alias instead of an activation
+type IotCfg struct {
+	// CredentialsEndpoint is the AWS IoT credentials provider endpoint of the account
+	CredentialsEndpoint string
+	RoleAlias           string
+	ThingName           string
+	Region              string
+	CertificatePath     string
+	PrivateKeyPath      string
+	RootCAPath          string
+	// ServiceRole is the IAM role assumed by the managed instance once registered
+	ServiceRole string
+}
+
+// PreconditionChecksCfg represents the site-specific precondition checks, executables run by the agent to evaluate
+// the "check:<name>" precondition variables of the documents, a check exiting with 0 is true
+type PreconditionChecksCfg struct {
+	// Directory is searched for check executables named after their check, on Linux and macOS it and the executables
+	// must be owned by root or the user of the agent and must not be writable by other users. Empty disables the checks.
+	Directory string
+	// TimeoutSeconds is how long a check can run before it is stopped and evaluates to false
+	TimeoutSeconds int
+	// CacheSeconds is how long the result of a check is reused by the following steps and documents, 0 runs the
+	// check for every step
+	CacheSeconds int
 }
 
 // SsmagentConfig stores agent configuration values.
 type SsmagentConfig struct {
-	Profile     CredentialProfile
-	Mds         MdsCfg
-	Ssm         SsmCfg
-	Mgs         MgsConfig
-	Agent       AgentInfo
-	Os          OsInfo
-	S3          S3Cfg
-	Birdwatcher BirdwatcherCfg
+	Profile            CredentialProfile
+	Mds                MdsCfg
+	Ssm                SsmCfg
+	Mgs                MgsConfig
+	Agent              AgentInfo
+	Os                 OsInfo
+	S3                 S3Cfg
+	Birdwatcher        BirdwatcherCfg
+	Throttle           ThrottleCfg
+	Boot               BootCfg
+	Readiness          ReadinessCfg
+	Namespaces         []NamespaceCfg
+	Iot                IotCfg
+	Tls                TlsCfg
+	SelfIntegrity      SelfIntegrityCfg
+	Liveness           LivenessCfg
+	RoleMonitor        RoleMonitorCfg
+	Rootless           RootlessCfg
+	ArtifactProxy      ArtifactProxyCfg
+	ArtifactCache      ArtifactCacheCfg
+	Telemetry          TelemetryCfg
+	Events             EventsCfg
+	ActivityMetrics    ActivityMetricsCfg
+	ErrorReporting     ErrorReportingCfg
+	Webhooks           WebhooksCfg
+	AuditExport        AuditExportCfg
+	ExecPlugins        ExecPluginsCfg
+	PreconditionChecks PreconditionChecksCfg
+	FanOut             FanOutCfg
+	Status             StatusEndpointCfg
+	Uploads            UploadsCfg
+	Log                LogCfg
+}
+
+// Enabled returns true if the agent registers with the AWS IoT identity of the device
+func (iot IotCfg) Enabled() bool {
+	return iot.CredentialsEndpoint != "" && iot.RoleAlias != ""
 }
 
 // AppConstants represents some run time constant variable for various module.
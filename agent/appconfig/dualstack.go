@@ -0,0 +1,76 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appconfig
+
+import (
+	"net"
+	"strings"
+	"sync"
+)
+
+// ipv4RouteProbe is a documentation address, dialing it over udp looks up a route without sending any packet
+const ipv4RouteProbe = "192.0.2.1:53"
+
+var (
+	// hasIPv4Route is replaced in unit tests
+	hasIPv4Route  = probeIPv4Route
+	ipv4RouteOnce sync.Once
+	ipv4Routed    bool
+)
+
+// UseDualStackEndpoints returns whether the agent reaches the AWS services through their dualstack endpoints
+func UseDualStackEndpoints() bool {
+	config, err := Config(false)
+	if err != nil {
+		return false
+	}
+	return useDualStack(config.Agent.DualStack)
+}
+
+// useDualStack returns whether the dualstack endpoints are used in the given mode, the route of the host is
+// looked up once as the agent does not follow the changes of the network
+func useDualStack(mode string) bool {
+	switch mode {
+	case DualStackEnabled:
+		return true
+	case DualStackDisabled:
+		return false
+	}
+	ipv4RouteOnce.Do(func() { ipv4Routed = hasIPv4Route() })
+	return !ipv4Routed
+}
+
+func probeIPv4Route() bool {
+	conn, err := net.Dial("udp4", ipv4RouteProbe)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// GetDualStackEndPoint returns the dualstack endpoint of a service in a region
+func GetDualStackEndPoint(region string, service string) string {
+	china := strings.HasPrefix(region, "cn-")
+	if service == "s3" {
+		if china {
+			return "s3.dualstack." + region + ".amazonaws.com.cn"
+		}
+		return "s3.dualstack." + region + ".amazonaws.com"
+	}
+	if china {
+		return service + "." + region + ".api.amazonwebservices.com.cn"
+	}
+	return service + "." + region + ".api.aws"
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appconfig
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDualStackEndPoint(t *testing.T) {
+	assert.Equal(t, "ssm.us-east-1.api.aws", GetDualStackEndPoint("us-east-1", "ssm"))
+	assert.Equal(t, "ec2messages.cn-north-1.api.amazonwebservices.com.cn", GetDualStackEndPoint("cn-north-1", "ec2messages"))
+	assert.Equal(t, "s3.dualstack.eu-west-1.amazonaws.com", GetDualStackEndPoint("eu-west-1", "s3"))
+	assert.Equal(t, "s3.dualstack.cn-northwest-1.amazonaws.com.cn", GetDualStackEndPoint("cn-northwest-1", "s3"))
+}
+
+// useIPv4Route replaces the route lookup of the host and returns the function restoring it
+func useIPv4Route(routed bool) func() {
+	savedProbe := hasIPv4Route
+	ipv4RouteOnce = sync.Once{}
+	hasIPv4Route = func() bool { return routed }
+	return func() {
+		hasIPv4Route = savedProbe
+		ipv4RouteOnce = sync.Once{}
+	}
+}
+
+func TestUseDualStack(t *testing.T) {
+	for _, routed := range []bool{true, false} {
+		restore := useIPv4Route(routed)
+
+		assert.True(t, useDualStack(DualStackEnabled))
+		assert.False(t, useDualStack(DualStackDisabled))
+		assert.Equal(t, !routed, useDualStack(DualStackAuto), "auto uses the dualstack endpoints without an ipv4 route")
+		restore()
+	}
+}
+
+func TestGetDefaultEndPointWithoutIPv4Route(t *testing.T) {
+	defer useIPv4Route(false)()
+
+	assert.Equal(t, "ssm.us-east-1.api.aws", GetDefaultEndPoint("us-east-1", "ssm"))
+	assert.Equal(t, "s3.dualstack.us-east-1.amazonaws.com", GetDefaultEndPoint("us-east-1", "s3"))
+}
+
+func TestParseDualStack(t *testing.T) {
+	for value, expected := range map[string]string{
+		"enabled":  DualStackEnabled,
+		"Disabled": DualStackDisabled,
+		"":         DualStackAuto,
+		"invalid":  DualStackAuto,
+	} {
+		config := DefaultConfig()
+		config.Agent.DualStack = value
+		parser(&config)
+		assert.Equal(t, expected, config.Agent.DualStack)
+	}
+}
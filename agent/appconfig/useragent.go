@@ -0,0 +1,32 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appconfig
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// MakeUserAgentHandler returns the build handler adding the name and version of the agent to the user agent of the
+// AWS requests, followed by the user agent suffix of the configuration when there is one
+func MakeUserAgentHandler(agent AgentInfo) func(*request.Request) {
+	addAgent := request.MakeAddToUserAgentHandler(agent.Name, agent.Version)
+	if agent.UserAgentSuffix == "" {
+		return addAgent
+	}
+	addSuffix := request.MakeAddToUserAgentFreeFormHandler(agent.UserAgentSuffix)
+	return func(r *request.Request) {
+		addAgent(r)
+		addSuffix(r)
+	}
+}
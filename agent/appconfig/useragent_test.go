@@ -0,0 +1,32 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package appconfig
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeUserAgentHandler(t *testing.T) {
+	r := &request.Request{HTTPRequest: &http.Request{Header: http.Header{}}}
+	MakeUserAgentHandler(AgentInfo{Name: "amazon-ssm-agent", Version: "3.0.0"})(r)
+	assert.Equal(t, "amazon-ssm-agent/3.0.0", r.HTTPRequest.Header.Get("User-Agent"))
+
+	r = &request.Request{HTTPRequest: &http.Request{Header: http.Header{}}}
+	MakeUserAgentHandler(AgentInfo{Name: "amazon-ssm-agent", Version: "3.0.0", UserAgentSuffix: "fleet/web"})(r)
+	assert.Equal(t, "amazon-ssm-agent/3.0.0 fleet/web", r.HTTPRequest.Header.Get("User-Agent"))
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -35,6 +36,10 @@ import (
 // ErrorMsg represents the error message to be sent to the customer
 const ErrorMsg = "Encountered error while parsing input - internal error"
 
+// CheckModeParameter is the association parameter which runs the document in check mode when it is set to true,
+// the steps then report the changes they would make without making them
+const CheckModeParameter = "CheckMode"
+
 // ParseDocumentForPayload parses an document and replaces the parameters where needed.
 func ParseDocumentForPayload(log log.T,
 	rawData *model.InstanceAssociation) (*messageContracts.SendCommandPayload, error) {
@@ -97,13 +102,30 @@ func InitializeDocumentState(context context.T,
 	}
 
 	docContent := &docparser.DocContent{
-		SchemaVersion: payload.DocumentContent.SchemaVersion,
-		Description:   payload.DocumentContent.Description,
-		RuntimeConfig: payload.DocumentContent.RuntimeConfig,
-		MainSteps:     payload.DocumentContent.MainSteps,
-		Parameters:    payload.DocumentContent.Parameters,
+		SchemaVersion:       payload.DocumentContent.SchemaVersion,
+		Description:         payload.DocumentContent.Description,
+		RuntimeConfig:       payload.DocumentContent.RuntimeConfig,
+		MainSteps:           payload.DocumentContent.MainSteps,
+		Parameters:          payload.DocumentContent.Parameters,
+		MinimumAgentVersion: payload.DocumentContent.MinimumAgentVersion,
+	}
+	docState, err := docparser.InitializeDocState(context.Log(), contracts.Association, docContent, documentInfo, parserInfo, payload.Parameters)
+	if err != nil {
+		return docState, err
 	}
-	return docparser.InitializeDocState(context.Log(), contracts.Association, docContent, documentInfo, parserInfo, payload.Parameters)
+	if isCheckMode(rawData) {
+		context.Log().Infof("Association %v runs in check mode", documentInfo.AssociationID)
+		for i := range docState.InstancePluginsInformation {
+			docState.InstancePluginsInformation[i].Configuration.CheckMode = true
+		}
+	}
+	return docState, nil
+}
+
+// isCheckMode returns true when the association sets its check mode parameter
+func isCheckMode(rawData *model.InstanceAssociation) bool {
+	values := rawData.Association.Parameters[CheckModeParameter]
+	return len(values) > 0 && values[0] != nil && strings.EqualFold(strings.TrimSpace(*values[0]), "true")
 }
 
 // newDocumentInfo initializes new DocumentInfo object
@@ -34,8 +34,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/liveness"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/carlescere/scheduler"
 )
@@ -47,6 +49,7 @@ const (
 	documentLevelTimeOutDurationHour        = 2
 	outputMessageTemplate            string = "%v out of %v plugin%v processed, %v success, %v failed, %v timedout, %v skipped. %v"
 	defaultRetryWaitOnBootInSeconds         = 30
+	associationSchedulerName                = "AssociationScheduler"
 )
 
 // Processor contains the logic for processing association
@@ -59,6 +62,8 @@ type Processor struct {
 	proc               processor.Processor
 	resChan            chan contracts.DocumentResult
 	onBoot             bool
+	// pollStretcher skips association refreshes while the api calls of the agent are throttled
+	pollStretcher throttle.Stretcher
 }
 
 var lock sync.RWMutex
@@ -109,8 +114,27 @@ func (p *Processor) ModuleExecute(context context.T) {
 	}
 	p.InitializeAssociationProcessor()
 	p.SetPollJob(job)
+	liveness.Register(associationSchedulerName, time.Duration(associationFrequenceMinutes)*time.Minute, p.restartAssociationPolling)
 }
+
+// restartAssociationPolling replaces a stuck association poll job with a new one
+func (p *Processor) restartAssociationPolling() {
+	log := p.context.Log()
+	log.Warn("Restarting the association polling")
+	assocScheduler.Stop(p.pollJob)
+	job, err := assocScheduler.CreateScheduler(
+		log,
+		p.ProcessAssociation,
+		p.context.AppConfig().Ssm.AssociationFrequencyMinutes)
+	if err != nil {
+		log.Errorf("unable to schedule association processor. %v", err)
+		return
+	}
+	p.SetPollJob(job)
+}
+
 func (p *Processor) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	liveness.Unregister(associationSchedulerName)
 	assocScheduler.Stop(p.pollJob)
 	signal.Stop()
 	p.proc.Stop(stopType)
@@ -151,6 +175,13 @@ func (p *Processor) ProcessAssociation() {
 	associations := []*model.InstanceAssociation{}
 
 	log.Debug("running ProcessAssociation")
+	defer liveness.Beat(associationSchedulerName)
+
+	if !p.pollStretcher.Due(p.context.AppConfig().Throttle) {
+		log.Infof("Skipping association refresh while api calls are throttled - %v",
+			throttle.FormatAPIThrottlingStats(throttle.APIThrottlingStats()))
+		return
+	}
 
 	instanceID, err := sys.InstanceID()
 	if err != nil {
@@ -480,6 +511,37 @@ func (r *Processor) pluginExecutionReport(
 		outputUrl)
 }
 
+// queuedExecutionReport updates the association progress while it waits in its concurrency group
+func (r *Processor) queuedExecutionReport(
+	log log.T,
+	associationID string,
+	concurrencyGroup string,
+	queuePosition int) {
+
+	// Legacy association api does not support plugin level status update
+	// it returns error for multiple update with same status
+	if !r.assocSvc.IsInstanceAssociationApiMode() {
+		return
+	}
+
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		log.Error("failed to load instance id ", err)
+		return
+	}
+
+	r.assocSvc.UpdateInstanceAssociationStatus(
+		log,
+		associationID,
+		"",
+		instanceID,
+		contracts.AssociationStatusInProgress,
+		contracts.AssociationErrorCodeNoError,
+		times.ToIso8601UTC(time.Now()),
+		fmt.Sprintf("Waiting for %v documents ahead in concurrency group %v", queuePosition, concurrencyGroup),
+		"")
+}
+
 // associationExecutionReport update the status for association
 func (r *Processor) associationExecutionReport(
 	log log.T,
@@ -519,11 +581,38 @@ func (r *Processor) associationExecutionReport(
 		documentVersion,
 		associationStatus,
 		time.Now().UTC())
+
+	if isCheckRun(outputs) {
+		if err = r.complianceUploader.UpdateCheckCompliance(
+			associationID,
+			instanceID,
+			documentName,
+			documentVersion,
+			outputs,
+			time.Now().UTC()); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// isCheckRun returns true when the steps of the association ran in check mode
+func isCheckRun(outputs map[string]*contracts.PluginResult) bool {
+	for _, output := range outputs {
+		if output != nil && output.CheckReport != nil {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *Processor) listenToResponses() {
 	log := r.context.Log()
 	for res := range r.resChan {
+		if res.QueuePosition > 0 {
+			log.Infof("association %v is queued in concurrency group %v", res.AssociationID, res.ConcurrencyGroup)
+			r.queuedExecutionReport(log, res.AssociationID, res.ConcurrencyGroup, res.QueuePosition)
+			continue
+		}
 		if res.LastPlugin != "" {
 			log.Infof("update association status upon plugin $v completion", res.LastPlugin)
 			r.pluginExecutionReport(log, res.AssociationID, res.LastPlugin, res.PluginResults, res.NPlugins)
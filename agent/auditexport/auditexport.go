@@ -0,0 +1,206 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package auditexport writes the command and the session audit events of the agent in CEF or LEEF to the local syslog
+// socket or to a file, so that SIEMs such as ArcSight or QRadar ingest them with their syslog connectors. The export is
+// disabled unless it is enabled in the agent configuration, then every function of the package is a no-op.
+package auditexport
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Names of the audit events
+const (
+	// StepCompleted is recorded when a step of a command or an association completes
+	StepCompleted = "StepCompleted"
+	// CommandCompleted is recorded when a command or an association completes
+	CommandCompleted = "CommandCompleted"
+	// SessionStarted is recorded when a Session Manager session starts on the instance
+	SessionStarted = "SessionStarted"
+	// SessionEnded is recorded when a Session Manager session ends
+	SessionEnded = "SessionEnded"
+)
+
+// maxQueuedEvents is the number of events waiting to be written, further events are dropped
+const maxQueuedEvents = 1000
+
+// Event is an audit event, the fields that do not apply to the event are empty
+type Event struct {
+	Name            string
+	Time            time.Time
+	InstanceID      string
+	DocumentName    string
+	DocumentVersion string
+	CommandID       string
+	AssociationID   string
+	SessionID       string
+	// ClientID identifies the client of a session
+	ClientID string
+	StepName string
+	// Action is the plugin of a step
+	Action string
+	Status string
+	// ExitCode is the exit code of a step
+	ExitCode int
+}
+
+var (
+	lock     sync.Mutex
+	exporter *eventExporter
+)
+
+// Start begins exporting the audit events when it is enabled by the configuration
+func Start(log log.T, config appconfig.AuditExportCfg, instanceID string) {
+	if !config.Enabled {
+		return
+	}
+	if config.FilePath == "" && config.SyslogSocket == "" {
+		log.Warnf("Audit events are not exported, there is no local syslog socket and no file is configured")
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if exporter != nil {
+		return
+	}
+	exporter = newEventExporter(log, config, instanceID)
+	log.Infof("Exporting audit events in %v to %v", config.Format, exporter.out.String())
+	go exporter.run()
+}
+
+// Stop writes the queued events and stops the export
+func Stop() {
+	lock.Lock()
+	current := exporter
+	exporter = nil
+	lock.Unlock()
+	if current != nil {
+		current.stop()
+	}
+}
+
+// Enabled returns whether audit events are exported
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return exporter != nil
+}
+
+// Record queues an event, which is dropped when the queue is full. The time and the instance id are filled in.
+func Record(event Event) {
+	lock.Lock()
+	defer lock.Unlock()
+	if exporter == nil {
+		return
+	}
+	exporter.enqueue(event)
+}
+
+// eventExporter writes the queued events one at a time
+type eventExporter struct {
+	log        log.T
+	format     func(Event, string) string
+	out        output
+	instanceID string
+	hostname   string
+	queue      chan Event
+	dropped    int64
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+	done       chan struct{}
+}
+
+func newEventExporter(log log.T, config appconfig.AuditExportCfg, instanceID string) *eventExporter {
+	e := &eventExporter{
+		log:        log,
+		format:     formatCef,
+		instanceID: instanceID,
+		queue:      make(chan Event, maxQueuedEvents),
+		stopChan:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if config.Format == appconfig.AuditExportFormatLeef {
+		e.format = formatLeef
+	}
+	e.hostname, _ = os.Hostname()
+	if config.FilePath != "" {
+		e.out = &fileOutput{path: config.FilePath}
+	} else {
+		e.out = &syslogOutput{socket: config.SyslogSocket, hostname: e.hostname}
+	}
+	return e
+}
+
+// enqueue queues an event without blocking the caller, it is called under the lock of the package
+func (e *eventExporter) enqueue(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.InstanceID = e.instanceID
+	select {
+	case e.queue <- event:
+	default:
+		e.dropped++
+	}
+}
+
+// takeDropped returns the number of events dropped since the last call
+func (e *eventExporter) takeDropped() int64 {
+	lock.Lock()
+	defer lock.Unlock()
+	dropped := e.dropped
+	e.dropped = 0
+	return dropped
+}
+
+// run writes the queued events until the exporter is stopped, then writes the remaining events
+func (e *eventExporter) run() {
+	defer close(e.done)
+	defer e.out.Close()
+	for {
+		select {
+		case event := <-e.queue:
+			e.write(event)
+		case <-e.stopChan:
+			for {
+				select {
+				case event := <-e.queue:
+					e.write(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *eventExporter) stop() {
+	e.stopOnce.Do(func() { close(e.stopChan) })
+	<-e.done
+}
+
+// write writes an event, the events that can not be written are dropped
+func (e *eventExporter) write(event Event) {
+	if dropped := e.takeDropped(); dropped > 0 {
+		e.log.Warnf("Dropped %v audit events, more than %v events were queued", dropped, maxQueuedEvents)
+	}
+	if err := e.out.Write(severity(event), event.Time, e.format(event, e.hostname)); err != nil {
+		e.log.Warnf("Failed to export the %v audit event to %v: %v", event.Name, e.out.String(), err)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package auditexport
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func stepEvent() Event {
+	return Event{
+		Name:         StepCompleted,
+		Time:         testTime,
+		InstanceID:   "i-0123456789abcdef0",
+		DocumentName: "AWS-RunShellScript",
+		CommandID:    "c1",
+		StepName:     "a=b|c",
+		Action:       "aws:runShellScript",
+		Status:       "Failed",
+		ExitCode:     2,
+	}
+}
+
+func TestFormatCef(t *testing.T) {
+	line := formatCef(stepEvent(), "host1")
+
+	assert.Equal(t, "CEF:0|Amazon|SSM Agent|"+version.Version+"|StepCompleted|StepCompleted|7|rt=1714564800000 dvchost=host1 "+
+		"deviceExternalId=i-0123456789abcdef0 externalId=c1 act=aws:runShellScript outcome=Failed "+
+		"cs1Label=documentName cs1=AWS-RunShellScript cs4Label=stepName cs4=a\\=b|c cn1Label=exitCode cn1=2", line)
+}
+
+func TestFormatLeef(t *testing.T) {
+	event := Event{Name: SessionStarted, Time: testTime, InstanceID: "i-1", SessionID: "s1", ClientID: "c\t1\n"}
+
+	line := formatLeef(event, "host1")
+
+	assert.Equal(t, "LEEF:1.0|Amazon|SSM Agent|"+version.Version+"|SessionStarted|devTime=1714564800000\tcat=SessionStarted\tsev=5\t"+
+		"identHostName=host1\tresource=i-1\tsessionId=s1\tclientId=c 1 ", line)
+}
+
+func TestExportToFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "auditexport")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit", "ssm.log")
+
+	Start(log.NewMockLog(), appconfig.AuditExportCfg{Enabled: true, Format: appconfig.AuditExportFormatLeef, FilePath: path}, "i-1")
+	assert.True(t, Enabled())
+	Record(Event{Name: CommandCompleted, CommandID: "c1", Status: "Success"})
+	Record(Event{Name: CommandCompleted, CommandID: "c2", Status: "Failed"})
+	Stop()
+	assert.False(t, Enabled())
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "commandId=c1")
+	assert.Contains(t, lines[1], "resource=i-1")
+	info, _ := os.Stat(path)
+	if info != nil && os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(appconfig.ReadWriteAccess), info.Mode().Perm())
+	}
+}
+
+func TestExportToSyslog(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "auditexport")
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "log")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets are not supported: %v", err)
+	}
+	defer conn.Close()
+
+	Start(log.NewMockLog(), appconfig.AuditExportCfg{Enabled: true, Format: appconfig.AuditExportFormatCef, SyslogSocket: socket}, "i-1")
+	Record(stepEvent())
+	Stop()
+
+	buffer := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buffer)
+	assert.NoError(t, err)
+	message := string(buffer[:n])
+	// authpriv warning
+	assert.True(t, strings.HasPrefix(message, "<84>"), message)
+	assert.Contains(t, message, " amazon-ssm-agent[")
+	assert.Contains(t, message, "]: CEF:0|Amazon|SSM Agent|")
+	assert.Contains(t, message, "deviceExternalId=i-1")
+}
+
+func TestStartWithoutOutput(t *testing.T) {
+	Start(log.NewMockLog(), appconfig.AuditExportCfg{Enabled: true, Format: appconfig.AuditExportFormatCef}, "i-1")
+	assert.False(t, Enabled())
+	Record(stepEvent())
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package auditexport
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	vendor  = "Amazon"
+	product = "SSM Agent"
+)
+
+// field is an extension field of an event, the fields with an empty value are left out
+type field struct {
+	key   string
+	value string
+}
+
+// severity returns the severity of an event from 0 to 10, the failed commands and steps are the most severe
+func severity(event Event) int {
+	switch contracts.ResultStatus(event.Status) {
+	case contracts.ResultStatusFailed, contracts.ResultStatusTimedOut:
+		return 7
+	case contracts.ResultStatusCancelled:
+		return 5
+	}
+	if event.Name == SessionStarted {
+		return 5
+	}
+	return 3
+}
+
+// exitCode returns the exit code of a step, the other events have none
+func exitCode(event Event) string {
+	if event.Name != StepCompleted {
+		return ""
+	}
+	return strconv.Itoa(event.ExitCode)
+}
+
+// externalID returns the id of the command or the session of an event
+func externalID(event Event) string {
+	if event.SessionID != "" {
+		return event.SessionID
+	}
+	return event.CommandID
+}
+
+// formatCef formats an event in ArcSight Common Event Format
+func formatCef(event Event, hostname string) string {
+	fields := []field{
+		{"rt", strconv.FormatInt(event.Time.UnixNano()/1e6, 10)},
+		{"dvchost", hostname},
+		{"deviceExternalId", event.InstanceID},
+		{"externalId", externalID(event)},
+		{"act", event.Action},
+		{"outcome", event.Status},
+		{"cs1Label", "documentName"}, {"cs1", event.DocumentName},
+		{"cs2Label", "documentVersion"}, {"cs2", event.DocumentVersion},
+		{"cs3Label", "associationId"}, {"cs3", event.AssociationID},
+		{"cs4Label", "stepName"}, {"cs4", event.StepName},
+		{"cs5Label", "clientId"}, {"cs5", event.ClientID},
+		{"cn1Label", "exitCode"}, {"cn1", exitCode(event)},
+	}
+	var extension []string
+	for i, f := range fields {
+		// a label is left out with its field
+		if strings.HasSuffix(f.key, "Label") && fields[i+1].value == "" {
+			continue
+		}
+		if f.value != "" {
+			extension = append(extension, f.key+"="+escapeCefExtension(f.value))
+		}
+	}
+	header := []string{"CEF:0", vendor, product, version.Version, event.Name, event.Name, strconv.Itoa(severity(event))}
+	for i := 1; i < len(header); i++ {
+		header[i] = escapeCefHeader(header[i])
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(extension, " ")
+}
+
+// formatLeef formats an event in IBM QRadar Log Event Extended Format 1.0, whose attributes are separated by tabs
+func formatLeef(event Event, hostname string) string {
+	fields := []field{
+		{"devTime", strconv.FormatInt(event.Time.UnixNano()/1e6, 10)},
+		{"cat", event.Name},
+		{"sev", strconv.Itoa(severity(event))},
+		{"identHostName", hostname},
+		{"resource", event.InstanceID},
+		{"documentName", event.DocumentName},
+		{"documentVersion", event.DocumentVersion},
+		{"commandId", event.CommandID},
+		{"associationId", event.AssociationID},
+		{"sessionId", event.SessionID},
+		{"clientId", event.ClientID},
+		{"stepName", event.StepName},
+		{"action", event.Action},
+		{"status", event.Status},
+		{"exitCode", exitCode(event)},
+	}
+	var attributes []string
+	for _, f := range fields {
+		if f.value != "" {
+			attributes = append(attributes, f.key+"="+escapeLeef(f.value))
+		}
+	}
+	header := []string{"LEEF:1.0", vendor, product, version.Version, event.Name}
+	for i := 1; i < len(header); i++ {
+		header[i] = escapeLeef(strings.Replace(header[i], "|", " ", -1))
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(attributes, "\t")
+}
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+	leefEscaper         = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+)
+
+func escapeCefHeader(value string) string {
+	return cefHeaderEscaper.Replace(value)
+}
+
+func escapeCefExtension(value string) string {
+	return cefExtensionEscaper.Replace(value)
+}
+
+// escapeLeef replaces the attribute delimiter and the line breaks, which LEEF values can not hold
+func escapeLeef(value string) string {
+	return leefEscaper.Replace(value)
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package auditexport
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// syslogFacility is the authpriv facility, where the systems log their security events
+const syslogFacility = 10
+
+// syslogTag is the tag of the syslog messages
+const syslogTag = "amazon-ssm-agent"
+
+// output is where the formatted events are written
+type output interface {
+	Write(severity int, eventTime time.Time, line string) error
+	Close()
+	String() string
+}
+
+// syslogOutput sends the events to the local syslog socket, which is reconnected after a failed write
+type syslogOutput struct {
+	socket   string
+	hostname string
+	conn     net.Conn
+}
+
+// syslogSeverity returns the syslog severity of an event severity, warning for the severe events and notice otherwise
+func syslogSeverity(severity int) int {
+	if severity >= 7 {
+		return 4
+	}
+	return 5
+}
+
+func (s *syslogOutput) Write(severity int, eventTime time.Time, line string) (err error) {
+	message := fmt.Sprintf("<%d>%s %s %s[%d]: %s\n", syslogFacility*8+syslogSeverity(severity),
+		eventTime.Format(time.Stamp), s.hostname, syslogTag, os.Getpid(), line)
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = dialSyslog(s.socket); err != nil {
+				return err
+			}
+		}
+		if _, err = s.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		s.Close()
+	}
+	return err
+}
+
+// dialSyslog connects to a syslog socket, which is a datagram socket such as /dev/log or a stream socket
+func dialSyslog(socket string) (conn net.Conn, err error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		if conn, err = net.Dial(network, socket); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+func (s *syslogOutput) Close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *syslogOutput) String() string {
+	return "syslog " + s.socket
+}
+
+// fileOutput appends the events to a file only readable by the agent, it is opened for every event so that it can be
+// rotated
+type fileOutput struct {
+	path string
+}
+
+func (f *fileOutput) Write(severity int, eventTime time.Time, line string) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(line + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (f *fileOutput) Close() {}
+
+func (f *fileOutput) String() string {
+	return f.path
+}
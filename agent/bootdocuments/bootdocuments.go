@@ -0,0 +1,237 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package bootdocuments runs the command documents designated in the agent configuration at first boot or every boot,
+// before the agent starts polling for work.
+package bootdocuments
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/docparser"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/twinj/uuid"
+)
+
+const (
+	name          = "BootDocuments"
+	stateDirName  = "bootdocuments"
+	stateFileName = "state.json"
+)
+
+// state records the boot documents that ran on an instance.
+// It is kept per instance id so an image baked from an instance runs its first boot documents again.
+type state struct {
+	LastRunTime                 time.Time
+	CompletedFirstBootDocuments []string
+}
+
+var now = time.Now
+
+var getBootTime = bootTime
+
+var getInstanceID = platform.InstanceID
+
+var dataStorePath = appconfig.DefaultDataStorePath
+
+var runDocument = runDocumentWithProcessor
+
+// Run runs the boot documents due at this boot in their configured order and blocks until they complete.
+// First boot documents are run until they succeed once, every boot documents run once after each boot.
+// When a document fails and its failure policy is Stop, or it requests a reboot, the remaining documents are skipped.
+func Run(context context.T) {
+	context = context.With("[" + name + "]")
+	log := context.Log()
+	config := context.AppConfig()
+	if len(config.Boot.Documents) == 0 {
+		return
+	}
+
+	instanceID, err := getInstanceID()
+	if err != nil {
+		log.Errorf("Failed to get instance id, boot documents are not run: %v", err)
+		return
+	}
+	statePath := filepath.Join(dataStorePath, instanceID, stateDirName, stateFileName)
+	bootState := loadState(context, statePath)
+
+	newBoot := bootState.LastRunTime.IsZero()
+	if bootTime, err := getBootTime(); err != nil {
+		log.Warnf("Failed to get the boot time, every boot documents only run at first boot: %v", err)
+	} else if bootState.LastRunTime.Before(bootTime) {
+		newBoot = true
+	}
+
+	completed := make(map[string]bool)
+	for _, documentName := range bootState.CompletedFirstBootDocuments {
+		completed[documentName] = true
+	}
+
+	orchestrationRootDir := filepath.Join(dataStorePath, instanceID, appconfig.DefaultDocumentRootDirName, config.Agent.OrchestrationRootDir)
+	timeout := time.Duration(config.Boot.DocumentTimeoutSeconds) * time.Second
+	for _, document := range config.Boot.Documents {
+		documentName := document.Name
+		if documentName == "" {
+			documentName = filepath.Base(document.Path)
+		}
+		if document.RunOn == appconfig.BootDocumentRunOnFirstBoot && completed[documentName] ||
+			document.RunOn == appconfig.BootDocumentRunOnEveryBoot && !newBoot {
+			log.Debugf("Boot document %v already ran", documentName)
+			continue
+		}
+
+		log.Infof("Running boot document %v from %v", documentName, document.Path)
+		status, err := runBootDocument(context, instanceID, orchestrationRootDir, documentName, document.Path, timeout)
+		if err != nil {
+			log.Errorf("Boot document %v failed: %v", documentName, err)
+		} else {
+			log.Infof("Boot document %v completed with status %v", documentName, status)
+		}
+
+		succeeded := err == nil && (status == contracts.ResultStatusSuccess || status == contracts.ResultStatusSuccessAndReboot)
+		if succeeded && document.RunOn == appconfig.BootDocumentRunOnFirstBoot {
+			completed[documentName] = true
+			bootState.CompletedFirstBootDocuments = append(bootState.CompletedFirstBootDocuments, documentName)
+			saveState(context, statePath, bootState)
+		}
+		if status == contracts.ResultStatusSuccessAndReboot {
+			log.Infof("Boot document %v requested a reboot, the remaining boot documents run after the reboot", documentName)
+			return
+		}
+		if !succeeded && document.OnFailure == appconfig.BootDocumentOnFailureStop {
+			log.Errorf("Skipping the remaining boot documents after the failure of %v", documentName)
+			break
+		}
+	}
+
+	bootState.LastRunTime = now()
+	saveState(context, statePath, bootState)
+}
+
+// runBootDocument parses the document at documentPath and runs it to completion or until the timeout.
+func runBootDocument(
+	context context.T,
+	instanceID string,
+	orchestrationRootDir string,
+	documentName string,
+	documentPath string,
+	timeout time.Duration) (status contracts.ResultStatus, err error) {
+
+	var content contracts.DocumentContent
+	if err = jsonutil.UnmarshalFile(documentPath, &content); err != nil {
+		return contracts.ResultStatusFailed, fmt.Errorf("failed to read document: %v", err)
+	}
+
+	commandID := uuid.NewV4().String()
+	documentInfo := contracts.DocumentInfo{
+		DocumentID:     commandID,
+		CommandID:      commandID,
+		InstanceID:     instanceID,
+		MessageID:      fmt.Sprintf("aws.ssm.%v.%v", commandID, instanceID),
+		RunID:          times.ToIsoDashUTC(times.DefaultClock.Now()),
+		CreatedDate:    times.ToIso8601UTC(now()),
+		DocumentName:   documentName,
+		DocumentStatus: contracts.ResultStatusInProgress,
+	}
+	parserInfo := docparser.DocumentParserInfo{
+		OrchestrationDir: filepath.Join(orchestrationRootDir, commandID),
+		MessageId:        documentInfo.MessageID,
+		DocumentId:       documentInfo.DocumentID,
+	}
+	docContent := &docparser.DocContent{
+		SchemaVersion:       content.SchemaVersion,
+		Description:         content.Description,
+		RuntimeConfig:       content.RuntimeConfig,
+		MainSteps:           content.MainSteps,
+		Parameters:          content.Parameters,
+		MinimumAgentVersion: content.MinimumAgentVersion,
+	}
+	docState, err := docparser.InitializeDocState(context.Log(), contracts.BootDocument, docContent, documentInfo, parserInfo, nil)
+	if err != nil {
+		return contracts.ResultStatusFailed, fmt.Errorf("failed to parse document: %v", err)
+	}
+
+	result, err := runDocument(context, docState, timeout)
+	if err != nil {
+		return contracts.ResultStatusFailed, err
+	}
+	return result.Status, nil
+}
+
+// runDocumentWithProcessor runs the document with a dedicated processor and waits for its final result.
+// The document is canceled when it does not complete before the timeout.
+func runDocumentWithProcessor(context context.T, docState contracts.DocumentState, timeout time.Duration) (result contracts.DocumentResult, err error) {
+	proc := processor.NewEngineProcessor(context, 1, 1, []contracts.DocumentType{contracts.BootDocument})
+	resChan, err := proc.Start()
+	if err != nil {
+		return result, err
+	}
+	stopType := contracts.StopTypeSoftStop
+	defer func() {
+		// drain the results sent while the processor stops, it closes the channel once stopped
+		go func() {
+			for range resChan {
+			}
+		}()
+		proc.Stop(stopType)
+	}()
+
+	proc.Submit(docState)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case res, ok := <-resChan:
+			if !ok {
+				return result, fmt.Errorf("document processor stopped")
+			}
+			if res.LastPlugin == "" && res.QueuePosition == 0 {
+				return res, nil
+			}
+		case <-timer.C:
+			stopType = contracts.StopTypeHardStop
+			return result, fmt.Errorf("document timed out after %v", timeout)
+		}
+	}
+}
+
+func loadState(context context.T, statePath string) (bootState state) {
+	if !fileutil.Exists(statePath) {
+		return
+	}
+	if err := jsonutil.UnmarshalFile(statePath, &bootState); err != nil {
+		context.Log().Errorf("Failed to read boot documents state, all boot documents run again: %v", err)
+	}
+	return
+}
+
+func saveState(context context.T, statePath string, bootState state) {
+	content, err := jsonutil.Marshal(bootState)
+	if err == nil {
+		if err = fileutil.MakeDirs(filepath.Dir(statePath)); err == nil {
+			err = fileutil.WriteAllText(statePath, content)
+		}
+	}
+	if err != nil {
+		context.Log().Errorf("Failed to save boot documents state: %v", err)
+	}
+}
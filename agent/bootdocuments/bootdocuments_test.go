@@ -0,0 +1,161 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package bootdocuments
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testDocument = `{
+	"schemaVersion": "2.0",
+	"mainSteps": [{"action": "aws:runShellScript", "name": "run", "inputs": {"runCommand": ["echo"]}}]
+}`
+
+type bootDocumentsTest struct {
+	dir      string
+	ran      []string
+	statuses map[string]contracts.ResultStatus
+	bootTime time.Time
+	now      time.Time
+}
+
+func setupBootDocumentsTest(t *testing.T) (*bootDocumentsTest, func()) {
+	dir, err := ioutil.TempDir("", "bootdocuments")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "document.json"), []byte(testDocument), 0600))
+
+	test := &bootDocumentsTest{
+		dir:      dir,
+		statuses: make(map[string]contracts.ResultStatus),
+		bootTime: time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC),
+		now:      time.Date(2018, 5, 1, 10, 1, 0, 0, time.UTC),
+	}
+	origNow, origGetBootTime, origGetInstanceID, origDataStorePath, origRunDocument := now, getBootTime, getInstanceID, dataStorePath, runDocument
+	now = func() time.Time { return test.now }
+	getBootTime = func() (time.Time, error) { return test.bootTime, nil }
+	getInstanceID = func() (string, error) { return "i-1234567890", nil }
+	dataStorePath = dir
+	runDocument = func(context context.T, docState contracts.DocumentState, timeout time.Duration) (contracts.DocumentResult, error) {
+		documentName := docState.DocumentInformation.DocumentName
+		test.ran = append(test.ran, documentName)
+		status, exists := test.statuses[documentName]
+		if !exists {
+			status = contracts.ResultStatusSuccess
+		}
+		return contracts.DocumentResult{Status: status}, nil
+	}
+	return test, func() {
+		now, getBootTime, getInstanceID, dataStorePath, runDocument = origNow, origGetBootTime, origGetInstanceID, origDataStorePath, origRunDocument
+		os.RemoveAll(dir)
+	}
+}
+
+func (test *bootDocumentsTest) run(documents ...appconfig.BootDocumentCfg) []string {
+	for i := range documents {
+		documents[i].Path = filepath.Join(test.dir, "document.json")
+	}
+	config := appconfig.SsmagentConfig{Boot: appconfig.BootCfg{Documents: documents, DocumentTimeoutSeconds: 60}}
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	ctx.On("With", mock.AnythingOfType("string")).Return(ctx)
+
+	test.ran = nil
+	Run(ctx)
+	return test.ran
+}
+
+func TestRunFirstBootAndEveryBootDocuments(t *testing.T) {
+	test, cleanup := setupBootDocumentsTest(t)
+	defer cleanup()
+	documents := []appconfig.BootDocumentCfg{
+		{Name: "bootstrap", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+		{Name: "refresh", RunOn: appconfig.BootDocumentRunOnEveryBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+	}
+
+	assert.Equal(t, []string{"bootstrap", "refresh"}, test.run(documents...))
+
+	// agent restart without reboot
+	test.now = test.now.Add(time.Hour)
+	assert.Empty(t, test.run(documents...))
+
+	// reboot
+	test.bootTime = test.now.Add(time.Minute)
+	test.now = test.now.Add(2 * time.Minute)
+	assert.Equal(t, []string{"refresh"}, test.run(documents...))
+}
+
+func TestRunRetriesFailedFirstBootDocument(t *testing.T) {
+	test, cleanup := setupBootDocumentsTest(t)
+	defer cleanup()
+	documents := []appconfig.BootDocumentCfg{
+		{Name: "bootstrap", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+	}
+
+	test.statuses["bootstrap"] = contracts.ResultStatusFailed
+	assert.Equal(t, []string{"bootstrap"}, test.run(documents...))
+
+	test.statuses["bootstrap"] = contracts.ResultStatusSuccess
+	assert.Equal(t, []string{"bootstrap"}, test.run(documents...))
+	assert.Empty(t, test.run(documents...))
+}
+
+func TestRunStopsOnFailure(t *testing.T) {
+	test, cleanup := setupBootDocumentsTest(t)
+	defer cleanup()
+	documents := []appconfig.BootDocumentCfg{
+		{Name: "first", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+		{Name: "second", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureStop},
+		{Name: "third", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+	}
+
+	test.statuses["first"] = contracts.ResultStatusFailed
+	test.statuses["second"] = contracts.ResultStatusFailed
+	assert.Equal(t, []string{"first", "second"}, test.run(documents...))
+}
+
+func TestRunStopsOnRebootRequest(t *testing.T) {
+	test, cleanup := setupBootDocumentsTest(t)
+	defer cleanup()
+	documents := []appconfig.BootDocumentCfg{
+		{Name: "first", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+		{Name: "second", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+	}
+
+	test.statuses["first"] = contracts.ResultStatusSuccessAndReboot
+	assert.Equal(t, []string{"first"}, test.run(documents...))
+	assert.Equal(t, []string{"second"}, test.run(documents...))
+}
+
+func TestRunInvalidDocument(t *testing.T) {
+	test, cleanup := setupBootDocumentsTest(t)
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(test.dir, "document.json"), []byte("{"), 0600))
+
+	documents := []appconfig.BootDocumentCfg{
+		{Name: "bootstrap", RunOn: appconfig.BootDocumentRunOnFirstBoot, OnFailure: appconfig.BootDocumentOnFailureContinue},
+	}
+	assert.Empty(t, test.run(documents...))
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentstate"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	exportAgentState = "export-agent-state"
+	importAgentState = "import-agent-state"
+
+	agentStatePath = "path"
+)
+
+const exportAgentStateHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Exports the state of the agent that is not tied to this instance to an archive,
+    to be imported with {{.ImportCommandName}} on an instance launched from a new image.
+    The archive contains the installed packages with their integrity and SBOM records, cached manifests,
+    registered daemons and local schedules.
+    The managed instance registration, the vault and the document state of this instance are never exported.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.PathFlag}}
+
+PARAMETERS
+    {{.PathFlag}} (string) Path of the archive to create, an existing file is replaced.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.PathFlag}} /tmp/agent-state.zip
+
+    Output:
+
+      successfully exported packages, sbom, daemons to /tmp/agent-state.zip
+
+OUTPUT
+    Success message or failure message - failure usually happens because you are not admin
+`
+
+const importAgentStateHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Imports an archive created with {{.ExportCommandName}}.
+    Files in the archive replace the files with the same path, other files are left in place.
+    Stop the agent before importing and start it again afterwards.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.PathFlag}}
+
+PARAMETERS
+    {{.PathFlag}} (string) Path of the archive to import.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.PathFlag}} /tmp/agent-state.zip
+
+    Output:
+
+      successfully imported daemons, packages, sbom from /tmp/agent-state.zip
+
+OUTPUT
+    Success message or failure message - failure usually happens because you are not admin or the archive is not agent state
+`
+
+type agentStateHelpParams struct {
+	SsmCliName        string
+	CommandName       string
+	ExportCommandName string
+	ImportCommandName string
+	PathFlag          string
+}
+
+func init() {
+	cliutil.Register(&ExportAgentStateCommand{})
+	cliutil.Register(&ImportAgentStateCommand{})
+}
+
+type ExportAgentStateCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the export-agent-state cli command
+func (c *ExportAgentStateCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(exportAgentState, subcommands, parameters, agentStatePath)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	path := parameters[agentStatePath][0]
+	exported, err := agentstate.Export(log.NewMockLog(), path)
+	if err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("successfully exported %v to %v", directoryList(exported), path)
+}
+
+// Help prints help for the export-agent-state cli command
+func (c *ExportAgentStateCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = agentStateHelpText(exportAgentStateHelp, exportAgentState)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ExportAgentStateCommand) Name() string {
+	return exportAgentState
+}
+
+type ImportAgentStateCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the import-agent-state cli command
+func (c *ImportAgentStateCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(importAgentState, subcommands, parameters, agentStatePath)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	path := parameters[agentStatePath][0]
+	imported, err := agentstate.Import(log.NewMockLog(), path)
+	if err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("successfully imported %v from %v", directoryList(imported), path)
+}
+
+// Help prints help for the import-agent-state cli command
+func (c *ImportAgentStateCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = agentStateHelpText(importAgentStateHelp, importAgentState)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ImportAgentStateCommand) Name() string {
+	return importAgentState
+}
+
+// directoryList formats the names of exported or imported state directories for the command output
+func directoryList(names []string) string {
+	if len(names) == 0 {
+		return "no state"
+	}
+	return strings.Join(names, ", ")
+}
+
+// agentStateHelpText renders the help template of an agent state cli command
+func agentStateHelpText(helpTemplate string, commandName string) string {
+	t, _ := template.New(commandName).Parse(helpTemplate)
+	params := agentStateHelpParams{
+		SsmCliName:        cliutil.SsmCliName,
+		CommandName:       commandName,
+		ExportCommandName: exportAgentState,
+		ImportCommandName: importAgentState,
+		PathFlag:          cliutil.FormatFlag(agentStatePath),
+	}
+	buf := new(bytes.Buffer)
+	t.Execute(buf, params)
+	return buf.String()
+}
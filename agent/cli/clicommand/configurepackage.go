@@ -0,0 +1,208 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage"
+)
+
+const (
+	configurePackage = "configure-package"
+
+	configurePackageSource  = "source"
+	configurePackageName    = "name"
+	configurePackageVersion = "version"
+	configurePackageAction  = "action"
+)
+
+const configurePackageHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Installs or uninstalls a package staged in a local archive, the same way the aws:configurePackage plugin
+    does for a document, without publishing the package. Use it to bootstrap an instance or to debug the
+    scripts of a package before it is published. The result is not reported to Systems Manager.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.SourceFlag}}
+    {{.NameFlag}}
+    [{{.VersionFlag}}]
+    [{{.ActionFlag}}]
+
+PARAMETERS
+    {{.SourceFlag}} (string) Directory or file URL of the local archive, laid out as
+    <archive>/<package name>/<version>/ with the manifest.json of the version and its files.
+
+    {{.NameFlag}} (string) Name of the package, the name of its directory in the archive.
+
+    {{.VersionFlag}} (string) Version of the package, the highest version staged in the archive by default.
+
+    {{.ActionFlag}} (string) Install or Uninstall, Install by default.
+
+EXAMPLES
+    This example installs the version 1.2.0 of a package staged in /tmp/packages/MyPackage/1.2.0.
+
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.SourceFlag}} /tmp/packages {{.NameFlag}} MyPackage {{.VersionFlag}} 1.2.0 {{.ActionFlag}} Install
+
+    Output:
+
+      Initiating MyPackage 1.2.0 install
+      ...
+      Install of package MyPackage completed with status Success and exit code 0
+
+OUTPUT
+    Output of the package scripts and the result of the action - failure usually happens because you are
+    not admin, the package is not in the archive or one of its scripts failed
+`
+
+type configurePackageHelpParams struct {
+	SsmCliName  string
+	CommandName string
+	SourceFlag  string
+	NameFlag    string
+	VersionFlag string
+	ActionFlag  string
+}
+
+func init() {
+	cliutil.Register(&ConfigurePackageCommand{})
+}
+
+type ConfigurePackageCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the configure-package cli command
+func (c *ConfigurePackageCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateConfigurePackageInput(subcommands, parameters)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	input := configurepackage.ConfigurePackagePluginInput{
+		Name:   parameters[configurePackageName][0],
+		Action: configurepackage.InstallAction,
+	}
+	if values, exists := parameters[configurePackageVersion]; exists {
+		input.Version = values[0]
+	}
+	if values, exists := parameters[configurePackageAction]; exists {
+		switch strings.ToLower(values[0]) {
+		case strings.ToLower(configurepackage.InstallAction):
+			input.Action = configurepackage.InstallAction
+		case strings.ToLower(configurepackage.UninstallAction):
+			input.Action = configurepackage.UninstallAction
+		default:
+			return fmt.Errorf("%v value must be %v or %v", cliutil.FormatFlag(configurePackageAction), configurepackage.InstallAction, configurepackage.UninstallAction), ""
+		}
+	}
+
+	orchestrationDir, err := ioutil.TempDir("", configurePackage)
+	if err != nil {
+		return err, ""
+	}
+	config, err := appconfig.Config(false)
+	if err != nil {
+		config = appconfig.DefaultConfig()
+	}
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+	output, err := configurepackage.RunLocal(context.Default(logger, config), parameters[configurePackageSource][0], input, orchestrationDir)
+	if err != nil {
+		return err, ""
+	}
+
+	var result bytes.Buffer
+	if stdout := strings.TrimSpace(output.GetStdout()); stdout != "" {
+		fmt.Fprintln(&result, stdout)
+	}
+	if stderr := strings.TrimSpace(output.GetStderr()); stderr != "" {
+		fmt.Fprintln(&result, stderr)
+	}
+	fmt.Fprintf(&result, "The output of the package scripts is in %v\n", orchestrationDir)
+	if output.GetStatus().IsReboot() {
+		fmt.Fprintf(&result, "%v of package %v requested a reboot, reboot the instance and run the command again to complete it", input.Action, input.Name)
+	} else {
+		fmt.Fprintf(&result, "%v of package %v completed with status %v and exit code %v", input.Action, input.Name, output.GetStatus(), output.GetExitCode())
+	}
+	return nil, result.String()
+}
+
+// Help prints help for the configure-package cli command
+func (c *ConfigurePackageCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("ConfigurePackageHelp").Parse(configurePackageHelp)
+		params := configurePackageHelpParams{
+			SsmCliName:  cliutil.SsmCliName,
+			CommandName: configurePackage,
+			SourceFlag:  cliutil.FormatFlag(configurePackageSource),
+			NameFlag:    cliutil.FormatFlag(configurePackageName),
+			VersionFlag: cliutil.FormatFlag(configurePackageVersion),
+			ActionFlag:  cliutil.FormatFlag(configurePackageAction),
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ConfigurePackageCommand) Name() string {
+	return configurePackage
+}
+
+// validateConfigurePackageInput checks the subcommands and parameters for required values, format, and unsupported values
+func validateConfigurePackageInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", configurePackage, subcommands), "")
+		return validation
+	}
+
+	// look for required parameters, version and action are optional
+	for _, name := range []string{configurePackageSource, configurePackageName} {
+		if _, exists := parameters[name]; !exists {
+			validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(name)))
+		}
+	}
+
+	// look for parameters without a single value and unsupported parameters
+	for key, values := range parameters {
+		switch key {
+		case configurePackageSource, configurePackageName, configurePackageVersion, configurePackageAction:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+)
+
+const (
+	listDeadLetters  = "list-dead-letters"
+	getDeadLetter    = "get-dead-letter"
+	replayDeadLetter = "replay-dead-letter"
+
+	deadLetterID = "id"
+)
+
+const listDeadLettersHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Lists the command results the agent could not deliver to the service, with the reason.
+    A result is kept when the service rejects it, for example because it is too large, when it can't be
+    serialized, or when it could not be sent before the command timed out.
+    Show a result with {{.GetCommandName}} and send it again with {{.ReplayCommandName}}.
+
+SYNOPSIS
+    {{.CommandName}}
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}}
+
+    Output:
+
+      2024-05-01T10:00:00.000Z 4a3bd4c5-1234-4c5d-9a7b-0123456789ab aws.ssm.3c4e.i-0123456789abcdef0 SendReply Error: ValidationException
+
+OUTPUT
+    One line per result with the time it was kept, its id, its message id and the reason
+`
+
+const getDeadLetterHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Shows a command result the agent could not deliver to the service, listed with {{.ListCommandName}}.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.IDFlag}}
+
+PARAMETERS
+    {{.IDFlag}} (string) Id of the result.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.IDFlag}} 4a3bd4c5-1234-4c5d-9a7b-0123456789ab
+
+OUTPUT
+    The result in json format
+`
+
+const replayDeadLetterHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Sends again a command result the agent could not deliver to the service, listed with {{.ListCommandName}}.
+    The result is moved back to the replies the agent sends, the agent sends it on its next attempt and
+    keeps it again if the service still rejects it.
+    Results which could not be serialized have nothing to send and are not replayed.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.IDFlag}}
+
+PARAMETERS
+    {{.IDFlag}} (string) Id of the result.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.IDFlag}} 4a3bd4c5-1234-4c5d-9a7b-0123456789ab
+
+    Output:
+
+      result 4a3bd4c5-1234-4c5d-9a7b-0123456789ab is queued to be sent by the agent
+
+OUTPUT
+    Success message or failure message - failure usually happens because you are not admin or the result does not exist
+`
+
+type deadLetterHelpParams struct {
+	SsmCliName        string
+	CommandName       string
+	ListCommandName   string
+	GetCommandName    string
+	ReplayCommandName string
+	IDFlag            string
+}
+
+func init() {
+	cliutil.Register(&ListDeadLettersCommand{})
+	cliutil.Register(&GetDeadLetterCommand{})
+	cliutil.Register(&ReplayDeadLetterCommand{})
+}
+
+type ListDeadLettersCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the list-dead-letters cli command
+func (c *ListDeadLettersCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(listDeadLetters, subcommands, parameters)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+	letters := mdsService.LoadDeadLetters(logger)
+	if len(letters) == 0 {
+		return nil, "there are no undelivered results"
+	}
+	lines := make([]string, 0, len(letters))
+	for _, letter := range letters {
+		lines = append(lines, fmt.Sprintf("%v %v %v %v", times.ToIso8601UTC(letter.Time), letter.ID, letter.MessageID, letter.Reason))
+	}
+	return nil, strings.Join(lines, "\n")
+}
+
+// Help prints help for the list-dead-letters cli command
+func (c *ListDeadLettersCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = deadLetterHelpText(listDeadLettersHelp, listDeadLetters)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ListDeadLettersCommand) Name() string {
+	return listDeadLetters
+}
+
+type GetDeadLetterCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the get-dead-letter cli command
+func (c *GetDeadLetterCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(getDeadLetter, subcommands, parameters, deadLetterID)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	letter, err := mdsService.GetDeadLetter(parameters[deadLetterID][0])
+	if err != nil {
+		return err, ""
+	}
+	content, err := jsonutil.Marshal(letter)
+	if err != nil {
+		return err, ""
+	}
+	return nil, jsonutil.Indent(content)
+}
+
+// Help prints help for the get-dead-letter cli command
+func (c *GetDeadLetterCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = deadLetterHelpText(getDeadLetterHelp, getDeadLetter)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (GetDeadLetterCommand) Name() string {
+	return getDeadLetter
+}
+
+type ReplayDeadLetterCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the replay-dead-letter cli command
+func (c *ReplayDeadLetterCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(replayDeadLetter, subcommands, parameters, deadLetterID)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+	id := parameters[deadLetterID][0]
+	if err := mdsService.ReplayDeadLetter(logger, id); err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("result %v is queued to be sent by the agent", id)
+}
+
+// Help prints help for the replay-dead-letter cli command
+func (c *ReplayDeadLetterCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = deadLetterHelpText(replayDeadLetterHelp, replayDeadLetter)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ReplayDeadLetterCommand) Name() string {
+	return replayDeadLetter
+}
+
+// deadLetterHelpText renders the help template of a dead letter cli command
+func deadLetterHelpText(helpTemplate string, commandName string) string {
+	t, _ := template.New(commandName).Parse(helpTemplate)
+	params := deadLetterHelpParams{
+		SsmCliName:        cliutil.SsmCliName,
+		CommandName:       commandName,
+		ListCommandName:   listDeadLetters,
+		GetCommandName:    getDeadLetter,
+		ReplayCommandName: replayDeadLetter,
+		IDFlag:            cliutil.FormatFlag(deadLetterID),
+	}
+	buf := new(bytes.Buffer)
+	t.Execute(buf, params)
+	return buf.String()
+}
@@ -23,18 +23,22 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 )
 
 const (
 	getInstanceInformationCommand = "get-instance-information"
+
+	registrationStateRegistered   = "registered"
+	registrationStateUnregistered = "unregistered"
 )
 
 const getInstanceInformationCommandHelp = `NAME:
 EXAMPLES
     This example returns basic information about the instance this agent is running on,
-    including AWS region name, instance id and release version of this CLI.
+    including AWS region name, instance id, managed instance registration state and release version of this CLI.
 
     Note: release version of this CLI should match the release version of the SSM agent,
     since in normal case, CLI and agent are compiled from same source files; in rare
@@ -49,13 +53,35 @@ EXAMPLES
       {
         "region" : "us-west-2",
         "instance-id" : "i-12345678",
+        "registration-state" : "unregistered",
+        "release-version" : "1.0.0"
+      }
+
+    On a registered managed instance, the instance ID is the managed instance ID:
+
+      {
+        "region" : "us-west-2",
+        "instance-id" : "mi-12345678901234567",
+        "managed-instance-id" : "mi-12345678901234567",
+        "registration-state" : "registered",
         "release-version" : "1.0.0"
       }
 
 OUTPUT
-    Instance information containing region, instance ID and version in JSON format
+    Instance information containing region, instance ID, registration state and version in JSON format.
+    The registration state is "registered" when the agent holds managed instance registration credentials,
+    "unregistered" otherwise. The region and instance ID of an unregistered instance outside of EC2
+    are left out.
 `
 
+// the registration and the instance information reported by the command, replaced in the tests
+var (
+	hasManagedInstancesCredentials = registration.HasManagedInstancesCredentials
+	managedInstanceID              = registration.InstanceID
+	instanceRegion                 = platform.Region
+	instanceID                     = platform.InstanceID
+)
+
 type getInstanceInformationHelpParams struct {
 	SsmCliName                        string
 	GetInstanceInformationCommandName string
@@ -78,16 +104,25 @@ func (c *GetInstanceInformationCommand) Execute(subcommands []string, parameters
 	}
 
 	information := make(map[string]string)
-	if region, err := platform.Region(); err != nil {
-		return err, ""
+	registered, _ := hasManagedInstancesCredentials()
+	if registered {
+		information["registration-state"] = registrationStateRegistered
+		information["managed-instance-id"] = managedInstanceID()
 	} else {
-		information["region"] = region
+		information["registration-state"] = registrationStateUnregistered
 	}
 
-	if instanceId, err := platform.InstanceID(); err != nil {
+	// bootstrap scripts check the registration state of instances that are neither registered nor in EC2
+	if region, err := instanceRegion(); err == nil {
+		information["region"] = region
+	} else if registered {
 		return err, ""
-	} else {
+	}
+
+	if instanceId, err := instanceID(); err == nil {
 		information["instance-id"] = instanceId
+	} else if registered {
+		return err, ""
 	}
 
 	information["release-version"] = version.Version
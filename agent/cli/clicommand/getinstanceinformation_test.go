@@ -0,0 +1,93 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// useInstance stubs the registration and the instance information, an empty region or instance id fails to be read
+func useInstance(registered bool, region string, id string) func() {
+	savedCredentials, savedManagedInstanceID, savedRegion, savedInstanceID := hasManagedInstancesCredentials, managedInstanceID, instanceRegion, instanceID
+	hasManagedInstancesCredentials = func() (bool, error) { return registered, nil }
+	managedInstanceID = func() string { return id }
+	instanceRegion = func() (string, error) {
+		if region == "" {
+			return "", errors.New("no region")
+		}
+		return region, nil
+	}
+	instanceID = func() (string, error) {
+		if id == "" {
+			return "", errors.New("no instance id")
+		}
+		return id, nil
+	}
+	return func() {
+		hasManagedInstancesCredentials, managedInstanceID, instanceRegion, instanceID = savedCredentials, savedManagedInstanceID, savedRegion, savedInstanceID
+	}
+}
+
+func TestGetInstanceInformationValidation(t *testing.T) {
+	command := &GetInstanceInformationCommand{}
+
+	err, output := command.Execute([]string{"extra"}, nil)
+	assert.EqualError(t, err, "get-instance-information does not support subcommand [extra]\n")
+	assert.Empty(t, output)
+
+	err, output = command.Execute(nil, map[string][]string{"region": {"us-west-2"}})
+	assert.EqualError(t, err, "unknown parameter --region")
+	assert.Empty(t, output)
+}
+
+func TestGetInstanceInformationOfEc2Instance(t *testing.T) {
+	defer useInstance(false, "us-west-2", "i-12345678")()
+
+	err, output := (&GetInstanceInformationCommand{}).Execute(nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"instance-id":"i-12345678","region":"us-west-2","registration-state":"unregistered","release-version":"`+version.Version+`"}`, output)
+}
+
+func TestGetInstanceInformationOfManagedInstance(t *testing.T) {
+	defer useInstance(true, "us-west-2", "mi-12345678901234567")()
+
+	err, output := (&GetInstanceInformationCommand{}).Execute(nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"instance-id":"mi-12345678901234567","managed-instance-id":"mi-12345678901234567","region":"us-west-2",`+
+		`"registration-state":"registered","release-version":"`+version.Version+`"}`, output)
+}
+
+func TestGetInstanceInformationOfUnregisteredInstanceOutsideEc2(t *testing.T) {
+	defer useInstance(false, "", "")()
+
+	err, output := (&GetInstanceInformationCommand{}).Execute(nil, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"registration-state":"unregistered","release-version":"`+version.Version+`"}`, output)
+}
+
+func TestGetInstanceInformationFailsWhenManagedInstanceRegionIsMissing(t *testing.T) {
+	defer useInstance(true, "", "mi-12345678901234567")()
+
+	err, output := (&GetInstanceInformationCommand{}).Execute(nil, nil)
+
+	assert.EqualError(t, err, "no region")
+	assert.Empty(t, output)
+}
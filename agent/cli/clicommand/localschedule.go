@@ -0,0 +1,318 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clicommand contains the implementation of all commands for the ssm agent cli
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/localschedule"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+)
+
+const (
+	registerLocalSchedule   = "register-local-schedule"
+	deregisterLocalSchedule = "deregister-local-schedule"
+	listLocalSchedules      = "list-local-schedules"
+
+	localScheduleName       = "name"
+	localScheduleExpression = "schedule-expression"
+	localScheduleContent    = "content"
+)
+
+const registerLocalScheduleHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Registers a command document to run on a schedule on this instance, without an association.
+    The schedule is kept across agent restarts and runs while the instance is offline.
+    Registering a schedule with an existing name replaces that schedule.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.NameFlag}}
+    {{.ExpressionFlag}}
+    {{.ContentFlag}}
+
+PARAMETERS
+    {{.NameFlag}} (string) Name of the schedule, letters, digits, '_', '-' or '.'.
+
+    {{.ExpressionFlag}} (string) Cron or rate expression, for example "cron(0 2 * * ? *)" or "rate(30 minutes)".
+
+    {{.ContentFlag}} (string) JSON or URL to command document, in the format accepted by {{.SendCommandName}}.
+
+EXAMPLES
+    This example runs a command document stored on the instance every night at 2 AM.
+
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.NameFlag}} nightly-cleanup {{.ExpressionFlag}} "cron(0 2 * * ? *)" {{.ContentFlag}} file:///opt/cleanup.json
+
+    Output:
+
+      successfully registered local schedule nightly-cleanup
+
+OUTPUT
+    Success message or failure message - failure usually happens because you are not admin or provided invalid input
+`
+
+const deregisterLocalScheduleHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Removes a schedule registered with {{.RegisterCommandName}}.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.NameFlag}}
+
+PARAMETERS
+    {{.NameFlag}} (string) Name of the schedule.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.NameFlag}} nightly-cleanup
+
+    Output:
+
+      successfully deregistered local schedule nightly-cleanup
+
+OUTPUT
+    Success message or failure message
+`
+
+const listLocalSchedulesHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Lists the schedules registered with {{.RegisterCommandName}}.
+    Documents run by a schedule can be found with get-offline-command-invocation.
+
+SYNOPSIS
+    {{.CommandName}}
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}}
+
+    Output:
+
+      [
+        {
+          "name": "nightly-cleanup",
+          "scheduleExpression": "cron(0 2 * * ? *)",
+          "nextRunTime": "2018-05-02T02:00:00.000Z"
+        }
+      ]
+
+OUTPUT
+    Registered schedules in JSON format
+`
+
+type localScheduleHelpParams struct {
+	SsmCliName          string
+	CommandName         string
+	RegisterCommandName string
+	SendCommandName     string
+	NameFlag            string
+	ExpressionFlag      string
+	ContentFlag         string
+}
+
+// localScheduleSummary is the output of list-local-schedules for a schedule
+type localScheduleSummary struct {
+	Name               string `json:"name"`
+	ScheduleExpression string `json:"scheduleExpression"`
+	NextRunTime        string `json:"nextRunTime"`
+}
+
+func init() {
+	cliutil.Register(&RegisterLocalScheduleCommand{})
+	cliutil.Register(&DeregisterLocalScheduleCommand{})
+	cliutil.Register(&ListLocalSchedulesCommand{})
+}
+
+type RegisterLocalScheduleCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the register-local-schedule cli command
+func (c *RegisterLocalScheduleCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(registerLocalSchedule, subcommands, parameters,
+		localScheduleName, localScheduleExpression, localScheduleContent)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+	if val := parameters[localScheduleContent][0]; !cliutil.ValidJson(val) && !cliutil.ValidUrl(val) {
+		return fmt.Errorf("%v value must be valid json or a URL", cliutil.FormatFlag(localScheduleContent)), ""
+	}
+
+	err, content := SendOfflineCommand{}.loadContent(parameters[localScheduleContent][0])
+	if err != nil {
+		return err, ""
+	}
+	schedule := localschedule.Schedule{
+		Name:               parameters[localScheduleName][0],
+		ScheduleExpression: parameters[localScheduleExpression][0],
+		Content:            content,
+	}
+	if err = localschedule.Save(log.NewMockLog(), appconfig.LocalScheduleRoot, schedule); err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("successfully registered local schedule %v", schedule.Name)
+}
+
+// Help prints help for the register-local-schedule cli command
+func (c *RegisterLocalScheduleCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = localScheduleHelpText(registerLocalScheduleHelp, registerLocalSchedule)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (RegisterLocalScheduleCommand) Name() string {
+	return registerLocalSchedule
+}
+
+type DeregisterLocalScheduleCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the deregister-local-schedule cli command
+func (c *DeregisterLocalScheduleCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(deregisterLocalSchedule, subcommands, parameters, localScheduleName)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	name := parameters[localScheduleName][0]
+	if err := localschedule.Delete(appconfig.LocalScheduleRoot, name); err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("successfully deregistered local schedule %v", name)
+}
+
+// Help prints help for the deregister-local-schedule cli command
+func (c *DeregisterLocalScheduleCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = localScheduleHelpText(deregisterLocalScheduleHelp, deregisterLocalSchedule)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (DeregisterLocalScheduleCommand) Name() string {
+	return deregisterLocalSchedule
+}
+
+type ListLocalSchedulesCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the list-local-schedules cli command
+func (c *ListLocalSchedulesCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(listLocalSchedules, subcommands, parameters)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	logger := log.NewMockLog()
+	schedules, err := localschedule.Load(logger, appconfig.LocalScheduleRoot)
+	if err != nil {
+		return err, ""
+	}
+	summaries := make([]localScheduleSummary, 0, len(schedules))
+	for _, schedule := range schedules {
+		summary := localScheduleSummary{Name: schedule.Name, ScheduleExpression: schedule.ScheduleExpression}
+		if expression, err := scheduleexpression.CreateScheduleExpression(logger, schedule.ScheduleExpression); err == nil {
+			summary.NextRunTime = times.ToIso8601UTC(expression.Next(time.Now()))
+		}
+		summaries = append(summaries, summary)
+	}
+	result, _ := jsonutil.Marshal(summaries)
+	return nil, jsonutil.Indent(result)
+}
+
+// Help prints help for the list-local-schedules cli command
+func (c *ListLocalSchedulesCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = localScheduleHelpText(listLocalSchedulesHelp, listLocalSchedules)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ListLocalSchedulesCommand) Name() string {
+	return listLocalSchedules
+}
+
+// localScheduleHelpText renders the help template of a local schedule cli command
+func localScheduleHelpText(helpTemplate string, commandName string) string {
+	t, _ := template.New(commandName).Parse(helpTemplate)
+	params := localScheduleHelpParams{
+		SsmCliName:          cliutil.SsmCliName,
+		CommandName:         commandName,
+		RegisterCommandName: registerLocalSchedule,
+		SendCommandName:     sendCommand,
+		NameFlag:            cliutil.FormatFlag(localScheduleName),
+		ExpressionFlag:      cliutil.FormatFlag(localScheduleExpression),
+		ContentFlag:         cliutil.FormatFlag(localScheduleContent),
+	}
+	buf := new(bytes.Buffer)
+	t.Execute(buf, params)
+	return buf.String()
+}
+
+// validateCommandInput checks the command has no subcommands and exactly one value for each of the required parameters
+func validateCommandInput(commandName string, subcommands []string, parameters map[string][]string, required ...string) []string {
+	validation := make([]string, 0)
+	if subcommands != nil && len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", commandName, subcommands), "")
+		return validation // invalid subcommand is an attempt to execute something that really isn't this command, so the rest of the validation is skipped in this case
+	}
+
+	// look for required parameters
+	for _, name := range required {
+		if _, exists := parameters[name]; !exists {
+			validation = append(validation, fmt.Sprintf("%v is required", cliutil.FormatFlag(name)))
+		} else if len(parameters[name]) != 1 {
+			validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(name)))
+		}
+	}
+
+	// look for unsupported parameters
+	for key := range parameters {
+		supported := false
+		for _, name := range required {
+			supported = supported || key == name
+		}
+		if !supported {
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
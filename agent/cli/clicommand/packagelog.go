@@ -0,0 +1,171 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installlog"
+)
+
+const (
+	tailPackageLog = "tail-package-log"
+
+	packageLogName  = "name"
+	packageLogLines = "lines"
+
+	defaultPackageLogLines = 100
+)
+
+const tailPackageLogHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Shows the end of the install log of a package, the output of its install, uninstall and validate scripts
+    with the version, status and exit code of each run. The install logs are written when
+    Birdwatcher.InstallLogs.Enabled is set in the agent configuration, the log of each package is rotated at
+    Birdwatcher.InstallLogs.MaxFileSizeKB and the rotated logs are shown when the current one has fewer lines.
+    Without a package name, lists the packages with an install log.
+
+SYNOPSIS
+    {{.CommandName}}
+    [{{.NameFlag}}]
+    [{{.LinesFlag}}]
+
+PARAMETERS
+    {{.NameFlag}} (string) Name or ARN of the package.
+
+    {{.LinesFlag}} (integer) Number of lines shown, {{.DefaultLines}} by default.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.NameFlag}} MyPackage {{.LinesFlag}} 5
+
+    Output:
+
+      ==== 2024-05-01T10:00:00Z arn:aws:ssm:us-east-1:123456789012:document/MyPackage 1.2.0 install ====
+      status: Failed, exit code: 1
+      ---- stderr
+      install.sh: line 12: /opt/mypackage/bin/setup: No such file or directory
+
+OUTPUT
+    The last lines of the install log, or the packages with an install log - failure usually happens because
+    you are not admin or the package has no install log
+`
+
+type tailPackageLogHelpParams struct {
+	SsmCliName   string
+	CommandName  string
+	NameFlag     string
+	LinesFlag    string
+	DefaultLines int
+}
+
+func init() {
+	cliutil.Register(&TailPackageLogCommand{})
+}
+
+type TailPackageLogCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the tail-package-log cli command
+func (c *TailPackageLogCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateTailPackageLogInput(subcommands, parameters)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	names, exists := parameters[packageLogName]
+	if !exists {
+		packages, err := installlog.Packages(appconfig.PackageLogsDirectory)
+		if err != nil {
+			return err, ""
+		}
+		if len(packages) == 0 {
+			return nil, fmt.Sprintf("there are no package install logs in %v", appconfig.PackageLogsDirectory)
+		}
+		return nil, strings.Join(packages, "\n")
+	}
+
+	lines := defaultPackageLogLines
+	if values, exists := parameters[packageLogLines]; exists {
+		lines, _ = strconv.Atoi(values[0])
+	}
+	path, err := installlog.Find(appconfig.PackageLogsDirectory, names[0])
+	if err != nil {
+		return err, ""
+	}
+	tail, err := installlog.Tail(path, lines)
+	if err != nil {
+		return err, ""
+	}
+	return nil, strings.TrimSuffix(tail, "\n")
+}
+
+// Help prints help for the tail-package-log cli command
+func (c *TailPackageLogCommand) Help() string {
+	if len(c.helpText) == 0 {
+		t, _ := template.New("TailPackageLogHelp").Parse(tailPackageLogHelp)
+		params := tailPackageLogHelpParams{
+			SsmCliName:   cliutil.SsmCliName,
+			CommandName:  tailPackageLog,
+			NameFlag:     cliutil.FormatFlag(packageLogName),
+			LinesFlag:    cliutil.FormatFlag(packageLogLines),
+			DefaultLines: defaultPackageLogLines,
+		}
+		buf := new(bytes.Buffer)
+		t.Execute(buf, params)
+		c.helpText = buf.String()
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (TailPackageLogCommand) Name() string {
+	return tailPackageLog
+}
+
+// validateTailPackageLogInput checks the subcommands and parameters for format and unsupported values
+func validateTailPackageLogInput(subcommands []string, parameters map[string][]string) []string {
+	validation := make([]string, 0)
+	if len(subcommands) > 0 {
+		validation = append(validation, fmt.Sprintf("%v does not support subcommand %v", tailPackageLog, subcommands), "")
+		return validation
+	}
+
+	for key, values := range parameters {
+		switch key {
+		case packageLogName, packageLogLines:
+			if len(values) != 1 {
+				validation = append(validation, fmt.Sprintf("expected 1 value for parameter %v", cliutil.FormatFlag(key)))
+			} else if key == packageLogLines {
+				if lines, err := strconv.Atoi(values[0]); err != nil || lines < 1 {
+					validation = append(validation, fmt.Sprintf("%v must be a positive integer", cliutil.FormatFlag(key)))
+				}
+			}
+		default:
+			validation = append(validation, fmt.Sprintf("unknown parameter %v", cliutil.FormatFlag(key)))
+		}
+	}
+	return validation
+}
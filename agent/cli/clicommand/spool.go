@@ -0,0 +1,203 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clicommand
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/cli/cliutil"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	_ "github.com/aws/amazon-ssm-agent/agent/telemetry" // registers the handler of the spooled telemetry
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+)
+
+const (
+	exportSpool = "export-spool"
+	importSpool = "import-spool"
+
+	spoolPath = "path"
+)
+
+const exportSpoolHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Exports the uploads spooled by the agent of a disconnected instance to a compressed bundle,
+    to be carried to a connected network and imported with {{.ImportCommandName}}.
+    The bundle contains the command outputs, session logs and telemetry the agent did not send,
+    it is signed with the secret of Uploads.Spool.SigningKeyPath of the agent configuration.
+    The exported uploads are removed from the spool.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.PathFlag}}
+
+PARAMETERS
+    {{.PathFlag}} (string) Path of the bundle to create, an existing file is replaced.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.PathFlag}} /media/usb/spool-1.gz
+
+    Output:
+
+      successfully exported bundle 1 of i-0123456789abcdef0 with 42 uploads to /media/usb/spool-1.gz
+
+OUTPUT
+    Success message or failure message - failure usually happens because you are not admin or the signing key is not set
+`
+
+const importSpoolHelp = `NAME:
+    {{.CommandName}}
+
+DESCRIPTION
+    Imports a bundle created with {{.ExportCommandName}} on a disconnected instance and does its uploads.
+    The signature of the bundle is verified with the secret of Uploads.Spool.SigningKeyPath of the agent configuration.
+    A bundle is imported once, and never after a later bundle of the same instance.
+    The uploads the ssm-cli can't do, for example the inventory of the instance, are skipped.
+
+SYNOPSIS
+    {{.CommandName}}
+    {{.PathFlag}}
+
+PARAMETERS
+    {{.PathFlag}} (string) Path of the bundle to import.
+
+EXAMPLES
+    Command:
+
+      {{.SsmCliName}} {{.CommandName}} {{.PathFlag}} /media/usb/spool-1.gz
+
+    Output:
+
+      successfully imported bundle 1 of i-0123456789abcdef0 with 42 uploads, skipped 0 uploads
+
+OUTPUT
+    Success message or failure message - failure usually happens because the signature does not match or the bundle was already imported
+`
+
+type spoolHelpParams struct {
+	SsmCliName        string
+	CommandName       string
+	ExportCommandName string
+	ImportCommandName string
+	PathFlag          string
+}
+
+func init() {
+	cliutil.Register(&ExportSpoolCommand{})
+	cliutil.Register(&ImportSpoolCommand{})
+}
+
+type ExportSpoolCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the export-spool cli command
+func (c *ExportSpoolCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(exportSpool, subcommands, parameters, spoolPath)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		return err, ""
+	}
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+	path := parameters[spoolPath][0]
+	summary, err := uploadqueue.ExportSpool(logger, spoolConfig(), instanceID, path)
+	if err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("successfully exported bundle %v of %v with %v uploads to %v", summary.Sequence, summary.InstanceID, summary.Uploads, path)
+}
+
+// Help prints help for the export-spool cli command
+func (c *ExportSpoolCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = spoolHelpText(exportSpoolHelp, exportSpool)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ExportSpoolCommand) Name() string {
+	return exportSpool
+}
+
+type ImportSpoolCommand struct {
+	helpText string
+}
+
+// Execute validates and executes the import-spool cli command
+func (c *ImportSpoolCommand) Execute(subcommands []string, parameters map[string][]string) (error, string) {
+	validation := validateCommandInput(importSpool, subcommands, parameters, spoolPath)
+	if len(validation) > 0 {
+		return errors.New(strings.Join(validation, "\n")), ""
+	}
+
+	logger := ssmlog.SSMLogger(false)
+	defer logger.Flush()
+	summary, err := uploadqueue.ImportSpool(logger, spoolConfig(), parameters[spoolPath][0])
+	if err != nil {
+		return err, ""
+	}
+	return nil, fmt.Sprintf("successfully imported bundle %v of %v with %v uploads, skipped %v uploads", summary.Sequence, summary.InstanceID, summary.Uploads, summary.Skipped)
+}
+
+// Help prints help for the import-spool cli command
+func (c *ImportSpoolCommand) Help() string {
+	if len(c.helpText) == 0 {
+		c.helpText = spoolHelpText(importSpoolHelp, importSpool)
+	}
+	return c.helpText
+}
+
+// Name is the command name used in the cli
+func (ImportSpoolCommand) Name() string {
+	return importSpool
+}
+
+// spoolConfig returns the spool configuration of the agent
+func spoolConfig() appconfig.SpoolCfg {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		config = appconfig.DefaultConfig()
+	}
+	return config.Uploads.Spool
+}
+
+// spoolHelpText renders the help template of a spool cli command
+func spoolHelpText(helpTemplate string, commandName string) string {
+	t, _ := template.New(commandName).Parse(helpTemplate)
+	params := spoolHelpParams{
+		SsmCliName:        cliutil.SsmCliName,
+		CommandName:       commandName,
+		ExportCommandName: exportSpool,
+		ImportCommandName: importSpool,
+		PathFlag:          cliutil.FormatFlag(spoolPath),
+	}
+	buf := new(bytes.Buffer)
+	t.Execute(buf, params)
+	return buf.String()
+}
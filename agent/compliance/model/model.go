@@ -14,6 +14,7 @@
 package model
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -39,8 +40,22 @@ type AssociationComplianceItem struct {
 	ComplianceStatus   string
 }
 
+// CheckComplianceItem is what a step of an association run in check mode would change,
+// the step is compliant when it would change nothing
+type CheckComplianceItem struct {
+	AssociationId      string
+	StepName           string
+	ExecutionTime      time.Time
+	DocumentName       string
+	DocumentVersion    string
+	ComplianceSeverity string
+	ComplianceStatus   string
+	Changes            []string
+}
+
 // Association compliance status is Unspecified by default
 var associationComplianceItems = []*AssociationComplianceItem{}
+var checkComplianceItems = []*CheckComplianceItem{}
 var lock = sync.RWMutex{}
 
 /**
@@ -117,6 +132,61 @@ func RefreshAssociationComplianceItems(associations []*model.InstanceAssociation
 	}
 
 	associationComplianceItems = newComplianceItems
+
+	var newCheckItems = []*CheckComplianceItem{}
+	for _, item := range checkComplianceItems {
+		if _, exist := associationMap[item.AssociationId]; exist {
+			newCheckItems = append(newCheckItems, item)
+		}
+	}
+	checkComplianceItems = newCheckItems
+}
+
+/**
+ * Replace the check compliance items of the association with the steps of its last run in check mode,
+ * the steps which don't support check mode are left out
+ */
+func UpdateCheckComplianceItems(associationId string, documentName string, documentVersion string, pluginResults map[string]*contracts.PluginResult, executionTime time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var newCheckItems = []*CheckComplianceItem{}
+	for _, item := range checkComplianceItems {
+		if item.AssociationId != associationId {
+			newCheckItems = append(newCheckItems, item)
+		} else if !item.ExecutionTime.Before(executionTime) {
+			// a newer check run of the association was already recorded
+			return
+		}
+	}
+
+	var stepNames = []string{}
+	for stepName, result := range pluginResults {
+		if result != nil && result.CheckReport != nil && result.CheckReport.Supported {
+			stepNames = append(stepNames, stepName)
+		}
+	}
+	sort.Strings(stepNames)
+
+	for _, stepName := range stepNames {
+		var changes = pluginResults[stepName].CheckReport.Changes
+		var compliantStatus = COMPLIANT
+		if len(changes) > 0 {
+			compliantStatus = NON_COMPLIANT
+		}
+		newCheckItems = append(newCheckItems, &CheckComplianceItem{
+			associationId,
+			stepName,
+			executionTime,
+			documentName,
+			documentVersion,
+			UNSPECIFIED,
+			compliantStatus,
+			changes,
+		})
+	}
+
+	checkComplianceItems = newCheckItems
 }
 
 func GetAssociationComplianceEntries() []*AssociationComplianceItem {
@@ -125,3 +195,10 @@ func GetAssociationComplianceEntries() []*AssociationComplianceItem {
 
 	return associationComplianceItems
 }
+
+func GetCheckComplianceEntries() []*CheckComplianceItem {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return checkComplianceItems
+}
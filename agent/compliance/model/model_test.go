@@ -156,3 +156,31 @@ func TestRefreshAssociationComplianceItems(t *testing.T) {
 	assert.Equal(t, 1, len(complianceItems))
 
 }
+
+func TestUpdateCheckComplianceItems(t *testing.T) {
+	executionTime := time.Now()
+	results := map[string]*contracts.PluginResult{
+		"firewall": {CheckReport: &contracts.CheckReport{Supported: true, Changes: []string{"- old"}}},
+		"dns":      {CheckReport: &contracts.CheckReport{Supported: true}},
+		"domain":   {CheckReport: &contracts.CheckReport{Supported: false}},
+	}
+
+	UpdateCheckComplianceItems("check_association", "testDoc", "1", results, executionTime)
+
+	var items []*CheckComplianceItem
+	for _, item := range GetCheckComplianceEntries() {
+		if item.AssociationId == "check_association" {
+			items = append(items, item)
+		}
+	}
+	assert.Equal(t, 2, len(items))
+	assert.Equal(t, "dns", items[0].StepName)
+	assert.Equal(t, COMPLIANT, items[0].ComplianceStatus)
+	assert.Equal(t, "firewall", items[1].StepName)
+	assert.Equal(t, NON_COMPLIANT, items[1].ComplianceStatus)
+	assert.Equal(t, []string{"- old"}, items[1].Changes)
+
+	// an older run doesn't replace the items of the association
+	UpdateCheckComplianceItems("check_association", "testDoc", "1", map[string]*contracts.PluginResult{}, executionTime.Add(-time.Minute))
+	assert.Equal(t, 2, len(GetCheckComplianceEntries()))
+}
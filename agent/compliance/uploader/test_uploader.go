@@ -16,6 +16,7 @@ package compliance
 import (
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/mock"
 )
@@ -38,3 +39,8 @@ func (m *ComplianceUploaderMock) UpdateAssociationCompliance(associationId strin
 	args := m.Called(associationId, instanceId, documentName, documentVersion, associationStatus, executionTime)
 	return args.Error(0)
 }
+
+func (m *ComplianceUploaderMock) UpdateCheckCompliance(associationId string, instanceId string, documentName string, documentVersion string, pluginResults map[string]*contracts.PluginResult, executionTime time.Time) error {
+	args := m.Called(associationId, instanceId, documentName, documentVersion, pluginResults, executionTime)
+	return args.Error(0)
+}
@@ -17,6 +17,7 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -39,6 +40,11 @@ const (
 	associationComplianceType     = "Association"
 	Name                          = "ComplianceUploader"
 	AssociationComplianceItemName = "AssociationComplianceItem"
+	// checkComplianceType reports the changes the associations run in check mode would make
+	checkComplianceType     = "Custom:AssociationCheck"
+	CheckComplianceItemName = "AssociationCheckComplianceItem"
+	// maxChangesDetailLength caps the changes of a step reported in the details of its compliance item
+	maxChangesDetailLength = 1024
 )
 
 var (
@@ -49,6 +55,7 @@ var (
 type T interface {
 	CreateNewServiceIfUnHealthy(log log.T)
 	UpdateAssociationCompliance(associationId string, instanceId string, documentName string, documentVersion string, associationStatus string, executionTime time.Time) error
+	UpdateCheckCompliance(associationId string, instanceId string, documentName string, documentVersion string, pluginResults map[string]*contracts.PluginResult, executionTime time.Time) error
 }
 
 // ComplianceService wraps the Ssm Service
@@ -149,6 +156,82 @@ func (u *ComplianceUploader) UpdateAssociationCompliance(associationID string, i
 	return nil
 }
 
+/**
+ * Update the compliance of the steps of an association run in check mode, a step that would change the instance is non compliant
+ */
+func (u *ComplianceUploader) UpdateCheckCompliance(associationID string, instanceID string, documentName string, documentVersion string, pluginResults map[string]*contracts.PluginResult, executionTime time.Time) error {
+	log := u.context.Log()
+
+	model.UpdateCheckComplianceItems(associationID, documentName, documentVersion, pluginResults, executionTime)
+	var checkComplianceEntries = model.GetCheckComplianceEntries()
+
+	oldHash := u.optimizer.GetContentHash(CheckComplianceItemName)
+	newComplianceItems, itemContentHash, err := u.ConvertToSsmCheckComplianceItems(log, checkComplianceEntries, oldHash)
+	if err != nil {
+		return fmt.Errorf("Unable to convert check compliance %v", err)
+	}
+
+	response, err := u.ssmSvc.PutComplianceItems(
+		log,
+		&executionTime,
+		"",
+		"",
+		instanceID,
+		checkComplianceType,
+		itemContentHash,
+		newComplianceItems)
+
+	if err != nil {
+		return fmt.Errorf("Unable to update check compliance %v", err)
+	}
+
+	if itemContentHash != oldHash {
+		u.optimizer.UpdateContentHash(CheckComplianceItemName, itemContentHash)
+	}
+
+	log.Debugf("Put check compliance item %v return response %v", newComplianceItems, response)
+	return nil
+}
+
+// ConvertToSsmCheckComplianceItems converts the check compliance items into *ssm.ComplianceItemEntry, the items are
+// left out when they are the same as the ones of the old hash.
+func (u *ComplianceUploader) ConvertToSsmCheckComplianceItems(log log.T, checkComplianceEntries []*model.CheckComplianceItem, oldHash string) (
+	checkComplianceItems []*ssm.ComplianceItemEntry, contentHash string, err error) {
+
+	var dataB []byte
+	if dataB, err = json.Marshal(checkComplianceEntries); err != nil {
+		return
+	}
+
+	newHash := calculateCheckSum(dataB)
+	if newHash == oldHash {
+		log.Debugf("Compliance data for %v is same as before - we can just send content hash", CheckComplianceItemName)
+		return []*ssm.ComplianceItemEntry{}, newHash, nil
+	}
+
+	for _, item := range checkComplianceEntries {
+		changes := strings.Join(item.Changes, "\n")
+		if len(changes) > maxChangesDetailLength {
+			changes = changes[:maxChangesDetailLength]
+		}
+		var complianceItem = &ssm.ComplianceItemEntry{
+			Id:       aws.String(item.AssociationId + "/" + item.StepName),
+			Status:   aws.String(item.ComplianceStatus),
+			Severity: aws.String(item.ComplianceSeverity),
+			Title:    aws.String(item.StepName),
+			Details: map[string]*string{
+				"AssociationId":   aws.String(item.AssociationId),
+				"DocumentName":    aws.String(item.DocumentName),
+				"DocumentVersion": aws.String(item.DocumentVersion),
+				"ChangeCount":     aws.String(fmt.Sprint(len(item.Changes))),
+				"Changes":         aws.String(changes),
+			},
+		}
+		checkComplianceItems = append(checkComplianceItems, complianceItem)
+	}
+	return checkComplianceItems, newHash, nil
+}
+
 // ConvertToSsmAssociationComplianceItems converts given array of complianceItem into an array of *ssm.ComplianceItemEntry. It returns 2 such arrays - one is optimized array
 // which contains only contentHash for those compliance types where the dataset hasn't changed from previous collection. The other array is non-optimized array
 // which contains both contentHash & content. This is done to avoid iterating over the compliance data twice. It throws error when it encounters error during
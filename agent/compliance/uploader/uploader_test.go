@@ -106,6 +106,34 @@ func TestConvertToSsmComplianceItemAreEqual(t *testing.T) {
 	assert.Equal(t, "1", *ssmComplianceItem.Details["DocumentVersion"])
 }
 
+func TestConvertToSsmCheckComplianceItems(t *testing.T) {
+	c := context.NewMockDefault()
+	u := MockComplianceUploader()
+	items := []*model.CheckComplianceItem{{
+		AssociationId:      "associationId",
+		StepName:           "configureFirewall",
+		ExecutionTime:      time.Now(),
+		DocumentName:       "testDoc",
+		DocumentVersion:    "1",
+		ComplianceSeverity: "UNSPECIFIED",
+		ComplianceStatus:   "NON_COMPLIANT",
+		Changes:            []string{"+ https: Inbound Allow TCP port 443", "- old"},
+	}}
+
+	complianceItems, hash, err := u.ConvertToSsmCheckComplianceItems(c.Log(), items, "RandomHash")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(complianceItems))
+	assert.Equal(t, "associationId/configureFirewall", *complianceItems[0].Id)
+	assert.Equal(t, "NON_COMPLIANT", *complianceItems[0].Status)
+	assert.Equal(t, "2", *complianceItems[0].Details["ChangeCount"])
+	assert.Equal(t, "+ https: Inbound Allow TCP port 443\n- old", *complianceItems[0].Details["Changes"])
+
+	complianceItems, _, err = u.ConvertToSsmCheckComplianceItems(c.Log(), items, hash)
+	assert.Nil(t, err)
+	assert.Empty(t, complianceItems)
+}
+
 func TestConvertToSsmComplianceItems(t *testing.T) {
 
 	var items []*model.AssociationComplianceItem
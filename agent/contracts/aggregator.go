@@ -42,6 +42,11 @@ func prepareRuntimeStatus(log log.T, pluginResult PluginResult) PluginRuntimeSta
 		EndDateTime:    times.ToIso8601UTC(pluginResult.EndDateTime),
 		StandardOutput: pluginResult.StandardOutput,
 		StandardError:  pluginResult.StandardError,
+		ErrorCode:      pluginResult.ErrorCode,
+
+		StandardOutputContinuation: pluginResult.StandardOutputContinuation,
+		StandardErrorContinuation:  pluginResult.StandardErrorContinuation,
+		OutputArtifacts:            pluginResult.OutputArtifacts,
 	}
 
 	if pluginResult.OutputS3BucketName != "" {
@@ -0,0 +1,94 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package contracts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	// CapabilityPluginPrefix prefixes the capabilities naming a plugin, such as plugin:aws:runShellScript
+	CapabilityPluginPrefix = "plugin:"
+	// CapabilitySchemaVersionPrefix prefixes the capabilities naming a document schema version, such as schemaVersion:2.2
+	CapabilitySchemaVersionPrefix = "schemaVersion:"
+	// CapabilityPreconditionPrefix prefixes the capabilities naming a precondition, such as precondition:StringEquals:platformType
+	CapabilityPreconditionPrefix = "precondition:"
+
+	unsupportedFeaturePrefix = "UnsupportedFeature: "
+)
+
+// UnsupportedFeature is the result of a document requiring capabilities this agent does not have.
+type UnsupportedFeature struct {
+	// MissingCapabilities lists the capabilities required by the document and missing from the agent
+	MissingCapabilities []string `json:"missingCapabilities"`
+	// MinimumAgentVersion is the agent version declared by the document, if any
+	MinimumAgentVersion string `json:"minimumAgentVersion,omitempty"`
+	// AgentVersion is the version of this agent
+	AgentVersion string `json:"agentVersion"`
+}
+
+// NewUnsupportedFeature returns the result listing the given missing capabilities, sorted and without duplicates.
+func NewUnsupportedFeature(minimumAgentVersion string, missingCapabilities ...string) *UnsupportedFeature {
+	unique := map[string]struct{}{}
+	for _, capability := range missingCapabilities {
+		unique[capability] = struct{}{}
+	}
+	capabilities := []string{}
+	for capability := range unique {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	return &UnsupportedFeature{
+		MissingCapabilities: capabilities,
+		MinimumAgentVersion: minimumAgentVersion,
+		AgentVersion:        version.Version,
+	}
+}
+
+// Error renders the result as UnsupportedFeature: followed by its json so that callers can parse it back.
+func (u *UnsupportedFeature) Error() string {
+	content, err := jsonutil.Marshal(u)
+	if err != nil {
+		return fmt.Sprintf("%vmissing capabilities %v", unsupportedFeaturePrefix, strings.Join(u.MissingCapabilities, ", "))
+	}
+	return unsupportedFeaturePrefix + content
+}
+
+// ErrorCode classifies the result as ErrorCodeUnsupportedFeature.
+func (u *UnsupportedFeature) ErrorCode() ErrorCode {
+	return ErrorCodeUnsupportedFeature
+}
+
+// PluginCapability returns the capability of running the given plugin.
+func PluginCapability(pluginName string) string {
+	return CapabilityPluginPrefix + pluginName
+}
+
+// SchemaVersionCapability returns the capability of parsing documents with the given schema version.
+func SchemaVersionCapability(schemaVersion string) string {
+	return CapabilitySchemaVersionPrefix + schemaVersion
+}
+
+// PreconditionCapability returns the capability of evaluating the given precondition operator and variable.
+func PreconditionCapability(operator string, variable string) string {
+	if variable == "" {
+		return CapabilityPreconditionPrefix + operator
+	}
+	return CapabilityPreconditionPrefix + operator + ":" + variable
+}
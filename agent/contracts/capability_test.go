@@ -0,0 +1,45 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package contracts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsupportedFeature(t *testing.T) {
+	unsupported := NewUnsupportedFeature("3.1.0.0",
+		PluginCapability("aws:configureThing"),
+		SchemaVersionCapability("3.0"),
+		PluginCapability("aws:configureThing"))
+
+	assert.Equal(t, []string{"plugin:aws:configureThing", "schemaVersion:3.0"}, unsupported.MissingCapabilities)
+	assert.Equal(t, version.Version, unsupported.AgentVersion)
+	assert.Equal(t, ErrorCodeUnsupportedFeature, ErrorCodeOf(unsupported))
+
+	message := unsupported.Error()
+	assert.True(t, strings.HasPrefix(message, "UnsupportedFeature: "))
+	var parsed UnsupportedFeature
+	assert.NoError(t, jsonutil.Unmarshal(strings.TrimPrefix(message, "UnsupportedFeature: "), &parsed))
+	assert.Equal(t, *unsupported, parsed)
+}
+
+func TestPreconditionCapability(t *testing.T) {
+	assert.Equal(t, "precondition:StringLike", PreconditionCapability("StringLike", ""))
+	assert.Equal(t, "precondition:StringEquals:platformType", PreconditionCapability("StringEquals", "platformType"))
+}
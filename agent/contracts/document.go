@@ -36,6 +36,8 @@ const (
 	SendCommandOffline DocumentType = "SendCommandOffline"
 	// CancelCommandOffline represents document type for cancel command received from offline service
 	CancelCommandOffline DocumentType = "CancelCommandOffline"
+	// BootDocument represents document type for a document the agent runs at boot
+	BootDocument DocumentType = "BootDocument"
 )
 
 // PluginState represents information stored as interim state for any plugin
@@ -99,6 +101,8 @@ type DocumentState struct {
 	InstancePluginsInformation []PluginState
 	CancelInformation          CancelCommandInfo
 	IOConfig                   IOConfiguration
+	ConcurrencyGroup           string
+	ResultNotification         ResultNotification
 }
 
 // IsRebootRequired returns if reboot is needed
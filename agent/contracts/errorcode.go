@@ -0,0 +1,215 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package contracts
+
+import (
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// ErrorCode classifies the failure of a plugin so that automation can react to the failure class.
+type ErrorCode string
+
+const (
+	// ErrorCodeNetwork means a remote endpoint could not be reached
+	ErrorCodeNetwork ErrorCode = "Network"
+	// ErrorCodeAuth means the request was rejected because of missing or invalid credentials or permissions
+	ErrorCodeAuth ErrorCode = "Auth"
+	// ErrorCodeThrottling means a service rejected the request because of its rate
+	ErrorCodeThrottling ErrorCode = "Throttling"
+	// ErrorCodeScriptFailure means a script or command run by the plugin exited with a failure
+	ErrorCodeScriptFailure ErrorCode = "ScriptFailure"
+	// ErrorCodeTimeout means the execution did not complete in time
+	ErrorCodeTimeout ErrorCode = "Timeout"
+	// ErrorCodePlatformUnsupported means the plugin or package is not available on this platform
+	ErrorCodePlatformUnsupported ErrorCode = "PlatformUnsupported"
+	// ErrorCodeChecksumMismatch means a downloaded file does not match its expected hash
+	ErrorCodeChecksumMismatch ErrorCode = "ChecksumMismatch"
+	// ErrorCodeArtifactRejected means a configured scanner rejected a downloaded artifact
+	ErrorCodeArtifactRejected ErrorCode = "ArtifactRejected"
+	// ErrorCodeIntegrityFailure means a file extracted from a downloaded artifact does not match the hash its manifest declares
+	ErrorCodeIntegrityFailure ErrorCode = "IntegrityFailure"
+	// ErrorCodeDiskFull means there is not enough disk space left
+	ErrorCodeDiskFull ErrorCode = "DiskFull"
+	// ErrorCodePermissionDenied means the agent is not allowed to access a local resource
+	ErrorCodePermissionDenied ErrorCode = "PermissionDenied"
+	// ErrorCodeNotFound means a requested resource does not exist
+	ErrorCodeNotFound ErrorCode = "NotFound"
+	// ErrorCodeInvalidInput means the document or the plugin input is invalid
+	ErrorCodeInvalidInput ErrorCode = "InvalidInput"
+	// ErrorCodeNotReady means the host did not pass a readiness gate before the document ran
+	ErrorCodeNotReady ErrorCode = "NotReady"
+	// ErrorCodeRebootLimit means a step kept requesting reboots after reaching the reboot limit of the agent
+	ErrorCodeRebootLimit ErrorCode = "RebootLimitExceeded"
+	// ErrorCodeUnsupportedFeature means the document requires a plugin, schema or precondition this agent does not have
+	ErrorCodeUnsupportedFeature ErrorCode = "UnsupportedFeature"
+	// ErrorCodeInternal is used for any other failure
+	ErrorCodeInternal ErrorCode = "Internal"
+)
+
+var throttlingErrorCodes = []string{
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"RequestLimitExceeded",
+	"RequestThrottled",
+	"TooManyRequestsException",
+	"SlowDown",
+}
+
+var authErrorCodes = []string{
+	"AccessDenied",
+	"AccessDeniedException",
+	"UnrecognizedClientException",
+	"InvalidAccessKeyId",
+	"InvalidClientTokenId",
+	"SignatureDoesNotMatch",
+	"ExpiredToken",
+	"ExpiredTokenException",
+	"NoCredentialProviders",
+}
+
+var notFoundErrorCodes = []string{
+	"NoSuchKey",
+	"NoSuchBucket",
+	"NotFound",
+	"ResourceNotFoundException",
+	"InvalidDocument",
+}
+
+// codedError is an error annotated with an ErrorCode
+type codedError struct {
+	error
+	code ErrorCode
+}
+
+// ErrorCode returns the code of the error
+func (e codedError) ErrorCode() ErrorCode {
+	return e.code
+}
+
+// NewCodedError annotates err with code, the message of the error is unchanged.
+func NewCodedError(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return codedError{error: err, code: code}
+}
+
+// ErrorCodeOf returns the code of err. Errors created with NewCodedError keep their code,
+// other errors are classified by their type, falling back to ErrorCodeInternal.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	if coded, ok := err.(interface {
+		ErrorCode() ErrorCode
+	}); ok {
+		return coded.ErrorCode()
+	}
+	if awsErr, ok := err.(awserr.Error); ok {
+		if code := awsErrorCode(awsErr); code != "" {
+			return code
+		}
+	}
+	if netErr, ok := err.(net.Error); ok {
+		if netErr.Timeout() {
+			return ErrorCodeTimeout
+		}
+		return ErrorCodeNetwork
+	}
+	if os.IsPermission(err) {
+		return ErrorCodePermissionDenied
+	}
+	if os.IsNotExist(err) {
+		return ErrorCodeNotFound
+	}
+	if isDiskFull(err) {
+		return ErrorCodeDiskFull
+	}
+	return ErrorCodeInternal
+}
+
+// ResultErrorCode returns the code reported for a plugin completed with status, code being the error code set by the plugin.
+func ResultErrorCode(status ResultStatus, code ErrorCode) ErrorCode {
+	switch status {
+	case ResultStatusTimedOut:
+		return ErrorCodeTimeout
+	case ResultStatusFailed:
+		if code == "" {
+			return ErrorCodeInternal
+		}
+		return code
+	}
+	return ""
+}
+
+func awsErrorCode(awsErr awserr.Error) ErrorCode {
+	if containsCode(throttlingErrorCodes, awsErr.Code()) {
+		return ErrorCodeThrottling
+	}
+	if containsCode(authErrorCodes, awsErr.Code()) {
+		return ErrorCodeAuth
+	}
+	if containsCode(notFoundErrorCodes, awsErr.Code()) {
+		return ErrorCodeNotFound
+	}
+	if requestErr, ok := awsErr.(awserr.RequestFailure); ok {
+		switch code := requestErr.StatusCode(); {
+		case code == 401 || code == 403:
+			return ErrorCodeAuth
+		case code == 404:
+			return ErrorCodeNotFound
+		case code == 429:
+			return ErrorCodeThrottling
+		}
+	}
+	// errors raised while sending the request wrap the underlying network error
+	if awsErr.OrigErr() != nil {
+		if _, ok := awsErr.OrigErr().(net.Error); ok {
+			return ErrorCodeNetwork
+		}
+	}
+	return ""
+}
+
+func containsCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isDiskFull returns true if err was raised because the disk has no space left
+func isDiskFull(err error) bool {
+	switch pathErr := err.(type) {
+	case *os.PathError:
+		err = pathErr.Err
+	case *os.LinkError:
+		err = pathErr.Err
+	case *os.SyscallError:
+		err = pathErr.Err
+	}
+	if err == syscall.ENOSPC {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "no space left on device") || strings.Contains(message, "not enough space on the disk")
+}
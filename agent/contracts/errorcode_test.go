@@ -0,0 +1,60 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package contracts
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected ErrorCode
+	}{
+		{nil, ""},
+		{errors.New("something failed"), ErrorCodeInternal},
+		{NewCodedError(ErrorCodeChecksumMismatch, errors.New("hash mismatch")), ErrorCodeChecksumMismatch},
+		{awserr.New("ThrottlingException", "Rate exceeded", nil), ErrorCodeThrottling},
+		{awserr.New("AccessDeniedException", "not authorized", nil), ErrorCodeAuth},
+		{awserr.NewRequestFailure(awserr.New("Forbidden", "forbidden", nil), 403, "id"), ErrorCodeAuth},
+		{awserr.New("RequestError", "send request failed", &net.OpError{Op: "dial", Err: errors.New("refused")}), ErrorCodeNetwork},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorCodeNetwork},
+		{&os.PathError{Op: "open", Path: "/file", Err: os.ErrPermission}, ErrorCodePermissionDenied},
+		{&os.PathError{Op: "open", Path: "/file", Err: os.ErrNotExist}, ErrorCodeNotFound},
+		{&os.PathError{Op: "write", Path: "/file", Err: syscall.ENOSPC}, ErrorCodeDiskFull},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, ErrorCodeOf(testCase.err), "%v", testCase.err)
+	}
+}
+
+func TestNewCodedErrorKeepsMessage(t *testing.T) {
+	err := NewCodedError(ErrorCodeInvalidInput, errors.New("invalid input"))
+	assert.Equal(t, "invalid input", err.Error())
+	assert.Nil(t, NewCodedError(ErrorCodeInvalidInput, nil))
+}
+
+func TestResultErrorCode(t *testing.T) {
+	assert.Equal(t, ErrorCode(""), ResultErrorCode(ResultStatusSuccess, ""))
+	assert.Equal(t, ErrorCodeTimeout, ResultErrorCode(ResultStatusTimedOut, ErrorCodeScriptFailure))
+	assert.Equal(t, ErrorCodeInternal, ResultErrorCode(ResultStatusFailed, ""))
+	assert.Equal(t, ErrorCodeNetwork, ResultErrorCode(ResultStatusFailed, ErrorCodeNetwork))
+}
@@ -181,6 +181,10 @@ type InstancePluginConfig struct {
 	Settings      interface{}         `json:"settings" yaml:"settings"`
 	Timeout       int                 `json:"timeoutSeconds" yaml:"timeoutSeconds"`
 	Preconditions map[string][]string `json:"precondition" yaml:"precondition"`
+	// Disruptive marks a step that interrupts the workload of the instance, such as a patch or a reboot
+	Disruptive bool `json:"disruptive,omitempty" yaml:"disruptive,omitempty"`
+	// Normalize adapts the line endings and the paths of the content downloaded by the step to the platform
+	Normalize ContentNormalization `json:"normalize,omitempty" yaml:"normalize,omitempty"`
 }
 
 // DocumentContent object which represents ssm document content.
@@ -190,6 +194,27 @@ type DocumentContent struct {
 	RuntimeConfig map[string]*PluginConfig `json:"runtimeConfig" yaml:"runtimeConfig"`
 	MainSteps     []*InstancePluginConfig  `json:"mainSteps" yaml:"mainSteps"`
 	Parameters    map[string]*Parameter    `json:"parameters" yaml:"parameters"`
+	// ConcurrencyGroup serializes the executions of every document declaring the same group
+	ConcurrencyGroup string `json:"concurrencyGroup,omitempty" yaml:"concurrencyGroup,omitempty"`
+	// FilePermissions controls the permissions of the files created by the steps of the document
+	FilePermissions FilePermissionPolicy `json:"filePermissions,omitempty" yaml:"filePermissions,omitempty"`
+	// ResultNotification is where the agent publishes the final result summary of the document
+	ResultNotification ResultNotification `json:"resultNotification,omitempty" yaml:"resultNotification,omitempty"`
+	// MinimumAgentVersion is the oldest agent version having the capabilities required by the document
+	MinimumAgentVersion string `json:"minimumAgentVersion,omitempty" yaml:"minimumAgentVersion,omitempty"`
+}
+
+// ResultNotification is the SNS topic or the SQS queue the final result summary of a document is published to.
+// The instance profile or the managed instance role must allow sns:Publish on the topic or sqs:SendMessage on the
+// queue, and kms:GenerateDataKey and kms:Decrypt on the key of an encrypted topic or queue.
+type ResultNotification struct {
+	SnsTopicArn string `json:"snsTopicArn,omitempty" yaml:"snsTopicArn,omitempty"`
+	SqsQueueUrl string `json:"sqsQueueUrl,omitempty" yaml:"sqsQueueUrl,omitempty"`
+}
+
+// IsEmpty returns true if the result of the document is not published.
+func (n ResultNotification) IsEmpty() bool {
+	return n.SnsTopicArn == "" && n.SqsQueueUrl == ""
 }
 
 // SessionInputs stores session configuration
@@ -199,6 +224,8 @@ type SessionInputs struct {
 	S3EncryptionEnabled         bool   `json:"s3EncryptionEnabled" yaml:"s3EncryptionEnabled"`
 	CloudWatchLogGroupName      string `json:"cloudWatchLogGroupName" yaml:"cloudWatchLogGroupName"`
 	CloudWatchEncryptionEnabled bool   `json:"cloudWatchEncryptionEnabled" yaml:"cloudWatchEncryptionEnabled"`
+	// ReadOnly starts the shell with a read-only view of the filesystem, for the sessions that inspect a host
+	ReadOnly bool `json:"readOnly" yaml:"readOnly"`
 }
 
 // SessionDocumentContent object which represents ssm session content.
@@ -239,6 +266,11 @@ type PluginRuntimeStatus struct {
 	OutputS3KeyPrefix  string       `json:"outputS3KeyPrefix"`
 	StandardOutput     string       `json:"standardOutput"`
 	StandardError      string       `json:"standardError"`
+	ErrorCode          ErrorCode    `json:"errorCode,omitempty"`
+
+	StandardOutputContinuation *OutputContinuation `json:"standardOutputContinuation,omitempty"`
+	StandardErrorContinuation  *OutputContinuation `json:"standardErrorContinuation,omitempty"`
+	OutputArtifacts            *OutputArtifacts    `json:"outputArtifacts,omitempty"`
 }
 
 // AgentConfiguration is a struct that stores information about the agent and instance
@@ -257,4 +289,8 @@ type DocumentResult struct {
 	Status          ResultStatus
 	LastPlugin      string
 	NPlugins        int
+	// ConcurrencyGroup and QueuePosition are set while the document waits for the documents ahead of it in its
+	// concurrency group, such a result is sent before any plugin runs and doesn't report any plugin result.
+	ConcurrencyGroup string
+	QueuePosition    int
 }
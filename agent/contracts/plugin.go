@@ -15,6 +15,9 @@
 package contracts
 
 import (
+	"fmt"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -40,6 +43,47 @@ type PluginResult struct {
 	Error              string       `json:"error"`
 	StandardOutput     string       `json:"standardOutput"`
 	StandardError      string       `json:"standardError"`
+	ErrorCode          ErrorCode    `json:"errorCode,omitempty"`
+
+	StandardOutputContinuation *OutputContinuation `json:"standardOutputContinuation,omitempty"`
+	StandardErrorContinuation  *OutputContinuation `json:"standardErrorContinuation,omitempty"`
+	OutputArtifacts            *OutputArtifacts    `json:"outputArtifacts,omitempty"`
+	// RebootCount is how many times the step requested a reboot to be run again
+	RebootCount int `json:"rebootCount,omitempty"`
+	// CheckReport is what the step would change, it is only set when the step ran in check mode
+	CheckReport *CheckReport `json:"checkReport,omitempty"`
+	// SimulationReport is what the step would do, it is only set when the step ran in simulation
+	SimulationReport *SimulationReport `json:"simulationReport,omitempty"`
+}
+
+// CheckReport is the result of a step run in check mode, which reports the changes the step would make
+// without making them. A step whose plugin doesn't support check mode is skipped and is not Supported.
+type CheckReport struct {
+	Supported bool     `json:"supported"`
+	Changes   []string `json:"changes,omitempty"`
+}
+
+// SimulationReport is the result of a step run in simulation, in which the scripts are not run, the files are not
+// written and the packages are downloaded but not installed. A step whose plugin doesn't support simulation is skipped
+// and is not Supported.
+type SimulationReport struct {
+	Supported bool     `json:"supported"`
+	Trace     []string `json:"trace,omitempty"`
+}
+
+// OutputArtifacts describes the bundle of files a step declared as its output artifacts.
+// Location is the s3 url of the zip bundle and is empty when the bundle was not uploaded.
+type OutputArtifacts struct {
+	Location string   `json:"location"`
+	Files    []string `json:"files"`
+}
+
+// OutputContinuation points to the complete output of a plugin, which is truncated in the result.
+// When PartCount is more than 1 the output is split in objects named Location followed by ".part-00001", ".part-00002" and so on.
+type OutputContinuation struct {
+	PartCount  int    `json:"partCount"`
+	TotalBytes int64  `json:"totalBytes"`
+	Location   string `json:"location"`
 }
 
 // IPlugin is interface for authoring a functionality of work.
@@ -83,6 +127,71 @@ type Configuration struct {
 	CurrentAssociations         []string
 	SessionId                   string
 	ClientId                    string
+	ReadOnlySession             bool
+	FilePermissions             FilePermissionPolicy
+	WorkspaceDirectory          string
+	RebootCount                 int
+	Disruptive                  bool
+	MinimumAgentVersion         string
+	CheckMode                   bool
+	Simulation                  bool
+	Normalize                   ContentNormalization
+	// Elevation is the operation needing root the step performs, such as a package install
+	Elevation string
+}
+
+// FilePermissionPolicy controls the permissions of the files created by the steps of a document.
+type FilePermissionPolicy struct {
+	// Umask is the octal umask, such as "077", of the commands and of the files created on Linux and macOS
+	Umask string `json:"umask,omitempty" yaml:"umask,omitempty"`
+	// RestrictAcl limits the files created on Windows to Administrators and SYSTEM
+	RestrictAcl bool `json:"restrictAcl,omitempty" yaml:"restrictAcl,omitempty"`
+}
+
+// IsEmpty returns true if the files created keep the default permissions.
+func (p FilePermissionPolicy) IsEmpty() bool {
+	return p.Umask == "" && !p.RestrictAcl
+}
+
+// Mask returns the permission bits of the umask, 0 if there is no umask.
+func (p FilePermissionPolicy) Mask() (os.FileMode, error) {
+	if p.Umask == "" {
+		return 0, nil
+	}
+	mask, err := strconv.ParseUint(p.Umask, 8, 32)
+	if err != nil || mask > 0777 {
+		return 0, fmt.Errorf("invalid umask %q, expected an octal value between 000 and 777", p.Umask)
+	}
+	return os.FileMode(mask), nil
+}
+
+// Line endings the content of a step is converted to.
+const (
+	LineEndingsLF     = "lf"
+	LineEndingsCRLF   = "crlf"
+	LineEndingsNative = "native"
+)
+
+// ContentNormalization adapts the scripts and the configuration files downloaded by a step to the platform of the instance.
+type ContentNormalization struct {
+	// LineEndings converts the line endings of the text files to "lf", "crlf" or "native", the line endings of the platform
+	LineEndings string `json:"lineEndings,omitempty" yaml:"lineEndings,omitempty"`
+	// ExpandPaths replaces the path variables of the text files, such as {{path:/opt/app}}, with their value on the platform
+	ExpandPaths bool `json:"expandPaths,omitempty" yaml:"expandPaths,omitempty"`
+}
+
+// IsEmpty returns true if the content is kept as it is.
+func (n ContentNormalization) IsEmpty() bool {
+	return n.LineEndings == "" && !n.ExpandPaths
+}
+
+// Validate returns an error if the line endings are not supported.
+func (n ContentNormalization) Validate() error {
+	switch n.LineEndings {
+	case "", LineEndingsLF, LineEndingsCRLF, LineEndingsNative:
+		return nil
+	}
+	return fmt.Errorf("invalid line endings %q, expected %v, %v or %v", n.LineEndings, LineEndingsLF, LineEndingsCRLF, LineEndingsNative)
 }
 
 // Plugin wraps the plugin configuration and plugin result.
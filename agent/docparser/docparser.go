@@ -23,9 +23,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/parameters"
 	"github.com/aws/amazon-ssm-agent/agent/parameterstore"
 	"github.com/aws/amazon-ssm-agent/agent/updateutil"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
 
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -33,6 +36,13 @@ const (
 	preconditionSchemaVersion string = "2.2"
 )
 
+var (
+	// snsTopicArnPattern matches the ARN of a standard topic, FIFO topics require a message group the agent does not set
+	snsTopicArnPattern = regexp.MustCompile(`^arn:aws[a-z-]*:sns:[a-z0-9-]+:[0-9]{12}:[A-Za-z0-9_-]{1,256}$`)
+	// sqsQueueUrlPattern matches the url of a queue, such as https://sqs.us-east-1.amazonaws.com/123456789012/queue
+	sqsQueueUrlPattern = regexp.MustCompile(`^https://[a-z0-9.-]+/[0-9]{12}/[A-Za-z0-9_-]{1,80}(\.fifo)?$`)
+)
+
 // DocumentParserInfo represents the parsed information from the request
 type DocumentParserInfo struct {
 	OrchestrationDir    string
@@ -43,6 +53,7 @@ type DocumentParserInfo struct {
 	DocumentId          string
 	DefaultWorkingDir   string
 	CloudWatchConfig    contracts.CloudWatchConfiguration
+	ReadOnlySession     bool
 }
 
 // InitializeDocState is a method to obtain the state of the document.
@@ -58,11 +69,23 @@ func InitializeDocState(log log.T,
 	docState.DocumentType = documentType
 	docState.DocumentInformation = docInfo
 	docState.IOConfig = docContent.GetIOConfiguration(parserInfo)
+	docState.ConcurrencyGroup = docContent.GetConcurrencyGroup()
+
+	filePermissions, err := docContent.GetFilePermissions()
+	if err != nil {
+		return
+	}
+	if docState.ResultNotification, err = docContent.GetResultNotification(); err != nil {
+		return
+	}
 
 	pluginInfo, err := docContent.ParseDocument(log, docInfo, parserInfo, params)
 	if err != nil {
 		return
 	}
+	for i := range pluginInfo {
+		pluginInfo[i].Configuration.FilePermissions = filePermissions
+	}
 	docState.InstancePluginsInformation = pluginInfo
 	return docState, nil
 }
@@ -70,6 +93,9 @@ func InitializeDocState(log log.T,
 type IDocumentContent interface {
 	GetSchemaVersion() string
 	GetIOConfiguration(parserInfo DocumentParserInfo) contracts.IOConfiguration
+	GetConcurrencyGroup() string
+	GetFilePermissions() (contracts.FilePermissionPolicy, error)
+	GetResultNotification() (contracts.ResultNotification, error)
 	ParseDocument(log log.T, docInfo contracts.DocumentInfo, parserInfo DocumentParserInfo, params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error)
 }
 
@@ -92,13 +118,47 @@ func (docContent *DocContent) GetIOConfiguration(parserInfo DocumentParserInfo)
 	}
 }
 
+// GetConcurrencyGroup is a method used to get the concurrency group declared by the document
+func (docContent *DocContent) GetConcurrencyGroup() string {
+	return strings.TrimSpace(docContent.ConcurrencyGroup)
+}
+
+// GetFilePermissions is a method used to get the permission policy of the files created by the document
+func (docContent *DocContent) GetFilePermissions() (policy contracts.FilePermissionPolicy, err error) {
+	policy = docContent.FilePermissions
+	policy.Umask = strings.TrimSpace(policy.Umask)
+	if _, err = policy.Mask(); err != nil {
+		return contracts.FilePermissionPolicy{}, err
+	}
+	return policy, nil
+}
+
+// GetResultNotification is a method used to get the SNS topic or the SQS queue the result of the document is published to
+func (docContent *DocContent) GetResultNotification() (notification contracts.ResultNotification, err error) {
+	notification.SnsTopicArn = strings.TrimSpace(docContent.ResultNotification.SnsTopicArn)
+	notification.SqsQueueUrl = strings.TrimSpace(docContent.ResultNotification.SqsQueueUrl)
+	if notification.SnsTopicArn != "" && notification.SqsQueueUrl != "" {
+		return contracts.ResultNotification{}, fmt.Errorf("resultNotification must declare either an SNS topic or an SQS queue")
+	}
+	if notification.SnsTopicArn != "" && !snsTopicArnPattern.MatchString(notification.SnsTopicArn) {
+		return contracts.ResultNotification{}, fmt.Errorf("resultNotification snsTopicArn %v is not the ARN of a standard SNS topic", notification.SnsTopicArn)
+	}
+	if notification.SqsQueueUrl != "" && !sqsQueueUrlPattern.MatchString(notification.SqsQueueUrl) {
+		return contracts.ResultNotification{}, fmt.Errorf("resultNotification sqsQueueUrl %v is not the url of an SQS queue", notification.SqsQueueUrl)
+	}
+	return notification, nil
+}
+
 // ParseDocument is a method used to parse documents that are not received by any service (MDS or State manager)
 func (docContent *DocContent) ParseDocument(log log.T,
 	docInfo contracts.DocumentInfo,
 	parserInfo DocumentParserInfo,
 	params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error) {
 
-	if err = validateSchema(docContent.SchemaVersion); err != nil {
+	if err = validateSchema(docContent.SchemaVersion, docContent.MinimumAgentVersion); err != nil {
+		return
+	}
+	if err = validateMinimumAgentVersion(docContent.MinimumAgentVersion); err != nil {
 		return
 	}
 	if err = getValidatedParameters(log, params, docContent); err != nil {
@@ -122,13 +182,28 @@ func (sessionDocContent *SessionDocContent) GetIOConfiguration(parserInfo Docume
 	}
 }
 
+// GetConcurrencyGroup is a method used to get the concurrency group, sessions never wait for one
+func (sessionDocContent *SessionDocContent) GetConcurrencyGroup() string {
+	return ""
+}
+
+// GetFilePermissions is a method used to get the file permission policy, sessions keep the default permissions
+func (sessionDocContent *SessionDocContent) GetFilePermissions() (contracts.FilePermissionPolicy, error) {
+	return contracts.FilePermissionPolicy{}, nil
+}
+
+// GetResultNotification is a method used to get the result notification, the result of a session is not published
+func (sessionDocContent *SessionDocContent) GetResultNotification() (contracts.ResultNotification, error) {
+	return contracts.ResultNotification{}, nil
+}
+
 // ParseDocument is a method used to parse documents that are not received by any service (MDS or State manager)
 func (sessionDocContent *SessionDocContent) ParseDocument(log log.T,
 	docInfo contracts.DocumentInfo,
 	parserInfo DocumentParserInfo,
 	params map[string]interface{}) (pluginsInfo []contracts.PluginState, err error) {
 
-	return parsePluginStateForStartSession(parserInfo, docInfo.DocumentID, docInfo.ClientId)
+	return parsePluginStateForStartSession(parserInfo, docInfo.DocumentID, docInfo.ClientId, sessionDocContent.Parameters)
 }
 
 // ParseParameters is a method to parse the ssm parameters into a string map interface
@@ -198,6 +273,7 @@ func parsePluginStateForV10Schema(
 			PluginName:              pluginName,
 			PluginID:                pluginName,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			MinimumAgentVersion:     docContent.MinimumAgentVersion,
 		}
 		pluginConfigurations = append(pluginConfigurations, &config)
 	}
@@ -229,6 +305,9 @@ func parsePluginStateForV20Schema(
 	// getPluginConfigurations converts from PluginConfig (structure from the MDS message) to plugin.Configuration (structure expected by the plugin)
 	for _, instancePluginConfig := range docContent.MainSteps {
 		pluginName := instancePluginConfig.Action
+		if err = instancePluginConfig.Normalize.Validate(); err != nil {
+			return pluginsInfo, fmt.Errorf("invalid normalize options of step %v: %v", instancePluginConfig.Name, err)
+		}
 		config := contracts.Configuration{
 			Settings:                instancePluginConfig.Settings,
 			Properties:              instancePluginConfig.Inputs,
@@ -242,6 +321,9 @@ func parsePluginStateForV20Schema(
 			Preconditions:           instancePluginConfig.Preconditions,
 			IsPreconditionEnabled:   isPreconditionEnabled,
 			DefaultWorkingDirectory: defaultWorkingDir,
+			Disruptive:              instancePluginConfig.Disruptive,
+			Normalize:               instancePluginConfig.Normalize,
+			MinimumAgentVersion:     docContent.MinimumAgentVersion,
 		}
 
 		var plugin contracts.PluginState
@@ -257,7 +339,8 @@ func parsePluginStateForV20Schema(
 func parsePluginStateForStartSession(
 	parserInfo DocumentParserInfo,
 	sessionId string,
-	clientId string) (pluginsInfo []contracts.PluginState, err error) {
+	clientId string,
+	parameters map[string]*contracts.Parameter) (pluginsInfo []contracts.PluginState, err error) {
 
 	// getPluginConfigurations converts from PluginConfig (structure from the MGS message) to plugin.Configuration (structure expected by the plugin)
 	pluginName := appconfig.PluginNameStandardStream
@@ -275,6 +358,18 @@ func parsePluginStateForStartSession(
 		ClientId:                    clientId,
 		CloudWatchLogGroup:          parserInfo.CloudWatchConfig.LogGroupName,
 		CloudWatchEncryptionEnabled: parserInfo.CloudWatchConfig.LogGroupEncryptionEnabled,
+		ReadOnlySession:             parserInfo.ReadOnlySession,
+	}
+
+	// session parameters arrive resolved as their default values, hand them to the plugin for the session start hooks
+	if len(parameters) > 0 {
+		properties := make(map[string]interface{})
+		for name, param := range parameters {
+			if param != nil {
+				properties[name] = param.DefaultVal
+			}
+		}
+		config.Properties = properties
 	}
 
 	var plugin contracts.PluginState
@@ -286,14 +381,23 @@ func parsePluginStateForStartSession(
 	return
 }
 
-// validateSchema checks if the document schema version is supported by this agent version
-func validateSchema(documentSchemaVersion string) error {
+// validateSchema checks if the document schema version is supported by this agent version,
+// the returned UnsupportedFeature lists the schema version as missing capability
+func validateSchema(documentSchemaVersion string, minimumAgentVersion string) error {
 	// Check if the document version is supported by this agent version
 	if _, isDocumentVersionSupport := appconfig.SupportedDocumentVersions[documentSchemaVersion]; !isDocumentVersionSupport {
-		errorMsg := fmt.Sprintf(
-			"Document with schema version %s is not supported by this version of ssm agent, please update to latest version",
-			documentSchemaVersion)
-		return fmt.Errorf("%v", errorMsg)
+		return contracts.NewUnsupportedFeature(minimumAgentVersion, contracts.SchemaVersionCapability(documentSchemaVersion))
+	}
+	return nil
+}
+
+// validateMinimumAgentVersion checks that this agent is not older than the minimum agent version declared by the document
+func validateMinimumAgentVersion(minimumAgentVersion string) error {
+	if minimumAgentVersion == "" {
+		return nil
+	}
+	if versionutil.Compare(version.Version, minimumAgentVersion, false) < 0 {
+		return contracts.NewUnsupportedFeature(minimumAgentVersion)
 	}
 	return nil
 }
@@ -158,6 +158,70 @@ func TestInitializeDocState_Valid(t *testing.T) {
 	assert.Equal(t, testWorkingDir, pluginInfo[0].Configuration.DefaultWorkingDirectory)
 	assert.Equal(t, testLogGroupName, docState.IOConfig.CloudWatchConfig.LogGroupName)
 	assert.Equal(t, testLogStreamPrefix, docState.IOConfig.CloudWatchConfig.LogStreamPrefix)
+	assert.Equal(t, "", docState.ConcurrencyGroup)
+
+	testDocContent.ConcurrencyGroup = " db-maintenance "
+	docState, err = InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "db-maintenance", docState.ConcurrencyGroup)
+}
+
+func TestInitializeDocState_FilePermissions(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{OrchestrationDir: testOrchDir}
+
+	var testDocContent DocContent
+	err := json.Unmarshal(loadFile(t, "../runcommand/mds/testdata/validcommand12.json"), &testDocContent)
+	assert.NoError(t, err)
+
+	docState, err := InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.True(t, docState.InstancePluginsInformation[0].Configuration.FilePermissions.IsEmpty())
+
+	testDocContent.FilePermissions = contracts.FilePermissionPolicy{Umask: " 027 ", RestrictAcl: true}
+	docState, err = InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, contracts.FilePermissionPolicy{Umask: "027", RestrictAcl: true}, docState.InstancePluginsInformation[0].Configuration.FilePermissions)
+
+	for _, umask := range []string{"999", "1777", "rwx"} {
+		testDocContent.FilePermissions = contracts.FilePermissionPolicy{Umask: umask}
+		_, err = InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+		assert.Error(t, err, umask)
+	}
+}
+
+func TestInitializeDocState_ResultNotification(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{OrchestrationDir: testOrchDir}
+
+	var testDocContent DocContent
+	err := json.Unmarshal(loadFile(t, "../runcommand/mds/testdata/validcommand12.json"), &testDocContent)
+	assert.NoError(t, err)
+
+	docState, err := InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.True(t, docState.ResultNotification.IsEmpty())
+
+	testDocContent.ResultNotification = contracts.ResultNotification{SnsTopicArn: " arn:aws:sns:us-east-1:123456789012:results "}
+	docState, err = InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:results", docState.ResultNotification.SnsTopicArn)
+
+	testDocContent.ResultNotification = contracts.ResultNotification{SqsQueueUrl: "https://sqs.eu-west-1.amazonaws.com/123456789012/results.fifo"}
+	docState, err = InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://sqs.eu-west-1.amazonaws.com/123456789012/results.fifo", docState.ResultNotification.SqsQueueUrl)
+
+	for _, notification := range []contracts.ResultNotification{
+		{SnsTopicArn: "arn:aws:sns:us-east-1:123456789012:results", SqsQueueUrl: "https://sqs.eu-west-1.amazonaws.com/123456789012/results"},
+		{SnsTopicArn: "arn:aws:sns:us-east-1:123456789012:results.fifo"},
+		{SnsTopicArn: "arn:aws:sqs:us-east-1:123456789012:results"},
+		{SqsQueueUrl: "http://sqs.eu-west-1.amazonaws.com/123456789012/results"},
+	} {
+		testDocContent.ResultNotification = notification
+		_, err = InitializeDocState(mockLog, contracts.SendCommand, &testDocContent, contracts.DocumentInfo{}, testParserInfo, nil)
+		assert.Error(t, err, "%v", notification)
+	}
 }
 
 func TestInitializeDocStateForStartSessionDocument_Valid(t *testing.T) {
@@ -170,6 +234,7 @@ func TestInitializeDocStateForStartSessionDocument_Valid(t *testing.T) {
 		S3Bucket:         testS3Bucket,
 		S3Prefix:         testS3Prefix,
 		CloudWatchConfig: contracts.CloudWatchConfiguration{LogGroupName: testLogGroupName},
+		ReadOnlySession:  true,
 	}
 	sessionDocContent := &SessionDocContent{
 		SchemaVersion: "1.0",
@@ -197,6 +262,7 @@ func TestInitializeDocStateForStartSessionDocument_Valid(t *testing.T) {
 	assert.Equal(t, testSessionId, pluginInfo[0].Configuration.SessionId)
 	assert.Equal(t, testClientId, pluginInfo[0].Configuration.ClientId)
 	assert.Equal(t, testLogGroupName, pluginInfo[0].Configuration.CloudWatchLogGroup)
+	assert.True(t, pluginInfo[0].Configuration.ReadOnlySession)
 	assert.Equal(t, fileutil.BuildPath(testOrchDir, appconfig.PluginNameStandardStream), pluginInfo[0].Configuration.OrchestrationDirectory)
 }
 
@@ -262,7 +328,55 @@ func TestParseDocument_InvalidSchema(t *testing.T) {
 	_, err = testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "Document with schema version 9999.0 is not supported by this version of ssm agent")
+	unsupported, isUnsupported := err.(*contracts.UnsupportedFeature)
+	assert.True(t, isUnsupported)
+	assert.Equal(t, []string{"schemaVersion:9999.0"}, unsupported.MissingCapabilities)
+	assert.Equal(t, contracts.ErrorCodeUnsupportedFeature, contracts.ErrorCodeOf(err))
+}
+
+func TestParseDocument_MinimumAgentVersion(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir: testOrchDir,
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+	}
+	var testDocContent DocContent
+	err := json.Unmarshal(loadFile(t, "../runcommand/mds/testdata/validcommand20.json"), &testDocContent)
+	assert.NoError(t, err)
+
+	testDocContent.MinimumAgentVersion = "1.0.0.0"
+	pluginsInfo, err := testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0.0", pluginsInfo[0].Configuration.MinimumAgentVersion)
+
+	testDocContent.MinimumAgentVersion = "9999.0.0.0"
+	_, err = testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+	unsupported, isUnsupported := err.(*contracts.UnsupportedFeature)
+	assert.True(t, isUnsupported)
+	assert.Equal(t, "9999.0.0.0", unsupported.MinimumAgentVersion)
+	assert.Empty(t, unsupported.MissingCapabilities)
+}
+
+func TestParseDocument_Normalize(t *testing.T) {
+	mockLog := log.NewMockLog()
+	testParserInfo := DocumentParserInfo{
+		OrchestrationDir: testOrchDir,
+		MessageId:        testMessageID,
+		DocumentId:       testDocumentID,
+	}
+	var testDocContent DocContent
+	err := json.Unmarshal(loadFile(t, "../runcommand/mds/testdata/validcommand20.json"), &testDocContent)
+	assert.NoError(t, err)
+
+	testDocContent.MainSteps[0].Normalize = contracts.ContentNormalization{LineEndings: contracts.LineEndingsNative, ExpandPaths: true}
+	pluginsInfo, err := testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, testDocContent.MainSteps[0].Normalize, pluginsInfo[0].Configuration.Normalize)
+
+	testDocContent.MainSteps[0].Normalize.LineEndings = "cr"
+	_, err = testDocContent.ParseDocument(mockLog, contracts.DocumentInfo{}, testParserInfo, nil)
+	assert.Error(t, err)
 }
 
 func TestParseDocument_ValidParameters(t *testing.T) {
@@ -0,0 +1,208 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	ssmsvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+)
+
+// documentVersionPattern matches the numbered versions of a document, the content of such a version never changes
+var documentVersionPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// documentCacheLock serializes the writes and the evictions of the document cache
+var documentCacheLock sync.Mutex
+
+// documentCacheEntry is an SSM document kept by the document cache
+type documentCacheEntry struct {
+	// Hash is the hash the service reported for the document version, it changes with the content of the version
+	Hash string
+	// Checksum is the sha256 of the content, the entry is discarded when the content no longer matches it
+	Checksum string
+	Written  time.Time
+	Document *ssm.GetDocumentOutput
+}
+
+// documentCachingService is an SSM service getting the documents from the document cache when the service reports
+// they did not change, or when the service can't be reached
+type documentCachingService struct {
+	ssmsvc.Service
+	directory  string
+	maxEntries int
+}
+
+// NewDocumentCachingService returns the service getting the SSM documents, such as the documents of aws:runDocument,
+// through the local document cache when it is enabled in the agent configuration. A numbered document version is
+// only downloaded once. The version a document name resolves to, such as its default or latest version, is
+// described first and downloaded only when its hash changed.
+func NewDocumentCachingService(svc ssmsvc.Service) ssmsvc.Service {
+	config, err := appconfig.Config(false)
+	if err != nil || !config.Ssm.DocumentCache.Enabled {
+		return svc
+	}
+	return &documentCachingService{Service: svc, directory: GetDocumentCacheDirectory(), maxEntries: config.Ssm.DocumentCache.MaxEntries}
+}
+
+// GetDocumentCacheDirectory returns the directory of the cached SSM documents
+func GetDocumentCacheDirectory() string {
+	instanceID, _ := platform.InstanceID()
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.DocumentCacheRootDirName)
+}
+
+// GetDocument returns the document from the cache when it is current, or downloads it and caches it. The cached
+// document is also returned when the service can't be reached.
+func (c *documentCachingService) GetDocument(log log.T, docName string, docVersion string) (*ssm.GetDocumentOutput, error) {
+	cached, err := c.read(docName, docVersion)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("Discarding the cached document %v: %v", docName, err)
+	}
+
+	var hash string
+	if documentVersionPattern.MatchString(docVersion) {
+		if cached != nil {
+			log.Debugf("Using the cached document %v version %v", docName, docVersion)
+			return cached.Document, nil
+		}
+	} else if description, err := c.Service.DescribeDocument(log, docName, docVersion); err != nil {
+		log.Debugf("Failed to describe document %v, downloading it: %v", docName, err)
+	} else if description.Document != nil {
+		hash = aws.StringValue(description.Document.Hash)
+		version := aws.StringValue(description.Document.DocumentVersion)
+		if cached != nil && hash != "" && cached.Hash == hash && aws.StringValue(cached.Document.DocumentVersion) == version {
+			log.Debugf("Using the cached document %v version %v, it did not change", docName, version)
+			return cached.Document, nil
+		}
+	}
+
+	response, err := c.Service.GetDocument(log, docName, docVersion)
+	if err != nil {
+		if cached != nil && isServiceUnreachable(err) {
+			log.Warnf("Failed to get document %v, using its version %v cached at %v: %v",
+				docName, aws.StringValue(cached.Document.DocumentVersion), cached.Written.Format(time.RFC3339), err)
+			return cached.Document, nil
+		}
+		return response, err
+	}
+	if err = c.write(docName, docVersion, hash, response); err != nil {
+		log.Warnf("Failed to cache document %v: %v", docName, err)
+	}
+	return response, nil
+}
+
+// isServiceUnreachable returns true if the error is a failure to reach the service rather than the service
+// rejecting the request, a document the service rejects, such as a deleted document, is not run from the cache
+func isServiceUnreachable(err error) bool {
+	if requestFailure, ok := err.(awserr.RequestFailure); ok {
+		return requestFailure.StatusCode() >= 500 || requestFailure.Code() == "ThrottlingException"
+	}
+	return true
+}
+
+// entryPath returns the file of the cached document, the name of a shared document is an ARN
+func (c *documentCachingService) entryPath(docName string, docVersion string) string {
+	key := sha256.Sum256([]byte(docName + "\x00" + docVersion))
+	return filepath.Join(c.directory, hex.EncodeToString(key[:])+".json")
+}
+
+// read returns the cached document, an entry whose content does not match its checksum is deleted
+func (c *documentCachingService) read(docName string, docVersion string) (*documentCacheEntry, error) {
+	path := c.entryPath(docName, docVersion)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry documentCacheEntry
+	if err = json.Unmarshal(content, &entry); err != nil || entry.Document == nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("the cache entry is not valid")
+	}
+	if documentChecksum(entry.Document) != entry.Checksum {
+		os.Remove(path)
+		return nil, fmt.Errorf("the content does not match its checksum")
+	}
+	return &entry, nil
+}
+
+// write caches the document, then removes the least recently written documents above the maximum
+func (c *documentCachingService) write(docName string, docVersion string, hash string, document *ssm.GetDocumentOutput) error {
+	content, err := json.Marshal(documentCacheEntry{Hash: hash, Checksum: documentChecksum(document), Written: time.Now().UTC(), Document: document})
+	if err != nil {
+		return err
+	}
+
+	documentCacheLock.Lock()
+	defer documentCacheLock.Unlock()
+	if err = os.MkdirAll(c.directory, appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	path := c.entryPath(docName, docVersion)
+	// the entry is renamed into place so a reader never sees it partially written
+	temporary := path + ".tmp"
+	if err = ioutil.WriteFile(temporary, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	if err = os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return c.evict()
+}
+
+// evict removes the least recently written documents above the maximum
+func (c *documentCachingService) evict() error {
+	files, err := ioutil.ReadDir(c.directory)
+	if err != nil {
+		return err
+	}
+	var entries []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
+			entries = append(entries, file)
+		}
+	}
+	if len(entries) <= c.maxEntries {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	for _, entry := range entries[:len(entries)-c.maxEntries] {
+		if err = os.Remove(filepath.Join(c.directory, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentChecksum returns the sha256 of the content of the document
+func documentChecksum(document *ssm.GetDocumentOutput) string {
+	checksum := sha256.Sum256([]byte(aws.StringValue(document.Content)))
+	return hex.EncodeToString(checksum[:])
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package docparser
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	ssmsvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDocumentCache returns a document cache in a temporary directory getting the documents from the mock
+func newTestDocumentCache(t *testing.T, maxEntries int) (*documentCachingService, *ssmsvc.Mock, func()) {
+	directory, err := ioutil.TempDir("", "documentcache")
+	assert.NoError(t, err)
+	svc := ssmsvc.NewMockDefault()
+	return &documentCachingService{Service: svc, directory: directory, maxEntries: maxEntries}, svc, func() { os.RemoveAll(directory) }
+}
+
+func testDocument(version string, content string) *ssm.GetDocumentOutput {
+	return &ssm.GetDocumentOutput{Name: aws.String("Deploy"), DocumentVersion: aws.String(version), Content: aws.String(content)}
+}
+
+func testDescription(version string, hash string) *ssm.DescribeDocumentOutput {
+	return &ssm.DescribeDocumentOutput{Document: &ssm.DocumentDescription{DocumentVersion: aws.String(version), Hash: aws.String(hash)}}
+}
+
+func TestDocumentCacheDownloadsNumberedVersionOnce(t *testing.T) {
+	cache, svc, cleanup := newTestDocumentCache(t, 10)
+	defer cleanup()
+	logger := log.NewMockLog()
+	svc.On("GetDocument", logger, "Deploy", "3").Return(testDocument("3", `{"schemaVersion": "2.2"}`), nil).Once()
+
+	for i := 0; i < 2; i++ {
+		document, err := cache.GetDocument(logger, "Deploy", "3")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"schemaVersion": "2.2"}`, *document.Content)
+	}
+	svc.AssertExpectations(t)
+}
+
+func TestDocumentCacheDownloadsChangedVersion(t *testing.T) {
+	cache, svc, cleanup := newTestDocumentCache(t, 10)
+	defer cleanup()
+	logger := log.NewMockLog()
+	svc.On("DescribeDocument", logger, "Deploy", "$LATEST").Return(testDescription("1", "hash1"), nil).Twice()
+	svc.On("GetDocument", logger, "Deploy", "$LATEST").Return(testDocument("1", "v1"), nil).Once()
+
+	for i := 0; i < 2; i++ {
+		document, err := cache.GetDocument(logger, "Deploy", "$LATEST")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", *document.Content)
+	}
+
+	svc.On("DescribeDocument", logger, "Deploy", "$LATEST").Return(testDescription("2", "hash2"), nil).Once()
+	svc.On("GetDocument", logger, "Deploy", "$LATEST").Return(testDocument("2", "v2"), nil).Once()
+	document, err := cache.GetDocument(logger, "Deploy", "$LATEST")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", *document.Content)
+	svc.AssertExpectations(t)
+}
+
+func TestDocumentCacheWhenServiceIsUnreachable(t *testing.T) {
+	cache, svc, cleanup := newTestDocumentCache(t, 10)
+	defer cleanup()
+	logger := log.NewMockLog()
+	assert.NoError(t, cache.write("Deploy", "", "hash1", testDocument("1", "v1")))
+	unreachable := errors.New("dial tcp: i/o timeout")
+	svc.On("DescribeDocument", logger, "Deploy", "").Return((*ssm.DescribeDocumentOutput)(nil), unreachable).Once()
+	svc.On("GetDocument", logger, "Deploy", "").Return((*ssm.GetDocumentOutput)(nil), unreachable).Once()
+
+	document, err := cache.GetDocument(logger, "Deploy", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", *document.Content)
+
+	rejected := awserr.NewRequestFailure(awserr.New(ssm.ErrCodeInvalidDocument, "deleted", nil), 400, "id")
+	svc.On("DescribeDocument", logger, "Deploy", "").Return((*ssm.DescribeDocumentOutput)(nil), rejected).Once()
+	svc.On("GetDocument", logger, "Deploy", "").Return((*ssm.GetDocumentOutput)(nil), rejected).Once()
+
+	_, err = cache.GetDocument(logger, "Deploy", "")
+
+	assert.Error(t, err)
+	svc.AssertExpectations(t)
+}
+
+func TestDocumentCacheDiscardsCorruptEntry(t *testing.T) {
+	cache, _, cleanup := newTestDocumentCache(t, 10)
+	defer cleanup()
+	content, err := json.Marshal(documentCacheEntry{Checksum: documentChecksum(testDocument("3", "v3")), Document: testDocument("3", "tampered")})
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(cache.entryPath("Deploy", "3"), content, 0600))
+
+	_, err = cache.read("Deploy", "3")
+
+	assert.Error(t, err)
+	_, err = os.Stat(cache.entryPath("Deploy", "3"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDocumentCacheEvictsOldestEntries(t *testing.T) {
+	cache, _, cleanup := newTestDocumentCache(t, 2)
+	defer cleanup()
+	written := time.Now().Add(-time.Hour)
+	for _, version := range []string{"1", "2"} {
+		assert.NoError(t, cache.write("Deploy", version, "", testDocument(version, "v"+version)))
+		assert.NoError(t, os.Chtimes(cache.entryPath("Deploy", version), written, written))
+		written = written.Add(time.Minute)
+	}
+
+	assert.NoError(t, cache.write("Deploy", "3", "", testDocument("3", "v3")))
+
+	files, err := ioutil.ReadDir(cache.directory)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	_, err = cache.read("Deploy", "1")
+	assert.True(t, os.IsNotExist(err))
+	_, err = cache.read("Deploy", "3")
+	assert.NoError(t, err)
+}
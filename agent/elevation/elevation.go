@@ -0,0 +1,143 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package elevation runs the operations needing root, such as installing a package or controlling a service, when the
+// agent runs as another user for the security baselines forbidding root daemons. The command of such an operation runs
+// through a setuid helper, such as sudo, and each elevation is appended to an audit log before the command starts.
+// Nothing changes when the agent runs as root or when the rootless operation is disabled in the agent configuration.
+package elevation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Entry is an elevation appended to the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// Helper is the setuid helper the command runs through
+	Helper string `json:"helper"`
+	// User is the id of the user the agent runs as
+	User      string   `json:"user"`
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+}
+
+// auditLock serializes the writes of the entries to the audit log
+var auditLock sync.Mutex
+
+// isRoot returns true if the agent runs as root, replaced in tests
+var isRoot = func() bool {
+	return os.Geteuid() == 0
+}
+
+// Required returns true if the command of the operation must be elevated to run.
+func Required(config appconfig.RootlessCfg, operation string) bool {
+	// the agent always runs as LocalSystem on Windows
+	if !config.Enabled || runtime.GOOS == "windows" || isRoot() {
+		return false
+	}
+	for _, elevated := range config.Operations {
+		if elevated == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Elevate changes the command of the operation to run with the privileges it needs and audits the elevation. The
+// command is left unchanged when it does not need to be elevated. The elevation is refused when it can't be audited.
+func Elevate(log log.T, config appconfig.RootlessCfg, operation string, command *exec.Cmd) error {
+	if !Required(config, operation) {
+		return nil
+	}
+	entry := Entry{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Helper:    config.ElevationHelper,
+		User:      strconv.Itoa(os.Geteuid()),
+		Command:   command.Path,
+		Arguments: command.Args[1:],
+	}
+
+	helper := strings.Fields(config.ElevationHelper)
+	if len(helper) == 0 {
+		return fmt.Errorf("failed to elevate %v: no elevation helper is configured", operation)
+	}
+	helperPath, err := exec.LookPath(helper[0])
+	if err != nil {
+		return fmt.Errorf("failed to elevate %v: elevation helper %v not found: %v", operation, helper[0], err)
+	}
+
+	if err = audit(config.AuditLogPath, entry); err != nil {
+		return fmt.Errorf("failed to audit the elevation of %v, it is refused: %v", operation, err)
+	}
+	log.Infof("Elevating %v %v for %v with %v", entry.Command, strings.Join(entry.Arguments, " "), operation, config.ElevationHelper)
+	args := append([]string{helper[0]}, helper[1:]...)
+	args = append(args, command.Path)
+	command.Args = append(args, command.Args[1:]...)
+	command.Path = helperPath
+	return nil
+}
+
+// Check logs how the operations needing root are run, it is called when the agent starts.
+func Check(log log.T, config appconfig.RootlessCfg) {
+	if !config.Enabled || runtime.GOOS == "windows" {
+		return
+	}
+	if isRoot() {
+		log.Warnf("Rootless operation is enabled but the agent runs as root, the operations are not elevated")
+		return
+	}
+	log.Infof("The agent runs as user %v, %v are elevated with %v and audited to %v",
+		os.Geteuid(), strings.Join(config.Operations, ", "), config.ElevationHelper, config.AuditLogPath)
+	if helper := strings.Fields(config.ElevationHelper); len(helper) > 0 {
+		if _, err := exec.LookPath(helper[0]); err != nil {
+			log.Errorf("The operations needing root will fail, elevation helper %v not found: %v", helper[0], err)
+		}
+	}
+}
+
+// audit appends the entry to the audit log
+func audit(path string, entry Entry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	if err = os.MkdirAll(filepath.Dir(path), appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(content, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package elevation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// rootlessConfig returns a configuration elevating the package installs with env as the helper, auditing to the
+// temporary directory
+func rootlessConfig(t *testing.T) (appconfig.RootlessCfg, func()) {
+	directory, err := ioutil.TempDir("", "elevation")
+	assert.NoError(t, err)
+	isRoot = func() bool { return false }
+	config := appconfig.RootlessCfg{
+		Enabled:         true,
+		ElevationHelper: "env -u SSM_TEST",
+		Operations:      []string{appconfig.ElevationOperationPackageInstall},
+		AuditLogPath:    filepath.Join(directory, "audit", "elevation-audit.log"),
+	}
+	return config, func() {
+		isRoot = func() bool { return os.Geteuid() == 0 }
+		os.RemoveAll(directory)
+	}
+}
+
+func TestElevateWithHelper(t *testing.T) {
+	config, restore := rootlessConfig(t)
+	defer restore()
+	command := exec.Command("/bin/echo", "installed")
+
+	assert.NoError(t, Elevate(log.NewMockLog(), config, appconfig.ElevationOperationPackageInstall, command))
+
+	helper, _ := exec.LookPath("env")
+	assert.Equal(t, helper, command.Path)
+	assert.Equal(t, []string{"env", "-u", "SSM_TEST", "/bin/echo", "installed"}, command.Args)
+	content, err := ioutil.ReadFile(config.AuditLogPath)
+	assert.NoError(t, err)
+	var entry Entry
+	assert.NoError(t, json.Unmarshal(content, &entry))
+	assert.Equal(t, appconfig.ElevationOperationPackageInstall, entry.Operation)
+	assert.Equal(t, "env -u SSM_TEST", entry.Helper)
+	assert.Equal(t, "/bin/echo", entry.Command)
+	assert.Equal(t, []string{"installed"}, entry.Arguments)
+}
+
+func TestElevateLeavesOtherOperations(t *testing.T) {
+	config, restore := rootlessConfig(t)
+	defer restore()
+	command := exec.Command("/bin/echo", "restarted")
+
+	assert.NoError(t, Elevate(log.NewMockLog(), config, appconfig.ElevationOperationServiceControl, command))
+	config.Enabled = false
+	assert.NoError(t, Elevate(log.NewMockLog(), config, appconfig.ElevationOperationPackageInstall, command))
+
+	assert.Equal(t, []string{"/bin/echo", "restarted"}, command.Args)
+	_, err := os.Stat(config.AuditLogPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestElevateRefusedWithoutAudit(t *testing.T) {
+	config, restore := rootlessConfig(t)
+	defer restore()
+	assert.NoError(t, os.MkdirAll(config.AuditLogPath, appconfig.ReadWriteExecuteAccess))
+	command := exec.Command("/bin/echo", "installed")
+
+	assert.Error(t, Elevate(log.NewMockLog(), config, appconfig.ElevationOperationPackageInstall, command))
+
+	assert.Equal(t, []string{"/bin/echo", "installed"}, command.Args)
+}
+
+func TestElevateWithMissingHelper(t *testing.T) {
+	config, restore := rootlessConfig(t)
+	defer restore()
+	config.ElevationHelper = "/nonexistent/helper"
+
+	assert.Error(t, Elevate(log.NewMockLog(), config, appconfig.ElevationOperationPackageInstall, exec.Command("/bin/echo")))
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package errordedup deduplicates the errors the agent reports to the service, so that a systemic failure, for
+// example an s3 bucket that denies the uploads of every step, is not reported thousands of times.
+// The errors are grouped by their fingerprint, the message with its ids and numbers masked, and every group is
+// reported a limited number of times by window. The suppressed occurrences are counted and summarized with the
+// detail of their first and last occurrence.
+package errordedup
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+const (
+	// maxGroups bounds the number of fingerprints tracked by a deduplicator, further errors are reported
+	maxGroups = 1000
+	// maxFingerprintLength is the length of the fingerprints, longer messages are grouped by their beginning
+	maxFingerprintLength = 256
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	idPattern     = regexp.MustCompile(`(?i)\b[a-z]+-[0-9a-f]{8,}\b`)
+	hexPattern    = regexp.MustCompile(`(?i)\b[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*\b|\b[0-9a-f]*[a-f][0-9a-f]*[0-9][0-9a-f]*\b`)
+	numberPattern = regexp.MustCompile(`\b\d+\b`)
+)
+
+var (
+	configLock sync.Mutex
+	config     = appconfig.ErrorReportingCfg{
+		MaxReportsPerError: appconfig.DefaultErrorReportsPerWindow,
+		WindowSeconds:      appconfig.DefaultErrorReportingWindowSeconds,
+	}
+)
+
+// Configure sets the limits of the deduplicators created afterwards, the defaults of the agent configuration
+// apply until then
+func Configure(errorReporting appconfig.ErrorReportingCfg) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	config = errorReporting
+}
+
+// Summary is a group of suppressed errors
+type Summary struct {
+	Fingerprint string
+	// Count is the number of occurrences that were not reported
+	Count        int64
+	First        time.Time
+	Last         time.Time
+	FirstMessage string
+	LastMessage  string
+}
+
+// group tracks the reports of the errors of a fingerprint
+type group struct {
+	windowStart time.Time
+	reported    int
+	suppressed  Summary
+}
+
+// Deduplicator limits the reports of every error and summarizes the suppressed ones
+type Deduplicator struct {
+	mutex      sync.Mutex
+	maxReports int
+	window     time.Duration
+	groups     map[string]*group
+}
+
+// New returns a deduplicator with the limits of the configuration
+func New() *Deduplicator {
+	configLock.Lock()
+	defer configLock.Unlock()
+	return &Deduplicator{
+		maxReports: config.MaxReportsPerError,
+		window:     time.Duration(config.WindowSeconds) * time.Second,
+		groups:     make(map[string]*group),
+	}
+}
+
+// Report records an occurrence of an error and returns true if it is reported, false if it is suppressed
+func (d *Deduplicator) Report(message string, now time.Time) bool {
+	fingerprint := Fingerprint(message)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	g, found := d.groups[fingerprint]
+	if !found {
+		if len(d.groups) >= maxGroups && !d.evict(now) {
+			return true
+		}
+		g = &group{windowStart: now}
+		d.groups[fingerprint] = g
+	}
+	if now.Sub(g.windowStart) >= d.window {
+		g.windowStart = now
+		g.reported = 0
+	}
+	if g.reported < d.maxReports {
+		g.reported++
+		return true
+	}
+	if g.suppressed.Count == 0 {
+		g.suppressed = Summary{Fingerprint: fingerprint, First: now, FirstMessage: message}
+	}
+	g.suppressed.Count++
+	g.suppressed.Last = now
+	g.suppressed.LastMessage = message
+	return false
+}
+
+// Flush returns the errors suppressed since the last flush ordered by their first occurrence
+func (d *Deduplicator) Flush() []Summary {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var summaries []Summary
+	for _, g := range d.groups {
+		if g.suppressed.Count > 0 {
+			summaries = append(summaries, g.suppressed)
+			g.suppressed = Summary{}
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].First.Before(summaries[j].First) })
+	return summaries
+}
+
+// evict removes the groups whose window ended without suppressed errors and returns whether there is room for a group
+func (d *Deduplicator) evict(now time.Time) bool {
+	for fingerprint, g := range d.groups {
+		if now.Sub(g.windowStart) >= d.window && g.suppressed.Count == 0 {
+			delete(d.groups, fingerprint)
+		}
+	}
+	return len(d.groups) < maxGroups
+}
+
+// Fingerprint returns the message of an error with its uuids, resource ids, hexadecimal and decimal numbers masked
+func Fingerprint(message string) string {
+	fingerprint := uuidPattern.ReplaceAllString(message, "<uuid>")
+	fingerprint = idPattern.ReplaceAllString(fingerprint, "<id>")
+	fingerprint = hexPattern.ReplaceAllString(fingerprint, "<hex>")
+	fingerprint = numberPattern.ReplaceAllString(fingerprint, "<n>")
+	if len(fingerprint) > maxFingerprintLength {
+		fingerprint = fingerprint[:maxFingerprintLength]
+	}
+	return fingerprint
+}
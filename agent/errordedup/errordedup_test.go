@@ -0,0 +1,92 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package errordedup
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDeduplicator(maxReports int, windowSeconds int) *Deduplicator {
+	Configure(appconfig.ErrorReportingCfg{MaxReportsPerError: maxReports, WindowSeconds: windowSeconds})
+	defer Configure(appconfig.ErrorReportingCfg{
+		MaxReportsPerError: appconfig.DefaultErrorReportsPerWindow,
+		WindowSeconds:      appconfig.DefaultErrorReportingWindowSeconds,
+	})
+	return New()
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t,
+		"AccessDenied: access denied to s3://bucket/<uuid>/<id>/stdout, status code: <n>, request id: <hex>",
+		Fingerprint("AccessDenied: access denied to s3://bucket/5e2a4b0c-9d6f-4c47-8d1e-2f1c3b4a5d6e/i-0123456789abcdef0/stdout, status code: 403, request id: 7B2D1F8E9A3C4D5E"))
+	assert.Equal(t, Fingerprint("exit status 1"), Fingerprint("exit status 127"))
+	assert.NotEqual(t, Fingerprint("exit status 1"), Fingerprint("timed out"))
+}
+
+func TestReportLimitsAndSummarizesAnError(t *testing.T) {
+	d := newTestDeduplicator(2, 60)
+	start := time.Now()
+
+	var reported int
+	for i := 0; i < 10; i++ {
+		if d.Report(fmt.Sprintf("failed to upload s3://bucket/cmd-%08d/stdout: AccessDenied", i), start.Add(time.Duration(i)*time.Second)) {
+			reported++
+		}
+	}
+	assert.True(t, d.Report("exit status 1", start))
+	assert.Equal(t, 2, reported)
+
+	summaries := d.Flush()
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, Summary{
+		Fingerprint:  "failed to upload s3://bucket/<id>/stdout: AccessDenied",
+		Count:        8,
+		First:        start.Add(2 * time.Second),
+		Last:         start.Add(9 * time.Second),
+		FirstMessage: "failed to upload s3://bucket/cmd-00000002/stdout: AccessDenied",
+		LastMessage:  "failed to upload s3://bucket/cmd-00000009/stdout: AccessDenied",
+	}, summaries[0])
+	assert.Empty(t, d.Flush())
+}
+
+func TestReportAgainInTheNextWindow(t *testing.T) {
+	d := newTestDeduplicator(1, 60)
+	start := time.Now()
+
+	assert.True(t, d.Report("AccessDenied", start))
+	assert.False(t, d.Report("AccessDenied", start.Add(59*time.Second)))
+	assert.True(t, d.Report("AccessDenied", start.Add(60*time.Second)))
+	assert.False(t, d.Report("AccessDenied", start.Add(61*time.Second)))
+	summaries := d.Flush()
+	assert.Len(t, summaries, 1)
+	assert.Equal(t, int64(2), summaries[0].Count)
+}
+
+func TestReportBeyondTheTrackedErrors(t *testing.T) {
+	d := newTestDeduplicator(1, 60)
+	start := time.Now()
+	for i := 0; i < maxGroups; i++ {
+		d.Report(fmt.Sprintf("error %c%c", 'a'+i%26, 'a'+i/26), start)
+	}
+
+	assert.True(t, d.Report("untracked", start))
+	assert.True(t, d.Report("untracked", start))
+	assert.False(t, d.Report("error aa", start), "the tracked errors are still limited")
+	assert.True(t, d.Report("tracked once the window ended", start.Add(time.Minute)))
+}
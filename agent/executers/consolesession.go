@@ -0,0 +1,65 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package executers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// screenshotTimeoutSeconds bounds the capture of the console screenshot
+const screenshotTimeoutSeconds = 60
+
+// screenshotScript saves the whole virtual screen of the session it runs in as a png image
+const screenshotScript = `Add-Type -AssemblyName System.Windows.Forms, System.Drawing
+$bounds = [System.Windows.Forms.SystemInformation]::VirtualScreen
+$bitmap = New-Object System.Drawing.Bitmap $bounds.Width, $bounds.Height
+$graphics = [System.Drawing.Graphics]::FromImage($bitmap)
+$graphics.CopyFromScreen($bounds.Location, [System.Drawing.Point]::Empty, $bounds.Size)
+$bitmap.Save('%v', [System.Drawing.Imaging.ImageFormat]::Png)
+$graphics.Dispose()
+$bitmap.Dispose()`
+
+// CaptureConsoleScreenshot saves a png screenshot of the console session to the given path,
+// it fails when no user is logged on to the console.
+func CaptureConsoleScreenshot(log log.T, screenshotPath string) error {
+	// complete the flag so the goroutine waiting for a cancel returns with the capture
+	cancelFlag := task.NewChanneledCancelFlag()
+	defer cancelFlag.Set(task.Completed)
+
+	var stderr bytes.Buffer
+	script := fmt.Sprintf(screenshotScript, strings.Replace(screenshotPath, "'", "''", -1))
+	exitCode, err := executeCommand(
+		log,
+		cancelFlag,
+		"",
+		&bytes.Buffer{},
+		&stderr,
+		screenshotTimeoutSeconds,
+		ExecuteOptions{ConsoleSession: true},
+		appconfig.PowerShellPluginCommandName,
+		[]string{"-NoProfile", "-NonInteractive", "-Command", script})
+	if err != nil {
+		return fmt.Errorf("failed to capture the console screenshot: %v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to capture the console screenshot, exit code %v: %v", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package executers
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// noConsoleSession is returned by WTSGetActiveConsoleSessionId while the console is attached to no session
+const noConsoleSession = 0xFFFFFFFF
+
+var (
+	wtsapi32                         = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSQueryUserToken            = wtsapi32.NewProc("WTSQueryUserToken")
+	procWTSGetActiveConsoleSessionId = kernel32.NewProc("WTSGetActiveConsoleSessionId")
+)
+
+// prepareConsoleSession makes the command run with the token of the user logged on to the console session,
+// the process is then created in that session and attached to its interactive desktop instead of the
+// non interactive session 0 of the agent service. The returned function closes the token once the command ended.
+func prepareConsoleSession(command *exec.Cmd) (release func(), err error) {
+	sessionID, _, _ := procWTSGetActiveConsoleSessionId.Call()
+	if uint32(sessionID) == noConsoleSession {
+		return nil, fmt.Errorf("no session is attached to the console")
+	}
+	var token syscall.Token
+	if ret, _, callErr := procWTSQueryUserToken.Call(sessionID, uintptr(unsafe.Pointer(&token))); ret == 0 {
+		return nil, fmt.Errorf("no user is logged on to the console session %v: %v", uint32(sessionID), callErr)
+	}
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.Token = token
+	return func() { token.Close() }, nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package executers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	minPort = 1
+	maxPort = 65535
+
+	// EgressLogPrefix prefixes the kernel log entries of the connections refused by an egress policy
+	EgressLogPrefix = "ssm-egress-denied"
+	// egressLogRate bounds the kernel log entries of an egress policy, the refused connections are still counted
+	egressLogRate = "10/second"
+)
+
+// EgressRules are the network destinations the processes of a command may connect to.
+// A destination in Deny is always refused. When Allow or Ports are set, any other destination is refused unless
+// it is in Allow, any address when Allow is empty, on one of the Ports, any port when Ports is empty.
+type EgressRules struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+	Ports []int
+}
+
+// IsEmpty returns true if the rules do not restrict any destination.
+func (r EgressRules) IsEmpty() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0 && len(r.Ports) == 0
+}
+
+// restrictive returns true if the destinations that are not allowed are refused.
+func (r EgressRules) restrictive() bool {
+	return len(r.Allow) > 0 || len(r.Ports) > 0
+}
+
+// ParseEgressRules parses the allowed and denied CIDRs, a single address is a /32 or /128 network.
+func ParseEgressRules(allow []string, deny []string, ports []int) (rules EgressRules, err error) {
+	if rules.Allow, err = parseNetworks(allow); err != nil {
+		return
+	}
+	if rules.Deny, err = parseNetworks(deny); err != nil {
+		return
+	}
+	for _, port := range ports {
+		if port < minPort || port > maxPort {
+			return rules, fmt.Errorf("port %v should be between %v and %v", port, minPort, maxPort)
+		}
+		rules.Ports = append(rules.Ports, port)
+	}
+	return rules, nil
+}
+
+// parseNetworks parses CIDRs and single addresses, ignoring the empty ones.
+func parseNetworks(values []string) (networks []*net.IPNet, err error) {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// egressFilterName returns the name of the nftables table and of the cgroup of a command, unique for its key.
+func egressFilterName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("ssm_egress_%x", sum[:6])
+}
+
+// egressRuleset returns the nftables table applying the rules to the sockets of the processes in a cgroup v2.
+// The cgroup is given by its path relative to the root of the cgroup v2 hierarchy.
+func egressRuleset(name string, cgroupPath string, rules EgressRules) string {
+	cgroupPath = strings.Trim(cgroupPath, "/")
+	var ruleset strings.Builder
+	fmt.Fprintf(&ruleset, "table inet %s {\n", name)
+	ruleset.WriteString("\tcounter denied {\n\t}\n")
+	ruleset.WriteString("\tchain output {\n\t\ttype filter hook output priority 0; policy accept;\n")
+	fmt.Fprintf(&ruleset, "\t\tsocket cgroupv2 level %d %q jump step\n\t}\n", strings.Count(cgroupPath, "/")+1, cgroupPath)
+
+	ruleset.WriteString("\tchain deny {\n")
+	fmt.Fprintf(&ruleset, "\t\tlimit rate %s log prefix \"%s %s: \"\n", egressLogRate, EgressLogPrefix, name)
+	ruleset.WriteString("\t\tcounter name \"denied\" reject with icmpx type admin-prohibited\n\t}\n")
+
+	ruleset.WriteString("\tchain step {\n\t\tct state established,related accept\n")
+	deny4, deny6 := splitNetworks(rules.Deny)
+	if len(deny4) > 0 {
+		fmt.Fprintf(&ruleset, "\t\tip daddr { %s } jump deny\n", strings.Join(deny4, ", "))
+	}
+	if len(deny6) > 0 {
+		fmt.Fprintf(&ruleset, "\t\tip6 daddr { %s } jump deny\n", strings.Join(deny6, ", "))
+	}
+	if rules.restrictive() {
+		ports := ""
+		if len(rules.Ports) > 0 {
+			values := make([]string, len(rules.Ports))
+			for i, port := range rules.Ports {
+				values[i] = fmt.Sprint(port)
+			}
+			ports = fmt.Sprintf(" meta l4proto { tcp, udp } th dport { %s }", strings.Join(values, ", "))
+		}
+		allow4, allow6 := splitNetworks(rules.Allow)
+		if len(rules.Allow) == 0 {
+			fmt.Fprintf(&ruleset, "\t\t%s accept\n", strings.TrimSpace(ports))
+		}
+		if len(allow4) > 0 {
+			fmt.Fprintf(&ruleset, "\t\tip daddr { %s }%s accept\n", strings.Join(allow4, ", "), ports)
+		}
+		if len(allow6) > 0 {
+			fmt.Fprintf(&ruleset, "\t\tip6 daddr { %s }%s accept\n", strings.Join(allow6, ", "), ports)
+		}
+		ruleset.WriteString("\t\tjump deny\n")
+	}
+	ruleset.WriteString("\t}\n}\n")
+	return ruleset.String()
+}
+
+// splitNetworks returns the IPv4 and the IPv6 networks in CIDR notation.
+func splitNetworks(networks []*net.IPNet) (ipv4 []string, ipv6 []string) {
+	for _, network := range networks {
+		if network.IP.To4() != nil {
+			ipv4 = append(ipv4, network.String())
+		} else {
+			ipv6 = append(ipv6, network.String())
+		}
+	}
+	return
+}
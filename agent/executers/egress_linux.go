@@ -0,0 +1,172 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package executers
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	nftCommand = "nft"
+	// cgroupReleaseAttempts is how many times the cgroup of a command is removed while its last processes exit
+	cgroupReleaseAttempts = 10
+)
+
+var deniedPacketsPattern = regexp.MustCompile(`packets (\d+)`)
+
+// runNft runs nft with the given standard input
+var runNft = func(input string, args ...string) (string, error) {
+	cmd := exec.Command(nftCommand, args...)
+	cmd.Stdin = strings.NewReader(input)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+var (
+	mountInfoPath = "/proc/self/mountinfo"
+	cgroupPath    = "/proc/self/cgroup"
+)
+
+// EgressFilter confines the network connections of a command and all its children to egress rules while it is open.
+// The command runs in a dedicated cgroup v2 whose sockets are filtered by a temporary nftables table, the refused
+// connections are logged to the kernel log and counted.
+type EgressFilter struct {
+	name       string
+	cgroupDir  string
+	cgroupPath string
+}
+
+// NewEgressFilter creates the cgroup and the nftables table of a command, key identifies the command.
+func NewEgressFilter(log log.T, key string, rules EgressRules) (filter *EgressFilter, err error) {
+	root, err := cgroup2Root()
+	if err != nil {
+		return nil, err
+	}
+	parent, err := ownCgroup()
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(parent, "'") {
+		return nil, fmt.Errorf("unsupported cgroup %q", parent)
+	}
+	name := egressFilterName(key)
+	filter = &EgressFilter{
+		name:       name,
+		cgroupPath: filepath.Join(parent, name),
+		cgroupDir:  filepath.Join(root, parent, name),
+	}
+	if err = os.Mkdir(filter.cgroupDir, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("failed to create the cgroup of the egress policy: %v", err)
+	}
+	if _, err = runNft(egressRuleset(name, filter.cgroupPath, rules), "-f", "-"); err != nil {
+		os.Remove(filter.cgroupDir)
+		return nil, fmt.Errorf("failed to apply the egress policy: %v", err)
+	}
+	log.Infof("Applied the egress policy %v to cgroup %v", name, filter.cgroupPath)
+	return filter, nil
+}
+
+// Wrap runs the command through sh which moves itself to the cgroup of the filter before it starts the command.
+func (f *EgressFilter) Wrap(commandName string, commandArguments []string) (string, []string) {
+	script := fmt.Sprintf(`echo $$ > '%s' && exec "$0" "$@"`, filepath.Join(f.cgroupDir, "cgroup.procs"))
+	return "/bin/sh", append([]string{"-c", script, commandName}, commandArguments...)
+}
+
+// Close stops the processes left in the cgroup of the filter, then removes the cgroup and the nftables table.
+// It returns how many packets the policy refused.
+func (f *EgressFilter) Close(log log.T) (denied int, err error) {
+	if out, err := runNft("", "list", "counter", "inet", f.name, "denied"); err != nil {
+		log.Warnf("Failed to read the refused connections of egress policy %v: %v", f.name, err)
+	} else if match := deniedPacketsPattern.FindStringSubmatch(out); match != nil {
+		denied, _ = strconv.Atoi(match[1])
+	}
+
+	// the processes left behind by the command are stopped before the table is deleted, or they would escape the policy
+	if err = f.releaseCgroup(); err != nil {
+		return denied, err
+	}
+	if _, err = runNft("", "delete", "table", "inet", f.name); err != nil {
+		return denied, fmt.Errorf("failed to delete egress policy %v: %v", f.name, err)
+	}
+	return denied, nil
+}
+
+// releaseCgroup kills the processes of the cgroup and removes it.
+func (f *EgressFilter) releaseCgroup() (err error) {
+	for attempt := 0; attempt < cgroupReleaseAttempts; attempt++ {
+		if err = os.Remove(f.cgroupDir); err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if content, readErr := ioutil.ReadFile(filepath.Join(f.cgroupDir, "cgroup.procs")); readErr == nil {
+			for _, field := range strings.Fields(string(content)) {
+				if pid, convErr := strconv.Atoi(field); convErr == nil {
+					syscall.Kill(pid, syscall.SIGKILL)
+				}
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("failed to remove the cgroup of egress policy %v: %v", f.name, err)
+}
+
+// cgroup2Root returns the mount point of the cgroup v2 hierarchy.
+func cgroup2Root() (string, error) {
+	file, err := os.Open(mountInfoPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// the fields after the " - " separator are the filesystem type, the source and the super block options
+		parts := strings.SplitN(scanner.Text(), " - ", 2)
+		fields := strings.Fields(parts[0])
+		if len(parts) == 2 && len(fields) > 4 && strings.HasPrefix(parts[1], "cgroup2 ") {
+			return fields[4], nil
+		}
+	}
+	return "", fmt.Errorf("egress policies require the cgroup v2 hierarchy to be mounted")
+}
+
+// ownCgroup returns the cgroup v2 of the agent, relative to the root of the hierarchy.
+func ownCgroup() (string, error) {
+	content, err := ioutil.ReadFile(cgroupPath)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimSuffix(strings.TrimPrefix(line, "0::"), " (deleted)"), nil
+		}
+	}
+	return "", fmt.Errorf("the agent does not run in a cgroup v2")
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package executers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEgressFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "egress")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(mountInfo string, cgroup string, nft func(string, ...string) (string, error)) {
+		mountInfoPath, cgroupPath, runNft = mountInfo, cgroup, nft
+	}(mountInfoPath, cgroupPath, runNft)
+
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	cgroupPath = filepath.Join(dir, "cgroup")
+	root := filepath.Join(dir, "cgroup2")
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "system.slice"), 0755))
+	assert.NoError(t, ioutil.WriteFile(mountInfoPath, []byte("30 24 0:26 / "+root+" rw,nosuid - cgroup2 cgroup2 rw\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(cgroupPath, []byte("0::/system.slice\n"), 0644))
+	var nftCalls []string
+	runNft = func(input string, args ...string) (string, error) {
+		nftCalls = append(nftCalls, strings.Join(args, " "))
+		if args[0] == "list" {
+			return "counter denied {\n\tpackets 3 bytes 180\n}\n", nil
+		}
+		return "", nil
+	}
+	rules, _ := ParseEgressRules(nil, []string{"169.254.169.254"}, nil)
+
+	filter, err := NewEgressFilter(log.NewMockLog(), "orchestration/step", rules)
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(root, "system.slice", filter.name))
+	assert.NoError(t, err)
+	name, args := filter.Wrap("sh", []string{"script.sh"})
+	assert.Equal(t, "/bin/sh", name)
+	assert.Contains(t, args[1], filepath.Join(root, "system.slice", filter.name, "cgroup.procs"))
+	assert.Equal(t, []string{"sh", "script.sh"}, args[2:])
+
+	denied, err := filter.Close(log.NewMockLog())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, denied)
+	_, err = os.Stat(filepath.Join(root, "system.slice", filter.name))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, []string{"-f -", "list counter inet " + filter.name + " denied", "delete table inet " + filter.name}, nftCalls)
+}
+
+func TestEgressFilterRequiresCgroup2(t *testing.T) {
+	dir, err := ioutil.TempDir("", "egress")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(mountInfo string) { mountInfoPath = mountInfo }(mountInfoPath)
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	assert.NoError(t, ioutil.WriteFile(mountInfoPath, []byte("30 24 0:26 / /sys/fs/cgroup/memory rw - cgroup cgroup rw,memory\n"), 0644))
+
+	_, err = NewEgressFilter(log.NewMockLog(), "step", EgressRules{})
+
+	assert.EqualError(t, err, "egress policies require the cgroup v2 hierarchy to be mounted")
+}
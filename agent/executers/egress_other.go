@@ -0,0 +1,41 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build !linux
+
+package executers
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// EgressFilter is only implemented on linux, where the connections of a command are filtered by its cgroup
+type EgressFilter struct{}
+
+// NewEgressFilter fails, the command would otherwise run without the egress policy it requires.
+func NewEgressFilter(log log.T, key string, rules EgressRules) (*EgressFilter, error) {
+	return nil, fmt.Errorf("egress policies are not supported on %v", runtime.GOOS)
+}
+
+// Wrap returns the command as is.
+func (f *EgressFilter) Wrap(commandName string, commandArguments []string) (string, []string) {
+	return commandName, commandArguments
+}
+
+// Close does nothing.
+func (f *EgressFilter) Close(log log.T) (int, error) {
+	return 0, nil
+}
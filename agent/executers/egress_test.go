@@ -0,0 +1,64 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package executers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEgressRules(t *testing.T) {
+	rules, err := ParseEgressRules([]string{"10.0.0.0/8", " 2001:db8::1 ", ""}, []string{"169.254.169.254"}, []int{443})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", rules.Allow[0].String())
+	assert.Equal(t, "2001:db8::1/128", rules.Allow[1].String())
+	assert.Equal(t, "169.254.169.254/32", rules.Deny[0].String())
+	assert.Equal(t, []int{443}, rules.Ports)
+
+	_, err = ParseEgressRules([]string{"example.com"}, nil, nil)
+	assert.Error(t, err)
+	_, err = ParseEgressRules(nil, nil, []int{70000})
+	assert.Error(t, err)
+}
+
+func TestEgressRulesetDenyOnly(t *testing.T) {
+	rules, _ := ParseEgressRules(nil, []string{"169.254.169.254"}, nil)
+
+	ruleset := egressRuleset("ssm_egress_test", "/system.slice/amazon-ssm-agent.service/ssm_egress_test", rules)
+
+	assert.Contains(t, ruleset, `socket cgroupv2 level 3 "system.slice/amazon-ssm-agent.service/ssm_egress_test" jump step`)
+	assert.Contains(t, ruleset, "ip daddr { 169.254.169.254/32 } jump deny\n\t}")
+	assert.NotContains(t, ruleset, "ip6 daddr")
+}
+
+func TestEgressRulesetAllowList(t *testing.T) {
+	rules, _ := ParseEgressRules([]string{"10.0.0.0/8", "2001:db8::/32"}, nil, []int{443, 53})
+
+	ruleset := egressRuleset("ssm_egress_test", "ssm_egress_test", rules)
+
+	assert.Contains(t, ruleset, `socket cgroupv2 level 1 "ssm_egress_test" jump step`)
+	assert.Contains(t, ruleset, "ip daddr { 10.0.0.0/8 } meta l4proto { tcp, udp } th dport { 443, 53 } accept")
+	assert.Contains(t, ruleset, "ip6 daddr { 2001:db8::/32 } meta l4proto { tcp, udp } th dport { 443, 53 } accept")
+	assert.Contains(t, ruleset, "\t\tjump deny\n\t}\n}\n")
+	assert.Contains(t, ruleset, `log prefix "ssm-egress-denied ssm_egress_test: "`)
+}
+
+func TestEgressRulesetPortsOnly(t *testing.T) {
+	rules, _ := ParseEgressRules(nil, nil, []int{443})
+
+	ruleset := egressRuleset("ssm_egress_test", "ssm_egress_test", rules)
+
+	assert.Contains(t, ruleset, "\t\tmeta l4proto { tcp, udp } th dport { 443 } accept\n\t\tjump deny\n")
+}
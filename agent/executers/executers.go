@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -29,6 +30,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
@@ -36,6 +38,19 @@ const (
 	// envVar* constants are names of environment variables set for processes executed by ssm agent and should start with AWS_SSM_
 	envVarInstanceID = "AWS_SSM_INSTANCE_ID"
 	envVarRegionName = "AWS_SSM_REGION_NAME"
+	envVarWorkspace  = "AWS_SSM_WORKSPACE"
+	// a script creates the file named by envVarRebootMarker to request a reboot after which it runs again,
+	// envVarRebootCount tells it how many reboots it requested so far
+	envVarRebootMarker = "AWS_SSM_REBOOT_MARKER"
+	envVarRebootCount  = "AWS_SSM_REBOOT_COUNT"
+	// envVarCheckMode tells a script it runs in check mode, it writes the changes it would make to the file
+	// named by envVarCheckChanges, one change per line, instead of making them
+	envVarCheckMode    = "AWS_SSM_CHECK_MODE"
+	envVarCheckChanges = "AWS_SSM_CHECK_CHANGES"
+	// the standard credential variables of the AWS SDKs and CLI, which take precedence over the role of the instance
+	envVarAccessKeyID     = "AWS_ACCESS_KEY_ID"
+	envVarSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
+	envVarSessionToken    = "AWS_SESSION_TOKEN"
 )
 
 // T is the interface type for ShellCommandExecuter.
@@ -43,9 +58,50 @@ type T interface {
 	//TODO: Remove Execute and rename NewExecute to Execute.
 	Execute(log.T, string, string, string, task.CancelFlag, int, string, []string) (io.Reader, io.Reader, int, []error)
 	NewExecute(log.T, string, io.Writer, io.Writer, task.CancelFlag, int, string, []string) (int, error)
+	NewExecuteWithOptions(log.T, string, io.Writer, io.Writer, task.CancelFlag, int, ExecuteOptions, string, []string) (int, error)
 	StartExe(log.T, string, io.Writer, io.Writer, task.CancelFlag, string, []string) (*os.Process, int, error)
 }
 
+// ExecuteOptions holds the optional settings of a command execution.
+type ExecuteOptions struct {
+	// Stdin is streamed to the standard input of the command, which reads from the null device when it is nil
+	Stdin io.Reader
+	// PromptPolicy tells how the interactive prompts of the command are handled
+	PromptPolicy PromptPolicy
+	// Workspace is the ephemeral workspace directory of the document, exposed to the command as AWS_SSM_WORKSPACE
+	Workspace string
+	// RebootMarker is the file the command creates to request a reboot, exposed to it as AWS_SSM_REBOOT_MARKER
+	RebootMarker string
+	// RebootCount is how many reboots the step requested before this run, exposed as AWS_SSM_REBOOT_COUNT
+	RebootCount int
+	// CheckMode tells the command to report the changes it would make without making them, exposed as AWS_SSM_CHECK_MODE
+	CheckMode bool
+	// CheckChanges is the file the command writes the changes it would make to, exposed as AWS_SSM_CHECK_CHANGES
+	CheckChanges string
+	// Simulation writes the command that would run to the standard output instead of running it
+	Simulation bool
+	// ConsoleSession runs the command as the user logged on to the console of a Windows instance, in their desktop
+	ConsoleSession bool
+	// Priority is the priority class and cpu affinity of the process of the command on Windows, the command is
+	// wrapped with WithProcessPriority on other platforms
+	Priority ProcessPriority
+	// OnTimeout is called when the command timed out, before it is stopped
+	OnTimeout func()
+	// Elevation is the operation needing root the command performs, such as a package install, the command is elevated
+	// and audited when the agent runs rootless
+	Elevation string
+	// Credentials are the temporary credentials of the command, exposed as AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+	// and AWS_SESSION_TOKEN so the AWS SDKs and CLI use them rather than the role of the instance
+	Credentials *Credentials
+}
+
+// Credentials are temporary AWS credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
 // ShellCommandExecuter is specially added for testing purposes
 type ShellCommandExecuter struct {
 }
@@ -173,6 +229,28 @@ func (ShellCommandExecuter) NewExecute(
 	return
 }
 
+// NewExecuteWithOptions executes a list of shell commands like NewExecute, streaming the input given in the options
+// to the commands and handling their interactive prompts according to the prompt policy of the options.
+func (ShellCommandExecuter) NewExecuteWithOptions(
+	log log.T,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	cancelFlag task.CancelFlag,
+	executionTimeout int,
+	options ExecuteOptions,
+	commandName string,
+	commandArguments []string,
+) (exitCode int, err error) {
+	if options.Simulation {
+		log.Infof("Simulating %v %v", commandName, strings.Join(commandArguments, " "))
+		_, err = fmt.Fprintf(stdoutWriter, "[simulation] %v %v in %v\n", commandName, strings.Join(commandArguments, " "), workingDir)
+		return appconfig.SuccessExitCode, err
+	}
+	exitCode, err = executeCommand(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, options, commandName, commandArguments)
+	return
+}
+
 // StartExe starts a list of shell commands in the given working directory.
 // Returns process started, an exit code (0 if successfully launch, 1 if error launching process), and a set of errors.
 // The errors need not be fatal - the output streams may still have data
@@ -254,18 +332,49 @@ func ExecuteCommand(log log.T,
 	commandName string,
 	commandArguments []string,
 ) (exitCode int, err error) {
+	return executeCommand(log, cancelFlag, workingDir, stdoutWriter, stderrWriter, executionTimeout, ExecuteOptions{}, commandName, commandArguments)
+}
+
+// executeCommand executes the given commands with the input and the prompt policy of the options.
+func executeCommand(log log.T,
+	cancelFlag task.CancelFlag,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	executionTimeout int,
+	options ExecuteOptions,
+	commandName string,
+	commandArguments []string,
+) (exitCode int, err error) {
 
 	stdoutInterruptable, stopStdout := newWriter(stdoutWriter)
 	stderrInterruptable, stopStderr := newWriter(stderrWriter)
 
 	command := exec.Command(commandName, commandArguments...)
-	command.Dir = workingDir
+	command.Dir = fileutil.ShortPath(workingDir)
 	exitCode = 0
 
 	// If we assign the writers directly, the command may never exit even though a command.Process.Wait() does due to https://github.com/golang/go/issues/13155
 	// However, if we run goroutines to copy from the StdoutPipe and StderrPipe we may lose the last write.
 	command.Stdout = stdoutInterruptable
 	command.Stderr = stderrInterruptable
+
+	// Without input nor prompt policy the command reads from the null device, otherwise it gets a stdin that is
+	// written with the input and then to answer its prompts
+	var stdin io.WriteCloser
+	var detector *promptDetector
+	if options.PromptPolicy.enabled() {
+		detector = newPromptDetector()
+		command.Stdout = detector.wrap(stdoutInterruptable)
+		command.Stderr = detector.wrap(stderrInterruptable)
+	}
+	if options.Stdin != nil || detector != nil {
+		if stdin, err = command.StdinPipe(); err != nil {
+			log.Error("error occurred creating the stdin of the command", err)
+			exitCode = 1
+			return
+		}
+	}
 	/*
 		stdoutPipe, err := command.StdoutPipe()
 		if err != nil {
@@ -281,9 +390,27 @@ func ExecuteCommand(log log.T,
 
 	// configure OS-specific process settings
 	prepareProcess(command)
+	prepareProcessPriority(command, options.Priority)
+	if options.ConsoleSession {
+		var release func()
+		if release, err = prepareConsoleSession(command); err != nil {
+			log.Error("error occurred preparing the console session of the command", err)
+			exitCode = 1
+			return
+		}
+		defer release()
+	}
+	if options.Elevation != "" {
+		if err = elevateCommand(log, options.Elevation, command); err != nil {
+			log.Error("error occurred elevating the command", err)
+			exitCode = 1
+			return
+		}
+	}
 
 	// configure environment variables
 	prepareEnvironment(command)
+	prepareOptionsEnvironment(command, options)
 
 	log.Debug()
 	log.Debugf("Running in directory %v, command: %v %v", workingDir, commandName, commandArguments)
@@ -293,6 +420,13 @@ func ExecuteCommand(log log.T,
 		exitCode = 1
 		return
 	}
+	if err = applyProcessPriority(command.Process, options.Priority); err != nil {
+		log.Error("error occurred applying the priority of the command", err)
+		command.Process.Kill()
+		command.Wait()
+		exitCode = 1
+		return
+	}
 
 	signal := timeoutSignal{}
 
@@ -306,6 +440,13 @@ func ExecuteCommand(log log.T,
 		log.Debugf("Cancel flag set to %v", cancelState)
 	}()
 
+	prompted := make(chan string, 1)
+	if stdin != nil {
+		stopWatch := make(chan bool)
+		defer close(stopWatch)
+		go feedStdin(log, stdin, options, detector, stopWatch, prompted)
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		done <- command.Wait()
@@ -313,6 +454,9 @@ func ExecuteCommand(log log.T,
 
 	select {
 	case <-time.After(time.Duration(executionTimeout) * time.Second):
+		if options.OnTimeout != nil {
+			options.OnTimeout()
+		}
 		stopStdout <- true
 		stopStderr <- true
 		if err = killProcess(command.Process, &signal); err != nil {
@@ -338,6 +482,17 @@ func ExecuteCommand(log log.T,
 			err = &exec.ExitError{Stderr: []byte("Cancelled process")}
 			log.Infof("The execution of command was cancelled.")
 		}
+	case prompt := <-prompted:
+		// the command waits for input nobody will give, stop it instead of waiting for the timeout
+		log.Infof("The command is waiting for input at prompt %q. Attempting to stop process.", prompt)
+		stopStdout <- true
+		stopStderr <- true
+		if err = killProcess(command.Process, &signal); err != nil {
+			log.Error(err)
+		} else {
+			err = &InteractivePromptError{Prompt: prompt}
+		}
+		exitCode = 1
 	case err = <-done:
 		log.Debug("Process completed.")
 		if err != nil {
@@ -391,7 +546,7 @@ func StartCommand(log log.T,
 ) (process *os.Process, exitCode int, err error) {
 
 	command := exec.Command(commandName, commandArguments...)
-	command.Dir = workingDir
+	command.Dir = fileutil.ShortPath(workingDir)
 	command.Stdout = stdoutWriter
 	command.Stderr = stderrWriter
 	exitCode = 0
@@ -422,6 +577,22 @@ func StartCommand(log log.T,
 	return
 }
 
+// feedStdin streams the input of the command to its stdin, then answers its prompts when it has a prompt policy.
+// Without prompt policy the stdin is closed once the input is written so that the command reads its end.
+func feedStdin(log log.T, stdin io.WriteCloser, options ExecuteOptions, detector *promptDetector, stop <-chan bool, prompted chan<- string) {
+	if options.Stdin != nil {
+		// the copy stops when the command exits, whether it read the whole input or not
+		if _, err := io.Copy(stdin, options.Stdin); err != nil {
+			log.Debugf("stopped writing the input of the command: %v", err)
+		}
+	}
+	if detector == nil {
+		stdin.Close()
+		return
+	}
+	detector.watch(log, options.PromptPolicy, stdin, stop, prompted)
+}
+
 // killProcessOnCancel waits for a cancel request.
 // If a cancel request is received, this method kills the underlying
 // process of the command. This will unblock the command.Wait() call.
@@ -454,12 +625,34 @@ func prepareEnvironment(command *exec.Cmd) {
 	if region, err := instance.Region(); err == nil {
 		env = append(env, fmtEnvVariable(envVarRegionName, region))
 	}
-	command.Env = env
+	command.Env = network.AppendProxyEnvironment(env)
 
 	// Running powershell on linux erquired the HOME env variable to be set and to remove the TERM env variable
 	validateEnvironmentVariables(command)
 }
 
+// prepareOptionsEnvironment exposes the workspace, the reboot marker, the check mode and the credentials of the options to the command
+func prepareOptionsEnvironment(command *exec.Cmd, options ExecuteOptions) {
+	if options.Workspace != "" {
+		command.Env = append(command.Env, fmtEnvVariable(envVarWorkspace, options.Workspace))
+	}
+	if options.RebootMarker != "" {
+		command.Env = append(command.Env, fmtEnvVariable(envVarRebootMarker, options.RebootMarker))
+		command.Env = append(command.Env, fmtEnvVariable(envVarRebootCount, strconv.Itoa(options.RebootCount)))
+	}
+	if options.CheckMode {
+		command.Env = append(command.Env, fmtEnvVariable(envVarCheckMode, "true"))
+		command.Env = append(command.Env, fmtEnvVariable(envVarCheckChanges, options.CheckChanges))
+	}
+	if options.Credentials != nil {
+		// appended last, the credentials replace any credentials the agent inherited from its environment
+		command.Env = append(command.Env,
+			fmtEnvVariable(envVarAccessKeyID, options.Credentials.AccessKeyID),
+			fmtEnvVariable(envVarSecretAccessKey, options.Credentials.SecretAccessKey),
+			fmtEnvVariable(envVarSessionToken, options.Credentials.SessionToken))
+	}
+}
+
 // fmtEnvVariable creates the string to append to the current set of environment variables.
 func fmtEnvVariable(name string, val string) string {
 	return fmt.Sprintf("%s=%s", name, val)
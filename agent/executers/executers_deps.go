@@ -16,11 +16,22 @@
 package executers
 
 import (
+	"os/exec"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/elevation"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 )
 
 var instance instanceInfo = &instanceInfoImp{}
 
+// elevateCommand elevates the command of an operation needing root as the agent configuration tells
+var elevateCommand = func(log log.T, operation string, command *exec.Cmd) error {
+	config, _ := appconfig.Config(false)
+	return elevation.Elevate(log, config.Rootless, operation, command)
+}
+
 // system represents the dependency for platform
 type instanceInfo interface {
 	InstanceID() (string, error)
@@ -90,6 +90,33 @@ func TestEnvironmentVariables_None(t *testing.T) {
 	assert.Empty(t, getEnvVariableValue(command.Env, envVarRegionName))
 }
 
+func TestPrepareOptionsEnvironment(t *testing.T) {
+	command := getTestCommand(t)
+	prepareOptionsEnvironment(command, ExecuteOptions{})
+	assert.Empty(t, getEnvVariableValue(command.Env, envVarWorkspace))
+	assert.Empty(t, getEnvVariableValue(command.Env, envVarRebootMarker))
+	assert.Empty(t, getEnvVariableValue(command.Env, envVarRebootCount))
+	assert.Empty(t, getEnvVariableValue(command.Env, envVarCheckMode))
+	assert.Empty(t, getEnvVariableValue(command.Env, envVarSessionToken))
+
+	prepareOptionsEnvironment(command, ExecuteOptions{
+		Workspace:    "/tmp/ssm-workspace-1234",
+		RebootMarker: "/var/lib/amazon/ssm/orchestration/0.awsrunShellScript/reboot-requested",
+		RebootCount:  2,
+		CheckMode:    true,
+		CheckChanges: "/var/lib/amazon/ssm/orchestration/0.awsrunShellScript/check-changes",
+		Credentials:  &Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"},
+	})
+	assert.Equal(t, "/tmp/ssm-workspace-1234", getEnvVariableValue(command.Env, envVarWorkspace))
+	assert.Equal(t, "/var/lib/amazon/ssm/orchestration/0.awsrunShellScript/reboot-requested", getEnvVariableValue(command.Env, envVarRebootMarker))
+	assert.Equal(t, "2", getEnvVariableValue(command.Env, envVarRebootCount))
+	assert.Equal(t, "true", getEnvVariableValue(command.Env, envVarCheckMode))
+	assert.Equal(t, "/var/lib/amazon/ssm/orchestration/0.awsrunShellScript/check-changes", getEnvVariableValue(command.Env, envVarCheckChanges))
+	assert.Equal(t, "ASIAEXAMPLE", getEnvVariableValue(command.Env, envVarAccessKeyID))
+	assert.Equal(t, "secret", getEnvVariableValue(command.Env, envVarSecretAccessKey))
+	assert.Equal(t, "token", getEnvVariableValue(command.Env, envVarSessionToken))
+}
+
 func TestQuoteShString(t *testing.T) {
 	var result string
 
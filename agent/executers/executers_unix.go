@@ -16,6 +16,7 @@
 package executers
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -64,3 +65,17 @@ func validateEnvironmentVariables(command *exec.Cmd) {
 		command.Env = env
 	}
 }
+
+// WithUmask runs the command through sh which sets the umask of the files created by the command and its children.
+func WithUmask(umask os.FileMode, commandName string, commandArguments []string) (string, []string) {
+	if umask == 0 {
+		return commandName, commandArguments
+	}
+	script := fmt.Sprintf(`umask %04o && exec "$0" "$@"`, umask)
+	return "/bin/sh", append([]string{"-c", script, commandName}, commandArguments...)
+}
+
+// prepareConsoleSession fails, only Windows instances have a console session with a desktop to run the command in.
+func prepareConsoleSession(command *exec.Cmd) (release func(), err error) {
+	return nil, fmt.Errorf("running a command in the console session is only supported on Windows")
+}
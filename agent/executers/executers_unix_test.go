@@ -0,0 +1,35 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package executers
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithUmask(t *testing.T) {
+	commandName, commandArguments := WithUmask(0, "sh", []string{"-c", "umask"})
+	assert.Equal(t, "sh", commandName)
+	assert.Equal(t, []string{"-c", "umask"}, commandArguments)
+
+	commandName, commandArguments = WithUmask(0027, "sh", []string{"-c", "umask"})
+	output, err := exec.Command(commandName, commandArguments...).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "0027", strings.TrimSpace(string(output)))
+}
@@ -18,6 +18,7 @@ package executers
 import (
 	"os"
 	"os/exec"
+	"strconv"
 )
 
 const (
@@ -32,9 +33,18 @@ func killProcess(process *os.Process, signal *timeoutSignal) error {
 	// process kill doesn't send proper signal to the process status
 	// Setting the signal to indicate execution was interrupted
 	signal.execInterruptedOnWindows = true
+	// kill the descendants of the process with it, like the kill of the process group on unix
+	if err := exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(process.Pid)).Run(); err == nil {
+		return nil
+	}
 	return process.Kill()
 }
 
 // Running powershell on linux required the HOME env variable to be set and to remove the TERM env variable
 func validateEnvironmentVariables(command *exec.Cmd) {
 }
+
+// WithUmask returns the command as is, there is no umask on windows, the files inherit the ACL of their directory.
+func WithUmask(umask os.FileMode, commandName string, commandArguments []string) (string, []string) {
+	return commandName, commandArguments
+}
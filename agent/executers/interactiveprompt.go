@@ -0,0 +1,193 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// maxPromptLength is the longest tail of an output line kept to report the prompt
+const maxPromptLength = 256
+
+// promptCheckInterval is how often a running command is checked for a pending prompt
+var promptCheckInterval = time.Second
+
+// PromptPolicy describes how a command that stops to prompt for input is handled.
+// The zero value leaves the command without stdin, as the agent always did.
+type PromptPolicy struct {
+	// Action is one of appconfig.InteractivePromptAction*
+	Action string
+	// Responses are the answers written to stdin, one per detected prompt
+	Responses []string
+	// IdleTimeout is how long the command has to wait without output after a prompt
+	IdleTimeout time.Duration
+}
+
+// InteractivePromptError is returned when a command is stopped because it waits for input.
+type InteractivePromptError struct {
+	Prompt string
+}
+
+func (e *InteractivePromptError) Error() string {
+	return fmt.Sprintf("interactive prompt detected: %q", e.Prompt)
+}
+
+// NewPromptPolicy builds the prompt policy from the agent configuration, reading the response file when needed.
+func NewPromptPolicy(cfg appconfig.InteractivePromptCfg) (policy PromptPolicy, err error) {
+	policy = PromptPolicy{
+		Action:      cfg.Action,
+		IdleTimeout: time.Duration(cfg.IdleSeconds) * time.Second,
+	}
+	if policy.Action != appconfig.InteractivePromptActionRespond {
+		return
+	}
+	if cfg.ResponseFile == "" {
+		return policy, fmt.Errorf("no response file configured to answer interactive prompts")
+	}
+	content, err := ioutil.ReadFile(cfg.ResponseFile)
+	if err != nil {
+		return policy, fmt.Errorf("failed to read the prompt response file %v: %v", cfg.ResponseFile, err)
+	}
+	lines := strings.Split(strings.Replace(string(content), "\r\n", "\n", -1), "\n")
+	// a trailing newline does not start another response
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	policy.Responses = lines
+	return
+}
+
+// enabled returns true if the command gets a stdin and is watched for prompts.
+func (p PromptPolicy) enabled() bool {
+	return (p.Action == appconfig.InteractivePromptActionFail || p.Action == appconfig.InteractivePromptActionRespond) &&
+		p.IdleTimeout > 0
+}
+
+// promptDetector tracks the output of a command to tell when it is waiting for input.
+// A command is considered prompting when it has been quiet for the idle timeout and its last output is
+// an unterminated line or a question.
+type promptDetector struct {
+	mutex      sync.Mutex
+	lastOutput time.Time
+	lastStream *promptStream
+}
+
+// promptStream is the state of one output stream of the command
+type promptStream struct {
+	detector *promptDetector
+	writer   io.Writer
+	// partial is the tail of the line being written
+	partial []byte
+	// lastLine is the last complete line
+	lastLine string
+}
+
+func newPromptDetector() *promptDetector {
+	return &promptDetector{lastOutput: time.Now()}
+}
+
+// wrap returns a writer recording the output written to writer.
+func (d *promptDetector) wrap(writer io.Writer) io.Writer {
+	return &promptStream{detector: d, writer: writer}
+}
+
+func (s *promptStream) Write(p []byte) (n int, err error) {
+	n, err = s.writer.Write(p)
+	if n <= 0 {
+		return
+	}
+	written := p[:n]
+
+	s.detector.mutex.Lock()
+	defer s.detector.mutex.Unlock()
+	s.detector.lastOutput = time.Now()
+	s.detector.lastStream = s
+	if i := bytes.LastIndexByte(written, '\n'); i >= 0 {
+		lines := append(s.partial, written[:i]...)
+		if j := bytes.LastIndexByte(lines, '\n'); j >= 0 {
+			lines = lines[j+1:]
+		}
+		s.lastLine = strings.TrimSpace(string(lines))
+		s.partial = append([]byte(nil), written[i+1:]...)
+	} else {
+		s.partial = append(s.partial, written...)
+	}
+	if len(s.partial) > maxPromptLength {
+		s.partial = s.partial[len(s.partial)-maxPromptLength:]
+	}
+	return
+}
+
+// pendingPrompt returns the prompt the command is waiting on, if it has been quiet for idleTimeout.
+func (d *promptDetector) pendingPrompt(idleTimeout time.Duration) (prompt string, prompting bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.lastStream == nil || time.Since(d.lastOutput) < idleTimeout {
+		return "", false
+	}
+	if prompt = strings.TrimSpace(string(d.lastStream.partial)); prompt != "" {
+		return prompt, true
+	}
+	if strings.HasSuffix(d.lastStream.lastLine, "?") {
+		return d.lastStream.lastLine, true
+	}
+	return "", false
+}
+
+// answered forgets the current prompt so that only new output can be detected as the next prompt.
+func (d *promptDetector) answered() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.lastOutput = time.Now()
+	d.lastStream = nil
+}
+
+// watch checks the command for prompts until stop is closed.
+// Prompts are answered from the responses of the policy, the first one that can't be answered is sent to detected.
+func (d *promptDetector) watch(log log.T, policy PromptPolicy, stdin io.Writer, stop <-chan bool, detected chan<- string) {
+	ticker := time.NewTicker(promptCheckInterval)
+	defer ticker.Stop()
+	responses := policy.Responses
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			prompt, prompting := d.pendingPrompt(policy.IdleTimeout)
+			if !prompting {
+				continue
+			}
+			if policy.Action != appconfig.InteractivePromptActionRespond || len(responses) == 0 {
+				detected <- prompt
+				return
+			}
+			log.Infof("Answering interactive prompt %q", prompt)
+			d.answered()
+			if _, err := io.WriteString(stdin, responses[0]+"\n"); err != nil {
+				log.Warnf("failed to answer interactive prompt: %v", err)
+			}
+			responses = responses[1:]
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package executers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPromptPolicy(t *testing.T) {
+	policy, err := NewPromptPolicy(appconfig.InteractivePromptCfg{Action: appconfig.InteractivePromptActionNone, IdleSeconds: 30})
+	assert.NoError(t, err)
+	assert.False(t, policy.enabled())
+
+	policy, err = NewPromptPolicy(appconfig.InteractivePromptCfg{Action: appconfig.InteractivePromptActionFail, IdleSeconds: 30})
+	assert.NoError(t, err)
+	assert.True(t, policy.enabled())
+	assert.Equal(t, 30*time.Second, policy.IdleTimeout)
+
+	_, err = NewPromptPolicy(appconfig.InteractivePromptCfg{Action: appconfig.InteractivePromptActionRespond, IdleSeconds: 30})
+	assert.Error(t, err)
+
+	dir, err := ioutil.TempDir("", "prompt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	responseFile := filepath.Join(dir, "responses")
+	assert.NoError(t, ioutil.WriteFile(responseFile, []byte("yes\r\n\nadmin\n"), 0600))
+
+	policy, err = NewPromptPolicy(appconfig.InteractivePromptCfg{Action: appconfig.InteractivePromptActionRespond, ResponseFile: responseFile, IdleSeconds: 30})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"yes", "", "admin"}, policy.Responses)
+}
+
+func TestPromptDetector(t *testing.T) {
+	detector := newPromptDetector()
+	stdout := detector.wrap(ioutil.Discard)
+	stderr := detector.wrap(ioutil.Discard)
+
+	// no output yet
+	_, prompting := detector.pendingPrompt(0)
+	assert.False(t, prompting)
+
+	// complete lines are not prompts
+	stdout.Write([]byte("Installing\nDone\n"))
+	_, prompting = detector.pendingPrompt(0)
+	assert.False(t, prompting)
+
+	// an unterminated line is a prompt once the command is quiet
+	stderr.Write([]byte("Continue? "))
+	stderr.Write([]byte("[y/N] "))
+	_, prompting = detector.pendingPrompt(time.Hour)
+	assert.False(t, prompting)
+	prompt, prompting := detector.pendingPrompt(0)
+	assert.True(t, prompting)
+	assert.Equal(t, "Continue? [y/N]", prompt)
+
+	// answered prompts are forgotten
+	detector.answered()
+	_, prompting = detector.pendingPrompt(0)
+	assert.False(t, prompting)
+
+	// so are questions written on their own line
+	stdout.Write([]byte("Enter the password\nDo you want to proceed?\n"))
+	prompt, prompting = detector.pendingPrompt(0)
+	assert.True(t, prompting)
+	assert.Equal(t, "Do you want to proceed?", prompt)
+}
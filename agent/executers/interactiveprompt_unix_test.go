@@ -0,0 +1,102 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package executers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+const promptScript = `printf "Continue? [y/N] "; read answer; echo "answer $answer"; printf "Name: "; read name; echo "name $name"`
+
+// useFastPromptChecks checks for prompts often and stubs the instance lookups of the environment of the commands
+func useFastPromptChecks() func() {
+	previousInterval, previousInstance := promptCheckInterval, instance
+	promptCheckInterval = 10 * time.Millisecond
+	instance = &instanceInfoStub{instanceID: testInstanceID, regionName: testRegionName}
+	return func() { promptCheckInterval, instance = previousInterval, previousInstance }
+}
+
+func TestExecuteCommandFailsOnPrompt(t *testing.T) {
+	defer useFastPromptChecks()()
+	policy := PromptPolicy{Action: appconfig.InteractivePromptActionFail, IdleTimeout: 200 * time.Millisecond}
+	var stdout, stderr bytes.Buffer
+
+	start := time.Now()
+	exitCode, err := executeCommand(log.NewMockLog(), task.NewChanneledCancelFlag(), "", &stdout, &stderr, 30, ExecuteOptions{PromptPolicy: policy}, "sh", []string{"-c", promptScript})
+
+	assert.Equal(t, 1, exitCode)
+	assert.IsType(t, &InteractivePromptError{}, err)
+	assert.Equal(t, "interactive prompt detected: \"Continue? [y/N]\"", err.Error())
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
+
+func TestExecuteCommandAnswersPrompts(t *testing.T) {
+	defer useFastPromptChecks()()
+	policy := PromptPolicy{Action: appconfig.InteractivePromptActionRespond, Responses: []string{"y", "admin"}, IdleTimeout: 200 * time.Millisecond}
+	var stdout, stderr bytes.Buffer
+
+	exitCode, err := executeCommand(log.NewMockLog(), task.NewChanneledCancelFlag(), "", &stdout, &stderr, 30, ExecuteOptions{PromptPolicy: policy}, "sh", []string{"-c", promptScript})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "Continue? [y/N] answer y\nName: name admin\n", stdout.String())
+}
+
+func TestExecuteCommandFailsWhenResponsesRunOut(t *testing.T) {
+	defer useFastPromptChecks()()
+	policy := PromptPolicy{Action: appconfig.InteractivePromptActionRespond, Responses: []string{"y"}, IdleTimeout: 200 * time.Millisecond}
+	var stdout, stderr bytes.Buffer
+
+	exitCode, err := executeCommand(log.NewMockLog(), task.NewChanneledCancelFlag(), "", &stdout, &stderr, 30, ExecuteOptions{PromptPolicy: policy}, "sh", []string{"-c", promptScript})
+
+	assert.Equal(t, 1, exitCode)
+	assert.Equal(t, "interactive prompt detected: \"Name:\"", err.Error())
+}
+
+func TestExecuteCommandStreamsStdin(t *testing.T) {
+	defer useFastPromptChecks()()
+	var stdout, stderr bytes.Buffer
+	options := ExecuteOptions{Stdin: strings.NewReader("line 1\nline 2\n")}
+
+	exitCode, err := executeCommand(log.NewMockLog(), task.NewChanneledCancelFlag(), "", &stdout, &stderr, 30, options, "sh", []string{"-c", "cat"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "line 1\nline 2\n", stdout.String())
+}
+
+func TestExecuteCommandAnswersPromptsAfterStdin(t *testing.T) {
+	defer useFastPromptChecks()()
+	var stdout, stderr bytes.Buffer
+	options := ExecuteOptions{
+		Stdin:        strings.NewReader("y\n"),
+		PromptPolicy: PromptPolicy{Action: appconfig.InteractivePromptActionRespond, Responses: []string{"admin"}, IdleTimeout: 200 * time.Millisecond},
+	}
+
+	exitCode, err := executeCommand(log.NewMockLog(), task.NewChanneledCancelFlag(), "", &stdout, &stderr, 30, options, "sh", []string{"-c", promptScript})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "Continue? [y/N] answer y\nName: name admin\n", stdout.String())
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package executers
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// OutputEncodingAuto transcodes the output from the OEM code page of the system, which is what console programs use on Windows
+	OutputEncodingAuto = "auto"
+	// OutputEncodingUtf8 leaves the output untouched, for commands that already write UTF-8
+	OutputEncodingUtf8 = "utf-8"
+
+	codePageUtf8 = 65001
+)
+
+// parseOutputEncoding returns the code page to transcode the output from.
+// The code page is 0 when the system OEM code page should be detected and -1 when no transcoding is needed.
+func parseOutputEncoding(outputEncoding string) (codePage int, err error) {
+	value := strings.ToLower(strings.TrimSpace(outputEncoding))
+	switch value {
+	case "", OutputEncodingAuto:
+		return 0, nil
+	case OutputEncodingUtf8, "utf8", "none":
+		return -1, nil
+	}
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "cp"), "windows-")
+	if codePage, err = strconv.Atoi(value); err != nil || codePage <= 0 || codePage > 65535 {
+		return 0, fmt.Errorf("unsupported output encoding %v, use %v, %v or a code page number", outputEncoding, OutputEncodingAuto, OutputEncodingUtf8)
+	}
+	if codePage == codePageUtf8 {
+		return -1, nil
+	}
+	return codePage, nil
+}
+
+// TranscodeOutput wraps the writer so that the command output written in outputEncoding is converted to UTF-8.
+// The returned flush function writes any incomplete character left at the end of the output and must be called once the command completed.
+// Transcoding only applies on Windows, other platforms get the writer back unchanged.
+func TranscodeOutput(writer io.Writer, outputEncoding string) (io.Writer, func(), error) {
+	codePage, err := parseOutputEncoding(outputEncoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	if writer == nil || codePage < 0 {
+		return writer, func() {}, nil
+	}
+	return newTranscodingWriter(writer, codePage)
+}
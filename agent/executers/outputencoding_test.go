@@ -0,0 +1,55 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package executers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutputEncoding(t *testing.T) {
+	testCases := map[string]int{
+		"":             0,
+		"Auto":         0,
+		"utf-8":        -1,
+		"UTF8":         -1,
+		"65001":        -1,
+		"850":          850,
+		"cp932":        932,
+		"windows-1252": 1252,
+	}
+	for value, expected := range testCases {
+		codePage, err := parseOutputEncoding(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, codePage, value)
+	}
+
+	for _, value := range []string{"latin1", "0", "70000"} {
+		_, err := parseOutputEncoding(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestTranscodeOutputUtf8(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, flush, err := TranscodeOutput(&buffer, OutputEncodingUtf8)
+	assert.NoError(t, err)
+	assert.Equal(t, &buffer, writer)
+	flush()
+
+	_, _, err = TranscodeOutput(&buffer, "unknown")
+	assert.Error(t, err)
+}
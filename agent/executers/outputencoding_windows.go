@@ -0,0 +1,120 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package executers
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	procGetOEMCP            = kernel32.NewProc("GetOEMCP")
+	procIsValidCodePage     = kernel32.NewProc("IsValidCodePage")
+	procIsDBCSLeadByteEx    = kernel32.NewProc("IsDBCSLeadByteEx")
+	procMultiByteToWideChar = kernel32.NewProc("MultiByteToWideChar")
+)
+
+// transcodingWriter converts the output of a command from a Windows code page to UTF-8
+type transcodingWriter struct {
+	writer   io.Writer
+	codePage uint32
+	// leadBytes marks the bytes starting a double byte character in the code page
+	leadBytes [256]bool
+	mutex     sync.Mutex
+	// pending holds a double byte character lead byte received at the end of the previous write
+	pending []byte
+}
+
+func newTranscodingWriter(writer io.Writer, codePage int) (io.Writer, func(), error) {
+	if codePage == 0 {
+		oemCodePage, _, _ := procGetOEMCP.Call()
+		codePage = int(oemCodePage)
+	}
+	if codePage == codePageUtf8 {
+		return writer, func() {}, nil
+	}
+	if valid, _, _ := procIsValidCodePage.Call(uintptr(codePage)); valid == 0 {
+		return nil, nil, fmt.Errorf("code page %v is not installed on this instance", codePage)
+	}
+	w := &transcodingWriter{writer: writer, codePage: uint32(codePage)}
+	for b := range w.leadBytes {
+		isLead, _, _ := procIsDBCSLeadByteEx.Call(uintptr(codePage), uintptr(b))
+		w.leadBytes[b] = isLead != 0
+	}
+	return w, w.flush, nil
+}
+
+// Write transcodes p and writes it to the underlying writer, it always reports len(p) bytes written on success
+func (w *transcodingWriter) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	data := append(w.pending, p...)
+	w.pending = nil
+	// keep a trailing lead byte until its trail byte is received
+	if end := w.completeLength(data); end < len(data) {
+		w.pending = append([]byte{}, data[end:]...)
+		data = data[:end]
+	}
+	if len(data) > 0 {
+		if _, err = w.writer.Write(w.decode(data)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the incomplete character left at the end of the output
+func (w *transcodingWriter) flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.pending) > 0 {
+		w.writer.Write(w.decode(w.pending))
+		w.pending = nil
+	}
+}
+
+// completeLength returns the length of data without a trailing incomplete double byte character
+func (w *transcodingWriter) completeLength(data []byte) int {
+	for i := 0; i < len(data); i++ {
+		if w.leadBytes[data[i]] {
+			if i == len(data)-1 {
+				return i
+			}
+			i++
+		}
+	}
+	return len(data)
+}
+
+// decode converts data from the code page to UTF-8, the data is returned unchanged if it can't be converted
+func (w *transcodingWriter) decode(data []byte) []byte {
+	size, _, _ := procMultiByteToWideChar.Call(uintptr(w.codePage), 0, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), 0, 0)
+	if size == 0 {
+		return data
+	}
+	wide := make([]uint16, size)
+	size, _, _ = procMultiByteToWideChar.Call(uintptr(w.codePage), 0, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&wide[0])), size)
+	if size == 0 {
+		return data
+	}
+	return []byte(string(utf16.Decode(wide[:size])))
+}
@@ -0,0 +1,119 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package executers contains general purpose (shell) command executing objects.
+package executers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	minNiceLevel = -20
+	maxNiceLevel = 19
+
+	// IoPriorityClass* are the io scheduling classes supported by ionice on Linux
+	IoPriorityClassIdle       = "idle"
+	IoPriorityClassBestEffort = "best-effort"
+	IoPriorityClassRealtime   = "realtime"
+
+	// PriorityClass* are the process priority classes supported on Windows
+	PriorityClassIdle        = "Idle"
+	PriorityClassBelowNormal = "BelowNormal"
+	PriorityClassNormal      = "Normal"
+	PriorityClassAboveNormal = "AboveNormal"
+	PriorityClassHigh        = "High"
+)
+
+// ProcessPriority holds the scheduling settings applied to a spawned command and all its descendants.
+// Empty fields leave the corresponding setting inherited from the agent.
+type ProcessPriority struct {
+	// CpuAffinity is the list of cpus the command may run on
+	CpuAffinity []int
+	// NiceLevel is the nice value of the command on unix, nil keeps the agent's nice value
+	NiceLevel *int
+	// IoPriorityClass is the ionice scheduling class on Linux
+	IoPriorityClass string
+	// PriorityClass is the process priority class on Windows
+	PriorityClass string
+}
+
+// IsEmpty returns true if no scheduling setting is requested.
+func (p ProcessPriority) IsEmpty() bool {
+	return len(p.CpuAffinity) == 0 && p.NiceLevel == nil && p.IoPriorityClass == "" && p.PriorityClass == ""
+}
+
+// ParseCpuAffinity parses a cpu list in the taskset format, for example "0,2-3".
+func ParseCpuAffinity(cpuList string) (cpus []int, err error) {
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(cpuList, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid cpu %q in cpu list %q", bounds[0], cpuList)
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil || last < first {
+				return nil, fmt.Errorf("invalid cpu range %q in cpu list %q", part, cpuList)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// Validate checks the settings are within the supported ranges.
+func (p ProcessPriority) Validate() error {
+	if p.NiceLevel != nil && (*p.NiceLevel < minNiceLevel || *p.NiceLevel > maxNiceLevel) {
+		return fmt.Errorf("nice level %v should be between %v and %v", *p.NiceLevel, minNiceLevel, maxNiceLevel)
+	}
+	switch p.IoPriorityClass {
+	case "", IoPriorityClassIdle, IoPriorityClassBestEffort, IoPriorityClassRealtime:
+	default:
+		return fmt.Errorf("unsupported io priority class %v", p.IoPriorityClass)
+	}
+	switch p.PriorityClass {
+	case "", PriorityClassIdle, PriorityClassBelowNormal, PriorityClassNormal, PriorityClassAboveNormal, PriorityClassHigh:
+	default:
+		return fmt.Errorf("unsupported priority class %v", p.PriorityClass)
+	}
+	return nil
+}
+
+// WithProcessPriority wraps the command so that it runs with the given scheduling settings.
+// On Unix the settings are applied by launchers in front of the command. On Windows the command is returned as is and
+// the executer applies the settings of ExecuteOptions.Priority to the process it creates. Either way every process the
+// command spawns inherits them.
+func WithProcessPriority(priority ProcessPriority, commandName string, commandArguments []string) (string, []string, error) {
+	if priority.IsEmpty() {
+		return commandName, commandArguments, nil
+	}
+	if err := priority.Validate(); err != nil {
+		return "", nil, err
+	}
+	return wrapWithProcessPriority(priority, commandName, commandArguments)
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package executers
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	niceCommand    = "nice"
+	ioniceCommand  = "ionice"
+	tasksetCommand = "taskset"
+)
+
+var ioniceClasses = map[string]string{
+	IoPriorityClassRealtime:   "1",
+	IoPriorityClassBestEffort: "2",
+	IoPriorityClassIdle:       "3",
+}
+
+// wrapWithProcessPriority prefixes the command with taskset, ionice and nice as requested.
+func wrapWithProcessPriority(priority ProcessPriority, commandName string, commandArguments []string) (string, []string, error) {
+	if priority.PriorityClass != "" {
+		return "", nil, errors.New("priority class is only supported on Windows, use nice level instead")
+	}
+	if runtime.GOOS != "linux" && (len(priority.CpuAffinity) > 0 || priority.IoPriorityClass != "") {
+		return "", nil, errors.New("cpu affinity and io priority class are only supported on Linux")
+	}
+
+	command := append([]string{commandName}, commandArguments...)
+	if len(priority.CpuAffinity) > 0 {
+		cpus := make([]string, len(priority.CpuAffinity))
+		for i, cpu := range priority.CpuAffinity {
+			cpus[i] = strconv.Itoa(cpu)
+		}
+		command = append([]string{tasksetCommand, "-c", strings.Join(cpus, ",")}, command...)
+	}
+	if priority.IoPriorityClass != "" {
+		command = append([]string{ioniceCommand, "-c", ioniceClasses[priority.IoPriorityClass]}, command...)
+	}
+	if priority.NiceLevel != nil {
+		command = append([]string{niceCommand, "-n", strconv.Itoa(*priority.NiceLevel)}, command...)
+	}
+	return command[0], command[1:], nil
+}
+
+// prepareProcessPriority does nothing, the launchers added by wrapWithProcessPriority apply the settings.
+func prepareProcessPriority(command *exec.Cmd, priority ProcessPriority) {
+}
+
+// applyProcessPriority does nothing, the launchers added by wrapWithProcessPriority apply the settings.
+func applyProcessPriority(process *os.Process, priority ProcessPriority) error {
+	return nil
+}
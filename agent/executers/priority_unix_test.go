@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package executers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCpuAffinity(t *testing.T) {
+	cpus, err := ParseCpuAffinity("3, 0-1,1")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 3}, cpus)
+
+	_, err = ParseCpuAffinity("2-1")
+	assert.Error(t, err)
+	_, err = ParseCpuAffinity("a")
+	assert.Error(t, err)
+}
+
+func TestWithProcessPriority(t *testing.T) {
+	name, args, err := WithProcessPriority(ProcessPriority{}, "sh", []string{"-c", "script.sh"})
+	assert.NoError(t, err)
+	assert.Equal(t, "sh", name)
+	assert.Equal(t, []string{"-c", "script.sh"}, args)
+
+	nice := 10
+	name, args, err = WithProcessPriority(ProcessPriority{CpuAffinity: []int{0, 2}, NiceLevel: &nice, IoPriorityClass: IoPriorityClassIdle}, "sh", []string{"-c", "script.sh"})
+	assert.NoError(t, err)
+	assert.Equal(t, "nice", name)
+	assert.Equal(t, []string{"-n", "10", "ionice", "-c", "3", "taskset", "-c", "0,2", "sh", "-c", "script.sh"}, args)
+}
+
+func TestWithProcessPriorityInvalid(t *testing.T) {
+	nice := 20
+	_, _, err := WithProcessPriority(ProcessPriority{NiceLevel: &nice}, "sh", nil)
+	assert.Error(t, err)
+
+	_, _, err = WithProcessPriority(ProcessPriority{PriorityClass: PriorityClassIdle}, "sh", nil)
+	assert.Error(t, err)
+
+	_, _, err = WithProcessPriority(ProcessPriority{IoPriorityClass: "lowest"}, "sh", nil)
+	assert.Error(t, err)
+}
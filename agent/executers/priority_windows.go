@@ -0,0 +1,108 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package executers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const (
+	// createSuspended creates the process with its main thread suspended, so its affinity is set before it runs
+	createSuspended = 0x00000004
+	// access rights needed to set the affinity of the process and resume it
+	processSetInformation = 0x0200
+	processSuspendResume  = 0x0800
+)
+
+var (
+	ntdll                      = syscall.NewLazyDLL("ntdll.dll")
+	procNtResumeProcess        = ntdll.NewProc("NtResumeProcess")
+	procSetProcessAffinityMask = kernel32.NewProc("SetProcessAffinityMask")
+)
+
+// priorityClassFlags are the process creation flags of the priority classes
+var priorityClassFlags = map[string]uint32{
+	PriorityClassIdle:        0x00000040,
+	PriorityClassBelowNormal: 0x00004000,
+	PriorityClassNormal:      0x00000020,
+	PriorityClassAboveNormal: 0x00008000,
+	PriorityClassHigh:        0x00000080,
+}
+
+// wrapWithProcessPriority returns the command as is, the priority class and the affinity are applied by the executer
+// to the process it creates, and inherited by the processes it spawns.
+func wrapWithProcessPriority(priority ProcessPriority, commandName string, commandArguments []string) (string, []string, error) {
+	if priority.NiceLevel != nil || priority.IoPriorityClass != "" {
+		return "", nil, errors.New("nice level and io priority class are only supported on Linux, use priority class instead")
+	}
+	if _, err := affinityMask(priority.CpuAffinity); err != nil {
+		return "", nil, err
+	}
+	return commandName, commandArguments, nil
+}
+
+// affinityMask returns the affinity mask of the cpus
+func affinityMask(cpus []int) (mask uintptr, err error) {
+	for _, cpu := range cpus {
+		if cpu >= 64 {
+			return 0, fmt.Errorf("cpu %v is out of the supported affinity range", cpu)
+		}
+		mask |= 1 << uint(cpu)
+	}
+	return mask, nil
+}
+
+// prepareProcessPriority creates the process of the command in the priority class, and suspended when an affinity is
+// requested, so that it doesn't run nor spawn processes before applyProcessPriority sets its affinity.
+func prepareProcessPriority(command *exec.Cmd, priority ProcessPriority) {
+	if priority.PriorityClass == "" && len(priority.CpuAffinity) == 0 {
+		return
+	}
+	if command.SysProcAttr == nil {
+		command.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	command.SysProcAttr.CreationFlags |= priorityClassFlags[priority.PriorityClass]
+	if len(priority.CpuAffinity) > 0 {
+		command.SysProcAttr.CreationFlags |= createSuspended
+	}
+}
+
+// applyProcessPriority sets the affinity of the process created by prepareProcessPriority and resumes it.
+func applyProcessPriority(process *os.Process, priority ProcessPriority) error {
+	if len(priority.CpuAffinity) == 0 {
+		return nil
+	}
+	mask, err := affinityMask(priority.CpuAffinity)
+	if err != nil {
+		return err
+	}
+	handle, err := syscall.OpenProcess(processSetInformation|processSuspendResume, false, uint32(process.Pid))
+	if err != nil {
+		return fmt.Errorf("failed to open the process %v: %v", process.Pid, err)
+	}
+	defer syscall.CloseHandle(handle)
+	if ret, _, callErr := procSetProcessAffinityMask.Call(uintptr(handle), mask); ret == 0 {
+		return fmt.Errorf("failed to set the cpu affinity of the process %v: %v", process.Pid, callErr)
+	}
+	if status, _, _ := procNtResumeProcess.Call(uintptr(handle)); status != 0 {
+		return fmt.Errorf("failed to resume the process %v, status 0x%x", process.Pid, status)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package executers
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProcessPriorityKeepsCommand(t *testing.T) {
+	priority := ProcessPriority{CpuAffinity: []int{0, 2}, PriorityClass: PriorityClassBelowNormal}
+	name, args, err := WithProcessPriority(priority, "powershell.exe", []string{"-File", "C:\\a & b\\script.ps1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "powershell.exe", name)
+	assert.Equal(t, []string{"-File", "C:\\a & b\\script.ps1"}, args)
+
+	mask, err := affinityMask(priority.CpuAffinity)
+	assert.NoError(t, err)
+	assert.Equal(t, uintptr(5), mask)
+
+	nice := 10
+	_, _, err = WithProcessPriority(ProcessPriority{NiceLevel: &nice}, "powershell.exe", nil)
+	assert.Error(t, err)
+	_, _, err = WithProcessPriority(ProcessPriority{CpuAffinity: []int{64}}, "powershell.exe", nil)
+	assert.Error(t, err)
+}
+
+func TestPrepareProcessPriority(t *testing.T) {
+	command := exec.Command("powershell.exe")
+	prepareProcessPriority(command, ProcessPriority{})
+	assert.Nil(t, command.SysProcAttr)
+
+	prepareProcessPriority(command, ProcessPriority{PriorityClass: PriorityClassIdle})
+	assert.Equal(t, priorityClassFlags[PriorityClassIdle], command.SysProcAttr.CreationFlags)
+
+	command = exec.Command("powershell.exe")
+	prepareProcessPriority(command, ProcessPriority{CpuAffinity: []int{1}})
+	assert.Equal(t, uint32(createSuspended), command.SysProcAttr.CreationFlags)
+}
@@ -61,6 +61,23 @@ func (m *MockCommandExecuter) NewExecute(
 	return args.Get(0).(int), args.Error(1)
 }
 
+// NewExecuteWithOptions is a mocked method that just returns what mock tells it to.
+func (m *MockCommandExecuter) NewExecuteWithOptions(
+	log log.T,
+	workingDir string,
+	stdoutWriter io.Writer,
+	stderrWriter io.Writer,
+	cancelFlag task.CancelFlag,
+	executionTimeout int,
+	options ExecuteOptions,
+	commandName string,
+	commandArguments []string,
+) (exitCode int, err error) {
+	args := m.Called(log, workingDir, stdoutWriter, stderrWriter, cancelFlag, executionTimeout, options, commandName, commandArguments)
+	log.Infof("args are %v", args)
+	return args.Get(0).(int), args.Error(1)
+}
+
 // StartExe is a mocked method that just returns what mock tells it to.
 func (m *MockCommandExecuter) StartExe(log log.T,
 	workingDir string,
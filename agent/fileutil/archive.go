@@ -0,0 +1,278 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fileutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/golang/go/zstd"
+)
+
+// Archive formats extracted by ExtractArchive
+const (
+	ArchiveFormatZip    = "zip"
+	ArchiveFormatTarGz  = "tar.gz"
+	ArchiveFormatTarZst = "tar.zst"
+)
+
+// maxUntarSize is the largest size a tar archive may decompress to, so that a small archive can't fill the disk
+var maxUntarSize int64 = 8 * 1024 * 1024 * 1024
+
+var (
+	zipMagic      = []byte("PK\x03\x04")
+	emptyZipMagic = []byte("PK\x05\x06")
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zstdMagic     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// DetectArchiveFormat returns the format of an archive from its first bytes, a gzip or zstd stream is expected to hold a tar archive
+func DetectArchiveFormat(src string) (string, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read %v: %v", src, err)
+	}
+	magic = magic[:n]
+	switch {
+	case bytes.HasPrefix(magic, zipMagic) || bytes.HasPrefix(magic, emptyZipMagic):
+		return ArchiveFormatZip, nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return ArchiveFormatTarGz, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return ArchiveFormatTarZst, nil
+	}
+	return "", fmt.Errorf("%v is not a %v, %v or %v archive", src, ArchiveFormatZip, ArchiveFormatTarGz, ArchiveFormatTarZst)
+}
+
+// ExtractArchive extracts a zip, tar.gz or tar.zst archive to dest, the format is detected from the content of the archive
+func ExtractArchive(src, dest string) error {
+	format, err := DetectArchiveFormat(src)
+	if err != nil {
+		return err
+	}
+	if format == ArchiveFormatZip {
+		return Unzip(src, dest)
+	}
+	return Untar(src, dest, format)
+}
+
+// Untar extracts a tar archive compressed with gzip or zstd (using platform agnostic tar functionality).
+// Entries must stay in the dest subtree: an entry is never written through a symbolic link, and a symbolic
+// link must point inside the subtree once the archive is extracted. The extraction fails once the archive
+// decompresses to more than maxUntarSize bytes.
+func Untar(src, dest, format string) error {
+	file, err := os.Open(LongPath(src))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	dest = LongPath(dest)
+
+	var reader io.Reader
+	switch format {
+	case ArchiveFormatTarGz:
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case ArchiveFormatTarZst:
+		reader = zstd.NewReader(file)
+	default:
+		return fmt.Errorf("%v is not a tar archive format", format)
+	}
+
+	if err = os.MkdirAll(dest, appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	var links []string
+	tarReader := tar.NewReader(&sizeLimitedReader{reader: reader, remaining: maxUntarSize, limit: maxUntarSize})
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		path := filepath.Join(dest, header.Name)
+		if !isUnderDir(path, dest) {
+			return fmt.Errorf("%v attempts to place files outside %v subtree", header.Name, dest)
+		}
+		if err = checkNoSymlink(dest, filepath.Dir(path)); err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(path), appconfig.ReadWriteExecuteAccess); err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = untarDirectory(path, header)
+		case tar.TypeReg:
+			err = untarFile(path, header, tarReader)
+		case tar.TypeSymlink:
+			if err = untarSymlink(dest, path, header); err == nil {
+				links = append(links, path)
+			}
+		case tar.TypeLink:
+			err = untarHardLink(dest, path, header)
+		default:
+			err = fmt.Errorf("%v has the unsupported tar entry type %q", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return checkSymlinksUnderDir(dest, links)
+}
+
+// sizeLimitedReader fails once more than limit bytes are read
+type sizeLimitedReader struct {
+	reader    io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (n int, err error) {
+	// read one byte past the limit to tell an archive of exactly the limit from a larger one
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err = r.reader.Read(p)
+	if r.remaining -= int64(n); r.remaining < 0 {
+		return n, fmt.Errorf("the archive decompresses to more than %v bytes", r.limit)
+	}
+	return n, err
+}
+
+func untarDirectory(path string, header *tar.Header) error {
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(path, os.FileMode(header.Mode).Perm()|appconfig.ReadWriteExecuteAccess)
+}
+
+func untarFile(path string, header *tar.Header, reader io.Reader) error {
+	// a later entry replaces an earlier one, the file must not be written through a link of the earlier entry
+	if err := removeLink(path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, appconfig.FileFlagsCreateOrTruncate, os.FileMode(header.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	return err
+}
+
+func untarSymlink(dest, path string, header *tar.Header) error {
+	target := header.Linkname
+	if filepath.IsAbs(target) || strings.HasPrefix(filepath.ToSlash(target), "/") || !isUnderDir(filepath.Join(filepath.Dir(path), target), dest) {
+		return fmt.Errorf("symbolic link %v to %v points outside %v subtree", header.Name, target, dest)
+	}
+	if err := removeLink(path); err != nil {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func untarHardLink(dest, path string, header *tar.Header) error {
+	source := filepath.Join(dest, header.Linkname)
+	if !isUnderDir(source, dest) {
+		return fmt.Errorf("hard link %v to %v points outside %v subtree", header.Name, header.Linkname, dest)
+	}
+	if err := checkNoSymlink(dest, source); err != nil {
+		return err
+	}
+	if err := removeLink(path); err != nil {
+		return err
+	}
+	return os.Link(source, path)
+}
+
+// removeLink removes the symbolic link or file at path so an entry replaces it instead of writing through it
+func removeLink(path string) error {
+	if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+		return os.Remove(path)
+	}
+	return nil
+}
+
+// checkNoSymlink returns an error if path or a directory between dest and path is a symbolic link
+func checkNoSymlink(dest, path string) error {
+	relative, err := filepath.Rel(dest, path)
+	if err != nil || relative == "." {
+		return err
+	}
+	current := dest
+	for _, name := range strings.Split(relative, string(filepath.Separator)) {
+		current = filepath.Join(current, name)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%v is a symbolic link, entries are not extracted through symbolic links", current)
+		}
+	}
+	return nil
+}
+
+// checkSymlinksUnderDir resolves the extracted symbolic links, a chain of links can leave the subtree even if
+// every link points inside it. A link to a file that does not exist is accepted, its target was checked.
+func checkSymlinksUnderDir(dest string, links []string) error {
+	if len(links) == 0 {
+		return nil
+	}
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		resolved, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		if !isUnderDir(resolved, realDest) {
+			os.Remove(link)
+			return fmt.Errorf("symbolic link %v resolves to %v outside %v subtree", link, resolved, dest)
+		}
+	}
+	return nil
+}
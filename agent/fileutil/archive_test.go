@@ -0,0 +1,167 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fileutil
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTarGz writes a tar.gz archive of the entries, the content of a regular file is its link name
+func writeTarGz(t *testing.T, path string, entries []tar.Header) {
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := entry
+		content := ""
+		if header.Typeflag == tar.TypeReg {
+			content, header.Linkname = header.Linkname, ""
+			header.Size = int64(len(content))
+		}
+		assert.NoError(t, tarWriter.WriteHeader(&header))
+		_, err = tarWriter.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tarWriter.Close())
+	assert.NoError(t, gzipWriter.Close())
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	format, err := DetectArchiveFormat(filepath.Join("testdata", "package.tar.zst"))
+	assert.NoError(t, err)
+	assert.Equal(t, ArchiveFormatTarZst, format)
+
+	tarGzPath := filepath.Join(dir, "package.tar.gz")
+	writeTarGz(t, tarGzPath, nil)
+	format, err = DetectArchiveFormat(tarGzPath)
+	assert.NoError(t, err)
+	assert.Equal(t, ArchiveFormatTarGz, format)
+
+	zipPath := filepath.Join(dir, "package.zip")
+	_, err = ZipFiles(zipPath, []string{tarGzPath})
+	assert.NoError(t, err)
+	format, err = DetectArchiveFormat(zipPath)
+	assert.NoError(t, err)
+	assert.Equal(t, ArchiveFormatZip, format)
+
+	_, err = DetectArchiveFormat(filepath.Join("testdata", "file.txt"))
+	assert.Error(t, err)
+}
+
+func TestExtractArchive_TarZst(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ExtractArchive(filepath.Join("testdata", "package.tar.zst"), dir))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "bin", "install.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo installed\n", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(dir, "readme.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "zstd package\n", string(content))
+}
+
+func TestExtractArchive_TarGz(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links require a privilege on windows")
+	}
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "package.tar.gz")
+	writeTarGz(t, src, []tar.Header{
+		{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "bin/install.sh", Typeflag: tar.TypeReg, Mode: 0755, Linkname: "echo installed"},
+		{Name: "install", Typeflag: tar.TypeSymlink, Linkname: "bin/install.sh"},
+		{Name: "lib/install.sh", Typeflag: tar.TypeLink, Linkname: "bin/install.sh"},
+	})
+	dest := filepath.Join(dir, "extract")
+	assert.NoError(t, ExtractArchive(src, dest))
+
+	content, err := ioutil.ReadFile(filepath.Join(dest, "install"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo installed", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(dest, "lib", "install.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo installed", string(content))
+	info, err := os.Stat(filepath.Join(dest, "bin", "install.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+}
+
+func TestUntar_OutsideSubtree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links require a privilege on windows")
+	}
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, entries := range map[string][]tar.Header{
+		"path traversal":   {{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644}},
+		"absolute link":    {{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+		"link traversal":   {{Name: "bin/up", Typeflag: tar.TypeSymlink, Linkname: "../../evil"}},
+		"hard link":        {{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "../../etc/passwd"}},
+		"through a link":   {{Name: "bin", Typeflag: tar.TypeSymlink, Linkname: "."}, {Name: "bin/evil", Typeflag: tar.TypeReg, Mode: 0644}},
+		"chain of links":   {{Name: "a/up", Typeflag: tar.TypeSymlink, Linkname: ".."}, {Name: "escape", Typeflag: tar.TypeSymlink, Linkname: "a/up/.."}},
+		"unsupported type": {{Name: "fifo", Typeflag: tar.TypeFifo, Mode: 0644}},
+	} {
+		src := filepath.Join(dir, "package.tar.gz")
+		writeTarGz(t, src, entries)
+		dest := filepath.Join(dir, "extract")
+		assert.Error(t, Untar(src, dest, ArchiveFormatTarGz), name)
+		_, err = os.Lstat(filepath.Join(dir, "evil"))
+		assert.True(t, os.IsNotExist(err), name)
+		assert.NoError(t, os.RemoveAll(dest))
+	}
+}
+
+func TestUntar_SizeLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(size int64) { maxUntarSize = size }(maxUntarSize)
+	tarGzPath := filepath.Join(dir, "package.tar.gz")
+	writeTarGz(t, tarGzPath, []tar.Header{
+		{Name: "zeros.bin", Typeflag: tar.TypeReg, Mode: 0600, Linkname: strings.Repeat("0", 64*1024)},
+	})
+
+	maxUntarSize = 32 * 1024
+	err = Untar(tarGzPath, filepath.Join(dir, "small"), ArchiveFormatTarGz)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "decompresses to more than")
+
+	maxUntarSize = 128 * 1024
+	assert.NoError(t, Untar(tarGzPath, filepath.Join(dir, "large"), ArchiveFormatTarGz))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "large", "zeros.bin"))
+	assert.NoError(t, err)
+	assert.Len(t, content, 64*1024)
+}
@@ -15,11 +15,14 @@
 package artifact
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"net/url"
@@ -30,11 +33,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
@@ -44,40 +48,54 @@ type DownloadOutput struct {
 	LocalFilePath string
 	IsUpdated     bool
 	IsHashMatched bool
+	// Timings of the http/s requests of the download, zero for other downloads
+	Timings DownloadTimings
 }
 
-// DownloadInput specifies the input to file download operation
+// DownloadInput specifies the input to file download operation,
+// SourceChecksums are keyed by algorithm (sha256, sha384, sha512 or md5) and computed while the file downloads,
+// a download fails as soon as it exceeds SourceSize when the size is declared.
+// Resumable http/s downloads are split in ranges and resume after the ranges downloaded by a previous attempt.
+// Progress, when set, is called as the download advances.
+// Cached downloads declaring a sha256, sha384 or sha512 checksum are copied from the shared artifact cache when
+// another download fetched the same artifact, concurrent downloads of the artifact wait for each other.
 type DownloadInput struct {
 	SourceURL            string
 	DestinationDirectory string
 	SourceChecksums      map[string]string
+	SourceSize           int64
+	Resumable            bool
+	Progress             ProgressFunc
+	Cached               bool
+	// Headers are added to the http/s requests sent to the host of SourceURL, such as the authorization of a mirror,
+	// a redirect to another host does not receive them
+	Headers map[string]string
 }
 
+// ProgressFunc receives the number of bytes of the file downloaded so far and its size,
+// the size is 0 when the server does not send it
+type ProgressFunc func(downloaded int64, total int64)
+
 // httpDownload attempts to download a file via http/s call
-func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOutput, err error) {
+func httpDownload(ctx context.Context, log log.T, fileURL string, headers map[string]string, destFile string, verifier *checksumVerifier, progress ProgressFunc) (output DownloadOutput, err error) {
 	log.Debugf("attempting to download as http/https download %v", destFile)
 	eTagFile := destFile + ".etag"
-	var check http.Client
 	var request *http.Request
 	request, err = http.NewRequest("GET", fileURL, nil)
 	if err != nil {
 		return
 	}
+	request = request.WithContext(ctx)
+	client, timings := newArtifactHTTPClient(request.URL.Host, headers)
+	defer func() { output.Timings = output.Timings.plus(timings.get()) }()
 	if fileutil.Exists(destFile) == true && fileutil.Exists(eTagFile) == true {
 		var existingETag string
 		existingETag, err = fileutil.ReadAllText(eTagFile)
 		request.Header.Add("If-None-Match", existingETag)
 	}
 
-	check = http.Client{
-		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
-			return nil
-		},
-	}
-
 	var resp *http.Response
-	resp, err = check.Do(request)
+	resp, err = client.Do(request)
 	if err != nil {
 		log.Debug("failed to download from http/https, ", err)
 		fileutil.DeleteFile(destFile)
@@ -94,10 +112,15 @@ func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOu
 		log.Debug("failed to download from http/https, ", err)
 		fileutil.DeleteFile(destFile)
 		fileutil.DeleteFile(eTagFile)
-		err = fmt.Errorf("http request failed. status:%v statuscode:%v", resp.Status, resp.StatusCode)
+		err = HTTPStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
 		return
 	}
 	defer resp.Body.Close()
+	if err = verifier.checkLength(resp.ContentLength); err != nil {
+		fileutil.DeleteFile(destFile)
+		fileutil.DeleteFile(eTagFile)
+		return
+	}
 	eTagValue := resp.Header.Get("Etag")
 	if eTagValue != "" {
 		log.Debug("file eTagValue is ", eTagValue)
@@ -107,7 +130,7 @@ func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOu
 			return
 		}
 	}
-	_, err = FileCopy(log, destFile, resp.Body)
+	_, err = fileCopy(log, destFile, newProgressReader(resp.Body, 0, resp.ContentLength, progress), verifier)
 	if err == nil {
 		output.LocalFilePath = destFile
 		output.IsUpdated = true
@@ -117,6 +140,54 @@ func httpDownload(log log.T, fileURL string, destFile string) (output DownloadOu
 	return
 }
 
+// HTTPStatusError is returned when an http/https download is answered with an unexpected status,
+// callers use the status code to decide whether the download can be retried
+type HTTPStatusError struct {
+	Status     string
+	StatusCode int
+}
+
+func (e HTTPStatusError) Error() string {
+	return fmt.Sprintf("http request failed. status:%v statuscode:%v", e.Status, e.StatusCode)
+}
+
+// newArtifactHTTPClient returns the http client downloading artifacts from http/s urls and the recorder of the timings
+// of its requests, the headers are added to the requests sent to host
+func newArtifactHTTPClient(host string, headers map[string]string) (*http.Client, *timingRecorder) {
+	// artifact servers are trusted independently of the service endpoints
+	var transport http.RoundTripper = network.NewArtifactTransport(network.ArtifactEndpoint)
+	if len(headers) > 0 {
+		transport = &headerTransport{base: transport, host: host, headers: headers}
+	}
+	timings := &timingRecorder{}
+	return &http.Client{
+		Transport: timings.transport(transport),
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			r.URL.Opaque = r.URL.Path
+			return nil
+		},
+	}, timings
+}
+
+// headerTransport adds headers to the requests sent to a host
+type headerTransport struct {
+	base    http.RoundTripper
+	host    string
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Host != t.host {
+		return t.base.RoundTrip(request)
+	}
+	// a round tripper must not modify the request it is given
+	request = request.Clone(request.Context())
+	for name, value := range t.headers {
+		request.Header.Set(name, value)
+	}
+	return t.base.RoundTrip(request)
+}
+
 // awsConfig creates a config and sets region and credential information given an S3 URL
 func awsConfig(log log.T, amazonS3URL s3util.AmazonS3URL) (config *aws.Config, err error) {
 	config = sdkutil.AwsConfig()
@@ -127,6 +198,9 @@ func awsConfig(log log.T, amazonS3URL s3util.AmazonS3URL) (config *aws.Config, e
 	} else {
 		if appConfig.S3.Endpoint != "" {
 			config.Endpoint = &appConfig.S3.Endpoint
+		} else if appconfig.UseDualStackEndpoints() {
+			// let the sdk resolve the dualstack endpoint of the bucket region
+			config.UseDualStack = aws.Bool(true)
 		} else {
 			if region, err := platform.Region(); err == nil {
 				if defaultEndpoint := appconfig.GetDefaultEndPoint(region, "s3"); defaultEndpoint != "" {
@@ -139,6 +213,7 @@ func awsConfig(log log.T, amazonS3URL s3util.AmazonS3URL) (config *aws.Config, e
 	}
 	config.S3ForcePathStyle = aws.Bool(amazonS3URL.IsPathStyle)
 	config.Region = aws.String(amazonS3URL.Region)
+	config.HTTPClient = &http.Client{Transport: network.NewArtifactTransport(network.ServiceEndpoint)}
 	return config, nil
 }
 
@@ -155,7 +230,7 @@ func CanGetS3Object(log log.T, amazonS3URL s3util.AmazonS3URL) bool {
 
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	s3client := s3.New(sess)
 	var res *s3.HeadObjectOutput
@@ -183,7 +258,7 @@ func ListS3Folders(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []str
 	}
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	s3client := s3.New(sess)
 	req, resp := s3client.ListObjectsRequest(params)
@@ -226,7 +301,7 @@ func ListS3Directory(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []s
 
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	s3client := s3.New(sess)
 	obj, err := s3client.ListObjects(params)
@@ -244,7 +319,7 @@ func ListS3Directory(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []s
 }
 
 // s3Download attempts to download a file via the aws sdk.
-func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (output DownloadOutput, err error) {
+func s3Download(ctx context.Context, log log.T, amazonS3URL s3util.AmazonS3URL, destFile string, verifier *checksumVerifier, progress ProgressFunc) (output DownloadOutput, err error) {
 	log.Debugf("attempting to download as s3 download %v", destFile)
 	eTagFile := destFile + ".etag"
 
@@ -265,11 +340,12 @@ func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (out
 	}
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	s3client := s3.New(sess)
 
 	req, resp := s3client.GetObjectRequest(params)
+	req.SetContext(ctx)
 	err = req.Send()
 	if err != nil {
 		if req.HTTPResponse == nil || req.HTTPResponse.StatusCode != http.StatusNotModified {
@@ -285,6 +361,12 @@ func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (out
 		return output, nil
 	}
 
+	if err = verifier.checkLength(aws.Int64Value(resp.ContentLength)); err != nil {
+		resp.Body.Close()
+		fileutil.DeleteFile(destFile)
+		fileutil.DeleteFile(eTagFile)
+		return
+	}
 	if *resp.ETag != "" {
 		log.Debug("files etag is ", *resp.ETag)
 		err = fileutil.WriteAllText(eTagFile, *resp.ETag)
@@ -295,7 +377,7 @@ func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (out
 	}
 
 	defer resp.Body.Close()
-	_, err = FileCopy(log, destFile, resp.Body)
+	_, err = fileCopy(log, destFile, newProgressReader(resp.Body, 0, aws.Int64Value(resp.ContentLength), progress), verifier)
 	if err == nil {
 		output.LocalFilePath = destFile
 		output.IsUpdated = true
@@ -307,22 +389,71 @@ func s3Download(log log.T, amazonS3URL s3util.AmazonS3URL, destFile string) (out
 
 // FileCopy copies the content from reader to destinationPath file
 func FileCopy(log log.T, destinationPath string, src io.Reader) (written int64, err error) {
+	return fileCopy(log, destinationPath, src, nil)
+}
 
+// fileCopy copies the content from reader to destinationPath file and computes the checksums of the verifier
+// as the content streams in, the file is deleted when the copy fails
+func fileCopy(log log.T, destinationPath string, src io.Reader, verifier *checksumVerifier) (written int64, err error) {
 	var file *os.File
 	file, err = os.Create(destinationPath)
 	if err != nil {
 		log.Errorf("failed to create file. %v", err)
 		return
 	}
-	defer file.Close()
-	var size int64
-	size, err = io.Copy(file, src)
-	log.Infof("%s with %v bytes downloaded", destinationPath, size)
+	// downloads are rate limited and slowed down during the configured peak hours
+	src = throttle.NewDownloadReader(src)
+	if verifier != nil {
+		verifier.reset()
+		src = io.TeeReader(src, verifier)
+	}
+	written, err = io.Copy(file, src)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fileutil.DeleteFile(destinationPath)
+		return
+	}
+	log.Infof("%s with %v bytes downloaded", destinationPath, written)
+	return
+}
+
+// progressReader reports the bytes read through it to a ProgressFunc
+type progressReader struct {
+	reader     io.Reader
+	downloaded int64
+	total      int64
+	progress   ProgressFunc
+}
+
+// newProgressReader wraps the reader of the bytes of the file following offset, it returns the reader as is without a ProgressFunc
+func newProgressReader(reader io.Reader, offset int64, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return reader
+	}
+	if total < 0 {
+		total = 0
+	}
+	return &progressReader{reader: reader, downloaded: offset, total: total, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		r.downloaded += int64(n)
+		r.progress(r.downloaded, r.total)
+	}
 	return
 }
 
 // Download is a generic utility which attempts to download smartly.
 func Download(log log.T, input DownloadInput) (output DownloadOutput, err error) {
+	return DownloadWithContext(context.Background(), log, input)
+}
+
+// DownloadWithContext is the same as Download, the download is aborted when the context is done.
+func DownloadWithContext(ctx context.Context, log log.T, input DownloadInput) (output DownloadOutput, err error) {
 	// parse the url
 	var fileURL *url.URL
 	fileURL, err = url.Parse(input.SourceURL)
@@ -358,6 +489,7 @@ func Download(log log.T, input DownloadInput) (output DownloadOutput, err error)
 		output.IsUpdated = false
 		output.IsHashMatched, err = VerifyHash(log, input, output)
 	} else {
+		verifier := newChecksumVerifier(input.SourceChecksums, input.SourceSize)
 		err = fmt.Errorf("source file wasn't found locally, will attempt as web download. %v", input.SourceURL)
 		// compute the local filename which is hash of url_filename
 		// Generating a hash_filename will also help against attackers
@@ -365,19 +497,39 @@ func Download(log log.T, input DownloadInput) (output DownloadOutput, err error)
 		urlHash := sha1.Sum([]byte(fileURL.String()))
 		output.LocalFilePath = filepath.Join(destinationDir, fmt.Sprintf("%x", urlHash))
 
+		if cache, key, ok := cacheFor(input); ok {
+			unlock, lockErr := cache.lock(key)
+			if lockErr != nil {
+				log.Warnf("Downloading %v without the artifact cache: %v", input.SourceURL, lockErr)
+			} else {
+				defer unlock()
+				if cache.restore(log, key, input, output.LocalFilePath) {
+					return DownloadOutput{LocalFilePath: output.LocalFilePath, IsUpdated: true, IsHashMatched: true}, nil
+				}
+				defer func() {
+					if err == nil && output.IsHashMatched {
+						cache.store(log, key, output.LocalFilePath)
+					}
+				}()
+			}
+		}
+
 		amazonS3URL := s3util.ParseAmazonS3URL(log, fileURL)
-		if amazonS3URL.IsBucketAndKeyPresent() {
+		if fileURL.Scheme == "file" {
+			// source is a file on a local directory or mounted share
+			output, err = fileDownload(ctx, log, fileURL, output.LocalFilePath, verifier, input.Progress)
+		} else if amazonS3URL.IsBucketAndKeyPresent() {
 			// source is s3
 			var tempOutput DownloadOutput
-			tempOutput, err = s3Download(log, amazonS3URL, output.LocalFilePath)
+			tempOutput, err = s3Download(ctx, log, amazonS3URL, output.LocalFilePath, verifier, input.Progress)
 			// if s3 download fails, attempt http/https download as fallback
-			if err != nil {
-				tempOutput, err = httpDownload(log, input.SourceURL, output.LocalFilePath)
+			if err != nil && ctx.Err() == nil {
+				tempOutput, err = webDownload(ctx, log, input, output.LocalFilePath, verifier)
 			}
 			output = tempOutput
 		} else {
 			// simple http/https download
-			output, err = httpDownload(log, input.SourceURL, output.LocalFilePath)
+			output, err = webDownload(ctx, log, input, output.LocalFilePath, verifier)
 		}
 
 		if err != nil {
@@ -386,90 +538,115 @@ func Download(log log.T, input DownloadInput) (output DownloadOutput, err error)
 
 		isLocalFile, err = fileutil.LocalFileExist(output.LocalFilePath)
 		if isLocalFile == true {
-			output.IsHashMatched, err = VerifyHash(log, input, output)
+			if output.IsUpdated {
+				// the checksums were computed as the file was written
+				output.IsHashMatched, err = verifier.verify(log, input, output.LocalFilePath)
+			} else {
+				output.IsHashMatched, err = VerifyHash(log, input, output)
+			}
+			if !output.IsHashMatched {
+				// the next attempt must not reuse or resume from corrupted content
+				deletePartialDownload(output.LocalFilePath)
+			}
 		}
 	}
 
 	return
 }
 
-// VerifyHash verifies the hash of the url file as per specified hash algorithm type and its value
-func VerifyHash(log log.T, input DownloadInput, output DownloadOutput) (bool, error) {
-	hasMatchingHash := false
-
-	// check and set default hashing algorithm
-	checksums := input.SourceChecksums
-
-	if len(checksums) == 0 {
-		return true, nil
+// fileDownload copies the file of a file url to destFile, so the caller owns its copy like any downloaded file
+func fileDownload(ctx context.Context, log log.T, fileURL *url.URL, destFile string, verifier *checksumVerifier, progress ProgressFunc) (output DownloadOutput, err error) {
+	sourcePath := FilePathFromURL(fileURL)
+	log.Debugf("attempting to copy %v to %v", sourcePath, destFile)
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return output, fmt.Errorf("failed to open %v, %v", sourcePath, err)
 	}
+	defer source.Close()
 
-	//backwards compatibility for empty HashValues and HashTypes
-	if len(checksums) == 1 {
-		for hashAlgorithm, hashValue := range checksums {
-			// this is the only pair in the map
-			if hashAlgorithm == "" || hashValue == "" {
-				return true, nil
-			}
-		}
+	var size int64
+	if info, statErr := source.Stat(); statErr == nil {
+		size = info.Size()
 	}
+	if err = verifier.checkLength(size); err != nil {
+		return output, err
+	}
+	if _, err = fileCopy(log, destFile, newProgressReader(&contextReader{ctx: ctx, reader: source}, 0, size, progress), verifier); err != nil {
+		return output, fmt.Errorf("failed to copy %v, %v", sourcePath, err)
+	}
+	return DownloadOutput{LocalFilePath: destFile, IsUpdated: true}, nil
+}
 
-	for hashAlgorithm, hashValue := range checksums {
-		var computedHashValue string
-		var err error
-		// check the sha256 algorithm by default
-		if hashAlgorithm == "" || strings.EqualFold(hashAlgorithm, "sha256") {
-			computedHashValue, err = Sha256HashValue(log, output.LocalFilePath)
-		} else if strings.EqualFold(hashAlgorithm, "md5") {
-			computedHashValue, err = Md5HashValue(log, output.LocalFilePath)
-		} else {
-			continue
-		}
-
-		if err != nil {
-			return false, fmt.Errorf("the algorithm returned an error when trying to compute the checksum %v", input)
-		}
+// FilePathFromURL returns the local path of a file url, the host of file://host/share/file is
+// kept as a network share
+func FilePathFromURL(fileURL *url.URL) string {
+	path := fileURL.Path
+	if fileURL.Host != "" && fileURL.Host != "localhost" {
+		path = "//" + fileURL.Host + path
+	} else if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		// file:///C:/directory/file
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
 
-		if !strings.EqualFold(hashValue, computedHashValue) {
-			return false, fmt.Errorf("failed to verify hash of downloadinput %v", input)
-		}
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
 
-		hasMatchingHash = true
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
+	return r.reader.Read(p)
+}
 
-	//if a supported hash algorithm was not provided, jut return an error
-	if !hasMatchingHash {
-		return false, fmt.Errorf("no supported algorithm was provided for downloadinput %v", input)
+// webDownload downloads the file over http/s, in resumable ranges if the input asks for it
+func webDownload(ctx context.Context, log log.T, input DownloadInput, destFile string, verifier *checksumVerifier) (DownloadOutput, error) {
+	if input.Resumable {
+		return resumableHTTPDownload(ctx, log, input.SourceURL, input.Headers, destFile, verifier, input.Progress)
 	}
+	return httpDownload(ctx, log, input.SourceURL, input.Headers, destFile, verifier, input.Progress)
+}
 
-	return true, nil
+// VerifyHash verifies the hash of the url file against the checksums declared in the download input.
+// Checksums are keyed by algorithm and may declare several digests; the file is verified against the
+// strongest supported one.
+func VerifyHash(log log.T, input DownloadInput, output DownloadOutput) (bool, error) {
+	verifier := newChecksumVerifier(input.SourceChecksums, input.SourceSize)
+	if len(input.SourceChecksums) > 0 || input.SourceSize > 0 {
+		if err := verifier.hashFile(output.LocalFilePath, -1); err != nil {
+			log.Error(err)
+			return false, fmt.Errorf("the algorithm returned an error when trying to compute the checksum %v", input)
+		}
+	}
+	return verifier.verify(log, input, output.LocalFilePath)
 }
 
 // Sha256HashValue gets the sha256 hash value
 func Sha256HashValue(log log.T, filePath string) (hash string, err error) {
-	var exists = false
-	exists, err = fileutil.LocalFileExist(filePath)
-	if err != nil || exists == false {
-		return
-	}
+	return hashValue(log, filePath, sha256.New())
+}
 
-	var f *os.File
-	f, err = os.Open(filePath)
-	if err != nil {
-		log.Error(err)
-	}
-	defer f.Close()
-	hasher := sha256.New()
-	if _, err = io.Copy(hasher, f); err != nil {
-		log.Error(err)
-	}
-	hash = hex.EncodeToString(hasher.Sum(nil))
-	log.Debugf("Hash=%v, FilePath=%v", hash, filePath)
-	return
+// Sha384HashValue gets the sha384 hash value
+func Sha384HashValue(log log.T, filePath string) (hash string, err error) {
+	return hashValue(log, filePath, sha512.New384())
+}
+
+// Sha512HashValue gets the sha512 hash value
+func Sha512HashValue(log log.T, filePath string) (hash string, err error) {
+	return hashValue(log, filePath, sha512.New())
 }
 
 // Md5HashValue gets the md5 hash value
 func Md5HashValue(log log.T, filePath string) (hash string, err error) {
+	return hashValue(log, filePath, md5.New())
+}
+
+// hashValue gets the hex encoded digest of the file computed by the given hasher
+func hashValue(log log.T, filePath string, hasher hash.Hash) (value string, err error) {
 	var exists = false
 	exists, err = fileutil.LocalFileExist(filePath)
 	if err != nil || exists == false {
@@ -482,11 +659,10 @@ func Md5HashValue(log log.T, filePath string) (hash string, err error) {
 		log.Error(err)
 	}
 	defer f.Close()
-	hasher := md5.New()
 	if _, err = io.Copy(hasher, f); err != nil {
 		log.Error(err)
 	}
-	hash = hex.EncodeToString(hasher.Sum(nil))
-	log.Debugf("Hash=%v, FilePath=%v", hash, filePath)
+	value = hex.EncodeToString(hasher.Sum(nil))
+	log.Debugf("Hash=%v, FilePath=%v", value, filePath)
 	return
 }
@@ -0,0 +1,198 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	checkMyHashSha256 = "090c1965e46155b2b23ba9093ed7c67243957a397e3ad5531a693d57958a760a"
+	checkMyHashSha384 = "d9d22ed1e07c0dc961e0a3af3c7bc3dc93970bc87d3d398659a247ae42bd9ad944ba56b03b181186b340f96c0361be5d"
+	checkMyHashSha512 = "1772bb8f8804d5f2173298683931470c883c3a5ddd2e91821b0d5ace7f335d8bba7049977be733c21669d22289a7863d7592d4a0d7b5786e5786c69fecd083e8"
+	checkMyHashMd5    = "e84913ff3a8eef39238b32170e657ba8"
+)
+
+func TestVerifyHash(t *testing.T) {
+	output := DownloadOutput{LocalFilePath: filepath.Join("testdata", "CheckMyHash.txt")}
+	testCases := []struct {
+		name      string
+		checksums map[string]string
+		matched   bool
+		mismatch  bool
+	}{
+		{"no checksums", nil, true, false},
+		{"empty checksum", map[string]string{"": ""}, true, false},
+		{"default algorithm", map[string]string{"": checkMyHashSha256}, true, false},
+		{"sha256", map[string]string{"sha256": checkMyHashSha256}, true, false},
+		{"sha384", map[string]string{"SHA384": checkMyHashSha384}, true, false},
+		{"sha512", map[string]string{"sha512": checkMyHashSha512}, true, false},
+		{"md5", map[string]string{"md5": checkMyHashMd5}, true, false},
+		{"all digests", map[string]string{"sha256": checkMyHashSha256, "sha512": checkMyHashSha512}, true, false},
+		{"strongest digest validates", map[string]string{"sha256": "bad", "sha512": checkMyHashSha512}, true, false},
+		{"strongest digest mismatches", map[string]string{"sha256": checkMyHashSha256, "sha512": "bad"}, false, true},
+		{"md5 does not override sha256", map[string]string{"md5": checkMyHashMd5, "sha256": "bad"}, false, true},
+		{"unsupported alongside supported", map[string]string{"sha3-256": "abc", "sha384": checkMyHashSha384}, true, false},
+		{"no digest validates", map[string]string{"sha256": "bad", "sha512": "bad"}, false, true},
+		{"only unsupported", map[string]string{"sha3-256": "abc"}, false, false},
+	}
+
+	for _, test := range testCases {
+		matched, err := VerifyHash(log.NewMockLog(), DownloadInput{SourceChecksums: test.checksums}, output)
+		assert.Equal(t, test.matched, matched, test.name)
+		if test.matched {
+			assert.NoError(t, err, test.name)
+			continue
+		}
+		assert.Error(t, err, test.name)
+		assert.Equal(t, test.mismatch, contracts.ErrorCodeOf(err) == contracts.ErrorCodeChecksumMismatch, test.name)
+	}
+}
+
+func TestDownloadFileURL(t *testing.T) {
+	source, err := filepath.Abs(filepath.Join("testdata", "CheckMyHash.txt"))
+	assert.NoError(t, err)
+	destination, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(destination)
+
+	fileURL := url.URL{Scheme: "file", Path: filepath.ToSlash(source)}
+	if !strings.HasPrefix(fileURL.Path, "/") {
+		fileURL.Path = "/" + fileURL.Path
+	}
+	output, err := Download(log.NewMockLog(), DownloadInput{
+		SourceURL:            fileURL.String(),
+		DestinationDirectory: destination,
+		SourceChecksums:      map[string]string{"sha256": checkMyHashSha256},
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, output.IsHashMatched)
+	assert.Equal(t, destination, filepath.Dir(output.LocalFilePath))
+	// the source is copied and left in place
+	assert.True(t, fileutil.Exists(source))
+}
+
+func TestChecksumVerifierStreams(t *testing.T) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "CheckMyHash.txt"))
+	assert.NoError(t, err)
+	input := DownloadInput{SourceChecksums: map[string]string{"sha512": checkMyHashSha512, "md5": "bad"}}
+	verifier := newChecksumVerifier(input.SourceChecksums, int64(len(content)))
+
+	// the checksums are the same whatever the size of the writes
+	for len(content) > 0 {
+		n := 3
+		if n > len(content) {
+			n = len(content)
+		}
+		written, err := verifier.Write(content[:n])
+		assert.NoError(t, err)
+		assert.Equal(t, n, written)
+		content = content[n:]
+	}
+	matched, err := verifier.verify(log.NewMockLog(), input, "CheckMyHash.txt")
+	assert.NoError(t, err)
+	assert.True(t, matched)
+
+	// nothing is added past the declared size
+	_, err = verifier.Write([]byte("x"))
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, contracts.ErrorCodeOf(err))
+}
+
+func TestValidateChecksum(t *testing.T) {
+	assert.NoError(t, ValidateChecksum("sha256", "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08"))
+	assert.NoError(t, ValidateChecksum("md5", "098f6bcd4621d373cade4e832627b4f6"))
+	assert.Error(t, ValidateChecksum("sha256", "098f6bcd4621d373cade4e832627b4f6"))
+	assert.Error(t, ValidateChecksum("sha512", "not hex"))
+	assert.Error(t, ValidateChecksum("crc32", "d87f7e0c"))
+}
+
+func TestDownloadStopsWhenStreamExceedsDeclaredSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the response is chunked so its size is not known before it is read
+		for i := 0; i < 100; i++ {
+			if _, err := w.Write(bytes.Repeat([]byte("x"), 1024)); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+	destination, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(destination)
+
+	output, err := Download(log.NewMockLog(), DownloadInput{
+		SourceURL:            server.URL + "/file",
+		DestinationDirectory: destination,
+		SourceSize:           2048,
+	})
+
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, contracts.ErrorCodeOf(err))
+	assert.False(t, output.IsHashMatched)
+	files, _ := ioutil.ReadDir(destination)
+	assert.Empty(t, files)
+}
+
+func TestDownloadDeletesFileOnChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer server.Close()
+	destination, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(destination)
+
+	output, err := Download(log.NewMockLog(), DownloadInput{
+		SourceURL:            server.URL + "/file",
+		DestinationDirectory: destination,
+		SourceChecksums:      map[string]string{"sha256": checkMyHashSha256},
+	})
+
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, contracts.ErrorCodeOf(err))
+	assert.False(t, output.IsHashMatched)
+	assert.False(t, fileutil.Exists(output.LocalFilePath))
+}
+
+func TestFilePathFromURL(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"file:///var/packages/file.zip", "/var/packages/file.zip"},
+		{"file://localhost/var/packages/file.zip", "/var/packages/file.zip"},
+		{"file:///C:/packages/file.zip", "C:/packages/file.zip"},
+		{"file://server/share/file.zip", "//server/share/file.zip"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.url, func(t *testing.T) {
+			fileURL, err := url.Parse(testCase.url)
+			assert.NoError(t, err)
+			assert.Equal(t, filepath.FromSlash(testCase.expected), FilePathFromURL(fileURL))
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filelock"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// cacheLockSuffix ends the lock files of the entries of the cache being downloaded or reused
+	cacheLockSuffix = ".lock"
+	// cacheTempPrefix starts the files being added to the cache
+	cacheTempPrefix = ".tmp-"
+	// cacheLockTimeoutSeconds expires the locks left behind by a process that stopped while holding them
+	cacheLockTimeoutSeconds = 30 * 60
+)
+
+// cacheKeyAlgorithms are the checksums identifying a cached artifact, strongest first.
+// md5 is not collision resistant and never identifies an artifact.
+var cacheKeyAlgorithms = []string{"sha512", "sha384", "sha256"}
+
+// cacheDirectory is the directory of the shared artifact cache, replaced by the tests
+var cacheDirectory = appconfig.ArtifactCacheDirectory
+
+// cacheLockWait is how long a download waits for another download of the same artifact, then it downloads without the cache
+var cacheLockWait = 15 * time.Minute
+
+// cacheLockRetryInterval is the delay between two attempts to lock an entry of the cache
+var cacheLockRetryInterval = time.Second
+
+// cacheEntryLocks serialize the downloads of the same artifact in this process, the lock files serialize them
+// across the processes of the agent
+var (
+	cacheEntryLocks     = make(map[string]*sync.Mutex)
+	cacheEntryLocksLock sync.Mutex
+)
+
+// artifactCache is a directory of verified downloads named after their checksum
+type artifactCache struct {
+	directory string
+	maxSize   int64
+}
+
+// cacheFor returns the cache and the key of the artifact of a download, false when the download does not use the
+// cache or its checksums can not identify the artifact
+func cacheFor(input DownloadInput) (cache *artifactCache, key string, ok bool) {
+	if !input.Cached {
+		return nil, "", false
+	}
+	if key, ok = cacheKey(input.SourceChecksums); !ok {
+		return nil, "", false
+	}
+	config, _ := appconfig.Config(false)
+	if !config.ArtifactCache.Enabled {
+		return nil, "", false
+	}
+	return &artifactCache{directory: cacheDirectory, maxSize: int64(config.ArtifactCache.MaxSizeMB) * 1024 * 1024}, key, true
+}
+
+// RestoreCached copies the cached artifact of a cached download to its destination directory without resolving its
+// source, so the artifacts shared by several packages are located and downloaded once. It returns false when the
+// cache has no artifact matching the checksums of the download, the SourceURL of the input only names it in the logs.
+func RestoreCached(log log.T, input DownloadInput) (output DownloadOutput, ok bool) {
+	cache, key, ok := cacheFor(input)
+	if !ok {
+		return output, false
+	}
+	if _, err := os.Stat(filepath.Join(cache.directory, key)); err != nil {
+		return output, false
+	}
+	destinationDir := input.DestinationDirectory
+	if destinationDir == "" {
+		destinationDir = appconfig.DownloadRoot
+	}
+	if err := os.MkdirAll(destinationDir, appconfig.ReadWriteExecuteAccess); err != nil {
+		log.Warnf("Failed to reuse the cached artifact of %v: %v", input.SourceURL, err)
+		return output, false
+	}
+	unlock, err := cache.lock(key)
+	if err != nil {
+		log.Warnf("Failed to reuse the cached artifact of %v: %v", input.SourceURL, err)
+		return output, false
+	}
+	defer unlock()
+	// the artifact is named after its checksum, like in the cache, since its url is not known
+	localFilePath := filepath.Join(destinationDir, key)
+	if !cache.restore(log, key, input, localFilePath) {
+		return output, false
+	}
+	return DownloadOutput{LocalFilePath: localFilePath, IsUpdated: true, IsHashMatched: true}, true
+}
+
+// cacheKey returns the name of the artifact of the checksums in the cache, its strongest valid checksum
+func cacheKey(checksums map[string]string) (string, bool) {
+	for _, keyAlgorithm := range cacheKeyAlgorithms {
+		for algorithm, value := range checksums {
+			if strings.EqualFold(algorithm, keyAlgorithm) && ValidateChecksum(keyAlgorithm, value) == nil {
+				return keyAlgorithm + "-" + strings.ToLower(value), true
+			}
+		}
+	}
+	return "", false
+}
+
+// lock locks an entry of the cache against the other downloads of the artifact, in this process or in another one
+func (c *artifactCache) lock(key string) (unlock func(), err error) {
+	if err = os.MkdirAll(c.directory, appconfig.ReadWriteExecuteAccess); err != nil {
+		return nil, err
+	}
+	cacheEntryLocksLock.Lock()
+	entryLock, found := cacheEntryLocks[key]
+	if !found {
+		entryLock = &sync.Mutex{}
+		cacheEntryLocks[key] = entryLock
+	}
+	cacheEntryLocksLock.Unlock()
+	entryLock.Lock()
+
+	lockPath := filepath.Join(c.directory, key+cacheLockSuffix)
+	ownerID := filelock.GetOwnerIdForProcess()
+	deadline := time.Now().Add(cacheLockWait)
+	for {
+		locked, err := filelock.LockFile(lockPath, ownerID, cacheLockTimeoutSeconds)
+		if err != nil {
+			entryLock.Unlock()
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			entryLock.Unlock()
+			return nil, contracts.NewCodedError(contracts.ErrorCodeTimeout,
+				fmt.Errorf("another download of the artifact did not finish in %v", cacheLockWait))
+		}
+		time.Sleep(cacheLockRetryInterval)
+	}
+	return func() {
+		filelock.UnlockFile(lockPath, ownerID)
+		entryLock.Unlock()
+	}, nil
+}
+
+// restore copies a cached artifact to destFile, verifying it against the checksums of the download as it is copied.
+// An entry that does not match its checksums anymore is removed from the cache.
+func (c *artifactCache) restore(log log.T, key string, input DownloadInput, destFile string) bool {
+	entry := filepath.Join(c.directory, key)
+	if _, err := os.Stat(entry); err != nil {
+		return false
+	}
+	verifier := newChecksumVerifier(input.SourceChecksums, input.SourceSize)
+	if err := copyFile(entry, destFile, verifier); err != nil {
+		log.Warnf("Failed to reuse the cached artifact of %v: %v", input.SourceURL, err)
+		return false
+	}
+	if matched, _ := verifier.verify(log, input, destFile); !matched {
+		log.Warnf("Cached artifact %v does not match its checksums anymore, downloading %v again", key, input.SourceURL)
+		os.Remove(entry)
+		deletePartialDownload(destFile)
+		return false
+	}
+	// the modification time orders the entries from the least recently used
+	now := time.Now()
+	os.Chtimes(entry, now, now)
+	log.Infof("Reused the cached artifact of %v", input.SourceURL)
+	return true
+}
+
+// store adds a verified download to the cache, then removes the least recently used artifacts above the size of the cache
+func (c *artifactCache) store(log log.T, key string, srcFile string) {
+	temp, err := ioutil.TempFile(c.directory, cacheTempPrefix)
+	if err != nil {
+		log.Warnf("Failed to cache artifact %v: %v", key, err)
+		return
+	}
+	temp.Close()
+	if err = copyFile(srcFile, temp.Name(), nil); err == nil {
+		err = os.Rename(temp.Name(), filepath.Join(c.directory, key))
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		log.Warnf("Failed to cache artifact %v: %v", key, err)
+		return
+	}
+	c.evict(log, key)
+}
+
+// evict removes the least recently used artifacts until the cache fits in its size, the artifact just stored and the
+// artifacts being reused are kept
+func (c *artifactCache) evict(log log.T, keep string) {
+	files, err := ioutil.ReadDir(c.directory)
+	if err != nil {
+		return
+	}
+	locked := make(map[string]bool)
+	var entries []os.FileInfo
+	var size int64
+	for _, file := range files {
+		switch {
+		case file.IsDir():
+		case strings.HasSuffix(file.Name(), cacheLockSuffix):
+			locked[strings.TrimSuffix(file.Name(), cacheLockSuffix)] = true
+		case strings.HasPrefix(file.Name(), cacheTempPrefix):
+			size += file.Size()
+		default:
+			entries = append(entries, file)
+			size += file.Size()
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	for _, entry := range entries {
+		if size <= c.maxSize {
+			return
+		}
+		if entry.Name() == keep || locked[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.directory, entry.Name())); err != nil {
+			log.Debugf("Failed to remove cached artifact %v: %v", entry.Name(), err)
+			continue
+		}
+		log.Debugf("Removed cached artifact %v, the artifact cache is above %v bytes", entry.Name(), c.maxSize)
+		size -= entry.Size()
+	}
+}
+
+// copyFile copies a file and computes the checksums of the verifier as it is copied, the copy is deleted when it fails.
+// Unlike downloads, copies from and to the cache are not throttled.
+func copyFile(srcFile string, destFile string, verifier *checksumVerifier) error {
+	src, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := os.OpenFile(destFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = src
+	if verifier != nil {
+		verifier.reset()
+		reader = io.TeeReader(src, verifier)
+	}
+	_, err = io.Copy(dest, reader)
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(destFile)
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// startCacheTest serves the content of CheckMyHash.txt, counts its downloads and caches them in a temporary directory
+func startCacheTest(t *testing.T) (server *httptest.Server, downloads *int32, cleanup func()) {
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "CheckMyHash.txt"))
+	assert.NoError(t, err)
+	downloads = new(int32)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(downloads, 1)
+		w.Write(content)
+	}))
+	directory, err := ioutil.TempDir("", "artifactcache")
+	assert.NoError(t, err)
+	savedDirectory, savedInterval := cacheDirectory, cacheLockRetryInterval
+	cacheDirectory, cacheLockRetryInterval = filepath.Join(directory, "cache"), 10*time.Millisecond
+	return server, downloads, func() {
+		cacheDirectory, cacheLockRetryInterval = savedDirectory, savedInterval
+		server.Close()
+		os.RemoveAll(directory)
+	}
+}
+
+func cachedDownload(t *testing.T, sourceURL string) DownloadOutput {
+	destination, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(destination)
+	output, err := Download(log.NewMockLog(), DownloadInput{
+		SourceURL:            sourceURL,
+		DestinationDirectory: destination,
+		SourceChecksums:      map[string]string{"SHA256": checkMyHashSha256, "md5": checkMyHashMd5},
+		Cached:               true,
+	})
+	assert.NoError(t, err)
+	assert.True(t, output.IsHashMatched)
+	hash, _ := Sha256HashValue(log.NewMockLog(), output.LocalFilePath)
+	assert.Equal(t, checkMyHashSha256, hash)
+	return output
+}
+
+func TestCacheKey(t *testing.T) {
+	key, ok := cacheKey(map[string]string{"md5": checkMyHashMd5, "SHA256": checkMyHashSha256, "sha512": checkMyHashSha512})
+	assert.True(t, ok)
+	assert.Equal(t, "sha512-"+checkMyHashSha512, key)
+
+	_, ok = cacheKey(map[string]string{"md5": checkMyHashMd5})
+	assert.False(t, ok)
+	_, ok = cacheKey(map[string]string{"sha256": "../../etc/passwd"})
+	assert.False(t, ok)
+}
+
+func TestCachedDownloadReusesArtifact(t *testing.T) {
+	server, downloads, cleanup := startCacheTest(t)
+	defer cleanup()
+
+	cachedDownload(t, server.URL+"/v1/artifact")
+	cachedDownload(t, server.URL+"/v2/artifact")
+	assert.Equal(t, int32(1), atomic.LoadInt32(downloads))
+
+	// a corrupted entry is downloaded again
+	entry := filepath.Join(cacheDirectory, "sha256-"+checkMyHashSha256)
+	assert.NoError(t, ioutil.WriteFile(entry, []byte("tampered"), 0600))
+	cachedDownload(t, server.URL+"/v1/artifact")
+	assert.Equal(t, int32(2), atomic.LoadInt32(downloads))
+	hash, _ := Sha256HashValue(log.NewMockLog(), entry)
+	assert.Equal(t, checkMyHashSha256, hash)
+}
+
+func TestRestoreCached(t *testing.T) {
+	server, downloads, cleanup := startCacheTest(t)
+	defer cleanup()
+	destination, err := ioutil.TempDir("", "artifact")
+	assert.NoError(t, err)
+	defer os.RemoveAll(destination)
+	input := DownloadInput{
+		SourceURL:            "artifact.zip",
+		DestinationDirectory: destination,
+		SourceChecksums:      map[string]string{"sha256": checkMyHashSha256},
+		Cached:               true,
+	}
+
+	_, ok := RestoreCached(log.NewMockLog(), input)
+	assert.False(t, ok)
+
+	cachedDownload(t, server.URL+"/package1/artifact")
+	output, ok := RestoreCached(log.NewMockLog(), input)
+	assert.True(t, ok)
+	assert.True(t, output.IsHashMatched)
+	assert.Equal(t, filepath.Join(destination, "sha256-"+checkMyHashSha256), output.LocalFilePath)
+	hash, _ := Sha256HashValue(log.NewMockLog(), output.LocalFilePath)
+	assert.Equal(t, checkMyHashSha256, hash)
+	assert.Equal(t, int32(1), atomic.LoadInt32(downloads))
+
+	input.Cached = false
+	_, ok = RestoreCached(log.NewMockLog(), input)
+	assert.False(t, ok)
+}
+
+func TestConcurrentCachedDownloadsWaitForEachOther(t *testing.T) {
+	server, downloads, cleanup := startCacheTest(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cachedDownload(t, server.URL+"/artifact")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(downloads))
+	files, _ := ioutil.ReadDir(cacheDirectory)
+	assert.Len(t, files, 1)
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	directory, err := ioutil.TempDir("", "artifactcache")
+	assert.NoError(t, err)
+	defer os.RemoveAll(directory)
+	now := time.Now()
+	for i, name := range []string{"oldest", "locked", "older", "recent", "stored"} {
+		path := filepath.Join(directory, name)
+		assert.NoError(t, ioutil.WriteFile(path, make([]byte, 100), 0600))
+		modified := now.Add(time.Duration(i-10) * time.Minute)
+		assert.NoError(t, os.Chtimes(path, modified, modified))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(directory, "locked"+cacheLockSuffix), nil, 0600))
+	cache := &artifactCache{directory: directory, maxSize: 250}
+
+	cache.evict(log.NewMockLog(), "stored")
+
+	files, _ := ioutil.ReadDir(directory)
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"locked", "locked" + cacheLockSuffix, "stored"}, names)
+}
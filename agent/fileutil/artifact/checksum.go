@@ -0,0 +1,189 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+)
+
+// hashConstructors maps the supported checksum algorithms to their hash, an empty algorithm defaults to sha256
+var hashConstructors = map[string]func() hash.Hash{
+	"":       sha256.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+	"md5":    md5.New,
+}
+
+// hashStrengths orders the supported checksum algorithms from the weakest to the strongest
+var hashStrengths = map[string]int{
+	"md5":    1,
+	"":       2,
+	"sha256": 2,
+	"sha384": 3,
+	"sha512": 4,
+}
+
+// fipsUnapprovedHashes are not used to verify files when the agent runs in FIPS mode
+var fipsUnapprovedHashes = map[string]bool{
+	"md5": true,
+}
+
+// ValidateChecksum returns an error if the algorithm is not supported or the value is not a hex digest of it
+func ValidateChecksum(algorithm string, value string) error {
+	newHash, ok := hashConstructors[strings.ToLower(algorithm)]
+	if !ok {
+		return fmt.Errorf("unsupported checksum algorithm %v", algorithm)
+	}
+	if digest, err := hex.DecodeString(value); err != nil || len(digest) != newHash().Size() {
+		return fmt.Errorf("%v checksum %v is not a hex digest of %v characters", algorithm, value, newHash().Size()*2)
+	}
+	return nil
+}
+
+// checksumVerifier computes the declared checksums of a file as its bytes are written to it, so the file
+// is verified without being read again. Writes fail as soon as the file grows past its declared size.
+type checksumVerifier struct {
+	checksums map[string]string
+	size      int64
+	hashes    map[string]hash.Hash
+	written   int64
+}
+
+// newChecksumVerifier returns a verifier of the checksums, a size of 0 means the size is not declared
+func newChecksumVerifier(checksums map[string]string, size int64) *checksumVerifier {
+	verifier := &checksumVerifier{checksums: checksums, size: size}
+	verifier.reset()
+	return verifier
+}
+
+// reset forgets the bytes written so far, for a download starting over
+func (v *checksumVerifier) reset() {
+	v.written = 0
+	v.hashes = map[string]hash.Hash{}
+	for algorithm := range v.checksums {
+		algorithm = strings.ToLower(algorithm)
+		if newHash, ok := hashConstructors[algorithm]; ok && !(network.IsFipsMode() && fipsUnapprovedHashes[algorithm]) {
+			v.hashes[algorithm] = newHash()
+		}
+	}
+}
+
+// Write adds the bytes to the checksums, it fails without adding them when they exceed the declared size
+func (v *checksumVerifier) Write(p []byte) (int, error) {
+	if err := v.checkLength(v.written + int64(len(p))); err != nil {
+		return 0, err
+	}
+	v.written += int64(len(p))
+	for _, h := range v.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// checkLength fails when a file of the length exceeds the declared size, downloads check the length the
+// server announces before they start
+func (v *checksumVerifier) checkLength(length int64) error {
+	if v.size > 0 && length > v.size {
+		return contracts.NewCodedError(contracts.ErrorCodeChecksumMismatch,
+			fmt.Errorf("the download exceeds its declared size of %v bytes", v.size))
+	}
+	return nil
+}
+
+// hashFile adds the first length bytes of the file to the checksums, or the whole file for a negative length
+func (v *checksumVerifier) hashFile(filePath string, length int64) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	if length >= 0 {
+		reader = io.LimitReader(f, length)
+	}
+	_, err = io.Copy(v, reader)
+	return err
+}
+
+// verify checks the bytes written against the checksums of the download input. Checksums are keyed by
+// algorithm and may declare several digests; the file is verified against the strongest declared algorithm
+// that is supported, so a weaker digest never accepts a file that a stronger one rejects.
+func (v *checksumVerifier) verify(log log.T, input DownloadInput, filePath string) (bool, error) {
+	if v.size > 0 && v.written != v.size {
+		return false, contracts.NewCodedError(contracts.ErrorCodeChecksumMismatch,
+			fmt.Errorf("received %v bytes of %v, its declared size is %v bytes", v.written, input.SourceURL, v.size))
+	}
+	if len(v.checksums) == 0 {
+		return true, nil
+	}
+	//backwards compatibility for empty HashValues and HashTypes
+	if len(v.checksums) == 1 {
+		for hashAlgorithm, hashValue := range v.checksums {
+			// this is the only pair in the map
+			if hashAlgorithm == "" || hashValue == "" {
+				return true, nil
+			}
+		}
+	}
+
+	if algorithm, ok := v.strongestAlgorithm(log); ok {
+		if strings.EqualFold(v.checksums[algorithm], hex.EncodeToString(v.hashes[strings.ToLower(algorithm)].Sum(nil))) {
+			return true, nil
+		}
+		log.Warnf("%v checksum of %v does not match the expected value", algorithm, filePath)
+	}
+
+	//if a supported hash algorithm was not provided, jut return an error
+	if len(v.hashes) == 0 {
+		return false, fmt.Errorf("no supported algorithm was provided for downloadinput %v", input)
+	}
+	return false, contracts.NewCodedError(contracts.ErrorCodeChecksumMismatch, fmt.Errorf("failed to verify hash of downloadinput %v", input))
+}
+
+// strongestAlgorithm returns the strongest declared checksum algorithm that is supported, an explicit sha256
+// is preferred over the empty algorithm defaulting to it
+func (v *checksumVerifier) strongestAlgorithm(log log.T) (string, bool) {
+	strongest, found := "", false
+	for algorithm := range v.checksums {
+		if _, ok := v.hashes[strings.ToLower(algorithm)]; !ok {
+			log.Debugf("skipping unsupported or not FIPS approved checksum algorithm %v", algorithm)
+			continue
+		}
+		if !found || hashStrength(algorithm) > hashStrength(strongest) {
+			strongest, found = algorithm, true
+		}
+	}
+	return strongest, found
+}
+
+func hashStrength(algorithm string) int {
+	strength := hashStrengths[strings.ToLower(algorithm)] * 2
+	if algorithm != "" {
+		strength++
+	}
+	return strength
+}
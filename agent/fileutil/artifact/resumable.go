@@ -0,0 +1,289 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
+)
+
+const (
+	// partialFileSuffix is appended to the destination file while it is downloaded
+	partialFileSuffix = ".part"
+	// partialStateSuffix is appended to the destination file for the state of a partial download
+	partialStateSuffix = ".part.json"
+	// chunkRetryLimit is the number of attempts to download a chunk
+	chunkRetryLimit = 5
+)
+
+var (
+	// downloadChunkSize is the size of the ranges a resumable download is split into
+	downloadChunkSize int64 = 8 * 1024 * 1024
+	// chunkRetryDelay is the delay before retrying a chunk, doubled after each failed attempt
+	chunkRetryDelay = 2 * time.Second
+)
+
+// partialDownload identifies the remote file a partial download belongs to, a partial download is only
+// resumed when the file at the url and its expected checksums have not changed
+type partialDownload struct {
+	URL          string            `json:"url"`
+	ETag         string            `json:"etag"`
+	LastModified string            `json:"lastModified"`
+	Size         int64             `json:"size"`
+	Checksums    map[string]string `json:"checksums"`
+}
+
+// resumableHTTPDownload downloads a file in ranges with per-range retries, the ranges downloaded before
+// a failure are kept and the next download of the same file resumes after them.
+// Servers that do not support range requests are downloaded with httpDownload.
+// The checksums of the verifier are computed as the ranges are written, the bytes of a previous attempt are hashed
+// from the partial file.
+func resumableHTTPDownload(ctx context.Context, log log.T, fileURL string, headers map[string]string, destFile string, verifier *checksumVerifier, progress ProgressFunc) (output DownloadOutput, err error) {
+	log.Debugf("attempting resumable http/https download %v", destFile)
+	parsedURL, err := url.Parse(fileURL)
+	if err != nil {
+		return output, err
+	}
+	client, timings := newArtifactHTTPClient(parsedURL.Host, headers)
+	defer func() { output.Timings = output.Timings.plus(timings.get()) }()
+	eTagFile := destFile + ".etag"
+	partFile := destFile + partialFileSuffix
+	stateFile := destFile + partialStateSuffix
+
+	remote, rangesSupported, err := probeRemoteFile(ctx, client, fileURL)
+	if err != nil {
+		return output, err
+	}
+	if !rangesSupported {
+		log.Debugf("%v does not support range requests, downloading it at once", fileURL)
+		return httpDownload(ctx, log, fileURL, headers, destFile, verifier, progress)
+	}
+	if err = verifier.checkLength(remote.Size); err != nil {
+		return output, err
+	}
+	remote.Checksums = verifier.checksums
+
+	if remote.ETag != "" && fileutil.Exists(destFile) && fileutil.Exists(eTagFile) {
+		if existingETag, err := fileutil.ReadAllText(eTagFile); err == nil && existingETag == remote.ETag {
+			log.Debugf("Unchanged file.")
+			return DownloadOutput{LocalFilePath: destFile, IsUpdated: false}, nil
+		}
+	}
+
+	// resume only the partial download of the same remote file
+	var offset int64
+	var previous partialDownload
+	if jsonutil.UnmarshalFile(stateFile, &previous) == nil && reflect.DeepEqual(previous, remote) {
+		if info, statErr := os.Stat(partFile); statErr == nil && info.Size() <= remote.Size {
+			offset = info.Size()
+		}
+	}
+	if offset == 0 {
+		fileutil.DeleteFile(partFile)
+		var content string
+		if content, err = jsonutil.Marshal(remote); err != nil {
+			return output, err
+		}
+		if err = fileutil.WriteAllText(stateFile, content); err != nil {
+			return output, fmt.Errorf("failed to write partial download state %v, %v", stateFile, err)
+		}
+	} else {
+		log.Infof("resuming download of %v at %v of %v bytes", fileURL, offset, remote.Size)
+	}
+
+	file, err := os.OpenFile(partFile, os.O_WRONLY|os.O_CREATE, appconfig.ReadWriteAccess)
+	if err != nil {
+		return output, fmt.Errorf("failed to open %v, %v", partFile, err)
+	}
+	verifier.reset()
+	if err = verifier.hashFile(partFile, offset); err != nil {
+		file.Close()
+		return output, fmt.Errorf("failed to read %v, %v", partFile, err)
+	}
+	for offset < remote.Size {
+		end := offset + downloadChunkSize - 1
+		if end >= remote.Size {
+			end = remote.Size - 1
+		}
+		if err = downloadChunkWithRetry(ctx, log, client, fileURL, remote, file, offset, end, verifier, progress); err != nil {
+			file.Close()
+			if _, changed := err.(remoteFileChangedError); changed {
+				// the downloaded ranges belong to another version of the file
+				fileutil.DeleteFile(partFile)
+				fileutil.DeleteFile(stateFile)
+			}
+			return output, err
+		}
+		offset = end + 1
+	}
+	if err = file.Close(); err != nil {
+		return output, err
+	}
+
+	fileutil.DeleteFile(destFile)
+	if err = os.Rename(partFile, destFile); err != nil {
+		return output, fmt.Errorf("failed to move %v to %v, %v", partFile, destFile, err)
+	}
+	fileutil.DeleteFile(stateFile)
+	if remote.ETag != "" {
+		if err = fileutil.WriteAllText(eTagFile, remote.ETag); err != nil {
+			log.Errorf("failed to write eTagfile %v, %v ", eTagFile, err)
+			return output, err
+		}
+	} else {
+		fileutil.DeleteFile(eTagFile)
+	}
+	log.Infof("%s with %v bytes downloaded", destFile, remote.Size)
+	return DownloadOutput{LocalFilePath: destFile, IsUpdated: true}, nil
+}
+
+// deletePartialDownload removes the downloaded file and its partial download state,
+// so a file that failed verification is downloaded from the start next time
+func deletePartialDownload(destFile string) {
+	fileutil.DeleteFile(destFile)
+	fileutil.DeleteFile(destFile + ".etag")
+	fileutil.DeleteFile(destFile + partialFileSuffix)
+	fileutil.DeleteFile(destFile + partialStateSuffix)
+}
+
+// probeRemoteFile requests the first byte of the file to learn its size and version and whether
+// the server supports range requests
+func probeRemoteFile(ctx context.Context, client *http.Client, fileURL string) (remote partialDownload, rangesSupported bool, err error) {
+	request, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Range", "bytes=0-0")
+	resp, err := client.Do(request)
+	if err != nil {
+		return remote, false, fmt.Errorf("failed to download from http/https, %v", err)
+	}
+	defer resp.Body.Close()
+
+	remote = partialDownload{URL: fileURL, ETag: resp.Header.Get("Etag"), LastModified: resp.Header.Get("Last-Modified")}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range is "bytes 0-0/<size>"
+		contentRange := resp.Header.Get("Content-Range")
+		slash := strings.LastIndex(contentRange, "/")
+		if slash < 0 {
+			return remote, false, nil
+		}
+		remote.Size, err = strconv.ParseInt(contentRange[slash+1:], 10, 64)
+		return remote, err == nil && remote.Size > 0, nil
+	case http.StatusOK:
+		return remote, false, nil
+	default:
+		return remote, false, HTTPStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+}
+
+// downloadChunkWithRetry downloads a range of the file, retrying with an exponential backoff
+func downloadChunkWithRetry(ctx context.Context, log log.T, client *http.Client, fileURL string, remote partialDownload, file *os.File, start int64, end int64, verifier *checksumVerifier, progress ProgressFunc) (err error) {
+	delay := chunkRetryDelay
+	for attempt := 1; attempt <= chunkRetryLimit; attempt++ {
+		if err = downloadChunk(ctx, client, fileURL, remote, file, start, end, verifier, progress); err == nil {
+			return nil
+		}
+		// the checksums may hold some bytes of the failed attempt, they are computed again up to the chunk
+		verifier.reset()
+		if hashErr := verifier.hashFile(file.Name(), start); hashErr != nil {
+			return hashErr
+		}
+		if _, changed := err.(remoteFileChangedError); changed {
+			return err
+		}
+		if ctx.Err() != nil {
+			// the ranges downloaded so far are kept for the next download
+			return ctx.Err()
+		}
+		log.Warnf("attempt %v to download bytes %v-%v of %v failed, %v", attempt, start, end, fileURL, err)
+		if attempt < chunkRetryLimit {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+	}
+	return fmt.Errorf("failed to download bytes %v-%v of %v after %v attempts, %v", start, end, fileURL, chunkRetryLimit, err)
+}
+
+// remoteFileChangedError reports that the remote file changed during the download
+type remoteFileChangedError struct {
+	url string
+}
+
+func (e remoteFileChangedError) Error() string {
+	return fmt.Sprintf("%v changed during the download", e.url)
+}
+
+// downloadChunk downloads the bytes start to end of the file and writes them at the same offset
+func downloadChunk(ctx context.Context, client *http.Client, fileURL string, remote partialDownload, file *os.File, start int64, end int64, verifier *checksumVerifier, progress ProgressFunc) error {
+	request, err := http.NewRequest("GET", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", start, end))
+	// the server returns the whole file instead of the range if it changed
+	if remote.ETag != "" {
+		request.Header.Set("If-Range", remote.ETag)
+	} else if remote.LastModified != "" {
+		request.Header.Set("If-Range", remote.LastModified)
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return remoteFileChangedError{url: fileURL}
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		return HTTPStatusError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+
+	if _, err = file.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	// downloads are rate limited and slowed down during the configured peak hours
+	src := throttle.NewDownloadReader(newProgressReader(resp.Body, start, remote.Size, progress))
+	length := end - start + 1
+	written, err := io.Copy(file, io.TeeReader(io.LimitReader(src, length), verifier))
+	if err != nil {
+		return err
+	}
+	if written != length {
+		return fmt.Errorf("received %v of %v bytes", written, length)
+	}
+	return nil
+}
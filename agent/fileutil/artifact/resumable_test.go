@@ -0,0 +1,307 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeServer serves content with range support and records the requested ranges,
+// requests for failRange fail the first failures times
+type rangeServer struct {
+	lock    sync.Mutex
+	content []byte
+	eTag    string
+	ranges  []string
+	// authorizations are the Authorization headers of the requests
+	authorizations []string
+	failRange      string
+	failures       int
+	// changed replaces the content after the probe request
+	changed []byte
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	content, eTag := s.content, s.eTag
+	requested := r.Header.Get("Range")
+	s.ranges = append(s.ranges, requested)
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+	fail := s.failures > 0 && requested == s.failRange
+	if fail {
+		s.failures--
+	}
+	if s.changed != nil && requested != "bytes=0-0" {
+		s.content, s.eTag, s.changed = s.changed, `"v2"`, nil
+		content, eTag = s.content, s.eTag
+	}
+	s.lock.Unlock()
+
+	if fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Etag", eTag)
+	http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+}
+
+func setupResumableDownload(t *testing.T, content string) (server *rangeServer, url string, destFile string, cleanup func()) {
+	savedChunkSize, savedRetryDelay := downloadChunkSize, chunkRetryDelay
+	downloadChunkSize, chunkRetryDelay = 4, time.Millisecond
+	server = &rangeServer{content: []byte(content), eTag: `"v1"`}
+	httpServer := httptest.NewServer(server)
+	dir, err := ioutil.TempDir("", "resumable")
+	assert.NoError(t, err)
+	cleanup = func() {
+		downloadChunkSize, chunkRetryDelay = savedChunkSize, savedRetryDelay
+		httpServer.Close()
+		os.RemoveAll(dir)
+	}
+	return server, httpServer.URL + "/file", filepath.Join(dir, "file"), cleanup
+}
+
+func TestResumableHTTPDownloadInChunks(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	output, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	assert.True(t, output.IsUpdated)
+	content, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, []string{"bytes=0-0", "bytes=0-3", "bytes=4-7", "bytes=8-9"}, server.ranges)
+	assert.False(t, fileutil.Exists(destFile+partialFileSuffix))
+	assert.False(t, fileutil.Exists(destFile+partialStateSuffix))
+
+	// an unchanged file is not downloaded again
+	output, err = resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	assert.False(t, output.IsUpdated)
+}
+
+func TestResumableHTTPDownloadResumesPartialDownload(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	checksums := map[string]string{"sha256": "abc"}
+	state, _ := jsonutil.Marshal(partialDownload{URL: url, ETag: `"v1"`, Size: 10, Checksums: checksums})
+	assert.NoError(t, ioutil.WriteFile(destFile+partialStateSuffix, []byte(state), 0600))
+	assert.NoError(t, ioutil.WriteFile(destFile+partialFileSuffix, []byte("0123"), 0600))
+
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(checksums, 0), nil)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, []string{"bytes=0-0", "bytes=4-7", "bytes=8-9"}, server.ranges)
+}
+
+func TestResumableHTTPDownloadRestartsChangedFile(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	// the partial download belongs to a previous version of the file
+	state, _ := jsonutil.Marshal(partialDownload{URL: url, ETag: `"v0"`, Size: 10})
+	assert.NoError(t, ioutil.WriteFile(destFile+partialStateSuffix, []byte(state), 0600))
+	assert.NoError(t, ioutil.WriteFile(destFile+partialFileSuffix, []byte("abcd"), 0600))
+
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, "bytes=0-3", server.ranges[1])
+}
+
+func TestResumableHTTPDownloadRetriesChunk(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+	server.failRange, server.failures = "bytes=0-3", 2
+
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, []string{"bytes=0-0", "bytes=0-3", "bytes=0-3", "bytes=0-3", "bytes=4-7", "bytes=8-9"}, server.ranges)
+}
+
+func TestResumableHTTPDownloadComputesChecksumsAcrossRetriesAndResume(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+	// sha256 of 0123456789
+	checksums := map[string]string{"sha256": "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882"}
+	server.failRange, server.failures = "bytes=8-9", chunkRetryLimit+1
+	input := DownloadInput{SourceURL: url, SourceChecksums: checksums}
+
+	verifier := newChecksumVerifier(checksums, 10)
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, verifier, nil)
+	assert.Error(t, err)
+
+	// the resumed download hashes the chunks of the failed attempt from the partial file
+	verifier = newChecksumVerifier(checksums, 10)
+	_, err = resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, verifier, nil)
+	assert.NoError(t, err)
+	matched, err := verifier.verify(log.NewMockLog(), input, destFile)
+	assert.NoError(t, err)
+	assert.True(t, matched)
+}
+
+func TestResumableHTTPDownloadFailsWhenLargerThanDeclared(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 8), nil)
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, contracts.ErrorCodeOf(err))
+	// only the probe was requested
+	assert.Equal(t, []string{"bytes=0-0"}, server.ranges)
+}
+
+func TestResumableHTTPDownloadKeepsChunksAfterFailure(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+	server.failRange, server.failures = "bytes=4-7", chunkRetryLimit
+
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.Error(t, err)
+	assert.False(t, fileutil.Exists(destFile))
+
+	// the next attempt downloads the remaining chunks only
+	server.ranges = nil
+	_, err = resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, []string{"bytes=0-0", "bytes=4-7", "bytes=8-9"}, server.ranges)
+}
+
+func TestResumableHTTPDownloadDiscardsChunksOfChangedFile(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+	// the file is replaced between the probe and the first chunk
+	server.changed = []byte("abcdefghij")
+
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.Error(t, err)
+	assert.False(t, fileutil.Exists(destFile+partialFileSuffix))
+	assert.False(t, fileutil.Exists(destFile+partialStateSuffix))
+
+	_, err = resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadFile(destFile)
+	assert.Equal(t, "abcdefghij", string(content))
+}
+
+func TestResumableHTTPDownloadReportsProgress(t *testing.T) {
+	_, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	checksums := map[string]string{"sha256": "abc"}
+	state, _ := jsonutil.Marshal(partialDownload{URL: url, ETag: `"v1"`, Size: 10, Checksums: checksums})
+	assert.NoError(t, ioutil.WriteFile(destFile+partialStateSuffix, []byte(state), 0600))
+	assert.NoError(t, ioutil.WriteFile(destFile+partialFileSuffix, []byte("0123"), 0600))
+
+	var downloaded []int64
+	progress := func(bytes int64, total int64) {
+		assert.Equal(t, int64(10), total)
+		downloaded = append(downloaded, bytes)
+	}
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(checksums, 0), progress)
+	assert.NoError(t, err)
+	// the resumed download counts the bytes of the previous attempt
+	assert.True(t, len(downloaded) >= 2)
+	assert.True(t, downloaded[0] > 4)
+	assert.Equal(t, int64(10), downloaded[len(downloaded)-1])
+}
+
+func TestResumableHTTPDownloadCancelled(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	// cancel once the first chunk is downloaded
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress := func(downloaded int64, total int64) {
+		if downloaded >= 4 {
+			cancel()
+		}
+	}
+	_, err := resumableHTTPDownload(ctx, log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), progress)
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, fileutil.Exists(destFile))
+
+	server.ranges = nil
+	_, err = resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bytes=0-0", "bytes=4-7", "bytes=8-9"}, server.ranges)
+}
+
+func TestResumableHTTPDownloadHeaders(t *testing.T) {
+	server, url, destFile, cleanup := setupResumableDownload(t, "0123456")
+	defer cleanup()
+
+	headers := map[string]string{"Authorization": "Bearer token"}
+	_, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, headers, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer token", "Bearer token", "Bearer token"}, server.authorizations)
+}
+
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, request)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func TestHeaderTransportOnlySendsHeadersToItsHost(t *testing.T) {
+	base := &recordingTransport{}
+	transport := &headerTransport{base: base, host: "mirror.example.com", headers: map[string]string{"Authorization": "Bearer token"}}
+
+	mirrorRequest, _ := http.NewRequest("GET", "https://mirror.example.com/file", nil)
+	otherRequest, _ := http.NewRequest("GET", "https://other.example.com/file", nil)
+	transport.RoundTrip(mirrorRequest)
+	transport.RoundTrip(otherRequest)
+
+	assert.Equal(t, "Bearer token", base.requests[0].Header.Get("Authorization"))
+	assert.Empty(t, mirrorRequest.Header.Get("Authorization"))
+	assert.Empty(t, base.requests[1].Header.Get("Authorization"))
+}
+
+func TestResumableHTTPDownloadTimings(t *testing.T) {
+	_, url, destFile, cleanup := setupResumableDownload(t, "0123456789")
+	defer cleanup()
+
+	output, err := resumableHTTPDownload(context.Background(), log.NewMockLog(), url, nil, destFile, newChecksumVerifier(nil, 0), nil)
+	assert.NoError(t, err)
+	assert.False(t, output.Timings.IsZero())
+	assert.True(t, output.Timings.Connect > 0)
+	assert.True(t, output.Timings.FirstByte > 0)
+	assert.True(t, output.Timings.Transfer > 0)
+	// the test server is plain http
+	assert.Equal(t, time.Duration(0), output.Timings.TLS)
+}
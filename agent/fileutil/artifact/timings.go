@@ -0,0 +1,173 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package artifact
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// DownloadTimings break down the time spent in the http/s requests of a download, summed over its requests,
+// so a slow download can be attributed to name resolution, the network or the server.
+// FirstByte is the wait for the response once the request is sent, Transfer the time reading the response body.
+type DownloadTimings struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Transfer  time.Duration
+}
+
+// IsZero returns true when no http/s request was timed, such as for s3 or file downloads
+func (t DownloadTimings) IsZero() bool {
+	return t == DownloadTimings{}
+}
+
+// plus returns the sum of the timings
+func (t DownloadTimings) plus(other DownloadTimings) DownloadTimings {
+	return DownloadTimings{
+		DNS:       t.DNS + other.DNS,
+		Connect:   t.Connect + other.Connect,
+		TLS:       t.TLS + other.TLS,
+		FirstByte: t.FirstByte + other.FirstByte,
+		Transfer:  t.Transfer + other.Transfer,
+	}
+}
+
+func (t DownloadTimings) String() string {
+	return fmt.Sprintf("dns %v, connect %v, tls %v, first byte %v, transfer %v", t.DNS, t.Connect, t.TLS, t.FirstByte, t.Transfer)
+}
+
+// timingRecorder sums the timings of the requests sent through its transport
+type timingRecorder struct {
+	lock    sync.Mutex
+	timings DownloadTimings
+}
+
+func (r *timingRecorder) add(field *time.Duration, start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	*field += time.Since(start)
+}
+
+// get returns the timings recorded so far
+func (r *timingRecorder) get() DownloadTimings {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.timings
+}
+
+// transport returns a round tripper timing the requests sent through base
+func (r *timingRecorder) transport(base http.RoundTripper) http.RoundTripper {
+	return &timingTransport{base: base, recorder: r}
+}
+
+// timingTransport records the timings of each request with an http client trace
+type timingTransport struct {
+	base     http.RoundTripper
+	recorder *timingRecorder
+}
+
+func (t *timingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	r := t.recorder
+	// the callbacks of a request can run concurrently, such as the dials to the addresses of a host
+	var lock sync.Mutex
+	var dnsStart, tlsStart, wroteRequest time.Time
+	connectStarts := map[string]time.Time{}
+	clientTrace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			r.add(&r.timings.DNS, dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			lock.Lock()
+			defer lock.Unlock()
+			connectStarts[network+addr] = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			// only the dial that connected counts
+			if err == nil {
+				r.add(&r.timings.Connect, connectStarts[network+addr])
+			}
+		},
+		TLSHandshakeStart: func() {
+			lock.Lock()
+			defer lock.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			r.add(&r.timings.TLS, tlsStart)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			lock.Lock()
+			defer lock.Unlock()
+			wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			lock.Lock()
+			defer lock.Unlock()
+			r.add(&r.timings.FirstByte, wroteRequest)
+		},
+	}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), clientTrace))
+	response, err := t.base.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+	response.Body = &timedBody{ReadCloser: response.Body, recorder: r, start: time.Now()}
+	return response, nil
+}
+
+// timedBody records the time from the response headers until the body is read to its end or closed
+type timedBody struct {
+	io.ReadCloser
+	recorder *timingRecorder
+	start    time.Time
+	once     sync.Once
+}
+
+func (b *timedBody) done() {
+	b.once.Do(func() { b.recorder.add(&b.recorder.timings.Transfer, b.start) })
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.done()
+	return b.ReadCloser.Close()
+}
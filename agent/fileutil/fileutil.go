@@ -55,7 +55,7 @@ func DeleteFile(filepath string) (err error) {
 // DeleteDirectory deletes a directory and all its content.
 func DeleteDirectory(dirName string) (err error) {
 
-	return os.RemoveAll(dirName)
+	return os.RemoveAll(LongPath(dirName))
 }
 
 // ReadAllText reads all content from the specified file
@@ -147,7 +147,7 @@ func removeInvalidColon(pluginName string) string {
 // MakeDirs create the directories along the path if missing.
 func MakeDirs(destinationDir string) (err error) {
 	// create directory
-	err = fs.MkdirAll(destinationDir, appconfig.ReadWriteAccess)
+	err = fs.MkdirAll(LongPath(destinationDir), appconfig.ReadWriteAccess)
 	if err != nil {
 		err = fmt.Errorf("failed to create directory %v. %v", destinationDir, err)
 	}
@@ -157,7 +157,7 @@ func MakeDirs(destinationDir string) (err error) {
 // MakeDirsWithExecuteAccess create the directories along the path if missing.
 func MakeDirsWithExecuteAccess(destinationDir string) (err error) {
 	// create directory
-	if err = fs.MkdirAll(destinationDir, appconfig.ReadWriteExecuteAccess); err != nil {
+	if err = fs.MkdirAll(LongPath(destinationDir), appconfig.ReadWriteExecuteAccess); err != nil {
 		err = fmt.Errorf("failed to create directory %v. %v", destinationDir, err)
 	}
 	return
@@ -313,10 +313,11 @@ func isUnderDir(childPath, parentDirPath string) bool {
 // Unzip unzips the installation package (using platform agnostic zip functionality)
 // For platform specific implementation that uses tar.gz on Linux, use Uncompress
 func Unzip(src, dest string) error {
-	r, err := zip.OpenReader(src)
+	r, err := zip.OpenReader(LongPath(src))
 	if err != nil {
 		return err
 	}
+	dest = LongPath(dest)
 	defer func() {
 		if err := r.Close(); err != nil {
 			return
@@ -371,3 +372,68 @@ func Unzip(src, dest string) error {
 
 	return nil
 }
+
+// ZipFiles creates a zip archive at dest containing the given files and directories.
+// Entries are named after the absolute path of the source without its volume name,
+// so files with the same base name in different directories do not collide.
+// The names of the zipped files are returned in the order they were added.
+func ZipFiles(dest string, paths []string) (entries []string, err error) {
+	zipFile, err := os.OpenFile(dest, appconfig.FileFlagsCreateOrTruncate, appconfig.ReadWriteAccess)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := zipFile.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	writer := zip.NewWriter(zipFile)
+	defer func() {
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	addFile := func(filePath string, info os.FileInfo) error {
+		name := strings.TrimPrefix(filePath, filepath.VolumeName(filePath))
+		name = strings.TrimLeft(filepath.ToSlash(name), "/")
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		header.Method = zip.Deflate
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		if _, err = io.Copy(w, src); err != nil {
+			return err
+		}
+		entries = append(entries, name)
+		return nil
+	}
+
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		err = filepath.Walk(p, func(filePath string, info os.FileInfo, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			return addFile(filePath, info)
+		})
+		if err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
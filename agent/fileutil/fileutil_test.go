@@ -15,6 +15,7 @@ package fileutil
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -269,3 +270,32 @@ func TestAppendToFile(t *testing.T) {
 	assert.NoError(t, err, "expected no error")
 	fmt.Println(filePath)
 }
+
+func TestZipFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zipfiles")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	reportDir := filepath.Join(dir, "reports")
+	assert.NoError(t, os.MkdirAll(filepath.Join(reportDir, "nested"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(reportDir, "nested", "report.txt"), []byte("report"), 0600))
+	logFile := filepath.Join(dir, "app.log")
+	assert.NoError(t, ioutil.WriteFile(logFile, []byte("log"), 0600))
+
+	zipPath := filepath.Join(dir, "artifacts.zip")
+	entries, err := ZipFiles(zipPath, []string{logFile, reportDir})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	extractDir := filepath.Join(dir, "extract")
+	assert.NoError(t, Unzip(zipPath, extractDir))
+	content, err := ioutil.ReadFile(filepath.Join(extractDir, filepath.FromSlash(entries[0])))
+	assert.NoError(t, err)
+	assert.Equal(t, "log", string(content))
+	content, err = ioutil.ReadFile(filepath.Join(extractDir, filepath.FromSlash(entries[1])))
+	assert.NoError(t, err)
+	assert.Equal(t, "report", string(content))
+
+	_, err = ZipFiles(zipPath, []string{filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+}
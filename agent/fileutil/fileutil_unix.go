@@ -94,6 +94,20 @@ func GetDiskSpaceInfo() (diskSpaceInfo DiskSpaceInfo, err error) {
 	// get filesystem statistics
 	syscall.Statfs(wd, &stat)
 
+	return diskSpaceInfoOfStat(stat), nil
+}
+
+// GetDiskSpaceInfoOfPath returns DiskSpaceInfo with available, free, and total bytes of the disk holding the path
+func GetDiskSpaceInfoOfPath(path string) (diskSpaceInfo DiskSpaceInfo, err error) {
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(path, &stat); err != nil {
+		return
+	}
+	return diskSpaceInfoOfStat(stat), nil
+}
+
+// diskSpaceInfoOfStat calculates the bytes of DiskSpaceInfo from filesystem statistics
+func diskSpaceInfoOfStat(stat syscall.Statfs_t) DiskSpaceInfo {
 	// get block size
 	bSize := uint64(stat.Bsize)
 
@@ -103,7 +117,7 @@ func GetDiskSpaceInfo() (diskSpaceInfo DiskSpaceInfo, err error) {
 		AvailBytes: (int64)((uint64)(stat.Bavail) * bSize), // available space = # of available blocks * block size
 		FreeBytes:  (int64)(stat.Bfree * bSize),            // free space = # of free blocks * block size
 		TotalBytes: (int64)(stat.Blocks * bSize),           // total space = # of total blocks * block size
-	}, nil
+	}
 }
 
 // HardenDataFolder sets permission of %PROGRAM_DATA% folder for Windows. In
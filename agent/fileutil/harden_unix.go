@@ -17,6 +17,7 @@ package fileutil
 
 import (
 	"os"
+	"path/filepath"
 	"syscall"
 )
 
@@ -49,3 +50,21 @@ func Harden(path string) (err error) {
 	}
 	return
 }
+
+// ApplyFilePermissions removes the permissions of the umask from the path and everything under it, restrictAcl only
+// applies on Windows.
+func ApplyFilePermissions(path string, umask os.FileMode, restrictAcl bool) error {
+	if umask == 0 {
+		return nil
+	}
+	return filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// the permissions of a symlink are the ones of its target
+		if fi.Mode()&os.ModeSymlink != 0 || fi.Mode().Perm()&umask == 0 {
+			return nil
+		}
+		return os.Chmod(p, fi.Mode()&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)|fi.Mode().Perm()&^umask)
+	})
+}
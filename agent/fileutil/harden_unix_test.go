@@ -0,0 +1,53 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyFilePermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "permissions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	subDir := filepath.Join(dir, "downloads")
+	assert.NoError(t, os.Mkdir(subDir, 0755))
+	assert.NoError(t, os.Chmod(subDir, 0755))
+	script := filepath.Join(subDir, "script.sh")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("echo"), 0755))
+	assert.NoError(t, os.Chmod(script, 0755))
+	stdout := filepath.Join(dir, "stdout")
+	assert.NoError(t, ioutil.WriteFile(stdout, []byte("output"), 0644))
+	assert.NoError(t, os.Chmod(stdout, 0644))
+
+	// without umask the permissions are kept
+	assert.NoError(t, ApplyFilePermissions(dir, 0, true))
+	fi, _ := os.Stat(script)
+	assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+
+	assert.NoError(t, ApplyFilePermissions(dir, 0077, false))
+	for path, expected := range map[string]os.FileMode{subDir: 0700, script: 0700, stdout: 0600} {
+		fi, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, fi.Mode().Perm(), path)
+	}
+}
@@ -66,6 +66,15 @@ func Harden(path string) (err error) {
 	return
 }
 
+// ApplyFilePermissions limits the path and everything under it to Administrators and SYSTEM when restrictAcl is set,
+// the files created later in a directory inherit its ACL. The umask only applies on Linux and macOS.
+func ApplyFilePermissions(path string, umask os.FileMode, restrictAcl bool) error {
+	if !restrictAcl {
+		return nil
+	}
+	return RecursivelyHarden(path)
+}
+
 // Allocate memory space for SID.
 func mallocSID(sidSize int) (sidPtr *windows.SID, sidLen uint32) {
 	var sid = make([]byte, sidSize)
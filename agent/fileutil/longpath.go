@@ -0,0 +1,51 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fileutil
+
+import "strings"
+
+const (
+	// maxShortPathLength is the length from which Windows refuses a path without the extended-length prefix, a
+	// directory must leave room for a file name of 8.3 characters
+	maxShortPathLength = 248
+
+	extendedLengthPrefix    = `\\?\`
+	extendedLengthUNCPrefix = `\\?\UNC\`
+)
+
+// extendedLengthPath adds the extended-length prefix to an absolute Windows path, the path of a network share is
+// given the prefix of UNC paths. The path must be clean since Windows does not resolve the . and .. elements of
+// extended-length paths.
+func extendedLengthPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, extendedLengthPrefix), strings.HasPrefix(path, `\\.\`):
+		return path
+	case strings.HasPrefix(path, `\\`):
+		return extendedLengthUNCPrefix + path[2:]
+	default:
+		return extendedLengthPrefix + path
+	}
+}
+
+// trimExtendedLengthPrefix removes the extended-length prefix of a Windows path
+func trimExtendedLengthPrefix(path string) string {
+	switch {
+	case strings.HasPrefix(path, extendedLengthUNCPrefix):
+		return `\\` + path[len(extendedLengthUNCPrefix):]
+	case strings.HasPrefix(path, extendedLengthPrefix):
+		return path[len(extendedLengthPrefix):]
+	default:
+		return path
+	}
+}
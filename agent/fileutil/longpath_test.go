@@ -0,0 +1,33 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fileutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendedLengthPath(t *testing.T) {
+	assert.Equal(t, `\\?\C:\ProgramData\Amazon\SSM\Packages\node_modules`, extendedLengthPath(`C:\ProgramData\Amazon\SSM\Packages\node_modules`))
+	assert.Equal(t, `\\?\UNC\server\share\packages`, extendedLengthPath(`\\server\share\packages`))
+	assert.Equal(t, `\\?\C:\packages`, extendedLengthPath(`\\?\C:\packages`))
+	assert.Equal(t, `\\.\pipe\agent`, extendedLengthPath(`\\.\pipe\agent`))
+}
+
+func TestTrimExtendedLengthPrefix(t *testing.T) {
+	assert.Equal(t, `C:\PROGRA~3\Amazon\SSM`, trimExtendedLengthPrefix(`\\?\C:\PROGRA~3\Amazon\SSM`))
+	assert.Equal(t, `\\server\share\packages`, trimExtendedLengthPrefix(`\\?\UNC\server\share\packages`))
+	assert.Equal(t, `C:\packages`, trimExtendedLengthPrefix(`C:\packages`))
+}
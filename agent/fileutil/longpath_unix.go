@@ -0,0 +1,26 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package fileutil
+
+// LongPath returns the path unchanged, the length of paths is not limited below the limit of the file system
+func LongPath(path string) string {
+	return path
+}
+
+// ShortPath returns the path unchanged, any directory can be the working directory of a process
+func ShortPath(path string) string {
+	return path
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package fileutil
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// LongPath returns the path with the extended-length prefix when it is too long for the Windows file APIs, such
+// as the files of deep package contents. Shorter paths are returned unchanged.
+func LongPath(path string) string {
+	if len(path) < maxShortPathLength || strings.HasPrefix(path, extendedLengthPrefix) {
+		return path
+	}
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return extendedLengthPath(absolutePath)
+}
+
+// ShortPath returns the 8.3 form of a directory too long to be the working directory of a process, Windows does
+// not start a process in a directory with the extended-length prefix. The path is returned unchanged when it is
+// short enough or has no 8.3 form.
+func ShortPath(path string) string {
+	if len(path) < maxShortPathLength {
+		return path
+	}
+	longPath, err := syscall.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return path
+	}
+	length, err := syscall.GetShortPathName(longPath, nil, 0)
+	if err != nil || length == 0 {
+		return path
+	}
+	buffer := make([]uint16, length)
+	if length, err = syscall.GetShortPathName(longPath, &buffer[0], length); err != nil || int(length) >= len(buffer) {
+		return path
+	}
+	if shortPath := trimExtendedLengthPrefix(syscall.UTF16ToString(buffer[:length])); len(shortPath) < maxShortPathLength {
+		return shortPath
+	}
+	return path
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fileutil
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// shredBufferSize is the size of the blocks written over the content of a file
+const shredBufferSize = 32 * 1024
+
+// SecureDeleteDirectory overwrites the regular files under dirName with random data passes times and then
+// deletes the directory and all its content. The directory is deleted even if overwriting a file fails.
+func SecureDeleteDirectory(dirName string, passes int) (err error) {
+	err = filepath.Walk(dirName, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		// skip directories, symlinks and devices, only the content of regular files is overwritten
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return shredFile(path, info.Size(), passes)
+	})
+	if removeErr := os.RemoveAll(dirName); removeErr != nil {
+		return removeErr
+	}
+	return err
+}
+
+// shredFile overwrites the size bytes of a file with random data passes times.
+func shredFile(path string, size int64, passes int) (err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, shredBufferSize)
+	for pass := 0; pass < passes; pass++ {
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		for remaining := size; remaining > 0; {
+			block := buffer
+			if remaining < int64(len(block)) {
+				block = block[:remaining]
+			}
+			if _, err = rand.Read(block); err != nil {
+				return err
+			}
+			if _, err = file.Write(block); err != nil {
+				return err
+			}
+			remaining -= int64(len(block))
+		}
+		if err = file.Sync(); err != nil {
+			return err
+		}
+	}
+	return file.Truncate(0)
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fileutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShredFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shred")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	content := bytes.Repeat([]byte("secret"), 10000)
+	path := filepath.Join(dir, "secret.txt")
+	assert.NoError(t, ioutil.WriteFile(path, content, 0600))
+
+	assert.NoError(t, shredFile(path, int64(len(content)), 2))
+	shredded, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, shredded)
+}
+
+func TestSecureDeleteDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shred")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	workspace := filepath.Join(dir, "workspace")
+	assert.NoError(t, os.MkdirAll(filepath.Join(workspace, "sub"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "a.txt"), []byte("secret"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "sub", "b.txt"), []byte("secret"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "sub", "empty.txt"), nil, 0600))
+
+	assert.NoError(t, SecureDeleteDirectory(workspace, 1))
+	_, err = os.Stat(workspace)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSecureDeleteDirectory_Missing(t *testing.T) {
+	err := SecureDeleteDirectory(filepath.Join(os.TempDir(), "shred-missing-directory"), 1)
+	assert.Error(t, err)
+}
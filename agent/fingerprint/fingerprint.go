@@ -81,6 +81,20 @@ func SetSimilarityThreshold(value int) (err error) {
 	return nil
 }
 
+// ClearFingerprint removes the saved fingerprint so a new one is generated the next time it is requested
+func ClearFingerprint() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := vault.Remove(vaultKey); err != nil {
+		return err
+	}
+
+	fingerprint = ""
+	loaded = false
+	return nil
+}
+
 // generateFingerprint generates new fingerprint and saves it in the vault
 func generateFingerprint() (string, error) {
 	uuid.SwitchFormat(uuid.CleanHyphen)
@@ -22,9 +22,11 @@ var vault fpVault = &fpFsVault{}
 type fpVault interface {
 	Retrieve(key string) (data []byte, err error)
 	Store(key string, data []byte) (err error)
+	Remove(key string) (err error)
 }
 
 type fpFsVault struct{}
 
 func (fpFsVault) Retrieve(key string) ([]byte, error) { return fsvault.Retrieve(key) }
 func (fpFsVault) Store(key string, data []byte) error { return fsvault.Store(key, data) }
+func (fpFsVault) Remove(key string) error             { return fsvault.Remove(key) }
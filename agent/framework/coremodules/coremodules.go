@@ -18,11 +18,15 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/health"
+	"github.com/aws/amazon-ssm-agent/agent/localschedule"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/manager"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/integrity"
 	"github.com/aws/amazon-ssm-agent/agent/runcommand"
 	"github.com/aws/amazon-ssm-agent/agent/session"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
 	"github.com/aws/amazon-ssm-agent/agent/startup"
+	"github.com/aws/amazon-ssm-agent/agent/status/endpoint"
 )
 
 // ModuleRegistry stores a set of core modules.
@@ -50,11 +54,16 @@ func loadCoreModules(context context.T) {
 
 	if offlineProcessor, err := runcommand.NewOfflineService(context); err == nil {
 		registeredCoreModules = append(registeredCoreModules, offlineProcessor)
+		// locally scheduled documents are executed by the offline command document processor
+		registeredCoreModules = append(registeredCoreModules, localschedule.NewLocalScheduler(context))
 	} else {
 		context.Log().Errorf("Failed to start offline command document processor")
 	}
 
 	registeredCoreModules = append(registeredCoreModules, startup.NewProcessor(context))
+	registeredCoreModules = append(registeredCoreModules, integrity.NewMonitor(context))
+	registeredCoreModules = append(registeredCoreModules, birdwatcherservice.NewResultSender(context))
+	registeredCoreModules = append(registeredCoreModules, endpoint.NewEndpoint(context))
 
 	// registering the long running plugin manager as a core module
 	manager.EnsureInitialization(context)
@@ -0,0 +1,134 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// documentAdmissions tracks the documents that wait to be admitted before they take a slot of the send command pool,
+// so that a cancel command finds them while they wait and a stop shuts them down. A document stays registered until
+// it ends, its cancel flag being the one its execution watches.
+type documentAdmissions struct {
+	mutex   sync.Mutex
+	flags   map[string]*task.ChanneledCancelFlag
+	stopped bool
+	// waiting counts the documents that did not reach the send command pool yet
+	waiting sync.WaitGroup
+}
+
+func newDocumentAdmissions() *documentAdmissions {
+	return &documentAdmissions{flags: make(map[string]*task.ChanneledCancelFlag)}
+}
+
+// add registers a document waiting to be admitted, it fails when the job is already registered or the processor is
+// stopped. Every add must be followed by a call to admitted and to remove.
+func (a *documentAdmissions) add(jobID string) (*task.ChanneledCancelFlag, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.stopped {
+		return nil, fmt.Errorf("job %v was not submitted, the processor is stopped", jobID)
+	}
+	if _, found := a.flags[jobID]; found {
+		return nil, fmt.Errorf("Job with id %v already exists", jobID)
+	}
+	flag := task.NewChanneledCancelFlag()
+	a.flags[jobID] = flag
+	a.waiting.Add(1)
+	return flag, nil
+}
+
+// admitted marks a document as handed to the send command pool, or as given up
+func (a *documentAdmissions) admitted() {
+	a.waiting.Done()
+}
+
+// remove unregisters a document once it ended
+func (a *documentAdmissions) remove(jobID string, flag *task.ChanneledCancelFlag) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.flags[jobID] == flag {
+		delete(a.flags, jobID)
+	}
+}
+
+// cancel cancels a registered document, it returns false when no such document is registered
+func (a *documentAdmissions) cancel(jobID string) bool {
+	if a == nil {
+		return false
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	flag, found := a.flags[jobID]
+	if !found {
+		return false
+	}
+	// delete the document to avoid multiple cancelations
+	delete(a.flags, jobID)
+	flag.Set(task.Canceled)
+	return true
+}
+
+// shutdown shuts the registered documents down, refuses new ones and waits for the documents that did not reach
+// the send command pool to give up. The pool must be shut down at the same time for the documents being handed to
+// it to give up.
+func (a *documentAdmissions) shutdown() {
+	if a == nil {
+		return
+	}
+	a.mutex.Lock()
+	a.stopped = true
+	for _, flag := range a.flags {
+		flag.Set(task.ShutDown)
+	}
+	a.mutex.Unlock()
+	a.waiting.Wait()
+}
+
+// forwardCancel sets the state of the cancel flag of the pool job on the cancel flag of the document, until the job
+// completes
+func forwardCancel(jobFlag task.CancelFlag, flag task.CancelFlag) {
+	if state := jobFlag.Wait(); state == task.Canceled || state == task.ShutDown {
+		flag.Set(state)
+	}
+}
+
+// canceledResult returns the final result of a document canceled before it started, none of its plugins ran
+func canceledResult(docState *contracts.DocumentState) contracts.DocumentResult {
+	now := time.Now()
+	results := make(map[string]*contracts.PluginResult)
+	for _, pluginState := range docState.InstancePluginsInformation {
+		results[pluginState.Id] = &contracts.PluginResult{
+			PluginID:      pluginState.Id,
+			PluginName:    pluginState.Name,
+			Status:        contracts.ResultStatusCancelled,
+			StartDateTime: now,
+			EndDateTime:   now,
+		}
+	}
+	return contracts.DocumentResult{
+		DocumentName:    docState.DocumentInformation.DocumentName,
+		DocumentVersion: docState.DocumentInformation.DocumentVersion,
+		MessageID:       docState.DocumentInformation.MessageID,
+		AssociationID:   docState.DocumentInformation.AssociationID,
+		PluginResults:   results,
+		Status:          contracts.ResultStatusCancelled,
+		NPlugins:        len(docState.InstancePluginsInformation),
+	}
+}
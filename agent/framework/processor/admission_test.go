@@ -0,0 +1,267 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeLimiter lets the documents start when it is open
+type fakeLimiter struct {
+	mutex    sync.Mutex
+	open     bool
+	running  int
+	released int
+}
+
+func (l *fakeLimiter) Limited() bool {
+	return true
+}
+
+func (l *fakeLimiter) Acquire(cancelFlag task.CancelFlag) bool {
+	for {
+		l.mutex.Lock()
+		if l.open {
+			l.running++
+			l.mutex.Unlock()
+			return true
+		}
+		l.mutex.Unlock()
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (l *fakeLimiter) Release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.running--
+	l.released++
+}
+
+func newAdmissionTestProcessor(pool task.Pool, docMgr *DocumentMgrMock, limiter executionLimiter) *EngineProcessor {
+	return &EngineProcessor{
+		context:         context.NewMockDefault(),
+		sendCommandPool: pool,
+		resChan:         make(chan contracts.DocumentResult),
+		documentMgr:     docMgr,
+		executionGate:   limiter,
+		admissions:      newDocumentAdmissions(),
+	}
+}
+
+func admissionTestDocument() *contracts.DocumentState {
+	docState := &contracts.DocumentState{
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:   "documentID",
+			InstanceID:   "instanceID",
+			MessageID:    "messageID",
+			DocumentName: "RunShellScript",
+		},
+		InstancePluginsInformation: []contracts.PluginState{{Id: "step1", Name: "aws:runShellScript"}},
+	}
+	return docState
+}
+
+func TestSubmitCanceledWhileWaitingForTheExecutionLimit(t *testing.T) {
+	pool := new(task.MockedPool)
+	pool.On("HasJob", "messageID").Return(false)
+	docMgr := new(DocumentMgrMock)
+	docMgr.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMgr.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+	p := newAdmissionTestProcessor(pool, docMgr, &fakeLimiter{})
+
+	// the waiting document doesn't take a slot of the pool
+	assert.NoError(t, p.submit(admissionTestDocument()))
+	pool.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+
+	cancel := contracts.DocumentState{}
+	cancel.CancelInformation.CancelMessageID = "messageID"
+	cancel.DocumentInformation.DocumentID = "cancelDocumentID"
+	docMgr.On("MoveDocumentState", mock.Anything, "cancelDocumentID", "", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMgr.On("RemoveDocumentState", mock.Anything, "cancelDocumentID", "", appconfig.DefaultLocationOfCurrent)
+	processCancelCommand(context.NewMockDefault(), pool, p.admissions, &cancel, docMgr)
+	assert.Equal(t, contracts.ResultStatusSuccess, cancel.DocumentInformation.DocumentStatus)
+
+	res := <-p.resChan
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.Equal(t, "", res.LastPlugin)
+	assert.Equal(t, contracts.ResultStatusCancelled, res.PluginResults["step1"].Status)
+	p.admissions.waiting.Wait()
+	docMgr.AssertExpectations(t)
+	pool.AssertNotCalled(t, "Cancel", mock.Anything)
+	assert.Empty(t, p.admissions.flags)
+}
+
+func TestSubmitCanceledWhileWaitingForThePool(t *testing.T) {
+	limiter := &fakeLimiter{open: true}
+	pool := new(task.MockedPool)
+	pool.On("HasJob", "messageID").Return(false)
+	jobs := make(chan task.Job, 1)
+	pool.On("Submit", mock.Anything, "messageID", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		jobs <- args.Get(2).(task.Job)
+	})
+	docMgr := new(DocumentMgrMock)
+	docMgr.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMgr.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+	p := newAdmissionTestProcessor(pool, docMgr, limiter)
+
+	assert.NoError(t, p.submit(admissionTestDocument()))
+	job := <-jobs
+	assert.True(t, p.admissions.cancel("messageID"))
+
+	done := make(chan struct{})
+	go func() {
+		job(task.NewChanneledCancelFlag())
+		close(done)
+	}()
+	res := <-p.resChan
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	<-done
+	p.admissions.waiting.Wait()
+	assert.Equal(t, 1, limiter.released)
+	assert.Equal(t, 0, limiter.running)
+}
+
+func TestSubmitCanceledWhileQueuedInConcurrencyGroup(t *testing.T) {
+	defer func(interval time.Duration) { groupRetryInterval = interval }(groupRetryInterval)
+	groupRetryInterval = time.Millisecond
+	assert.True(t, concurrencyGroups.Wait("admission-group", "running", task.NewChanneledCancelFlag(), func(int) {}))
+	defer concurrencyGroups.Leave("admission-group", "running")
+	pool := new(task.MockedPool)
+	pool.On("HasJob", "messageID").Return(false)
+	docMgr := new(DocumentMgrMock)
+	docMgr.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMgr.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+	p := newAdmissionTestProcessor(pool, docMgr, nil)
+	docState := admissionTestDocument()
+	docState.ConcurrencyGroup = "admission-group"
+
+	// the queued document doesn't take a slot of the pool
+	assert.NoError(t, p.submit(docState))
+	res := <-p.resChan
+	assert.Equal(t, contracts.ResultStatusInProgress, res.Status)
+	assert.Equal(t, 1, res.QueuePosition)
+	pool.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+
+	assert.True(t, p.admissions.cancel("messageID"))
+	res = <-p.resChan
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.Equal(t, contracts.ResultStatusCancelled, res.PluginResults["step1"].Status)
+	p.admissions.waiting.Wait()
+	docMgr.AssertExpectations(t)
+	assert.Equal(t, -1, concurrencyGroups.position("admission-group", "documentID"))
+}
+
+func TestSubmitLeavesConcurrencyGroupWhenDocumentEnds(t *testing.T) {
+	limiter := &fakeLimiter{open: true}
+	pool := new(task.MockedPool)
+	pool.On("HasJob", "messageID").Return(false)
+	jobs := make(chan task.Job, 1)
+	pool.On("Submit", mock.Anything, "messageID", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		jobs <- args.Get(2).(task.Job)
+	})
+	docMgr := new(DocumentMgrMock)
+	docMgr.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMgr.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+	p := newAdmissionTestProcessor(pool, docMgr, limiter)
+	docState := admissionTestDocument()
+	docState.ConcurrencyGroup = "admission-group-ended"
+
+	assert.NoError(t, p.submit(docState))
+	job := <-jobs
+	// the document holds its group and the execution limit until it ends
+	assert.Equal(t, 0, concurrencyGroups.position("admission-group-ended", "documentID"))
+	assert.True(t, p.admissions.cancel("messageID"))
+	done := make(chan struct{})
+	go func() {
+		job(task.NewChanneledCancelFlag())
+		close(done)
+	}()
+	<-p.resChan
+	<-done
+	p.admissions.waiting.Wait()
+	assert.Equal(t, -1, concurrencyGroups.position("admission-group-ended", "documentID"))
+	assert.Equal(t, 1, limiter.released)
+}
+
+func TestSubmitCanceledWhileDeferredByReadiness(t *testing.T) {
+	_, restore := stubReadiness(diskGateErr, diskGateErr)
+	defer restore()
+	pool := new(task.MockedPool)
+	pool.On("HasJob", "messageID").Return(false)
+	docMgr := new(DocumentMgrMock)
+	docMgr.On("MoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
+	docMgr.On("RemoveDocumentState", mock.Anything, "documentID", "instanceID", appconfig.DefaultLocationOfCurrent)
+	p := newAdmissionTestProcessor(pool, docMgr, nil)
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(appconfig.SsmagentConfig{
+		Readiness: appconfig.ReadinessCfg{MinFreeDiskMB: 1024, OnFailure: appconfig.ReadinessOnFailureDefer, DeferTimeoutSeconds: 60},
+	})
+	p.context = ctx
+	docState := admissionTestDocument()
+	docState.DocumentType = contracts.SendCommand
+
+	// the deferred document doesn't take a slot of the pool
+	assert.NoError(t, p.submit(docState))
+	assert.True(t, p.admissions.cancel("messageID"))
+	res := <-p.resChan
+	assert.Equal(t, contracts.ResultStatusCancelled, res.Status)
+	assert.Equal(t, contracts.ResultStatusCancelled, res.PluginResults["step1"].Status)
+	p.admissions.waiting.Wait()
+	docMgr.AssertExpectations(t)
+	pool.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStopShutsDownWaitingDocuments(t *testing.T) {
+	pool := new(task.MockedPool)
+	pool.On("HasJob", "messageID").Return(false)
+	pool.On("ShutdownAndWait", mock.AnythingOfType("time.Duration")).Return(true)
+	cancelPool := new(task.MockedPool)
+	cancelPool.On("ShutdownAndWait", mock.AnythingOfType("time.Duration")).Return(true)
+	docMgr := new(DocumentMgrMock)
+	p := newAdmissionTestProcessor(pool, docMgr, &fakeLimiter{})
+	p.cancelCommandPool = cancelPool
+
+	assert.NoError(t, p.submit(admissionTestDocument()))
+	p.Stop(contracts.StopTypeSoftStop)
+
+	// the shut down document stays pending to be resumed and no document is accepted anymore
+	docMgr.AssertNotCalled(t, "RemoveDocumentState", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	_, err := p.admissions.add("other")
+	assert.Error(t, err)
+}
+
+func TestSubmitWithoutLimitUsesThePool(t *testing.T) {
+	pool := new(task.MockedPool)
+	pool.On("Submit", mock.Anything, "messageID", mock.Anything).Return(nil)
+	p := newAdmissionTestProcessor(pool, new(DocumentMgrMock), nil)
+
+	assert.NoError(t, p.submit(admissionTestDocument()))
+	pool.AssertExpectations(t)
+	assert.Empty(t, p.admissions.flags)
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// groupRetryInterval is how often a queued document checks whether its turn has come
+var groupRetryInterval = time.Second
+
+// concurrencyGroups is shared by every processor of the agent, so that documents of a group are serialized
+// whether they come from Run Command, associations or any other source.
+var concurrencyGroups = newGroupQueues()
+
+// groupQueues keeps, for every concurrency group, the documents in the order they joined it.
+// The first document of a queue is the one running.
+type groupQueues struct {
+	mutex  sync.Mutex
+	queues map[string][]string
+}
+
+func newGroupQueues() *groupQueues {
+	return &groupQueues{queues: make(map[string][]string)}
+}
+
+// Wait queues the document in the group and blocks until the documents ahead of it are done.
+// onQueued is called with the number of documents ahead every time it changes while waiting.
+// It returns false if the document was canceled or the agent shut down while waiting.
+func (q *groupQueues) Wait(group string, documentID string, cancelFlag task.CancelFlag, onQueued func(position int)) bool {
+	q.join(group, documentID)
+	reported := 0
+	for {
+		position := q.position(group, documentID)
+		if position <= 0 {
+			return true
+		}
+		if position != reported {
+			onQueued(position)
+			reported = position
+		}
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			q.Leave(group, documentID)
+			return false
+		}
+		time.Sleep(groupRetryInterval)
+	}
+}
+
+// Leave removes the document from the group, letting the next one run.
+func (q *groupQueues) Leave(group string, documentID string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	queue := q.queues[group]
+	for i, id := range queue {
+		if id == documentID {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(q.queues, group)
+		return
+	}
+	q.queues[group] = queue
+}
+
+func (q *groupQueues) join(group string, documentID string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, id := range q.queues[group] {
+		if id == documentID {
+			return
+		}
+	}
+	q.queues[group] = append(q.queues[group], documentID)
+}
+
+// position returns how many documents are ahead of the document in the group, -1 if it's not queued.
+func (q *groupQueues) position(group string, documentID string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i, id := range q.queues[group] {
+		if id == documentID {
+			return i
+		}
+	}
+	return -1
+}
+
+// queuedResult builds the in progress result reporting the document position in its concurrency group.
+func queuedResult(docState *contracts.DocumentState, position int) contracts.DocumentResult {
+	return contracts.DocumentResult{
+		DocumentName:     docState.DocumentInformation.DocumentName,
+		DocumentVersion:  docState.DocumentInformation.DocumentVersion,
+		MessageID:        docState.DocumentInformation.MessageID,
+		AssociationID:    docState.DocumentInformation.AssociationID,
+		Status:           contracts.ResultStatusInProgress,
+		NPlugins:         len(docState.InstancePluginsInformation),
+		ConcurrencyGroup: docState.ConcurrencyGroup,
+		QueuePosition:    position,
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupQueues_SerializesDocumentsOfAGroup(t *testing.T) {
+	defer func(interval time.Duration) { groupRetryInterval = interval }(groupRetryInterval)
+	groupRetryInterval = time.Millisecond
+	queues := newGroupQueues()
+
+	assert.True(t, queues.Wait("db-maintenance", "first", task.NewChanneledCancelFlag(), func(int) {
+		assert.Fail(t, "the first document of a group should not wait")
+	}))
+	// other groups are independent
+	assert.True(t, queues.Wait("web", "other", task.NewChanneledCancelFlag(), func(int) {}))
+
+	positions := make(chan int, 10)
+	done := make(chan bool)
+	go func() {
+		done <- queues.Wait("db-maintenance", "second", task.NewChanneledCancelFlag(), func(position int) { positions <- position })
+	}()
+	assert.Equal(t, 1, <-positions)
+	select {
+	case <-done:
+		assert.Fail(t, "the second document should wait for the first one")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	queues.Leave("db-maintenance", "first")
+	assert.True(t, <-done)
+	assert.Equal(t, 0, queues.position("db-maintenance", "second"))
+	queues.Leave("db-maintenance", "second")
+	assert.Equal(t, -1, queues.position("db-maintenance", "second"))
+	assert.Len(t, queues.queues, 1)
+}
+
+func TestGroupQueues_CanceledWhileWaiting(t *testing.T) {
+	defer func(interval time.Duration) { groupRetryInterval = interval }(groupRetryInterval)
+	groupRetryInterval = time.Millisecond
+	queues := newGroupQueues()
+	queues.Wait("db-maintenance", "first", task.NewChanneledCancelFlag(), func(int) {})
+
+	var positions []int
+	queues.join("db-maintenance", "second")
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+	assert.False(t, queues.Wait("db-maintenance", "third", cancelFlag, func(position int) { positions = append(positions, position) }))
+	assert.Equal(t, []int{2}, positions)
+	assert.Equal(t, -1, queues.position("db-maintenance", "third"))
+}
+
+func TestQueuedResult(t *testing.T) {
+	docState := contracts.DocumentState{
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentName: "DbMaintenance",
+			MessageID:    "aws.ssm.command-id.instance-id",
+		},
+		InstancePluginsInformation: []contracts.PluginState{{Id: "step1"}, {Id: "step2"}},
+		ConcurrencyGroup:           "db-maintenance",
+	}
+
+	res := queuedResult(&docState, 2)
+
+	assert.Equal(t, "DbMaintenance", res.DocumentName)
+	assert.Equal(t, "aws.ssm.command-id.instance-id", res.MessageID)
+	assert.Equal(t, contracts.ResultStatusInProgress, res.Status)
+	assert.Equal(t, "", res.LastPlugin)
+	assert.Equal(t, 2, res.NPlugins)
+	assert.Equal(t, "db-maintenance", res.ConcurrencyGroup)
+	assert.Equal(t, 2, res.QueuePosition)
+}
@@ -127,8 +127,10 @@ func (e *BasicExecuter) Run(
 	cancelFlag task.CancelFlag,
 	docStore executer.DocumentStore) chan contracts.DocumentResult {
 
-	log := e.ctx.Log()
 	docState := docStore.Load()
+	//update context with the document id
+	e.ctx = e.ctx.With("[documentID=" + docState.DocumentInformation.DocumentID + "]")
+	log := e.ctx.Log()
 	nPlugins := len(docState.InstancePluginsInformation)
 	// we're creating a buffered channel according to the number of plugins the document has
 	e.resChan = make(chan contracts.DocumentResult, nPlugins)
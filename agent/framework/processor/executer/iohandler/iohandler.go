@@ -20,6 +20,7 @@ import (
 	"io"
 
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/iomodule"
@@ -77,18 +78,24 @@ type IOHandler interface {
 	AppendInfof(format string, params ...interface{})
 	AppendError(message string)
 	AppendErrorf(format string, params ...interface{})
+	AppendCheckChanges(changes ...string)
+	AppendSimulationTrace(actions ...string)
 
 	// getters/setters
 	GetStatus() contracts.ResultStatus
 	GetStdout() string
 	GetStderr() string
 	GetExitCode() int
+	GetErrorCode() contracts.ErrorCode
+	GetCheckChanges() []string
+	GetSimulationTrace() []string
 	GetStdoutWriter() multiwriter.DocumentIOMultiWriter
 	GetStderrWriter() multiwriter.DocumentIOMultiWriter
 	GetIOConfig() contracts.IOConfiguration
 
 	SetStatus(contracts.ResultStatus)
 	SetExitCode(int)
+	SetErrorCode(contracts.ErrorCode)
 	SetOutput(interface{})
 	SetStdout(string)
 	SetStderr(string)
@@ -96,14 +103,22 @@ type IOHandler interface {
 
 // DefaultIOHandler is used for writing output by the plugins
 type DefaultIOHandler struct {
-	ExitCode int
-	Status   contracts.ResultStatus
+	ExitCode  int
+	Status    contracts.ResultStatus
+	ErrorCode contracts.ErrorCode
+	// CheckChanges are the changes a plugin run in check mode would make
+	CheckChanges []string
+	// SimulationTrace are the actions a plugin run in simulation would take
+	SimulationTrace []string
 	//private members - not exposed directly to plugins because they shouldn't write to these
 	stdout   string
 	stderr   string
 	ioConfig contracts.IOConfiguration
 	//refreshassociation and invoker write a different output rather than merging stdout and stderr
 	output interface{}
+	// location of the complete stdout and stderr, set when output parts are enabled and the output is uploaded
+	stdoutContinuation contracts.OutputContinuation
+	stderrContinuation contracts.OutputContinuation
 
 	// List of Writers attached to the IOHandler instance
 	StdoutWriter multiwriter.DocumentIOMultiWriter
@@ -123,6 +138,10 @@ func NewDefaultIOHandler(log log.T, ioConfig contracts.IOConfiguration) *Default
 func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 
 	pluginConfig := DefaultOutputConfig()
+	var outputPartSizeBytes int64
+	if config, err := appconfig.Config(false); err == nil {
+		outputPartSizeBytes = int64(config.S3.OutputPartSizeMB) * 1024 * 1024
+	}
 	// Create path to output location for file and s3
 	fullPath := out.ioConfig.OrchestrationDirectory
 	s3KeyPrefix := out.ioConfig.OutputS3KeyPrefix
@@ -155,6 +174,10 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		LogGroupName:           out.ioConfig.CloudWatchConfig.LogGroupName,
 		LogStreamName:          stdOutLogStreamName,
 	}
+	if outputPartSizeBytes > 0 {
+		stdoutFile.OutputPartSizeBytes = outputPartSizeBytes
+		stdoutFile.Continuation = &out.stdoutContinuation
+	}
 
 	// Initialize console output module
 	stdoutConsole := iomodule.CommandOutput{
@@ -177,6 +200,10 @@ func (out *DefaultIOHandler) Init(log log.T, filePath ...string) {
 		LogGroupName:           out.ioConfig.CloudWatchConfig.LogGroupName,
 		LogStreamName:          stdErrLogStreamName,
 	}
+	if outputPartSizeBytes > 0 {
+		stderrFile.OutputPartSizeBytes = outputPartSizeBytes
+		stderrFile.Continuation = &out.stderrContinuation
+	}
 
 	// Initialize console error module
 	stderrConsole := iomodule.CommandOutput{
@@ -254,6 +281,34 @@ func (out DefaultIOHandler) GetExitCode() int {
 	return out.ExitCode
 }
 
+// GetErrorCode returns the error code
+func (out DefaultIOHandler) GetErrorCode() contracts.ErrorCode {
+	return out.ErrorCode
+}
+
+// GetCheckChanges returns the changes the plugin would make in check mode
+func (out DefaultIOHandler) GetCheckChanges() []string {
+	return out.CheckChanges
+}
+
+// GetSimulationTrace returns the actions the plugin would take in simulation
+func (out DefaultIOHandler) GetSimulationTrace() []string {
+	return out.SimulationTrace
+}
+
+// GetOutputContinuations returns the location of the complete stdout and stderr, nil when they were not uploaded in parts
+func (out DefaultIOHandler) GetOutputContinuations() (stdout *contracts.OutputContinuation, stderr *contracts.OutputContinuation) {
+	if out.stdoutContinuation.PartCount > 0 {
+		continuation := out.stdoutContinuation
+		stdout = &continuation
+	}
+	if out.stderrContinuation.PartCount > 0 {
+		continuation := out.stderrContinuation
+		stderr = &continuation
+	}
+	return
+}
+
 // GetStderr returns the stderr
 func (out DefaultIOHandler) GetStderr() string {
 	return out.stderr
@@ -294,6 +349,11 @@ func (out *DefaultIOHandler) SetExitCode(exitCode int) {
 	out.ExitCode = exitCode
 }
 
+// SetErrorCode sets the error code
+func (out *DefaultIOHandler) SetErrorCode(errorCode contracts.ErrorCode) {
+	out.ErrorCode = errorCode
+}
+
 // SetOutput sets the output
 func (out *DefaultIOHandler) SetOutput(output interface{}) {
 	out.output = output
@@ -321,6 +381,17 @@ func (out *DefaultIOHandler) Merge(log log.T, mergeOutput *DefaultIOHandler) {
 	if out.ExitCode == 0 {
 		out.ExitCode = mergeOutput.GetExitCode()
 	}
+	if out.ErrorCode == "" {
+		out.ErrorCode = mergeOutput.GetErrorCode()
+	}
+	out.CheckChanges = append(out.CheckChanges, mergeOutput.GetCheckChanges()...)
+	out.SimulationTrace = append(out.SimulationTrace, mergeOutput.GetSimulationTrace()...)
+	if out.stdoutContinuation.PartCount == 0 {
+		out.stdoutContinuation = mergeOutput.stdoutContinuation
+	}
+	if out.stderrContinuation.PartCount == 0 {
+		out.stderrContinuation = mergeOutput.stderrContinuation
+	}
 	out.Status = contracts.MergeResultStatus(out.Status, mergeOutput.GetStatus())
 }
 
@@ -332,6 +403,10 @@ func (out *DefaultIOHandler) MarkAsFailed(err error) {
 	}
 	out.Status = contracts.ResultStatusFailed
 	if err != nil {
+		// keep the first error code, later errors are usually a consequence of it
+		if out.ErrorCode == "" {
+			out.ErrorCode = contracts.ErrorCodeOf(err)
+		}
 		out.AppendError(err.Error())
 	}
 }
@@ -416,6 +491,19 @@ func (out *DefaultIOHandler) AppendErrorf(format string, params ...interface{})
 	}
 }
 
+// AppendCheckChanges records changes the plugin would make in check mode, one change per line of the report
+func (out *DefaultIOHandler) AppendCheckChanges(changes ...string) {
+	out.CheckChanges = append(out.CheckChanges, changes...)
+}
+
+// AppendSimulationTrace records actions the plugin would take in simulation, they are also written to the stdout
+func (out *DefaultIOHandler) AppendSimulationTrace(actions ...string) {
+	out.SimulationTrace = append(out.SimulationTrace, actions...)
+	for _, action := range actions {
+		out.AppendInfo("[simulation] " + action)
+	}
+}
+
 // TruncateOutput truncates the output
 func TruncateOutput(stdout string, stderr string, capacity int) (response string) {
 	outputSize := len(stdout)
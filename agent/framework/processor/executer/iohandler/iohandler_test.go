@@ -114,6 +114,16 @@ func TestFailed(t *testing.T) {
 	assert.Contains(t, output.GetStderr(), "Error message")
 	assert.False(t, output.Status.IsSuccess())
 	assert.False(t, output.Status.IsReboot())
+	assert.Equal(t, contracts.ErrorCodeInternal, output.GetErrorCode())
+}
+
+func TestFailedKeepsFirstErrorCode(t *testing.T) {
+	output := DefaultIOHandler{}
+
+	output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeChecksumMismatch, fmt.Errorf("hash mismatch")))
+	output.MarkAsFailed(fmt.Errorf("install failed"))
+
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, output.GetErrorCode())
 }
 
 func TestMarkAsInProgress(t *testing.T) {
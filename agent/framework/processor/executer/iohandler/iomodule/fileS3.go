@@ -15,6 +15,7 @@ package iomodule
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -22,15 +23,22 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
-	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
 )
 
 const (
 	maxCloudWatchUploadRetry = 5
+
+	// outputPartSuffix is appended to the s3 key of the output followed by the part number
+	outputPartSuffix = ".part-"
 )
 
+// fileUploader uploads a file, or a part of it, to s3
+type fileUploader func(log log.T, priority uploadqueue.Priority, object uploadqueue.S3Object) error
+
 // File handles writing to an output file and upload to s3 and cloudWatch
 type File struct {
 	FileName               string
@@ -39,6 +47,10 @@ type File struct {
 	OutputS3KeyPrefix      string
 	LogGroupName           string
 	LogStreamName          string
+	// OutputPartSizeBytes splits the output uploaded to s3 in parts of this size, 0 uploads a single object
+	OutputPartSizeBytes int64
+	// Continuation receives the location of the complete output once it is uploaded, it may be nil
+	Continuation *contracts.OutputContinuation
 }
 
 // Read reads from the stream and writes to the output file, s3 and CloudWatchLogs.
@@ -90,11 +102,33 @@ func (file File) Read(log log.T, reader *io.PipeReader) {
 		return
 	}
 
-	// Upload output file to S3
+	// Upload output file to S3 through the upload queue, ahead of the other uploads of the agent
 	if file.OutputS3BucketName != "" && fi.Size() > 0 {
 		s3Key := fileutil.BuildS3Path(file.OutputS3KeyPrefix, file.FileName)
-		if err := s3util.NewAmazonS3Util(log, file.OutputS3BucketName).S3Upload(log, file.OutputS3BucketName, s3Key, filePath); err != nil {
+		partCount := 1
+		if file.OutputPartSizeBytes > 0 && fi.Size() > file.OutputPartSizeBytes {
+			partCount, err = file.uploadParts(log, uploadqueue.UploadFile, s3Key, filePath, fi.Size())
+		} else {
+			err = uploadqueue.UploadFile(log, uploadqueue.PriorityResults, uploadqueue.S3Object{
+				Bucket:   file.OutputS3BucketName,
+				Key:      s3Key,
+				FilePath: filePath,
+			})
+		}
+		if err != nil {
 			log.Errorf("Failed to upload the output to s3: %v", err)
+		} else if file.Continuation != nil {
+			*file.Continuation = contracts.OutputContinuation{
+				PartCount:  partCount,
+				TotalBytes: fi.Size(),
+				Location:   fmt.Sprintf("s3://%v/%v", file.OutputS3BucketName, s3Key),
+			}
+		}
+	} else if file.LogGroupName != "" && fi.Size() > 0 && file.Continuation != nil {
+		*file.Continuation = contracts.OutputContinuation{
+			PartCount:  1,
+			TotalBytes: fi.Size(),
+			Location:   fmt.Sprintf("cloudwatch://%v/%v", file.LogGroupName, file.LogStreamName),
 		}
 	}
 
@@ -109,3 +143,25 @@ func (file File) Read(log log.T, reader *io.PipeReader) {
 		}
 	}
 }
+
+// uploadParts uploads the output file to s3 in ordered parts and returns the number of parts
+func (file File) uploadParts(log log.T, upload fileUploader, s3Key string, filePath string, size int64) (partCount int, err error) {
+	for offset := int64(0); offset < size; offset += file.OutputPartSizeBytes {
+		partCount++
+		length := file.OutputPartSizeBytes
+		if offset+length > size {
+			length = size - offset
+		}
+		part := uploadqueue.S3Object{
+			Bucket:   file.OutputS3BucketName,
+			Key:      fmt.Sprintf("%v%v%05d", s3Key, outputPartSuffix, partCount),
+			FilePath: filePath,
+			Offset:   offset,
+			Length:   length,
+		}
+		if err = upload(log, uploadqueue.PriorityResults, part); err != nil {
+			return partCount, fmt.Errorf("failed to upload part %v of the output: %v", partCount, err)
+		}
+	}
+	return partCount, nil
+}
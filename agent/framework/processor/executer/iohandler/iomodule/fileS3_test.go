@@ -0,0 +1,67 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iomodule
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeS3Uploader struct {
+	objects map[string]string
+}
+
+func (u *fakeS3Uploader) upload(log log.T, priority uploadqueue.Priority, object uploadqueue.S3Object) error {
+	file, err := os.Open(object.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(io.NewSectionReader(file, object.Offset, object.Length))
+	u.objects[object.Bucket+"/"+object.Key] = string(content)
+	return err
+}
+
+// TestUploadParts tests the output is uploaded in ordered parts of the configured size
+func TestUploadParts(t *testing.T) {
+	output := "0123456789abcdefghijklmnopqrstuvwxyz"
+	outputFile, err := ioutil.TempFile("", "stdout")
+	assert.NoError(t, err)
+	defer os.Remove(outputFile.Name())
+	outputFile.WriteString(output)
+	outputFile.Close()
+
+	uploader := &fakeS3Uploader{objects: make(map[string]string)}
+	file := File{
+		FileName:            "stdout",
+		OutputS3BucketName:  "bucket",
+		OutputPartSizeBytes: 16,
+	}
+
+	partCount, err := file.uploadParts(logger, uploader.upload, "prefix/stdout", outputFile.Name(), int64(len(output)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, partCount)
+	assert.Equal(t, map[string]string{
+		"bucket/prefix/stdout.part-00001": "0123456789abcdef",
+		"bucket/prefix/stdout.part-00002": "ghijklmnopqrstuv",
+		"bucket/prefix/stdout.part-00003": "wxyz",
+	}, uploader.objects)
+}
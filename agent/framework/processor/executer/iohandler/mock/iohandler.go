@@ -83,6 +83,16 @@ func (m *MockIOHandler) AppendInfo(message string) {
 	m.Called(message)
 }
 
+// AppendCheckChanges is a mocked method that acknowledges that the function has been called.
+func (m *MockIOHandler) AppendCheckChanges(changes ...string) {
+	m.Called(changes)
+}
+
+// AppendSimulationTrace is a mocked method that acknowledges that the function has been called.
+func (m *MockIOHandler) AppendSimulationTrace(actions ...string) {
+	m.Called(actions)
+}
+
 // AppendInfof is a mocked method that acknowledges that the function has been called.
 func (m *MockIOHandler) AppendInfof(format string, params ...interface{}) {
 	m.Called(format, params)
@@ -122,6 +132,24 @@ func (m *MockIOHandler) GetExitCode() int {
 	return args.Int(0)
 }
 
+// GetErrorCode is a mocked method that just returns what mock tells it to.
+func (m *MockIOHandler) GetErrorCode() contracts.ErrorCode {
+	args := m.Called()
+	return args.Get(0).(contracts.ErrorCode)
+}
+
+// GetCheckChanges is a mocked method that just returns what mock tells it to.
+func (m *MockIOHandler) GetCheckChanges() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+// GetSimulationTrace is a mocked method that just returns what mock tells it to.
+func (m *MockIOHandler) GetSimulationTrace() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
 // GetStdoutWriter is a mocked method that just returns what mock tells it to.
 func (m *MockIOHandler) GetStdoutWriter() multiwriter.DocumentIOMultiWriter {
 	args := m.Called()
@@ -150,6 +178,11 @@ func (m *MockIOHandler) SetExitCode(code int) {
 	m.Called(code)
 }
 
+// SetErrorCode is a mocked method that acknowledges that the function has been called.
+func (m *MockIOHandler) SetErrorCode(code contracts.ErrorCode) {
+	m.Called(code)
+}
+
 // SetOutput is a mocked method that acknowledges that the function has been called.
 func (m *MockIOHandler) SetOutput(out interface{}) {
 	m.Called(out)
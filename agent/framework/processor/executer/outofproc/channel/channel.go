@@ -1,7 +1,11 @@
 package channel
 
 import (
+	"crypto/sha1"
+	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
@@ -16,6 +20,10 @@ const (
 const (
 	defaultChannelBufferSize = 100
 	defaultFileChannelPath   = "channels"
+	//unix socket paths are limited to ~100 characters, so sockets are named by a short hash of the channel name
+	defaultSocketChannelPath = "sockets"
+	socketNameLength         = 16
+	socketFileExtension      = ".sock"
 )
 
 type Mode string
@@ -58,3 +66,81 @@ func CreateFileChannel(log log.T, mode Mode, filename string) (Channel, error, b
 	f, err := NewFileWatcherChannel(log, mode, path.Join(appconfig.DefaultDataStorePath, instanceID, defaultFileChannelPath, filename))
 	return f, err, false
 }
+
+//CreateChannel creates the ipc channel with the given name using the transport configured for the agent.
+//Master picks the transport from the agent config, worker follows whatever transport the master has set up:
+//if a socket exists for the channel it connects to it, otherwise it falls back to the file channel.
+//return the channel and the found flag
+func CreateChannel(log log.T, mode Mode, name string) (Channel, error, bool) {
+	socketPath, err := getSocketPath(name)
+	if err != nil {
+		log.Errorf("failed to resolve socket path: %v", err)
+		return nil, err, false
+	}
+	if mode == ModeWorker {
+		if _, err = os.Stat(socketPath); err == nil {
+			if ch, err := NewSocketChannel(log, mode, socketPath); err == nil {
+				return ch, nil, true
+			}
+			log.Infof("socket %v is not connectable, falling back to file channel", socketPath)
+		}
+		return CreateFileChannel(log, mode, name)
+	}
+	config, _ := appconfig.Config(false)
+	if config.Agent.IpcTransport != appconfig.IpcTransportSocket {
+		return CreateFileChannel(log, mode, name)
+	}
+	_, err = os.Stat(socketPath)
+	found := err == nil
+	if found {
+		log.Infof("channel: %v found", name)
+	}
+	ch, err := NewSocketChannel(log, mode, socketPath)
+	if err != nil {
+		log.Errorf("failed to create socket channel, falling back to file channel: %v", err)
+		return CreateFileChannel(log, mode, name)
+	}
+	return ch, nil, found
+}
+
+//getSocketPath returns the socket path of the given channel name under the default data store
+//the path uses the native separators so it resolves the same way on every platform
+func getSocketPath(name string) (string, error) {
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		return "", err
+	}
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(name)))
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, defaultSocketChannelPath, hash[:socketNameLength]+socketFileExtension), nil
+}
+
+//RemoveStaleSockets removes the sockets left behind by an agent that did not exit cleanly and returns their names.
+//The agent is the master of every socket, so it must run before the agent creates any channel: dialing a socket to
+//check whether it is alive would take over the connection of its worker.
+func RemoveStaleSockets(log log.T) []string {
+	instanceID, err := platform.InstanceID()
+	if err != nil {
+		log.Errorf("failed to load instance ID: %v", err)
+		return nil
+	}
+	return removeSockets(log, filepath.Join(appconfig.DefaultDataStorePath, instanceID, defaultSocketChannelPath))
+}
+
+//removeSockets removes the sockets of the directory, the workers still running redial their master once it listens again
+func removeSockets(log log.T, dir string) (removed []string) {
+	list, err := fileutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, val := range list {
+		if val.IsDir() || filepath.Ext(val.Name()) != socketFileExtension {
+			continue
+		}
+		if err = os.Remove(filepath.Join(dir, val.Name())); err != nil {
+			log.Warnf("failed to remove stale socket %v: %v", val.Name(), err)
+			continue
+		}
+		removed = append(removed, val.Name())
+	}
+	return removed
+}
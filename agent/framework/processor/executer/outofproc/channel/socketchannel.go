@@ -0,0 +1,482 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package channel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	//FrameVersion is the version of the socket frame envelope, bump it whenever the envelope schema changes
+	FrameVersion = 1
+
+	frameTypeData = "data"
+	frameTypePing = "ping"
+	frameTypePong = "pong"
+
+	//frames larger than this are treated as corrupted and the connection is reset
+	maxFrameSize = 64 * 1024 * 1024
+	//the size of the length prefix in front of every frame
+	frameHeaderSize = 4
+
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckTimeout  = 30 * time.Second
+	//Send() blocks at most this long when the peer is not draining or is not connected
+	defaultSendTimeout = 60 * time.Second
+	//worker keeps dialing the master for this long after losing the connection, so that master restart is survivable
+	defaultReconnectTimeout       = 5 * time.Minute
+	defaultReconnectRetryInterval = 1 * time.Second
+	defaultSocketFileCreateMode   = 0600
+	//the socket is created in a directory only the agent can enter, so no other user can connect to it
+	defaultSocketDirCreateMode = 0700
+)
+
+// frame is the envelope of every message written on the socket; unknown fields are ignored by the reader so new fields can be added
+// without breaking older peers
+type frame struct {
+	Version int    `json:"v"`
+	Type    string `json:"t"`
+	Payload string `json:"p,omitempty"`
+}
+
+// peerCredentials identify the process at the other end of a socket connection
+type peerCredentials struct {
+	pid int
+	uid int
+}
+
+// socketChannel is a Channel implementation on top of a local stream socket (unix domain socket).
+// Master listens on the socket path while worker dials it; messages are length-prefixed json frames.
+type socketChannel struct {
+	logger        log.T
+	path          string
+	mode          Mode
+	listener      net.Listener
+	conn          net.Conn
+	connChanged   *sync.Cond
+	onMessageChan chan string
+	mu            sync.Mutex
+	writeMu       sync.Mutex
+	closed        bool
+	closeChan     chan bool
+	wg            sync.WaitGroup
+	lastRecv      time.Time
+	//delivering is set while a reader waits for room in onMessageChan, the peer is not checked then
+	delivering bool
+	//peerPid is the process the master accepted its first connection from, a reconnection must come from the same process
+	peerPid int
+
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+	sendTimeout         time.Duration
+	reconnectTimeout    time.Duration
+}
+
+/*
+Create a socket channel identified by the given socket path.
+Master listens on the path (removing any stale socket left by a previous agent process), worker connects to it.
+Only Master channel has the privilege to remove the socket at destroy time
+*/
+func NewSocketChannel(logger log.T, mode Mode, socketPath string) (*socketChannel, error) {
+	ch := &socketChannel{
+		logger:              logger,
+		path:                socketPath,
+		mode:                mode,
+		onMessageChan:       make(chan string, defaultChannelBufferSize),
+		closeChan:           make(chan bool),
+		healthCheckInterval: defaultHealthCheckInterval,
+		healthCheckTimeout:  defaultHealthCheckTimeout,
+		sendTimeout:         defaultSendTimeout,
+		reconnectTimeout:    defaultReconnectTimeout,
+	}
+	ch.connChanged = sync.NewCond(&ch.mu)
+	if mode == ModeMaster {
+		socketDir := filepath.Dir(socketPath)
+		if err := createIfNotExist(socketDir); err != nil {
+			logger.Errorf("failed to create socket directory: %v", err)
+			return nil, err
+		}
+		//the directory must be private before the socket exists, the socket can be connected as soon as it is created
+		if err := os.Chmod(socketDir, defaultSocketDirCreateMode); err != nil {
+			logger.Errorf("failed to restrict socket directory %v: %v", socketDir, err)
+			return nil, err
+		}
+		//a socket file left behind by a crashed master can not be reused, remove it before listening
+		os.Remove(socketPath)
+		listener, err := net.Listen("unix", socketPath)
+		if err != nil {
+			logger.Errorf("failed to listen on socket %v: %v", socketPath, err)
+			return nil, err
+		}
+		os.Chmod(socketPath, defaultSocketFileCreateMode)
+		ch.listener = listener
+		ch.wg.Add(1)
+		go ch.accept()
+	} else {
+		conn, err := net.Dial("unix", socketPath)
+		if err != nil {
+			logger.Errorf("failed to connect to socket %v: %v", socketPath, err)
+			return nil, err
+		}
+		ch.setConn(conn)
+	}
+	ch.wg.Add(1)
+	go ch.healthCheck()
+	return ch, nil
+}
+
+// Send writes a data frame to the socket. If the peer is not connected, Send waits until the connection is (re)established or
+// the send timeout elapses. The socket write itself is bounded by the same timeout, which provides backpressure when the
+// peer stops draining its end.
+func (ch *socketChannel) Send(rawJson string) error {
+	deadline := time.Now().Add(ch.sendTimeout)
+	for {
+		conn, err := ch.waitForConn(deadline.Sub(time.Now()))
+		if err != nil {
+			return err
+		}
+		written, err := ch.writeFrame(conn, frame{Version: FrameVersion, Type: frameTypeData, Payload: rawJson})
+		if err == nil {
+			return nil
+		}
+		ch.logger.Errorf("failed to write to socket %v: %v", ch.path, err)
+		ch.dropConn(conn)
+		//a frame partly written may have reached the peer, writing it again could deliver it twice
+		if written > 0 || !time.Now().Before(deadline) {
+			return err
+		}
+		//nothing was written before the connection broke, the peer is expected to reconnect so retry on the new connection
+	}
+}
+
+func (ch *socketChannel) GetMessage() <-chan string {
+	return ch.onMessageChan
+}
+
+// Close a socket channel
+// stop listening and reading, close the connection and the GetMessage() go channel
+func (ch *socketChannel) Close() {
+	ch.mu.Lock()
+	if ch.closed {
+		ch.mu.Unlock()
+		return
+	}
+	ch.logger.Infof("channel %v requested close", ch.path)
+	ch.closed = true
+	close(ch.closeChan)
+	if ch.listener != nil {
+		ch.listener.Close()
+	}
+	if ch.conn != nil {
+		ch.conn.Close()
+	}
+	ch.connChanged.Broadcast()
+	ch.mu.Unlock()
+	go func() {
+		//wait for the reader routines to exit before closing the message channel, so no one writes to a closed channel
+		ch.wg.Wait()
+		close(ch.onMessageChan)
+		ch.logger.Infof("channel %v closed", ch.path)
+	}()
+}
+
+func (ch *socketChannel) Destroy() {
+	ch.Close()
+	//only master can remove the socket at close
+	if ch.mode == ModeMaster {
+		ch.logger.Debug("master removing socket...")
+		if err := os.Remove(ch.path); err != nil && !os.IsNotExist(err) {
+			ch.logger.Errorf("failed to remove socket %v : %v", ch.path, err)
+		}
+	}
+}
+
+func (ch *socketChannel) isClosed() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.closed
+}
+
+// setConn replaces the current connection, the previous one (if any) is closed and its reader exits
+func (ch *socketChannel) setConn(conn net.Conn) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.closed {
+		conn.Close()
+		return
+	}
+	if ch.conn != nil {
+		ch.conn.Close()
+	}
+	ch.conn = conn
+	ch.lastRecv = time.Now()
+	ch.wg.Add(1)
+	go ch.read(conn)
+	ch.connChanged.Broadcast()
+}
+
+// dropConn closes the given connection if it is still the current one; worker starts reconnecting afterwards
+func (ch *socketChannel) dropConn(conn net.Conn) {
+	ch.mu.Lock()
+	if ch.conn != conn {
+		ch.mu.Unlock()
+		return
+	}
+	conn.Close()
+	ch.conn = nil
+	closed := ch.closed
+	ch.mu.Unlock()
+	if ch.mode == ModeWorker && !closed {
+		ch.wg.Add(1)
+		go ch.reconnect()
+	}
+}
+
+// waitForConn blocks until a connection is available, the channel is closed or the timeout elapses
+func (ch *socketChannel) waitForConn(timeout time.Duration) (net.Conn, error) {
+	timer := time.AfterFunc(timeout, func() {
+		ch.mu.Lock()
+		ch.connChanged.Broadcast()
+		ch.mu.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	for ch.conn == nil {
+		if ch.closed {
+			return nil, errors.New("channel already closed")
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("channel %v is not connected after %v", ch.path, timeout)
+		}
+		ch.connChanged.Wait()
+	}
+	if ch.closed {
+		return nil, errors.New("channel already closed")
+	}
+	return ch.conn, nil
+}
+
+// accept keeps accepting connections on the master side. The master has a single peer: a connection is only accepted
+// while the master is not connected, and from the process of the first accepted connection once it is known.
+func (ch *socketChannel) accept() {
+	defer ch.wg.Done()
+	for {
+		conn, err := ch.listener.Accept()
+		if err != nil {
+			if !ch.isClosed() {
+				ch.logger.Errorf("socket %v stopped accepting connections: %v", ch.path, err)
+			}
+			return
+		}
+		if err = ch.verifyPeer(conn); err != nil {
+			ch.logger.Warnf("rejected connection on socket %v: %v", ch.path, err)
+			conn.Close()
+			continue
+		}
+		ch.logger.Debugf("accepted connection on socket %v", ch.path)
+		ch.setConn(conn)
+	}
+}
+
+// verifyPeer checks that the master has no peer yet and that the connection comes from a process of the agent user,
+// the same process as the first peer if it reconnects. Where the peer credentials are not available, only the single
+// peer and the permissions of the socket directory protect the socket.
+func (ch *socketChannel) verifyPeer(conn net.Conn) error {
+	cred, err := getPeerCredentials(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read the peer credentials: %v", err)
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.conn != nil {
+		return errors.New("the channel is already connected")
+	}
+	if cred == nil {
+		return nil
+	}
+	if cred.uid != os.Geteuid() {
+		return fmt.Errorf("peer process %v runs as user %v", cred.pid, cred.uid)
+	}
+	if ch.peerPid != 0 && cred.pid != ch.peerPid {
+		return fmt.Errorf("peer process %v is not the process %v the channel is connected with", cred.pid, ch.peerPid)
+	}
+	ch.peerPid = cred.pid
+	return nil
+}
+
+// reconnect dials the master until it succeeds, the channel is closed or the reconnect window elapses
+func (ch *socketChannel) reconnect() {
+	defer ch.wg.Done()
+	deadline := time.Now().Add(ch.reconnectTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ch.closeChan:
+			return
+		case <-time.After(defaultReconnectRetryInterval):
+		}
+		conn, err := net.Dial("unix", ch.path)
+		if err != nil {
+			ch.logger.Debugf("failed to reconnect to socket %v: %v", ch.path, err)
+			continue
+		}
+		ch.logger.Infof("reconnected to socket %v", ch.path)
+		ch.setConn(conn)
+		return
+	}
+	ch.logger.Errorf("failed to reconnect to socket %v within %v, closing channel", ch.path, ch.reconnectTimeout)
+	ch.Close()
+}
+
+// read decodes frames from a connection until it breaks, data frames are delivered in order through onMessageChan.
+// A full onMessageChan blocks the reader, which in turn stops draining the socket and applies backpressure to the sender.
+// The peer is not checked while the reader is blocked, it is the receiving side that is slow.
+func (ch *socketChannel) read(conn net.Conn) {
+	defer ch.wg.Done()
+	reader := bufio.NewReader(conn)
+	for {
+		f, err := readFrame(reader)
+		if err != nil {
+			if err != io.EOF && !ch.isClosed() {
+				ch.logger.Errorf("failed to read from socket %v: %v", ch.path, err)
+			}
+			ch.dropConn(conn)
+			return
+		}
+		ch.mu.Lock()
+		ch.lastRecv = time.Now()
+		ch.mu.Unlock()
+		switch f.Type {
+		case frameTypeData:
+			if !ch.deliver(f.Payload) {
+				return
+			}
+		case frameTypePing:
+			if _, err = ch.writeFrame(conn, frame{Version: FrameVersion, Type: frameTypePong}); err != nil {
+				ch.logger.Debugf("failed to answer health check on socket %v: %v", ch.path, err)
+			}
+		case frameTypePong:
+		default:
+			//frames from a newer peer that this build does not understand are skipped rather than treated as corruption
+			ch.logger.Debugf("ignoring unsupported frame type %v (version %v)", f.Type, f.Version)
+		}
+	}
+}
+
+// deliver hands a data frame to onMessageChan, it returns false if the channel was closed first
+func (ch *socketChannel) deliver(payload string) bool {
+	select {
+	case ch.onMessageChan <- payload:
+		return true
+	default:
+	}
+	ch.setDelivering(true)
+	defer ch.setDelivering(false)
+	select {
+	case ch.onMessageChan <- payload:
+		return true
+	case <-ch.closeChan:
+		return false
+	}
+}
+
+// setDelivering records whether a reader waits for room in onMessageChan, the wait doesn't count against the peer
+func (ch *socketChannel) setDelivering(delivering bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.delivering = delivering
+	ch.lastRecv = time.Now()
+}
+
+// healthCheck pings the peer periodically and resets the connection when nothing is heard back within the health timeout
+func (ch *socketChannel) healthCheck() {
+	defer ch.wg.Done()
+	ticker := time.NewTicker(ch.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ch.closeChan:
+			return
+		case <-ticker.C:
+		}
+		ch.checkPeer()
+	}
+}
+
+// checkPeer resets the connection if nothing was heard from the peer within the health timeout, and pings it otherwise
+func (ch *socketChannel) checkPeer() {
+	ch.mu.Lock()
+	conn := ch.conn
+	lastRecv := ch.lastRecv
+	delivering := ch.delivering
+	ch.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	if !delivering && time.Since(lastRecv) > ch.healthCheckTimeout {
+		ch.logger.Errorf("socket %v peer is unresponsive for %v, resetting connection", ch.path, ch.healthCheckTimeout)
+		ch.dropConn(conn)
+		return
+	}
+	if _, err := ch.writeFrame(conn, frame{Version: FrameVersion, Type: frameTypePing}); err != nil {
+		ch.logger.Debugf("health check on socket %v failed: %v", ch.path, err)
+	}
+}
+
+// writeFrame writes a frame to the connection and returns the number of bytes written
+func (ch *socketChannel) writeFrame(conn net.Conn, f frame) (int, error) {
+	buf, err := json.Marshal(f)
+	if err != nil {
+		return 0, err
+	}
+	header := make([]byte, frameHeaderSize)
+	binary.BigEndian.PutUint32(header, uint32(len(buf)))
+	ch.writeMu.Lock()
+	defer ch.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(ch.sendTimeout))
+	return conn.Write(append(header, buf...))
+}
+
+func readFrame(reader io.Reader) (f frame, err error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size > maxFrameSize {
+		err = fmt.Errorf("frame size %v exceeds the limit %v", size, maxFrameSize)
+		return
+	}
+	buf := make([]byte, size)
+	if _, err = io.ReadFull(reader, buf); err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &f); err != nil {
+		err = fmt.Errorf("corrupted frame: %v", err)
+	}
+	return
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package channel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var socketTestMessages = []string{"{\"m\":0}", "{\"m\":1}", "{\"m\":2}"}
+
+func newTestSocketPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "socketchannel")
+	assert.NoError(t, err)
+	return filepath.Join(dir, "test.sock"), func() { os.RemoveAll(dir) }
+}
+
+func receive(t *testing.T, ch Channel, count int) []string {
+	var res []string
+	for i := 0; i < count; i++ {
+		select {
+		case msg := <-ch.GetMessage():
+			res = append(res, msg)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "timed out waiting for message")
+			return res
+		}
+	}
+	return res
+}
+
+func TestSocketChannelDuplexTransmission(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	master, err := NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	worker, err := NewSocketChannel(log.NewMockLog(), ModeWorker, socketPath)
+	assert.NoError(t, err)
+
+	for _, msg := range socketTestMessages {
+		assert.NoError(t, worker.Send(msg))
+	}
+	assert.Equal(t, socketTestMessages, receive(t, master, len(socketTestMessages)))
+
+	for _, msg := range socketTestMessages {
+		assert.NoError(t, master.Send(msg))
+	}
+	assert.Equal(t, socketTestMessages, receive(t, worker, len(socketTestMessages)))
+
+	worker.Close()
+	master.Destroy()
+	_, ok := <-master.GetMessage()
+	assert.False(t, ok)
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSocketChannelWorkerReconnectsAfterMasterRestart(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	master, err := NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	worker, err := NewSocketChannel(log.NewMockLog(), ModeWorker, socketPath)
+	assert.NoError(t, err)
+	defer worker.Close()
+
+	//master goes away without removing the socket, simulating an agent crash
+	master.Close()
+	master, err = NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	defer master.Destroy()
+
+	assert.NoError(t, worker.Send(socketTestMessages[0]))
+	assert.Equal(t, socketTestMessages[:1], receive(t, master, 1))
+}
+
+func TestSocketChannelSendTimesOutWhenNotConnected(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	master, err := NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	defer master.Destroy()
+	master.sendTimeout = 100 * time.Millisecond
+
+	assert.Error(t, master.Send(socketTestMessages[0]))
+}
+
+func TestSocketChannelSendAfterClose(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	master, err := NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	master.Destroy()
+
+	assert.Error(t, master.Send(socketTestMessages[0]))
+}
+
+func TestSocketChannelAcceptsASinglePeer(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	master, err := NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	defer master.Destroy()
+	worker, err := NewSocketChannel(log.NewMockLog(), ModeWorker, socketPath)
+	assert.NoError(t, err)
+	defer worker.Close()
+	assert.NoError(t, worker.Send(socketTestMessages[0]))
+	assert.Equal(t, socketTestMessages[:1], receive(t, master, 1))
+
+	//a second connection is closed by the master
+	other, err := net.Dial("unix", socketPath)
+	assert.NoError(t, err)
+	defer other.Close()
+	other.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = other.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	assert.NoError(t, worker.Send(socketTestMessages[1]))
+	assert.Equal(t, socketTestMessages[1:2], receive(t, master, 1))
+	info, err := os.Stat(filepath.Dir(socketPath))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(defaultSocketDirCreateMode), info.Mode().Perm())
+}
+
+func TestSocketChannelKeepsPeerWhileDelivering(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	master, err := NewSocketChannel(log.NewMockLog(), ModeMaster, socketPath)
+	assert.NoError(t, err)
+	defer master.Destroy()
+	worker, err := NewSocketChannel(log.NewMockLog(), ModeWorker, socketPath)
+	assert.NoError(t, err)
+	defer worker.Close()
+	assert.NoError(t, worker.Send(socketTestMessages[0]))
+	assert.Equal(t, socketTestMessages[:1], receive(t, master, 1))
+
+	//the master is slow to drain its messages, the worker is still alive
+	master.mu.Lock()
+	master.delivering = true
+	master.lastRecv = time.Now().Add(-2 * master.healthCheckTimeout)
+	master.mu.Unlock()
+	master.checkPeer()
+	_, err = master.waitForConn(0)
+	assert.NoError(t, err)
+
+	master.mu.Lock()
+	master.delivering = false
+	master.mu.Unlock()
+	master.checkPeer()
+	_, err = master.waitForConn(0)
+	assert.Error(t, err)
+}
+
+func TestReadFrame(t *testing.T) {
+	valid := []byte(`{"v":1,"t":"data","p":"hello","future":true}`)
+	header := make([]byte, frameHeaderSize)
+	binary.BigEndian.PutUint32(header, uint32(len(valid)))
+	f, err := readFrame(bytes.NewReader(append(header, valid...)))
+	assert.NoError(t, err)
+	assert.Equal(t, frame{Version: 1, Type: frameTypeData, Payload: "hello"}, f)
+
+	corrupted := []byte(`{"v":1,"t":`)
+	binary.BigEndian.PutUint32(header, uint32(len(corrupted)))
+	_, err = readFrame(bytes.NewReader(append(header, corrupted...)))
+	assert.Error(t, err)
+
+	binary.BigEndian.PutUint32(header, maxFrameSize+1)
+	_, err = readFrame(bytes.NewReader(header))
+	assert.Error(t, err)
+}
+
+func TestRemoveSockets(t *testing.T) {
+	socketPath, cleanup := newTestSocketPath(t)
+	defer cleanup()
+	dir := filepath.Dir(socketPath)
+	assert.NoError(t, ioutil.WriteFile(socketPath, nil, 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.txt"), nil, 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "folder.sock"), 0700))
+
+	removed := removeSockets(log.NewMockLog(), dir)
+
+	assert.Equal(t, []string{"test.sock"}, removed)
+	_, err := os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "other.txt"))
+	assert.NoError(t, err)
+	assert.Empty(t, removeSockets(log.NewMockLog(), filepath.Join(dir, "missing")))
+}
@@ -376,6 +376,10 @@ func (p *FakeProcess) StartTime() time.Time {
 	return testStartDateTime
 }
 
+func (p *FakeProcess) Output() *proc.OutputBuffer {
+	return nil
+}
+
 func (p *FakeProcess) Wait() error {
 	//once the child is detached (controlled by our test engine), Wait() is illegal since the Executer is no longer the direct parent of the child
 	if !p.attached {
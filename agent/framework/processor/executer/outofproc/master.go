@@ -35,7 +35,7 @@ type OutOfProcExecuter struct {
 }
 
 var channelCreator = func(log log.T, mode channel.Mode, documentID string) (channel.Channel, error, bool) {
-	return channel.CreateFileChannel(log, mode, documentID)
+	return channel.CreateChannel(log, mode, documentID)
 }
 
 var processFinder = func(log log.T, procinfo contracts.OSProcInfo) bool {
@@ -49,9 +49,12 @@ var processFinder = func(log log.T, procinfo contracts.OSProcInfo) bool {
 }
 
 var processCreator = func(name string, argv []string) (proc.OSProcess, error) {
-	return proc.StartProcess(name, argv)
+	return proc.StartWorker(name, argv)
 }
 
+// workerDiagnosticsDirectory is where the latest output of the workers which exited abnormally is written
+var workerDiagnosticsDirectory = appconfig.WorkerDiagnosticsDirectory
+
 func NewOutOfProcExecuter(ctx context.T) *OutOfProcExecuter {
 	return &OutOfProcExecuter{
 		BasicExecuter: *basicexecuter.NewBasicExecuter(ctx),
@@ -69,7 +72,7 @@ func (e *OutOfProcExecuter) Run(
 	documentID := docState.DocumentInformation.DocumentID
 
 	//update context with the document id
-	e.ctx = e.ctx.With("[" + documentID + "]")
+	e.ctx = e.ctx.With("[documentID=" + documentID + "]")
 	log := e.ctx.Log()
 
 	//stopTimer signals messaging routine to stop, it's buffered because it needs to exit if messaging is already stopped and not receiving anymore
@@ -166,12 +169,7 @@ func (e *OutOfProcExecuter) initialize(stopTimer chan bool) (ipc channel.Channel
 		go timeout(stopTimer, stopTime, e.cancelFlag)
 	} else {
 		log.Debug("channel not found, starting a new process...")
-		var workerName string
-		if e.docState.DocumentType == contracts.StartSession {
-			workerName = appconfig.DefaultSessionWorker
-		} else {
-			workerName = appconfig.DefaultDocumentWorker
-		}
+		workerName := e.workerName()
 		var process proc.OSProcess
 		if process, err = processCreator(workerName, proc.FormArgv(documentID)); err != nil {
 			log.Errorf("start process: %v error: %v", workerName, err)
@@ -209,6 +207,11 @@ func (e *OutOfProcExecuter) WaitForProcess(stopTimer chan bool, process proc.OSP
 	//}()
 	if err := process.Wait(); err != nil {
 		log.Errorf("process: %v exited unsuccessfully, error message: %v", process.Pid(), err)
+		if path, saveErr := proc.SaveOutput(workerDiagnosticsDirectory, e.workerName(), process, err); saveErr != nil {
+			log.Warnf("failed to save the output of process: %v, error message: %v", process.Pid(), saveErr)
+		} else if path != "" {
+			log.Infof("the latest output of process: %v is saved to %v", process.Pid(), path)
+		}
 	} else {
 		log.Debugf("process: %v exited successfully, trying to stop messaging worker", process.Pid())
 	}
@@ -216,6 +219,14 @@ func (e *OutOfProcExecuter) WaitForProcess(stopTimer chan bool, process proc.OSP
 	timeout(stopTimer, defaultZombieProcessTimeout, e.cancelFlag)
 }
 
+// workerName returns the executable of the worker running the document
+func (e *OutOfProcExecuter) workerName() string {
+	if e.docState.DocumentType == contracts.StartSession {
+		return appconfig.DefaultSessionWorker
+	}
+	return appconfig.DefaultDocumentWorker
+}
+
 func timeout(stopTimer chan bool, duration time.Duration, cancelFlag task.CancelFlag) {
 	stopChan := make(chan bool)
 	//TODO refactor cancelFlag.Wait() to return channel instead of blocking call
@@ -1,6 +1,10 @@
 package outofproc
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -249,9 +253,15 @@ func TestInitializeProcessUnexpectedExited(t *testing.T) {
 		docState:   &testCase.docState,
 		cancelFlag: cancel,
 	}
+	output := proc.NewOutputBuffer(16)
+	output.Write([]byte("panic: runtime error"))
 	testCase.processMock.On("Wait").Return(err)
 	testCase.processMock.On("Pid").Return(testPid)
 	testCase.processMock.On("StartTime").Return(testStartDateTime)
+	testCase.processMock.On("Output").Return(output)
+	diagnosticsDir, _ := ioutil.TempDir("", "diagnostics")
+	defer os.RemoveAll(diagnosticsDir)
+	workerDiagnosticsDirectory = diagnosticsDir
 	//assert Wait() syscall is called
 	stopTimer := make(chan bool)
 	_, err2 := exe.initialize(stopTimer)
@@ -260,6 +270,12 @@ func TestInitializeProcessUnexpectedExited(t *testing.T) {
 	<-stopTimer
 	//assert pid is saved
 	assert.Equal(t, testPid, exe.docState.DocumentInformation.ProcInfo.Pid)
+	//assert the latest output of the worker is saved
+	files, _ := ioutil.ReadDir(diagnosticsDir)
+	assert.Len(t, files, 1)
+	content, _ := ioutil.ReadFile(filepath.Join(diagnosticsDir, files[0].Name()))
+	assert.Contains(t, string(content), "process exited with status 1")
+	assert.True(t, strings.HasSuffix(string(content), "\n---\nc: runtime error"))
 	//set job complete and kill is not called
 	cancel.Set(task.Completed)
 	testCase.processMock.AssertExpectations(t)
@@ -31,6 +31,8 @@ type WorkerBackend struct {
 	cancelFlag task.CancelFlag
 	runner     PluginRunner
 	stopChan   chan int
+	//message version negotiated with the master, replies are sent in this version so an older master can parse them
+	version string
 }
 
 //Executer backend formulate the run request to the worker, and collect back the responses from worker
@@ -84,9 +86,12 @@ func (p *ExecuterBackend) Close() {
 }
 
 //TODO handle error and logging, when err, ask messaging to stop
-//TODO version handling?
 func (p *ExecuterBackend) Process(datagram string) error {
-	t, content := ParseDatagram(datagram)
+	message, err := ParseMessage(datagram)
+	if err != nil {
+		return err
+	}
+	t, content := message.Type, message.Content
 	switch t {
 	case MessageTypeReply, MessageTypeComplete:
 		var docResult contracts.DocumentResult
@@ -122,15 +127,25 @@ func NewWorkerBackend(ctx context.T, runner PluginRunner) *WorkerBackend {
 		cancelFlag: task.NewChanneledCancelFlag(),
 		runner:     runner,
 		stopChan:   stopChan,
+		version:    GetLatestVersion(),
 	}
 }
 
 func (p *WorkerBackend) Process(datagram string) error {
-	t, content := ParseDatagram(datagram)
 	log := p.ctx.Log()
+	message, err := ParseMessage(datagram)
+	if err != nil {
+		return err
+	}
+	t, content := message.Type, message.Content
 	switch t {
 	case MessageTypePluginConfig:
 		log.Info("received plugin config message")
+		version, err := NegotiateVersion(message.Version)
+		if err != nil {
+			log.Errorf("failed to negotiate message version: %v", err)
+			return err
+		}
 		var docState contracts.DocumentState
 		log.Info(content)
 		if err := jsonutil.Unmarshal(content, &docState); err != nil {
@@ -139,6 +154,10 @@ func (p *WorkerBackend) Process(datagram string) error {
 			return err
 		}
 		p.once.Do(func() {
+			if version != p.version {
+				log.Infof("master speaks message version %v, replying in that version", version)
+				p.version = version
+			}
 			statusChan := make(chan contracts.PluginResult)
 			go p.runner(p.ctx, docState, statusChan, p.cancelFlag)
 			go p.pluginListener(statusChan)
@@ -171,7 +190,7 @@ func (p *WorkerBackend) pluginListener(statusChan chan contracts.PluginResult) {
 			LastPlugin:    "",
 		}
 		log.Info("sending document complete response...")
-		completeMessage, _ := CreateDatagram(MessageTypeComplete, docResult)
+		completeMessage, _ := CreateDatagramWithVersion(p.replyVersion(), MessageTypeComplete, docResult)
 		p.input <- completeMessage
 		close(p.input)
 		log.Info("stopping ipc worker...")
@@ -190,7 +209,7 @@ func (p *WorkerBackend) pluginListener(statusChan chan contracts.PluginResult) {
 			PluginResults: results,
 			LastPlugin:    res.PluginID,
 		}
-		replyMessage, _ := CreateDatagram(MessageTypeReply, docResult)
+		replyMessage, _ := CreateDatagramWithVersion(p.replyVersion(), MessageTypeReply, docResult)
 		log.Debugf("plugin: %v done, sending reply message...", res.PluginID)
 		p.input <- replyMessage
 	}
@@ -199,6 +218,14 @@ func (p *WorkerBackend) pluginListener(statusChan chan contracts.PluginResult) {
 
 }
 
+//replyVersion returns the version replies are sent in, latest version is used until a version is negotiated
+func (p *WorkerBackend) replyVersion() string {
+	if p.version == "" {
+		return GetLatestVersion()
+	}
+	return p.version
+}
+
 func (p *WorkerBackend) Accept() <-chan string {
 	return p.input
 }
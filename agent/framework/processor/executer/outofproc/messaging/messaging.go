@@ -2,6 +2,9 @@ package messaging
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
@@ -23,6 +26,9 @@ const (
 	MessageTypeCancel       = "cancel"
 )
 
+//Message versions in ascending order, a build must be able to parse every version listed here.
+//Add a version only along with a change of the message schema, and document the change here.
+//1.0: initial message schema
 var versions = []string{"1.0"}
 
 type Message struct {
@@ -48,14 +54,19 @@ func GetLatestVersion() string {
 
 //CreateDatagram marshals a given arbitrary object to raw json string
 //Message schema is determined by the current version, content struct is indicated by type field
-//TODO add version handling
 func CreateDatagram(t MessageType, content interface{}) (string, error) {
+	return CreateDatagramWithVersion(GetLatestVersion(), t, content)
+}
+
+//CreateDatagramWithVersion marshals a given arbitrary object to raw json string stamped with the given message version,
+//it is used to talk to a peer running an older build with the version negotiated with that peer
+func CreateDatagramWithVersion(version string, t MessageType, content interface{}) (string, error) {
 	contentStr, err := jsonutil.Marshal(content)
 	if err != nil {
 		return "", err
 	}
 	message := Message{
-		Version: GetLatestVersion(),
+		Version: version,
 		Type:    t,
 		Content: contentStr,
 	}
@@ -73,6 +84,74 @@ func ParseDatagram(datagram string) (MessageType, string) {
 	return message.Type, message.Content
 }
 
+//ParseMessage unmarshals a raw json datagram and validates its version is compatible with this build.
+//A message without a version comes from a build that predates versioning and is treated as the oldest version.
+func ParseMessage(datagram string) (message Message, err error) {
+	if err = jsonutil.Unmarshal(datagram, &message); err != nil {
+		return message, fmt.Errorf("failed to parse datagram: %v", err)
+	}
+	if message.Version == "" {
+		message.Version = versions[0]
+	}
+	if !IsVersionCompatible(message.Version) {
+		return message, fmt.Errorf("unsupported message version: %v", message.Version)
+	}
+	return message, nil
+}
+
+//IsVersionCompatible returns true if the given message version shares its major version with one of the versions of this build.
+//Minor versions are backward compatible by contract: newer minor versions only add fields or message types.
+func IsVersionCompatible(version string) bool {
+	major, _, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+	for _, v := range versions {
+		if m, _, _ := parseVersion(v); m == major {
+			return true
+		}
+	}
+	return false
+}
+
+//NegotiateVersion picks the message version to talk to a peer with, which is the lower of the peer version and the latest
+//version of this build. Peer without a version is treated as the oldest version.
+func NegotiateVersion(peerVersion string) (string, error) {
+	if peerVersion == "" {
+		return versions[0], nil
+	}
+	if !IsVersionCompatible(peerVersion) {
+		return "", fmt.Errorf("peer message version %v is not compatible with %v", peerVersion, GetLatestVersion())
+	}
+	if compareVersion(peerVersion, GetLatestVersion()) < 0 {
+		return peerVersion, nil
+	}
+	return GetLatestVersion(), nil
+}
+
+//parseVersion splits a major.minor version string
+func parseVersion(version string) (major int, minor int, err error) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid message version: %v", version)
+	}
+	if major, err = strconv.Atoi(parts[0]); err != nil {
+		return
+	}
+	minor, err = strconv.Atoi(parts[1])
+	return
+}
+
+//compareVersion returns negative, zero or positive if a is lower, equal or higher than b, invalid versions compare as lowest
+func compareVersion(a, b string) int {
+	aMajor, aMinor, _ := parseVersion(a)
+	bMajor, bMinor, _ := parseVersion(b)
+	if aMajor != bMajor {
+		return aMajor - bMajor
+	}
+	return aMinor - bMinor
+}
+
 // Messaging implements the duplex transmission between master and worker, it send datagram it received to data backend,
 // TODO ipc should not be destroyed within this worker, destroying ipc object should be done in its caller: Executer
 func Messaging(log log.T, ipc channel.Channel, backend MessagingBackend, stopTimer chan bool) (err error) {
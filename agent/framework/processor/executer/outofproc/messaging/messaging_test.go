@@ -5,6 +5,7 @@ import (
 
 	channelmock "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel/mock"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -94,3 +95,46 @@ func (m *BackendMock) Stop() <-chan int {
 func (m *BackendMock) Close() {
 	m.Called()
 }
+
+func TestNegotiateVersion(t *testing.T) {
+	version, err := NegotiateVersion("1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", version)
+
+	//peer from a newer build with a higher minor version speaks our latest version
+	version, err = NegotiateVersion("1.1")
+	assert.NoError(t, err)
+	assert.Equal(t, GetLatestVersion(), version)
+
+	version, err = NegotiateVersion("")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", version)
+
+	_, err = NegotiateVersion("2.0")
+	assert.Error(t, err)
+	_, err = NegotiateVersion("bad")
+	assert.Error(t, err)
+}
+
+func TestParseMessage(t *testing.T) {
+	datagram, err := CreateDatagramWithVersion("1.0", MessageTypeCancel, "cancel")
+	assert.NoError(t, err)
+	message, err := ParseMessage(datagram)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", message.Version)
+	assert.Equal(t, MessageType(MessageTypeCancel), message.Type)
+
+	datagram, err = CreateDatagramWithVersion("3.0", MessageTypeCancel, "cancel")
+	assert.NoError(t, err)
+	_, err = ParseMessage(datagram)
+	assert.Error(t, err)
+
+	//a build that predates versioning sends no version
+	message, err = ParseMessage(`{"type":"cancel","content":"\"cancel\""}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", message.Version)
+	assert.Equal(t, MessageType(MessageTypeCancel), message.Type)
+
+	_, err = ParseMessage("a very bad string")
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package procmock
 import (
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -29,3 +30,8 @@ func (m *MockedOSProcess) Wait() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+func (m *MockedOSProcess) Output() *proc.OutputBuffer {
+	args := m.Called()
+	return args.Get(0).(*proc.OutputBuffer)
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDiagnosticsFiles is how many output files of abnormally exited workers are kept, the oldest are removed first
+const maxDiagnosticsFiles = 50
+
+// OutputBuffer is a ring buffer keeping the latest bytes written to it, it captures the stdout and stderr of a worker
+type OutputBuffer struct {
+	mu      sync.Mutex
+	data    []byte
+	next    int
+	full    bool
+	written int64
+}
+
+// NewOutputBuffer returns a buffer keeping the latest size bytes written to it
+func NewOutputBuffer(size int) *OutputBuffer {
+	return &OutputBuffer{data: make([]byte, size)}
+}
+
+// Write keeps the latest bytes of p, overwriting the oldest bytes of the buffer once it is full
+func (b *OutputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.written += int64(len(p))
+	size := len(b.data)
+	if size == 0 {
+		return len(p), nil
+	}
+	if len(p) >= size {
+		copy(b.data, p[len(p)-size:])
+		b.next = 0
+		b.full = true
+		return len(p), nil
+	}
+	n := copy(b.data[b.next:], p)
+	copy(b.data, p[n:])
+	if b.next+len(p) >= size {
+		b.full = true
+	}
+	b.next = (b.next + len(p)) % size
+	return len(p), nil
+}
+
+// Bytes returns the content of the buffer from the oldest to the latest byte
+func (b *OutputBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]byte(nil), b.data[:b.next]...)
+	}
+	return append(append([]byte(nil), b.data[b.next:]...), b.data[:b.next]...)
+}
+
+// Truncated returns true if older output was overwritten by the latest output
+func (b *OutputBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written > int64(len(b.data))
+}
+
+// SaveOutput writes the captured output of a worker which exited abnormally to the diagnostics directory and removes
+// the oldest output files beyond maxDiagnosticsFiles, it returns the path of the file
+func SaveOutput(dir string, name string, process OSProcess, exitErr error) (string, error) {
+	output := process.Output()
+	if output == nil {
+		return "", nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "worker: %v\npid: %v\nstarted: %v\nexited: %v\nerror: %v\n",
+		name, process.Pid(), process.StartTime().Format(time.RFC3339), time.Now().UTC().Format(time.RFC3339), exitErr)
+	if output.Truncated() {
+		content.WriteString("the output before the following was overwritten\n")
+	}
+	content.WriteString("---\n")
+	content.Write(output.Bytes())
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d-%d.log", filepath.Base(name), process.Pid(), time.Now().UnixNano()))
+	if err := ioutil.WriteFile(path, content.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	pruneDiagnostics(dir)
+	return path, nil
+}
+
+// pruneDiagnostics removes the oldest output files of the diagnostics directory beyond maxDiagnosticsFiles
+func pruneDiagnostics(dir string) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var outputs []os.FileInfo
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".log") {
+			outputs = append(outputs, file)
+		}
+	}
+	if len(outputs) <= maxDiagnosticsFiles {
+		return
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].ModTime().Before(outputs[j].ModTime())
+	})
+	for _, file := range outputs[:len(outputs)-maxDiagnosticsFiles] {
+		os.Remove(filepath.Join(dir, file.Name()))
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputBufferKeepsLatestBytes(t *testing.T) {
+	buffer := NewOutputBuffer(5)
+
+	buffer.Write([]byte("abc"))
+	assert.Equal(t, "abc", string(buffer.Bytes()))
+	assert.False(t, buffer.Truncated())
+
+	buffer.Write([]byte("defg"))
+	assert.Equal(t, "cdefg", string(buffer.Bytes()))
+	assert.True(t, buffer.Truncated())
+
+	buffer.Write([]byte("0123456"))
+	assert.Equal(t, "23456", string(buffer.Bytes()))
+}
+
+func TestSaveOutputRemovesOldestFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "diagnostics")
+	defer os.RemoveAll(dir)
+	output := NewOutputBuffer(16)
+	output.Write([]byte("fatal error"))
+	process := &WorkerProcess{output: output}
+
+	for i := 0; i < maxDiagnosticsFiles+2; i++ {
+		_, err := SaveOutput(dir, "ssm-document-worker", &savedProcess{process}, nil)
+		assert.NoError(t, err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, maxDiagnosticsFiles)
+}
+
+func TestSaveOutputWithoutCapture(t *testing.T) {
+	path, err := SaveOutput("", "ssm-document-worker", &savedProcess{&WorkerProcess{}}, nil)
+
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+}
+
+// savedProcess is a worker process which already exited
+type savedProcess struct {
+	*WorkerProcess
+}
+
+func (p *savedProcess) Pid() int {
+	return 42
+}
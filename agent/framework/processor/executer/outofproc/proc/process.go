@@ -18,8 +18,10 @@ import (
 	"time"
 
 	"errors"
+	"fmt"
 
 	"os/exec"
+	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -37,12 +39,15 @@ type OSProcess interface {
 	//On Windows service controller, stop service does not kill orphan by default
 	//TODO confirm MSI Installer does not kill the child process
 	Wait() error
+	//the latest stdout and stderr of the child process, nil if its output is not captured
+	Output() *OutputBuffer
 }
 
 //impl of OSProcess with os.Process embed
 type WorkerProcess struct {
 	*exec.Cmd
 	startTime time.Time
+	output    *OutputBuffer
 }
 
 func (p *WorkerProcess) Pid() int {
@@ -62,20 +67,58 @@ func (p *WorkerProcess) Wait() error {
 	return p.Cmd.Wait()
 }
 
-//start a child process, with the resources attached to its parent
-func StartProcess(name string, argv []string) (OSProcess, error) {
-	//TODO connect stdin and stdout to avoid seelog error
+func (p *WorkerProcess) Output() *OutputBuffer {
+	return p.output
+}
+
+//start a child process, with the resources attached to its parent, the latest outputSize bytes of its stdout and stderr
+//are kept in memory, 0 leaves its output unconnected
+func StartProcess(name string, argv []string, outputSize int) (OSProcess, error) {
+	//TODO connect stdin to avoid seelog error
 	cmd := exec.Command(name, argv...)
 	prepareProcess(cmd)
+	var output *OutputBuffer
+	if outputSize > 0 {
+		output = NewOutputBuffer(outputSize)
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
 	err := cmd.Start()
 	p := WorkerProcess{
-		cmd,
-		time.Now().UTC(),
+		Cmd:       cmd,
+		startTime: time.Now().UTC(),
+		output:    output,
 	}
 
 	return &p, err
 }
 
+// StartWorker starts a worker process the way the agent configuration selects, on Windows it can be started by the
+// Task Scheduler for hosts whose endpoint security blocks the child processes of the agent
+func StartWorker(name string, argv []string) (OSProcess, error) {
+	launch := appconfig.WorkerLaunchProcess
+	outputSize := appconfig.DefaultWorkerOutputBufferKB * 1024
+	if config, err := appconfig.Config(false); err == nil {
+		launch = config.Agent.WorkerLaunch
+		outputSize = config.Agent.WorkerOutputBufferKB * 1024
+	}
+	switch launch {
+	case appconfig.WorkerLaunchTaskScheduler:
+		return startScheduledTask(name, argv)
+	case appconfig.WorkerLaunchFallback:
+		process, err := StartProcess(name, argv, outputSize)
+		if err == nil {
+			return process, nil
+		}
+		process, taskErr := startScheduledTask(name, argv)
+		if taskErr != nil {
+			return nil, fmt.Errorf("%v, starting it with the task scheduler failed too: %v", err, taskErr)
+		}
+		return process, nil
+	}
+	return StartProcess(name, argv, outputSize)
+}
+
 //os.FindProcess() doesn't work on Linux: https://groups.google.com/forum/#!topic/golang-nuts/hqrp0UHBK9k
 //what we can only do is check whether it exists
 func IsProcessExists(log log.T, pid int, createTime time.Time) bool {
@@ -86,8 +129,15 @@ func IsProcessExists(log log.T, pid int, createTime time.Time) bool {
 	return found
 }
 
+// scheduledTaskMarkerPrefix prefixes the argument identifying the scheduled task a worker is started by, the worker
+// ignores it
+const scheduledTaskMarkerPrefix = "--scheduled-task="
+
 //TODO figure out why sometimes argv does not contain program name
 func ParseArgv(argv []string) (string, string, error) {
+	if len(argv) > 0 && strings.HasPrefix(argv[len(argv)-1], scheduledTaskMarkerPrefix) {
+		argv = argv[:len(argv)-1]
+	}
 	if len(argv) == 1 {
 		if argv[0] == appconfig.DefaultDocumentWorker || argv[0] == appconfig.DefaultSessionWorker {
 			return "", "", errors.New("insufficient argument number")
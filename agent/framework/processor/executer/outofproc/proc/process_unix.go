@@ -17,7 +17,9 @@
 package proc
 
 import (
+	"errors"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
@@ -36,6 +38,12 @@ func prepareProcess(command *exec.Cmd) {
 	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 }
 
+// IgnoreBrokenOutput lets a worker outlive the agent capturing its output, writing to the stdout or stderr pipe of an
+// exited agent fails instead of killing the worker with SIGPIPE
+func IgnoreBrokenOutput() {
+	signal.Ignore(syscall.SIGPIPE)
+}
+
 //given the pid and the unix process startTime format string, return whether the process is still alive
 func find_process(pid int, startTime time.Time) (bool, error) {
 	output, err := ps()
@@ -67,3 +75,8 @@ func compareTimes(startTime time.Time, timeRaw string) bool {
 	parsedTime, _ := time.Parse(time.ANSIC, timeRaw)
 	return startTime.Before(parsedTime.Add(time.Second)) && startTime.After(parsedTime.Add(-time.Second))
 }
+
+// startScheduledTask is not supported, the Task Scheduler is only available on Windows
+func startScheduledTask(name string, argv []string) (OSProcess, error) {
+	return nil, errors.New("workers can only be started with the task scheduler on Windows")
+}
@@ -33,6 +33,10 @@ func prepareProcess(command *exec.Cmd) {
 	// nothing to do on windows
 }
 
+// IgnoreBrokenOutput does nothing on windows, writing to a broken pipe does not raise a signal
+func IgnoreBrokenOutput() {
+}
+
 //given the pid and the high order filetime, look up the process
 func find_process(pid int, startTime time.Time) (bool, error) {
 	const da = syscall.STANDARD_RIGHTS_READ |
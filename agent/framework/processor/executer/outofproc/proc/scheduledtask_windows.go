@@ -0,0 +1,275 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package proc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+const (
+	// scheduledTaskPrefix is the name prefix of the scheduled tasks the workers are started with
+	scheduledTaskPrefix = "AmazonSSMWorker-"
+	// scheduledTaskStartTimeout is how long the worker process of a scheduled task is looked for once the task runs
+	scheduledTaskStartTimeout = 30 * time.Second
+	scheduledTaskPollInterval = 200 * time.Millisecond
+
+	// processCommandLineInformation is the class of NtQueryInformationProcess returning the command line of a process
+	processCommandLineInformation = 60
+	// statusInfoLengthMismatch is returned by NtQueryInformationProcess when the buffer is too small
+	statusInfoLengthMismatch = 0xC0000004
+	// processQueryLimitedInformation is the access right needed to query the command line of a process
+	processQueryLimitedInformation = 0x1000
+)
+
+// scheduledTaskTemplate defines an on demand, hidden task without triggers or time limit running as LocalSystem,
+// like the agent itself
+const scheduledTaskTemplate = `<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo>
+    <Description>Amazon SSM Agent worker</Description>
+  </RegistrationInfo>
+  <Principals>
+    <Principal id="Author">
+      <UserId>S-1-5-18</UserId>
+      <RunLevel>HighestAvailable</RunLevel>
+    </Principal>
+  </Principals>
+  <Settings>
+    <MultipleInstancesPolicy>Parallel</MultipleInstancesPolicy>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <AllowHardTerminate>true</AllowHardTerminate>
+    <AllowStartOnDemand>true</AllowStartOnDemand>
+    <Enabled>true</Enabled>
+    <Hidden>true</Hidden>
+    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>
+    <Priority>7</Priority>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>%v</Command>
+      <Arguments>%v</Arguments>
+      <WorkingDirectory>%v</WorkingDirectory>
+    </Exec>
+  </Actions>
+</Task>
+`
+
+var (
+	invalidTaskNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+	ntdll                         = syscall.NewLazyDLL("ntdll.dll")
+	procNtQueryInformationProcess = ntdll.NewProc("NtQueryInformationProcess")
+)
+
+// schtasks runs the Task Scheduler command line, a signed system binary endpoint security allows to run
+var schtasks = func(args ...string) error {
+	output, err := exec.Command(filepath.Join(os.Getenv("SystemRoot"), "System32", "schtasks.exe"), args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("schtasks %v failed: %v %v", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// scheduledTaskProcess is a worker started by a scheduled task, the task is deleted once the worker exits
+type scheduledTaskProcess struct {
+	process   *os.Process
+	taskName  string
+	startTime time.Time
+}
+
+func (p *scheduledTaskProcess) Pid() int {
+	return p.process.Pid
+}
+
+func (p *scheduledTaskProcess) StartTime() time.Time {
+	return p.startTime
+}
+
+func (p *scheduledTaskProcess) Kill() error {
+	err := p.process.Kill()
+	schtasks("/Delete", "/TN", p.taskName, "/F")
+	return err
+}
+
+// Output returns nil, the output of a worker started by the Task Scheduler cannot be captured
+func (p *scheduledTaskProcess) Output() *OutputBuffer {
+	return nil
+}
+
+func (p *scheduledTaskProcess) Wait() error {
+	state, err := p.process.Wait()
+	schtasks("/Delete", "/TN", p.taskName, "/F")
+	if err != nil {
+		return err
+	}
+	if !state.Success() {
+		return fmt.Errorf("exit status %v", state.ExitCode())
+	}
+	return nil
+}
+
+// startScheduledTask registers an on demand scheduled task running the worker, runs it and returns its process. The
+// worker is given a unique marker argument, its process is the one whose command line holds the marker.
+func startScheduledTask(name string, argv []string) (OSProcess, error) {
+	taskName := scheduledTaskPrefix + invalidTaskNameChars.ReplaceAllString(strings.Join(argv, "-"), "_")
+	marker, err := scheduledTaskMarker()
+	if err != nil {
+		return nil, err
+	}
+	definition, err := scheduledTaskDefinition(name, append(append([]string{}, argv...), marker))
+	if err != nil {
+		return nil, err
+	}
+	definitionFile, err := ioutil.TempFile("", "ssm-worker-task-*.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(definitionFile.Name())
+	_, err = definitionFile.Write(definition)
+	if closeErr := definitionFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = schtasks("/Create", "/TN", taskName, "/XML", definitionFile.Name(), "/F"); err != nil {
+		return nil, err
+	}
+	if err = schtasks("/Run", "/TN", taskName); err != nil {
+		schtasks("/Delete", "/TN", taskName, "/F")
+		return nil, err
+	}
+	startTime := time.Now().UTC()
+	for deadline := time.Now().Add(scheduledTaskStartTimeout); time.Now().Before(deadline); time.Sleep(scheduledTaskPollInterval) {
+		pids, err := workerProcesses(name)
+		if err != nil {
+			continue
+		}
+		for _, pid := range pids {
+			if commandLine, err := processCommandLine(pid); err != nil || !strings.Contains(commandLine, marker) {
+				continue
+			}
+			process, err := os.FindProcess(int(pid))
+			if err != nil {
+				continue
+			}
+			return &scheduledTaskProcess{process: process, taskName: taskName, startTime: startTime}, nil
+		}
+	}
+	schtasks("/End", "/TN", taskName)
+	schtasks("/Delete", "/TN", taskName, "/F")
+	return nil, fmt.Errorf("the process of scheduled task %v did not start within %v", taskName, scheduledTaskStartTimeout)
+}
+
+// scheduledTaskMarker returns a random argument identifying the worker started by a scheduled task
+func scheduledTaskMarker() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return scheduledTaskMarkerPrefix + hex.EncodeToString(random), nil
+}
+
+// scheduledTaskDefinition returns the task definition running the worker, encoded in UTF-16 as schtasks expects
+func scheduledTaskDefinition(name string, argv []string) ([]byte, error) {
+	var arguments []string
+	for _, arg := range argv {
+		arguments = append(arguments, syscall.EscapeArg(arg))
+	}
+	escaped := make([]string, 3)
+	for i, value := range []string{name, strings.Join(arguments, " "), filepath.Dir(name)} {
+		var buffer bytes.Buffer
+		if err := xml.EscapeText(&buffer, []byte(value)); err != nil {
+			return nil, err
+		}
+		escaped[i] = buffer.String()
+	}
+	definition := fmt.Sprintf(scheduledTaskTemplate, escaped[0], escaped[1], escaped[2])
+
+	var encoded bytes.Buffer
+	encoded.Write([]byte{0xFF, 0xFE})
+	binary.Write(&encoded, binary.LittleEndian, utf16.Encode([]rune(definition)))
+	return encoded.Bytes(), nil
+}
+
+// workerProcesses returns the ids of the processes running the executable of the worker
+func workerProcesses(name string) ([]uint32, error) {
+	snapshot, err := syscall.CreateToolhelp32Snapshot(syscall.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.CloseHandle(snapshot)
+
+	executable := strings.ToLower(filepath.Base(name))
+	var pids []uint32
+	var entry syscall.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = syscall.Process32First(snapshot, &entry); err == nil; err = syscall.Process32Next(snapshot, &entry) {
+		if strings.ToLower(syscall.UTF16ToString(entry.ExeFile[:])) == executable {
+			pids = append(pids, entry.ProcessID)
+		}
+	}
+	return pids, nil
+}
+
+// unicodeString is the UNICODE_STRING returned by NtQueryInformationProcess, followed by its characters
+type unicodeString struct {
+	Length        uint16
+	MaximumLength uint16
+	Buffer        *uint16
+}
+
+// processCommandLine returns the command line of a process
+func processCommandLine(pid uint32) (string, error) {
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, pid)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(handle)
+
+	var size uint32
+	status, _, _ := procNtQueryInformationProcess.Call(uintptr(handle), processCommandLineInformation, 0, 0, uintptr(unsafe.Pointer(&size)))
+	if status != statusInfoLengthMismatch || size < uint32(unsafe.Sizeof(unicodeString{})) {
+		return "", fmt.Errorf("failed to query the command line of process %v, status 0x%x", pid, status)
+	}
+	buffer := make([]byte, size)
+	status, _, _ = procNtQueryInformationProcess.Call(uintptr(handle), processCommandLineInformation,
+		uintptr(unsafe.Pointer(&buffer[0])), uintptr(size), uintptr(unsafe.Pointer(&size)))
+	if status != 0 {
+		return "", fmt.Errorf("failed to query the command line of process %v, status 0x%x", pid, status)
+	}
+	commandLine := (*unicodeString)(unsafe.Pointer(&buffer[0]))
+	if commandLine.Buffer == nil || commandLine.Length == 0 {
+		return "", nil
+	}
+	return syscall.UTF16ToString((*[1 << 20]uint16)(unsafe.Pointer(commandLine.Buffer))[: commandLine.Length/2 : commandLine.Length/2]), nil
+}
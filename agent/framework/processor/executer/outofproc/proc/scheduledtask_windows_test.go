@@ -0,0 +1,73 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package proc
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduledTaskDefinition(t *testing.T) {
+	encoded, err := scheduledTaskDefinition(`C:\Program Files\Amazon\SSM\ssm-document-worker.exe`, []string{"a&b <c>"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []byte{0xFF, 0xFE}, encoded[:2])
+	units := make([]uint16, (len(encoded)-2)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(encoded[2+2*i:])
+	}
+	definition := string(utf16.Decode(units))
+	assert.True(t, strings.HasPrefix(definition, `<?xml version="1.0" encoding="UTF-16"?>`))
+	assert.Contains(t, definition, `<Command>C:\Program Files\Amazon\SSM\ssm-document-worker.exe</Command>`)
+	assert.Contains(t, definition, `<Arguments>&#34;a&amp;b &lt;c&gt;&#34;</Arguments>`)
+	assert.Contains(t, definition, `<WorkingDirectory>C:\Program Files\Amazon\SSM</WorkingDirectory>`)
+	assert.Contains(t, definition, `<Hidden>true</Hidden>`)
+}
+
+func TestStartScheduledTaskFailsWhenTaskCannotBeCreated(t *testing.T) {
+	defer func(original func(args ...string) error) { schtasks = original }(schtasks)
+	var calls []string
+	schtasks = func(args ...string) error {
+		calls = append(calls, args[0])
+		if args[0] == "/Run" {
+			return assert.AnError
+		}
+		return nil
+	}
+
+	_, err := startScheduledTask(`C:\Program Files\Amazon\SSM\ssm-document-worker.exe`, []string{"document"})
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"/Create", "/Run", "/Delete"}, calls)
+}
+
+func TestScheduledTaskMarkerIsUniqueAndIgnoredByTheWorker(t *testing.T) {
+	marker, err := scheduledTaskMarker()
+	assert.NoError(t, err)
+	other, err := scheduledTaskMarker()
+	assert.NoError(t, err)
+	assert.NotEqual(t, marker, other)
+
+	channelName, instanceID, err := ParseArgv([]string{appconfig.DefaultDocumentWorker, "documentID", marker})
+	assert.NoError(t, err)
+	assert.Equal(t, "documentID", channelName)
+	assert.Equal(t, "", instanceID)
+}
@@ -17,6 +17,7 @@ package main
 import (
 	"os"
 
+	"github.com/aws/amazon-ssm-agent/agent/activitymetrics"
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
@@ -26,6 +27,8 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/plugin"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
@@ -53,11 +56,15 @@ var sessionPluginRunner = func(
 // initialize populates session worker information.
 //rule of thumb is, do not trigger extra file operation or other intricate dependencies during this setup, make it light weight
 func initialize(args []string) (context.T, string, error) {
+	// the agent keeps the latest output of the worker, it must not kill the worker once the agent exits
+	proc.IgnoreBrokenOutput()
 	// intialize a light weight logger, use the default seelog config logger
 	logger := ssmlog.SSMLogger(false)
 
 	// initialize appconfig, use default config
 	config := appconfig.DefaultConfig()
+	// outbound connections of the worker follow the agent tls policy
+	network.ApplyTLSPolicy(logger)
 
 	logger.Debugf("Session worker parse args: %v", args)
 	channelName, _, err := proc.ParseArgv(args)
@@ -83,6 +90,12 @@ func main() {
 		log.Errorf("Session worker failed to initialize: %s", err)
 		return
 	}
+	// the bytes forwarded by the sessions are counted in the worker, which publishes them itself
+	if appConfig, err := appconfig.Config(false); err == nil && appConfig.ActivityMetrics.Enabled {
+		instanceID, _ := platform.InstanceID()
+		activitymetrics.Start(log, appConfig.ActivityMetrics, instanceID)
+		defer activitymetrics.Stop()
+	}
 
 	createFileChannelAndExecutePlugin(context, channelName)
 	log.Info("Session worker closed")
@@ -95,7 +108,7 @@ func createFileChannelAndExecutePlugin(context context.T, channelName string) {
 	log := context.Log()
 	log.Infof("document: %v worker started", channelName)
 	//create channel from the given handle identifier by master
-	ipc, err, _ := channel.CreateFileChannel(log, channel.ModeWorker, channelName)
+	ipc, err, _ := channel.CreateChannel(log, channel.ModeWorker, channelName)
 	if err != nil {
 		log.Errorf("failed to create channel: %v", err)
 		return
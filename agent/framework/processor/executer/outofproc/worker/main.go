@@ -14,6 +14,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -38,10 +39,14 @@ var pluginRunner = func(
 //TODO add log level to args
 //rule of thumb is, do not trigger extra file operation or other intricate dependencies during this setup, make it light weight
 func initialize(args []string) (context.T, string, error) {
+	// the agent keeps the latest output of the worker, it must not kill the worker once the agent exits
+	proc.IgnoreBrokenOutput()
 	// intialize a light weight logger, use the default seelog config logger
 	logger := ssmlog.SSMLogger(false)
 	// initialize appconfig, use default config
 	config := appconfig.DefaultConfig()
+	// outbound connections of the worker follow the agent tls policy
+	network.ApplyTLSPolicy(logger)
 	logger.Debugf("parsing args: %v", args)
 	channelName, instanceID, err := proc.ParseArgv(args)
 	//cache the instanceID here in order to avoid throttle by metadata endpoint.
@@ -66,7 +71,7 @@ func main() {
 	}
 	logger.Infof("document: %v worker started", channelName)
 	//create channel from the given handle identifier by master
-	ipc, err, _ := channel.CreateFileChannel(logger, channel.ModeWorker, channelName)
+	ipc, err, _ := channel.CreateChannel(logger, channel.ModeWorker, channelName)
 	if err != nil {
 		logger.Errorf("failed to create channel: %v", err)
 		logger.Close()
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/proc"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +17,14 @@ func TestParseArgs(t *testing.T) {
 	assert.Equal(t, "instanceID", instanceID)
 }
 
+func TestParseArgsIgnoresScheduledTaskMarker(t *testing.T) {
+	input := []string{appconfig.DefaultDocumentWorker, "documentID", "--scheduled-task=0123456789abcdef"}
+	channelName, instanceID, err := proc.ParseArgv(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "documentID", channelName)
+	assert.Equal(t, "", instanceID)
+}
+
 func TestWorkerInitializeLightWeight(t *testing.T) {
 	ctxLight, name, err := initialize([]string{"test_binary", "documentID", "instanceID"})
 	assert.NoError(t, err)
@@ -23,13 +23,21 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurecontainers"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/dns"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/dockercontainer"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/execplugin"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/fanout"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/firewall"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/installcertificate"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/kubernetesnode"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/lifecycleaction"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/lrpminvoker"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/refreshassociation"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/rundocument"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/runscript"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/timesync"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/updatessmagent"
 	"github.com/aws/amazon-ssm-agent/agent/session/plugins/shell"
 )
@@ -41,7 +49,17 @@ var allPlugins = map[string]struct{}{
 	appconfig.PluginNameAwsAgentUpdate:         {},
 	appconfig.PluginNameAwsApplications:        {},
 	appconfig.PluginNameAwsConfigureDaemon:     {},
+	appconfig.PluginNameAwsConfigureDefender:   {},
+	appconfig.PluginNameAwsConfigureFirewall:   {},
 	appconfig.PluginNameAwsConfigurePackage:    {},
+	appconfig.PluginNameAwsInstallCertificate:  {},
+	appconfig.PluginNameAwsKubernetesNode:      {},
+	appconfig.PluginNameAwsConfigureVolume:     {},
+	appconfig.PluginNameAwsConfigureTimeSync:   {},
+	appconfig.PluginNameAwsConfigureDns:        {},
+	appconfig.PluginNameAwsLifecycleAction:     {},
+	appconfig.PluginNameAwsLogTailer:           {},
+	appconfig.PluginNameAwsFanOut:              {},
 	appconfig.PluginNameAwsPowerShellModule:    {},
 	appconfig.PluginNameAwsRunPowerShellScript: {},
 	appconfig.PluginNameAwsRunShellScript:      {},
@@ -71,6 +89,13 @@ func (f CloudWatchFactory) Create(context context.T) (runpluginutil.T, error) {
 	return lrpminvoker.NewPlugin(appconfig.PluginNameCloudWatch)
 }
 
+type LogTailerFactory struct {
+}
+
+func (f LogTailerFactory) Create(context context.T) (runpluginutil.T, error) {
+	return lrpminvoker.NewPlugin(appconfig.PluginNameAwsLogTailer)
+}
+
 type InventoryGathererFactory struct {
 }
 
@@ -113,6 +138,55 @@ func (f ConfigurePackageFactory) Create(context context.T) (runpluginutil.T, err
 	return configurepackage.NewPlugin()
 }
 
+type FirewallFactory struct {
+}
+
+func (f FirewallFactory) Create(context context.T) (runpluginutil.T, error) {
+	return firewall.NewPlugin()
+}
+
+type KubernetesNodeFactory struct {
+}
+
+func (f KubernetesNodeFactory) Create(context context.T) (runpluginutil.T, error) {
+	return kubernetesnode.NewPlugin()
+}
+
+type TimeSyncFactory struct {
+}
+
+func (f TimeSyncFactory) Create(context context.T) (runpluginutil.T, error) {
+	return timesync.NewPlugin()
+}
+
+type DnsFactory struct {
+}
+
+func (f DnsFactory) Create(context context.T) (runpluginutil.T, error) {
+	return dns.NewPlugin()
+}
+
+type LifecycleActionFactory struct {
+}
+
+func (f LifecycleActionFactory) Create(context context.T) (runpluginutil.T, error) {
+	return lifecycleaction.NewPlugin()
+}
+
+type FanOutFactory struct {
+}
+
+func (f FanOutFactory) Create(context context.T) (runpluginutil.T, error) {
+	return fanout.NewPlugin()
+}
+
+type InstallCertificateFactory struct {
+}
+
+func (f InstallCertificateFactory) Create(context context.T) (runpluginutil.T, error) {
+	return installcertificate.NewPlugin()
+}
+
 type RefreshAssociationFactory struct {
 }
 
@@ -134,6 +208,15 @@ func (r RunDocumentFactory) Create(context context.T) (runpluginutil.T, error) {
 	return rundocument.NewPlugin()
 }
 
+// ExecPluginFactory creates the out-of-tree plugins run through the exec plugin protocol
+type ExecPluginFactory struct {
+	descriptor execplugin.Descriptor
+}
+
+func (f ExecPluginFactory) Create(context context.T) (runpluginutil.T, error) {
+	return execplugin.NewPlugin(f.descriptor, context.AppConfig().ExecPlugins)
+}
+
 type SessionShellFactory struct {
 }
 
@@ -165,6 +248,7 @@ func loadWorkers(context context.T) {
 	//Long running plugins are handled by lrpm. lrpminvoker is a worker plugin that can communicate with lrpm.
 	//that's why all long running plugins are first handled by lrpminvoker - which then hands off the work to lrpm.
 	plugins[appconfig.PluginNameCloudWatch] = CloudWatchFactory{}
+	plugins[appconfig.PluginNameAwsLogTailer] = LogTailerFactory{}
 
 	for key, value := range loadPlatformIndependentPlugins(context) {
 		plugins[key] = value
@@ -174,6 +258,14 @@ func loadWorkers(context context.T) {
 		plugins[key] = value
 	}
 
+	for key, value := range loadExecPlugins(context) {
+		if _, builtIn := plugins[key]; builtIn {
+			context.Log().Warnf("Exec plugin %v is not loaded, the agent already has a plugin with this name", key)
+			continue
+		}
+		plugins[key] = value
+	}
+
 	registeredPlugins = &plugins
 }
 
@@ -187,6 +279,17 @@ func loadSessionPlugins() {
 	registeredSessionPlugins = &sessionPlugins
 }
 
+// loadExecPlugins registers the out-of-tree plugins found in the exec plugin directory
+func loadExecPlugins(context context.T) runpluginutil.PluginRegistry {
+	var workerPlugins = runpluginutil.PluginRegistry{}
+
+	for _, descriptor := range execplugin.Discover(context.Log(), context.AppConfig().ExecPlugins) {
+		runpluginutil.RegisterExecPlugin(descriptor.Name)
+		workerPlugins[descriptor.Name] = ExecPluginFactory{descriptor: descriptor}
+	}
+	return workerPlugins
+}
+
 // loadPlatformIndependentPlugins registers plugins common to all platforms
 func loadPlatformIndependentPlugins(context context.T) runpluginutil.PluginRegistry {
 	var workerPlugins = runpluginutil.PluginRegistry{}
@@ -226,5 +329,33 @@ func loadPlatformIndependentPlugins(context context.T) runpluginutil.PluginRegis
 	runDocumentPluginName := rundocument.Name()
 	workerPlugins[runDocumentPluginName] = RunDocumentFactory{}
 
+	// registering aws:installCertificate
+	installCertificatePluginName := installcertificate.Name()
+	workerPlugins[installCertificatePluginName] = InstallCertificateFactory{}
+
+	// registering aws:configureFirewall
+	firewallPluginName := firewall.Name()
+	workerPlugins[firewallPluginName] = FirewallFactory{}
+
+	// registering aws:kubernetesNode
+	kubernetesNodePluginName := kubernetesnode.Name()
+	workerPlugins[kubernetesNodePluginName] = KubernetesNodeFactory{}
+
+	// registering aws:configureTimeSync
+	timeSyncPluginName := timesync.Name()
+	workerPlugins[timeSyncPluginName] = TimeSyncFactory{}
+
+	// registering aws:configureDns
+	dnsPluginName := dns.Name()
+	workerPlugins[dnsPluginName] = DnsFactory{}
+
+	// registering aws:lifecycleAction
+	lifecycleActionPluginName := lifecycleaction.Name()
+	workerPlugins[lifecycleActionPluginName] = LifecycleActionFactory{}
+
+	// registering aws:fanOut
+	fanOutPluginName := fanout.Name()
+	workerPlugins[fanOutPluginName] = FanOutFactory{}
+
 	return workerPlugins
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/application"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/defender"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/domainjoin"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/psmodule"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/updateec2config"
@@ -48,6 +49,13 @@ func (f DomainJoinFactory) Create(context context.T) (runpluginutil.T, error) {
 	return domainjoin.NewPlugin()
 }
 
+type DefenderFactory struct {
+}
+
+func (f DefenderFactory) Create(context context.T) (runpluginutil.T, error) {
+	return defender.NewPlugin()
+}
+
 type UpdateEc2ConfigFactory struct {
 }
 
@@ -71,6 +79,10 @@ func loadPlatformDependentPlugins(context context.T) runpluginutil.PluginRegistr
 	domainJoinPluginName := domainjoin.Name()
 	workerPlugins[domainJoinPluginName] = DomainJoinFactory{}
 
+	// registering aws:configureDefender plugin
+	defenderPluginName := defender.Name()
+	workerPlugins[defenderPluginName] = DefenderFactory{}
+
 	// registering aws:updateAgent plugin.
 	updateEC2AgentPluginName := updateec2config.Name()
 	workerPlugins[updateEC2AgentPluginName] = UpdateEc2ConfigFactory{}
@@ -0,0 +1,89 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// findNamespace returns the first configured namespace whose document name patterns match the document, nil otherwise.
+func findNamespace(namespaces []appconfig.NamespaceCfg, documentName string) *appconfig.NamespaceCfg {
+	for i, namespace := range namespaces {
+		for _, pattern := range namespace.DocumentNames {
+			if matched, _ := path.Match(pattern, documentName); matched {
+				return &namespaces[i]
+			}
+		}
+	}
+	return nil
+}
+
+// applyNamespace enforces the namespace policy on a document before it's executed.
+// Steps running a plugin outside the namespace allowlist are failed, so the executer skips them,
+// and the output is redirected to the namespace bucket.
+func applyNamespace(log log.T, namespace *appconfig.NamespaceCfg, docState *contracts.DocumentState) {
+	log.Infof("Applying namespace %v to document %v", namespace.Name, docState.DocumentInformation.DocumentName)
+
+	if namespace.OutputS3BucketName != "" {
+		docState.IOConfig.OutputS3BucketName = namespace.OutputS3BucketName
+	}
+
+	for i := range docState.InstancePluginsInformation {
+		pluginState := &docState.InstancePluginsInformation[i]
+		if namespace.OutputS3BucketName != "" {
+			pluginState.Configuration.OutputS3BucketName = namespace.OutputS3BucketName
+		}
+		if isPluginAllowed(namespace, pluginState.Name) || isPluginDone(pluginState.Result.Status) {
+			continue
+		}
+		now := time.Now()
+		pluginState.Result.PluginID = pluginState.Id
+		pluginState.Result.PluginName = pluginState.Name
+		pluginState.Result.Status = contracts.ResultStatusFailed
+		pluginState.Result.Code = 1
+		pluginState.Result.Error = fmt.Sprintf("plugin %v is not allowed in namespace %v", pluginState.Name, namespace.Name)
+		pluginState.Result.ErrorCode = contracts.ErrorCodePermissionDenied
+		pluginState.Result.StartDateTime = now
+		pluginState.Result.EndDateTime = now
+		log.Error(pluginState.Result.Error)
+	}
+}
+
+// isPluginAllowed checks the plugin against the namespace allowlist, an empty allowlist allows every plugin.
+func isPluginAllowed(namespace *appconfig.NamespaceCfg, pluginName string) bool {
+	if len(namespace.AllowedPlugins) == 0 {
+		return true
+	}
+	for _, allowed := range namespace.AllowedPlugins {
+		if allowed == pluginName {
+			return true
+		}
+	}
+	return false
+}
+
+// isPluginDone checks whether a step already ran, when a document resumes after a reboot or restart.
+func isPluginDone(status contracts.ResultStatus) bool {
+	switch status {
+	case "", contracts.ResultStatusNotStarted, contracts.ResultStatusInProgress, contracts.ResultStatusSuccessAndReboot:
+		return false
+	}
+	return true
+}
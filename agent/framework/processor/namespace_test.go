@@ -0,0 +1,84 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var testNamespaces = []appconfig.NamespaceCfg{
+	{
+		Name:               "TeamA",
+		DocumentNames:      []string{"TeamA-*", "arn:*:document/TeamA-*"},
+		AllowedPlugins:     []string{appconfig.PluginNameAwsRunShellScript},
+		OutputS3BucketName: "team-a-output",
+	},
+	{
+		Name:          "TeamB",
+		DocumentNames: []string{"TeamB-*"},
+	},
+}
+
+func TestFindNamespace(t *testing.T) {
+	assert.Equal(t, "TeamA", findNamespace(testNamespaces, "TeamA-Deploy").Name)
+	assert.Equal(t, "TeamA", findNamespace(testNamespaces, "arn:aws:ssm:us-east-1:123456789012:document/TeamA-Deploy").Name)
+	assert.Equal(t, "TeamB", findNamespace(testNamespaces, "TeamB-Deploy").Name)
+	assert.Nil(t, findNamespace(testNamespaces, "AWS-RunShellScript"))
+	assert.Nil(t, findNamespace(nil, "TeamA-Deploy"))
+}
+
+func TestApplyNamespace(t *testing.T) {
+	docState := contracts.DocumentState{
+		IOConfig: contracts.IOConfiguration{OutputS3BucketName: "requested"},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "shell", Name: appconfig.PluginNameAwsRunShellScript},
+			{Id: "powershell", Name: appconfig.PluginNameAwsRunPowerShellScript},
+			{
+				Id:     "done",
+				Name:   appconfig.PluginNameAwsConfigurePackage,
+				Result: contracts.PluginResult{Status: contracts.ResultStatusSuccess},
+			},
+		},
+	}
+
+	applyNamespace(log.NewMockLog(), &testNamespaces[0], &docState)
+
+	assert.Equal(t, "team-a-output", docState.IOConfig.OutputS3BucketName)
+	plugins := docState.InstancePluginsInformation
+	assert.Equal(t, "team-a-output", plugins[0].Configuration.OutputS3BucketName)
+	assert.Equal(t, contracts.ResultStatus(""), plugins[0].Result.Status)
+	assert.Equal(t, contracts.ResultStatusFailed, plugins[1].Result.Status)
+	assert.Equal(t, contracts.ErrorCodePermissionDenied, plugins[1].Result.ErrorCode)
+	assert.Equal(t, "powershell", plugins[1].Result.PluginID)
+	assert.Equal(t, contracts.ResultStatusSuccess, plugins[2].Result.Status)
+}
+
+func TestApplyNamespaceAllowsEveryPlugin(t *testing.T) {
+	docState := contracts.DocumentState{
+		IOConfig: contracts.IOConfiguration{OutputS3BucketName: "requested"},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "powershell", Name: appconfig.PluginNameAwsRunPowerShellScript},
+		},
+	}
+
+	applyNamespace(log.NewMockLog(), &testNamespaces[1], &docState)
+
+	assert.Equal(t, "requested", docState.IOConfig.OutputS3BucketName)
+	assert.Equal(t, contracts.ResultStatus(""), docState.InstancePluginsInformation[0].Result.Status)
+}
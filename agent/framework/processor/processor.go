@@ -15,15 +15,20 @@
 package processor
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"path/filepath"
 
+	"github.com/aws/amazon-ssm-agent/agent/activitymetrics"
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/auditexport"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
@@ -33,8 +38,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/manager"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/rebooter"
+	"github.com/aws/amazon-ssm-agent/agent/status"
 	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/amazon-ssm-agent/agent/telemetry"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
 	"github.com/aws/amazon-ssm-agent/agent/times"
+	"github.com/aws/amazon-ssm-agent/agent/webhooks"
 )
 
 type ExecuterCreator func(ctx context.T) executer.Executer
@@ -60,6 +69,16 @@ type Processor interface {
 	//CancelAll()
 }
 
+// executionLimiter limits the number of documents running concurrently, it is shared by the processors of the agent
+type executionLimiter interface {
+	// Limited returns true if documents may have to wait for the limiter
+	Limited() bool
+	// Acquire blocks until the document may start or the cancel flag is set, and returns whether it may start
+	Acquire(cancelFlag task.CancelFlag) bool
+	// Release marks a document as finished
+	Release()
+}
+
 type EngineProcessor struct {
 	context           context.T
 	executerCreator   ExecuterCreator
@@ -69,6 +88,8 @@ type EngineProcessor struct {
 	supportedDocTypes []contracts.DocumentType
 	resChan           chan contracts.DocumentResult
 	documentMgr       docmanager.DocumentMgr
+	executionGate     executionLimiter
+	admissions        *documentAdmissions
 }
 
 //TODO worker pool should be triggered in the Start() function
@@ -86,6 +107,9 @@ func NewEngineProcessor(ctx context.T, commandWorkerLimit int, cancelWorkerLimit
 		return outofproc.NewOutOfProcExecuter(ctx)
 	}
 	documentMgr := docmanager.NewDocumentFileMgr(appconfig.DefaultDataStorePath, appconfig.DefaultDocumentRootDirName, appconfig.DefaultLocationOfState)
+	if err := throttle.Validate(ctx.AppConfig().Throttle); err != nil {
+		log.Warnf("Peak hours throttling is disabled, %v", err)
+	}
 	return &EngineProcessor{
 		context:           ctx.With("[EngineProcessor]"),
 		executerCreator:   executerCreator,
@@ -94,6 +118,8 @@ func NewEngineProcessor(ctx context.T, commandWorkerLimit int, cancelWorkerLimit
 		supportedDocTypes: supportedDocs,
 		resChan:           resChan,
 		documentMgr:       documentMgr,
+		executionGate:     throttle.SharedGate(ctx.AppConfig().Throttle),
+		admissions:        newDocumentAdmissions(),
 	}
 }
 
@@ -156,16 +182,120 @@ func (p *EngineProcessor) submit(docState *contracts.DocumentState) error {
 	} else {
 		jobID = docState.DocumentInformation.MessageID
 	}
-	return p.sendCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
-		processCommand(
-			p.context,
-			p.executerCreator,
-			cancelFlag,
-			p.resChan,
-			docState,
-			p.documentMgr)
+	if !p.needsAdmission(docState) {
+		return p.sendCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
+			p.runDocument(docState, cancelFlag)
+		})
+	}
+	if p.sendCommandPool.HasJob(jobID) {
+		return fmt.Errorf("Job with id %v already exists", jobID)
+	}
+	cancelFlag, err := p.admissions.add(jobID)
+	if err != nil {
+		return err
+	}
+	go p.admit(jobID, docState, cancelFlag)
+	return nil
+}
+
+// needsAdmission tells if a document may have to wait before it starts, such a document waits before it takes a
+// slot of the send command pool
+func (p *EngineProcessor) needsAdmission(docState *contracts.DocumentState) bool {
+	return p.admissions != nil &&
+		(docState.ConcurrencyGroup != "" || needsReadiness(p.context.AppConfig().Readiness, docState) || p.gated())
+}
+
+// gated tells if the documents wait for the peak hours execution limit
+func (p *EngineProcessor) gated() bool {
+	return p.executionGate != nil && p.executionGate.Limited()
+}
+
+// admit waits until a document may start and hands it to the send command pool. A document canceled while it waits
+// is reported as canceled, a document shut down while it waits stays pending to be resumed.
+func (p *EngineProcessor) admit(jobID string, docState *contracts.DocumentState, cancelFlag *task.ChanneledCancelFlag) {
+	log := p.context.Log()
+	defer p.admissions.admitted()
+	// releases are run in reverse order once the document ends or gives up
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+		p.admissions.remove(jobID, cancelFlag)
+	}
+	if group := docState.ConcurrencyGroup; group != "" {
+		// wait for the documents of the same concurrency group queued before this one
+		documentID := docState.DocumentInformation.DocumentID
+		if !concurrencyGroups.Wait(group, documentID, cancelFlag, func(position int) {
+			log.Infof("Document %v is waiting in concurrency group %v at queue position %v", jobID, group, position)
+			p.resChan <- queuedResult(docState, position)
+		}) {
+			release()
+			p.endWaitingDocument(jobID, docState, cancelFlag, "concurrency group "+group)
+			return
+		}
+		releases = append(releases, func() { concurrencyGroups.Leave(group, documentID) })
+	}
+	// the host must be ready before the document runs, a deferred document doesn't take a slot of the pool
+	if !awaitReadiness(log, p.context.AppConfig().Readiness, docState, cancelFlag) {
+		release()
+		p.endWaitingDocument(jobID, docState, cancelFlag, "the host to be ready")
+		return
+	}
+	if p.gated() {
+		// wait for a slot while the peak hours execution limit is reached
+		if !p.executionGate.Acquire(cancelFlag) {
+			release()
+			p.endWaitingDocument(jobID, docState, cancelFlag, "the peak hours execution limit")
+			return
+		}
+		releases = append(releases, p.executionGate.Release)
+	}
+	err := p.sendCommandPool.Submit(log, jobID, func(jobFlag task.CancelFlag) {
+		defer release()
+		go forwardCancel(jobFlag, cancelFlag)
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			p.endWaitingDocument(jobID, docState, cancelFlag, "a slot of the worker pool")
+			return
+		}
+		p.runDocument(docState, cancelFlag)
 	})
+	if err != nil {
+		log.Warnf("Document %v was not started, %v", jobID, err)
+		release()
+	}
+}
 
+// endWaitingDocument ends a document canceled or shut down before it started. A canceled document is reported as
+// canceled and its state is removed, a shut down document stays pending to be resumed.
+func (p *EngineProcessor) endWaitingDocument(jobID string, docState *contracts.DocumentState, cancelFlag task.CancelFlag, waitingFor string) {
+	log := p.context.Log()
+	if !cancelFlag.Canceled() {
+		log.Infof("Document %v was shut down while waiting for %v", jobID, waitingFor)
+		return
+	}
+	log.Infof("Document %v was canceled while waiting for %v", jobID, waitingFor)
+	p.resChan <- canceledResult(docState)
+	p.documentMgr.MoveDocumentState(log,
+		docState.DocumentInformation.DocumentID,
+		docState.DocumentInformation.InstanceID,
+		appconfig.DefaultLocationOfPending,
+		appconfig.DefaultLocationOfCurrent)
+	p.documentMgr.RemoveDocumentState(log,
+		docState.DocumentInformation.DocumentID,
+		docState.DocumentInformation.InstanceID,
+		appconfig.DefaultLocationOfCurrent)
+}
+
+// runDocument runs a document in a slot of the send command pool
+func (p *EngineProcessor) runDocument(docState *contracts.DocumentState, cancelFlag task.CancelFlag) {
+	processCommand(
+		p.context,
+		p.executerCreator,
+		cancelFlag,
+		p.resChan,
+		docState,
+		p.documentMgr)
 }
 
 func (p *EngineProcessor) Cancel(docState contracts.DocumentState) {
@@ -180,7 +310,7 @@ func (p *EngineProcessor) Cancel(docState contracts.DocumentState) {
 	//queue up the pending document
 	p.documentMgr.PersistDocumentState(log, docState.DocumentInformation.DocumentID, docState.DocumentInformation.InstanceID, appconfig.DefaultLocationOfPending, docState)
 	err := p.cancelCommandPool.Submit(log, jobID, func(cancelFlag task.CancelFlag) {
-		processCancelCommand(p.context, p.sendCommandPool, &docState, p.documentMgr)
+		processCancelCommand(p.context, p.sendCommandPool, p.admissions, &docState, p.documentMgr)
 	})
 	if err != nil {
 		log.Error("CancelCommand failed", err)
@@ -207,6 +337,14 @@ func (p *EngineProcessor) Stop(stopType contracts.StopType) {
 		p.sendCommandPool.ShutdownAndWait(waitTimeout)
 	}()
 
+	// shutdown the documents waiting to start in a separate go routine, the documents being handed to the send
+	// command pool give up once it is shut down
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.admissions.shutdown()
+	}()
+
 	// shutdown the cancel command pool in a separate go routine
 	wg.Add(1)
 	go func() {
@@ -324,8 +462,32 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 	documentID := docState.DocumentInformation.DocumentID
 	instanceID := docState.DocumentInformation.InstanceID
 	messageID := docState.DocumentInformation.MessageID
+	if namespace := findNamespace(context.AppConfig().Namespaces, docState.DocumentInformation.DocumentName); namespace != nil {
+		applyNamespace(log, namespace, docState)
+		if namespace.MaxExecutionSeconds > 0 {
+			limit := time.Duration(namespace.MaxExecutionSeconds) * time.Second
+			timer := time.AfterFunc(limit, func() {
+				log.Infof("document %v exceeded the %v limit of namespace %v, canceling...", messageID, limit, namespace.Name)
+				cancelFlag.Set(task.Canceled)
+			})
+			defer timer.Stop()
+		}
+	}
 	e := executerCreator(context)
 	docStore := executer.NewDocumentFileStore(context, instanceID, documentID, appconfig.DefaultLocationOfCurrent, docState, docMgr)
+	status.ExecutionStarted(documentID, messageID, docState.DocumentInformation.DocumentName, string(docState.DocumentType))
+	defer status.ExecutionEnded(documentID)
+	if docState.DocumentType == contracts.StartSession {
+		activitymetrics.Add(activitymetrics.SessionsActive, 1)
+		defer activitymetrics.Add(activitymetrics.SessionsActive, -1)
+	} else {
+		activitymetrics.Add(activitymetrics.CommandsStarted, 1)
+	}
+	documentSpan := telemetry.StartSpan("document", nil, map[string]string{
+		"ssm.document.name":    docState.DocumentInformation.DocumentName,
+		"ssm.document.version": docState.DocumentInformation.DocumentVersion,
+		"ssm.message.id":       messageID,
+	})
 	statusChan := e.Run(
 		cancelFlag,
 		&docStore,
@@ -337,14 +499,21 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 			log.Infof("sending document: %v complete response", documentID)
 		} else {
 			log.Infof("sending reply for plugin update: %v", res.LastPlugin)
-
+			status.ExecutionProgressed(documentID, res.LastPlugin)
+			recordPluginTelemetry(documentSpan, res.PluginResults[res.LastPlugin])
 		}
 		handleCloudwatchPlugin(context, res.PluginResults, documentID)
+		callWebhooks(docState, res)
+		exportAuditEvent(docState, res)
 		//hand off the message to Service
 		resChan <- res
 		final = &res
 	}
 	//TODO add shutdown as API call, move cancelFlag out of task pool; cancelFlag to contracts, nobody else above runplugins needs to create cancelFlag.
+	recordDocumentTelemetry(documentSpan, docState.DocumentInformation.DocumentName, final)
+	publishDocumentEvent(docState, final)
+	recordActivityMetrics(docState, final)
+	notifyResult(log, docState, final)
 	// Shutdown/reboot detection
 	if final == nil || final.LastPlugin != "" {
 		log.Infof("document %v still in progress, shutting down...", messageID)
@@ -365,8 +534,190 @@ func processCommand(context context.T, executerCreator ExecuterCreator, cancelFl
 
 }
 
+// recordPluginTelemetry records the run of a plugin reported by the executer as a span of the document
+func recordPluginTelemetry(documentSpan *telemetry.Span, result *contracts.PluginResult) {
+	if result == nil || !telemetry.Enabled() {
+		return
+	}
+	var err error
+	if result.Error != "" {
+		err = errors.New(result.Error)
+	}
+	attributes := map[string]string{
+		"ssm.plugin.name": result.PluginName,
+		"ssm.status":      string(result.Status),
+	}
+	telemetry.AddCount("ssm.plugin.runs", 1, attributes)
+	attributes["ssm.plugin.id"] = result.PluginID
+	telemetry.RecordSpan("plugin "+result.PluginName, documentSpan, result.StartDateTime, result.EndDateTime, attributes, err)
+}
+
+// recordDocumentTelemetry ends the span of a document, a document interrupted by a shutdown has no final result
+func recordDocumentTelemetry(documentSpan *telemetry.Span, documentName string, final *contracts.DocumentResult) {
+	status := "Interrupted"
+	if final != nil && final.LastPlugin == "" {
+		status = string(final.Status)
+	}
+	documentSpan.SetAttribute("ssm.status", status)
+	var err error
+	if status == string(contracts.ResultStatusFailed) || status == string(contracts.ResultStatusTimedOut) {
+		err = fmt.Errorf("document %v", strings.ToLower(status))
+	}
+	documentSpan.End(err)
+	telemetry.AddCount("ssm.documents", 1, map[string]string{"ssm.document.name": documentName, "ssm.status": status})
+}
+
+// publishDocumentEvent publishes the failure or the reboot request of a finished document, sessions excepted
+func publishDocumentEvent(docState *contracts.DocumentState, final *contracts.DocumentResult) {
+	if final == nil || final.LastPlugin != "" || docState.DocumentType == contracts.StartSession {
+		return
+	}
+	var detailType string
+	switch final.Status {
+	case contracts.ResultStatusFailed, contracts.ResultStatusTimedOut:
+		detailType = agentevents.CommandFailed
+	case contracts.ResultStatusSuccessAndReboot:
+		detailType = agentevents.RebootPending
+	default:
+		return
+	}
+	detail := map[string]string{
+		"documentType":    string(docState.DocumentType),
+		"documentName":    docState.DocumentInformation.DocumentName,
+		"documentVersion": docState.DocumentInformation.DocumentVersion,
+		"commandId":       docState.DocumentInformation.CommandID,
+		"associationId":   docState.DocumentInformation.AssociationID,
+		"status":          string(final.Status),
+	}
+	if detailType == agentevents.CommandFailed {
+		if err := firstStepError(docState, final); err != "" {
+			detail[agentevents.ErrorDetail] = err
+		}
+	}
+	agentevents.Publish(detailType, detail)
+}
+
+// recordActivityMetrics counts the success or the failure of a finished document, sessions excepted
+func recordActivityMetrics(docState *contracts.DocumentState, final *contracts.DocumentResult) {
+	if final == nil || final.LastPlugin != "" || docState.DocumentType == contracts.StartSession {
+		return
+	}
+	switch final.Status {
+	case contracts.ResultStatusSuccess, contracts.ResultStatusSuccessAndReboot:
+		activitymetrics.Add(activitymetrics.CommandsSucceeded, 1)
+	case contracts.ResultStatusFailed, contracts.ResultStatusTimedOut:
+		activitymetrics.Add(activitymetrics.CommandsFailed, 1)
+	}
+}
+
+// firstStepError returns the error of the first failed step of a document, truncated like the result summaries
+func firstStepError(docState *contracts.DocumentState, final *contracts.DocumentResult) string {
+	for _, pluginState := range docState.InstancePluginsInformation {
+		result, ok := final.PluginResults[pluginState.Id]
+		if !ok || result == nil || result.Error == "" {
+			continue
+		}
+		if len(result.Error) > maxSummaryErrorLength {
+			return fmt.Sprintf("%v...", result.Error[:maxSummaryErrorLength])
+		}
+		return result.Error
+	}
+	return ""
+}
+
+// callWebhooks calls back the webhooks with a completed step or the completed document
+func callWebhooks(docState *contracts.DocumentState, res contracts.DocumentResult) {
+	if !webhooks.Enabled() {
+		return
+	}
+	if event, ok := webhookEvent(docState, res); ok {
+		webhooks.Notify(event)
+	}
+}
+
+// webhookEvent returns the webhook event of a result of the executer, sessions excepted.
+// Steps and documents that requested a reboot complete when they resume.
+func webhookEvent(docState *contracts.DocumentState, res contracts.DocumentResult) (event webhooks.Event, ok bool) {
+	if docState.DocumentType == contracts.StartSession {
+		return event, false
+	}
+	event = webhooks.Event{
+		DocumentName:    docState.DocumentInformation.DocumentName,
+		DocumentVersion: docState.DocumentInformation.DocumentVersion,
+		CommandID:       docState.DocumentInformation.CommandID,
+		AssociationID:   docState.DocumentInformation.AssociationID,
+	}
+	if res.LastPlugin == "" {
+		if res.Status == contracts.ResultStatusSuccessAndReboot {
+			return event, false
+		}
+		event.Event = appconfig.WebhookEventDocumentCompleted
+		event.Status = string(res.Status)
+	} else {
+		result := res.PluginResults[res.LastPlugin]
+		if result == nil || result.Status.IsReboot() {
+			return event, false
+		}
+		event.Event = appconfig.WebhookEventStepCompleted
+		event.StepName = result.PluginID
+		event.Action = result.PluginName
+		event.Status = string(result.Status)
+		event.Code = result.Code
+	}
+	return event, true
+}
+
+// exportAuditEvent exports the audit event of a completed step, a completed command or an ended session.
+// Steps and documents that requested a reboot complete when they resume.
+func exportAuditEvent(docState *contracts.DocumentState, res contracts.DocumentResult) {
+	if !auditexport.Enabled() {
+		return
+	}
+	if event, ok := auditEvent(docState, res); ok {
+		auditexport.Record(event)
+	}
+}
+
+// auditEvent returns the audit event of a result of the executer, the steps of the sessions are not audited
+func auditEvent(docState *contracts.DocumentState, res contracts.DocumentResult) (event auditexport.Event, ok bool) {
+	event = auditexport.Event{
+		DocumentName:    docState.DocumentInformation.DocumentName,
+		DocumentVersion: docState.DocumentInformation.DocumentVersion,
+	}
+	if docState.DocumentType == contracts.StartSession {
+		if res.LastPlugin != "" {
+			return event, false
+		}
+		event.Name = auditexport.SessionEnded
+		event.SessionID = docState.DocumentInformation.DocumentID
+		event.ClientID = docState.DocumentInformation.ClientId
+		event.Status = string(res.Status)
+		return event, true
+	}
+	event.CommandID = docState.DocumentInformation.CommandID
+	event.AssociationID = docState.DocumentInformation.AssociationID
+	if res.LastPlugin == "" {
+		if res.Status == contracts.ResultStatusSuccessAndReboot {
+			return event, false
+		}
+		event.Name = auditexport.CommandCompleted
+		event.Status = string(res.Status)
+	} else {
+		result := res.PluginResults[res.LastPlugin]
+		if result == nil || result.Status.IsReboot() {
+			return event, false
+		}
+		event.Name = auditexport.StepCompleted
+		event.StepName = result.PluginID
+		event.Action = result.PluginName
+		event.Status = string(result.Status)
+		event.ExitCode = result.Code
+	}
+	return event, true
+}
+
 //TODO CancelCommand is currently treated as a special type of Command by the Processor, but in general Cancel operation should be seen as a probe to existing commands
-func processCancelCommand(context context.T, sendCommandPool task.Pool, docState *contracts.DocumentState, docMgr docmanager.DocumentMgr) {
+func processCancelCommand(context context.T, sendCommandPool task.Pool, admissions *documentAdmissions, docState *contracts.DocumentState, docMgr docmanager.DocumentMgr) {
 
 	log := context.Log()
 	//persist the final status of cancel-message in current folder
@@ -376,7 +727,9 @@ func processCancelCommand(context context.T, sendCommandPool task.Pool, docState
 		appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
 	log.Debugf("Canceling job with id %v...", docState.CancelInformation.CancelMessageID)
 
-	if found := sendCommandPool.Cancel(docState.CancelInformation.CancelMessageID); !found {
+	// a document waiting to start or started after waiting is canceled through its admission
+	cancelMessageID := docState.CancelInformation.CancelMessageID
+	if found := admissions.cancel(cancelMessageID) || sendCommandPool.Cancel(cancelMessageID); !found {
 		log.Debugf("Job with id %v not found (possibly completed)", docState.CancelInformation.CancelMessageID)
 		docState.CancelInformation.DebugInfo = fmt.Sprintf("Command %v couldn't be cancelled", docState.CancelInformation.CancelCommandID)
 		docState.DocumentInformation.DocumentStatus = contracts.ResultStatusFailed
@@ -402,7 +755,7 @@ func handleCloudwatchPlugin(context context.T, pluginResults map[string]*contrac
 	instanceID, _ := platform.InstanceID()
 	//TODO once association service switches to use RC and CW goes away, remove this block
 	for ID, pluginRes := range pluginResults {
-		if pluginRes.PluginName == appconfig.PluginNameCloudWatch {
+		if pluginRes.PluginName == appconfig.PluginNameCloudWatch || pluginRes.PluginName == appconfig.PluginNameAwsLogTailer {
 			log.Infof("Found %v to invoke lrpm invoker", pluginRes.PluginName)
 			orchestrationRootDir := filepath.Join(
 				appconfig.DefaultDataStorePath,
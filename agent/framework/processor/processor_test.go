@@ -184,7 +184,7 @@ func TestProcessCancelCommand_Success(t *testing.T) {
 	docMock := new(DocumentMgrMock)
 	docMock.On("MoveDocumentState", mock.Anything, "", "", appconfig.DefaultLocationOfPending, appconfig.DefaultLocationOfCurrent)
 	docMock.On("RemoveDocumentState", mock.Anything, "", "", appconfig.DefaultLocationOfCurrent, mock.Anything)
-	processCancelCommand(ctx, sendCommandPoolMock, &docState, docMock)
+	processCancelCommand(ctx, sendCommandPoolMock, newDocumentAdmissions(), &docState, docMock)
 	sendCommandPoolMock.AssertExpectations(t)
 	docMock.AssertExpectations(t)
 	assert.Equal(t, docState.DocumentInformation.DocumentStatus, contracts.ResultStatusSuccess)
@@ -0,0 +1,89 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/readiness"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+var (
+	evaluateReadiness = readiness.Evaluate
+	// readinessRetryInterval is the delay before a deferred document evaluates the gates again
+	readinessRetryInterval = 30 * time.Second
+	// readinessPollInterval is how often a deferred document checks whether it was canceled
+	readinessPollInterval = time.Second
+)
+
+// awaitReadiness evaluates the readiness gates before the document runs its first step. With the Defer policy
+// the gates are evaluated again until they pass or the defer timeout expires, the document is failed with the
+// gate reason if the host is still not ready. It returns false if the document was canceled while deferred.
+func awaitReadiness(log log.T, cfg appconfig.ReadinessCfg, docState *contracts.DocumentState, cancelFlag task.CancelFlag) bool {
+	if !needsReadiness(cfg, docState) {
+		return true
+	}
+	deadline := time.Now().Add(time.Duration(cfg.DeferTimeoutSeconds) * time.Second)
+	gateErr := evaluateReadiness(log, cfg)
+	for gateErr != nil && cfg.OnFailure == appconfig.ReadinessOnFailureDefer && time.Now().Before(deadline) {
+		log.Infof("Deferring document %v, %v", docState.DocumentInformation.DocumentID, gateErr)
+		for retry := time.Now().Add(readinessRetryInterval); time.Now().Before(retry); time.Sleep(readinessPollInterval) {
+			if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+				return false
+			}
+		}
+		gateErr = evaluateReadiness(log, cfg)
+	}
+	if gateErr != nil {
+		failNotReady(log, gateErr, docState)
+	}
+	return true
+}
+
+// needsReadiness tells if the readiness gates are evaluated before the document runs, sessions and documents that
+// already ran a step are not gated
+func needsReadiness(cfg appconfig.ReadinessCfg, docState *contracts.DocumentState) bool {
+	return readiness.Enabled(cfg) && docState.DocumentType != contracts.StartSession && !isDocumentStarted(docState)
+}
+
+// isDocumentStarted returns true if a step already ran, such as a document resumed after a reboot
+func isDocumentStarted(docState *contracts.DocumentState) bool {
+	for _, pluginState := range docState.InstancePluginsInformation {
+		if pluginState.Result.Status != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// failNotReady fails every step of the document with the gate reason, so the executer skips them and reports it
+func failNotReady(log log.T, gateErr *readiness.GateError, docState *contracts.DocumentState) {
+	log.Errorf("Failing document %v, %v", docState.DocumentInformation.DocumentID, gateErr)
+	now := time.Now()
+	for i := range docState.InstancePluginsInformation {
+		pluginState := &docState.InstancePluginsInformation[i]
+		pluginState.Result.PluginID = pluginState.Id
+		pluginState.Result.PluginName = pluginState.Name
+		pluginState.Result.Status = contracts.ResultStatusFailed
+		pluginState.Result.Code = 1
+		pluginState.Result.Error = gateErr.Error()
+		pluginState.Result.ErrorCode = contracts.ErrorCodeNotReady
+		pluginState.Result.StartDateTime = now
+		pluginState.Result.EndDateTime = now
+	}
+}
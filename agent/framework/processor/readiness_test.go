@@ -0,0 +1,125 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/readiness"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubReadiness returns the gate errors in order, nil once they are all returned
+func stubReadiness(gateErrs ...*readiness.GateError) (evaluations *int, restore func()) {
+	saved, savedRetry, savedPoll := evaluateReadiness, readinessRetryInterval, readinessPollInterval
+	readinessRetryInterval, readinessPollInterval = time.Millisecond, time.Millisecond
+	evaluations = new(int)
+	evaluateReadiness = func(log log.T, cfg appconfig.ReadinessCfg) *readiness.GateError {
+		*evaluations++
+		if *evaluations <= len(gateErrs) {
+			return gateErrs[*evaluations-1]
+		}
+		return nil
+	}
+	return evaluations, func() {
+		evaluateReadiness, readinessRetryInterval, readinessPollInterval = saved, savedRetry, savedPoll
+	}
+}
+
+func readinessTestDocument() *contracts.DocumentState {
+	return &contracts.DocumentState{
+		DocumentType: contracts.SendCommand,
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "step1", Name: appconfig.PluginNameAwsRunShellScript},
+			{Id: "step2", Name: appconfig.PluginNameAwsRunShellScript},
+		},
+	}
+}
+
+var diskGateErr = &readiness.GateError{Gate: readiness.GateDiskSpace, Reason: "512 MB free on /var/lib/amazon/ssm, 1024 MB required"}
+
+func TestAwaitReadinessDefersUntilReady(t *testing.T) {
+	evaluations, restore := stubReadiness(diskGateErr, diskGateErr)
+	defer restore()
+	cfg := appconfig.ReadinessCfg{MinFreeDiskMB: 1024, OnFailure: appconfig.ReadinessOnFailureDefer, DeferTimeoutSeconds: 60}
+	docState := readinessTestDocument()
+
+	assert.True(t, awaitReadiness(log.NewMockLog(), cfg, docState, task.NewChanneledCancelFlag()))
+
+	assert.Equal(t, 3, *evaluations)
+	assert.Equal(t, contracts.ResultStatus(""), docState.InstancePluginsInformation[0].Result.Status)
+}
+
+func TestAwaitReadinessFailsDocument(t *testing.T) {
+	evaluations, restore := stubReadiness(diskGateErr)
+	defer restore()
+	cfg := appconfig.ReadinessCfg{MinFreeDiskMB: 1024, OnFailure: appconfig.ReadinessOnFailureFail, DeferTimeoutSeconds: 60}
+	docState := readinessTestDocument()
+
+	assert.True(t, awaitReadiness(log.NewMockLog(), cfg, docState, task.NewChanneledCancelFlag()))
+
+	assert.Equal(t, 1, *evaluations)
+	for _, pluginState := range docState.InstancePluginsInformation {
+		assert.Equal(t, contracts.ResultStatusFailed, pluginState.Result.Status)
+		assert.Equal(t, contracts.ErrorCodeNotReady, pluginState.Result.ErrorCode)
+		assert.Equal(t, pluginState.Id, pluginState.Result.PluginID)
+		assert.Contains(t, pluginState.Result.Error, "gate DiskSpace")
+	}
+}
+
+func TestAwaitReadinessFailsDocumentAfterDeferTimeout(t *testing.T) {
+	_, restore := stubReadiness(diskGateErr, diskGateErr, diskGateErr)
+	defer restore()
+	cfg := appconfig.ReadinessCfg{MinFreeDiskMB: 1024, OnFailure: appconfig.ReadinessOnFailureDefer}
+	docState := readinessTestDocument()
+
+	assert.True(t, awaitReadiness(log.NewMockLog(), cfg, docState, task.NewChanneledCancelFlag()))
+
+	assert.Equal(t, contracts.ResultStatusFailed, docState.InstancePluginsInformation[0].Result.Status)
+}
+
+func TestAwaitReadinessCanceledWhileDeferred(t *testing.T) {
+	_, restore := stubReadiness(diskGateErr, diskGateErr)
+	defer restore()
+	cfg := appconfig.ReadinessCfg{MinFreeDiskMB: 1024, OnFailure: appconfig.ReadinessOnFailureDefer, DeferTimeoutSeconds: 60}
+	cancelFlag := task.NewChanneledCancelFlag()
+	cancelFlag.Set(task.Canceled)
+
+	assert.False(t, awaitReadiness(log.NewMockLog(), cfg, readinessTestDocument(), cancelFlag))
+}
+
+func TestAwaitReadinessSkipped(t *testing.T) {
+	evaluations, restore := stubReadiness(diskGateErr)
+	defer restore()
+	cfg := appconfig.ReadinessCfg{MinFreeDiskMB: 1024, OnFailure: appconfig.ReadinessOnFailureFail}
+
+	// no gate configured
+	assert.True(t, awaitReadiness(log.NewMockLog(), appconfig.ReadinessCfg{}, readinessTestDocument(), task.NewChanneledCancelFlag()))
+	// sessions are not gated
+	session := readinessTestDocument()
+	session.DocumentType = contracts.StartSession
+	assert.True(t, awaitReadiness(log.NewMockLog(), cfg, session, task.NewChanneledCancelFlag()))
+	// a document resumed after a reboot already passed the gates
+	resumed := readinessTestDocument()
+	resumed.InstancePluginsInformation[0].Result.Status = contracts.ResultStatusSuccess
+	assert.True(t, awaitReadiness(log.NewMockLog(), cfg, resumed, task.NewChanneledCancelFlag()))
+
+	assert.Equal(t, 0, *evaluations)
+	assert.Equal(t, contracts.ResultStatus(""), resumed.InstancePluginsInformation[1].Result.Status)
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// maxSummaryErrorLength truncates the errors of the steps, the summary stays well below the 256KB message limit
+const maxSummaryErrorLength = 1024
+
+// resultSummary is the final result of a document published to its result notification
+type resultSummary struct {
+	InstanceID      string              `json:"instanceId"`
+	DocumentType    string              `json:"documentType"`
+	DocumentName    string              `json:"documentName"`
+	DocumentVersion string              `json:"documentVersion,omitempty"`
+	CommandID       string              `json:"commandId,omitempty"`
+	AssociationID   string              `json:"associationId,omitempty"`
+	Status          string              `json:"status"`
+	Steps           []resultSummaryStep `json:"steps"`
+}
+
+// resultSummaryStep is the result of a step of the document, its output is left in the configured output locations
+type resultSummaryStep struct {
+	Name          string    `json:"name"`
+	Action        string    `json:"action"`
+	Status        string    `json:"status"`
+	Code          int       `json:"code"`
+	StartDateTime time.Time `json:"startDateTime"`
+	EndDateTime   time.Time `json:"endDateTime"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// publishResultSummary sends the summary to the topic or the queue of the notification, it is replaced by the tests
+var publishResultSummary = func(notification contracts.ResultNotification, message string, groupID string) error {
+	appConfig, _ := appconfig.Config(false)
+	if notification.SnsTopicArn != "" {
+		// the topic may be in another region than the instance
+		client := sns.New(notificationSession(appConfig, strings.Split(notification.SnsTopicArn, ":")[3]))
+		_, err := client.Publish(&sns.PublishInput{TopicArn: aws.String(notification.SnsTopicArn), Message: aws.String(message)})
+		return err
+	}
+	client := sqs.New(notificationSession(appConfig, queueRegion(notification.SqsQueueUrl)))
+	input := &sqs.SendMessageInput{QueueUrl: aws.String(notification.SqsQueueUrl), MessageBody: aws.String(message)}
+	if strings.HasSuffix(notification.SqsQueueUrl, ".fifo") {
+		digest := sha256.Sum256([]byte(message))
+		input.MessageGroupId = aws.String(groupID)
+		input.MessageDeduplicationId = aws.String(hex.EncodeToString(digest[:]))
+	}
+	_, err := client.SendMessage(input)
+	return err
+}
+
+func notificationSession(appConfig appconfig.SsmagentConfig, region string) *session.Session {
+	config := sdkutil.AwsConfig()
+	if region != "" {
+		config.Region = aws.String(region)
+	}
+	sess := session.New(config)
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
+	return sess
+}
+
+// queueRegion returns the region of a queue url such as https://sqs.us-east-1.amazonaws.com/123456789012/queue
+// or the legacy https://us-east-1.queue.amazonaws.com/123456789012/queue, empty when the url has no region
+func queueRegion(queueURL string) string {
+	host := strings.SplitN(strings.TrimPrefix(queueURL, "https://"), "/", 2)[0]
+	labels := strings.Split(host, ".")
+	if len(labels) > 2 && labels[0] == "sqs" {
+		return labels[1]
+	}
+	if len(labels) > 2 && labels[1] == "queue" {
+		return labels[0]
+	}
+	return ""
+}
+
+// notifyResult publishes the summary of a document that completed to the result notification of the document,
+// a document interrupted by a shutdown or waiting for a reboot publishes its result when it completes after it.
+// A failure to publish is logged and does not change the result of the document.
+func notifyResult(log log.T, docState *contracts.DocumentState, final *contracts.DocumentResult) {
+	notification := docState.ResultNotification
+	if notification.IsEmpty() || final == nil || final.LastPlugin != "" || final.Status == contracts.ResultStatusSuccessAndReboot {
+		return
+	}
+	summary := newResultSummary(docState, final)
+	message, err := json.Marshal(summary)
+	if err != nil {
+		log.Warnf("failed to serialize the result summary of document %v: %v", docState.DocumentInformation.DocumentID, err)
+		return
+	}
+	target := notification.SnsTopicArn
+	if target == "" {
+		target = notification.SqsQueueUrl
+	}
+	if err = publishResultSummary(notification, string(message), summary.InstanceID); err != nil {
+		log.Warnf("failed to publish the result of document %v to %v: %v", docState.DocumentInformation.DocumentID, target, err)
+		return
+	}
+	log.Infof("published the result of document %v to %v", docState.DocumentInformation.DocumentID, target)
+}
+
+// newResultSummary summarizes the result of every step of the document in the order of the steps
+func newResultSummary(docState *contracts.DocumentState, final *contracts.DocumentResult) resultSummary {
+	info := docState.DocumentInformation
+	summary := resultSummary{
+		InstanceID:      info.InstanceID,
+		DocumentType:    string(docState.DocumentType),
+		DocumentName:    info.DocumentName,
+		DocumentVersion: info.DocumentVersion,
+		CommandID:       info.CommandID,
+		AssociationID:   info.AssociationID,
+		Status:          string(final.Status),
+		Steps:           []resultSummaryStep{},
+	}
+	for _, pluginState := range docState.InstancePluginsInformation {
+		step := resultSummaryStep{Name: pluginState.Id, Action: pluginState.Name}
+		result, ok := final.PluginResults[pluginState.Id]
+		if !ok || result == nil {
+			result = &pluginState.Result
+		}
+		step.Status = string(result.Status)
+		step.Code = result.Code
+		step.StartDateTime = result.StartDateTime
+		step.EndDateTime = result.EndDateTime
+		step.Error = result.Error
+		if len(step.Error) > maxSummaryErrorLength {
+			step.Error = fmt.Sprintf("%v...", step.Error[:maxSummaryErrorLength])
+		}
+		summary.Steps = append(summary.Steps, step)
+	}
+	return summary
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/auditexport"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubPublishResultSummary records the messages published to the result notification
+func stubPublishResultSummary(err error) (messages *[]string, restore func()) {
+	saved := publishResultSummary
+	messages = &[]string{}
+	publishResultSummary = func(notification contracts.ResultNotification, message string, groupID string) error {
+		*messages = append(*messages, message)
+		return err
+	}
+	return messages, func() { publishResultSummary = saved }
+}
+
+func resultNotificationDocState() *contracts.DocumentState {
+	return &contracts.DocumentState{
+		DocumentType: contracts.SendCommand,
+		DocumentInformation: contracts.DocumentInfo{
+			DocumentID:   "documentID",
+			CommandID:    "commandID",
+			InstanceID:   "i-1234567890abcdef0",
+			DocumentName: "AWS-RunShellScript",
+		},
+		InstancePluginsInformation: []contracts.PluginState{
+			{Id: "step1", Name: "aws:runShellScript"},
+			{Id: "step2", Name: "aws:runShellScript"},
+		},
+		ResultNotification: contracts.ResultNotification{SnsTopicArn: "arn:aws:sns:us-east-1:123456789012:results"},
+	}
+}
+
+func TestNotifyResult(t *testing.T) {
+	messages, restore := stubPublishResultSummary(nil)
+	defer restore()
+	docState := resultNotificationDocState()
+	final := &contracts.DocumentResult{
+		Status: contracts.ResultStatusFailed,
+		PluginResults: map[string]*contracts.PluginResult{
+			"step1": {Status: contracts.ResultStatusSuccess},
+			"step2": {Status: contracts.ResultStatusFailed, Code: 1, Error: strings.Repeat("e", 2*maxSummaryErrorLength)},
+		},
+	}
+
+	notifyResult(log.NewMockLog(), docState, final)
+
+	assert.Len(t, *messages, 1)
+	var summary resultSummary
+	assert.NoError(t, json.Unmarshal([]byte((*messages)[0]), &summary))
+	assert.Equal(t, "commandID", summary.CommandID)
+	assert.Equal(t, "i-1234567890abcdef0", summary.InstanceID)
+	assert.Equal(t, string(contracts.ResultStatusFailed), summary.Status)
+	assert.Len(t, summary.Steps, 2)
+	assert.Equal(t, "step1", summary.Steps[0].Name)
+	assert.Equal(t, string(contracts.ResultStatusSuccess), summary.Steps[0].Status)
+	assert.Equal(t, 1, summary.Steps[1].Code)
+	assert.Len(t, summary.Steps[1].Error, maxSummaryErrorLength+3)
+}
+
+func TestNotifyResult_Skipped(t *testing.T) {
+	messages, restore := stubPublishResultSummary(nil)
+	defer restore()
+	docState := resultNotificationDocState()
+
+	notifyResult(log.NewMockLog(), docState, nil)
+	notifyResult(log.NewMockLog(), docState, &contracts.DocumentResult{Status: contracts.ResultStatusSuccess, LastPlugin: "step1"})
+	notifyResult(log.NewMockLog(), docState, &contracts.DocumentResult{Status: contracts.ResultStatusSuccessAndReboot})
+	docState.ResultNotification = contracts.ResultNotification{}
+	notifyResult(log.NewMockLog(), docState, &contracts.DocumentResult{Status: contracts.ResultStatusSuccess})
+
+	assert.Empty(t, *messages)
+}
+
+func TestNotifyResult_PublishFailure(t *testing.T) {
+	messages, restore := stubPublishResultSummary(fmt.Errorf("AuthorizationError"))
+	defer restore()
+
+	notifyResult(log.NewMockLog(), resultNotificationDocState(), &contracts.DocumentResult{Status: contracts.ResultStatusSuccess})
+
+	assert.Len(t, *messages, 1)
+}
+
+func TestQueueRegion(t *testing.T) {
+	assert.Equal(t, "eu-west-1", queueRegion("https://sqs.eu-west-1.amazonaws.com/123456789012/results"))
+	assert.Equal(t, "us-east-1", queueRegion("https://us-east-1.queue.amazonaws.com/123456789012/results"))
+	assert.Equal(t, "", queueRegion("https://queue.amazonaws.com/123456789012/results"))
+}
+
+func TestWebhookEvent(t *testing.T) {
+	docState := resultNotificationDocState()
+	step := contracts.DocumentResult{
+		LastPlugin: "step2",
+		Status:     contracts.ResultStatusInProgress,
+		PluginResults: map[string]*contracts.PluginResult{
+			"step2": {PluginID: "step2", PluginName: "aws:runShellScript", Status: contracts.ResultStatusFailed, Code: 1},
+		},
+	}
+
+	event, ok := webhookEvent(docState, step)
+	assert.True(t, ok)
+	assert.Equal(t, appconfig.WebhookEventStepCompleted, event.Event)
+	assert.Equal(t, "AWS-RunShellScript", event.DocumentName)
+	assert.Equal(t, "commandID", event.CommandID)
+	assert.Equal(t, "step2", event.StepName)
+	assert.Equal(t, "aws:runShellScript", event.Action)
+	assert.Equal(t, string(contracts.ResultStatusFailed), event.Status)
+	assert.Equal(t, 1, event.Code)
+
+	event, ok = webhookEvent(docState, contracts.DocumentResult{Status: contracts.ResultStatusSuccess})
+	assert.True(t, ok)
+	assert.Equal(t, appconfig.WebhookEventDocumentCompleted, event.Event)
+	assert.Equal(t, string(contracts.ResultStatusSuccess), event.Status)
+	assert.Empty(t, event.StepName)
+
+	step.PluginResults["step2"].Status = contracts.ResultStatusSuccessAndReboot
+	_, ok = webhookEvent(docState, step)
+	assert.False(t, ok)
+	_, ok = webhookEvent(docState, contracts.DocumentResult{Status: contracts.ResultStatusSuccessAndReboot})
+	assert.False(t, ok)
+	docState.DocumentType = contracts.StartSession
+	_, ok = webhookEvent(docState, contracts.DocumentResult{Status: contracts.ResultStatusSuccess})
+	assert.False(t, ok)
+}
+
+func TestAuditEvent(t *testing.T) {
+	docState := resultNotificationDocState()
+	step := contracts.DocumentResult{
+		LastPlugin: "step2",
+		Status:     contracts.ResultStatusInProgress,
+		PluginResults: map[string]*contracts.PluginResult{
+			"step2": {PluginID: "step2", PluginName: "aws:runShellScript", Status: contracts.ResultStatusFailed, Code: 1},
+		},
+	}
+
+	event, ok := auditEvent(docState, step)
+	assert.True(t, ok)
+	assert.Equal(t, auditexport.StepCompleted, event.Name)
+	assert.Equal(t, "commandID", event.CommandID)
+	assert.Equal(t, "aws:runShellScript", event.Action)
+	assert.Equal(t, 1, event.ExitCode)
+
+	event, ok = auditEvent(docState, contracts.DocumentResult{Status: contracts.ResultStatusFailed})
+	assert.True(t, ok)
+	assert.Equal(t, auditexport.CommandCompleted, event.Name)
+	assert.Equal(t, string(contracts.ResultStatusFailed), event.Status)
+
+	docState.DocumentType = contracts.StartSession
+	docState.DocumentInformation.DocumentID = "session-1"
+	docState.DocumentInformation.ClientId = "client-1"
+	_, ok = auditEvent(docState, step)
+	assert.False(t, ok)
+	event, ok = auditEvent(docState, contracts.DocumentResult{Status: contracts.ResultStatusSuccess})
+	assert.True(t, ok)
+	assert.Equal(t, auditexport.SessionEnded, event.Name)
+	assert.Equal(t, "session-1", event.SessionID)
+	assert.Equal(t, "client-1", event.ClientID)
+	assert.Empty(t, event.CommandID)
+}
+
+func TestFirstStepError(t *testing.T) {
+	docState := resultNotificationDocState()
+	final := &contracts.DocumentResult{
+		Status: contracts.ResultStatusFailed,
+		PluginResults: map[string]*contracts.PluginResult{
+			"step1": {Status: contracts.ResultStatusSuccess},
+			"step2": {Status: contracts.ResultStatusFailed, Error: "AccessDenied"},
+		},
+	}
+	assert.Equal(t, "AccessDenied", firstStepError(docState, final))
+
+	final.PluginResults["step1"] = &contracts.PluginResult{Status: contracts.ResultStatusFailed, Error: strings.Repeat("e", 2*maxSummaryErrorLength)}
+	assert.Len(t, firstStepError(docState, final), maxSummaryErrorLength+3)
+
+	assert.Empty(t, firstStepError(docState, &contracts.DocumentResult{Status: contracts.ResultStatusTimedOut}))
+}
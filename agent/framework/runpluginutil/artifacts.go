@@ -0,0 +1,111 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+)
+
+const outputArtifactsFileName = "artifacts.zip"
+
+// outputArtifactsInput is the part of the plugin properties that declares the output artifacts of a step.
+type outputArtifactsInput struct {
+	OutputArtifacts []string
+}
+
+// uploadOutputArtifacts uploads the bundle through the upload queue, with the priority and the presigned urls of the output
+var uploadOutputArtifacts = func(log log.T, bucketName string, objectKey string, filePath string) error {
+	return uploadqueue.UploadFile(log, uploadqueue.PriorityResults, uploadqueue.S3Object{Bucket: bucketName, Key: objectKey, FilePath: filePath})
+}
+
+// getOutputArtifactPaths returns the absolute paths declared as output artifacts in the plugin properties,
+// with glob patterns expanded.
+func getOutputArtifactPaths(properties interface{}) (paths []string, err error) {
+	var propertyList []interface{}
+	if list, ok := properties.([]interface{}); ok {
+		propertyList = list
+	} else {
+		propertyList = []interface{}{properties}
+	}
+
+	for _, prop := range propertyList {
+		if _, ok := prop.(map[string]interface{}); !ok {
+			continue
+		}
+		var input outputArtifactsInput
+		if err = jsonutil.Remarshal(prop, &input); err != nil {
+			return nil, fmt.Errorf("invalid output artifacts %v", err)
+		}
+		for _, pattern := range input.OutputArtifacts {
+			if !filepath.IsAbs(pattern) {
+				return nil, fmt.Errorf("output artifact path %v is not absolute", pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid output artifact path %v: %v", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("output artifact path %v does not match any file", pattern)
+			}
+			paths = append(paths, matches...)
+		}
+	}
+	return paths, nil
+}
+
+// collectOutputArtifacts zips the output artifacts declared by a step into its orchestration directory
+// and uploads the bundle next to the step output in s3 when an output bucket is configured.
+// Collecting artifacts does not change the status of the step, failures are reported as an error message.
+func collectOutputArtifacts(
+	log log.T,
+	pluginName string,
+	config contracts.Configuration,
+	ioConfig contracts.IOConfiguration) (artifacts *contracts.OutputArtifacts, err error) {
+
+	paths, err := getOutputArtifactPaths(config.Properties)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+
+	orchestrationDir := fileutil.BuildPath(ioConfig.OrchestrationDirectory, pluginName, config.PluginID)
+	if err = fileutil.MakeDirs(orchestrationDir); err != nil {
+		return nil, fmt.Errorf("failed to create directory for output artifacts %v", err)
+	}
+	zipPath := filepath.Join(orchestrationDir, outputArtifactsFileName)
+	log.Debugf("Collecting output artifacts %v into %v", paths, zipPath)
+
+	files, err := fileutil.ZipFiles(zipPath, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect output artifacts %v", err)
+	}
+	artifacts = &contracts.OutputArtifacts{Files: files}
+
+	if ioConfig.OutputS3BucketName == "" {
+		return artifacts, nil
+	}
+	s3Key := fileutil.BuildS3Path(ioConfig.OutputS3KeyPrefix, pluginName, config.PluginID, outputArtifactsFileName)
+	log.Debugf("Uploading output artifacts to s3://%v/%v", ioConfig.OutputS3BucketName, s3Key)
+	if err = uploadOutputArtifacts(log, ioConfig.OutputS3BucketName, s3Key, zipPath); err != nil {
+		return artifacts, fmt.Errorf("failed to upload output artifacts %v", err)
+	}
+	artifacts.Location = fmt.Sprintf("s3://%v/%v", ioConfig.OutputS3BucketName, s3Key)
+	return artifacts, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOutputArtifactPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.log"), []byte("a"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.log"), []byte("b"), 0600))
+
+	paths, err := getOutputArtifactPaths(map[string]interface{}{"outputArtifacts": []interface{}{filepath.Join(dir, "*.log")}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")}, paths)
+
+	paths, err = getOutputArtifactPaths([]interface{}{map[string]interface{}{"runCommand": "echo"}})
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+
+	_, err = getOutputArtifactPaths(map[string]interface{}{"outputArtifacts": []interface{}{"a.log"}})
+	assert.Error(t, err)
+
+	_, err = getOutputArtifactPaths(map[string]interface{}{"outputArtifacts": []interface{}{filepath.Join(dir, "*.txt")}})
+	assert.Error(t, err)
+}
+
+func TestCollectOutputArtifacts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifacts")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	report := filepath.Join(dir, "report.txt")
+	assert.NoError(t, ioutil.WriteFile(report, []byte("report"), 0600))
+
+	var uploadedKey string
+	origUpload := uploadOutputArtifacts
+	defer func() { uploadOutputArtifacts = origUpload }()
+	uploadOutputArtifacts = func(log log.T, bucketName string, objectKey string, filePath string) error {
+		uploadedKey = objectKey
+		_, err := os.Stat(filePath)
+		assert.NoError(t, err)
+		return nil
+	}
+
+	config := contracts.Configuration{
+		PluginID:   "step1",
+		Properties: map[string]interface{}{"outputArtifacts": []interface{}{report}},
+	}
+	ioConfig := contracts.IOConfiguration{
+		OrchestrationDirectory: filepath.Join(dir, "orchestration"),
+		OutputS3BucketName:     "bucket",
+		OutputS3KeyPrefix:      "prefix",
+	}
+	artifacts, err := collectOutputArtifacts(log.NewMockLog(), "aws:runShellScript", config, ioConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, "prefix/awsrunShellScript/step1/artifacts.zip", uploadedKey)
+	assert.Equal(t, "s3://bucket/"+uploadedKey, artifacts.Location)
+	assert.Len(t, artifacts.Files, 1)
+
+	uploadOutputArtifacts = func(log log.T, bucketName string, objectKey string, filePath string) error {
+		return fmt.Errorf("access denied")
+	}
+	artifacts, err = collectOutputArtifacts(log.NewMockLog(), "aws:runShellScript", config, ioConfig)
+	assert.Error(t, err)
+	assert.Empty(t, artifacts.Location)
+	assert.Len(t, artifacts.Files, 1)
+}
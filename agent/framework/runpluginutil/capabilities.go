@@ -0,0 +1,110 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// preconditionOperators is the list of the precondition operators evaluated by this agent
+var preconditionOperators = []string{"StringEquals"}
+
+// AgentCapabilities returns the capabilities of this agent, i.e. the document schema versions it parses,
+// the plugins it knows, including the registered out-of-tree plugins, and the preconditions it evaluates, including
+// the site-specific checks.
+func AgentCapabilities() []string {
+	capabilities := []string{}
+	for schemaVersion := range appconfig.SupportedDocumentVersions {
+		capabilities = append(capabilities, contracts.SchemaVersionCapability(schemaVersion))
+	}
+	for pluginName := range allPlugins {
+		capabilities = append(capabilities, contracts.PluginCapability(pluginName))
+	}
+	execPluginsLock.RLock()
+	for pluginName := range execPlugins {
+		capabilities = append(capabilities, contracts.PluginCapability(pluginName))
+	}
+	execPluginsLock.RUnlock()
+	for _, operator := range preconditionOperators {
+		for variable := range preconditionVariables {
+			capabilities = append(capabilities, contracts.PreconditionCapability(operator, variable))
+		}
+		for _, check := range preconditionChecks() {
+			capabilities = append(capabilities, contracts.PreconditionCapability(operator, checkVariablePrefix+check))
+		}
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// documentMissingCapabilities runs the capability check pass of a document, it returns the capabilities missing
+// from this agent for the steps left to run which would fail because of them
+func documentMissingCapabilities(log log.T, plugins []contracts.PluginState, registry PluginRegistry) (missing []string) {
+	for _, pluginState := range plugins {
+		switch pluginState.Result.Status {
+		case "", contracts.ResultStatusNotStarted, contracts.ResultStatusInProgress, contracts.ResultStatusSuccessAndReboot:
+		default:
+			continue
+		}
+		configuration := pluginState.Configuration
+		_, pluginHandlerFound := registry[pluginState.Name]
+		isKnown, isSupported, _ := isSupportedPlugin(log, pluginState.Name)
+		operation, _ := getStepExecutionOperation(
+			log,
+			pluginState.Name,
+			pluginState.Id,
+			isKnown,
+			isSupported,
+			pluginHandlerFound,
+			configuration.IsPreconditionEnabled,
+			configuration.Preconditions)
+		if operation == failStep {
+			missing = append(missing, stepMissingCapabilities(pluginState.Name, isKnown, configuration)...)
+		}
+	}
+	return
+}
+
+// stepMissingCapabilities returns the capabilities missing from this agent to run a step,
+// there is none when the step fails for any other reason than the agent being too old
+func stepMissingCapabilities(pluginName string, isKnown bool, configuration contracts.Configuration) (missing []string) {
+	if !isKnown {
+		missing = append(missing, contracts.PluginCapability(pluginName))
+	}
+	if !configuration.IsPreconditionEnabled {
+		return
+	}
+	for operator, operands := range configuration.Preconditions {
+		if !isKnownPreconditionOperator(operator) {
+			missing = append(missing, contracts.PreconditionCapability(operator, ""))
+		} else if _, _, isValid := preconditionOperands(operands); !isValid {
+			missing = append(missing, contracts.PreconditionCapability(operator, strings.Join(operands, ",")))
+		}
+	}
+	return
+}
+
+func isKnownPreconditionOperator(operator string) bool {
+	for _, known := range preconditionOperators {
+		if operator == known {
+			return true
+		}
+	}
+	return false
+}
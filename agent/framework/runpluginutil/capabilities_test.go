@@ -0,0 +1,98 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentCapabilities(t *testing.T) {
+	RegisterExecPlugin("acme:configureThing")
+	defer func() { delete(execPlugins, "acme:configureThing") }()
+
+	capabilities := AgentCapabilities()
+
+	assert.Contains(t, capabilities, "schemaVersion:2.2")
+	assert.Contains(t, capabilities, "plugin:"+appconfig.PluginNameAwsRunShellScript)
+	assert.Contains(t, capabilities, "plugin:acme:configureThing")
+	assert.Contains(t, capabilities, "precondition:StringEquals:platformType")
+	assert.NotContains(t, capabilities, "schemaVersion:3.0")
+}
+
+func TestDocumentMissingCapabilities(t *testing.T) {
+	setIsSupportedMock()
+	defer restoreIsSupported()
+	logger := log.NewMockLog()
+	registry := PluginRegistry{testPlugin1: new(PluginFactoryMock), testPlugin2: new(PluginFactoryMock)}
+
+	step := func(name string, preconditions map[string][]string, status contracts.ResultStatus) contracts.PluginState {
+		return contracts.PluginState{
+			Id:   name,
+			Name: name,
+			Configuration: contracts.Configuration{
+				PluginID:              name,
+				PluginName:            name,
+				IsPreconditionEnabled: true,
+				Preconditions:         preconditions,
+			},
+			Result: contracts.PluginResult{Status: status},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		plugins  []contracts.PluginState
+		expected []string
+	}{
+		{
+			"supported document",
+			[]contracts.PluginState{step(testPlugin1, nil, ""), step(testPlugin2, nil, "")},
+			nil,
+		},
+		{
+			"unknown plugin",
+			[]contracts.PluginState{step(testPlugin1, nil, ""), step(testUnknownPlugin, nil, "")},
+			[]string{"plugin:" + testUnknownPlugin},
+		},
+		{
+			"unknown plugin already completed",
+			[]contracts.PluginState{step(testUnknownPlugin, nil, contracts.ResultStatusFailed)},
+			nil,
+		},
+		{
+			"unrecognized precondition operator and operand",
+			[]contracts.PluginState{
+				step(testPlugin1, map[string][]string{"StringLike": {"platformType", "Linux"}}, ""),
+				step(testPlugin2, map[string][]string{"StringEquals": {"kernel", "5.10"}}, ""),
+			},
+			[]string{"precondition:StringLike", "precondition:StringEquals:kernel,5.10"},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, documentMissingCapabilities(logger, tc.plugins, registry), tc.name)
+	}
+}
+
+func TestStepMissingCapabilities(t *testing.T) {
+	legacy := contracts.Configuration{Preconditions: map[string][]string{"StringLike": {"platformType", "Linux"}}}
+
+	assert.Empty(t, stepMissingCapabilities(testPlugin1, true, legacy))
+	assert.Equal(t, []string{"plugin:" + testUnknownPlugin}, stepMissingCapabilities(testUnknownPlugin, false, legacy))
+}
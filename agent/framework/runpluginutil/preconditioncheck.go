@@ -0,0 +1,148 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	gocontext "context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/execplugin"
+)
+
+// checkVariablePrefix prefixes the precondition variables evaluated by a site-specific check, such as check:sanMounted
+const checkVariablePrefix = "check:"
+
+// checkNamePattern is the format of the names of the precondition checks, they are the names of their executables
+var checkNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// checkExecutableExtensions are the extensions the executable of a check is looked for with, in that order
+var checkExecutableExtensions = []string{"", ".exe", ".cmd", ".bat"}
+
+// preconditionChecksConfig returns the configuration of the precondition checks
+var preconditionChecksConfig = func() appconfig.PreconditionChecksCfg {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		return appconfig.PreconditionChecksCfg{}
+	}
+	return config.PreconditionChecks
+}
+
+// checkResult is the cached result of a precondition check
+type checkResult struct {
+	passed  bool
+	expires time.Time
+}
+
+var checkResults = map[string]checkResult{}
+var checkResultsLock sync.Mutex
+
+// isCheckVariable returns true if the precondition variable names a check, the checks are only known when their
+// directory is configured
+func isCheckVariable(variable string) bool {
+	if !strings.HasPrefix(variable, checkVariablePrefix) {
+		return false
+	}
+	return checkNamePattern.MatchString(strings.TrimPrefix(variable, checkVariablePrefix)) &&
+		preconditionChecksConfig().Directory != ""
+}
+
+// preconditionVariableValue returns the value on this instance of a precondition variable
+func preconditionVariableValue(log log.T, variable string) string {
+	if isCheckVariable(variable) {
+		return strconv.FormatBool(runPreconditionCheck(log, strings.TrimPrefix(variable, checkVariablePrefix)))
+	}
+	return preconditionVariables[variable](log)
+}
+
+// runPreconditionCheck runs the executable of a check and returns true if it exits with 0, the result is reused
+// until it expires; a check which is missing, not trusted, fails to run or times out is false
+func runPreconditionCheck(log log.T, name string) bool {
+	config := preconditionChecksConfig()
+	checkResultsLock.Lock()
+	defer checkResultsLock.Unlock()
+	if cached, found := checkResults[name]; found && time.Now().Before(cached.expires) {
+		log.Debugf("Precondition check %s is cached as %t", name, cached.passed)
+		return cached.passed
+	}
+
+	passed := false
+	if path, err := checkExecutable(config.Directory, name); err != nil {
+		log.Warnf("Precondition check %s cannot be run: %v", name, err)
+	} else {
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), time.Duration(config.TimeoutSeconds)*time.Second)
+		err = exec.CommandContext(ctx, path).Run()
+		if ctx.Err() == gocontext.DeadlineExceeded {
+			log.Warnf("Precondition check %s timed out after %v seconds", name, config.TimeoutSeconds)
+		} else if err != nil {
+			log.Infof("Precondition check %s is false: %v", name, err)
+		} else {
+			passed = true
+		}
+		cancel()
+	}
+
+	if config.CacheSeconds > 0 {
+		checkResults[name] = checkResult{
+			passed:  passed,
+			expires: time.Now().Add(time.Duration(config.CacheSeconds) * time.Second),
+		}
+	}
+	return passed
+}
+
+// checkExecutable returns the trusted executable of a check in the checks directory
+func checkExecutable(directory string, name string) (string, error) {
+	if err := execplugin.CheckTrusted(directory, true); err != nil {
+		return "", err
+	}
+	var err error
+	for _, extension := range checkExecutableExtensions {
+		path := filepath.Join(directory, name+extension)
+		if err = execplugin.CheckTrusted(path, false); err == nil {
+			return path, nil
+		}
+	}
+	return "", err
+}
+
+// preconditionChecks returns the names of the checks found in the checks directory
+func preconditionChecks() (names []string) {
+	directory := preconditionChecksConfig().Directory
+	if directory == "" {
+		return
+	}
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return
+	}
+	found := make(map[string]bool)
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		if file.Mode().IsRegular() && checkNamePattern.MatchString(name) && !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	return
+}
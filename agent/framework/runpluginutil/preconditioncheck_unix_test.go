@@ -0,0 +1,106 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package runpluginutil run plugin utility functions without referencing the actually plugin impl packages
+package runpluginutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultPreconditionChecksConfig = preconditionChecksConfig
+
+func setupPreconditionChecks(t *testing.T, cacheSeconds int) string {
+	dir, err := ioutil.TempDir("", "preconditions")
+	assert.NoError(t, err)
+	os.Chmod(dir, 0755)
+	ioutil.WriteFile(filepath.Join(dir, "sanMounted"), []byte("#!/bin/sh\nexit 0\n"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "quorum"), []byte("#!/bin/sh\necho run >> "+filepath.Join(dir, "runs.txt")+"\nexit 1\n"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "slow"), []byte("#!/bin/sh\nsleep 5\n"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "untrusted"), []byte("#!/bin/sh\nexit 0\n"), 0755)
+	os.Chmod(filepath.Join(dir, "untrusted"), 0777)
+	preconditionChecksConfig = func() appconfig.PreconditionChecksCfg {
+		return appconfig.PreconditionChecksCfg{Directory: dir, TimeoutSeconds: 1, CacheSeconds: cacheSeconds}
+	}
+	checkResults = map[string]checkResult{}
+	return dir
+}
+
+func teardownPreconditionChecks(dir string) {
+	os.RemoveAll(dir)
+	preconditionChecksConfig = defaultPreconditionChecksConfig
+	checkResults = map[string]checkResult{}
+}
+
+func TestEvaluatePreconditionsCheck(t *testing.T) {
+	logger := log.NewMockLog()
+	dir := setupPreconditionChecks(t, 0)
+	defer teardownPreconditionChecks(dir)
+
+	isAllowed, unrecognized := evaluatePreconditions(logger, map[string][]string{"StringEquals": {"check:sanMounted", "true"}})
+	assert.True(t, isAllowed)
+	assert.Empty(t, unrecognized)
+
+	isAllowed, unrecognized = evaluatePreconditions(logger, map[string][]string{"StringEquals": {"true", "check:quorum"}})
+	assert.False(t, isAllowed)
+	assert.Empty(t, unrecognized)
+
+	// the checks which time out, are not trusted or are missing are false
+	for _, check := range []string{"check:slow", "check:untrusted", "check:missing"} {
+		isAllowed, unrecognized = evaluatePreconditions(logger, map[string][]string{"StringEquals": {check, "true"}})
+		assert.False(t, isAllowed, check)
+		assert.Empty(t, unrecognized, check)
+	}
+
+	_, unrecognized = evaluatePreconditions(logger, map[string][]string{"StringEquals": {"check:../sanMounted", "true"}})
+	assert.Len(t, unrecognized, 1)
+}
+
+func TestPreconditionCheckIsCached(t *testing.T) {
+	logger := log.NewMockLog()
+	dir := setupPreconditionChecks(t, 60)
+	defer teardownPreconditionChecks(dir)
+
+	assert.False(t, runPreconditionCheck(logger, "quorum"))
+	assert.False(t, runPreconditionCheck(logger, "quorum"))
+
+	runs, _ := ioutil.ReadFile(filepath.Join(dir, "runs.txt"))
+	assert.Equal(t, "run\n", string(runs))
+}
+
+func TestPreconditionCheckUnknownWithoutDirectory(t *testing.T) {
+	logger := log.NewMockLog()
+
+	_, unrecognized := evaluatePreconditions(logger, map[string][]string{"StringEquals": {"check:sanMounted", "true"}})
+	assert.Len(t, unrecognized, 1)
+}
+
+func TestAgentCapabilitiesIncludePreconditionChecks(t *testing.T) {
+	dir := setupPreconditionChecks(t, 0)
+	defer teardownPreconditionChecks(dir)
+
+	capabilities := AgentCapabilities()
+
+	assert.Contains(t, capabilities, contracts.PreconditionCapability("StringEquals", "check:sanMounted"))
+	assert.Contains(t, capabilities, contracts.PreconditionCapability("StringEquals", "check:quorum"))
+}
@@ -18,7 +18,9 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/cloudwatchlogsinterface"
@@ -30,6 +32,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/clusterdetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
@@ -45,6 +48,20 @@ const (
 	failStep    string = "fail"
 )
 
+// preconditionVariables returns the value on this instance of the variables a precondition can compare
+var preconditionVariables = map[string]func(log log.T) string{
+	"platformType": func(log log.T) string {
+		platformType, _ := platform.PlatformType(log)
+		return platformType
+	},
+	"isActiveNode": func(log log.T) string {
+		return strconv.FormatBool(isActiveClusterNode(log))
+	},
+}
+
+// isActiveClusterNode returns true when the instance owns a role of its failover cluster
+var isActiveClusterNode = clusterdetect.IsActiveNode
+
 // TODO: rename to RCPlugin, this represents RCPlugin interface.
 type T interface {
 	Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler)
@@ -83,7 +100,17 @@ var allPlugins = map[string]struct{}{
 	appconfig.PluginNameAwsAgentUpdate:         {},
 	appconfig.PluginNameAwsApplications:        {},
 	appconfig.PluginNameAwsConfigureDaemon:     {},
+	appconfig.PluginNameAwsConfigureDefender:   {},
+	appconfig.PluginNameAwsConfigureFirewall:   {},
 	appconfig.PluginNameAwsConfigurePackage:    {},
+	appconfig.PluginNameAwsInstallCertificate:  {},
+	appconfig.PluginNameAwsKubernetesNode:      {},
+	appconfig.PluginNameAwsConfigureVolume:     {},
+	appconfig.PluginNameAwsConfigureTimeSync:   {},
+	appconfig.PluginNameAwsConfigureDns:        {},
+	appconfig.PluginNameAwsLifecycleAction:     {},
+	appconfig.PluginNameAwsLogTailer:           {},
+	appconfig.PluginNameAwsFanOut:              {},
 	appconfig.PluginNameAwsPowerShellModule:    {},
 	appconfig.PluginNameAwsRunPowerShellScript: {},
 	appconfig.PluginNameAwsRunShellScript:      {},
@@ -98,6 +125,64 @@ var allPlugins = map[string]struct{}{
 	appconfig.PluginRunDocument:                {},
 }
 
+// execPlugins is the list of the out-of-tree plugins found by the agent, they are known in addition to allPlugins
+var execPlugins = map[string]struct{}{}
+
+var execPluginsLock sync.RWMutex
+
+// RegisterExecPlugin adds an out-of-tree plugin to the plugins known to the agent
+func RegisterExecPlugin(pluginName string) {
+	execPluginsLock.Lock()
+	defer execPluginsLock.Unlock()
+	execPlugins[pluginName] = struct{}{}
+}
+
+// isKnownPlugin returns true for the plugins of the agent and the registered out-of-tree plugins
+func isKnownPlugin(pluginName string) bool {
+	if _, known := allPlugins[pluginName]; known {
+		return true
+	}
+	execPluginsLock.RLock()
+	defer execPluginsLock.RUnlock()
+	_, known := execPlugins[pluginName]
+	return known
+}
+
+// hostOnlyPlugins is the list of plugins managing the host itself, they are not supported when the agent runs in a container.
+var hostOnlyPlugins = map[string]struct{}{
+	appconfig.PluginNameAwsAgentUpdate:       {},
+	appconfig.PluginNameAwsConfigureDefender: {},
+	appconfig.PluginNameAwsConfigureFirewall: {},
+	appconfig.PluginNameAwsKubernetesNode:    {},
+	appconfig.PluginNameAwsConfigureVolume:   {},
+	appconfig.PluginNameAwsConfigureTimeSync: {},
+	appconfig.PluginNameAwsConfigureDns:      {},
+	appconfig.PluginNameCloudWatch:           {},
+	appconfig.PluginNameConfigureDocker:      {},
+	appconfig.PluginNameDockerContainer:      {},
+	appconfig.PluginNameDomainJoin:           {},
+	appconfig.PluginEC2ConfigUpdate:          {},
+}
+
+// checkModePlugins is the list of plugins reporting the changes they would make without making them, the steps of the
+// other plugins are skipped when a document runs in check mode.
+var checkModePlugins = map[string]struct{}{
+	appconfig.PluginNameAwsConfigureDns:        {},
+	appconfig.PluginNameAwsConfigureFirewall:   {},
+	appconfig.PluginNameAwsConfigurePackage:    {},
+	appconfig.PluginNameAwsRunPowerShellScript: {},
+	appconfig.PluginNameAwsRunShellScript:      {},
+}
+
+// simulationPlugins is the list of plugins which can run without side effects, the steps of the other plugins are
+// skipped when a document runs in simulation.
+var simulationPlugins = map[string]struct{}{
+	appconfig.PluginDownloadContent:            {},
+	appconfig.PluginNameAwsConfigurePackage:    {},
+	appconfig.PluginNameAwsRunPowerShellScript: {},
+	appconfig.PluginNameAwsRunShellScript:      {},
+}
+
 // allSessionPlugins is the list of all known session plugins.
 var allSessionPlugins = map[string]struct{}{
 	appconfig.PluginNameStandardStream: {},
@@ -125,6 +210,17 @@ func RunPlugins(
 	//Contains the logStreamPrefix without the pluginID
 	logStreamPrefix := ioConfig.CloudWatchConfig.LogStreamPrefix
 
+	// documents get a workspace shared by their steps which is removed once they stop running, whatever the outcome
+	var workspace string
+	// the steps failing because of capabilities missing from this agent report every capability missing for the document
+	var missingCapabilities []string
+	if pluginRegistry, isDocument := registry.(PluginRegistry); isDocument {
+		var removeWorkspace func()
+		workspace, removeWorkspace = createWorkspace(context.Log(), context.AppConfig().Ssm.Workspace)
+		defer removeWorkspace()
+		missingCapabilities = documentMissingCapabilities(context.Log(), plugins, pluginRegistry)
+	}
+
 	for _, pluginState := range plugins {
 		pluginID := pluginState.Id     // the identifier of the plugin
 		pluginName := pluginState.Name // the name of the plugin
@@ -161,6 +257,11 @@ func RunPlugins(
 
 		// populate plugin start time and status
 		configuration := pluginState.Configuration
+		configuration.WorkspaceDirectory = workspace
+		configuration.RebootCount = pluginOutput.RebootCount
+		if _, isDocument := registry.(PluginRegistry); isDocument && context.AppConfig().Agent.Simulation {
+			configuration.Simulation = true
+		}
 
 		if ioConfig.OutputS3BucketName != "" {
 			pluginOutputs[pluginID].OutputS3BucketName = ioConfig.OutputS3BucketName
@@ -202,6 +303,7 @@ func RunPlugins(
 			pluginOutputs[pluginID].Status = contracts.ResultStatusFailed
 			pluginOutputs[pluginID].Code = 1
 			pluginOutputs[pluginID].Error = "Invalid registry type. Must be either worker PluginRegistry or SessionPluginRegistry"
+			pluginOutputs[pluginID].ErrorCode = contracts.ErrorCodeInternal
 			context.Log().Error(pluginOutputs[pluginID].Error)
 			resChan <- *pluginOutputs[pluginID]
 		}
@@ -216,31 +318,82 @@ func RunPlugins(
 			configuration.IsPreconditionEnabled,
 			configuration.Preconditions)
 
+		if operation == executeStep && deferOnActiveNode(context.Log(), configuration, context.AppConfig().Ssm.DeferDisruptiveStepsOnActiveNode) {
+			operation = skipStep
+			logMessage = fmt.Sprintf(
+				"Step execution deferred because this instance is the active node of its failover cluster. Step name: %s",
+				pluginID)
+		}
+
+		_, supportsCheckMode := checkModePlugins[pluginName]
+		if operation == executeStep && configuration.CheckMode && !supportsCheckMode {
+			operation = skipStep
+			logMessage = fmt.Sprintf(
+				"Step execution skipped because plugin %s does not support check mode. Step name: %s",
+				pluginName,
+				pluginID)
+		}
+
+		_, supportsSimulation := simulationPlugins[pluginName]
+		if operation == executeStep && configuration.Simulation && !supportsSimulation {
+			operation = skipStep
+			logMessage = fmt.Sprintf(
+				"Step execution skipped because plugin %s does not support simulation. Step name: %s",
+				pluginName,
+				pluginID)
+		}
+
 		switch operation {
 		case executeStep:
 			context.Log().Infof("Running plugin %s", pluginName)
 			r = runPlugin(context, pluginFactory, pluginName, configuration, cancelFlag, ioConfig)
+			if r.Status == contracts.ResultStatusSuccessAndReboot {
+				pluginOutputs[pluginID].RebootCount++
+				r = limitReboots(context.Log(), r, pluginOutputs[pluginID].RebootCount, context.AppConfig().Ssm.StepRebootLimit)
+			}
 			pluginOutputs[pluginID].Code = r.Code
 			pluginOutputs[pluginID].Status = r.Status
 			pluginOutputs[pluginID].Error = r.Error
 			pluginOutputs[pluginID].Output = r.Output
 			pluginOutputs[pluginID].StandardOutput = r.StandardOutput
 			pluginOutputs[pluginID].StandardError = r.StandardError
+			pluginOutputs[pluginID].ErrorCode = r.ErrorCode
+			pluginOutputs[pluginID].StandardOutputContinuation = r.StandardOutputContinuation
+			pluginOutputs[pluginID].StandardErrorContinuation = r.StandardErrorContinuation
+			pluginOutputs[pluginID].OutputArtifacts = r.OutputArtifacts
+			pluginOutputs[pluginID].CheckReport = r.CheckReport
+			pluginOutputs[pluginID].SimulationReport = r.SimulationReport
 
 		case skipStep:
 			context.Log().Info(logMessage)
 			pluginOutputs[pluginID].Status = contracts.ResultStatusSkipped
 			pluginOutputs[pluginID].Code = 0
 			pluginOutputs[pluginID].Output = logMessage
+			if configuration.CheckMode {
+				pluginOutputs[pluginID].CheckReport = &contracts.CheckReport{Supported: supportsCheckMode}
+			}
+			if configuration.Simulation {
+				pluginOutputs[pluginID].SimulationReport = &contracts.SimulationReport{Supported: supportsSimulation}
+			}
 		case failStep:
 			err := fmt.Errorf(logMessage)
 			pluginOutputs[pluginID].Status = contracts.ResultStatusFailed
 			pluginOutputs[pluginID].Error = err.Error()
+			if isKnown && !isSupported {
+				pluginOutputs[pluginID].ErrorCode = contracts.ErrorCodePlatformUnsupported
+			} else if _, isDocument := registry.(PluginRegistry); isDocument && len(stepMissingCapabilities(pluginName, isKnown, configuration)) > 0 {
+				unsupported := contracts.NewUnsupportedFeature(configuration.MinimumAgentVersion, missingCapabilities...)
+				pluginOutputs[pluginID].ErrorCode = unsupported.ErrorCode()
+				pluginOutputs[pluginID].Output = unsupported.Error()
+			} else {
+				pluginOutputs[pluginID].ErrorCode = contracts.ErrorCodeInvalidInput
+			}
 			context.Log().Error(err)
 		default:
 			err := fmt.Errorf("Unknown error, Operation: %s, Plugin name: %s", operation, pluginName)
 			pluginOutputs[pluginID].Status = contracts.ResultStatusFailed
 			pluginOutputs[pluginID].Error = err.Error()
+			pluginOutputs[pluginID].ErrorCode = contracts.ErrorCodeInternal
 			context.Log().Error(err)
 		}
 
@@ -274,7 +427,7 @@ func runPlugin(
 	cancelFlag task.CancelFlag,
 	ioConfig contracts.IOConfiguration) (res contracts.PluginResult) {
 	// create a new context that includes plugin ID
-	context = context.With("[pluginName=" + pluginName + "]")
+	context = context.With("[pluginName=" + pluginName + "]").With("[pluginID=" + config.PluginID + "]")
 
 	log := context.Log()
 	defer func() {
@@ -284,6 +437,7 @@ func runPlugin(
 			res.Status = contracts.ResultStatusFailed
 			res.Code = 1
 			res.Error = fmt.Errorf("Plugin crashed with message %v!", err).Error()
+			res.ErrorCode = contracts.ErrorCodeInternal
 			log.Error(res.Error)
 		}
 	}()
@@ -308,6 +462,17 @@ func runPlugin(
 		res.Status = contracts.ResultStatusFailed
 		res.Code = 1
 		res.Error = fmt.Errorf("failed to create plugin %v!", err).Error()
+		res.ErrorCode = contracts.ErrorCodeInternal
+		log.Error(res.Error)
+		return
+	}
+
+	// restrict the orchestration directory before the plugin runs, on Windows the files created in it inherit its ACL
+	if err = applyFilePermissions(config, ioConfig); err != nil {
+		res.Status = contracts.ResultStatusFailed
+		res.Code = 1
+		res.Error = fmt.Errorf("failed to apply the file permissions of the document %v", err).Error()
+		res.ErrorCode = contracts.ErrorCodeInternal
 		log.Error(res.Error)
 		return
 	}
@@ -316,6 +481,7 @@ func runPlugin(
 	defer func() { res.EndDateTime = time.Now() }()
 
 	output := iohandler.NewDefaultIOHandler(log, ioConfig)
+	stepConfig := config
 	//check if properties is a list. If true, then unroll
 	switch config.Properties.(type) {
 	case []interface{}:
@@ -340,10 +506,86 @@ func runPlugin(
 	res.Output = output.GetOutput()
 	res.StandardOutput = output.GetStdout()
 	res.StandardError = output.GetStderr()
+	res.ErrorCode = contracts.ResultErrorCode(res.Status, output.GetErrorCode())
+	res.StandardOutputContinuation, res.StandardErrorContinuation = output.GetOutputContinuations()
+	if config.CheckMode {
+		res.CheckReport = &contracts.CheckReport{Supported: true, Changes: output.GetCheckChanges()}
+		// a step run in check mode reports the reboot it would request but doesn't reboot the instance
+		if res.Status == contracts.ResultStatusSuccessAndReboot {
+			log.Info("Ignoring the reboot requested by the step run in check mode")
+			res.Status = contracts.ResultStatusSuccess
+			res.CheckReport.Changes = append(res.CheckReport.Changes, "Reboot the instance")
+		}
+	}
+	if config.Simulation {
+		res.SimulationReport = &contracts.SimulationReport{Supported: true, Trace: output.GetSimulationTrace()}
+		// a step run in simulation reports the reboot it would request but doesn't reboot the instance
+		if res.Status == contracts.ResultStatusSuccessAndReboot {
+			log.Info("Ignoring the reboot requested by the step run in simulation")
+			res.Status = contracts.ResultStatusSuccess
+			res.SimulationReport.Trace = append(res.SimulationReport.Trace, "Reboot the instance")
+		}
+	}
+
+	if res.OutputArtifacts, err = collectOutputArtifacts(log, pluginName, stepConfig, ioConfig); err != nil {
+		log.Error(err)
+		if res.StandardError != "" {
+			res.StandardError += "\n"
+		}
+		res.StandardError += err.Error()
+	}
+
+	if err = applyFilePermissions(config, ioConfig); err != nil {
+		err = fmt.Errorf("failed to apply the file permissions of the document %v", err)
+		log.Error(err)
+		if res.StandardError != "" {
+			res.StandardError += "\n"
+		}
+		res.StandardError += err.Error()
+		res.Status = contracts.ResultStatusFailed
+		if res.Code == 0 {
+			res.Code = 1
+		}
+	}
 
 	return
 }
 
+// limitReboots fails a step that requested more reboots than the limit instead of rebooting the instance again,
+// which stops the scripts that keep requesting a reboot from looping forever
+func limitReboots(log log.T, res contracts.PluginResult, rebootCount int, rebootLimit int) contracts.PluginResult {
+	if rebootCount <= rebootLimit {
+		log.Infof("The step requested reboot %v of %v", rebootCount, rebootLimit)
+		return res
+	}
+	res.Status = contracts.ResultStatusFailed
+	if res.Code == 0 {
+		res.Code = 1
+	}
+	res.Error = fmt.Sprintf("the step requested more than %v reboots", rebootLimit)
+	res.ErrorCode = contracts.ErrorCodeRebootLimit
+	log.Error(res.Error)
+	return res
+}
+
+// applyFilePermissions applies the file permission policy of the document to its orchestration directory, which
+// holds the scripts, the downloads and the output of its plugins
+// deferOnActiveNode returns true when a disruptive step must wait for the roles of the failover cluster to move to
+// another node before it runs
+func deferOnActiveNode(log log.T, config contracts.Configuration, deferDisruptiveSteps bool) bool {
+	return config.Disruptive && deferDisruptiveSteps && isActiveClusterNode(log)
+}
+
+func applyFilePermissions(config contracts.Configuration, ioConfig contracts.IOConfiguration) error {
+	if config.FilePermissions.IsEmpty() || ioConfig.OrchestrationDirectory == "" {
+		return nil
+	}
+	if err := fileutil.MakeDirs(ioConfig.OrchestrationDirectory); err != nil {
+		return err
+	}
+	return pluginutil.ApplyFilePermissions(ioConfig.OrchestrationDirectory, config.FilePermissions)
+}
+
 var getDataChannelForSessionPlugin = func(context context.T, sessionId string, clientId string, onMessageHandler func(input []byte)) (datachannel.IDataChannel, error) {
 	retryer := retry.ExponentialRetryer{
 		CallableFunc: func() (channel interface{}, err error) {
@@ -377,8 +619,8 @@ func executePlugin(context context.T,
 	var propID string
 	var err error
 	if config.PluginName == config.PluginID {
-		if pluginName == appconfig.PluginNameCloudWatch {
-			propID = appconfig.PluginNameCloudWatch
+		if pluginName == appconfig.PluginNameCloudWatch || pluginName == appconfig.PluginNameAwsLogTailer {
+			propID = pluginName
 		} else {
 			propID, err = GetPropertyName(config.Properties) //V10 Schema
 		}
@@ -521,32 +763,20 @@ func evaluatePreconditions(
 	var isAllowed = true
 	var unrecognizedPreconditionList []string
 
-	// For current release, we only support "StringEquals" operator and "platformType"
-	// operand, so explicitly checking for those and number of operands must be 2
+	// For current release, we only support "StringEquals" operator and the variables of preconditionVariables or
+	// the precondition checks as operand, so explicitly checking for those and number of operands must be 2
 	for key, value := range preconditions {
 		switch key {
 		case "StringEquals":
-			// Platform type of OS on the instance
-			instancePlatformType, _ := platform.PlatformType(log)
-			log.Debugf("OS platform type of this instance = %s", instancePlatformType)
-
-			if len(value) != 2 ||
-				(strings.Compare(value[0], "platformType") == 0 && strings.Compare(value[1], "platformType") == 0) ||
-				(strings.Compare(value[0], "platformType") != 0 && strings.Compare(value[1], "platformType") != 0) {
-
+			variable, expected, isValid := preconditionOperands(value)
+			if !isValid {
 				unrecognizedPreconditionList = append(unrecognizedPreconditionList, fmt.Sprintf("\"%s\": %v", key, value))
 			} else {
-				// Variable and value can be in any order, i.e. both "StringEquals": ["platformType", "Windows"]
-				// and "StringEquals": ["Windows", "platformType"] are valid
-				var platformTypeValue string
-				if strings.Compare(value[0], "platformType") == 0 {
-					platformTypeValue = value[1]
-				} else {
-					platformTypeValue = value[0]
-				}
+				instanceValue := preconditionVariableValue(log, variable)
+				log.Debugf("%s of this instance = %s", variable, instanceValue)
 
-				if strings.Compare(instancePlatformType, strings.ToLower(platformTypeValue)) != 0 {
-					// if precondition doesn't match for platformType, mark step for skip
+				if strings.ToLower(instanceValue) != strings.ToLower(expected) {
+					// if precondition doesn't match, mark step for skip
 					isAllowed = false
 				}
 			}
@@ -558,3 +788,28 @@ func evaluatePreconditions(
 
 	return isAllowed, unrecognizedPreconditionList
 }
+
+// preconditionOperands returns the variable and the value compared by a precondition.
+// Variable and value can be in any order, i.e. both "StringEquals": ["platformType", "Windows"]
+// and "StringEquals": ["Windows", "platformType"] are valid
+func preconditionOperands(operands []string) (variable string, value string, isValid bool) {
+	if len(operands) != 2 {
+		return "", "", false
+	}
+	isFirstVariable := isPreconditionVariable(operands[0])
+	isSecondVariable := isPreconditionVariable(operands[1])
+	switch {
+	case isFirstVariable && !isSecondVariable:
+		return operands[0], operands[1], true
+	case isSecondVariable && !isFirstVariable:
+		return operands[1], operands[0], true
+	default:
+		return "", "", false
+	}
+}
+
+// isPreconditionVariable returns true if the operand of a precondition is a variable evaluated by this agent
+func isPreconditionVariable(operand string) bool {
+	_, found := preconditionVariables[operand]
+	return found || isCheckVariable(operand)
+}
@@ -19,9 +19,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/clusterdetect"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
 	datachannelMock "github.com/aws/amazon-ssm-agent/agent/session/datachannel/mocks"
@@ -278,6 +280,7 @@ func TestRunPluginsWithMissingPluginHandler(t *testing.T) {
 			StandardError:  defaultOutput,
 			Status:         contracts.ResultStatusFailed,
 			Error:          pluginError,
+			ErrorCode:      contracts.ErrorCodeInvalidInput,
 		}
 
 		pluginConfigs2[index] = pluginConfigs[name]
@@ -981,6 +984,8 @@ func TestRunPluginsWithMoreThanOnePrecondition(t *testing.T) {
 			StandardError:  defaultOutput,
 			Status:         contracts.ResultStatusFailed,
 			Error:          pluginError,
+			Output:         contracts.NewUnsupportedFeature("", contracts.PreconditionCapability("foo", "")).Error(),
+			ErrorCode:      contracts.ErrorCodeUnsupportedFeature,
 		}
 		pluginFactory := new(PluginFactoryMock)
 		pluginFactory.On("Create", mock.Anything).Return(pluginInstances[name], nil)
@@ -1076,6 +1081,8 @@ func TestRunPluginsWithUnrecognizedPreconditionOperator(t *testing.T) {
 			StandardError:  defaultOutput,
 			Status:         contracts.ResultStatusFailed,
 			Error:          pluginError,
+			Output:         contracts.NewUnsupportedFeature("", contracts.PreconditionCapability("foo", "")).Error(),
+			ErrorCode:      contracts.ErrorCodeUnsupportedFeature,
 		}
 		pluginFactory := new(PluginFactoryMock)
 		pluginFactory.On("Create", mock.Anything).Return(pluginInstances[name], nil)
@@ -1172,6 +1179,8 @@ func TestRunPluginsWithUnrecognizedPreconditionOperand(t *testing.T) {
 			StandardError:  defaultOutput,
 			Status:         contracts.ResultStatusFailed,
 			Error:          pluginError,
+			Output:         contracts.NewUnsupportedFeature("", contracts.PreconditionCapability("StringEquals", "foo,Linux")).Error(),
+			ErrorCode:      contracts.ErrorCodeUnsupportedFeature,
 		}
 
 		pluginFactory := new(PluginFactoryMock)
@@ -1269,6 +1278,8 @@ func TestRunPluginsWithUnrecognizedPreconditionDuplicateVariable(t *testing.T) {
 			StandardError:  defaultOutput,
 			Status:         contracts.ResultStatusFailed,
 			Error:          pluginError,
+			Output:         contracts.NewUnsupportedFeature("", contracts.PreconditionCapability("StringEquals", "platformType,platformType")).Error(),
+			ErrorCode:      contracts.ErrorCodeUnsupportedFeature,
 		}
 
 		pluginFactory := new(PluginFactoryMock)
@@ -1366,6 +1377,8 @@ func TestRunPluginsWithMoreThanTwoPreconditionOperands(t *testing.T) {
 			StandardError:  defaultOutput,
 			Status:         contracts.ResultStatusFailed,
 			Error:          pluginError,
+			Output:         contracts.NewUnsupportedFeature("", contracts.PreconditionCapability("StringEquals", "platformType,Linux,foo")).Error(),
+			ErrorCode:      contracts.ErrorCodeUnsupportedFeature,
 		}
 
 		pluginFactory := new(PluginFactoryMock)
@@ -1467,6 +1480,8 @@ func TestRunPluginsWithUnknownPlugin(t *testing.T) {
 				StandardError:  defaultOutput,
 				Status:         contracts.ResultStatusFailed,
 				Error:          pluginError,
+				Output:         contracts.NewUnsupportedFeature("", contracts.PluginCapability(testUnknownPlugin)).Error(),
+				ErrorCode:      contracts.ErrorCodeUnsupportedFeature,
 			}
 		} else {
 			pluginResults[name] = &contracts.PluginResult{
@@ -1592,3 +1607,59 @@ func TestRunPluginSuccessWithNonTruncatedResult(t *testing.T) {
 		assert.Equal(t, pluginResults[pluginID].StandardOutput, output.StandardOutput)
 	}
 }
+
+func TestLimitReboots(t *testing.T) {
+	logger := log.NewMockLog()
+	res := contracts.PluginResult{Status: contracts.ResultStatusSuccessAndReboot}
+
+	limited := limitReboots(logger, res, 3, 3)
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, limited.Status)
+	assert.Empty(t, limited.Error)
+
+	limited = limitReboots(logger, res, 4, 3)
+	assert.Equal(t, contracts.ResultStatusFailed, limited.Status)
+	assert.Equal(t, 1, limited.Code)
+	assert.Equal(t, contracts.ErrorCodeRebootLimit, limited.ErrorCode)
+	assert.Contains(t, limited.Error, "more than 3 reboots")
+}
+
+func TestEvaluatePreconditionsIsActiveNode(t *testing.T) {
+	logger := log.NewMockLog()
+	defer func() { isActiveClusterNode = clusterdetect.IsActiveNode }()
+	isActiveClusterNode = func(log.T) bool { return true }
+
+	isAllowed, unrecognized := evaluatePreconditions(logger, map[string][]string{"StringEquals": {"isActiveNode", "true"}})
+	assert.True(t, isAllowed)
+	assert.Empty(t, unrecognized)
+
+	isAllowed, unrecognized = evaluatePreconditions(logger, map[string][]string{"StringEquals": {"False", "isActiveNode"}})
+	assert.False(t, isAllowed)
+	assert.Empty(t, unrecognized)
+
+	_, unrecognized = evaluatePreconditions(logger, map[string][]string{"StringEquals": {"isActiveNode", "platformType"}})
+	assert.Len(t, unrecognized, 1)
+}
+
+func TestDeferOnActiveNode(t *testing.T) {
+	logger := log.NewMockLog()
+	defer func() { isActiveClusterNode = clusterdetect.IsActiveNode }()
+	isActiveClusterNode = func(log.T) bool { return true }
+
+	disruptive := contracts.Configuration{Disruptive: true}
+	assert.True(t, deferOnActiveNode(logger, disruptive, true))
+	assert.False(t, deferOnActiveNode(logger, disruptive, false))
+	assert.False(t, deferOnActiveNode(logger, contracts.Configuration{}, true))
+
+	isActiveClusterNode = func(log.T) bool { return false }
+	assert.False(t, deferOnActiveNode(logger, disruptive, true))
+}
+
+func TestRegisterExecPlugin(t *testing.T) {
+	assert.True(t, isKnownPlugin(appconfig.PluginNameAwsRunShellScript))
+	assert.False(t, isKnownPlugin("acme:configureThing"))
+
+	RegisterExecPlugin("acme:configureThing")
+	defer func() { delete(execPlugins, "acme:configureThing") }()
+
+	assert.True(t, isKnownPlugin("acme:configureThing"))
+}
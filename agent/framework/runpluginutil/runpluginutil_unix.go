@@ -26,10 +26,13 @@ import (
 // IsPluginSupportedForCurrentPlatform always returns true for plugins that exist for linux because currently there
 // are no plugins that are supported on only one distribution or version of linux.
 func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown bool, isSupported bool, message string) {
-	_, known := allPlugins[pluginName]
+	known := isKnownPlugin(pluginName)
 	platformName, _ := platform.PlatformName(log)
 	platformVersion, _ := platform.PlatformVersion(log)
 
+	if _, hostOnly := hostOnlyPlugins[pluginName]; hostOnly && platform.IsContainerMode(log) {
+		return known, false, fmt.Sprintf("%s (container) v%s", platformName, platformVersion)
+	}
 	return known, true, fmt.Sprintf("%s v%s", platformName, platformVersion)
 }
 
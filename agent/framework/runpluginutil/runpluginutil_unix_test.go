@@ -17,6 +17,7 @@
 package runpluginutil
 
 import (
+	"os"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -45,3 +46,16 @@ func TestUnknown(t *testing.T) {
 	assert.False(t, isKnown)
 	assert.True(t, isSupported)
 }
+
+func TestHostOnlyUnsupportedInContainerMode(t *testing.T) {
+	os.Setenv(appconfig.ContainerModeEnvVar, "true")
+	defer os.Unsetenv(appconfig.ContainerModeEnvVar)
+
+	isKnown, isSupported, _ := IsPluginSupportedForCurrentPlatform(mockLog, appconfig.PluginNameDomainJoin)
+	assert.True(t, isKnown)
+	assert.False(t, isSupported)
+
+	isKnown, isSupported, _ = IsPluginSupportedForCurrentPlatform(mockLog, appconfig.PluginNameAwsRunShellScript)
+	assert.True(t, isKnown)
+	assert.True(t, isSupported)
+}
@@ -31,7 +31,7 @@ import (
 
 // IsPluginSupportedForCurrentPlatform returns true if current platform supports the plugin with given name.
 func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown bool, isSupported bool, message string) {
-	_, known := allPlugins[pluginName]
+	known := isKnownPlugin(pluginName)
 	platformName, _ := platform.PlatformName(log)
 	platformVersion, _ := platform.PlatformVersion(log)
 
@@ -42,6 +42,9 @@ func IsPluginSupportedForCurrentPlatform(log log.T, pluginName string) (isKnown
 			return known, false, fmt.Sprintf("%s (Nano Server) v%s", platformName, platformVersion)
 		}
 	}
+	if _, hostOnly := hostOnlyPlugins[pluginName]; hostOnly && platform.IsContainerMode(log) {
+		return known, false, fmt.Sprintf("%s (container) v%s", platformName, platformVersion)
+	}
 	return known, true, fmt.Sprintf("%s v%s", platformName, platformVersion)
 }
 
@@ -0,0 +1,57 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"io/ioutil"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const workspaceDirPrefix = "ssm-workspace-"
+
+var createTempDir = ioutil.TempDir
+
+// createWorkspace creates the ephemeral workspace of a document execution, a directory only readable by the agent user
+// that is exposed to the scripts of the document. The returned function removes it, overwriting its files first
+// when secure delete is configured.
+func createWorkspace(log log.T, config appconfig.WorkspaceCfg) (workspace string, remove func()) {
+	workspace, err := createTempDir(config.RootDirectory, workspaceDirPrefix)
+	if err != nil {
+		log.Errorf("failed to create the workspace of the document in %v: %v", config.RootDirectory, err)
+		return "", func() {}
+	}
+	log.Debugf("Created the document workspace %v", workspace)
+
+	return workspace, func() {
+		removeWorkspace(log, config, workspace)
+	}
+}
+
+// removeWorkspace deletes the workspace of a document execution and all the files left in it.
+func removeWorkspace(log log.T, config appconfig.WorkspaceCfg, workspace string) {
+	var err error
+	if config.SecureDelete {
+		err = fileutil.SecureDeleteDirectory(workspace, config.OverwritePasses)
+	} else {
+		err = fileutil.DeleteDirectory(workspace)
+	}
+	if err != nil {
+		log.Errorf("failed to delete the document workspace %v: %v", workspace, err)
+		return
+	}
+	log.Debugf("Deleted the document workspace %v", workspace)
+}
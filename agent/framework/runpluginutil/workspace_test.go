@@ -0,0 +1,56 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runpluginutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWorkspace(t *testing.T) {
+	for _, secureDelete := range []bool{false, true} {
+		root, err := ioutil.TempDir("", "workspaceroot")
+		assert.NoError(t, err)
+		defer os.RemoveAll(root)
+
+		config := appconfig.WorkspaceCfg{RootDirectory: root, SecureDelete: secureDelete, OverwritePasses: 1}
+		workspace, remove := createWorkspace(log.NewMockLog(), config)
+		assert.Equal(t, root, filepath.Dir(workspace))
+		assert.True(t, strings.HasPrefix(filepath.Base(workspace), workspaceDirPrefix))
+
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(workspace, "temp.txt"), []byte("secret"), 0600))
+		remove()
+		_, err = os.Stat(workspace)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestCreateWorkspace_Failure(t *testing.T) {
+	defer func(f func(string, string) (string, error)) { createTempDir = f }(createTempDir)
+	createTempDir = func(string, string) (string, error) {
+		return "", errors.New("no space left on device")
+	}
+
+	workspace, remove := createWorkspace(log.NewMockLog(), appconfig.WorkspaceCfg{})
+	assert.Empty(t, workspace)
+	remove()
+}
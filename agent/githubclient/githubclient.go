@@ -17,6 +17,7 @@ package githubclient
 
 import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/go-github/github"
 	gitcontext "golang.org/x/net/context"
 
@@ -36,8 +37,11 @@ const (
 	contentTypeDirectory = "dir"
 )
 
-// NewClient is a constructor for GitClient
+// NewClient is a constructor for GitClient, the client downloads through the artifact proxy when httpClient is nil
 func NewClient(httpClient *http.Client) IGitClient {
+	if httpClient == nil {
+		httpClient = &http.Client{Transport: network.NewArtifactTransport(network.ArtifactEndpoint)}
+	}
 
 	return &GitClient{
 		github.NewClient(httpClient),
@@ -20,6 +20,7 @@ import (
 
 	"net/http"
 
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"golang.org/x/oauth2"
 )
 
@@ -34,7 +35,9 @@ type IOAuthClient interface {
 // GetGithubOauthClient returns the http client using oauth access tokens
 // implementation of this has been taken from https://github.com/google/go-github#authentication
 func (git OAuthClient) GetGithubOauthClient(token string) *http.Client {
-	ctx := gitcontext.Background()
+	// the token is sent through the artifact proxy
+	base := &http.Client{Transport: network.NewArtifactTransport(network.ArtifactEndpoint)}
+	ctx := gitcontext.WithValue(gitcontext.Background(), oauth2.HTTPClient, base)
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
@@ -20,8 +20,10 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/rolemonitor"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 	"github.com/carlescere/scheduler"
 )
@@ -39,6 +41,8 @@ type HealthCheck struct {
 	healthCheckStopPolicy *sdkutil.StopPolicy
 	healthJob             *scheduler.Job
 	service               ssm.Service
+	// reportedRoleChange is the time of the last change of the role of the instance reported
+	reportedRoleChange time.Time
 }
 
 const (
@@ -97,6 +101,21 @@ func (h *HealthCheck) updateHealth() {
 	if _, err = h.service.UpdateInstanceInformation(log, version.Version, "Active", AgentName); err != nil {
 		sdkutil.HandleAwsError(log, err, h.healthCheckStopPolicy)
 	}
+
+	// report the apis throttled recently and how much the non-critical schedules are stretched because of them
+	if stats := throttle.FormatAPIThrottlingStats(throttle.APIThrottlingStats()); stats != "" {
+		log.Warnf("Throttled api calls in the last five minutes - %v", stats)
+		if factor := throttle.BackoffFactor(h.context.AppConfig().Throttle); factor > 1 {
+			log.Warnf("Inventory and association refresh run %v times less often until the throttling clears", factor)
+		}
+	}
+
+	// report the role of the instance changing since the last report, the credentials were renewed then
+	if change, changed := rolemonitor.LastChange(); changed && change.Time.After(h.reportedRoleChange) {
+		log.Warnf("The role of the instance changed from %v to %v at %v, the agent renewed its credentials",
+			change.From, change.To, change.Time.UTC().Format(time.RFC3339))
+		h.reportedRoleChange = change.Time
+	}
 	return
 }
 
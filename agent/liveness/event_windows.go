@@ -0,0 +1,54 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package liveness
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// eventName is the auto-reset event set at every liveness signal, a watcher waiting on it with a timeout longer than
+// the interval of the signal finds out that the agent is no longer alive
+const eventName = `Global\AmazonSSMAgentLiveness`
+
+// namedEvent is the liveness event of the agent
+type namedEvent interface {
+	set() error
+	close()
+}
+
+type windowsEvent struct {
+	handle windows.Handle
+}
+
+func newNamedEvent() (namedEvent, error) {
+	name, err := windows.UTF16PtrFromString(eventName)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateEvent(nil, 0, 0, name)
+	if err != nil {
+		return nil, err
+	}
+	return &windowsEvent{handle: handle}, nil
+}
+
+func (e *windowsEvent) set() error {
+	return windows.SetEvent(e.handle)
+}
+
+func (e *windowsEvent) close() {
+	windows.CloseHandle(e.handle)
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package liveness gives the liveness signal of the agent to the service managers, and detects the internal loops of
+// the agent that stopped completing their iterations, such as the MDS poller and the association scheduler. A stuck
+// loop is restarted on its own first, and once its restarts do not help the signal is withheld and the agent is
+// reported unhealthy, so that it is restarted as a whole.
+package liveness
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// loop is a registered internal loop of the agent
+type loop struct {
+	// period is the longest time between two iterations of a healthy loop
+	period   time.Duration
+	restart  func()
+	lastBeat time.Time
+	restarts int
+}
+
+var (
+	lock          sync.Mutex
+	loops         = map[string]*loop{}
+	monitor       *livenessMonitor
+	unhealthy     = make(chan struct{})
+	unhealthyOnce sync.Once
+)
+
+// Start begins giving the liveness signal and checking the loops when it is enabled by the configuration. The
+// systemd watchdog is pinged whenever the unit of the agent enables it.
+func Start(log log.T, config appconfig.LivenessCfg) {
+	watchdog := newSystemdWatchdog()
+	if !config.Enabled && watchdog == nil {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if monitor != nil {
+		return
+	}
+	monitor = newLivenessMonitor(log, config, watchdog)
+	if config.Enabled {
+		log.Infof("Giving the liveness signal every %v and restarting the loops stuck for %v minutes", monitor.interval, config.StuckLoopMinutes)
+	}
+	if watchdog != nil {
+		log.Infof("Pinging the systemd watchdog every %v", monitor.interval)
+	}
+	go monitor.run()
+}
+
+// Stop stops the liveness signal
+func Stop() {
+	lock.Lock()
+	current := monitor
+	monitor = nil
+	lock.Unlock()
+	if current != nil {
+		current.stop()
+	}
+}
+
+// Unhealthy returns a channel closed once a stuck loop could not be recovered, the agent exits with a failure then
+func Unhealthy() <-chan struct{} {
+	return unhealthy
+}
+
+// Register starts watching a loop, which is stuck once it does not complete an iteration within its period and the
+// stuck loop minutes of the configuration. A stuck loop is restarted by calling restart.
+func Register(name string, period time.Duration, restart func()) {
+	lock.Lock()
+	defer lock.Unlock()
+	loops[name] = &loop{period: period, restart: restart, lastBeat: time.Now()}
+}
+
+// Unregister stops watching a loop, such as a loop of a stopped core module
+func Unregister(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(loops, name)
+}
+
+// Beat records that a loop completed an iteration
+func Beat(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if l, found := loops[name]; found {
+		l.lastBeat = time.Now()
+		l.restarts = 0
+	}
+}
+
+// livenessMonitor checks the loops and gives the liveness signal while they are healthy
+type livenessMonitor struct {
+	log      log.T
+	config   appconfig.LivenessCfg
+	interval time.Duration
+	watchdog *systemdWatchdog
+	event    namedEvent
+	healthy  bool
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newLivenessMonitor(log log.T, config appconfig.LivenessCfg, watchdog *systemdWatchdog) *livenessMonitor {
+	m := &livenessMonitor{
+		log:      log,
+		config:   config,
+		interval: time.Duration(config.IntervalSeconds) * time.Second,
+		watchdog: watchdog,
+		healthy:  true,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if watchdog != nil && (watchdog.interval < m.interval || m.interval <= 0) {
+		m.interval = watchdog.interval
+	}
+	if config.Enabled {
+		var err error
+		if m.event, err = newNamedEvent(); err != nil {
+			log.Warnf("The liveness event can not be created: %v", err)
+		}
+	}
+	return m
+}
+
+// run gives the signal right away, then at every interval until the monitor is stopped
+func (m *livenessMonitor) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		if m.healthy = m.healthy && m.check(time.Now()); m.healthy {
+			m.signal()
+		}
+		select {
+		case <-ticker.C:
+		case <-m.stopChan:
+			if m.event != nil {
+				m.event.close()
+			}
+			return
+		}
+	}
+}
+
+func (m *livenessMonitor) stop() {
+	m.stopOnce.Do(func() { close(m.stopChan) })
+	<-m.done
+}
+
+// check restarts the stuck loops, and returns false once a loop is still stuck after its restarts
+func (m *livenessMonitor) check(now time.Time) bool {
+	if !m.config.Enabled {
+		return true
+	}
+	stuckAfter := time.Duration(m.config.StuckLoopMinutes) * time.Minute
+	var restarts []func()
+	healthy := true
+	lock.Lock()
+	for name, l := range loops {
+		silence := now.Sub(l.lastBeat)
+		if silence <= l.period+stuckAfter {
+			continue
+		}
+		if l.restarts >= m.config.MaxLoopRestarts {
+			m.log.Errorf("%v did not complete an iteration for %v after %v restarts, the agent is unhealthy", name, silence.Round(time.Second), l.restarts)
+			healthy = false
+			continue
+		}
+		l.restarts++
+		l.lastBeat = now
+		m.log.Warnf("%v did not complete an iteration for %v, restarting it (%v/%v)", name, silence.Round(time.Second), l.restarts, m.config.MaxLoopRestarts)
+		restarts = append(restarts, l.restart)
+	}
+	lock.Unlock()
+	for _, restart := range restarts {
+		go restart()
+	}
+	if !healthy {
+		unhealthyOnce.Do(func() { close(unhealthy) })
+	}
+	return healthy
+}
+
+// signal updates the heartbeat file, sets the named event and pings the systemd watchdog
+func (m *livenessMonitor) signal() {
+	if m.config.Enabled {
+		if err := writeHeartbeat(m.config.HeartbeatFile, time.Now()); err != nil {
+			m.log.Warnf("Failed to update the heartbeat file %v: %v", m.config.HeartbeatFile, err)
+		}
+		if m.event != nil {
+			if err := m.event.set(); err != nil {
+				m.log.Warnf("Failed to set the liveness event: %v", err)
+			}
+		}
+	}
+	if m.watchdog != nil {
+		if err := m.watchdog.ping(); err != nil {
+			m.log.Warnf("Failed to ping the systemd watchdog: %v", err)
+		}
+	}
+}
+
+// writeHeartbeat replaces the heartbeat file with the time of the signal, so that its content and its modification
+// time tell when the agent was last alive
+func writeHeartbeat(path string, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	content := fmt.Sprintf("%v\n", now.UTC().Format(time.RFC3339))
+	if err := ioutil.WriteFile(tmp, []byte(content), appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
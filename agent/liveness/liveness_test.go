@@ -0,0 +1,136 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package liveness
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// resetState clears the registered loops and the unhealthy state between tests
+func resetState() {
+	lock.Lock()
+	defer lock.Unlock()
+	loops = map[string]*loop{}
+	unhealthy = make(chan struct{})
+	unhealthyOnce = sync.Once{}
+}
+
+func testConfig() appconfig.LivenessCfg {
+	return appconfig.LivenessCfg{
+		Enabled:          true,
+		IntervalSeconds:  appconfig.DefaultLivenessIntervalSeconds,
+		StuckLoopMinutes: 5,
+		MaxLoopRestarts:  1,
+	}
+}
+
+func TestCheckRestartsStuckLoop(t *testing.T) {
+	resetState()
+	defer resetState()
+	restarted := make(chan struct{}, 2)
+	Register("poller", time.Minute, func() { restarted <- struct{}{} })
+	m := newLivenessMonitor(log.NewMockLog(), testConfig(), nil)
+
+	now := time.Now()
+	assert.True(t, m.check(now.Add(5*time.Minute)))
+	assert.Len(t, restarted, 0)
+
+	assert.True(t, m.check(now.Add(7*time.Minute)))
+	select {
+	case <-restarted:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the stuck loop was not restarted")
+	}
+
+	// the loop is still stuck after its restart
+	assert.False(t, m.check(now.Add(14*time.Minute)))
+	select {
+	case <-Unhealthy():
+	default:
+		assert.Fail(t, "the agent was not reported unhealthy")
+	}
+}
+
+func TestBeatResetsRestarts(t *testing.T) {
+	resetState()
+	defer resetState()
+	Register("poller", time.Minute, func() {})
+	m := newLivenessMonitor(log.NewMockLog(), testConfig(), nil)
+
+	assert.True(t, m.check(time.Now().Add(7*time.Minute)))
+	Beat("poller")
+	assert.Equal(t, 0, loops["poller"].restarts)
+	assert.True(t, m.check(time.Now().Add(5*time.Minute)))
+
+	Unregister("poller")
+	assert.True(t, m.check(time.Now().Add(time.Hour)))
+}
+
+func TestWriteHeartbeat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "liveness")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data", appconfig.LivenessHeartbeatFileName)
+
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	assert.NoError(t, writeHeartbeat(path, now))
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-01T10:00:00Z", strings.TrimSpace(string(content)))
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSystemdWatchdogPing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "liveness")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets are not available: %v", err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	os.Setenv("WATCHDOG_USEC", "20000000")
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	defer os.Unsetenv("WATCHDOG_USEC")
+
+	watchdog := newSystemdWatchdog()
+	assert.NotNil(t, watchdog)
+	assert.Equal(t, 10*time.Second, watchdog.interval)
+	assert.NoError(t, watchdog.ping())
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "WATCHDOG=1", string(buf[:n]))
+
+	os.Setenv("WATCHDOG_PID", "1")
+	defer os.Unsetenv("WATCHDOG_PID")
+	assert.Nil(t, newSystemdWatchdog())
+}
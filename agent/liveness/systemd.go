@@ -0,0 +1,62 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package liveness
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemdWatchdog pings the watchdog of the systemd unit of the agent through the notification socket
+type systemdWatchdog struct {
+	socket   string
+	interval time.Duration
+}
+
+// newSystemdWatchdog returns the watchdog of the unit of the agent, nil when the unit does not enable it.
+// The watchdog is pinged twice within its timeout.
+func newSystemdWatchdog() *systemdWatchdog {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if socket == "" || err != nil || usec <= 0 {
+		return nil
+	}
+	// the watchdog is meant for another process, such as the parent of the agent
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		// abstract socket
+		socket = "\x00" + socket[1:]
+	}
+	return &systemdWatchdog{socket: socket, interval: time.Duration(usec) * time.Microsecond / 2}
+}
+
+func (w *systemdWatchdog) ping() error {
+	return notify(w.socket, "WATCHDOG=1")
+}
+
+// notify sends a state to the notification socket of systemd
+func notify(socket string, state string) error {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
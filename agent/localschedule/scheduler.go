@@ -0,0 +1,163 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localschedule
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/carlescere/scheduler"
+)
+
+const (
+	name = "LocalScheduler"
+
+	// pollFrequencySeconds is how often registered schedules are reloaded and checked,
+	// cron expressions have a granularity of one minute
+	pollFrequencySeconds = 30
+)
+
+var now = time.Now
+
+// scheduledRun tracks the next run of a schedule for the expression it was computed from.
+type scheduledRun struct {
+	scheduleExpression string
+	next               time.Time
+}
+
+// LocalScheduler is a core module that submits locally scheduled documents to the offline command service when they are due.
+type LocalScheduler struct {
+	context     context.T
+	scheduleDir string
+	commandDir  string
+	job         *scheduler.Job
+	nextRuns    map[string]scheduledRun
+}
+
+// NewLocalScheduler creates a new local scheduler core module.
+func NewLocalScheduler(context context.T) *LocalScheduler {
+	return &LocalScheduler{
+		context:     context.With("[" + name + "]"),
+		scheduleDir: appconfig.LocalScheduleRoot,
+		commandDir:  appconfig.LocalCommandRoot,
+		nextRuns:    make(map[string]scheduledRun),
+	}
+}
+
+// ModuleName returns the module name
+func (s *LocalScheduler) ModuleName() string {
+	return name
+}
+
+// ModuleExecute starts checking the registered schedules
+func (s *LocalScheduler) ModuleExecute(context context.T) (err error) {
+	log := s.context.Log()
+	if err = fileutil.MakeDirs(s.scheduleDir); err != nil {
+		log.Errorf("Failed to create local schedule directory %v: %v", s.scheduleDir, err)
+		return
+	}
+	if s.job, err = scheduler.Every(pollFrequencySeconds).Seconds().Run(s.runDueSchedules); err != nil {
+		log.Errorf("Unable to schedule local scheduler. %v", err)
+	}
+	return
+}
+
+// ModuleRequestStop stops checking the registered schedules
+func (s *LocalScheduler) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	if s.job != nil {
+		s.context.Log().Info("Stopping local scheduler job.")
+		s.job.Quit <- true
+	}
+	return nil
+}
+
+// runDueSchedules reloads the registered schedules and submits the documents of the schedules that are due.
+// Next runs are computed when a schedule is first seen or its expression changes, so runs missed while the agent
+// was stopped are skipped.
+func (s *LocalScheduler) runDueSchedules() {
+	log := s.context.Log()
+	schedules, err := Load(log, s.scheduleDir)
+	if err != nil {
+		log.Errorf("Failed to load local schedules: %v", err)
+		return
+	}
+
+	currentTime := now()
+	registered := make(map[string]bool)
+	for _, schedule := range schedules {
+		registered[schedule.Name] = true
+		expression, err := scheduleexpression.CreateScheduleExpression(log, schedule.ScheduleExpression)
+		if err != nil {
+			continue
+		}
+
+		run, exists := s.nextRuns[schedule.Name]
+		if !exists || run.scheduleExpression != schedule.ScheduleExpression {
+			run = scheduledRun{scheduleExpression: schedule.ScheduleExpression, next: expression.Next(currentTime)}
+			log.Infof("Local schedule %v next runs at %v", schedule.Name, run.next)
+		} else if !currentTime.Before(run.next) {
+			if err = s.submit(schedule, currentTime); err != nil {
+				log.Errorf("Failed to submit local schedule %v: %v", schedule.Name, err)
+			} else {
+				log.Infof("Submitted local schedule %v", schedule.Name)
+			}
+			run.next = expression.Next(currentTime)
+		}
+		s.nextRuns[schedule.Name] = run
+	}
+
+	for scheduleName := range s.nextRuns {
+		if !registered[scheduleName] {
+			log.Infof("Local schedule %v was removed", scheduleName)
+			delete(s.nextRuns, scheduleName)
+		}
+	}
+}
+
+// submit writes the schedule document to the local command directory where the offline command service picks it up.
+// The document is written to a temporary file first so the service never reads a partial document.
+func (s *LocalScheduler) submit(schedule Schedule, runTime time.Time) (err error) {
+	var content string
+	if content, err = jsonutil.Marshal(schedule.Content); err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirs(s.commandDir); err != nil {
+		return err
+	}
+
+	var tempFile *os.File
+	if tempFile, err = ioutil.TempFile(s.scheduleDir, ".submit-"); err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.WriteString(content)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	documentName := fmt.Sprintf("%v-%v", schedule.Name, runTime.Unix())
+	return os.Rename(tempFile.Name(), filepath.Join(s.commandDir, documentName))
+}
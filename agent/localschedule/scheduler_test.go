@@ -0,0 +1,68 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localschedule
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunDueSchedules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localschedule")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func() { now = time.Now }()
+
+	s := &LocalScheduler{
+		context:     context.NewMockDefault(),
+		scheduleDir: filepath.Join(dir, "schedules"),
+		commandDir:  filepath.Join(dir, "commands"),
+		nextRuns:    make(map[string]scheduledRun),
+	}
+	assert.NoError(t, Save(log.NewMockLog(), s.scheduleDir, testSchedule("hourly", "rate(1 hour)")))
+
+	start := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+	s.runDueSchedules()
+	assert.Equal(t, start.Add(time.Hour), s.nextRuns["hourly"].next)
+	submitted, _ := ioutil.ReadDir(s.commandDir)
+	assert.Empty(t, submitted)
+
+	now = func() time.Time { return start.Add(61 * time.Minute) }
+	s.runDueSchedules()
+	s.runDueSchedules()
+	submitted, err = ioutil.ReadDir(s.commandDir)
+	assert.NoError(t, err)
+	assert.Len(t, submitted, 1)
+	var content contracts.DocumentContent
+	assert.NoError(t, jsonutil.UnmarshalFile(filepath.Join(s.commandDir, submitted[0].Name()), &content))
+	assert.Equal(t, "2.0", content.SchemaVersion)
+	assert.Equal(t, start.Add(121*time.Minute), s.nextRuns["hourly"].next)
+
+	remaining, _ := ioutil.ReadDir(s.scheduleDir)
+	assert.Len(t, remaining, 1)
+
+	assert.NoError(t, Delete(s.scheduleDir, "hourly"))
+	s.runDueSchedules()
+	assert.Empty(t, s.nextRuns)
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package localschedule runs documents on schedules registered locally on the instance,
+// without an association, so they keep running while the instance has no connectivity.
+package localschedule
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/association/scheduleexpression"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const scheduleFileExtension = ".json"
+
+var scheduleNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_\-.]{1,128}$`)
+
+// Schedule is a document registered to run locally on a cron or rate expression.
+type Schedule struct {
+	Name               string                    `json:"name"`
+	ScheduleExpression string                    `json:"scheduleExpression"`
+	Content            contracts.DocumentContent `json:"content"`
+}
+
+// Validate checks the schedule has a valid name, schedule expression and document content.
+func (s Schedule) Validate(log log.T) error {
+	if !scheduleNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("schedule name %v is invalid, it must be 1 to 128 letters, digits, '_', '-' or '.'", s.Name)
+	}
+	if _, err := scheduleexpression.CreateScheduleExpression(log, s.ScheduleExpression); err != nil {
+		return err
+	}
+	return ValidateContent(s.Content)
+}
+
+// ValidateContent checks that content has at least one runtimeConfig for 1.2 or mainSteps for 2.0
+func ValidateContent(content contracts.DocumentContent) error {
+	if content.SchemaVersion == "1.2" {
+		if len(content.RuntimeConfig) == 0 {
+			return fmt.Errorf("runtimeConfig cannot be empty")
+		}
+	} else if content.SchemaVersion == "2.0" {
+		if len(content.MainSteps) == 0 {
+			return fmt.Errorf("mainSteps cannot be empty")
+		}
+	} else {
+		return fmt.Errorf("unsupported schema version %v", content.SchemaVersion)
+	}
+	return nil
+}
+
+// Save validates the schedule and persists it in the schedule directory, replacing any schedule with the same name.
+func Save(log log.T, scheduleDir string, schedule Schedule) (err error) {
+	if err = schedule.Validate(log); err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirs(scheduleDir); err != nil {
+		return fmt.Errorf("failed to create schedule directory %v: %v", scheduleDir, err)
+	}
+	var content string
+	if content, err = jsonutil.Marshal(schedule); err != nil {
+		return err
+	}
+	return fileutil.WriteAllText(schedulePath(scheduleDir, schedule.Name), content)
+}
+
+// Delete removes the schedule with the given name from the schedule directory.
+func Delete(scheduleDir string, name string) error {
+	if !scheduleNamePattern.MatchString(name) {
+		return fmt.Errorf("schedule name %v is invalid", name)
+	}
+	path := schedulePath(scheduleDir, name)
+	if !fileutil.Exists(path) {
+		return fmt.Errorf("schedule %v does not exist", name)
+	}
+	return fileutil.DeleteFile(path)
+}
+
+// Load returns the valid schedules persisted in the schedule directory.
+// Schedules that cannot be read or are invalid are logged and skipped.
+func Load(log log.T, scheduleDir string) (schedules []Schedule, err error) {
+	var fileNames []string
+	if fileNames, err = fileutil.GetFileNames(scheduleDir); err != nil {
+		return nil, err
+	}
+	for _, fileName := range fileNames {
+		if !strings.HasSuffix(fileName, scheduleFileExtension) {
+			continue
+		}
+		var schedule Schedule
+		if err := jsonutil.UnmarshalFile(filepath.Join(scheduleDir, fileName), &schedule); err != nil {
+			log.Errorf("Failed to read local schedule %v: %v", fileName, err)
+			continue
+		}
+		if err := schedule.Validate(log); err != nil {
+			log.Errorf("Local schedule %v is invalid: %v", fileName, err)
+			continue
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func schedulePath(scheduleDir string, name string) string {
+	return filepath.Join(scheduleDir, name+scheduleFileExtension)
+}
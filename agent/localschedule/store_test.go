@@ -0,0 +1,81 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localschedule
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSchedule(name string, expression string) Schedule {
+	return Schedule{
+		Name:               name,
+		ScheduleExpression: expression,
+		Content: contracts.DocumentContent{
+			SchemaVersion: "2.0",
+			MainSteps: []*contracts.InstancePluginConfig{
+				{Action: "aws:runShellScript", Name: "run", Inputs: map[string]interface{}{"runCommand": []string{"echo"}}},
+			},
+		},
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	logger := log.NewMockLog()
+	assert.NoError(t, testSchedule("nightly", "cron(0 2 * * ? *)").Validate(logger))
+	assert.NoError(t, testSchedule("every-30.min_x", "rate(30 minutes)").Validate(logger))
+	assert.Error(t, testSchedule("../escape", "rate(30 minutes)").Validate(logger))
+	assert.Error(t, testSchedule("", "rate(30 minutes)").Validate(logger))
+	assert.Error(t, testSchedule("nightly", "at 2am").Validate(logger))
+
+	schedule := testSchedule("nightly", "rate(30 minutes)")
+	schedule.Content.SchemaVersion = "1.2"
+	assert.Error(t, schedule.Validate(logger))
+}
+
+func TestSaveLoadDelete(t *testing.T) {
+	logger := log.NewMockLog()
+	dir, err := ioutil.TempDir("", "localschedule")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	schedules, err := Load(logger, filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	assert.NoError(t, Save(logger, dir, testSchedule("b", "rate(1 hour)")))
+	assert.NoError(t, Save(logger, dir, testSchedule("a", "rate(30 minutes)")))
+	assert.NoError(t, Save(logger, dir, testSchedule("a", "rate(10 minutes)")))
+	assert.Error(t, Save(logger, dir, testSchedule("c", "rate(0 minutes)")))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "corrupt.json"), []byte("{"), 0600))
+
+	schedules, err = Load(logger, dir)
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 2)
+	assert.Equal(t, "a", schedules[0].Name)
+	assert.Equal(t, "rate(10 minutes)", schedules[0].ScheduleExpression)
+	assert.Equal(t, "b", schedules[1].Name)
+
+	assert.NoError(t, Delete(dir, "a"))
+	assert.Error(t, Delete(dir, "a"))
+	schedules, err = Load(logger, dir)
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 1)
+}
@@ -16,7 +16,7 @@ package ssmlog
 
 import (
 	"fmt"
-
+	"regexp"
 	"sync"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -33,6 +33,12 @@ var pkgMutex = new(sync.Mutex)
 // loggerInstance is the delegate logger in the wrapper
 var loggerInstance = &log.DelegateLogger{}
 
+// minLevelOverride replaces the minimum log level of the seelog configurations when set
+var minLevelOverride string
+
+var seelogRoot = regexp.MustCompile(`<seelog\b[^>]*?/?>`)
+var seelogLevelAttributes = regexp.MustCompile(`\s+(minlevel|maxlevel|levels)="[^"]*"`)
+
 func SSMLogger(useWatcher bool) log.T {
 	if !isLoaded() {
 		logger := initLogger(useWatcher)
@@ -44,7 +50,7 @@ func SSMLogger(useWatcher bool) log.T {
 // initLogger initializes a new logger based on current configurations and starts file watcher on the configurations file
 func initLogger(useWatcher bool) (logger log.T) {
 	// Read the current configurations or get the default configurations
-	logConfigBytes := log.GetLogConfigBytes()
+	logConfigBytes := withLogConfig(withMinLevel(log.GetLogConfigBytes(), minLevelOverride), logConfig())
 	// Initialize the base seelog logger
 	baseLogger, _ := initBaseLoggerFromBytes(logConfigBytes)
 	// Create the wrapper logger
@@ -135,7 +141,7 @@ func replaceLogger() {
 	logger := getCached()
 
 	//Create new logger
-	logConfigBytes := log.GetLogConfigBytes()
+	logConfigBytes := withLogConfig(withMinLevel(log.GetLogConfigBytes(), minLevelOverride), logConfig())
 	baseLogger, err := initBaseLoggerFromBytes(logConfigBytes)
 
 	// If err in creating logger, do not replace logger
@@ -158,6 +164,33 @@ func replaceLogger() {
 	wrapper.ReplaceDelegate(baseLogger)
 }
 
+// SetLogLevel overrides the minimum level of the seelog configurations and replaces the current logger
+func SetLogLevel(level string) error {
+	if _, ok := seelog.LogLevelFromString(level); !ok {
+		return fmt.Errorf("invalid log level %v", level)
+	}
+	minLevelOverride = level
+	if isLoaded() {
+		replaceLogger()
+	}
+	return nil
+}
+
+// withMinLevel sets the minlevel attribute of the seelog root element, leaving the configurations unchanged if level is empty
+func withMinLevel(seelogConfig []byte, level string) []byte {
+	loc := seelogRoot.FindIndex(seelogConfig)
+	if level == "" || loc == nil {
+		return seelogConfig
+	}
+	root := seelogLevelAttributes.ReplaceAll(seelogConfig[loc[0]:loc[1]], nil)
+	root = append([]byte(fmt.Sprintf("<seelog minlevel=\"%v\"", level)), root[len("<seelog"):]...)
+
+	var result []byte
+	result = append(result, seelogConfig[:loc[0]]...)
+	result = append(result, root...)
+	return append(result, seelogConfig[loc[1]:]...)
+}
+
 // initLoggerFromBytes creates a new wrapper logger from configurations passed
 func initLoggerFromBytes(seelogConfig []byte) log.T {
 	logger, _ := initBaseLoggerFromBytes(seelogConfig)
@@ -163,3 +163,22 @@ func TestReplaceLogger(t *testing.T) {
 	assert.Equal(t, newOutput, out.String())
 
 }
+
+func TestWithMinLevel(t *testing.T) {
+	config := []byte(`<seelog type="sync" minlevel="info"><exception filepattern="test*" minlevel="error"/></seelog>`)
+
+	assert.Equal(t, config, withMinLevel(config, ""))
+	assert.Equal(t,
+		`<seelog minlevel="debug" type="sync"><exception filepattern="test*" minlevel="error"/></seelog>`,
+		string(withMinLevel(config, "debug")))
+	assert.Equal(t,
+		`<?xml version="1.0"?><seelog minlevel="warn" type="sync"></seelog>`,
+		string(withMinLevel([]byte(`<?xml version="1.0"?><seelog levels="info,error" type="sync"></seelog>`), "warn")))
+
+	_, err := seelog.LoggerFromConfigAsBytes(withMinLevel(log.DefaultConfig(), "debug"))
+	assert.NoError(t, err)
+}
+
+func TestSetLogLevelInvalid(t *testing.T) {
+	assert.Error(t, SetLogLevel("verbose"))
+}
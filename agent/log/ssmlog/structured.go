@@ -0,0 +1,150 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssmlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/cihub/seelog"
+)
+
+// jsonFormatterName is the seelog formatter writing a log entry as a json object
+const jsonFormatterName = "SsmJson"
+
+// agentSourceDir is the directory of the agent sources the subsystem of a log entry is relative to
+const agentSourceDir = "/agent/"
+
+var contextField = regexp.MustCompile(`^\[(\w+)=(.*)\]$`)
+var subsystemName = regexp.MustCompile(`^[A-Za-z0-9_./]+$`)
+var seelogFormatAttribute = regexp.MustCompile(`(<format\b[^>]*?\sformat=")[^"]*(")`)
+var seelogExceptions = regexp.MustCompile(`<exceptions\s*>`)
+
+func init() {
+	seelog.RegisterCustomFormatter(jsonFormatterName, func(param string) seelog.FormatterFunc {
+		return formatJson
+	})
+}
+
+// formatJson writes a log entry as a json object. The fields of the logger context, for example [documentID=...]
+// and [pluginID=...], are written as fields of the object and the other context entries are written as a list.
+func formatJson(message string, level seelog.LogLevel, context seelog.LogContextInterface) interface{} {
+	entry := map[string]interface{}{
+		"level":   level.String(),
+		"message": message,
+	}
+	if context.IsValid() {
+		entry["time"] = context.CallTime().UTC().Format(time.RFC3339Nano)
+		entry["file"] = fmt.Sprintf("%v:%v", context.FileName(), context.Line())
+		entry["func"] = context.Func()
+		if subsystem := subsystemOf(context.FullPath()); subsystem != "" {
+			entry["subsystem"] = subsystem
+		}
+		if logContext, ok := context.CustomContext().([]string); ok && len(logContext) > 0 {
+			entry["message"] = strings.TrimPrefix(message, strings.Join(logContext, " ")+" ")
+			var others []string
+			for _, item := range logContext {
+				if field := contextField.FindStringSubmatch(item); field != nil {
+					entry[field[1]] = field[2]
+				} else {
+					others = append(others, item)
+				}
+			}
+			if len(others) > 0 {
+				entry["context"] = others
+			}
+		}
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return message
+	}
+	return string(encoded)
+}
+
+// subsystemOf returns the directory of a source file relative to the agent sources
+func subsystemOf(filePath string) string {
+	filePath = strings.Replace(filePath, "\\", "/", -1)
+	index := strings.Index(filePath, agentSourceDir)
+	if index < 0 {
+		return ""
+	}
+	dir := path.Dir(filePath[index+len(agentSourceDir):])
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// logConfig returns the log settings of the agent configurations
+func logConfig() appconfig.LogCfg {
+	config, _ := appconfig.Config(false)
+	return config.Log
+}
+
+// withLogConfig applies the format and the subsystem levels of the agent configurations to the seelog configurations
+func withLogConfig(seelogConfig []byte, config appconfig.LogCfg) []byte {
+	if config.Format == appconfig.LogFormatJson {
+		seelogConfig = seelogFormatAttribute.ReplaceAll(seelogConfig, []byte("${1}%"+jsonFormatterName+"%n${2}"))
+	}
+	return withSubsystemLevels(seelogConfig, config.SubsystemLevels)
+}
+
+// withSubsystemLevels adds a seelog exception for the sources of every subsystem. Seelog applies the first exception
+// matching a source file, so the exceptions go before the ones of the configurations and the nested subsystems go
+// before their parents.
+func withSubsystemLevels(seelogConfig []byte, levels map[string]string) []byte {
+	var subsystems []string
+	for subsystem, level := range levels {
+		if _, ok := seelog.LogLevelFromString(level); !ok || !subsystemName.MatchString(subsystem) {
+			fmt.Printf("Ignoring the log level %v of the subsystem %v\n", level, subsystem)
+			continue
+		}
+		subsystems = append(subsystems, subsystem)
+	}
+	if len(subsystems) == 0 {
+		return seelogConfig
+	}
+	sort.Slice(subsystems, func(i, j int) bool {
+		if len(subsystems[i]) != len(subsystems[j]) {
+			return len(subsystems[i]) > len(subsystems[j])
+		}
+		return subsystems[i] < subsystems[j]
+	})
+
+	var exceptions string
+	for _, subsystem := range subsystems {
+		exceptions += fmt.Sprintf("\n        <exception filepattern=\"*%v%v/*\" minlevel=\"%v\"/>",
+			agentSourceDir, subsystem, levels[subsystem])
+	}
+
+	var loc []int
+	if loc = seelogExceptions.FindIndex(seelogConfig); loc == nil {
+		if loc = seelogRoot.FindIndex(seelogConfig); loc == nil {
+			return seelogConfig
+		}
+		exceptions = "\n    <exceptions>" + exceptions + "\n    </exceptions>"
+	}
+
+	var result []byte
+	result = append(result, seelogConfig[:loc[1]]...)
+	result = append(result, exceptions...)
+	return append(result, seelogConfig[loc[1]:]...)
+}
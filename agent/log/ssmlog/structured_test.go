@@ -0,0 +1,108 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssmlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonFormat(t *testing.T) {
+	var out bytes.Buffer
+	seelogger, err := seelog.LoggerFromWriterWithMinLevelAndFormat(&out, seelog.TraceLvl, "%"+jsonFormatterName+"%n")
+	assert.NoError(t, err)
+
+	logger := withContext(seelogger, "[documentID=doc-1]", "[pluginID=step1]", "[Worker]")
+	logger.Infof("started %v", "step1")
+	logger.Flush()
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "started step1", entry["message"])
+	assert.Equal(t, "doc-1", entry["documentID"])
+	assert.Equal(t, "step1", entry["pluginID"])
+	assert.Equal(t, []interface{}{"[Worker]"}, entry["context"])
+	assert.Equal(t, "log/ssmlog", entry["subsystem"])
+	assert.True(t, strings.HasPrefix(entry["file"].(string), "structured_test.go:"))
+	assert.Contains(t, entry["func"], "TestJsonFormat")
+
+	out.Reset()
+	logger = withContext(seelogger)
+	logger.Debug("no context")
+	logger.Flush()
+
+	entry = nil
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "no context", entry["message"])
+	assert.NotContains(t, entry, "context")
+	assert.NotContains(t, entry, "documentID")
+}
+
+func TestSubsystemOf(t *testing.T) {
+	assert.Equal(t, "plugins/configurepackage", subsystemOf("/src/github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/configurepackage.go"))
+	assert.Equal(t, "runcommand/mds", subsystemOf(`C:\src\amazon-ssm-agent\agent\runcommand\mds\service.go`))
+	assert.Equal(t, "", subsystemOf("/src/github.com/aws/amazon-ssm-agent/agent/main.go"))
+	assert.Equal(t, "", subsystemOf("/usr/local/go/src/runtime/proc.go"))
+}
+
+func TestWithLogConfig(t *testing.T) {
+	config := withLogConfig(log.DefaultConfig(), appconfig.LogCfg{
+		Format: appconfig.LogFormatJson,
+		SubsystemLevels: map[string]string{
+			"plugins":                  "warn",
+			"plugins/configurepackage": "debug",
+			"runcommand":               "loud",
+			"plugins/../*":             "debug",
+		},
+	})
+
+	configurepackage := strings.Index(string(config), `<exception filepattern="*/agent/plugins/configurepackage/*" minlevel="debug"/>`)
+	plugins := strings.Index(string(config), `<exception filepattern="*/agent/plugins/*" minlevel="warn"/>`)
+	test := strings.Index(string(config), `<exception filepattern="test*" minlevel="error"/>`)
+	assert.True(t, configurepackage > 0)
+	assert.True(t, plugins > configurepackage)
+	assert.True(t, test > plugins)
+	assert.NotContains(t, string(config), "runcommand")
+	assert.NotContains(t, string(config), "%Date")
+	assert.Equal(t, 3, strings.Count(string(config), `format="%SsmJson%n"`))
+
+	_, err := seelog.LoggerFromConfigAsBytes(config)
+	assert.NoError(t, err)
+}
+
+func TestWithLogConfigWithoutExceptions(t *testing.T) {
+	config := []byte(`<seelog type="sync" minlevel="info"><outputs><console/></outputs></seelog>`)
+
+	assert.Equal(t, config, withLogConfig(config, appconfig.LogCfg{Format: appconfig.LogFormatText}))
+
+	config = withLogConfig(config, appconfig.LogCfg{
+		Format:          appconfig.LogFormatText,
+		SubsystemLevels: map[string]string{"runcommand/mds": "error"},
+	})
+	assert.Equal(t, `<seelog type="sync" minlevel="info">
+    <exceptions>
+        <exception filepattern="*/agent/runcommand/mds/*" minlevel="error"/>
+    </exceptions><outputs><console/></outputs></seelog>`, string(config))
+
+	_, err := seelog.LoggerFromConfigAsBytes(config)
+	assert.NoError(t, err)
+}
@@ -46,6 +46,22 @@ func (w *Wrapper) WithContext(context ...string) (contextLogger T) {
 	return contextLogger
 }
 
+// contextSetter is implemented by the loggers that keep the context of a log entry apart from its message.
+type contextSetter interface {
+	SetContext(context interface{})
+}
+
+// setContext passes the context of the wrapper to the delegate logger, the delegate logger must be locked.
+func (w *Wrapper) setContext() {
+	if setter, ok := w.Delegate.BaseLoggerInstance.(contextSetter); ok {
+		if filter, ok := w.Format.(*ContextFormatFilter); ok {
+			setter.SetContext(filter.Context)
+		} else {
+			setter.SetContext(nil)
+		}
+	}
+}
+
 // Tracef formats message according to format specifier
 // and writes to log with level = Trace.
 func (w *Wrapper) Tracef(format string, params ...interface{}) {
@@ -53,6 +69,7 @@ func (w *Wrapper) Tracef(format string, params ...interface{}) {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	w.Delegate.BaseLoggerInstance.Tracef(format, params...)
 }
 
@@ -63,6 +80,7 @@ func (w *Wrapper) Debugf(format string, params ...interface{}) {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	w.Delegate.BaseLoggerInstance.Debugf(format, params...)
 }
 
@@ -73,6 +91,7 @@ func (w *Wrapper) Infof(format string, params ...interface{}) {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	w.Delegate.BaseLoggerInstance.Infof(format, params...)
 }
 
@@ -83,6 +102,7 @@ func (w *Wrapper) Warnf(format string, params ...interface{}) error {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	return w.Delegate.BaseLoggerInstance.Warnf(format, params...)
 }
 
@@ -93,6 +113,7 @@ func (w *Wrapper) Errorf(format string, params ...interface{}) error {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	return w.Delegate.BaseLoggerInstance.Errorf(format, params...)
 }
 
@@ -103,6 +124,7 @@ func (w *Wrapper) Criticalf(format string, params ...interface{}) error {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	return w.Delegate.BaseLoggerInstance.Criticalf(format, params...)
 }
 
@@ -112,6 +134,7 @@ func (w *Wrapper) Trace(v ...interface{}) {
 	v = w.Format.Filter(v...)
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	w.Delegate.BaseLoggerInstance.Trace(v...)
 }
 
@@ -122,6 +145,7 @@ func (w *Wrapper) Debug(v ...interface{}) {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	w.Delegate.BaseLoggerInstance.Debug(v...)
 }
 
@@ -132,6 +156,7 @@ func (w *Wrapper) Info(v ...interface{}) {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	w.Delegate.BaseLoggerInstance.Info(v...)
 }
 
@@ -142,6 +167,7 @@ func (w *Wrapper) Warn(v ...interface{}) error {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	return w.Delegate.BaseLoggerInstance.Warn(v...)
 }
 
@@ -152,6 +178,7 @@ func (w *Wrapper) Error(v ...interface{}) error {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	return w.Delegate.BaseLoggerInstance.Error(v...)
 }
 
@@ -162,6 +189,7 @@ func (w *Wrapper) Critical(v ...interface{}) error {
 
 	w.M.Lock()
 	defer w.M.Unlock()
+	w.setContext()
 	return w.Delegate.BaseLoggerInstance.Critical(v...)
 }
 
@@ -3,7 +3,6 @@ package manager
 import (
 	"fmt"
 
-	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
@@ -11,8 +10,6 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
 
-var lrpName = appconfig.PluginNameCloudWatch
-
 func CreateResult(msg string, status contracts.ResultStatus, res *contracts.PluginResult) {
 	res.Output = msg
 
@@ -32,6 +29,7 @@ func Invoke(log logger.T, pluginID string, res *contracts.PluginResult, orchestr
 	var lrpm T
 	var err error
 	var startType = res.StandardOutput
+	var lrpName = res.PluginName
 	var property string
 	jsonutil.Remarshal(res.Output, &property)
 	res.StandardOutput = ""
@@ -51,7 +49,7 @@ func Invoke(log logger.T, pluginID string, res *contracts.PluginResult, orchestr
 	//check if plugin is enabled or not - which would be stored in settings
 	switch startType {
 	case "Enabled":
-		enablePlugin(log, orchestrationDir, pluginID, lrpm, cancelFlag, property, res)
+		enablePlugin(log, orchestrationDir, pluginID, lrpName, lrpm, cancelFlag, property, res)
 
 	case "Disabled":
 		log.Infof("Disabling %s", lrpName)
@@ -75,7 +73,7 @@ func Invoke(log logger.T, pluginID string, res *contracts.PluginResult, orchestr
 	return
 }
 
-func enablePlugin(log logger.T, orchestrationDirectory string, pluginID string, lrpm T, cancelFlag task.CancelFlag, property string, res *contracts.PluginResult) {
+func enablePlugin(log logger.T, orchestrationDirectory string, pluginID string, lrpName string, lrpm T, cancelFlag task.CancelFlag, property string, res *contracts.PluginResult) {
 	log.Infof("Enabling %s", lrpName)
 
 	//loading properties as string since aws:cloudWatch uses properties as string. Properties has new configuration for cloudwatch plugin.
@@ -93,7 +91,7 @@ func enablePlugin(log logger.T, orchestrationDirectory string, pluginID string,
 	}
 	out := iohandler.NewDefaultIOHandler(log, ioConfig)
 	defer out.Close(log)
-	out.Init(log, appconfig.PluginNameCloudWatch)
+	out.Init(log, lrpName)
 
 	//start the plugin with the new configuration
 	if err := lrpm.StartPlugin(lrpName, property, orchestrationDirectory, cancelFlag, out); err != nil {
@@ -114,7 +112,7 @@ func enablePlugin(log logger.T, orchestrationDirectory string, pluginID string,
 				contracts.ResultStatusFailed, res)
 
 		} else {
-			log.Info("Start Cloud Watch successfully.")
+			log.Infof("Started %s successfully.", lrpName)
 			CreateResult("success", contracts.ResultStatusSuccess, res)
 		}
 	}
@@ -0,0 +1,176 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package logtailer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const journalctl = "journalctl"
+
+// journaldRestartDelay is how long the reader waits before running journalctl again after it exited
+var journaldRestartDelay = 10 * time.Second
+
+// journaldReader follows the entries of a systemd unit with journalctl
+type journaldReader struct {
+	log           log.T
+	unit          string
+	fromBeginning bool
+	cursor        string
+	assembler     assembler
+}
+
+// journaldEntry is the part of the journalctl json output the reader uses
+type journaldEntry struct {
+	Cursor            string          `json:"__CURSOR"`
+	RealtimeTimestamp string          `json:"__REALTIME_TIMESTAMP"`
+	Message           json.RawMessage `json:"MESSAGE"`
+}
+
+func newJournaldReader(log log.T, source SourceConfig, saved *position, pattern *regexp.Regexp) (reader, error) {
+	if _, err := exec.LookPath(journalctl); err != nil {
+		return nil, err
+	}
+	r := &journaldReader{
+		log:           log,
+		unit:          source.JournaldUnit,
+		fromBeginning: source.FromBeginning,
+		assembler:     assembler{pattern: pattern},
+	}
+	if saved != nil {
+		r.cursor = saved.Cursor
+	}
+	return r, nil
+}
+
+func (r *journaldReader) run(stop <-chan struct{}, events chan<- event) {
+	for {
+		if !r.follow(stop, events) {
+			return
+		}
+		select {
+		case <-time.After(journaldRestartDelay):
+		case <-stop:
+			return
+		}
+	}
+}
+
+// arguments returns the journalctl arguments following the unit after the last entry sent
+func (r *journaldReader) arguments() []string {
+	arguments := []string{"--unit", r.unit, "--follow", "--output", "json"}
+	switch {
+	case r.cursor != "":
+		arguments = append(arguments, "--after-cursor", r.cursor)
+	case r.fromBeginning:
+		arguments = append(arguments, "--lines", "all")
+	default:
+		arguments = append(arguments, "--lines", "0")
+	}
+	return arguments
+}
+
+// follow sends the entries of the unit until journalctl exits, it returns false once stop is closed
+func (r *journaldReader) follow(stop <-chan struct{}, events chan<- event) bool {
+	command := exec.Command(journalctl, r.arguments()...)
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		r.log.Warnf("Failed to follow the journal of %v: %v", r.unit, err)
+		return true
+	}
+	if err = command.Start(); err != nil {
+		r.log.Warnf("Failed to follow the journal of %v: %v", r.unit, err)
+		return true
+	}
+	defer command.Wait()
+	defer command.Process.Kill()
+
+	lines := make(chan []byte)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, readChunkSize), 4*maxEventSize)
+		for scanner.Scan() {
+			select {
+			case lines <- append([]byte(nil), scanner.Bytes()...):
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, open := <-lines:
+			if !open {
+				r.log.Warnf("journalctl stopped following %v", r.unit)
+				return send(stop, events, r.assembler.flush())
+			}
+			var entry journaldEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				r.log.Debugf("Ignoring the journal entry of %v: %v", r.unit, err)
+				continue
+			}
+			r.cursor = entry.Cursor
+			e := r.assembler.add(entry.message(), entry.timestamp(), position{Cursor: entry.Cursor})
+			if !send(stop, events, e) {
+				return false
+			}
+		case <-ticker.C:
+			if !send(stop, events, r.assembler.expired()) {
+				return false
+			}
+		case <-stop:
+			send(stop, events, r.assembler.flush())
+			return false
+		}
+	}
+}
+
+// message returns the message of the entry, journald writes the messages that are not valid UTF-8 as arrays of bytes
+func (entry journaldEntry) message() string {
+	var text string
+	if err := json.Unmarshal(entry.Message, &text); err == nil {
+		return text
+	}
+	var raw []int
+	if err := json.Unmarshal(entry.Message, &raw); err == nil {
+		message := make([]byte, len(raw))
+		for i, b := range raw {
+			message[i] = byte(b)
+		}
+		return string(message)
+	}
+	return ""
+}
+
+// timestamp returns the time the entry was written, given in microseconds by journald
+func (entry journaldEntry) timestamp() time.Time {
+	microseconds, err := strconv.ParseInt(entry.RealtimeTimestamp, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(0, microseconds*int64(time.Microsecond))
+}
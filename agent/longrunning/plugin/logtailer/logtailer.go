@@ -0,0 +1,221 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package logtailer implements the aws:logTailer long running plugin. It tails files and journald units and streams
+// their lines to CloudWatch Logs, for the instances that need light log shipping without the CloudWatch agent.
+package logtailer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+const (
+	defaultFlushIntervalSeconds = 5
+	minFlushIntervalSeconds     = 1
+	maxFlushIntervalSeconds     = 60
+
+	// stateFileName is the file the positions of the sources are saved in, so the lines are not sent again after a
+	// restart of the agent
+	stateFileName = "positions.json"
+)
+
+// Config is the configuration of the plugin, given as the properties of the aws:logTailer step
+type Config struct {
+	// LogGroupName is the log group of the sources that don't name one
+	LogGroupName string
+	// FlushIntervalSeconds is the longest time a line waits before it is sent
+	FlushIntervalSeconds int
+	Sources              []SourceConfig
+}
+
+// SourceConfig is a file or a journald unit streamed to a log stream
+type SourceConfig struct {
+	FilePath     string
+	JournaldUnit string
+	LogGroupName string
+	// LogStreamName is the id of the instance followed by the name of the file or the unit by default
+	LogStreamName string
+	// MultiLineStartPattern matches the first line of an event, the lines that don't match it are appended to the
+	// event before them, such as the lines of a stack trace. Each line is an event by default.
+	MultiLineStartPattern string
+	// FromBeginning sends the lines written before the source was first tailed, only the new lines are sent by default
+	FromBeginning bool
+}
+
+// logsService is the part of the CloudWatch Logs service the plugin uses
+type logsService interface {
+	CreateLogGroup(log log.T, logGroup string) error
+	CreateLogStream(log log.T, logGroup, logStream string) error
+	GetSequenceTokenForStream(log log.T, logGroupName, logStreamName string) *string
+	PutLogEvents(log log.T, messages []*cloudwatchlogs.InputLogEvent, logGroup, logStream string, sequenceToken *string) (*string, error)
+}
+
+// newLogsService is replaced by the tests
+var newLogsService = func() logsService {
+	return cloudwatchlogspublisher.NewCloudWatchLogsService()
+}
+
+// stateDirectory returns the directory of the state of the plugin, it is replaced by the tests
+var stateDirectory = func() string {
+	instanceID, _ := platform.InstanceID()
+	return filepath.Join(appconfig.DefaultDataStorePath, instanceID, appconfig.LongRunningPluginsLocation, "logtailer")
+}
+
+// Plugin is the aws:logTailer long running plugin
+type Plugin struct {
+	lock    sync.Mutex
+	stop    chan struct{}
+	done    sync.WaitGroup
+	running bool
+	state   *positions
+}
+
+// NewPlugin returns the aws:logTailer plugin
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+// Name returns the plugin name
+func Name() string {
+	return appconfig.PluginNameAwsLogTailer
+}
+
+// IsRunning returns true while the sources are tailed
+func (p *Plugin) IsRunning(context context.T) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.running
+}
+
+// Start tails the sources of the configuration, the sources of a previous configuration are stopped first
+func (p *Plugin) Start(context context.T, configuration string, orchestrationDir string, cancelFlag task.CancelFlag, out iohandler.IOHandler) error {
+	log := context.Log()
+	config, err := parseConfig(configuration)
+	if err != nil {
+		out.AppendErrorf("Invalid %v configuration: %v", Name(), err)
+		return err
+	}
+	p.Stop(context, cancelFlag)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stop = make(chan struct{})
+	p.state = loadPositions(log, filepath.Join(stateDirectory(), stateFileName))
+	instanceID, _ := platform.InstanceID()
+	service := newLogsService()
+	for _, source := range config.Sources {
+		stream := newStream(log, service, p.state, source, config, instanceID)
+		reader, err := newReader(log, source, p.state.get(stream.key))
+		if err != nil {
+			out.AppendErrorf("Failed to tail %v: %v", stream.key, err)
+			continue
+		}
+		log.Infof("Streaming %v to the log stream %v of %v", stream.key, stream.logStream, stream.logGroup)
+		events := make(chan event, eventQueueSize)
+		p.done.Add(2)
+		go func() {
+			defer p.done.Done()
+			defer close(events)
+			reader.run(p.stop, events)
+		}()
+		go func() {
+			defer p.done.Done()
+			stream.publish(p.stop, events)
+		}()
+	}
+	p.running = true
+	out.AppendInfof("Streaming %v sources to CloudWatch Logs", len(config.Sources))
+	return nil
+}
+
+// Stop stops tailing the sources once the lines read so far are sent
+func (p *Plugin) Stop(context context.T, cancelFlag task.CancelFlag) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.running {
+		return nil
+	}
+	close(p.stop)
+	p.done.Wait()
+	p.running = false
+	return p.state.save()
+}
+
+// parseConfig parses and validates the configuration of the plugin
+func parseConfig(configuration string) (config Config, err error) {
+	if err = json.Unmarshal([]byte(configuration), &config); err != nil {
+		return config, err
+	}
+	if len(config.Sources) == 0 {
+		return config, errors.New("no source to tail")
+	}
+	if config.FlushIntervalSeconds == 0 {
+		config.FlushIntervalSeconds = defaultFlushIntervalSeconds
+	} else if config.FlushIntervalSeconds < minFlushIntervalSeconds || config.FlushIntervalSeconds > maxFlushIntervalSeconds {
+		return config, fmt.Errorf("FlushIntervalSeconds %v is not between %v and %v", config.FlushIntervalSeconds, minFlushIntervalSeconds, maxFlushIntervalSeconds)
+	}
+	keys := make(map[string]bool)
+	for i := range config.Sources {
+		source := &config.Sources[i]
+		source.FilePath = strings.TrimSpace(source.FilePath)
+		source.JournaldUnit = strings.TrimSpace(source.JournaldUnit)
+		if (source.FilePath == "") == (source.JournaldUnit == "") {
+			return config, fmt.Errorf("source %v must have either a FilePath or a JournaldUnit", i+1)
+		}
+		if source.FilePath != "" && !filepath.IsAbs(source.FilePath) {
+			return config, fmt.Errorf("FilePath %v is not absolute", source.FilePath)
+		}
+		if strings.TrimSpace(source.LogGroupName) == "" && strings.TrimSpace(config.LogGroupName) == "" {
+			return config, fmt.Errorf("source %v has no LogGroupName", sourceKey(*source))
+		}
+		if source.MultiLineStartPattern != "" {
+			if _, err = regexp.Compile(source.MultiLineStartPattern); err != nil {
+				return config, fmt.Errorf("invalid MultiLineStartPattern of %v: %v", sourceKey(*source), err)
+			}
+		}
+		if keys[sourceKey(*source)] {
+			return config, fmt.Errorf("%v is tailed twice", sourceKey(*source))
+		}
+		keys[sourceKey(*source)] = true
+	}
+	return config, nil
+}
+
+// sourceKey identifies a source in the saved positions
+func sourceKey(source SourceConfig) string {
+	if source.JournaldUnit != "" {
+		return "journald:" + source.JournaldUnit
+	}
+	return source.FilePath
+}
+
+// flushInterval returns the flush interval of the configuration
+func flushInterval(config Config) time.Duration {
+	return time.Duration(config.FlushIntervalSeconds) * time.Second
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logtailer
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLogsService records the events it is given, the first failures calls of PutLogEvents fail
+type fakeLogsService struct {
+	lock     sync.Mutex
+	failures int
+	messages []string
+}
+
+func (s *fakeLogsService) CreateLogGroup(log log.T, logGroup string) error { return nil }
+
+func (s *fakeLogsService) CreateLogStream(log log.T, logGroup, logStream string) error { return nil }
+
+func (s *fakeLogsService) GetSequenceTokenForStream(log log.T, logGroupName, logStreamName string) *string {
+	return nil
+}
+
+func (s *fakeLogsService) PutLogEvents(log log.T, messages []*cloudwatchlogs.InputLogEvent, logGroup, logStream string, sequenceToken *string) (*string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return nil, errors.New("throttled")
+	}
+	for _, message := range messages {
+		s.messages = append(s.messages, *message.Message)
+	}
+	return aws.String("token"), nil
+}
+
+func (s *fakeLogsService) received() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := parseConfig(`{"LogGroupName": "group", "Sources": [{"FilePath": "/var/log/app.log"}, {"JournaldUnit": "sshd"}]}`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, defaultFlushIntervalSeconds, config.FlushIntervalSeconds)
+	assert.Equal(t, "journald:sshd", sourceKey(config.Sources[1]))
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, configuration := range []string{
+		`{"LogGroupName": "group"}`,
+		`{"LogGroupName": "group", "Sources": [{}]}`,
+		`{"LogGroupName": "group", "Sources": [{"FilePath": "/a", "JournaldUnit": "sshd"}]}`,
+		`{"LogGroupName": "group", "Sources": [{"FilePath": "relative.log"}]}`,
+		`{"Sources": [{"FilePath": "/a"}]}`,
+		`{"LogGroupName": "group", "Sources": [{"FilePath": "/a", "MultiLineStartPattern": "("}]}`,
+		`{"LogGroupName": "group", "Sources": [{"FilePath": "/a"}, {"FilePath": "/a"}]}`,
+		`{"LogGroupName": "group", "FlushIntervalSeconds": 600, "Sources": [{"FilePath": "/a"}]}`,
+	} {
+		_, err := parseConfig(configuration)
+		assert.Error(t, err, configuration)
+	}
+}
+
+func TestAssemblerMultiLine(t *testing.T) {
+	a := assembler{pattern: regexp.MustCompile(`^\d{4}-`)}
+	now := time.Now()
+
+	assert.Nil(t, a.add("2024-05-01 error", now, position{Offset: 17}))
+	assert.Nil(t, a.add("  at main.go:10", now, position{Offset: 33}))
+	completed := a.add("2024-05-01 done", now, position{Offset: 49})
+
+	assert.Equal(t, "2024-05-01 error\n  at main.go:10", completed.message)
+	assert.Equal(t, int64(33), completed.position.Offset)
+	assert.Equal(t, "2024-05-01 done", a.flush().message)
+	assert.Nil(t, a.flush())
+}
+
+func TestFileReaderFollowsTruncation(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logtailer")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(path, []byte("old\n"), 0600)
+
+	r := &fileReader{log: log.NewMockLog(), path: path}
+	stop := make(chan struct{})
+	events := make(chan event, eventQueueSize)
+
+	assert.True(t, r.poll(stop, events))
+	assert.Len(t, events, 0, "the lines written before the source is tailed are skipped")
+
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString("first\r\nsecond\nthi")
+	file.Close()
+	assert.True(t, r.poll(stop, events))
+	assert.Equal(t, "first", (<-events).message)
+	second := <-events
+	assert.Equal(t, "second", second.message)
+	assert.Equal(t, int64(len("old\nfirst\r\nsecond\n")), second.position.Offset)
+	assert.Len(t, events, 0, "the partial line waits for its end")
+
+	ioutil.WriteFile(path, []byte("new\n"), 0600)
+	assert.True(t, r.poll(stop, events))
+	assert.Equal(t, "new", (<-events).message)
+	r.close()
+}
+
+func TestFileReaderResumesAtSavedPosition(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logtailer")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(path, []byte("sent\nnot sent\n"), 0600)
+
+	r := &fileReader{log: log.NewMockLog(), path: path, saved: &position{Offset: 5}}
+	events := make(chan event, eventQueueSize)
+
+	assert.True(t, r.poll(make(chan struct{}), events))
+	assert.Equal(t, "not sent", (<-events).message)
+	r.close()
+}
+
+func TestStreamRetriesAndSavesPosition(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "logtailer")
+	defer os.RemoveAll(dir)
+	minRetryDelay = time.Millisecond
+	defer func() { minRetryDelay = time.Second }()
+
+	service := &fakeLogsService{failures: 2}
+	state := loadPositions(log.NewMockLog(), filepath.Join(dir, stateFileName))
+	source := SourceConfig{FilePath: "/var/log/app.log"}
+	s := newStream(log.NewMockLog(), service, state, source, Config{LogGroupName: "group", FlushIntervalSeconds: 1}, "i-1")
+	assert.Equal(t, "i-1/app.log", s.logStream)
+
+	events := make(chan event, 2)
+	events <- event{message: "a", timestamp: time.Now(), position: position{Offset: 2}}
+	events <- event{message: "", timestamp: time.Now(), position: position{Offset: 3}}
+	close(events)
+	s.publish(make(chan struct{}), events)
+
+	assert.Equal(t, []string{"a", " "}, service.received())
+	saved := loadPositions(log.NewMockLog(), filepath.Join(dir, stateFileName)).get(source.FilePath)
+	assert.Equal(t, int64(3), saved.Offset)
+}
+
+func TestInputEventsKeepTimestampsInOrder(t *testing.T) {
+	now := time.Now()
+	s := &stream{batch: []event{{message: "a", timestamp: now}, {message: "b", timestamp: now.Add(-time.Minute)}}}
+
+	inputs := s.inputEvents()
+
+	assert.Equal(t, *inputs[0].Timestamp, *inputs[1].Timestamp)
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logtailer
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+const (
+	// maxBatchEvents and maxBatchSize are the limits of a PutLogEvents call
+	maxBatchEvents = 10000
+	maxBatchSize   = 1024 * 1024
+
+	// eventOverhead is the size CloudWatch Logs counts for each event besides its message
+	eventOverhead = 26
+)
+
+// retry delays of a batch CloudWatch Logs did not accept, the batch is held meanwhile so the sources stop reading once
+// their queue is full
+var (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+// stream sends the events of a source to its log stream
+type stream struct {
+	log       log.T
+	service   logsService
+	state     *positions
+	key       string
+	logGroup  string
+	logStream string
+	interval  time.Duration
+
+	created       bool
+	sequenceToken *string
+	batch         []event
+	batchSize     int
+	lastTimestamp int64
+}
+
+func newStream(log log.T, service logsService, state *positions, source SourceConfig, config Config, instanceID string) *stream {
+	s := &stream{
+		log:       log,
+		service:   service,
+		state:     state,
+		key:       sourceKey(source),
+		logGroup:  strings.TrimSpace(source.LogGroupName),
+		logStream: strings.TrimSpace(source.LogStreamName),
+		interval:  flushInterval(config),
+	}
+	if s.logGroup == "" {
+		s.logGroup = strings.TrimSpace(config.LogGroupName)
+	}
+	if s.logStream == "" {
+		name := source.JournaldUnit
+		if name == "" {
+			name = filepath.Base(source.FilePath)
+		}
+		s.logStream = instanceID + "/" + name
+	}
+	return s
+}
+
+// publish sends the events in batches until the queue is closed
+func (s *stream) publish(stop <-chan struct{}, events <-chan event) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, open := <-events:
+			if !open {
+				s.flush(stop)
+				return
+			}
+			size := len(e.message) + eventOverhead
+			if len(s.batch) >= maxBatchEvents || s.batchSize+size > maxBatchSize {
+				s.flush(stop)
+			}
+			s.batch = append(s.batch, e)
+			s.batchSize += size
+		case <-ticker.C:
+			s.flush(stop)
+		}
+	}
+}
+
+// flush sends the batch, retrying until CloudWatch Logs accepts it or stop is closed, and saves the position of the
+// source after it
+func (s *stream) flush(stop <-chan struct{}) {
+	if len(s.batch) == 0 {
+		return
+	}
+	delay := minRetryDelay
+	for !s.put() {
+		select {
+		case <-stop:
+			s.log.Warnf("Dropping %v events of %v, they are sent again once the plugin restarts", len(s.batch), s.key)
+			s.batch, s.batchSize = nil, 0
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+	s.state.set(s.key, s.batch[len(s.batch)-1].position)
+	if err := s.state.save(); err != nil {
+		s.log.Warnf("Failed to save the position of %v: %v", s.key, err)
+	}
+	s.batch, s.batchSize = nil, 0
+}
+
+// put sends the batch once
+func (s *stream) put() bool {
+	if !s.created {
+		if err := s.service.CreateLogGroup(s.log, s.logGroup); err != nil {
+			s.log.Warnf("Failed to create the log group %v: %v", s.logGroup, err)
+			return false
+		}
+		if err := s.service.CreateLogStream(s.log, s.logGroup, s.logStream); err != nil {
+			s.log.Warnf("Failed to create the log stream %v: %v", s.logStream, err)
+			return false
+		}
+		s.sequenceToken = s.service.GetSequenceTokenForStream(s.log, s.logGroup, s.logStream)
+		s.created = true
+	}
+	token, err := s.service.PutLogEvents(s.log, s.inputEvents(), s.logGroup, s.logStream, s.sequenceToken)
+	if err != nil {
+		s.log.Warnf("Failed to send the events of %v: %v", s.key, err)
+		return false
+	}
+	s.sequenceToken = token
+	return true
+}
+
+// inputEvents returns the events of the batch, CloudWatch Logs rejects the batches whose timestamps go backwards
+func (s *stream) inputEvents() []*cloudwatchlogs.InputLogEvent {
+	inputs := make([]*cloudwatchlogs.InputLogEvent, 0, len(s.batch))
+	for _, e := range s.batch {
+		timestamp := e.timestamp.UnixNano() / int64(time.Millisecond)
+		if timestamp < s.lastTimestamp {
+			timestamp = s.lastTimestamp
+		}
+		s.lastTimestamp = timestamp
+		message := e.message
+		if message == "" {
+			// CloudWatch Logs rejects the empty messages
+			message = " "
+		}
+		inputs = append(inputs, &cloudwatchlogs.InputLogEvent{
+			Message:   aws.String(message),
+			Timestamp: aws.Int64(timestamp),
+		})
+	}
+	return inputs
+}
@@ -0,0 +1,356 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package logtailer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// eventQueueSize is the number of events a source reads ahead of the publisher, the source stops reading when the
+	// queue is full so the lines wait in the files while CloudWatch Logs is slow or unreachable
+	eventQueueSize = 1000
+
+	// maxEventSize is the largest message CloudWatch Logs accepts, once the 26 bytes of overhead of an event are
+	// taken out of its 256 KB limit
+	maxEventSize = 256*1024 - eventOverhead
+
+	readChunkSize = 64 * 1024
+)
+
+// pollInterval is how often the files are checked for new lines
+var pollInterval = time.Second
+
+// multiLineTimeout is how long an event is kept open for more lines
+var multiLineTimeout = 2 * time.Second
+
+// position is where a source has been sent up to
+type position struct {
+	// Offset is the offset in the file of the line after the last one sent
+	Offset int64 `json:",omitempty"`
+	// Cursor is the journald cursor of the last entry sent
+	Cursor string `json:",omitempty"`
+}
+
+// event is a log event read from a source, with the position of the source after it
+type event struct {
+	message   string
+	timestamp time.Time
+	position  position
+}
+
+// reader reads the events of a source until stop is closed
+type reader interface {
+	run(stop <-chan struct{}, events chan<- event)
+}
+
+// newReader returns the reader of a source, starting at the saved position if any
+func newReader(log log.T, source SourceConfig, saved *position) (reader, error) {
+	pattern, err := multiLinePattern(source.MultiLineStartPattern)
+	if err != nil {
+		return nil, err
+	}
+	if source.JournaldUnit != "" {
+		return newJournaldReader(log, source, saved, pattern)
+	}
+	return &fileReader{
+		log:           log,
+		path:          source.FilePath,
+		fromBeginning: source.FromBeginning,
+		saved:         saved,
+		assembler:     assembler{pattern: pattern},
+	}, nil
+}
+
+func multiLinePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// assembler joins the lines of multiline events
+type assembler struct {
+	pattern    *regexp.Regexp
+	pending    *event
+	lastAppend time.Time
+}
+
+// add adds a line and returns the event it completes, if any
+func (a *assembler) add(line string, timestamp time.Time, after position) *event {
+	if a.pattern == nil {
+		return &event{message: truncate(line), timestamp: timestamp, position: after}
+	}
+	var completed *event
+	if a.pending == nil || a.pattern.MatchString(line) {
+		completed = a.pending
+		a.pending = &event{message: truncate(line), timestamp: timestamp, position: after}
+	} else {
+		a.pending.message = truncate(a.pending.message + "\n" + line)
+		a.pending.position = after
+	}
+	a.lastAppend = time.Now()
+	return completed
+}
+
+// expired returns the pending event once no line was added to it for multiLineTimeout
+func (a *assembler) expired() *event {
+	if a.pending == nil || time.Since(a.lastAppend) < multiLineTimeout {
+		return nil
+	}
+	return a.flush()
+}
+
+// flush returns the pending event
+func (a *assembler) flush() *event {
+	pending := a.pending
+	a.pending = nil
+	return pending
+}
+
+// truncate cuts the messages CloudWatch Logs would reject
+func truncate(message string) string {
+	if len(message) > maxEventSize {
+		return message[:maxEventSize]
+	}
+	return message
+}
+
+// send queues an event, it blocks while the queue is full and returns false once stop is closed
+func send(stop <-chan struct{}, events chan<- event, e *event) bool {
+	if e == nil {
+		return true
+	}
+	select {
+	case events <- *e:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// fileReader polls a file for new lines. It starts over when the file is truncated and finishes the old file before
+// following the new one when it is rotated.
+type fileReader struct {
+	log           log.T
+	path          string
+	fromBeginning bool
+	saved         *position
+	assembler     assembler
+
+	file    *os.File
+	offset  int64
+	partial []byte
+}
+
+func (r *fileReader) run(stop <-chan struct{}, events chan<- event) {
+	defer r.close()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if !r.poll(stop, events) {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			send(stop, events, r.assembler.flush())
+			return
+		}
+	}
+}
+
+// poll sends the lines added to the file since the last poll, it returns false once stop is closed
+func (r *fileReader) poll(stop <-chan struct{}, events chan<- event) bool {
+	if r.file == nil && !r.open() {
+		return true
+	}
+	current, err := os.Stat(r.path)
+	opened, openedErr := r.file.Stat()
+	if openedErr != nil {
+		r.log.Warnf("Failed to stat %v: %v", r.path, openedErr)
+		r.close()
+		return true
+	}
+	if opened.Size() < r.offset {
+		r.log.Infof("%v was truncated, reading it from the beginning", r.path)
+		r.offset = 0
+		r.partial = nil
+	}
+	read, ok := r.read(stop, events)
+	if !ok {
+		return false
+	}
+	if err == nil && !os.SameFile(current, opened) {
+		r.log.Infof("%v was rotated, following the new file", r.path)
+		if len(r.partial) > 0 {
+			line := string(r.partial)
+			r.partial = nil
+			if !send(stop, events, r.assembler.add(line, time.Now(), position{Offset: r.offset})) {
+				return false
+			}
+		}
+		if !send(stop, events, r.assembler.flush()) {
+			return false
+		}
+		r.close()
+		r.fromBeginning = true
+		return true
+	}
+	if read == 0 {
+		return send(stop, events, r.assembler.expired())
+	}
+	return true
+}
+
+// open opens the file at the saved position, or at its end unless the lines already in it are to be sent
+func (r *fileReader) open() bool {
+	file, err := os.Open(r.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			r.log.Warnf("Failed to open %v: %v", r.path, err)
+		}
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		r.log.Warnf("Failed to stat %v: %v", r.path, err)
+		file.Close()
+		return false
+	}
+	switch {
+	case r.saved != nil && r.saved.Offset <= info.Size():
+		r.offset = r.saved.Offset
+	case r.saved != nil || r.fromBeginning:
+		r.offset = 0
+	default:
+		r.offset = info.Size()
+	}
+	r.saved = nil
+	r.file = file
+	r.partial = nil
+	return true
+}
+
+func (r *fileReader) close() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// read sends the complete lines after the offset and returns the number of bytes read
+func (r *fileReader) read(stop <-chan struct{}, events chan<- event) (total int, ok bool) {
+	buffer := make([]byte, readChunkSize)
+	for {
+		n, err := r.file.ReadAt(buffer, r.offset+int64(len(r.partial)))
+		total += n
+		data := append(r.partial, buffer[:n]...)
+		for {
+			end := bytes.IndexByte(data, '\n')
+			if end < 0 {
+				break
+			}
+			r.offset += int64(end + 1)
+			line := strings.TrimSuffix(string(data[:end]), "\r")
+			data = data[end+1:]
+			if !send(stop, events, r.assembler.add(line, time.Now(), position{Offset: r.offset})) {
+				return total, false
+			}
+		}
+		if len(data) > maxEventSize {
+			r.offset += int64(len(data))
+			if !send(stop, events, r.assembler.add(string(data), time.Now(), position{Offset: r.offset})) {
+				return total, false
+			}
+			data = nil
+		}
+		r.partial = append([]byte(nil), data...)
+		if err == io.EOF || n == 0 {
+			return total, true
+		}
+		if err != nil {
+			r.log.Warnf("Failed to read %v: %v", r.path, err)
+			return total, true
+		}
+	}
+}
+
+// positions are the positions of the sources, saved in the state directory of the plugin
+type positions struct {
+	lock   sync.Mutex
+	path   string
+	values map[string]position
+}
+
+// loadPositions loads the positions saved by a previous run of the plugin
+func loadPositions(log log.T, path string) *positions {
+	state := &positions{path: path, values: make(map[string]position)}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read the positions of the log sources: %v", err)
+		}
+		return state
+	}
+	if err = json.Unmarshal(content, &state.values); err != nil {
+		log.Warnf("Ignoring the invalid positions of the log sources: %v", err)
+		state.values = make(map[string]position)
+	}
+	return state
+}
+
+func (p *positions) get(key string) *position {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if value, found := p.values[key]; found {
+		return &value
+	}
+	return nil
+}
+
+func (p *positions) set(key string, value position) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.values[key] = value
+}
+
+func (p *positions) save() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	content, err := json.Marshal(p.values)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p.path), appconfig.ReadWriteExecuteAccess); err != nil {
+		return err
+	}
+	temporary := p.path + ".tmp"
+	if err = ioutil.WriteFile(temporary, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(temporary, p.path)
+}
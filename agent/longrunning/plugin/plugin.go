@@ -23,6 +23,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/logtailer"
 	"github.com/aws/amazon-ssm-agent/agent/longrunning/plugin/rundaemon"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 )
@@ -94,6 +95,13 @@ func loadPlatformIndependentPlugins(context context.T) map[string]Plugin {
 	//long running plugins that can be started/stopped/configured by long running plugin manager
 	longrunningplugins := make(map[string]Plugin)
 
+	longrunningplugins[logtailer.Name()] = Plugin{
+		Info: PluginInfo{
+			Name: logtailer.Name(),
+		},
+		Handler: logtailer.NewPlugin(),
+	}
+
 	for key, value := range loadDaemonPlugins(context) {
 		context.Log().Debugf("Adding long-running plugin for %v", key)
 		longrunningplugins[key] = value
@@ -0,0 +1,142 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package iotcreds retrieves AWS credentials from the AWS IoT credentials provider
+// with the X.509 certificate of the device.
+package iotcreds
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+const (
+	// ProviderName provides a name of the IoT credentials provider
+	ProviderName = "iotCredentialsProvider"
+
+	// ThingNameHeader identifies the thing the certificate is attached to
+	ThingNameHeader = "x-amzn-iot-thingname"
+
+	// EarlyExpiryTimeWindow refreshes the credentials before they expire
+	EarlyExpiryTimeWindow = 1 * time.Minute
+
+	requestTimeout = 30 * time.Second
+)
+
+// iotCredentialsProvider implements the AWS SDK credential provider for the AWS IoT credentials provider.
+type iotCredentialsProvider struct {
+	credentials.Expiry
+
+	client    *http.Client
+	url       string
+	thingName string
+}
+
+// credentialsResponse is the response of the AWS IoT credentials provider.
+type credentialsResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `json:"accessKeyId"`
+		SecretAccessKey string    `json:"secretAccessKey"`
+		SessionToken    string    `json:"sessionToken"`
+		Expiration      time.Time `json:"expiration"`
+	} `json:"credentials"`
+}
+
+// NewCredentials returns credentials of the IoT role alias, authenticated with the device certificate.
+func NewCredentials(config appconfig.IotCfg) (*credentials.Credentials, error) {
+	certificate, err := tls.LoadX509KeyPair(config.CertificatePath, config.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the device certificate: %v", err)
+	}
+	tlsConfig := network.GetDefaultTLSConfig()
+	tlsConfig.Certificates = []tls.Certificate{certificate}
+
+	if config.RootCAPath != "" {
+		rootCA, err := ioutil.ReadFile(config.RootCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the root CA: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(rootCA) {
+			return nil, fmt.Errorf("invalid root CA %v", config.RootCAPath)
+		}
+		// the IoT root CA replaces the trust configured for service endpoints
+		tlsConfig.InsecureSkipVerify = false
+		tlsConfig.VerifyConnection = nil
+	}
+
+	p := &iotCredentialsProvider{
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+		},
+		url:       credentialsURL(config),
+		thingName: config.ThingName,
+	}
+	return credentials.NewCredentials(p), nil
+}
+
+// credentialsURL returns the url of the role alias credentials on the credentials provider endpoint.
+func credentialsURL(config appconfig.IotCfg) string {
+	return fmt.Sprintf("https://%v/role-aliases/%v/credentials", config.CredentialsEndpoint, url.PathEscape(config.RoleAlias))
+}
+
+// Retrieve retrieves credentials from the AWS IoT credentials provider.
+func (p *iotCredentialsProvider) Retrieve() (credentials.Value, error) {
+	emptyCredential := credentials.Value{ProviderName: ProviderName}
+
+	request, err := http.NewRequest(http.MethodGet, p.url, nil)
+	if err != nil {
+		return emptyCredential, err
+	}
+	if p.thingName != "" {
+		request.Header.Set(ThingNameHeader, p.thingName)
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return emptyCredential, fmt.Errorf("error requesting IoT credentials: %v", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return emptyCredential, fmt.Errorf("error reading IoT credentials: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return emptyCredential, fmt.Errorf("IoT credentials provider returned %v: %v", response.Status, string(body))
+	}
+
+	var creds credentialsResponse
+	if err = json.Unmarshal(body, &creds); err != nil {
+		return emptyCredential, fmt.Errorf("invalid IoT credentials response: %v", err)
+	}
+	p.SetExpiration(creds.Credentials.Expiration, EarlyExpiryTimeWindow)
+
+	return credentials.Value{
+		AccessKeyID:     creds.Credentials.AccessKeyID,
+		SecretAccessKey: creds.Credentials.SecretAccessKey,
+		SessionToken:    creds.Credentials.SessionToken,
+		ProviderName:    ProviderName,
+	}, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package iotcreds
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrieve(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/role-aliases/ssm-alias/credentials", r.URL.Path)
+		assert.Equal(t, "edge-device-1", r.Header.Get(ThingNameHeader))
+		fmt.Fprintf(w, `{"credentials":{"accessKeyId":"AKID","secretAccessKey":"SECRET","sessionToken":"TOKEN","expiration":"%v"}}`,
+			expiration.Format(time.RFC3339))
+	}))
+	defer server.Close()
+
+	p := &iotCredentialsProvider{
+		client:    server.Client(),
+		url:       server.URL + "/role-aliases/ssm-alias/credentials",
+		thingName: "edge-device-1",
+	}
+	value, err := p.Retrieve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "AKID", value.AccessKeyID)
+	assert.Equal(t, "SECRET", value.SecretAccessKey)
+	assert.Equal(t, "TOKEN", value.SessionToken)
+	assert.Equal(t, ProviderName, value.ProviderName)
+	assert.False(t, p.IsExpired())
+}
+
+func TestRetrieveRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Access Denied"}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	p := &iotCredentialsProvider{client: server.Client(), url: server.URL}
+	_, err := p.Retrieve()
+
+	assert.Error(t, err)
+	assert.True(t, p.IsExpired())
+}
+
+func TestNewCredentialsMissingCertificate(t *testing.T) {
+	_, err := NewCredentials(appconfig.IotCfg{
+		CredentialsEndpoint: "c1234.credentials.iot.us-east-1.amazonaws.com",
+		RoleAlias:           "ssm-alias",
+		CertificatePath:     "testdata/missing.pem.crt",
+		PrivateKeyPath:      "testdata/missing.pem.key",
+	})
+	assert.Error(t, err)
+}
+
+func TestCredentialsURL(t *testing.T) {
+	assert.Equal(t,
+		"https://c1234.credentials.iot.us-east-1.amazonaws.com/role-aliases/ssm-alias/credentials",
+		credentialsURL(appconfig.IotCfg{CredentialsEndpoint: "c1234.credentials.iot.us-east-1.amazonaws.com", RoleAlias: "ssm-alias"}))
+}
@@ -118,6 +118,20 @@ func UpdateServerInfo(instanceID, region, privateKey, privateKeyType string) (er
 	return updateServerInfo(info)
 }
 
+// ClearServerInfo removes the instance info from the registration persistence store,
+// the instance falls back to its EC2 identity or has to be registered again
+func ClearServerInfo() (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err = vault.Remove(RegVaultKey); err != nil {
+		return fmt.Errorf("Failed to remove instance info from vault. %v", err)
+	}
+
+	loadedServerInfo = instanceInfo{}
+	return
+}
+
 // GenerateKeyPair generate a new keypair
 func GenerateKeyPair() (publicKey, privateKey, keyType string, err error) {
 	var keyPair auth.RsaKey
@@ -87,3 +87,7 @@ func (v vaultStub) Store(key string, data []byte) error {
 func (v vaultStub) Retrieve(key string) ([]byte, error) {
 	return v.data, v.err
 }
+
+func (v vaultStub) Remove(key string) error {
+	return v.err
+}
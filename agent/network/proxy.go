@@ -0,0 +1,231 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// artifactProxy is the proxy configuration of the connections to the artifact servers, guarded by policyLock
+var artifactProxy appconfig.ArtifactProxyCfg
+
+var getenv = os.Getenv
+
+// applyArtifactProxy replaces the proxy configuration of the artifact downloads
+func applyArtifactProxy(config appconfig.ArtifactProxyCfg) {
+	policyLock.Lock()
+	artifactProxy = config
+	policyLock.Unlock()
+}
+
+// NewArtifactTransport returns a transport of the artifact downloads, which goes through the artifact proxy
+// and trusts the servers of the endpoint class. Artifacts stored in S3 are downloaded from service endpoints.
+func NewArtifactTransport(class EndpointClass) *http.Transport {
+	return &http.Transport{
+		Proxy:           ArtifactProxy,
+		TLSClientConfig: GetTLSConfig(class),
+	}
+}
+
+// ArtifactProxy returns the proxy of a request to an artifact server, nil when the request does not use a proxy.
+// Unlike http.ProxyFromEnvironment the environment is read for every request, so the proxy variables set after the
+// first download are honored, and the configured no proxy entries are matched in addition to NO_PROXY.
+func ArtifactProxy(request *http.Request) (*url.URL, error) {
+	policyLock.RLock()
+	config := artifactProxy
+	policyLock.RUnlock()
+	return proxyForURL(config, request.URL)
+}
+
+// ProxyEnvironment returns the proxy variables of the processes started by the agent, so that they download
+// through the proxy of the artifacts. The configured proxy replaces the proxy variables of the environment and the
+// configured no proxy entries are added to NO_PROXY. Every variable is set in lower and upper case.
+func ProxyEnvironment() map[string]string {
+	policyLock.RLock()
+	config := artifactProxy
+	policyLock.RUnlock()
+
+	httpsProxy, httpProxy := config.Url, config.Url
+	if config.Url == "" {
+		httpsProxy = getenvAny("HTTPS_PROXY", "https_proxy")
+		httpProxy = getenvAny("HTTP_PROXY", "http_proxy")
+	}
+	var noProxy []string
+	if environment := getenvAny("NO_PROXY", "no_proxy"); environment != "" {
+		noProxy = append(noProxy, environment)
+	}
+	noProxy = append(noProxy, config.NoProxy...)
+
+	variables := make(map[string]string)
+	for name, value := range map[string]string{"https_proxy": httpsProxy, "http_proxy": httpProxy, "no_proxy": strings.Join(noProxy, ",")} {
+		variables[name] = value
+		variables[strings.ToUpper(name)] = value
+	}
+	return variables
+}
+
+// loopbackNoProxy are the no proxy entries of the loopback addresses, which the agent never reaches through a proxy
+var loopbackNoProxy = []string{"localhost", "127.0.0.1", "::1"}
+
+// AppendProxyEnvironment appends the variables of ProxyEnvironment to the environment of a plugin or script process
+// when the artifact proxy injects its environment, so that the process reaches the internet the way the agent does.
+// The appended variables replace the variables of env with the same name; loopback addresses are added to NO_PROXY
+// when a proxy is set and variables without a value are left out.
+func AppendProxyEnvironment(env []string) []string {
+	policyLock.RLock()
+	inject := artifactProxy.InjectEnvironment
+	policyLock.RUnlock()
+	if !inject {
+		return env
+	}
+
+	variables := ProxyEnvironment()
+	if variables["https_proxy"] != "" || variables["http_proxy"] != "" {
+		noProxy := strings.Join(loopbackNoProxy, ",")
+		if variables["no_proxy"] != "" {
+			noProxy = variables["no_proxy"] + "," + noProxy
+		}
+		variables["no_proxy"], variables["NO_PROXY"] = noProxy, noProxy
+	}
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if variables[name] != "" {
+			env = append(env, name+"="+variables[name])
+		}
+	}
+	return env
+}
+
+// proxyForURL returns the proxy of the target url, the configured proxy or the one of the environment
+// variables for its scheme
+func proxyForURL(config appconfig.ArtifactProxyCfg, target *url.URL) (*url.URL, error) {
+	proxy := config.Url
+	if proxy == "" {
+		switch target.Scheme {
+		case "https":
+			proxy = getenvAny("HTTPS_PROXY", "https_proxy")
+		case "http":
+			proxy = getenvAny("HTTP_PROXY", "http_proxy")
+		}
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+
+	noProxy := append([]string{}, config.NoProxy...)
+	noProxy = append(noProxy, strings.Split(getenvAny("NO_PROXY", "no_proxy"), ",")...)
+	if bypassProxy(target, noProxy) {
+		return nil, nil
+	}
+	return parseProxyURL(proxy)
+}
+
+// parseProxyURL parses a proxy address, which defaults to the http scheme as in http.ProxyFromEnvironment
+func parseProxyURL(proxy string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && proxyURL.Scheme != "socks5") {
+		if schemeURL, schemeErr := url.Parse("http://" + proxy); schemeErr == nil {
+			return schemeURL, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy address %v: %v", proxy, err)
+	}
+	return proxyURL, nil
+}
+
+// bypassProxy returns true when the target url matches a no proxy entry: "*", a domain name which also
+// matches its subdomains, an optional port after the host, an IP address, a CIDR range or a url. Loopback
+// addresses never use the proxy.
+func bypassProxy(target *url.URL, noProxy []string) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if port == "" {
+		port = defaultPorts[target.Scheme]
+	}
+	ip := net.ParseIP(host)
+	if host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return true
+	}
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if strings.Contains(entry, "://") {
+			entryURL, err := url.Parse(entry)
+			if err != nil {
+				continue
+			}
+			entry = entryURL.Host
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if splitHost, splitPort, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = splitHost, splitPort
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		entryHost = strings.Trim(entryHost, "[]")
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost = strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// getenvAny returns the value of the first environment variable that is set
+func getenvAny(names ...string) string {
+	for _, name := range names {
+		if value := getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
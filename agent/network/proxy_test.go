@@ -0,0 +1,140 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+func useEnvironment(env map[string]string) func() {
+	original := getenv
+	getenv = func(name string) string { return env[name] }
+	return func() { getenv = original }
+}
+
+func proxyOf(t *testing.T, config appconfig.ArtifactProxyCfg, target string) string {
+	targetURL, err := url.Parse(target)
+	assert.NoError(t, err)
+	proxyURL, err := proxyForURL(config, targetURL)
+	assert.NoError(t, err)
+	if proxyURL == nil {
+		return ""
+	}
+	return proxyURL.String()
+}
+
+func TestProxyForURLEnvironment(t *testing.T) {
+	defer useEnvironment(map[string]string{
+		"https_proxy": "proxy.corp:3128",
+		"HTTP_PROXY":  "http://plain.corp:8080",
+		"NO_PROXY":    "internal.corp, 10.0.0.0/8",
+	})()
+	config := appconfig.ArtifactProxyCfg{}
+
+	assert.Equal(t, "http://proxy.corp:3128", proxyOf(t, config, "https://s3.amazonaws.com/bucket/key"))
+	assert.Equal(t, "http://plain.corp:8080", proxyOf(t, config, "http://example.com/file"))
+	assert.Equal(t, "", proxyOf(t, config, "https://repo.internal.corp/package.zip"))
+	assert.Equal(t, "", proxyOf(t, config, "https://10.1.2.3/package.zip"))
+	assert.Equal(t, "", proxyOf(t, config, "https://localhost/package.zip"))
+	assert.Equal(t, "", proxyOf(t, config, "ftp://example.com/file"))
+}
+
+func TestProxyForURLConfig(t *testing.T) {
+	defer useEnvironment(map[string]string{"HTTPS_PROXY": "http://env.corp:3128"})()
+	config := appconfig.ArtifactProxyCfg{
+		Url:     "https://artifacts-proxy.corp:8443",
+		NoProxy: []string{"*.mirror.corp", "downloads.corp:8443", "192.168.1.10", "https://github.com/org"},
+	}
+
+	assert.Equal(t, "https://artifacts-proxy.corp:8443", proxyOf(t, config, "https://s3.amazonaws.com/bucket/key"))
+	assert.Equal(t, "https://artifacts-proxy.corp:8443", proxyOf(t, config, "http://example.com/file"))
+	assert.Equal(t, "", proxyOf(t, config, "https://eu.mirror.corp/package.zip"))
+	assert.Equal(t, "", proxyOf(t, config, "https://downloads.corp:8443/package.zip"))
+	assert.Equal(t, "https://artifacts-proxy.corp:8443", proxyOf(t, config, "https://downloads.corp/package.zip"))
+	assert.Equal(t, "", proxyOf(t, config, "http://192.168.1.10/package.zip"))
+	assert.Equal(t, "", proxyOf(t, config, "https://github.com/org/repo"))
+	assert.Equal(t, "https://artifacts-proxy.corp:8443", proxyOf(t, config, "https://notgithub.com/org/repo"))
+}
+
+func TestProxyForURLNoProxyWildcard(t *testing.T) {
+	defer useEnvironment(map[string]string{"no_proxy": "*"})()
+	config := appconfig.ArtifactProxyCfg{Url: "proxy.corp:3128"}
+
+	assert.Equal(t, "", proxyOf(t, config, "https://s3.amazonaws.com/bucket/key"))
+}
+
+func TestArtifactProxy(t *testing.T) {
+	defer useEnvironment(map[string]string{})()
+	applyArtifactProxy(appconfig.ArtifactProxyCfg{Url: "http://proxy.corp:3128"})
+	defer applyArtifactProxy(appconfig.ArtifactProxyCfg{})
+
+	request, err := http.NewRequest("GET", "https://example.com/file", nil)
+	assert.NoError(t, err)
+	proxyURL, err := ArtifactProxy(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://proxy.corp:3128", proxyURL.String())
+
+	transport := NewArtifactTransport(ArtifactEndpoint)
+	assert.NotNil(t, transport.Proxy)
+	assert.NotNil(t, transport.TLSClientConfig)
+}
+
+func TestProxyEnvironment(t *testing.T) {
+	defer useEnvironment(map[string]string{"https_proxy": "proxy.corp:3128", "NO_PROXY": "internal.corp"})()
+	defer applyArtifactProxy(appconfig.ArtifactProxyCfg{})
+
+	assert.Equal(t, map[string]string{
+		"https_proxy": "proxy.corp:3128", "HTTPS_PROXY": "proxy.corp:3128",
+		"http_proxy": "", "HTTP_PROXY": "",
+		"no_proxy": "internal.corp", "NO_PROXY": "internal.corp",
+	}, ProxyEnvironment())
+
+	applyArtifactProxy(appconfig.ArtifactProxyCfg{Url: "https://artifacts-proxy.corp:8443", NoProxy: []string{"*.mirror.corp"}})
+	assert.Equal(t, map[string]string{
+		"https_proxy": "https://artifacts-proxy.corp:8443", "HTTPS_PROXY": "https://artifacts-proxy.corp:8443",
+		"http_proxy": "https://artifacts-proxy.corp:8443", "HTTP_PROXY": "https://artifacts-proxy.corp:8443",
+		"no_proxy": "internal.corp,*.mirror.corp", "NO_PROXY": "internal.corp,*.mirror.corp",
+	}, ProxyEnvironment())
+}
+
+func TestAppendProxyEnvironment(t *testing.T) {
+	defer useEnvironment(map[string]string{"HTTPS_PROXY": "http://proxy.corp:3128", "no_proxy": "internal.corp"})()
+	defer applyArtifactProxy(appconfig.ArtifactProxyCfg{})
+	env := []string{"PATH=/usr/bin"}
+
+	applyArtifactProxy(appconfig.ArtifactProxyCfg{})
+	assert.Equal(t, env, AppendProxyEnvironment(env))
+
+	applyArtifactProxy(appconfig.ArtifactProxyCfg{InjectEnvironment: true})
+	assert.Equal(t, []string{
+		"PATH=/usr/bin",
+		"HTTPS_PROXY=http://proxy.corp:3128",
+		"NO_PROXY=internal.corp,localhost,127.0.0.1,::1",
+		"https_proxy=http://proxy.corp:3128",
+		"no_proxy=internal.corp,localhost,127.0.0.1,::1",
+	}, AppendProxyEnvironment(env))
+}
+
+func TestAppendProxyEnvironmentWithoutProxy(t *testing.T) {
+	defer useEnvironment(map[string]string{})()
+	defer applyArtifactProxy(appconfig.ArtifactProxyCfg{})
+
+	applyArtifactProxy(appconfig.ArtifactProxyCfg{InjectEnvironment: true})
+	assert.Empty(t, AppendProxyEnvironment(nil))
+}
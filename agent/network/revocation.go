@@ -0,0 +1,189 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// crlFetchTimeout bounds the handshake delay added by downloading a CRL
+	crlFetchTimeout = 10 * time.Second
+	// crlMaxSize bounds the memory used by a downloaded CRL
+	crlMaxSize = 20 * 1024 * 1024
+)
+
+// revocationChecker rejects server certificates revoked by a stapled OCSP response or by the CRL of their issuer
+type revocationChecker struct {
+	log              log.T
+	hardFail         bool
+	requireStapling  bool
+	crlCacheDuration time.Duration
+	client           *http.Client
+	now              func() time.Time
+
+	lock sync.Mutex
+	crls map[string]cachedCRL // keyed by url and issuer key
+}
+
+// cachedCRL is a verified CRL and the time it must be fetched again
+type cachedCRL struct {
+	crl     *x509.RevocationList
+	expires time.Time
+}
+
+// newRevocationChecker returns nil when revocation checking is off
+func newRevocationChecker(log log.T, config appconfig.TlsRevocationCfg) *revocationChecker {
+	if config.Mode != appconfig.TlsRevocationSoftFail && config.Mode != appconfig.TlsRevocationHardFail {
+		return nil
+	}
+	return &revocationChecker{
+		log:              log,
+		hardFail:         config.Mode == appconfig.TlsRevocationHardFail,
+		requireStapling:  config.RequireOcspStapling,
+		crlCacheDuration: time.Duration(config.CrlCacheMinutes) * time.Minute,
+		// CRLs are served over plain http, their integrity comes from the issuer signature
+		client: &http.Client{
+			Timeout:   crlFetchTimeout,
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+		},
+		now:  time.Now,
+		crls: make(map[string]cachedCRL),
+	}
+}
+
+// check rejects the connection when the server certificate is revoked, or when its status cannot be
+// determined in hard fail mode
+func (checker *revocationChecker) check(state tls.ConnectionState, chains [][]*x509.Certificate) error {
+	if checker.requireStapling && len(state.OCSPResponse) == 0 {
+		return fmt.Errorf("server %v did not staple an OCSP response", state.ServerName)
+	}
+	if len(chains) == 0 || len(chains[0]) < 2 {
+		// a certificate trusted directly has no issuer that could revoke it
+		return nil
+	}
+
+	leaf, issuer := chains[0][0], chains[0][1]
+	revoked, err := checker.status(state.OCSPResponse, leaf, issuer)
+	if revoked {
+		return fmt.Errorf("certificate %v of server %v is revoked", leaf.SerialNumber, state.ServerName)
+	}
+	if err == nil {
+		return nil
+	}
+	if checker.hardFail {
+		return fmt.Errorf("revocation status of the certificate of server %v is unknown: %v", state.ServerName, err)
+	}
+	checker.log.Warnf("Allowing server %v whose certificate revocation status is unknown: %v", state.ServerName, err)
+	return nil
+}
+
+// status returns true if the certificate is revoked, and an error if its status cannot be determined.
+// A valid stapled OCSP response is authoritative, the CRL distribution points are checked otherwise.
+func (checker *revocationChecker) status(staple []byte, leaf *x509.Certificate, issuer *x509.Certificate) (bool, error) {
+	if len(staple) > 0 {
+		response, err := ocsp.ParseResponseForCert(staple, leaf, issuer)
+		if err == nil && (response.NextUpdate.IsZero() || checker.now().Before(response.NextUpdate)) {
+			switch response.Status {
+			case ocsp.Good:
+				return false, nil
+			case ocsp.Revoked:
+				return true, nil
+			}
+		}
+	}
+
+	var lastErr error
+	for _, url := range leaf.CRLDistributionPoints {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		crl, err := checker.fetchCRL(url, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if lastErr != nil {
+		return false, lastErr
+	}
+	return false, fmt.Errorf("no valid OCSP response is stapled and the certificate has no CRL distribution point")
+}
+
+// fetchCRL returns the CRL published at the url, downloaded CRLs are cached until their next update
+// or for the configured duration, whichever comes first
+func (checker *revocationChecker) fetchCRL(url string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	// the signature of a cached CRL was verified with the issuer key it is cached for
+	key := url + " " + publicKeyPin(issuer)
+	now := checker.now()
+	checker.lock.Lock()
+	cached, ok := checker.crls[key]
+	checker.lock.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.crl, nil
+	}
+
+	resp, err := checker.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download CRL %v: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download CRL %v: %v", url, resp.Status)
+	}
+	der, err := ioutil.ReadAll(io.LimitReader(resp.Body, crlMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download CRL %v: %v", url, err)
+	}
+
+	crl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CRL %v: %v", url, err)
+	}
+	if err = crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("CRL %v is not signed by the issuer of the certificate: %v", url, err)
+	}
+	expires := now.Add(checker.crlCacheDuration)
+	if !crl.NextUpdate.IsZero() {
+		if !now.Before(crl.NextUpdate) {
+			return nil, fmt.Errorf("CRL %v expired at %v", url, crl.NextUpdate)
+		}
+		if crl.NextUpdate.Before(expires) {
+			expires = crl.NextUpdate
+		}
+	}
+
+	checker.lock.Lock()
+	checker.crls[key] = cachedCRL{crl: crl, expires: expires}
+	checker.lock.Unlock()
+	return crl, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ocsp"
+)
+
+type testPKI struct {
+	ca      *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+	leaf    *x509.Certificate
+	crl     []byte
+	crlHits int
+	server  *httptest.Server
+}
+
+func newTestPKI(t *testing.T, revoked bool) *testPKI {
+	pki := &testPKI{}
+	pki.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pki.crlHits++
+		w.Write(pki.crl)
+	}))
+
+	var err error
+	pki.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &pki.caKey.PublicKey, pki.caKey)
+	assert.NoError(t, err)
+	pki.ca, _ = x509.ParseCertificate(caDer)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: []string{pki.server.URL + "/ca.crl"},
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, leafTemplate, pki.ca, &leafKey.PublicKey, pki.caKey)
+	assert.NoError(t, err)
+	pki.leaf, _ = x509.ParseCertificate(leafDer)
+
+	crlTemplate := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+	}
+	if revoked {
+		crlTemplate.RevokedCertificateEntries = []x509.RevocationListEntry{
+			{SerialNumber: pki.leaf.SerialNumber, RevocationTime: time.Now().Add(-time.Minute)},
+		}
+	}
+	pki.crl, err = x509.CreateRevocationList(rand.Reader, crlTemplate, pki.ca, pki.caKey)
+	assert.NoError(t, err)
+	return pki
+}
+
+func (pki *testPKI) staple(t *testing.T, status int) []byte {
+	response, err := ocsp.CreateResponse(pki.ca, pki.ca, ocsp.Response{
+		Status:       status,
+		SerialNumber: pki.leaf.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Minute),
+		NextUpdate:   time.Now().Add(time.Hour),
+		RevokedAt:    time.Now().Add(-time.Minute),
+	}, crypto.Signer(pki.caKey))
+	assert.NoError(t, err)
+	return response
+}
+
+func (pki *testPKI) state(staple []byte) (tls.ConnectionState, [][]*x509.Certificate) {
+	return tls.ConnectionState{ServerName: "server", OCSPResponse: staple}, [][]*x509.Certificate{{pki.leaf, pki.ca}}
+}
+
+func newTestRevocationChecker(mode string, requireStapling bool) *revocationChecker {
+	return newRevocationChecker(log.NewMockLog(), appconfig.TlsRevocationCfg{
+		Mode:                mode,
+		RequireOcspStapling: requireStapling,
+		CrlCacheMinutes:     appconfig.DefaultCrlCacheMinutes,
+	})
+}
+
+func TestRevocationCheckerOff(t *testing.T) {
+	assert.Nil(t, newTestRevocationChecker(appconfig.TlsRevocationOff, true))
+}
+
+func TestRevocationCheckerCRL(t *testing.T) {
+	pki := newTestPKI(t, false)
+	defer pki.server.Close()
+	checker := newTestRevocationChecker(appconfig.TlsRevocationHardFail, false)
+
+	assert.NoError(t, checker.check(pki.state(nil)))
+	assert.NoError(t, checker.check(pki.state(nil)))
+	assert.Equal(t, 1, pki.crlHits, "the CRL is cached")
+
+	revokedPKI := newTestPKI(t, true)
+	defer revokedPKI.server.Close()
+	assert.Error(t, checker.check(revokedPKI.state(nil)))
+}
+
+func TestRevocationCheckerCRLNotSignedByIssuer(t *testing.T) {
+	pki := newTestPKI(t, false)
+	defer pki.server.Close()
+	otherPKI := newTestPKI(t, false)
+	defer otherPKI.server.Close()
+	pki.crl = otherPKI.crl
+	checker := newTestRevocationChecker(appconfig.TlsRevocationHardFail, false)
+
+	assert.Error(t, checker.check(pki.state(nil)))
+}
+
+func TestRevocationCheckerUnknownStatus(t *testing.T) {
+	pki := newTestPKI(t, false)
+	pki.server.Close()
+
+	assert.Error(t, newTestRevocationChecker(appconfig.TlsRevocationHardFail, false).check(pki.state(nil)))
+	assert.NoError(t, newTestRevocationChecker(appconfig.TlsRevocationSoftFail, false).check(pki.state(nil)))
+
+	revokedPKI := newTestPKI(t, true)
+	defer revokedPKI.server.Close()
+	assert.Error(t, newTestRevocationChecker(appconfig.TlsRevocationSoftFail, false).check(revokedPKI.state(nil)),
+		"soft fail still rejects revoked certificates")
+}
+
+func TestRevocationCheckerOcspStapling(t *testing.T) {
+	pki := newTestPKI(t, true)
+	defer pki.server.Close()
+	checker := newTestRevocationChecker(appconfig.TlsRevocationHardFail, true)
+
+	assert.Error(t, checker.check(pki.state(nil)), "stapling is required")
+	assert.Error(t, checker.check(pki.state(pki.staple(t, ocsp.Revoked))))
+	// a good stapled response is authoritative over the CRL
+	assert.NoError(t, checker.check(pki.state(pki.staple(t, ocsp.Good))))
+	assert.Equal(t, 0, pki.crlHits)
+}
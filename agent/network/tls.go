@@ -0,0 +1,202 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package network contains the transport security policy shared by all outbound connections of the agent.
+package network
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/gorilla/websocket"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// fipsCipherSuites are the FIPS approved TLS 1.2 cipher suites
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the FIPS approved elliptic curves
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+
+var (
+	policyLock  sync.RWMutex
+	policy      = &tls.Config{}
+	fipsMode    bool
+	trustStores = map[EndpointClass]*trustStore{}
+	revocation  *revocationChecker
+)
+
+// ApplyTLSPolicy loads the tls policy from the agent config and applies it to the default
+// http transport and websocket dialer, which connect to service endpoints.
+// Connections using their own transport call GetDefaultTLSConfig or GetTLSConfig.
+// The artifact proxy of the config is loaded too, artifact downloads use NewArtifactTransport.
+func ApplyTLSPolicy(log log.T) {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		log.Debugf("appconfig could not be loaded, using the default tls policy - %v", err)
+		return
+	}
+	applyTLSPolicy(log, config.Tls)
+	applyArtifactProxy(config.ArtifactProxy)
+}
+
+func applyTLSPolicy(log log.T, config appconfig.TlsCfg) {
+	tlsConfig := newTLSConfig(log, config)
+	stores := map[EndpointClass]*trustStore{
+		ServiceEndpoint:  newTrustStore(log, config.Service),
+		ArtifactEndpoint: newTrustStore(log, config.Artifacts),
+	}
+	checker := newRevocationChecker(log, config.Revocation)
+
+	policyLock.Lock()
+	for _, store := range trustStores {
+		if store != nil {
+			store.close()
+		}
+	}
+	policy = tlsConfig
+	fipsMode = config.FipsMode
+	trustStores = stores
+	revocation = checker
+	policyLock.Unlock()
+
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.TLSClientConfig = GetTLSConfig(ServiceEndpoint)
+	}
+	websocket.DefaultDialer.TLSClientConfig = GetTLSConfig(ServiceEndpoint)
+}
+
+// GetDefaultTLSConfig returns a copy of the tls config connections to service endpoints must use
+func GetDefaultTLSConfig() *tls.Config {
+	return GetTLSConfig(ServiceEndpoint)
+}
+
+// GetTLSConfig returns a copy of the tls config connections to the endpoint class must use
+func GetTLSConfig(class EndpointClass) *tls.Config {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	tlsConfig := policy.Clone()
+	applyVerification(tlsConfig, trustStores[class], revocation)
+	return tlsConfig
+}
+
+// applyVerification adds the custom trust and revocation checks to the tls config. With a trust store
+// the built-in verification is replaced, so the current CA bundle is used by connections of
+// transports created before it was reloaded.
+func applyVerification(tlsConfig *tls.Config, store *trustStore, checker *revocationChecker) {
+	if store == nil && checker == nil {
+		return
+	}
+	if store != nil {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		chains := state.VerifiedChains
+		if store != nil {
+			var err error
+			if chains, err = store.verify(state); err != nil {
+				return err
+			}
+		}
+		if checker != nil {
+			return checker.check(state, chains)
+		}
+		return nil
+	}
+}
+
+// IsFipsMode returns true when the agent is restricted to FIPS approved cryptography
+func IsFipsMode() bool {
+	policyLock.RLock()
+	defer policyLock.RUnlock()
+	return fipsMode
+}
+
+// newTLSConfig builds the tls config for the policy, invalid settings are logged and ignored
+func newTLSConfig(log log.T, config appconfig.TlsCfg) *tls.Config {
+	tlsConfig := &tls.Config{}
+
+	if config.MinVersion != "" {
+		if version, ok := tlsVersions[config.MinVersion]; ok {
+			tlsConfig.MinVersion = version
+		} else {
+			log.Warnf("Ignoring unsupported minimum TLS version %v", config.MinVersion)
+		}
+	}
+
+	if len(config.CipherSuites) > 0 {
+		suites := cipherSuitesByName()
+		for _, name := range config.CipherSuites {
+			if id, ok := suites[name]; ok {
+				tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+			} else {
+				log.Warnf("Ignoring unknown cipher suite %v", name)
+			}
+		}
+	}
+
+	if config.FipsMode {
+		if !fipsCryptoModule {
+			log.Warnf("FIPS mode is enabled but the agent is not built with a FIPS validated crypto module")
+		}
+		if tlsConfig.MinVersion < tls.VersionTLS12 {
+			tlsConfig.MinVersion = tls.VersionTLS12
+		}
+		tlsConfig.CipherSuites = fipsApproved(tlsConfig.CipherSuites)
+		tlsConfig.CurvePreferences = fipsCurves
+	}
+
+	return tlsConfig
+}
+
+// cipherSuitesByName maps the IANA names of the cipher suites implemented by the runtime to their ids
+func cipherSuitesByName() map[string]uint16 {
+	suites := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[suite.Name] = suite.ID
+	}
+	return suites
+}
+
+// fipsApproved keeps the FIPS approved cipher suites of the configured ones, or all of them if none are configured
+func fipsApproved(configured []uint16) []uint16 {
+	if len(configured) == 0 {
+		return fipsCipherSuites
+	}
+	var approved []uint16
+	for _, id := range configured {
+		for _, fipsID := range fipsCipherSuites {
+			if id == fipsID {
+				approved = append(approved, id)
+			}
+		}
+	}
+	if len(approved) == 0 {
+		return fipsCipherSuites
+	}
+	return approved
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTLSConfigDefaults(t *testing.T) {
+	tlsConfig := newTLSConfig(log.NewMockLog(), appconfig.TlsCfg{})
+	assert.Equal(t, uint16(0), tlsConfig.MinVersion)
+	assert.Empty(t, tlsConfig.CipherSuites)
+	assert.Empty(t, tlsConfig.CurvePreferences)
+}
+
+func TestNewTLSConfigMinVersion(t *testing.T) {
+	tlsConfig := newTLSConfig(log.NewMockLog(), appconfig.TlsCfg{MinVersion: "1.3"})
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+
+	tlsConfig = newTLSConfig(log.NewMockLog(), appconfig.TlsCfg{MinVersion: "2.0"})
+	assert.Equal(t, uint16(0), tlsConfig.MinVersion)
+}
+
+func TestNewTLSConfigCipherSuites(t *testing.T) {
+	config := appconfig.TlsCfg{CipherSuites: []string{
+		"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+		"TLS_RSA_WITH_AES_128_CBC_SHA",
+		"TLS_UNKNOWN",
+	}}
+	tlsConfig := newTLSConfig(log.NewMockLog(), config)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, tls.TLS_RSA_WITH_AES_128_CBC_SHA}, tlsConfig.CipherSuites)
+}
+
+func TestNewTLSConfigFipsMode(t *testing.T) {
+	tlsConfig := newTLSConfig(log.NewMockLog(), appconfig.TlsCfg{MinVersion: "1.0", FipsMode: true})
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+	assert.Equal(t, fipsCipherSuites, tlsConfig.CipherSuites)
+	assert.Equal(t, fipsCurves, tlsConfig.CurvePreferences)
+
+	config := appconfig.TlsCfg{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_RSA_WITH_AES_128_CBC_SHA", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		FipsMode:     true,
+	}
+	tlsConfig = newTLSConfig(log.NewMockLog(), config)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tlsConfig.CipherSuites)
+}
+
+func TestApplyTLSPolicy(t *testing.T) {
+	transport := http.DefaultTransport.(*http.Transport)
+	transportConfig, dialerConfig := transport.TLSClientConfig, websocket.DefaultDialer.TLSClientConfig
+	defer func() {
+		applyTLSPolicy(log.NewMockLog(), appconfig.TlsCfg{})
+		transport.TLSClientConfig, websocket.DefaultDialer.TLSClientConfig = transportConfig, dialerConfig
+	}()
+
+	applyTLSPolicy(log.NewMockLog(), appconfig.TlsCfg{MinVersion: "1.2", FipsMode: true})
+	assert.True(t, IsFipsMode())
+	assert.Equal(t, uint16(tls.VersionTLS12), GetDefaultTLSConfig().MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), websocket.DefaultDialer.TLSClientConfig.MinVersion)
+
+	// callers get their own copy of the policy
+	GetDefaultTLSConfig().MinVersion = tls.VersionTLS10
+	assert.Equal(t, uint16(tls.VersionTLS12), GetDefaultTLSConfig().MinVersion)
+}
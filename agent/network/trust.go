@@ -0,0 +1,191 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// EndpointClass is a kind of server the agent connects to, each class is trusted independently
+type EndpointClass int
+
+const (
+	// ServiceEndpoint are the SSM and other AWS APIs
+	ServiceEndpoint EndpointClass = iota
+	// ArtifactEndpoint are the servers documents download artifacts from
+	ArtifactEndpoint
+)
+
+// pinPrefix is accepted in front of pinned public keys, as in the HTTP public key pinning format
+const pinPrefix = "sha256/"
+
+// trustStore verifies the servers of an endpoint class against a custom CA bundle and pinned public keys
+type trustStore struct {
+	log          log.T
+	caBundlePath string
+	pinning      bool
+	pins         map[string]bool
+	watcher      *fsnotify.Watcher
+
+	lock  sync.RWMutex
+	roots *x509.CertPool
+}
+
+// newTrustStore returns nil when the endpoint class uses the default verification of the system roots
+func newTrustStore(log log.T, config appconfig.TlsTrustCfg) *trustStore {
+	if config.CABundlePath == "" && len(config.PinnedPublicKeys) == 0 {
+		return nil
+	}
+
+	store := &trustStore{
+		log:          log,
+		caBundlePath: config.CABundlePath,
+		pinning:      len(config.PinnedPublicKeys) > 0,
+		pins:         make(map[string]bool),
+	}
+	for _, pin := range config.PinnedPublicKeys {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), pinPrefix)
+		if digest, err := base64.StdEncoding.DecodeString(pin); err != nil || len(digest) != sha256.Size {
+			log.Warnf("Ignoring invalid pinned public key %v", pin)
+			continue
+		}
+		store.pins[pin] = true
+	}
+	if store.pinning && len(store.pins) == 0 {
+		// pinning was asked for, trusting any key instead would silently weaken the policy
+		log.Errorf("None of the pinned public keys is valid, connections will be rejected")
+	}
+
+	if store.caBundlePath != "" {
+		if err := store.reload(); err != nil {
+			log.Errorf("Failed to load CA bundle %v, only the system roots are trusted - %v", store.caBundlePath, err)
+		}
+		store.watch()
+	}
+	return store
+}
+
+// reload reads the CA bundle and trusts its certificates in addition to the system roots
+func (store *trustStore) reload() error {
+	bundle, err := ioutil.ReadFile(store.caBundlePath)
+	if err != nil {
+		return err
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("no certificates found in %v", store.caBundlePath)
+	}
+
+	store.lock.Lock()
+	store.roots = roots
+	store.lock.Unlock()
+	return nil
+}
+
+// watch reloads the CA bundle when it changes, the parent directory is watched so the bundle can be
+// replaced by a rename or created after the agent started
+func (store *trustStore) watch() {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		store.log.Errorf("Error initializing the CA bundle watcher: %v", err)
+		return
+	}
+	if err = watcher.Add(filepath.Dir(store.caBundlePath)); err != nil {
+		store.log.Errorf("Error watching the CA bundle directory: %v", err)
+		watcher.Close()
+		return
+	}
+	store.watcher = watcher
+
+	go func() {
+		for event := range watcher.Events {
+			if filepath.Clean(event.Name) != filepath.Clean(store.caBundlePath) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if err := store.reload(); err != nil {
+				store.log.Warnf("Keeping the previous CA bundle, reloading %v failed - %v", store.caBundlePath, err)
+				continue
+			}
+			store.log.Infof("Reloaded CA bundle %v", store.caBundlePath)
+		}
+	}()
+}
+
+// close stops watching the CA bundle
+func (store *trustStore) close() {
+	if store.watcher != nil {
+		store.watcher.Close()
+	}
+}
+
+// verify verifies the certificate chain and host name of the server, then the pinned public keys,
+// and returns the verified chains
+func (store *trustStore) verify(state tls.ConnectionState) ([][]*x509.Certificate, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("server %v presented no certificate", state.ServerName)
+	}
+
+	store.lock.RLock()
+	roots := store.roots
+	store.lock.RUnlock()
+
+	options := x509.VerifyOptions{
+		DNSName:       state.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, certificate := range state.PeerCertificates[1:] {
+		options.Intermediates.AddCert(certificate)
+	}
+	chains, err := state.PeerCertificates[0].Verify(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if !store.pinning {
+		return chains, nil
+	}
+	for _, chain := range chains {
+		for _, certificate := range chain {
+			if store.pins[publicKeyPin(certificate)] {
+				return chains, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no certificate presented by %v has a pinned public key", state.ServerName)
+}
+
+// publicKeyPin returns the base64 encoded sha256 digest of the subject public key of the certificate
+func publicKeyPin(certificate *x509.Certificate) string {
+	digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
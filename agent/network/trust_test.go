@@ -0,0 +1,135 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package network
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTLSServer(t *testing.T) (*httptest.Server, []byte, string) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	certificate := server.Certificate()
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return server, bundle, base64.StdEncoding.EncodeToString(digest[:])
+}
+
+func connect(store *trustStore, url string) error {
+	tlsConfig := GetDefaultTLSConfig()
+	applyVerification(tlsConfig, store, nil)
+	client := http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	resp, err := client.Get(url)
+	if err == nil {
+		resp.Body.Close()
+	}
+	return err
+}
+
+func writeBundle(t *testing.T, bundle []byte) (string, func()) {
+	dir, err := ioutil.TempDir("", "trust")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "ca-bundle.pem")
+	assert.NoError(t, ioutil.WriteFile(path, bundle, 0600))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestNewTrustStoreDefault(t *testing.T) {
+	assert.Nil(t, newTrustStore(log.NewMockLog(), appconfig.TlsTrustCfg{}))
+}
+
+func TestTrustStoreCABundle(t *testing.T) {
+	server, bundle, _ := newTestTLSServer(t)
+	defer server.Close()
+	path, cleanup := writeBundle(t, bundle)
+	defer cleanup()
+
+	assert.Error(t, connect(nil, server.URL), "the test server is not trusted by the system roots")
+
+	store := newTrustStore(log.NewMockLog(), appconfig.TlsTrustCfg{CABundlePath: path})
+	defer store.close()
+	assert.NoError(t, connect(store, server.URL))
+}
+
+func TestTrustStorePinnedPublicKeys(t *testing.T) {
+	server, bundle, pin := newTestTLSServer(t)
+	defer server.Close()
+	path, cleanup := writeBundle(t, bundle)
+	defer cleanup()
+
+	store := newTrustStore(log.NewMockLog(), appconfig.TlsTrustCfg{CABundlePath: path, PinnedPublicKeys: []string{"sha256/" + pin}})
+	defer store.close()
+	assert.NoError(t, connect(store, server.URL))
+
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+	store = newTrustStore(log.NewMockLog(), appconfig.TlsTrustCfg{CABundlePath: path, PinnedPublicKeys: []string{otherPin}})
+	defer store.close()
+	assert.Error(t, connect(store, server.URL))
+
+	store = newTrustStore(log.NewMockLog(), appconfig.TlsTrustCfg{CABundlePath: path, PinnedPublicKeys: []string{"invalid"}})
+	defer store.close()
+	assert.Error(t, connect(store, server.URL), "pinning with no valid key rejects every server")
+}
+
+func TestTrustStoreReloadsCABundle(t *testing.T) {
+	server, bundle, _ := newTestTLSServer(t)
+	defer server.Close()
+	// the bundle is not valid yet when the store is created
+	path, cleanup := writeBundle(t, []byte("not a certificate"))
+	defer cleanup()
+
+	store := newTrustStore(log.NewMockLog(), appconfig.TlsTrustCfg{CABundlePath: path})
+	defer store.close()
+	assert.Error(t, connect(store, server.URL))
+
+	assert.NoError(t, ioutil.WriteFile(path, bundle, 0600))
+	deadline := time.Now().Add(5 * time.Second)
+	for connect(store, server.URL) != nil && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert.NoError(t, connect(store, server.URL))
+}
+
+func TestGetTLSConfigPerEndpointClass(t *testing.T) {
+	server, bundle, _ := newTestTLSServer(t)
+	defer server.Close()
+	path, cleanup := writeBundle(t, bundle)
+	defer cleanup()
+	defer applyTLSPolicy(log.NewMockLog(), appconfig.TlsCfg{})
+
+	applyTLSPolicy(log.NewMockLog(), appconfig.TlsCfg{Artifacts: appconfig.TlsTrustCfg{CABundlePath: path}})
+	assert.False(t, GetTLSConfig(ServiceEndpoint).InsecureSkipVerify)
+	assert.Nil(t, GetTLSConfig(ServiceEndpoint).VerifyConnection)
+	artifactConfig := GetTLSConfig(ArtifactEndpoint)
+	assert.NotNil(t, artifactConfig.VerifyConnection)
+
+	client := http.Client{Transport: &http.Transport{TLSClientConfig: artifactConfig}}
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
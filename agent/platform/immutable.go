@@ -0,0 +1,152 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+const (
+	// ImmutableOSMechanismHostContainer installs software in a host container, the host is only configured through its API
+	ImmutableOSMechanismHostContainer = "host-container"
+
+	// ImmutableOSMechanismRpmOstree layers rpm packages on the ostree deployment, they are applied at the next boot
+	ImmutableOSMechanismRpmOstree = "rpm-ostree"
+
+	// ImmutableOSMechanismVarBindMount installs software under the writable /var, bind mounted where it is expected
+	ImmutableOSMechanismVarBindMount = "var-bind-mount"
+
+	bottlerocketID         = "bottlerocket"
+	bottlerocketMarkerFile = "/.bottlerocket"
+	ostreeBootedFile       = "/run/ostree-booted"
+	mountInfoFile          = "/proc/self/mountinfo"
+	etcOSReleaseFile       = "/etc/os-release"
+)
+
+var immutableFileExists = fileutil.Exists
+var immutableReadFile = func(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	return string(content), err
+}
+
+// ImmutableOS describes a host whose root filesystem is read-only, such as Bottlerocket or Fedora CoreOS,
+// on which software can't be installed by writing to /usr or /opt.
+type ImmutableOS struct {
+	// Name is the distribution from os-release, such as bottlerocket or fedora-coreos
+	Name string
+	// Mechanism is how software is installed on the host
+	Mechanism string
+}
+
+// DetectImmutableOS returns the immutable OS of the host, false when its root filesystem is writable.
+func DetectImmutableOS() (ImmutableOS, bool) {
+	name := osReleaseName()
+	switch {
+	case name == bottlerocketID || immutableFileExists(bottlerocketMarkerFile):
+		return ImmutableOS{Name: bottlerocketID, Mechanism: ImmutableOSMechanismHostContainer}, true
+	case immutableFileExists(ostreeBootedFile):
+		return ImmutableOS{Name: name, Mechanism: ImmutableOSMechanismRpmOstree}, true
+	case IsReadOnlyPath("/"):
+		return ImmutableOS{Name: name, Mechanism: ImmutableOSMechanismVarBindMount}, true
+	}
+	return ImmutableOS{}, false
+}
+
+// Guidance tells how to achieve the action, which writes to the read-only root filesystem, on the immutable OS.
+func (o ImmutableOS) Guidance(action string) string {
+	name := o.Name
+	if name == "" {
+		name = "this host"
+	}
+	switch o.Mechanism {
+	case ImmutableOSMechanismHostContainer:
+		return fmt.Sprintf("%v is not supported on %v whose root filesystem is read-only: "+
+			"run the software in a host container or a bootstrap container, or change the settings of the host with apiclient", action, name)
+	case ImmutableOSMechanismRpmOstree:
+		return fmt.Sprintf("%v is not supported on %v whose root filesystem is managed by rpm-ostree: "+
+			"layer the rpm with 'rpm-ostree install' and reboot to apply it, or install the software under /var", action, name)
+	default:
+		return fmt.Sprintf("%v is not supported while the root filesystem of %v is read-only: "+
+			"install the software under /var and bind mount it where it is expected", action, name)
+	}
+}
+
+// IsReadOnlyFilesystemError returns true when the error message is the one of a write to a read-only filesystem.
+func IsReadOnlyFilesystemError(message string) bool {
+	return strings.Contains(strings.ToLower(message), "read-only file system")
+}
+
+// IsReadOnlyPath returns true when the path is on a filesystem mounted read-only, false when it can't be told.
+func IsReadOnlyPath(path string) bool {
+	mountInfo, err := immutableReadFile(mountInfoFile)
+	if err != nil {
+		return false
+	}
+	path = filepath.Clean(path)
+	mountPoint := ""
+	readOnly := false
+	for _, line := range strings.Split(mountInfo, "\n") {
+		// fields are: id, parent id, major:minor, root, mount point, mount options...
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		point := strings.Replace(fields[4], `\040`, " ", -1)
+		if !isUnderMountPoint(path, point) || len(point) < len(mountPoint) {
+			continue
+		}
+		// the last mount of the deepest mount point hides the previous ones
+		mountPoint = point
+		readOnly = false
+		for _, option := range strings.Split(fields[5], ",") {
+			if option == "ro" {
+				readOnly = true
+			}
+		}
+	}
+	return readOnly
+}
+
+func isUnderMountPoint(path string, mountPoint string) bool {
+	return mountPoint == "/" || path == mountPoint || strings.HasPrefix(path, mountPoint+"/")
+}
+
+// osReleaseName returns the ID of the distribution from os-release, with its variant for the editions of Fedora
+// such as fedora-coreos.
+func osReleaseName() string {
+	content, err := immutableReadFile(etcOSReleaseFile)
+	if err != nil {
+		return ""
+	}
+	var id, variant string
+	for _, line := range strings.Split(content, "\n") {
+		if key, value, found := strings.Cut(strings.TrimSpace(line), "="); found {
+			switch key {
+			case "ID":
+				id = strings.Trim(value, `"'`)
+			case "VARIANT_ID":
+				variant = strings.Trim(value, `"'`)
+			}
+		}
+	}
+	if id == "fedora" && variant != "" {
+		return id + "-" + variant
+	}
+	return id
+}
@@ -0,0 +1,90 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package platform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mountInfo = `22 1 259:1 / / ro,relatime shared:1 - ext4 /dev/root ro
+23 22 259:2 / /var rw,relatime shared:2 - ext4 /dev/nvme0n1p2 rw
+24 23 259:2 /opt /opt rw,relatime shared:3 - ext4 /dev/nvme0n1p2 rw
+25 22 0:21 / /var\040lib ro,relatime - tmpfs tmpfs ro`
+
+func withImmutableFiles(files map[string]string) func() {
+	fileExists, readFile := immutableFileExists, immutableReadFile
+	immutableFileExists = func(path string) bool {
+		_, found := files[path]
+		return found
+	}
+	immutableReadFile = func(path string) (string, error) {
+		if content, found := files[path]; found {
+			return content, nil
+		}
+		return "", fmt.Errorf("%v not found", path)
+	}
+	return func() {
+		immutableFileExists, immutableReadFile = fileExists, readFile
+	}
+}
+
+func TestIsReadOnlyPath(t *testing.T) {
+	defer withImmutableFiles(map[string]string{mountInfoFile: mountInfo})()
+
+	assert.True(t, IsReadOnlyPath("/"))
+	assert.True(t, IsReadOnlyPath("/usr/bin"))
+	assert.False(t, IsReadOnlyPath("/var/lib/amazon"))
+	assert.False(t, IsReadOnlyPath("/opt/aws"))
+	assert.True(t, IsReadOnlyPath("/variable"))
+	assert.True(t, IsReadOnlyPath("/var lib/data"))
+}
+
+func TestDetectImmutableOS(t *testing.T) {
+	restore := withImmutableFiles(map[string]string{bottlerocketMarkerFile: ""})
+	immutable, ok := DetectImmutableOS()
+	assert.True(t, ok)
+	assert.Equal(t, ImmutableOS{Name: "bottlerocket", Mechanism: ImmutableOSMechanismHostContainer}, immutable)
+	restore()
+
+	restore = withImmutableFiles(map[string]string{
+		ostreeBootedFile: "",
+		etcOSReleaseFile: "NAME=\"Fedora Linux\"\nID=fedora\nVARIANT_ID=coreos\n",
+	})
+	immutable, ok = DetectImmutableOS()
+	assert.True(t, ok)
+	assert.Equal(t, ImmutableOS{Name: "fedora-coreos", Mechanism: ImmutableOSMechanismRpmOstree}, immutable)
+	restore()
+
+	restore = withImmutableFiles(map[string]string{mountInfoFile: mountInfo, etcOSReleaseFile: "ID=\"flatcar\"\n"})
+	immutable, ok = DetectImmutableOS()
+	assert.True(t, ok)
+	assert.Equal(t, ImmutableOS{Name: "flatcar", Mechanism: ImmutableOSMechanismVarBindMount}, immutable)
+	restore()
+
+	restore = withImmutableFiles(map[string]string{mountInfoFile: "22 1 259:1 / / rw,relatime - ext4 /dev/root rw", etcOSReleaseFile: "ID=amzn\n"})
+	_, ok = DetectImmutableOS()
+	assert.False(t, ok)
+	restore()
+}
+
+func TestImmutableOSGuidance(t *testing.T) {
+	assert.Contains(t, ImmutableOS{Name: "bottlerocket", Mechanism: ImmutableOSMechanismHostContainer}.Guidance("Installing nginx"), "host container")
+	assert.Contains(t, ImmutableOS{Name: "fedora-coreos", Mechanism: ImmutableOSMechanismRpmOstree}.Guidance("Installing nginx"), "rpm-ostree install")
+	assert.Contains(t, ImmutableOS{Mechanism: ImmutableOSMechanismVarBindMount}.Guidance("Installing nginx"), "root filesystem of this host is read-only")
+	assert.True(t, IsReadOnlyFilesystemError("cp: cannot create regular file '/usr/bin/tool': Read-only file system"))
+	assert.False(t, IsReadOnlyFilesystemError("permission denied"))
+}
@@ -49,6 +49,17 @@ func SetInstanceID(instanceID string) error {
 	return nil
 }
 
+// EC2InstanceID returns the instance id from the EC2 instance metadata, ignoring any managed instance registration
+func EC2InstanceID() (string, error) {
+	return metadata.GetMetadata("instance-id")
+}
+
+// EC2InstanceTag returns the value of a tag of the instance from the EC2 instance metadata, which only has the tags
+// of instances allowing access to them in their metadata options
+func EC2InstanceTag(key string) (string, error) {
+	return metadata.GetMetadata("tags/instance/" + key)
+}
+
 // InstanceType returns the current instance type
 func InstanceType() (string, error) {
 	lock.RLock()
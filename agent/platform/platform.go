@@ -17,8 +17,11 @@ package platform
 import (
 	"fmt"
 	"net"
+	"os"
 	"sort"
+	"strconv"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 )
 
@@ -129,3 +132,21 @@ func (b byIndex) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
 func IsPlatformNanoServer(log log.T) (bool, error) {
 	return isPlatformNanoServer(log)
 }
+
+// IsContainerMode returns true if the agent runs in a container,
+// either because the container mode flag is set or because the container is detected.
+func IsContainerMode(log log.T) bool {
+	if enabled, _ := strconv.ParseBool(os.Getenv(appconfig.ContainerModeEnvVar)); enabled {
+		return true
+	}
+	isContainer, err := isPlatformContainer(log)
+	if err != nil {
+		log.Debugf("Failed to detect container - %v", err)
+	}
+	return isContainer
+}
+
+// SetupContainerNetworking makes the instance metadata reachable from the container network.
+func SetupContainerNetworking(log log.T) error {
+	return setupContainerNetworking(log)
+}
@@ -202,3 +202,11 @@ func fullyQualifiedDomainName() string {
 func isPlatformNanoServer(log log.T) (bool, error) {
 	return false, nil
 }
+
+func isPlatformContainer(log log.T) (bool, error) {
+	return false, nil
+}
+
+func setupContainerNetworking(log log.T) error {
+	return nil
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"golang.org/x/sys/windows/registry"
 )
 
 const caption = "Caption"
@@ -60,6 +61,36 @@ func isPlatformNanoServer(log log.T) (bool, error) {
 	return false, nil
 }
 
+// isPlatformContainer returns true if the ContainerType value exists, it's only set in Windows containers
+func isPlatformContainer(log log.T) (bool, error) {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control`, registry.QUERY_VALUE)
+	if err != nil {
+		return false, err
+	}
+	defer key.Close()
+
+	if _, _, err = key.GetIntegerValue("ContainerType"); err == registry.ErrNotExist {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// setupContainerNetworking routes the instance metadata address through the default gateway of the container,
+// the container network doesn't route link local addresses to the host by default.
+func setupContainerNetworking(log log.T) error {
+	script := `$ErrorActionPreference = 'Stop'
+if (-not (Get-NetRoute -DestinationPrefix '169.254.169.254/32' -ErrorAction SilentlyContinue)) {
+	$gateway = Get-NetRoute -DestinationPrefix '0.0.0.0/0' | Sort-Object -Property RouteMetric | Select-Object -First 1
+	New-NetRoute -DestinationPrefix '169.254.169.254/32' -InterfaceIndex $gateway.ifIndex -NextHop $gateway.NextHop | Out-Null
+}`
+	cmdOut, err := exec.Command(appconfig.PowerShellPluginCommandName, "-NoProfile", "-NonInteractive", "-Command", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to add the instance metadata route - %v, %v", err, string(cmdOut))
+	}
+	log.Debugf(commandOutputMessage, string(cmdOut))
+	return nil
+}
+
 func getPlatformName(log log.T) (value string, err error) {
 	return getPlatformDetails(caption, log)
 }
@@ -16,12 +16,17 @@
 package archive
 
 import (
+	"context"
+
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
 )
 
 const (
 	PackageArchiveBirdwatcher = "birdwatcher"
 	PackageArchiveDocument    = "document"
+	PackageArchiveLocal       = "local"
+	PackageArchiveS3          = "s3"
+	PackageArchiveOci         = "oci"
 )
 
 type File struct {
@@ -32,7 +37,13 @@ type File struct {
 type IPackageArchive interface {
 	Name() string
 	GetResourceVersion(packageName string, packageVersion string) (name string, version string)
-	DownloadArchiveInfo(packageName string, version string) (string, error)
-	GetFileDownloadLocation(file *File, packageName string, version string) (string, error)
+	DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error)
+	GetFileDownloadLocation(ctx context.Context, file *File, packageName string, version string) (string, error)
 	GetResourceArn(manifest *birdwatcher.Manifest) string
 }
+
+// IFallbackLocations is implemented by the archives whose files have several download locations, the location
+// returned by GetFileDownloadLocation comes first and the others are tried in order when a download fails
+type IFallbackLocations interface {
+	GetFileDownloadLocations(ctx context.Context, file *File, packageName string, version string) ([]string, error)
+}
@@ -15,6 +15,8 @@
 package birdwatcher
 
 import (
+	"context"
+
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 )
@@ -26,7 +28,7 @@ type networkMock struct {
 	downloadError  error
 }
 
-func (p *networkMock) Download(log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+func (p *networkMock) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
 	p.downloadInput = input
 	return p.downloadOutput, p.downloadError
 }
@@ -16,8 +16,11 @@
 package birdwatcherarchive
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
@@ -25,6 +28,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
+// instanceRegion returns the region whose download locations are preferred
+var instanceRegion = platform.Region
+
 type PackageArchive struct {
 	facadeClient facade.BirdwatcherFacade
 	manifest     string
@@ -56,10 +62,11 @@ func (ba *PackageArchive) GetResourceVersion(packageName string, packageVersion
 }
 
 // DownloadArtifactInfo downloads the manifest for the original birwatcher service
-func (ba *PackageArchive) DownloadArchiveInfo(packageName string, version string) (string, error) {
+func (ba *PackageArchive) DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error) {
 
-	if ba.manifest == "" {
-		resp, err := ba.facadeClient.GetManifest(
+	if !ba.isCachedManifest(version) {
+		resp, err := ba.facadeClient.GetManifestWithContext(
+			ctx,
 			&ssm.GetManifestInput{
 				PackageName:    &packageName,
 				PackageVersion: &version,
@@ -74,12 +81,50 @@ func (ba *PackageArchive) DownloadArchiveInfo(packageName string, version string
 	return ba.manifest, nil
 }
 
-// GetFileDownloadLocation obtains the location of the file in the archive
-func (ba *PackageArchive) GetFileDownloadLocation(file *archive.File, packageName string, version string) (string, error) {
+// isCachedManifest returns true when the manifest downloaded last is the manifest of the version, the manifest of
+// another version, such as the version of a rollout channel, is downloaded again
+func (ba *PackageArchive) isCachedManifest(version string) bool {
+	if ba.manifest == "" {
+		return false
+	}
+	if packageservice.IsLatest(version) {
+		return true
+	}
+	var cached struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(ba.manifest), &cached); err != nil {
+		return true
+	}
+	return cached.Version == version
+}
+
+// GetFileDownloadLocation obtains the location of the file in the archive, the copy of the file in the region of
+// the instance when the manifest declares one
+func (ba *PackageArchive) GetFileDownloadLocation(ctx context.Context, file *archive.File, packageName string, version string) (string, error) {
+	locations, err := ba.GetFileDownloadLocations(ctx, file, packageName, version)
+	if err != nil {
+		return "", err
+	}
+	return locations[0], nil
+}
+
+// GetFileDownloadLocations returns the locations of the file in order of preference, the region of the instance
+// is only looked up for files with regional copies
+func (ba *PackageArchive) GetFileDownloadLocations(ctx context.Context, file *archive.File, packageName string, version string) ([]string, error) {
 	if file == nil {
-		return "", fmt.Errorf("file is empty")
+		return nil, fmt.Errorf("file is empty")
+	}
+	if len(file.Info.RegionalDownloadLocations) == 0 {
+		return []string{file.Info.DownloadLocation}, nil
+	}
+	// without the region of the instance the fallback regions are tried
+	region, _ := instanceRegion()
+	locations := file.Info.DownloadLocations(region)
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("file %v has no download location for region %v", file.Name, region)
 	}
-	return file.Info.DownloadLocation, nil
+	return locations, nil
 }
 
 // GetResourceArn returns the packageArn that is found i nthe manifest file
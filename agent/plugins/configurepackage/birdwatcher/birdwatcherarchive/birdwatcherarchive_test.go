@@ -16,10 +16,16 @@
 package birdwatcherarchive
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -71,3 +77,71 @@ func TestArchiveName(t *testing.T) {
 	assert.Equal(t, archive.PackageArchiveBirdwatcher, testArchive.Name())
 
 }
+
+func TestDownloadArchiveInfoOfAnotherVersion(t *testing.T) {
+	latest := `{"version": "1.0.0"}`
+	beta := `{"version": "1.1.0"}`
+	mockBWFacade := facade.FacadeStub{GetManifestOutput: &ssm.GetManifestOutput{Manifest: aws.String(beta)}}
+	bwArchive := New(&mockBWFacade, latest)
+
+	manifest, err := bwArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "latest")
+	assert.NoError(t, err)
+	assert.Equal(t, latest, manifest)
+	manifest, err = bwArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, latest, manifest)
+	assert.Nil(t, mockBWFacade.GetManifestInput)
+
+	manifest, err = bwArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "1.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, beta, manifest)
+	assert.Equal(t, "1.1.0", aws.StringValue(mockBWFacade.GetManifestInput.PackageVersion))
+}
+
+func setInstanceRegion(region string, err error) func() {
+	instanceRegion = func() (string, error) { return region, err }
+	return func() { instanceRegion = platform.Region }
+}
+
+func TestGetFileDownloadLocationsPrefersRegion(t *testing.T) {
+	file := &archive.File{Name: "app.zip", Info: birdwatcher.FileInfo{
+		DownloadLocation:          "https://global/app.zip",
+		RegionalDownloadLocations: map[string]string{"us-east-1": "https://use1/app.zip", "eu-west-1": "https://euw1/app.zip", "us-west-2": "https://usw2/app.zip"},
+		FallbackRegions:           []string{"us-west-2", "us-east-1"},
+	}}
+	bwArchive := New(&facade.FacadeStub{}, "")
+
+	for _, test := range []struct {
+		region    string
+		err       error
+		locations []string
+	}{
+		{"eu-west-1", nil, []string{"https://euw1/app.zip", "https://usw2/app.zip", "https://use1/app.zip", "https://global/app.zip"}},
+		{"us-east-1", nil, []string{"https://use1/app.zip", "https://usw2/app.zip", "https://global/app.zip"}},
+		{"ap-south-1", nil, []string{"https://usw2/app.zip", "https://use1/app.zip", "https://global/app.zip"}},
+		{"", errors.New("no metadata"), []string{"https://usw2/app.zip", "https://use1/app.zip", "https://global/app.zip"}},
+	} {
+		restore := setInstanceRegion(test.region, test.err)
+		locations, err := bwArchive.(archive.IFallbackLocations).GetFileDownloadLocations(context.Background(), file, "app", "1.0.0")
+		assert.NoError(t, err)
+		assert.Equal(t, test.locations, locations, test.region)
+		location, err := bwArchive.GetFileDownloadLocation(context.Background(), file, "app", "1.0.0")
+		assert.NoError(t, err)
+		assert.Equal(t, test.locations[0], location)
+		restore()
+	}
+}
+
+func TestGetFileDownloadLocationWithoutRegionalLocations(t *testing.T) {
+	defer setInstanceRegion("", errors.New("the region is not looked up"))()
+	bwArchive := New(&facade.FacadeStub{}, "")
+
+	location, err := bwArchive.GetFileDownloadLocation(context.Background(), &archive.File{Info: birdwatcher.FileInfo{DownloadLocation: "https://global/app.zip"}}, "app", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://global/app.zip", location)
+
+	_, err = bwArchive.GetFileDownloadLocation(context.Background(), &archive.File{Name: "app.zip", Info: birdwatcher.FileInfo{
+		RegionalDownloadLocations: map[string]string{"us-east-1": "https://use1/app.zip"},
+	}}, "app", "1.0.0")
+	assert.Error(t, err)
+}
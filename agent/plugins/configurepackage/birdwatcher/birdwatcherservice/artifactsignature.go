@@ -0,0 +1,198 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	_ "crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// Formats of the detached signatures of the artifacts
+const (
+	SignatureFormatGpg      = "gpg"
+	SignatureFormatSigstore = "sigstore"
+)
+
+// artifactSigning returns the keys the artifact signatures are verified with
+var artifactSigning = func() appconfig.ArtifactSigningCfg {
+	if appCfg, err := appconfig.Config(false); err == nil {
+		return appCfg.Birdwatcher.ArtifactSigning
+	}
+	return appconfig.ArtifactSigningCfg{}
+}
+
+// sigstoreHashes are the digest algorithms of the Sigstore bundles
+var sigstoreHashes = map[string]crypto.Hash{
+	"SHA2_256": crypto.SHA256,
+	"SHA2_384": crypto.SHA384,
+	"SHA2_512": crypto.SHA512,
+}
+
+// sigstoreBundle is the part of a Sigstore bundle verified against the pinned key,
+// its transparency log entries and the certificate of a keyless signature are not used
+type sigstoreBundle struct {
+	MediaType        string `json:"mediaType"`
+	MessageSignature *struct {
+		MessageDigest struct {
+			Algorithm string `json:"algorithm"`
+			Digest    []byte `json:"digest"`
+		} `json:"messageDigest"`
+		Signature []byte `json:"signature"`
+	} `json:"messageSignature"`
+}
+
+// verifyArtifactSignature downloads the detached signature the manifest references for a downloaded artifact and
+// verifies the artifact with the pinned key of the signature format. Without a pinned key the signature is not verified,
+// unless signed packages are required, which also rejects the unsigned artifacts.
+func verifyArtifactSignature(ctx context.Context, ds *PackageService, tracer trace.Tracer, manifest *birdwatcher.Manifest, file *archive.File, filePath string, packageName string, version string) (err error) {
+	signing := artifactSigning()
+	signature := file.Info.Signature
+	if signature == nil {
+		if signing.RequireSigned {
+			return fmt.Errorf("artifact %v is not signed and signed packages are required", file.Name)
+		}
+		return nil
+	}
+
+	trace := tracer.BeginSection(fmt.Sprintf("verify signature of %v", file.Name))
+	defer func() {
+		if err != nil {
+			trace.WithError(err)
+		}
+		trace.End()
+	}()
+
+	format := strings.ToLower(signature.Format)
+	var keyPath string
+	switch format {
+	case SignatureFormatGpg:
+		keyPath = signing.GpgKeyringPath
+	case SignatureFormatSigstore:
+		keyPath = signing.SigstorePublicKeyPath
+	default:
+		if signing.RequireSigned {
+			return fmt.Errorf("signature format %q of artifact %v is not supported", signature.Format, file.Name)
+		}
+		trace.AppendInfof("signature format %q is not supported, the signature is not verified", signature.Format)
+		return nil
+	}
+	if keyPath == "" {
+		if signing.RequireSigned {
+			return fmt.Errorf("no %v key is configured to verify artifact %v with", format, file.Name)
+		}
+		trace.AppendInfof("no %v key is configured, the signature is not verified", format)
+		return nil
+	}
+
+	var info *birdwatcher.FileInfo
+	if manifest != nil {
+		info = manifest.Files[signature.File]
+	}
+	if info == nil {
+		return fmt.Errorf("signature %v of artifact %v is not a file of the manifest", signature.File, file.Name)
+	}
+	signaturePath, err := fetchFile(ctx, ds, tracer, &archive.File{Name: signature.File, Info: *info}, packageName, version)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(signaturePath)
+	content, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return fmt.Errorf("failed to read signature %v: %v", signature.File, err)
+	}
+
+	if format == SignatureFormatGpg {
+		err = verifyGpgSignature(keyPath, filePath, content)
+	} else {
+		err = verifySigstoreBundle(keyPath, filePath, content)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify the %v signature of artifact %v: %v", format, file.Name, err)
+	}
+	return nil
+}
+
+// verifySigstoreBundle verifies the message signature of a Sigstore bundle made for a file with the pinned public key
+func verifySigstoreBundle(publicKeyPath string, filePath string, content []byte) error {
+	publicKey, err := loadPublicKey(publicKeyPath)
+	if err != nil {
+		return err
+	}
+	var bundle sigstoreBundle
+	if err = json.Unmarshal(content, &bundle); err != nil {
+		return fmt.Errorf("invalid Sigstore bundle: %v", err)
+	}
+	if bundle.MessageSignature == nil {
+		return errors.New("the Sigstore bundle has no message signature, DSSE envelopes are not supported")
+	}
+	algorithm := bundle.MessageSignature.MessageDigest.Algorithm
+	if algorithm == "" {
+		algorithm = "SHA2_256"
+	}
+	digestHash, ok := sigstoreHashes[algorithm]
+	if !ok {
+		return fmt.Errorf("unsupported Sigstore digest algorithm %v", algorithm)
+	}
+	digest := digestHash.New()
+	if err = hashFile(digest, filePath); err != nil {
+		return err
+	}
+	sum := digest.Sum(nil)
+	if expected := bundle.MessageSignature.MessageDigest.Digest; len(expected) > 0 && !bytes.Equal(expected, sum) {
+		return errors.New("the digest of the Sigstore bundle does not match the artifact")
+	}
+
+	matched := false
+	if key, ok := publicKey.(ed25519.PublicKey); ok {
+		// ed25519 signs the artifact rather than its digest
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		matched = ed25519.Verify(key, data, bundle.MessageSignature.Signature)
+	} else if matched, err = verifyDigestSignature(publicKey, digestHash, sum, bundle.MessageSignature.Signature); err != nil {
+		return err
+	}
+	if !matched {
+		return errors.New("signature does not match the artifact")
+	}
+	return nil
+}
+
+// hashFile writes the content of a file to a hash
+func hashFile(digest hash.Hash, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(digest, file)
+	return err
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+// fileNetworkStub downloads every url to the local file staged for it
+type fileNetworkStub struct {
+	files map[string]string
+}
+
+func (n *fileNetworkStub) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	if path, ok := n.files[input.SourceURL]; ok {
+		return artifact.DownloadOutput{LocalFilePath: path}, nil
+	}
+	return artifact.DownloadOutput{}, fmt.Errorf("%v is not staged", input.SourceURL)
+}
+
+func TestVerifySigstoreBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigstorebundle")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	publicKey, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKeyPath := writeTestFile(t, dir, "sigstore.pem", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})))
+	artifactPath := writeTestFile(t, dir, "artifact.zip", testGpgArtifact)
+	tamperedPath := writeTestFile(t, dir, "tampered.zip", "package artifact content?\n")
+
+	digest := sha256.Sum256([]byte(testGpgArtifact))
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	bundle := func(algorithm string, digest []byte, signature []byte) []byte {
+		content, _ := json.Marshal(map[string]interface{}{
+			"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.2",
+			"messageSignature": map[string]interface{}{
+				"messageDigest": map[string]interface{}{"algorithm": algorithm, "digest": digest},
+				"signature":     signature,
+			},
+		})
+		return content
+	}
+
+	testCases := []struct {
+		name        string
+		artifact    string
+		bundle      []byte
+		expectedErr bool
+	}{
+		{"valid bundle", artifactPath, bundle("SHA2_256", digest[:], signature), false},
+		{"bundle without digest", artifactPath, bundle("", nil, signature), false},
+		{"tampered artifact", tamperedPath, bundle("SHA2_256", nil, signature), true},
+		{"digest mismatch", tamperedPath, bundle("SHA2_256", digest[:], signature), true},
+		{"unsupported digest", artifactPath, bundle("MD5", digest[:], signature), true},
+		{"dsse envelope", artifactPath, []byte(`{"dsseEnvelope": {}}`), true},
+		{"not a bundle", artifactPath, []byte("not a bundle"), true},
+	}
+	for _, testCase := range testCases {
+		err := verifySigstoreBundle(publicKeyPath, testCase.artifact, testCase.bundle)
+		if testCase.expectedErr {
+			assert.Error(t, err, testCase.name)
+		} else {
+			assert.NoError(t, err, testCase.name)
+		}
+	}
+}
+
+func TestDownloadFileVerifiesSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "artifactsigning")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyring := writeTestFile(t, dir, "keyring.asc", testGpgKeyring)
+	defer func(original func() appconfig.ArtifactSigningCfg) { artifactSigning = original }(artifactSigning)
+
+	signed := &birdwatcher.FileSignature{Format: SignatureFormatGpg, File: "package.zip.asc"}
+	testCases := []struct {
+		name        string
+		signing     appconfig.ArtifactSigningCfg
+		signature   *birdwatcher.FileSignature
+		content     string
+		expectedErr bool
+	}{
+		{"unsigned", appconfig.ArtifactSigningCfg{}, nil, testGpgArtifact, false},
+		{"unsigned when signed packages are required", appconfig.ArtifactSigningCfg{RequireSigned: true}, nil, testGpgArtifact, true},
+		{"valid signature", appconfig.ArtifactSigningCfg{GpgKeyringPath: keyring}, signed, testGpgArtifact, false},
+		{"tampered artifact", appconfig.ArtifactSigningCfg{GpgKeyringPath: keyring}, signed, "tampered", true},
+		{"no key configured", appconfig.ArtifactSigningCfg{}, signed, "tampered", false},
+		{"no key configured when signed packages are required", appconfig.ArtifactSigningCfg{RequireSigned: true}, signed, testGpgArtifact, true},
+		{"signature not in the manifest", appconfig.ArtifactSigningCfg{GpgKeyringPath: keyring},
+			&birdwatcher.FileSignature{Format: SignatureFormatGpg, File: "missing.asc"}, testGpgArtifact, true},
+		{"unsupported format", appconfig.ArtifactSigningCfg{RequireSigned: true, GpgKeyringPath: keyring},
+			&birdwatcher.FileSignature{Format: "minisign", File: "package.zip.asc"}, testGpgArtifact, true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			signing := testCase.signing
+			artifactSigning = func() appconfig.ArtifactSigningCfg { return signing }
+			artifactPath := writeTestFile(t, dir, "package.zip", testCase.content)
+			signaturePath := writeTestFile(t, dir, "package.zip.asc", testGpgEd25519Signature)
+			birdwatcher.Networkdep = &fileNetworkStub{files: map[string]string{
+				"https://example.com/package.zip":     artifactPath,
+				"https://example.com/package.zip.asc": signaturePath,
+			}}
+			manifest := &birdwatcher.Manifest{Files: map[string]*birdwatcher.FileInfo{
+				"package.zip.asc": {DownloadLocation: "https://example.com/package.zip.asc"},
+			}}
+			file := &archive.File{Name: "package.zip", Info: birdwatcher.FileInfo{
+				DownloadLocation: "https://example.com/package.zip",
+				Signature:        testCase.signature,
+			}}
+			ds := &PackageService{
+				manifestCache: packageservice.ManifestCacheMemNew(),
+				collector:     &envdetect.CollectorMock{},
+				archive:       birdwatcherarchive.New(&facade.FacadeStub{}, ""),
+			}
+
+			tracer := trace.NewTracer(log.NewMockLog())
+			tracer.BeginSection("test segment root")
+
+			filePath, err := downloadFile(context.Background(), ds, tracer, manifest, file, "packagename", "1.0.0")
+
+			if testCase.expectedErr {
+				assert.Error(t, err)
+				assert.Empty(t, filePath)
+				assert.False(t, fileExists(artifactPath), "a rejected artifact is removed")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, artifactPath, filePath)
+			}
+		})
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// maxParallelAttachmentDownloads bounds the attachments of a package downloaded at the same time
+const maxParallelAttachmentDownloads = 4
+
+// attachmentDownload is an attachment of the package and the url it is downloaded from
+type attachmentDownload struct {
+	file      *archive.File
+	sourceURL string
+	localPath string
+}
+
+// DownloadAttachments downloads the attachments of the platform matching package into the target directory.
+// Attachments are downloaded in parallel with resumable downloads and verified against their checksums,
+// after checking the disk has room for all of them.
+func (ds *PackageService) DownloadAttachments(ctx context.Context, tracer trace.Tracer, packageName string, version string, targetDirectory string) (err error) {
+	trace := tracer.BeginSection("download attachments")
+	defer trace.EndWithError(&err)
+
+	manifest, err := readManifestFromCache(ds.manifestCache, packageName, version)
+	if err != nil {
+		if manifest, _, err = downloadManifest(ctx, ds, tracer, packageName, version); err != nil {
+			return fmt.Errorf("failed to download the manifest: %v", err)
+		}
+	}
+	pkginfo, err := ds.extractPackageInfo(tracer, manifest)
+	if err != nil {
+		return fmt.Errorf("failed to find platform: %v", err)
+	}
+	if len(pkginfo.Attachments) == 0 {
+		return nil
+	}
+
+	downloads, err := attachmentFiles(manifest, pkginfo)
+	if err != nil {
+		return err
+	}
+	if err = checkDiskSpace(tracer, appconfig.DownloadRoot, attachmentsSize(downloads)); err != nil {
+		return err
+	}
+	// the locations are resolved one at a time, archives fetch them lazily
+	for _, download := range downloads {
+		if download.sourceURL, err = ds.archive.GetFileDownloadLocation(ctx, download.file, packageName, version); err != nil {
+			return fmt.Errorf("failed to get the location of attachment %v: %v", download.file.Name, err)
+		}
+	}
+
+	if ds.progress != nil {
+		ds.progress.ReportPhase(fmt.Sprintf("Downloading %v attachments of package %v version %v", len(downloads), packageName, version))
+	}
+	if err = downloadAttachmentsInParallel(ctx, tracer, downloads); err != nil {
+		return err
+	}
+	for _, download := range downloads {
+		if err = verifyArtifactSignature(ctx, ds, tracer, manifest, download.file, download.localPath, packageName, version); err != nil {
+			os.Remove(download.localPath)
+			return err
+		}
+	}
+
+	for _, download := range downloads {
+		if _, err = fileutil.MoveAndRenameFile(filepath.Dir(download.localPath), filepath.Base(download.localPath), targetDirectory, download.file.Name); err != nil {
+			return fmt.Errorf("failed to move attachment %v to the package: %v", download.file.Name, err)
+		}
+	}
+	trace.AppendInfof("downloaded %v attachments", len(downloads))
+	return nil
+}
+
+// attachmentFiles returns the manifest files of the attachments, every attachment must declare its checksums
+func attachmentFiles(manifest *birdwatcher.Manifest, pkginfo *birdwatcher.PackageInfo) ([]*attachmentDownload, error) {
+	var downloads []*attachmentDownload
+	for _, name := range pkginfo.Attachments {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return nil, fmt.Errorf("%q is not a valid attachment name", name)
+		}
+		fileInfo, ok := manifest.Files[name]
+		if !ok || fileInfo == nil {
+			return nil, fmt.Errorf("attachment %v is not a file of the manifest", name)
+		}
+		if len(fileInfo.Checksums) == 0 {
+			return nil, fmt.Errorf("attachment %v has no checksum", name)
+		}
+		downloads = append(downloads, &attachmentDownload{file: &archive.File{Name: name, Info: *fileInfo}})
+	}
+	return downloads, nil
+}
+
+// attachmentsSize returns the bytes of all the attachments, checked against the disk before any download
+func attachmentsSize(downloads []*attachmentDownload) int64 {
+	var total int64
+	for _, download := range downloads {
+		total += int64(download.file.Info.Size)
+	}
+	return total
+}
+
+// downloadAttachmentsInParallel downloads the attachments, the remaining downloads are cancelled after the first
+// failure and their downloaded ranges are kept for the next attempt
+func downloadAttachmentsInParallel(parent context.Context, tracer trace.Tracer, downloads []*attachmentDownload) error {
+	log := tracer.CurrentTrace().Logger
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var lock sync.Mutex
+	var firstErr error
+	slots := make(chan struct{}, maxParallelAttachmentDownloads)
+	var wait sync.WaitGroup
+	for _, download := range downloads {
+		wait.Add(1)
+		go func(download *attachmentDownload) {
+			defer wait.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			output, err := downloadWithRetry(ctx, log, artifact.DownloadInput{
+				SourceURL:       download.sourceURL,
+				SourceChecksums: download.file.Info.Checksums,
+				Resumable:       true,
+				Cached:          true,
+			})
+			if err == nil && output.LocalFilePath == "" {
+				err = fmt.Errorf("no file downloaded")
+			}
+			if err != nil {
+				// the first failure is reported rather than the downloads it cancelled
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to download attachment %v: %v", download.file.Name, err)
+				}
+				lock.Unlock()
+				cancel()
+				return
+			}
+			download.localPath = output.LocalFilePath
+		}(download)
+	}
+	wait.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := parent.Err(); err != nil {
+		return fmt.Errorf("failed to download attachments: %v", err)
+	}
+	return nil
+}
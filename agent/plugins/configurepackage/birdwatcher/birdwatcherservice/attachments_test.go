@@ -0,0 +1,247 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+const attachmentsManifest = `
+{
+	"packages": {
+		"abc": {
+			"567": {
+				"xyz": {
+					"file": "test.zip",
+					"attachments": ["data1.bin", "data2.bin"]
+				}
+			}
+		}
+	},
+	"files": {
+		"test.zip": {
+			"downloadLocation": "https://example.com/test.zip",
+			"checksums": {"sha256": "aaa"}
+		},
+		"data1.bin": {
+			"downloadLocation": "https://example.com/data1.bin",
+			"checksums": {"sha256": "bbb"},
+			"size": 100
+		},
+		"data2.bin": {
+			"downloadLocation": "https://example.com/data2.bin",
+			"checksums": {"sha256": "ccc"},
+			"size": 200
+		}
+	}
+}
+`
+
+// attachmentNetworkMock writes a file for every download and is safe for parallel downloads
+type attachmentNetworkMock struct {
+	lock      sync.Mutex
+	directory string
+	inputs    []artifact.DownloadInput
+	failURL   string
+}
+
+func (p *attachmentNetworkMock) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	p.lock.Lock()
+	p.inputs = append(p.inputs, input)
+	p.lock.Unlock()
+	if input.SourceURL == p.failURL {
+		return artifact.DownloadOutput{}, errors.New("connection reset")
+	}
+	localPath := filepath.Join(p.directory, filepath.Base(input.SourceURL))
+	if err := ioutil.WriteFile(localPath, []byte(input.SourceURL), 0600); err != nil {
+		return artifact.DownloadOutput{}, err
+	}
+	return artifact.DownloadOutput{LocalFilePath: localPath}, nil
+}
+
+func newAttachmentsTestService(manifest string) *PackageService {
+	cache := packageservice.ManifestCacheMemNew()
+	cache.WriteManifest("packageName", "1.0.0", []byte(manifest))
+	return &PackageService{
+		manifestCache: cache,
+		collector:     testCollector(),
+		archive:       birdwatcherarchive.New(&facade.FacadeStub{}, manifest),
+	}
+}
+
+func TestDownloadAttachments(t *testing.T) {
+	downloadDirectory, _ := ioutil.TempDir("", "attachmentsdownload")
+	defer os.RemoveAll(downloadDirectory)
+	targetDirectory, _ := ioutil.TempDir("", "attachmentstarget")
+	defer os.RemoveAll(targetDirectory)
+
+	network := &attachmentNetworkMock{directory: downloadDirectory}
+	birdwatcher.Networkdep = network
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	ds := newAttachmentsTestService(attachmentsManifest)
+	err := ds.DownloadAttachments(context.Background(), tracer, "packageName", "1.0.0", targetDirectory)
+
+	assert.NoError(t, err)
+	assert.Len(t, network.inputs, 2)
+	for _, input := range network.inputs {
+		assert.True(t, input.Resumable)
+		assert.True(t, input.Cached)
+		assert.NotEmpty(t, input.SourceChecksums)
+	}
+	for _, name := range []string{"data1.bin", "data2.bin"} {
+		content, err := ioutil.ReadFile(filepath.Join(targetDirectory, name))
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/"+name, string(content))
+	}
+}
+
+func TestDownloadAttachmentsNone(t *testing.T) {
+	manifest := `{"packages": {"abc": {"567": {"xyz": {"file": "test.zip"}}}}, "files": {"test.zip": {"downloadLocation": "https://example.com/test.zip"}}}`
+	network := &attachmentNetworkMock{}
+	birdwatcher.Networkdep = network
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	ds := newAttachmentsTestService(manifest)
+	err := ds.DownloadAttachments(context.Background(), tracer, "packageName", "1.0.0", "target")
+
+	assert.NoError(t, err)
+	assert.Empty(t, network.inputs)
+}
+
+func TestDownloadAttachmentsDownloadFailure(t *testing.T) {
+	downloadDirectory, _ := ioutil.TempDir("", "attachmentsdownload")
+	defer os.RemoveAll(downloadDirectory)
+	targetDirectory, _ := ioutil.TempDir("", "attachmentstarget")
+	defer os.RemoveAll(targetDirectory)
+
+	network := &attachmentNetworkMock{directory: downloadDirectory, failURL: "https://example.com/data2.bin"}
+	birdwatcher.Networkdep = network
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	ds := newAttachmentsTestService(attachmentsManifest)
+	err := ds.DownloadAttachments(context.Background(), tracer, "packageName", "1.0.0", targetDirectory)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "data2.bin")
+	assert.False(t, fileutil.Exists(filepath.Join(targetDirectory, "data2.bin")))
+}
+
+func TestDownloadAttachmentsUnsigned(t *testing.T) {
+	downloadDirectory, _ := ioutil.TempDir("", "attachmentsdownload")
+	defer os.RemoveAll(downloadDirectory)
+	targetDirectory, _ := ioutil.TempDir("", "attachmentstarget")
+	defer os.RemoveAll(targetDirectory)
+	defer func(original func() appconfig.ArtifactSigningCfg) { artifactSigning = original }(artifactSigning)
+	artifactSigning = func() appconfig.ArtifactSigningCfg { return appconfig.ArtifactSigningCfg{RequireSigned: true} }
+
+	birdwatcher.Networkdep = &attachmentNetworkMock{directory: downloadDirectory}
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	ds := newAttachmentsTestService(attachmentsManifest)
+	err := ds.DownloadAttachments(context.Background(), tracer, "packageName", "1.0.0", targetDirectory)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not signed")
+	assert.False(t, fileutil.Exists(filepath.Join(targetDirectory, "data1.bin")))
+}
+
+func TestDownloadAttachmentsInsufficientDiskSpace(t *testing.T) {
+	network := &attachmentNetworkMock{}
+	birdwatcher.Networkdep = network
+	getDiskSpaceInfo = func(path string) (fileutil.DiskSpaceInfo, error) {
+		return fileutil.DiskSpaceInfo{AvailBytes: 250}, nil
+	}
+	defer func() { getDiskSpaceInfo = fileutil.GetDiskSpaceInfoOfPath }()
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	ds := newAttachmentsTestService(attachmentsManifest)
+	err := ds.DownloadAttachments(context.Background(), tracer, "packageName", "1.0.0", "target")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "300 bytes")
+	assert.Equal(t, contracts.ErrorCodeDiskFull, contracts.ErrorCodeOf(err))
+	assert.Empty(t, network.inputs)
+}
+
+func TestAttachmentFiles(t *testing.T) {
+	manifest := &birdwatcher.Manifest{
+		Files: map[string]*birdwatcher.FileInfo{
+			"data.bin":       {Checksums: map[string]string{"sha256": "abc"}},
+			"nochecksum.bin": {},
+		},
+	}
+	data := []struct {
+		name        string
+		attachments []string
+		expectedErr bool
+	}{
+		{"valid attachment", []string{"data.bin"}, false},
+		{"missing checksum", []string{"nochecksum.bin"}, true},
+		{"missing file", []string{"missing.bin"}, true},
+		{"path in the name", []string{"../data.bin"}, true},
+		{"parent directory", []string{".."}, true},
+	}
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			downloads, err := attachmentFiles(manifest, &birdwatcher.PackageInfo{Attachments: testdata.attachments})
+			if testdata.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Len(t, downloads, len(testdata.attachments))
+			}
+		})
+	}
+}
+
+func TestDescribeArtifact(t *testing.T) {
+	network := &attachmentNetworkMock{}
+	birdwatcher.Networkdep = network
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	ds := newAttachmentsTestService(attachmentsManifest)
+	artifactInfo, err := ds.DescribeArtifact(context.Background(), tracer, "packageName", "1.0.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "test.zip", artifactInfo.FileName)
+	assert.Equal(t, int64(300), artifactInfo.Size)
+	assert.Equal(t, map[string]string{"sha256": "aaa"}, artifactInfo.Checksums)
+	assert.Equal(t, []string{"data1.bin", "data2.bin"}, artifactInfo.Attachments)
+	assert.Empty(t, network.inputs)
+}
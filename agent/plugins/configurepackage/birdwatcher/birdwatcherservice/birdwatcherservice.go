@@ -16,30 +16,66 @@ package birdwatcherservice
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/documentarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade/retryer"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/localarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/ociarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/s3archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
-// NanoTime is helper interface for mocking time
+// platformAliases returns the platforms whose packages are installed on a platform the manifest has no packages for
+var platformAliases = func() map[string]string {
+	if appCfg, err := appconfig.Config(false); err == nil {
+		return appCfg.Birdwatcher.PlatformAliases
+	}
+	return nil
+}
+
+// architectureAliases are the names the same architecture is published under
+var architectureAliases = map[string][]string{
+	constants.ArchitectureX86_64: {"amd64", "x64"},
+	constants.ArchitectureArm64:  {"aarch64"},
+}
+
+// timingSourceMonotonic and timingSourceWallClock are the clocks the timings of a result can be measured with
+const (
+	timingSourceMonotonic = "monotonic"
+	timingSourceWallClock = "wallclock"
+)
+
+// NanoTime is helper interface for mocking time, the monotonic clock is the clock of the traces
 type NanoTime interface {
 	NowUnixNano() int64
+	MonotonicNano() int64
+	AnchorUnixNano() int64
 }
 
 type TimeImpl struct {
+	trace.TimeImpl
 }
 
 func (t *TimeImpl) NowUnixNano() int64 {
@@ -54,20 +90,45 @@ type PackageService struct {
 	collector     envdetect.Collector
 	timeProvider  NanoTime
 	archive       archive.IPackageArchive
+	signing       appconfig.ManifestSigningCfg
+	progress      packageservice.ProgressReporter
+	resultQueue   *resultQueue
+	// artifactCache is the directory of the artifacts deltas are applied to, there are no deltas when it is empty
+	artifactCache string
+	// channel is the rollout channel selecting the version installed when the latest version is requested
+	channel string
 }
 
-func NewBirdwatcherArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, birdwatcherManifest string) packageservice.PackageService {
+func NewBirdwatcherArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, birdwatcherManifest string, signing appconfig.ManifestSigningCfg) packageservice.PackageService {
 	pkgArchive := birdwatcherarchive.New(facadeClient, birdwatcherManifest)
-	return New(pkgArchive, facadeClient, manifestCache, packageservice.PackageServiceName_birdwatcher)
+	return New(pkgArchive, facadeClient, manifestCache, packageservice.PackageServiceName_birdwatcher, signing)
+}
+
+func NewDocumentArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, signing appconfig.ManifestSigningCfg, versionSelection string, versionLabel string) packageservice.PackageService {
+	pkgArchive := documentarchive.New(facadeClient, versionSelection, versionLabel)
+	return New(pkgArchive, facadeClient, manifestCache, packageservice.PackageServiceName_document, signing)
 }
 
-func NewDocumentArchive(facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache) packageservice.PackageService {
-	pkgArchive := documentarchive.New(facadeClient)
-	return New(pkgArchive, facadeClient, manifestCache, packageservice.PackageServiceName_document)
+// NewLocalArchive returns a PackageService installing packages staged in a local directory, it does not call the service
+func NewLocalArchive(manifestCache packageservice.ManifestCache, location string, signing appconfig.ManifestSigningCfg) packageservice.PackageService {
+	pkgArchive := localarchive.New(location)
+	return New(pkgArchive, nil, manifestCache, packageservice.PackageServiceName_local, signing)
+}
+
+// NewS3Archive returns a PackageService installing packages stored in a customer owned s3 bucket, it does not call the service
+func NewS3Archive(log log.T, manifestCache packageservice.ManifestCache, signing appconfig.ManifestSigningCfg) packageservice.PackageService {
+	pkgArchive := s3archive.New(log)
+	return New(pkgArchive, nil, manifestCache, packageservice.PackageServiceName_s3, signing)
+}
+
+// NewOciArchive returns a PackageService installing packages stored in an OCI registry, it does not call the service
+func NewOciArchive(log log.T, manifestCache packageservice.ManifestCache, signing appconfig.ManifestSigningCfg) packageservice.PackageService {
+	pkgArchive := ociarchive.New(log)
+	return New(pkgArchive, nil, manifestCache, packageservice.PackageServiceName_oci, signing)
 }
 
 // New constructor for PackageService
-func New(pkgArchive archive.IPackageArchive, facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, name string) packageservice.PackageService {
+func New(pkgArchive archive.IPackageArchive, facadeClient facade.BirdwatcherFacade, manifestCache packageservice.ManifestCache, name string, signing appconfig.ManifestSigningCfg) packageservice.PackageService {
 
 	return &PackageService{
 		pkgSvcName:    name,
@@ -76,6 +137,9 @@ func New(pkgArchive archive.IPackageArchive, facadeClient facade.BirdwatcherFaca
 		collector:     &envdetect.CollectorImp{},
 		timeProvider:  &TimeImpl{},
 		archive:       pkgArchive,
+		signing:       signing,
+		resultQueue:   newResultQueue(appconfig.PackageResultQueueDirectory),
+		artifactCache: appconfig.PackageArtifactCacheDirectory,
 	}
 }
 
@@ -88,39 +152,199 @@ func (ds *PackageService) GetPackageArnAndVersion(packageName string, packageVer
 }
 
 // DownloadManifest downloads the manifest for a given version (or latest) and returns the agent version specified in manifest
-func (ds *PackageService) DownloadManifest(tracer trace.Tracer, packageName string, version string) (string, string, bool, error) {
-	manifest, isSameAsCache, err := downloadManifest(ds, packageName, version)
+func (ds *PackageService) DownloadManifest(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, string, bool, error) {
+	manifest, isSameAsCache, err := downloadManifest(ctx, ds, tracer, packageName, version)
 	if err != nil {
 		return "", "", isSameAsCache, err
 	}
+	if packageservice.IsLatest(version) {
+		if channelVersion := ds.selectChannelVersion(tracer, packageName, manifest); channelVersion != manifest.Version {
+			if manifest, isSameAsCache, err = downloadManifest(ctx, ds, tracer, packageName, channelVersion); err != nil {
+				return "", "", isSameAsCache, err
+			}
+		}
+	}
 	return ds.archive.GetResourceArn(manifest), manifest.Version, isSameAsCache, nil
 }
 
-// DownloadArtifact downloads the platform matching artifact specified in the manifest
-func (ds *PackageService) DownloadArtifact(tracer trace.Tracer, packageName string, version string) (string, error) {
+// DownloadArtifact downloads the platform matching artifact specified in the manifest, it is patched from the cached
+// artifact of the previous version when the manifest has a delta from that version
+func (ds *PackageService) DownloadArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, error) {
 	trace := tracer.BeginSection("download artifact")
 	manifest, err := readManifestFromCache(ds.manifestCache, packageName, version)
 	if err != nil {
 		trace.AppendInfof("error when reading the manifest from cache %v", err).End()
-		manifest, _, err = downloadManifest(ds, packageName, version)
+		manifest, _, err = downloadManifest(ctx, ds, tracer, packageName, version)
 		if err != nil {
 			trace.WithError(err).End()
 			return "", fmt.Errorf("failed to download the manifest: %v", err)
 		}
 	}
 
-	file, err := ds.findFileFromManifest(tracer, manifest)
+	pkginfo, err := ds.extractPackageInfo(tracer, manifest)
+	if err != nil {
+		trace.WithError(err).End()
+		return "", fmt.Errorf("failed to find platform: %v", err)
+	}
+	file, err := findFile(manifest, pkginfo)
 	if err != nil {
 		trace.WithError(err).End()
 		return "", err
 	}
+	if err = preflight(tracer, manifest, &file.Info, pkginfo); err != nil {
+		trace.WithError(err).End()
+		return "", err
+	}
 
 	trace.End()
-	return downloadFile(ds, tracer, file, packageName, version)
+	if ds.progress != nil {
+		ds.progress.ReportPhase(fmt.Sprintf("Downloading package %v version %v", packageName, version))
+	}
+	filePath, ok := ds.downloadDelta(ctx, tracer, manifest, pkginfo, file, packageName, version)
+	if !ok {
+		if filePath, err = downloadFile(ctx, ds, tracer, manifest, file, packageName, version); err != nil {
+			return "", err
+		}
+	}
+	if err = verifyArchiveFormat(file, filePath); err != nil {
+		return "", err
+	}
+	ds.cacheArtifact(tracer, packageName, version, filePath)
+	return filePath, nil
+}
+
+// DescribeArtifact returns the file of the manifest matching the platform of the instance, without downloading it
+func (ds *PackageService) DescribeArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (*packageservice.ArtifactInfo, error) {
+	manifest, err := readManifestFromCache(ds.manifestCache, packageName, version)
+	if err != nil {
+		if manifest, _, err = downloadManifest(ctx, ds, tracer, packageName, version); err != nil {
+			return nil, fmt.Errorf("failed to download the manifest: %v", err)
+		}
+	}
+	pkginfo, err := ds.extractPackageInfo(tracer, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find platform: %v", err)
+	}
+	file, err := findFile(manifest, pkginfo)
+	if err != nil {
+		return nil, err
+	}
+
+	artifactInfo := &packageservice.ArtifactInfo{
+		FileName:       file.Name,
+		Size:           int64(file.Info.Size),
+		Checksums:      file.Info.Checksums,
+		Attachments:    pkginfo.Attachments,
+		ExtractedFiles: file.Info.ExtractedFiles,
+	}
+	for _, name := range pkginfo.Attachments {
+		if fileInfo, ok := manifest.Files[name]; ok && fileInfo != nil {
+			artifactInfo.Size += int64(fileInfo.Size)
+		}
+	}
+	return artifactInfo, nil
+}
+
+// GetDependencies returns the dependencies declared by the manifest of a downloaded package version
+func (ds *PackageService) GetDependencies(tracer trace.Tracer, packageArn string, version string) ([]packageservice.Dependency, error) {
+	manifest, err := readManifestFromCache(ds.manifestCache, packageArn, version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the manifest of %v %v: %v", packageArn, version, err)
+	}
+
+	var dependencies []packageservice.Dependency
+	for _, dependency := range manifest.Dependencies {
+		if dependency.Name == "" {
+			return nil, fmt.Errorf("manifest of %v %v has a dependency without name", packageArn, version)
+		}
+		dependencies = append(dependencies, packageservice.Dependency{Name: dependency.Name, Version: dependency.Version})
+	}
+	return dependencies, nil
+}
+
+// GetHooks returns the hooks, the action timeouts and the install parameters of the platform of the instance
+// declared by the manifest of a package version
+func (ds *PackageService) GetHooks(ctx context.Context, tracer trace.Tracer, packageName string, version string) (packageservice.Hooks, error) {
+	manifest, err := readManifestFromCache(ds.manifestCache, packageName, version)
+	if err != nil {
+		if manifest, _, err = downloadManifest(ctx, ds, tracer, packageName, version); err != nil {
+			return packageservice.Hooks{}, fmt.Errorf("failed to download the manifest: %v", err)
+		}
+	}
+	hooks := packageservice.Hooks{ActionTimeouts: manifest.ActionTimeouts}
+	if manifest.Hooks != nil {
+		hooks.PreInstall = convertHook(manifest.Hooks.PreInstall)
+		hooks.PostInstall = convertHook(manifest.Hooks.PostInstall)
+		hooks.PreUninstall = convertHook(manifest.Hooks.PreUninstall)
+	}
+	if hooks.InstallParameters, err = ds.resolveInstallParameters(tracer, manifest); err != nil {
+		return packageservice.Hooks{}, err
+	}
+	return hooks, nil
+}
+
+// resolveInstallParameters returns the install parameters of the manifest overridden by the parameters of the
+// package of the platform of the instance, the platform is only detected when the manifest declares parameters
+func (ds *PackageService) resolveInstallParameters(tracer trace.Tracer, manifest *birdwatcher.Manifest) (map[string]string, error) {
+	if !declaresInstallParameters(manifest) {
+		return nil, nil
+	}
+	pkginfo, err := ds.extractPackageInfo(tracer, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve the install parameters: %v", err)
+	}
+	var platformParameters map[string]string
+	if pkginfo != nil {
+		platformParameters = pkginfo.InstallParameters
+	}
+	return packageservice.MergeInstallParameters(manifest.InstallParameters, platformParameters), nil
+}
+
+// declaresInstallParameters returns true if the manifest or the package of any platform declares install parameters
+func declaresInstallParameters(manifest *birdwatcher.Manifest) bool {
+	if len(manifest.InstallParameters) > 0 {
+		return true
+	}
+	for _, versions := range manifest.Packages {
+		for _, architectures := range versions {
+			for _, pkginfo := range architectures {
+				if pkginfo != nil && len(pkginfo.InstallParameters) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func convertHook(hook *birdwatcher.Hook) *packageservice.Hook {
+	if hook == nil {
+		return nil
+	}
+	return &packageservice.Hook{Commands: hook.Commands, TimeoutSeconds: hook.TimeoutSeconds, ExpectedExitCodes: hook.ExpectedExitCodes}
+}
+
+// SetProgressReporter sets the reporter of the artifact downloads
+func (ds *PackageService) SetProgressReporter(reporter packageservice.ProgressReporter) {
+	ds.progress = reporter
+}
+
+// SetChannel sets the rollout channel of the instance, the latest manifest of a package can advertise another
+// version to the channel
+func (ds *PackageService) SetChannel(channel string) {
+	ds.channel = channel
 }
 
 // ReportResult sents back the result of the install/upgrade/uninstall run back to Birdwatcher
-func (ds *PackageService) ReportResult(tracer trace.Tracer, result packageservice.PackageResult) error {
+func (ds *PackageService) ReportResult(ctx context.Context, tracer trace.Tracer, result packageservice.PackageResult) error {
+	if ds.archive != nil {
+		switch ds.archive.Name() {
+		case archive.PackageArchiveLocal, archive.PackageArchiveS3, archive.PackageArchiveOci:
+			// the local archive is used by instances without connectivity to the service,
+			// and packages of s3 buckets and registries are not known to it
+			return nil
+		}
+	}
 	log := tracer.CurrentTrace().Logger
 	env, _ := ds.collector.CollectData(log)
 
@@ -131,7 +355,7 @@ func (ds *PackageService) ReportResult(tracer trace.Tracer, result packageservic
 
 	var steps []*ssm.ConfigurePackageResultStep
 	for _, t := range result.Trace {
-		timing := (t.Timing - result.Timing) / 1000000 // converting nano to miliseconds
+		timing := elapsedMilliseconds(result, t.Timing, t.Anchor, t.MonotonicTiming)
 		steps = append(steps,
 			&ssm.ConfigurePackageResultStep{
 				Action: &t.Operation,
@@ -140,7 +364,17 @@ func (ds *PackageService) ReportResult(tracer trace.Tracer, result packageservic
 			})
 	}
 
-	overallTiming := (ds.timeProvider.NowUnixNano() - result.Timing) / 1000000
+	now := ds.timeProvider.NowUnixNano()
+	anchor := ds.timeProvider.AnchorUnixNano()
+	overallTiming := elapsedMilliseconds(result, now, anchor, ds.timeProvider.MonotonicNano())
+	startTime := trace.FormatTimestamp(result.Timing)
+	endTime := trace.FormatTimestamp(now)
+	// the wall clock timing differs from the overall timing when the clock changed during the operation
+	wallClockTiming := strconv.FormatInt((now-result.Timing)/1000000, 10)
+	timingSource := timingSourceWallClock
+	if result.Anchor != 0 && result.Anchor == anchor {
+		timingSource = timingSourceMonotonic
+	}
 
 	input := &ssm.PutConfigurePackageResultInput{
 		PackageName:            &result.PackageName,
@@ -157,19 +391,77 @@ func (ds *PackageService) ReportResult(tracer trace.Tracer, result packageservic
 			"instanceType":     &env.Ec2Infrastructure.InstanceType,
 			"region":           &env.Ec2Infrastructure.Region,
 			"availabilityZone": &env.Ec2Infrastructure.AvailabilityZone,
+			"provenance":       &env.Ec2Infrastructure.Provenance,
+			"startTime":        &startTime,
+			"endTime":          &endTime,
+			"wallClockTiming":  &wallClockTiming,
+			"timingSource":     &timingSource,
 		},
 		Steps: steps,
 	}
+	if result.ErrorCode != "" {
+		errorCode := string(result.ErrorCode)
+		input.Attributes["errorCode"] = &errorCode
+	}
+	if env.Container != nil && env.Container.Containerized {
+		containerized := "true"
+		input.Attributes["containerized"] = &containerized
+		for name, value := range map[string]string{
+			"containerRuntime": env.Container.Runtime,
+			"orchestrator":     env.Container.Orchestrator,
+			"containerHost":    env.Container.Host,
+		} {
+			if value != "" {
+				value := value
+				input.Attributes[name] = &value
+			}
+		}
+	}
+	if result.RolledBack {
+		// the install failed and the previous version was installed again
+		rolledBack := "true"
+		input.Attributes["rolledBack"] = &rolledBack
+	}
+	for name, value := range result.Attributes {
+		if _, ok := input.Attributes[name]; !ok {
+			value := value
+			input.Attributes[name] = &value
+		}
+	}
 
-	_, err := ds.facadeClient.PutConfigurePackageResult(input)
+	_, err := ds.facadeClient.PutConfigurePackageResultWithContext(ctx, input)
 
 	if err != nil {
-		return fmt.Errorf("failed to report results: %v", err)
+		if ds.resultQueue == nil {
+			return fmt.Errorf("failed to report results: %v", err)
+		}
+		// the result is submitted with the next result reported once the service is reachable again
+		if queueErr := ds.resultQueue.enqueue(log, input); queueErr != nil {
+			return fmt.Errorf("failed to report results: %v, and to queue them: %v", err, queueErr)
+		}
+		tracer.CurrentTrace().AppendInfof("Queued results for a later report, failed to report them: %v", err)
+		return nil
 	}
 
+	if ds.resultQueue != nil {
+		ds.resultQueue.flush(ctx, log, ds.facadeClient)
+	}
 	return nil
 }
 
+// elapsedMilliseconds returns the milliseconds from the start of the operation of the result to a time, measured with
+// the monotonic clock when both were timed by the same clock and with the wall clock otherwise
+func elapsedMilliseconds(result packageservice.PackageResult, timing int64, anchor int64, monotonicTiming int64) int64 {
+	if anchor != 0 && anchor == result.Anchor {
+		return (monotonicTiming - result.MonotonicTiming) / 1000000
+	}
+	if timing < result.Timing {
+		// the wall clock went back
+		return 0
+	}
+	return (timing - result.Timing) / 1000000 // converting nano to miliseconds
+}
+
 // utils
 func readManifestFromCache(cache packageservice.ManifestCache, packageName string, version string) (*birdwatcher.Manifest, error) {
 	data, err := cache.ReadManifest(packageName, version)
@@ -180,12 +472,12 @@ func readManifestFromCache(cache packageservice.ManifestCache, packageName strin
 	return parseManifest(&data)
 }
 
-func downloadManifest(ds *PackageService, packageName string, version string) (*birdwatcher.Manifest, bool, error) {
+func downloadManifest(ctx context.Context, ds *PackageService, tracer trace.Tracer, packageName string, version string) (*birdwatcher.Manifest, bool, error) {
 	isSameAsCache := false
 	if ds == nil {
 		return nil, isSameAsCache, fmt.Errorf("PackageService doesn't exist")
 	}
-	manifest, err := ds.archive.DownloadArchiveInfo(packageName, version)
+	manifest, err := ds.archive.DownloadArchiveInfo(ctx, packageName, version)
 	if err != nil {
 		return nil, isSameAsCache, fmt.Errorf("failed to download manifest - %v", err)
 	}
@@ -196,6 +488,16 @@ func downloadManifest(ds *PackageService, packageName string, version string) (*
 	if err != nil {
 		return nil, isSameAsCache, err
 	}
+	if err = validateManifest(parsedManifest, ds.archive.GetResourceArn(parsedManifest)); err != nil {
+		return nil, isSameAsCache, fmt.Errorf("%v %v: %v", packageName, version, err)
+	}
+
+	// unsigned or tampered manifests are neither cached nor used when signing is enforced
+	if ds.signing.Enabled {
+		if err = verifyManifestSignature(ctx, ds, tracer, parsedManifest, byteManifest, packageName, version); err != nil {
+			return nil, isSameAsCache, fmt.Errorf("failed to verify manifest signature: %v", err)
+		}
+	}
 
 	cachedManifest, err := readManifestFromCache(ds.manifestCache, ds.archive.GetResourceArn(parsedManifest), parsedManifest.Version)
 
@@ -207,6 +509,9 @@ func downloadManifest(ds *PackageService, packageName string, version string) (*
 	if err != nil {
 		return nil, isSameAsCache, fmt.Errorf("failed to write manifest to file: %v", err)
 	}
+	if current := tracer.CurrentTrace(); current != nil {
+		current.AppendInfof("manifest of %v %v has sha256 %x", ds.archive.GetResourceArn(parsedManifest), parsedManifest.Version, sha256.Sum256(byteManifest))
+	}
 
 	return parsedManifest, isSameAsCache, nil
 }
@@ -214,7 +519,7 @@ func downloadManifest(ds *PackageService, packageName string, version string) (*
 func parseManifest(data *[]byte) (*birdwatcher.Manifest, error) {
 	var manifest birdwatcher.Manifest
 
-	// TODO: additional validation
+	// the structure of downloaded manifests is checked by validateManifest
 	if err := json.NewDecoder(bytes.NewReader(*data)).Decode(&manifest); err != nil {
 		return nil, fmt.Errorf("failed to decode manifest: %v", err)
 	}
@@ -223,14 +528,18 @@ func parseManifest(data *[]byte) (*birdwatcher.Manifest, error) {
 }
 
 func (ds *PackageService) findFileFromManifest(tracer trace.Tracer, manifest *birdwatcher.Manifest) (*archive.File, error) {
-	var fileInfo *birdwatcher.FileInfo
-	var file archive.File
-	var filename string
-
 	pkginfo, err := ds.extractPackageInfo(tracer, manifest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find platform: %v", err)
 	}
+	return findFile(manifest, pkginfo)
+}
+
+// findFile returns the file of the manifest referenced by the package info
+func findFile(manifest *birdwatcher.Manifest, pkginfo *birdwatcher.PackageInfo) (*archive.File, error) {
+	var fileInfo *birdwatcher.FileInfo
+	var file archive.File
+	var filename string
 
 	for name, f := range manifest.Files {
 		if name == pkginfo.FileName {
@@ -249,34 +558,119 @@ func (ds *PackageService) findFileFromManifest(tracer trace.Tracer, manifest *bi
 	return &file, nil
 }
 
-func downloadFile(ds *PackageService, tracer trace.Tracer, file *archive.File, packagename string, version string) (string, error) {
+// downloadFile downloads an artifact of the manifest and verifies its signature before returning its local path
+func downloadFile(ctx context.Context, ds *PackageService, tracer trace.Tracer, manifest *birdwatcher.Manifest, file *archive.File, packagename string, version string) (string, error) {
+	filePath, err := fetchFile(ctx, ds, tracer, file, packagename, version)
+	if err != nil {
+		return "", err
+	}
+	if err = verifyArtifactSignature(ctx, ds, tracer, manifest, file, filePath, packagename, version); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, nil
+}
+
+// fetchFile downloads a file of the manifest verified against its checksums only
+func fetchFile(ctx context.Context, ds *PackageService, tracer trace.Tracer, file *archive.File, packagename string, version string) (string, error) {
 	if ds == nil || ds.archive == nil || file == nil {
 		return "", fmt.Errorf("Either package service does not exist or does not have archive information or the file information does not exist")
 	}
-	sourceUrl, err := ds.archive.GetFileDownloadLocation(file, packagename, version)
+	log := tracer.CurrentTrace().Logger
+	// an artifact shared with another package is identified by its checksum, its location is not resolved again
+	cachedInput := artifact.DownloadInput{
+		SourceURL:       file.Name,
+		SourceChecksums: file.Info.Checksums,
+		SourceSize:      int64(file.Info.Size),
+		Cached:          true,
+	}
+	if cachedOutput, ok := restoreCachedArtifact(log, cachedInput); ok {
+		tracer.CurrentTrace().AppendInfof("%v of %v %v is already in the artifact cache", file.Name, packagename, version)
+		return cachedOutput.LocalFilePath, nil
+	}
+	sourceUrls, err := fileDownloadLocations(ctx, ds.archive, file, packagename, version)
 	if err != nil {
 		return "", err
 	}
-	downloadInput := artifact.DownloadInput{
-		SourceURL: sourceUrl,
-		// TODO don't hardcode sha256 - use multiple checksums
-		SourceChecksums: file.Info.Checksums,
-	}
+	var errMessage string
+	for i, source := range downloadSources(downloadMirrors(), sourceUrls) {
+		if i > 0 {
+			tracer.CurrentTrace().AppendInfof("%v, downloading %v from %v", errMessage, file.Name, source.url)
+		}
+		// package artifacts can be large, a failed download resumes on the next invocation,
+		// and the artifacts shared by packages or installed again are reused from the artifact cache
+		downloadInput := artifact.DownloadInput{
+			SourceURL:       source.url,
+			SourceChecksums: file.Info.Checksums,
+			SourceSize:      int64(file.Info.Size),
+			Resumable:       true,
+			Progress:        packageservice.NewDownloadProgressFunc(ds.progress),
+			Cached:          true,
+			Headers:         source.headers,
+		}
 
-	log := tracer.CurrentTrace().Logger
-	downloadOutput, downloadErr := birdwatcher.Networkdep.Download(log, downloadInput)
-	if downloadErr != nil || downloadOutput.LocalFilePath == "" {
-		errMessage := fmt.Sprintf("failed to download installation package reliably, %v", downloadInput.SourceURL)
+		downloadOutput, downloadErr := downloadWithRetry(ctx, log, downloadInput)
+		if !downloadOutput.Timings.IsZero() {
+			// a slow download is attributed to the network or the endpoint from its trace
+			tracer.CurrentTrace().AppendInfof("download of %v from %v: %v", file.Name, source.url, downloadOutput.Timings)
+		}
+		if downloadErr == nil && downloadOutput.LocalFilePath != "" {
+			return downloadOutput.LocalFilePath, nil
+		}
+		errMessage = fmt.Sprintf("failed to download installation package reliably, %v", downloadInput.SourceURL)
 		if downloadErr != nil {
 			errMessage = fmt.Sprintf("%v, %v", errMessage, downloadErr.Error())
 		}
 		// TODO: attempt to clean up failed download folder?
+		if ctx.Err() != nil {
+			break
+		}
+	}
 
-		// return download error
-		return "", errors.New(errMessage)
+	// return download error
+	return "", errors.New(errMessage)
+}
+
+// restoreCachedArtifact copies an artifact from the artifact cache, replaced by the tests
+var restoreCachedArtifact = artifact.RestoreCached
+
+// fileDownloadLocations returns the locations the file is downloaded from in order, the archives with a single
+// location per file return it
+func fileDownloadLocations(ctx context.Context, packageArchive archive.IPackageArchive, file *archive.File, packageName string, version string) ([]string, error) {
+	if fallbackArchive, ok := packageArchive.(archive.IFallbackLocations); ok {
+		return fallbackArchive.GetFileDownloadLocations(ctx, file, packageName, version)
 	}
+	sourceUrl, err := packageArchive.GetFileDownloadLocation(ctx, file, packageName, version)
+	if err != nil {
+		return nil, err
+	}
+	return []string{sourceUrl}, nil
+}
+
+// verifyArchiveFormat checks the artifact is an archive of the format declared by the manifest, so a mislabeled
+// artifact fails before it is cached. An artifact without a declared format is detected from its content when extracted.
+func verifyArchiveFormat(file *archive.File, filePath string) error {
+	expected := strings.ToLower(file.Info.ArchiveFormat)
+	if expected == "" {
+		return nil
+	}
+	format, err := fileutil.DetectArchiveFormat(filePath)
+	if err != nil {
+		return fmt.Errorf("artifact %v is not a package archive: %v", file.Name, err)
+	}
+	if format != expected {
+		return fmt.Errorf("artifact %v is a %v archive, the manifest expects %v", file.Name, format, expected)
+	}
+	return nil
+}
 
-	return downloadOutput.LocalFilePath, nil
+// downloadWithRetry downloads the file, retrying transient and throttling failures with the shared download backoff
+func downloadWithRetry(ctx context.Context, log log.T, input artifact.DownloadInput) (output artifact.DownloadOutput, err error) {
+	err = retryer.Do(ctx, log, retryer.DownloadPolicy, retryer.DefaultBudget, "download of "+input.SourceURL, func() (downloadErr error) {
+		output, downloadErr = birdwatcher.Networkdep.Download(ctx, log, input)
+		return downloadErr
+	})
+	return output, err
 }
 
 // ExtractPackageInfo returns the correct PackageInfo for the current instances platform/version/arch
@@ -287,7 +681,8 @@ func (ds *PackageService) extractPackageInfo(tracer trace.Tracer, manifest *bird
 		return nil, fmt.Errorf("failed to collect data: %v", err)
 	}
 
-	if keyplatform, ok := matchPackageSelectorPlatform(env.OperatingSystem.Platform, manifest.Packages); ok {
+	containerized := env.Container != nil && env.Container.Containerized
+	if keyplatform, ok := matchPackageSelectorPlatform(env.OperatingSystem.Platform, containerized, manifest.Packages); ok {
 		if keyversion, ok := matchPackageSelectorVersion(env.OperatingSystem.PlatformVersion, manifest.Packages[keyplatform]); ok {
 			if keyarch, ok := matchPackageSelectorArch(env.OperatingSystem.Architecture, manifest.Packages[keyplatform][keyversion]); ok {
 				return manifest.Packages[keyplatform][keyversion][keyarch], nil
@@ -299,9 +694,15 @@ func (ds *PackageService) extractPackageInfo(tracer trace.Tracer, manifest *bird
 		env.OperatingSystem.Platform, env.OperatingSystem.PlatformVersion, env.OperatingSystem.Architecture)
 }
 
-func matchPackageSelectorPlatform(key string, dict map[string]map[string]map[string]*birdwatcher.PackageInfo) (string, bool) {
-	if _, ok := dict[key]; ok {
+// matchPackageSelectorPlatform prefers the packages built for containers when the agent runs in a container
+// over the packages of the platform over the packages of its alias, such as redhat for rocky, over "_any"
+func matchPackageSelectorPlatform(key string, containerized bool, dict map[string]map[string]map[string]*birdwatcher.PackageInfo) (string, bool) {
+	if _, ok := dict[constants.PlatformFamilyContainer]; ok && containerized {
+		return constants.PlatformFamilyContainer, true
+	} else if _, ok := dict[key]; ok {
 		return key, true
+	} else if alias, ok := platformAliases()[key]; ok && dict[alias] != nil {
+		return alias, true
 	} else if _, ok := dict["_any"]; ok {
 		return "_any", true
 	}
@@ -309,9 +710,20 @@ func matchPackageSelectorPlatform(key string, dict map[string]map[string]map[str
 	return "", false
 }
 
+// matchPackageSelectorVersion prefers an exact version over a prefix or range selector over "_any"
 func matchPackageSelectorVersion(key string, dict map[string]map[string]*birdwatcher.PackageInfo) (string, bool) {
 	if _, ok := dict[key]; ok {
 		return key, true
+	}
+
+	var selectors []string
+	for selector := range dict {
+		if isVersionSelector(selector) {
+			selectors = append(selectors, selector)
+		}
+	}
+	if selector, ok := matchVersionSelector(key, selectors); ok {
+		return selector, true
 	} else if _, ok := dict["_any"]; ok {
 		return "_any", true
 	}
@@ -319,10 +731,18 @@ func matchPackageSelectorVersion(key string, dict map[string]map[string]*birdwat
 	return "", false
 }
 
+// matchPackageSelectorArch prefers the architecture over the other names of the architecture over "_any"
 func matchPackageSelectorArch(key string, dict map[string]*birdwatcher.PackageInfo) (string, bool) {
 	if _, ok := dict[key]; ok {
 		return key, true
-	} else if _, ok := dict["_any"]; ok {
+	}
+
+	for _, alias := range architectureAliases[key] {
+		if _, ok := dict[alias]; ok {
+			return alias, true
+		}
+	}
+	if _, ok := dict["_any"]; ok {
 		return "_any", true
 	}
 
@@ -15,8 +15,15 @@
 package birdwatcherservice
 
 import (
+	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -25,7 +32,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/documentarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade/retryer"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/localarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/containerdetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
@@ -48,6 +58,16 @@ func (t *TimeMock) NowUnixNano() int64 {
 	return int64(args.Int(0))
 }
 
+func (t *TimeMock) MonotonicNano() int64 {
+	args := t.Called()
+	return int64(args.Int(0))
+}
+
+func (t *TimeMock) AnchorUnixNano() int64 {
+	args := t.Called()
+	return int64(args.Int(0))
+}
+
 type pkgtree map[string]map[string]map[string]*birdwatcher.PackageInfo
 type pkgselector struct {
 	platform     string
@@ -208,6 +228,8 @@ func TestExtractPackageInfo(t *testing.T) {
 			mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
 				&osdetect.OperatingSystem{platformName, platformVersion, "", architecture, "", ""},
 				nil,
+				nil,
+				nil,
 			}, nil).Once()
 
 			facadeClientMock := facade.FacadeStub{
@@ -227,10 +249,146 @@ func TestExtractPackageInfo(t *testing.T) {
 	}
 }
 
+func TestExtractPackageInfoInContainer(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	manifest := &birdwatcher.Manifest{
+		Packages: manifestPackageGen(&[]pkgselector{
+			{"amazon", "2", "x86_64", &birdwatcher.PackageInfo{FileName: "host.zip"}},
+			{"container", "_any", "x86_64", &birdwatcher.PackageInfo{FileName: "container.zip"}},
+		}),
+	}
+
+	for _, containerized := range []bool{false, true} {
+		mockedCollector := envdetect.CollectorMock{}
+		mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+			&osdetect.OperatingSystem{"amazon", "2", "", "x86_64", "", ""},
+			nil,
+			&containerdetect.Container{Containerized: containerized},
+			nil,
+		}, nil).Once()
+		ds := &PackageService{manifestCache: packageservice.ManifestCacheMemNew(), collector: &mockedCollector}
+
+		result, err := ds.extractPackageInfo(tracer, manifest)
+
+		assert.NoError(t, err)
+		if containerized {
+			assert.Equal(t, "container.zip", result.FileName)
+		} else {
+			assert.Equal(t, "host.zip", result.FileName)
+		}
+	}
+}
+
+func TestMatchPackageSelectorVersion(t *testing.T) {
+	data := []struct {
+		name     string
+		version  string
+		keys     []string
+		expected string
+		matched  bool
+	}{
+		{"exact match", "16.04", []string{"16.04", "16.*", "_any"}, "16.04", true},
+		{"prefix match", "16.10", []string{"16.04", "16.*", "_any"}, "16.*", true},
+		{"prefix match of the major version", "16", []string{"16.*"}, "16.*", true},
+		{"prefix does not match a longer number", "160.1", []string{"16.*"}, "", false},
+		{"range match", "22.04", []string{">=20.04 <24.04", "_any"}, ">=20.04 <24.04", true},
+		{"range upper bound is exclusive", "24.04", []string{">=20.04 <24.04", "_any"}, "_any", true},
+		{"range lower bound is inclusive", "20.04", []string{">=20.04 <24.04"}, ">=20.04 <24.04", true},
+		{"range with spaces after operators", "20.10", []string{">= 20.04 < 24.04"}, ">= 20.04 < 24.04", true},
+		{"open range", "2018.03", []string{">2017"}, ">2017", true},
+		{"range over windows builds", "10.0.17763", []string{">=10.0.14393 <=10.0.17763"}, ">=10.0.14393 <=10.0.17763", true},
+		{"narrower range wins", "20.04", []string{">=16.04", ">=20.04 <24.04", "20.*"}, ">=20.04 <24.04", true},
+		{"higher lower bound wins", "20.04", []string{">=20.04", "20.*"}, ">=20.04", true},
+		{"lower upper bound wins", "20.04", []string{">=20", "20.*"}, "20.*", true},
+		{"equally specific selectors are ordered by key", "7.6", []string{">=7 <8", "7.*"}, "7.*", true},
+		{"invalid selectors are ignored", "16.04", []string{">=abc", "x.*", "_any"}, "_any", true},
+		{"non numeric version only matches exactly", "buster/sid", []string{">=9", "_any"}, "_any", true},
+		{"no match", "14.04", []string{">=16.04", "16.*"}, "", false},
+	}
+
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			dict := make(map[string]map[string]*birdwatcher.PackageInfo)
+			for _, key := range testdata.keys {
+				dict[key] = map[string]*birdwatcher.PackageInfo{}
+			}
+			result, ok := matchPackageSelectorVersion(testdata.version, dict)
+			assert.Equal(t, testdata.matched, ok)
+			assert.Equal(t, testdata.expected, result)
+		})
+	}
+}
+
+func TestMatchPackageSelectorPlatform(t *testing.T) {
+	defer func(original func() map[string]string) { platformAliases = original }(platformAliases)
+	platformAliases = func() map[string]string {
+		return map[string]string{"rocky": "redhat", "almalinux": "redhat", "mac_os_x": "darwin"}
+	}
+
+	data := []struct {
+		name     string
+		platform string
+		keys     []string
+		expected string
+		matched  bool
+	}{
+		{"exact match", "redhat", []string{"redhat", "_any"}, "redhat", true},
+		{"exact match over alias", "rocky", []string{"rocky", "redhat", "_any"}, "rocky", true},
+		{"alias match", "rocky", []string{"redhat", "_any"}, "redhat", true},
+		{"alias match of almalinux", "almalinux", []string{"redhat"}, "redhat", true},
+		{"alias match of macOS", "mac_os_x", []string{"darwin", "windows"}, "darwin", true},
+		{"missing alias falls back to any", "rocky", []string{"amazon", "_any"}, "_any", true},
+		{"no alias", "alpine", []string{"redhat"}, "", false},
+	}
+
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			dict := make(map[string]map[string]map[string]*birdwatcher.PackageInfo)
+			for _, key := range testdata.keys {
+				dict[key] = map[string]map[string]*birdwatcher.PackageInfo{}
+			}
+			result, ok := matchPackageSelectorPlatform(testdata.platform, false, dict)
+			assert.Equal(t, testdata.matched, ok)
+			assert.Equal(t, testdata.expected, result)
+		})
+	}
+}
+
+func TestMatchPackageSelectorArch(t *testing.T) {
+	data := []struct {
+		name     string
+		arch     string
+		keys     []string
+		expected string
+		matched  bool
+	}{
+		{"exact match", "x86_64", []string{"x86_64", "amd64", "_any"}, "x86_64", true},
+		{"amd64 alias", "x86_64", []string{"amd64", "_any"}, "amd64", true},
+		{"aarch64 alias", "arm64", []string{"aarch64", "_any"}, "aarch64", true},
+		{"arm64 does not install x86_64", "arm64", []string{"x86_64", "_any"}, "_any", true},
+		{"no match", "arm64", []string{"x86_64"}, "", false},
+	}
+
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			dict := make(map[string]*birdwatcher.PackageInfo)
+			for _, key := range testdata.keys {
+				dict[key] = &birdwatcher.PackageInfo{}
+			}
+			result, ok := matchPackageSelectorArch(testdata.arch, dict)
+			assert.Equal(t, testdata.matched, ok)
+			assert.Equal(t, testdata.expected, result)
+		})
+	}
+}
+
 func TestReportResult(t *testing.T) {
 	now := 420000
 	timemock := &TimeMock{}
 	timemock.On("NowUnixNano").Return(now)
+	timemock.On("AnchorUnixNano").Return(1)
+	timemock.On("MonotonicNano").Return(now)
 
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
@@ -268,6 +426,21 @@ func TestReportResult(t *testing.T) {
 				Exitcode:    815,
 			},
 		},
+		{
+			"successful api call after a rollback",
+			facade.FacadeStub{
+				PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{},
+			},
+			false,
+			packageservice.PackageResult{
+				PackageName:            "name",
+				Version:                "1234",
+				PreviousPackageVersion: "5678",
+				Timing:                 29347,
+				Exitcode:               1,
+				RolledBack:             true,
+			},
+		},
 		{
 			"failing api call",
 			facade.FacadeStub{
@@ -290,11 +463,13 @@ func TestReportResult(t *testing.T) {
 
 			mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
 				&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
-				&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ"},
+				&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+				nil,
+				nil,
 			}, nil).Once()
 			ds := &PackageService{facadeClient: &testdata.facadeClient, manifestCache: packageservice.ManifestCacheMemNew(), collector: &mockedCollector, timeProvider: timemock}
 
-			err := ds.ReportResult(tracer, testdata.packageResult)
+			err := ds.ReportResult(context.Background(), tracer, testdata.packageResult)
 			if testdata.expectedErr {
 				assert.Error(t, err)
 			} else {
@@ -316,14 +491,202 @@ func TestReportResult(t *testing.T) {
 				assert.Equal(t, "instanceTypeZ", *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["instanceType"])
 				assert.Equal(t, "AZ1", *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["availabilityZone"])
 				assert.Equal(t, "Reg1", *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["region"])
+				assert.Equal(t, trace.FormatTimestamp(testdata.packageResult.Timing), *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["startTime"])
+				assert.Equal(t, trace.FormatTimestamp(int64(now)), *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["endTime"])
+				assert.Equal(t, "wallclock", *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["timingSource"])
+				if testdata.packageResult.RolledBack {
+					assert.Equal(t, "true", *testdata.facadeClient.PutConfigurePackageResultInput.Attributes["rolledBack"])
+				} else {
+					assert.Nil(t, testdata.facadeClient.PutConfigurePackageResultInput.Attributes["rolledBack"])
+				}
 			}
 		})
 	}
 }
 
+func TestReportResultLocalArchive(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultError: errors.New("testerror")}
+	ds := &PackageService{facadeClient: &facadeClient, archive: localarchive.New("/packages"), manifestCache: packageservice.ManifestCacheMemNew()}
+
+	err := ds.ReportResult(context.Background(), tracer, packageservice.PackageResult{PackageName: "name", Version: "1234"})
+
+	assert.NoError(t, err)
+	assert.Nil(t, facadeClient.PutConfigurePackageResultInput)
+}
+
+func TestReportResultMeasuresStepsWithMonotonicClock(t *testing.T) {
+	timemock := &TimeMock{}
+	// the wall clock went back 5 seconds while the package was installed for 3 seconds
+	timemock.On("NowUnixNano").Return(int(8 * time.Second))
+	timemock.On("AnchorUnixNano").Return(1)
+	timemock.On("MonotonicNano").Return(int(13 * time.Second))
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	mockedCollector := envdetect.CollectorMock{}
+	mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+		&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
+		&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+		nil,
+		nil,
+	}, nil).Once()
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+	ds := &PackageService{facadeClient: &facadeClient, manifestCache: packageservice.ManifestCacheMemNew(), collector: &mockedCollector, timeProvider: timemock}
+
+	err := ds.ReportResult(context.Background(), tracer, packageservice.PackageResult{
+		PackageName:     "name",
+		Version:         "1234",
+		Timing:          int64(10 * time.Second),
+		Anchor:          1,
+		MonotonicTiming: int64(10 * time.Second),
+		Trace: []*packageservice.Trace{
+			{Operation: "> install", Timing: int64(10 * time.Second), Anchor: 1, MonotonicTiming: int64(10 * time.Second)},
+			{Operation: "< install", Timing: int64(7 * time.Second), Anchor: 1, MonotonicTiming: int64(12 * time.Second)},
+			{Operation: "= loaded", Timing: int64(11 * time.Second), Anchor: 2, MonotonicTiming: int64(time.Second)},
+		},
+	})
+
+	assert.NoError(t, err)
+	input := facadeClient.PutConfigurePackageResultInput
+	assert.Equal(t, int64(3000), *input.OverallTiming)
+	assert.Equal(t, int64(0), *input.Steps[0].Timing)
+	assert.Equal(t, int64(2000), *input.Steps[1].Timing)
+	assert.Equal(t, int64(1000), *input.Steps[2].Timing)
+	assert.Equal(t, "-2000", *input.Attributes["wallClockTiming"])
+	assert.Equal(t, "monotonic", *input.Attributes["timingSource"])
+}
+
+func TestReportResultInContainer(t *testing.T) {
+	timemock := &TimeMock{}
+	timemock.On("NowUnixNano").Return(420000)
+	timemock.On("AnchorUnixNano").Return(1)
+	timemock.On("MonotonicNano").Return(420000)
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	mockedCollector := envdetect.CollectorMock{}
+	mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+		&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
+		&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+		&containerdetect.Container{Containerized: true, Runtime: "containerd", Orchestrator: "ecs", Host: "fargate"},
+		nil,
+	}, nil).Once()
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+	ds := &PackageService{facadeClient: &facadeClient, manifestCache: packageservice.ManifestCacheMemNew(), collector: &mockedCollector, timeProvider: timemock}
+
+	err := ds.ReportResult(context.Background(), tracer, packageservice.PackageResult{PackageName: "name", Version: "1234"})
+
+	assert.NoError(t, err)
+	attributes := facadeClient.PutConfigurePackageResultInput.Attributes
+	assert.Equal(t, "true", *attributes["containerized"])
+	assert.Equal(t, "containerd", *attributes["containerRuntime"])
+	assert.Equal(t, "ecs", *attributes["orchestrator"])
+	assert.Equal(t, "fargate", *attributes["containerHost"])
+}
+
+func TestReportResultWithCustomAttributes(t *testing.T) {
+	timemock := &TimeMock{}
+	timemock.On("NowUnixNano").Return(420000)
+	timemock.On("AnchorUnixNano").Return(1)
+	timemock.On("MonotonicNano").Return(420000)
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	mockedCollector := envdetect.CollectorMock{}
+	mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+		&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
+		&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+		nil,
+		nil,
+	}, nil).Once()
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+	ds := &PackageService{facadeClient: &facadeClient, manifestCache: packageservice.ManifestCacheMemNew(), collector: &mockedCollector, timeProvider: timemock}
+
+	err := ds.ReportResult(context.Background(), tracer, packageservice.PackageResult{
+		PackageName: "name",
+		Version:     "1234",
+		Attributes:  map[string]string{"team": "payments", "region": "overridden"},
+	})
+
+	assert.NoError(t, err)
+	attributes := facadeClient.PutConfigurePackageResultInput.Attributes
+	assert.Equal(t, "payments", *attributes["team"])
+	assert.Equal(t, "Reg1", *attributes["region"])
+}
+
+func TestGetDependencies(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	cache := packageservice.ManifestCacheMemNew()
+	cache.WriteManifest("packagearn", "1234", []byte(`{"version": "1234", "dependencies": [{"name": "other", "version": ">=1.0"}]}`))
+	ds := &PackageService{manifestCache: cache}
+
+	dependencies, err := ds.GetDependencies(tracer, "packagearn", "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []packageservice.Dependency{{Name: "other", Version: ">=1.0"}}, dependencies)
+
+	_, err = ds.GetDependencies(tracer, "packagearn", "5678")
+	assert.Error(t, err)
+}
+
+func TestGetHooks(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	cache := packageservice.ManifestCacheMemNew()
+	cache.WriteManifest("packagearn", "1234", []byte(`{"version": "1234", "hooks": {"preinstall": {"commands": ["systemctl stop app"], "timeoutSeconds": 30},
+		"postinstall": {"commands": ["systemctl start app"], "expectedExitCodes": [0, 3]}}}`))
+	cache.WriteManifest("packagearn", "5678", []byte(`{"version": "5678"}`))
+	ds := &PackageService{manifestCache: cache}
+
+	hooks, err := ds.GetHooks(context.Background(), tracer, "packagearn", "1234")
+
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.Hooks{
+		PreInstall:  &packageservice.Hook{Commands: []string{"systemctl stop app"}, TimeoutSeconds: 30},
+		PostInstall: &packageservice.Hook{Commands: []string{"systemctl start app"}, ExpectedExitCodes: []int{0, 3}},
+	}, hooks)
+
+	hooks, err = ds.GetHooks(context.Background(), tracer, "packagearn", "5678")
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.Hooks{}, hooks)
+}
+
+func TestGetHooksResolvesInstallParameters(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	cache := packageservice.ManifestCacheMemNew()
+	cache.WriteManifest("packagearn", "1234", []byte(`{"version": "1234", "installParameters": {"serviceName": "app", "installPath": "/opt/app"},
+		"packages": {"windows": {"_any": {"_any": {"file": "app.msi", "installParameters": {"installPath": "C:\\Program Files\\App", "flags": "/quiet"}}}},
+			"amazon": {"_any": {"_any": {"file": "app.zip"}}}}}`))
+
+	for _, platform := range []string{"windows", "amazon"} {
+		mockedCollector := envdetect.CollectorMock{}
+		mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+			&osdetect.OperatingSystem{platform, "1", "", "x86_64", "", ""},
+			nil,
+			nil,
+			nil,
+		}, nil).Once()
+		ds := &PackageService{manifestCache: cache, collector: &mockedCollector}
+
+		hooks, err := ds.GetHooks(context.Background(), tracer, "packagearn", "1234")
+
+		assert.NoError(t, err)
+		if platform == "windows" {
+			assert.Equal(t, map[string]string{"serviceName": "app", "installPath": `C:\Program Files\App`, "flags": "/quiet"}, hooks.InstallParameters)
+		} else {
+			assert.Equal(t, map[string]string{"serviceName": "app", "installPath": "/opt/app"}, hooks.InstallParameters)
+		}
+	}
+}
+
+// testManifest is the smallest valid manifest, with a single file for all platforms
+const testManifest = `{"schemaVersion": "2.0", "version": "1234", "packageArn": "packagearn",
+	"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}},
+	"files": {"package.zip": {"checksums": {"sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}, "size": 4}}}`
+
 func TestDownloadManifest(t *testing.T) {
 	manifestStrErr := "xkj]{}["
-	manifestStr := "{\"version\": \"1234\",\"packageArn\":\"packagearn\"}"
+	manifestStrInvalid := "{\"version\": \"1234\",\"packageArn\":\"packagearn\"}"
+	manifestStr := testManifest
 	tracer := trace.NewTracer(log.NewMockLog())
 
 	data := []struct {
@@ -380,6 +743,18 @@ func TestDownloadManifest(t *testing.T) {
 			"",
 			true,
 		},
+		{
+			"error in validating manifest",
+			"packagename",
+			packageservice.Latest,
+			facade.FacadeStub{
+				GetManifestOutput: &ssm.GetManifestOutput{
+					Manifest: &manifestStrInvalid,
+				},
+			},
+			"",
+			true,
+		},
 		{
 			"Manifest already stored in package service",
 			"packagename",
@@ -396,17 +771,21 @@ func TestDownloadManifest(t *testing.T) {
 			mockedCollector := envdetect.CollectorMock{}
 			envdata := &envdetect.Environment{
 				&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
-				&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ"},
+				&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+				nil,
+				nil,
 			}
 
 			mockedCollector.On("CollectData", mock.Anything).Return(envdata, nil).Once()
 			cache := packageservice.ManifestCacheMemNew()
 			ds := &PackageService{facadeClient: &testdata.facadeClient, manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 
-			_, result, isSameAsCache, err := ds.DownloadManifest(tracer, testdata.packageName, testdata.packageVersion)
+			_, result, isSameAsCache, err := ds.DownloadManifest(context.Background(), tracer, testdata.packageName, testdata.packageVersion)
 
 			if testdata.expectedErr {
 				assert.Error(t, err)
+				cachedManifest, _ := cache.ReadManifest("packagearn", "1234")
+				assert.Empty(t, cachedManifest)
 			} else {
 				if testdata.manifest == "" {
 					// verify parameter for api call
@@ -427,7 +806,7 @@ func TestDownloadManifest(t *testing.T) {
 }
 
 func TestDownloadDocument(t *testing.T) {
-	manifestStr := "{\"version\": \"1234\"}"
+	manifestStr := testManifest
 	documentActive := ssm.DocumentStatusActive
 	tracer := trace.NewTracer(log.NewMockLog())
 	packageName := "documentarn"
@@ -458,18 +837,20 @@ func TestDownloadDocument(t *testing.T) {
 
 	for _, testdata := range data {
 		t.Run(testdata.name, func(t *testing.T) {
-			testArchive := documentarchive.New(&testdata.facadeClient)
+			testArchive := documentarchive.New(&testdata.facadeClient, "", "")
 			mockedCollector := envdetect.CollectorMock{}
 			envdata := &envdetect.Environment{
 				&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
-				&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ"},
+				&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+				nil,
+				nil,
 			}
 
 			mockedCollector.On("CollectData", mock.Anything).Return(envdata, nil).Once()
 			cache := packageservice.ManifestCacheMemNew()
 			ds := &PackageService{facadeClient: &testdata.facadeClient, manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 
-			_, result, isSameAsCache, err := ds.DownloadManifest(tracer, testdata.packageName, testdata.packageVersion)
+			_, result, isSameAsCache, err := ds.DownloadManifest(context.Background(), tracer, testdata.packageName, testdata.packageVersion)
 
 			if testdata.expectedErr {
 				assert.Error(t, err)
@@ -491,7 +872,7 @@ func TestDownloadDocument(t *testing.T) {
 }
 
 func TestDownloadManifestSameAsCacheManifest(t *testing.T) {
-	manifestStr := "{\"version\": \"1234\",\"packageArn\":\"packagearn\"}"
+	manifestStr := testManifest
 	tracer := trace.NewTracer(log.NewMockLog())
 	data := []struct {
 		name           string
@@ -546,12 +927,12 @@ func TestDownloadManifestSameAsCacheManifest(t *testing.T) {
 		ds := &PackageService{facadeClient: &testdata.facadeClient, manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 
 		// first call has empty cache and is expected to come back with isSameAsCache == false
-		_, result, isSameAsCache, err := ds.DownloadManifest(tracer, testdata.packageName, testdata.packageVersion)
+		_, result, isSameAsCache, err := ds.DownloadManifest(context.Background(), tracer, testdata.packageName, testdata.packageVersion)
 		assert.NoError(t, err)
 		assert.False(t, isSameAsCache)
 
 		// second call has the cache already populated by the first call
-		_, result, isSameAsCache, err = ds.DownloadManifest(tracer, testdata.packageName, testdata.packageVersion)
+		_, result, isSameAsCache, err = ds.DownloadManifest(context.Background(), tracer, testdata.packageName, testdata.packageVersion)
 
 		// verify parameter for api call
 		assert.Equal(t, testdata.packageName, *testdata.facadeClient.GetManifestInput.PackageName)
@@ -564,12 +945,13 @@ func TestDownloadManifestSameAsCacheManifest(t *testing.T) {
 		cachedManifest, cacheErr := cache.ReadManifest("packagearn", "1234")
 		assert.Equal(t, []byte(manifestStr), cachedManifest)
 		assert.NoError(t, cacheErr)
+		assert.Contains(t, tracer.CurrentTrace().InfoOut.String(), fmt.Sprintf("manifest of packagearn 1234 has sha256 %x", sha256.Sum256([]byte(manifestStr))))
 	}
 }
 
 func TestDownloadManifestDifferentFromCacheManifest(t *testing.T) {
 	cachedManifestStr := "{\"version\": \"123\",\"packageArn\":\"packagearn\"}"
-	manifestStr := "{\"version\": \"1234\",\"packageArn\":\"packagearn\"}"
+	manifestStr := testManifest
 	tracer := trace.NewTracer(log.NewMockLog())
 
 	testdata := struct {
@@ -596,7 +978,9 @@ func TestDownloadManifestDifferentFromCacheManifest(t *testing.T) {
 	mockedCollector := envdetect.CollectorMock{}
 	envdata := &envdetect.Environment{
 		&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
-		&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ"},
+		&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+		nil,
+		nil,
 	}
 
 	mockedCollector.On("CollectData", mock.Anything).Return(envdata, nil).Once()
@@ -607,7 +991,7 @@ func TestDownloadManifestDifferentFromCacheManifest(t *testing.T) {
 
 	ds := &PackageService{facadeClient: &testdata.facadeClient, manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 
-	_, result, isSameAsCache, err := ds.DownloadManifest(tracer, testdata.packageName, testdata.packageVersion)
+	_, result, isSameAsCache, err := ds.DownloadManifest(context.Background(), tracer, testdata.packageName, testdata.packageVersion)
 
 	// verify parameter for api call
 	assert.Equal(t, testdata.packageName, *testdata.facadeClient.GetManifestInput.PackageName)
@@ -622,6 +1006,30 @@ func TestDownloadManifestDifferentFromCacheManifest(t *testing.T) {
 	assert.NoError(t, cacheErr)
 }
 
+func TestDownloadManifestCancelled(t *testing.T) {
+	manifestStr := testManifest
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test cancelled getManifest")
+
+	facadeClient := facade.FacadeStub{
+		GetManifestOutput: &ssm.GetManifestOutput{
+			Manifest: &manifestStr,
+		},
+	}
+	cache := packageservice.ManifestCacheMemNew()
+	ds := &PackageService{facadeClient: &facadeClient, manifestCache: cache, archive: birdwatcherarchive.New(&facadeClient, "")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, _, err := ds.DownloadManifest(ctx, tracer, "packagename", "1234")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), context.Canceled.Error())
+	assert.Nil(t, facadeClient.GetManifestInput)
+	cachedManifest, _ := cache.ReadManifest("packagearn", "1234")
+	assert.Empty(t, cachedManifest)
+}
+
 func TestFindFileFromManifest(t *testing.T) {
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
@@ -688,7 +1096,9 @@ func TestFindFileFromManifest(t *testing.T) {
 
 			mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
 				&osdetect.OperatingSystem{"platformName", "platformVersion", "", "architecture", "", ""},
-				&ec2infradetect.Ec2Infrastructure{"instanceID", "region", "", "availabilityZone", "instanceType"},
+				&ec2infradetect.Ec2Infrastructure{"instanceID", "region", "", "availabilityZone", "instanceType", "ec2"},
+				nil,
+				nil,
 			}, nil).Once()
 
 			facadeClientMock := facade.FacadeStub{
@@ -783,7 +1193,7 @@ func TestDownloadFile(t *testing.T) {
 			mockedCollector := envdetect.CollectorMock{}
 			ds := &PackageService{manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 
-			result, err := downloadFile(ds, tracer, testdata.file, packagename, version)
+			result, err := downloadFile(context.Background(), ds, tracer, nil, testdata.file, packagename, version)
 			if testdata.expectedErr {
 				assert.Error(t, err)
 			} else {
@@ -793,6 +1203,8 @@ func TestDownloadFile(t *testing.T) {
 				input := artifact.DownloadInput{
 					SourceURL:       testdata.file.Info.DownloadLocation,
 					SourceChecksums: map[string]string{"sha256": "asdf"},
+					Resumable:       true,
+					Cached:          true,
 				}
 				assert.Equal(t, input, testdata.network.downloadInput)
 			}
@@ -800,6 +1212,95 @@ func TestDownloadFile(t *testing.T) {
 	}
 }
 
+// regionalArchiveStub returns the regional download locations of the files for an instance of the region
+type regionalArchiveStub struct {
+	versionedArchiveStub
+	region string
+}
+
+func (a *regionalArchiveStub) GetFileDownloadLocations(ctx context.Context, file *archive.File, packageName string, version string) ([]string, error) {
+	return file.Info.DownloadLocations(a.region), nil
+}
+
+// failingLocationsNetworkMock fails the downloads from the locations in failing
+type failingLocationsNetworkMock struct {
+	failing    map[string]bool
+	downloaded []string
+	headers    []map[string]string
+}
+
+func (p *failingLocationsNetworkMock) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	p.downloaded = append(p.downloaded, input.SourceURL)
+	p.headers = append(p.headers, input.Headers)
+	if p.failing[input.SourceURL] {
+		return artifact.DownloadOutput{}, errors.New("access denied")
+	}
+	return artifact.DownloadOutput{LocalFilePath: "agent.zip"}, nil
+}
+
+func TestDownloadFileFallsBackToNextLocation(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	file := &archive.File{"fileName.zip", birdwatcher.FileInfo{
+		DownloadLocation:          "https://global/agent.zip",
+		RegionalDownloadLocations: map[string]string{"eu-west-1": "https://euw1/agent.zip", "eu-central-1": "https://euc1/agent.zip"},
+		FallbackRegions:           []string{"eu-central-1"},
+	}}
+	network := &failingLocationsNetworkMock{failing: map[string]bool{"https://euw1/agent.zip": true, "https://euc1/agent.zip": true}}
+	birdwatcher.Networkdep = network
+	ds := &PackageService{manifestCache: packageservice.ManifestCacheMemNew(), collector: &envdetect.CollectorMock{}, archive: &regionalArchiveStub{region: "eu-west-1"}}
+
+	result, err := fetchFile(context.Background(), ds, tracer, file, "packagename", "version")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "agent.zip", result)
+	assert.Equal(t, "https://euw1/agent.zip", network.downloaded[0])
+	assert.Equal(t, "https://global/agent.zip", network.downloaded[len(network.downloaded)-1])
+	assert.Contains(t, network.downloaded, "https://euc1/agent.zip")
+
+	network.failing["https://global/agent.zip"] = true
+	_, err = fetchFile(context.Background(), ds, tracer, file, "packagename", "version")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "https://global/agent.zip")
+}
+
+func TestDownloadFileTracesTimings(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	file := &archive.File{"fileName.zip", birdwatcher.FileInfo{DownloadLocation: "https://global/agent.zip"}}
+	timings := artifact.DownloadTimings{DNS: time.Millisecond, Connect: 2 * time.Millisecond, FirstByte: 3 * time.Second, Transfer: time.Second}
+	birdwatcher.Networkdep = &networkMock{downloadOutput: artifact.DownloadOutput{LocalFilePath: "agent.zip", Timings: timings}}
+	ds := &PackageService{manifestCache: packageservice.ManifestCacheMemNew(), collector: &envdetect.CollectorMock{}, archive: &regionalArchiveStub{}}
+
+	_, err := fetchFile(context.Background(), ds, tracer, file, "packagename", "version")
+
+	assert.NoError(t, err)
+	assert.Contains(t, tracer.CurrentTrace().InfoOut.String(), "download of fileName.zip from https://global/agent.zip: dns 1ms, connect 2ms, tls 0s, first byte 3s, transfer 1s")
+}
+
+func TestFetchFileReusesCachedArtifact(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	file := &archive.File{"fileName.zip", birdwatcher.FileInfo{DownloadLocation: "https://global/agent.zip", Checksums: map[string]string{"sha256": "abc"}}}
+	network := &networkMock{downloadOutput: artifact.DownloadOutput{LocalFilePath: "agent.zip"}}
+	birdwatcher.Networkdep = network
+	var restoreInput artifact.DownloadInput
+	restoreCachedArtifact = func(log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, bool) {
+		restoreInput = input
+		return artifact.DownloadOutput{LocalFilePath: "cached.zip"}, true
+	}
+	defer func() { restoreCachedArtifact = artifact.RestoreCached }()
+	ds := &PackageService{manifestCache: packageservice.ManifestCacheMemNew(), collector: &envdetect.CollectorMock{}, archive: &regionalArchiveStub{}}
+
+	result, err := fetchFile(context.Background(), ds, tracer, file, "packagename", "version")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "cached.zip", result)
+	assert.Equal(t, file.Info.Checksums, restoreInput.SourceChecksums)
+	assert.Empty(t, network.downloadInput.SourceURL)
+	assert.Contains(t, tracer.CurrentTrace().InfoOut.String(), "fileName.zip of packagename version is already in the artifact cache")
+}
+
 func TestDownloadFileFromDocumentArchive(t *testing.T) {
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
@@ -868,12 +1369,12 @@ func TestDownloadFileFromDocumentArchive(t *testing.T) {
 					},
 				},
 			}
-			testArchive := documentarchive.New(&facadeClient)
+			testArchive := documentarchive.New(&facadeClient, "", "")
 
 			mockedCollector := envdetect.CollectorMock{}
 			ds := &PackageService{manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 
-			result, err := downloadFile(ds, tracer, testdata.file, packagename, version)
+			result, err := downloadFile(context.Background(), ds, tracer, nil, testdata.file, packagename, version)
 			if testdata.expectedErr {
 				assert.Error(t, err)
 			} else {
@@ -942,13 +1443,15 @@ func TestDownloadArtifact(t *testing.T) {
 
 			mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
 				&osdetect.OperatingSystem{"platformName", "platformVersion", "", "architecture", "", ""},
-				&ec2infradetect.Ec2Infrastructure{"instanceID", "region", "", "availabilityZone", "instanceType"},
+				&ec2infradetect.Ec2Infrastructure{"instanceID", "region", "", "availabilityZone", "instanceType", "ec2"},
+				nil,
+				nil,
 			}, nil).Once()
 
 			ds := &PackageService{manifestCache: cache, collector: &mockedCollector, archive: testArchive}
 			birdwatcher.Networkdep = &testdata.network
 
-			result, err := ds.DownloadArtifact(tracer, testdata.packageName, testdata.packageVersion)
+			result, err := ds.DownloadArtifact(context.Background(), tracer, testdata.packageName, testdata.packageVersion)
 
 			if testdata.expectedErr {
 				assert.Error(t, err)
@@ -959,3 +1462,54 @@ func TestDownloadArtifact(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyArchiveFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archiveformat")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "download")
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte{0x28, 0xb5, 0x2f, 0xfd, 0}, 0600))
+
+	assert.NoError(t, verifyArchiveFormat(&archive.File{Name: "package.tar.zst", Info: birdwatcher.FileInfo{ArchiveFormat: "tar.zst"}}, filePath))
+	assert.NoError(t, verifyArchiveFormat(&archive.File{Name: "package", Info: birdwatcher.FileInfo{ArchiveFormat: "TAR.ZST"}}, filePath))
+	assert.NoError(t, verifyArchiveFormat(&archive.File{Name: "package.zip"}, filePath))
+	assert.Error(t, verifyArchiveFormat(&archive.File{Name: "package.tar.gz", Info: birdwatcher.FileInfo{ArchiveFormat: "tar.gz"}}, filePath))
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte("not an archive"), 0600))
+	assert.Error(t, verifyArchiveFormat(&archive.File{Name: "package.zip", Info: birdwatcher.FileInfo{ArchiveFormat: "zip"}}, filePath))
+}
+
+// flakyNetwork fails the first downloads with the given errors
+type flakyNetwork struct {
+	errors []error
+	calls  int
+}
+
+func (n *flakyNetwork) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	n.calls++
+	if n.calls <= len(n.errors) {
+		return artifact.DownloadOutput{}, n.errors[n.calls-1]
+	}
+	return artifact.DownloadOutput{LocalFilePath: "downloaded"}, nil
+}
+
+func TestDownloadWithRetry(t *testing.T) {
+	defer func(policy retryer.Policy) { retryer.DownloadPolicy = policy }(retryer.DownloadPolicy)
+	retryer.DownloadPolicy = retryer.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, ThrottledBaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	network := &flakyNetwork{errors: []error{
+		artifact.HTTPStatusError{Status: "503 Slow Down", StatusCode: 503},
+		artifact.HTTPStatusError{Status: "500 Internal Server Error", StatusCode: 500},
+	}}
+	birdwatcher.Networkdep = network
+	output, err := downloadWithRetry(context.Background(), log.NewMockLog(), artifact.DownloadInput{SourceURL: "https://example.com/test.zip"})
+	assert.NoError(t, err)
+	assert.Equal(t, "downloaded", output.LocalFilePath)
+	assert.Equal(t, 3, network.calls)
+
+	network = &flakyNetwork{errors: []error{artifact.HTTPStatusError{Status: "403 Forbidden", StatusCode: 403}}}
+	birdwatcher.Networkdep = network
+	_, err = downloadWithRetry(context.Background(), log.NewMockLog(), artifact.DownloadInput{SourceURL: "https://example.com/test.zip"})
+	assert.Error(t, err)
+	assert.Equal(t, 1, network.calls)
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"compress/bzip2"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// bsdiffMagic starts the header of the patches of the bsdiff format
+const bsdiffMagic = "BSDIFF40"
+
+// bsdiffHeaderSize is the size of the magic, the lengths of the compressed control and diff blocks and the size of
+// the patched file
+const bsdiffHeaderSize = 32
+
+// errCorruptPatch is returned when the control block of a patch does not describe the patched file
+var errCorruptPatch = errors.New("the patch is corrupt")
+
+// applyBsdiff writes the file the bsdiff patch makes of the base file to the output. The patch is a header followed
+// by the bzip2 compressed control, diff and extra blocks. Each control entry adds the next bytes of the diff block
+// to the bytes of the base file, copies the next bytes of the extra block and seeks the base file, the base file
+// is read at those offsets so neither file is loaded in memory.
+func applyBsdiff(base io.ReaderAt, baseSize int64, patch io.ReaderAt, patchSize int64, output io.Writer) error {
+	header := make([]byte, bsdiffHeaderSize)
+	if _, err := patch.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("failed to read the patch header: %v", err)
+	}
+	if string(header[:len(bsdiffMagic)]) != bsdiffMagic {
+		return fmt.Errorf("the patch is not a bsdiff patch")
+	}
+	controlLength, diffLength, newSize := offtin(header[8:16]), offtin(header[16:24]), offtin(header[24:32])
+	if controlLength < 0 || diffLength < 0 || newSize < 0 || controlLength > patchSize-bsdiffHeaderSize-diffLength {
+		return errCorruptPatch
+	}
+	control := bzip2.NewReader(io.NewSectionReader(patch, bsdiffHeaderSize, controlLength))
+	diff := bzip2.NewReader(io.NewSectionReader(patch, bsdiffHeaderSize+controlLength, diffLength))
+	extra := bzip2.NewReader(io.NewSectionReader(patch, bsdiffHeaderSize+controlLength+diffLength,
+		patchSize-bsdiffHeaderSize-controlLength-diffLength))
+
+	entry := make([]byte, 24)
+	chunk := make([]byte, 32*1024)
+	baseChunk := make([]byte, len(chunk))
+	var basePos, newPos int64
+	for newPos < newSize {
+		if _, err := io.ReadFull(control, entry); err != nil {
+			return fmt.Errorf("failed to read the control block: %v", err)
+		}
+		diffCount, extraCount, seek := offtin(entry[0:8]), offtin(entry[8:16]), offtin(entry[16:24])
+		if diffCount < 0 || extraCount < 0 || diffCount > newSize-newPos || extraCount > newSize-newPos-diffCount {
+			return errCorruptPatch
+		}
+
+		for diffCount > 0 {
+			n := int64(len(chunk))
+			if diffCount < n {
+				n = diffCount
+			}
+			if _, err := io.ReadFull(diff, chunk[:n]); err != nil {
+				return fmt.Errorf("failed to read the diff block: %v", err)
+			}
+			if err := addBase(base, baseSize, basePos, chunk[:n], baseChunk); err != nil {
+				return fmt.Errorf("failed to read the base file: %v", err)
+			}
+			if _, err := output.Write(chunk[:n]); err != nil {
+				return err
+			}
+			diffCount -= n
+			basePos += n
+			newPos += n
+		}
+
+		if _, err := io.CopyN(output, extra, extraCount); err != nil {
+			return fmt.Errorf("failed to read the extra block: %v", err)
+		}
+		newPos += extraCount
+		basePos += seek
+	}
+	return nil
+}
+
+// addBase adds the bytes of the base file at the offset to the diff bytes, the bytes outside of the base file are
+// zeros
+func addBase(base io.ReaderAt, baseSize int64, offset int64, diff []byte, buffer []byte) error {
+	start, end := offset, offset+int64(len(diff))
+	if start < 0 {
+		start = 0
+	}
+	if end > baseSize {
+		end = baseSize
+	}
+	if start >= end {
+		return nil
+	}
+	baseBytes := buffer[:end-start]
+	if _, err := base.ReadAt(baseBytes, start); err != nil {
+		return err
+	}
+	for i, b := range baseBytes {
+		diff[start-offset+int64(i)] += b
+	}
+	return nil
+}
+
+// offtin decodes the integers of bsdiff patches, 8 bytes of magnitude in little endian with the sign in the top bit
+func offtin(buf []byte) int64 {
+	value := int64(buf[7] & 0x7f)
+	for i := 6; i >= 0; i-- {
+		value = value<<8 | int64(buf[i])
+	}
+	if buf[7]&0x80 != 0 {
+		value = -value
+	}
+	return value
+}
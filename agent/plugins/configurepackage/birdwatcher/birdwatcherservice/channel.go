@@ -0,0 +1,72 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// selectChannelVersion returns the version the latest manifest advertises to the rollout channel of the
+// instance, or the version of the manifest when the instance follows the stable channel, the manifest has no
+// version for the channel or the instance is held back from its rollout
+func (ds *PackageService) selectChannelVersion(tracer trace.Tracer, packageName string, manifest *birdwatcher.Manifest) string {
+	channelName := strings.ToLower(ds.channel)
+	if channelName == "" || channelName == packageservice.ChannelStable {
+		return manifest.Version
+	}
+	var channel *birdwatcher.Channel
+	for name, c := range manifest.Channels {
+		if strings.EqualFold(name, channelName) {
+			channel = c
+			break
+		}
+	}
+	if channel == nil || channel.Version == manifest.Version {
+		return manifest.Version
+	}
+
+	log := tracer.CurrentTrace().Logger
+	var instanceID string
+	if env, err := ds.collector.CollectData(log); err == nil && env.Ec2Infrastructure != nil {
+		instanceID = env.Ec2Infrastructure.InstanceID
+	}
+	if channel.RolloutPercent != nil && !inRollout(instanceID, packageName, channel.Version, *channel.RolloutPercent) {
+		tracer.CurrentTrace().AppendInfof("held back from the %v%% rollout of version %v to channel %v, installing version %v",
+			*channel.RolloutPercent, channel.Version, channelName, manifest.Version)
+		return manifest.Version
+	}
+	tracer.CurrentTrace().AppendInfof("channel %v selects version %v", channelName, channel.Version)
+	return channel.Version
+}
+
+// inRollout places the instance in one of 100 buckets, the same for every run of the rollout of a version of a
+// package, and returns true when the bucket is within the rollout percentage. Raising the percentage keeps the
+// instances already in the rollout, and each version and package rolls out to a different set of instances first.
+// Instances without an id are held back unless the version rolls out to all instances
+func inRollout(instanceID string, packageName string, version string, percent int) bool {
+	if percent >= 100 {
+		return true
+	}
+	if instanceID == "" || percent <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(instanceID + "/" + packageName + "/" + version))
+	return int(h.Sum32()%100) < percent
+}
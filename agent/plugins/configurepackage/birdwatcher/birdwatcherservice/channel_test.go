@@ -0,0 +1,133 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// versionedArchiveStub serves the manifests of the versions of a package, latest is the manifest of the empty version
+type versionedArchiveStub struct {
+	manifests  map[string]string
+	downloaded []string
+}
+
+func (a *versionedArchiveStub) Name() string {
+	return "stub"
+}
+
+func (a *versionedArchiveStub) GetResourceVersion(packageName string, packageVersion string) (string, string) {
+	return packageName, packageVersion
+}
+
+func (a *versionedArchiveStub) DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error) {
+	if packageservice.IsLatest(version) {
+		version = ""
+	}
+	a.downloaded = append(a.downloaded, version)
+	if manifest, ok := a.manifests[version]; ok {
+		return manifest, nil
+	}
+	return "", fmt.Errorf("version %v not found", version)
+}
+
+func (a *versionedArchiveStub) GetFileDownloadLocation(ctx context.Context, file *archive.File, packageName string, version string) (string, error) {
+	return "", nil
+}
+
+func (a *versionedArchiveStub) GetResourceArn(manifest *birdwatcher.Manifest) string {
+	return manifest.PackageArn
+}
+
+func channelManifest(version string, channels string) string {
+	return fmt.Sprintf(`{"schemaVersion": "2.0", "version": "%v", "packageArn": "packagearn",
+		"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}},
+		"files": {"package.zip": {}}, "channels": {%v}}`, version, channels)
+}
+
+func collectorWithInstance(instanceID string) *envdetect.CollectorMock {
+	collector := &envdetect.CollectorMock{}
+	collector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+		Ec2Infrastructure: &ec2infradetect.Ec2Infrastructure{InstanceID: instanceID},
+	}, nil)
+	return collector
+}
+
+func TestDownloadManifestSelectsChannelVersion(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test channels")
+	channels := `"beta": {"version": "1.1.0"}, "canary": {"version": "1.2.0", "rolloutPercent": 0}`
+
+	testCases := []struct {
+		name       string
+		channel    string
+		version    string
+		expected   string
+		downloaded []string
+	}{
+		{"stable", "", packageservice.Latest, "1.0.0", []string{""}},
+		{"explicit stable", "Stable", packageservice.Latest, "1.0.0", []string{""}},
+		{"beta", "beta", packageservice.Latest, "1.1.0", []string{"", "1.1.0"}},
+		{"held back from canary", "canary", packageservice.Latest, "1.0.0", []string{""}},
+		{"unknown channel", "nightly", packageservice.Latest, "1.0.0", []string{""}},
+		{"concrete version", "beta", "1.0.0", "1.0.0", []string{"1.0.0"}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			stub := &versionedArchiveStub{manifests: map[string]string{
+				"":      channelManifest("1.0.0", channels),
+				"1.0.0": channelManifest("1.0.0", channels),
+				"1.1.0": channelManifest("1.1.0", ""),
+			}}
+			ds := &PackageService{manifestCache: packageservice.ManifestCacheMemNew(), collector: collectorWithInstance("i-0123456789abcdef0"), archive: stub}
+			ds.SetChannel(testCase.channel)
+
+			_, version, _, err := ds.DownloadManifest(context.Background(), tracer, "packagename", testCase.version)
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, version)
+			assert.Equal(t, testCase.downloaded, stub.downloaded)
+		})
+	}
+}
+
+func TestInRollout(t *testing.T) {
+	assert.True(t, inRollout("", "packagename", "1.0.0", 100))
+	assert.False(t, inRollout("i-0123456789abcdef0", "packagename", "1.0.0", 0))
+	assert.False(t, inRollout("", "packagename", "1.0.0", 50))
+
+	included := 0
+	for i := 0; i < 1000; i++ {
+		instanceID := fmt.Sprintf("i-%017x", i)
+		if inRollout(instanceID, "packagename", "1.0.0", 20) {
+			included++
+			// raising the percentage keeps the instances already in the rollout
+			assert.True(t, inRollout(instanceID, "packagename", "1.0.0", 50))
+		}
+		assert.Equal(t, inRollout(instanceID, "packagename", "1.0.0", 20), inRollout(instanceID, "packagename", "1.0.0", 20))
+	}
+	assert.InDelta(t, 200, included, 60)
+}
@@ -0,0 +1,202 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// deltaFormatBsdiff is the format of deltas that do not declare one
+const deltaFormatBsdiff = "bsdiff"
+
+// downloadDelta downloads the delta from the cached artifact of a previous version of the package and patches the
+// cached artifact with it. It returns false when the package has no delta from the cached artifact or the delta
+// fails, the full artifact is downloaded then.
+func (ds *PackageService) downloadDelta(ctx context.Context, tracer trace.Tracer, manifest *birdwatcher.Manifest, pkginfo *birdwatcher.PackageInfo, file *archive.File, packageName string, version string) (string, bool) {
+	if ds.artifactCache == "" || len(pkginfo.Deltas) == 0 || len(file.Info.Checksums) == 0 {
+		// the patched artifact can only be trusted when it is verified against the checksums of the full artifact
+		return "", false
+	}
+	baseVersion, basePath, ok := ds.cachedArtifact(packageName)
+	if !ok || baseVersion == version {
+		return "", false
+	}
+	delta, ok := pkginfo.Deltas[baseVersion]
+	if !ok || delta == nil || (delta.Format != "" && delta.Format != deltaFormatBsdiff) {
+		return "", false
+	}
+
+	trace := tracer.BeginSection(fmt.Sprintf("download delta from version %v", baseVersion))
+	filePath, err := ds.patchArtifact(ctx, tracer, manifest, delta, basePath, file, packageName, version)
+	if err != nil {
+		trace.AppendInfof("downloading the full artifact, the delta failed: %v", err).End()
+		return "", false
+	}
+	trace.AppendInfof("patched version %v into %v", baseVersion, version).End()
+	return filePath, true
+}
+
+// patchArtifact checks the base artifact is the one the delta patches, downloads the delta and patches the base
+// artifact with it into a file verified against the checksums of the full artifact
+func (ds *PackageService) patchArtifact(ctx context.Context, tracer trace.Tracer, manifest *birdwatcher.Manifest, delta *birdwatcher.Delta, basePath string, file *archive.File, packageName string, version string) (string, error) {
+	log := tracer.CurrentTrace().Logger
+	if len(delta.BaseChecksums) == 0 {
+		return "", fmt.Errorf("the delta declares no base checksum")
+	}
+	baseInput := artifact.DownloadInput{SourceURL: basePath, SourceChecksums: delta.BaseChecksums}
+	if matched, err := artifact.VerifyHash(log, baseInput, artifact.DownloadOutput{LocalFilePath: basePath}); !matched {
+		return "", fmt.Errorf("the cached artifact is not the base of the delta: %v", err)
+	}
+	deltaInfo, ok := manifest.Files[delta.FileName]
+	if !ok || deltaInfo == nil {
+		return "", fmt.Errorf("delta %v is not a file of the manifest", delta.FileName)
+	}
+
+	deltaPath, err := fetchFile(ctx, ds, tracer, &archive.File{Name: delta.FileName, Info: *deltaInfo}, packageName, version)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(deltaPath)
+
+	filePath := deltaPath + ".patched"
+	if err = patchFile(basePath, deltaPath, filePath); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	input := artifact.DownloadInput{SourceURL: filePath, SourceChecksums: file.Info.Checksums, SourceSize: int64(file.Info.Size)}
+	if matched, err := artifact.VerifyHash(log, input, artifact.DownloadOutput{LocalFilePath: filePath}); !matched {
+		os.Remove(filePath)
+		return "", fmt.Errorf("the patched artifact does not match its checksums: %v", err)
+	}
+	// the signature of the full artifact covers the patched artifact, which matches its checksums
+	if err = verifyArtifactSignature(ctx, ds, tracer, manifest, file, filePath, packageName, version); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, nil
+}
+
+// patchFile writes the file the bsdiff patch makes of the base file
+func patchFile(basePath string, patchPath string, outputPath string) error {
+	base, err := os.Open(basePath)
+	if err != nil {
+		return err
+	}
+	defer base.Close()
+	baseStat, err := base.Stat()
+	if err != nil {
+		return err
+	}
+	patch, err := os.Open(patchPath)
+	if err != nil {
+		return err
+	}
+	defer patch.Close()
+	patchStat, err := patch.Stat()
+	if err != nil {
+		return err
+	}
+	output, err := os.OpenFile(outputPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err = applyBsdiff(base, baseStat.Size(), patch, patchStat.Size(), output); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
+
+// artifactCacheDirectory returns the directory of the cached artifact of the package, named after the hash of the
+// package name which may be an arn
+func (ds *PackageService) artifactCacheDirectory(packageName string) string {
+	return filepath.Join(ds.artifactCache, fmt.Sprintf("%x", sha1.Sum([]byte(packageName))))
+}
+
+// cachedArtifact returns the version and the path of the cached artifact of the package
+func (ds *PackageService) cachedArtifact(packageName string) (version string, path string, ok bool) {
+	files, err := ioutil.ReadDir(ds.artifactCacheDirectory(packageName))
+	if err != nil {
+		return "", "", false
+	}
+	for _, file := range files {
+		if file.Mode().IsRegular() && manifestVersionPattern.MatchString(file.Name()) {
+			return file.Name(), filepath.Join(ds.artifactCacheDirectory(packageName), file.Name()), true
+		}
+	}
+	return "", "", false
+}
+
+// cacheArtifact keeps a copy of the downloaded artifact as the base of the deltas of the next versions of the
+// package, replacing the artifact of the previous version. The artifact is still installed when it is not cached.
+func (ds *PackageService) cacheArtifact(tracer trace.Tracer, packageName string, version string, filePath string) {
+	if ds.artifactCache == "" || !manifestVersionPattern.MatchString(version) {
+		return
+	}
+	log := tracer.CurrentTrace().Logger
+	directory := ds.artifactCacheDirectory(packageName)
+	if err := fileutil.MakeDirs(directory); err != nil {
+		log.Warnf("Failed to create the artifact cache of %v: %v", packageName, err)
+		return
+	}
+	// the copy is not a version until it is complete, versions may not start with a dot
+	copyPath := filepath.Join(directory, ".copy")
+	if err := copyFile(filePath, copyPath); err != nil {
+		log.Warnf("Failed to cache the artifact of %v %v: %v", packageName, version, err)
+		os.Remove(copyPath)
+		return
+	}
+	if err := os.Rename(copyPath, filepath.Join(directory, version)); err != nil {
+		log.Warnf("Failed to cache the artifact of %v %v: %v", packageName, version, err)
+		os.Remove(copyPath)
+		return
+	}
+
+	files, _ := ioutil.ReadDir(directory)
+	for _, file := range files {
+		if file.Name() != version {
+			os.RemoveAll(filepath.Join(directory, file.Name()))
+		}
+	}
+}
+
+// copyFile copies the file, the copy is readable by its owner only
+func copyFile(source string, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(destination, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testBase = []byte("The quick brown fox jumps over the lazy dog.\n")
+var testPatched = []byte("The quick brown cat jumps over the lazy dog!\nAnd again: The quick")
+
+// testPatch is the bsdiff patch of testBase into testPatched, its second control entry seeks back to the start of
+// the base file
+var testPatch, _ = hex.DecodeString(
+	"425344494646343037000000000000003a000000000000004100000000000000425a6839314159265359d914bcbb00001360" +
+		"40582848104000200031064c40c299924996bb012184473abcbbe2ee48a70a121b22979760425a6839314159265359debf29" +
+		"600000005611e0000400000820000001020018012000220323210030ca9e5a43570d9f177245385090debf2960425a683931" +
+		"4159265359455270ee0000039d804000001020002ca104002000220d343210030c09948150d8f177245385090455270ee0")
+
+// deltaNetworkMock downloads the content of the urls to files of a directory
+type deltaNetworkMock struct {
+	directory  string
+	files      map[string][]byte
+	downloaded []string
+}
+
+func (n *deltaNetworkMock) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	n.downloaded = append(n.downloaded, input.SourceURL)
+	path := filepath.Join(n.directory, fmt.Sprintf("download%v", len(n.downloaded)))
+	err := ioutil.WriteFile(path, n.files[input.SourceURL], 0600)
+	return artifact.DownloadOutput{LocalFilePath: path, IsHashMatched: true}, err
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestApplyBsdiff(t *testing.T) {
+	var output bytes.Buffer
+	err := applyBsdiff(bytes.NewReader(testBase), int64(len(testBase)), bytes.NewReader(testPatch), int64(len(testPatch)), &output)
+	assert.NoError(t, err)
+	assert.Equal(t, string(testPatched), output.String())
+
+	longer := append([]byte{}, testPatch...)
+	longer[24]++
+	truncated := testPatch[:len(testPatch)-20]
+	for name, patch := range map[string][]byte{"not a patch": testPatched, "size past the control block": longer, "truncated": truncated} {
+		t.Run(name, func(t *testing.T) {
+			var output bytes.Buffer
+			err := applyBsdiff(bytes.NewReader(testBase), int64(len(testBase)), bytes.NewReader(patch), int64(len(patch)), &output)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDownloadArtifactFromDelta(t *testing.T) {
+	manifest := func(format string) string {
+		return fmt.Sprintf(`{"version": "1.1.0",
+			"packages": {"platformName": {"platformVersion": {"architecture": {"file": "test.zip",
+				"deltas": {"1.0.0": {"file": "test-1.0.0.bsdiff", "format": %q, "baseChecksums": {"sha256": %q}}}}}}},
+			"files": {"test.zip": {"downloadLocation": "https://example.com/test.zip", "checksums": {"sha256": %q}, "size": %v},
+				"test-1.0.0.bsdiff": {"downloadLocation": "https://example.com/test-1.0.0.bsdiff"}}}`,
+			format, sha256Hex(testBase), sha256Hex(testPatched), len(testPatched))
+	}
+
+	testCases := []struct {
+		name               string
+		manifest           string
+		cachedVersion      string
+		cachedArtifact     []byte
+		patch              []byte
+		expectedDownloaded []string
+	}{
+		{
+			"delta from the cached version",
+			manifest(""),
+			"1.0.0",
+			testBase,
+			testPatch,
+			[]string{"https://example.com/test-1.0.0.bsdiff"},
+		},
+		{
+			"no cached version",
+			manifest(""),
+			"",
+			nil,
+			testPatch,
+			[]string{"https://example.com/test.zip"},
+		},
+		{
+			"no delta from the cached version",
+			manifest(""),
+			"0.9.0",
+			testBase,
+			testPatch,
+			[]string{"https://example.com/test.zip"},
+		},
+		{
+			"cached version is not the base of the delta",
+			manifest(""),
+			"1.0.0",
+			testPatched,
+			testPatch,
+			[]string{"https://example.com/test.zip"},
+		},
+		{
+			"unsupported format",
+			manifest("zstd"),
+			"1.0.0",
+			testBase,
+			testPatch,
+			[]string{"https://example.com/test.zip"},
+		},
+		{
+			"delta fails",
+			manifest("bsdiff"),
+			"1.0.0",
+			testBase,
+			testPatch[:len(testPatch)-20],
+			[]string{"https://example.com/test-1.0.0.bsdiff", "https://example.com/test.zip"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			directory, err := ioutil.TempDir("", "delta")
+			assert.NoError(t, err)
+			defer os.RemoveAll(directory)
+
+			tracer := trace.NewTracer(log.NewMockLog())
+			tracer.BeginSection("test segment root")
+			cache := packageservice.ManifestCacheMemNew()
+			cache.WriteManifest("packageName", "1.1.0", []byte(testCase.manifest))
+			mockedCollector := envdetect.CollectorMock{}
+			mockedCollector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+				&osdetect.OperatingSystem{"platformName", "platformVersion", "", "architecture", "", ""},
+				&ec2infradetect.Ec2Infrastructure{"instanceID", "region", "", "availabilityZone", "instanceType", "ec2"},
+				nil,
+				nil,
+			}, nil)
+			ds := &PackageService{manifestCache: cache, collector: &mockedCollector, archive: birdwatcherarchive.New(&facade.FacadeStub{}, testCase.manifest),
+				artifactCache: filepath.Join(directory, "cache")}
+			if testCase.cachedVersion != "" {
+				assert.NoError(t, os.MkdirAll(ds.artifactCacheDirectory("packageName"), 0700))
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(ds.artifactCacheDirectory("packageName"), testCase.cachedVersion), testCase.cachedArtifact, 0600))
+			}
+			network := &deltaNetworkMock{directory: directory, files: map[string][]byte{
+				"https://example.com/test.zip":          testPatched,
+				"https://example.com/test-1.0.0.bsdiff": testCase.patch,
+			}}
+			birdwatcher.Networkdep = network
+
+			result, err := ds.DownloadArtifact(context.Background(), tracer, "packageName", "1.1.0")
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expectedDownloaded, network.downloaded)
+			content, err := ioutil.ReadFile(result)
+			assert.NoError(t, err)
+			assert.Equal(t, string(testPatched), string(content))
+
+			version, path, ok := ds.cachedArtifact("packageName")
+			assert.True(t, ok)
+			assert.Equal(t, "1.1.0", version)
+			content, err = ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, string(testPatched), string(content))
+			files, err := ioutil.ReadDir(ds.artifactCacheDirectory("packageName"))
+			assert.NoError(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
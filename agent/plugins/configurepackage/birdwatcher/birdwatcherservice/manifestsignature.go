@@ -0,0 +1,124 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// ManifestSignatureFileName is the package file holding the detached signature of the manifest,
+// it is listed in the manifest files like any other file (as an attachment for document packages)
+const ManifestSignatureFileName = "manifest.sig"
+
+// verifyManifestSignature downloads the detached signature of a manifest and verifies it against the configured public key
+func verifyManifestSignature(ctx context.Context, ds *PackageService, tracer trace.Tracer, manifest *birdwatcher.Manifest, rawManifest []byte, packageName string, version string) (err error) {
+	trace := tracer.BeginSection("verify manifest signature")
+	defer func() {
+		if err != nil {
+			trace.WithError(err)
+		}
+		trace.End()
+	}()
+
+	publicKey, err := loadPublicKey(ds.signing.PublicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	info, ok := manifest.Files[ManifestSignatureFileName]
+	if !ok || info == nil {
+		return fmt.Errorf("manifest does not list a %v file", ManifestSignatureFileName)
+	}
+	signaturePath, err := fetchFile(ctx, ds, tracer, &archive.File{Name: ManifestSignatureFileName, Info: *info}, packageName, version)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest signature: %v", err)
+	}
+
+	return verifySignature(publicKey, rawManifest, decodeSignature(content))
+}
+
+// loadPublicKey reads a PEM encoded PKIX public key
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	if path == "" {
+		return nil, errors.New("no public key is configured to verify manifests with")
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %v", err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("%v does not contain a PEM encoded public key", path)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// decodeSignature accepts both base64 encoded and raw signatures
+func decodeSignature(content []byte) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content))); err == nil {
+		return decoded
+	}
+	return content
+}
+
+// verifySignature verifies a sha256 based signature of data
+func verifySignature(publicKey crypto.PublicKey, data []byte, signature []byte) error {
+	matched := false
+	if key, ok := publicKey.(ed25519.PublicKey); ok {
+		matched = ed25519.Verify(key, data, signature)
+	} else {
+		digest := sha256.Sum256(data)
+		var err error
+		if matched, err = verifyDigestSignature(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return err
+		}
+	}
+	if !matched {
+		return errors.New("signature does not match the manifest")
+	}
+	return nil
+}
+
+// verifyDigestSignature verifies an RSA or ECDSA signature of a digest,
+// RSA signatures may use either PKCS #1 v1.5 or PSS padding
+func verifyDigestSignature(publicKey crypto.PublicKey, hash crypto.Hash, digest []byte, signature []byte) (bool, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil || rsa.VerifyPSS(key, hash, digest, signature, nil) == nil, nil
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest, signature), nil
+	default:
+		return false, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+const signedManifest = `{"schemaVersion": "2.0", "version": "1234", "packageArn": "packagearn",
+	"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}},
+	"files": {"package.zip": {"size": 4}, "manifest.sig": {"downloadLocation": "https://example.com/manifest.sig"}}}`
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte(signedManifest)
+	digest := sha256.Sum256(data)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaSignature, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	pssSignature, _ := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:], nil)
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecdsaSignature, _ := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+	ed25519Public, ed25519Private, _ := ed25519.GenerateKey(rand.Reader)
+	ed25519Signature := ed25519.Sign(ed25519Private, data)
+
+	testCases := []struct {
+		name      string
+		publicKey crypto.PublicKey
+		signature []byte
+	}{
+		{"rsa pkcs1v15", &rsaKey.PublicKey, rsaSignature},
+		{"rsa pss", &rsaKey.PublicKey, pssSignature},
+		{"ecdsa", &ecdsaKey.PublicKey, ecdsaSignature},
+		{"ed25519", ed25519Public, ed25519Signature},
+	}
+	for _, testCase := range testCases {
+		assert.NoError(t, verifySignature(testCase.publicKey, data, testCase.signature), testCase.name)
+		assert.Error(t, verifySignature(testCase.publicKey, append(data, ' '), testCase.signature), testCase.name)
+	}
+	assert.Error(t, verifySignature("not a key", data, rsaSignature))
+}
+
+func TestDownloadManifestWithSigning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifestsigning")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	publicKey, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKeyPath := filepath.Join(dir, "public.pem")
+	assert.NoError(t, ioutil.WriteFile(publicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0600))
+
+	digest := sha256.Sum256([]byte(signedManifest))
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	signaturePath := filepath.Join(dir, "manifest.sig")
+	assert.NoError(t, ioutil.WriteFile(signaturePath, []byte(base64.StdEncoding.EncodeToString(signature)), 0600))
+	badSignaturePath := filepath.Join(dir, "bad.sig")
+	assert.NoError(t, ioutil.WriteFile(badSignaturePath, []byte("bm90IGEgc2lnbmF0dXJl"), 0600))
+
+	unsignedManifest := testManifest
+	enabled := appconfig.ManifestSigningCfg{Enabled: true, PublicKeyPath: publicKeyPath}
+
+	data := []struct {
+		name          string
+		manifest      string
+		signing       appconfig.ManifestSigningCfg
+		signaturePath string
+		expectedErr   bool
+	}{
+		{"signing disabled", unsignedManifest, appconfig.ManifestSigningCfg{}, "", false},
+		{"valid signature", signedManifest, enabled, signaturePath, false},
+		{"unsigned manifest", unsignedManifest, enabled, "", true},
+		{"tampered manifest", signedManifest, enabled, badSignaturePath, true},
+		{"no public key configured", signedManifest, appconfig.ManifestSigningCfg{Enabled: true}, signaturePath, true},
+	}
+
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			manifest := testdata.manifest
+			facadeClient := facade.FacadeStub{GetManifestOutput: &ssm.GetManifestOutput{Manifest: &manifest}}
+			birdwatcher.Networkdep = &networkMock{downloadOutput: artifact.DownloadOutput{LocalFilePath: testdata.signaturePath}}
+			cache := packageservice.ManifestCacheMemNew()
+			ds := &PackageService{
+				manifestCache: cache,
+				collector:     &envdetect.CollectorMock{},
+				archive:       birdwatcherarchive.New(&facadeClient, ""),
+				signing:       testdata.signing,
+			}
+
+			_, _, _, err := ds.DownloadManifest(context.Background(), trace.NewTracer(log.NewMockLog()), "packagename", "1234")
+
+			cachedManifest, _ := cache.ReadManifest("packagearn", "1234")
+			if testdata.expectedErr {
+				assert.Error(t, err)
+				assert.Empty(t, cachedManifest)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, []byte(manifest), cachedManifest)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+// manifestVersionPattern is the format of package versions, they name the directories of the package
+// versions so they may not contain path separators
+var manifestVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// manifestErrors collects the problems of a manifest, each qualified by the path of its field, they are
+// sorted by path when reported
+type manifestErrors []string
+
+func (errs *manifestErrors) add(path string, format string, args ...interface{}) {
+	*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+}
+
+// validateManifest checks the structure of a downloaded manifest, so broken manifests fail with all their
+// problems at once rather than with the first step that stumbles on one of them
+func validateManifest(manifest *birdwatcher.Manifest, resourceArn string) error {
+	var errs manifestErrors
+
+	if manifest.SchemaVersion == "" {
+		errs.add("schemaVersion", "is required")
+	}
+	if manifest.Version == "" {
+		errs.add("version", "is required")
+	} else if !manifestVersionPattern.MatchString(manifest.Version) {
+		errs.add("version", "%q is not a valid version, expected letters, digits and . _ + -", manifest.Version)
+	}
+	if resourceArn == "" {
+		errs.add("packageArn", "is required")
+	}
+
+	if len(manifest.Packages) == 0 {
+		errs.add("packages", "must contain at least one platform")
+	}
+	for platform, versions := range manifest.Packages {
+		if len(versions) == 0 {
+			errs.add("packages."+platform, "must contain at least one platform version")
+		}
+		for version, architectures := range versions {
+			versionPath := "packages." + platform + "." + version
+			if isVersionSelector(version) {
+				if _, err := parseVersionSelector(version); err != nil {
+					errs.add(versionPath, "is not a valid version selector: %v", err)
+				}
+			}
+			if len(architectures) == 0 {
+				errs.add(versionPath, "must contain at least one architecture")
+			}
+			for arch, pkginfo := range architectures {
+				validatePackageInfo(&errs, versionPath+"."+arch, pkginfo, manifest.Files)
+			}
+		}
+	}
+
+	if len(manifest.Files) == 0 {
+		errs.add("files", "must contain at least one file")
+	}
+	for name, fileInfo := range manifest.Files {
+		validateFileInfo(&errs, "files."+name, fileInfo)
+		if fileInfo != nil && fileInfo.Signature != nil {
+			validateFileSignature(&errs, "files."+name+".signature", fileInfo.Signature, manifest.Files)
+		}
+	}
+
+	if manifest.Hooks != nil {
+		validateHook(&errs, "hooks.preinstall", manifest.Hooks.PreInstall)
+		validateHook(&errs, "hooks.postinstall", manifest.Hooks.PostInstall)
+		validateHook(&errs, "hooks.preuninstall", manifest.Hooks.PreUninstall)
+	}
+
+	for name, channel := range manifest.Channels {
+		validateChannel(&errs, "channels."+name, channel)
+	}
+
+	for action, timeoutSeconds := range manifest.ActionTimeouts {
+		switch action {
+		case packageservice.ActionInstall, packageservice.ActionUninstall, packageservice.ActionValidate:
+		default:
+			errs.add("actionTimeouts."+action, "is not an action, expected %v, %v or %v", packageservice.ActionInstall, packageservice.ActionUninstall, packageservice.ActionValidate)
+		}
+		if timeoutSeconds < packageservice.MinActionTimeoutSeconds || timeoutSeconds > packageservice.MaxActionTimeoutSeconds {
+			errs.add("actionTimeouts."+action, "must be between %v and %v seconds", packageservice.MinActionTimeoutSeconds, packageservice.MaxActionTimeoutSeconds)
+		}
+	}
+
+	validateInstallParameters(&errs, "installParameters", manifest.InstallParameters)
+
+	for i, dependency := range manifest.Dependencies {
+		if dependency.Name == "" {
+			errs.add(fmt.Sprintf("dependencies[%v].name", i), "is required")
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("manifest is not valid:\n%v", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// validatePackageInfo checks the package of a platform references files of the manifest
+func validatePackageInfo(errs *manifestErrors, path string, pkginfo *birdwatcher.PackageInfo, files map[string]*birdwatcher.FileInfo) {
+	if pkginfo == nil {
+		errs.add(path, "is empty")
+		return
+	}
+	if pkginfo.FileName == "" {
+		errs.add(path+".file", "is required")
+	} else if _, ok := files[pkginfo.FileName]; !ok {
+		errs.add(path+".file", "%q is not in files", pkginfo.FileName)
+	}
+	for i, attachment := range pkginfo.Attachments {
+		if _, ok := files[attachment]; !ok {
+			errs.add(fmt.Sprintf("%v.attachments[%v]", path, i), "%q is not in files", attachment)
+		}
+	}
+	validateInstallParameters(errs, path+".installParameters", pkginfo.InstallParameters)
+	for baseVersion, delta := range pkginfo.Deltas {
+		deltaPath := path + ".deltas." + baseVersion
+		if !manifestVersionPattern.MatchString(baseVersion) {
+			errs.add(deltaPath, "%q is not a valid version, expected letters, digits and . _ + -", baseVersion)
+		}
+		if delta == nil {
+			errs.add(deltaPath, "is empty")
+			continue
+		}
+		if delta.FileName == "" {
+			errs.add(deltaPath+".file", "is required")
+		} else if _, ok := files[delta.FileName]; !ok {
+			errs.add(deltaPath+".file", "%q is not in files", delta.FileName)
+		}
+		if len(delta.BaseChecksums) == 0 {
+			errs.add(deltaPath+".baseChecksums", "must contain at least one checksum")
+		}
+		for algorithm, value := range delta.BaseChecksums {
+			if err := artifact.ValidateChecksum(algorithm, value); err != nil {
+				errs.add(deltaPath+".baseChecksums."+algorithm, "%v", err)
+			}
+		}
+	}
+}
+
+// validateChannel checks a channel advertises a valid version to a valid share of the instances
+func validateChannel(errs *manifestErrors, path string, channel *birdwatcher.Channel) {
+	if channel == nil {
+		errs.add(path, "is empty")
+		return
+	}
+	if channel.Version == "" {
+		errs.add(path+".version", "is required")
+	} else if !manifestVersionPattern.MatchString(channel.Version) {
+		errs.add(path+".version", "%q is not a valid version, expected letters, digits and . _ + -", channel.Version)
+	}
+	if channel.RolloutPercent != nil && (*channel.RolloutPercent < 0 || *channel.RolloutPercent > 100) {
+		errs.add(path+".rolloutPercent", "must be between 0 and 100")
+	}
+}
+
+// validateInstallParameters checks the names of install parameters can name environment variables
+func validateInstallParameters(errs *manifestErrors, path string, parameters map[string]string) {
+	for name := range parameters {
+		if !packageservice.InstallParameterNamePattern.MatchString(name) {
+			errs.add(path+"."+name, "is not a valid parameter name, expected letters, digits and _ starting with a letter")
+		}
+	}
+}
+
+// isRelativeSlashPath returns true if the path with / separators stays within the directory it is relative to
+func isRelativeSlashPath(path string) bool {
+	if path == "" || strings.HasPrefix(path, "/") || strings.Contains(path, "\\") || strings.Contains(path, ":") {
+		return false
+	}
+	for _, element := range strings.Split(path, "/") {
+		if element == "" || element == "." || element == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// validateHook checks a declared hook has commands and a timeout the agent can enforce
+func validateHook(errs *manifestErrors, path string, hook *birdwatcher.Hook) {
+	if hook == nil {
+		return
+	}
+	if len(hook.Commands) == 0 {
+		errs.add(path+".commands", "must contain at least one command")
+	}
+	if hook.TimeoutSeconds < 0 {
+		errs.add(path+".timeoutSeconds", "must not be negative")
+	}
+}
+
+// validateFileSignature checks the signature of a file has a supported format and is a file of the manifest
+func validateFileSignature(errs *manifestErrors, path string, signature *birdwatcher.FileSignature, files map[string]*birdwatcher.FileInfo) {
+	if format := strings.ToLower(signature.Format); format != SignatureFormatGpg && format != SignatureFormatSigstore {
+		errs.add(path+".format", "%q is not a supported signature format, expected %v or %v", signature.Format, SignatureFormatGpg, SignatureFormatSigstore)
+	}
+	if signature.File == "" {
+		errs.add(path+".file", "is required")
+	} else if _, ok := files[signature.File]; !ok {
+		errs.add(path+".file", "%q is not in files", signature.File)
+	}
+}
+
+// validateFileInfo checks the size and checksums of a file, checksums of unsupported algorithms are accepted
+// as long as the file has one the agent verifies
+func validateFileInfo(errs *manifestErrors, path string, fileInfo *birdwatcher.FileInfo) {
+	if fileInfo == nil {
+		errs.add(path, "is empty")
+		return
+	}
+	if fileInfo.Size < 0 {
+		errs.add(path+".size", "must not be negative")
+	}
+	for region, location := range fileInfo.RegionalDownloadLocations {
+		if location == "" {
+			errs.add(path+".regionalDownloadLocations."+region, "is empty")
+		}
+	}
+	for i, region := range fileInfo.FallbackRegions {
+		if _, ok := fileInfo.RegionalDownloadLocations[region]; !ok {
+			errs.add(fmt.Sprintf("%v.fallbackRegions[%v]", path, i), "%q is not in regionalDownloadLocations", region)
+		}
+	}
+	for extracted, checksum := range fileInfo.ExtractedFiles {
+		if !isRelativeSlashPath(extracted) {
+			errs.add(path+".extractedFiles."+extracted, "is not a relative path within the archive")
+		}
+		if err := artifact.ValidateChecksum("sha256", checksum); err != nil {
+			errs.add(path+".extractedFiles."+extracted, "%v", err)
+		}
+	}
+	switch strings.ToLower(fileInfo.ArchiveFormat) {
+	case "", fileutil.ArchiveFormatZip, fileutil.ArchiveFormatTarGz, fileutil.ArchiveFormatTarZst:
+	default:
+		errs.add(path+".archiveFormat", "%q is not a supported archive format, expected %v, %v or %v", fileInfo.ArchiveFormat, fileutil.ArchiveFormatZip, fileutil.ArchiveFormatTarGz, fileutil.ArchiveFormatTarZst)
+	}
+	if len(fileInfo.Checksums) == 0 {
+		return
+	}
+	var checksumErrs manifestErrors
+	for algorithm, value := range fileInfo.Checksums {
+		if err := artifact.ValidateChecksum(algorithm, value); err != nil {
+			checksumErrs.add(path+".checksums."+algorithm, "%v", err)
+		}
+	}
+	if len(checksumErrs) == len(fileInfo.Checksums) {
+		*errs = append(*errs, checksumErrs...)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateManifest(t *testing.T) {
+	var manifest birdwatcher.Manifest
+	assert.NoError(t, json.Unmarshal([]byte(testManifest), &manifest))
+	assert.NoError(t, validateManifest(&manifest, "packagearn"))
+
+	testCases := []struct {
+		name     string
+		manifest string
+		errors   []string
+	}{
+		{
+			"empty manifest",
+			`{}`,
+			[]string{"files: must contain at least one file", "packageArn: is required", "packages: must contain at least one platform",
+				"schemaVersion: is required", "version: is required"},
+		},
+		{
+			"missing files",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"amazon": {"_any": {"x86_64": {"file": "package.zip", "attachments": ["data.bin"]}, "arm64": {}}}},
+				"files": {"other.zip": {}}}`,
+			[]string{`packages.amazon._any.arm64.file: is required`,
+				`packages.amazon._any.x86_64.attachments[0]: "data.bin" is not in files`,
+				`packages.amazon._any.x86_64.file: "package.zip" is not in files`},
+		},
+		{
+			"bad version and selector",
+			`{"schemaVersion": "2.0", "version": "../1.0", "packageArn": "packagearn",
+				"packages": {"ubuntu": {">=a": {"_any": {"file": "package.zip"}}, "18.04": {}}}, "files": {"package.zip": {}}}`,
+			[]string{`packages.ubuntu.18.04: must contain at least one architecture`,
+				`packages.ubuntu.>=a: is not a valid version selector: invalid version a`,
+				`version: "../1.0" is not a valid version, expected letters, digits and . _ + -`},
+		},
+		{
+			"bad files, hooks and dependencies",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}},
+				"files": {"package.zip": {"checksums": {"sha256": "abc"}, "size": -1},
+					"other.zip": {"checksums": {"sha3-256": "abc", "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}}},
+				"dependencies": [{"version": "1.0"}],
+				"hooks": {"preinstall": {"commands": []}, "postinstall": {"commands": ["systemctl start app"], "timeoutSeconds": -1}}}`,
+			[]string{`dependencies[0].name: is required`,
+				`files.package.zip.checksums.sha256: sha256 checksum abc is not a hex digest of 64 characters`,
+				`files.package.zip.size: must not be negative`,
+				`hooks.postinstall.timeoutSeconds: must not be negative`,
+				`hooks.preinstall.commands: must contain at least one command`},
+		},
+		{
+			"bad extracted files",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}},
+				"files": {"package.zip": {"extractedFiles": {"install.sh": "abc", "../etc/passwd": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+					"bin/tool": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}}}}`,
+			[]string{`files.package.zip.extractedFiles.../etc/passwd: is not a relative path within the archive`,
+				`files.package.zip.extractedFiles.install.sh: sha256 checksum abc is not a hex digest of 64 characters`},
+		},
+		{
+			"bad install parameters",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn", "installParameters": {"serviceName": "app", "install-path": "/opt/app"},
+				"packages": {"windows": {"_any": {"_any": {"file": "package.zip", "installParameters": {"1flags": "/quiet"}}}}},
+				"files": {"package.zip": {}}}`,
+			[]string{`installParameters.install-path: is not a valid parameter name, expected letters, digits and _ starting with a letter`,
+				`packages.windows._any._any.installParameters.1flags: is not a valid parameter name, expected letters, digits and _ starting with a letter`},
+		},
+		{
+			"bad regional download locations",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}},
+				"files": {"package.zip": {"regionalDownloadLocations": {"us-east-1": "", "eu-west-1": "https://euw1/package.zip"},
+					"fallbackRegions": ["eu-west-1", "us-west-2"]}}}`,
+			[]string{`files.package.zip.fallbackRegions[1]: "us-west-2" is not in regionalDownloadLocations`,
+				`files.package.zip.regionalDownloadLocations.us-east-1: is empty`},
+		},
+		{
+			"bad channels",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}}, "files": {"package.zip": {}},
+				"channels": {"beta": {"version": "../1.1"}, "canary": {"version": "1.2.0", "rolloutPercent": 101}, "edge": {}}}`,
+			[]string{`channels.beta.version: "../1.1" is not a valid version, expected letters, digits and . _ + -`,
+				`channels.canary.rolloutPercent: must be between 0 and 100`,
+				`channels.edge.version: is required`},
+		},
+		{
+			"bad deltas",
+			`{"schemaVersion": "2.0", "version": "1.1.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip", "deltas": {
+					"1.0.0": {"file": "package-1.0.0.bsdiff"}, "../1.0": {"file": "package.zip", "baseChecksums": {"sha256": "abc"}}}}}}},
+				"files": {"package.zip": {}}}`,
+			[]string{`packages._any._any._any.deltas.../1.0.baseChecksums.sha256: sha256 checksum abc is not a hex digest of 64 characters`,
+				`packages._any._any._any.deltas.../1.0: "../1.0" is not a valid version, expected letters, digits and . _ + -`,
+				`packages._any._any._any.deltas.1.0.0.baseChecksums: must contain at least one checksum`,
+				`packages._any._any._any.deltas.1.0.0.file: "package-1.0.0.bsdiff" is not in files`},
+		},
+		{
+			"bad signatures",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip", "attachments": ["data.bin"]}}}},
+				"files": {"package.zip": {"signature": {"format": "minisign", "file": "package.zip.minisig"}},
+					"data.bin": {"signature": {"format": "gpg"}}}}`,
+			[]string{`files.data.bin.signature.file: is required`,
+				`files.package.zip.signature.file: "package.zip.minisig" is not in files`,
+				`files.package.zip.signature.format: "minisign" is not a supported signature format, expected gpg or sigstore`},
+		},
+		{
+			"bad archive format",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.tar.xz"}}}},
+				"files": {"package.tar.xz": {"archiveFormat": "tar.xz"}}}`,
+			[]string{`files.package.tar.xz.archiveFormat: "tar.xz" is not a supported archive format, expected zip, tar.gz or tar.zst`},
+		},
+		{
+			"bad action timeouts",
+			`{"schemaVersion": "2.0", "version": "1.0.0", "packageArn": "packagearn",
+				"packages": {"_any": {"_any": {"_any": {"file": "package.zip"}}}}, "files": {"package.zip": {}},
+				"actionTimeouts": {"install": 600, "uninstall": 1, "upgrade": 60}}`,
+			[]string{`actionTimeouts.uninstall: must be between 5 and 172800 seconds`,
+				`actionTimeouts.upgrade: is not an action, expected install, uninstall or validate`},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var manifest birdwatcher.Manifest
+			assert.NoError(t, json.Unmarshal([]byte(testCase.manifest), &manifest))
+
+			err := validateManifest(&manifest, manifest.PackageArn)
+
+			assert.Error(t, err)
+			expected := "manifest is not valid:"
+			for _, e := range testCase.errors {
+				expected += "\n" + e
+			}
+			assert.Equal(t, expected, err.Error())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// downloadMirrors returns the mirrors the artifact downloads are redirected to
+var downloadMirrors = func() []appconfig.DownloadMirrorCfg {
+	if appCfg, err := appconfig.Config(false); err == nil {
+		return appCfg.Birdwatcher.DownloadMirrors
+	}
+	return nil
+}
+
+// downloadSource is a url an artifact is downloaded from and the headers of its requests
+type downloadSource struct {
+	url     string
+	headers map[string]string
+}
+
+// downloadSources returns the sources of the artifact locations in order, a location matching a mirror is preceded
+// by the url of the mirror so it is the fallback when the mirror is unavailable
+func downloadSources(mirrors []appconfig.DownloadMirrorCfg, locations []string) []downloadSource {
+	var sources []downloadSource
+	for _, location := range locations {
+		if mirror, ok := mirrorLocation(mirrors, location); ok && mirror.url != location {
+			sources = append(sources, mirror)
+		}
+		sources = append(sources, downloadSource{url: location})
+	}
+	return sources
+}
+
+// mirrorLocation returns the location rewritten by the first mirror matching it
+func mirrorLocation(mirrors []appconfig.DownloadMirrorCfg, location string) (downloadSource, bool) {
+	for _, mirror := range mirrors {
+		if mirror.Pattern != "" {
+			pattern, err := regexp.Compile(mirror.Pattern)
+			if err != nil || !pattern.MatchString(location) {
+				continue
+			}
+			return downloadSource{url: pattern.ReplaceAllString(location, mirror.Mirror), headers: mirror.Headers}, true
+		}
+		if mirror.Prefix != "" && strings.HasPrefix(location, mirror.Prefix) {
+			return downloadSource{url: mirror.Mirror + strings.TrimPrefix(location, mirror.Prefix), headers: mirror.Headers}, true
+		}
+	}
+	return downloadSource{}, false
+}
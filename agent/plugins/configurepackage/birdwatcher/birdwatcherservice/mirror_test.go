@@ -0,0 +1,79 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+package birdwatcherservice
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadSources(t *testing.T) {
+	headers := map[string]string{"Authorization": "Bearer token"}
+	mirrors := []appconfig.DownloadMirrorCfg{
+		{Pattern: `^https://([a-z0-9-]+)\.s3\.amazonaws\.com/`, Mirror: "https://mirror.example.com/$1/"},
+		{Prefix: "https://s3.amazonaws.com/", Mirror: "https://artifactory.example.com/s3/", Headers: headers},
+		{Prefix: "https://artifactory.example.com/", Mirror: "https://artifactory.example.com/"},
+	}
+
+	assert.Equal(t, []downloadSource{
+		{url: "https://mirror.example.com/bucket/agent.zip"},
+		{url: "https://bucket.s3.amazonaws.com/agent.zip"},
+		{url: "https://artifactory.example.com/s3/bucket/agent.zip", headers: headers},
+		{url: "https://s3.amazonaws.com/bucket/agent.zip"},
+		{url: "https://artifactory.example.com/agent.zip"},
+		{url: "https://other.example.com/agent.zip"},
+	}, downloadSources(mirrors, []string{
+		"https://bucket.s3.amazonaws.com/agent.zip",
+		"https://s3.amazonaws.com/bucket/agent.zip",
+		"https://artifactory.example.com/agent.zip",
+		"https://other.example.com/agent.zip",
+	}))
+	assert.Equal(t, []downloadSource{{url: "https://s3.amazonaws.com/bucket/agent.zip"}}, downloadSources(nil, []string{"https://s3.amazonaws.com/bucket/agent.zip"}))
+}
+
+func TestDownloadFileFromMirror(t *testing.T) {
+	defer func(original func() []appconfig.DownloadMirrorCfg) { downloadMirrors = original }(downloadMirrors)
+	headers := map[string]string{"Authorization": "Bearer token"}
+	downloadMirrors = func() []appconfig.DownloadMirrorCfg {
+		return []appconfig.DownloadMirrorCfg{{Prefix: "https://global/", Mirror: "https://mirror/", Headers: headers}}
+	}
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	file := &archive.File{"fileName.zip", birdwatcher.FileInfo{DownloadLocation: "https://global/agent.zip"}}
+	network := &failingLocationsNetworkMock{failing: map[string]bool{}}
+	birdwatcher.Networkdep = network
+	ds := &PackageService{manifestCache: packageservice.ManifestCacheMemNew(), collector: &envdetect.CollectorMock{}, archive: &regionalArchiveStub{}}
+
+	_, err := fetchFile(context.Background(), ds, tracer, file, "packagename", "version")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://mirror/agent.zip"}, network.downloaded)
+	assert.Equal(t, headers, network.headers[0])
+
+	// the original location is the fallback of an unavailable mirror
+	network = &failingLocationsNetworkMock{failing: map[string]bool{"https://mirror/agent.zip": true}}
+	birdwatcher.Networkdep = network
+	_, err = fetchFile(context.Background(), ds, tracer, file, "packagename", "version")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://global/agent.zip", network.downloaded[len(network.downloaded)-1])
+	assert.Nil(t, network.headers[len(network.headers)-1])
+}
@@ -0,0 +1,478 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+)
+
+// OpenPGP packet tags, public key algorithms and signature subpackets of the version 4 signatures verified by the agent
+const (
+	openpgpTagSignature    = 2
+	openpgpTagPublicKey    = 6
+	openpgpTagPublicSubkey = 14
+
+	openpgpAlgorithmRSA         = 1
+	openpgpAlgorithmRSASignOnly = 3
+	openpgpAlgorithmECDSA       = 19
+	openpgpAlgorithmEdDSA       = 22
+
+	openpgpSubpacketIssuer            = 16
+	openpgpSubpacketIssuerFingerprint = 33
+
+	openpgpSignatureTypeBinary = 0x00
+)
+
+// openpgpHashes are the hash algorithms accepted in signatures, MD5 and SHA-1 are rejected
+var openpgpHashes = map[byte]crypto.Hash{
+	8:  crypto.SHA256,
+	9:  crypto.SHA384,
+	10: crypto.SHA512,
+	11: crypto.SHA224,
+}
+
+// openpgpCurves are the ECDSA curves by their OID
+var openpgpCurves = map[string]elliptic.Curve{
+	"\x2a\x86\x48\xce\x3d\x03\x01\x07": elliptic.P256(),
+	"\x2b\x81\x04\x00\x22":             elliptic.P384(),
+	"\x2b\x81\x04\x00\x23":             elliptic.P521(),
+}
+
+// openpgpOidEd25519 is the OID of the EdDSA curve
+const openpgpOidEd25519 = "\x2b\x06\x01\x04\x01\xda\x47\x0f\x01"
+
+var errOpenPGPTruncated = errors.New("truncated OpenPGP data")
+
+// openpgpPacket is the tag and the body of an OpenPGP packet
+type openpgpPacket struct {
+	tag  byte
+	body []byte
+}
+
+// openpgpSignature is a version 4 signature, the hashed part goes from its version to its hashed subpackets
+type openpgpSignature struct {
+	signatureType byte
+	hash          crypto.Hash
+	hashedPart    []byte
+	issuer        uint64
+	hashPrefix    []byte
+	values        [][]byte
+}
+
+// verifyGpgSignature verifies a detached OpenPGP signature of a file with the keys of the pinned keyring,
+// the keys are trusted as pinned, their expiration and revocation are not checked
+func verifyGpgSignature(keyringPath string, filePath string, content []byte) error {
+	keys, err := readOpenPGPKeyring(keyringPath)
+	if err != nil {
+		return err
+	}
+	data, err := dearmorOpenPGP(content)
+	if err != nil {
+		return err
+	}
+	packets, err := readOpenPGPPackets(data)
+	if err != nil {
+		return err
+	}
+	verifyErr := errors.New("the signature is not made by a key of the keyring")
+	for _, packet := range packets {
+		if packet.tag != openpgpTagSignature {
+			continue
+		}
+		signature, err := parseOpenPGPSignature(packet.body)
+		if err != nil {
+			verifyErr = err
+			continue
+		}
+		candidates := keys
+		if publicKey, ok := keys[signature.issuer]; ok {
+			candidates = map[uint64]crypto.PublicKey{signature.issuer: publicKey}
+		} else if signature.issuer != 0 {
+			continue
+		}
+		if signature.signatureType != openpgpSignatureTypeBinary {
+			verifyErr = fmt.Errorf("unsupported OpenPGP signature type %#x", signature.signatureType)
+			continue
+		}
+		digest, err := signature.digest(filePath)
+		if err != nil {
+			return err
+		}
+		for _, publicKey := range candidates {
+			if signature.verify(publicKey, digest) {
+				return nil
+			}
+		}
+		verifyErr = errors.New("signature does not match the artifact")
+	}
+	return verifyErr
+}
+
+// readOpenPGPKeyring returns the version 4 public keys and subkeys of an armored or binary keyring by key id,
+// the keys of other versions and algorithms are ignored
+func readOpenPGPKeyring(path string) (map[uint64]crypto.PublicKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gpg keyring: %v", err)
+	}
+	data, err := dearmorOpenPGP(content)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := readOpenPGPPackets(data)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[uint64]crypto.PublicKey)
+	for _, packet := range packets {
+		if packet.tag != openpgpTagPublicKey && packet.tag != openpgpTagPublicSubkey {
+			continue
+		}
+		keyID, publicKey, err := parseOpenPGPPublicKey(packet.body)
+		if err != nil {
+			return nil, err
+		}
+		if publicKey != nil {
+			keys[keyID] = publicKey
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%v does not contain a supported OpenPGP public key", path)
+	}
+	return keys, nil
+}
+
+// dearmorOpenPGP returns the binary content of the armored blocks of data, or data itself when it is not armored
+func dearmorOpenPGP(data []byte) ([]byte, error) {
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	if !strings.Contains(text, "-----BEGIN PGP ") {
+		return data, nil
+	}
+	var content []byte
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		if !strings.HasPrefix(strings.TrimSpace(lines[i]), "-----BEGIN PGP ") {
+			continue
+		}
+		var body strings.Builder
+		for i++; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if strings.HasPrefix(line, "-----END PGP ") {
+				break
+			}
+			// armor headers hold a colon and the checksum starts with an equal sign, neither is base64 content
+			if line == "" || strings.Contains(line, ":") || strings.HasPrefix(line, "=") {
+				continue
+			}
+			body.WriteString(line)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(body.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid armored OpenPGP data: %v", err)
+		}
+		content = append(content, decoded...)
+	}
+	return content, nil
+}
+
+// readOpenPGPPackets splits binary OpenPGP data into its packets, partial body lengths are not supported
+func readOpenPGPPackets(data []byte) ([]openpgpPacket, error) {
+	var packets []openpgpPacket
+	for len(data) > 0 {
+		header := data[0]
+		if header&0x80 == 0 {
+			return nil, errors.New("invalid OpenPGP packet header")
+		}
+		var tag byte
+		var length, offset int
+		if header&0x40 != 0 {
+			tag = header & 0x3f
+			if len(data) < 2 {
+				return nil, errOpenPGPTruncated
+			}
+			switch first := int(data[1]); {
+			case first < 192:
+				length, offset = first, 2
+			case first < 224:
+				if len(data) < 3 {
+					return nil, errOpenPGPTruncated
+				}
+				length, offset = (first-192)<<8+int(data[2])+192, 3
+			case first == 255:
+				if len(data) < 6 {
+					return nil, errOpenPGPTruncated
+				}
+				length, offset = int(binary.BigEndian.Uint32(data[2:6])), 6
+			default:
+				return nil, errors.New("partial OpenPGP packet lengths are not supported")
+			}
+		} else {
+			tag = (header >> 2) & 0x0f
+			switch header & 0x03 {
+			case 0:
+				if len(data) < 2 {
+					return nil, errOpenPGPTruncated
+				}
+				length, offset = int(data[1]), 2
+			case 1:
+				if len(data) < 3 {
+					return nil, errOpenPGPTruncated
+				}
+				length, offset = int(binary.BigEndian.Uint16(data[1:3])), 3
+			case 2:
+				if len(data) < 5 {
+					return nil, errOpenPGPTruncated
+				}
+				length, offset = int(binary.BigEndian.Uint32(data[1:5])), 5
+			default:
+				length, offset = len(data)-1, 1
+			}
+		}
+		if length < 0 || len(data)-offset < length {
+			return nil, errOpenPGPTruncated
+		}
+		packets = append(packets, openpgpPacket{tag: tag, body: data[offset : offset+length]})
+		data = data[offset+length:]
+	}
+	return packets, nil
+}
+
+// parseOpenPGPPublicKey returns the key id and the public key of a version 4 public key packet,
+// there is no public key for the other versions and the unsupported algorithms
+func parseOpenPGPPublicKey(body []byte) (uint64, crypto.PublicKey, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return 0, nil, nil
+	}
+	fingerprint := sha1.New()
+	fingerprint.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+	fingerprint.Write(body)
+	keyID := binary.BigEndian.Uint64(fingerprint.Sum(nil)[12:20])
+
+	material := body[6:]
+	switch body[5] {
+	case openpgpAlgorithmRSA, openpgpAlgorithmRSASignOnly:
+		modulus, rest, err := readOpenPGPMPI(material)
+		if err != nil {
+			return 0, nil, err
+		}
+		exponent, _, err := readOpenPGPMPI(rest)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(exponent) == 0 || len(exponent) > 4 {
+			return 0, nil, fmt.Errorf("unsupported RSA exponent of OpenPGP key %016X", keyID)
+		}
+		return keyID, &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: int(new(big.Int).SetBytes(exponent).Int64())}, nil
+	case openpgpAlgorithmECDSA:
+		oid, rest, err := readOpenPGPOID(material)
+		if err != nil {
+			return 0, nil, err
+		}
+		curve, ok := openpgpCurves[string(oid)]
+		if !ok {
+			return keyID, nil, nil
+		}
+		point, _, err := readOpenPGPMPI(rest)
+		if err != nil {
+			return 0, nil, err
+		}
+		x, y := elliptic.Unmarshal(curve, point)
+		if x == nil {
+			return 0, nil, fmt.Errorf("invalid ECDSA point of OpenPGP key %016X", keyID)
+		}
+		return keyID, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case openpgpAlgorithmEdDSA:
+		oid, rest, err := readOpenPGPOID(material)
+		if err != nil {
+			return 0, nil, err
+		}
+		if string(oid) != openpgpOidEd25519 {
+			return keyID, nil, nil
+		}
+		point, _, err := readOpenPGPMPI(rest)
+		if err != nil {
+			return 0, nil, err
+		}
+		// the point is prefixed with 0x40 for its native encoding
+		if len(point) != ed25519.PublicKeySize+1 || point[0] != 0x40 {
+			return 0, nil, fmt.Errorf("invalid Ed25519 point of OpenPGP key %016X", keyID)
+		}
+		return keyID, ed25519.PublicKey(point[1:]), nil
+	}
+	return keyID, nil, nil
+}
+
+// parseOpenPGPSignature parses a version 4 signature packet
+func parseOpenPGPSignature(body []byte) (*openpgpSignature, error) {
+	if len(body) < 6 || body[0] != 4 {
+		return nil, errors.New("only version 4 OpenPGP signatures are supported")
+	}
+	hash, ok := openpgpHashes[body[3]]
+	if !ok {
+		return nil, fmt.Errorf("unsupported OpenPGP hash algorithm %v", body[3])
+	}
+	hashedLength := int(binary.BigEndian.Uint16(body[4:6]))
+	if len(body) < 6+hashedLength+2 {
+		return nil, errOpenPGPTruncated
+	}
+	rest := body[6+hashedLength:]
+	unhashedLength := int(binary.BigEndian.Uint16(rest))
+	if len(rest) < 2+unhashedLength+2 {
+		return nil, errOpenPGPTruncated
+	}
+	signature := &openpgpSignature{
+		signatureType: body[1],
+		hash:          hash,
+		hashedPart:    body[:6+hashedLength],
+		hashPrefix:    rest[2+unhashedLength : 4+unhashedLength],
+	}
+
+	// the issuer of the hashed subpackets is preferred to the one of the unhashed subpackets
+	for _, subpackets := range [][]byte{body[6 : 6+hashedLength], rest[2 : 2+unhashedLength]} {
+		issuer, err := openpgpIssuer(subpackets)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != 0 {
+			signature.issuer = issuer
+			break
+		}
+	}
+
+	values := rest[4+unhashedLength:]
+	for len(values) > 0 {
+		value, remaining, err := readOpenPGPMPI(values)
+		if err != nil {
+			return nil, err
+		}
+		signature.values = append(signature.values, value)
+		values = remaining
+	}
+	return signature, nil
+}
+
+// openpgpIssuer returns the key id of the issuer or issuer fingerprint subpacket, 0 when there is none
+func openpgpIssuer(subpackets []byte) (uint64, error) {
+	for len(subpackets) > 0 {
+		var length, offset int
+		switch first := int(subpackets[0]); {
+		case first < 192:
+			length, offset = first, 1
+		case first < 255:
+			if len(subpackets) < 2 {
+				return 0, errOpenPGPTruncated
+			}
+			length, offset = (first-192)<<8+int(subpackets[1])+192, 2
+		default:
+			if len(subpackets) < 5 {
+				return 0, errOpenPGPTruncated
+			}
+			length, offset = int(binary.BigEndian.Uint32(subpackets[1:5])), 5
+		}
+		if length < 1 || len(subpackets)-offset < length {
+			return 0, errOpenPGPTruncated
+		}
+		content := subpackets[offset : offset+length]
+		switch content[0] & 0x7f {
+		case openpgpSubpacketIssuer:
+			if len(content) == 9 {
+				return binary.BigEndian.Uint64(content[1:9]), nil
+			}
+		case openpgpSubpacketIssuerFingerprint:
+			// the key id of a version 4 key is the end of its fingerprint
+			if len(content) == 22 && content[1] == 4 {
+				return binary.BigEndian.Uint64(content[14:22]), nil
+			}
+		}
+		subpackets = subpackets[offset+length:]
+	}
+	return 0, nil
+}
+
+// digest hashes the signed file followed by the hashed part of the signature and its trailer
+func (signature *openpgpSignature) digest(filePath string) ([]byte, error) {
+	digest := signature.hash.New()
+	if err := hashFile(digest, filePath); err != nil {
+		return nil, err
+	}
+	digest.Write(signature.hashedPart)
+	trailer := []byte{4, 0xff, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(trailer[2:], uint32(len(signature.hashedPart)))
+	digest.Write(trailer)
+	return digest.Sum(nil), nil
+}
+
+// verify returns whether the signature of the digest is made by the public key
+func (signature *openpgpSignature) verify(publicKey crypto.PublicKey, digest []byte) bool {
+	if !bytes.Equal(digest[:2], signature.hashPrefix) {
+		return false
+	}
+	values := signature.values
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return len(values) == 1 && rsa.VerifyPKCS1v15(key, signature.hash, digest, leftPad(values[0], key.Size())) == nil
+	case *ecdsa.PublicKey:
+		return len(values) == 2 && ecdsa.Verify(key, digest, new(big.Int).SetBytes(values[0]), new(big.Int).SetBytes(values[1]))
+	case ed25519.PublicKey:
+		if len(values) != 2 || len(values[0]) > 32 || len(values[1]) > 32 {
+			return false
+		}
+		return ed25519.Verify(key, digest, append(leftPad(values[0], 32), leftPad(values[1], 32)...))
+	}
+	return false
+}
+
+// readOpenPGPMPI returns the bytes of a multiprecision integer and the data following it
+func readOpenPGPMPI(data []byte) ([]byte, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, errOpenPGPTruncated
+	}
+	size := (int(binary.BigEndian.Uint16(data)) + 7) / 8
+	if len(data)-2 < size {
+		return nil, nil, errOpenPGPTruncated
+	}
+	return data[2 : 2+size], data[2+size:], nil
+}
+
+// readOpenPGPOID returns the curve OID of an elliptic curve key and the data following it
+func readOpenPGPOID(data []byte) ([]byte, []byte, error) {
+	if len(data) < 1 || data[0] == 0 || data[0] == 0xff || len(data)-1 < int(data[0]) {
+		return nil, nil, errOpenPGPTruncated
+	}
+	size := int(data[0])
+	return data[1 : 1+size], data[1+size:], nil
+}
+
+// leftPad pads a value with leading zeros up to size bytes
+func leftPad(value []byte, size int) []byte {
+	if len(value) >= size {
+		return value
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(value):], value)
+	return padded
+}
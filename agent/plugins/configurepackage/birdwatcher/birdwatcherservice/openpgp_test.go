@@ -0,0 +1,143 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the keyring holds an Ed25519 key and an RSA key, each signing testGpgArtifact
+const testGpgKeyring = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatJWaxYJKwYBBAHaRw8BAQdA2mRSfCzv03IJSZp1knH8lXC/G52oU/kSQUji
+J+EEvNq0JlBhY2thZ2UgU2lnbmluZyA8cGFja2FnZXNAZXhhbXBsZS5jb20+iJAE
+ExYIADgWIQQPOhwnHc6Pnzf7ejZubqCWQx2IcgUCatJWawIbAwULCQgHAgYVCgkI
+CwIEFgIDAQIeAQIXgAAKCRBubqCWQx2IcglTAQCJsh/LegpzmZ8ZRXsapdQbGLeW
+JML3enBVBPi1Y2VHbgD/WfLaqN2AP8RcFq6YOuR3VgJNhlFPQE6AMnYgHVQqzQ+Z
+AQ0EatJWawEIAM8E9rIaIRqTdgMb5NdcZV9R3bsLRwzPqAGdVMOO2ovEbb9glp99
+ZH9LMcGnyOoE6OLZJER3WgahqdRdWIYhkC1TqvhvvnmKyVUzJNF/kNmQWn3z+HWn
+PS2XWisw8CW6frlgDX+9LG4sJSNMUdLIlVmr77wuncgKrMEUGtl7GD+qPndZHnwp
+WtGoCa8gvxwF85rRwam+LFnopJ1/0GtKO5E56DAZBxoQsuL23/iSugaKoALyR1PT
+rGpJ1siCsqA42o1Utmvhc0MQ+Fp3rdtZnHG/xL3PLITHo3HkJ4HpUWR0NS8CyGSM
+uwWttLgFlO80CTZ7+NQiUmqqvYb6AV4cf4kAEQEAAbQqUGFja2FnZSBTaWduaW5n
+IFJTQSA8cGFja2FnZXNAZXhhbXBsZS5jb20+iQFOBBMBCgA4FiEEsBYXjgumAGQS
+Mh0CSIlZ64tCb9sFAmrSVmsCGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AACgkQ
+SIlZ64tCb9tkTgf/RAE2D8FQG6WVkehRB39buwJu1fQYkQ8wXCtLtR5hSVZdltV0
+zx573E10suIKFjJfNhwco49U07RngPbEwhqU7Zfsha20ZIFhMLf2xLdPEMwl9rZH
+KpBd9mICNddQKLnFLinsa7CDHemDhL1pCAK7DRlY+I/aoJxHyDePwZTWabjD19CB
+wawlJEP1TxukJ+NGc+GhfpAZ3tZ8/AwLxvhE3nXH+KnU8eaQ5XqGwuN5SSmy4IBL
+lApaDsKEM7YDnTzxGrYOAJfJFG250xj3BHCIl9sbbIiAMRnTpVKjS1bX3DGUyojs
+Z0lZaPXQSTU1WChZ58H/7Oy6VN6tevkTJBgUaw==
+=UMFr
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+const testGpgEd25519Key = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatJWaxYJKwYBBAHaRw8BAQdA2mRSfCzv03IJSZp1knH8lXC/G52oU/kSQUji
+J+EEvNq0JlBhY2thZ2UgU2lnbmluZyA8cGFja2FnZXNAZXhhbXBsZS5jb20+iJAE
+ExYIADgWIQQPOhwnHc6Pnzf7ejZubqCWQx2IcgUCatJWawIbAwULCQgHAgYVCgkI
+CwIEFgIDAQIeAQIXgAAKCRBubqCWQx2IcglTAQCJsh/LegpzmZ8ZRXsapdQbGLeW
+JML3enBVBPi1Y2VHbgD/WfLaqN2AP8RcFq6YOuR3VgJNhlFPQE6AMnYgHVQqzQ8=
+=SRsB
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+const testGpgEd25519Signature = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQQPOhwnHc6Pnzf7ejZubqCWQx2IcgUCatJWcAAKCRBubqCWQx2I
+cjpTAP4lS7fBEX3nxr974rJhCEjseYOB+DauzUOfx458ZrPuZwD7Bnt+eU4J+999
+JRxPnZwSg4Vkh83xga0Tuq74I7en+gM=
+=Uy60
+-----END PGP SIGNATURE-----
+`
+
+const testGpgRSASignature = `-----BEGIN PGP SIGNATURE-----
+
+iQEzBAABCgAdFiEEsBYXjgumAGQSMh0CSIlZ64tCb9sFAmrSVnAACgkQSIlZ64tC
+b9tVywf+Lk4M++4jerEFltddX1Taiz5X+8gpyYKA+4dZcvALF4/QJEApUVi9E0go
+01+fhEoBPhHOS9YTYbIYF6Jam36m50/eygUB8Q542UusWIIq/7/ORlGU+1xpWbxF
+/mcG1amm5sLkk7avBTJ/A+bZomXrpWwy5pbLiTYywTcmWyOLWE9Ry89IJZ/EDiy+
+j/EDNo2keq6vPFIHo6PDdt0Gb1E+Mq3lPN+7eXqclezuPdSr5NSedF6rHKmpwwhG
+3/cJPqQUqFDqDORwH/13VrbqVkGF7m+8GAPScmHyNiDcNr8tr7tT+YC1MjpJ6xct
+rkZaMZMFpqqvWx6DmBXmhC++HLrXsA==
+=WkcR
+-----END PGP SIGNATURE-----
+`
+
+const testGpgArtifact = "package artifact content\n"
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestVerifyGpgSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpgsignature")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyring := writeTestFile(t, dir, "keyring.asc", testGpgKeyring)
+	ed25519Keyring := writeTestFile(t, dir, "ed25519.asc", testGpgEd25519Key)
+	artifact := writeTestFile(t, dir, "artifact.zip", testGpgArtifact)
+	tampered := writeTestFile(t, dir, "tampered.zip", "package artifact content?\n")
+	binarySignature, err := dearmorOpenPGP([]byte(testGpgRSASignature))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		keyring     string
+		artifact    string
+		signature   []byte
+		expectedErr bool
+	}{
+		{"ed25519", keyring, artifact, []byte(testGpgEd25519Signature), false},
+		{"rsa sha512", keyring, artifact, []byte(testGpgRSASignature), false},
+		{"binary signature", keyring, artifact, binarySignature, false},
+		{"tampered ed25519", keyring, tampered, []byte(testGpgEd25519Signature), true},
+		{"tampered rsa", keyring, tampered, []byte(testGpgRSASignature), true},
+		{"key not in keyring", ed25519Keyring, artifact, []byte(testGpgRSASignature), true},
+		{"not a signature", keyring, artifact, []byte("not a signature"), true},
+		{"no keyring", filepath.Join(dir, "missing.asc"), artifact, []byte(testGpgEd25519Signature), true},
+	}
+	for _, testCase := range testCases {
+		err := verifyGpgSignature(testCase.keyring, testCase.artifact, testCase.signature)
+		if testCase.expectedErr {
+			assert.Error(t, err, testCase.name)
+		} else {
+			assert.NoError(t, err, testCase.name)
+		}
+	}
+}
+
+func TestReadOpenPGPKeyring(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gpgkeyring")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keys, err := readOpenPGPKeyring(writeTestFile(t, dir, "keyring.asc", testGpgKeyring))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, uint64(0x6E6EA096431D8872))
+	assert.Contains(t, keys, uint64(0x488959EB8B426FDB))
+
+	_, err = readOpenPGPKeyring(writeTestFile(t, dir, "empty.asc", ""))
+	assert.Error(t, err)
+}
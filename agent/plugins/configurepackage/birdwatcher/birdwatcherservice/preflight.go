@@ -0,0 +1,129 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
+)
+
+var getDiskSpaceInfo = fileutil.GetDiskSpaceInfoOfPath
+
+var getSystemResources = systemResources
+
+// systemResourcesInfo is the memory and kernel of the instance checked against the package prerequisites
+type systemResourcesInfo struct {
+	memoryMB      int64
+	kernelVersion string
+}
+
+// preflight fails before the download when the package does not fit in the download and install
+// directories or when the instance does not meet the prerequisites declared by the manifest
+func preflight(tracer trace.Tracer, manifest *birdwatcher.Manifest, file *birdwatcher.FileInfo, pkginfo *birdwatcher.PackageInfo) error {
+	size := int64(file.Size)
+	for _, name := range pkginfo.Attachments {
+		if fileInfo, ok := manifest.Files[name]; ok && fileInfo != nil {
+			size += int64(fileInfo.Size)
+		}
+	}
+	for _, directory := range []string{appconfig.DownloadRoot, appconfig.PackageRoot} {
+		if err := checkDiskSpace(tracer, directory, size); err != nil {
+			return err
+		}
+	}
+	return checkPrerequisites(tracer, pkginfo.Prerequisites)
+}
+
+// checkDiskSpace fails when the disk holding the directory has less than size bytes available,
+// the directory is created later so the disk of its closest existing parent is checked
+func checkDiskSpace(tracer trace.Tracer, directory string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	path := existingParent(directory)
+	diskSpace, err := getDiskSpaceInfo(path)
+	if err != nil {
+		// the download fails on its own when the disk is full
+		tracer.CurrentTrace().AppendInfof("skipping disk space check of %v: %v", path, err)
+		return nil
+	}
+	if diskSpace.AvailBytes < size {
+		return contracts.NewCodedError(contracts.ErrorCodeDiskFull,
+			fmt.Errorf("the package needs %v bytes in %v, only %v bytes are available", size, directory, diskSpace.AvailBytes))
+	}
+	return nil
+}
+
+// existingParent returns the directory or its closest parent that exists
+func existingParent(directory string) string {
+	path := filepath.Clean(directory)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// checkPrerequisites fails when the instance does not meet the prerequisites, a prerequisite
+// that cannot be read on the instance is skipped
+func checkPrerequisites(tracer trace.Tracer, prerequisites *birdwatcher.Prerequisites) error {
+	if prerequisites == nil || (prerequisites.MinMemoryMB <= 0 && prerequisites.MinKernelVersion == "") {
+		return nil
+	}
+	resources, err := getSystemResources()
+	if err != nil {
+		tracer.CurrentTrace().AppendInfof("skipping prerequisite checks: %v", err)
+		return nil
+	}
+
+	if prerequisites.MinMemoryMB > 0 && resources.memoryMB < prerequisites.MinMemoryMB {
+		return contracts.NewCodedError(contracts.ErrorCodePlatformUnsupported,
+			fmt.Errorf("the package requires %v MB of memory, the instance has %v MB", prerequisites.MinMemoryMB, resources.memoryMB))
+	}
+	if prerequisites.MinKernelVersion != "" {
+		kernelVersion := numericVersion(resources.kernelVersion)
+		if kernelVersion == "" {
+			tracer.CurrentTrace().AppendInfof("skipping kernel version check, unknown kernel version %q", resources.kernelVersion)
+		} else if versionutil.Compare(kernelVersion, prerequisites.MinKernelVersion, false) < 0 {
+			return contracts.NewCodedError(contracts.ErrorCodePlatformUnsupported,
+				fmt.Errorf("the package requires kernel %v or later, the instance runs kernel %v", prerequisites.MinKernelVersion, resources.kernelVersion))
+		}
+	}
+	return nil
+}
+
+// numericVersion returns the leading dotted numbers of a kernel release such as 4.14.186-146.268.amzn2.x86_64
+func numericVersion(release string) string {
+	end := strings.IndexFunc(release, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	})
+	if end >= 0 {
+		release = release[:end]
+	}
+	return strings.Trim(release, ".")
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build linux
+
+package birdwatcherservice
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// systemResources reads the total memory from /proc/meminfo and the kernel release from /proc/sys/kernel/osrelease
+func systemResources() (info systemResourcesInfo, err error) {
+	meminfo, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return info, err
+	}
+	defer meminfo.Close()
+
+	scanner := bufio.NewScanner(meminfo)
+	for scanner.Scan() {
+		// MemTotal:        8047840 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return info, fmt.Errorf("invalid total memory %q: %v", fields[1], err)
+			}
+			info.memoryMB = kb / 1024
+			break
+		}
+	}
+	if info.memoryMB == 0 {
+		return info, fmt.Errorf("total memory not found in /proc/meminfo")
+	}
+
+	release, err := ioutil.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return info, err
+	}
+	info.kernelVersion = strings.TrimSpace(string(release))
+	return info, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+const prerequisitesManifest = `
+{
+	"packages": {
+		"abc": {
+			"567": {
+				"xyz": {
+					"file": "test.zip",
+					"prerequisites": {"minMemoryMB": 2048, "minKernelVersion": "4.14"}
+				}
+			}
+		}
+	},
+	"files": {
+		"test.zip": {
+			"downloadLocation": "https://example.com/test.zip",
+			"checksums": {"sha256": "aaa"},
+			"size": 1000
+		}
+	}
+}
+`
+
+func mockDiskSpace(availBytes int64, paths *[]string) func() {
+	getDiskSpaceInfo = func(path string) (fileutil.DiskSpaceInfo, error) {
+		if paths != nil {
+			*paths = append(*paths, path)
+		}
+		return fileutil.DiskSpaceInfo{AvailBytes: availBytes}, nil
+	}
+	return func() { getDiskSpaceInfo = fileutil.GetDiskSpaceInfoOfPath }
+}
+
+func mockSystemResources(memoryMB int64, kernelVersion string, err error) func() {
+	getSystemResources = func() (systemResourcesInfo, error) {
+		return systemResourcesInfo{memoryMB: memoryMB, kernelVersion: kernelVersion}, err
+	}
+	return func() { getSystemResources = systemResources }
+}
+
+func TestDownloadArtifactPreflight(t *testing.T) {
+	data := []struct {
+		name          string
+		availBytes    int64
+		memoryMB      int64
+		kernelVersion string
+		expectedCode  contracts.ErrorCode
+	}{
+		{"prerequisites met", 5000, 4096, "4.14.186-146.268.amzn2.x86_64", ""},
+		{"disk full", 500, 4096, "4.14.186-146.268.amzn2.x86_64", contracts.ErrorCodeDiskFull},
+		{"not enough memory", 5000, 1024, "4.14.186-146.268.amzn2.x86_64", contracts.ErrorCodePlatformUnsupported},
+		{"kernel too old", 5000, 4096, "3.10.0-1160.el7.x86_64", contracts.ErrorCodePlatformUnsupported},
+	}
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			defer mockDiskSpace(testdata.availBytes, nil)()
+			defer mockSystemResources(testdata.memoryMB, testdata.kernelVersion, nil)()
+			network := &attachmentNetworkMock{directory: os.TempDir()}
+			birdwatcher.Networkdep = network
+			tracer := trace.NewTracer(log.NewMockLog())
+			tracer.BeginSection("test segment root")
+
+			ds := newAttachmentsTestService(prerequisitesManifest)
+			result, err := ds.DownloadArtifact(context.Background(), tracer, "packageName", "1.0.0")
+
+			if testdata.expectedCode == "" {
+				assert.NoError(t, err)
+				assert.NotEmpty(t, result)
+				assert.Len(t, network.inputs, 1)
+			} else {
+				assert.Error(t, err)
+				assert.Equal(t, testdata.expectedCode, contracts.ErrorCodeOf(err))
+				assert.Empty(t, network.inputs)
+			}
+		})
+	}
+}
+
+func TestPreflightChecksDownloadAndPackageRoot(t *testing.T) {
+	var paths []string
+	defer mockDiskSpace(5000, &paths)()
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	manifest := &birdwatcher.Manifest{
+		Files: map[string]*birdwatcher.FileInfo{
+			"test.zip":  {Size: 1000},
+			"data1.bin": {Size: 4000},
+		},
+	}
+	pkginfo := &birdwatcher.PackageInfo{FileName: "test.zip", Attachments: []string{"data1.bin"}}
+
+	err := preflight(tracer, manifest, manifest.Files["test.zip"], pkginfo)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{existingParent(appconfig.DownloadRoot), existingParent(appconfig.PackageRoot)}, paths)
+
+	manifest.Files["data1.bin"].Size = 4001
+	err = preflight(tracer, manifest, manifest.Files["test.zip"], pkginfo)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "5001 bytes")
+}
+
+func TestCheckDiskSpaceSkippedOnError(t *testing.T) {
+	getDiskSpaceInfo = func(path string) (fileutil.DiskSpaceInfo, error) {
+		return fileutil.DiskSpaceInfo{}, errors.New("not supported")
+	}
+	defer func() { getDiskSpaceInfo = fileutil.GetDiskSpaceInfoOfPath }()
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	assert.NoError(t, checkDiskSpace(tracer, "download", 1000))
+}
+
+func TestExistingParent(t *testing.T) {
+	directory, _ := ioutil.TempDir("", "preflight")
+	defer os.RemoveAll(directory)
+
+	assert.Equal(t, directory, existingParent(directory))
+	assert.Equal(t, directory, existingParent(filepath.Join(directory, "packages", "name")))
+}
+
+func TestCheckPrerequisites(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	data := []struct {
+		name          string
+		prerequisites *birdwatcher.Prerequisites
+		memoryMB      int64
+		kernelVersion string
+		resourcesErr  error
+		expectedErr   bool
+	}{
+		{"no prerequisites", nil, 0, "", errors.New("unexpected"), false},
+		{"memory met", &birdwatcher.Prerequisites{MinMemoryMB: 1024}, 1024, "", nil, false},
+		{"memory not met", &birdwatcher.Prerequisites{MinMemoryMB: 1024}, 1023, "", nil, true},
+		{"kernel met", &birdwatcher.Prerequisites{MinKernelVersion: "4.14"}, 1024, "4.14.0-amzn", nil, false},
+		{"kernel newer major", &birdwatcher.Prerequisites{MinKernelVersion: "4.14"}, 1024, "5.4.0", nil, false},
+		{"kernel not met", &birdwatcher.Prerequisites{MinKernelVersion: "4.14"}, 1024, "4.9.0", nil, true},
+		{"unknown kernel", &birdwatcher.Prerequisites{MinKernelVersion: "4.14"}, 1024, "unknown", nil, false},
+		{"resources not available", &birdwatcher.Prerequisites{MinMemoryMB: 1024}, 0, "", errors.New("not supported"), false},
+	}
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			defer mockSystemResources(testdata.memoryMB, testdata.kernelVersion, testdata.resourcesErr)()
+
+			err := checkPrerequisites(tracer, testdata.prerequisites)
+
+			if testdata.expectedErr {
+				assert.Error(t, err)
+				assert.Equal(t, contracts.ErrorCodePlatformUnsupported, contracts.ErrorCodeOf(err))
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNumericVersion(t *testing.T) {
+	assert.Equal(t, "4.14.186", numericVersion("4.14.186-146.268.amzn2.x86_64"))
+	assert.Equal(t, "5.4.0", numericVersion("5.4.0"))
+	assert.Equal(t, "3.10.0", numericVersion("3.10.0.el7"))
+	assert.Equal(t, "", numericVersion("unknown"))
+}
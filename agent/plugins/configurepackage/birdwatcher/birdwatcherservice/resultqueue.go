@@ -0,0 +1,191 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/twinj/uuid"
+)
+
+const (
+	// resultBatchSize is the number of queued results submitted after a result is reported
+	resultBatchSize = 10
+	// maxQueuedResults bounds the queue, the oldest results are dropped above it
+	maxQueuedResults = 100
+	// maxQueuedResultAge drops results the service would no longer relate to their execution
+	maxQueuedResultAge = 7 * 24 * time.Hour
+	// maxResultRetryDelay bounds the delay between attempts to submit a queued result
+	maxResultRetryDelay = time.Hour
+
+	queuedResultSuffix = ".json"
+)
+
+// flushLock prevents concurrent executions of the plugin from submitting the same queued result
+var flushLock sync.Mutex
+
+// queuedResult is a result that could not be reported and the next time it is submitted
+type queuedResult struct {
+	QueuedAt    time.Time                           `json:"queuedAt"`
+	Attempts    int                                 `json:"attempts"`
+	NextAttempt time.Time                           `json:"nextAttempt"`
+	Input       *ssm.PutConfigurePackageResultInput `json:"input"`
+}
+
+// resultQueue keeps the results that could not be reported in a directory, one file per result named
+// after the time it was queued, until they are submitted after a result is reported successfully or
+// by the result sender core module
+type resultQueue struct {
+	directory string
+	now       func() time.Time
+}
+
+func newResultQueue(directory string) *resultQueue {
+	return &resultQueue{directory: directory, now: time.Now}
+}
+
+// enqueue writes the result to the queue, dropping the oldest results above the queue size
+func (queue *resultQueue) enqueue(log log.T, input *ssm.PutConfigurePackageResultInput) error {
+	if err := fileutil.MakeDirs(queue.directory); err != nil {
+		return err
+	}
+	now := queue.now()
+	entry := queuedResult{QueuedAt: now, Attempts: 1, NextAttempt: now.Add(retryDelay(1)), Input: input}
+	name := fmt.Sprintf("%020d-%v%v", now.UnixNano(), uuid.NewV4().String(), queuedResultSuffix)
+	if err := queue.write(name, entry); err != nil {
+		return err
+	}
+
+	names := queue.names(log)
+	for len(names) > maxQueuedResults {
+		log.Warnf("Dropping package result %v, more than %v results are queued", names[0], maxQueuedResults)
+		fileutil.DeleteFile(filepath.Join(queue.directory, names[0]))
+		names = names[1:]
+	}
+	return nil
+}
+
+// flush submits a batch of queued results, oldest first. It stops at the first failure, which is retried
+// with an exponential backoff, so that an unreachable service is not called for every queued result.
+func (queue *resultQueue) flush(ctx context.Context, log log.T, facadeClient facade.BirdwatcherFacade) {
+	flushLock.Lock()
+	defer flushLock.Unlock()
+
+	submitted := 0
+	for _, name := range queue.names(log) {
+		if submitted == resultBatchSize || ctx.Err() != nil {
+			return
+		}
+		path := filepath.Join(queue.directory, name)
+		var entry queuedResult
+		content, err := fileutil.ReadAllText(path)
+		if err == nil {
+			err = json.Unmarshal([]byte(content), &entry)
+		}
+		now := queue.now()
+		if err != nil || entry.Input == nil || now.Sub(entry.QueuedAt) > maxQueuedResultAge {
+			log.Warnf("Dropping invalid or expired package result %v", name)
+			fileutil.DeleteFile(path)
+			continue
+		}
+		if now.Before(entry.NextAttempt) {
+			return
+		}
+
+		if _, err = facadeClient.PutConfigurePackageResultWithContext(ctx, entry.Input); err != nil {
+			entry.Attempts++
+			if isRejected(err) {
+				log.Warnf("Dropping package result %v rejected by the service after %v attempts: %v", name, entry.Attempts, err)
+				fileutil.DeleteFile(path)
+				continue
+			}
+			entry.NextAttempt = now.Add(retryDelay(entry.Attempts))
+			if writeErr := queue.write(name, entry); writeErr != nil {
+				log.Warnf("Failed to update queued package result %v: %v", name, writeErr)
+			}
+			log.Debugf("Failed to submit queued package result %v, retrying after %v: %v", name, entry.NextAttempt, err)
+			return
+		}
+		fileutil.DeleteFile(path)
+		submitted++
+	}
+	if submitted > 0 {
+		log.Infof("Submitted %v queued package results", submitted)
+	}
+}
+
+// names returns the queued results, oldest first
+func (queue *resultQueue) names(log log.T) []string {
+	if !fileutil.Exists(queue.directory) {
+		return nil
+	}
+	files, err := fileutil.GetFileNames(queue.directory)
+	if err != nil {
+		log.Warnf("Failed to list queued package results: %v", err)
+		return nil
+	}
+	var names []string
+	for _, name := range files {
+		if strings.HasSuffix(name, queuedResultSuffix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (queue *resultQueue) write(name string, entry queuedResult) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAllText(filepath.Join(queue.directory, name), string(content))
+}
+
+// isRejected returns true if the service refused the result itself, submitting it again would fail the same way.
+// Network, throttling, credential and server errors are transient, the result is kept until it expires.
+func isRejected(err error) bool {
+	requestErr, ok := err.(awserr.RequestFailure)
+	if !ok {
+		return false
+	}
+	switch contracts.ErrorCodeOf(err) {
+	case contracts.ErrorCodeThrottling, contracts.ErrorCodeAuth, contracts.ErrorCodeNetwork:
+		return false
+	}
+	return requestErr.StatusCode() >= 400 && requestErr.StatusCode() < 500
+}
+
+// retryDelay doubles from a minute for each attempt, up to an hour
+func retryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < maxResultRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxResultRetryDelay {
+		delay = maxResultRetryDelay
+	}
+	return delay
+}
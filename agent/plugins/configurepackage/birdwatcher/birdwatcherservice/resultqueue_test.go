@@ -0,0 +1,211 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testCollector() envdetect.Collector {
+	collector := &envdetect.CollectorMock{}
+	collector.On("CollectData", mock.Anything).Return(&envdetect.Environment{
+		&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
+		&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+		nil,
+		nil,
+	}, nil)
+	return collector
+}
+
+// newTestResultQueue returns a queue in a temporary directory whose clock is set by the test
+func newTestResultQueue(t *testing.T) (*resultQueue, *time.Time, func()) {
+	directory, err := ioutil.TempDir("", "resultqueue")
+	assert.NoError(t, err)
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	queue := newResultQueue(directory)
+	queue.now = func() time.Time { return now }
+	return queue, &now, func() { os.RemoveAll(directory) }
+}
+
+func queueResults(t *testing.T, queue *resultQueue, now *time.Time, count int) {
+	for i := 0; i < count; i++ {
+		*now = now.Add(time.Millisecond)
+		assert.NoError(t, queue.enqueue(log.NewMockLog(), &ssm.PutConfigurePackageResultInput{PackageName: aws.String("name"), PackageVersion: aws.String(string('a' + rune(i)))}))
+	}
+}
+
+func TestReportResultQueuesOnFailure(t *testing.T) {
+	queue, _, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultError: errors.New("unreachable")}
+	ds := &PackageService{facadeClient: &facadeClient, collector: testCollector(), timeProvider: &TimeImpl{}, resultQueue: queue}
+
+	err := ds.ReportResult(context.Background(), tracer, packageservice.PackageResult{PackageName: "name", Version: "1234"})
+
+	assert.NoError(t, err)
+	assert.Len(t, queue.names(log.NewMockLog()), 1)
+}
+
+func TestReportResultSubmitsQueue(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 2)
+	*now = now.Add(time.Hour)
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+	ds := &PackageService{facadeClient: &facadeClient, collector: testCollector(), timeProvider: &TimeImpl{}, resultQueue: queue}
+
+	err := ds.ReportResult(context.Background(), tracer, packageservice.PackageResult{PackageName: "name", Version: "1234"})
+
+	assert.NoError(t, err)
+	assert.Empty(t, queue.names(log.NewMockLog()))
+	// queued results are submitted oldest first, after the reported result
+	assert.Equal(t, "b", *facadeClient.PutConfigurePackageResultInput.PackageVersion)
+}
+
+func TestFlushWaitsForBackoff(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 1)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+
+	queue.flush(context.Background(), log.NewMockLog(), &facadeClient)
+
+	assert.Nil(t, facadeClient.PutConfigurePackageResultInput)
+	assert.Len(t, queue.names(log.NewMockLog()), 1)
+}
+
+func TestFlushBatch(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, resultBatchSize+2)
+	*now = now.Add(time.Hour)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+
+	queue.flush(context.Background(), log.NewMockLog(), &facadeClient)
+
+	assert.Len(t, queue.names(log.NewMockLog()), 2)
+}
+
+func TestFlushStopsAtFailure(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 2)
+	*now = now.Add(time.Hour)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultError: errors.New("unreachable")}
+
+	queue.flush(context.Background(), log.NewMockLog(), &facadeClient)
+
+	names := queue.names(log.NewMockLog())
+	assert.Len(t, names, 2)
+	assert.Equal(t, "a", *facadeClient.PutConfigurePackageResultInput.PackageVersion)
+	var entry queuedResult
+	content, err := fileutil.ReadAllText(filepath.Join(queue.directory, names[0]))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(content), &entry))
+	assert.Equal(t, 2, entry.Attempts)
+	assert.Equal(t, now.Add(2*time.Minute), entry.NextAttempt)
+}
+
+func TestFlushDropsExpiredResults(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 1)
+	*now = now.Add(maxQueuedResultAge + time.Hour)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+
+	queue.flush(context.Background(), log.NewMockLog(), &facadeClient)
+
+	assert.Nil(t, facadeClient.PutConfigurePackageResultInput)
+	assert.Empty(t, queue.names(log.NewMockLog()))
+}
+
+func TestEnqueueDropsOldestResults(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+
+	queueResults(t, queue, now, maxQueuedResults+1)
+
+	names := queue.names(log.NewMockLog())
+	assert.Len(t, names, maxQueuedResults)
+	var entry queuedResult
+	content, err := fileutil.ReadAllText(filepath.Join(queue.directory, names[0]))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(content), &entry))
+	assert.Equal(t, "b", *entry.Input.PackageVersion)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, retryDelay(1))
+	assert.Equal(t, 4*time.Minute, retryDelay(3))
+	assert.Equal(t, time.Hour, retryDelay(10))
+}
+
+func TestFlushKeepsResultsUntilExpired(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 1)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultError: awserr.NewRequestFailure(awserr.New("InternalServerError", "unavailable", nil), 500, "id")}
+
+	for i := 0; i < 20; i++ {
+		*now = now.Add(maxResultRetryDelay)
+		queue.flush(context.Background(), log.NewMockLog(), &facadeClient)
+	}
+
+	assert.Len(t, queue.names(log.NewMockLog()), 1)
+}
+
+func TestFlushDropsRejectedResults(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 2)
+	*now = now.Add(time.Hour)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultError: awserr.NewRequestFailure(awserr.New("ValidationException", "invalid", nil), 400, "id")}
+
+	queue.flush(context.Background(), log.NewMockLog(), &facadeClient)
+
+	assert.Empty(t, queue.names(log.NewMockLog()))
+}
+
+func TestIsRejected(t *testing.T) {
+	assert.True(t, isRejected(awserr.NewRequestFailure(awserr.New("ValidationException", "invalid", nil), 400, "id")))
+	assert.False(t, isRejected(awserr.NewRequestFailure(awserr.New("ThrottlingException", "slow down", nil), 400, "id")))
+	assert.False(t, isRejected(awserr.NewRequestFailure(awserr.New("AccessDeniedException", "denied", nil), 403, "id")))
+	assert.False(t, isRejected(awserr.NewRequestFailure(awserr.New("InternalServerError", "unavailable", nil), 503, "id")))
+	assert.False(t, isRejected(errors.New("unreachable")))
+}
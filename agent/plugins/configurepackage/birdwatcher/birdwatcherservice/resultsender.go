@@ -0,0 +1,87 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	gocontext "context"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/carlescere/scheduler"
+)
+
+const resultSenderName = "PackageResultSender"
+
+// ResultSender is a core module that periodically submits the package results that could not be reported,
+// so that they are delivered even when no package is installed after the service is reachable again.
+type ResultSender struct {
+	context         context.T
+	queue           *resultQueue
+	frequency       int
+	newFacadeClient func() facade.BirdwatcherFacade
+	facadeClient    facade.BirdwatcherFacade
+	cancel          gocontext.CancelFunc
+	job             *scheduler.Job
+}
+
+// NewResultSender creates a new package result sender core module.
+func NewResultSender(context context.T) *ResultSender {
+	return &ResultSender{
+		context:         context.With("[" + resultSenderName + "]"),
+		queue:           newResultQueue(appconfig.PackageResultQueueDirectory),
+		frequency:       context.AppConfig().Birdwatcher.ResultRetryMinutes,
+		newFacadeClient: facade.NewBirdwatcherFacade,
+	}
+}
+
+// ModuleName returns the module name
+func (s *ResultSender) ModuleName() string {
+	return resultSenderName
+}
+
+// ModuleExecute starts submitting the queued package results, right away and then every ResultRetryMinutes
+func (s *ResultSender) ModuleExecute(context context.T) (err error) {
+	var ctx gocontext.Context
+	ctx, s.cancel = gocontext.WithCancel(gocontext.Background())
+	if s.job, err = scheduler.Every(s.frequency).Minutes().Run(func() { s.send(ctx) }); err != nil {
+		s.context.Log().Errorf("Unable to schedule package result sender. %v", err)
+	}
+	return
+}
+
+// ModuleRequestStop stops submitting the queued package results
+func (s *ResultSender) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if s.job != nil {
+		s.context.Log().Info("Stopping package result sender job.")
+		s.job.Quit <- true
+	}
+	return nil
+}
+
+// send submits the queued results whose backoff expired, the service client is only created once there is a result
+func (s *ResultSender) send(ctx gocontext.Context) {
+	log := s.context.Log()
+	if len(s.queue.names(log)) == 0 {
+		return
+	}
+	if s.facadeClient == nil {
+		s.facadeClient = s.newFacadeClient()
+	}
+	s.queue.flush(ctx, log, s.facadeClient)
+}
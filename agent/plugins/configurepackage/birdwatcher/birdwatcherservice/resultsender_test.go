@@ -0,0 +1,64 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	agentcontext "github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResultSender(queue *resultQueue, facadeClient facade.BirdwatcherFacade) (*ResultSender, *int) {
+	created := 0
+	return &ResultSender{
+		context: agentcontext.NewMockDefault(),
+		queue:   queue,
+		newFacadeClient: func() facade.BirdwatcherFacade {
+			created++
+			return facadeClient
+		},
+	}, &created
+}
+
+func TestResultSenderWithoutQueuedResults(t *testing.T) {
+	queue, _, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	sender, created := newTestResultSender(queue, &facade.FacadeStub{})
+
+	sender.send(context.Background())
+
+	assert.Equal(t, 0, *created)
+}
+
+func TestResultSenderSubmitsQueuedResults(t *testing.T) {
+	queue, now, cleanup := newTestResultQueue(t)
+	defer cleanup()
+	queueResults(t, queue, now, 2)
+	*now = now.Add(time.Hour)
+	facadeClient := facade.FacadeStub{PutConfigurePackageResultOutput: &ssm.PutConfigurePackageResultOutput{}}
+	sender, created := newTestResultSender(queue, &facadeClient)
+
+	sender.send(context.Background())
+	sender.send(context.Background())
+
+	assert.Equal(t, 1, *created)
+	assert.Empty(t, queue.names(log.NewMockLog()))
+	assert.Equal(t, "b", *facadeClient.PutConfigurePackageResultInput.PackageVersion)
+}
@@ -0,0 +1,234 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package birdwatcherservice
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionPrefixSuffix ends a prefix selector, "16.*" matches 16, 16.04 and 16.10 but not 160.1
+const versionPrefixSuffix = ".*"
+
+// versionOperators are the comparison operators of range selectors, longest first so ">=" is not read as ">"
+var versionOperators = []string{">=", "<=", ">", "<", "="}
+
+// versionConstraint is a single comparison of a range selector
+type versionConstraint struct {
+	operator string
+	version  []uint64
+}
+
+// versionSelector is a prefix or range selector of the package manifest, a version matches when it
+// satisfies all constraints, lower and upper are the tightest bounds used to rank overlapping selectors
+type versionSelector struct {
+	key         string
+	constraints []versionConstraint
+	lower       []uint64
+	upper       []uint64
+}
+
+// matchVersionSelector returns the prefix or range selector matching the version, when several match
+// the most specific wins: the highest lower bound, then the lowest upper bound, then the smallest key
+func matchVersionSelector(version string, keys []string) (string, bool) {
+	parsedVersion, err := parseVersionNumber(version)
+	if err != nil {
+		return "", false
+	}
+
+	var matches []versionSelector
+	for _, key := range keys {
+		selector, err := parseVersionSelector(key)
+		if err != nil {
+			continue
+		}
+		if selector.matches(parsedVersion) {
+			matches = append(matches, selector)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if c := compareBound(matches[i].lower, matches[j].lower, false); c != 0 {
+			return c > 0
+		}
+		if c := compareBound(matches[i].upper, matches[j].upper, true); c != 0 {
+			return c < 0
+		}
+		return matches[i].key < matches[j].key
+	})
+	return matches[0].key, true
+}
+
+// isVersionSelector returns true if the manifest key is a prefix or range selector rather than a version
+func isVersionSelector(key string) bool {
+	if strings.HasSuffix(key, versionPrefixSuffix) {
+		return true
+	}
+	for _, operator := range versionOperators {
+		if strings.HasPrefix(key, operator) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVersionSelector parses a prefix selector such as "16.*" or a range selector such as ">=20.04 <24.04"
+func parseVersionSelector(key string) (selector versionSelector, err error) {
+	selector.key = key
+	if strings.HasSuffix(key, versionPrefixSuffix) {
+		prefix, err := parseVersionNumber(strings.TrimSuffix(key, versionPrefixSuffix))
+		if err != nil {
+			return selector, err
+		}
+		// a prefix selector is the range from the prefix to the next value of its last component
+		next := append([]uint64{}, prefix...)
+		next[len(next)-1]++
+		selector.constraints = []versionConstraint{{">=", prefix}, {"<", next}}
+	} else {
+		fields := strings.Fields(key)
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			// allow a space between the operator and the version
+			if isOperator(field) && i+1 < len(fields) {
+				i++
+				field += fields[i]
+			}
+			constraint, err := parseVersionConstraint(field)
+			if err != nil {
+				return selector, err
+			}
+			selector.constraints = append(selector.constraints, constraint)
+		}
+		if len(selector.constraints) == 0 {
+			return selector, fmt.Errorf("empty version selector")
+		}
+	}
+
+	for _, constraint := range selector.constraints {
+		if constraint.operator != "<" && constraint.operator != "<=" &&
+			compareBound(constraint.version, selector.lower, false) > 0 {
+			selector.lower = constraint.version
+		}
+		if constraint.operator != ">" && constraint.operator != ">=" &&
+			compareBound(constraint.version, selector.upper, true) < 0 {
+			selector.upper = constraint.version
+		}
+	}
+	return selector, nil
+}
+
+// parseVersionConstraint parses a single comparison such as ">=20.04"
+func parseVersionConstraint(field string) (constraint versionConstraint, err error) {
+	for _, operator := range versionOperators {
+		if strings.HasPrefix(field, operator) {
+			constraint.operator = operator
+			break
+		}
+	}
+	if constraint.operator == "" {
+		return constraint, fmt.Errorf("version constraint %v has no comparison operator", field)
+	}
+	constraint.version, err = parseVersionNumber(strings.TrimPrefix(field, constraint.operator))
+	return constraint, err
+}
+
+// parseVersionNumber parses a dotted numeric version such as "2018.03" or "10.0.14393"
+func parseVersionNumber(version string) ([]uint64, error) {
+	if version == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+	var parsed []uint64
+	for _, part := range strings.Split(version, ".") {
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %v", version)
+		}
+		parsed = append(parsed, number)
+	}
+	return parsed, nil
+}
+
+func isOperator(field string) bool {
+	for _, operator := range versionOperators {
+		if field == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the version satisfies all constraints of the selector
+func (selector versionSelector) matches(version []uint64) bool {
+	for _, constraint := range selector.constraints {
+		c := compareVersionNumbers(version, constraint.version)
+		var ok bool
+		switch constraint.operator {
+		case ">=":
+			ok = c >= 0
+		case "<=":
+			ok = c <= 0
+		case ">":
+			ok = c > 0
+		case "<":
+			ok = c < 0
+		case "=":
+			ok = c == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// compareVersionNumbers compares two versions component by component, missing components count as 0
+func compareVersionNumbers(a, b []uint64) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y uint64
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
+
+// compareBound compares optional bounds, a missing lower bound is the lowest and a missing upper bound the highest
+func compareBound(a, b []uint64, upper bool) int {
+	unbounded := -1
+	if upper {
+		unbounded = 1
+	}
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return unbounded
+	case b == nil:
+		return -unbounded
+	}
+	return compareVersionNumbers(a, b)
+}
@@ -16,34 +16,73 @@
 package documentarchive
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
+const (
+	// VersionSelectionDefault installs the default version of the package document when no version is requested
+	VersionSelectionDefault = "default"
+	// VersionSelectionLatest installs the most recent active version of the package document when no version is requested
+	VersionSelectionLatest = "latest"
+
+	// VersionLabelTagPrefix starts the tags of a package document that label its versions, the tag
+	// VersionLabel:prod with the value 1.2.0 labels the version named 1.2.0 as prod
+	VersionLabelTagPrefix = "VersionLabel:"
+
+	// versionLabelPrefix marks the versions that are labels to resolve when the document is downloaded
+	versionLabelPrefix = "label:"
+)
+
+var versionLabelPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,100}$`)
+
 type PackageArchive struct {
-	facadeClient facade.BirdwatcherFacade
-	attachments  []*ssm.AttachmentContent
-	manifest     string
-	archiveType  string
-	documentArn  string
+	facadeClient     facade.BirdwatcherFacade
+	attachments      []*ssm.AttachmentContent
+	manifest         string
+	archiveType      string
+	documentArn      string
+	versionSelection string
+	versionLabel     string
 }
 
-// New is a constructor for PackageArchive struct
-func New(facadeClientSession facade.BirdwatcherFacade) archive.IPackageArchive {
+// New is a constructor for PackageArchive struct, versionSelection is one of the VersionSelection values
+// and defaults to VersionSelectionDefault when empty. When no version is requested and versionLabel is set,
+// the version labeled with it is installed instead.
+func New(facadeClientSession facade.BirdwatcherFacade, versionSelection string, versionLabel string) archive.IPackageArchive {
 	return &PackageArchive{
-		facadeClient: facadeClientSession,
-		archiveType:  archive.PackageArchiveDocument,
+		facadeClient:     facadeClientSession,
+		archiveType:      archive.PackageArchiveDocument,
+		versionSelection: versionSelection,
+		versionLabel:     versionLabel,
 	}
 }
 
+// IsValidVersionSelection returns true if the version selection is supported, empty is the default selection
+func IsValidVersionSelection(versionSelection string) bool {
+	return versionSelection == "" || versionSelection == VersionSelectionDefault || versionSelection == VersionSelectionLatest
+}
+
+// IsValidVersionLabel returns true if the version label can be part of the tag key of a package document,
+// empty means no label
+func IsValidVersionLabel(versionLabel string) bool {
+	return versionLabel == "" || versionLabelPattern.MatchString(versionLabel)
+}
+
 // Name of archive type
 func (da *PackageArchive) Name() string {
 	return da.archiveType
@@ -57,14 +96,107 @@ func NewWithAttachments(facadeClientSession facade.BirdwatcherFacade, att []*ssm
 	}
 }
 
-// GetResourceVersion makes a call to birdwatcher API to figure the right version of the resource that needs to be installed
+// GetResourceVersion returns the version of the resource that needs to be installed. When no version is requested,
+// the version is the label of the version to install, empty for the default version of the document, or latest
+// for its most recent version. Labels and latest are resolved when the document is downloaded.
 func (da *PackageArchive) GetResourceVersion(packageName string, packageVersion string) (name string, version string) {
-	// Return the packageVersion as "" if empty and return version if specified.
-	return packageName, packageVersion
+	if !packageservice.IsLatest(packageVersion) {
+		return packageName, packageVersion
+	}
+	if da.versionLabel != "" {
+		return packageName, versionLabelPrefix + da.versionLabel
+	}
+	if da.versionSelection == VersionSelectionLatest {
+		return packageName, packageservice.Latest
+	}
+	return packageName, ""
+}
+
+// ListVersions returns the versions of the package document, following the pages of ListDocumentVersions
+func (da *PackageArchive) ListVersions(ctx context.Context, packageName string) ([]*ssm.DocumentVersionInfo, error) {
+	var versions []*ssm.DocumentVersionInfo
+	input := &ssm.ListDocumentVersionsInput{Name: &packageName}
+	for {
+		resp, err := da.facadeClient.ListDocumentVersionsWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the versions of package document: %v", err)
+		}
+		versions = append(versions, resp.DocumentVersions...)
+		if resp.NextToken == nil || *resp.NextToken == "" {
+			return versions, nil
+		}
+		input = &ssm.ListDocumentVersionsInput{Name: &packageName, NextToken: resp.NextToken}
+	}
+}
+
+// latestVersion returns the version name of the most recent active version of the package document,
+// document versions are numbered in the order they are created
+func (da *PackageArchive) latestVersion(ctx context.Context, packageName string) (string, error) {
+	versions, err := da.ListVersions(ctx, packageName)
+	if err != nil {
+		return "", err
+	}
+	latest, latestNumber := "", 0
+	for _, version := range versions {
+		if version.Status != nil && *version.Status != ssm.DocumentStatusActive {
+			continue
+		}
+		if version.DocumentVersion == nil || version.VersionName == nil || *version.VersionName == "" {
+			continue
+		}
+		number, err := strconv.Atoi(*version.DocumentVersion)
+		if err != nil {
+			continue
+		}
+		if number > latestNumber {
+			latest, latestNumber = *version.VersionName, number
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("package document %v has no active version with a version name", packageName)
+	}
+	return latest, nil
+}
+
+// labeledVersion returns the version name the tags of the package document label with the version label
+func (da *PackageArchive) labeledVersion(ctx context.Context, packageName string, versionLabel string) (string, error) {
+	resp, err := da.facadeClient.ListTagsForResourceWithContext(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: aws.String(ssm.ResourceTypeForTaggingDocument),
+		ResourceId:   &packageName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list the version labels of package document: %v", err)
+	}
+	for _, tag := range resp.TagList {
+		if tag.Key == nil || *tag.Key != VersionLabelTagPrefix+versionLabel {
+			continue
+		}
+		if tag.Value == nil || strings.TrimSpace(*tag.Value) == "" {
+			break
+		}
+		return strings.TrimSpace(*tag.Value), nil
+	}
+	return "", fmt.Errorf("package document %v has no version labeled %v, it is labeled by the tag %v%v", packageName, versionLabel, VersionLabelTagPrefix, versionLabel)
+}
+
+// resolveVersion returns the version name of the latest version or of the labeled version, other versions
+// are returned unchanged
+func (da *PackageArchive) resolveVersion(ctx context.Context, packageName string, version string) (string, error) {
+	if strings.HasPrefix(version, versionLabelPrefix) {
+		return da.labeledVersion(ctx, packageName, strings.TrimPrefix(version, versionLabelPrefix))
+	}
+	if version != "" && packageservice.IsLatest(version) {
+		return da.latestVersion(ctx, packageName)
+	}
+	return version, nil
 }
 
 // DownloadArtifactInfo downloads the document using GetDocument and eventually gets the manifest from that and returns it
-func (da *PackageArchive) DownloadArchiveInfo(packageName string, version string) (string, error) {
+func (da *PackageArchive) DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error) {
+	version, err := da.resolveVersion(ctx, packageName, version)
+	if err != nil {
+		return "", err
+	}
 	// return manifest and error
 	versionName := &version
 	if version == "" {
@@ -72,8 +204,13 @@ func (da *PackageArchive) DownloadArchiveInfo(packageName string, version string
 	}
 	MaxDelayBeforeCall := 15 //seconds
 	// random back off before GetDocument call
-	time.Sleep(time.Duration(getRandomBackOffTime(MaxDelayBeforeCall)) * time.Second)
-	resp, err := da.facadeClient.GetDocument(
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("failed to retrieve package document: %v", ctx.Err())
+	case <-time.After(time.Duration(getRandomBackOffTime(MaxDelayBeforeCall)) * time.Second):
+	}
+	resp, err := da.facadeClient.GetDocumentWithContext(
+		ctx,
 		&ssm.GetDocumentInput{
 			Name:        &packageName,
 			VersionName: versionName,
@@ -114,7 +251,7 @@ func (da *PackageArchive) DownloadArchiveInfo(packageName string, version string
 // GetFileDownloadLocation obtains the location of the file in the archive
 // in the document archive, this information is stored in the attachmentContent
 // field in the reult of GetDocument.
-func (da *PackageArchive) GetFileDownloadLocation(file *archive.File, packageName string, version string) (string, error) {
+func (da *PackageArchive) GetFileDownloadLocation(ctx context.Context, file *archive.File, packageName string, version string) (string, error) {
 	if file == nil {
 		return "", errors.New("Could not obtain the file from manifest")
 	}
@@ -122,12 +259,17 @@ func (da *PackageArchive) GetFileDownloadLocation(file *archive.File, packageNam
 
 	//If the attachments are nil, try to get document again.
 	if da.attachments == nil {
+		version, err := da.resolveVersion(ctx, packageName, version)
+		if err != nil {
+			return "", err
+		}
 		// return manifest and error
 		versionName := &version
 		if version == "" {
 			versionName = nil
 		}
-		resp, err := da.facadeClient.GetDocument(
+		resp, err := da.facadeClient.GetDocumentWithContext(
+			ctx,
 			&ssm.GetDocumentInput{
 				Name:        &packageName,
 				VersionName: versionName,
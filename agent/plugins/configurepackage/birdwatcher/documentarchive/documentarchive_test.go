@@ -16,6 +16,7 @@
 package documentarchive
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -31,49 +32,119 @@ import (
 func TestGetResourceVersion(t *testing.T) {
 
 	packageName := "Test Package"
-	version1 := ""
-	version2 := "1.2.3.4"
-	latest := "latest"
+	version := "1.2.3.4"
 
 	data := []struct {
-		name         string
-		packagename  string
-		version      string
-		facadeClient facade.FacadeStub
+		name             string
+		version          string
+		versionSelection string
+		versionLabel     string
+		expectedVersion  string
 	}{
-		{
-			"ValidDistributionRule",
-			packageName,
-			latest,
-			facade.FacadeStub{},
-		},
-		{
-			"ValidDistributionRule_2",
-			packageName,
-			version1,
-			facade.FacadeStub{},
-		},
-		{
+		{"latest is the default version", "latest", "", "", ""},
+		{"empty is the default version", "", VersionSelectionDefault, "", ""},
+		{"latest is the latest version", "latest", VersionSelectionLatest, "", "latest"},
+		{"empty is the latest version", "", VersionSelectionLatest, "", "latest"},
+		{"version is kept", version, VersionSelectionLatest, "", version},
+		{"empty is the labeled version", "", VersionSelectionLatest, "prod", "label:prod"},
+		{"version is kept over the label", version, "", "prod", version},
+	}
 
-			"ValidDistributionRule_3",
-			packageName,
-			version2,
-			facade.FacadeStub{},
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+
+			bwArchive := New(&facade.FacadeStub{}, testdata.versionSelection, testdata.versionLabel)
+
+			names, versions := bwArchive.GetResourceVersion(packageName, testdata.version)
+			assert.Equal(t, packageName, names)
+			assert.Equal(t, testdata.expectedVersion, versions)
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	active := ssm.DocumentStatusActive
+	failed := ssm.DocumentStatusFailed
+	nextToken := "page2"
+	version := func(documentVersion string, versionName string, status *string) *ssm.DocumentVersionInfo {
+		return &ssm.DocumentVersionInfo{DocumentVersion: &documentVersion, VersionName: &versionName, Status: status}
+	}
+	facadeClient := facade.FacadeStub{
+		ListDocumentVersionsOutputs: []*ssm.ListDocumentVersionsOutput{
+			{DocumentVersions: []*ssm.DocumentVersionInfo{version("1", "1.0.0", &active), version("10", "3.0.0", &failed)}, NextToken: &nextToken},
+			{DocumentVersions: []*ssm.DocumentVersionInfo{version("9", "2.0.0", &active), version("2", "1.1.0", &active)}},
 		},
 	}
+	docArchive := &PackageArchive{facadeClient: &facadeClient}
 
+	latest, err := docArchive.latestVersion(context.Background(), "ABC_package")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "2.0.0", latest)
+	assert.Len(t, facadeClient.ListDocumentVersionsInputs, 2)
+	assert.Nil(t, facadeClient.ListDocumentVersionsInputs[0].NextToken)
+	assert.Equal(t, nextToken, *facadeClient.ListDocumentVersionsInputs[1].NextToken)
+}
+
+func TestLatestVersionErrors(t *testing.T) {
+	failed := ssm.DocumentStatusFailed
+	documentVersion, versionName := "1", "1.0.0"
+	data := []struct {
+		name         string
+		facadeClient facade.FacadeStub
+	}{
+		{"failed api call", facade.FacadeStub{ListDocumentVersionsError: errors.New("testerror")}},
+		{"no active version", facade.FacadeStub{ListDocumentVersionsOutputs: []*ssm.ListDocumentVersionsOutput{
+			{DocumentVersions: []*ssm.DocumentVersionInfo{{DocumentVersion: &documentVersion, VersionName: &versionName, Status: &failed}}},
+		}}},
+	}
 	for _, testdata := range data {
 		t.Run(testdata.name, func(t *testing.T) {
+			docArchive := &PackageArchive{facadeClient: &testdata.facadeClient}
 
-			bwArchive := New(&testdata.facadeClient)
-
-			names, versions := bwArchive.GetResourceVersion(testdata.packagename, testdata.version)
-			assert.Equal(t, names, testdata.packagename)
-			assert.Equal(t, versions, testdata.version)
+			_, err := docArchive.latestVersion(context.Background(), "ABC_package")
+			assert.Error(t, err)
 		})
 	}
 }
 
+func TestLabeledVersion(t *testing.T) {
+	tag := func(key string, value string) *ssm.Tag {
+		return &ssm.Tag{Key: &key, Value: &value}
+	}
+	facadeClient := facade.FacadeStub{
+		ListTagsForResourceOutput: &ssm.ListTagsForResourceOutput{
+			TagList: []*ssm.Tag{tag("Team", "infra"), tag("VersionLabel:canary", "2.0.0"), tag("VersionLabel:prod", " 1.1.0 ")},
+		},
+	}
+	docArchive := &PackageArchive{facadeClient: &facadeClient}
+
+	version, err := docArchive.resolveVersion(context.Background(), "ABC_package", "label:prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.0", version)
+	assert.Equal(t, ssm.ResourceTypeForTaggingDocument, *facadeClient.ListTagsForResourceInput.ResourceType)
+	assert.Equal(t, "ABC_package", *facadeClient.ListTagsForResourceInput.ResourceId)
+
+	_, err = docArchive.resolveVersion(context.Background(), "ABC_package", "label:beta")
+	assert.Error(t, err)
+
+	facadeClient.ListTagsForResourceError = errors.New("testerror")
+	_, err = docArchive.resolveVersion(context.Background(), "ABC_package", "label:prod")
+	assert.Error(t, err)
+
+	version, err = docArchive.resolveVersion(context.Background(), "ABC_package", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0.0", version)
+}
+
+func TestIsValidVersionLabel(t *testing.T) {
+	assert.True(t, IsValidVersionLabel(""))
+	assert.True(t, IsValidVersionLabel("prod"))
+	assert.True(t, IsValidVersionLabel("canary-2.eu_west"))
+	assert.False(t, IsValidVersionLabel("prod label"))
+	assert.False(t, IsValidVersionLabel("prod:1"))
+}
+
 func TestDownloadArchiveInfo(t *testing.T) {
 	packageName := "ABC_package"
 	documentArn := "arn:aws:ssm:us-east-1:1234567890:document/NameOfDoc"
@@ -185,9 +256,9 @@ func TestDownloadArchiveInfo(t *testing.T) {
 	for _, testdata := range data {
 		t.Run(testdata.name, func(t *testing.T) {
 
-			docArchive := New(&testdata.facadeClient)
+			docArchive := New(&testdata.facadeClient, "", "")
 
-			document, err := docArchive.DownloadArchiveInfo(packageName, testdata.version)
+			document, err := docArchive.DownloadArchiveInfo(context.Background(), packageName, testdata.version)
 			if testdata.isError {
 				assert.Error(t, err)
 			} else {
@@ -352,7 +423,7 @@ func TestGetFileDownloadLocation(t *testing.T) {
 
 			docArchive := NewWithAttachments(&testdata.facadeClient, testdata.attachments)
 
-			location, err := docArchive.GetFileDownloadLocation(testdata.file, packagename, version)
+			location, err := docArchive.GetFileDownloadLocation(context.Background(), testdata.file, packagename, version)
 			if testdata.isError {
 				assert.Error(t, err)
 				assert.Equal(t, testdata.err, err.Error())
@@ -367,7 +438,7 @@ func TestGetFileDownloadLocation(t *testing.T) {
 
 func TestArchiveName(t *testing.T) {
 	facadeSession := facade.FacadeStub{}
-	testArchive := New(&facadeSession)
+	testArchive := New(&facadeSession, "", "")
 
 	assert.Equal(t, archive.PackageArchiveDocument, testArchive.Name())
 
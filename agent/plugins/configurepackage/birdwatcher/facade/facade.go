@@ -17,31 +17,21 @@ package facade
 
 import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	retry "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade/retryer"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/amazon-ssm-agent/agent/version"
 
-	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
-const (
-	maxRetries = 3
-)
-
 func NewBirdwatcherFacade() BirdwatcherFacade {
 	awsConfig := sdkutil.AwsConfig()
-	// overriding the retry strategy
-	retryer := retry.BirdwatcherRetryer{
-		DefaultRetryer: client.DefaultRetryer{
-			NumMaxRetries: maxRetries,
-		},
-	}
-
-	cfg := request.WithRetryer(awsConfig, retryer)
+	// overriding the retry strategy, the retries of every facade share the same budget
+	cfg := request.WithRetryer(awsConfig, retry.NewBirdwatcherRetryer())
 
 	// overrides ssm client config from appconfig if applicable
 	if appCfg, err := appconfig.Config(false); err == nil {
@@ -60,14 +50,38 @@ func NewBirdwatcherFacade() BirdwatcherFacade {
 	}
 	facadeClientSession := session.New(cfg)
 
-	// Define a request handler with current agentName and version
+	// Send the requests to the endpoint the calls failed over to, if any
+	facadeEndpointsOnce.Do(func() {
+		clientConfig := facadeClientSession.ClientConfig(ssm.EndpointsID)
+		primary := Endpoint{URL: clientConfig.Endpoint, Region: clientConfig.SigningRegion}
+		failover := appconfig.DefaultConfig().Birdwatcher.Failover
+		if appCfg, err := appconfig.Config(false); err == nil {
+			failover = appCfg.Birdwatcher.Failover
+		}
+		facadeEndpoints = NewEndpoints(log.DefaultLogger(), failoverEndpoints(primary, failover), failover.FailureThreshold)
+	})
+	failoverSign, failoverRetry, failoverComplete := failoverHandlers(facadeEndpoints)
+	facadeClientSession.Handlers.Sign.PushFrontNamed(failoverSign)
+	facadeClientSession.Handlers.Retry.PushFrontNamed(failoverRetry)
+	facadeClientSession.Handlers.Complete.PushBackNamed(failoverComplete)
+
+	// Define a request handler with current agentName and version, and the user agent suffix of the configuration
+	agentInfo := appconfig.DefaultConfig().Agent
+	agentInfo.Version = version.Version
+	if appCfg, err := appconfig.Config(false); err == nil {
+		agentInfo.UserAgentSuffix = appCfg.Agent.UserAgentSuffix
+	}
 	SSMAgentVersionUserAgentHandler := request.NamedHandler{
 		Name: "ssm.SSMAgentVersionUserAgentHandler",
-		Fn:   request.MakeAddToUserAgentHandler(appconfig.DefaultConfig().Agent.Name, version.Version),
+		Fn:   appconfig.MakeUserAgentHandler(agentInfo),
 	}
 
 	// Add the handler to each request to the BirdwatcherStationService
 	facadeClientSession.Handlers.Build.PushBackNamed(SSMAgentVersionUserAgentHandler)
 
+	// Record the latency and errors of each request, including its retries
+	facadeClientSession.Handlers.Complete.PushBackNamed(metricsHandler(metrics))
+	facadeClientSession.Handlers.Complete.PushBackNamed(retry.RefundHandler(retry.DefaultBudget))
+
 	return ssm.New(facadeClientSession)
 }
@@ -0,0 +1,166 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Endpoint is an endpoint of the package service and the region its requests are signed for
+type Endpoint struct {
+	URL    string
+	Region string
+}
+
+func (e Endpoint) String() string {
+	return fmt.Sprintf("%v (%v)", e.URL, e.Region)
+}
+
+// Endpoints are the endpoints the facade calls in order of preference. The calls stick to the current endpoint
+// until it is unreachable threshold attempts in a row, they then fail over to the next endpoint, and back to
+// the first one after the last. It is safe for concurrent use
+type Endpoints struct {
+	lock      sync.Mutex
+	log       log.T
+	endpoints []Endpoint
+	threshold int
+	current   int
+	failures  int
+}
+
+// NewEndpoints returns the endpoints starting with the first one
+func NewEndpoints(log log.T, endpoints []Endpoint, threshold int) *Endpoints {
+	return &Endpoints{log: log, endpoints: endpoints, threshold: threshold}
+}
+
+// Current returns the endpoint the calls are sent to
+func (e *Endpoints) Current() Endpoint {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.endpoints[e.current]
+}
+
+// Report records whether an attempt sent to the endpoint reached it. The attempts sent to an endpoint
+// the calls already failed over from are ignored, so concurrent calls fail over only once
+func (e *Endpoints) Report(endpoint Endpoint, reachable bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if endpoint != e.endpoints[e.current] {
+		return
+	}
+	if reachable {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	if e.failures < e.threshold || len(e.endpoints) == 1 {
+		return
+	}
+	e.current = (e.current + 1) % len(e.endpoints)
+	e.failures = 0
+	e.log.Warnf("Package service endpoint %v is unreachable, failing over to %v", endpoint, e.endpoints[e.current])
+}
+
+// facadeEndpoints are the endpoints shared by the facades created with NewBirdwatcherFacade, so a fail over
+// outlives the facade that made it
+var (
+	facadeEndpoints     *Endpoints
+	facadeEndpointsOnce sync.Once
+)
+
+// failoverEndpoints returns the endpoint of the region followed by the fail over endpoints of the config
+func failoverEndpoints(primary Endpoint, cfg appconfig.FacadeFailoverCfg) []Endpoint {
+	list := []Endpoint{primary}
+	for _, endpointURL := range cfg.Endpoints {
+		if !strings.Contains(endpointURL, "://") {
+			endpointURL = "https://" + endpointURL
+		}
+		list = append(list, Endpoint{URL: endpointURL, Region: primary.Region})
+	}
+	for _, region := range cfg.Regions {
+		if region == primary.Region {
+			continue
+		}
+		if resolved, err := endpoints.DefaultResolver().EndpointFor("ssm", region); err == nil {
+			list = append(list, Endpoint{URL: resolved.URL, Region: region})
+		}
+	}
+	return list
+}
+
+// isUnreachable returns true if the attempt failed without a response from the endpoint,
+// or the gateway in front of the endpoint could not reach it
+func isUnreachable(r *request.Request) bool {
+	if r.Error == nil {
+		return false
+	}
+	if r.HTTPResponse == nil {
+		return true
+	}
+	switch r.HTTPResponse.StatusCode {
+	case 0, 502, 504:
+		return true
+	}
+	return false
+}
+
+// failoverHandlers returns a handler sending every attempt of a request to the current endpoint, a handler
+// reporting whether a failed attempt reached it and a handler reporting the requests that succeeded
+func failoverHandlers(endpoints *Endpoints) (sign, retry, complete request.NamedHandler) {
+	sign = request.NamedHandler{
+		Name: "ssm.BirdwatcherFailoverSignHandler",
+		Fn: func(r *request.Request) {
+			current := endpoints.Current()
+			endpointURL, err := url.Parse(current.URL)
+			if err != nil {
+				return
+			}
+			r.HTTPRequest.URL.Scheme = endpointURL.Scheme
+			r.HTTPRequest.URL.Host = endpointURL.Host
+			r.HTTPRequest.Host = ""
+			r.ClientInfo.Endpoint = current.URL
+			r.ClientInfo.SigningRegion = current.Region
+		},
+	}
+	retry = request.NamedHandler{
+		Name: "ssm.BirdwatcherFailoverRetryHandler",
+		Fn: func(r *request.Request) {
+			endpoints.Report(requestEndpoint(r), !isUnreachable(r))
+		},
+	}
+	complete = request.NamedHandler{
+		Name: "ssm.BirdwatcherFailoverCompleteHandler",
+		Fn: func(r *request.Request) {
+			// the failed attempts were reported by the retry handler
+			if r.Error == nil {
+				endpoints.Report(requestEndpoint(r), true)
+			}
+		},
+	}
+	return sign, retry, complete
+}
+
+// requestEndpoint returns the endpoint the last attempt of the request was sent to
+func requestEndpoint(r *request.Request) Endpoint {
+	return Endpoint{URL: r.ClientInfo.Endpoint, Region: r.ClientInfo.SigningRegion}
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package facade
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	primaryEndpoint   = Endpoint{URL: "https://ssm.us-east-1.amazonaws.com", Region: "us-east-1"}
+	vpcEndpoint       = Endpoint{URL: "https://vpce-1234.ssm.us-east-1.vpce.amazonaws.com", Region: "us-east-1"}
+	secondaryEndpoint = Endpoint{URL: "https://ssm.us-west-2.amazonaws.com", Region: "us-west-2"}
+)
+
+func TestEndpointsFailOver(t *testing.T) {
+	endpoints := NewEndpoints(log.NewMockLog(), []Endpoint{primaryEndpoint, vpcEndpoint, secondaryEndpoint}, 2)
+
+	endpoints.Report(primaryEndpoint, false)
+	endpoints.Report(primaryEndpoint, true)
+	endpoints.Report(primaryEndpoint, false)
+	assert.Equal(t, primaryEndpoint, endpoints.Current())
+
+	endpoints.Report(primaryEndpoint, false)
+	assert.Equal(t, vpcEndpoint, endpoints.Current())
+
+	endpoints.Report(vpcEndpoint, false)
+	endpoints.Report(vpcEndpoint, false)
+	assert.Equal(t, secondaryEndpoint, endpoints.Current())
+
+	endpoints.Report(secondaryEndpoint, false)
+	endpoints.Report(secondaryEndpoint, false)
+	assert.Equal(t, primaryEndpoint, endpoints.Current())
+}
+
+func TestEndpointsStickToWorkingEndpoint(t *testing.T) {
+	endpoints := NewEndpoints(log.NewMockLog(), []Endpoint{primaryEndpoint, vpcEndpoint}, 1)
+
+	endpoints.Report(primaryEndpoint, false)
+	assert.Equal(t, vpcEndpoint, endpoints.Current())
+
+	// attempts still in flight to the primary endpoint don't move the calls again
+	endpoints.Report(primaryEndpoint, false)
+	endpoints.Report(primaryEndpoint, true)
+	endpoints.Report(vpcEndpoint, true)
+	assert.Equal(t, vpcEndpoint, endpoints.Current())
+}
+
+func TestEndpointsSingleEndpoint(t *testing.T) {
+	endpoints := NewEndpoints(log.NewMockLog(), []Endpoint{primaryEndpoint}, 1)
+
+	endpoints.Report(primaryEndpoint, false)
+
+	assert.Equal(t, primaryEndpoint, endpoints.Current())
+}
+
+func TestFailoverEndpoints(t *testing.T) {
+	cfg := appconfig.FacadeFailoverCfg{
+		Endpoints: []string{"vpce-1234.ssm.us-east-1.vpce.amazonaws.com"},
+		Regions:   []string{"us-east-1", "us-west-2"},
+	}
+
+	assert.Equal(t, []Endpoint{primaryEndpoint, vpcEndpoint, secondaryEndpoint}, failoverEndpoints(primaryEndpoint, cfg))
+	assert.Equal(t, []Endpoint{primaryEndpoint}, failoverEndpoints(primaryEndpoint, appconfig.FacadeFailoverCfg{}))
+}
+
+func TestFailoverHandlers(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"Manifest":"{}"}`))
+	}))
+	defer server.Close()
+	// a port nothing listens on
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	unreachableURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	endpoints := NewEndpoints(log.NewMockLog(), []Endpoint{{URL: unreachableURL, Region: "us-east-1"}, {URL: server.URL, Region: "us-west-2"}}, 1)
+	clientSession := session.New(&aws.Config{
+		Endpoint:    aws.String(unreachableURL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(1),
+	})
+	sign, retry, complete := failoverHandlers(endpoints)
+	clientSession.Handlers.Sign.PushFrontNamed(sign)
+	clientSession.Handlers.Retry.PushFrontNamed(retry)
+	clientSession.Handlers.Complete.PushBackNamed(complete)
+	client := ssm.New(clientSession)
+	manifestInput := &ssm.GetManifestInput{PackageName: aws.String("package"), PackageVersion: aws.String("1.0.0")}
+
+	// the first attempt fails over, the retry reaches the next endpoint
+	output, err := client.GetManifest(manifestInput)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", aws.StringValue(output.Manifest))
+
+	_, err = client.GetManifest(manifestInput)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, server.URL, endpoints.Current().URL)
+}
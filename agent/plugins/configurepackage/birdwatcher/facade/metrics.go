@@ -0,0 +1,146 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package facade
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// LatencyBuckets are the upper bounds of the latency histogram of the facade calls, the last bucket
+// of the histogram counts the calls slower than the last bound
+var LatencyBuckets = []time.Duration{
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// CallMetrics are the latency and errors of the calls of one facade operation, the latency of a call includes its retries
+type CallMetrics struct {
+	Calls        int64
+	Errors       int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+	Histogram    []int64
+}
+
+// ErrorRate returns the ratio of calls that failed
+func (m CallMetrics) ErrorRate() float64 {
+	if m.Calls == 0 {
+		return 0
+	}
+	return float64(m.Errors) / float64(m.Calls)
+}
+
+// AverageLatency returns the mean latency of the calls
+func (m CallMetrics) AverageLatency() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+	return m.TotalLatency / time.Duration(m.Calls)
+}
+
+func (m CallMetrics) String() string {
+	return fmt.Sprintf("calls=%v errors=%v avg=%v max=%v histogram=%v", m.Calls, m.Errors, m.AverageLatency(), m.MaxLatency, m.Histogram)
+}
+
+// Metrics collects the CallMetrics of the facade operations, it is safe for concurrent use
+type Metrics struct {
+	lock       sync.Mutex
+	operations map[string]*CallMetrics
+}
+
+// NewMetrics returns empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{operations: make(map[string]*CallMetrics)}
+}
+
+// metrics are the metrics of the calls made by the facades created with NewBirdwatcherFacade
+var metrics = NewMetrics()
+
+// FacadeMetrics returns the metrics of the calls made by the facades created with NewBirdwatcherFacade
+func FacadeMetrics() *Metrics {
+	return metrics
+}
+
+// Record adds a call of the operation
+func (m *Metrics) Record(operation string, latency time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	call, ok := m.operations[operation]
+	if !ok {
+		call = &CallMetrics{Histogram: make([]int64, len(LatencyBuckets)+1)}
+		m.operations[operation] = call
+	}
+	call.Calls++
+	if err != nil {
+		call.Errors++
+	}
+	call.TotalLatency += latency
+	if latency > call.MaxLatency {
+		call.MaxLatency = latency
+	}
+	bucket := sort.Search(len(LatencyBuckets), func(i int) bool { return latency <= LatencyBuckets[i] })
+	call.Histogram[bucket]++
+}
+
+// Snapshot returns a copy of the metrics keyed by operation
+func (m *Metrics) Snapshot() map[string]CallMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	snapshot := make(map[string]CallMetrics, len(m.operations))
+	for operation, call := range m.operations {
+		copied := *call
+		copied.Histogram = append([]int64(nil), call.Histogram...)
+		snapshot[operation] = copied
+	}
+	return snapshot
+}
+
+// String returns the metrics of every operation sorted by operation
+func (m *Metrics) String() string {
+	snapshot := m.Snapshot()
+	operations := make([]string, 0, len(snapshot))
+	for operation := range snapshot {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	var lines []string
+	for _, operation := range operations {
+		lines = append(lines, fmt.Sprintf("%v %v", operation, snapshot[operation]))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// metricsHandler returns a handler recording every completed request of the client in the metrics
+func metricsHandler(metrics *Metrics) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "ssm.BirdwatcherMetricsHandler",
+		Fn: func(r *request.Request) {
+			metrics.Record(r.Operation.Name, time.Since(r.Time), r.Error)
+		},
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package facade
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsRecord(t *testing.T) {
+	metrics := NewMetrics()
+
+	metrics.Record("GetManifest", 50*time.Millisecond, nil)
+	metrics.Record("GetManifest", 300*time.Millisecond, errors.New("throttled"))
+	metrics.Record("GetManifest", time.Minute, nil)
+	metrics.Record("PutConfigurePackageResult", time.Second, nil)
+
+	snapshot := metrics.Snapshot()
+	assert.Len(t, snapshot, 2)
+	manifest := snapshot["GetManifest"]
+	assert.Equal(t, int64(3), manifest.Calls)
+	assert.Equal(t, int64(1), manifest.Errors)
+	assert.InDelta(t, 1.0/3, manifest.ErrorRate(), 0.0001)
+	assert.Equal(t, time.Minute, manifest.MaxLatency)
+	assert.Equal(t, (50*time.Millisecond+300*time.Millisecond+time.Minute)/3, manifest.AverageLatency())
+	assert.Equal(t, []int64{1, 0, 1, 0, 0, 0, 0, 1}, manifest.Histogram)
+	assert.Equal(t, []int64{0, 0, 0, 1, 0, 0, 0, 0}, snapshot["PutConfigurePackageResult"].Histogram)
+}
+
+func TestMetricsSnapshotIsCopy(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Record("GetDocument", time.Millisecond, nil)
+
+	snapshot := metrics.Snapshot()
+	snapshot["GetDocument"].Histogram[0] = 10
+	metrics.Record("GetDocument", time.Millisecond, nil)
+
+	assert.Equal(t, int64(2), metrics.Snapshot()["GetDocument"].Histogram[0])
+	assert.Equal(t, int64(1), snapshot["GetDocument"].Calls)
+}
+
+func TestMetricsString(t *testing.T) {
+	metrics := NewMetrics()
+	assert.Equal(t, "", metrics.String())
+
+	metrics.Record("PutConfigurePackageResult", time.Second, nil)
+	metrics.Record("GetManifest", time.Second, errors.New("failed"))
+
+	assert.Equal(t, "GetManifest calls=1 errors=1 avg=1s max=1s histogram=[0 0 0 1 0 0 0 0]\n"+
+		"PutConfigurePackageResult calls=1 errors=0 avg=1s max=1s histogram=[0 0 0 1 0 0 0 0]", metrics.String())
+}
+
+func TestMetricsHandler(t *testing.T) {
+	metrics := NewMetrics()
+	handler := metricsHandler(metrics)
+	r := &request.Request{
+		Operation:   &request.Operation{Name: "GetManifest"},
+		Time:        time.Now().Add(-200 * time.Millisecond),
+		HTTPRequest: &http.Request{},
+		Error:       errors.New("failed"),
+	}
+
+	handler.Fn(r)
+
+	call := metrics.Snapshot()["GetManifest"]
+	assert.Equal(t, int64(1), call.Calls)
+	assert.Equal(t, int64(1), call.Errors)
+	assert.True(t, call.MaxLatency >= 200*time.Millisecond)
+}
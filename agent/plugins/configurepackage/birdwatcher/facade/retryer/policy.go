@@ -0,0 +1,218 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package retryer
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Class tells whether and how a failed call is retried
+type Class int
+
+const (
+	// Permanent failures fail the same way when the call is made again
+	Permanent Class = iota
+	// Transient failures, such as network and server errors, are retried with a short backoff
+	Transient
+	// Throttled calls are retried with a longer backoff, so that many instances do not call the service in lockstep
+	Throttled
+)
+
+// Classify returns the class of the error returned by a service call or a download
+func Classify(err error) Class {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return Permanent
+	}
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == request.CanceledErrorCode {
+		return Permanent
+	}
+	if statusErr, ok := err.(artifact.HTTPStatusError); ok {
+		return classifyStatusCode(statusErr.StatusCode)
+	}
+	switch contracts.ErrorCodeOf(err) {
+	case contracts.ErrorCodeThrottling:
+		return Throttled
+	case contracts.ErrorCodeNetwork, contracts.ErrorCodeTimeout:
+		return Transient
+	}
+	if requestErr, ok := err.(awserr.RequestFailure); ok {
+		return classifyStatusCode(requestErr.StatusCode())
+	}
+	if err == io.ErrUnexpectedEOF {
+		return Transient
+	}
+	return Permanent
+}
+
+func classifyStatusCode(statusCode int) Class {
+	switch {
+	// s3 asks to slow down with 503
+	case statusCode == 429 || statusCode == 503:
+		return Throttled
+	case statusCode == 408 || statusCode >= 500:
+		return Transient
+	}
+	return Permanent
+}
+
+// Policy is the exponential backoff of a kind of call
+type Policy struct {
+	// MaxAttempts includes the first attempt
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry of a transient failure, doubled for each retry
+	BaseDelay time.Duration
+	// ThrottledBaseDelay is the delay before the first retry of a throttled call, doubled for each retry
+	ThrottledBaseDelay time.Duration
+	// MaxDelay bounds the delay between two attempts
+	MaxDelay time.Duration
+}
+
+// FacadePolicy is the backoff of the calls to the service
+var FacadePolicy = Policy{
+	MaxAttempts:        4,
+	BaseDelay:          time.Second,
+	ThrottledBaseDelay: 4 * time.Second,
+	MaxDelay:           2 * time.Minute,
+}
+
+// DownloadPolicy is the backoff of the package downloads, resumable downloads keep what they downloaded between attempts
+var DownloadPolicy = Policy{
+	MaxAttempts:        3,
+	BaseDelay:          2 * time.Second,
+	ThrottledBaseDelay: 8 * time.Second,
+	MaxDelay:           2 * time.Minute,
+}
+
+// Delay returns the delay before the retry following the failed attempt, counted from 0. The delay is between half
+// and all of the exponential backoff, the random part spreads the retries of instances that failed at the same time.
+func (p Policy) Delay(attempt int, class Class) time.Duration {
+	backoff := p.BaseDelay
+	if class == Throttled {
+		backoff = p.ThrottledBaseDelay
+	}
+	for i := 0; i < attempt && backoff < p.MaxDelay; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+	half := backoff / 2
+	return half + time.Duration(jitter(int64(backoff-half)+1))
+}
+
+var (
+	randomMutex sync.Mutex
+	random      = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitter returns a random number in [0, n)
+var jitter = func(n int64) int64 {
+	randomMutex.Lock()
+	defer randomMutex.Unlock()
+	return random.Int63n(n)
+}
+
+const (
+	// defaultBudgetTokens is the number of retries, counted in tokens, the agent can make in a row while calls fail
+	defaultBudgetTokens = 500
+	// transientRetryCost and throttledRetryCost are the tokens taken by a retry, throttled calls drain the budget faster
+	transientRetryCost = 5
+	throttledRetryCost = 10
+	// successRefund is given back by each successful call
+	successRefund = 1
+)
+
+// Budget bounds the retries made while calls keep failing. Every retry takes tokens that successful calls give back,
+// so that the agent stops adding retries to a service that is throttling or unavailable.
+type Budget struct {
+	mutex    sync.Mutex
+	capacity int
+	tokens   int
+}
+
+// NewBudget returns a full budget of capacity tokens
+func NewBudget(capacity int) *Budget {
+	return &Budget{capacity: capacity, tokens: capacity}
+}
+
+// DefaultBudget is shared by the service calls and downloads of the agent
+var DefaultBudget = NewBudget(defaultBudgetTokens)
+
+// Withdraw takes the cost of a retry, it returns false when the budget can't afford it
+func (b *Budget) Withdraw(class Class) bool {
+	cost := transientRetryCost
+	if class == Throttled {
+		cost = throttledRetryCost
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens < cost {
+		return false
+	}
+	b.tokens -= cost
+	return true
+}
+
+// Refund gives back tokens after a successful call
+func (b *Budget) Refund() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens += successRefund; b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// Tokens returns the tokens left
+func (b *Budget) Tokens() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.tokens
+}
+
+// Do calls fn until it succeeds, it fails with a permanent error, the policy attempts or the budget are exhausted,
+// or ctx is done. It returns the error of the last attempt.
+func Do(ctx context.Context, log log.T, policy Policy, budget *Budget, operation string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			budget.Refund()
+			return nil
+		}
+		class := Classify(err)
+		if class == Permanent || attempt+1 >= policy.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+		if !budget.Withdraw(class) {
+			log.Warnf("Not retrying %v, the retry budget is exhausted: %v", operation, err)
+			return err
+		}
+		delay := policy.Delay(attempt, class)
+		log.Warnf("Attempt %v of %v failed, retrying in %v: %v", attempt+1, operation, delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package retryer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestFailure(code string, statusCode int) error {
+	return awserr.NewRequestFailure(awserr.New(code, "message", nil), statusCode, "id")
+}
+
+func TestClassify(t *testing.T) {
+	assert.Equal(t, Throttled, Classify(requestFailure("ThrottlingException", 400)))
+	assert.Equal(t, Throttled, Classify(requestFailure("TooManyRequestsException", 429)))
+	assert.Equal(t, Throttled, Classify(artifact.HTTPStatusError{Status: "503 Slow Down", StatusCode: 503}))
+	assert.Equal(t, Transient, Classify(requestFailure("InternalServerError", 500)))
+	assert.Equal(t, Transient, Classify(awserr.New("RequestError", "send request failed", &net.OpError{Op: "dial", Err: errors.New("connection refused")})))
+	assert.Equal(t, Transient, Classify(artifact.HTTPStatusError{Status: "502 Bad Gateway", StatusCode: 502}))
+	assert.Equal(t, Permanent, Classify(requestFailure("ValidationException", 400)))
+	assert.Equal(t, Permanent, Classify(requestFailure("AccessDeniedException", 403)))
+	assert.Equal(t, Permanent, Classify(artifact.HTTPStatusError{Status: "404 Not Found", StatusCode: 404}))
+	assert.Equal(t, Permanent, Classify(awserr.New(request.CanceledErrorCode, "canceled", context.Canceled)))
+	assert.Equal(t, Permanent, Classify(context.Canceled))
+	assert.Equal(t, Permanent, Classify(errors.New("checksum mismatch")))
+}
+
+func TestPolicyDelay(t *testing.T) {
+	defer func(j func(int64) int64) { jitter = j }(jitter)
+	policy := Policy{MaxAttempts: 5, BaseDelay: time.Second, ThrottledBaseDelay: 4 * time.Second, MaxDelay: 10 * time.Second}
+
+	jitter = func(n int64) int64 { return 0 }
+	assert.Equal(t, 500*time.Millisecond, policy.Delay(0, Transient))
+	assert.Equal(t, 2*time.Second, policy.Delay(2, Transient))
+	assert.Equal(t, 2*time.Second, policy.Delay(0, Throttled))
+	assert.Equal(t, 5*time.Second, policy.Delay(5, Throttled))
+
+	jitter = func(n int64) int64 { return n - 1 }
+	assert.Equal(t, time.Second, policy.Delay(0, Transient))
+	assert.Equal(t, 10*time.Second, policy.Delay(10, Transient))
+}
+
+func TestBudget(t *testing.T) {
+	budget := NewBudget(15)
+
+	assert.True(t, budget.Withdraw(Throttled))
+	assert.True(t, budget.Withdraw(Transient))
+	assert.False(t, budget.Withdraw(Transient))
+	budget.Refund()
+	assert.Equal(t, 1, budget.Tokens())
+
+	for i := 0; i < 20; i++ {
+		budget.Refund()
+	}
+	assert.Equal(t, 15, budget.Tokens())
+}
+
+var testPolicy = Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, ThrottledBaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+func TestDoRetriesTransientErrors(t *testing.T) {
+	budget := NewBudget(100)
+	calls := 0
+
+	err := Do(context.Background(), log.NewMockLog(), testPolicy, budget, "test", func() error {
+		calls++
+		if calls < 3 {
+			return requestFailure("ThrottlingException", 400)
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 81, budget.Tokens())
+}
+
+func TestDoStopsAfterMaxAttempts(t *testing.T) {
+	calls := 0
+
+	err := Do(context.Background(), log.NewMockLog(), testPolicy, NewBudget(100), "test", func() error {
+		calls++
+		return requestFailure("InternalServerError", 500)
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, testPolicy.MaxAttempts, calls)
+}
+
+func TestDoDoesNotRetryPermanentErrors(t *testing.T) {
+	calls := 0
+
+	err := Do(context.Background(), log.NewMockLog(), testPolicy, NewBudget(100), "test", func() error {
+		calls++
+		return requestFailure("ValidationException", 400)
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDoStopsWhenBudgetIsExhausted(t *testing.T) {
+	calls := 0
+
+	err := Do(context.Background(), log.NewMockLog(), testPolicy, NewBudget(transientRetryCost), "test", func() error {
+		calls++
+		return requestFailure("InternalServerError", 500)
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestDoStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	err := Do(ctx, log.NewMockLog(), testPolicy, NewBudget(100), "test", func() error {
+		calls++
+		cancel()
+		return requestFailure("InternalServerError", 500)
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestBirdwatcherRetryerShouldRetry(t *testing.T) {
+	budget := NewBudget(throttledRetryCost)
+	retryer := NewBirdwatcherRetryer()
+	retryer.Budget = budget
+
+	assert.False(t, retryer.ShouldRetry(&request.Request{Error: requestFailure("ValidationException", 400)}))
+	assert.False(t, retryer.ShouldRetry(&request.Request{Error: requestFailure("ThrottlingException", 400), RetryCount: retryer.MaxRetries()}))
+	assert.Equal(t, throttledRetryCost, budget.Tokens())
+	assert.True(t, retryer.ShouldRetry(&request.Request{Error: requestFailure("ThrottlingException", 400)}))
+	// the budget is exhausted
+	assert.False(t, retryer.ShouldRetry(&request.Request{Error: requestFailure("InternalServerError", 500)}))
+}
@@ -11,41 +11,81 @@
 // either express or implied. See the License for the specific language governing
 // permissions and limitations under the License.
 
-// Package retryer overrides the default ssm retryer delay logic to suit GetManifest, DescribeDocument and GetDocument
+// Package retryer implements the retries of the calls made to install packages: the ssm retryer of the
+// Birdwatcher facade and the retries of package downloads share the same backoff, error classes and budget
 package retryer
 
 import (
-	"math"
-	"math/rand"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/request"
 )
 
+// BirdwatcherRetryer retries the facade calls within the retry budget, the zero value uses FacadePolicy and DefaultBudget
 type BirdwatcherRetryer struct {
 	client.DefaultRetryer
+	Policy *Policy
+	Budget *Budget
+}
+
+// NewBirdwatcherRetryer returns a retryer making the attempts of FacadePolicy
+func NewBirdwatcherRetryer() BirdwatcherRetryer {
+	return BirdwatcherRetryer{
+		DefaultRetryer: client.DefaultRetryer{
+			NumMaxRetries: FacadePolicy.MaxAttempts - 1,
+		},
+	}
+}
+
+func (s BirdwatcherRetryer) policy() Policy {
+	if s.Policy == nil {
+		return FacadePolicy
+	}
+	return *s.Policy
+}
+
+func (s BirdwatcherRetryer) budget() *Budget {
+	if s.Budget == nil {
+		return DefaultBudget
+	}
+	return s.Budget
+}
+
+// ShouldRetry returns true if the request failed with a transient or throttling error and the budget affords a retry
+func (s BirdwatcherRetryer) ShouldRetry(r *request.Request) bool {
+	if r.RetryCount >= s.MaxRetries() {
+		// the retry would not be made, don't take from the budget
+		return false
+	}
+	class := Classify(r.Error)
+	if class == Permanent {
+		// the sdk knows some other errors can be retried, such as an expired token
+		if !r.IsErrorRetryable() && !r.IsErrorExpired() {
+			return false
+		}
+		class = Transient
+	}
+	return s.budget().Withdraw(class)
 }
 
 // RetryRules returns the delay duration before retrying this request again
 func (s BirdwatcherRetryer) RetryRules(r *request.Request) time.Duration {
-	// retry after a > 1 sec timeout, increasing exponentially with each retry
-	// attempt 1: 1s - 21s
-	// attempt 2: 4s - 1.4min
-	// attempt 3: 9s - 5.48min
-	rand.Seed(time.Now().UnixNano())
-	throttleDelay := int(math.Pow(4, float64(r.RetryCount)))*rand.Intn(5000) + 1000*int(math.Pow(float64(r.RetryCount+1), 2))
-
-	// Handle GetManifest, GetDocument and DescribeDocument Throttled calls error
-	if (r.Operation.Name == "GetManifest" || r.Operation.Name == "GetDocument" || r.Operation.Name == "DescribeDocument") && r.IsErrorThrottle() {
-		// throttling attempt. Increase the delay with greater exponential backoff
-		return time.Duration(throttleDelay) * time.Millisecond
+	class := Classify(r.Error)
+	if r.IsErrorThrottle() {
+		class = Throttled
 	}
+	return s.policy().Delay(r.RetryCount, class)
+}
 
-	// if error is not of throttle type, add regular retry strategy
-	// attempt 1: 1 - 5 sec
-	// attempt 2: 1 - 9 sec
-	// attempt 3: 1 - 17 sec
-	delay := int(math.Pow(2, float64(r.RetryCount)))*rand.Intn(2000) + 1000
-	return time.Duration(delay) * time.Millisecond
+// RefundHandler gives back to the budget of the retryer after each successful request
+func RefundHandler(budget *Budget) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "birdwatcher.RetryBudgetRefundHandler",
+		Fn: func(r *request.Request) {
+			if r.Error == nil {
+				budget.Refund()
+			}
+		},
+	}
 }
@@ -16,6 +16,7 @@
 package facade
 
 import (
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
@@ -33,6 +34,15 @@ type FacadeStub struct {
 	GetDocumentInput  *ssm.GetDocumentInput
 	GetDocumentOutput *ssm.GetDocumentOutput
 	GetDocumentError  error
+
+	// ListDocumentVersionsOutputs are returned in order, one for each call
+	ListDocumentVersionsInputs  []*ssm.ListDocumentVersionsInput
+	ListDocumentVersionsOutputs []*ssm.ListDocumentVersionsOutput
+	ListDocumentVersionsError   error
+
+	ListTagsForResourceInput  *ssm.ListTagsForResourceInput
+	ListTagsForResourceOutput *ssm.ListTagsForResourceOutput
+	ListTagsForResourceError  error
 }
 
 func (m *FacadeStub) GetManifestRequest(*ssm.GetManifestInput) (*request.Request, *ssm.GetManifestOutput) {
@@ -44,6 +54,13 @@ func (m *FacadeStub) GetManifest(input *ssm.GetManifestInput) (*ssm.GetManifestO
 	return m.GetManifestOutput, m.GetManifestError
 }
 
+func (m *FacadeStub) GetManifestWithContext(ctx aws.Context, input *ssm.GetManifestInput, opts ...request.Option) (*ssm.GetManifestOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetManifest(input)
+}
+
 func (m *FacadeStub) PutConfigurePackageResultRequest(*ssm.PutConfigurePackageResultInput) (*request.Request, *ssm.PutConfigurePackageResultOutput) {
 	panic("not implemented")
 }
@@ -53,6 +70,13 @@ func (m *FacadeStub) PutConfigurePackageResult(input *ssm.PutConfigurePackageRes
 	return m.PutConfigurePackageResultOutput, m.PutConfigurePackageResultError
 }
 
+func (m *FacadeStub) PutConfigurePackageResultWithContext(ctx aws.Context, input *ssm.PutConfigurePackageResultInput, opts ...request.Option) (*ssm.PutConfigurePackageResultOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.PutConfigurePackageResult(input)
+}
+
 func (m *FacadeStub) GetDocumentRequest(*ssm.GetDocumentInput) (*request.Request, *ssm.GetDocumentOutput) {
 	panic("not implemented")
 }
@@ -61,3 +85,49 @@ func (m *FacadeStub) GetDocument(input *ssm.GetDocumentInput) (*ssm.GetDocumentO
 	m.GetDocumentInput = input
 	return m.GetDocumentOutput, m.GetDocumentError
 }
+
+func (m *FacadeStub) GetDocumentWithContext(ctx aws.Context, input *ssm.GetDocumentInput, opts ...request.Option) (*ssm.GetDocumentOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetDocument(input)
+}
+
+func (m *FacadeStub) ListDocumentVersionsRequest(*ssm.ListDocumentVersionsInput) (*request.Request, *ssm.ListDocumentVersionsOutput) {
+	panic("not implemented")
+}
+
+func (m *FacadeStub) ListDocumentVersions(input *ssm.ListDocumentVersionsInput) (*ssm.ListDocumentVersionsOutput, error) {
+	m.ListDocumentVersionsInputs = append(m.ListDocumentVersionsInputs, input)
+	if m.ListDocumentVersionsError != nil {
+		return nil, m.ListDocumentVersionsError
+	}
+	call := len(m.ListDocumentVersionsInputs) - 1
+	if call >= len(m.ListDocumentVersionsOutputs) {
+		return &ssm.ListDocumentVersionsOutput{}, nil
+	}
+	return m.ListDocumentVersionsOutputs[call], nil
+}
+
+func (m *FacadeStub) ListDocumentVersionsWithContext(ctx aws.Context, input *ssm.ListDocumentVersionsInput, opts ...request.Option) (*ssm.ListDocumentVersionsOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListDocumentVersions(input)
+}
+
+func (m *FacadeStub) ListTagsForResourceRequest(*ssm.ListTagsForResourceInput) (*request.Request, *ssm.ListTagsForResourceOutput) {
+	panic("not implemented")
+}
+
+func (m *FacadeStub) ListTagsForResource(input *ssm.ListTagsForResourceInput) (*ssm.ListTagsForResourceOutput, error) {
+	m.ListTagsForResourceInput = input
+	return m.ListTagsForResourceOutput, m.ListTagsForResourceError
+}
+
+func (m *FacadeStub) ListTagsForResourceWithContext(ctx aws.Context, input *ssm.ListTagsForResourceInput, opts ...request.Option) (*ssm.ListTagsForResourceOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.ListTagsForResource(input)
+}
@@ -0,0 +1,145 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package localarchive contains the struct that is called when the packages are staged in a local directory
+package localarchive
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
+)
+
+// ManifestFileName is the manifest of a package version, stored with its files in
+// <archive>/<package name>/<version>/
+const ManifestFileName = "manifest.json"
+
+type PackageArchive struct {
+	root        string
+	archiveType string
+	packageName string
+}
+
+// New is a constructor for PackageArchive struct, location is a directory path or a file url
+func New(location string) archive.IPackageArchive {
+	return &PackageArchive{
+		root:        rootPath(location),
+		archiveType: archive.PackageArchiveLocal,
+	}
+}
+
+// Name of archive type
+func (la *PackageArchive) Name() string {
+	return la.archiveType
+}
+
+func (la *PackageArchive) GetResourceVersion(packageName string, packageVersion string) (name string, version string) {
+	version = packageVersion
+	if packageservice.IsLatest(packageVersion) {
+		version = packageservice.Latest
+	}
+
+	return packageName, version
+}
+
+// DownloadArchiveInfo reads the manifest of the version of the package, latest is the highest version staged
+func (la *PackageArchive) DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error) {
+	if err := validatePathElement(packageName); err != nil {
+		return "", fmt.Errorf("invalid package name: %v", err)
+	}
+	packageDirectory := filepath.Join(la.root, packageName)
+	if packageservice.IsLatest(version) {
+		latest, err := latestVersion(packageDirectory)
+		if err != nil {
+			return "", err
+		}
+		version = latest
+	} else if err := validatePathElement(version); err != nil {
+		return "", fmt.Errorf("invalid package version: %v", err)
+	}
+
+	manifest, err := ioutil.ReadFile(filepath.Join(packageDirectory, version, ManifestFileName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the manifest of %v %v from the local archive: %v", packageName, version, err)
+	}
+	la.packageName = packageName
+	return string(manifest), nil
+}
+
+// GetFileDownloadLocation returns the file url of the file in the version directory of the package,
+// the download location of the manifest is not used
+func (la *PackageArchive) GetFileDownloadLocation(ctx context.Context, file *archive.File, packageName string, version string) (string, error) {
+	if file == nil {
+		return "", fmt.Errorf("file is empty")
+	}
+	for _, element := range []string{packageName, version, file.Name} {
+		if err := validatePathElement(element); err != nil {
+			return "", err
+		}
+	}
+	path := filepath.ToSlash(filepath.Join(la.root, packageName, version, file.Name))
+	if !strings.HasPrefix(path, "/") {
+		// drive letters and network shares
+		path = "/" + path
+	}
+	fileURL := url.URL{Scheme: "file", Path: path}
+	return fileURL.String(), nil
+}
+
+// GetResourceArn returns the name of the package, packages of the local archive are identified by their directory
+func (la *PackageArchive) GetResourceArn(manifest *birdwatcher.Manifest) string {
+	return la.packageName
+}
+
+// rootPath returns the directory of the archive location
+func rootPath(location string) string {
+	if locationURL, err := url.Parse(location); err == nil && locationURL.Scheme == "file" {
+		return artifact.FilePathFromURL(locationURL)
+	}
+	return location
+}
+
+// latestVersion returns the highest version staged in the package directory
+func latestVersion(packageDirectory string) (string, error) {
+	entries, err := ioutil.ReadDir(packageDirectory)
+	if err != nil {
+		return "", fmt.Errorf("failed to list the versions in the local archive: %v", err)
+	}
+	latest := ""
+	for _, entry := range entries {
+		if entry.IsDir() && (latest == "" || versionutil.Compare(entry.Name(), latest, false) > 0) {
+			latest = entry.Name()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no version of the package is staged in %v", packageDirectory)
+	}
+	return latest, nil
+}
+
+// validatePathElement rejects names that would resolve outside of the archive
+func validatePathElement(element string) error {
+	if element == "" || element == "." || element == ".." || strings.ContainsAny(element, `/\`) {
+		return fmt.Errorf("%q is not a valid name in the local archive", element)
+	}
+	return nil
+}
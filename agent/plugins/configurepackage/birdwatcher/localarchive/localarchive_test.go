@@ -0,0 +1,126 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package localarchive contains the struct that is called when the packages are staged in a local directory
+package localarchive
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+// stageArchive creates a local archive with the versions of the PVDriver package
+func stageArchive(t *testing.T, versions ...string) string {
+	root, err := ioutil.TempDir("", "localarchive")
+	assert.NoError(t, err)
+	for _, version := range versions {
+		directory := filepath.Join(root, "PVDriver", version)
+		assert.NoError(t, os.MkdirAll(directory, 0700))
+		manifest := `{"version":"` + version + `"}`
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(directory, ManifestFileName), []byte(manifest), 0600))
+	}
+	return root
+}
+
+func TestArchiveName(t *testing.T) {
+	testArchive := New("/packages")
+
+	assert.Equal(t, archive.PackageArchiveLocal, testArchive.Name())
+}
+
+func TestGetResourceVersion(t *testing.T) {
+	testArchive := New("/packages")
+
+	name, version := testArchive.GetResourceVersion("PVDriver", "")
+	assert.Equal(t, "PVDriver", name)
+	assert.Equal(t, "latest", version)
+
+	_, version = testArchive.GetResourceVersion("PVDriver", "1.2.3")
+	assert.Equal(t, "1.2.3", version)
+}
+
+func TestDownloadArchiveInfo(t *testing.T) {
+	root := stageArchive(t, "1.2.0", "1.10.0", "1.9.3")
+	defer os.RemoveAll(root)
+	testArchive := New(root)
+
+	manifest, err := testArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "1.9.3")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":"1.9.3"}`, manifest)
+	assert.Equal(t, "PVDriver", testArchive.GetResourceArn(&birdwatcher.Manifest{PackageArn: "arn"}))
+
+	manifest, err = testArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "latest")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":"1.10.0"}`, manifest)
+
+	_, err = testArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "2.0.0")
+	assert.Error(t, err)
+	_, err = testArchive.DownloadArchiveInfo(context.Background(), "Other", "latest")
+	assert.Error(t, err)
+}
+
+func TestDownloadArchiveInfoFileURL(t *testing.T) {
+	root := stageArchive(t, "1.0.0")
+	defer os.RemoveAll(root)
+	path := filepath.ToSlash(root)
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	location := url.URL{Scheme: "file", Path: path}
+	testArchive := New(location.String())
+
+	manifest, err := testArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":"1.0.0"}`, manifest)
+}
+
+func TestDownloadArchiveInfoRejectsPaths(t *testing.T) {
+	root := stageArchive(t, "1.0.0")
+	defer os.RemoveAll(root)
+	testArchive := New(filepath.Join(root, "PVDriver"))
+
+	for _, name := range []string{"..", "../PVDriver", `..\PVDriver`, ""} {
+		_, err := testArchive.DownloadArchiveInfo(context.Background(), name, "1.0.0")
+		assert.Error(t, err, name)
+	}
+	_, err := testArchive.DownloadArchiveInfo(context.Background(), "PVDriver", "../1.0.0")
+	assert.Error(t, err)
+}
+
+func TestGetFileDownloadLocation(t *testing.T) {
+	root := stageArchive(t)
+	defer os.RemoveAll(root)
+	testArchive := New(root)
+	file := &archive.File{Name: "PVDriver.zip", Info: birdwatcher.FileInfo{DownloadLocation: "https://example.com/PVDriver.zip"}}
+
+	location, err := testArchive.GetFileDownloadLocation(context.Background(), file, "PVDriver", "1.0.0")
+	assert.NoError(t, err)
+	fileURL, err := url.Parse(location)
+	assert.NoError(t, err)
+	assert.Equal(t, "file", fileURL.Scheme)
+	assert.Equal(t, filepath.Join(root, "PVDriver", "1.0.0", "PVDriver.zip"), artifact.FilePathFromURL(fileURL))
+
+	_, err = testArchive.GetFileDownloadLocation(context.Background(), &archive.File{Name: "../manifest.json"}, "PVDriver", "1.0.0")
+	assert.Error(t, err)
+	_, err = testArchive.GetFileDownloadLocation(context.Background(), nil, "PVDriver", "1.0.0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ociarchive
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// dependency on the authorization of private ECR registries
+type registryDep interface {
+	EcrAuthorizationToken(ctx context.Context, log log.T, region string) (string, error)
+}
+
+type registryDepImp struct{}
+
+var registrydep registryDep = &registryDepImp{}
+
+// EcrAuthorizationToken returns the base64 encoded credentials of the ECR registries of the region
+func (registryDepImp) EcrAuthorizationToken(ctx context.Context, log log.T, region string) (string, error) {
+	config := sdkutil.AwsConfig().WithRegion(region)
+	output, err := ecr.New(session.New(config)).GetAuthorizationTokenWithContext(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return "", err
+	}
+	if len(output.AuthorizationData) == 0 || aws.StringValue(output.AuthorizationData[0].AuthorizationToken) == "" {
+		return "", fmt.Errorf("no ECR authorization token returned in %v", region)
+	}
+	log.Debugf("Got ECR authorization token for %v", region)
+	return aws.StringValue(output.AuthorizationData[0].AuthorizationToken), nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ociarchive contains the struct that is called when the package is stored in an OCI registry
+package ociarchive
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+const (
+	// PackageNamePrefix is the scheme of package names stored in a registry, such as oci://registry/repository
+	PackageNamePrefix = "oci://"
+
+	// ManifestMediaType is the media type of the config blob of a package, which holds its manifest
+	ManifestMediaType = "application/vnd.aws.ssm.package.manifest.v1+json"
+
+	// TitleAnnotation names the file a layer of a package is installed as
+	TitleAnnotation = "org.opencontainers.image.title"
+
+	// latestTag is the tag of the latest version of a package
+	latestTag = "latest"
+)
+
+// PackageArchive reads a package version from the image of the same tag, the image config is
+// the package manifest and each layer is a file of the package
+type PackageArchive struct {
+	log           log.T
+	archiveType   string
+	packageName   string
+	blobDirectory string
+	registry      *registryClient
+	files         map[string]string // layer digests by file name
+}
+
+// IsOciPackageName returns true if the package name is the reference of a repository in an OCI registry
+func IsOciPackageName(packageName string) bool {
+	return strings.HasPrefix(packageName, PackageNamePrefix)
+}
+
+// New is a constructor for PackageArchive struct
+func New(log log.T) archive.IPackageArchive {
+	return &PackageArchive{
+		log:           log,
+		archiveType:   archive.PackageArchiveOci,
+		blobDirectory: filepath.Join(appconfig.DownloadRoot, "oci"),
+	}
+}
+
+// Name of archive type
+func (oa *PackageArchive) Name() string {
+	return oa.archiveType
+}
+
+func (oa *PackageArchive) GetResourceVersion(packageName string, packageVersion string) (name string, version string) {
+	version = packageVersion
+	if packageservice.IsLatest(packageVersion) {
+		version = packageservice.Latest
+	}
+
+	return packageName, version
+}
+
+// DownloadArchiveInfo downloads the image of the version of the package and returns its config, the package manifest.
+// Versions are tags or digests of the repository, latest is the latest tag.
+func (oa *PackageArchive) DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error) {
+	if packageservice.IsLatest(version) {
+		version = latestTag
+	}
+	manifest, err := oa.loadImage(ctx, packageName, version)
+	if err != nil {
+		return "", err
+	}
+	if manifest.Config.MediaType != ManifestMediaType {
+		return "", fmt.Errorf("%v %v is not a package, its config media type is %v", packageName, version, manifest.Config.MediaType)
+	}
+	config, err := oa.registry.getBlob(ctx, manifest.Config.Digest)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the manifest of %v %v: %v", packageName, version, err)
+	}
+	oa.packageName = packageName
+	return string(config), nil
+}
+
+// GetFileDownloadLocation returns the url the layer of the file is downloaded from,
+// the image is loaded again when the manifest came from the cache
+func (oa *PackageArchive) GetFileDownloadLocation(ctx context.Context, file *archive.File, packageName string, version string) (string, error) {
+	if file == nil {
+		return "", fmt.Errorf("file is empty")
+	}
+	if oa.files == nil {
+		if _, err := oa.loadImage(ctx, packageName, version); err != nil {
+			return "", err
+		}
+	}
+	digest, ok := oa.files[file.Name]
+	if !ok {
+		return "", fmt.Errorf("%v %v has no layer titled %v", packageName, version, file.Name)
+	}
+	return oa.registry.blobLocation(ctx, digest)
+}
+
+// GetResourceArn returns the repository reference of the package
+func (oa *PackageArchive) GetResourceArn(manifest *birdwatcher.Manifest) string {
+	return oa.packageName
+}
+
+// loadImage gets the image manifest of the version and indexes its layers by title
+func (oa *PackageArchive) loadImage(ctx context.Context, packageName string, version string) (*ociManifest, error) {
+	if !IsOciPackageName(packageName) {
+		return nil, fmt.Errorf("%v is not the reference of a package repository", packageName)
+	}
+	if oa.registry == nil {
+		registry, err := newRegistryClient(oa.log, strings.TrimPrefix(packageName, PackageNamePrefix), oa.blobDirectory)
+		if err != nil {
+			return nil, err
+		}
+		oa.registry = registry
+	}
+	manifest, err := oa.registry.getManifest(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	for _, layer := range manifest.Layers {
+		title := layer.Annotations[TitleAnnotation]
+		if title == "" {
+			continue
+		}
+		if title == "." || title == ".." || strings.ContainsAny(title, `/\`) {
+			return nil, fmt.Errorf("%v %v has an invalid layer title %q", packageName, version, title)
+		}
+		files[title] = layer.Digest
+	}
+	oa.files = files
+	return manifest, nil
+}
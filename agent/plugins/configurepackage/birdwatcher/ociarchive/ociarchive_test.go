@@ -0,0 +1,276 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ociarchive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `{"schemaVersion": "2.0", "version": "1.0.0", "packages": {}, "files": {}}`
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testRegistry serves a package image of the repository team/pkg behind an anonymous bearer token
+type testRegistry struct {
+	server  *httptest.Server
+	blobs   map[string]string
+	storage map[string]string
+	tags    map[string]ociManifest
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	registry := &testRegistry{blobs: make(map[string]string), storage: make(map[string]string), tags: make(map[string]ociManifest)}
+	registry.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			assert.Equal(t, "repository:team/pkg:pull", r.URL.Query().Get("scope"))
+			w.Write([]byte(`{"token": "anonymous"}`))
+			return
+		}
+		if content, ok := registry.storage[r.URL.Path]; ok {
+			assert.Empty(t, r.Header.Get("Authorization"))
+			w.Write([]byte(content))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+registry.server.URL+`/token",service="test",scope="repository:team/pkg:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if tag := strings.TrimPrefix(r.URL.Path, "/v2/team/pkg/manifests/"); tag != r.URL.Path {
+			manifest, ok := registry.tags[tag]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(manifest)
+			return
+		}
+		digest := strings.TrimPrefix(r.URL.Path, "/v2/team/pkg/blobs/")
+		if location, ok := registry.storage["redirect "+digest]; ok {
+			http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+			return
+		}
+		if content, ok := registry.blobs[digest]; ok {
+			w.Write([]byte(content))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	return registry
+}
+
+// push tags an image whose config is the manifest and whose layers are the files
+func (registry *testRegistry) push(tag string, configMediaType string, manifest string, files map[string]string) {
+	image := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        ociDescriptor{MediaType: configMediaType, Digest: digestOf(manifest)},
+	}
+	registry.blobs[digestOf(manifest)] = manifest
+	for name, content := range files {
+		image.Layers = append(image.Layers, ociDescriptor{
+			MediaType:   "application/octet-stream",
+			Digest:      digestOf(content),
+			Annotations: map[string]string{TitleAnnotation: name},
+		})
+		registry.blobs[digestOf(content)] = content
+	}
+	registry.tags[tag] = image
+}
+
+// newTestArchive returns an archive of the test registry, which is served over http
+func newTestArchive(t *testing.T, registry *testRegistry) (*PackageArchive, string) {
+	blobDirectory, err := ioutil.TempDir("", "ociarchive")
+	assert.NoError(t, err)
+	oa := New(log.NewMockLog()).(*PackageArchive)
+	oa.blobDirectory = blobDirectory
+	oa.registry, err = newRegistryClient(oa.log, strings.TrimPrefix(registry.server.URL, "http://")+"/team/pkg", blobDirectory)
+	assert.NoError(t, err)
+	oa.registry.scheme = "http"
+	return oa, blobDirectory
+}
+
+func TestIsOciPackageName(t *testing.T) {
+	assert.True(t, IsOciPackageName("oci://public.ecr.aws/team/pkg"))
+	assert.False(t, IsOciPackageName("s3://bucket/pkg"))
+	assert.False(t, IsOciPackageName("AWSPVDriver"))
+}
+
+func TestDownloadArchiveInfo(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("latest", ManifestMediaType, testManifest, map[string]string{"pkg.zip": "zip content"})
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	manifest, err := oa.DownloadArchiveInfo(context.Background(), "oci://registry/team/pkg", "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, testManifest, manifest)
+	assert.Equal(t, map[string]string{"pkg.zip": digestOf("zip content")}, oa.files)
+	assert.Equal(t, "oci://registry/team/pkg", oa.GetResourceArn(nil))
+	assert.Equal(t, archive.PackageArchiveOci, oa.Name())
+}
+
+func TestDownloadArchiveInfoNotPackage(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("1.0.0", "application/vnd.oci.image.config.v1+json", "{}", nil)
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	_, err := oa.DownloadArchiveInfo(context.Background(), "oci://registry/team/pkg", "1.0.0")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not a package")
+}
+
+func TestDownloadArchiveInfoUnknownVersion(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	_, err := oa.DownloadArchiveInfo(context.Background(), "oci://registry/team/pkg", "2.0.0")
+
+	assert.Error(t, err)
+}
+
+func TestGetFileDownloadLocationSavesBlob(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("1.0.0", ManifestMediaType, testManifest, map[string]string{"pkg.zip": "zip content"})
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	// the manifest came from the cache, the image is loaded by the file download
+	location, err := oa.GetFileDownloadLocation(context.Background(), &archive.File{Name: "pkg.zip"}, "oci://registry/team/pkg", "1.0.0")
+
+	assert.NoError(t, err)
+	fileURL, err := url.Parse(location)
+	assert.NoError(t, err)
+	assert.Equal(t, "file", fileURL.Scheme)
+	content, err := ioutil.ReadFile(filepath.FromSlash(fileURL.Path))
+	assert.NoError(t, err)
+	assert.Equal(t, "zip content", string(content))
+}
+
+func TestGetFileDownloadLocationRedirect(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("1.0.0", ManifestMediaType, testManifest, map[string]string{"pkg.zip": "zip content"})
+	registry.storage["redirect "+digestOf("zip content")] = registry.server.URL + "/storage/pkg.zip?signature=abc"
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	location, err := oa.GetFileDownloadLocation(context.Background(), &archive.File{Name: "pkg.zip"}, "oci://registry/team/pkg", "1.0.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, registry.server.URL+"/storage/pkg.zip?signature=abc", location)
+}
+
+func TestGetFileDownloadLocationDigestMismatch(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("1.0.0", ManifestMediaType, testManifest, map[string]string{"pkg.zip": "zip content"})
+	registry.blobs[digestOf("zip content")] = "tampered content"
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	_, err := oa.GetFileDownloadLocation(context.Background(), &archive.File{Name: "pkg.zip"}, "oci://registry/team/pkg", "1.0.0")
+
+	assert.Error(t, err)
+	encoded := strings.TrimPrefix(digestOf("zip content"), "sha256:")
+	assert.False(t, fileutil.Exists(filepath.Join(blobDirectory, encoded)))
+}
+
+func TestGetFileDownloadLocationUnknownFile(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("1.0.0", ManifestMediaType, testManifest, map[string]string{"pkg.zip": "zip content"})
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	_, err := oa.GetFileDownloadLocation(context.Background(), &archive.File{Name: "other.zip"}, "oci://registry/team/pkg", "1.0.0")
+
+	assert.Error(t, err)
+}
+
+func TestLoadImageInvalidTitle(t *testing.T) {
+	registry := newTestRegistry(t)
+	defer registry.server.Close()
+	registry.push("1.0.0", ManifestMediaType, testManifest, map[string]string{"../pkg.zip": "zip content"})
+	oa, blobDirectory := newTestArchive(t, registry)
+	defer os.RemoveAll(blobDirectory)
+
+	_, err := oa.DownloadArchiveInfo(context.Background(), "oci://registry/team/pkg", "1.0.0")
+
+	assert.Error(t, err)
+}
+
+type registryDepMock struct {
+	region string
+}
+
+func (m *registryDepMock) EcrAuthorizationToken(ctx context.Context, log log.T, region string) (string, error) {
+	m.region = region
+	return "QVdTOnBhc3N3b3Jk", nil
+}
+
+func TestAuthorizeEcr(t *testing.T) {
+	mock := &registryDepMock{}
+	registrydep = mock
+	defer func() { registrydep = &registryDepImp{} }()
+	client, err := newRegistryClient(log.NewMockLog(), "123456789012.dkr.ecr.eu-west-1.amazonaws.com/team/pkg", "")
+	assert.NoError(t, err)
+
+	err = client.authorize(context.Background(), `Basic realm="https://123456789012.dkr.ecr.eu-west-1.amazonaws.com/",service="ecr.amazonaws.com"`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", mock.region)
+	assert.Equal(t, "Basic QVdTOnBhc3N3b3Jk", client.authorization)
+}
+
+func TestNewRegistryClientInvalidReference(t *testing.T) {
+	for _, reference := range []string{"", "registry", "registry/", "/repository"} {
+		_, err := newRegistryClient(log.NewMockLog(), reference, "")
+		assert.Error(t, err, reference)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, parameters := parseChallenge(`Bearer realm="https://public.ecr.aws/token/",service="public.ecr.aws",scope="aws"`)
+
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{"realm": "https://public.ecr.aws/token/", "service": "public.ecr.aws", "scope": "aws"}, parameters)
+}
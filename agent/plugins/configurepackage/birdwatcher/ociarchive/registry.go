@@ -0,0 +1,329 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ociarchive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
+)
+
+const (
+	// manifestMaxSize bounds the memory used by a manifest or a config blob
+	manifestMaxSize = 4 * 1024 * 1024
+
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// ecrRegistryPattern matches the private ECR registries, its group 1 is the region
+var ecrRegistryPattern = regexp.MustCompile(`^\d{12}\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// ociDescriptor references a blob of a repository
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ociManifest is an OCI image manifest
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// registryClient pulls the manifests and blobs of a repository with the OCI distribution api
+type registryClient struct {
+	log           log.T
+	scheme        string
+	registry      string
+	repository    string
+	blobDirectory string
+	client        *http.Client
+	authorization string
+}
+
+// newRegistryClient returns the client of the repository of a package reference registry/repository
+func newRegistryClient(log log.T, reference string, blobDirectory string) (*registryClient, error) {
+	slash := strings.Index(reference, "/")
+	if slash <= 0 || slash == len(reference)-1 {
+		return nil, fmt.Errorf("%v is not a repository reference, expected registry/repository", reference)
+	}
+	return &registryClient{
+		log:           log,
+		scheme:        "https",
+		registry:      reference[:slash],
+		repository:    strings.Trim(reference[slash+1:], "/"),
+		blobDirectory: blobDirectory,
+		client: &http.Client{
+			// registries are artifact servers, trusted independently of the service endpoints
+			Transport: network.NewArtifactTransport(network.ArtifactEndpoint),
+			// blobs are usually redirected to a presigned storage url, which is returned instead of followed
+			CheckRedirect: func(r *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// getManifest returns the manifest of a tag or digest
+func (c *registryClient) getManifest(ctx context.Context, reference string) (*ociManifest, error) {
+	response, err := c.get(ctx, "manifests/"+reference, ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get manifest %v of %v: %v", reference, c.repository, response.Status)
+	}
+
+	var manifest ociManifest
+	if err = json.NewDecoder(io.LimitReader(response.Body, manifestMaxSize)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %v of %v: %v", reference, c.repository, err)
+	}
+	if manifest.SchemaVersion != 2 {
+		return nil, fmt.Errorf("manifest %v of %v has unsupported schema version %v", reference, c.repository, manifest.SchemaVersion)
+	}
+	return &manifest, nil
+}
+
+// getBlob returns the content of a small blob, such as a config, after verifying its digest
+func (c *registryClient) getBlob(ctx context.Context, digest string) ([]byte, error) {
+	response, err := c.followBlob(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, manifestMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %v: %v", digest, err)
+	}
+	if err = verifyDigest(digest, sha256.Sum256(content)); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// blobLocation returns a url the blob can be downloaded from without authorization. A blob redirected to a
+// storage url is downloaded from there, a blob served by the registry is saved in the blob directory first.
+func (c *registryClient) blobLocation(ctx context.Context, digest string) (string, error) {
+	response, err := c.get(ctx, "blobs/"+digest, "")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if isRedirect(response.StatusCode) {
+		location, err := response.Location()
+		if err != nil {
+			return "", fmt.Errorf("invalid redirect of blob %v: %v", digest, err)
+		}
+		return location.String(), nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get blob %v: %v", digest, response.Status)
+	}
+	return c.saveBlob(digest, response.Body)
+}
+
+// saveBlob writes the blob to the blob directory and returns its file url
+func (c *registryClient) saveBlob(digest string, content io.Reader) (string, error) {
+	algorithm, encoded, _ := strings.Cut(digest, ":")
+	if algorithm != "sha256" || encoded == "" || strings.ContainsAny(encoded, `/\.`) {
+		return "", fmt.Errorf("unsupported digest %v", digest)
+	}
+	if err := fileutil.MakeDirs(c.blobDirectory); err != nil {
+		return "", err
+	}
+	path := filepath.Join(c.blobDirectory, encoded)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), throttle.NewDownloadReader(content))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	if err == nil {
+		err = verifyDigest(digest, sum)
+	}
+	if err != nil {
+		fileutil.DeleteFile(path)
+		return "", fmt.Errorf("failed to save blob %v: %v", digest, err)
+	}
+
+	fileURL := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if !strings.HasPrefix(fileURL.Path, "/") {
+		fileURL.Path = "/" + fileURL.Path
+	}
+	return fileURL.String(), nil
+}
+
+// followBlob returns the response of the blob, following a redirect to its storage url
+func (c *registryClient) followBlob(ctx context.Context, digest string) (*http.Response, error) {
+	response, err := c.get(ctx, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	if isRedirect(response.StatusCode) {
+		response.Body.Close()
+		location, err := response.Location()
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect of blob %v: %v", digest, err)
+		}
+		// presigned urls must not receive the registry authorization
+		request, err := http.NewRequest("GET", location.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if response, err = c.client.Do(request.WithContext(ctx)); err != nil {
+			return nil, err
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("failed to get blob %v: %v", digest, response.Status)
+	}
+	return response, nil
+}
+
+// get requests a path of the repository, authorizing and retrying once when the registry asks for it
+func (c *registryClient) get(ctx context.Context, path string, accept string) (*http.Response, error) {
+	requestURL := fmt.Sprintf("%v://%v/v2/%v/%v", c.scheme, c.registry, c.repository, path)
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequest("GET", requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			request.Header.Set("Accept", accept)
+		}
+		if c.authorization != "" {
+			request.Header.Set("Authorization", c.authorization)
+		}
+		response, err := c.client.Do(request.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return response, nil
+		}
+		challenge := response.Header.Get("Www-Authenticate")
+		response.Body.Close()
+		if err = c.authorize(ctx, challenge); err != nil {
+			return nil, fmt.Errorf("failed to authorize with %v: %v", c.registry, err)
+		}
+	}
+}
+
+// authorize gets the credentials of the registry, private ECR registries are authorized with the instance role
+// and other registries with the anonymous token of their bearer challenge
+func (c *registryClient) authorize(ctx context.Context, challenge string) error {
+	if match := ecrRegistryPattern.FindStringSubmatch(c.registry); match != nil {
+		token, err := registrydep.EcrAuthorizationToken(ctx, c.log, match[1])
+		if err != nil {
+			return err
+		}
+		c.authorization = "Basic " + token
+		return nil
+	}
+
+	scheme, parameters := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") || parameters["realm"] == "" {
+		return fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	tokenURL, err := url.Parse(parameters["realm"])
+	if err != nil {
+		return err
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if parameters[key] != "" {
+			query.Set(key, parameters[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	request, err := http.NewRequest("GET", tokenURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed: %v", response.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(io.LimitReader(response.Body, manifestMaxSize)).Decode(&token); err != nil {
+		return err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return fmt.Errorf("no token returned by %v", tokenURL.Host)
+	}
+	c.authorization = "Bearer " + token.Token
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header such as Bearer realm="https://host/token",service="host"
+func parseChallenge(challenge string) (scheme string, parameters map[string]string) {
+	parameters = make(map[string]string)
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	for _, match := range regexp.MustCompile(`(\w+)="([^"]*)"`).FindAllStringSubmatch(rest, -1) {
+		parameters[strings.ToLower(match[1])] = match[2]
+	}
+	return scheme, parameters
+}
+
+// verifyDigest compares the sha256 digest of a blob with its expected digest
+func verifyDigest(digest string, sum [sha256.Size]byte) error {
+	if digest != "sha256:"+hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("content does not match digest %v", digest)
+	}
+	return nil
+}
+
+func isRedirect(statusCode int) bool {
+	return statusCode == http.StatusMovedPermanently || statusCode == http.StatusFound ||
+		statusCode == http.StatusSeeOther || statusCode == http.StatusTemporaryRedirect || statusCode == http.StatusPermanentRedirect
+}
@@ -0,0 +1,45 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package s3archive
+
+import (
+	"context"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+)
+
+// dependency on S3 and downloaded artifacts
+type networkDep interface {
+	BucketRegion(log log.T, bucket string) string
+	ListS3Folders(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []string, err error)
+	Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error)
+}
+
+type networkDepImp struct{}
+
+var networkdep networkDep = &networkDepImp{}
+
+func (networkDepImp) BucketRegion(log log.T, bucket string) string {
+	return s3util.GetBucketRegion(log, bucket, s3util.HttpProviderImpl{})
+}
+
+func (networkDepImp) ListS3Folders(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []string, err error) {
+	return artifact.ListS3Folders(log, amazonS3URL)
+}
+
+func (networkDepImp) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	return artifact.DownloadWithContext(ctx, log, input)
+}
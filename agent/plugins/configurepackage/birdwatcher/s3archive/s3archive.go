@@ -0,0 +1,180 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package s3archive contains the struct that is called when the package is stored in a customer owned s3 bucket
+package s3archive
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
+)
+
+const (
+	// PackageNamePrefix is the scheme of package names stored in s3, such as s3://bucket/prefix/package
+	PackageNamePrefix = "s3://"
+
+	// ManifestFileName is the manifest of a package version, stored with its files in
+	// s3://bucket/prefix/package/<version>/
+	ManifestFileName = "manifest.json"
+)
+
+type PackageArchive struct {
+	log         log.T
+	archiveType string
+	packageName string
+	region      string
+}
+
+// IsS3PackageName returns true if the package name is the s3 url of a package
+func IsS3PackageName(packageName string) bool {
+	return strings.HasPrefix(packageName, PackageNamePrefix)
+}
+
+// New is a constructor for PackageArchive struct
+func New(log log.T) archive.IPackageArchive {
+	return &PackageArchive{
+		log:         log,
+		archiveType: archive.PackageArchiveS3,
+	}
+}
+
+// Name of archive type
+func (sa *PackageArchive) Name() string {
+	return sa.archiveType
+}
+
+func (sa *PackageArchive) GetResourceVersion(packageName string, packageVersion string) (name string, version string) {
+	version = packageVersion
+	if packageservice.IsLatest(packageVersion) {
+		version = packageservice.Latest
+	}
+
+	return packageName, version
+}
+
+// DownloadArchiveInfo downloads the manifest of the version of the package, latest is the highest version in the bucket
+func (sa *PackageArchive) DownloadArchiveInfo(ctx context.Context, packageName string, version string) (string, error) {
+	packageURL, err := sa.packageURL(packageName)
+	if err != nil {
+		return "", err
+	}
+	if packageservice.IsLatest(version) {
+		if version, err = sa.latestVersion(packageURL); err != nil {
+			return "", err
+		}
+	}
+
+	manifestURL, err := sa.objectURL(packageURL, version, ManifestFileName)
+	if err != nil {
+		return "", err
+	}
+	output, err := networkdep.Download(ctx, sa.log, artifact.DownloadInput{SourceURL: manifestURL})
+	if err != nil {
+		return "", fmt.Errorf("failed to download the manifest of %v %v: %v", packageName, version, err)
+	}
+	manifest, err := ioutil.ReadFile(output.LocalFilePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the manifest of %v %v: %v", packageName, version, err)
+	}
+	sa.packageName = packageName
+	return string(manifest), nil
+}
+
+// GetFileDownloadLocation returns the url of the file in the version prefix of the package,
+// the download location of the manifest is not used
+func (sa *PackageArchive) GetFileDownloadLocation(ctx context.Context, file *archive.File, packageName string, version string) (string, error) {
+	if file == nil {
+		return "", fmt.Errorf("file is empty")
+	}
+	packageURL, err := sa.packageURL(packageName)
+	if err != nil {
+		return "", err
+	}
+	return sa.objectURL(packageURL, version, file.Name)
+}
+
+// GetResourceArn returns the s3 url of the package
+func (sa *PackageArchive) GetResourceArn(manifest *birdwatcher.Manifest) string {
+	return sa.packageName
+}
+
+// packageURL parses the bucket and key prefix of a package name, the region is the region of the bucket
+func (sa *PackageArchive) packageURL(packageName string) (s3util.AmazonS3URL, error) {
+	parsed, err := url.Parse(packageName)
+	if err != nil || !IsS3PackageName(packageName) {
+		return s3util.AmazonS3URL{}, fmt.Errorf("%v is not the s3 url of a package", packageName)
+	}
+	key := strings.Trim(parsed.Path, "/")
+	if parsed.Host == "" || key == "" {
+		return s3util.AmazonS3URL{}, fmt.Errorf("%v is not the s3 url of a package, expected s3://bucket/prefix/package", packageName)
+	}
+	if sa.region == "" {
+		sa.region = networkdep.BucketRegion(sa.log, parsed.Host)
+	}
+	return s3util.AmazonS3URL{
+		IsValidS3URI: true,
+		IsPathStyle:  true,
+		Bucket:       parsed.Host,
+		Key:          key,
+		Region:       sa.region,
+	}, nil
+}
+
+// objectURL returns the path style url of an object of a package version, the objects are downloaded
+// through the s3 api with the configured S3 endpoint (such as a VPC endpoint)
+func (sa *PackageArchive) objectURL(packageURL s3util.AmazonS3URL, version string, fileName string) (string, error) {
+	for _, element := range []string{version, fileName} {
+		if element == "" || element == "." || element == ".." || strings.Contains(element, "/") {
+			return "", fmt.Errorf("%q is not a valid name in the package", element)
+		}
+	}
+	host := fmt.Sprintf("s3.%v.amazonaws.com", packageURL.Region)
+	if strings.HasPrefix(packageURL.Region, "cn-") {
+		host += ".cn"
+	}
+	objectURL := url.URL{
+		Scheme: "https",
+		Host:   host,
+		Path:   "/" + strings.Join([]string{packageURL.Bucket, packageURL.Key, version, fileName}, "/"),
+	}
+	return objectURL.String(), nil
+}
+
+// latestVersion returns the highest version prefix of the package
+func (sa *PackageArchive) latestVersion(packageURL s3util.AmazonS3URL) (string, error) {
+	versions, err := networkdep.ListS3Folders(sa.log, packageURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to list the versions of the package: %v", err)
+	}
+	latest := ""
+	for _, version := range versions {
+		if version != "" && (latest == "" || versionutil.Compare(version, latest, false) > 0) {
+			latest = version
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no version of the package is stored in s3://%v/%v", packageURL.Bucket, packageURL.Key)
+	}
+	return latest, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package s3archive
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/archive"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testPackageName = "s3://my-bucket/prefix/pkg"
+
+var testPackageURL = s3util.AmazonS3URL{IsValidS3URI: true, IsPathStyle: true, Bucket: "my-bucket", Key: "prefix/pkg", Region: "eu-west-1"}
+
+type networkMock struct {
+	mock.Mock
+}
+
+func (m *networkMock) BucketRegion(log log.T, bucket string) string {
+	args := m.Called(log, bucket)
+	return args.String(0)
+}
+
+func (m *networkMock) ListS3Folders(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []string, err error) {
+	args := m.Called(log, amazonS3URL)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *networkMock) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	args := m.Called(ctx, log, input)
+	return args.Get(0).(artifact.DownloadOutput), args.Error(1)
+}
+
+// setNetworkMock replaces the network dependency until the returned function is called
+func setNetworkMock(m *networkMock) func() {
+	previous := networkdep
+	networkdep = m
+	return func() { networkdep = previous }
+}
+
+// writeManifest writes a downloaded manifest
+func writeManifest(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "s3archive")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "manifest")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestIsS3PackageName(t *testing.T) {
+	assert.True(t, IsS3PackageName(testPackageName))
+	assert.False(t, IsS3PackageName("PVDriver"))
+	assert.False(t, IsS3PackageName("arn:aws:ssm:us-west-1:1234567890:document/package"))
+}
+
+func TestArchiveName(t *testing.T) {
+	testArchive := New(log.NewMockLog())
+
+	assert.Equal(t, archive.PackageArchiveS3, testArchive.Name())
+}
+
+func TestDownloadArchiveInfo(t *testing.T) {
+	logger := log.NewMockLog()
+	manifestPath, cleanup := writeManifest(t, `{"version":"1.10.0"}`)
+	defer cleanup()
+	network := &networkMock{}
+	defer setNetworkMock(network)()
+	network.On("BucketRegion", logger, "my-bucket").Return("eu-west-1").Once()
+	network.On("ListS3Folders", logger, testPackageURL).Return([]string{"1.2.0", "1.10.0", "1.9.3"}, nil).Once()
+	network.On("Download", mock.Anything, logger, artifact.DownloadInput{SourceURL: "https://s3.eu-west-1.amazonaws.com/my-bucket/prefix/pkg/1.10.0/manifest.json"}).
+		Return(artifact.DownloadOutput{LocalFilePath: manifestPath}, nil).Once()
+	testArchive := New(logger)
+
+	manifest, err := testArchive.DownloadArchiveInfo(context.Background(), testPackageName, "latest")
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":"1.10.0"}`, manifest)
+	assert.Equal(t, testPackageName, testArchive.GetResourceArn(&birdwatcher.Manifest{PackageArn: "arn"}))
+	network.AssertExpectations(t)
+}
+
+func TestDownloadArchiveInfoErrors(t *testing.T) {
+	logger := log.NewMockLog()
+	network := &networkMock{}
+	defer setNetworkMock(network)()
+	network.On("BucketRegion", logger, "my-bucket").Return("eu-west-1")
+	network.On("ListS3Folders", logger, testPackageURL).Return([]string{}, nil)
+	network.On("Download", mock.Anything, logger, mock.Anything).Return(artifact.DownloadOutput{}, errors.New("AccessDenied"))
+	testArchive := New(logger)
+
+	_, err := testArchive.DownloadArchiveInfo(context.Background(), testPackageName, "latest")
+	assert.Error(t, err)
+	_, err = testArchive.DownloadArchiveInfo(context.Background(), testPackageName, "1.0.0")
+	assert.Error(t, err)
+	_, err = testArchive.DownloadArchiveInfo(context.Background(), testPackageName, "../1.0.0")
+	assert.Error(t, err)
+	_, err = testArchive.DownloadArchiveInfo(context.Background(), "s3://my-bucket", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestGetFileDownloadLocation(t *testing.T) {
+	logger := log.NewMockLog()
+	network := &networkMock{}
+	defer setNetworkMock(network)()
+	network.On("BucketRegion", logger, "my-bucket").Return("cn-north-1").Once()
+	testArchive := New(logger)
+	file := &archive.File{Name: "pkg.zip", Info: birdwatcher.FileInfo{DownloadLocation: "https://example.com/pkg.zip"}}
+
+	location, err := testArchive.GetFileDownloadLocation(context.Background(), file, testPackageName, "1.0.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://s3.cn-north-1.amazonaws.com.cn/my-bucket/prefix/pkg/1.0.0/pkg.zip", location)
+	_, err = testArchive.GetFileDownloadLocation(context.Background(), nil, testPackageName, "1.0.0")
+	assert.Error(t, err)
+	network.AssertExpectations(t)
+}
@@ -14,16 +14,108 @@
 
 package birdwatcher
 
-// FileInfo contains data for one SSM package
+// FileInfo contains data for one SSM package, Checksums are keyed by algorithm
+// (sha256, sha384, sha512 or md5) and the file is accepted if any of them validates.
+// ArchiveFormat is "zip", "tar.gz" or "tar.zst", when empty the format is detected from the content of the file.
+// RegionalDownloadLocations are copies of the file keyed by region, such as in-region buckets, the copy of the region
+// of the instance is preferred, then the copies of FallbackRegions in order, then DownloadLocation.
+// ExtractedFiles are the sha256 checksums of the files extracted from the archive, keyed by their path in the archive
+// with / separators, the files not listed are not verified.
 type FileInfo struct {
-	Checksums        map[string]string `json:"checksums"`
-	DownloadLocation string            `json:"downloadLocation"`
-	Size             int               `json:"size"`
+	Checksums                 map[string]string `json:"checksums"`
+	DownloadLocation          string            `json:"downloadLocation"`
+	RegionalDownloadLocations map[string]string `json:"regionalDownloadLocations,omitempty"`
+	FallbackRegions           []string          `json:"fallbackRegions,omitempty"`
+	Size                      int               `json:"size"`
+	Signature                 *FileSignature    `json:"signature,omitempty"`
+	ArchiveFormat             string            `json:"archiveFormat,omitempty"`
+	ExtractedFiles            map[string]string `json:"extractedFiles,omitempty"`
 }
 
-// PackageInfo contains references to Files matching the current platform/version/arch
+// DownloadLocations returns the locations of the file in order of preference for an instance of the region,
+// the copies of the regions that are neither the region of the instance nor fallback regions are not used
+func (info *FileInfo) DownloadLocations(region string) []string {
+	var locations []string
+	seen := make(map[string]bool)
+	add := func(location string) {
+		if location != "" && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+	if region != "" {
+		add(info.RegionalDownloadLocations[region])
+	}
+	for _, fallback := range info.FallbackRegions {
+		add(info.RegionalDownloadLocations[fallback])
+	}
+	add(info.DownloadLocation)
+	return locations
+}
+
+// FileSignature references the detached signature of a file, the signature is itself a file of the manifest.
+// Format is "gpg" for an OpenPGP signature or "sigstore" for a Sigstore bundle.
+type FileSignature struct {
+	Format string `json:"format"`
+	File   string `json:"file"`
+}
+
+// PackageInfo contains references to Files matching the current platform/version/arch, Attachments are
+// files copied into the package directory next to the content extracted from the file, such as large payloads.
+// Deltas are keyed by the previous version they patch into the file. InstallParameters override the install
+// parameters of the manifest on the platform.
 type PackageInfo struct {
-	FileName string `json:"file"`
+	FileName          string            `json:"file"`
+	Attachments       []string          `json:"attachments,omitempty"`
+	Prerequisites     *Prerequisites    `json:"prerequisites,omitempty"`
+	Deltas            map[string]*Delta `json:"deltas,omitempty"`
+	InstallParameters map[string]string `json:"installParameters,omitempty"`
+}
+
+// Delta is a file of the manifest patching the artifact of a previous version into the artifact of the package,
+// it applies when the cached artifact of the previous version matches BaseChecksums. Format defaults to bsdiff,
+// deltas of formats the agent does not support are ignored.
+type Delta struct {
+	FileName      string            `json:"file"`
+	Format        string            `json:"format,omitempty"`
+	BaseChecksums map[string]string `json:"baseChecksums"`
+}
+
+// Prerequisites are the requirements an instance must meet before the package is downloaded,
+// a requirement is not checked when it is not set
+type Prerequisites struct {
+	MinMemoryMB      int64  `json:"minMemoryMB,omitempty"`
+	MinKernelVersion string `json:"minKernelVersion,omitempty"`
+}
+
+// Dependency is a package that is installed before the package depending on it, Version is a
+// constraint such as 1.2.0, >=1.2.0 or >=1.2.0,<2.0.0 and any version is accepted when it is empty
+type Dependency struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Hook is a list of commands the agent runs around an action of the package, it fails unless the
+// commands exit with one of ExpectedExitCodes, 0 when none are declared
+type Hook struct {
+	Commands          []string `json:"commands"`
+	TimeoutSeconds    int      `json:"timeoutSeconds,omitempty"`
+	ExpectedExitCodes []int    `json:"expectedExitCodes,omitempty"`
+}
+
+// Hooks are the hooks the agent runs before and after installing the package and before uninstalling it
+type Hooks struct {
+	PreInstall   *Hook `json:"preinstall,omitempty"`
+	PostInstall  *Hook `json:"postinstall,omitempty"`
+	PreUninstall *Hook `json:"preuninstall,omitempty"`
+}
+
+// Channel is the version a manifest advertises to the instances following a rollout channel, such as beta or
+// canary. RolloutPercent limits the version to that share of the instances, the others install the version of
+// the manifest, and the version is offered to all of them when it is not set
+type Channel struct {
+	Version        string `json:"version"`
+	RolloutPercent *int   `json:"rolloutPercent,omitempty"`
 }
 
 // Manifest contains references to all SSM packages for a given agent version
@@ -33,6 +125,16 @@ type Manifest struct {
 	Version       string `json:"version"`
 
 	// platform -> version -> arch -> file
-	Packages map[string]map[string]map[string]*PackageInfo `json:"packages"`
-	Files    map[string]*FileInfo                          `json:"files"`
+	Packages     map[string]map[string]map[string]*PackageInfo `json:"packages"`
+	Files        map[string]*FileInfo                          `json:"files"`
+	Dependencies []Dependency                                  `json:"dependencies,omitempty"`
+	Hooks        *Hooks                                        `json:"hooks,omitempty"`
+	// Channels are the versions the latest manifest advertises per rollout channel
+	Channels map[string]*Channel `json:"channels,omitempty"`
+	// ActionTimeouts bound the install, uninstall and validate actions of the package in seconds, the process
+	// tree of an action running longer is killed
+	ActionTimeouts map[string]int `json:"actionTimeouts,omitempty"`
+	// InstallParameters are the default parameters of the scripts of the package on every platform, such as
+	// serviceName, installPath or flags, the packages of a platform override them
+	InstallParameters map[string]string `json:"installParameters,omitempty"`
 }
@@ -16,10 +16,12 @@
 package configurepackage
 
 import (
+	gocontext "context"
 	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -28,13 +30,20 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/documentarchive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/ociarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/s3archive"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/integrity"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/ssminstaller"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/ssms3"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/amazon-ssm-agent/agent/telemetry"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
 
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
@@ -44,10 +53,23 @@ const (
 	InstallAction = "Install"
 	// UninstallAction represents the json command to uninstall package
 	UninstallAction = "Uninstall"
+	// EnsureAction represents the json command to install package only when the desired version is not installed
+	EnsureAction = "Ensure"
+)
+
+const (
+	// manifestDownloadTimeout bounds the download of the package manifest
+	manifestDownloadTimeout = 2 * time.Minute
+	// reportResultTimeout bounds the report of the result to the package service
+	reportResultTimeout = 1 * time.Minute
 )
 
 const resourceNotFoundException = "ResourceNotFoundException"
 const birdwatcherVersionPattern = "[A-Za-z0-9.]+"
+
+// channelPattern is the format of the names of rollout channels
+var channelPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
 const documentArnPattern = "^arn:[a-z0-9][-.a-z0-9]{0,62}:[a-z0-9][-.a-z0-9]{0,62}:([a-z0-9][-.a-z0-9]{0,62})?:([a-z0-9][-.a-z0-9]{0,62})?:document\\/[a-zA-Z][a-zA-Z0-9-\\_]{0,39}$"
 
 // Plugin is the type for the configurepackage plugin.
@@ -66,6 +88,41 @@ type ConfigurePackagePluginInput struct {
 	Action     string `json:"action"`
 	Source     string `json:"source"`
 	Repository string `json:"repository"`
+	// Force installs a version older than the installed one even when rollbacks are prevented
+	Force bool `json:"force"`
+	// DryRun reports what the action would do without downloading the package or running its scripts
+	DryRun bool `json:"dryRun"`
+	// VersionSelection selects the version of a package document installed when no version is given, either
+	// the default version of the document or its latest version
+	VersionSelection string `json:"versionSelection"`
+	// VersionLabel installs the version of a package document labeled by its VersionLabel tags when no
+	// version is given, publishers promote a version by moving the label to it
+	VersionLabel string `json:"versionLabel"`
+	// Channel is the rollout channel, such as beta or canary, whose version is installed when no version is given,
+	// it overrides the channel of the agent configuration
+	Channel string `json:"channel"`
+	// ResultAttributes are reported with the result of the action, such as the environment or the team the
+	// document parameters identify
+	ResultAttributes map[string]string `json:"resultAttributes"`
+	// ActionTimeoutSeconds bounds each install, uninstall and validate action of the packages, it overrides the
+	// action timeouts of the manifest
+	ActionTimeoutSeconds int `json:"actionTimeoutSeconds"`
+	// InstallParameters are passed to the scripts of the packages, they override the install parameters the
+	// manifest declares for the platform of the instance
+	InstallParameters map[string]string `json:"installParameters"`
+	// Packages are run in order in place of the package given by name, version and action, the other properties
+	// apply to each of them
+	Packages []ConfigurePackageEntry `json:"packages"`
+
+	// simulation downloads and scans the artifact of a dry run install, it is set when the document runs in simulation
+	simulation bool
+}
+
+// ConfigurePackageEntry is a package of a batch, an empty action is the action of the input
+type ConfigurePackageEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Action  string `json:"action"`
 }
 
 // NewPlugin returns a new instance of the plugin.
@@ -82,6 +139,7 @@ func NewPlugin() (*Plugin, error) {
 
 // prepareConfigurePackage ensures the packages are present with the right version for the scenario requested and returns their installers
 func prepareConfigurePackage(
+	ctx gocontext.Context,
 	tracer trace.Tracer,
 	config contracts.Configuration,
 	repository localpackages.Repository,
@@ -90,6 +148,7 @@ func prepareConfigurePackage(
 	packageArn string,
 	version string,
 	isSameAsCache bool,
+	antiRollback bool,
 	output contracts.PluginOutputter) (inst installer.Installer, uninst installer.Installer, installState localpackages.InstallState, installedVersion string) {
 
 	prepareTrace := tracer.BeginSection(fmt.Sprintf("prepare %s", input.Action))
@@ -102,10 +161,19 @@ func prepareConfigurePackage(
 		installedVersion, installState = getVersionToInstall(tracer, repository, packageArn)
 		trace.AppendDebugf("installed: %v in state %v, to install: %v", installedVersion, installState, version).End()
 
-		// ensure manifest file and package
+		// refuse to downgrade packages protected against rollbacks
 		var err error
+		trace = tracer.BeginSection("check version is not a rollback")
+		if err = checkRollback(tracer, repository, input, packageArn, installedVersion, installState, version, antiRollback); err != nil {
+			trace.WithError(err).End()
+			output.MarkAsFailed(nil, nil)
+			return
+		}
+		trace.End()
+
+		// ensure manifest file and package
 		trace = tracer.BeginSection("ensure package is locally available")
-		inst, err = ensurePackage(tracer, repository, packageService, packageArn, version, isSameAsCache, config)
+		inst, err = ensurePackage(ctx, tracer, repository, packageService, packageArn, version, isSameAsCache, config)
 		if err != nil {
 			trace.WithError(err).End()
 			output.MarkAsFailed(nil, nil)
@@ -119,7 +187,7 @@ func prepareConfigurePackage(
 		// * Return success if the package is already installed
 		trace = tracer.BeginSection("ensure old package is locally available")
 		if !(installedVersion == "" || installState == localpackages.None) && (installedVersion != version || !isSameAsCache) {
-			uninst, err = ensurePackage(tracer, repository, packageService, packageArn, installedVersion, isSameAsCache, config)
+			uninst, err = ensurePackage(ctx, tracer, repository, packageService, packageArn, installedVersion, isSameAsCache, config)
 			if err != nil {
 				trace.WithError(err)
 			}
@@ -153,7 +221,7 @@ func prepareConfigurePackage(
 
 		// ensure manifest file and package
 		trace = tracer.BeginSection("ensure package is locally available")
-		uninst, err = ensurePackage(tracer, repository, packageService, packageArn, installedVersion, isSameAsCache, config)
+		uninst, err = ensurePackage(ctx, tracer, repository, packageService, packageArn, installedVersion, isSameAsCache, config)
 		if err != nil {
 			trace.WithError(err)
 			output.MarkAsFailed(nil, nil)
@@ -171,6 +239,7 @@ func prepareConfigurePackage(
 
 // ensurePackage validates local copy of the manifest and package and downloads if needed, returning the installer
 func ensurePackage(
+	ctx gocontext.Context,
 	tracer trace.Tracer,
 	repository localpackages.Repository,
 	packageService packageservice.PackageService,
@@ -181,36 +250,64 @@ func ensurePackage(
 
 	pkgTrace := tracer.BeginSection("ensure package is available locally")
 
-	currentState, currentVersion := repository.GetInstallState(tracer, packageName)
-	if err := repository.ValidatePackage(tracer, packageName, version); err != nil ||
-		(currentVersion == version && (currentState == localpackages.Failed || !isSameAsCache)) {
-		pkgTrace.AppendDebugf("Current %v Target %v State %v", currentVersion, version, currentState).End()
+	if needsRefresh(tracer, repository, packageName, version, isSameAsCache) {
 		pkgTrace.AppendDebugf("Refreshing package content for %v %v", packageName, version).End()
-		if err = repository.RefreshPackage(tracer, packageName, version, packageService.PackageServiceName(), buildDownloadDelegate(tracer, packageService, packageName, version)); err != nil {
+		var verifiedFiles map[string]string
+		downloader := buildDownloadDelegate(ctx, tracer, packageService, packageName, version, func(files map[string]string) { verifiedFiles = files })
+		if err := repository.RefreshPackage(tracer, packageName, version, packageService.PackageServiceName(), downloader); err != nil {
 			pkgTrace.WithError(err).End()
 			return nil, err
 		}
-		if err = repository.ValidatePackage(tracer, packageName, version); err != nil {
+		if err := repository.ValidatePackage(tracer, packageName, version); err != nil {
 			// TODO: Remove from repository?
 			pkgTrace.WithError(err).End()
 			return nil, err
 		}
+		if verifiedFiles != nil {
+			if err := repository.SetVerifiedFiles(tracer, packageName, version, verifiedFiles); err != nil {
+				pkgTrace.WithError(err).End()
+				return nil, err
+			}
+		}
 	}
 
 	pkgTrace.End()
 	return repository.GetInstaller(tracer, config, packageName, version), nil
 }
 
-// buildDownloadDelegate constructs the delegate used by the repository to download a package from the service
-func buildDownloadDelegate(tracer trace.Tracer, packageService packageservice.PackageService, packageName string, version string) func(trace.Tracer, string) error {
+// needsRefresh returns true if the package version is not valid in the repository, or if it is the current version
+// and it failed or its manifest changed, in which case its content is downloaded again
+func needsRefresh(tracer trace.Tracer, repository localpackages.Repository, packageName string, version string, isSameAsCache bool) bool {
+	currentState, currentVersion := repository.GetInstallState(tracer, packageName)
+	tracer.CurrentTrace().AppendDebugf("Current %v Target %v State %v", currentVersion, version, currentState)
+	return repository.ValidatePackage(tracer, packageName, version) != nil ||
+		(currentVersion == version && (currentState == localpackages.Failed || !isSameAsCache))
+}
+
+// buildDownloadDelegate constructs the delegate used by the repository to download a package from the service,
+// onVerified receives the checksums of the extracted files once they match the manifest
+func buildDownloadDelegate(ctx gocontext.Context, tracer trace.Tracer, packageService packageservice.PackageService, packageName string, version string, onVerified func(map[string]string)) func(trace.Tracer, string) error {
 	return func(tracer trace.Tracer, targetDirectory string) error {
 		trace := tracer.BeginSection("download artifact")
-		filePath, err := packageService.DownloadArtifact(tracer, packageName, version)
+		span := telemetry.StartSpan("download package artifact", nil, map[string]string{
+			"ssm.package.name":    packageName,
+			"ssm.package.version": version,
+		})
+		filePath, err := packageService.DownloadArtifact(ctx, tracer, packageName, version)
+		span.End(err)
+		recordDownloadTelemetry(packageService.PackageServiceName(), err)
 		if err != nil {
 			trace.WithError(err).End()
 			return err
 		}
 
+		// a rejected artifact is neither extracted nor kept
+		if err = scanArtifact(ctx, tracer, artifactScanners(), packageName, version, filePath); err != nil {
+			filesysdep.RemoveAll(filePath)
+			trace.WithError(err).End()
+			return err
+		}
+
 		// TODO: Consider putting uncompress into the ssminstaller new and not deleting it (since the zip is the repository-validatable artifact)
 		if uncompressErr := filesysdep.Uncompress(filePath, targetDirectory); uncompressErr != nil {
 			trace.WithError(uncompressErr).End()
@@ -224,11 +321,81 @@ func buildDownloadDelegate(tracer trace.Tracer, packageService packageservice.Pa
 			return fmt.Errorf("failed to delete compressed package %v, %v", filePath, cleanupErr.Error())
 		}
 
+		// the extracted files are verified before any script of the package can run
+		if err = verifyExtractedFiles(ctx, tracer, packageService, packageName, version, targetDirectory, onVerified); err != nil {
+			trace.WithError(err).End()
+			return err
+		}
+
+		if err = packageService.DownloadAttachments(ctx, tracer, packageName, version, targetDirectory); err != nil {
+			trace.WithError(err).End()
+			return err
+		}
+
+		// the hooks of the manifest are kept with the package, they run long after the manifest was downloaded
+		hooks, err := packageService.GetHooks(ctx, tracer, packageName, version)
+		if err == nil {
+			err = ssminstaller.WriteHooks(targetDirectory, hooks)
+		}
+		if err != nil {
+			trace.WithError(err).End()
+			return fmt.Errorf("failed to write the hooks of package %v: %v", packageName, err)
+		}
+
 		trace.End()
 		return nil
 	}
 }
 
+// verifyExtractedFiles compares the files extracted from the artifact to the checksums the manifest declares for them,
+// a mismatch fails the download as an integrity failure
+func verifyExtractedFiles(ctx gocontext.Context, tracer trace.Tracer, packageService packageservice.PackageService, packageName string, version string, targetDirectory string, onVerified func(map[string]string)) error {
+	artifact, err := packageService.DescribeArtifact(ctx, tracer, packageName, version)
+	if err != nil {
+		return fmt.Errorf("failed to describe the artifact of package %v: %v", packageName, err)
+	}
+	if len(artifact.ExtractedFiles) == 0 {
+		return nil
+	}
+	verified, err := integrity.VerifyExtractedFiles(targetDirectory, artifact.ExtractedFiles)
+	if err != nil {
+		if _, mismatch := err.(*integrity.ExtractedFilesError); mismatch {
+			return contracts.NewCodedError(contracts.ErrorCodeIntegrityFailure, fmt.Errorf("package %v %v: %v", packageName, version, err))
+		}
+		return err
+	}
+	tracer.CurrentTrace().AppendInfof("Verified %v extracted files of package %v", len(verified), packageName)
+	if onVerified != nil {
+		onVerified(verified)
+	}
+	return nil
+}
+
+// firstTrace returns the trace which started first, the start times of traces timed by the same monotonic clock are
+// compared on that clock since the wall clock may have changed in between
+func firstTrace(traces []*trace.Trace) *trace.Trace {
+	first := traces[0]
+	for _, t := range traces[1:] {
+		if t.Anchor != 0 && t.Anchor == first.Anchor {
+			if t.MonotonicStart < first.MonotonicStart {
+				first = t
+			}
+		} else if t.Start < first.Start {
+			first = t
+		}
+	}
+	return first
+}
+
+// recordDownloadTelemetry counts the artifact downloads of a package service by outcome
+func recordDownloadTelemetry(serviceName string, err error) {
+	outcome := "Success"
+	if err != nil {
+		outcome = "Failed"
+	}
+	telemetry.AddCount("ssm.package.downloads", 1, map[string]string{"ssm.package.service": serviceName, "ssm.outcome": outcome})
+}
+
 // getVersionToInstall decides which version to install and whether there is an existing version (that is not in the process of installing)
 func getVersionToInstall(
 	tracer trace.Tracer,
@@ -245,6 +412,35 @@ func getVersionToInstall(
 	return installedVersion, currentState
 }
 
+// checkRollback returns an error if the version to install is older than the installed version and rollbacks are
+// prevented by the agent policy or by the manifest of the installed version, unless the input forces the install
+func checkRollback(
+	tracer trace.Tracer,
+	repository localpackages.Repository,
+	input *ConfigurePackagePluginInput,
+	packageArn string,
+	installedVersion string,
+	installState localpackages.InstallState,
+	version string,
+	antiRollback bool) error {
+
+	if input.Force || installState != localpackages.Installed || installedVersion == "" {
+		return nil
+	}
+	if versionutil.Compare(version, installedVersion, false) >= 0 {
+		return nil
+	}
+	if !antiRollback {
+		// without the agent policy, the installed package decides
+		manifest, err := repository.GetPackageManifest(tracer, packageArn, installedVersion)
+		if err != nil || !manifest.AntiRollback {
+			return nil
+		}
+	}
+	return contracts.NewCodedError(contracts.ErrorCodeInvalidInput,
+		fmt.Errorf("version %v of package %v is older than the installed version %v and rollbacks are prevented, set force to downgrade", version, input.Name, installedVersion))
+}
+
 // getVersionToUninstall decides which version to uninstall
 func getVersionToUninstall(
 	tracer trace.Tracer,
@@ -266,11 +462,11 @@ func parseAndValidateInput(rawPluginInput interface{}) (*ConfigurePackagePluginI
 	var input ConfigurePackagePluginInput
 	var err error
 	if err = jsonutil.Remarshal(rawPluginInput, &input); err != nil {
-		return nil, fmt.Errorf("invalid format in plugin properties %v; \nerror %v", rawPluginInput, err)
+		return nil, contracts.NewCodedError(contracts.ErrorCodeInvalidInput, fmt.Errorf("invalid format in plugin properties %v; \nerror %v", rawPluginInput, err))
 	}
 
 	if valid, err := validateInput(&input); !valid {
-		return nil, fmt.Errorf("invalid input: %v", err)
+		return nil, contracts.NewCodedError(contracts.ErrorCodeInvalidInput, fmt.Errorf("invalid input: %v", err))
 	}
 
 	return &input, nil
@@ -284,10 +480,41 @@ func validateInput(input *ConfigurePackagePluginInput) (valid bool, err error) {
 	}
 
 	// ensure non-empty name
-	if input.Name == "" {
+	if len(input.Packages) > 0 {
+		if err := validatePackages(input); err != nil {
+			return false, err
+		}
+	} else if input.Name == "" {
 		return false, errors.New("empty name field")
 	}
 
+	if !documentarchive.IsValidVersionSelection(input.VersionSelection) {
+		return false, fmt.Errorf("unsupported version selection %v, it must be %v or %v", input.VersionSelection, documentarchive.VersionSelectionDefault, documentarchive.VersionSelectionLatest)
+	}
+
+	input.VersionLabel = strings.TrimSpace(input.VersionLabel)
+	if !documentarchive.IsValidVersionLabel(input.VersionLabel) {
+		return false, fmt.Errorf("invalid version label %v, it must have up to 100 letters, digits, periods, hyphens or underscores", input.VersionLabel)
+	}
+	if input.VersionLabel != "" && input.Version != "" {
+		return false, errors.New("version and versionLabel cannot both be set")
+	}
+
+	input.Channel = strings.ToLower(strings.TrimSpace(input.Channel))
+	if input.Channel != "" && !channelPattern.MatchString(input.Channel) {
+		return false, fmt.Errorf("invalid channel %v, it must have up to 64 letters, digits, periods, hyphens or underscores", input.Channel)
+	}
+
+	if input.ActionTimeoutSeconds != 0 && (input.ActionTimeoutSeconds < packageservice.MinActionTimeoutSeconds || input.ActionTimeoutSeconds > packageservice.MaxActionTimeoutSeconds) {
+		return false, fmt.Errorf("invalid action timeout %v, it must be between %v and %v seconds", input.ActionTimeoutSeconds, packageservice.MinActionTimeoutSeconds, packageservice.MaxActionTimeoutSeconds)
+	}
+
+	for name := range input.InstallParameters {
+		if !packageservice.InstallParameterNamePattern.MatchString(name) {
+			return false, fmt.Errorf("invalid install parameter %v, it must have letters, digits or underscores and start with a letter", name)
+		}
+	}
+
 	// dump any unsupported value for Repository
 	if input.Repository != "beta" && input.Repository != "gamma" {
 		input.Repository = ""
@@ -368,6 +595,30 @@ func checkAlreadyInstalled(
 
 // selectService chooses the implementation of PackageService to use for a given execution of the plugin
 func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, localrepo localpackages.Repository, appCfg *appconfig.SsmagentConfig, birdwatcherFacade facade.BirdwatcherFacade, isDocumentArchive *bool) (packageservice.PackageService, error) {
+	var signing appconfig.ManifestSigningCfg
+	if appCfg != nil {
+		signing = appCfg.Birdwatcher.ManifestSigning
+	}
+	manifestCache := newManifestCache(appCfg)
+
+	if s3archive.IsS3PackageName(input.Name) {
+		tracer.CurrentTrace().AppendInfof("Package %v is stored in s3", input.Name)
+		*isDocumentArchive = false
+		return birdwatcherservice.NewS3Archive(tracer.CurrentTrace().Logger, manifestCache, signing), nil
+	}
+
+	if ociarchive.IsOciPackageName(input.Name) {
+		tracer.CurrentTrace().AppendInfof("Package %v is stored in an OCI registry", input.Name)
+		*isDocumentArchive = false
+		return birdwatcherservice.NewOciArchive(tracer.CurrentTrace().Logger, manifestCache, signing), nil
+	}
+
+	if appCfg != nil && appCfg.Birdwatcher.LocalArchivePath != "" {
+		tracer.CurrentTrace().AppendInfof("Local package archive %v is configured", appCfg.Birdwatcher.LocalArchivePath)
+		*isDocumentArchive = false
+		return birdwatcherservice.NewLocalArchive(manifestCache, appCfg.Birdwatcher.LocalArchivePath, signing), nil
+	}
+
 	region, _ := platform.Region()
 	serviceEndpoint := input.Repository
 	response := &ssm.GetManifestOutput{}
@@ -379,7 +630,7 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 		if regexp.MustCompile(documentArnPattern).MatchString(input.Name) {
 			*isDocumentArchive = true
 			// return a new object of type document
-			return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo), nil
+			return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, manifestCache, signing, input.VersionSelection, input.VersionLabel), nil
 		}
 		if input.Version != "" {
 			// This could happen if there is a typo or if the version matches the document requirement
@@ -408,7 +659,7 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 			if strings.Contains(err.Error(), resourceNotFoundException) {
 				*isDocumentArchive = true
 				// return a new object of type document
-				return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, localrepo), nil
+				return birdwatcherservice.NewDocumentArchive(birdwatcherFacade, manifestCache, signing, input.VersionSelection, input.VersionLabel), nil
 			} else {
 				tracer.CurrentTrace().AppendErrorf("Error returned for GetManifest - %v.", err.Error())
 				return nil, err
@@ -417,7 +668,7 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 
 		*isDocumentArchive = false
 		// return a new object of type birdwatcher
-		return birdwatcherservice.NewBirdwatcherArchive(birdwatcherFacade, localrepo, *response.Manifest), nil
+		return birdwatcherservice.NewBirdwatcherArchive(birdwatcherFacade, manifestCache, *response.Manifest, signing), nil
 
 	}
 
@@ -425,6 +676,24 @@ func selectService(tracer trace.Tracer, input *ConfigurePackagePluginInput, loca
 	return ssms3.New(serviceEndpoint, region), nil
 }
 
+// newManifestCache returns the manifest cache of the configured backend and eviction policy
+func newManifestCache(appCfg *appconfig.SsmagentConfig) packageservice.ManifestCache {
+	cacheCfg := appconfig.DefaultConfig().Birdwatcher.ManifestCache
+	if appCfg != nil {
+		cacheCfg = appCfg.Birdwatcher.ManifestCache
+	}
+
+	var backend packageservice.ManifestCacheBackend
+	if cacheCfg.Backend == appconfig.ManifestCacheBackendMemory {
+		backend = packageservice.NewManifestCacheMemBackend()
+	} else {
+		// manifests that cannot be moved are downloaded again
+		localpackages.MigrateManifestCache(cacheCfg.Directory)
+		backend = localpackages.NewManifestCacheBackend(cacheCfg.Directory)
+	}
+	return packageservice.NewManifestCache(backend, time.Duration(cacheCfg.TtlHours)*time.Hour, cacheCfg.MaxEntries)
+}
+
 // Execute runs the plugin operation and returns output
 func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	p.execute(context, config, cancelFlag, output)
@@ -432,10 +701,30 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 }
 
 func (p *Plugin) execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	context.Log().Info("RunCommand started with configuration ", config)
+	if input, err := parseAndValidateInput(config.Properties); err == nil && len(input.Packages) > 0 {
+		p.executeBatch(context, config, cancelFlag, output, input)
+		return
+	}
+	p.executePackage(context, config, cancelFlag, output, nil)
+}
+
+// executePackage runs the action of a package, the manifest of the package is downloaded unless it is resolved
+func (p *Plugin) executePackage(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler, resolved *resolvedPackage) {
 	log := context.Log()
-	log.Info("RunCommand started with configuration ", config)
 	tracer := trace.NewTracer(log)
 	defer tracer.BeginSection("configurePackage").End()
+	if exporter := newTraceExporter(log, packageTraceCfg()); exporter != nil {
+		tracer.AddExporter(exporter)
+	}
+	// the operation whose traces are exported, dry runs are not exported
+	var operation trace.Operation
+	// the metrics of the facade calls are cumulative since the agent started
+	defer func() {
+		if summary := facade.FacadeMetrics().String(); summary != "" {
+			log.Debugf("birdwatcher facade metrics:\n%v", summary)
+		}
+	}()
 
 	out := trace.PluginOutputTrace{Tracer: tracer}
 
@@ -447,6 +736,18 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 		tracer.CurrentTrace().WithError(err).End()
 		out.MarkAsFailed(nil, nil)
 	} else {
+		// a step run in check mode is a dry run whatever its input
+		if config.CheckMode {
+			input.DryRun = true
+		}
+		// a step run in simulation is a dry run which downloads the package without installing it
+		if config.Simulation {
+			input.DryRun = true
+			input.simulation = true
+		}
+		if !input.DryRun {
+			operation = trace.Operation{PackageName: input.Name, Version: input.Version, Action: input.Action}
+		}
 		appCfg, err := appconfig.Config(false)
 		var appConfig *appconfig.SsmagentConfig
 		if err != nil {
@@ -454,26 +755,58 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 		} else {
 			appConfig = &appCfg
 		}
-		packageService, err := p.packageServiceSelector(tracer, input, p.localRepository, appConfig, p.birdwatcherfacade, &p.isDocumentArchive)
-		if err != nil {
-			tracer.CurrentTrace().WithError(err).End()
-			out.MarkAsFailed(nil, nil)
-		}
-		if out.GetStatus() != contracts.ResultStatusFailed {
-			//Return failure if the manifest cannot be accessed
-			//Return failure if the package version is installed, but the manifest is no longer available
-			packageName, packageVersion := packageService.GetPackageArnAndVersion(input.Name, input.Version)
-
-			//always download the manifest before acting upon the request
-			trace := tracer.BeginSection("download manifest")
-			packageArn, manifestVersion, isSameAsCache, err := packageService.DownloadManifest(tracer, packageName, packageVersion)
-			trace.AppendDebugf("got manifest for package %v version %v isSameAsCache %v", packageArn, manifestVersion, isSameAsCache)
 
-			trace.End()
+		// the downloads and service calls in flight are abandoned when the document is cancelled
+		ctx, cancel := gocontext.WithCancel(gocontext.Background())
+		defer cancel()
+		go func() {
+			cancelFlag.Wait()
+			if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+				cancel()
+			}
+		}()
 
-			if err != nil {
+		// phases and download progress are written to the output while the plugin runs
+		progress := newOutputProgressReporter(output)
+		if resolved == nil {
+			//Return failure if the manifest cannot be accessed
+			//Return failure if the package version is installed, but the manifest is no longer available
+			if resolved, err = p.resolvePackage(ctx, context, tracer, appConfig, input, progress); err != nil {
 				tracer.CurrentTrace().WithError(err).End()
 				out.MarkAsFailed(nil, nil)
+			}
+		} else {
+			tracer.PrependTraces(resolved.traces)
+		}
+		if out.GetStatus() != contracts.ResultStatusFailed {
+			packageService := resolved.service
+			packageArn, manifestVersion, isSameAsCache := resolved.packageArn, resolved.manifestVersion, resolved.isSameAsCache
+			p.isDocumentArchive = resolved.isDocumentArchive
+			packageService.SetProgressReporter(progress)
+			tracer.SetLogger(context.With("[packageArn=" + packageArn + "]").Log())
+			if manifestVersion != "" {
+				operation.Version = manifestVersion
+			}
+
+			compliant := false
+			if input.Action == EnsureAction {
+				if compliant = p.ensure(tracer, input, packageArn, manifestVersion, isSameAsCache, &out); compliant {
+					// nothing changed on the instance, rate-scheduled associations are not exported every interval
+					operation = trace.Operation{}
+				} else if operation.Action != "" {
+					operation.Action = input.Action
+				}
+			}
+
+			if compliant {
+				// the ensured version is already installed
+			} else if input.DryRun {
+				// nothing is changed on the instance, so the package is not locked and no result is reported
+				changes := p.dryRun(ctx, tracer, appConfig, packageService, input, packageArn, manifestVersion, &out)
+				output.AppendCheckChanges(changes...)
+				if input.simulation {
+					output.AppendSimulationTrace(changes...)
+				}
 			} else if err := p.localRepository.LockPackage(tracer, packageArn, input.Action); err != nil {
 				// do not allow multiple actions to be performed at the same time for the same package
 				// this is possible with multiple concurrent runcommand documents
@@ -481,27 +814,72 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 				out.MarkAsFailed(nil, nil)
 			} else {
 				defer p.localRepository.UnlockPackage(tracer, packageArn)
+				antiRollback := appConfig != nil && appConfig.Birdwatcher.AntiRollback
+
+				// the dependencies declared by the manifest are installed before the package
+				if input.Action == InstallAction {
+					trace := tracer.BeginSection("resolve dependencies")
+					installs, err := p.resolveDependencies(ctx, tracer, appConfig, packageService, input.Name, packageArn, manifestVersion)
+					if err != nil {
+						trace.WithError(err).End()
+						out.MarkAsFailed(nil, nil)
+					} else {
+						trace.AppendDebugf("%v dependencies to install", len(installs)).End()
+
+						// the dependencies and the package are downloaded at the same time, then installed in order
+						prefetched := p.prefetchArtifacts(ctx, tracer, downloadParallelism(appConfig), append(installs, dependencyInstall{
+							name:          input.Name,
+							packageArn:    packageArn,
+							version:       manifestVersion,
+							isSameAsCache: isSameAsCache,
+							service:       packageService,
+						}))
+						for i, service := range prefetched {
+							defer service.discard()
+							if i < len(installs) {
+								installs[i].service = service
+							} else {
+								packageService = service
+							}
+						}
+						p.installDependencies(ctx, context, tracer, config, antiRollback, installs, &out)
+					}
+				}
 
-				log.Debugf("Prepare for %v %v %v", input.Action, input.Name, input.Version)
-				inst, uninst, installState, installedVersion := prepareConfigurePackage(
-					tracer,
-					config,
-					p.localRepository,
-					packageService,
-					input,
-					packageArn,
-					manifestVersion,
-					isSameAsCache,
-					&out)
-				log.Debugf("HasInst %v, HasUninst %v, InstallState %v, PackageName %v, InstalledVersion %v", inst != nil, uninst != nil, installState, packageArn, installedVersion)
+				var inst, uninst installer.Installer
+				var installState localpackages.InstallState
+				var installedVersion string
+				var rolledBack bool
+				var upgradeAttributes map[string]string
+				if out.GetStatus() != contracts.ResultStatusFailed && !out.GetStatus().IsReboot() {
+					log.Debugf("Prepare for %v %v %v", input.Action, input.Name, input.Version)
+					inst, uninst, installState, installedVersion = prepareConfigurePackage(
+						ctx,
+						tracer,
+						config,
+						p.localRepository,
+						packageService,
+						input,
+						packageArn,
+						manifestVersion,
+						isSameAsCache,
+						antiRollback,
+						&out)
+					log.Debugf("HasInst %v, HasUninst %v, InstallState %v, PackageName %v, InstalledVersion %v", inst != nil, uninst != nil, installState, packageArn, installedVersion)
+				}
 
 				//if the status is already decided as failed or succeeded, do not execute anything
-				if out.GetStatus() != contracts.ResultStatusFailed && out.GetStatus() != contracts.ResultStatusSuccess {
+				if out.GetStatus() != contracts.ResultStatusFailed && out.GetStatus() != contracts.ResultStatusSuccess && !out.GetStatus().IsReboot() {
 					alreadyInstalled := checkAlreadyInstalled(tracer, context, p.localRepository, installedVersion, installState, inst, uninst, &out)
 					// if already failed or already installed and valid, do not execute install
 					// if it is already installed and the cache is the same, do not execute install
 					if !alreadyInstalled || !isSameAsCache {
 						log.Debugf("Calling execute, current status %v", out.GetStatus())
+						var previousFiles *packageFiles
+						if input.Action == InstallAction {
+							previousFiles = snapshotPreviousFiles(tracer, p.localRepository, inst, uninst)
+						}
+						progress.ReportPhase(fmt.Sprintf("Running %v of package %v", input.Action, input.Name))
 						executeConfigurePackage(
 							tracer,
 							context,
@@ -510,6 +888,17 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 							uninst,
 							installState,
 							&out)
+						rolledBack = input.Action == InstallAction && isRolledBack(tracer, p.localRepository, packageArn, &out)
+						if input.Action == InstallAction && out.GetStatus() == contracts.ResultStatusSuccess {
+							upgradeAttributes = reportUpgradeChanges(tracer, p.localRepository, inst, previousFiles)
+						}
+						if input.Action == InstallAction && out.GetStatus() == contracts.ResultStatusSuccess && appConfig != nil {
+							emitSBOM(tracer, appConfig.Birdwatcher.Sbom, appconfig.SbomDirectory, p.localRepository, inst)
+							recordIntegrityBaseline(tracer, appConfig.Birdwatcher.Integrity, appconfig.PackageIntegrityDirectory, p.localRepository, input.Name, inst)
+							p.localRepository.CollectGarbage(tracer, appConfig.Birdwatcher.Retention, packageArn)
+						} else if input.Action == UninstallAction && out.GetStatus() == contracts.ResultStatusSuccess {
+							deleteIntegrityBaseline(tracer, appconfig.PackageIntegrityDirectory, packageArn)
+						}
 					}
 				}
 				if err := p.localRepository.LoadTraces(tracer, packageArn); err != nil {
@@ -529,22 +918,24 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 							version = uninst.Version()
 						}
 					}
-					startTime := tracer.Traces()[0].Start
-					for _, trace := range tracer.Traces() {
-						if trace.Start < startTime {
-							startTime = trace.Start
-						}
-					}
+					start := firstTrace(tracer.Traces())
 					if !p.isDocumentArchive {
-						err := packageService.ReportResult(tracer, packageservice.PackageResult{
+						reportCtx, cancelReport := gocontext.WithTimeout(ctx, reportResultTimeout)
+						err := packageService.ReportResult(reportCtx, tracer, packageservice.PackageResult{
 							Exitcode:               int64(out.GetExitCode()),
+							ErrorCode:              out.GetErrorCode(),
+							RolledBack:             rolledBack,
 							Operation:              input.Action,
 							PackageName:            input.Name,
 							PreviousPackageVersion: installedVersion,
-							Timing:                 startTime,
+							Timing:                 start.Start,
+							Anchor:                 start.Anchor,
+							MonotonicTiming:        start.MonotonicStart,
 							Version:                version,
-							Trace:                  packageservice.ConvertToPackageServiceTrace(tracer.Traces()),
+							Attributes:             withUpgradeChanges(resultAttributes(tracer, appConfig, input.ResultAttributes), upgradeAttributes),
+							Trace:                  packageservice.ConvertToPackageServiceTrace(compactTraces(tracer, packageTraceCfg())),
 						})
+						cancelReport()
 						if err != nil {
 							out.AppendErrorf(log, "Error reporting results: %v", err.Error())
 						}
@@ -556,13 +947,131 @@ func (p *Plugin) execute(context context.T, config contracts.Configuration, canc
 
 	output.SetExitCode(out.GetExitCode())
 	output.SetStatus(out.GetStatus())
+	if out.GetStatus() == contracts.ResultStatusFailed {
+		output.SetErrorCode(out.GetErrorCode())
+	}
 
 	// convert trace
-	traceout := tracer.ToPluginOutput()
+	appendTraces(tracer, output)
+
+	// an operation interrupted by a reboot is exported when it completes after the reboot, with the persisted traces
+	if operation.Action != "" && !out.GetStatus().IsReboot() {
+		operation.Status = string(out.GetStatus())
+		tracer.Export(operation)
+	}
+
+	return
+}
+
+// resolvedPackage is a package whose manifest is downloaded
+type resolvedPackage struct {
+	service           packageservice.PackageService
+	isDocumentArchive bool
+	packageArn        string
+	manifestVersion   string
+	isSameAsCache     bool
+	// traces are the traces of the manifest download when it is resolved ahead of the action
+	traces []*trace.Trace
+}
+
+// resolvePackage selects the service of the package and downloads its manifest
+func (p *Plugin) resolvePackage(
+	ctx gocontext.Context,
+	context context.T,
+	tracer trace.Tracer,
+	appConfig *appconfig.SsmagentConfig,
+	input *ConfigurePackagePluginInput,
+	progress packageservice.ProgressReporter) (*resolvedPackage, error) {
+
+	resolved := &resolvedPackage{}
+	packageService, err := p.packageServiceSelector(tracer, input, p.localRepository, appConfig, p.birdwatcherfacade, &resolved.isDocumentArchive)
+	if err != nil {
+		return nil, err
+	}
+	packageService.SetProgressReporter(progress)
+
+	// the channel of the document overrides the channel of the agent
+	channel := input.Channel
+	if channel == "" && appConfig != nil {
+		channel = appConfig.Birdwatcher.Channel
+	}
+	packageService.SetChannel(channel)
+
+	packageName, packageVersion := packageService.GetPackageArnAndVersion(input.Name, input.Version)
+	tracer.SetLogger(context.With("[packageArn=" + packageName + "]").Log())
+
+	//always download the manifest before acting upon the request
+	progress.ReportPhase(fmt.Sprintf("Downloading manifest of package %v", input.Name))
+	trace := tracer.BeginSection("download manifest")
+	manifestCtx, cancelManifest := gocontext.WithTimeout(ctx, manifestDownloadTimeout)
+	packageArn, manifestVersion, isSameAsCache, err := packageService.DownloadManifest(manifestCtx, tracer, packageName, packageVersion)
+	cancelManifest()
+	trace.AppendDebugf("got manifest for package %v version %v isSameAsCache %v", packageArn, manifestVersion, isSameAsCache)
+	if err == nil {
+		trace.AppendInfo(manifestChangeMessage(packageArn, manifestVersion, isSameAsCache))
+	}
+	trace.End()
+	if err != nil {
+		return nil, err
+	}
+
+	resolved.service = packageService
+	resolved.packageArn = packageArn
+	resolved.manifestVersion = manifestVersion
+	resolved.isSameAsCache = isSameAsCache
+	return resolved, nil
+}
+
+// manifestChangeMessage tells whether the manifest changed since it was cached by a previous execution,
+// the package of an unchanged manifest is only validated again when it is installed
+func manifestChangeMessage(packageArn string, version string, isSameAsCache bool) string {
+	if isSameAsCache {
+		return fmt.Sprintf("manifest of %v %v is unchanged since the previous execution (isSameAsCache true)", packageArn, version)
+	}
+	return fmt.Sprintf("manifest of %v %v changed or was not cached (isSameAsCache false)", packageArn, version)
+}
+
+// appendTraces writes the traces of the tracer and their timing to the output
+func appendTraces(tracer trace.Tracer, output iohandler.IOHandler) {
+	traceCfg := packageTraceCfg()
+	traces := compactTraces(tracer, traceCfg)
+	traceout := trace.TracesToPluginOutput(traces)
 	output.AppendInfo(traceout.GetStdout())
 	output.AppendError(traceout.GetStderr())
+	output.AppendInfo(trace.TimingSummary(traces, trace.TimestampOptions{
+		LocalTime: traceCfg.LocalTimeAnnotation,
+		Durations: traceCfg.IncludeDurations,
+	}))
+}
 
-	return
+// CollectRepositoryGarbage applies the retention policy to the local package repository and logs what was reclaimed,
+// it is run when the agent starts
+func CollectRepositoryGarbage(context context.T) {
+	log := context.Log()
+	tracer := trace.NewTracer(log)
+	localpackages.NewRepository().CollectGarbage(tracer, context.AppConfig().Birdwatcher.Retention, "")
+
+	output := trace.TracesToPluginOutput(tracer.Traces())
+	log.Info(output.GetStdout())
+	if stderr := output.GetStderr(); stderr != "" {
+		log.Warn(stderr)
+	}
+}
+
+// packageTraceCfg returns the configuration of the traces written to the output and reported to the service
+func packageTraceCfg() appconfig.PackageTraceCfg {
+	if appCfg, err := appconfig.Config(false); err == nil {
+		return appCfg.Birdwatcher.Trace
+	}
+	return appconfig.DefaultConfig().Birdwatcher.Trace
+}
+
+// compactTraces returns the traces written to the output and reported to the service, within the configured limits
+func compactTraces(tracer trace.Tracer, traceCfg appconfig.PackageTraceCfg) []*trace.Trace {
+	return trace.Compact(tracer.Traces(), trace.Limits{
+		CollapseRepeated: traceCfg.CollapseRepeatedSections,
+		MaxSectionBytes:  traceCfg.MaxSectionOutputBytes,
+	})
 }
 
 // Name returns the name of the plugin.
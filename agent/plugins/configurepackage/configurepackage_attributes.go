@@ -0,0 +1,89 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+const (
+	maxResultAttributes           = 20
+	maxResultAttributeValueLength = 256
+)
+
+// resultAttributeKeyPattern allows the characters and the length of the keys of tags
+var resultAttributeKeyPattern = regexp.MustCompile(`^[\p{L}\p{N}_.:/=+@-]{1,128}$`)
+
+// instanceTag reads a tag of the instance from the instance metadata, replaced in tests
+var instanceTag = platform.EC2InstanceTag
+
+// isManagedInstance tells if the instance has no instance metadata to read tags from, replaced in tests
+var isManagedInstance = platform.IsManagedInstance
+
+// resultAttributes returns the operator defined attributes reported with the result of a package action. The
+// attributes of the document override the instance tags which override the attributes of the agent configuration,
+// invalid attributes are ignored.
+func resultAttributes(tracer trace.Tracer, appCfg *appconfig.SsmagentConfig, documentAttributes map[string]string) map[string]string {
+	log := tracer.CurrentTrace().Logger
+	attributes := make(map[string]string)
+	add := func(source string, key string, value string) {
+		if !resultAttributeKeyPattern.MatchString(key) || len(value) > maxResultAttributeValueLength {
+			log.Warnf("Ignoring the %v result attribute %q, keys have up to 128 letters, numbers or _.:/=+@- and values up to %v characters", source, key, maxResultAttributeValueLength)
+			return
+		}
+		attributes[key] = value
+	}
+
+	if appCfg != nil {
+		config := appCfg.Birdwatcher.ResultAttributes
+		for key, value := range config.Static {
+			add("configured", key, value)
+		}
+		if len(config.InstanceTags) > 0 {
+			if managed, err := isManagedInstance(); err != nil || managed {
+				log.Debugf("Not reporting instance tags, the instance has no instance metadata: %v", err)
+			} else {
+				for _, key := range config.InstanceTags {
+					value, err := instanceTag(key)
+					if err != nil {
+						log.Debugf("Instance tag %v is not in the instance metadata: %v", key, err)
+						continue
+					}
+					add("instance tag", key, value)
+				}
+			}
+		}
+	}
+	for key, value := range documentAttributes {
+		add("document", key, value)
+	}
+
+	if len(attributes) > maxResultAttributes {
+		keys := make([]string, 0, len(attributes))
+		for key := range attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		log.Warnf("Reporting the first %v of %v result attributes, ignoring %v", maxResultAttributes, len(keys), keys[maxResultAttributes:])
+		for _, key := range keys[maxResultAttributes:] {
+			delete(attributes, key)
+		}
+	}
+	return attributes
+}
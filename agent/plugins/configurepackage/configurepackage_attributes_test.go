@@ -0,0 +1,112 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func useInstanceTags(managed bool, tags map[string]string) func() {
+	originalTag, originalManaged := instanceTag, isManagedInstance
+	instanceTag = func(key string) (string, error) {
+		if value, ok := tags[key]; ok {
+			return value, nil
+		}
+		return "", errors.New("404 - Not Found")
+	}
+	isManagedInstance = func() (bool, error) { return managed, nil }
+	return func() { instanceTag, isManagedInstance = originalTag, originalManaged }
+}
+
+func newAttributesTracer() trace.Tracer {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("report result")
+	return tracer
+}
+
+func TestResultAttributes(t *testing.T) {
+	defer useInstanceTags(false, map[string]string{"team": "payments", "stack": "checkout"})()
+	appCfg := appconfig.DefaultConfig()
+	appCfg.Birdwatcher.ResultAttributes = appconfig.ResultAttributesCfg{
+		Static:       map[string]string{"environment": "staging", "team": "unknown"},
+		InstanceTags: []string{"team", "stack", "missing"},
+	}
+
+	attributes := resultAttributes(newAttributesTracer(), &appCfg, map[string]string{"stack": "checkout-blue"})
+
+	assert.Equal(t, map[string]string{
+		"environment": "staging",
+		"team":        "payments",
+		"stack":       "checkout-blue",
+	}, attributes)
+}
+
+func TestResultAttributesOfManagedInstance(t *testing.T) {
+	defer useInstanceTags(true, map[string]string{"team": "payments"})()
+	appCfg := appconfig.DefaultConfig()
+	appCfg.Birdwatcher.ResultAttributes.InstanceTags = []string{"team"}
+
+	attributes := resultAttributes(newAttributesTracer(), &appCfg, nil)
+
+	assert.Empty(t, attributes)
+}
+
+func TestResultAttributesWithoutConfig(t *testing.T) {
+	attributes := resultAttributes(newAttributesTracer(), nil, map[string]string{"environment": "prod"})
+
+	assert.Equal(t, map[string]string{"environment": "prod"}, attributes)
+}
+
+func TestResultAttributesIgnoresInvalidAttributes(t *testing.T) {
+	attributes := resultAttributes(newAttributesTracer(), nil, map[string]string{
+		"valid:key/1":            "value",
+		"":                       "empty key",
+		"invalid key":            "space",
+		strings.Repeat("k", 129): "long key",
+		"long-value":             strings.Repeat("v", maxResultAttributeValueLength+1),
+		"max-value":              strings.Repeat("v", maxResultAttributeValueLength),
+	})
+
+	assert.Equal(t, []string{"max-value", "valid:key/1"}, sortedKeys(attributes))
+}
+
+func TestResultAttributesLimit(t *testing.T) {
+	documentAttributes := make(map[string]string)
+	for i := 0; i < maxResultAttributes+5; i++ {
+		documentAttributes[fmt.Sprintf("key%02d", i)] = "value"
+	}
+
+	attributes := resultAttributes(newAttributesTracer(), nil, documentAttributes)
+
+	assert.Len(t, attributes, maxResultAttributes)
+	assert.Contains(t, attributes, "key00")
+	assert.NotContains(t, attributes, fmt.Sprintf("key%02d", maxResultAttributes))
+}
+
+func sortedKeys(attributes map[string]string) (keys []string) {
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// validatePackages ensures the packages of a batch are named once each and have a supported action
+func validatePackages(input *ConfigurePackagePluginInput) error {
+	if input.Name != "" || input.Version != "" {
+		return errors.New("name and version cannot be set with packages")
+	}
+	names := make(map[string]bool)
+	for _, entry := range input.Packages {
+		if entry.Name == "" {
+			return errors.New("empty name field in packages")
+		}
+		if names[entry.Name] {
+			return fmt.Errorf("package %v is listed more than once", entry.Name)
+		}
+		names[entry.Name] = true
+		if action := entry.actionOf(input); action != InstallAction && action != UninstallAction && action != EnsureAction {
+			return fmt.Errorf("unsupported action %v of package %v", action, entry.Name)
+		}
+		if input.VersionLabel != "" && entry.Version != "" {
+			return fmt.Errorf("version of package %v and versionLabel cannot both be set", entry.Name)
+		}
+	}
+	return nil
+}
+
+// actionOf returns the action of the package in the batch
+func (entry ConfigurePackageEntry) actionOf(input *ConfigurePackagePluginInput) string {
+	if entry.Action == "" {
+		return input.Action
+	}
+	return entry.Action
+}
+
+// packageInput returns the input of a package of the batch
+func (input *ConfigurePackagePluginInput) packageInput(entry ConfigurePackageEntry) *ConfigurePackagePluginInput {
+	packageInput := *input
+	packageInput.Name = entry.Name
+	packageInput.Version = entry.Version
+	packageInput.Action = entry.actionOf(input)
+	packageInput.Packages = nil
+	return &packageInput
+}
+
+// executeBatch runs the actions of the packages of a batch in the declared order. The manifests of all the packages
+// are downloaded first, so that a package that cannot be resolved fails the step before any package is changed.
+// Every package reports its own result, and the batch stops at the first package that fails or requires a reboot.
+func (p *Plugin) executeBatch(
+	context context.T,
+	config contracts.Configuration,
+	cancelFlag task.CancelFlag,
+	output iohandler.IOHandler,
+	input *ConfigurePackagePluginInput) {
+
+	log := context.Log()
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+		return
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+		return
+	}
+
+	var appConfig *appconfig.SsmagentConfig
+	if appCfg, err := appconfig.Config(false); err == nil {
+		appConfig = &appCfg
+	}
+
+	// the manifest downloads in flight are abandoned when the document is cancelled
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	go func() {
+		cancelFlag.Wait()
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			cancel()
+		}
+	}()
+
+	progress := newOutputProgressReporter(output)
+	inputs := make([]*ConfigurePackagePluginInput, len(input.Packages))
+	resolved := make([]*resolvedPackage, len(input.Packages))
+	for i, entry := range input.Packages {
+		inputs[i] = input.packageInput(entry)
+		tracer := trace.NewTracer(log)
+		pkg, err := p.resolvePackage(ctx, context, tracer, appConfig, inputs[i], progress)
+		if err != nil {
+			log.Errorf("Failed to resolve package %v of the batch: %v", entry.Name, err)
+			tracer.BeginSection(fmt.Sprintf("resolve package %v", entry.Name)).WithError(err).End()
+			failBatch(cancelFlag, tracer, output)
+			return
+		}
+		pkg.traces = tracer.Traces()
+		resolved[i] = pkg
+	}
+
+	for i, pkg := range resolved {
+		packageConfig := config
+		packageConfig.Properties = inputs[i]
+		p.executePackage(context, packageConfig, cancelFlag, output, pkg)
+		if status := output.GetStatus(); status != contracts.ResultStatusSuccess {
+			if i < len(resolved)-1 {
+				output.AppendInfof("Skipped the packages after %v, its %v is %v", inputs[i].Name, inputs[i].Action, status)
+			}
+			return
+		}
+	}
+}
+
+// failBatch writes the traces of a package that cannot be resolved and fails the batch
+func failBatch(cancelFlag task.CancelFlag, tracer trace.Tracer, output iohandler.IOHandler) {
+	out := trace.PluginOutputTrace{Tracer: tracer}
+	if cancelFlag.ShutDown() {
+		out.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		out.MarkAsCancelled()
+	} else {
+		out.MarkAsFailed(nil, nil)
+	}
+	output.SetExitCode(out.GetExitCode())
+	output.SetStatus(out.GetStatus())
+	if out.GetStatus() == contracts.ResultStatusFailed {
+		output.SetErrorCode(out.GetErrorCode())
+	}
+	appendTraces(tracer, output)
+}
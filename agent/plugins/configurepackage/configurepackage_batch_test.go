@@ -0,0 +1,97 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// batchServiceMock adds the calls of the plugin execution to the service of a package
+func batchServiceMock(service *serviceMock.Mock) *serviceMock.Mock {
+	service.On("SetProgressReporter", mock.Anything).Return()
+	service.On("SetChannel", mock.Anything).Return()
+	return service
+}
+
+func TestValidateInput_Packages(t *testing.T) {
+	input := ConfigurePackagePluginInput{
+		Action:   InstallAction,
+		Packages: []ConfigurePackageEntry{{Name: "app"}, {Name: "old", Action: UninstallAction}},
+	}
+	valid, err := validateInput(&input)
+	assert.True(t, valid)
+	assert.NoError(t, err)
+	assert.Equal(t, &ConfigurePackagePluginInput{Name: "app", Action: InstallAction}, input.packageInput(input.Packages[0]))
+	assert.Equal(t, UninstallAction, input.packageInput(input.Packages[1]).Action)
+
+	invalid := []ConfigurePackagePluginInput{
+		{Name: "app", Action: InstallAction, Packages: []ConfigurePackageEntry{{Name: "old"}}},
+		{Action: InstallAction, Packages: []ConfigurePackageEntry{{Name: "app"}, {Name: "app", Version: "1.0"}}},
+		{Packages: []ConfigurePackageEntry{{Name: "app"}}},
+		{Action: InstallAction, Packages: []ConfigurePackageEntry{{Name: "app", Action: "Upgrade"}}},
+		{Action: InstallAction, Packages: []ConfigurePackageEntry{{Version: "1.0"}}},
+		{Action: InstallAction, VersionLabel: "prod", Packages: []ConfigurePackageEntry{{Name: "app", Version: "1.0"}}},
+	}
+	for _, input := range invalid {
+		valid, err := validateInput(&input)
+		assert.False(t, valid, "%v", input)
+		assert.Error(t, err)
+	}
+}
+
+func TestExecuteBatchDryRun(t *testing.T) {
+	defer setDiskSpace(1000)()
+	app := batchServiceMock(dryRunServiceMock(100))
+	old := batchServiceMock(dependencyServiceMock("old", "1.0.0"))
+	plugin := dependencyPlugin(installedRepositoryMock("1.0.0"), map[string]*serviceMock.Mock{"app": app, "old": old})
+	input := &ConfigurePackagePluginInput{
+		Action:   InstallAction,
+		DryRun:   true,
+		Packages: []ConfigurePackageEntry{{Name: "app"}, {Name: "old", Action: UninstallAction}},
+	}
+	output := &iohandler.DefaultIOHandler{}
+
+	plugin.execute(contextMock, contracts.Configuration{Properties: input}, createMockCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, []string{"Upgrade app from 1.0.0 to 2.0.0", "Uninstall old 1.0.0"}, output.GetCheckChanges())
+	assert.Contains(t, output.GetStdout(), "Would upgrade app from 1.0.0 to 2.0.0")
+	assert.Contains(t, output.GetStdout(), "Would uninstall old 1.0.0")
+	app.AssertNumberOfCalls(t, "DownloadManifest", 1)
+	old.AssertNumberOfCalls(t, "DownloadManifest", 1)
+}
+
+func TestExecuteBatchUnresolvedPackage(t *testing.T) {
+	app := batchServiceMock(dryRunServiceMock(100))
+	plugin := dependencyPlugin(installedRepositoryMock("1.0.0"), map[string]*serviceMock.Mock{"app": app})
+	input := &ConfigurePackagePluginInput{
+		Action:   InstallAction,
+		DryRun:   true,
+		Packages: []ConfigurePackageEntry{{Name: "app"}, {Name: "missing"}},
+	}
+	output := &iohandler.DefaultIOHandler{}
+
+	plugin.execute(contextMock, contracts.Configuration{Properties: input}, createMockCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "unknown package missing")
+	assert.Empty(t, output.GetCheckChanges())
+	app.AssertNotCalled(t, "DescribeArtifact", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/sbom"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// maxTracedChangedFiles is the largest number of paths traced for each kind of change of an upgrade
+const maxTracedChangedFiles = 20
+
+// Result attributes of the files changed by an upgrade
+const (
+	upgradeFilesAddedAttribute           = "upgrade.filesAdded"
+	upgradeFilesRemovedAttribute         = "upgrade.filesRemoved"
+	upgradeFilesModifiedAttribute        = "upgrade.filesModified"
+	upgradeConfigFilesPreservedAttribute = "upgrade.configFilesPreserved"
+)
+
+// packageFiles are the checksums of the files of a version of a package by their path relative to the package
+// directory, with the configuration files declared by its manifest
+type packageFiles struct {
+	version     string
+	checksums   map[string]string
+	configFiles []string
+}
+
+// upgradeChanges summarizes the files changed between the previous and the new version of a package
+type upgradeChanges struct {
+	PreviousVersion string
+	Version         string
+	Added           []string
+	Removed         []string
+	Modified        []string
+	Unchanged       int
+	// ConfigPreserved are the configuration files with the same content in both versions, ConfigReplaced the ones
+	// modified or removed by the new version
+	ConfigPreserved []string
+	ConfigReplaced  []string
+}
+
+// snapshotPreviousFiles hashes the files of the installed version of a package before an upgrade removes them, it
+// returns nil when the action is not an upgrade or the files cannot be read
+func snapshotPreviousFiles(tracer trace.Tracer, repository localpackages.Repository, inst installer.Installer, uninst installer.Installer) *packageFiles {
+	if inst == nil || uninst == nil || inst.Version() == uninst.Version() {
+		return nil
+	}
+	files, err := collectPackageFiles(tracer, repository, uninst)
+	if err != nil {
+		tracer.CurrentTrace().Logger.Debugf("Not comparing the files of %v %v, they cannot be read: %v", uninst.PackageName(), uninst.Version(), err)
+		return nil
+	}
+	return files
+}
+
+// reportUpgradeChanges compares the files of the new version of a package with the files of the previous version and
+// traces the summary, it returns the result attributes of the summary. Failures are traced but do not fail the
+// upgrade.
+func reportUpgradeChanges(tracer trace.Tracer, repository localpackages.Repository, inst installer.Installer, previous *packageFiles) map[string]string {
+	if previous == nil {
+		return nil
+	}
+	var err error
+	trace := tracer.BeginSection(fmt.Sprintf("compare files of %v %v and %v", inst.PackageName(), previous.version, inst.Version()))
+	defer trace.EndWithError(&err)
+
+	var current *packageFiles
+	if current, err = collectPackageFiles(tracer, repository, inst); err != nil {
+		return nil
+	}
+	changes := compareFiles(previous, current)
+	trace.AppendInfof("Upgrade from %v to %v: %v files added, %v removed, %v modified, %v unchanged, %v configuration files preserved, %v replaced",
+		changes.PreviousVersion, changes.Version, len(changes.Added), len(changes.Removed), len(changes.Modified), changes.Unchanged,
+		len(changes.ConfigPreserved), len(changes.ConfigReplaced))
+	for _, files := range []struct {
+		kind  string
+		paths []string
+	}{
+		{"added", changes.Added},
+		{"removed", changes.Removed},
+		{"modified", changes.Modified},
+		{"configuration preserved", changes.ConfigPreserved},
+		{"configuration replaced", changes.ConfigReplaced},
+	} {
+		if len(files.paths) > maxTracedChangedFiles {
+			trace.AppendInfof("Files %v: %v and %v more", files.kind, strings.Join(files.paths[:maxTracedChangedFiles], ", "), len(files.paths)-maxTracedChangedFiles)
+		} else if len(files.paths) > 0 {
+			trace.AppendInfof("Files %v: %v", files.kind, strings.Join(files.paths, ", "))
+		}
+	}
+	return changes.attributes()
+}
+
+// collectPackageFiles hashes the files of the version of a package of an installer
+func collectPackageFiles(tracer trace.Tracer, repository localpackages.Repository, inst installer.Installer) (*packageFiles, error) {
+	files, err := sbom.CollectFiles(repository.GetPackageDirectory(tracer, inst.PackageName(), inst.Version()))
+	if err != nil {
+		return nil, err
+	}
+	result := &packageFiles{version: inst.Version(), checksums: make(map[string]string, len(files))}
+	for _, file := range files {
+		result.checksums[file.Path] = file.SHA256
+	}
+	if manifest, manifestErr := repository.GetPackageManifest(tracer, inst.PackageName(), inst.Version()); manifestErr == nil {
+		result.configFiles = manifest.ConfigFiles
+	}
+	return result, nil
+}
+
+// compareFiles compares the checksums of the files of two versions of a package. The configuration files are the
+// ones declared by the manifest of either version.
+func compareFiles(previous *packageFiles, current *packageFiles) upgradeChanges {
+	changes := upgradeChanges{PreviousVersion: previous.version, Version: current.version}
+	configPatterns := append(append([]string{}, previous.configFiles...), current.configFiles...)
+	for file, checksum := range previous.checksums {
+		currentChecksum, found := current.checksums[file]
+		switch {
+		case !found:
+			changes.Removed = append(changes.Removed, file)
+		case currentChecksum != checksum:
+			changes.Modified = append(changes.Modified, file)
+		default:
+			changes.Unchanged++
+		}
+		if isConfigFile(file, configPatterns) {
+			if found && currentChecksum == checksum {
+				changes.ConfigPreserved = append(changes.ConfigPreserved, file)
+			} else {
+				changes.ConfigReplaced = append(changes.ConfigReplaced, file)
+			}
+		}
+	}
+	for file := range current.checksums {
+		if _, found := previous.checksums[file]; !found {
+			changes.Added = append(changes.Added, file)
+		}
+	}
+	for _, paths := range [][]string{changes.Added, changes.Removed, changes.Modified, changes.ConfigPreserved, changes.ConfigReplaced} {
+		sort.Strings(paths)
+	}
+	return changes
+}
+
+// isConfigFile tells if the path of a file matches one of the configuration file patterns of a manifest
+func isConfigFile(file string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(path.Clean("/"+strings.Replace(pattern, "\\", "/", -1)), "/")
+		if pattern == "" {
+			continue
+		}
+		if matched, _ := path.Match(pattern, file); matched || strings.HasPrefix(file, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// attributes returns the counts of the changes as result attributes
+func (changes upgradeChanges) attributes() map[string]string {
+	return map[string]string{
+		upgradeFilesAddedAttribute:           strconv.Itoa(len(changes.Added)),
+		upgradeFilesRemovedAttribute:         strconv.Itoa(len(changes.Removed)),
+		upgradeFilesModifiedAttribute:        strconv.Itoa(len(changes.Modified)),
+		upgradeConfigFilesPreservedAttribute: strconv.Itoa(len(changes.ConfigPreserved)),
+	}
+}
+
+// withUpgradeChanges adds the attributes of the changes of an upgrade to the result attributes, they override the
+// operator defined attributes with the same key
+func withUpgradeChanges(attributes map[string]string, changes map[string]string) map[string]string {
+	if len(changes) == 0 {
+		return attributes
+	}
+	if attributes == nil {
+		attributes = make(map[string]string, len(changes))
+	}
+	for key, value := range changes {
+		attributes[key] = value
+	}
+	return attributes
+}
@@ -0,0 +1,130 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	installerMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const changesPackageArn = "arn:aws:ssm:us-east-1:123456789012:document/MyPackage"
+
+func writePackageFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+}
+
+func newVersionInstaller(version string) *installerMock.Mock {
+	inst := &installerMock.Mock{}
+	inst.On("PackageName").Return(changesPackageArn)
+	inst.On("Version").Return(version)
+	return inst
+}
+
+func TestReportUpgradeChanges(t *testing.T) {
+	root, _ := ioutil.TempDir("", "changes")
+	defer os.RemoveAll(root)
+	previousDir, currentDir := filepath.Join(root, "1.0.0"), filepath.Join(root, "2.0.0")
+	writePackageFiles(t, previousDir, map[string]string{
+		"install.sh":       "echo install 1",
+		"uninstall.sh":     "echo uninstall",
+		"conf/agent.conf":  "level=info",
+		"conf/extra.conf":  "extra",
+		"settings.ini":     "old",
+		"bin/deprecated":   "binary",
+		"README.txt":       "readme",
+		"lib/unchanged.so": "library",
+	})
+	writePackageFiles(t, currentDir, map[string]string{
+		"install.sh":       "echo install 2",
+		"uninstall.sh":     "echo uninstall",
+		"conf/agent.conf":  "level=info",
+		"settings.ini":     "new",
+		"bin/added":        "binary",
+		"README.txt":       "readme",
+		"lib/unchanged.so": "library",
+	})
+
+	repo := &repoMock.MockedRepository{}
+	repo.On("GetPackageDirectory", mock.Anything, changesPackageArn, "1.0.0").Return(previousDir)
+	repo.On("GetPackageDirectory", mock.Anything, changesPackageArn, "2.0.0").Return(currentDir)
+	repo.On("GetPackageManifest", mock.Anything, changesPackageArn, "1.0.0").Return(&localpackages.PackageManifest{ConfigFiles: []string{"conf/"}}, nil)
+	repo.On("GetPackageManifest", mock.Anything, changesPackageArn, "2.0.0").Return(&localpackages.PackageManifest{ConfigFiles: []string{"conf/", "*.ini"}}, nil)
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	previous := snapshotPreviousFiles(tracer, repo, newVersionInstaller("2.0.0"), newVersionInstaller("1.0.0"))
+	assert.NotNil(t, previous)
+	attributes := reportUpgradeChanges(tracer, repo, newVersionInstaller("2.0.0"), previous)
+
+	assert.Equal(t, map[string]string{
+		upgradeFilesAddedAttribute:           "1",
+		upgradeFilesRemovedAttribute:         "2",
+		upgradeFilesModifiedAttribute:        "2",
+		upgradeConfigFilesPreservedAttribute: "1",
+	}, attributes)
+	assert.Equal(t, 1, len(tracer.Traces()))
+	section := tracer.Traces()[0]
+	assert.Equal(t, "compare files of "+changesPackageArn+" 1.0.0 and 2.0.0", section.Operation)
+	assert.Equal(t, "Upgrade from 1.0.0 to 2.0.0: 1 files added, 2 removed, 2 modified, 4 unchanged, 1 configuration files preserved, 2 replaced\n"+
+		"Files added: bin/added\n"+
+		"Files removed: bin/deprecated, conf/extra.conf\n"+
+		"Files modified: install.sh, settings.ini\n"+
+		"Files configuration preserved: conf/agent.conf\n"+
+		"Files configuration replaced: conf/extra.conf, settings.ini\n", section.InfoOut.String())
+}
+
+func TestCompareFiles(t *testing.T) {
+	previous := &packageFiles{version: "1.0.0", checksums: map[string]string{"a": "1", "b": "2", "etc/c.conf": "3"}, configFiles: []string{"etc/*.conf"}}
+	current := &packageFiles{version: "2.0.0", checksums: map[string]string{"a": "1", "b": "20", "etc/c.conf": "3", "d": "4"}}
+
+	changes := compareFiles(previous, current)
+
+	assert.Equal(t, upgradeChanges{
+		PreviousVersion: "1.0.0",
+		Version:         "2.0.0",
+		Added:           []string{"d"},
+		Modified:        []string{"b"},
+		Unchanged:       2,
+		ConfigPreserved: []string{"etc/c.conf"},
+	}, changes)
+}
+
+func TestSnapshotPreviousFilesWithoutUpgrade(t *testing.T) {
+	repo := &repoMock.MockedRepository{}
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	assert.Nil(t, snapshotPreviousFiles(tracer, repo, newVersionInstaller("1.0.0"), nil))
+	assert.Nil(t, snapshotPreviousFiles(tracer, repo, newVersionInstaller("1.0.0"), newVersionInstaller("1.0.0")))
+	assert.Nil(t, reportUpgradeChanges(tracer, repo, newVersionInstaller("1.0.0"), nil))
+	repo.AssertNotCalled(t, "GetPackageDirectory", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWithUpgradeChanges(t *testing.T) {
+	assert.Equal(t, map[string]string{"stack": "blue"}, withUpgradeChanges(map[string]string{"stack": "blue"}, nil))
+	assert.Equal(t, map[string]string{"stack": "blue", upgradeFilesAddedAttribute: "3"},
+		withUpgradeChanges(map[string]string{"stack": "blue"}, map[string]string{upgradeFilesAddedAttribute: "3"}))
+}
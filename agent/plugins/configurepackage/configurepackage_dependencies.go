@@ -0,0 +1,306 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/amazon-ssm-agent/agent/versionutil"
+)
+
+// versionOperators are the comparisons of a version constraint, longest first so that >= is not read as >
+var versionOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// versionComparison is one comparison of a version constraint, such as >=1.2.0
+type versionComparison struct {
+	operator string
+	version  string
+}
+
+// versionConstraint is satisfied by the versions satisfying all of its comparisons
+type versionConstraint []versionComparison
+
+// parseVersionConstraint parses comma separated comparisons such as >=1.2.0,<2.0.0, a version without
+// operator must be matched exactly and an empty constraint or latest accepts any version
+func parseVersionConstraint(constraint string) (versionConstraint, error) {
+	if packageservice.IsLatest(strings.TrimSpace(constraint)) {
+		return nil, nil
+	}
+	var parsed versionConstraint
+	for _, element := range strings.Split(constraint, ",") {
+		element = strings.TrimSpace(element)
+		comparison := versionComparison{operator: "=", version: element}
+		for _, operator := range versionOperators {
+			if strings.HasPrefix(element, operator) {
+				comparison = versionComparison{operator: operator, version: strings.TrimSpace(element[len(operator):])}
+				break
+			}
+		}
+		if comparison.version == "" {
+			return nil, fmt.Errorf("invalid version constraint %q", constraint)
+		}
+		parsed = append(parsed, comparison)
+	}
+	return parsed, nil
+}
+
+// satisfiedBy returns true if the version satisfies all the comparisons
+func (constraint versionConstraint) satisfiedBy(version string) bool {
+	for _, comparison := range constraint {
+		result := versionutil.Compare(version, comparison.version, false)
+		var satisfied bool
+		switch comparison.operator {
+		case ">=":
+			satisfied = result >= 0
+		case "<=":
+			satisfied = result <= 0
+		case "!=":
+			satisfied = result != 0
+		case ">":
+			satisfied = result > 0
+		case "<":
+			satisfied = result < 0
+		default:
+			satisfied = result == 0
+		}
+		if !satisfied {
+			return false
+		}
+	}
+	return true
+}
+
+// versionToDownload returns the version pinned by the constraint, or latest when it accepts a range
+func (constraint versionConstraint) versionToDownload() string {
+	for _, comparison := range constraint {
+		if comparison.operator == "=" {
+			return comparison.version
+		}
+	}
+	return packageservice.Latest
+}
+
+// String returns the constraint in its manifest format
+func (constraint versionConstraint) String() string {
+	if len(constraint) == 0 {
+		return packageservice.Latest
+	}
+	var elements []string
+	for _, comparison := range constraint {
+		elements = append(elements, comparison.operator+comparison.version)
+	}
+	return strings.Join(elements, ",")
+}
+
+// dependencyInstall is a dependency to install and the package service its manifest was downloaded from
+type dependencyInstall struct {
+	name          string
+	packageArn    string
+	version       string
+	isSameAsCache bool
+	service       packageservice.PackageService
+}
+
+// dependencyResolver walks the dependencies of a package depth first
+type dependencyResolver struct {
+	ctx       gocontext.Context
+	tracer    trace.Tracer
+	plugin    *Plugin
+	appConfig *appconfig.SsmagentConfig
+	resolved  map[string]string // resolved version by package name
+	path      []string          // packages being resolved, to detect cycles
+	installs  []dependencyInstall
+}
+
+// resolveDependencies returns the dependencies of the package that are not installed in a version satisfying their
+// constraint, ordered so that every package comes after its own dependencies
+func (p *Plugin) resolveDependencies(
+	ctx gocontext.Context,
+	tracer trace.Tracer,
+	appConfig *appconfig.SsmagentConfig,
+	packageService packageservice.PackageService,
+	packageName string,
+	packageArn string,
+	version string) ([]dependencyInstall, error) {
+
+	resolver := &dependencyResolver{
+		ctx:       ctx,
+		tracer:    tracer,
+		plugin:    p,
+		appConfig: appConfig,
+		resolved:  map[string]string{packageName: version},
+	}
+	if err := resolver.resolve(packageService, packageName, packageArn, version); err != nil {
+		return nil, err
+	}
+	return resolver.installs, nil
+}
+
+// resolve resolves the dependencies of a package version, then appends them to the installs
+func (resolver *dependencyResolver) resolve(packageService packageservice.PackageService, packageName string, packageArn string, version string) error {
+	dependencies, err := packageService.GetDependencies(resolver.tracer, packageArn, version)
+	if err != nil {
+		return err
+	}
+	resolver.path = append(resolver.path, packageName)
+	defer func() { resolver.path = resolver.path[:len(resolver.path)-1] }()
+
+	for _, dependency := range dependencies {
+		constraint, err := parseVersionConstraint(dependency.Version)
+		if err != nil {
+			return fmt.Errorf("dependency %v of %v: %v", dependency.Name, packageName, err)
+		}
+		for _, name := range resolver.path {
+			if name == dependency.Name {
+				return contracts.NewCodedError(contracts.ErrorCodeInvalidInput,
+					fmt.Errorf("dependency cycle %v -> %v", strings.Join(resolver.path, " -> "), dependency.Name))
+			}
+		}
+		if resolvedVersion, ok := resolver.resolved[dependency.Name]; ok {
+			if !constraint.satisfiedBy(resolvedVersion) {
+				return contracts.NewCodedError(contracts.ErrorCodeInvalidInput,
+					fmt.Errorf("%v requires %v %v, which conflicts with version %v required by another package", packageName, dependency.Name, constraint, resolvedVersion))
+			}
+			continue
+		}
+		if err = resolver.resolveDependency(packageName, dependency.Name, constraint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveDependency downloads the manifest of the dependency and resolves its own dependencies,
+// unless it is already installed in a version satisfying the constraint
+func (resolver *dependencyResolver) resolveDependency(packageName string, dependencyName string, constraint versionConstraint) (err error) {
+	trace := resolver.tracer.BeginSection(fmt.Sprintf("resolve dependency %v %v of %v", dependencyName, constraint, packageName))
+	defer trace.EndWithError(&err)
+
+	input := &ConfigurePackagePluginInput{Name: dependencyName, Version: constraint.versionToDownload(), Action: InstallAction}
+	isDocumentArchive := false
+	service, err := resolver.plugin.packageServiceSelector(resolver.tracer, input, resolver.plugin.localRepository, resolver.appConfig, resolver.plugin.birdwatcherfacade, &isDocumentArchive)
+	if err != nil {
+		return err
+	}
+	name, version := service.GetPackageArnAndVersion(input.Name, input.Version)
+	packageArn, manifestVersion, isSameAsCache, err := service.DownloadManifest(resolver.ctx, resolver.tracer, name, version)
+	if err != nil {
+		return fmt.Errorf("failed to download the manifest of dependency %v: %v", dependencyName, err)
+	}
+
+	repository := resolver.plugin.localRepository
+	installedVersion := repository.GetInstalledVersion(resolver.tracer, packageArn)
+	if state, _ := repository.GetInstallState(resolver.tracer, packageArn); state == localpackages.Installed && installedVersion != "" && constraint.satisfiedBy(installedVersion) {
+		trace.AppendInfof("%v %v is installed", dependencyName, installedVersion)
+		resolver.resolved[dependencyName] = installedVersion
+		return nil
+	}
+	if !constraint.satisfiedBy(manifestVersion) {
+		return contracts.NewCodedError(contracts.ErrorCodeInvalidInput,
+			fmt.Errorf("no version of dependency %v satisfies %v, the available version is %v", dependencyName, constraint, manifestVersion))
+	}
+	resolver.resolved[dependencyName] = manifestVersion
+
+	if err = resolver.resolve(service, dependencyName, packageArn, manifestVersion); err != nil {
+		return err
+	}
+	trace.AppendInfof("%v %v will be installed", dependencyName, manifestVersion)
+	resolver.installs = append(resolver.installs, dependencyInstall{
+		name:          dependencyName,
+		packageArn:    packageArn,
+		version:       manifestVersion,
+		isSameAsCache: isSameAsCache,
+		service:       service,
+	})
+	return nil
+}
+
+// installDependencies installs the dependencies in order, it stops at the first dependency that fails
+// or requires a reboot, the dependencies are resolved again when the document resumes after the reboot
+func (p *Plugin) installDependencies(
+	ctx gocontext.Context,
+	context context.T,
+	tracer trace.Tracer,
+	config contracts.Configuration,
+	antiRollback bool,
+	installs []dependencyInstall,
+	output *trace.PluginOutputTrace) {
+
+	for _, dependency := range installs {
+		dependencyTrace := tracer.BeginSection(fmt.Sprintf("install dependency %v %v", dependency.name, dependency.version))
+		dependencyOutput := &trace.PluginOutputTrace{Tracer: tracer}
+		p.installDependency(ctx, context, tracer, config, antiRollback, dependency, dependencyOutput)
+		dependencyTrace.WithExitcode(int64(dependencyOutput.GetExitCode()))
+
+		switch status := dependencyOutput.GetStatus(); {
+		case status == contracts.ResultStatusSuccess:
+			dependencyTrace.End()
+		case status.IsReboot():
+			dependencyTrace.AppendInfof("%v %v requires a reboot", dependency.name, dependency.version).End()
+			output.MarkAsSuccessWithReboot()
+			return
+		default:
+			dependencyTrace.AppendErrorf("failed to install dependency %v %v", dependency.name, dependency.version).End()
+			output.SetErrorCode(dependencyOutput.GetErrorCode())
+			output.MarkAsFailed(nil, nil)
+			return
+		}
+	}
+}
+
+// installDependency installs one dependency like the package requested by the document
+func (p *Plugin) installDependency(
+	ctx gocontext.Context,
+	context context.T,
+	tracer trace.Tracer,
+	config contracts.Configuration,
+	antiRollback bool,
+	dependency dependencyInstall,
+	output *trace.PluginOutputTrace) {
+
+	if err := p.localRepository.LockPackage(tracer, dependency.packageArn, InstallAction); err != nil {
+		tracer.CurrentTrace().WithError(err)
+		output.MarkAsFailed(nil, nil)
+		return
+	}
+	defer p.localRepository.UnlockPackage(tracer, dependency.packageArn)
+
+	input := &ConfigurePackagePluginInput{Name: dependency.name, Version: dependency.version, Action: InstallAction}
+	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		ctx,
+		tracer,
+		config,
+		p.localRepository,
+		dependency.service,
+		input,
+		dependency.packageArn,
+		dependency.version,
+		dependency.isSameAsCache,
+		antiRollback,
+		output)
+	if output.GetStatus() == contracts.ResultStatusFailed || output.GetStatus() == contracts.ResultStatusSuccess {
+		return
+	}
+	if !checkAlreadyInstalled(tracer, context, p.localRepository, installedVersion, installState, inst, uninst, output) || !dependency.isSameAsCache {
+		executeConfigurePackage(tracer, context, p.localRepository, inst, uninst, installState, output)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// dependencyServiceMock returns the service of a package whose latest version has the dependencies
+func dependencyServiceMock(name string, version string, dependencies ...packageservice.Dependency) *serviceMock.Mock {
+	service := &serviceMock.Mock{}
+	service.On("GetPackageArnAndVersion", name, mock.Anything).Return(name, version)
+	service.On("DownloadManifest", mock.Anything, mock.Anything, name, mock.Anything).Return(name, version, false, nil)
+	service.On("GetDependencies", mock.Anything, name, version).Return(dependencies, nil)
+	return service
+}
+
+// dependencyPlugin returns a plugin selecting the service of each package by name
+func dependencyPlugin(repository localpackages.Repository, services map[string]*serviceMock.Mock) *Plugin {
+	return &Plugin{
+		localRepository: repository,
+		packageServiceSelector: func(tracer trace.Tracer, input *ConfigurePackagePluginInput, localrepo localpackages.Repository, appCfg *appconfig.SsmagentConfig, bwfacade facade.BirdwatcherFacade, isDocumentArchive *bool) (packageservice.PackageService, error) {
+			if service, ok := services[input.Name]; ok {
+				return service, nil
+			}
+			return nil, errors.New("unknown package " + input.Name)
+		},
+	}
+}
+
+func emptyRepositoryMock() *repoMock.MockedRepository {
+	repository := &repoMock.MockedRepository{}
+	repository.On("GetInstalledVersion", mock.Anything, mock.Anything).Return("")
+	repository.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.None, "")
+	return repository
+}
+
+func installedNames(installs []dependencyInstall) []string {
+	var names []string
+	for _, install := range installs {
+		names = append(names, install.name+" "+install.version)
+	}
+	return names
+}
+
+func TestVersionConstraint(t *testing.T) {
+	data := []struct {
+		constraint string
+		version    string
+		satisfied  bool
+		download   string
+	}{
+		{"", "1.0.0", true, "latest"},
+		{"latest", "1.0.0", true, "latest"},
+		{"1.2.0", "1.2.0", true, "1.2.0"},
+		{"1.2.0", "1.2.1", false, "1.2.0"},
+		{"=1.2.0", "1.2.0", true, "1.2.0"},
+		{">=1.2.0", "1.10.0", true, "latest"},
+		{">=1.2.0,<2.0.0", "2.0.0", false, "latest"},
+		{">= 1.2.0, < 2.0.0", "1.9.9", true, "latest"},
+		{">1.2.0", "1.2.0", false, "latest"},
+		{"<=1.2.0", "1.2.0", true, "latest"},
+		{"!=1.2.0", "1.2.0", false, "latest"},
+	}
+	for _, testdata := range data {
+		constraint, err := parseVersionConstraint(testdata.constraint)
+
+		assert.NoError(t, err, testdata.constraint)
+		assert.Equal(t, testdata.satisfied, constraint.satisfiedBy(testdata.version), testdata.constraint)
+		assert.Equal(t, testdata.download, constraint.versionToDownload(), testdata.constraint)
+	}
+
+	for _, invalid := range []string{">=", "1.0.0,", ">=1.0.0,,<2.0.0"} {
+		_, err := parseVersionConstraint(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestResolveDependenciesOrder(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	app := dependencyServiceMock("app", "1.0.0", packageservice.Dependency{Name: "b", Version: ">=1.0"}, packageservice.Dependency{Name: "c"})
+	b := dependencyServiceMock("b", "1.5", packageservice.Dependency{Name: "c", Version: "2.0"})
+	c := dependencyServiceMock("c", "2.0")
+	plugin := dependencyPlugin(emptyRepositoryMock(), map[string]*serviceMock.Mock{"app": app, "b": b, "c": c})
+
+	installs, err := plugin.resolveDependencies(gocontext.Background(), tracer, nil, app, "app", "app", "1.0.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c 2.0", "b 1.5"}, installedNames(installs))
+	c.AssertNumberOfCalls(t, "DownloadManifest", 1)
+}
+
+func TestResolveDependenciesCycle(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	app := dependencyServiceMock("app", "1.0.0", packageservice.Dependency{Name: "b"})
+	b := dependencyServiceMock("b", "1.5", packageservice.Dependency{Name: "app"})
+	plugin := dependencyPlugin(emptyRepositoryMock(), map[string]*serviceMock.Mock{"app": app, "b": b})
+
+	_, err := plugin.resolveDependencies(gocontext.Background(), tracer, nil, app, "app", "app", "1.0.0")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "app -> b -> app")
+	assert.Equal(t, contracts.ErrorCodeInvalidInput, contracts.ErrorCodeOf(err))
+}
+
+func TestResolveDependenciesConflict(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	app := dependencyServiceMock("app", "1.0.0", packageservice.Dependency{Name: "b"}, packageservice.Dependency{Name: "c", Version: "<2.0"})
+	b := dependencyServiceMock("b", "1.5", packageservice.Dependency{Name: "c", Version: "2.0"})
+	c := dependencyServiceMock("c", "2.0")
+	plugin := dependencyPlugin(emptyRepositoryMock(), map[string]*serviceMock.Mock{"app": app, "b": b, "c": c})
+
+	_, err := plugin.resolveDependencies(gocontext.Background(), tracer, nil, app, "app", "app", "1.0.0")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "conflicts")
+}
+
+func TestResolveDependenciesUnavailableVersion(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	app := dependencyServiceMock("app", "1.0.0", packageservice.Dependency{Name: "b", Version: ">=2.0"})
+	b := dependencyServiceMock("b", "1.5")
+	plugin := dependencyPlugin(emptyRepositoryMock(), map[string]*serviceMock.Mock{"app": app, "b": b})
+
+	_, err := plugin.resolveDependencies(gocontext.Background(), tracer, nil, app, "app", "app", "1.0.0")
+
+	assert.Error(t, err)
+}
+
+func TestResolveDependenciesInstalled(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	app := dependencyServiceMock("app", "1.0.0", packageservice.Dependency{Name: "b", Version: ">=1.0"})
+	b := dependencyServiceMock("b", "1.5", packageservice.Dependency{Name: "c"})
+	repository := &repoMock.MockedRepository{}
+	repository.On("GetInstalledVersion", mock.Anything, "b").Return("1.2")
+	repository.On("GetInstallState", mock.Anything, "b").Return(localpackages.Installed, "1.2")
+	plugin := dependencyPlugin(repository, map[string]*serviceMock.Mock{"app": app, "b": b})
+
+	installs, err := plugin.resolveDependencies(gocontext.Background(), tracer, nil, app, "app", "app", "1.0.0")
+
+	assert.NoError(t, err)
+	assert.Empty(t, installs)
+	// the dependencies of an installed dependency are not resolved again
+	b.AssertNotCalled(t, "GetDependencies", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInstallDependenciesStopsAtFailure(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	repository := &repoMock.MockedRepository{}
+	repository.On("LockPackage", mock.Anything, "b", InstallAction).Return(errors.New("b is locked"))
+	plugin := dependencyPlugin(repository, nil)
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	plugin.installDependencies(gocontext.Background(), contextMock, tracer, contracts.Configuration{}, false,
+		[]dependencyInstall{{name: "b", packageArn: "b", version: "1.5"}, {name: "c", packageArn: "c", version: "2.0"}}, output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	repository.AssertNotCalled(t, "LockPackage", mock.Anything, "c", mock.Anything)
+}
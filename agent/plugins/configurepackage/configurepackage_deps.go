@@ -44,7 +44,7 @@ func (fileSysDepImp) WriteFile(filename string, content string) error {
 }
 
 func (fileSysDepImp) Uncompress(src, dest string) error {
-	return fileutil.Unzip(src, dest)
+	return fileutil.ExtractArchive(src, dest)
 }
 
 func (fileSysDepImp) RemoveAll(path string) error {
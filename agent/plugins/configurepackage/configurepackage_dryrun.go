@@ -0,0 +1,152 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+var getDiskSpaceInfo = fileutil.GetDiskSpaceInfo
+
+// dryRun reports what the action would do, the manifests are downloaded and checked but the artifact is not
+// downloaded and the package scripts are not run. The artifact of an install run in simulation is downloaded and scanned
+// too, then deleted. The dry run fails when the action would fail before running them.
+// It returns the installs and uninstalls the action would make.
+func (p *Plugin) dryRun(
+	ctx gocontext.Context,
+	tracer trace.Tracer,
+	appConfig *appconfig.SsmagentConfig,
+	packageService packageservice.PackageService,
+	input *ConfigurePackagePluginInput,
+	packageArn string,
+	manifestVersion string,
+	output contracts.PluginOutputter) (changes []string) {
+
+	trace := tracer.BeginSection(fmt.Sprintf("dry run %v of %v", input.Action, input.Name))
+	var err error
+	switch input.Action {
+	case InstallAction:
+		changes, err = p.dryRunInstall(ctx, tracer, appConfig, packageService, input, packageArn, manifestVersion)
+	case UninstallAction:
+		changes = dryRunUninstall(tracer, p.localRepository, input, packageArn)
+	default:
+		err = contracts.NewCodedError(contracts.ErrorCodeInvalidInput, fmt.Errorf("unsupported action: %v", input.Action))
+	}
+	if err != nil {
+		trace.WithError(err).End()
+		output.MarkAsFailed(nil, nil)
+		return nil
+	}
+	trace.End()
+	output.MarkAsSucceeded()
+	return changes
+}
+
+// dryRunInstall checks the version to install can be installed on this instance and reports the files and
+// dependencies that would be installed
+func (p *Plugin) dryRunInstall(
+	ctx gocontext.Context,
+	tracer trace.Tracer,
+	appConfig *appconfig.SsmagentConfig,
+	packageService packageservice.PackageService,
+	input *ConfigurePackagePluginInput,
+	packageArn string,
+	version string) (changes []string, err error) {
+
+	trace := tracer.CurrentTrace()
+	installedVersion, installState := getVersionToInstall(tracer, p.localRepository, packageArn)
+	if installState == localpackages.Installed && installedVersion == version {
+		trace.AppendInfof("%v %v is already installed, it would be validated and installed again if it is not valid", input.Name, version)
+		return nil, nil
+	}
+	antiRollback := appConfig != nil && appConfig.Birdwatcher.AntiRollback
+	if err = checkRollback(tracer, p.localRepository, input, packageArn, installedVersion, installState, version, antiRollback); err != nil {
+		return nil, err
+	}
+
+	artifactInfo, err := packageService.DescribeArtifact(ctx, tracer, packageArn, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(artifactInfo.Checksums) == 0 {
+		trace.AppendErrorf("%v declares no checksum, its download would not be verified", artifactInfo.FileName)
+	}
+	if artifactInfo.Size > 0 {
+		diskSpace, err := getDiskSpaceInfo()
+		if err != nil {
+			trace.AppendInfof("Failed to check the disk space: %v", err)
+		} else if diskSpace.AvailBytes < artifactInfo.Size {
+			return nil, contracts.NewCodedError(contracts.ErrorCodeDiskFull,
+				fmt.Errorf("%v needs %v bytes, only %v bytes are available", artifactInfo.FileName, artifactInfo.Size, diskSpace.AvailBytes))
+		}
+	}
+
+	dependencies, err := p.resolveDependencies(ctx, tracer, appConfig, packageService, input.Name, packageArn, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		changes = append(changes, fmt.Sprintf("Install dependency %v %v", dependency.name, dependency.version))
+		trace.AppendInfof("Would install dependency %v %v", dependency.name, dependency.version)
+	}
+
+	if installedVersion != "" && installState != localpackages.None {
+		changes = append(changes, fmt.Sprintf("Upgrade %v from %v to %v", input.Name, installedVersion, version))
+		trace.AppendInfof("Would upgrade %v from %v to %v", input.Name, installedVersion, version)
+	} else {
+		changes = append(changes, fmt.Sprintf("Install %v %v", input.Name, version))
+		trace.AppendInfof("Would install %v %v", input.Name, version)
+	}
+	trace.AppendInfof("Would download %v (%v bytes)", artifactInfo.FileName, artifactInfo.Size)
+	for _, attachment := range artifactInfo.Attachments {
+		trace.AppendInfof("Would download attachment %v", attachment)
+	}
+	if input.simulation {
+		filePath, err := packageService.DownloadArtifact(ctx, tracer, packageArn, version)
+		if err != nil {
+			return nil, err
+		}
+		defer filesysdep.RemoveAll(filePath)
+		if err = scanArtifact(ctx, tracer, artifactScanners(), packageArn, version, filePath); err != nil {
+			return nil, err
+		}
+		trace.AppendInfof("Downloaded %v, it is not installed in simulation", artifactInfo.FileName)
+		changes = append(changes, fmt.Sprintf("Download %v", artifactInfo.FileName))
+	}
+	return changes, nil
+}
+
+// dryRunUninstall reports the version that would be uninstalled
+func dryRunUninstall(tracer trace.Tracer, repository localpackages.Repository, input *ConfigurePackagePluginInput, packageArn string) (changes []string) {
+	trace := tracer.CurrentTrace()
+	installedVersion, installState := getVersionToUninstall(tracer, repository, packageArn)
+	version := input.Version
+	if version == "" || packageservice.IsLatest(version) {
+		version = installedVersion
+	}
+	if installedVersion == "" || version != installedVersion || installState == localpackages.None || installState == localpackages.Uninstalled {
+		trace.AppendInfof("%v %v is not installed, nothing would be uninstalled", input.Name, version)
+		return nil
+	}
+	trace.AppendInfof("Would uninstall %v %v", input.Name, installedVersion)
+	return []string{fmt.Sprintf("Uninstall %v %v", input.Name, installedVersion)}
+}
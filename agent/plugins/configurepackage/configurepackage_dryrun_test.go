@@ -0,0 +1,116 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// dryRunServiceMock returns the service of a package whose artifact has the size
+func dryRunServiceMock(size int64, dependencies ...packageservice.Dependency) *serviceMock.Mock {
+	service := dependencyServiceMock("app", "2.0.0", dependencies...)
+	service.On("DescribeArtifact", mock.Anything, mock.Anything, "app", "2.0.0").Return(&packageservice.ArtifactInfo{
+		FileName:  "app.zip",
+		Size:      size,
+		Checksums: map[string]string{"sha256": "abc"},
+	}, nil)
+	return service
+}
+
+func installedRepositoryMock(version string) *repoMock.MockedRepository {
+	repository := &repoMock.MockedRepository{}
+	repository.On("GetInstalledVersion", mock.Anything, mock.Anything).Return(version)
+	repository.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.Installed, version)
+	return repository
+}
+
+func setDiskSpace(available int64) func() {
+	getDiskSpaceInfo = func() (fileutil.DiskSpaceInfo, error) {
+		return fileutil.DiskSpaceInfo{AvailBytes: available}, nil
+	}
+	return func() { getDiskSpaceInfo = fileutil.GetDiskSpaceInfo }
+}
+
+func TestDryRunInstall(t *testing.T) {
+	defer setDiskSpace(1000)()
+	tracer := trace.NewTracer(contextMock.Log())
+	tracer.BeginSection("test")
+	app := dryRunServiceMock(100, packageservice.Dependency{Name: "b", Version: ">=1.5"})
+	b := dependencyServiceMock("b", "1.5")
+	plugin := dependencyPlugin(installedRepositoryMock("1.0.0"), map[string]*serviceMock.Mock{"app": app, "b": b})
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	changes := plugin.dryRun(gocontext.Background(), tracer, nil, app, &ConfigurePackagePluginInput{Name: "app", Action: InstallAction}, "app", "2.0.0", output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, []string{"Install dependency b 1.5", "Upgrade app from 1.0.0 to 2.0.0"}, changes)
+	stdout := tracer.ToPluginOutput().GetStdout()
+	assert.Contains(t, stdout, "Would upgrade app from 1.0.0 to 2.0.0")
+	assert.Contains(t, stdout, "Would download app.zip (100 bytes)")
+	assert.Contains(t, stdout, "Would install dependency b 1.5")
+	app.AssertNotCalled(t, "DownloadArtifact", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDryRunInstallAlreadyInstalled(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	tracer.BeginSection("test")
+	app := dryRunServiceMock(100)
+	plugin := dependencyPlugin(installedRepositoryMock("2.0.0"), map[string]*serviceMock.Mock{"app": app})
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	changes := plugin.dryRun(gocontext.Background(), tracer, nil, app, &ConfigurePackagePluginInput{Name: "app", Action: InstallAction}, "app", "2.0.0", output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Empty(t, changes)
+	assert.Contains(t, tracer.ToPluginOutput().GetStdout(), "app 2.0.0 is already installed")
+	app.AssertNotCalled(t, "DescribeArtifact", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDryRunInstallInsufficientDiskSpace(t *testing.T) {
+	defer setDiskSpace(50)()
+	tracer := trace.NewTracer(contextMock.Log())
+	tracer.BeginSection("test")
+	app := dryRunServiceMock(100)
+	plugin := dependencyPlugin(emptyRepositoryMock(), map[string]*serviceMock.Mock{"app": app})
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	plugin.dryRun(gocontext.Background(), tracer, nil, app, &ConfigurePackagePluginInput{Name: "app", Action: InstallAction}, "app", "2.0.0", output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, contracts.ErrorCodeDiskFull, output.GetErrorCode())
+}
+
+func TestDryRunUninstall(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	tracer.BeginSection("test")
+	plugin := dependencyPlugin(installedRepositoryMock("1.0.0"), nil)
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	changes := plugin.dryRun(gocontext.Background(), tracer, nil, nil, &ConfigurePackagePluginInput{Name: "app", Action: UninstallAction}, "app", "1.0.0", output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, []string{"Uninstall app 1.0.0"}, changes)
+	assert.Contains(t, tracer.ToPluginOutput().GetStdout(), "Would uninstall app 1.0.0")
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// AlreadyCompliant is reported by an ensure action that found the desired version installed
+const AlreadyCompliant = "AlreadyCompliant"
+
+// ensure compares the installed version of the package with the version of its manifest, latest being resolved
+// when the manifest was downloaded. It returns true and marks the output as succeeded when the version is installed
+// from the same manifest, nothing is downloaded, installed or reported then. Otherwise the action becomes an install.
+func (p *Plugin) ensure(
+	tracer trace.Tracer,
+	input *ConfigurePackagePluginInput,
+	packageArn string,
+	manifestVersion string,
+	isSameAsCache bool,
+	output contracts.PluginOutputter) (compliant bool) {
+
+	trace := tracer.BeginSection(fmt.Sprintf("check %v %v is installed", input.Name, manifestVersion))
+	defer trace.End()
+
+	installedVersion, installState := getVersionToInstall(tracer, p.localRepository, packageArn)
+	switch {
+	case installedVersion == "" || installState == localpackages.None || installState == localpackages.Uninstalled:
+		trace.AppendInfof("%v is not installed, installing %v", input.Name, manifestVersion)
+	case installedVersion != manifestVersion:
+		trace.AppendInfof("%v %v is installed, upgrading to %v", input.Name, installedVersion, manifestVersion)
+	case installState != localpackages.Installed:
+		trace.AppendInfof("%v %v is in state %v, installing it again", input.Name, installedVersion, installState)
+	case !isSameAsCache:
+		trace.AppendInfof("the manifest of %v %v changed, installing it again", input.Name, installedVersion)
+	default:
+		trace.AppendInfof("%v: %v %v is installed", AlreadyCompliant, input.Name, installedVersion)
+		output.MarkAsSucceeded()
+		return true
+	}
+	input.Action = InstallAction
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEnsure(t *testing.T) {
+	for _, test := range []struct {
+		installedVersion string
+		installState     localpackages.InstallState
+		isSameAsCache    bool
+		compliant        bool
+	}{
+		{"2.0.0", localpackages.Installed, true, true},
+		{"2.0.0", localpackages.Installed, false, false},
+		{"1.0.0", localpackages.Installed, true, false},
+		{"2.0.0", localpackages.Failed, true, false},
+		{"", localpackages.None, true, false},
+	} {
+		repository := &repoMock.MockedRepository{}
+		repository.On("GetInstalledVersion", mock.Anything, "app").Return(test.installedVersion)
+		repository.On("GetInstallState", mock.Anything, "app").Return(test.installState, test.installedVersion)
+		tracer := trace.NewTracer(contextMock.Log())
+		tracer.BeginSection("test")
+		output := &trace.PluginOutputTrace{Tracer: tracer}
+		input := &ConfigurePackagePluginInput{Name: "app", Action: EnsureAction}
+
+		compliant := (&Plugin{localRepository: repository}).ensure(tracer, input, "app", "2.0.0", test.isSameAsCache, output)
+
+		assert.Equal(t, test.compliant, compliant, "%+v", test)
+		if test.compliant {
+			assert.Equal(t, EnsureAction, input.Action)
+			assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+			assert.Contains(t, tracer.ToPluginOutput().GetStdout(), AlreadyCompliant)
+		} else {
+			assert.Equal(t, InstallAction, input.Action)
+			assert.NotEqual(t, contracts.ResultStatusSuccess, output.GetStatus())
+		}
+	}
+}
+
+func TestExecuteEnsureAlreadyCompliant(t *testing.T) {
+	app := &serviceMock.Mock{}
+	app.On("GetPackageArnAndVersion", "app", mock.Anything).Return("app", "2.0.0")
+	app.On("DownloadManifest", mock.Anything, mock.Anything, "app", mock.Anything).Return("app", "2.0.0", true, nil)
+	plugin := dependencyPlugin(installedRepositoryMock("2.0.0"), map[string]*serviceMock.Mock{"app": batchServiceMock(app)})
+	output := &iohandler.DefaultIOHandler{}
+
+	plugin.execute(contextMock, contracts.Configuration{Properties: &ConfigurePackagePluginInput{Name: "app", Action: EnsureAction}}, createMockCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "AlreadyCompliant: app 2.0.0 is installed")
+	assert.Contains(t, output.GetStdout(), manifestChangeMessage("app", "2.0.0", true))
+	app.AssertNotCalled(t, "ReportResult", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExecuteEnsureDryRunReportsUpgrade(t *testing.T) {
+	defer setDiskSpace(1000)()
+	plugin := dependencyPlugin(installedRepositoryMock("1.0.0"), map[string]*serviceMock.Mock{"app": batchServiceMock(dryRunServiceMock(100))})
+	output := &iohandler.DefaultIOHandler{}
+
+	plugin.execute(contextMock, contracts.Configuration{Properties: &ConfigurePackagePluginInput{Name: "app", Action: EnsureAction, DryRun: true}}, createMockCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, []string{"Upgrade app from 1.0.0 to 2.0.0"}, output.GetCheckChanges())
+}
@@ -49,6 +49,11 @@ func executeConfigurePackage(
 		executeUninstall(tracer, context, repository, uninst, inst, true, output)
 	default:
 		if uninst != nil {
+			if inst != nil && initialInstallState != localpackages.Upgrading && initialInstallState != localpackages.Uninstalling {
+				// the installed version is kept aside until the upgrade completes, its uninstall may remove its files,
+				// an upgrade resumed after a reboot keeps the snapshot taken before the uninstall started
+				snapshotPackage(tracer, repository, uninst)
+			}
 			executeUninstall(tracer, context, repository, inst, uninst, false, output)
 		} else {
 			executeInstall(tracer, context, repository, inst, uninst, false, output)
@@ -81,6 +86,7 @@ func executeInstall(
 	defer installtrace.End()
 
 	if isRollback {
+		restorePackage(tracer, repository, inst)
 		setNewInstallState(tracer, repository, inst, localpackages.RollbackInstall)
 	} else {
 		setNewInstallState(tracer, repository, inst, localpackages.Installing)
@@ -106,6 +112,9 @@ func executeInstall(
 			output.MarkAsFailed(nil, nil)
 			// TODO: Remove from repository if this isn't the last successfully installed version?  Run uninstall to clean up?
 			setNewInstallState(tracer, repository, inst, localpackages.Failed)
+			if isRollback {
+				deleteSnapshot(tracer, repository, inst)
+			}
 			return
 		}
 		// Execute rollback
@@ -114,6 +123,7 @@ func executeInstall(
 	}
 	if uninst != nil {
 		cleanupAfterUninstall(tracer, repository, uninst, output)
+		deleteSnapshot(tracer, repository, inst)
 	}
 	if isRollback {
 		installtrace.AppendInfof("Failed to install %v %v, successfully rolled back to %v %v", uninst.PackageName(), uninst.Version(), inst.PackageName(), inst.Version())
@@ -178,6 +188,45 @@ func executeUninstall(
 	output.MarkAsSucceeded()
 }
 
+// snapshotPackage copies the installed version aside before an upgrade and logs any error, the upgrade proceeds
+// without a snapshot and a rollback reinstalls the version left in the repository
+func snapshotPackage(tracer trace.Tracer, repository localpackages.Repository, uninst installer.Installer) {
+	trace := tracer.BeginSection(fmt.Sprintf("snapshot %s/%s", uninst.PackageName(), uninst.Version()))
+
+	if err := repository.SnapshotPackage(tracer, uninst.PackageName(), uninst.Version()); err != nil {
+		trace.AppendErrorf("Failed to snapshot the installed version: %v", err)
+	}
+
+	trace.End()
+}
+
+// restorePackage replaces the version to roll back to with its snapshot and logs any error
+func restorePackage(tracer trace.Tracer, repository localpackages.Repository, inst installer.Installer) {
+	trace := tracer.BeginSection(fmt.Sprintf("restore snapshot %s/%s", inst.PackageName(), inst.Version()))
+
+	if err := repository.RestorePackage(tracer, inst.PackageName(), inst.Version()); err != nil {
+		trace.AppendInfof("Rolling back without a snapshot: %v", err)
+	}
+
+	trace.End()
+}
+
+// deleteSnapshot removes the snapshot of a package once an upgrade or its rollback is complete
+func deleteSnapshot(tracer trace.Tracer, repository localpackages.Repository, inst installer.Installer) {
+	if err := repository.DeleteSnapshot(tracer, inst.PackageName()); err != nil {
+		tracer.CurrentTrace().AppendErrorf("Failed to delete the snapshot of %v: %v", inst.PackageName(), err)
+	}
+}
+
+// isRolledBack returns true when an install failed and the version installed before was installed again
+func isRolledBack(tracer trace.Tracer, repository localpackages.Repository, packageArn string, output contracts.PluginOutputter) bool {
+	if output.GetStatus() != contracts.ResultStatusFailed {
+		return false
+	}
+	state, _ := repository.GetInstallState(tracer, packageArn)
+	return state == localpackages.Installed
+}
+
 // cleanupAfterUninstall removes packages that are no longer needed in the repository
 func cleanupAfterUninstall(tracer trace.Tracer, repository localpackages.Repository, uninst installer.Installer, output contracts.PluginOutputter) {
 	trace := tracer.BeginSection(fmt.Sprintf("cleanup %s/%s", uninst.PackageName(), uninst.Version()))
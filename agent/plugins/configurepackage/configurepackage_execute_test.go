@@ -15,12 +15,15 @@
 package configurepackage
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
 	repository_mock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -47,6 +50,8 @@ func TestUpgrade(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installing).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("SnapshotPackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -66,6 +71,8 @@ func TestUpgradeFailedUninstall(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installing).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("SnapshotPackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -148,6 +155,9 @@ func TestRollback(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.RollbackInstall).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.2").Return(nil)
+	repoMock.On("SnapshotPackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("RestorePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -159,6 +169,44 @@ func TestRollback(t *testing.T) {
 	repoMock.AssertExpectations(t)
 }
 
+func TestRollbackWithoutSnapshot(t *testing.T) {
+	uninstallerMock := uninstallerSuccessWithRollbackMock("SsmTest", "0.0.1")
+	installerMock := installerFailedWithRollbackMock("SsmTest", "0.0.2")
+	repoMock := &repository_mock.MockedRepository{}
+	repoMock.On("SetInstallState", mock.Anything, "SsmTest", mock.Anything, mock.Anything).Return(nil)
+	repoMock.On("SnapshotPackage", mock.Anything, "SsmTest", "0.0.1").Return(errors.New("disk full"))
+	repoMock.On("RestorePackage", mock.Anything, "SsmTest", "0.0.1").Return(errors.New("no snapshot"))
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
+	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.2").Return(nil)
+	repoMock.On("GetInstallState", mock.Anything, "SsmTest").Return(localpackages.Installed, "0.0.1")
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	executeConfigurePackage(tracer, contextMock, repoMock, installerMock, uninstallerMock, localpackages.Installed, output)
+
+	// the previous version is installed again from the repository
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.True(t, isRolledBack(tracer, repoMock, "SsmTest", output))
+	installerMock.AssertExpectations(t)
+	uninstallerMock.AssertExpectations(t)
+	repoMock.AssertExpectations(t)
+}
+
+func TestIsRolledBack(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	repoMock := &repository_mock.MockedRepository{}
+	repoMock.On("GetInstallState", mock.Anything, "SsmTest").Return(localpackages.Failed, "0.0.2")
+	failed := &trace.PluginOutputTrace{Tracer: tracer}
+	failed.MarkAsFailed(nil, nil)
+	succeeded := &trace.PluginOutputTrace{Tracer: tracer}
+	succeeded.MarkAsSucceeded()
+
+	assert.False(t, isRolledBack(tracer, repoMock, "SsmTest", failed))
+	assert.False(t, isRolledBack(tracer, repoMock, "SsmTest", succeeded))
+}
+
 func TestRollbackFailed(t *testing.T) {
 	uninstallerMock := uninstallerSuccessWithFailedRollbackMock("SsmTest", "0.0.1")
 	installerMock := installerFailedWithRollbackMock("SsmTest", "0.0.2")
@@ -168,6 +216,9 @@ func TestRollbackFailed(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.RollbackUninstall).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.RollbackInstall).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.Failed).Return(nil)
+	repoMock.On("SnapshotPackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("RestorePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -246,6 +297,7 @@ func TestUpgradeAfterUninstallReboot(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installing).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -264,6 +316,7 @@ func TestUpgradeAfterInstallReboot(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installing).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.2", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -283,6 +336,8 @@ func TestRollbackAfterUninstallReboot(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.RollbackInstall).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.2").Return(nil)
+	repoMock.On("RestorePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -301,6 +356,8 @@ func TestRollbackAfterInstallReboot(t *testing.T) {
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.RollbackInstall).Return(nil)
 	repoMock.On("SetInstallState", mock.Anything, "SsmTest", "0.0.1", localpackages.Installed).Return(nil)
 	repoMock.On("RemovePackage", mock.Anything, "SsmTest", "0.0.2").Return(nil)
+	repoMock.On("RestorePackage", mock.Anything, "SsmTest", "0.0.1").Return(nil)
+	repoMock.On("DeleteSnapshot", mock.Anything, "SsmTest").Return(nil)
 	tracer := trace.NewTracer(log.NewMockLog())
 	tracer.BeginSection("test segment root")
 	output := &trace.PluginOutputTrace{Tracer: tracer}
@@ -0,0 +1,50 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/integrity"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// recordIntegrityBaseline records the checksums of the files of a freshly installed package, the integrity monitor
+// verifies the package against them. Failures are traced but do not fail the installation.
+func recordIntegrityBaseline(tracer trace.Tracer, config appconfig.PackageIntegrityCfg, integrityDirectory string, repository localpackages.Repository, packageName string, inst installer.Installer) {
+	if !integrity.IsMonitored(config, packageName, inst.PackageName()) {
+		return
+	}
+	var err error
+	trace := tracer.BeginSection(fmt.Sprintf("record integrity baseline of %v %v", inst.PackageName(), inst.Version()))
+	defer trace.EndWithError(&err)
+
+	var baseline integrity.Baseline
+	directory := repository.GetPackageDirectory(tracer, inst.PackageName(), inst.Version())
+	if baseline, err = integrity.NewBaseline(packageName, inst.PackageName(), inst.Version(), directory, time.Now()); err != nil {
+		return
+	}
+	err = integrity.WriteBaseline(integrityDirectory, baseline)
+}
+
+// deleteIntegrityBaseline stops the verification of an uninstalled package
+func deleteIntegrityBaseline(tracer trace.Tracer, integrityDirectory string, packageArn string) {
+	if err := integrity.DeleteBaseline(integrityDirectory, packageArn); err != nil {
+		tracer.CurrentTrace().AppendErrorf("failed to delete the integrity baseline of %v: %v", packageArn, err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/integrity"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordIntegrityBaseline(t *testing.T) {
+	packageDir, sbomDir, repo, inst, cleanup := setupSbomTest(t)
+	defer cleanup()
+	integrityDir := filepath.Join(filepath.Dir(sbomDir), "integrity")
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	config := appconfig.PackageIntegrityCfg{Enabled: true, Packages: []string{"MyPackage"}}
+	recordIntegrityBaseline(tracer, config, integrityDir, repo, "MyPackage", inst)
+
+	baselines, err := integrity.LoadBaselines(integrityDir)
+	assert.NoError(t, err)
+	assert.Len(t, baselines, 1)
+	assert.Equal(t, "MyPackage", baselines[0].PackageName)
+	assert.Equal(t, packageDir, baselines[0].Directory)
+	assert.Contains(t, baselines[0].Files, "install.sh")
+
+	deleteIntegrityBaseline(tracer, integrityDir, inst.PackageName())
+	baselines, err = integrity.LoadBaselines(integrityDir)
+	assert.NoError(t, err)
+	assert.Empty(t, baselines)
+}
+
+func TestRecordIntegrityBaselineNotMonitored(t *testing.T) {
+	_, sbomDir, repo, inst, cleanup := setupSbomTest(t)
+	defer cleanup()
+	integrityDir := filepath.Join(filepath.Dir(sbomDir), "integrity")
+
+	config := appconfig.PackageIntegrityCfg{Enabled: true, Packages: []string{"OtherPackage"}}
+	recordIntegrityBaseline(trace.NewTracer(log.NewMockLog()), config, integrityDir, repo, "MyPackage", inst)
+
+	_, err := os.Stat(integrityDir)
+	assert.True(t, os.IsNotExist(err))
+}
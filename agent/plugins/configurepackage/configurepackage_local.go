@@ -0,0 +1,101 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/birdwatcherservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/localarchive"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/twinj/uuid"
+)
+
+// NewLocalPlugin returns an instance of the plugin that installs the packages, and their dependencies, staged in the
+// local archive at source, a directory or file url laid out as <archive>/<package name>/<version>/, instead of the
+// packages of the service. It does not call the service, results are not reported to it.
+func NewLocalPlugin(source string) (*Plugin, error) {
+	location, err := localArchiveLocation(source)
+	if err != nil {
+		return nil, err
+	}
+	var plugin Plugin
+	plugin.localRepository = localpackages.NewRepository()
+	plugin.packageServiceSelector = func(tracer trace.Tracer, input *ConfigurePackagePluginInput, localrepo localpackages.Repository, appCfg *appconfig.SsmagentConfig, bwfacade facade.BirdwatcherFacade, isDocumentArchive *bool) (packageservice.PackageService, error) {
+		var signing appconfig.ManifestSigningCfg
+		if appCfg != nil {
+			signing = appCfg.Birdwatcher.ManifestSigning
+		}
+		tracer.CurrentTrace().AppendInfof("Package %v is installed from the local archive %v", input.Name, location)
+		*isDocumentArchive = false
+		return birdwatcherservice.NewLocalArchive(newManifestCache(appCfg), location, signing), nil
+	}
+	return &plugin, nil
+}
+
+// RunLocal runs the action of the input on a package of the local archive at source, the same way the plugin runs it
+// for a document, and returns the output of the plugin. The output of the package scripts is kept in orchestrationDir.
+func RunLocal(context context.T, source string, input ConfigurePackagePluginInput, orchestrationDir string) (*iohandler.DefaultIOHandler, error) {
+	plugin, err := NewLocalPlugin(source)
+	if err != nil {
+		return nil, err
+	}
+	log := context.Log()
+	runID := uuid.NewV4().String()
+	config := contracts.Configuration{
+		Properties:             input,
+		OrchestrationDirectory: orchestrationDir,
+		MessageId:              runID,
+		BookKeepingFileName:    runID,
+		PluginName:             Name(),
+		PluginID:               Name(),
+	}
+	output := iohandler.NewDefaultIOHandler(log, contracts.IOConfiguration{OrchestrationDirectory: orchestrationDir})
+	output.Init(log, Name())
+	plugin.Execute(context, config, task.NewChanneledCancelFlag(), output)
+	output.Close(log)
+	return output, nil
+}
+
+// localArchiveLocation checks the source is a directory or the file url of a directory
+func localArchiveLocation(source string) (string, error) {
+	path := source
+	// a scheme of a single letter is the drive of a windows path
+	if sourceURL, err := url.Parse(source); err == nil && len(sourceURL.Scheme) > 1 {
+		if sourceURL.Scheme != "file" {
+			return "", fmt.Errorf("source %v must be a directory or a file url, packages are not downloaded from %v urls", source, sourceURL.Scheme)
+		}
+		path = artifact.FilePathFromURL(sourceURL)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the local archive %v: %v", source, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("source %v must be the directory of the local archive, laid out as <package name>/<version>/%v", source, localarchive.ManifestFileName)
+	}
+	return filepath.Clean(path), nil
+}
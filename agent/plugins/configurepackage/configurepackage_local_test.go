@@ -0,0 +1,62 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLocalPlugin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localarchive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	cfg := appconfig.DefaultConfig()
+	cfg.Birdwatcher.ManifestCache.Backend = appconfig.ManifestCacheBackendMemory
+
+	for _, source := range []string{dir, (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()} {
+		plugin, err := NewLocalPlugin(source)
+		assert.NoError(t, err, source)
+
+		isDocumentArchive := true
+		service, err := plugin.packageServiceSelector(tracer, &ConfigurePackagePluginInput{Name: "s3://bucket/package"}, plugin.localRepository, &cfg, plugin.birdwatcherfacade, &isDocumentArchive)
+		assert.NoError(t, err)
+		assert.Equal(t, packageservice.PackageServiceName_local, service.PackageServiceName())
+		assert.False(t, isDocumentArchive)
+	}
+}
+
+func TestNewLocalPlugin_InvalidSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localarchive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	manifest := filepath.Join(dir, "manifest.json")
+	assert.NoError(t, ioutil.WriteFile(manifest, []byte("{}"), 0600))
+
+	for _, source := range []string{filepath.Join(dir, "missing"), manifest, "https://example.com/packages"} {
+		_, err := NewLocalPlugin(source)
+		assert.Error(t, err, source)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// prefetchedService returns the artifact of a package version downloaded ahead of its install,
+// any other download goes to the package service
+type prefetchedService struct {
+	packageservice.PackageService
+	packageName  string
+	version      string
+	artifactPath string
+}
+
+// DownloadArtifact returns the prefetched artifact the first time it is requested, the install deletes it once extracted
+func (service *prefetchedService) DownloadArtifact(ctx gocontext.Context, tracer trace.Tracer, packageName string, version string) (string, error) {
+	if service.artifactPath != "" && packageName == service.packageName && version == service.version {
+		artifactPath := service.artifactPath
+		service.artifactPath = ""
+		return artifactPath, nil
+	}
+	return service.PackageService.DownloadArtifact(ctx, tracer, packageName, version)
+}
+
+// discard deletes the prefetched artifact if no install used it
+func (service *prefetchedService) discard() {
+	if service.artifactPath != "" {
+		filesysdep.RemoveAll(service.artifactPath)
+		service.artifactPath = ""
+	}
+}
+
+// downloadParallelism returns the number of packages downloaded at the same time
+func downloadParallelism(appConfig *appconfig.SsmagentConfig) int {
+	if appConfig == nil {
+		return appconfig.DefaultPackageDownloadParallelism
+	}
+	return appConfig.Birdwatcher.DownloadParallelism
+}
+
+// prefetchArtifacts downloads the artifacts of the packages that must be downloaded into the repository, at most
+// parallelism at the same time, while the packages are still installed one at a time. It returns the package
+// services of the packages in the same order, returning the prefetched artifacts to the installs, or nil when there
+// is nothing to download in parallel. A failed download is not an error, the install downloads the package again
+// and reports the failure.
+func (p *Plugin) prefetchArtifacts(ctx gocontext.Context, tracer trace.Tracer, parallelism int, packages []dependencyInstall) []*prefetchedService {
+	if parallelism < 2 || len(packages) < 2 {
+		return nil
+	}
+
+	prefetchTrace := tracer.BeginSection(fmt.Sprintf("download %v packages, %v at a time", len(packages), parallelism))
+	defer prefetchTrace.End()
+
+	services := make([]*prefetchedService, len(packages))
+	var downloads []int
+	for i, pkg := range packages {
+		services[i] = &prefetchedService{PackageService: pkg.service, packageName: pkg.packageArn, version: pkg.version}
+		if needsRefresh(tracer, p.localRepository, pkg.packageArn, pkg.version, pkg.isSameAsCache) {
+			downloads = append(downloads, i)
+		}
+	}
+	if len(downloads) < 2 {
+		prefetchTrace.AppendDebugf("%v packages to download", len(downloads))
+		return services
+	}
+
+	// the tracer is not safe for concurrent use, every download has its own
+	log := tracer.CurrentTrace().Logger
+	errs := make([]error, len(packages))
+	slots := make(chan struct{}, parallelism)
+	var wait sync.WaitGroup
+	for _, i := range downloads {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			downloadTracer := trace.NewTracer(log)
+			defer downloadTracer.BeginSection(fmt.Sprintf("prefetch %v %v", packages[i].name, packages[i].version)).End()
+			services[i].artifactPath, errs[i] = packages[i].service.DownloadArtifact(ctx, downloadTracer, packages[i].packageArn, packages[i].version)
+		}(i)
+	}
+	wait.Wait()
+
+	for _, i := range downloads {
+		if errs[i] != nil {
+			prefetchTrace.AppendInfof("failed to download %v %v ahead of its install: %v", packages[i].name, packages[i].version, errs[i])
+		} else {
+			prefetchTrace.AppendDebugf("downloaded %v %v", packages[i].name, packages[i].version)
+		}
+	}
+	return services
+}
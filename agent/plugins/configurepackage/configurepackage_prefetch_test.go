@@ -0,0 +1,133 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func artifactServiceMock(name string, version string, artifactPath string, err error) *serviceMock.Mock {
+	service := &serviceMock.Mock{}
+	service.On("DownloadArtifact", mock.Anything, mock.Anything, name, version).Return(artifactPath, err)
+	return service
+}
+
+func TestPrefetchArtifacts(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	repository := &repoMock.MockedRepository{}
+	repository.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.None, "")
+	repository.On("ValidatePackage", mock.Anything, "a", "1.0").Return(errors.New("not found"))
+	repository.On("ValidatePackage", mock.Anything, "b", "2.0").Return(errors.New("not found"))
+	repository.On("ValidatePackage", mock.Anything, "c", "3.0").Return(errors.New("not found"))
+	repository.On("ValidatePackage", mock.Anything, "d", "4.0").Return(nil)
+	serviceA := artifactServiceMock("a", "1.0", "/download/a", nil)
+	serviceB := artifactServiceMock("b", "2.0", "", errors.New("connection reset"))
+	serviceC := artifactServiceMock("c", "3.0", "/download/c", nil)
+	serviceD := &serviceMock.Mock{}
+	plugin := &Plugin{localRepository: repository}
+
+	services := plugin.prefetchArtifacts(gocontext.Background(), tracer, 2, []dependencyInstall{
+		{name: "a", packageArn: "a", version: "1.0", service: serviceA},
+		{name: "b", packageArn: "b", version: "2.0", service: serviceB},
+		{name: "c", packageArn: "c", version: "3.0", service: serviceC},
+		{name: "d", packageArn: "d", version: "4.0", isSameAsCache: true, service: serviceD},
+	})
+
+	assert.Len(t, services, 4)
+	assert.Equal(t, "/download/a", services[0].artifactPath)
+	assert.Equal(t, "", services[1].artifactPath)
+	assert.Equal(t, "/download/c", services[2].artifactPath)
+	assert.Equal(t, "", services[3].artifactPath)
+	serviceA.AssertExpectations(t)
+	serviceB.AssertExpectations(t)
+	serviceC.AssertExpectations(t)
+	serviceD.AssertNotCalled(t, "DownloadArtifact", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPrefetchArtifactsNothingInParallel(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	repository := &repoMock.MockedRepository{}
+	plugin := &Plugin{localRepository: repository}
+	packages := []dependencyInstall{
+		{name: "a", packageArn: "a", version: "1.0", service: &serviceMock.Mock{}},
+		{name: "b", packageArn: "b", version: "2.0", service: &serviceMock.Mock{}},
+	}
+
+	assert.Nil(t, plugin.prefetchArtifacts(gocontext.Background(), tracer, 1, packages))
+	assert.Nil(t, plugin.prefetchArtifacts(gocontext.Background(), tracer, 3, packages[:1]))
+	repository.AssertNotCalled(t, "ValidatePackage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPrefetchArtifactsSingleDownload(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	repository := &repoMock.MockedRepository{}
+	repository.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.Installed, "1.0")
+	repository.On("ValidatePackage", mock.Anything, "a", "1.0").Return(nil)
+	repository.On("ValidatePackage", mock.Anything, "b", "2.0").Return(errors.New("not found"))
+	service := &serviceMock.Mock{}
+	plugin := &Plugin{localRepository: repository}
+
+	services := plugin.prefetchArtifacts(gocontext.Background(), tracer, 3, []dependencyInstall{
+		{name: "a", packageArn: "a", version: "1.0", isSameAsCache: true, service: service},
+		{name: "b", packageArn: "b", version: "2.0", service: service},
+	})
+
+	// a single download gains nothing from being made ahead of the install
+	assert.Len(t, services, 2)
+	service.AssertNotCalled(t, "DownloadArtifact", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPrefetchedServiceDownloadArtifact(t *testing.T) {
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+	service := artifactServiceMock("a", "1.0", "/download/again", nil)
+	prefetched := &prefetchedService{PackageService: service, packageName: "a", version: "1.0", artifactPath: "/download/a"}
+
+	artifactPath, err := prefetched.DownloadArtifact(gocontext.Background(), tracer, "a", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "/download/a", artifactPath)
+	service.AssertNotCalled(t, "DownloadArtifact", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	artifactPath, err = prefetched.DownloadArtifact(gocontext.Background(), tracer, "a", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, "/download/again", artifactPath)
+}
+
+func TestPrefetchedServiceDiscard(t *testing.T) {
+	file, _ := ioutil.TempFile("", "prefetched")
+	file.Close()
+	defer os.Remove(file.Name())
+	prefetched := &prefetchedService{PackageService: &serviceMock.Mock{}, packageName: "a", version: "1.0", artifactPath: file.Name()}
+
+	prefetched.discard()
+
+	assert.False(t, fileutil.Exists(file.Name()))
+	assert.Equal(t, "", prefetched.artifactPath)
+}
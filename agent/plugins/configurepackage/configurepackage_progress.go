@@ -0,0 +1,62 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+// outputProgressReporter writes the phases and download progress to the plugin output as they happen,
+// so they are visible while a long install is in flight
+type outputProgressReporter struct {
+	output iohandler.IOHandler
+}
+
+func newOutputProgressReporter(output iohandler.IOHandler) *outputProgressReporter {
+	return &outputProgressReporter{output: output}
+}
+
+// ReportPhase writes the start of a phase to the output
+func (reporter *outputProgressReporter) ReportPhase(phase string) {
+	reporter.output.AppendInfo(phase)
+}
+
+// ReportProgress writes the progress of a download to the output
+func (reporter *outputProgressReporter) ReportProgress(progress packageservice.DownloadProgress) {
+	message := fmt.Sprintf("Downloaded %v", formatBytes(progress.BytesDownloaded))
+	if percent := progress.Percent(); percent >= 0 {
+		message = fmt.Sprintf("%v of %v (%v%%)", message, formatBytes(progress.TotalBytes), percent)
+	}
+	if progress.BytesPerSecond > 0 {
+		message = fmt.Sprintf("%v at %v/s", message, formatBytes(progress.BytesPerSecond))
+	}
+	reporter.output.AppendInfo(message)
+}
+
+// formatBytes formats a number of bytes with a binary unit, such as 12.5 MiB
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%v B", bytes)
+	}
+	value, exponent := float64(bytes)/unit, 0
+	for value >= unit && exponent < 3 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGT"[exponent])
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"testing"
+
+	iohandlermocks "github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputProgressReporter(t *testing.T) {
+	output := &iohandlermocks.MockIOHandler{}
+	output.On("AppendInfo", "Downloading package foo version 1.0.0").Return()
+	output.On("AppendInfo", "Downloaded 12.5 MiB of 50.0 MiB (25%) at 1.0 MiB/s").Return()
+	output.On("AppendInfo", "Downloaded 512 B").Return()
+
+	reporter := newOutputProgressReporter(output)
+	reporter.ReportPhase("Downloading package foo version 1.0.0")
+	reporter.ReportProgress(packageservice.DownloadProgress{BytesDownloaded: 12.5 * 1024 * 1024, TotalBytes: 50 * 1024 * 1024, BytesPerSecond: 1024 * 1024})
+	reporter.ReportProgress(packageservice.DownloadProgress{BytesDownloaded: 512})
+
+	output.AssertExpectations(t)
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.5 KiB", formatBytes(1536))
+	assert.Equal(t, "2.0 GiB", formatBytes(2*1024*1024*1024))
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/sbom"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/twinj/uuid"
+)
+
+// sbomInstanceID groups the SBOMs uploaded by this instance, replaced in tests
+var sbomInstanceID = platform.InstanceID
+
+// sbomUploader uploads an SBOM file to S3, replaced in tests
+var sbomUploader = func(tracer trace.Tracer, bucketName string, objectKey string, filePath string) error {
+	log := tracer.CurrentTrace().Logger
+	return s3util.NewAmazonS3Util(log, bucketName).S3Upload(log, bucketName, objectKey, filePath)
+}
+
+// emitSBOM writes the SBOM of a freshly installed package to the SBOM directory and uploads it to S3 if configured.
+// Failures are traced but do not fail the installation.
+func emitSBOM(tracer trace.Tracer, config appconfig.SbomCfg, sbomDirectory string, repository localpackages.Repository, inst installer.Installer) {
+	if !config.Enabled {
+		return
+	}
+	var err error
+	trace := tracer.BeginSection(fmt.Sprintf("write %v SBOM of %v %v", config.Format, inst.PackageName(), inst.Version()))
+	defer trace.EndWithError(&err)
+
+	pkg := sbom.Package{
+		Arn:     inst.PackageName(),
+		Name:    inst.PackageName(),
+		Version: inst.Version(),
+	}
+	if manifest, manifestErr := repository.GetPackageManifest(tracer, inst.PackageName(), inst.Version()); manifestErr == nil {
+		if manifest.AppName != "" {
+			pkg.Name = manifest.AppName
+		} else if manifest.Name != "" {
+			pkg.Name = manifest.Name
+		}
+		pkg.Platform = manifest.Platform
+		pkg.Architecture = manifest.Architecture
+		pkg.Publisher = manifest.AppPublisher
+		pkg.ReferenceURL = manifest.AppReferenceURL
+	}
+	if pkg.Files, err = sbom.CollectFiles(repository.GetPackageDirectory(tracer, inst.PackageName(), inst.Version())); err != nil {
+		return
+	}
+
+	var content []byte
+	if content, err = sbom.Generate(config.Format, pkg, uuid.NewV4().String(), time.Now()); err != nil {
+		return
+	}
+	if err = fileutil.MakeDirs(sbomDirectory); err != nil {
+		return
+	}
+	fileName := sbom.FileName(pkg, config.Format)
+	filePath := filepath.Join(sbomDirectory, fileName)
+	if err = fileutil.WriteAllText(filePath, string(content)); err != nil {
+		return
+	}
+	trace.AppendInfof("SBOM written to %v", filePath)
+
+	if config.S3BucketName == "" {
+		return
+	}
+	// SBOMs of a fleet share the bucket, they are grouped by instance
+	instanceID, _ := sbomInstanceID()
+	objectKey := path.Join(config.S3KeyPrefix, instanceID, fileName)
+	if err = sbomUploader(tracer, config.S3BucketName, objectKey, filePath); err != nil {
+		return
+	}
+	trace.AppendInfof("SBOM uploaded to s3://%v/%v", config.S3BucketName, objectKey)
+}
@@ -0,0 +1,83 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	installerMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupSbomTest(t *testing.T) (packageDir string, sbomDir string, repo *repoMock.MockedRepository, inst *installerMock.Mock, cleanup func()) {
+	root, err := ioutil.TempDir("", "sbom")
+	assert.NoError(t, err)
+	packageDir, sbomDir = filepath.Join(root, "package"), filepath.Join(root, "sbom")
+	assert.NoError(t, os.MkdirAll(packageDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "install.sh"), []byte("echo install"), 0600))
+
+	inst = &installerMock.Mock{}
+	inst.On("PackageName").Return("arn:aws:ssm:us-east-1:123456789012:document/MyPackage")
+	inst.On("Version").Return("1.0.0")
+	repo = &repoMock.MockedRepository{}
+	repo.On("GetPackageManifest", mock.Anything, mock.Anything, "1.0.0").Return(&localpackages.PackageManifest{Name: "MyPackage", AppPublisher: "Example Corp", Platform: "linux", Architecture: "amd64"}, nil)
+	repo.On("GetPackageDirectory", mock.Anything, mock.Anything, "1.0.0").Return(packageDir)
+	return packageDir, sbomDir, repo, inst, func() { os.RemoveAll(root) }
+}
+
+func TestEmitSBOM(t *testing.T) {
+	_, sbomDir, repo, inst, cleanup := setupSbomTest(t)
+	defer cleanup()
+	savedUploader, savedInstanceID := sbomUploader, sbomInstanceID
+	defer func() { sbomUploader, sbomInstanceID = savedUploader, savedInstanceID }()
+	sbomInstanceID = func() (string, error) { return "i-1234567890abcdef0", nil }
+	var uploadedKey string
+	sbomUploader = func(tracer trace.Tracer, bucketName string, objectKey string, filePath string) error {
+		assert.Equal(t, "sbom-bucket", bucketName)
+		uploadedKey = objectKey
+		return nil
+	}
+
+	config := appconfig.SbomCfg{Enabled: true, Format: appconfig.SbomFormatCycloneDX, S3BucketName: "sbom-bucket", S3KeyPrefix: "fleet"}
+	emitSBOM(trace.NewTracer(log.NewMockLog()), config, sbomDir, repo, inst)
+
+	content, err := ioutil.ReadFile(filepath.Join(sbomDir, "MyPackage_1.0.0.cdx.json"))
+	assert.NoError(t, err)
+	var document map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content, &document))
+	assert.Equal(t, "CycloneDX", document["bomFormat"])
+	assert.Len(t, document["components"], 1)
+	assert.Equal(t, "fleet/i-1234567890abcdef0/MyPackage_1.0.0.cdx.json", uploadedKey)
+}
+
+func TestEmitSBOMDisabled(t *testing.T) {
+	_, sbomDir, repo, inst, cleanup := setupSbomTest(t)
+	defer cleanup()
+
+	emitSBOM(trace.NewTracer(log.NewMockLog()), appconfig.SbomCfg{Format: appconfig.SbomFormatSPDX}, sbomDir, repo, inst)
+
+	_, err := os.Stat(sbomDir)
+	assert.True(t, os.IsNotExist(err))
+	repo.AssertNotCalled(t, "GetPackageDirectory", mock.Anything, mock.Anything, mock.Anything)
+}
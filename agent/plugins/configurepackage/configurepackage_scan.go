@@ -0,0 +1,113 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// maxScannerOutputBytes is the output of a rejecting scanner kept in the error
+const maxScannerOutputBytes = 1024
+
+// ArtifactScanner checks a downloaded package artifact before it is extracted, it returns an error when it rejects
+// the artifact
+type ArtifactScanner interface {
+	Name() string
+	Scan(ctx gocontext.Context, artifactPath string) error
+}
+
+// artifactScanners returns the scanners of the agent configuration
+var artifactScanners = func() []ArtifactScanner {
+	appCfg, err := appconfig.Config(false)
+	if err != nil {
+		return nil
+	}
+	var scanners []ArtifactScanner
+	for _, scannerCfg := range appCfg.Birdwatcher.ArtifactScanners {
+		scanners = append(scanners, commandScanner{cfg: scannerCfg})
+	}
+	return scanners
+}
+
+// commandScanner is an external command scanning the artifact, the artifact is accepted when it exits with 0
+type commandScanner struct {
+	cfg appconfig.ArtifactScannerCfg
+}
+
+func (scanner commandScanner) Name() string {
+	return scanner.cfg.Name
+}
+
+func (scanner commandScanner) Scan(ctx gocontext.Context, artifactPath string) error {
+	timeout := time.Duration(scanner.cfg.TimeoutSeconds) * time.Second
+	scanCtx, cancel := gocontext.WithTimeout(ctx, timeout)
+	defer cancel()
+	out, err := exec.CommandContext(scanCtx, scanner.cfg.Command, scannerArguments(scanner.cfg.Arguments, artifactPath)...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() == nil && scanCtx.Err() == gocontext.DeadlineExceeded {
+		return fmt.Errorf("scan did not complete within %v", timeout)
+	}
+	output := strings.TrimSpace(string(out))
+	if len(output) > maxScannerOutputBytes {
+		output = output[:maxScannerOutputBytes] + "..."
+	}
+	return fmt.Errorf("%v, output: %v", err, output)
+}
+
+// scannerArguments replaces the path placeholder of the arguments by the path of the artifact, the path is the
+// last argument when no argument has the placeholder
+func scannerArguments(arguments []string, artifactPath string) []string {
+	var values []string
+	replaced := false
+	for _, argument := range arguments {
+		if strings.Contains(argument, appconfig.ArtifactScannerPathPlaceholder) {
+			argument = strings.Replace(argument, appconfig.ArtifactScannerPathPlaceholder, artifactPath, -1)
+			replaced = true
+		}
+		values = append(values, argument)
+	}
+	if !replaced {
+		values = append(values, artifactPath)
+	}
+	return values
+}
+
+// scanArtifact runs the scanners on the downloaded artifact in order, before the artifact is extracted and the
+// scripts of the package run. The first scanner rejecting the artifact fails its own trace section.
+func scanArtifact(ctx gocontext.Context, tracer trace.Tracer, scanners []ArtifactScanner, packageName string, version string, artifactPath string) error {
+	if len(scanners) == 0 {
+		return nil
+	}
+	scanTrace := tracer.BeginSection(fmt.Sprintf("scan artifact of %v %v", packageName, version))
+	for _, scanner := range scanners {
+		if err := scanner.Scan(ctx, artifactPath); err != nil {
+			err = contracts.NewCodedError(contracts.ErrorCodeArtifactRejected, fmt.Errorf("scanner %v rejected the artifact of %v %v: %v", scanner.Name(), packageName, version, err))
+			scanTrace.WithError(err).End()
+			return err
+		}
+		scanTrace.AppendInfof("scanner %v accepted the artifact", scanner.Name())
+	}
+	scanTrace.End()
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	gocontext "context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeScanner struct {
+	name    string
+	err     error
+	scanned []string
+}
+
+func (scanner *fakeScanner) Name() string {
+	return scanner.name
+}
+
+func (scanner *fakeScanner) Scan(ctx gocontext.Context, artifactPath string) error {
+	scanner.scanned = append(scanner.scanned, artifactPath)
+	return scanner.err
+}
+
+func TestScanArtifact(t *testing.T) {
+	accepting := &fakeScanner{name: "accepting"}
+	rejecting := &fakeScanner{name: "rejecting", err: errors.New("infected")}
+	skipped := &fakeScanner{name: "skipped"}
+	tracer := trace.NewTracer(contextMock.Log())
+
+	err := scanArtifact(gocontext.Background(), tracer, []ArtifactScanner{accepting, rejecting, skipped}, "app", "1.0.0", "/tmp/app.zip")
+
+	assert.Error(t, err)
+	assert.Equal(t, contracts.ErrorCodeArtifactRejected, contracts.ErrorCodeOf(err))
+	assert.Contains(t, err.Error(), "rejecting")
+	assert.Equal(t, []string{"/tmp/app.zip"}, accepting.scanned)
+	assert.Equal(t, []string{"/tmp/app.zip"}, rejecting.scanned)
+	assert.Empty(t, skipped.scanned)
+	assert.Equal(t, 1, len(tracer.Traces()))
+	assert.Equal(t, "scan artifact of app 1.0.0", tracer.Traces()[0].Operation)
+	assert.Equal(t, contracts.ErrorCodeArtifactRejected, tracer.Traces()[0].ErrorCode)
+}
+
+func TestScanArtifactAccepted(t *testing.T) {
+	scanner := &fakeScanner{name: "accepting"}
+	tracer := trace.NewTracer(contextMock.Log())
+
+	assert.NoError(t, scanArtifact(gocontext.Background(), tracer, []ArtifactScanner{scanner}, "app", "1.0.0", "/tmp/app.zip"))
+	assert.Contains(t, tracer.ToPluginOutput().GetStdout(), "scanner accepting accepted the artifact")
+
+	tracer = trace.NewTracer(contextMock.Log())
+	assert.NoError(t, scanArtifact(gocontext.Background(), tracer, nil, "app", "1.0.0", "/tmp/app.zip"))
+	assert.Empty(t, tracer.Traces())
+}
+
+func TestScannerArguments(t *testing.T) {
+	assert.Equal(t, []string{"--no-summary", "/tmp/app.zip"}, scannerArguments([]string{"--no-summary"}, "/tmp/app.zip"))
+	assert.Equal(t, []string{"--file=/tmp/app.zip", "-q"}, scannerArguments([]string{"--file={artifact}", "-q"}, "/tmp/app.zip"))
+	assert.Equal(t, []string{"/tmp/app.zip"}, scannerArguments(nil, "/tmp/app.zip"))
+}
+
+func TestCommandScanner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test scanner is a shell command")
+	}
+	scan := func(script string, timeoutSeconds int) error {
+		scanner := commandScanner{cfg: appconfig.ArtifactScannerCfg{
+			Name:           "sh",
+			Command:        "sh",
+			Arguments:      []string{"-c", script, "scanner", "{artifact}"},
+			TimeoutSeconds: timeoutSeconds,
+		}}
+		return scanner.Scan(gocontext.Background(), "/tmp/app.zip")
+	}
+
+	assert.NoError(t, scan(`test "$1" = /tmp/app.zip`, 10))
+	err := scan(`echo "$1 is infected"; exit 1`, 10)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "/tmp/app.zip is infected")
+	err = scan("sleep 5", 1)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "did not complete within 1s")
+}
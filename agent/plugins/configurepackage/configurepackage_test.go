@@ -16,6 +16,7 @@
 package configurepackage
 
 import (
+	gocontext "context"
 	"errors"
 	"testing"
 
@@ -28,7 +29,9 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade"
 	facadeMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/birdwatcher/facade/mocks"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repoMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	serviceMock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice/mock"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 
 	"github.com/aws/aws-sdk-go/service/ssm"
@@ -136,6 +139,7 @@ func TestPrepareNewInstall(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -144,6 +148,7 @@ func TestPrepareNewInstall(t *testing.T) {
 		"packageArn",
 		"0.0.1",
 		false,
+		false,
 		output)
 
 	assert.NotNil(t, inst)
@@ -169,6 +174,7 @@ func TestAlreadyInstalled(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -177,6 +183,7 @@ func TestAlreadyInstalled(t *testing.T) {
 		"packageArn",
 		"0.0.1",
 		true,
+		false,
 		output)
 
 	assert.NotNil(t, inst)
@@ -202,6 +209,7 @@ func TestPrepareUpgrade(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -210,6 +218,7 @@ func TestPrepareUpgrade(t *testing.T) {
 		"packageArn",
 		"0.0.2",
 		false,
+		false,
 		output)
 
 	assert.NotNil(t, inst)
@@ -222,6 +231,76 @@ func TestPrepareUpgrade(t *testing.T) {
 	installerMock.AssertExpectations(t)
 }
 
+func TestPrepareDowngradePrevented(t *testing.T) {
+	pluginInformation := createStubPluginInputInstall()
+	repository := &repoMock.MockedRepository{}
+	repository.On("GetInstalledVersion", mock.Anything, mock.Anything).Return("0.0.2")
+	repository.On("GetInstallState", mock.Anything, mock.Anything).Return(localpackages.Installed, "0.0.2")
+	tracer := trace.NewTracer(log.NewMockLog())
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+
+	inst, uninst, _, _ := prepareConfigurePackage(
+		gocontext.Background(),
+		tracer,
+		buildConfigSimple(pluginInformation),
+		repository,
+		&serviceMock.Mock{},
+		pluginInformation,
+		"packageArn",
+		"0.0.1",
+		false,
+		true,
+		output)
+
+	assert.Nil(t, inst)
+	assert.Nil(t, uninst)
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, contracts.ErrorCodeInvalidInput, output.GetErrorCode())
+	assert.Contains(t, tracer.ToPluginOutput().GetStderr(), "older than the installed version 0.0.2")
+	repository.AssertExpectations(t)
+}
+
+func TestCheckRollback(t *testing.T) {
+	data := []struct {
+		name             string
+		installedVersion string
+		installState     localpackages.InstallState
+		version          string
+		force            bool
+		antiRollback     bool
+		manifest         *localpackages.PackageManifest
+		expectedErr      bool
+	}{
+		{"upgrade", "1.0.0", localpackages.Installed, "1.1.0", false, true, nil, false},
+		{"reinstall", "1.0.0", localpackages.Installed, "1.0.0", false, true, nil, false},
+		{"downgrade prevented by policy", "1.1.0", localpackages.Installed, "1.0.0", false, true, nil, true},
+		{"downgrade prevented by manifest", "1.1.0", localpackages.Installed, "1.0.0", false, false, &localpackages.PackageManifest{AntiRollback: true}, true},
+		{"downgrade allowed by manifest", "1.1.0", localpackages.Installed, "1.0.0", false, false, &localpackages.PackageManifest{}, false},
+		{"downgrade forced", "1.1.0", localpackages.Installed, "1.0.0", true, true, nil, false},
+		{"failed install", "1.1.0", localpackages.Failed, "1.0.0", false, true, nil, false},
+		{"numeric components", "10.0.0.1", localpackages.Installed, "9.0.0.1", false, true, nil, true},
+	}
+	for _, testdata := range data {
+		t.Run(testdata.name, func(t *testing.T) {
+			repository := &repoMock.MockedRepository{}
+			if testdata.manifest != nil {
+				repository.On("GetPackageManifest", mock.Anything, "packageArn", testdata.installedVersion).Return(testdata.manifest, nil)
+			}
+			input := &ConfigurePackagePluginInput{Name: "PVDriver", Action: InstallAction, Force: testdata.force}
+
+			err := checkRollback(trace.NewTracer(log.NewMockLog()), repository, input, "packageArn", testdata.installedVersion, testdata.installState, testdata.version, testdata.antiRollback)
+
+			if testdata.expectedErr {
+				assert.Error(t, err)
+				assert.Equal(t, contracts.ErrorCodeInvalidInput, contracts.ErrorCodeOf(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			repository.AssertExpectations(t)
+		})
+	}
+}
+
 func TestPrepareUninstall(t *testing.T) {
 	// file stubs are needed for ensurePackage because it handles the unzip
 	stubs := setSuccessStubs()
@@ -235,6 +314,7 @@ func TestPrepareUninstall(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -243,6 +323,7 @@ func TestPrepareUninstall(t *testing.T) {
 		"packageArn",
 		"0.0.1",
 		false,
+		false,
 		output)
 
 	assert.Nil(t, inst)
@@ -268,6 +349,7 @@ func TestPrepareUninstallCurrent(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -276,6 +358,7 @@ func TestPrepareUninstallCurrent(t *testing.T) {
 		"packageArn",
 		"0.0.1",
 		false,
+		false,
 		output)
 
 	assert.Nil(t, inst)
@@ -301,6 +384,7 @@ func TestPrepareUninstallCurrentWithLatest(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -309,6 +393,7 @@ func TestPrepareUninstallCurrentWithLatest(t *testing.T) {
 		"packageArn",
 		"0.0.1",
 		false,
+		false,
 		output)
 
 	assert.Nil(t, inst)
@@ -334,6 +419,7 @@ func TestPrepareUninstallWrongVersion(t *testing.T) {
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 
 	inst, uninst, installState, installedVersion := prepareConfigurePackage(
+		gocontext.Background(),
 		tracer,
 		buildConfigSimple(pluginInformation),
 		repoMock,
@@ -342,6 +428,7 @@ func TestPrepareUninstallWrongVersion(t *testing.T) {
 		"packageArn",
 		"2.3.4",
 		false,
+		false,
 		output)
 
 	assert.Nil(t, inst)
@@ -486,6 +573,7 @@ func TestExecute(t *testing.T) {
 	pluginInformation := createStubPluginInputInstall()
 	installerMock := installerSuccessMock(pluginInformation.Name, pluginInformation.Version)
 	repoMock := repoInstallMock(pluginInformation, installerMock)
+	repoMock.On("CollectGarbage", mock.Anything, mock.Anything, mock.Anything).Return(int64(0)).Once()
 	serviceMock := serviceSuccessMock()
 
 	plugin := &Plugin{
@@ -560,6 +648,110 @@ func TestValidateInput_Source(t *testing.T) {
 	assert.Contains(t, err.Error(), "source parameter is not supported")
 }
 
+func TestValidateInput_VersionSelection(t *testing.T) {
+	input := ConfigurePackagePluginInput{}
+
+	input.Name = "PVDriver"
+	input.Action = "Install"
+	input.VersionSelection = "latest"
+
+	result, err := validateInput(&input)
+
+	assert.True(t, result)
+	assert.NoError(t, err)
+
+	input.VersionSelection = "newest"
+
+	result, err = validateInput(&input)
+
+	assert.False(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported version selection")
+}
+
+func TestValidateInput_Channel(t *testing.T) {
+	input := ConfigurePackagePluginInput{Name: "PVDriver", Action: "Install", Channel: " Beta "}
+
+	result, err := validateInput(&input)
+
+	assert.True(t, result)
+	assert.NoError(t, err)
+	assert.Equal(t, "beta", input.Channel)
+
+	input.Channel = "beta/1"
+
+	result, err = validateInput(&input)
+
+	assert.False(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid channel")
+}
+
+func TestValidateInput_ActionTimeout(t *testing.T) {
+	input := ConfigurePackagePluginInput{Name: "PVDriver", Action: "Install", ActionTimeoutSeconds: 600}
+
+	result, err := validateInput(&input)
+
+	assert.True(t, result)
+	assert.NoError(t, err)
+
+	input.ActionTimeoutSeconds = 2
+
+	result, err = validateInput(&input)
+
+	assert.False(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid action timeout")
+}
+
+func TestValidateInput_InstallParameters(t *testing.T) {
+	input := ConfigurePackagePluginInput{Name: "PVDriver", Action: "Install", InstallParameters: map[string]string{"serviceName": "pvdriver", "log_level": "debug"}}
+
+	result, err := validateInput(&input)
+
+	assert.True(t, result)
+	assert.NoError(t, err)
+
+	input.InstallParameters["install-path"] = "/opt/pvdriver"
+
+	result, err = validateInput(&input)
+
+	assert.False(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid install parameter")
+}
+
+func TestValidateInput_VersionLabel(t *testing.T) {
+	input := ConfigurePackagePluginInput{}
+
+	input.Name = "PVDriver"
+	input.Action = "Install"
+	input.VersionLabel = " prod "
+
+	result, err := validateInput(&input)
+
+	assert.True(t, result)
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", input.VersionLabel)
+
+	input.Version = "1.0.0"
+
+	result, err = validateInput(&input)
+
+	assert.False(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot both be set")
+
+	input.Version = ""
+	input.VersionLabel = "prod label"
+
+	result, err = validateInput(&input)
+
+	assert.False(t, result)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid version label")
+}
+
 func TestValidateInput_NameEmpty(t *testing.T) {
 	input := ConfigurePackagePluginInput{}
 
@@ -753,6 +945,62 @@ func TestSelectService(t *testing.T) {
 	}
 }
 
+func TestSelectServiceLocalArchive(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	isDocumentArchive := true
+	appConfig := appconfig.SsmagentConfig{
+		Birdwatcher: appconfig.BirdwatcherCfg{
+			LocalArchivePath: "file:///packages",
+		},
+	}
+	input := &ConfigurePackagePluginInput{
+		Name:    "arn:aws:ssm:us-west-1:1234567890:document/package",
+		Version: "1.2.3.4",
+	}
+
+	// the service is not called to choose the archive
+	result, err := selectService(tracer, input, localpackages.NewRepository(), &appConfig, &facade.FacadeStub{GetManifestError: errors.New("testError")}, &isDocumentArchive)
+
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.PackageServiceName_local, result.PackageServiceName())
+	assert.False(t, isDocumentArchive)
+}
+
+func TestSelectServiceS3Archive(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	isDocumentArchive := true
+	input := &ConfigurePackagePluginInput{
+		Name:    "s3://my-bucket/prefix/pkg",
+		Version: "1.2.3.4",
+	}
+
+	// the service is not called to choose the archive
+	result, err := selectService(tracer, input, localpackages.NewRepository(), &appconfig.SsmagentConfig{}, &facade.FacadeStub{GetManifestError: errors.New("testError")}, &isDocumentArchive)
+
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.PackageServiceName_s3, result.PackageServiceName())
+	assert.False(t, isDocumentArchive)
+}
+
+func TestSelectServiceOciArchive(t *testing.T) {
+	tracer := trace.NewTracer(contextMock.Log())
+	defer tracer.BeginSection("test").End()
+	isDocumentArchive := true
+	input := &ConfigurePackagePluginInput{
+		Name:    "oci://public.ecr.aws/team/pkg",
+		Version: "1.2.3.4",
+	}
+
+	// the service is not called to choose the archive
+	result, err := selectService(tracer, input, localpackages.NewRepository(), &appconfig.SsmagentConfig{}, &facade.FacadeStub{GetManifestError: errors.New("testError")}, &isDocumentArchive)
+
+	assert.NoError(t, err)
+	assert.Equal(t, packageservice.PackageServiceName_oci, result.PackageServiceName())
+	assert.False(t, isDocumentArchive)
+}
+
 // Integration tests
 func loadFile(t *testing.T, fileName string) (result []byte) {
 	result, err := ioutil.ReadFile(fileName)
@@ -782,7 +1030,7 @@ func TestExecuteConfigurePackagePlugin_BirdwatcherService(t *testing.T) {
 		Manifest: &manifest,
 	}
 	bwFacade.On("GetManifest", getManifestInput).Return(getManifestOutput, nil).Once()
-	bwFacade.On("PutConfigurePackageResult", mock.Anything).Return(&ssm.PutConfigurePackageResultOutput{}, nil).Once()
+	bwFacade.On("PutConfigurePackageResultWithContext", mock.Anything, mock.Anything).Return(&ssm.PutConfigurePackageResultOutput{}, nil).Once()
 	repoMock.On("LoadTraces", mock.Anything, mock.Anything).Return(nil)
 
 	plugin := &Plugin{
@@ -921,7 +1169,7 @@ func TestExecuteConfigurePackagePlugin_DocumentService(t *testing.T) {
 				getDocumentError = errors.New(resourceNotFoundException)
 			}
 			bwFacade.On("GetManifest", getManifestInput).Return(nil, errors.New(resourceNotFoundException)).Once()
-			bwFacade.On("GetDocument", getDocumentInput).Return(getDocumentOutput, getDocumentError).Once()
+			bwFacade.On("GetDocumentWithContext", mock.Anything, getDocumentInput).Return(getDocumentOutput, getDocumentError).Once()
 
 			plugin := &Plugin{
 				birdwatcherfacade:      &bwFacade,
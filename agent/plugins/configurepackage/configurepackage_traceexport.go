@@ -0,0 +1,108 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher"
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/cloudwatchlogsinterface"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+)
+
+// traceExportStreamName is the log stream the traces are written to when the instance id is not available
+const traceExportStreamName = "configurePackage"
+
+// newCloudWatchLogsService creates the client of the trace export, replaced in tests
+var newCloudWatchLogsService = func() cloudwatchlogsinterface.ICloudWatchLogsService {
+	return cloudwatchlogspublisher.NewCloudWatchLogsService()
+}
+
+// traceExportInstanceID names the log stream of this instance, replaced in tests
+var traceExportInstanceID = platform.InstanceID
+
+// traceExportNow returns the time the exported records are stamped with, replaced in tests
+var traceExportNow = time.Now
+
+// cloudWatchTraceExporter writes the sections of each package operation to a CloudWatch Logs group,
+// in a log stream named after the instance
+type cloudWatchTraceExporter struct {
+	log         log.T
+	service     cloudwatchlogsinterface.ICloudWatchLogsService
+	logGroup    string
+	namespace   string
+	emitMetrics bool
+}
+
+// newTraceExporter returns the exporter configured for the package traces, nil when the export is disabled
+func newTraceExporter(log log.T, config appconfig.PackageTraceCfg) trace.Exporter {
+	if config.ExportLogGroup == "" {
+		return nil
+	}
+	return &cloudWatchTraceExporter{
+		log:         log,
+		service:     newCloudWatchLogsService(),
+		logGroup:    config.ExportLogGroup,
+		namespace:   config.MetricNamespace,
+		emitMetrics: config.ExportMetrics,
+	}
+}
+
+// Export writes the structured record of the operation, followed by its metrics when enabled
+func (e *cloudWatchTraceExporter) Export(operation trace.Operation, traces []*trace.Trace) error {
+	timestamp := traceExportNow()
+	record, err := json.Marshal(trace.NewOperationRecord(operation, traces))
+	if err != nil {
+		return err
+	}
+	events := []*cloudwatchlogs.InputLogEvent{newLogEvent(record, timestamp)}
+	if e.emitMetrics {
+		metrics, err := trace.EmbeddedMetrics(e.namespace, timestamp, operation, traces)
+		if err != nil {
+			return err
+		}
+		events = append(events, newLogEvent(metrics, timestamp))
+	}
+
+	logStream := traceExportStreamName
+	if instanceID, err := traceExportInstanceID(); err == nil && instanceID != "" {
+		logStream = instanceID
+	}
+	if !e.service.IsLogGroupPresent(e.log, e.logGroup) {
+		if err = e.service.CreateLogGroup(e.log, e.logGroup); err != nil {
+			return err
+		}
+	}
+	if !e.service.IsLogStreamPresent(e.log, e.logGroup, logStream) {
+		if err = e.service.CreateLogStream(e.log, e.logGroup, logStream); err != nil {
+			return err
+		}
+	}
+	sequenceToken := e.service.GetSequenceTokenForStream(e.log, e.logGroup, logStream)
+	_, err = e.service.PutLogEvents(e.log, events, e.logGroup, logStream, sequenceToken)
+	return err
+}
+
+func newLogEvent(message []byte, timestamp time.Time) *cloudwatchlogs.InputLogEvent {
+	return &cloudwatchlogs.InputLogEvent{
+		Message:   aws.String(string(message)),
+		Timestamp: aws.Int64(timestamp.UnixNano() / int64(time.Millisecond)),
+	}
+}
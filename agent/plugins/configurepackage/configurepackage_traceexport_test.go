@@ -0,0 +1,96 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurepackage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/cloudwatchlogsinterface"
+	cloudwatchlogspublisher_mock "github.com/aws/amazon-ssm-agent/agent/agentlogstocloudwatch/cloudwatchlogspublisher/mock"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func useTraceExportStubs(service *cloudwatchlogspublisher_mock.CloudWatchLogsServiceMock) func() {
+	savedService, savedInstanceID, savedNow := newCloudWatchLogsService, traceExportInstanceID, traceExportNow
+	newCloudWatchLogsService = func() cloudwatchlogsinterface.ICloudWatchLogsService { return service }
+	traceExportInstanceID = func() (string, error) { return "i-123", nil }
+	traceExportNow = func() time.Time { return time.Unix(10, 0) }
+	return func() {
+		newCloudWatchLogsService, traceExportInstanceID, traceExportNow = savedService, savedInstanceID, savedNow
+	}
+}
+
+func TestNewTraceExporter_Disabled(t *testing.T) {
+	assert.Nil(t, newTraceExporter(log.NewMockLog(), appconfig.DefaultConfig().Birdwatcher.Trace))
+}
+
+func TestTraceExport_CreatesLogGroupAndStream(t *testing.T) {
+	service := &cloudwatchlogspublisher_mock.CloudWatchLogsServiceMock{}
+	defer useTraceExportStubs(service)()
+	config := appconfig.DefaultConfig().Birdwatcher.Trace
+	config.ExportLogGroup = "packages"
+	config.ExportMetrics = true
+
+	service.On("IsLogGroupPresent", mock.Anything, "packages").Return(false)
+	service.On("CreateLogGroup", mock.Anything, "packages").Return(nil)
+	service.On("IsLogStreamPresent", mock.Anything, "packages", "i-123").Return(false)
+	service.On("CreateLogStream", mock.Anything, "packages", "i-123").Return(nil)
+	service.On("GetSequenceTokenForStream", mock.Anything, "packages", "i-123").Return(nil)
+	var events []*cloudwatchlogs.InputLogEvent
+	service.On("PutLogEvents", mock.Anything, mock.Anything, "packages", "i-123", (*string)(nil)).Run(func(args mock.Arguments) {
+		events = args.Get(1).([]*cloudwatchlogs.InputLogEvent)
+	}).Return(nil, nil)
+
+	exporter := newTraceExporter(log.NewMockLog(), config)
+	err := exporter.Export(trace.Operation{PackageName: "pkg", Version: "1.0", Action: "Install", Status: "Success"},
+		[]*trace.Trace{{Operation: "download artifact", Duration: time.Second}})
+
+	assert.NoError(t, err)
+	service.AssertExpectations(t)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(10000), *events[0].Timestamp)
+	var record trace.OperationRecord
+	assert.NoError(t, json.Unmarshal([]byte(*events[0].Message), &record))
+	assert.Equal(t, "pkg", record.PackageName)
+	assert.Equal(t, int64(1000), record.Sections[0].DurationMs)
+	assert.Contains(t, *events[1].Message, `"Namespace":"SSMAgent/ConfigurePackage"`)
+}
+
+func TestTraceExport_PutFails(t *testing.T) {
+	service := &cloudwatchlogspublisher_mock.CloudWatchLogsServiceMock{}
+	defer useTraceExportStubs(service)()
+	config := appconfig.DefaultConfig().Birdwatcher.Trace
+	config.ExportLogGroup = "packages"
+
+	token := "token"
+	service.On("IsLogGroupPresent", mock.Anything, "packages").Return(true)
+	service.On("IsLogStreamPresent", mock.Anything, "packages", "i-123").Return(true)
+	service.On("GetSequenceTokenForStream", mock.Anything, "packages", "i-123").Return(&token)
+	service.On("PutLogEvents", mock.Anything, mock.MatchedBy(func(events []*cloudwatchlogs.InputLogEvent) bool {
+		return len(events) == 1
+	}), "packages", "i-123", &token).Return(nil, errors.New("denied"))
+
+	err := newTraceExporter(log.NewMockLog(), config).Export(trace.Operation{PackageName: "pkg", Action: "Install"}, nil)
+
+	assert.Error(t, err)
+	service.AssertExpectations(t)
+}
@@ -89,8 +89,6 @@ func repoUninstallMock(pluginInformation *ConfigurePackagePluginInput, installer
 
 func repoInstallMock_ReadWriteManifest(pluginInformation *ConfigurePackagePluginInput, installerMock installer.Installer, version string, action string) *repoMock.MockedRepository {
 	mockRepo := repoMock.MockedRepository{}
-	mockRepo.On("ReadManifest", pluginInformation.Name, version).Return([]byte(""), nil)
-	mockRepo.On("WriteManifest", pluginInformation.Name, version, mock.Anything).Return(nil)
 	mockRepo.On("GetInstalledVersion", mock.Anything, pluginInformation.Name).Return("")
 	mockRepo.On("GetInstallState", mock.Anything, pluginInformation.Name).Return(localpackages.None, "")
 	mockRepo.On("UnlockPackage", mock.Anything, mock.Anything).Return().Once()
@@ -101,6 +99,7 @@ func repoInstallMock_ReadWriteManifest(pluginInformation *ConfigurePackagePlugin
 		mockRepo.On("ValidatePackage", mock.Anything, pluginInformation.Name, version).Return(nil)
 		mockRepo.On("GetInstaller", mock.Anything, mock.Anything, pluginInformation.Name, version).Return(installerMock)
 		mockRepo.On("SetInstallState", mock.Anything, pluginInformation.Name, version, mock.Anything).Return(nil)
+		mockRepo.On("CollectGarbage", mock.Anything, mock.Anything, pluginInformation.Name).Return(int64(0)).Once()
 	} else {
 		mockRepo.On("LockPackage", mock.Anything, pluginInformation.Name, "Uninstall").Return(nil).Once()
 
@@ -233,23 +232,31 @@ func selectMockService(service packageservice.PackageService) func(tracer trace.
 func serviceSuccessMock() *serviceMock.Mock {
 	mockService := serviceMock.Mock{}
 	mockService.On("GetPackageArnAndVersion", mock.Anything, mock.Anything).Return("packageArn", "0.0.1")
-	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything).Return("packageArn", "0.0.1", false, nil)
-	mockService.On("ReportResult", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("SetProgressReporter", mock.Anything).Return()
+	mockService.On("SetChannel", mock.Anything).Return()
+	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("packageArn", "0.0.1", false, nil)
+	mockService.On("GetDependencies", mock.Anything, mock.Anything, mock.Anything).Return([]packageservice.Dependency(nil), nil)
+	mockService.On("ReportResult", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	return &mockService
 }
 
 func serviceSameManifestCacheMock() *serviceMock.Mock {
 	mockService := serviceMock.Mock{}
 	mockService.On("GetPackageArnAndVersion", mock.Anything, mock.Anything).Return("packageArn", "0.0.1")
-	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything).Return("packageArn", "0.0.1", true, nil)
-	mockService.On("ReportResult", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("SetProgressReporter", mock.Anything).Return()
+	mockService.On("SetChannel", mock.Anything).Return()
+	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("packageArn", "0.0.1", true, nil)
+	mockService.On("GetDependencies", mock.Anything, mock.Anything, mock.Anything).Return([]packageservice.Dependency(nil), nil)
+	mockService.On("ReportResult", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	return &mockService
 }
 
 func serviceFailedMock() *serviceMock.Mock {
 	mockService := serviceMock.Mock{}
 	mockService.On("GetPackageArnAndVersion", mock.Anything, mock.Anything).Return("packageArn", "0.0.1")
-	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything).Return("", "", false, errors.New("testerror"))
+	mockService.On("SetProgressReporter", mock.Anything).Return()
+	mockService.On("SetChannel", mock.Anything).Return()
+	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("", "", false, errors.New("testerror"))
 	return &mockService
 }
 
@@ -260,9 +267,13 @@ func serviceRebootMock() *serviceMock.Mock {
 func serviceUpgradeMock() *serviceMock.Mock {
 	mockService := serviceMock.Mock{}
 	mockService.On("GetPackageArnAndVersion", mock.Anything, mock.Anything).Return("packageArn", "0.0.1")
-	mockService.On("DownloadManifest", mock.Anything, mock.Anything, "latest").Return("packageArn", "0.0.2", false, nil)
-	mockService.On("DownloadArtifact", mock.Anything, mock.Anything, "0.0.2").Return("/temp/0.0.2", nil)
-	mockService.On("ReportResult", mock.Anything, mock.Anything).Return(nil)
+	mockService.On("SetProgressReporter", mock.Anything).Return()
+	mockService.On("SetChannel", mock.Anything).Return()
+	mockService.On("DownloadManifest", mock.Anything, mock.Anything, mock.Anything, "latest").Return("packageArn", "0.0.2", false, nil)
+	mockService.On("GetDependencies", mock.Anything, mock.Anything, mock.Anything).Return([]packageservice.Dependency(nil), nil)
+	mockService.On("DownloadArtifact", mock.Anything, mock.Anything, mock.Anything, "0.0.2").Return("/temp/0.0.2", nil)
+	mockService.On("DownloadAttachments", mock.Anything, mock.Anything, mock.Anything, "0.0.2", mock.Anything).Return(nil)
+	mockService.On("ReportResult", mock.Anything, mock.Anything, mock.Anything).Return(nil)
 	return &mockService
 }
 
@@ -271,7 +282,7 @@ func createMockCancelFlag() task.CancelFlag {
 	// Setup mocks
 	mockCancelFlag.On("Canceled").Return(false)
 	mockCancelFlag.On("ShutDown").Return(false)
-	mockCancelFlag.On("Wait").Return(false).After(100 * time.Millisecond)
+	mockCancelFlag.On("Wait").Return(task.Completed).After(100 * time.Millisecond)
 
 	return mockCancelFlag
 }
@@ -281,6 +292,7 @@ func createMockIOHandler() iohandler.IOHandler {
 
 	mockIOHandler.On("SetExitCode", mock.Anything).Return()
 	mockIOHandler.On("SetStatus", mock.Anything).Return()
+	mockIOHandler.On("SetErrorCode", mock.Anything).Return()
 	mockIOHandler.On("AppendInfo", mock.Anything).Return()
 	mockIOHandler.On("AppendError", mock.Anything).Return()
 
@@ -292,6 +304,10 @@ func createMockIOHandlerStruct(errorResponse string) *iohandlermocks.MockIOHandl
 
 	mockIOHandler.On("SetExitCode", mock.Anything).Return()
 	mockIOHandler.On("SetStatus", mock.Anything).Return()
+	if errorResponse != "" {
+		// the error code is only set on failed executions
+		mockIOHandler.On("SetErrorCode", mock.Anything).Return()
+	}
 	mockIOHandler.On("AppendInfo", mock.Anything).Return()
 	mockIOHandler.On("AppendError", errorResponse).Return()
 
@@ -0,0 +1,86 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package clusterdetect implements detection of the Windows Server Failover Cluster the instance is a node of
+package clusterdetect
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// Cluster describes the failover cluster the instance is a node of, if any
+type Cluster struct {
+	Clustered bool
+	// Name is the name of the cluster
+	Name string
+	// ActiveNode is true when the instance owns at least one clustered role
+	ActiveNode bool
+	// OwnedRoles are the clustered roles currently owned by the instance
+	OwnedRoles []string
+}
+
+// clusterState is the state of the cluster reported by the cluster query
+type clusterState struct {
+	Name       string
+	OwnedRoles []string
+}
+
+// CollectClusterData detects whether the instance is a node of a failover cluster and which roles it owns.
+// Instances that are not clustered, or whose cluster can't be queried, are reported as not clustered.
+func CollectClusterData(log log.T) (*Cluster, error) {
+	c := &Cluster{}
+	output, err := clusterdep.QueryCluster()
+	if err != nil {
+		log.Debugf("failed to query the failover cluster: %v", err)
+		return c, nil
+	}
+	state, err := parseClusterState(output)
+	if err != nil {
+		log.Debugf("failed to parse the state of the failover cluster: %v", err)
+		return c, nil
+	}
+	if state == nil {
+		return c, nil
+	}
+	c.Clustered = true
+	c.Name = state.Name
+	for _, role := range state.OwnedRoles {
+		if role = strings.TrimSpace(role); role != "" {
+			c.OwnedRoles = append(c.OwnedRoles, role)
+		}
+	}
+	c.ActiveNode = len(c.OwnedRoles) > 0
+	return c, nil
+}
+
+// IsActiveNode returns true when the instance owns a role of the failover cluster it is a node of
+func IsActiveNode(log log.T) bool {
+	c, _ := CollectClusterData(log)
+	return c.ActiveNode
+}
+
+// parseClusterState parses the output of the cluster query, which is empty when the instance is not clustered
+func parseClusterState(output string) (*clusterState, error) {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return nil, nil
+	}
+	var state clusterState
+	if err := json.Unmarshal([]byte(output), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clusterdetect
+
+type clusterDep interface {
+	// QueryCluster returns the name of the cluster and the roles owned by the instance as json,
+	// or an empty string when the instance is not clustered
+	QueryCluster() (string, error)
+}
+
+var clusterdep clusterDep = &clusterDepImp{}
+
+type clusterDepImp struct{}
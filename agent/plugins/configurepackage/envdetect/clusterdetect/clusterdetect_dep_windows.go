@@ -0,0 +1,54 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package clusterdetect
+
+import (
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// clusterQueryTimeout bounds the query of the failover cluster
+const clusterQueryTimeout = 30 * time.Second
+
+// clusterQuery prints the cluster and the roles owned by this node, skipping the core cluster group and the
+// available storage group which move between nodes without carrying a workload
+const clusterQuery = `
+$ErrorActionPreference = 'Stop'
+$service = Get-Service -Name ClusSvc -ErrorAction SilentlyContinue
+if ($service -eq $null -or $service.Status -ne 'Running') { exit 0 }
+Import-Module FailoverClusters
+$roles = @(Get-ClusterGroup | Where-Object {
+    $_.OwnerNode.Name -eq $env:COMPUTERNAME -and
+    $_.GroupType -ne 'Cluster' -and $_.GroupType -ne 'AvailableStorage' -and
+    $_.State -eq 'Online'
+} | ForEach-Object { $_.Name })
+@{ Name = (Get-Cluster).Name; OwnedRoles = $roles } | ConvertTo-Json -Compress
+`
+
+// QueryCluster runs the cluster query with PowerShell
+func (*clusterDepImp) QueryCluster() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), clusterQueryTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, appconfig.PowerShellPluginCommandName,
+		"-NoProfile", "-NonInteractive", "-Command", clusterQuery).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package clusterdetect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var loggerMock = log.NewMockLog()
+
+// clusterDepStub returns a fixed cluster query output
+type clusterDepStub struct {
+	output string
+	err    error
+}
+
+func (s *clusterDepStub) QueryCluster() (string, error) {
+	return s.output, s.err
+}
+
+func collectWith(stub *clusterDepStub) *Cluster {
+	clusterdep = stub
+	cluster, _ := CollectClusterData(loggerMock)
+	return cluster
+}
+
+func TestCollectClusterData(t *testing.T) {
+	defer func() { clusterdep = &clusterDepImp{} }()
+
+	tests := []struct {
+		name     string
+		stub     *clusterDepStub
+		expected Cluster
+	}{
+		{"not clustered", &clusterDepStub{output: "\r\n"}, Cluster{}},
+		{"query failure", &clusterDepStub{err: errors.New("powershell failed")}, Cluster{}},
+		{"invalid output", &clusterDepStub{output: "Get-Cluster : access denied"}, Cluster{}},
+		{
+			"passive node",
+			&clusterDepStub{output: `{"Name":"sqlcluster","OwnedRoles":[]}`},
+			Cluster{Clustered: true, Name: "sqlcluster"},
+		},
+		{
+			"active node",
+			&clusterDepStub{output: `{"Name":"sqlcluster","OwnedRoles":["SQL Server (MSSQLSERVER)"," "]}` + "\r\n"},
+			Cluster{Clustered: true, Name: "sqlcluster", ActiveNode: true, OwnedRoles: []string{"SQL Server (MSSQLSERVER)"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, *collectWith(test.stub))
+		})
+	}
+}
+
+func TestIsActiveNode(t *testing.T) {
+	defer func() { clusterdep = &clusterDepImp{} }()
+
+	clusterdep = &clusterDepStub{output: `{"Name":"fs","OwnedRoles":["FileServer"]}`}
+	assert.True(t, IsActiveNode(loggerMock))
+
+	clusterdep = &clusterDepStub{output: `{"Name":"fs","OwnedRoles":null}`}
+	assert.False(t, IsActiveNode(loggerMock))
+}
@@ -55,6 +55,12 @@ const PlatformAmazon = "amazon"
 // PlatformAlpine uses Ohai identifier for alpine platform
 const PlatformAlpine = "alpine"
 
+// PlatformRocky uses Ohai identifier for rocky linux platform
+const PlatformRocky = "rocky"
+
+// PlatformAlmaLinux uses Ohai identifier for almalinux platform
+const PlatformAlmaLinux = "almalinux"
+
 // PlatformSuse uses Ohai identifier for suse platform
 const PlatformSuse = "suse"
 
@@ -80,6 +86,14 @@ const PlatformDarwin = "mac_os_x"
 const SKUProductDatacenterNanoServer = "143"
 const SKUProductStandardNanoServer = "144"
 
+// Architecture marks the processor architecture of the operating system
+
+// ArchitectureX86_64 is used for 64-bit x86 operating systems
+const ArchitectureX86_64 = "x86_64"
+
+// ArchitectureArm64 is used for 64-bit ARM operating systems
+const ArchitectureArm64 = "arm64"
+
 // Init marks a init system used by the Operating Sysstem
 
 // InitSystemd uses identifier for systemd init system
@@ -94,7 +108,7 @@ const InitChkconfig = "chkconfig"
 // InitUpdatercd uses Ohai identifier for update-rc.d init system (Debian)
 const InitUpdatercd = "updatercd"
 
-// InitOpenrc uses identifier for openrc init system (Gentoo)
+// InitOpenrc uses identifier for openrc init system (Gentoo, Alpine)
 const InitOpenrc = "openrc"
 
 // InitService uses identifier for undetected init systems but available
@@ -153,8 +167,55 @@ const PackageManagerZipper = "zypper"
 // PackageManagerAlpine is used on Alpine Linux
 const PackageManagerAlpine = "alpine"
 
-// PackageManagerDnf is used on Fedora
+// PackageManagerDnf is used on Fedora, Amazon Linux 2023 and the RHEL 8 derivatives
 const PackageManagerDnf = "dnf"
 
+// PackageManagerRpmOstree is used on the image based Fedora and RHEL editions, such as Fedora CoreOS, whose
+// packages are layered on the read-only root filesystem and applied at the next boot
+const PackageManagerRpmOstree = "rpm-ostree"
+
 // PackageManagerEmerge is used on Gentoo platform families (Gentoo, Funtoo, ...)
 const PackageManagerEmerge = "emerge"
+
+// Provenance marks where the instance runs
+
+// ProvenanceEc2 is used for EC2 instances
+const ProvenanceEc2 = "ec2"
+
+// ProvenanceOnPremises is used for managed instances registered with an activation
+const ProvenanceOnPremises = "on-premises"
+
+// ProvenanceEdge is used for edge devices registered with their AWS IoT identity
+const ProvenanceEdge = "edge"
+
+// Container marks the container runtime, orchestrator and host the agent runs in
+
+// PlatformFamilyContainer is the package selector of the packages built for containers
+const PlatformFamilyContainer = "container"
+
+// ContainerRuntimeDocker is used for containers run by docker
+const ContainerRuntimeDocker = "docker"
+
+// ContainerRuntimeContainerd is used for containers run by containerd
+const ContainerRuntimeContainerd = "containerd"
+
+// ContainerRuntimeCrio is used for containers run by cri-o
+const ContainerRuntimeCrio = "cri-o"
+
+// ContainerRuntimePodman is used for containers run by podman
+const ContainerRuntimePodman = "podman"
+
+// ContainerRuntimeLxc is used for lxc containers
+const ContainerRuntimeLxc = "lxc"
+
+// OrchestratorEcs is used for containers of ECS tasks
+const OrchestratorEcs = "ecs"
+
+// OrchestratorKubernetes is used for containers of Kubernetes pods, including EKS
+const OrchestratorKubernetes = "kubernetes"
+
+// ContainerHostFargate is used for containers running on Fargate
+const ContainerHostFargate = "fargate"
+
+// ContainerHostBottlerocket is used for containers running on Bottlerocket
+const ContainerHostBottlerocket = "bottlerocket"
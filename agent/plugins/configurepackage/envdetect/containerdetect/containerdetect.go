@@ -0,0 +1,172 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package containerdetect implements detection of the container the agent runs in
+package containerdetect
+
+import (
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
+)
+
+const (
+	ecsMetadataEnvV4     = "ECS_CONTAINER_METADATA_URI_V4"
+	ecsMetadataEnv       = "ECS_CONTAINER_METADATA_URI"
+	executionEnv         = "AWS_EXECUTION_ENV"
+	executionEnvFargate  = "AWS_ECS_FARGATE"
+	kubernetesHostEnv    = "KUBERNETES_SERVICE_HOST"
+	kubernetesAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	launchTypeFargate    = "FARGATE"
+)
+
+// Container describes the container the agent runs in, if any
+type Container struct {
+	Containerized bool
+	// Runtime is the container runtime, empty when it can't be told
+	Runtime string
+	// Orchestrator is the orchestrator of the container, empty for standalone containers
+	Orchestrator string
+	// Host is the kind of host running the container when it is relevant to packages, such as Fargate or Bottlerocket
+	Host string
+}
+
+// CollectContainerData detects whether the agent runs in a container from the cgroups, the mounts and the environment
+// of the agent and the metadata of the orchestrator
+func CollectContainerData(log log.T) (*Container, error) {
+	c := &Container{
+		Runtime:      detectRuntime(),
+		Orchestrator: detectOrchestrator(),
+	}
+	c.Containerized = c.Runtime != "" || c.Orchestrator != ""
+	c.Host = detectHost(log, c.Orchestrator)
+	return c, nil
+}
+
+// runtimeMarkers maps the names found in cgroup paths and in the root mount to the container runtime
+var runtimeMarkers = []struct {
+	marker  string
+	runtime string
+}{
+	{"crio", constants.ContainerRuntimeCrio},
+	{"libpod", constants.ContainerRuntimePodman},
+	{"docker", constants.ContainerRuntimeDocker},
+	{"containerd", constants.ContainerRuntimeContainerd},
+	{"lxc", constants.ContainerRuntimeLxc},
+}
+
+func detectRuntime() string {
+	switch {
+	case containerdep.FileExists("/.dockerenv"):
+		return constants.ContainerRuntimeDocker
+	case containerdep.FileExists("/run/.containerenv"):
+		return constants.ContainerRuntimePodman
+	}
+
+	// set by podman, lxc and systemd-nspawn in the environment of the container
+	if container := strings.ToLower(strings.TrimSpace(containerdep.Getenv("container"))); container != "" {
+		if strings.HasPrefix(container, constants.ContainerRuntimeLxc) {
+			return constants.ContainerRuntimeLxc
+		}
+		return container
+	}
+
+	if runtime := runtimeFromMarkers(cgroupPaths()); runtime != "" {
+		return runtime
+	}
+	return runtimeFromMarkers(rootMountSource())
+}
+
+func runtimeFromMarkers(text string) string {
+	text = strings.ToLower(text)
+	for _, m := range runtimeMarkers {
+		if strings.Contains(text, m.marker) {
+			return m.runtime
+		}
+	}
+	return ""
+}
+
+// cgroupPaths returns the cgroups of the agent, which are nested under the container on cgroup v1
+func cgroupPaths() string {
+	data, err := containerdep.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	return data
+}
+
+// rootMountSource returns the mount of the root filesystem, which is the image layers of the container on cgroup v2
+func rootMountSource() string {
+	data, err := containerdep.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(data, "\n") {
+		// fields are: id, parent id, major:minor, root, mount point, options...
+		fields := strings.Fields(line)
+		if len(fields) > 4 && fields[4] == "/" {
+			return line
+		}
+	}
+	return ""
+}
+
+func detectOrchestrator() string {
+	if containerdep.Getenv(ecsMetadataEnvV4) != "" || containerdep.Getenv(ecsMetadataEnv) != "" {
+		return constants.OrchestratorEcs
+	}
+	if containerdep.Getenv(kubernetesHostEnv) != "" ||
+		containerdep.FileExists(kubernetesAccountDir) ||
+		strings.Contains(cgroupPaths(), "kubepods") {
+		return constants.OrchestratorKubernetes
+	}
+	return ""
+}
+
+func detectHost(log log.T, orchestrator string) string {
+	if isFargate(log, orchestrator) {
+		return constants.ContainerHostFargate
+	}
+	if containerdep.FileExists("/.bottlerocket") {
+		return constants.ContainerHostBottlerocket
+	}
+	if osRelease, err := containerdep.ReadFile("/etc/os-release"); err == nil {
+		for _, line := range strings.Split(osRelease, "\n") {
+			if strings.Trim(strings.TrimPrefix(line, "ID="), `"'`) == constants.ContainerHostBottlerocket {
+				return constants.ContainerHostBottlerocket
+			}
+		}
+	}
+	return ""
+}
+
+func isFargate(log log.T, orchestrator string) bool {
+	if containerdep.Getenv(executionEnv) == executionEnvFargate {
+		return true
+	}
+	if orchestrator != constants.OrchestratorEcs {
+		return false
+	}
+	metadataURI := containerdep.Getenv(ecsMetadataEnvV4)
+	if metadataURI == "" {
+		metadataURI = containerdep.Getenv(ecsMetadataEnv)
+	}
+	launchType, err := containerdep.TaskLaunchType(metadataURI)
+	if err != nil {
+		log.Debugf("failed to read the launch type of the ECS task: %v", err)
+		return false
+	}
+	return launchType == launchTypeFargate
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containerdetect
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/utils"
+)
+
+// taskMetadataTimeout bounds the query of the ECS task metadata endpoint
+const taskMetadataTimeout = 2 * time.Second
+
+type containerDep interface {
+	FileExists(path string) bool
+	ReadFile(path string) (string, error)
+	Getenv(name string) string
+	TaskLaunchType(metadataURI string) (string, error)
+}
+
+var containerdep containerDep = &containerDepImp{}
+
+type containerDepImp struct{}
+
+func (*containerDepImp) FileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (*containerDepImp) ReadFile(path string) (string, error) {
+	return utils.ReadFileTrim(path)
+}
+
+func (*containerDepImp) Getenv(name string) string {
+	return os.Getenv(name)
+}
+
+// TaskLaunchType returns the launch type reported by the ECS task metadata endpoint
+func (*containerDepImp) TaskLaunchType(metadataURI string) (string, error) {
+	client := &http.Client{Timeout: taskMetadataTimeout}
+	resp, err := client.Get(metadataURI + "/task")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("task metadata endpoint returned %v", resp.Status)
+	}
+	var task struct {
+		LaunchType string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return "", err
+	}
+	return task.LaunchType, nil
+}
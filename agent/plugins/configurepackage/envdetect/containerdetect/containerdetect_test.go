@@ -0,0 +1,129 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containerdetect
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+var loggerMock = log.NewMockLog()
+
+// containerDepStub serves files and environment variables from maps
+type containerDepStub struct {
+	files      map[string]string
+	env        map[string]string
+	launchType string
+}
+
+func (s *containerDepStub) FileExists(path string) bool {
+	_, ok := s.files[path]
+	return ok
+}
+
+func (s *containerDepStub) ReadFile(path string) (string, error) {
+	if data, ok := s.files[path]; ok {
+		return data, nil
+	}
+	return "", errors.New("file not found")
+}
+
+func (s *containerDepStub) Getenv(name string) string {
+	return s.env[name]
+}
+
+func (s *containerDepStub) TaskLaunchType(metadataURI string) (string, error) {
+	if s.launchType == "" {
+		return "", errors.New("no task metadata")
+	}
+	return s.launchType, nil
+}
+
+func collectWith(stub *containerDepStub) *Container {
+	containerdep = stub
+	container, _ := CollectContainerData(loggerMock)
+	return container
+}
+
+func TestCollectContainerData(t *testing.T) {
+	defer func(dep containerDep) { containerdep = dep }(containerdep)
+
+	testCases := []struct {
+		name     string
+		stub     *containerDepStub
+		expected Container
+	}{
+		{
+			"ec2 host",
+			&containerDepStub{files: map[string]string{
+				"/proc/self/cgroup":    "1:name=systemd:/system.slice/amazon-ssm-agent.service",
+				"/proc/self/mountinfo": "25 1 259:1 / / rw,noatime shared:1 - xfs /dev/nvme0n1p1 rw",
+			}},
+			Container{},
+		},
+		{
+			"docker container",
+			&containerDepStub{files: map[string]string{"/.dockerenv": ""}},
+			Container{Containerized: true, Runtime: constants.ContainerRuntimeDocker},
+		},
+		{
+			"ecs task on ec2",
+			&containerDepStub{
+				files: map[string]string{"/proc/self/cgroup": "3:cpu:/ecs/6f0c2d8e/4d1f5a6b"},
+				env:   map[string]string{ecsMetadataEnvV4: "http://169.254.170.2/v4/abc", executionEnv: "AWS_ECS_EC2"},
+			},
+			Container{Containerized: true, Orchestrator: constants.OrchestratorEcs},
+		},
+		{
+			"ecs task on fargate",
+			&containerDepStub{
+				files:      map[string]string{"/proc/self/mountinfo": "1 0 0:1 / / rw - overlay overlay rw,lowerdir=/var/lib/containerd/io.containerd.snapshotter.v1.overlayfs/1/fs"},
+				env:        map[string]string{ecsMetadataEnvV4: "http://169.254.170.2/v4/abc"},
+				launchType: launchTypeFargate,
+			},
+			Container{Containerized: true, Runtime: constants.ContainerRuntimeContainerd, Orchestrator: constants.OrchestratorEcs, Host: constants.ContainerHostFargate},
+		},
+		{
+			"eks pod",
+			&containerDepStub{
+				files: map[string]string{"/proc/self/cgroup": "11:memory:/kubepods/burstable/pod1234/crio-5678"},
+				env:   map[string]string{kubernetesHostEnv: "10.100.0.1"},
+			},
+			Container{Containerized: true, Runtime: constants.ContainerRuntimeCrio, Orchestrator: constants.OrchestratorKubernetes},
+		},
+		{
+			"bottlerocket control container",
+			&containerDepStub{files: map[string]string{
+				"/proc/self/cgroup": "0::/../../system.slice/containerd.service",
+				"/.bottlerocket":    "",
+			}},
+			Container{Containerized: true, Runtime: constants.ContainerRuntimeContainerd, Host: constants.ContainerHostBottlerocket},
+		},
+		{
+			"lxc container",
+			&containerDepStub{env: map[string]string{"container": "lxc-libvirt"}},
+			Container{Containerized: true, Runtime: constants.ContainerRuntimeLxc},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, *collectWith(testCase.stub))
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package ec2infradetect
 
 import (
+	"strings"
+
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
 )
 
+const managedInstancePrefix = "mi-"
+
 // Ec2Infrastructure contains information about instance, region and account
 // queried from Ec2 metadata service
 type Ec2Infrastructure struct {
@@ -12,6 +17,8 @@ type Ec2Infrastructure struct {
 	AccountID        string
 	AvailabilityZone string
 	InstanceType     string
+	// Provenance tells whether the instance is an EC2 instance, an on-premises server or an edge device
+	Provenance string
 }
 
 // CollectEc2Infrastructure queries Ec2 metadata service for infrastructure
@@ -28,6 +35,18 @@ func CollectEc2Infrastructure(log log.T) (*Ec2Infrastructure, error) {
 		Region:           region,
 		AvailabilityZone: availabilityZone,
 		InstanceType:     instanceType,
+		Provenance:       provenance(instanceID),
 	}
 	return e, nil
 }
+
+// provenance derives where the instance runs from its instance id and the identity it registered with
+func provenance(instanceID string) string {
+	if !strings.HasPrefix(instanceID, managedInstancePrefix) {
+		return constants.ProvenanceEc2
+	}
+	if isIotDevice() {
+		return constants.ProvenanceEdge
+	}
+	return constants.ProvenanceOnPremises
+}
@@ -1,6 +1,7 @@
 package ec2infradetect
 
 import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 )
@@ -15,6 +16,11 @@ type platformProviderDep interface {
 
 var platformProviderdep platformProviderDep = &platformProviderDepImp{}
 
+var isIotDevice = func() bool {
+	config, err := appconfig.Config(false)
+	return err == nil && config.Iot.Enabled()
+}
+
 type platformProviderDepImp struct{}
 
 func (*platformProviderDepImp) InstanceID(log log.T) (string, error) {
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -27,3 +28,14 @@ func TestCollectEc2Infrastructure(t *testing.T) {
 	assert.Equal(t, "reg1", result.Region)
 	assert.NoError(t, err)
 }
+
+func TestProvenance(t *testing.T) {
+	defer func(orig func() bool) { isIotDevice = orig }(isIotDevice)
+	isIotDevice = func() bool { return false }
+
+	assert.Equal(t, constants.ProvenanceEc2, provenance("i-1234567890abcdef0"))
+	assert.Equal(t, constants.ProvenanceOnPremises, provenance("mi-1234567890abcdef0"))
+
+	isIotDevice = func() bool { return true }
+	assert.Equal(t, constants.ProvenanceEdge, provenance("mi-1234567890abcdef0"))
+}
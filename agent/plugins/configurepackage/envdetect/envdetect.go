@@ -2,6 +2,8 @@ package envdetect
 
 import (
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/clusterdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/containerdetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect"
 )
@@ -9,9 +11,13 @@ import (
 // Environment contains data for:
 // * Operating system
 // * Ec2 infrastructure
+// * Container the agent runs in
+// * Failover cluster the instance is a node of
 type Environment struct {
 	OperatingSystem   *osdetect.OperatingSystem
 	Ec2Infrastructure *ec2infradetect.Ec2Infrastructure
+	Container         *containerdetect.Container
+	Cluster           *clusterdetect.Cluster
 }
 
 type Collector interface {
@@ -21,7 +27,7 @@ type Collector interface {
 type CollectorImp struct {
 }
 
-// CollectData queries operating system and infrastructure data
+// CollectData queries operating system, infrastructure, container and cluster data
 func (cd *CollectorImp) CollectData(log log.T) (*Environment, error) {
 	os, err := osdetect.CollectOSData(log)
 	if err != nil {
@@ -33,9 +39,21 @@ func (cd *CollectorImp) CollectData(log log.T) (*Environment, error) {
 		return nil, err
 	}
 
+	container, err := containerdetect.CollectContainerData(log)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := clusterdetect.CollectClusterData(log)
+	if err != nil {
+		return nil, err
+	}
+
 	e := &Environment{
 		OperatingSystem:   os,
 		Ec2Infrastructure: ec2inf,
+		Container:         container,
+		Cluster:           cluster,
 	}
 	return e, nil
 }
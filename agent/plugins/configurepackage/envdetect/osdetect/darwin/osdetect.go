@@ -1,6 +1,7 @@
 package darwin
 
 import (
+	"os"
 	"os/exec"
 	"strings"
 
@@ -12,6 +13,8 @@ import (
 type Detector struct {
 }
 
+const systemVersionCompatEnv = "SYSTEM_VERSION_COMPAT=0"
+
 func (*Detector) DetectPkgManager(platform string, version string, family string) (string, error) {
 	return c.PackageManagerMac, nil
 }
@@ -21,7 +24,10 @@ func (*Detector) DetectInitSystem() (string, error) {
 }
 
 func (*Detector) DetectPlatform(_ log.T) (string, string, string, error) {
-	cmdOut, err := exec.Command("/usr/bin/sw_vers", "-productVersion").Output()
+	cmd := exec.Command("/usr/bin/sw_vers", "-productVersion")
+	// agents built with an older SDK are told 10.16 instead of the actual version of macOS 11 and later
+	cmd.Env = append(os.Environ(), systemVersionCompatEnv)
+	cmdOut, err := cmd.Output()
 	if err != nil {
 		return "", "", "", err
 	}
@@ -29,6 +35,25 @@ func (*Detector) DetectPlatform(_ log.T) (string, string, string, error) {
 	return c.PlatformDarwin, extractDarwinVersion(cmdOut), c.PlatformFamilyDarwin, nil
 }
 
+// DetectNativeArchitecture returns arm64 on Apple silicon, where an x86_64 agent runs translated by Rosetta and
+// packages for arm64 are preferred
+func (*Detector) DetectNativeArchitecture(log log.T) string {
+	cmdOut, err := exec.Command("/usr/sbin/sysctl", "-n", "hw.optional.arm64").Output()
+	if err != nil {
+		// the key doesn't exist on Intel processors
+		log.Debugf("Could not read hw.optional.arm64, proceeding with the agent architecture - %v", err)
+		return ""
+	}
+	return parseNativeArchitecture(cmdOut)
+}
+
+func parseNativeArchitecture(data []byte) string {
+	if strings.TrimSpace(string(data)) == "1" {
+		return c.ArchitectureArm64
+	}
+	return ""
+}
+
 func extractDarwinVersion(data []byte) string {
 	return strings.TrimSpace(string(data))
 }
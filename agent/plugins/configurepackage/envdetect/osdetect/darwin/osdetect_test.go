@@ -40,3 +40,9 @@ func TestDetectPkgManager(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, c.PackageManagerMac, result)
 }
+
+func TestParseNativeArchitecture(t *testing.T) {
+	assert.Equal(t, c.ArchitectureArm64, parseNativeArchitecture([]byte("1\n")))
+	assert.Equal(t, "", parseNativeArchitecture([]byte("0\n")))
+	assert.Equal(t, "", parseNativeArchitecture(nil))
+}
@@ -6,9 +6,11 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	agentplatform "github.com/aws/amazon-ssm-agent/agent/platform"
 
 	c "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/utils"
@@ -17,7 +19,13 @@ import (
 type Detector struct {
 }
 
+var detectImmutableOS = agentplatform.DetectImmutableOS
+
 func (*Detector) DetectPkgManager(platform string, version string, family string) (string, error) {
+	// the image based editions of the rpm families can't be changed with dnf or yum
+	if immutable, ok := detectImmutableOS(); ok && immutable.Mechanism == agentplatform.ImmutableOSMechanismRpmOstree {
+		return c.PackageManagerRpmOstree, nil
+	}
 	switch family {
 	case c.PlatformFamilyDebian:
 		return c.PackageManagerApt, nil
@@ -32,12 +40,29 @@ func (*Detector) DetectPkgManager(platform string, version string, family string
 	case c.PlatformFamilyFedora:
 		return c.PackageManagerDnf, nil
 	case c.PlatformFamilyRhel:
+		if usesDnf(platform, version) {
+			return c.PackageManagerDnf, nil
+		}
 		return c.PackageManagerYum, nil
 	default:
 		return "", fmt.Errorf("could not detect package manager for: `%s`, `%s`, `%s`", platform, version, family)
 	}
 }
 
+// usesDnf returns true for the rhel platforms that replaced yum with dnf, yum may not be installed on their
+// minimal images
+func usesDnf(platform string, version string) bool {
+	switch platform {
+	case c.PlatformRocky, c.PlatformAlmaLinux:
+		return true
+	case c.PlatformAmazon:
+		// Amazon Linux 2023 and later, Amazon Linux 2 reports version 2 and Amazon Linux AMI reports 2018.03
+		major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+		return err == nil && major >= 2022
+	}
+	return false
+}
+
 func (*Detector) DetectInitSystem() (string, error) {
 	var cmdOut []byte
 	var err error
@@ -58,6 +83,11 @@ func (*Detector) DetectInitSystem() (string, error) {
 		return c.InitDocker, nil
 	}
 
+	// alpine runs openrc, its busybox ps and service don't tell
+	if _, err = os.Stat("/sbin/openrc"); err == nil {
+		return c.InitOpenrc, nil
+	}
+
 	cmdOut, err = exec.Command("service", "--version").Output()
 	if err == nil && strings.Contains(strings.ToLower(string(cmdOut)), "systemd") {
 		return c.InitSystemd, nil
@@ -395,7 +425,7 @@ func platformFamilyForPlatform(platform string) (string, error) {
 	switch platform {
 	case c.PlatformUbuntu, c.PlatformDebian, c.PlatformRaspbian:
 		return c.PlatformFamilyDebian, nil
-	case c.PlatformRedhat, c.PlatformCentos, c.PlatformAmazon:
+	case c.PlatformRedhat, c.PlatformCentos, c.PlatformAmazon, c.PlatformRocky, c.PlatformAlmaLinux:
 		return c.PlatformFamilyRhel, nil
 	case c.PlatformFedora:
 		return c.PlatformFamilyFedora, nil
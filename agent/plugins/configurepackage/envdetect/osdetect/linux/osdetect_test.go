@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"testing"
 
+	agentplatform "github.com/aws/amazon-ssm-agent/agent/platform"
 	c "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
 	"github.com/stretchr/testify/assert"
 )
@@ -174,6 +175,14 @@ func TestParseOSreleaseFile(t *testing.T) {
 			[]string{`NAME=Fedora`, `VERSION="20 (Heisenbug)"`, `ID=fedora`, `VERSION_ID=20`, `PRETTY_NAME="Fedora 20 (Heisenbug)"`, `ANSI_COLOR="0;34"`, `CPE_NAME="cpe:/o:fedoraproject:fedora:20"`, `HOME_URL="https://fedoraproject.org/"`, `BUG_REPORT_URL="https://bugzilla.redhat.com/"`, `REDHAT_BUGZILLA_PRODUCT="Fedora"`, `REDHAT_BUGZILLA_PRODUCT_VERSION=20`, `REDHAT_SUPPORT_PRODUCT="Fedora"`, `REDHAT_SUPPORT_PRODUCT_VERSION=20`},
 			"fedora", "20", false,
 		},
+		{
+			[]string{`NAME="Amazon Linux"`, `VERSION="2023"`, `ID="amzn"`, `ID_LIKE="fedora"`, `VERSION_ID="2023"`, `PLATFORM_ID="platform:al2023"`, `PRETTY_NAME="Amazon Linux 2023"`},
+			"amazon", "2023", false,
+		},
+		{
+			[]string{`NAME="Rocky Linux"`, `VERSION="9.1 (Blue Onyx)"`, `ID="rocky"`, `ID_LIKE="rhel centos fedora"`, `VERSION_ID="9.1"`, `PRETTY_NAME="Rocky Linux 9.1 (Blue Onyx)"`},
+			"rocky", "9.1", false,
+		},
 		{
 			[]string{`NAME=Fedora`, `VERSION="21 (Twenty One)"`, `ID=fedora`, `VERSION_ID=21`, `PRETTY_NAME="Fedora 21 (Twenty One)"`, `ANSI_COLOR="0;34"`, `CPE_NAME="cpe:/o:fedoraproject:fedora:21"`, `HOME_URL="https://fedoraproject.org/"`, `BUG_REPORT_URL="https://bugzilla.redhat.com/"`, `REDHAT_BUGZILLA_PRODUCT="Fedora"`, `REDHAT_BUGZILLA_PRODUCT_VERSION=21`, `REDHAT_SUPPORT_PRODUCT="Fedora"`, `REDHAT_SUPPORT_PRODUCT_VERSION=21`},
 			"fedora", "21", false,
@@ -331,6 +340,8 @@ func TestPlatformFamilyForPlatform(t *testing.T) {
 		{"centos", "rhel", false},
 		{"redhat", "rhel", false},
 		{"amazon", "rhel", false},
+		{"rocky", "rhel", false},
+		{"almalinux", "rhel", false},
 		{"suse", "suse", false},
 		{"opensuse", "suse", false},
 		{"opensuseleap", "suse", false},
@@ -370,6 +381,12 @@ func TestDetectPackageManager(t *testing.T) {
 		{"", "", "mac_os_x", "", true},
 		{"", "", "debian", "apt", false},
 		{"", "", "rhel", "yum", false},
+		{"amazon", "2", "rhel", "yum", false},
+		{"amazon", "2018.03", "rhel", "yum", false},
+		{"amazon", "2023", "rhel", "dnf", false},
+		{"redhat", "8.6", "rhel", "yum", false},
+		{"rocky", "9.1", "rhel", "dnf", false},
+		{"almalinux", "8.7", "rhel", "dnf", false},
 		{"", "", "fedora", "dnf", false},
 		{"", "", "alpine", "alpine", false},
 		{"", "", "suse", "zypper", false},
@@ -392,3 +409,22 @@ func TestDetectPackageManager(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectPkgManagerOnImmutableOS(t *testing.T) {
+	defer func() { detectImmutableOS = agentplatform.DetectImmutableOS }()
+	d := Detector{}
+
+	detectImmutableOS = func() (agentplatform.ImmutableOS, bool) {
+		return agentplatform.ImmutableOS{Name: "fedora-coreos", Mechanism: agentplatform.ImmutableOSMechanismRpmOstree}, true
+	}
+	result, err := d.DetectPkgManager("fedora", "38", c.PlatformFamilyFedora)
+	assert.NoError(t, err)
+	assert.Equal(t, c.PackageManagerRpmOstree, result)
+
+	detectImmutableOS = func() (agentplatform.ImmutableOS, bool) {
+		return agentplatform.ImmutableOS{Name: "flatcar", Mechanism: agentplatform.ImmutableOSMechanismVarBindMount}, true
+	}
+	result, err = d.DetectPkgManager("", "", c.PlatformFamilyGentoo)
+	assert.NoError(t, err)
+	assert.Equal(t, c.PackageManagerEmerge, result)
+}
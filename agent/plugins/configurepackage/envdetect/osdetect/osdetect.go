@@ -6,6 +6,7 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
 
+	c "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/constants"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect/darwin"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect/linux"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect/windows"
@@ -17,6 +18,12 @@ type OsDetector interface {
 	DetectPkgManager(string, string, string) (string, error)
 }
 
+// nativeArchitectureDetector is implemented by the detectors of the platforms the agent can run emulated on,
+// such as an x86_64 agent on Windows on ARM64, where the architecture of the agent isn't the one of the operating system
+type nativeArchitectureDetector interface {
+	DetectNativeArchitecture(log.T) string
+}
+
 // OperatingSystem contains operating system information and capabilities
 // Identifies are aligned with Ohai data naming.
 type OperatingSystem struct {
@@ -47,9 +54,10 @@ func CollectOSData(log log.T) (*OperatingSystem, error) {
 		return nil, err
 	}
 
+	// minimal images, such as containers, may not have an init system, packages can still be installed without it
 	init, err := d.DetectInitSystem()
 	if err != nil {
-		return nil, err
+		log.Infof("Proceeding without knowing the init system - %v", err)
 	}
 
 	pkg, err := d.DetectPkgManager(platform, platformVersion, platformFamily)
@@ -57,9 +65,11 @@ func CollectOSData(log log.T) (*OperatingSystem, error) {
 		return nil, err
 	}
 
-	arch := runtime.GOARCH
-	if arch == "amd64" {
-		arch = "x86_64"
+	arch := normalizeArchitecture(runtime.GOARCH)
+	if nd, ok := d.(nativeArchitectureDetector); ok {
+		if native := nd.DetectNativeArchitecture(log); native != "" {
+			arch = native
+		}
 	}
 
 	e := &OperatingSystem{
@@ -70,5 +80,16 @@ func CollectOSData(log log.T) (*OperatingSystem, error) {
 		InitSystem:      init,
 		PackageManager:  pkg,
 	}
-	return e, err
+	return e, nil
+}
+
+// normalizeArchitecture returns the architecture identifier packages are published for
+func normalizeArchitecture(arch string) string {
+	switch arch {
+	case "amd64":
+		return c.ArchitectureX86_64
+	case "arm64", "aarch64":
+		return c.ArchitectureArm64
+	}
+	return arch
 }
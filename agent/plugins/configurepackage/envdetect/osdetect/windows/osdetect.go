@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/aws/amazon-ssm-agent/agent/log"
 
@@ -56,6 +57,26 @@ func detectPlatformDetails(log log.T, wmioutput string) (string, string, string,
 	return c.PlatformWindows, version, c.PlatformFamilyWindows, err
 }
 
+// DetectNativeArchitecture returns arm64 on Windows on ARM64, an x86_64 agent runs there emulated and packages for
+// arm64 are preferred
+func (*Detector) DetectNativeArchitecture(log log.T) string {
+	output, err := getWmiOSInfo()
+	if err != nil {
+		log.Infof("Could not retrieve WmiOSInfo, proceeding with the agent architecture - %v", err)
+		return ""
+	}
+	return parseNativeArchitecture(output)
+}
+
+// parseNativeArchitecture parses OSArchitecture, such as "64-bit" or "ARM 64-bit Processor"
+func parseNativeArchitecture(wmioutput string) string {
+	match := regexp.MustCompile(`(?m)^\s*OSArchitecture\s*=\s*(.+?)\s*$`).FindStringSubmatch(wmioutput)
+	if len(match) > 0 && strings.Contains(strings.ToUpper(match[1]), "ARM") {
+		return c.ArchitectureArm64
+	}
+	return ""
+}
+
 func isWindowsNano(operatingSystemSKU string) bool {
 	return operatingSystemSKU == c.SKUProductStandardNanoServer ||
 		operatingSystemSKU == c.SKUProductDatacenterNanoServer
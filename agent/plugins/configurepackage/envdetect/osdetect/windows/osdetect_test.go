@@ -249,6 +249,25 @@ func TestParseVersion(t *testing.T) {
 	}
 }
 
+func TestParseNativeArchitecture(t *testing.T) {
+	data := []struct {
+		name      string
+		wmioutput string
+		expected  string
+	}{
+		{"x64", "\r\nOSArchitecture=64-bit\r\nVersion=10.0.17763\r\n", ""},
+		{"arm64", "\r\nOSArchitecture=ARM 64-bit Processor\r\nVersion=10.0.22621\r\n", c.ArchitectureArm64},
+		{"whitespace", "  OSArchitecture  =  ARM 64-bit Processor  ", c.ArchitectureArm64},
+		{"empty input", "", ""},
+	}
+
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			assert.Equal(t, d.expected, parseNativeArchitecture(d.wmioutput))
+		})
+	}
+}
+
 func TestParseOperatingSystemSKU(t *testing.T) {
 	data := []struct {
 		name        string
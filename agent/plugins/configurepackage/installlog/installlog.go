@@ -0,0 +1,190 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package installlog writes the output of the install, uninstall and validate scripts of each package to a rotating
+// log file of the package, so that a failed install can be debugged without reading the agent log.
+package installlog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// logFileName is the name of the current log file in the directory of a package, the rotated files are suffixed with
+// their number, .1 being the most recent
+const logFileName = "install.log"
+
+// Entry is the output of a script of a package action
+type Entry struct {
+	Version  string
+	Action   string
+	Status   string
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	Error    string
+}
+
+// Directory returns the directory of the log files of a package, the arn is flattened into a directory name
+func Directory(logsDirectory string, packageArn string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(packageArn)
+	return filepath.Join(logsDirectory, name)
+}
+
+// Path returns the current log file of a package
+func Path(logsDirectory string, packageArn string) string {
+	return filepath.Join(Directory(logsDirectory, packageArn), logFileName)
+}
+
+// Append appends the output of a script to the log file of a package, the file is rotated first when the entry
+// would make it larger than the configured size
+func Append(config appconfig.PackageInstallLogsCfg, logsDirectory string, packageArn string, entry Entry) error {
+	directory := Directory(logsDirectory, packageArn)
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+	content := format(packageArn, entry, time.Now())
+	path := filepath.Join(directory, logFileName)
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 && info.Size()+int64(len(content)) > int64(config.MaxFileSizeKB)*1024 {
+		if err = rotate(path, config.MaxFiles); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(content)
+	return err
+}
+
+// format returns the lines of an entry in the log file, after a header identifying the script run
+func format(packageArn string, entry Entry, now time.Time) []byte {
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "==== %v %v %v %v ====\n", now.UTC().Format(time.RFC3339), packageArn, entry.Version, entry.Action)
+	fmt.Fprintf(&content, "status: %v, exit code: %v\n", entry.Status, entry.ExitCode)
+	if entry.Error != "" {
+		fmt.Fprintf(&content, "error: %v\n", entry.Error)
+	}
+	for _, output := range []struct{ name, value string }{{"stdout", entry.Stdout}, {"stderr", entry.Stderr}} {
+		if output.value == "" {
+			continue
+		}
+		fmt.Fprintf(&content, "---- %v\n%v", output.name, output.value)
+		if !strings.HasSuffix(output.value, "\n") {
+			content.WriteString("\n")
+		}
+	}
+	return content.Bytes()
+}
+
+// rotate renames the log file to .1 after shifting the rotated files by one, the files beyond maxFiles are removed
+func rotate(path string, maxFiles int) error {
+	os.Remove(rotatedPath(path, maxFiles))
+	for i := maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedPath(path, i), rotatedPath(path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(path, rotatedPath(path, 1))
+}
+
+func rotatedPath(path string, number int) string {
+	return fmt.Sprintf("%v.%d", path, number)
+}
+
+// Find returns the current log file of a package given by its name or arn. A name matches the arns ending with it,
+// the most recently written log file is returned when several packages match
+func Find(logsDirectory string, name string) (string, error) {
+	if path := Path(logsDirectory, name); fileExists(path) {
+		return path, nil
+	}
+	packages, err := ioutil.ReadDir(logsDirectory)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	suffix := "_" + filepath.Base(Directory("", name))
+	var found string
+	var latest time.Time
+	for _, pkg := range packages {
+		path := filepath.Join(logsDirectory, pkg.Name(), logFileName)
+		if !pkg.IsDir() || !strings.HasSuffix(pkg.Name(), suffix) {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			found, latest = path, info.ModTime()
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("no install log of package %v in %v", name, logsDirectory)
+	}
+	return found, nil
+}
+
+// Packages returns the directories of the packages with an install log file
+func Packages(logsDirectory string) ([]string, error) {
+	packages, err := ioutil.ReadDir(logsDirectory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, pkg := range packages {
+		if pkg.IsDir() && fileExists(filepath.Join(logsDirectory, pkg.Name(), logFileName)) {
+			names = append(names, pkg.Name())
+		}
+	}
+	return names, nil
+}
+
+// Tail returns the last lines of a log file, continuing in the rotated files when the current file has fewer lines
+func Tail(path string, lines int) (string, error) {
+	var tail []string
+	for number := 0; len(tail) < lines; number++ {
+		current := path
+		if number > 0 {
+			current = rotatedPath(path, number)
+		}
+		content, err := ioutil.ReadFile(current)
+		if err != nil {
+			if number > 0 && os.IsNotExist(err) {
+				break
+			}
+			return "", err
+		}
+		fileLines := strings.SplitAfter(string(content), "\n")
+		if fileLines[len(fileLines)-1] == "" {
+			fileLines = fileLines[:len(fileLines)-1]
+		}
+		tail = append(fileLines, tail...)
+	}
+	if len(tail) > lines {
+		tail = tail[len(tail)-lines:]
+	}
+	return strings.Join(tail, ""), nil
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,98 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installlog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+const packageArn = "arn:aws:ssm:us-east-1:123456789012:document/MyPackage"
+
+func TestAppendWritesTheOutputOfTheScripts(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "packagelogs")
+	defer os.RemoveAll(dir)
+	config := appconfig.PackageInstallLogsCfg{Enabled: true, MaxFileSizeKB: 16, MaxFiles: 2}
+
+	err := Append(config, dir, packageArn, Entry{Version: "1.2.0", Action: "install", Status: "Failed", ExitCode: 1, Stdout: "copying files", Stderr: "disk full\n"})
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "arn_aws_ssm_us-east-1_123456789012_document_MyPackage", "install.log"))
+	assert.NoError(t, err)
+	lines := strings.Split(string(content), "\n")
+	assert.Contains(t, lines[0], packageArn+" 1.2.0 install")
+	assert.Equal(t, []string{"status: Failed, exit code: 1", "---- stdout", "copying files", "---- stderr", "disk full", ""}, lines[1:])
+}
+
+func TestAppendRotatesTheLogFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "packagelogs")
+	defer os.RemoveAll(dir)
+	config := appconfig.PackageInstallLogsCfg{Enabled: true, MaxFileSizeKB: 16, MaxFiles: 2}
+	output := strings.Repeat("x", 10*1024)
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, Append(config, dir, packageArn, Entry{Version: "1.2.0", Action: "install", Stdout: output}))
+	}
+
+	files, _ := ioutil.ReadDir(Directory(dir, packageArn))
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.Equal(t, []string{"install.log", "install.log.1", "install.log.2"}, names)
+}
+
+func TestFindByNameAndTail(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "packagelogs")
+	defer os.RemoveAll(dir)
+	config := appconfig.PackageInstallLogsCfg{Enabled: true, MaxFileSizeKB: 16, MaxFiles: 2}
+	Append(config, dir, packageArn, Entry{Version: "1.2.0", Action: "install", Stdout: "one\ntwo\nthree\n"})
+
+	path, err := Find(dir, "MyPackage")
+	assert.NoError(t, err)
+	assert.Equal(t, Path(dir, packageArn), path)
+
+	tail, err := Tail(path, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "two\nthree\n", tail)
+
+	_, err = Find(dir, "OtherPackage")
+	assert.Error(t, err)
+
+	packages, err := Packages(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"arn_aws_ssm_us-east-1_123456789012_document_MyPackage"}, packages)
+}
+
+func TestTailContinuesInTheRotatedFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "packagelogs")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "install.log")
+	ioutil.WriteFile(path+".1", []byte("a\nb\n"), 0600)
+	ioutil.WriteFile(path, []byte("c\n"), 0600)
+
+	tail, err := Tail(path, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "b\nc\n", tail)
+
+	tail, err = Tail(path, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\n", tail)
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package integrity verifies the files of installed packages against the checksums recorded when they were installed.
+package integrity
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/sbom"
+)
+
+// baselineFileSuffix ends the names of the baseline files in the integrity directory
+const baselineFileSuffix = ".json"
+
+// Baseline is the sha256 checksum of each file of an installed package, keyed by the path relative to the package directory
+type Baseline struct {
+	PackageName string            `json:"packageName"`
+	PackageArn  string            `json:"packageArn"`
+	Version     string            `json:"version"`
+	Directory   string            `json:"directory"`
+	Recorded    time.Time         `json:"recorded"`
+	Files       map[string]string `json:"files"`
+}
+
+// Drift lists the files of a package that changed since its baseline was recorded
+type Drift struct {
+	Modified []string
+	Missing  []string
+	Added    []string
+}
+
+// Empty returns true if no file changed
+func (drift Drift) Empty() bool {
+	return len(drift.Modified) == 0 && len(drift.Missing) == 0 && len(drift.Added) == 0
+}
+
+// IsMonitored returns true if the configuration verifies the package, given by its name or arn
+func IsMonitored(config appconfig.PackageIntegrityCfg, packageName string, packageArn string) bool {
+	if !config.Enabled {
+		return false
+	}
+	if len(config.Packages) == 0 {
+		return true
+	}
+	for _, name := range config.Packages {
+		if name == packageName || name == packageArn {
+			return true
+		}
+	}
+	return false
+}
+
+// NewBaseline hashes the files of the package installed in the directory
+func NewBaseline(packageName string, packageArn string, version string, directory string, recorded time.Time) (baseline Baseline, err error) {
+	baseline = Baseline{
+		PackageName: packageName,
+		PackageArn:  packageArn,
+		Version:     version,
+		Directory:   directory,
+		Recorded:    recorded,
+		Files:       make(map[string]string),
+	}
+	files, err := sbom.CollectFiles(directory)
+	if err != nil {
+		return baseline, fmt.Errorf("failed to hash the files of %v: %v", directory, err)
+	}
+	for _, file := range files {
+		baseline.Files[file.Path] = file.SHA256
+	}
+	return baseline, nil
+}
+
+// WriteBaseline stores the baseline in the integrity directory, replacing the baseline of a previous version of the package
+func WriteBaseline(integrityDirectory string, baseline Baseline) error {
+	content, err := jsonutil.Marshal(baseline)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirs(integrityDirectory); err != nil {
+		return err
+	}
+	return fileutil.WriteAllText(baselinePath(integrityDirectory, baseline.PackageArn), content)
+}
+
+// DeleteBaseline stops the verification of an uninstalled package
+func DeleteBaseline(integrityDirectory string, packageArn string) error {
+	path := baselinePath(integrityDirectory, packageArn)
+	if !fileutil.Exists(path) {
+		return nil
+	}
+	return fileutil.DeleteFile(path)
+}
+
+// LoadBaselines reads the baselines of all installed packages, sorted by package name
+func LoadBaselines(integrityDirectory string) (baselines []Baseline, err error) {
+	entries, err := ioutil.ReadDir(integrityDirectory)
+	if err != nil {
+		if fileutil.Exists(integrityDirectory) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), baselineFileSuffix) {
+			continue
+		}
+		var baseline Baseline
+		if err = jsonutil.UnmarshalFile(filepath.Join(integrityDirectory, entry.Name()), &baseline); err != nil {
+			return nil, fmt.Errorf("failed to read baseline %v: %v", entry.Name(), err)
+		}
+		baselines = append(baselines, baseline)
+	}
+	sort.Slice(baselines, func(i, j int) bool { return baselines[i].PackageName < baselines[j].PackageName })
+	return baselines, nil
+}
+
+// Verify hashes the files of the package again and compares them to the baseline
+func Verify(baseline Baseline) (drift Drift, err error) {
+	files, err := sbom.CollectFiles(baseline.Directory)
+	if err != nil && fileutil.Exists(baseline.Directory) {
+		return drift, fmt.Errorf("failed to hash the files of %v: %v", baseline.Directory, err)
+	}
+
+	current := make(map[string]bool)
+	for _, file := range files {
+		current[file.Path] = true
+		checksum, ok := baseline.Files[file.Path]
+		if !ok {
+			drift.Added = append(drift.Added, file.Path)
+		} else if checksum != file.SHA256 {
+			drift.Modified = append(drift.Modified, file.Path)
+		}
+	}
+	for path := range baseline.Files {
+		if !current[path] {
+			drift.Missing = append(drift.Missing, path)
+		}
+	}
+	sort.Strings(drift.Missing)
+	return drift, nil
+}
+
+// baselinePath returns the path of the baseline file of a package, the arn is flattened into a file name
+func baselinePath(integrityDirectory string, packageArn string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(packageArn)
+	return filepath.Join(integrityDirectory, name+baselineFileSuffix)
+}
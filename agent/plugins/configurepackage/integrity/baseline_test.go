@@ -0,0 +1,103 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package integrity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPackageArn = "arn:aws:ssm:us-east-1:123456789012:document/MyPackage"
+
+func setupPackage(t *testing.T) (root string, packageDir string) {
+	root, err := ioutil.TempDir("", "integrity")
+	assert.NoError(t, err)
+	packageDir = filepath.Join(root, "package")
+	assert.NoError(t, os.MkdirAll(filepath.Join(packageDir, "bin"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "install.sh"), []byte("echo install"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "uninstall.sh"), []byte("echo uninstall"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "bin", "tool"), []byte("tool"), 0600))
+	return root, packageDir
+}
+
+func TestBaselineRoundTrip(t *testing.T) {
+	root, packageDir := setupPackage(t)
+	defer os.RemoveAll(root)
+	integrityDir := filepath.Join(root, "integrity")
+
+	baseline, err := NewBaseline("MyPackage", testPackageArn, "1.0.0", packageDir, time.Unix(1500000000, 0).UTC())
+	assert.NoError(t, err)
+	assert.Len(t, baseline.Files, 3)
+	assert.Contains(t, baseline.Files, "bin/tool")
+	assert.NoError(t, WriteBaseline(integrityDir, baseline))
+
+	baselines, err := LoadBaselines(integrityDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []Baseline{baseline}, baselines)
+
+	assert.NoError(t, DeleteBaseline(integrityDir, testPackageArn))
+	assert.NoError(t, DeleteBaseline(integrityDir, testPackageArn))
+	baselines, err = LoadBaselines(integrityDir)
+	assert.NoError(t, err)
+	assert.Empty(t, baselines)
+}
+
+func TestLoadBaselinesMissingDirectory(t *testing.T) {
+	baselines, err := LoadBaselines(filepath.Join(os.TempDir(), "integrity-does-not-exist"))
+	assert.NoError(t, err)
+	assert.Empty(t, baselines)
+}
+
+func TestVerify(t *testing.T) {
+	root, packageDir := setupPackage(t)
+	defer os.RemoveAll(root)
+	baseline, err := NewBaseline("MyPackage", testPackageArn, "1.0.0", packageDir, time.Now())
+	assert.NoError(t, err)
+
+	drift, err := Verify(baseline)
+	assert.NoError(t, err)
+	assert.True(t, drift.Empty())
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "bin", "tool"), []byte("tampered"), 0600))
+	assert.NoError(t, os.Remove(filepath.Join(packageDir, "uninstall.sh")))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "backdoor"), []byte("x"), 0600))
+	drift, err = Verify(baseline)
+	assert.NoError(t, err)
+	assert.Equal(t, Drift{Modified: []string{"bin/tool"}, Missing: []string{"uninstall.sh"}, Added: []string{"backdoor"}}, drift)
+}
+
+func TestVerifyRemovedPackage(t *testing.T) {
+	root, packageDir := setupPackage(t)
+	baseline, err := NewBaseline("MyPackage", testPackageArn, "1.0.0", packageDir, time.Now())
+	assert.NoError(t, err)
+	os.RemoveAll(root)
+
+	drift, err := Verify(baseline)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bin/tool", "install.sh", "uninstall.sh"}, drift.Missing)
+}
+
+func TestIsMonitored(t *testing.T) {
+	assert.False(t, IsMonitored(appconfig.PackageIntegrityCfg{}, "MyPackage", testPackageArn))
+	assert.True(t, IsMonitored(appconfig.PackageIntegrityCfg{Enabled: true}, "MyPackage", testPackageArn))
+	assert.True(t, IsMonitored(appconfig.PackageIntegrityCfg{Enabled: true, Packages: []string{"MyPackage"}}, "MyPackage", testPackageArn))
+	assert.True(t, IsMonitored(appconfig.PackageIntegrityCfg{Enabled: true, Packages: []string{testPackageArn}}, "MyPackage", testPackageArn))
+	assert.False(t, IsMonitored(appconfig.PackageIntegrityCfg{Enabled: true, Packages: []string{"OtherPackage"}}, "MyPackage", testPackageArn))
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package integrity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/sbom"
+)
+
+// ExtractedFilesError lists the files extracted from the artifact of a package that do not match the checksums of
+// its manifest, the package is not installed
+type ExtractedFilesError struct {
+	Modified []string
+	Missing  []string
+}
+
+// Error describes the files that do not match
+func (e *ExtractedFilesError) Error() string {
+	var problems []string
+	if len(e.Modified) > 0 {
+		problems = append(problems, fmt.Sprintf("modified %v", strings.Join(e.Modified, ", ")))
+	}
+	if len(e.Missing) > 0 {
+		problems = append(problems, fmt.Sprintf("missing %v", strings.Join(e.Missing, ", ")))
+	}
+	return fmt.Sprintf("the extracted files do not match the checksums of the manifest: %v", strings.Join(problems, "; "))
+}
+
+// ErrorCode classifies the mismatch as ErrorCodeIntegrityFailure
+func (e *ExtractedFilesError) ErrorCode() contracts.ErrorCode {
+	return contracts.ErrorCodeIntegrityFailure
+}
+
+// VerifyExtractedFiles hashes the files extracted to the directory and compares them to the sha256 checksums the
+// manifest declares, keyed by their path with / separators. It returns the verified checksums, or an
+// ExtractedFilesError when a file is modified or missing.
+func VerifyExtractedFiles(directory string, expected map[string]string) (map[string]string, error) {
+	files, err := sbom.CollectFiles(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the extracted files of %v: %v", directory, err)
+	}
+	extracted := make(map[string]string, len(files))
+	for _, file := range files {
+		extracted[file.Path] = file.SHA256
+	}
+
+	var mismatch ExtractedFilesError
+	verified := make(map[string]string, len(expected))
+	for path, checksum := range expected {
+		actual, ok := extracted[path]
+		switch {
+		case !ok:
+			mismatch.Missing = append(mismatch.Missing, path)
+		case !strings.EqualFold(actual, checksum):
+			mismatch.Modified = append(mismatch.Modified, path)
+		default:
+			verified[path] = actual
+		}
+	}
+	if len(mismatch.Modified) > 0 || len(mismatch.Missing) > 0 {
+		sort.Strings(mismatch.Modified)
+		sort.Strings(mismatch.Missing)
+		return nil, &mismatch
+	}
+	return verified, nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyExtractedFiles(t *testing.T) {
+	root, packageDir := setupPackage(t)
+	defer os.RemoveAll(root)
+	expected := map[string]string{"install.sh": sha256Hex("echo install"), "bin/tool": sha256Hex("tool")}
+
+	verified, err := VerifyExtractedFiles(packageDir, expected)
+
+	assert.NoError(t, err)
+	assert.Equal(t, expected, verified)
+}
+
+func TestVerifyExtractedFilesMismatch(t *testing.T) {
+	root, packageDir := setupPackage(t)
+	defer os.RemoveAll(root)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "bin", "tool"), []byte("tampered"), 0600))
+	expected := map[string]string{"install.sh": sha256Hex("echo install"), "bin/tool": sha256Hex("tool"), "config.json": sha256Hex("{}")}
+
+	verified, err := VerifyExtractedFiles(packageDir, expected)
+
+	assert.Nil(t, verified)
+	mismatch, ok := err.(*ExtractedFilesError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"bin/tool"}, mismatch.Modified)
+	assert.Equal(t, []string{"config.json"}, mismatch.Missing)
+	assert.Equal(t, contracts.ErrorCodeIntegrityFailure, mismatch.ErrorCode())
+}
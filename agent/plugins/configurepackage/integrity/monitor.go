@@ -0,0 +1,166 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package integrity
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	ssmSvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/carlescere/scheduler"
+)
+
+const (
+	name = "PackageIntegrityMonitor"
+
+	// ComplianceType groups the integrity items of the installed packages
+	ComplianceType = "Custom:PackageIntegrity"
+
+	// maxReportedFiles bounds the file names listed in the details of a compliance item
+	maxReportedFiles = 10
+)
+
+// Monitor is a core module that periodically verifies the installed packages and reports a compliance item for each of them.
+type Monitor struct {
+	context            context.T
+	config             appconfig.PackageIntegrityCfg
+	integrityDirectory string
+	ssmService         ssmSvc.Service
+	instanceID         func() (string, error)
+	now                func() time.Time
+	job                *scheduler.Job
+}
+
+// NewMonitor creates a new package integrity monitor core module.
+func NewMonitor(context context.T) *Monitor {
+	return &Monitor{
+		context:            context.With("[" + name + "]"),
+		config:             context.AppConfig().Birdwatcher.Integrity,
+		integrityDirectory: appconfig.PackageIntegrityDirectory,
+		instanceID:         platform.InstanceID,
+		now:                time.Now,
+	}
+}
+
+// ModuleName returns the module name
+func (m *Monitor) ModuleName() string {
+	return name
+}
+
+// ModuleExecute starts verifying the installed packages if the monitor is enabled
+func (m *Monitor) ModuleExecute(context context.T) (err error) {
+	log := m.context.Log()
+	if !m.config.Enabled {
+		log.Debugf("Package integrity monitoring is disabled")
+		return nil
+	}
+	m.ssmService = ssmSvc.NewService()
+	if m.job, err = scheduler.Every(m.config.FrequencyMinutes).Minutes().Run(m.verifyPackages); err != nil {
+		log.Errorf("Unable to schedule package integrity monitor. %v", err)
+	}
+	return
+}
+
+// ModuleRequestStop stops verifying the installed packages
+func (m *Monitor) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	if m.job != nil {
+		m.context.Log().Info("Stopping package integrity monitor job.")
+		m.job.Quit <- true
+	}
+	return nil
+}
+
+// verifyPackages verifies the monitored packages and reports them, the report replaces the items of packages
+// that were uninstalled since the previous verification
+func (m *Monitor) verifyPackages() {
+	log := m.context.Log()
+	baselines, err := LoadBaselines(m.integrityDirectory)
+	if err != nil {
+		log.Errorf("Failed to load package baselines: %v", err)
+		return
+	}
+
+	var items []*ssm.ComplianceItemEntry
+	for _, baseline := range baselines {
+		if !IsMonitored(m.config, baseline.PackageName, baseline.PackageArn) {
+			continue
+		}
+		drift, err := Verify(baseline)
+		if err != nil {
+			log.Errorf("Failed to verify package %v: %v", baseline.PackageName, err)
+			continue
+		}
+		if !drift.Empty() {
+			log.Warnf("Package %v %v changed since it was installed: %v modified, %v missing and %v added files",
+				baseline.PackageName, baseline.Version, len(drift.Modified), len(drift.Missing), len(drift.Added))
+		}
+		items = append(items, complianceItem(baseline, drift))
+	}
+
+	instanceID, err := m.instanceID()
+	if err != nil {
+		log.Errorf("Failed to get the instance id to report package integrity: %v", err)
+		return
+	}
+	executionTime := m.now()
+	if _, err = m.ssmService.PutComplianceItems(log, &executionTime, "", "", instanceID, ComplianceType, contentHash(items), items); err != nil {
+		log.Errorf("Unable to report package integrity: %v", err)
+	}
+}
+
+// complianceItem returns the item of a package, it is non compliant if any file changed
+func complianceItem(baseline Baseline, drift Drift) *ssm.ComplianceItemEntry {
+	item := &ssm.ComplianceItemEntry{
+		Id:       aws.String(baseline.PackageName),
+		Title:    aws.String(fmt.Sprintf("%v %v", baseline.PackageName, baseline.Version)),
+		Status:   aws.String(ssm.ComplianceStatusCompliant),
+		Severity: aws.String(ssm.ComplianceSeverityHigh),
+		Details: map[string]*string{
+			"PackageVersion": aws.String(baseline.Version),
+			"Recorded":       aws.String(baseline.Recorded.UTC().Format(time.RFC3339)),
+		},
+	}
+	if !drift.Empty() {
+		item.Status = aws.String(ssm.ComplianceStatusNonCompliant)
+		item.Details["ModifiedFiles"] = aws.String(summarizeFiles(drift.Modified))
+		item.Details["MissingFiles"] = aws.String(summarizeFiles(drift.Missing))
+		item.Details["AddedFiles"] = aws.String(summarizeFiles(drift.Added))
+	}
+	return item
+}
+
+// summarizeFiles lists the first files and the count of the others
+func summarizeFiles(files []string) string {
+	if len(files) <= maxReportedFiles {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%v and %v more", strings.Join(files[:maxReportedFiles], ", "), len(files)-maxReportedFiles)
+}
+
+// contentHash returns the checksum of the reported items
+func contentHash(items []*ssm.ComplianceItemEntry) string {
+	content, _ := json.Marshal(items)
+	sum := sha256.Sum256(content)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package integrity
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	ssmSvc "github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestVerifyPackages(t *testing.T) {
+	root, packageDir := setupPackage(t)
+	defer os.RemoveAll(root)
+	integrityDir := filepath.Join(root, "integrity")
+	baseline, err := NewBaseline("MyPackage", testPackageArn, "1.0.0", packageDir, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, WriteBaseline(integrityDir, baseline))
+	unmonitored := baseline
+	unmonitored.PackageName, unmonitored.PackageArn = "OtherPackage", "OtherPackage"
+	assert.NoError(t, WriteBaseline(integrityDir, unmonitored))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(packageDir, "install.sh"), []byte("tampered"), 0600))
+
+	var items []*ssm.ComplianceItemEntry
+	service := ssmSvc.NewMockDefault()
+	service.On("PutComplianceItems", mock.Anything, mock.Anything, "", "", "i-1234567890abcdef0", ComplianceType, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { items = args.Get(7).([]*ssm.ComplianceItemEntry) }).
+		Return(&ssm.PutComplianceItemsOutput{}, nil).Once()
+
+	monitor := &Monitor{
+		context:            context.NewMockDefault(),
+		config:             appconfig.PackageIntegrityCfg{Enabled: true, Packages: []string{"MyPackage"}},
+		integrityDirectory: integrityDir,
+		ssmService:         service,
+		instanceID:         func() (string, error) { return "i-1234567890abcdef0", nil },
+		now:                time.Now,
+	}
+	monitor.verifyPackages()
+
+	service.AssertExpectations(t)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "MyPackage", *items[0].Id)
+	assert.Equal(t, ssm.ComplianceStatusNonCompliant, *items[0].Status)
+	assert.Equal(t, "install.sh", *items[0].Details["ModifiedFiles"])
+}
+
+func TestModuleExecuteDisabled(t *testing.T) {
+	monitor := &Monitor{context: context.NewMockDefault()}
+
+	assert.NoError(t, monitor.ModuleExecute(monitor.context))
+	assert.Nil(t, monitor.job)
+	assert.NoError(t, monitor.ModuleRequestStop(contracts.StopTypeSoftStop))
+}
+
+func TestSummarizeFiles(t *testing.T) {
+	assert.Equal(t, "a, b", summarizeFiles([]string{"a", "b"}))
+	files := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+	assert.Equal(t, "1, 2, 3, 4, 5, 6, 7, 8, 9, 10 and 2 more", summarizeFiles(files))
+}
@@ -38,6 +38,9 @@ import (
 // DownloadDelegate is a function that downloads a package to a directory provided by the repository
 type DownloadDelegate func(tracer trace.Tracer, targetDirectory string) error
 
+// previousSuffix is appended to the staging path of the version directory replaced by a new download
+const previousSuffix = ".previous"
+
 // InstallState is an enum describing the installation state of a package
 type InstallState uint
 
@@ -86,10 +89,17 @@ type Repository interface {
 	RefreshPackage(tracer trace.Tracer, packageArn string, version string, packageServiceName string, downloader DownloadDelegate) error
 	AddPackage(tracer trace.Tracer, packageArn string, version string, packageServiceName string, downloader DownloadDelegate) error
 	SetInstallState(tracer trace.Tracer, packageArn string, version string, state InstallState) error
+	SetVerifiedFiles(tracer trace.Tracer, packageArn string, version string, files map[string]string) error
 	GetInstallState(tracer trace.Tracer, packageArn string) (state InstallState, version string)
 	RemovePackage(tracer trace.Tracer, packageArn string, version string) error
+	SnapshotPackage(tracer trace.Tracer, packageArn string, version string) error
+	RestorePackage(tracer trace.Tracer, packageArn string, version string) error
+	DeleteSnapshot(tracer trace.Tracer, packageArn string) error
 	GetInventoryData(log log.T) []model.ApplicationData
+	GetPackageInventoryData(log log.T) []model.SSMPackageData
 	GetInstaller(tracer trace.Tracer, configuration contracts.Configuration, packageArn string, version string) installer.Installer
+	GetPackageManifest(tracer trace.Tracer, packageArn string, version string) (*PackageManifest, error)
+	GetPackageDirectory(tracer trace.Tracer, packageArn string, version string) string
 
 	LockPackage(tracer trace.Tracer, packageArn string, action string) error
 	UnlockPackage(tracer trace.Tracer, packageArn string)
@@ -97,6 +107,8 @@ type Repository interface {
 	ReadManifest(packageArn string, packageVersion string) ([]byte, error)
 	WriteManifest(packageArn string, packageVersion string, content []byte) error
 
+	CollectGarbage(tracer trace.Tracer, retention appconfig.PackageRetentionCfg, lockedPackageArn string) int64
+
 	LoadTraces(tracer trace.Tracer, packageArn string) error
 	PersistTraces(tracer trace.Tracer, packageArn string) error
 }
@@ -107,9 +119,30 @@ func NewRepository() Repository {
 		filesysdep:        &fileSysDepImp{},
 		repoRoot:          appconfig.PackageRoot,
 		lockRoot:          appconfig.PackageLockRoot,
-		manifestCachePath: appconfig.ManifestCacheDirectory,
+		stagingRoot:       appconfig.PackageStagingRoot,
+		snapshotRoot:      appconfig.PackageSnapshotRoot,
+		manifestCachePath: manifestCacheDirectory(),
+		artifactCachePath: appconfig.PackageArtifactCacheDirectory,
 		fileLocker:        filelock.NewFileLocker(),
+		lockWait:          packageLockWait(),
+	}
+}
+
+// manifestCacheDirectory returns the configured directory of the manifest cache
+func manifestCacheDirectory() string {
+	if appCfg, err := appconfig.Config(false); err == nil {
+		return appCfg.Birdwatcher.ManifestCache.Directory
+	}
+	return appconfig.ManifestCacheDirectory
+}
+
+// packageLockWait returns how long an operation waits for another operation on the same package to complete
+func packageLockWait() time.Duration {
+	lockWaitSeconds := appconfig.DefaultPackageLockWaitSeconds
+	if appCfg, err := appconfig.Config(false); err == nil {
+		lockWaitSeconds = appCfg.Birdwatcher.LockWaitSeconds
 	}
+	return time.Duration(lockWaitSeconds) * time.Second
 }
 
 // PackageInstallState represents the json structure of the current package state
@@ -120,6 +153,9 @@ type PackageInstallState struct {
 	Time                 time.Time    `json:"time"`
 	LastInstalledVersion string       `json:"lastinstalledversion"`
 	RetryCount           int          `json:"retrycount"`
+	// VerifiedFiles are the sha256 checksums of the extracted files of VerifiedVersion matching its manifest
+	VerifiedVersion string            `json:"verifiedversion,omitempty"`
+	VerifiedFiles   map[string]string `json:"verifiedfiles,omitempty"`
 }
 
 // PackageManifest represents json structure of package's online configuration file.
@@ -132,14 +168,22 @@ type PackageManifest struct {
 	AppPublisher    string `json:"apppublisher"`    // optional inventory attribute
 	AppReferenceURL string `json:"appreferenceurl"` // optional inventory attribute
 	AppType         string `json:"apptype"`         // optional inventory attribute
+	AntiRollback    bool   `json:"antirollback"`    // optional, prevents installing an older version over this one
+	// ConfigFiles are the optional paths or patterns, relative to the package directory, of the configuration files
+	// of the package, a directory matches the files under it
+	ConfigFiles []string `json:"configfiles,omitempty"`
 }
 
 type localRepository struct {
 	filesysdep        FileSysDep
 	repoRoot          string
 	lockRoot          string
+	stagingRoot       string
+	snapshotRoot      string
 	manifestCachePath string
+	artifactCachePath string
 	fileLocker        filelock.FileLocker
+	lockWait          time.Duration
 }
 
 func (repo *localRepository) LockPackage(tracer trace.Tracer, packageArn string, action string) error {
@@ -148,7 +192,7 @@ func (repo *localRepository) LockPackage(tracer trace.Tracer, packageArn string,
 		return err
 	}
 	lockPath := repo.getLockPath(packageArn)
-	return lockPackage(repo.fileLocker, lockPath, packageArn, action)
+	return waitLockPackage(repo.fileLocker, lockPath, packageArn, action, repo.lockWait)
 }
 
 func (repo *localRepository) UnlockPackage(tracer trace.Tracer, packageArn string) {
@@ -167,11 +211,22 @@ func (repo *localRepository) GetInstaller(tracer trace.Tracer,
 	configuration.OrchestrationDirectory = filepath.Join(configuration.OrchestrationDirectory, normalizeDirectory(version))
 	return ssminstaller.New(packageArn,
 		version,
+		repo.GetInstalledVersion(tracer, packageArn),
 		repo.getPackageVersionPath(tracer, packageArn, version),
 		configuration,
 		&envdetect.CollectorImp{})
 }
 
+// GetPackageManifest returns the manifest of a package version, or an empty manifest if the package has none
+func (repo *localRepository) GetPackageManifest(tracer trace.Tracer, packageArn string, version string) (*PackageManifest, error) {
+	return repo.openPackageManifest(tracer, repo.filesysdep, packageArn, version)
+}
+
+// GetPackageDirectory returns the directory containing the files of a package version
+func (repo *localRepository) GetPackageDirectory(tracer trace.Tracer, packageArn string, version string) string {
+	return repo.getPackageVersionPath(tracer, packageArn, version)
+}
+
 // GetInstalledVersion returns the version of the last successfully installed package
 func (repo *localRepository) GetInstalledVersion(tracer trace.Tracer, packageArn string) string {
 	packageState := repo.loadInstallState(repo.filesysdep, tracer, packageArn)
@@ -254,13 +309,25 @@ func (repo *localRepository) RefreshPackage(tracer trace.Tracer, packageArn stri
 	return repo.AddPackage(tracer, packageArn, version, packageServiceName, downloader)
 }
 
-// AddPackage creates an entry in the repository and downloads artifacts for a package
+// AddPackage creates an entry in the repository and downloads artifacts for a package.
+// The artifacts are downloaded to a staging directory which replaces the version directory once complete,
+// so a failed download or extraction never leaves a partial version in the repository.
 func (repo *localRepository) AddPackage(tracer trace.Tracer, packageArn string, version string, packageServiceName string, downloader DownloadDelegate) error {
 	packagePath := repo.getPackageVersionPath(tracer, packageArn, version)
-	if err := repo.filesysdep.MakeDirExecute(packagePath); err != nil {
+	stagingPath := repo.getStagingPath(packageArn, version)
+	// an interrupted attempt may have left a partial staging directory
+	if err := repo.filesysdep.RemoveAll(stagingPath); err != nil {
 		return err
 	}
-	if err := downloader(tracer, packagePath); err != nil {
+	if err := repo.filesysdep.MakeDirExecute(stagingPath); err != nil {
+		return err
+	}
+	if err := downloader(tracer, stagingPath); err != nil {
+		repo.filesysdep.RemoveAll(stagingPath)
+		return err
+	}
+	if err := repo.promote(stagingPath, packagePath); err != nil {
+		repo.filesysdep.RemoveAll(stagingPath)
 		return err
 	}
 	// if no previous version, set state to new
@@ -269,6 +336,34 @@ func (repo *localRepository) AddPackage(tracer trace.Tracer, packageArn string,
 	return nil
 }
 
+// promote moves the staging directory to the version directory. An existing version directory is moved aside
+// first, and restored if the staging directory cannot be moved in its place.
+func (repo *localRepository) promote(stagingPath string, packagePath string) error {
+	if err := repo.filesysdep.MakeDirExecute(filepath.Dir(packagePath)); err != nil {
+		return err
+	}
+	previousPath := ""
+	if repo.filesysdep.Exists(packagePath) {
+		previousPath = stagingPath + previousSuffix
+		if err := repo.filesysdep.RemoveAll(previousPath); err != nil {
+			return err
+		}
+		if err := repo.filesysdep.Rename(packagePath, previousPath); err != nil {
+			return fmt.Errorf("failed to move aside %v: %v", packagePath, err)
+		}
+	}
+	if err := repo.filesysdep.Rename(stagingPath, packagePath); err != nil {
+		if previousPath != "" {
+			repo.filesysdep.Rename(previousPath, packagePath)
+		}
+		return fmt.Errorf("failed to move %v to %v: %v", stagingPath, packagePath, err)
+	}
+	if previousPath != "" {
+		repo.filesysdep.RemoveAll(previousPath)
+	}
+	return nil
+}
+
 // SetInstallState flags the state of a version of a package downloaded to the repository for installation
 func (repo *localRepository) SetInstallState(tracer trace.Tracer, packageArn string, version string, state InstallState) error {
 	var packageState = repo.loadInstallState(repo.filesysdep, tracer, packageArn)
@@ -298,6 +393,20 @@ func (repo *localRepository) SetInstallState(tracer trace.Tracer, packageArn str
 	return repo.filesysdep.WriteFile(repo.getInstallStatePath(packageArn), installStateContent)
 }
 
+// SetVerifiedFiles records the checksums of the files of a version of a package verified against its manifest
+func (repo *localRepository) SetVerifiedFiles(tracer trace.Tracer, packageArn string, version string, files map[string]string) error {
+	var packageState = repo.loadInstallState(repo.filesysdep, tracer, packageArn)
+	packageState.VerifiedVersion = version
+	packageState.VerifiedFiles = files
+
+	var installStateContent string
+	var err error
+	if installStateContent, err = jsonutil.Marshal(packageState); err != nil {
+		return err
+	}
+	return repo.filesysdep.WriteFile(repo.getInstallStatePath(packageArn), installStateContent)
+}
+
 // GetInstallState returns the current state of a package
 func (repo *localRepository) GetInstallState(tracer trace.Tracer, packageArn string) (state InstallState, version string) {
 	installState := repo.loadInstallState(repo.filesysdep, tracer, packageArn)
@@ -309,6 +418,71 @@ func (repo *localRepository) RemovePackage(tracer trace.Tracer, packageArn strin
 	return repo.filesysdep.RemoveAll(repo.getPackageVersionPath(tracer, packageArn, version))
 }
 
+// SnapshotPackage copies a version of a package and the install state of the package aside, so that they can be
+// restored if an upgrade fails. A package has at most one snapshot, taking one replaces the previous snapshot.
+func (repo *localRepository) SnapshotPackage(tracer trace.Tracer, packageArn string, version string) error {
+	snapshotPath := repo.getSnapshotPath(packageArn)
+	if err := repo.filesysdep.RemoveAll(snapshotPath); err != nil {
+		return err
+	}
+	if err := repo.filesysdep.MakeDirExecute(snapshotPath); err != nil {
+		return err
+	}
+	versionPath := repo.getPackageVersionPath(tracer, packageArn, version)
+	if err := repo.filesysdep.CopyDir(versionPath, filepath.Join(snapshotPath, normalizeDirectory(version))); err != nil {
+		repo.filesysdep.RemoveAll(snapshotPath)
+		return fmt.Errorf("failed to copy %v: %v", versionPath, err)
+	}
+	installStatePath := repo.getInstallStatePath(packageArn)
+	if repo.filesysdep.Exists(installStatePath) {
+		content, err := repo.filesysdep.ReadFile(installStatePath)
+		if err == nil {
+			err = repo.filesysdep.WriteFile(filepath.Join(snapshotPath, "installstate"), string(content))
+		}
+		if err != nil {
+			repo.filesysdep.RemoveAll(snapshotPath)
+			return fmt.Errorf("failed to copy %v: %v", installStatePath, err)
+		}
+	}
+	return nil
+}
+
+// RestorePackage replaces a version of a package and the install state of the package with their snapshot
+func (repo *localRepository) RestorePackage(tracer trace.Tracer, packageArn string, version string) error {
+	snapshotPath := repo.getSnapshotPath(packageArn)
+	snapshotVersionPath := filepath.Join(snapshotPath, normalizeDirectory(version))
+	if !repo.filesysdep.Exists(snapshotVersionPath) {
+		return fmt.Errorf("no snapshot of version %v of package %v", version, packageArn)
+	}
+	// the copy is staged first so the version directory is replaced in one move
+	stagingPath := repo.getStagingPath(packageArn, version)
+	if err := repo.filesysdep.RemoveAll(stagingPath); err != nil {
+		return err
+	}
+	if err := repo.filesysdep.CopyDir(snapshotVersionPath, stagingPath); err != nil {
+		repo.filesysdep.RemoveAll(stagingPath)
+		return fmt.Errorf("failed to copy %v: %v", snapshotVersionPath, err)
+	}
+	if err := repo.promote(stagingPath, repo.getPackageVersionPath(tracer, packageArn, version)); err != nil {
+		repo.filesysdep.RemoveAll(stagingPath)
+		return err
+	}
+	snapshotInstallStatePath := filepath.Join(snapshotPath, "installstate")
+	if repo.filesysdep.Exists(snapshotInstallStatePath) {
+		content, err := repo.filesysdep.ReadFile(snapshotInstallStatePath)
+		if err != nil {
+			return err
+		}
+		return repo.filesysdep.WriteFile(repo.getInstallStatePath(packageArn), string(content))
+	}
+	return nil
+}
+
+// DeleteSnapshot removes the snapshot of a package
+func (repo *localRepository) DeleteSnapshot(tracer trace.Tracer, packageArn string) error {
+	return repo.filesysdep.RemoveAll(repo.getSnapshotPath(packageArn))
+}
+
 // GetInventoryData returns ApplicationData for every successfully and currently installed package in the repository
 // that has inventory fields in its manifest
 func (repo *localRepository) GetInventoryData(log log.T) []model.ApplicationData {
@@ -341,11 +515,37 @@ func (repo *localRepository) GetInventoryData(log log.T) []model.ApplicationData
 	return result
 }
 
+// GetPackageInventoryData returns SSMPackageData describing the install state of every package in the repository
+func (repo *localRepository) GetPackageInventoryData(log log.T) []model.SSMPackageData {
+	result := make([]model.SSMPackageData, 0)
+
+	var dirs []string
+	var err error
+
+	tracer := trace.NewTracer(log) // temporarily wrap log into tracer to pass forward to other method calls
+	defer tracer.BeginSection("GetPackageInventoryData").EndWithError(&err)
+
+	if dirs, err = repo.filesysdep.GetDirectoryNames(repo.repoRoot); err != nil {
+		return result
+	}
+
+	for _, packageDirectoryName := range dirs {
+		var packageState *PackageInstallState
+		// packages without a readable installstate file have no name to report
+		if packageState = repo.loadInstallStateByDirectoryName(repo.filesysdep, tracer, packageDirectoryName); packageState == nil || packageState.Name == "" || packageState.State == None {
+			continue
+		}
+		result = append(result, createSSMPackageData(packageState))
+	}
+
+	return result
+}
+
 // manifest cache
 
 // filePath will return the manifest file path for a package name and package version
 func (r *localRepository) filePath(packageArn string, packageVersion string) string {
-	return filepath.Join(r.manifestCachePath, fmt.Sprintf("%s_%s.json", normalizeDirectory(packageArn), normalizeDirectory(packageVersion)))
+	return filepath.Join(r.manifestCachePath, manifestCacheID(packageArn, packageVersion)+manifestFileExtension)
 }
 
 // ReadManifest will return the manifest data for a given package name and package version from the cache
@@ -382,7 +582,7 @@ func createApplicationData(manifest *PackageManifest, packageState *PackageInsta
 		Name:            appName,
 		Publisher:       manifest.AppPublisher,
 		Version:         manifest.Version,
-		InstalledTime:   packageState.Time.Format(time.RFC3339),
+		InstalledTime:   packageState.Time.UTC().Format(time.RFC3339),
 		ApplicationType: manifest.AppType,
 		Architecture:    model.FormatArchitecture(manifest.Architecture),
 		URL:             manifest.AppReferenceURL,
@@ -390,6 +590,38 @@ func createApplicationData(manifest *PackageManifest, packageState *PackageInsta
 	}
 }
 
+// createSSMPackageData creates an SSMPackageData item from the install state of a package
+func createSSMPackageData(packageState *PackageInstallState) model.SSMPackageData {
+	data := model.SSMPackageData{
+		PackageArn:          packageState.Name,
+		Version:             packageState.Version,
+		InstallState:        packageState.State.String(),
+		InstalledVersion:    packageState.LastInstalledVersion,
+		LastOperationResult: operationResult(packageState.State),
+		LastOperationTime:   packageState.Time.UTC().Format(time.RFC3339),
+	}
+	if packageState.State == Installed {
+		data.InstalledTime = data.LastOperationTime
+	}
+	return data
+}
+
+// operationResult summarizes the outcome of the last operation that left a package in the given state
+func operationResult(state InstallState) string {
+	switch state {
+	case Installed, Uninstalled:
+		return "Success"
+	case Failed:
+		return "Failed"
+	case Uninstalling, Upgrading, Installing, RollbackUninstall, RollbackInstall:
+		return "InProgress"
+	case New:
+		return "Pending"
+	default:
+		return "Unknown"
+	}
+}
+
 // getPackageRoot is a helper function that given a package's directory name returns the path to the folder containing all versions of a package
 func (repo *localRepository) getPackageRootByDirectoryName(directoryName string) string {
 	return filepath.Join(repo.repoRoot, directoryName)
@@ -402,7 +634,12 @@ func (repo *localRepository) getPackageRoot(packageArn string) string {
 
 // getLockPath is a helper function that builds the path to the install state file
 func (repo *localRepository) getLockPath(packageArn string) string {
-	return filepath.Join(repo.lockRoot, normalizeDirectory(packageArn)+".lockfile")
+	return repo.getLockPathByDirectoryName(normalizeDirectory(packageArn))
+}
+
+// getLockPathByDirectoryName is a helper function that given a package's directory name builds the path to the lock file
+func (repo *localRepository) getLockPathByDirectoryName(directoryName string) string {
+	return filepath.Join(repo.lockRoot, directoryName+".lockfile")
 }
 
 // getInstallStatePath is a helper function that given a packagearn builds the path to the install state file
@@ -420,6 +657,16 @@ func (repo *localRepository) getPackageVersionPath(tracer trace.Tracer, packageA
 	return filepath.Join(repo.getPackageRoot(packageArn), normalizeDirectory(version))
 }
 
+// getStagingPath is a helper function that builds the path a version of a package is downloaded to before it is added to the repository
+func (repo *localRepository) getStagingPath(packageArn string, version string) string {
+	return filepath.Join(repo.stagingRoot, normalizeDirectory(packageArn), normalizeDirectory(version))
+}
+
+// getSnapshotPath is a helper function that builds the path to the snapshot of a package
+func (repo *localRepository) getSnapshotPath(packageArn string) string {
+	return filepath.Join(repo.snapshotRoot, normalizeDirectory(packageArn))
+}
+
 // getManifestPath is a helper function that builds the path to the manifest file for a given version of a package
 func (repo *localRepository) getManifestPath(tracer trace.Tracer, packageArn string, version string, manifestName string) string {
 	return filepath.Join(repo.getPackageVersionPath(tracer, packageArn, version), fmt.Sprintf("%v.json", manifestName))
@@ -16,8 +16,11 @@
 package localpackages
 
 import (
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 )
@@ -29,8 +32,12 @@ type FileSysDep interface {
 	GetFileNames(srcPath string) (files []string, err error)
 	Exists(filePath string) bool
 	RemoveAll(path string) error
+	Rename(oldPath string, newPath string) error
 	ReadFile(filename string) ([]byte, error)
 	WriteFile(filename string, content string) error
+	CopyDir(srcPath string, destPath string) error
+	ModTime(path string) (time.Time, error)
+	Size(path string) (int64, error)
 }
 
 type fileSysDepImp struct{}
@@ -55,6 +62,10 @@ func (fileSysDepImp) RemoveAll(path string) error {
 	return os.RemoveAll(path)
 }
 
+func (fileSysDepImp) Rename(oldPath string, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
+
 func (fileSysDepImp) ReadFile(filename string) ([]byte, error) {
 	return ioutil.ReadFile(filename)
 }
@@ -62,3 +73,60 @@ func (fileSysDepImp) ReadFile(filename string) ([]byte, error) {
 func (fileSysDepImp) WriteFile(filename string, content string) error {
 	return fileutil.WriteAllText(filename, content)
 }
+
+func (fileSysDepImp) CopyDir(srcPath string, destPath string) error {
+	return filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(srcPath, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(destPath, relativePath)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func (fileSysDepImp) ModTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// Size returns the size of a file or the total size of the files in a directory
+func (fileSysDepImp) Size(path string) (size int64, err error) {
+	err = filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// copyFile copies a file and its permissions
+func copyFile(srcPath string, destPath string, mode os.FileMode) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
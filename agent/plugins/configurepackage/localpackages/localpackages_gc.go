@@ -0,0 +1,169 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localpackages
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+// garbage is a version of a package or a cached download that can be removed from the repository
+type garbage struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// CollectGarbage removes the interrupted downloads, and the versions of the packages and the cached downloads
+// the retention policy doesn't keep. Packages locked by another operation are skipped, lockedPackageArn is a
+// package the caller holds the lock of. It returns the number of bytes reclaimed.
+func (repo *localRepository) CollectGarbage(tracer trace.Tracer, retention appconfig.PackageRetentionCfg, lockedPackageArn string) (reclaimed int64) {
+	trace := tracer.BeginSection("collect package repository garbage")
+	defer func() {
+		trace.AppendInfof("Reclaimed %v bytes", reclaimed).End()
+	}()
+
+	// the packages stay locked until the size limit is applied
+	locked := make(map[string]bool)
+	defer func() {
+		for directoryName := range locked {
+			unlockPackage(repo.fileLocker, repo.getLockPathByDirectoryName(directoryName), directoryName)
+		}
+	}()
+	lockedDirectoryName := ""
+	if lockedPackageArn != "" {
+		lockedDirectoryName = normalizeDirectory(lockedPackageArn)
+	}
+	tryLock := func(directoryName string) bool {
+		if directoryName == lockedDirectoryName || locked[directoryName] {
+			return true
+		}
+		if err := lockPackage(repo.fileLocker, repo.getLockPathByDirectoryName(directoryName), directoryName, "CollectGarbage"); err != nil {
+			trace.AppendDebugf("Skipped %v: %v", directoryName, err)
+			return false
+		}
+		locked[directoryName] = true
+		return true
+	}
+
+	// a staging directory is only left behind by a download that failed or was interrupted
+	stagingDirectories, _ := repo.filesysdep.GetDirectoryNames(repo.stagingRoot)
+	for _, directoryName := range stagingDirectories {
+		if tryLock(directoryName) {
+			reclaimed += repo.removeGarbage(trace, filepath.Join(repo.stagingRoot, directoryName), "interrupted download")
+		}
+	}
+
+	now := time.Now()
+	maxAge := time.Duration(retention.MaxAgeDays) * 24 * time.Hour
+	var candidates []garbage
+	var total int64
+
+	packageDirectories, _ := repo.filesysdep.GetDirectoryNames(repo.repoRoot)
+	for _, directoryName := range packageDirectories {
+		packageRoot := repo.getPackageRootByDirectoryName(directoryName)
+		if !tryLock(directoryName) {
+			if size, err := repo.filesysdep.Size(packageRoot); err == nil {
+				total += size
+			}
+			continue
+		}
+		versions := repo.listGarbage(packageRoot)
+		// the most recently downloaded versions first
+		sort.Slice(versions, func(i, j int) bool { return versions[i].modTime.After(versions[j].modTime) })
+
+		state := repo.loadInstallStateByDirectoryName(repo.filesysdep, tracer, directoryName)
+		kept := map[string]bool{}
+		for _, version := range []string{state.Version, state.LastInstalledVersion} {
+			if version != "" {
+				kept[filepath.Join(packageRoot, normalizeDirectory(version))] = true
+			}
+		}
+
+		for i, version := range versions {
+			switch {
+			case kept[version.path]:
+				total += version.size
+			case i >= retention.KeepVersions:
+				reclaimed += repo.removeGarbage(trace, version.path, "older than the versions kept")
+			case maxAge > 0 && now.Sub(version.modTime) > maxAge:
+				reclaimed += repo.removeGarbage(trace, version.path, "not downloaded again recently")
+			default:
+				total += version.size
+				candidates = append(candidates, version)
+			}
+		}
+	}
+
+	if repo.artifactCachePath != "" {
+		for _, artifact := range repo.listGarbage(repo.artifactCachePath) {
+			if maxAge > 0 && now.Sub(artifact.modTime) > maxAge {
+				reclaimed += repo.removeGarbage(trace, artifact.path, "cached download not used recently")
+				continue
+			}
+			total += artifact.size
+			candidates = append(candidates, artifact)
+		}
+	}
+
+	if retention.MaxTotalMB > 0 {
+		// the least recently downloaded versions and cached downloads are removed first
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].modTime.Before(candidates[j].modTime) })
+		limit := int64(retention.MaxTotalMB) * 1024 * 1024
+		for _, candidate := range candidates {
+			if total <= limit {
+				break
+			}
+			if removed := repo.removeGarbage(trace, candidate.path, "repository above its size limit"); removed > 0 {
+				reclaimed += removed
+				total -= removed
+			}
+		}
+	}
+	return
+}
+
+// listGarbage returns the size and modification time of the directories in a directory
+func (repo *localRepository) listGarbage(directory string) []garbage {
+	names, _ := repo.filesysdep.GetDirectoryNames(directory)
+	result := make([]garbage, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(directory, name)
+		modTime, err := repo.filesysdep.ModTime(path)
+		if err != nil {
+			continue
+		}
+		size, err := repo.filesysdep.Size(path)
+		if err != nil {
+			continue
+		}
+		result = append(result, garbage{path: path, size: size, modTime: modTime})
+	}
+	return result
+}
+
+// removeGarbage removes a directory and returns its size
+func (repo *localRepository) removeGarbage(trace *trace.Trace, path string, reason string) int64 {
+	size, _ := repo.filesysdep.Size(path)
+	if err := repo.filesysdep.RemoveAll(path); err != nil {
+		trace.AppendErrorf("Failed to remove %v: %v", path, err)
+		return 0
+	}
+	trace.AppendInfof("Removed %v (%v bytes), %v", path, size, reason)
+	return size
+}
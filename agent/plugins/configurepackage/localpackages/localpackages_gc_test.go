@@ -0,0 +1,140 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localpackages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filelock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// gcTestRepository creates a repository in a temporary directory
+func gcTestRepository(t *testing.T, locker filelock.FileLocker) (localRepository, func()) {
+	root, err := ioutil.TempDir("", "localpackagesgc")
+	assert.NoError(t, err)
+	repo := localRepository{
+		filesysdep:        &fileSysDepImp{},
+		repoRoot:          filepath.Join(root, "packages"),
+		lockRoot:          filepath.Join(root, "locks"),
+		stagingRoot:       filepath.Join(root, "staging"),
+		artifactCachePath: filepath.Join(root, "artifacts"),
+		fileLocker:        locker,
+	}
+	return repo, func() { os.RemoveAll(root) }
+}
+
+// createGarbage creates a directory with a file of the given size, last modified age ago
+func createGarbage(t *testing.T, path string, size int, age time.Duration) {
+	assert.NoError(t, os.MkdirAll(path, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "artifact"), make([]byte, size), 0600))
+	modTime := time.Now().Add(-age)
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func assertExists(t *testing.T, path string, exists bool) {
+	_, err := os.Stat(path)
+	assert.Equal(t, exists, err == nil, path)
+}
+
+func TestCollectGarbageKeepsVersions(t *testing.T) {
+	repo, cleanup := gcTestRepository(t, &filelock.FileLockerNoop{})
+	defer cleanup()
+	packageRoot := filepath.Join(repo.repoRoot, testPackage)
+	for i, version := range []string{"0.0.4", "0.0.3", "0.0.2", "0.0.1"} {
+		createGarbage(t, filepath.Join(packageRoot, version), 10, time.Duration(i)*time.Hour)
+	}
+	// the package was rolled back to 0.0.2
+	assert.NoError(t, repo.SetInstallState(tracerMock, testPackage, "0.0.2", Installed))
+	createGarbage(t, filepath.Join(repo.stagingRoot, testPackage, "0.0.5"), 7, 0)
+
+	reclaimed := repo.CollectGarbage(tracerMock, appconfig.PackageRetentionCfg{KeepVersions: 2}, "")
+
+	assert.Equal(t, int64(17), reclaimed)
+	assertExists(t, filepath.Join(packageRoot, "0.0.4"), true)
+	assertExists(t, filepath.Join(packageRoot, "0.0.3"), true)
+	assertExists(t, filepath.Join(packageRoot, "0.0.2"), true)
+	assertExists(t, filepath.Join(packageRoot, "0.0.1"), false)
+	assertExists(t, filepath.Join(packageRoot, "installstate"), true)
+	assertExists(t, filepath.Join(repo.stagingRoot, testPackage), false)
+}
+
+func TestCollectGarbageMaxAge(t *testing.T) {
+	repo, cleanup := gcTestRepository(t, &filelock.FileLockerNoop{})
+	defer cleanup()
+	packageRoot := filepath.Join(repo.repoRoot, testPackage)
+	createGarbage(t, filepath.Join(packageRoot, "0.0.2"), 10, 40*24*time.Hour)
+	createGarbage(t, filepath.Join(packageRoot, "0.0.1"), 10, 50*24*time.Hour)
+	assert.NoError(t, repo.SetInstallState(tracerMock, testPackage, "0.0.1", Installed))
+	createGarbage(t, filepath.Join(repo.artifactCachePath, "recent"), 5, time.Hour)
+	createGarbage(t, filepath.Join(repo.artifactCachePath, "old"), 5, 31*24*time.Hour)
+
+	reclaimed := repo.CollectGarbage(tracerMock, appconfig.PackageRetentionCfg{KeepVersions: 2, MaxAgeDays: 30}, "")
+
+	assert.Equal(t, int64(15), reclaimed)
+	assertExists(t, filepath.Join(packageRoot, "0.0.2"), false)
+	assertExists(t, filepath.Join(packageRoot, "0.0.1"), true)
+	assertExists(t, filepath.Join(repo.artifactCachePath, "recent"), true)
+	assertExists(t, filepath.Join(repo.artifactCachePath, "old"), false)
+}
+
+func TestCollectGarbageMaxTotalSize(t *testing.T) {
+	repo, cleanup := gcTestRepository(t, &filelock.FileLockerNoop{})
+	defer cleanup()
+	const mb = 1024 * 1024
+	packageRoot := filepath.Join(repo.repoRoot, testPackage)
+	createGarbage(t, filepath.Join(packageRoot, "0.0.2"), mb/2, time.Hour)
+	createGarbage(t, filepath.Join(packageRoot, "0.0.1"), mb/2, 3*time.Hour)
+	assert.NoError(t, repo.SetInstallState(tracerMock, testPackage, "0.0.1", Installed))
+	createGarbage(t, filepath.Join(repo.artifactCachePath, "newer"), mb/2, 2*time.Hour)
+	createGarbage(t, filepath.Join(repo.artifactCachePath, "newest"), mb/4, 0)
+
+	reclaimed := repo.CollectGarbage(tracerMock, appconfig.PackageRetentionCfg{KeepVersions: 2, MaxTotalMB: 1}, "")
+
+	// the installed version is kept, then the least recently downloaded are removed first
+	assert.Equal(t, int64(mb), reclaimed)
+	assertExists(t, filepath.Join(packageRoot, "0.0.1"), true)
+	assertExists(t, filepath.Join(repo.artifactCachePath, "newer"), false)
+	assertExists(t, filepath.Join(packageRoot, "0.0.2"), false)
+	assertExists(t, filepath.Join(repo.artifactCachePath, "newest"), true)
+}
+
+func TestCollectGarbageSkipsLockedPackages(t *testing.T) {
+	locker := &filelock.FileLockerMock{}
+	repo, cleanup := gcTestRepository(t, locker)
+	defer cleanup()
+	const otherPackage = "OtherPackage"
+	locker.On("Lock", filepath.Join(repo.lockRoot, otherPackage+".lockfile"), mock.Anything, mock.Anything).Return(false, nil)
+	for _, name := range []string{testPackage, otherPackage} {
+		createGarbage(t, filepath.Join(repo.repoRoot, name, "0.0.2"), 10, time.Hour)
+		createGarbage(t, filepath.Join(repo.repoRoot, name, "0.0.1"), 10, 2*time.Hour)
+		createGarbage(t, filepath.Join(repo.stagingRoot, name, "0.0.3"), 10, 0)
+	}
+
+	// the lock of testPackage is held by the caller
+	reclaimed := repo.CollectGarbage(tracerMock, appconfig.PackageRetentionCfg{KeepVersions: 1}, testPackage)
+
+	assert.Equal(t, int64(20), reclaimed)
+	assertExists(t, filepath.Join(repo.repoRoot, testPackage, "0.0.1"), false)
+	assertExists(t, filepath.Join(repo.stagingRoot, testPackage), false)
+	assertExists(t, filepath.Join(repo.repoRoot, otherPackage, "0.0.1"), true)
+	assertExists(t, filepath.Join(repo.stagingRoot, otherPackage), true)
+	locker.AssertExpectations(t)
+}
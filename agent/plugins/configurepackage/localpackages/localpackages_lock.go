@@ -18,7 +18,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filelock"
 )
 
@@ -26,6 +28,18 @@ const (
 	lockTimeoutInSeconds = 30 * 60 // 30 minutes
 )
 
+// lockRetryInterval is the delay between two attempts to lock a package held by another operation
+var lockRetryInterval = time.Second
+
+// packageLockedError is returned when the package is locked by another operation
+type packageLockedError struct {
+	message string
+}
+
+func (e *packageLockedError) Error() string {
+	return e.message
+}
+
 // Prevent multiple actions for the same package at the same time
 var lockPackageAction = &sync.Mutex{}
 var mapPackageAction = make(map[string]string)
@@ -36,7 +50,7 @@ func lockPackage(filelocker filelock.FileLocker, lockPath string, packageArn str
 	defer lockPackageAction.Unlock()
 
 	if val, ok := mapPackageAction[packageArn]; ok {
-		return errors.New(fmt.Sprintf(`Package "%v" is already in the process of action "%v"`, packageArn, val))
+		return &packageLockedError{fmt.Sprintf(`Package "%v" is already in the process of action "%v"`, packageArn, val)}
 	}
 
 	ownerId := filelock.GetOwnerIdForProcess()
@@ -46,13 +60,36 @@ func lockPackage(filelocker filelock.FileLocker, lockPath string, packageArn str
 	}
 
 	if !locked {
-		return errors.New(fmt.Sprintf(`Package "%v" is already in the process of other action`, packageArn))
+		return &packageLockedError{fmt.Sprintf(`Package "%v" is already in the process of other action`, packageArn)}
 	}
 
 	mapPackageAction[packageArn] = action
 	return nil
 }
 
+// waitLockPackage locks the package, waiting up to wait for the operation holding it, in this process
+// or in another one, to release it
+func waitLockPackage(filelocker filelock.FileLocker, lockPath string, packageArn string, action string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		err := lockPackage(filelocker, lockPath, packageArn, action)
+		if _, locked := err.(*packageLockedError); !locked {
+			return err
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			if wait <= 0 {
+				return err
+			}
+			return contracts.NewCodedError(contracts.ErrorCodeTimeout, fmt.Errorf("%v, gave up waiting for it after %v", err, wait))
+		}
+		if remaining > lockRetryInterval {
+			remaining = lockRetryInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
 // unlockPackage removes the package name from the list of packages currently being acted on in a threadsafe way
 func unlockPackage(filelocker filelock.FileLocker, lockPath string, packageArn string) error {
 	lockPackageAction.Lock()
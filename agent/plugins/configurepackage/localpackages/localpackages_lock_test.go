@@ -16,8 +16,11 @@ package localpackages
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/filelock"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,6 +65,49 @@ func TestPackageLock(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestWaitLockPackage(t *testing.T) {
+	lockRetryInterval = 10 * time.Millisecond
+	defer func() { lockRetryInterval = time.Second }()
+	lockPath := filepath.Join(os.TempDir(), "lockpath-Wait")
+	defer os.Remove(lockPath)
+
+	err := lockPackage(fileLocker, lockPath, "Wait", "Install")
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		unlockPackage(fileLocker, lockPath, "Wait")
+	}()
+
+	// the second operation gets the lock once the first one releases it
+	err = waitLockPackage(fileLocker, lockPath, "Wait", "Uninstall", 5*time.Second)
+	assert.NoError(t, err)
+	unlockPackage(fileLocker, lockPath, "Wait")
+}
+
+func TestWaitLockPackageTimeout(t *testing.T) {
+	lockRetryInterval = 10 * time.Millisecond
+	defer func() { lockRetryInterval = time.Second }()
+	lockPath := filepath.Join(os.TempDir(), "lockpath-Timeout")
+	defer os.Remove(lockPath)
+
+	err := lockPackage(fileLocker, lockPath, "Timeout", "Install")
+	assert.NoError(t, err)
+	defer unlockPackage(fileLocker, lockPath, "Timeout")
+
+	start := time.Now()
+	err = waitLockPackage(fileLocker, lockPath, "Timeout", "Uninstall", 50*time.Millisecond)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Contains(t, err.Error(), `Package "Timeout" is already in the process of action "Install"`)
+	assert.Contains(t, err.Error(), "gave up waiting for it after 50ms")
+	assert.Equal(t, contracts.ErrorCodeTimeout, contracts.ErrorCodeOf(err))
+
+	// without a wait the lock fails right away
+	err = waitLockPackage(fileLocker, lockPath, "Timeout", "Uninstall", 0)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "gave up")
+}
+
 func lockAndUnlockGo(lockpath string, packageName string, channel chan error) {
 	err := lockPackage(fileLocker, lockpath, packageName, "Install")
 	channel <- err
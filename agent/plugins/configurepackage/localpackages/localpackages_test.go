@@ -18,7 +18,10 @@ package localpackages
 import (
 	"errors"
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -37,6 +40,8 @@ import (
 
 const testRepoRoot = "testdata"
 const testLockRoot = "testlock"
+const testStagingRoot = "teststaging"
+const testSnapshotRoot = "testsnapshot"
 const testPackage = "SsmTest"
 
 var tracerMock = trace.NewTracer(log.NewMockLog())
@@ -349,15 +354,19 @@ func TestAddPackage(t *testing.T) {
 	version := "0.0.1"
 	// Setup mock with expectations
 	mockFileSys := MockedFileSys{}
-	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("RemoveAll", path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage)).Return(nil).Once()
+	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, version)).Return(false).Once()
+	mockFileSys.On("Rename", path.Join(testStagingRoot, testPackage, version), path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
 	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, "installstate")).Return(true).Once()
 	mockFileSys.On("ReadFile", path.Join(testRepoRoot, testPackage, "installstate")).Return(loadFile(t, path.Join(testRepoRoot, testPackage, "installstate_success")), nil).Once()
 
 	mockDownload := MockedDownloader{}
-	mockDownload.On("Download", tracerMock, path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
+	mockDownload.On("Download", tracerMock, path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
 
 	// Instantiate repository with mock
-	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, fileLocker: &filelock.FileLockerNoop{}}
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, fileLocker: &filelock.FileLockerNoop{}}
 
 	// Call and validate mock expectations and return value
 	err := repo.AddPackage(tracerMock, testPackage, version, "mock-package-service", mockDownload.Download)
@@ -370,16 +379,20 @@ func TestAddNewPackage(t *testing.T) {
 	version := "0.0.1"
 	// Setup mock with expectations
 	mockFileSys := MockedFileSys{}
-	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("RemoveAll", path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage)).Return(nil).Once()
+	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, version)).Return(false).Once()
+	mockFileSys.On("Rename", path.Join(testStagingRoot, testPackage, version), path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
 	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, "installstate")).Return(false).Once()
 	mockFileSys.On("GetDirectoryNames", path.Join(testRepoRoot, testPackage)).Return(make([]string, 0), nil).Once()
 	mockFileSys.On("WriteFile", path.Join(testRepoRoot, testPackage, "installstate"), mock.Anything).Return(nil).Once()
 
 	mockDownload := MockedDownloader{}
-	mockDownload.On("Download", tracerMock, path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
+	mockDownload.On("Download", tracerMock, path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
 
 	// Instantiate repository with mock
-	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, fileLocker: &filelock.FileLockerNoop{}}
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, fileLocker: &filelock.FileLockerNoop{}}
 
 	// Call and validate mock expectations and return value
 	err := repo.AddPackage(tracerMock, testPackage, version, "mock-package-service", mockDownload.Download)
@@ -392,15 +405,19 @@ func TestRefreshPackage(t *testing.T) {
 	version := "0.0.1"
 	// Setup mock with expectations
 	mockFileSys := MockedFileSys{}
-	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("RemoveAll", path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage)).Return(nil).Once()
+	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, version)).Return(false).Once()
+	mockFileSys.On("Rename", path.Join(testStagingRoot, testPackage, version), path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
 	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, "installstate")).Return(true).Once()
 	mockFileSys.On("ReadFile", path.Join(testRepoRoot, testPackage, "installstate")).Return(loadFile(t, path.Join(testRepoRoot, testPackage, "installstate_success")), nil).Once()
 
 	mockDownload := MockedDownloader{}
-	mockDownload.On("Download", tracerMock, path.Join(testRepoRoot, testPackage, version)).Return(nil).Once()
+	mockDownload.On("Download", tracerMock, path.Join(testStagingRoot, testPackage, version)).Return(nil).Once()
 
 	// Instantiate repository with mock
-	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, fileLocker: &filelock.FileLockerNoop{}}
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, fileLocker: &filelock.FileLockerNoop{}}
 
 	// Call and validate mock expectations and return value
 	err := repo.RefreshPackage(tracerMock, testPackage, version, "mock-package-service", mockDownload.Download)
@@ -409,6 +426,84 @@ func TestRefreshPackage(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAddPackageReplacesVersion(t *testing.T) {
+	version := "0.0.1"
+	stagingPath := path.Join(testStagingRoot, testPackage, version)
+	packagePath := path.Join(testRepoRoot, testPackage, version)
+	// Setup mock with expectations
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("RemoveAll", stagingPath).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", stagingPath).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage)).Return(nil).Once()
+	mockFileSys.On("Exists", packagePath).Return(true).Once()
+	mockFileSys.On("RemoveAll", stagingPath+previousSuffix).Return(nil).Twice()
+	mockFileSys.On("Rename", packagePath, stagingPath+previousSuffix).Return(nil).Once()
+	mockFileSys.On("Rename", stagingPath, packagePath).Return(nil).Once()
+	mockFileSys.On("Exists", path.Join(testRepoRoot, testPackage, "installstate")).Return(true).Once()
+	mockFileSys.On("ReadFile", path.Join(testRepoRoot, testPackage, "installstate")).Return(loadFile(t, path.Join(testRepoRoot, testPackage, "installstate_success")), nil).Once()
+
+	mockDownload := MockedDownloader{}
+	mockDownload.On("Download", tracerMock, stagingPath).Return(nil).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, fileLocker: &filelock.FileLockerNoop{}}
+
+	// Call and validate mock expectations and return value
+	err := repo.RefreshPackage(tracerMock, testPackage, version, "mock-package-service", mockDownload.Download)
+	mockFileSys.AssertExpectations(t)
+	mockDownload.AssertExpectations(t)
+	assert.Nil(t, err)
+}
+
+func TestAddPackageDownloadFails(t *testing.T) {
+	version := "0.0.1"
+	stagingPath := path.Join(testStagingRoot, testPackage, version)
+	// Setup mock with expectations, the version directory is not touched
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("RemoveAll", stagingPath).Return(nil).Twice()
+	mockFileSys.On("MakeDirExecute", stagingPath).Return(nil).Once()
+
+	mockDownload := MockedDownloader{}
+	mockDownload.On("Download", tracerMock, stagingPath).Return(errors.New("failed to extract")).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, fileLocker: &filelock.FileLockerNoop{}}
+
+	// Call and validate mock expectations and return value
+	err := repo.AddPackage(tracerMock, testPackage, version, "mock-package-service", mockDownload.Download)
+	mockFileSys.AssertExpectations(t)
+	mockDownload.AssertExpectations(t)
+	assert.Error(t, err)
+}
+
+func TestAddPackagePromoteFails(t *testing.T) {
+	version := "0.0.1"
+	stagingPath := path.Join(testStagingRoot, testPackage, version)
+	packagePath := path.Join(testRepoRoot, testPackage, version)
+	// Setup mock with expectations, the previous version directory is restored
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("RemoveAll", stagingPath).Return(nil).Twice()
+	mockFileSys.On("MakeDirExecute", stagingPath).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage)).Return(nil).Once()
+	mockFileSys.On("Exists", packagePath).Return(true).Once()
+	mockFileSys.On("RemoveAll", stagingPath+previousSuffix).Return(nil).Once()
+	mockFileSys.On("Rename", packagePath, stagingPath+previousSuffix).Return(nil).Once()
+	mockFileSys.On("Rename", stagingPath, packagePath).Return(errors.New("access denied")).Once()
+	mockFileSys.On("Rename", stagingPath+previousSuffix, packagePath).Return(nil).Once()
+
+	mockDownload := MockedDownloader{}
+	mockDownload.On("Download", tracerMock, stagingPath).Return(nil).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, fileLocker: &filelock.FileLockerNoop{}}
+
+	// Call and validate mock expectations and return value
+	err := repo.AddPackage(tracerMock, testPackage, version, "mock-package-service", mockDownload.Download)
+	mockFileSys.AssertExpectations(t)
+	mockDownload.AssertExpectations(t)
+	assert.Error(t, err)
+}
+
 func TestRemovePackage(t *testing.T) {
 	version := "0.0.1"
 	// Setup mock with expectations
@@ -424,6 +519,133 @@ func TestRemovePackage(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestSnapshotPackage(t *testing.T) {
+	version := "0.0.1"
+	snapshotPath := path.Join(testSnapshotRoot, testPackage)
+	installStatePath := path.Join(testRepoRoot, testPackage, "installstate")
+	// Setup mock with expectations
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("RemoveAll", snapshotPath).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", snapshotPath).Return(nil).Once()
+	mockFileSys.On("CopyDir", path.Join(testRepoRoot, testPackage, version), path.Join(snapshotPath, version)).Return(nil).Once()
+	mockFileSys.On("Exists", installStatePath).Return(true).Once()
+	mockFileSys.On("ReadFile", installStatePath).Return([]byte("state"), nil).Once()
+	mockFileSys.On("WriteFile", path.Join(snapshotPath, "installstate"), "state").Return(nil).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, snapshotRoot: testSnapshotRoot}
+
+	// Call and validate mock expectations and return value
+	err := repo.SnapshotPackage(tracerMock, testPackage, version)
+	mockFileSys.AssertExpectations(t)
+	assert.NoError(t, err)
+}
+
+func TestSnapshotPackageCopyFails(t *testing.T) {
+	version := "0.0.1"
+	snapshotPath := path.Join(testSnapshotRoot, testPackage)
+	// Setup mock with expectations, the partial snapshot is removed
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("RemoveAll", snapshotPath).Return(nil).Twice()
+	mockFileSys.On("MakeDirExecute", snapshotPath).Return(nil).Once()
+	mockFileSys.On("CopyDir", path.Join(testRepoRoot, testPackage, version), path.Join(snapshotPath, version)).Return(errors.New("disk full")).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, snapshotRoot: testSnapshotRoot}
+
+	// Call and validate mock expectations and return value
+	err := repo.SnapshotPackage(tracerMock, testPackage, version)
+	mockFileSys.AssertExpectations(t)
+	assert.Error(t, err)
+}
+
+func TestRestorePackage(t *testing.T) {
+	version := "0.0.1"
+	snapshotPath := path.Join(testSnapshotRoot, testPackage)
+	stagingPath := path.Join(testStagingRoot, testPackage, version)
+	packagePath := path.Join(testRepoRoot, testPackage, version)
+	// Setup mock with expectations
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(snapshotPath, version)).Return(true).Once()
+	mockFileSys.On("RemoveAll", stagingPath).Return(nil).Once()
+	mockFileSys.On("CopyDir", path.Join(snapshotPath, version), stagingPath).Return(nil).Once()
+	mockFileSys.On("MakeDirExecute", path.Join(testRepoRoot, testPackage)).Return(nil).Once()
+	mockFileSys.On("Exists", packagePath).Return(false).Once()
+	mockFileSys.On("Rename", stagingPath, packagePath).Return(nil).Once()
+	mockFileSys.On("Exists", path.Join(snapshotPath, "installstate")).Return(true).Once()
+	mockFileSys.On("ReadFile", path.Join(snapshotPath, "installstate")).Return([]byte("state"), nil).Once()
+	mockFileSys.On("WriteFile", path.Join(testRepoRoot, testPackage, "installstate"), "state").Return(nil).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, snapshotRoot: testSnapshotRoot}
+
+	// Call and validate mock expectations and return value
+	err := repo.RestorePackage(tracerMock, testPackage, version)
+	mockFileSys.AssertExpectations(t)
+	assert.NoError(t, err)
+}
+
+func TestRestorePackageNoSnapshot(t *testing.T) {
+	version := "0.0.1"
+	// Setup mock with expectations
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testSnapshotRoot, testPackage, version)).Return(false).Once()
+
+	// Instantiate repository with mock
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, stagingRoot: testStagingRoot, snapshotRoot: testSnapshotRoot}
+
+	// Call and validate mock expectations and return value
+	err := repo.RestorePackage(tracerMock, testPackage, version)
+	mockFileSys.AssertExpectations(t)
+	assert.Error(t, err)
+}
+
+func TestSnapshotRestoreRoundtrip(t *testing.T) {
+	root, err := ioutil.TempDir("", "localpackages")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	repoRoot := filepath.Join(root, "packages")
+	repo := localRepository{filesysdep: &fileSysDepImp{}, repoRoot: repoRoot, stagingRoot: filepath.Join(root, "staging"), snapshotRoot: filepath.Join(root, "snapshots")}
+	versionPath := filepath.Join(repoRoot, testPackage, "0.0.1")
+	assert.NoError(t, os.MkdirAll(filepath.Join(versionPath, "bin"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versionPath, "bin", "install.sh"), []byte("echo installed"), 0700))
+	assert.NoError(t, repo.SetInstallState(tracerMock, testPackage, "0.0.1", Installed))
+
+	assert.NoError(t, repo.SnapshotPackage(tracerMock, testPackage, "0.0.1"))
+	// the uninstall of the upgrade removes files of the installed version
+	assert.NoError(t, os.RemoveAll(filepath.Join(versionPath, "bin")))
+	assert.NoError(t, repo.SetInstallState(tracerMock, testPackage, "0.0.1", Upgrading))
+	assert.NoError(t, repo.RestorePackage(tracerMock, testPackage, "0.0.1"))
+
+	content, err := ioutil.ReadFile(filepath.Join(versionPath, "bin", "install.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo installed", string(content))
+	state, version := repo.GetInstallState(tracerMock, testPackage)
+	assert.Equal(t, Installed, state)
+	assert.Equal(t, "0.0.1", version)
+
+	assert.NoError(t, repo.DeleteSnapshot(tracerMock, testPackage))
+	_, err = os.Stat(filepath.Join(root, "snapshots", testPackage))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSetVerifiedFiles(t *testing.T) {
+	root, err := ioutil.TempDir("", "localpackages")
+	assert.NoError(t, err)
+	defer os.RemoveAll(root)
+	repo := localRepository{filesysdep: &fileSysDepImp{}, repoRoot: root}
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, testPackage), 0700))
+	assert.NoError(t, repo.SetInstallState(tracerMock, testPackage, "0.0.1", New))
+	files := map[string]string{"install.sh": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+
+	assert.NoError(t, repo.SetVerifiedFiles(tracerMock, testPackage, "0.0.1", files))
+
+	packageState := repo.loadInstallState(repo.filesysdep, tracerMock, testPackage)
+	assert.Equal(t, New, packageState.State)
+	assert.Equal(t, "0.0.1", packageState.VerifiedVersion)
+	assert.Equal(t, files, packageState.VerifiedFiles)
+}
+
 func TestSetInstallState(t *testing.T) {
 	initialState := PackageInstallState{Name: testPackage, Version: "0.0.1", State: None}
 	finalState := PackageInstallState{Name: testPackage, Version: "0.0.1", State: Installing, Time: time.Now()}
@@ -469,7 +691,7 @@ func TestGetInventoryData(t *testing.T) {
 		Architecture:  "x86_64",
 		Publisher:     "Amazon AWS",
 		CompType:      model.AWSComponent,
-		InstalledTime: installTime.Format(time.RFC3339),
+		InstalledTime: installTime.UTC().Format(time.RFC3339),
 	}
 
 	testInventory(t, []InventoryTestData{testData}, []model.ApplicationData{expectedInventory})
@@ -499,7 +721,7 @@ func TestGetInventoryDataMultiple(t *testing.T) {
 		Architecture:  "x86_64",
 		Publisher:     "Amazon AWS",
 		CompType:      model.AWSComponent,
-		InstalledTime: installTime.Format(time.RFC3339),
+		InstalledTime: installTime.UTC().Format(time.RFC3339),
 	}
 	expectedInventory2 := model.ApplicationData{
 		Name:            "Foo",
@@ -507,7 +729,7 @@ func TestGetInventoryDataMultiple(t *testing.T) {
 		Architecture:    "x86_64",
 		CompType:        model.AWSComponent,
 		ApplicationType: "Driver",
-		InstalledTime:   installTime.Format(time.RFC3339),
+		InstalledTime:   installTime.UTC().Format(time.RFC3339),
 	}
 
 	testInventory(t, []InventoryTestData{testData1, testData2}, []model.ApplicationData{expectedInventory1, expectedInventory2})
@@ -545,14 +767,14 @@ func TestGetInventoryDataComplex(t *testing.T) {
 			Version:       "0.0.1",
 			Architecture:  "i386",
 			Publisher:     "Test",
-			InstalledTime: installTime.Format(time.RFC3339),
+			InstalledTime: installTime.UTC().Format(time.RFC3339),
 		},
 		{
 			Name:          "SsmTest",
 			Version:       "0.1.2",
 			Architecture:  "i386",
 			CompType:      model.AWSComponent,
-			InstalledTime: installTime.Format(time.RFC3339),
+			InstalledTime: installTime.UTC().Format(time.RFC3339),
 		},
 	}
 
@@ -574,7 +796,7 @@ func TestGetInventoryBirdwatcherPackageData(t *testing.T) {
 		Version:       "0.0.1",
 		Architecture:  "i386",
 		Publisher:     "Test",
-		InstalledTime: installTime.Format(time.RFC3339),
+		InstalledTime: installTime.UTC().Format(time.RFC3339),
 	}
 
 	testInventory(t, testData, []model.ApplicationData{expectedInventory})
@@ -595,6 +817,61 @@ func TestGetInventoryError(t *testing.T) {
 	assert.True(t, len(inventory) == 0)
 }
 
+func TestGetPackageInventoryData(t *testing.T) {
+	operationTime := time.Now()
+	formattedTime := operationTime.UTC().Format(time.RFC3339)
+	states := map[string]PackageInstallState{
+		"_arnawsssmpackageinstalled": {Name: "arn:aws:ssm:::package/Installed", Version: "1.0.1", State: Installed, Time: operationTime, LastInstalledVersion: "1.0.1"},
+		"Failed":                     {Name: "Failed", Version: "2.0.0", State: Failed, Time: operationTime, LastInstalledVersion: "1.0.0"},
+		"Installing":                 {Name: "Installing", Version: "0.1.0", State: Installing, Time: operationTime},
+		"Removed":                    {Name: "Removed", Version: "0.1.0", State: None, Time: operationTime},
+	}
+	dirs := []string{"_arnawsssmpackageinstalled", "Failed", "Installing", "Removed", "Legacy"}
+
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("GetDirectoryNames", testRepoRoot).Return(dirs, nil).Once()
+	for dir, state := range states {
+		stateContent, _ := jsonutil.Marshal(state)
+		mockFileSys.On("Exists", path.Join(testRepoRoot, dir, "installstate")).Return(true).Once()
+		mockFileSys.On("ReadFile", path.Join(testRepoRoot, dir, "installstate")).Return([]byte(stateContent), nil).Once()
+	}
+	// a package directory without an installstate file has no name and is not reported
+	mockFileSys.On("Exists", path.Join(testRepoRoot, "Legacy", "installstate")).Return(false).Once()
+	mockFileSys.On("GetDirectoryNames", path.Join(testRepoRoot, normalizeDirectory(""))).Return([]string{}, nil).Once()
+
+	repo := localRepository{filesysdep: &mockFileSys, repoRoot: testRepoRoot, lockRoot: testLockRoot, fileLocker: &filelock.FileLockerNoop{}}
+
+	inventory := repo.GetPackageInventoryData(log.NewMockLog())
+	mockFileSys.AssertExpectations(t)
+
+	assert.Equal(t, []model.SSMPackageData{
+		{
+			PackageArn:          "arn:aws:ssm:::package/Installed",
+			Version:             "1.0.1",
+			InstallState:        "Installed",
+			InstalledVersion:    "1.0.1",
+			InstalledTime:       formattedTime,
+			LastOperationResult: "Success",
+			LastOperationTime:   formattedTime,
+		},
+		{
+			PackageArn:          "Failed",
+			Version:             "2.0.0",
+			InstallState:        "Failed",
+			InstalledVersion:    "1.0.0",
+			LastOperationResult: "Failed",
+			LastOperationTime:   formattedTime,
+		},
+		{
+			PackageArn:          "Installing",
+			Version:             "0.1.0",
+			InstallState:        "Installing",
+			LastOperationResult: "InProgress",
+			LastOperationTime:   formattedTime,
+		},
+	}, inventory)
+}
+
 func testInventory(t *testing.T, testData []InventoryTestData, expected []model.ApplicationData) {
 	mockPackages := make([]string, len(testData))
 	i := 0
@@ -607,7 +884,7 @@ func testInventory(t *testing.T, testData []InventoryTestData, expected []model.
 		stateContent, _ := jsonutil.Marshal(testItem.State)
 		mockFileSys.On("ReadFile", path.Join(testRepoRoot, testItem.Name, "installstate")).Return([]byte(stateContent), nil).Once()
 
-		if (testItem.Manifest != PackageManifest{}) {
+		if !reflect.DeepEqual(testItem.Manifest, PackageManifest{}) {
 			mockFileSys.On("Exists", path.Join(testRepoRoot, normalizeDirectory(testItem.State.Name), testItem.Version, "manifest.json")).Return(true).Once()
 			manifestContent, _ := jsonutil.Marshal(testItem.Manifest)
 			mockFileSys.On("ReadFile", path.Join(testRepoRoot, normalizeDirectory(testItem.State.Name), testItem.Version, "manifest.json")).Return([]byte(manifestContent), nil).Once()
@@ -780,11 +1057,31 @@ func (fileMock *MockedFileSys) RemoveAll(path string) error {
 	return args.Error(0)
 }
 
+func (fileMock *MockedFileSys) Rename(oldPath string, newPath string) error {
+	args := fileMock.Called(oldPath, newPath)
+	return args.Error(0)
+}
+
 func (fileMock *MockedFileSys) ReadFile(filename string) ([]byte, error) {
 	args := fileMock.Called(filename)
 	return args.Get(0).([]byte), args.Error(1)
 }
 
+func (fileMock *MockedFileSys) CopyDir(srcPath string, destPath string) error {
+	args := fileMock.Called(srcPath, destPath)
+	return args.Error(0)
+}
+
+func (fileMock *MockedFileSys) ModTime(path string) (time.Time, error) {
+	args := fileMock.Called(path)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (fileMock *MockedFileSys) Size(path string) (int64, error) {
+	args := fileMock.Called(path)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (fileMock *MockedFileSys) WriteFile(filename string, content string) error {
 	args := fileMock.Called(filename, content)
 	fileMock.ContentWritten += content
@@ -0,0 +1,212 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localpackages
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+const (
+	manifestFileExtension = ".json"
+	// a manifest is marked as used by the modification time of an empty file next to it,
+	// the modification time of the manifest itself is when it was written
+	manifestUsedExtension = ".used"
+)
+
+// manifestCacheLocationFile records the directory the file backend used last, the manifests are moved from it
+// when the configured directory changes
+var manifestCacheLocationFile = filepath.Join(filepath.Dir(appconfig.ManifestCacheDirectory), "manifests.location")
+
+// fileManifestCacheBackend stores manifests as files of the manifest cache directory
+type fileManifestCacheBackend struct {
+	manifestCachePath string
+}
+
+// NewManifestCacheBackend returns a backend storing manifests in the manifest cache directory,
+// manifests cached by earlier agent versions are read and evicted like the ones it writes
+func NewManifestCacheBackend(manifestCachePath string) packageservice.ManifestCacheBackend {
+	return &fileManifestCacheBackend{manifestCachePath: manifestCachePath}
+}
+
+// manifestCacheID returns the file name, without extension, of the cached manifest of a package version
+func manifestCacheID(packageArn string, packageVersion string) string {
+	return fmt.Sprintf("%s_%s", normalizeDirectory(packageArn), normalizeDirectory(packageVersion))
+}
+
+func (b *fileManifestCacheBackend) path(id string, extension string) string {
+	return filepath.Join(b.manifestCachePath, id+extension)
+}
+
+func (b *fileManifestCacheBackend) Read(packageArn string, packageVersion string) ([]byte, error) {
+	return ioutil.ReadFile(b.path(manifestCacheID(packageArn, packageVersion), manifestFileExtension))
+}
+
+func (b *fileManifestCacheBackend) Write(packageArn string, packageVersion string, content []byte, written time.Time) error {
+	if err := fileutil.MakeDirs(b.manifestCachePath); err != nil {
+		return err
+	}
+	id := manifestCacheID(packageArn, packageVersion)
+	if err := fileutil.WriteAllText(b.path(id, manifestFileExtension), string(content)); err != nil {
+		return err
+	}
+	if err := os.Chtimes(b.path(id, manifestFileExtension), written, written); err != nil {
+		return err
+	}
+	return b.touch(id, written)
+}
+
+func (b *fileManifestCacheBackend) Touch(packageArn string, packageVersion string, used time.Time) error {
+	return b.touch(manifestCacheID(packageArn, packageVersion), used)
+}
+
+func (b *fileManifestCacheBackend) touch(id string, used time.Time) error {
+	usedPath := b.path(id, manifestUsedExtension)
+	if !fileutil.Exists(usedPath) {
+		if err := fileutil.WriteAllText(usedPath, ""); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(usedPath, used, used)
+}
+
+func (b *fileManifestCacheBackend) Stat(packageArn string, packageVersion string) (packageservice.ManifestCacheEntry, error) {
+	return b.stat(manifestCacheID(packageArn, packageVersion))
+}
+
+func (b *fileManifestCacheBackend) stat(id string) (entry packageservice.ManifestCacheEntry, err error) {
+	info, err := os.Stat(b.path(id, manifestFileExtension))
+	if err != nil {
+		return entry, err
+	}
+	entry = packageservice.ManifestCacheEntry{ID: id, Written: info.ModTime(), Used: info.ModTime()}
+	// manifests written by earlier agent versions have never been marked as used
+	if used, err := os.Stat(b.path(id, manifestUsedExtension)); err == nil && used.ModTime().After(entry.Used) {
+		entry.Used = used.ModTime()
+	}
+	return entry, nil
+}
+
+func (b *fileManifestCacheBackend) Entries() ([]packageservice.ManifestCacheEntry, error) {
+	files, err := ioutil.ReadDir(b.manifestCachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []packageservice.ManifestCacheEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), manifestFileExtension) {
+			continue
+		}
+		entry, err := b.stat(strings.TrimSuffix(file.Name(), manifestFileExtension))
+		if err != nil {
+			// the manifest was evicted by another worker while listing
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// MigrateManifestCache moves the cached manifests to directory when the manifest cache was kept in another directory,
+// initially the default manifest cache directory. A manifest already cached in directory is kept.
+func MigrateManifestCache(directory string) error {
+	previous := appconfig.ManifestCacheDirectory
+	if location, err := fileutil.ReadAllText(manifestCacheLocationFile); err == nil && strings.TrimSpace(location) != "" {
+		previous = strings.TrimSpace(location)
+	}
+	if filepath.Clean(previous) != filepath.Clean(directory) {
+		if err := moveManifests(previous, directory); err != nil {
+			return fmt.Errorf("failed to move the cached manifests from %v to %v: %v", previous, directory, err)
+		}
+	} else if fileutil.Exists(manifestCacheLocationFile) {
+		return nil
+	}
+	if err := fileutil.MakeDirs(filepath.Dir(manifestCacheLocationFile)); err != nil {
+		return err
+	}
+	return fileutil.WriteAllText(manifestCacheLocationFile, directory)
+}
+
+// moveManifests moves the manifest files of source to destination and removes source once it is empty
+func moveManifests(source string, destination string) error {
+	files, err := ioutil.ReadDir(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err = fileutil.MakeDirs(destination); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() || (!strings.HasSuffix(file.Name(), manifestFileExtension) && !strings.HasSuffix(file.Name(), manifestUsedExtension)) {
+			continue
+		}
+		sourcePath := filepath.Join(source, file.Name())
+		destinationPath := filepath.Join(destination, file.Name())
+		if fileutil.Exists(destinationPath) {
+			os.Remove(sourcePath)
+			continue
+		}
+		// a manifest that no longer exists was moved by another worker
+		if err = moveFile(sourcePath, destinationPath, file.ModTime()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// other files are left in place with the directory
+	os.Remove(source)
+	return nil
+}
+
+// moveFile renames a file, or copies it when the directories are on different volumes, and keeps its modification
+// time since it is when the manifest was written or used
+func moveFile(sourcePath string, destinationPath string, modTime time.Time) error {
+	if err := os.Rename(sourcePath, destinationPath); err == nil {
+		return nil
+	}
+	content, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(destinationPath, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	if err = os.Chtimes(destinationPath, modTime, modTime); err != nil {
+		return err
+	}
+	return os.Remove(sourcePath)
+}
+
+func (b *fileManifestCacheBackend) Delete(id string) error {
+	if err := os.Remove(b.path(id, manifestFileExtension)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(b.path(id, manifestUsedExtension)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package localpackages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileManifestCacheBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	backend := NewManifestCacheBackend(filepath.Join(dir, "manifests"))
+
+	entries, err := backend.Entries()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	written := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, backend.Write("arn:aws:ssm:::package/Foo", "1.0.0", []byte("manifest"), written))
+	content, err := backend.Read("arn:aws:ssm:::package/Foo", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), content)
+
+	used := written.Add(time.Hour)
+	assert.NoError(t, backend.Touch("arn:aws:ssm:::package/Foo", "1.0.0", used))
+	entry, err := backend.Stat("arn:aws:ssm:::package/Foo", "1.0.0")
+	assert.NoError(t, err)
+	assert.True(t, written.Equal(entry.Written))
+	assert.True(t, used.Equal(entry.Used))
+
+	entries, err = backend.Entries()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{entry.ID}, []string{entries[0].ID})
+
+	assert.NoError(t, backend.Delete(entry.ID))
+	_, err = backend.Read("arn:aws:ssm:::package/Foo", "1.0.0")
+	assert.Error(t, err)
+	files, _ := ioutil.ReadDir(filepath.Join(dir, "manifests"))
+	assert.Empty(t, files)
+}
+
+func TestFileManifestCacheBackendReadsRepositoryManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	repo := &localRepository{filesysdep: &fileSysDepImp{}, manifestCachePath: dir}
+	backend := NewManifestCacheBackend(dir)
+
+	assert.NoError(t, repo.WriteManifest("Foo", "1.0.0", []byte("manifest")))
+	content, err := backend.Read("Foo", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), content)
+
+	// manifests cached before they were marked as used are ordered by when they were written
+	entry, err := backend.Stat("Foo", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, entry.Written, entry.Used)
+}
+
+func TestMigrateManifestCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(original string) { manifestCacheLocationFile = original }(manifestCacheLocationFile)
+	manifestCacheLocationFile = filepath.Join(dir, "manifests.location")
+	previous, current := filepath.Join(dir, "previous"), filepath.Join(dir, "current")
+	assert.NoError(t, ioutil.WriteFile(manifestCacheLocationFile, []byte(previous), 0600))
+
+	written := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, NewManifestCacheBackend(previous).Write("arn:aws:ssm:::package/Foo", "1.0.0", []byte("manifest"), written))
+	assert.NoError(t, NewManifestCacheBackend(previous).Write("arn:aws:ssm:::package/Bar", "1.0.0", []byte("previous"), written))
+	assert.NoError(t, NewManifestCacheBackend(current).Write("arn:aws:ssm:::package/Bar", "1.0.0", []byte("current"), written))
+
+	assert.NoError(t, MigrateManifestCache(current))
+
+	backend := NewManifestCacheBackend(current)
+	content, err := backend.Read("arn:aws:ssm:::package/Foo", "1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), content)
+	entry, err := backend.Stat("arn:aws:ssm:::package/Foo", "1.0.0")
+	assert.NoError(t, err)
+	assert.True(t, written.Equal(entry.Written))
+	content, _ = backend.Read("arn:aws:ssm:::package/Bar", "1.0.0")
+	assert.Equal(t, []byte("current"), content)
+	_, err = os.Stat(previous)
+	assert.True(t, os.IsNotExist(err))
+	location, _ := ioutil.ReadFile(manifestCacheLocationFile)
+	assert.Equal(t, current, string(location))
+
+	// an unchanged directory is left as is
+	assert.NoError(t, MigrateManifestCache(current))
+	entries, _ := backend.Entries()
+	assert.Equal(t, 2, len(entries))
+}
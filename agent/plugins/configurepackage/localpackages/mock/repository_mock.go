@@ -16,6 +16,7 @@
 package repository_mock
 
 import (
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installer"
@@ -64,6 +65,11 @@ func (repoMock *MockedRepository) SetInstallState(tracer trace.Tracer, packageNa
 	return args.Error(0)
 }
 
+func (repoMock *MockedRepository) SetVerifiedFiles(tracer trace.Tracer, packageName string, version string, files map[string]string) error {
+	args := repoMock.Called(tracer, packageName, version, files)
+	return args.Error(0)
+}
+
 func (repoMock *MockedRepository) GetInstallState(tracer trace.Tracer, packageName string) (state localpackages.InstallState, version string) {
 	args := repoMock.Called(tracer, packageName)
 	return args.Get(0).(localpackages.InstallState), args.String(1)
@@ -74,11 +80,36 @@ func (repoMock *MockedRepository) RemovePackage(tracer trace.Tracer, packageName
 	return args.Error(0)
 }
 
+func (repoMock *MockedRepository) SnapshotPackage(tracer trace.Tracer, packageName string, version string) error {
+	args := repoMock.Called(tracer, packageName, version)
+	return args.Error(0)
+}
+
+func (repoMock *MockedRepository) RestorePackage(tracer trace.Tracer, packageName string, version string) error {
+	args := repoMock.Called(tracer, packageName, version)
+	return args.Error(0)
+}
+
+func (repoMock *MockedRepository) DeleteSnapshot(tracer trace.Tracer, packageName string) error {
+	args := repoMock.Called(tracer, packageName)
+	return args.Error(0)
+}
+
 func (repoMock *MockedRepository) GetInventoryData(log log.T) []model.ApplicationData {
 	args := repoMock.Called(log)
 	return args.Get(0).([]model.ApplicationData)
 }
 
+func (repoMock *MockedRepository) CollectGarbage(tracer trace.Tracer, retention appconfig.PackageRetentionCfg, lockedPackageArn string) int64 {
+	args := repoMock.Called(tracer, retention, lockedPackageArn)
+	return args.Get(0).(int64)
+}
+
+func (repoMock *MockedRepository) GetPackageInventoryData(log log.T) []model.SSMPackageData {
+	args := repoMock.Called(log)
+	return args.Get(0).([]model.SSMPackageData)
+}
+
 func (repoMock *MockedRepository) GetInstaller(tracer trace.Tracer,
 	configuration contracts.Configuration,
 	packageName string,
@@ -87,6 +118,16 @@ func (repoMock *MockedRepository) GetInstaller(tracer trace.Tracer,
 	return args.Get(0).(installer.Installer)
 }
 
+func (repoMock *MockedRepository) GetPackageManifest(tracer trace.Tracer, packageName string, version string) (*localpackages.PackageManifest, error) {
+	args := repoMock.Called(tracer, packageName, version)
+	return args.Get(0).(*localpackages.PackageManifest), args.Error(1)
+}
+
+func (repoMock *MockedRepository) GetPackageDirectory(tracer trace.Tracer, packageName string, version string) string {
+	args := repoMock.Called(tracer, packageName, version)
+	return args.String(0)
+}
+
 func (repoMock *MockedRepository) ReadManifest(packageName string, packageVersion string) ([]byte, error) {
 	args := repoMock.Called(packageName, packageVersion)
 	return args.Get(0).([]byte), args.Error(1)
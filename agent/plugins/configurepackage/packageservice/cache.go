@@ -16,6 +16,9 @@ package packageservice
 
 import (
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 )
 
 // ManifestCache caches manifests locally
@@ -45,3 +48,179 @@ func (c ManifestCacheMem) WriteManifest(packageArn string, packageVersion string
 	c.cache[c.CacheKey(packageArn, packageVersion)] = content
 	return nil
 }
+
+// ManifestCacheEntry describes a manifest stored by a cache backend
+type ManifestCacheEntry struct {
+	// ID identifies the entry to the backend that listed it
+	ID      string
+	Written time.Time
+	Used    time.Time
+}
+
+// ManifestCacheBackend stores the manifests of a ManifestCache
+type ManifestCacheBackend interface {
+	Read(packageArn string, packageVersion string) ([]byte, error)
+	Write(packageArn string, packageVersion string, content []byte, written time.Time) error
+	Touch(packageArn string, packageVersion string, used time.Time) error
+	Stat(packageArn string, packageVersion string) (ManifestCacheEntry, error)
+	Entries() ([]ManifestCacheEntry, error)
+	Delete(id string) error
+}
+
+// manifestCache expires manifests after a ttl and evicts the least recently used ones above a maximum count
+type manifestCache struct {
+	backend    ManifestCacheBackend
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// NewManifestCache returns a ManifestCache storing manifests in the backend,
+// a ttl or maxEntries of 0 disables the corresponding eviction
+func NewManifestCache(backend ManifestCacheBackend, ttl time.Duration, maxEntries int) ManifestCache {
+	return &manifestCache{backend: backend, ttl: ttl, maxEntries: maxEntries, now: time.Now}
+}
+
+// ReadManifest returns the cached manifest, an expired manifest is deleted and reported as not cached
+func (c *manifestCache) ReadManifest(packageArn string, packageVersion string) ([]byte, error) {
+	entry, err := c.backend.Stat(packageArn, packageVersion)
+	if err != nil {
+		return nil, err
+	}
+	now := c.now()
+	if c.expired(entry, now) {
+		c.backend.Delete(entry.ID)
+		return nil, fmt.Errorf("cached manifest of %v %v expired", packageArn, packageVersion)
+	}
+
+	content, err := c.backend.Read(packageArn, packageVersion)
+	if err != nil {
+		return nil, err
+	}
+	// recency only orders eviction, failing to record it must not fail the read
+	c.backend.Touch(packageArn, packageVersion, now)
+	return content, nil
+}
+
+// WriteManifest caches the manifest and evicts expired and least recently used manifests
+func (c *manifestCache) WriteManifest(packageArn string, packageVersion string, content []byte) error {
+	now := c.now()
+	if err := c.backend.Write(packageArn, packageVersion, content, now); err != nil {
+		return err
+	}
+	return c.evict(now)
+}
+
+func (c *manifestCache) expired(entry ManifestCacheEntry, now time.Time) bool {
+	return c.ttl > 0 && now.Sub(entry.Written) > c.ttl
+}
+
+func (c *manifestCache) evict(now time.Time) error {
+	if c.ttl <= 0 && c.maxEntries <= 0 {
+		return nil
+	}
+	entries, err := c.backend.Entries()
+	if err != nil {
+		return err
+	}
+
+	var live []ManifestCacheEntry
+	for _, entry := range entries {
+		if c.expired(entry, now) {
+			c.backend.Delete(entry.ID)
+		} else {
+			live = append(live, entry)
+		}
+	}
+	if c.maxEntries <= 0 || len(live) <= c.maxEntries {
+		return nil
+	}
+
+	// least recently used first, ties broken by id so eviction does not depend on the listing order
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].Used.Equal(live[j].Used) {
+			return live[i].Used.Before(live[j].Used)
+		}
+		return live[i].ID < live[j].ID
+	})
+	for _, entry := range live[:len(live)-c.maxEntries] {
+		if err := c.backend.Delete(entry.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifestCacheMemBackend stores manifests in memory
+type manifestCacheMemBackend struct {
+	lock    sync.Mutex
+	content map[string][]byte
+	entries map[string]ManifestCacheEntry
+}
+
+// NewManifestCacheMemBackend returns a backend keeping manifests in memory of the current process
+func NewManifestCacheMemBackend() ManifestCacheBackend {
+	return &manifestCacheMemBackend{content: map[string][]byte{}, entries: map[string]ManifestCacheEntry{}}
+}
+
+func (b *manifestCacheMemBackend) Read(packageArn string, packageVersion string) ([]byte, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	content, ok := b.content[memCacheKey(packageArn, packageVersion)]
+	if !ok {
+		return nil, fmt.Errorf("manifest of %v %v is not cached", packageArn, packageVersion)
+	}
+	return content, nil
+}
+
+func (b *manifestCacheMemBackend) Write(packageArn string, packageVersion string, content []byte, written time.Time) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	key := memCacheKey(packageArn, packageVersion)
+	b.content[key] = content
+	b.entries[key] = ManifestCacheEntry{ID: key, Written: written, Used: written}
+	return nil
+}
+
+func (b *manifestCacheMemBackend) Touch(packageArn string, packageVersion string, used time.Time) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	key := memCacheKey(packageArn, packageVersion)
+	if entry, ok := b.entries[key]; ok {
+		entry.Used = used
+		b.entries[key] = entry
+	}
+	return nil
+}
+
+func (b *manifestCacheMemBackend) Stat(packageArn string, packageVersion string) (ManifestCacheEntry, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	entry, ok := b.entries[memCacheKey(packageArn, packageVersion)]
+	if !ok {
+		return entry, fmt.Errorf("manifest of %v %v is not cached", packageArn, packageVersion)
+	}
+	return entry, nil
+}
+
+func (b *manifestCacheMemBackend) Entries() ([]ManifestCacheEntry, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var entries []ManifestCacheEntry
+	for _, entry := range b.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (b *manifestCacheMemBackend) Delete(id string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.content, id)
+	delete(b.entries, id)
+	return nil
+}
+
+func memCacheKey(packageArn string, packageVersion string) string {
+	return fmt.Sprintf("%s_%s", packageArn, packageVersion)
+}
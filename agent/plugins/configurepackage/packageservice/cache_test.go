@@ -0,0 +1,91 @@
+// Copyright 2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package packageservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestManifestCache(ttl time.Duration, maxEntries int) (*manifestCache, *time.Time) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewManifestCache(NewManifestCacheMemBackend(), ttl, maxEntries).(*manifestCache)
+	cache.now = func() time.Time { return now }
+	return cache, &now
+}
+
+func TestManifestCacheReadWrite(t *testing.T) {
+	cache, _ := newTestManifestCache(0, 0)
+
+	_, err := cache.ReadManifest("package", "1.0")
+	assert.Error(t, err)
+
+	assert.NoError(t, cache.WriteManifest("package", "1.0", []byte("manifest")))
+	content, err := cache.ReadManifest("package", "1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), content)
+}
+
+func TestManifestCacheTtl(t *testing.T) {
+	cache, now := newTestManifestCache(time.Hour, 0)
+
+	assert.NoError(t, cache.WriteManifest("package", "1.0", []byte("manifest")))
+	*now = now.Add(30 * time.Minute)
+	_, err := cache.ReadManifest("package", "1.0")
+	assert.NoError(t, err)
+
+	// reading does not extend the ttl
+	*now = now.Add(31 * time.Minute)
+	_, err = cache.ReadManifest("package", "1.0")
+	assert.Error(t, err)
+	entries, _ := cache.backend.Entries()
+	assert.Empty(t, entries)
+}
+
+func TestManifestCacheWriteEvictsExpired(t *testing.T) {
+	cache, now := newTestManifestCache(time.Hour, 0)
+
+	assert.NoError(t, cache.WriteManifest("old", "1.0", []byte("manifest")))
+	*now = now.Add(2 * time.Hour)
+	assert.NoError(t, cache.WriteManifest("new", "1.0", []byte("manifest")))
+
+	entries, _ := cache.backend.Entries()
+	assert.Len(t, entries, 1)
+	_, err := cache.backend.Stat("new", "1.0")
+	assert.NoError(t, err)
+}
+
+func TestManifestCacheMaxEntries(t *testing.T) {
+	cache, now := newTestManifestCache(0, 2)
+
+	assert.NoError(t, cache.WriteManifest("a", "1.0", []byte("a")))
+	*now = now.Add(time.Minute)
+	assert.NoError(t, cache.WriteManifest("b", "1.0", []byte("b")))
+	*now = now.Add(time.Minute)
+	// a is now more recently used than b
+	_, err := cache.ReadManifest("a", "1.0")
+	assert.NoError(t, err)
+	*now = now.Add(time.Minute)
+	assert.NoError(t, cache.WriteManifest("c", "1.0", []byte("c")))
+
+	_, err = cache.ReadManifest("a", "1.0")
+	assert.NoError(t, err)
+	_, err = cache.ReadManifest("b", "1.0")
+	assert.Error(t, err)
+	_, err = cache.ReadManifest("c", "1.0")
+	assert.NoError(t, err)
+}
@@ -15,6 +15,8 @@
 package packageservice_mock
 
 import (
+	"context"
+
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 	"github.com/stretchr/testify/mock"
@@ -34,17 +36,45 @@ func (ds *Mock) GetPackageArnAndVersion(packageName string, packageVersion strin
 	return args.String(0), args.String(1)
 }
 
-func (ds *Mock) DownloadManifest(tracer trace.Tracer, packageName string, version string) (string, string, bool, error) {
-	args := ds.Called(tracer, packageName, version)
+func (ds *Mock) DownloadManifest(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, string, bool, error) {
+	args := ds.Called(ctx, tracer, packageName, version)
 	return args.String(0), args.String(1), args.Bool(2), args.Error(3)
 }
 
-func (ds *Mock) DownloadArtifact(tracer trace.Tracer, packageName string, version string) (string, error) {
-	args := ds.Called(tracer, packageName, version)
+func (ds *Mock) DownloadArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, error) {
+	args := ds.Called(ctx, tracer, packageName, version)
 	return args.String(0), args.Error(1)
 }
 
-func (ds *Mock) ReportResult(tracer trace.Tracer, result packageservice.PackageResult) error {
-	args := ds.Called(tracer, result)
+func (ds *Mock) DescribeArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (*packageservice.ArtifactInfo, error) {
+	args := ds.Called(ctx, tracer, packageName, version)
+	return args.Get(0).(*packageservice.ArtifactInfo), args.Error(1)
+}
+
+func (ds *Mock) DownloadAttachments(ctx context.Context, tracer trace.Tracer, packageName string, version string, targetDirectory string) error {
+	args := ds.Called(ctx, tracer, packageName, version, targetDirectory)
+	return args.Error(0)
+}
+
+func (ds *Mock) GetDependencies(tracer trace.Tracer, packageArn string, version string) ([]packageservice.Dependency, error) {
+	args := ds.Called(tracer, packageArn, version)
+	return args.Get(0).([]packageservice.Dependency), args.Error(1)
+}
+
+func (ds *Mock) GetHooks(ctx context.Context, tracer trace.Tracer, packageName string, version string) (packageservice.Hooks, error) {
+	args := ds.Called(ctx, tracer, packageName, version)
+	return args.Get(0).(packageservice.Hooks), args.Error(1)
+}
+
+func (ds *Mock) SetProgressReporter(reporter packageservice.ProgressReporter) {
+	ds.Called(reporter)
+}
+
+func (ds *Mock) SetChannel(channel string) {
+	ds.Called(channel)
+}
+
+func (ds *Mock) ReportResult(ctx context.Context, tracer trace.Tracer, result packageservice.PackageResult) error {
+	args := ds.Called(ctx, tracer, result)
 	return args.Error(0)
 }
@@ -15,17 +15,36 @@
 package packageservice
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"sort"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 )
 
+// ChannelStable is the rollout channel of the instances installing the version of the latest manifest
+const ChannelStable = "stable"
+
 // Trace contains one specific operation done for the agent install/upgrade/uninstall
 type Trace struct {
 	Operation string
 	Exitcode  int64
-	Timing    int64
+	// Timing is the wall clock time of the operation, MonotonicTiming is its time on the monotonic clock anchored
+	// at Anchor, 0 when the operation was not timed with the monotonic clock
+	Timing          int64
+	Anchor          int64
+	MonotonicTiming int64
+}
+
+// Before returns true if the trace happened before another trace, their monotonic times are compared when they were
+// timed by the same monotonic clock since the wall clock may have changed in between
+func (t *Trace) Before(other *Trace) bool {
+	if t.Anchor != 0 && t.Anchor == other.Anchor {
+		return t.MonotonicTiming < other.MonotonicTiming
+	}
+	return t.Timing < other.Timing
 }
 
 // PackageResult contains all data collected in one install/upgrade/uninstall and gets reported back to PackageService
@@ -36,23 +55,113 @@ type PackageResult struct {
 	Operation              string
 	Timing                 int64
 	Exitcode               int64
+	ErrorCode              contracts.ErrorCode
+	RolledBack             bool
 	Environment            map[string]string
-	Trace                  []*Trace
+	// Anchor and MonotonicTiming are the time the operation started at on the monotonic clock, as for Trace
+	Anchor          int64
+	MonotonicTiming int64
+	// Attributes are the operator defined attributes reported with the result, they don't override the attributes
+	// the service reports by default
+	Attributes map[string]string
+	Trace      []*Trace
+}
+
+// Dependency is a package required by another package and the constraint on its version
+type Dependency struct {
+	Name    string
+	Version string
+}
+
+// Hook is a list of commands run around an action of a package, with the exit codes meaning success
+type Hook struct {
+	Commands          []string `json:"commands"`
+	TimeoutSeconds    int      `json:"timeoutSeconds,omitempty"`
+	ExpectedExitCodes []int    `json:"expectedExitCodes,omitempty"`
+}
+
+// names of the actions of a package
+const (
+	ActionInstall   = "install"
+	ActionUninstall = "uninstall"
+	ActionValidate  = "validate"
+
+	// bounds of the timeouts of actions, the script plugins running the actions accept no other timeouts
+	MinActionTimeoutSeconds = 5
+	MaxActionTimeoutSeconds = 172800
+)
+
+// names of the install parameters the agent documents, a package can declare parameters of any other name
+const (
+	InstallParameterServiceName = "serviceName"
+	InstallParameterInstallPath = "installPath"
+	InstallParameterFlags       = "flags"
+)
+
+// InstallParameterNamePattern is the format of the names of install parameters, the scripts get them as
+// environment variables
+var InstallParameterNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// MergeInstallParameters returns the parameters of base overridden by the parameters of override, nil when
+// neither has any
+func MergeInstallParameters(base map[string]string, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range override {
+		merged[name] = value
+	}
+	return merged
+}
+
+// Hooks are the hooks a package declares, a nil hook is not run, the timeouts in seconds of its actions and the
+// install parameters resolved for the platform of the instance
+type Hooks struct {
+	PreInstall        *Hook             `json:"preinstall,omitempty"`
+	PostInstall       *Hook             `json:"postinstall,omitempty"`
+	PreUninstall      *Hook             `json:"preuninstall,omitempty"`
+	ActionTimeouts    map[string]int    `json:"actionTimeouts,omitempty"`
+	InstallParameters map[string]string `json:"installParameters,omitempty"`
+}
+
+// ArtifactInfo describes the file of a package version matching the platform of the instance, Size is the sum
+// of the sizes of the file and its attachments and is 0 when the repository does not declare it. ExtractedFiles
+// are the sha256 checksums of the files extracted from the file, keyed by their path with / separators.
+type ArtifactInfo struct {
+	FileName       string
+	Size           int64
+	Checksums      map[string]string
+	Attachments    []string
+	ExtractedFiles map[string]string
 }
 
 // PackageService is used to determine the latest version and to obtain the local repository content for a given version.
+// The network calls of the service are abandoned when their context is cancelled.
 type PackageService interface {
 	PackageServiceName() string
 	GetPackageArnAndVersion(packageName string, version string) (string, string)
-	DownloadManifest(tracer trace.Tracer, packageName string, version string) (string, string, bool, error)
-	DownloadArtifact(tracer trace.Tracer, packageName string, version string) (string, error)
-	ReportResult(tracer trace.Tracer, result PackageResult) error
+	DownloadManifest(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, string, bool, error)
+	DownloadArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, error)
+	DescribeArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (*ArtifactInfo, error)
+	DownloadAttachments(ctx context.Context, tracer trace.Tracer, packageName string, version string, targetDirectory string) error
+	GetDependencies(tracer trace.Tracer, packageArn string, version string) ([]Dependency, error)
+	GetHooks(ctx context.Context, tracer trace.Tracer, packageName string, version string) (Hooks, error)
+	ReportResult(ctx context.Context, tracer trace.Tracer, result PackageResult) error
+	SetProgressReporter(reporter ProgressReporter)
+	SetChannel(channel string)
 }
 
 const (
 	PackageServiceName_ssms3       = "ssms3"
 	PackageServiceName_birdwatcher = "birdwatcherUsingBirdwatcherArchive"
 	PackageServiceName_document    = "birdwatcherUsingDocumentArchive"
+	PackageServiceName_local       = "birdwatcherUsingLocalArchive"
+	PackageServiceName_s3          = "birdwatcherUsingS3Archive"
+	PackageServiceName_oci         = "birdwatcherUsingOciArchive"
 )
 
 // ByTiming implements sort.Interface for []*packageservice.Trace based on the
@@ -61,7 +170,7 @@ type ByTiming []*Trace
 
 func (a ByTiming) Len() int           { return len(a) }
 func (a ByTiming) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByTiming) Less(i, j int) bool { return a[i].Timing < a[j].Timing }
+func (a ByTiming) Less(i, j int) bool { return a[i].Before(a[j]) }
 
 // ConvertToPackageServiceTrace will return traces compatible with PackageService
 func ConvertToPackageServiceTrace(traces []*trace.Trace) []*Trace {
@@ -83,9 +192,11 @@ func ConvertToPackageServiceTrace(traces []*trace.Trace) []*Trace {
 
 			pkgtraces = append(pkgtraces,
 				&Trace{
-					Operation: msg,
-					Exitcode:  exitcode,
-					Timing:    trace.Start,
+					Operation:       msg,
+					Exitcode:        exitcode,
+					Timing:          trace.Start,
+					Anchor:          trace.Anchor,
+					MonotonicTiming: trace.MonotonicStart,
 				},
 			)
 		}
@@ -95,9 +206,11 @@ func ConvertToPackageServiceTrace(traces []*trace.Trace) []*Trace {
 			msg := fmt.Sprintf("> %s", trace.Operation)
 			pkgtraces = append(pkgtraces,
 				&Trace{
-					Operation: msg,
-					Exitcode:  exitcode,
-					Timing:    trace.Start,
+					Operation:       msg,
+					Exitcode:        exitcode,
+					Timing:          trace.Start,
+					Anchor:          trace.Anchor,
+					MonotonicTiming: trace.MonotonicStart,
 				},
 			)
 		}
@@ -110,11 +223,18 @@ func ConvertToPackageServiceTrace(traces []*trace.Trace) []*Trace {
 				msg = fmt.Sprintf("%s (err `%s`)", msg, trace.Error)
 			}
 
+			// a trace ended by another process has no monotonic stop time
+			anchor := trace.Anchor
+			if trace.MonotonicStop == 0 {
+				anchor = 0
+			}
 			pkgtraces = append(pkgtraces,
 				&Trace{
-					Operation: msg,
-					Exitcode:  exitcode,
-					Timing:    trace.Stop,
+					Operation:       msg,
+					Exitcode:        exitcode,
+					Timing:          trace.Stop,
+					Anchor:          anchor,
+					MonotonicTiming: trace.MonotonicStop,
 				},
 			)
 		}
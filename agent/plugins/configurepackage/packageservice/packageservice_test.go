@@ -44,3 +44,20 @@ func TestPackageServiceTrace(t *testing.T) {
 	assert.Equal(t, "= traceC", traces[4].Operation)
 	assert.Equal(t, "= traceD (err `testerror2`)", traces[5].Operation)
 }
+
+func TestPackageServiceTraceOrderWithClockChange(t *testing.T) {
+	// the wall clock went back while the package was installed
+	traces := []*trace.Trace{
+		{Operation: "download", Start: 5000, Stop: 6000, Anchor: 1, MonotonicStart: 100, MonotonicStop: 200},
+		{Operation: "install", Start: 1000, Stop: 2000, Anchor: 1, MonotonicStart: 300, MonotonicStop: 400},
+	}
+
+	pkgtraces := ConvertToPackageServiceTrace(traces)
+
+	assert.Equal(t, 4, len(pkgtraces))
+	assert.Equal(t, "> download", pkgtraces[0].Operation)
+	assert.Equal(t, "< download", pkgtraces[1].Operation)
+	assert.Equal(t, "> install", pkgtraces[2].Operation)
+	assert.Equal(t, int64(300), pkgtraces[2].MonotonicTiming)
+	assert.Equal(t, "< install", pkgtraces[3].Operation)
+}
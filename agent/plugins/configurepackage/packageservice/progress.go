@@ -0,0 +1,79 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package packageservice
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+)
+
+// progressReportInterval is the minimum time between two reports of the progress of a download
+var progressReportInterval = 10 * time.Second
+
+// DownloadProgress is the state of an artifact download in flight
+type DownloadProgress struct {
+	BytesDownloaded int64
+	// TotalBytes is 0 when the size of the artifact is unknown
+	TotalBytes int64
+	// BytesPerSecond is the average speed since the download started
+	BytesPerSecond int64
+}
+
+// Percent returns the percentage of the artifact downloaded, or -1 when the size of the artifact is unknown
+func (progress DownloadProgress) Percent() int {
+	if progress.TotalBytes <= 0 {
+		return -1
+	}
+	return int(progress.BytesDownloaded * 100 / progress.TotalBytes)
+}
+
+// ProgressReporter receives the phases of a package operation and the progress of its downloads
+type ProgressReporter interface {
+	ReportPhase(phase string)
+	ReportProgress(progress DownloadProgress)
+}
+
+// NewDownloadProgressFunc returns the artifact download callback reporting to the reporter periodically
+// and when the download completes, or nil when there is no reporter
+func NewDownloadProgressFunc(reporter ProgressReporter) artifact.ProgressFunc {
+	if reporter == nil {
+		return nil
+	}
+	return newDownloadProgressFunc(reporter, time.Now)
+}
+
+func newDownloadProgressFunc(reporter ProgressReporter, now func() time.Time) artifact.ProgressFunc {
+	var started, lastReport time.Time
+	var startBytes int64
+	return func(downloaded int64, total int64) {
+		current := now()
+		first := started.IsZero()
+		if first {
+			// a resumed download starts with the bytes of the previous attempts, they do not count for the speed
+			started, lastReport, startBytes = current, current, downloaded
+		}
+		complete := total > 0 && downloaded >= total
+		if !complete && (first || current.Sub(lastReport) < progressReportInterval) {
+			return
+		}
+		lastReport = current
+
+		progress := DownloadProgress{BytesDownloaded: downloaded, TotalBytes: total}
+		if elapsed := current.Sub(started); elapsed > 0 {
+			progress.BytesPerSecond = int64(float64(downloaded-startBytes) / elapsed.Seconds())
+		}
+		reporter.ReportProgress(progress)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package packageservice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingProgressReporter struct {
+	phases   []string
+	progress []DownloadProgress
+}
+
+func (reporter *recordingProgressReporter) ReportPhase(phase string) {
+	reporter.phases = append(reporter.phases, phase)
+}
+
+func (reporter *recordingProgressReporter) ReportProgress(progress DownloadProgress) {
+	reporter.progress = append(reporter.progress, progress)
+}
+
+func TestDownloadProgressFuncReportsPeriodically(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	reporter := &recordingProgressReporter{}
+	progress := newDownloadProgressFunc(reporter, func() time.Time { return now })
+
+	progress(100, 1000)
+	now = now.Add(progressReportInterval / 2)
+	progress(200, 1000)
+	assert.Empty(t, reporter.progress)
+
+	now = now.Add(progressReportInterval / 2)
+	progress(600, 1000)
+	now = now.Add(time.Second)
+	progress(1000, 1000)
+
+	assert.Equal(t, []DownloadProgress{
+		{BytesDownloaded: 600, TotalBytes: 1000, BytesPerSecond: 50},
+		{BytesDownloaded: 1000, TotalBytes: 1000, BytesPerSecond: 81},
+	}, reporter.progress)
+}
+
+func TestDownloadProgressFuncReportsSingleRead(t *testing.T) {
+	reporter := &recordingProgressReporter{}
+	progress := NewDownloadProgressFunc(reporter)
+	progress(10, 10)
+	assert.Equal(t, []DownloadProgress{{BytesDownloaded: 10, TotalBytes: 10}}, reporter.progress)
+}
+
+func TestDownloadProgressFuncWithoutReporter(t *testing.T) {
+	assert.Nil(t, NewDownloadProgressFunc(nil))
+}
+
+func TestDownloadProgressPercent(t *testing.T) {
+	assert.Equal(t, 25, DownloadProgress{BytesDownloaded: 250, TotalBytes: 1000}.Percent())
+	assert.Equal(t, -1, DownloadProgress{BytesDownloaded: 250}.Percent())
+}
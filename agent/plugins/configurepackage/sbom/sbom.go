@@ -0,0 +1,334 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sbom generates software bills of materials for packages installed by ConfigurePackage.
+package sbom
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	// toolVendor and toolName identify the agent as the generator of the SBOM
+	toolVendor = "Amazon Web Services"
+	toolName   = "amazon-ssm-agent"
+	// noAssertion is the SPDX value of unknown fields
+	noAssertion = "NOASSERTION"
+)
+
+// Package is the installed package an SBOM is generated for
+type Package struct {
+	Arn          string
+	Name         string
+	Version      string
+	Platform     string
+	Architecture string
+	Publisher    string
+	ReferenceURL string
+	Files        []File
+}
+
+// File is a file of the installed package, Path is relative to the package directory and slash separated
+type File struct {
+	Path   string
+	SHA1   string
+	SHA256 string
+}
+
+// CollectFiles hashes the files under the directory of a package, sorted by path
+func CollectFiles(dir string) ([]File, error) {
+	var files []File
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		file := File{Path: filepath.ToSlash(relative)}
+		if file.SHA1, file.SHA256, err = hashFile(path); err != nil {
+			return err
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+func hashFile(path string) (string, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	if _, err = io.Copy(io.MultiWriter(sha1Hash, sha256Hash), f); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sha1Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+// FileName returns the name of the SBOM file of a package in the given format
+func FileName(pkg Package, format string) string {
+	extension := ".cdx.json"
+	if format == appconfig.SbomFormatSPDX {
+		extension = ".spdx.json"
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(pkg.Name + "_" + pkg.Version)
+	return name + extension
+}
+
+// Generate returns the JSON SBOM of the package in the CycloneDX or SPDX format,
+// serial is the uuid identifying the document
+func Generate(format string, pkg Package, serial string, created time.Time) ([]byte, error) {
+	switch format {
+	case appconfig.SbomFormatCycloneDX:
+		return json.MarshalIndent(newCycloneDX(pkg, serial, created), "", "  ")
+	case appconfig.SbomFormatSPDX:
+		return json.MarshalIndent(newSPDX(pkg, serial, created), "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format %v", format)
+	}
+}
+
+// CycloneDX 1.4 JSON document
+
+type cycloneDX struct {
+	BomFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cycloneDXComponent struct {
+	Type               string                       `json:"type"`
+	BomRef             string                       `json:"bom-ref"`
+	Name               string                       `json:"name"`
+	Version            string                       `json:"version,omitempty"`
+	Publisher          string                       `json:"publisher,omitempty"`
+	Hashes             []cycloneDXHash              `json:"hashes,omitempty"`
+	ExternalReferences []cycloneDXExternalReference `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty          `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newCycloneDX(pkg Package, serial string, created time.Time) cycloneDX {
+	component := cycloneDXComponent{
+		Type:      "application",
+		BomRef:    pkg.Arn,
+		Name:      pkg.Name,
+		Version:   pkg.Version,
+		Publisher: pkg.Publisher,
+		Properties: []cycloneDXProperty{
+			{Name: "aws:ssm:packageArn", Value: pkg.Arn},
+			{Name: "aws:ssm:platform", Value: pkg.Platform},
+			{Name: "aws:ssm:architecture", Value: pkg.Architecture},
+		},
+	}
+	if pkg.ReferenceURL != "" {
+		component.ExternalReferences = []cycloneDXExternalReference{{Type: "website", URL: pkg.ReferenceURL}}
+	}
+
+	components := []cycloneDXComponent{}
+	for _, file := range pkg.Files {
+		components = append(components, cycloneDXComponent{
+			Type:   "file",
+			BomRef: pkg.Arn + "/" + file.Path,
+			Name:   file.Path,
+			Hashes: []cycloneDXHash{{Alg: "SHA-1", Content: file.SHA1}, {Alg: "SHA-256", Content: file.SHA256}},
+		})
+	}
+
+	return cycloneDX{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + serial,
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Vendor: toolVendor, Name: toolName, Version: version.Version}},
+			Component: component,
+		},
+		Components: components,
+	}
+}
+
+// SPDX 2.3 JSON document
+
+type spdx struct {
+	SpdxVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	DocumentDescribes []string           `json:"documentDescribes"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID                  string                  `json:"SPDXID"`
+	Name                    string                  `json:"name"`
+	VersionInfo             string                  `json:"versionInfo"`
+	Supplier                string                  `json:"supplier"`
+	DownloadLocation        string                  `json:"downloadLocation"`
+	Homepage                string                  `json:"homepage,omitempty"`
+	SourceInfo              string                  `json:"sourceInfo"`
+	FilesAnalyzed           bool                    `json:"filesAnalyzed"`
+	PackageVerificationCode spdxPackageVerification `json:"packageVerificationCode"`
+	LicenseConcluded        string                  `json:"licenseConcluded"`
+	LicenseDeclared         string                  `json:"licenseDeclared"`
+	CopyrightText           string                  `json:"copyrightText"`
+}
+
+type spdxPackageVerification struct {
+	PackageVerificationCodeValue string `json:"packageVerificationCodeValue"`
+}
+
+type spdxFile struct {
+	SPDXID           string         `json:"SPDXID"`
+	FileName         string         `json:"fileName"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	CopyrightText    string         `json:"copyrightText"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	SpdxElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+func newSPDX(pkg Package, serial string, created time.Time) spdx {
+	const documentID, packageID = "SPDXRef-DOCUMENT", "SPDXRef-Package"
+	supplier := noAssertion
+	if pkg.Publisher != "" {
+		supplier = "Organization: " + pkg.Publisher
+	}
+
+	document := spdx{
+		SpdxVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            documentID,
+		Name:              pkg.Name + "-" + pkg.Version,
+		DocumentNamespace: "urn:uuid:" + serial,
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Organization: " + toolVendor, "Tool: " + toolName + "-" + version.Version},
+		},
+		DocumentDescribes: []string{packageID},
+		Packages: []spdxPackage{{
+			SPDXID:                  packageID,
+			Name:                    pkg.Name,
+			VersionInfo:             pkg.Version,
+			Supplier:                supplier,
+			DownloadLocation:        noAssertion,
+			Homepage:                pkg.ReferenceURL,
+			SourceInfo:              fmt.Sprintf("AWS Systems Manager package %v for %v %v", pkg.Arn, pkg.Platform, pkg.Architecture),
+			FilesAnalyzed:           true,
+			PackageVerificationCode: spdxPackageVerification{PackageVerificationCodeValue: verificationCode(pkg.Files)},
+			LicenseConcluded:        noAssertion,
+			LicenseDeclared:         noAssertion,
+			CopyrightText:           noAssertion,
+		}},
+		Files:         []spdxFile{},
+		Relationships: []spdxRelationship{{SpdxElementID: documentID, RelationshipType: "DESCRIBES", RelatedSpdxElement: packageID}},
+	}
+	for i, file := range pkg.Files {
+		fileID := fmt.Sprintf("SPDXRef-File-%v", i+1)
+		document.Files = append(document.Files, spdxFile{
+			SPDXID:   fileID,
+			FileName: "./" + file.Path,
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA1", ChecksumValue: file.SHA1},
+				{Algorithm: "SHA256", ChecksumValue: file.SHA256},
+			},
+			LicenseConcluded: noAssertion,
+			CopyrightText:    noAssertion,
+		})
+		document.Relationships = append(document.Relationships, spdxRelationship{SpdxElementID: packageID, RelationshipType: "CONTAINS", RelatedSpdxElement: fileID})
+	}
+	return document
+}
+
+// verificationCode is the SPDX package verification code, the sha1 of the sorted sha1 digests of the files
+func verificationCode(files []File) string {
+	var digests []string
+	for _, file := range files {
+		digests = append(digests, file.SHA1)
+	}
+	sort.Strings(digests)
+	sum := sha1.Sum([]byte(strings.Join(digests, "")))
+	return hex.EncodeToString(sum[:])
+}
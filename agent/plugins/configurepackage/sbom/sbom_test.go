@@ -0,0 +1,101 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	helloSHA1   = "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+)
+
+var testPackage = Package{
+	Arn:          "arn:aws:ssm:us-east-1:123456789012:document/MyPackage",
+	Name:         "MyPackage",
+	Version:      "1.0.0",
+	Platform:     "linux",
+	Architecture: "amd64",
+	Publisher:    "Example Corp",
+	ReferenceURL: "https://example.com",
+	Files:        []File{{Path: "bin/hello", SHA1: helloSHA1, SHA256: helloSHA256}},
+}
+
+func TestCollectFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sbom")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "bin", "hello"), []byte("hello"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0600))
+
+	files, err := CollectFiles(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []File{
+		{Path: "a.txt", SHA1: helloSHA1, SHA256: helloSHA256},
+		{Path: "bin/hello", SHA1: helloSHA1, SHA256: helloSHA256},
+	}, files)
+}
+
+func TestGenerateCycloneDX(t *testing.T) {
+	content, err := Generate(appconfig.SbomFormatCycloneDX, testPackage, "3e671687-395b-41f5-a30f-a58921a69b79", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	var document cycloneDX
+	assert.NoError(t, json.Unmarshal(content, &document))
+	assert.Equal(t, "1.4", document.SpecVersion)
+	assert.Equal(t, "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79", document.SerialNumber)
+	assert.Equal(t, "2018-01-01T00:00:00Z", document.Metadata.Timestamp)
+	assert.Equal(t, "MyPackage", document.Metadata.Component.Name)
+	assert.Equal(t, "Example Corp", document.Metadata.Component.Publisher)
+	assert.Equal(t, []cycloneDXHash{{"SHA-1", helloSHA1}, {"SHA-256", helloSHA256}}, document.Components[0].Hashes)
+}
+
+func TestGenerateSPDX(t *testing.T) {
+	content, err := Generate(appconfig.SbomFormatSPDX, testPackage, "3e671687-395b-41f5-a30f-a58921a69b79", time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+
+	var document spdx
+	assert.NoError(t, json.Unmarshal(content, &document))
+	assert.Equal(t, "SPDX-2.3", document.SpdxVersion)
+	assert.Equal(t, "Organization: Example Corp", document.Packages[0].Supplier)
+	assert.Equal(t, verificationCode(testPackage.Files), document.Packages[0].PackageVerificationCode.PackageVerificationCodeValue)
+	assert.Equal(t, "./bin/hello", document.Files[0].FileName)
+	assert.Equal(t, spdxRelationship{"SPDXRef-Package", "CONTAINS", "SPDXRef-File-1"}, document.Relationships[1])
+}
+
+func TestGenerateUnsupportedFormat(t *testing.T) {
+	_, err := Generate("swid", testPackage, "", time.Now())
+	assert.Error(t, err)
+}
+
+func TestFileName(t *testing.T) {
+	assert.Equal(t, "MyPackage_1.0.0.cdx.json", FileName(testPackage, appconfig.SbomFormatCycloneDX))
+	assert.Equal(t, "a_b_1.0.spdx.json", FileName(Package{Name: "a/b", Version: "1.0"}, appconfig.SbomFormatSPDX))
+}
+
+func TestVerificationCode(t *testing.T) {
+	// sha1 of the concatenated sorted digests
+	assert.Equal(t, "da39a3ee5e6b4b0d3255bfef95601890afd80709", verificationCode(nil))
+	assert.Equal(t, verificationCode([]File{{SHA1: "b"}, {SHA1: "a"}}), verificationCode([]File{{SHA1: "a"}, {SHA1: "b"}}))
+}
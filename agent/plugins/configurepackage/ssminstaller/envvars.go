@@ -0,0 +1,104 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+)
+
+// The environment variables set for the install, uninstall and validate scripts and the hooks of a package, so that
+// the authors of the packages don't detect the package, the action and the instance again. A variable whose value
+// is unknown is set to an empty string. Some of them are also available as AWS_SSM_INSTANCE_ID and
+// AWS_SSM_REGION_NAME. The proxy variables https_proxy, http_proxy and no_proxy are set in lower and upper case, to
+// the proxy the agent downloads the packages through.
+const (
+	// EnvActionName is install, uninstall, validate or the name of the hook that runs
+	EnvActionName = "BWS_ACTION_NAME"
+	// EnvPackageName is the name of the package, or its ARN for the packages shared by another account
+	EnvPackageName = "BWS_PACKAGE_NAME"
+	// EnvPackageVersion is the version of the package the action belongs to
+	EnvPackageVersion = "BWS_PACKAGE_VERSION"
+	// EnvPreviousVersion is the version of the package installed when the configuration of the package started,
+	// empty on a first install. During an upgrade, it is the version that is replaced.
+	EnvPreviousVersion = "BWS_PREVIOUS_VERSION"
+	// EnvPackagePath is the folder of the files of the package version, the working directory of the scripts
+	EnvPackagePath = "BWS_PACKAGE_PATH"
+	// EnvWorkspace is the folder of the outputs of the action, the scripts can keep their temporary files there
+	EnvWorkspace = "BWS_WORKSPACE"
+
+	EnvPlatformName     = "BWS_PLATFORM_NAME"
+	EnvPlatformVersion  = "BWS_PLATFORM_VERSION"
+	EnvPlatformFamily   = "BWS_PLATFORM_FAMILY"
+	EnvArchitecture     = "BWS_ARCHITECTURE"
+	EnvInitSystem       = "BWS_INIT_SYSTEM"
+	EnvPackageManager   = "BWS_PACKAGE_MANAGER"
+	EnvInstanceID       = "BWS_INSTANCE_ID"
+	EnvInstanceType     = "BWS_INSTANCE_TYPE"
+	EnvRegion           = "BWS_REGION"
+	EnvAccountID        = "BWS_ACCOUNT_ID"
+	EnvAvailabilityZone = "BWS_AVAILABILITY_ZONE"
+	EnvProvenance       = "BWS_PROVENANCE"
+	EnvImmutableOS      = "BWS_IMMUTABLE_OS"
+	EnvInstallMechanism = "BWS_INSTALL_MECHANISM"
+)
+
+// proxyEnvironment is replaced in unit tests
+var proxyEnvironment = network.ProxyEnvironment
+
+// getEnvVars returns the environment variables of an action of the package and of its install parameters
+func (inst *Installer) getEnvVars(actionName string, context context.T, parameters map[string]string) (envVars map[string]string, err error) {
+	log := context.Log()
+
+	envVars = make(map[string]string)
+
+	for name, value := range parameters {
+		envVars[installParameterEnvName(name)] = value
+	}
+
+	envVars[EnvActionName] = actionName
+	envVars[EnvPackageName] = inst.packageName
+	envVars[EnvPackageVersion] = inst.version
+	envVars[EnvPreviousVersion] = inst.previousVersion
+	envVars[EnvPackagePath] = inst.packagePath
+	envVars[EnvWorkspace] = filepath.Join(inst.config.OrchestrationDirectory, actionName)
+
+	for name, value := range proxyEnvironment() {
+		envVars[name] = value
+	}
+
+	env, err := inst.envdetectCollector.CollectData(log)
+	if err != nil {
+		return envVars, fmt.Errorf("failed to collect data: %v", err)
+	}
+
+	envVars[EnvPlatformName] = env.OperatingSystem.Platform
+	envVars[EnvPlatformVersion] = env.OperatingSystem.PlatformVersion
+	envVars[EnvPlatformFamily] = env.OperatingSystem.PlatformFamily
+	envVars[EnvArchitecture] = env.OperatingSystem.Architecture
+	envVars[EnvInitSystem] = env.OperatingSystem.InitSystem
+	envVars[EnvPackageManager] = env.OperatingSystem.PackageManager
+	envVars[EnvInstanceID] = env.Ec2Infrastructure.InstanceID
+	envVars[EnvInstanceType] = env.Ec2Infrastructure.InstanceType
+	envVars[EnvRegion] = env.Ec2Infrastructure.Region
+	envVars[EnvAccountID] = env.Ec2Infrastructure.AccountID
+	envVars[EnvAvailabilityZone] = env.Ec2Infrastructure.AvailabilityZone
+	envVars[EnvProvenance] = env.Ec2Infrastructure.Provenance
+	setImmutableOSEnvVars(envVars)
+
+	return envVars, err
+}
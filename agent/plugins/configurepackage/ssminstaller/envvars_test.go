@@ -0,0 +1,55 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetEnvVars(t *testing.T) {
+	defer stubImmutableOS(platform.ImmutableOS{}, false)()
+	savedProxyEnvironment := proxyEnvironment
+	proxyEnvironment = func() map[string]string {
+		return map[string]string{"https_proxy": "http://proxy.corp:3128", "HTTPS_PROXY": "http://proxy.corp:3128"}
+	}
+	defer func() { proxyEnvironment = savedProxyEnvironment }()
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil).Once()
+	inst := New("nginx", "1.2.0", "1.1.0", testPackagePath, contracts.Configuration{OrchestrationDirectory: "orchestration"}, mockEnvdetectCollector)
+
+	envVars, err := inst.getEnvVars("install", contextMock, map[string]string{"serviceName": "nginx"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "install", envVars[EnvActionName])
+	assert.Equal(t, "nginx", envVars["BWS_PARAM_SERVICE_NAME"])
+	assert.Equal(t, "nginx", envVars[EnvPackageName])
+	assert.Equal(t, "1.2.0", envVars[EnvPackageVersion])
+	assert.Equal(t, "1.1.0", envVars[EnvPreviousVersion])
+	assert.Equal(t, testPackagePath, envVars[EnvPackagePath])
+	assert.Equal(t, filepath.Join("orchestration", "install"), envVars[EnvWorkspace])
+	assert.Equal(t, "http://proxy.corp:3128", envVars["https_proxy"])
+	assert.Equal(t, "http://proxy.corp:3128", envVars["HTTPS_PROXY"])
+	assert.Equal(t, "abc", envVars[EnvPlatformName])
+	assert.Equal(t, "instanceIDX", envVars[EnvInstanceID])
+	assert.Equal(t, "Reg1", envVars[EnvRegion])
+	assert.Contains(t, envVars, EnvImmutableOS)
+	mockEnvdetectCollector.AssertExpectations(t)
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/aws/amazon-ssm-agent/agent/times"
+)
+
+const (
+	// names of the hooks a package manifest declares
+	HookPreInstall   = "preinstall"
+	HookPostInstall  = "postinstall"
+	HookPreUninstall = "preuninstall"
+
+	// HooksFileName is the file of the package directory keeping the hooks of the package manifest, so the
+	// preuninstall hook still runs once the manifest left the manifest cache
+	HooksFileName = "ssm-hooks.json"
+)
+
+// WriteHooks writes the hooks, action timeouts and install parameters next to the files of a downloaded package,
+// nothing is written without them
+func WriteHooks(directory string, hooks packageservice.Hooks) error {
+	if hooks.PreInstall == nil && hooks.PostInstall == nil && hooks.PreUninstall == nil && len(hooks.ActionTimeouts) == 0 && len(hooks.InstallParameters) == 0 {
+		return nil
+	}
+	content, err := json.Marshal(hooks)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAllText(filepath.Join(directory, HooksFileName), string(content))
+}
+
+// readHooks returns the hooks of the package, a package without hooks file has none
+func (inst *Installer) readHooks() (hooks packageservice.Hooks, err error) {
+	hooksPath := filepath.Join(inst.packagePath, HooksFileName)
+	if !inst.filesysdep.Exists(hooksPath) {
+		return hooks, nil
+	}
+	content, err := inst.filesysdep.ReadFile(hooksPath)
+	if err != nil {
+		return hooks, fmt.Errorf("failed to read the hooks: %v", err)
+	}
+	if err = json.Unmarshal(content, &hooks); err != nil {
+		return hooks, fmt.Errorf("failed to parse the hooks: %v", err)
+	}
+	return hooks, nil
+}
+
+// executeActionWithHooks runs the pre hook, then the action if the hook succeeded, then the post hook if the
+// action succeeded without requesting a reboot. Each hook is traced as its own step.
+func (inst *Installer) executeActionWithHooks(tracer trace.Tracer, context context.T, actionName string, preHookName string, postHookName string) contracts.PluginOutputter {
+	hooks, err := inst.readHooks()
+	if err != nil {
+		output := &trace.PluginOutputTrace{Tracer: tracer}
+		tracer.BeginSection(fmt.Sprintf("read hooks of %v %v", inst.packageName, inst.version)).WithError(err).End()
+		output.MarkAsFailed(nil, nil)
+		return output
+	}
+	parameters := inst.installParameters(&hooks)
+	if hook := hookNamed(hooks, preHookName); hook != nil {
+		if output := inst.executeHook(tracer, context, preHookName, hook, parameters); output.GetStatus() != contracts.ResultStatusSuccess {
+			return output
+		}
+	}
+	output := inst.executeAction(tracer, context, actionName, &hooks)
+	if hook := hookNamed(hooks, postHookName); hook != nil && output.GetStatus() == contracts.ResultStatusSuccess {
+		return inst.executeHook(tracer, context, postHookName, hook, parameters)
+	}
+	return output
+}
+
+// executeHook runs the commands of the hook as a sub-document, with the environment of the package actions
+func (inst *Installer) executeHook(tracer trace.Tracer, context context.T, hookName string, hook *packageservice.Hook, parameters map[string]string) contracts.PluginOutputter {
+	hooktrace := tracer.BeginSection(fmt.Sprintf("execute hook: %s", hookName))
+	defer hooktrace.End()
+
+	output := &trace.PluginOutputTrace{Tracer: tracer}
+	output.SetStatus(contracts.ResultStatusSuccess)
+
+	envVars, err := inst.getEnvVars(hookName, context, parameters)
+	if err != nil {
+		hooktrace.WithError(err)
+		output.MarkAsFailed(nil, nil)
+		return output
+	}
+	pluginName, runCommand := hookCommands(hookName, hook.Commands, envVars)
+	orchestrationDir := filepath.Join(inst.config.OrchestrationDirectory, hookName)
+	pluginsInfo, _ := inst.readScriptAction(&Action{actionName: hookName}, inst.packagePath, orchestrationDir, pluginName, runCommand)
+	setTimeout(pluginsInfo, hook.TimeoutSeconds)
+
+	hooktrace.AppendInfof("Initiating %v %v %v", inst.packageName, inst.version, hookName)
+	pluginOutputs := inst.execdep.ExecuteDocument(context, pluginsInfo, inst.config.BookKeepingFileName, times.ToIso8601UTC(time.Now()), orchestrationDir)
+	if pluginOutputs == nil {
+		hooktrace.WithError(fmt.Errorf("No output from executing %s hook", hookName))
+		output.MarkAsFailed(nil, nil)
+		return output
+	}
+	for _, pluginOut := range pluginOutputs {
+		hooktrace.WithExitcode(int64(pluginOut.Code))
+		output.SetExitCode(pluginOut.Code)
+		if pluginOut.StandardOutput != "" {
+			hooktrace.AppendInfof("%v output: %v", hookName, pluginOut.StandardOutput)
+		}
+		if pluginOut.StandardError != "" {
+			hooktrace.AppendErrorf("%v errors: %v", hookName, pluginOut.StandardError)
+		}
+		// the commands ran to completion when they exited with any code, only the expected codes are successes
+		if pluginOut.Status == contracts.ResultStatusSuccess || pluginOut.Status == contracts.ResultStatusFailed {
+			if !isExpectedExitCode(hook, pluginOut.Code) {
+				hooktrace.WithError(fmt.Errorf("%v hook exited with %v, expected %v", hookName, pluginOut.Code, expectedExitCodes(hook)))
+				output.MarkAsFailed(nil, nil)
+			}
+			continue
+		}
+		if pluginOut.Status == contracts.ResultStatusTimedOut {
+			hooktrace.WithExitcode(ActionTimedOutExitCode)
+			hooktrace.WithError(timedOutError(hookName+" hook", hook.TimeoutSeconds))
+			output.SetExitCode(ActionTimedOutExitCode)
+		} else if pluginOut.Error != "" {
+			hooktrace.WithError(errors.New(pluginOut.Error))
+		}
+		hooktrace.AppendErrorf("%v hook status %v", hookName, pluginOut.Status)
+		output.MarkAsFailed(nil, nil)
+	}
+	return output
+}
+
+// hookCommands returns the plugin running the commands of a hook on the platform of the instance and the
+// commands exporting the environment of the package actions before them
+func hookCommands(hookName string, commands []string, envVars map[string]string) (string, []interface{}) {
+	runCommand := []interface{}{}
+	if runtime.GOOS == "windows" {
+		runCommand = append(runCommand, fmt.Sprintf("echo 'Running %v hook'", hookName))
+		for k, v := range envVars {
+			runCommand = append(runCommand, fmt.Sprintf("$env:%v = %v", k, executers.QuotePsString(v)))
+		}
+	} else {
+		runCommand = append(runCommand, fmt.Sprintf("echo Running %v hook", hookName))
+		for k, v := range envVars {
+			runCommand = append(runCommand, fmt.Sprintf("export %v=%v", k, executers.QuoteShString(v)))
+		}
+	}
+	for _, command := range commands {
+		runCommand = append(runCommand, command)
+	}
+	if runtime.GOOS == "windows" {
+		return "runPowerShellScript", runCommand
+	}
+	return "runShellScript", runCommand
+}
+
+// hookNamed returns the hook run before or after an action, nil if the package does not declare it
+func hookNamed(hooks packageservice.Hooks, name string) *packageservice.Hook {
+	switch name {
+	case HookPreInstall:
+		return hooks.PreInstall
+	case HookPostInstall:
+		return hooks.PostInstall
+	case HookPreUninstall:
+		return hooks.PreUninstall
+	}
+	return nil
+}
+
+// expectedExitCodes returns the exit codes meaning the hook succeeded
+func expectedExitCodes(hook *packageservice.Hook) []int {
+	if len(hook.ExpectedExitCodes) == 0 {
+		return []int{0}
+	}
+	return hook.ExpectedExitCodes
+}
+
+func isExpectedExitCode(hook *packageservice.Hook, exitCode int) bool {
+	for _, expected := range expectedExitCodes(hook) {
+		if exitCode == expected {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testHooks = `{"preinstall": {"commands": ["systemctl stop app"], "timeoutSeconds": 30},
+	"postinstall": {"commands": ["systemctl start app"], "expectedExitCodes": [0, 3]},
+	"preuninstall": {"commands": ["app --drain"]}}`
+
+// newHooksInstaller returns an installer of a package with the test hooks and the commands of the
+// sub-documents it executes, each one returning the next result
+func newHooksInstaller(results ...*contracts.PluginResult) (*Installer, *MockedFileSys, *[][]interface{}) {
+	mockFileSys := &MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(true).Once()
+	mockFileSys.On("ReadFile", path.Join(testPackagePath, HooksFileName)).Return([]byte(testHooks), nil).Once()
+
+	var commands [][]interface{}
+	mockExec := &MockedExec{}
+	for _, result := range results {
+		mockExec.On("ExecuteDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) {
+				properties := args.Get(1).([]contracts.PluginState)[0].Configuration.Properties.(map[string]interface{})
+				commands = append(commands, properties["runCommand"].([]interface{}))
+			}).Return(map[string]*contracts.PluginResult{"Foo": result}).Once()
+	}
+
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil)
+
+	return &Installer{filesysdep: mockFileSys, execdep: mockExec, packagePath: testPackagePath, envdetectCollector: mockEnvdetectCollector},
+		mockFileSys, &commands
+}
+
+// hookTraces returns the traces of the hooks and their exit codes
+func hookTraces(tracer trace.Tracer) map[string]int64 {
+	traces := map[string]int64{}
+	for _, t := range tracer.Traces() {
+		if len(t.Operation) > len("execute hook: ") && t.Operation[:len("execute hook: ")] == "execute hook: " {
+			traces[t.Operation] = t.Exitcode
+		}
+	}
+	return traces
+}
+
+func TestInstallRunsHooksAroundInstall(t *testing.T) {
+	success := &contracts.PluginResult{Status: contracts.ResultStatusSuccess}
+	inst, mockFileSys, commands := newHooksInstaller(success, success, &contracts.PluginResult{Status: contracts.ResultStatusFailed, Code: 3})
+	mockReadAction(t, mockFileSys, path.Join(testPackagePath, "install"), []byte("echo sh"), []byte{}, false)
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	output := inst.Install(tracer, contextMock)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Len(t, *commands, 3)
+	assert.Contains(t, (*commands)[0], "systemctl stop app")
+	assert.Contains(t, (*commands)[1], "sh install.sh")
+	assert.Contains(t, (*commands)[2], "systemctl start app")
+	assert.Equal(t, map[string]int64{"execute hook: preinstall": 0, "execute hook: postinstall": 3}, hookTraces(tracer))
+}
+
+func TestInstallStopsWhenPreInstallHookFails(t *testing.T) {
+	inst, mockFileSys, commands := newHooksInstaller(&contracts.PluginResult{Status: contracts.ResultStatusFailed, Code: 1, StandardError: "app is busy"})
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	output := inst.Install(tracer, contextMock)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, 1, output.GetExitCode())
+	assert.Contains(t, output.GetStderr(), "preinstall hook exited with 1, expected [0]")
+	assert.Contains(t, output.GetStderr(), "app is busy")
+	assert.Len(t, *commands, 1)
+	mockFileSys.AssertExpectations(t)
+}
+
+func TestInstallFailsWhenHookTimesOut(t *testing.T) {
+	inst, _, _ := newHooksInstaller(&contracts.PluginResult{Status: contracts.ResultStatusTimedOut})
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	output := inst.Install(tracer, contextMock)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, ActionTimedOutExitCode, output.GetExitCode())
+	assert.Contains(t, output.GetStderr(), "preinstall hook timed out after 30 seconds")
+	assert.Contains(t, output.GetStderr(), "preinstall hook status TimedOut")
+}
+
+func TestUninstallRunsPreUninstallHook(t *testing.T) {
+	success := &contracts.PluginResult{Status: contracts.ResultStatusSuccess}
+	inst, mockFileSys, commands := newHooksInstaller(success, success)
+	mockReadAction(t, mockFileSys, path.Join(testPackagePath, "uninstall"), []byte("echo sh"), []byte{}, false)
+	tracer := trace.NewTracer(log.NewMockLog())
+
+	output := inst.Uninstall(tracer, contextMock)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Len(t, *commands, 2)
+	assert.Contains(t, (*commands)[0], "app --drain")
+	assert.Contains(t, (*commands)[0], "export BWS_ACTION_NAME='preuninstall'")
+	assert.Equal(t, map[string]int64{"execute hook: preuninstall": 0}, hookTraces(tracer))
+}
+
+func TestWriteHooks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, WriteHooks(dir, packageservice.Hooks{}))
+	_, err = os.Stat(filepath.Join(dir, HooksFileName))
+	assert.True(t, os.IsNotExist(err))
+
+	hooks := packageservice.Hooks{PreUninstall: &packageservice.Hook{Commands: []string{"app --drain"}, TimeoutSeconds: 60}}
+	assert.NoError(t, WriteHooks(dir, hooks))
+	inst := Installer{filesysdep: &fileSysDepImp{}, packagePath: dir}
+	read, err := inst.readHooks()
+	assert.NoError(t, err)
+	assert.Equal(t, hooks, read)
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+)
+
+var detectImmutableOS = platform.DetectImmutableOS
+
+// setImmutableOSEnvVars tells the scripts of the package how to install software when the root filesystem of the host
+// is read-only, BWS_INSTALL_MECHANISM is host-container, rpm-ostree or var-bind-mount and empty on other hosts
+func setImmutableOSEnvVars(envVars map[string]string) {
+	immutable, _ := detectImmutableOS()
+	envVars[EnvImmutableOS] = immutable.Name
+	envVars[EnvInstallMechanism] = immutable.Mechanism
+}
+
+// readOnlyFilesystemError returns the error explaining how to run the action of the package on the immutable host
+// when the action failed writing to its read-only root filesystem, nil otherwise.
+func (inst *Installer) readOnlyFilesystemError(actionName string, pluginOut *contracts.PluginResult) error {
+	if pluginOut.Status != contracts.ResultStatusFailed ||
+		!platform.IsReadOnlyFilesystemError(pluginOut.StandardError+pluginOut.StandardOutput+pluginOut.Error) {
+		return nil
+	}
+	immutable, ok := detectImmutableOS()
+	if !ok {
+		return nil
+	}
+	action := fmt.Sprintf("The %v action of %v %v, which writes to the root filesystem,", actionName, inst.packageName, inst.version)
+	return contracts.NewCodedError(contracts.ErrorCodePlatformUnsupported, errors.New(immutable.Guidance(action)))
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubImmutableOS(immutable platform.ImmutableOS, ok bool) func() {
+	detectImmutableOS = func() (platform.ImmutableOS, bool) { return immutable, ok }
+	return func() { detectImmutableOS = platform.DetectImmutableOS }
+}
+
+func TestSetImmutableOSEnvVars(t *testing.T) {
+	defer stubImmutableOS(platform.ImmutableOS{Name: "bottlerocket", Mechanism: platform.ImmutableOSMechanismHostContainer}, true)()
+	envVars := map[string]string{}
+	setImmutableOSEnvVars(envVars)
+	assert.Equal(t, map[string]string{"BWS_IMMUTABLE_OS": "bottlerocket", "BWS_INSTALL_MECHANISM": "host-container"}, envVars)
+
+	stubImmutableOS(platform.ImmutableOS{}, false)
+	setImmutableOSEnvVars(envVars)
+	assert.Equal(t, map[string]string{"BWS_IMMUTABLE_OS": "", "BWS_INSTALL_MECHANISM": ""}, envVars)
+}
+
+func TestReadOnlyFilesystemError(t *testing.T) {
+	inst := &Installer{packageName: "nginx", version: "1.0.0"}
+	readOnly := &contracts.PluginResult{
+		Status:        contracts.ResultStatusFailed,
+		StandardError: "cp: cannot create regular file '/usr/sbin/nginx': Read-only file system",
+	}
+
+	defer stubImmutableOS(platform.ImmutableOS{Name: "fedora-coreos", Mechanism: platform.ImmutableOSMechanismRpmOstree}, true)()
+	err := inst.readOnlyFilesystemError("install", readOnly)
+	assert.Error(t, err)
+	assert.Equal(t, contracts.ErrorCodePlatformUnsupported, contracts.ErrorCodeOf(err))
+	assert.Contains(t, err.Error(), "The install action of nginx 1.0.0")
+	assert.Contains(t, err.Error(), "rpm-ostree install")
+
+	assert.NoError(t, inst.readOnlyFilesystemError("install", &contracts.PluginResult{Status: contracts.ResultStatusFailed, StandardError: "permission denied"}))
+	assert.NoError(t, inst.readOnlyFilesystemError("install", &contracts.PluginResult{Status: contracts.ResultStatusSuccess, StandardError: readOnly.StandardError}))
+
+	stubImmutableOS(platform.ImmutableOS{}, false)
+	assert.NoError(t, inst.readOnlyFilesystemError("install", readOnly))
+}
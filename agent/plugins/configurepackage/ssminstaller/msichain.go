@@ -0,0 +1,183 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+const (
+	// MsiChainExtension is the extension of the action files listing the msis installed or uninstalled in one
+	// Windows Installer transaction, e.g. install.msi.json
+	MsiChainExtension = "msi.json"
+
+	// msiLogDirectory is the directory of the action orchestration directory keeping the log of each msi
+	msiLogDirectory = "msilogs"
+
+	// msiLogTailLines is the number of lines of each msi log copied to the output of the action
+	msiLogTailLines = 100
+)
+
+// MsiChain is the content of an <action>.msi.json file. The msis of an install action are installed in order and
+// the msis of an uninstall action are removed in reverse order, so the same list serves both. Either all of them
+// succeed or the transaction rolls all of them back.
+type MsiChain struct {
+	Msis []ChainedMsi `json:"msis"`
+}
+
+// ChainedMsi is one msi of a chain
+type ChainedMsi struct {
+	// Path is the path of the msi relative to the package directory
+	Path string `json:"path"`
+	// Properties are the public properties passed to the msi, e.g. "ALLUSERS=1 INSTALLDIR=C:\App"
+	Properties string `json:"properties"`
+}
+
+// msiTransactionApi declares the msi.dll functions the action script calls
+var msiTransactionApi = []string{
+	`[DllImport("msi.dll", CharSet = CharSet.Unicode)] public static extern uint MsiBeginTransaction(string name, uint attributes, out uint transaction, out IntPtr changeOfOwnerEvent);`,
+	`[DllImport("msi.dll")] public static extern uint MsiEndTransaction(uint state);`,
+	`[DllImport("msi.dll", CharSet = CharSet.Unicode)] public static extern uint MsiEnableLog(uint logMode, string logFile, uint logAttributes);`,
+	`[DllImport("msi.dll", CharSet = CharSet.Unicode)] public static extern uint MsiInstallProduct(string packagePath, string commandLine);`,
+	`[DllImport("msi.dll")] public static extern int MsiSetInternalUI(int uiLevel, IntPtr window);`,
+}
+
+// readMsiChain reads and validates the msi chain of an action
+func (inst *Installer) readMsiChain(action *Action) (chain MsiChain, err error) {
+	content, err := inst.filesysdep.ReadFile(action.filepath)
+	if err != nil {
+		return chain, fmt.Errorf("failed to read %v: %v", filepath.Base(action.filepath), err)
+	}
+	if err = json.Unmarshal(content, &chain); err != nil {
+		return chain, fmt.Errorf("failed to parse %v: %v", filepath.Base(action.filepath), err)
+	}
+	if len(chain.Msis) == 0 {
+		return chain, fmt.Errorf("%v does not list any msi", filepath.Base(action.filepath))
+	}
+	for _, msi := range chain.Msis {
+		msiPath := filepath.Clean(msi.Path)
+		if msi.Path == "" || filepath.IsAbs(msiPath) || msiPath == ".." || strings.HasPrefix(msiPath, ".."+string(filepath.Separator)) {
+			return chain, fmt.Errorf("msi path %q must be relative to the package directory", msi.Path)
+		}
+		if !strings.EqualFold(filepath.Ext(msiPath), ".msi") {
+			return chain, fmt.Errorf("%q is not an msi", msi.Path)
+		}
+		if !inst.filesysdep.Exists(filepath.Join(inst.packagePath, msiPath)) {
+			return chain, fmt.Errorf("msi %q is not in the package", msi.Path)
+		}
+	}
+	return chain, nil
+}
+
+// readMsiAction turns an msi chain into a PowerShell script installing or removing the msis in one transaction.
+// The log of each msi is written to the orchestration directory and its tail to the output of the action.
+func (inst *Installer) readMsiAction(context context.T, action *Action, workingDir string, orchestrationDir string, envVars map[string]string) (pluginsInfo []contracts.PluginState, err error) {
+	if action.actionType != ACTION_TYPE_MSI {
+		return nil, fmt.Errorf("Internal error")
+	}
+	if action.actionName != packageservice.ActionInstall && action.actionName != packageservice.ActionUninstall {
+		return nil, fmt.Errorf("%v can not be an msi chain, only install and uninstall can", action.actionName)
+	}
+
+	chain, err := inst.readMsiChain(action)
+	if err != nil {
+		return nil, err
+	}
+
+	runCommand := []interface{}{}
+	runCommand = append(runCommand, fmt.Sprintf("echo 'Running %v.%v'", action.actionName, MsiChainExtension))
+
+	for k, v := range envVars {
+		v = executers.QuotePsString(v)
+		runCommand = append(runCommand, fmt.Sprintf("$env:%v = %v", k, v))
+	}
+
+	for _, command := range msiChainCommands(action.actionName == packageservice.ActionUninstall,
+		fmt.Sprintf("%v %v %v", inst.packageName, inst.version, action.actionName),
+		inst.packagePath, filepath.Join(orchestrationDir, msiLogDirectory), chain) {
+		runCommand = append(runCommand, command)
+	}
+
+	return inst.readScriptAction(action, workingDir, orchestrationDir, "runPowerShellScript", runCommand)
+}
+
+// msiChainCommands returns the PowerShell commands running the msis of the chain in one transaction. The first
+// failing msi rolls the transaction back and its exit code is the exit code of the action. Msis requesting a
+// reboot do not reboot, the action exits with the reboot exit code of the agent once the transaction committed.
+func msiChainCommands(uninstall bool, transactionName string, packagePath string, logDir string, chain MsiChain) []string {
+	msis := make([]string, 0, len(chain.Msis))
+	for i, msi := range chain.Msis {
+		commandLine := "REBOOT=ReallySuppress"
+		if uninstall {
+			commandLine += " REMOVE=ALL"
+		}
+		if msi.Properties != "" {
+			commandLine += " " + msi.Properties
+		}
+		logFile := fmt.Sprintf("%02d-%v.log", i+1, strings.TrimSuffix(filepath.Base(msi.Path), filepath.Ext(msi.Path)))
+		msis = append(msis, fmt.Sprintf("@{Path = %v; Log = %v; CommandLine = %v}",
+			quotePsLiteral(filepath.Join(packagePath, filepath.Clean(msi.Path))),
+			quotePsLiteral(filepath.Join(logDir, logFile)),
+			quotePsLiteral(commandLine)))
+	}
+	if uninstall {
+		for i, j := 0, len(msis)-1; i < j; i, j = i+1, j-1 {
+			msis[i], msis[j] = msis[j], msis[i]
+		}
+	}
+
+	commands := []string{"Add-Type -Namespace Ssm -Name Msi -MemberDefinition @'"}
+	commands = append(commands, msiTransactionApi...)
+	commands = append(commands,
+		"'@",
+		fmt.Sprintf("New-Item -ItemType Directory -Force -Path %v | Out-Null", quotePsLiteral(logDir)),
+		"[Ssm.Msi]::MsiSetInternalUI(2, [IntPtr]::Zero) | Out-Null",
+		"$transaction = [uint32]0",
+		"$changeOfOwner = [IntPtr]::Zero",
+		fmt.Sprintf("$code = [Ssm.Msi]::MsiBeginTransaction(%v, 0, [ref]$transaction, [ref]$changeOfOwner)", quotePsLiteral(transactionName)),
+		"if ($code -ne 0) { [Console]::Error.WriteLine(\"Failed to begin the msi transaction, exit code $code\"); exit $code }",
+		"$reboot = $false",
+		fmt.Sprintf("foreach ($msi in @(%v)) {", strings.Join(msis, ", ")),
+		"  [Ssm.Msi]::MsiEnableLog(0x1FFF, $msi.Log, 0) | Out-Null",
+		"  $code = [Ssm.Msi]::MsiInstallProduct($msi.Path, $msi.CommandLine)",
+		"  Write-Output \"$($msi.Path) exited with $code, log $($msi.Log):\"",
+		fmt.Sprintf("  if (Test-Path $msi.Log) { Get-Content -Path $msi.Log -Tail %v }", msiLogTailLines),
+		"  if ($code -eq 3010 -or $code -eq 1641) { $reboot = $true }",
+		"  elseif ($code -ne 0) {",
+		"    [Console]::Error.WriteLine(\"$($msi.Path) failed with exit code $code, rolling back the msi transaction\")",
+		"    [Ssm.Msi]::MsiEndTransaction(0) | Out-Null",
+		"    exit $code",
+		"  }",
+		"}",
+		"$code = [Ssm.Msi]::MsiEndTransaction(1)",
+		"if ($code -eq 3010) { $reboot = $true }",
+		"elseif ($code -ne 0) { [Console]::Error.WriteLine(\"Failed to commit the msi transaction, exit code $code\"); exit $code }",
+		fmt.Sprintf("if ($reboot) { exit %v }", appconfig.RebootExitCode),
+		"exit 0")
+	return commands
+}
+
+// quotePsLiteral quotes a string for PowerShell without expanding the variables and subexpressions in it
+func quotePsLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
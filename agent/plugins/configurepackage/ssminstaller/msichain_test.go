@@ -0,0 +1,123 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testMsiChain = `{"msis": [{"path": "runtime.msi"}, {"path": "app.msi", "properties": "INSTALLDIR='C:\\App'"}]}`
+
+func mockMsiAction(mockFileSys *MockedFileSys, actionName string, content string) {
+	actionPathNoExt := path.Join(testPackagePath, actionName)
+	mockFileSys.On("Exists", actionPathNoExt+".sh").Return(false).Once()
+	mockFileSys.On("Exists", actionPathNoExt+".ps1").Return(false).Once()
+	mockFileSys.On("Exists", actionPathNoExt+".msi.json").Return(true).Once()
+	mockFileSys.On("ReadFile", actionPathNoExt+".msi.json").Return([]byte(content), nil).Once()
+	mockFileSys.On("Exists", mock.Anything).Return(true)
+}
+
+func readMsiActionScript(t *testing.T, actionName string, content string) (string, error) {
+	mockFileSys := MockedFileSys{}
+	mockMsiAction(&mockFileSys, actionName, content)
+
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil).Once()
+
+	tracer := trace.NewTracer(log.NewMockLog())
+	tracer.BeginSection("test segment root")
+
+	inst := Installer{filesysdep: &mockFileSys, packagePath: testPackagePath, packageName: "Pkg", version: "1.0.0", envdetectCollector: mockEnvdetectCollector}
+	exists, pluginsInfo, _, _, err := inst.readAction(tracer, contextMock, actionName, nil)
+	assert.True(t, exists)
+	if err != nil {
+		return "", err
+	}
+	assert.Equal(t, 1, len(pluginsInfo))
+	assert.Equal(t, "aws:runPowerShellScript", pluginsInfo[0].Name)
+	commands := []string{}
+	for _, command := range pluginsInfo[0].Configuration.Properties.(map[string]interface{})["runCommand"].([]interface{}) {
+		commands = append(commands, command.(string))
+	}
+	return strings.Join(commands, "\n"), nil
+}
+
+func TestReadMsiActionInstall(t *testing.T) {
+	script, err := readMsiActionScript(t, "install", testMsiChain)
+
+	assert.NoError(t, err)
+	assert.Contains(t, script, "MsiBeginTransaction('Pkg 1.0.0 install', 0")
+	assert.Contains(t, script, "MsiEndTransaction(0)")
+	assert.Contains(t, script, "MsiEndTransaction(1)")
+	assert.Contains(t, script, "$env:BWS_ACTION_NAME = ")
+	runtime := strings.Index(script, quotePsLiteral(filepath.Join(testPackagePath, "runtime.msi")))
+	app := strings.Index(script, quotePsLiteral(filepath.Join(testPackagePath, "app.msi")))
+	assert.True(t, runtime >= 0 && app > runtime)
+	assert.Contains(t, script, "'REBOOT=ReallySuppress INSTALLDIR=''C:\\App'''")
+	assert.Contains(t, script, quotePsLiteral(filepath.Join("install", msiLogDirectory, "02-app.log")))
+}
+
+func TestReadMsiActionUninstallReversesOrder(t *testing.T) {
+	script, err := readMsiActionScript(t, "uninstall", testMsiChain)
+
+	assert.NoError(t, err)
+	assert.Contains(t, script, "'REBOOT=ReallySuppress REMOVE=ALL'")
+	runtime := strings.Index(script, quotePsLiteral(filepath.Join(testPackagePath, "runtime.msi")))
+	app := strings.Index(script, quotePsLiteral(filepath.Join(testPackagePath, "app.msi")))
+	assert.True(t, app >= 0 && runtime > app)
+}
+
+func TestReadMsiActionInvalid(t *testing.T) {
+	for _, test := range []struct {
+		actionName string
+		content    string
+	}{
+		{"validate", testMsiChain},
+		{"install", `{"msis": []}`},
+		{"install", `{"msis": [{"path": ""}]}`},
+		{"install", `{"msis": [{"path": "../other/app.msi"}]}`},
+		{"install", `{"msis": [{"path": "app.exe"}]}`},
+		{"install", `not json`},
+	} {
+		_, err := readMsiActionScript(t, test.actionName, test.content)
+		assert.Error(t, err, fmt.Sprintf("%v %v", test.actionName, test.content))
+	}
+}
+
+func TestReadMsiChainMissingMsi(t *testing.T) {
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("ReadFile", "install.msi.json").Return([]byte(testMsiChain), nil).Once()
+	mockFileSys.On("Exists", filepath.Join(testPackagePath, "runtime.msi")).Return(true).Once()
+	mockFileSys.On("Exists", filepath.Join(testPackagePath, "app.msi")).Return(false).Once()
+
+	inst := Installer{filesysdep: &mockFileSys, packagePath: testPackagePath}
+	_, err := inst.readMsiChain(&Action{actionName: "install", filepath: "install.msi.json", actionType: ACTION_TYPE_MSI})
+
+	assert.Error(t, err)
+	mockFileSys.AssertExpectations(t)
+}
+
+func TestQuotePsLiteral(t *testing.T) {
+	assert.Equal(t, "'a $b ''c'''", quotePsLiteral("a $b 'c'"))
+}
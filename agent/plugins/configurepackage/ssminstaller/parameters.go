@@ -0,0 +1,65 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+// EnvInstallParameterPrefix prefixes the environment variables of the install parameters, parameter serviceName
+// is set as BWS_PARAM_SERVICE_NAME
+const EnvInstallParameterPrefix = "BWS_PARAM_"
+
+// documentInstallParametersInput is the parameter of the configurePackage plugin overriding the install parameters
+// of the packages
+type documentInstallParametersInput struct {
+	InstallParameters map[string]string `json:"installParameters"`
+}
+
+// installParameters returns the install parameters the manifest declares for the platform of the instance
+// overridden by the install parameters of the document
+func (inst *Installer) installParameters(hooks *packageservice.Hooks) map[string]string {
+	var manifestParameters map[string]string
+	if hooks != nil {
+		manifestParameters = hooks.InstallParameters
+	}
+	var input documentInstallParametersInput
+	if err := jsonutil.Remarshal(inst.config.Properties, &input); err != nil {
+		return manifestParameters
+	}
+	return packageservice.MergeInstallParameters(manifestParameters, input.InstallParameters)
+}
+
+// installParameterEnvName returns the environment variable of an install parameter, the words of a camel case
+// name are separated by underscores, an acronym such as HTTP in HTTPPort is a word
+func installParameterEnvName(name string) string {
+	var envName strings.Builder
+	envName.WriteString(EnvInstallParameterPrefix)
+	runes := []rune(name)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			endsAcronym := unicode.IsUpper(previous) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || endsAcronym {
+				envName.WriteRune('_')
+			}
+		}
+		envName.WriteRune(unicode.ToUpper(r))
+	}
+	return envName.String()
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"path"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInstallParameterEnvName(t *testing.T) {
+	assert.Equal(t, "BWS_PARAM_SERVICE_NAME", installParameterEnvName("serviceName"))
+	assert.Equal(t, "BWS_PARAM_INSTALL_PATH", installParameterEnvName("installPath"))
+	assert.Equal(t, "BWS_PARAM_FLAGS", installParameterEnvName("flags"))
+	assert.Equal(t, "BWS_PARAM_LOG_LEVEL", installParameterEnvName("log_level"))
+	assert.Equal(t, "BWS_PARAM_HTTP_PORT", installParameterEnvName("HTTPPort"))
+	assert.Equal(t, "BWS_PARAM_IPV6_ONLY", installParameterEnvName("ipv6Only"))
+}
+
+func TestInstallParametersDocumentOverridesManifest(t *testing.T) {
+	inst := Installer{config: contracts.Configuration{Properties: map[string]interface{}{
+		"name":              "app",
+		"installParameters": map[string]interface{}{"flags": "--verbose"},
+	}}}
+
+	parameters := inst.installParameters(&packageservice.Hooks{InstallParameters: map[string]string{"serviceName": "app", "flags": "--quiet"}})
+
+	assert.Equal(t, map[string]string{"serviceName": "app", "flags": "--verbose"}, parameters)
+	assert.Nil(t, (&Installer{}).installParameters(nil))
+}
+
+func TestInstallPassesInstallParameters(t *testing.T) {
+	mockFileSys := &MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(true).Once()
+	mockFileSys.On("ReadFile", path.Join(testPackagePath, HooksFileName)).
+		Return([]byte(`{"installParameters": {"serviceName": "app", "installPath": "/opt/app"}}`), nil).Once()
+	mockReadAction(t, mockFileSys, path.Join(testPackagePath, "install"), []byte("echo sh"), []byte{}, false)
+	var commands []interface{}
+	mockExec := &MockedExec{}
+	mockExec.On("ExecuteDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			properties := args.Get(1).([]contracts.PluginState)[0].Configuration.Properties.(map[string]interface{})
+			commands = properties["runCommand"].([]interface{})
+		}).Return(map[string]*contracts.PluginResult{"Foo": {Status: contracts.ResultStatusSuccess}}).Once()
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil)
+	inst := Installer{filesysdep: mockFileSys, execdep: mockExec, packagePath: testPackagePath, envdetectCollector: mockEnvdetectCollector,
+		config: contracts.Configuration{Properties: map[string]interface{}{"installParameters": map[string]interface{}{"flags": "--no-start"}}}}
+
+	output := inst.Install(trace.NewTracer(log.NewMockLog()), contextMock)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Contains(t, commands, "export BWS_PARAM_SERVICE_NAME='app'")
+	assert.Contains(t, commands, "export BWS_PARAM_INSTALL_PATH='/opt/app'")
+	assert.Contains(t, commands, "export BWS_PARAM_FLAGS='--no-start'")
+	mockFileSys.AssertExpectations(t)
+}
@@ -0,0 +1,191 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+)
+
+const (
+	ProbeTypeHttp   = "http"
+	ProbeTypeTcp    = "tcp"
+	ProbeTypeScript = "script"
+
+	// defaultProbeTimeoutSeconds bounds a probe that declares no timeout
+	defaultProbeTimeoutSeconds = 30
+)
+
+// probeRetryInterval is the delay between the attempts of a probe, services may take a moment to start after install
+var probeRetryInterval = 2 * time.Second
+
+// ValidationProbe is a check declared in the validation list of the package manifest, the package is only
+// considered installed once every probe succeeds within its timeout:
+// http probes expect a 200 response from Url, tcp probes a connection to Address (host:port) and
+// script probes a 0 exit code from Script, a .sh or .ps1 file of the package
+type ValidationProbe struct {
+	Type           string `json:"type"`
+	Url            string `json:"url"`
+	Address        string `json:"address"`
+	Script         string `json:"script"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+}
+
+// probeManifest is the part of the package manifest declaring validation probes
+type probeManifest struct {
+	Validation []ValidationProbe `json:"validation"`
+}
+
+// runValidationProbes runs the probes declared by the package manifest and marks the output as failed if one of them fails
+func (inst *Installer) runValidationProbes(tracer trace.Tracer, output contracts.PluginOutputter) {
+	probes, err := inst.readValidationProbes()
+	if err != nil {
+		tracer.BeginSection("read validation probes").WithError(err).End()
+		output.MarkAsFailed(nil, nil)
+		return
+	}
+
+	for _, probe := range probes {
+		probetrace := tracer.BeginSection(fmt.Sprintf("validation probe: %v", probe.describe()))
+		if err := inst.runProbe(probe); err != nil {
+			probetrace.WithError(err).End()
+			output.MarkAsFailed(nil, nil)
+			return
+		}
+		probetrace.End()
+	}
+}
+
+// readValidationProbes reads the probes of the package manifest, a package without manifest has none
+func (inst *Installer) readValidationProbes() ([]ValidationProbe, error) {
+	manifestPath := filepath.Join(inst.packagePath, "manifest.json")
+	if !inst.filesysdep.Exists(manifestPath) {
+		return nil, nil
+	}
+	content, err := inst.filesysdep.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the validation probes: %v", err)
+	}
+	var manifest probeManifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse the validation probes: %v", err)
+	}
+	return manifest.Validation, nil
+}
+
+// runProbe attempts the probe until it succeeds or its timeout expires
+func (inst *Installer) runProbe(probe ValidationProbe) error {
+	timeoutSeconds := probe.TimeoutSeconds
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = defaultProbeTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	for {
+		err := inst.attemptProbe(ctx, probe)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("validation probe %v did not succeed within %v seconds: %v", probe.describe(), timeoutSeconds, err)
+		case <-time.After(probeRetryInterval):
+		}
+	}
+}
+
+// attemptProbe runs the probe once
+func (inst *Installer) attemptProbe(ctx context.Context, probe ValidationProbe) error {
+	switch strings.ToLower(probe.Type) {
+	case ProbeTypeHttp:
+		request, err := http.NewRequest("GET", probe.Url, nil)
+		if err != nil {
+			return err
+		}
+		response, err := http.DefaultClient.Do(request.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("status %v", response.Status)
+		}
+		return nil
+	case ProbeTypeTcp:
+		var dialer net.Dialer
+		connection, err := dialer.DialContext(ctx, "tcp", probe.Address)
+		if err != nil {
+			return err
+		}
+		return connection.Close()
+	case ProbeTypeScript:
+		command, err := inst.probeCommand(ctx, probe.Script)
+		if err != nil {
+			return err
+		}
+		if out, err := command.CombinedOutput(); err != nil {
+			return fmt.Errorf("%v, output: %v", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported validation probe type %q", probe.Type)
+	}
+}
+
+// probeCommand returns the command running a script of the package from the package directory
+func (inst *Installer) probeCommand(ctx context.Context, script string) (*exec.Cmd, error) {
+	if script == "" || filepath.Base(script) != script {
+		return nil, fmt.Errorf("validation script %q must be a file at the root of the package", script)
+	}
+	var command *exec.Cmd
+	switch filepath.Ext(script) {
+	case ".sh":
+		if runtime.GOOS == "windows" {
+			return nil, fmt.Errorf("validation script %q cannot run on windows", script)
+		}
+		command = exec.CommandContext(ctx, "sh", script)
+	case ".ps1":
+		command = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File", script)
+	default:
+		return nil, fmt.Errorf("validation script %q must be a .sh or .ps1 file", script)
+	}
+	command.Dir = fileutil.ShortPath(inst.packagePath)
+	return command, nil
+}
+
+// describe returns the target of the probe for traces
+func (probe ValidationProbe) describe() string {
+	switch strings.ToLower(probe.Type) {
+	case ProbeTypeHttp:
+		return fmt.Sprintf("%v %v", probe.Type, probe.Url)
+	case ProbeTypeTcp:
+		return fmt.Sprintf("%v %v", probe.Type, probe.Address)
+	case ProbeTypeScript:
+		return fmt.Sprintf("%v %v", probe.Type, probe.Script)
+	}
+	return probe.Type
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+// newProbeInstaller returns an installer of a package directory with the manifest
+func newProbeInstaller(t *testing.T, manifest string) (*Installer, func()) {
+	dir, err := ioutil.TempDir("", "probes")
+	assert.NoError(t, err)
+	if manifest != "" {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0600))
+	}
+	probeRetryInterval = 10 * time.Millisecond
+	return &Installer{filesysdep: &fileSysDepImp{}, packagePath: dir}, func() { os.RemoveAll(dir) }
+}
+
+func TestValidateProbes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closedAddress := closed.Addr().String()
+	closed.Close()
+
+	testCases := []struct {
+		name     string
+		manifest string
+		status   contracts.ResultStatus
+	}{
+		{"no manifest", "", contracts.ResultStatusSuccess},
+		{"no probes", `{"name":"pkg"}`, contracts.ResultStatusSuccess},
+		{"http", `{"validation":[{"type":"http","url":"` + server.URL + `/health"}]}`, contracts.ResultStatusSuccess},
+		{"http unhealthy", `{"validation":[{"type":"http","url":"` + server.URL + `/other","timeoutSeconds":1}]}`, contracts.ResultStatusFailed},
+		{"tcp", `{"validation":[{"type":"tcp","address":"` + listener.Addr().String() + `"}]}`, contracts.ResultStatusSuccess},
+		{"tcp refused", `{"validation":[{"type":"tcp","address":"` + closedAddress + `","timeoutSeconds":1}]}`, contracts.ResultStatusFailed},
+		{"one probe fails", `{"validation":[{"type":"http","url":"` + server.URL + `/health"},{"type":"tcp","address":"` + closedAddress + `","timeoutSeconds":1}]}`, contracts.ResultStatusFailed},
+		{"unsupported type", `{"validation":[{"type":"udp","timeoutSeconds":1}]}`, contracts.ResultStatusFailed},
+		{"invalid manifest", `{"validation":`, contracts.ResultStatusFailed},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			inst, cleanup := newProbeInstaller(t, testCase.manifest)
+			defer cleanup()
+
+			output := inst.Validate(trace.NewTracer(log.NewMockLog()), contextMock)
+
+			assert.Equal(t, testCase.status, output.GetStatus())
+		})
+	}
+}
+
+func TestValidateScriptProbe(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh scripts do not run on windows")
+	}
+	testCases := []struct {
+		name   string
+		script string
+		status contracts.ResultStatus
+	}{
+		{"exit 0", "exit 0", contracts.ResultStatusSuccess},
+		{"exit 1", "exit 1", contracts.ResultStatusFailed},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			inst, cleanup := newProbeInstaller(t, `{"validation":[{"type":"script","script":"check.sh","timeoutSeconds":1}]}`)
+			defer cleanup()
+			assert.NoError(t, ioutil.WriteFile(filepath.Join(inst.packagePath, "check.sh"), []byte(testCase.script), 0700))
+
+			output := inst.Validate(trace.NewTracer(log.NewMockLog()), contextMock)
+
+			assert.Equal(t, testCase.status, output.GetStatus())
+		})
+	}
+}
+
+func TestProbeScriptOutsidePackage(t *testing.T) {
+	inst, cleanup := newProbeInstaller(t, "")
+	defer cleanup()
+
+	for _, script := range []string{"", "../check.sh", "/bin/check.sh", "check.exe"} {
+		_, err := inst.probeCommand(context.Background(), script)
+		assert.Error(t, err, script)
+	}
+}
@@ -18,24 +18,30 @@ package ssminstaller
 import (
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/executers"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installlog"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 )
 
+// packageLogsDirectory is the directory of the install log files of the packages, it is replaced by the tests
+var packageLogsDirectory = appconfig.PackageLogsDirectory
+
 type Installer struct {
 	filesysdep         fileSysDep
 	execdep            execDep
 	packageName        string
 	version            string
+	previousVersion    string
 	packagePath        string
 	config             contracts.Configuration // TODO:MF: See if we can use a smaller struct that has just the things we need
 	envdetectCollector envdetect.Collector
@@ -46,6 +52,7 @@ type ActionType uint8
 const (
 	ACTION_TYPE_SH  ActionType = iota
 	ACTION_TYPE_PS1 ActionType = iota
+	ACTION_TYPE_MSI ActionType = iota
 )
 
 type Action struct {
@@ -56,6 +63,7 @@ type Action struct {
 
 func New(packageName string,
 	version string,
+	previousVersion string,
 	packagePath string,
 	configuration contracts.Configuration,
 	envdetectCollector envdetect.Collector) *Installer {
@@ -64,22 +72,30 @@ func New(packageName string,
 		execdep:            &execDepImp{},
 		packageName:        packageName,
 		version:            version,
+		previousVersion:    previousVersion,
 		packagePath:        packagePath,
 		config:             configuration,
 		envdetectCollector: envdetectCollector,
 	}
 }
 
+// Install runs the install action between the preinstall and postinstall hooks of the package
 func (inst *Installer) Install(tracer trace.Tracer, context context.T) contracts.PluginOutputter {
-	return inst.executeAction(tracer, context, "install")
+	return inst.executeActionWithHooks(tracer, context, packageservice.ActionInstall, HookPreInstall, HookPostInstall)
 }
 
+// Uninstall runs the uninstall action after the preuninstall hook of the package
 func (inst *Installer) Uninstall(tracer trace.Tracer, context context.T) contracts.PluginOutputter {
-	return inst.executeAction(tracer, context, "uninstall")
+	return inst.executeActionWithHooks(tracer, context, packageservice.ActionUninstall, HookPreUninstall, "")
 }
 
+// Validate runs the validate action, then the validation probes of the package manifest
 func (inst *Installer) Validate(tracer trace.Tracer, context context.T) contracts.PluginOutputter {
-	return inst.executeAction(tracer, context, "validate")
+	output := inst.executeActionWithHooks(tracer, context, packageservice.ActionValidate, "", "")
+	if output.GetStatus() == contracts.ResultStatusSuccess {
+		inst.runValidationProbes(tracer, output)
+	}
+	return output
 }
 
 func (inst *Installer) Version() string {
@@ -90,21 +106,27 @@ func (inst *Installer) PackageName() string {
 	return inst.packageName
 }
 
-// executeAction will execute the installer scripts if they exist.
-func (inst *Installer) executeAction(tracer trace.Tracer, context context.T, actionName string) contracts.PluginOutputter {
+// executeAction will execute the installer scripts if they exist, within the timeout of the action.
+func (inst *Installer) executeAction(tracer trace.Tracer, context context.T, actionName string, hooks *packageservice.Hooks) contracts.PluginOutputter {
 	exectrace := tracer.BeginSection(fmt.Sprintf("execute action: %s", actionName))
 
 	output := &trace.PluginOutputTrace{Tracer: tracer}
 	output.SetStatus(contracts.ResultStatusSuccess)
 
-	exists, pluginsInfo, _, orchestrationDir, err := inst.readAction(tracer, context, actionName)
+	exists, pluginsInfo, _, orchestrationDir, err := inst.readAction(tracer, context, actionName, inst.installParameters(hooks))
 	if exists {
 		if err != nil {
 			exectrace.WithError(err)
 			output.MarkAsFailed(nil, nil)
 		}
+		timeoutSeconds, err := inst.actionTimeout(actionName, hooks)
+		if err != nil {
+			exectrace.WithError(err)
+			output.MarkAsFailed(nil, nil)
+		}
+		setTimeout(pluginsInfo, timeoutSeconds)
 		exectrace.AppendInfof("Initiating %v %v %v", inst.packageName, inst.version, actionName)
-		inst.executeDocument(tracer, context, actionName, orchestrationDir, pluginsInfo, output)
+		inst.executeDocument(tracer, context, actionName, timeoutSeconds, orchestrationDir, pluginsInfo, output)
 	}
 
 	exectrace.End()
@@ -142,6 +164,7 @@ func (inst *Installer) readScriptAction(action *Action, workingDir string, orche
 		Preconditions:           make(map[string][]string),
 		IsPreconditionEnabled:   false,
 		DefaultWorkingDirectory: workingDir,
+		Elevation:               appconfig.ElevationOperationPackageInstall,
 	}
 
 	var plugin contracts.PluginState
@@ -196,9 +219,11 @@ func (inst *Installer) readPs1Action(context context.T, action *Action, workingD
 func (inst *Installer) resolveAction(tracer trace.Tracer, actionName string) (exists bool, action *Action, err error) {
 	actionPathSh := inst.getActionPath(actionName, "sh")
 	actionPathPs1 := inst.getActionPath(actionName, "ps1")
+	actionPathMsi := inst.getActionPath(actionName, MsiChainExtension)
 
 	actionPathExistsSh := inst.filesysdep.Exists(actionPathSh)
 	actionPathExistsPs1 := inst.filesysdep.Exists(actionPathPs1)
+	actionPathExistsMsi := inst.filesysdep.Exists(actionPathMsi)
 	countExists := 0
 
 	actionTemp := &Action{}
@@ -215,9 +240,15 @@ func (inst *Installer) resolveAction(tracer trace.Tracer, actionName string) (ex
 		actionTemp.actionType = ACTION_TYPE_PS1
 		actionTemp.filepath = actionPathPs1
 	}
+	if actionPathExistsMsi {
+		countExists += 1
+		actionTemp.actionName = actionName
+		actionTemp.actionType = ACTION_TYPE_MSI
+		actionTemp.filepath = actionPathMsi
+	}
 
 	if countExists > 1 {
-		err = fmt.Errorf("%v has more than one implementation (sh, ps1, json)", actionName)
+		err = fmt.Errorf("%v has more than one implementation (sh, ps1, msi.json)", actionName)
 		tracer.CurrentTrace().WithError(err)
 		return true, nil, err
 	} else if countExists == 1 {
@@ -227,42 +258,9 @@ func (inst *Installer) resolveAction(tracer trace.Tracer, actionName string) (ex
 	return false, nil, nil
 }
 
-func (inst *Installer) getEnvVars(actionName string, context context.T) (envVars map[string]string, err error) {
-	log := context.Log()
-
-	envVars = make(map[string]string)
-
-	envVars["BWS_ACTION_NAME"] = actionName
-
-	// Copy proxy settings from the environment
-	envVars["https_proxy"] = os.Getenv("https_proxy")
-	envVars["http_proxy"] = os.Getenv("http_proxy")
-	envVars["no_proxy"] = os.Getenv("no_proxy")
-
-	env, err := inst.envdetectCollector.CollectData(log)
-	if err != nil {
-		return envVars, fmt.Errorf("failed to collect data: %v", err)
-	}
-
-	// (Some of these are already available to script as AWS_SSM_INSTANCE_ID and AWS_SSM_REGION_NAME)
-	envVars["BWS_PLATFORM_NAME"] = env.OperatingSystem.Platform
-	envVars["BWS_PLATFORM_VERSION"] = env.OperatingSystem.PlatformVersion
-	envVars["BWS_PLATFORM_FAMILY"] = env.OperatingSystem.PlatformFamily
-	envVars["BWS_ARCHITECTURE"] = env.OperatingSystem.Architecture
-	envVars["BWS_INIT_SYSTEM"] = env.OperatingSystem.InitSystem
-	envVars["BWS_PACKAGE_MANAGER"] = env.OperatingSystem.PackageManager
-	envVars["BWS_INSTANCE_ID"] = env.Ec2Infrastructure.InstanceID
-	envVars["BWS_INSTANCE_TYPE"] = env.Ec2Infrastructure.InstanceType
-	envVars["BWS_REGION"] = env.Ec2Infrastructure.Region
-	envVars["BWS_ACCOUNT_ID"] = env.Ec2Infrastructure.AccountID
-	envVars["BWS_AVAILABILITY_ZONE"] = env.Ec2Infrastructure.AvailabilityZone
-
-	return envVars, err
-}
-
 // readAction returns a JSON document describing a management action and its working directory, or an empty string
-// if there is nothing to do for a given action
-func (inst *Installer) readAction(tracer trace.Tracer, context context.T, actionName string) (exists bool, pluginsInfo []contracts.PluginState, workingDir string, orchestrationDir string, err error) {
+// if there is nothing to do for a given action. The install parameters are passed to the action in its environment.
+func (inst *Installer) readAction(tracer trace.Tracer, context context.T, actionName string, parameters map[string]string) (exists bool, pluginsInfo []contracts.PluginState, workingDir string, orchestrationDir string, err error) {
 	// TODO: Split into linux and windows
 
 	var action *Action
@@ -278,7 +276,7 @@ func (inst *Installer) readAction(tracer trace.Tracer, context context.T, action
 
 	if action.actionType == ACTION_TYPE_SH {
 		var envVars map[string]string
-		if envVars, err = inst.getEnvVars(actionName, context); err != nil {
+		if envVars, err = inst.getEnvVars(actionName, context, parameters); err != nil {
 			return exists, nil, "", "", err
 		}
 
@@ -289,7 +287,7 @@ func (inst *Installer) readAction(tracer trace.Tracer, context context.T, action
 		return exists, pluginsInfo, workingDir, orchestrationDir, nil
 	} else if action.actionType == ACTION_TYPE_PS1 {
 		var envVars map[string]string
-		if envVars, err = inst.getEnvVars(actionName, context); err != nil {
+		if envVars, err = inst.getEnvVars(actionName, context, parameters); err != nil {
 			return exists, nil, "", "", err
 		}
 
@@ -297,6 +295,17 @@ func (inst *Installer) readAction(tracer trace.Tracer, context context.T, action
 			return exists, nil, "", "", err
 		}
 
+		return exists, pluginsInfo, workingDir, orchestrationDir, nil
+	} else if action.actionType == ACTION_TYPE_MSI {
+		var envVars map[string]string
+		if envVars, err = inst.getEnvVars(actionName, context, parameters); err != nil {
+			return exists, nil, "", "", err
+		}
+
+		if pluginsInfo, err = inst.readMsiAction(context, action, workingDir, orchestrationDir, envVars); err != nil {
+			return exists, nil, "", "", err
+		}
+
 		return exists, pluginsInfo, workingDir, orchestrationDir, nil
 	} else {
 		return exists, nil, "", "", fmt.Errorf("Internal error. Unknown actionType %v", action.actionType)
@@ -308,6 +317,7 @@ func (inst *Installer) executeDocument(
 	tracer trace.Tracer,
 	context context.T,
 	actionName string,
+	timeoutSeconds int,
 	orchestrationDir string,
 	pluginsInfo []contracts.PluginState,
 	output contracts.PluginOutputter) {
@@ -322,7 +332,14 @@ func (inst *Installer) executeDocument(
 	}
 
 	for _, pluginOut := range pluginOutputs {
-		exectrace.WithExitcode(int64(pluginOut.Code))
+		if pluginOut.Status == contracts.ResultStatusTimedOut {
+			// the output the action wrote before it was killed is traced below
+			exectrace.WithExitcode(ActionTimedOutExitCode)
+			exectrace.WithError(timedOutError(actionName, timeoutSeconds))
+			output.SetExitCode(ActionTimedOutExitCode)
+		} else {
+			exectrace.WithExitcode(int64(pluginOut.Code))
+		}
 		exectrace.AppendInfof("Plugin %v ResultStatus %v", pluginOut.PluginName, pluginOut.Status)
 		if pluginOut.StandardOutput != "" {
 			exectrace.AppendInfof("%v output: %v", actionName, pluginOut.StandardOutput)
@@ -334,6 +351,31 @@ func (inst *Installer) executeDocument(
 			exectrace.WithError(errors.New(pluginOut.Error))
 			output.MarkAsFailed(nil, nil)
 		}
+		if err := inst.readOnlyFilesystemError(actionName, pluginOut); err != nil {
+			exectrace.WithError(err)
+		}
 		output.SetStatus(contracts.MergeResultStatus(output.GetStatus(), pluginOut.Status))
+		inst.appendInstallLog(context, actionName, pluginOut)
+	}
+}
+
+// appendInstallLog appends the output of an action to the install log file of the package when it is enabled,
+// a failure to write it is only logged since the output is in the trace too
+func (inst *Installer) appendInstallLog(context context.T, actionName string, pluginOut *contracts.PluginResult) {
+	config := context.AppConfig().Birdwatcher.InstallLogs
+	if !config.Enabled {
+		return
+	}
+	entry := installlog.Entry{
+		Version:  inst.version,
+		Action:   actionName,
+		Status:   string(pluginOut.Status),
+		ExitCode: pluginOut.Code,
+		Stdout:   pluginOut.StandardOutput,
+		Stderr:   pluginOut.StandardError,
+		Error:    pluginOut.Error,
+	}
+	if err := installlog.Append(config, packageLogsDirectory, inst.packageName, entry); err != nil {
+		context.Log().Warnf("Failed to write the %v output of package %v to its install log: %v", actionName, inst.packageName, err)
 	}
 }
@@ -17,9 +17,11 @@ package ssminstaller
 
 import (
 	"io/ioutil"
+	"os"
 	"path"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
@@ -27,6 +29,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/ec2infradetect"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect/osdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/installlog"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -54,6 +57,7 @@ func mockReadAction(t *testing.T, mockFileSys *MockedFileSys, actionPathNoExt st
 	// Setup mock with expectations
 	mockFileSys.On("Exists", actionPathNoExt+".sh").Return(len(contentSh) != 0).Once()
 	mockFileSys.On("Exists", actionPathNoExt+".ps1").Return(len(contentPs1) != 0).Once()
+	mockFileSys.On("Exists", actionPathNoExt+".msi.json").Return(false).Once()
 
 	if expectReads {
 		if len(contentSh) != 0 {
@@ -67,7 +71,9 @@ func mockReadAction(t *testing.T, mockFileSys *MockedFileSys, actionPathNoExt st
 
 var environmentStub = envdetect.Environment{
 	&osdetect.OperatingSystem{"abc", "567", "", "xyz", "", ""},
-	&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ"},
+	&ec2infradetect.Ec2Infrastructure{"instanceIDX", "Reg1", "", "AZ1", "instanceTypeZ", "ec2"},
+	nil,
+	nil,
 }
 
 func testReadAction(t *testing.T, actionPathNoExt string, contentSh []byte, contentPs1 []byte, expectReads bool) {
@@ -84,7 +90,7 @@ func testReadAction(t *testing.T, actionPathNoExt string, contentSh []byte, cont
 	inst := Installer{filesysdep: &mockFileSys, packagePath: testPackagePath, envdetectCollector: mockEnvdetectCollector}
 
 	// Call and validate mock expectations and return value
-	exists, actionDoc, workingDir, orchestrationDir, err := inst.readAction(tracer, contextMock, "Foo")
+	exists, actionDoc, workingDir, orchestrationDir, err := inst.readAction(tracer, contextMock, "Foo", nil)
 	mockFileSys.AssertExpectations(t)
 	assert.True(t, exists)
 	assert.NotEmpty(t, actionDoc)
@@ -107,7 +113,7 @@ func testReadActionInvalid(t *testing.T, actionPathNoExt string, contentSh []byt
 	inst := Installer{filesysdep: &mockFileSys, packagePath: testPackagePath, envdetectCollector: mockEnvdetectCollector}
 
 	// Call and validate mock expectations and return value
-	exists, actionDoc, workingDir, orchestrationDir, err := inst.readAction(tracer, contextMock, "Foo")
+	exists, actionDoc, workingDir, orchestrationDir, err := inst.readAction(tracer, contextMock, "Foo", nil)
 	mockFileSys.AssertExpectations(t)
 	assert.True(t, exists)
 	assert.Empty(t, actionDoc)
@@ -137,7 +143,7 @@ func TestReadActionMissing(t *testing.T) {
 	repo := Installer{filesysdep: &mockFileSys, packagePath: testPackagePath, envdetectCollector: mockEnvdetectCollector}
 
 	// Call and validate mock expectations and return value
-	exists, actionDoc, workingDir, orchestrationDir, err := repo.readAction(tracer, contextMock, "Foo")
+	exists, actionDoc, workingDir, orchestrationDir, err := repo.readAction(tracer, contextMock, "Foo", nil)
 	mockFileSys.AssertExpectations(t)
 	assert.False(t, exists)
 	assert.Empty(t, actionDoc)
@@ -151,6 +157,7 @@ func testReadActionTooManyActionImplementations(t *testing.T, existSh bool, exis
 	actionPathNoExt := path.Join(testPackagePath, "Foo")
 	mockFileSys.On("Exists", actionPathNoExt+".sh").Return(existSh).Once()
 	mockFileSys.On("Exists", actionPathNoExt+".ps1").Return(existPs1).Once()
+	mockFileSys.On("Exists", actionPathNoExt+".msi.json").Return(false).Once()
 
 	mockEnvdetectCollector := &envdetect.CollectorMock{}
 	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil).Once()
@@ -162,7 +169,7 @@ func testReadActionTooManyActionImplementations(t *testing.T, existSh bool, exis
 	repo := Installer{filesysdep: &mockFileSys, packagePath: testPackagePath, envdetectCollector: mockEnvdetectCollector}
 
 	// Call and validate mock expectations and return value
-	exists, actionDoc, workingDir, orchestrationDir, err := repo.readAction(tracer, contextMock, "Foo")
+	exists, actionDoc, workingDir, orchestrationDir, err := repo.readAction(tracer, contextMock, "Foo", nil)
 	mockFileSys.AssertExpectations(t)
 	assert.True(t, exists)
 	assert.Empty(t, actionDoc)
@@ -178,6 +185,7 @@ func TestReadActionTooManyActionImplementations(t *testing.T) {
 func TestInstall_ExecuteError(t *testing.T) {
 	// Setup mocks with expectations
 	mockFileSys := MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(false).Once()
 	actionPathNoExt := path.Join(testPackagePath, "install")
 	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte("echo sh"), []byte{}, false)
 
@@ -204,7 +212,9 @@ func TestValidate_NoAction(t *testing.T) {
 	// Setup mocks with expectations
 	mockFileSys := MockedFileSys{}
 	actionPathNoExt := path.Join(testPackagePath, "validate")
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(false).Once()
 	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte{}, []byte{}, false)
+	mockFileSys.On("Exists", path.Join(testPackagePath, "manifest.json")).Return(false).Once()
 	mockExec := MockedExec{}
 
 	mockEnvdetectCollector := &envdetect.CollectorMock{}
@@ -227,6 +237,7 @@ func TestValidate_NoAction(t *testing.T) {
 func TestUninstall_Success(t *testing.T) {
 	// Setup mocks with expectations
 	mockFileSys := MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(false).Once()
 	actionPathNoExt := path.Join(testPackagePath, "uninstall")
 	mockReadAction(t, &mockFileSys, actionPathNoExt, []byte("echo sh"), []byte{}, false)
 
@@ -254,6 +265,41 @@ func TestUninstall_Success(t *testing.T) {
 	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
 }
 
+func TestUninstall_WritesInstallLog(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "packagelogs")
+	defer os.RemoveAll(dir)
+	defer func(previous string) { packageLogsDirectory = previous }(packageLogsDirectory)
+	packageLogsDirectory = dir
+
+	mockFileSys := MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(false).Once()
+	mockReadAction(t, &mockFileSys, path.Join(testPackagePath, "uninstall"), []byte("echo sh"), []byte{}, false)
+	mockExec := MockedExec{}
+	mockExec.On("ExecuteDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(map[string]*contracts.PluginResult{
+		"Foo": {Status: contracts.ResultStatusFailed, Code: 3, StandardError: "service not found"}}).Once()
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil).Once()
+	config := appconfig.DefaultConfig()
+	config.Birdwatcher.InstallLogs.Enabled = true
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	inst := Installer{filesysdep: &mockFileSys,
+		execdep:            &mockExec,
+		packageName:        "MyPackage",
+		version:            "1.0.0",
+		packagePath:        testPackagePath,
+		envdetectCollector: mockEnvdetectCollector}
+
+	inst.Uninstall(trace.NewTracer(log.NewMockLog()), ctx)
+
+	content, err := ioutil.ReadFile(installlog.Path(dir, "MyPackage"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "MyPackage 1.0.0 uninstall")
+	assert.Contains(t, string(content), "status: Failed, exit code: 3")
+	assert.Contains(t, string(content), "service not found")
+}
+
 // Load specified file from file system
 func loadFile(t *testing.T, fileName string) (result []byte) {
 	var err error
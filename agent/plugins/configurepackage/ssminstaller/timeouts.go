@@ -0,0 +1,70 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"fmt"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+)
+
+// ActionTimedOutExitCode is the exit code reported for an action or a hook whose process tree was killed when
+// it ran past its timeout, it is the exit code of timeout(1)
+const ActionTimedOutExitCode = 124
+
+// documentTimeoutInput is the parameter of the configurePackage plugin bounding each action of the packages
+type documentTimeoutInput struct {
+	ActionTimeoutSeconds int `json:"actionTimeoutSeconds"`
+}
+
+// actionTimeout returns the timeout in seconds of an action, the timeout of the document overrides the timeout
+// the package manifest declares for the action, 0 means the default timeout of the script plugins.
+// The hooks are read from the package when the caller did not read them.
+func (inst *Installer) actionTimeout(actionName string, hooks *packageservice.Hooks) (int, error) {
+	var input documentTimeoutInput
+	if err := jsonutil.Remarshal(inst.config.Properties, &input); err == nil && input.ActionTimeoutSeconds > 0 {
+		return input.ActionTimeoutSeconds, nil
+	}
+	if hooks == nil {
+		read, err := inst.readHooks()
+		if err != nil {
+			return 0, err
+		}
+		hooks = &read
+	}
+	return hooks.ActionTimeouts[actionName], nil
+}
+
+// setTimeout sets the timeout of the script plugins running an action or a hook
+func setTimeout(pluginsInfo []contracts.PluginState, timeoutSeconds int) {
+	if timeoutSeconds <= 0 {
+		return
+	}
+	for _, plugin := range pluginsInfo {
+		if properties, ok := plugin.Configuration.Properties.(map[string]interface{}); ok {
+			properties["timeoutSeconds"] = timeoutSeconds
+		}
+	}
+}
+
+// timedOutError describes an action or a hook killed after its timeout, the output it wrote until then is
+// traced with its other output
+func timedOutError(name string, timeoutSeconds int) error {
+	if timeoutSeconds <= 0 {
+		return fmt.Errorf("%v timed out and was stopped", name)
+	}
+	return fmt.Errorf("%v timed out after %v seconds and was stopped", name, timeoutSeconds)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package ssminstaller
+
+import (
+	"path"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/envdetect"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/packageservice"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/trace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestActionTimeout(t *testing.T) {
+	hooks := &packageservice.Hooks{ActionTimeouts: map[string]int{"install": 600}}
+	testCases := []struct {
+		name       string
+		properties interface{}
+		action     string
+		expected   int
+	}{
+		{"manifest", map[string]interface{}{"name": "pkg"}, "install", 600},
+		{"not bounded", map[string]interface{}{"name": "pkg"}, "validate", 0},
+		{"document overrides manifest", map[string]interface{}{"name": "pkg", "actionTimeoutSeconds": 120}, "install", 120},
+		{"document bounds every action", map[string]interface{}{"actionTimeoutSeconds": 120}, "validate", 120},
+		{"no properties", nil, "install", 600},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			inst := Installer{config: contracts.Configuration{Properties: testCase.properties}}
+
+			timeoutSeconds, err := inst.actionTimeout(testCase.action, hooks)
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.expected, timeoutSeconds)
+		})
+	}
+}
+
+func TestInstallActionTimesOut(t *testing.T) {
+	mockFileSys := &MockedFileSys{}
+	mockFileSys.On("Exists", path.Join(testPackagePath, HooksFileName)).Return(false).Once()
+	mockReadAction(t, mockFileSys, path.Join(testPackagePath, "install"), []byte("echo sh"), []byte{}, false)
+
+	var timeoutSeconds interface{}
+	mockExec := &MockedExec{}
+	mockExec.On("ExecuteDocument", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			timeoutSeconds = args.Get(1).([]contracts.PluginState)[0].Configuration.Properties.(map[string]interface{})["timeoutSeconds"]
+		}).Return(map[string]*contracts.PluginResult{"Foo": {
+		Status:         contracts.ResultStatusTimedOut,
+		Code:           137,
+		StandardOutput: "unpacking files",
+	}}).Once()
+
+	mockEnvdetectCollector := &envdetect.CollectorMock{}
+	mockEnvdetectCollector.On("CollectData", mock.Anything).Return(&environmentStub, nil)
+	tracer := trace.NewTracer(log.NewMockLog())
+	inst := Installer{filesysdep: mockFileSys,
+		execdep:            mockExec,
+		packagePath:        testPackagePath,
+		config:             contracts.Configuration{Properties: map[string]interface{}{"actionTimeoutSeconds": 120}},
+		envdetectCollector: mockEnvdetectCollector}
+
+	output := inst.Install(tracer, contextMock)
+
+	mockFileSys.AssertExpectations(t)
+	assert.Equal(t, 120, timeoutSeconds)
+	assert.Equal(t, contracts.ResultStatusTimedOut, output.GetStatus())
+	assert.Equal(t, ActionTimedOutExitCode, output.GetExitCode())
+	assert.Contains(t, output.GetStderr(), "install timed out after 120 seconds")
+	assert.Contains(t, output.GetStdout(), "install output: unpacking files")
+	for _, trace := range tracer.Traces() {
+		if trace.Operation == "execute action: install" {
+			assert.Equal(t, int64(ActionTimedOutExitCode), trace.Exitcode)
+		}
+	}
+}
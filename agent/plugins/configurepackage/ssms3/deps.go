@@ -15,6 +15,8 @@
 package ssms3
 
 import (
+	"context"
+
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
@@ -24,7 +26,7 @@ import (
 type networkDep interface {
 	ListS3Folders(log log.T, amazonS3URL s3util.AmazonS3URL) (folderNames []string, err error)
 	CanGetS3Object(log log.T, amazonS3URL s3util.AmazonS3URL) bool
-	Download(log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error)
+	Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error)
 }
 
 type networkDepImp struct{}
@@ -39,6 +41,6 @@ func (networkDepImp) CanGetS3Object(log log.T, amazonS3URL s3util.AmazonS3URL) b
 	return artifact.CanGetS3Object(log, amazonS3URL)
 }
 
-func (networkDepImp) Download(log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
-	return artifact.Download(log, input)
+func (networkDepImp) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	return artifact.DownloadWithContext(ctx, log, input)
 }
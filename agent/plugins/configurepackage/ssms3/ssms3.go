@@ -15,6 +15,7 @@
 package ssms3
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -71,6 +72,7 @@ const (
 
 type PackageService struct {
 	packageURL string
+	progress   packageservice.ProgressReporter
 }
 
 // UseSSMS3Service checks for existence of the active service indicator file.  If the file has been removed, it indicates that the new package service should be used
@@ -120,7 +122,7 @@ func (ds *PackageService) GetPackageArnAndVersion(packageName string, packageVer
 }
 
 // DownloadManifest looks up the latest version of a given package for this platform/arch in S3 or manifest at source location
-func (ds *PackageService) DownloadManifest(tracer trace.Tracer, packageName string, version string) (string, string, bool, error) {
+func (ds *PackageService) DownloadManifest(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, string, bool, error) {
 	//TODO: Redesign the DownloadManifest in the packageService to return the manifest, once ssms3 gets deleted
 	var targetVersion string
 	var err error
@@ -145,12 +147,44 @@ func (ds *PackageService) DownloadManifest(tracer trace.Tracer, packageName stri
 	return packageName, targetVersion, isSameAsCache, err
 }
 
-func (ds *PackageService) DownloadArtifact(tracer trace.Tracer, packageName string, version string) (string, error) {
+func (ds *PackageService) DownloadArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (string, error) {
 	s3Location := getS3Location(packageName, version, ds.packageURL)
-	return downloadPackageFromS3(tracer, s3Location)
+	if ds.progress != nil {
+		ds.progress.ReportPhase(fmt.Sprintf("Downloading package %v version %v", packageName, version))
+	}
+	return downloadPackageFromS3(ctx, tracer, s3Location, packageservice.NewDownloadProgressFunc(ds.progress))
+}
+
+// DescribeArtifact returns the location of the package, the ssms3 repository declares no size and no checksum
+func (ds *PackageService) DescribeArtifact(ctx context.Context, tracer trace.Tracer, packageName string, version string) (*packageservice.ArtifactInfo, error) {
+	return &packageservice.ArtifactInfo{FileName: getS3Location(packageName, version, ds.packageURL)}, nil
+}
+
+// DownloadAttachments downloads nothing, the packages of the ssms3 repository are a single file
+func (ds *PackageService) DownloadAttachments(ctx context.Context, tracer trace.Tracer, packageName string, version string, targetDirectory string) error {
+	return nil
+}
+
+// GetDependencies returns no dependencies, the packages of the ssms3 repository are self contained
+func (ds *PackageService) GetDependencies(tracer trace.Tracer, packageArn string, version string) ([]packageservice.Dependency, error) {
+	return nil, nil
+}
+
+// GetHooks returns no hooks, the packages of the ssms3 repository run their own scripts
+func (ds *PackageService) GetHooks(ctx context.Context, tracer trace.Tracer, packageName string, version string) (packageservice.Hooks, error) {
+	return packageservice.Hooks{}, nil
+}
+
+// SetProgressReporter sets the reporter of the artifact downloads
+func (ds *PackageService) SetProgressReporter(reporter packageservice.ProgressReporter) {
+	ds.progress = reporter
+}
+
+// SetChannel does nothing, the ssms3 repository has no rollout channels
+func (ds *PackageService) SetChannel(channel string) {
 }
 
-func (*PackageService) ReportResult(tracer trace.Tracer, result packageservice.PackageResult) error {
+func (*PackageService) ReportResult(ctx context.Context, tracer trace.Tracer, result packageservice.PackageResult) error {
 	// NOP
 	return nil
 }
@@ -158,15 +192,16 @@ func (*PackageService) ReportResult(tracer trace.Tracer, result packageservice.P
 // utils
 
 // downloadPackageFromS3 downloads and uncompresses the installation package from s3 bucket
-func downloadPackageFromS3(tracer trace.Tracer, packageS3Source string) (string, error) {
+func downloadPackageFromS3(ctx context.Context, tracer trace.Tracer, packageS3Source string, progress artifact.ProgressFunc) (string, error) {
 	// TODO: deduplicate with birdwatcher download
 	downloadInput := artifact.DownloadInput{
 		SourceURL: packageS3Source,
+		Progress:  progress,
 	}
 
 	logger := tracer.CurrentTrace().Logger
 
-	downloadOutput, downloadErr := networkdep.Download(logger, downloadInput)
+	downloadOutput, downloadErr := networkdep.Download(ctx, logger, downloadInput)
 	if downloadErr != nil || downloadOutput.LocalFilePath == "" {
 		errMessage := fmt.Sprintf("failed to download installation package reliably, %v", downloadInput.SourceURL)
 		if downloadErr != nil {
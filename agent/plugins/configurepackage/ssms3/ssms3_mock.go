@@ -15,6 +15,8 @@
 package ssms3
 
 import (
+	"context"
+
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
@@ -36,7 +38,7 @@ func (ds *SSMS3Mock) CanGetS3Object(log log.T, amazonS3URL s3util.AmazonS3URL) b
 	return args.Bool(0)
 }
 
-func (ds *SSMS3Mock) Download(log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
-	args := ds.Called(log, input)
+func (ds *SSMS3Mock) Download(ctx context.Context, log log.T, input artifact.DownloadInput) (artifact.DownloadOutput, error) {
+	args := ds.Called(ctx, log, input)
 	return args.Get(0).(artifact.DownloadOutput), args.Error(1)
 }
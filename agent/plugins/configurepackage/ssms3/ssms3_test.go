@@ -15,6 +15,7 @@
 package ssms3
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"runtime"
@@ -132,7 +133,7 @@ func TestSuccessfulDownloadManifest(t *testing.T) {
 	tracer.BeginSection("test segment root")
 
 	ds := &PackageService{packageURL: "https://abc.s3.mock-region.amazonaws.com/"}
-	packageArn, result, isSameAsCache, err := ds.DownloadManifest(tracer, "packageName", "1234")
+	packageArn, result, isSameAsCache, err := ds.DownloadManifest(context.Background(), tracer, "packageName", "1234")
 
 	assert.Equal(t, "packageName", packageArn)
 	assert.Equal(t, "1234", result)
@@ -150,7 +151,7 @@ func TestDownloadManifestWithLatest(t *testing.T) {
 	networkdep = mockObj
 
 	ds := &PackageService{packageURL: "https://abc.s3.mock-region.amazonaws.com/"}
-	packageArn, result, isSameAsCache, err := ds.DownloadManifest(tracer, "packageName", "latest")
+	packageArn, result, isSameAsCache, err := ds.DownloadManifest(context.Background(), tracer, "packageName", "latest")
 
 	assert.Equal(t, "packageName", packageArn)
 	assert.Equal(t, "2.0.0", result)
@@ -168,7 +169,7 @@ func TestDownloadManifestWithError(t *testing.T) {
 	networkdep = mockObj
 
 	ds := &PackageService{packageURL: "https://abc.s3.mock-region.amazonaws.com/"}
-	_, _, _, err := ds.DownloadManifest(tracer, "packageName", "latest")
+	_, _, _, err := ds.DownloadManifest(context.Background(), tracer, "packageName", "latest")
 
 	assert.Error(t, err)
 }
@@ -178,12 +179,12 @@ func TestSuccessfulDownloadArtifact(t *testing.T) {
 	tracer.BeginSection("test segment root")
 
 	mockObj := new(SSMS3Mock)
-	mockObj.On("Download", mock.Anything, mock.Anything).Return(artifact.DownloadOutput{"somePath", false, true}, nil)
+	mockObj.On("Download", mock.Anything, mock.Anything, mock.Anything).Return(artifact.DownloadOutput{LocalFilePath: "somePath", IsUpdated: false, IsHashMatched: true}, nil)
 
 	networkdep = mockObj
 
 	ds := &PackageService{packageURL: "https://abc.s3.mock-region.amazonaws.com/"}
-	result, err := ds.DownloadArtifact(tracer, "packageName", "1234")
+	result, err := ds.DownloadArtifact(context.Background(), tracer, "packageName", "1234")
 
 	assert.Equal(t, "somePath", result)
 	assert.NoError(t, err)
@@ -194,12 +195,12 @@ func TestDownloadArtifactWithError(t *testing.T) {
 	tracer.BeginSection("test segment root")
 
 	mockObj := new(SSMS3Mock)
-	mockObj.On("Download", mock.Anything, mock.Anything).Return(artifact.DownloadOutput{"somePath", false, true}, errors.New("testerror"))
+	mockObj.On("Download", mock.Anything, mock.Anything, mock.Anything).Return(artifact.DownloadOutput{LocalFilePath: "somePath", IsUpdated: false, IsHashMatched: true}, errors.New("testerror"))
 
 	networkdep = mockObj
 
 	ds := &PackageService{packageURL: "https://abc.s3.mock-region.amazonaws.com/"}
-	_, err := ds.DownloadArtifact(tracer, "packageName", "1234")
+	_, err := ds.DownloadArtifact(context.Background(), tracer, "packageName", "1234")
 
 	assert.Error(t, err)
 }
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 )
@@ -27,8 +28,16 @@ import (
 // NanoTime is helper interface for mocking time
 type NanoTime interface {
 	NowUnixNano() int64
+	// MonotonicNano returns the nanoseconds elapsed on the monotonic clock since it was anchored to the wall clock
+	MonotonicNano() int64
+	// AnchorUnixNano returns the wall clock time the monotonic clock was anchored at, the monotonic times of
+	// different processes are not comparable and have different anchors
+	AnchorUnixNano() int64
 }
 
+// clockAnchor is the wall clock time the monotonic clock of the process is measured from
+var clockAnchor = time.Now()
+
 type TimeImpl struct {
 }
 
@@ -36,17 +45,33 @@ func (t *TimeImpl) NowUnixNano() int64 {
 	return time.Now().UnixNano()
 }
 
+func (t *TimeImpl) MonotonicNano() int64 {
+	return int64(time.Since(clockAnchor))
+}
+
+func (t *TimeImpl) AnchorUnixNano() int64 {
+	return clockAnchor.UnixNano()
+}
+
 type Trace struct {
 	Tracer Tracer `json:"-"`
 	Logger log.T  `json:"-"`
 
 	Operation string
 	// results
-	Exitcode int64
-	Error    string `json:",omitempty"`
-	// timing
-	Start int64
-	Stop  int64 `json:",omitempty"`
+	Exitcode  int64
+	Error     string              `json:",omitempty"`
+	ErrorCode contracts.ErrorCode `json:",omitempty"`
+	// timing, Start and Stop are wall clock times in nanoseconds since the epoch and
+	// Duration is measured with the monotonic clock, which is not affected by clock changes
+	Start    int64
+	Stop     int64         `json:",omitempty"`
+	Duration time.Duration `json:",omitempty"`
+	// MonotonicStart and MonotonicStop are the times of the monotonic clock anchored at Anchor, 0 for traces
+	// not timed by a tracer
+	Anchor         int64 `json:",omitempty"`
+	MonotonicStart int64 `json:",omitempty"`
+	MonotonicStop  int64 `json:",omitempty"`
 	// output
 	InfoOut  bytes.Buffer `json:"-"`
 	ErrorOut bytes.Buffer `json:"-"`
@@ -63,6 +88,11 @@ type Tracer interface {
 	CurrentTrace() *Trace
 
 	ToPluginOutput() iohandler.IOHandler
+
+	AddExporter(exporter Exporter)
+	Export(operation Operation)
+
+	SetLogger(logger log.T)
 }
 
 // TracerImpl implements the Tracer interface for collecting traces
@@ -70,6 +100,7 @@ type TracerImpl struct {
 	timeProvider NanoTime
 	traces       []*Trace
 	tracestack   []*Trace
+	exporters    []Exporter
 	logger       log.T
 }
 
@@ -85,10 +116,12 @@ func (t *TracerImpl) BeginSection(message string) *Trace {
 	t.logger.Debugf("starting with %s", message)
 
 	trace := &Trace{
-		Tracer:    t,
-		Logger:    t.logger,
-		Operation: message,
-		Start:     t.timeProvider.NowUnixNano(),
+		Tracer:         t,
+		Logger:         t.logger,
+		Operation:      message,
+		Start:          t.timeProvider.NowUnixNano(),
+		Anchor:         t.timeProvider.AnchorUnixNano(),
+		MonotonicStart: t.timeProvider.MonotonicNano(),
 	}
 	t.tracestack = append(t.tracestack, trace)
 
@@ -127,7 +160,7 @@ func (t *TracerImpl) EndSection(trace *Trace) error {
 
 	logTraceDone(t.logger, trace)
 
-	trace.Stop = t.timeProvider.NowUnixNano()
+	t.stop(trace)
 
 	l := len(t.tracestack)
 	for t.tracestack[l-1] != trace {
@@ -136,7 +169,7 @@ func (t *TracerImpl) EndSection(trace *Trace) error {
 		l = len(t.tracestack)
 
 		// Trace not closed correctly - closing now
-		x.Stop = t.timeProvider.NowUnixNano()
+		t.stop(x)
 		t.logger.Tracef("closing skipped trace: %s", x.Operation)
 		t.traces = append(t.traces, x)
 	}
@@ -150,12 +183,25 @@ func (t *TracerImpl) EndSection(trace *Trace) error {
 	return nil
 }
 
+// stop sets the stop times of the trace and its duration measured with the monotonic clock, the duration of traces
+// not begun by the tracer is 0
+func (t *TracerImpl) stop(trace *Trace) {
+	trace.Stop = t.timeProvider.NowUnixNano()
+	if trace.Anchor == 0 || trace.Anchor != t.timeProvider.AnchorUnixNano() {
+		return
+	}
+	trace.MonotonicStop = t.timeProvider.MonotonicNano()
+	trace.Duration = time.Duration(trace.MonotonicStop - trace.MonotonicStart)
+}
+
 // AddTrace takes a one time trace without tracking a duration
 func (t *TracerImpl) AddTrace(trace *Trace) {
 	logTraceDone(t.logger, trace)
 
 	if trace.Start == 0 {
 		trace.Start = t.timeProvider.NowUnixNano()
+		trace.Anchor = t.timeProvider.AnchorUnixNano()
+		trace.MonotonicStart = t.timeProvider.MonotonicNano()
 	}
 
 	t.traces = append(t.traces, trace)
@@ -189,11 +235,40 @@ func (t *TracerImpl) CurrentTrace() *Trace {
 // ToPluginOutput will convert info and error output into a IOHandler struct
 // It will sort the output by trace end time
 func (t *TracerImpl) ToPluginOutput() iohandler.IOHandler {
+	return TracesToPluginOutput(t.Traces())
+}
+
+// AddExporter registers an exporter the traces are passed to by Export
+func (t *TracerImpl) AddExporter(exporter Exporter) {
+	t.exporters = append(t.exporters, exporter)
+}
+
+// Export passes the closed traces of the operation to the registered exporters,
+// a failing exporter is logged and does not affect the operation
+func (t *TracerImpl) Export(operation Operation) {
+	for _, exporter := range t.exporters {
+		if err := exporter.Export(operation, t.traces); err != nil {
+			t.logger.Warnf("Failed to export the traces of %v %v: %v", operation.Action, operation.PackageName, err)
+		}
+	}
+}
+
+// SetLogger replaces the logger of the tracer and of its open traces,
+// for example with a logger whose context names the package
+func (t *TracerImpl) SetLogger(logger log.T) {
+	t.logger = logger
+	for _, trace := range t.tracestack {
+		trace.Logger = logger
+	}
+}
+
+// TracesToPluginOutput converts the info and error output of the traces into a IOHandler struct
+func TracesToPluginOutput(traces []*Trace) iohandler.IOHandler {
 	var out iohandler.DefaultIOHandler
 	var infoOut bytes.Buffer
 	var errorOut bytes.Buffer
 
-	for _, trace := range t.Traces() {
+	for _, trace := range traces {
 		infoOut.Write(trace.InfoOut.Bytes())
 		errorOut.Write(trace.ErrorOut.Bytes())
 		if trace.Error != "" {
@@ -221,8 +296,10 @@ func (t *Trace) WithError(err error) *Trace {
 	t.Logger.Error(err)
 	if err != nil {
 		t.Error = err.Error()
+		t.ErrorCode = contracts.ErrorCodeOf(err)
 	} else {
 		t.Error = ""
+		t.ErrorCode = ""
 	}
 	return t
 }
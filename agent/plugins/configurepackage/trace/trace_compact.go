@@ -0,0 +1,102 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Limits bound the traces of a package install written to the plugin output and reported to the service
+type Limits struct {
+	// CollapseRepeated replaces consecutive identical sections by the first one with a repeat count
+	CollapseRepeated bool
+	// MaxSectionBytes truncates the info output of a section above this size, 0 keeps the whole output
+	MaxSectionBytes int
+}
+
+// Compact returns copies of the traces within the limits, the traces are not changed.
+// Sections with an error, an exit code or error output are neither collapsed nor truncated.
+func Compact(traces []*Trace, limits Limits) []*Trace {
+	var compacted []*Trace
+	var repeats []int
+	var previous *Trace
+	for _, trace := range traces {
+		if limits.CollapseRepeated && previous != nil && isRepeat(previous, trace) {
+			compacted[len(compacted)-1].Stop = trace.Stop
+			compacted[len(compacted)-1].Duration += trace.Duration
+			repeats[len(repeats)-1]++
+			continue
+		}
+		compacted = append(compacted, copyTrace(trace))
+		repeats = append(repeats, 1)
+		previous = trace
+	}
+
+	for i, trace := range compacted {
+		if repeats[i] > 1 {
+			trace.Operation = fmt.Sprintf("%v (repeated %v times)", trace.Operation, repeats[i])
+		}
+		if limits.MaxSectionBytes > 0 && !hasFailed(trace) && trace.InfoOut.Len() > limits.MaxSectionBytes {
+			truncateInfo(trace, limits.MaxSectionBytes)
+		}
+	}
+	return compacted
+}
+
+// hasFailed returns true if the section reports an error in any way
+func hasFailed(trace *Trace) bool {
+	return trace.Error != "" || trace.Exitcode != 0 || trace.ErrorOut.Len() > 0
+}
+
+// isRepeat returns true if both sections succeeded with the same operation and output
+func isRepeat(previous *Trace, trace *Trace) bool {
+	return !hasFailed(previous) && !hasFailed(trace) &&
+		previous.Operation == trace.Operation &&
+		bytes.Equal(previous.InfoOut.Bytes(), trace.InfoOut.Bytes())
+}
+
+// copyTrace returns a copy of the trace with its own output buffers
+func copyTrace(trace *Trace) *Trace {
+	copied := &Trace{
+		Tracer:    trace.Tracer,
+		Logger:    trace.Logger,
+		Operation: trace.Operation,
+		Exitcode:  trace.Exitcode,
+		Error:     trace.Error,
+		ErrorCode: trace.ErrorCode,
+		Start:     trace.Start,
+		Stop:      trace.Stop,
+		Duration:  trace.Duration,
+	}
+	copied.InfoOut.Write(trace.InfoOut.Bytes())
+	copied.ErrorOut.Write(trace.ErrorOut.Bytes())
+	return copied
+}
+
+// truncateInfo keeps the first bytes of the info output, without splitting a character
+func truncateInfo(trace *Trace, maxBytes int) {
+	info := trace.InfoOut.Bytes()
+	end := maxBytes
+	for end > 0 && !utf8.RuneStart(info[end]) {
+		end--
+	}
+	truncated := len(info) - end
+	trace.InfoOut.Truncate(end)
+	if end > 0 && info[end-1] != '\n' {
+		trace.InfoOut.WriteString("\n")
+	}
+	trace.InfoOut.WriteString(fmt.Sprintf("... %v bytes of output truncated\n", truncated))
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sectionWithInfo(tracer Tracer, operation string, info string) *Trace {
+	trace := tracer.BeginSection(operation)
+	if info != "" {
+		trace.AppendInfo(info)
+	}
+	trace.End()
+	return trace
+}
+
+func TestCompactCollapsesRepeatedSections(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	for i := 0; i < 3; i++ {
+		sectionWithInfo(tracer, "poll service", "waiting")
+	}
+	sectionWithInfo(tracer, "poll service", "ready")
+	sectionWithInfo(tracer, "install", "")
+
+	compacted := Compact(tracer.Traces(), Limits{CollapseRepeated: true})
+
+	assert.Len(t, compacted, 3)
+	assert.Equal(t, "poll service (repeated 3 times)", compacted[0].Operation)
+	assert.Equal(t, tracer.Traces()[0].Start, compacted[0].Start)
+	assert.Equal(t, tracer.Traces()[2].Stop, compacted[0].Stop)
+	assert.Equal(t, "waiting\n", compacted[0].InfoOut.String())
+	assert.Equal(t, "poll service", compacted[1].Operation)
+	assert.Equal(t, "install", compacted[2].Operation)
+	// the traces of the tracer are unchanged
+	assert.Len(t, tracer.Traces(), 5)
+	assert.Equal(t, "poll service", tracer.Traces()[0].Operation)
+}
+
+func TestCompactKeepsFailedSections(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	for i := 0; i < 2; i++ {
+		trace := tracer.BeginSection("download artifact")
+		trace.AppendInfo(strings.Repeat("a", 20))
+		trace.WithError(errors.New("connection reset")).End()
+	}
+	trace := tracer.BeginSection("run script")
+	trace.AppendInfo(strings.Repeat("b", 20))
+	trace.WithExitcode(1).End()
+
+	compacted := Compact(tracer.Traces(), Limits{CollapseRepeated: true, MaxSectionBytes: 10})
+
+	assert.Len(t, compacted, 3)
+	for i, trace := range compacted {
+		assert.Equal(t, tracer.Traces()[i].Operation, trace.Operation)
+		assert.Equal(t, tracer.Traces()[i].InfoOut.String(), trace.InfoOut.String())
+	}
+}
+
+func TestCompactTruncatesOutput(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	sectionWithInfo(tracer, "install", "0123456789abcdef")
+	sectionWithInfo(tracer, "configure", "short")
+
+	compacted := Compact(tracer.Traces(), Limits{MaxSectionBytes: 10})
+
+	assert.Equal(t, "0123456789\n... 7 bytes of output truncated\n", compacted[0].InfoOut.String())
+	assert.Equal(t, "short\n", compacted[1].InfoOut.String())
+	assert.Equal(t, "0123456789abcdef\n", tracer.Traces()[0].InfoOut.String())
+}
+
+func TestCompactTruncatesWholeCharacters(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	sectionWithInfo(tracer, "install", "abcdéfgh")
+
+	compacted := Compact(tracer.Traces(), Limits{MaxSectionBytes: 5})
+
+	assert.Equal(t, "abcd\n... 6 bytes of output truncated\n", compacted[0].InfoOut.String())
+}
+
+func TestCompactWithoutLimits(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	sectionWithInfo(tracer, "poll service", strings.Repeat("a", 100))
+	sectionWithInfo(tracer, "poll service", strings.Repeat("a", 100))
+
+	compacted := Compact(tracer.Traces(), Limits{})
+
+	assert.Len(t, compacted, 2)
+	assert.Equal(t, tracer.Traces()[1].InfoOut.String(), compacted[1].InfoOut.String())
+}
+
+func TestTracesToPluginOutput(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	sectionWithInfo(tracer, "install", "installed")
+	tracer.BeginSection("configure").WithError(errors.New("failed")).End()
+
+	out := TracesToPluginOutput(tracer.Traces())
+
+	assert.Equal(t, "installed\n", out.GetStdout())
+	assert.Equal(t, "failed\n", out.GetStderr())
+}
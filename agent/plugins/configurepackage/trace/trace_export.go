@@ -0,0 +1,164 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+)
+
+// Operation identifies the package operation exported traces belong to
+type Operation struct {
+	PackageName string
+	Version     string
+	Action      string
+	Status      string
+}
+
+// Exporter emits the traces of a completed package operation somewhere other than the agent logs
+type Exporter interface {
+	Export(operation Operation, traces []*Trace) error
+}
+
+// SectionRecord is the structured record of a trace section
+type SectionRecord struct {
+	Operation  string `json:"operation"`
+	Start      string `json:"start"`
+	DurationMs int64  `json:"durationMs"`
+	Exitcode   int64  `json:"exitcode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+}
+
+// OperationRecord is the structured record of a package operation and its sections
+type OperationRecord struct {
+	PackageName string          `json:"packageName"`
+	Version     string          `json:"version,omitempty"`
+	Action      string          `json:"action"`
+	Status      string          `json:"status"`
+	Sections    []SectionRecord `json:"sections"`
+}
+
+// OperationMetrics are the timings of a package operation summed over its sections, in milliseconds
+type OperationMetrics struct {
+	DownloadTime  int64
+	InstallTime   int64
+	UninstallTime int64
+	// Failures is 1 when the operation failed, so the sum over a fleet counts the failed operations
+	Failures int64
+}
+
+// NewOperationRecord returns the structured record of the traces of an operation
+func NewOperationRecord(operation Operation, traces []*Trace) OperationRecord {
+	record := OperationRecord{
+		PackageName: operation.PackageName,
+		Version:     operation.Version,
+		Action:      operation.Action,
+		Status:      operation.Status,
+		Sections:    make([]SectionRecord, 0, len(traces)),
+	}
+	for _, trace := range traces {
+		section := SectionRecord{
+			Operation:  trace.Operation,
+			DurationMs: sectionDuration(trace).Nanoseconds() / int64(time.Millisecond),
+			Exitcode:   trace.Exitcode,
+			Error:      trace.Error,
+			ErrorCode:  string(trace.ErrorCode),
+		}
+		if trace.Start != 0 {
+			section.Start = FormatTimestamp(trace.Start)
+		}
+		record.Sections = append(record.Sections, section)
+	}
+	return record
+}
+
+// NewOperationMetrics sums the download, install and uninstall sections of the traces of an operation.
+// The sections of the dependencies installed with the package are not counted twice.
+func NewOperationMetrics(operation Operation, traces []*Trace) OperationMetrics {
+	var metrics OperationMetrics
+	for _, trace := range traces {
+		ms := sectionDuration(trace).Nanoseconds() / int64(time.Millisecond)
+		switch {
+		case strings.HasPrefix(trace.Operation, "download "):
+			metrics.DownloadTime += ms
+		case strings.HasPrefix(trace.Operation, "install dependency "):
+			// contains the install section of the dependency
+		case strings.HasPrefix(trace.Operation, "install "):
+			metrics.InstallTime += ms
+		case strings.HasPrefix(trace.Operation, "uninstall "):
+			metrics.UninstallTime += ms
+		}
+	}
+	if operation.Status == string(contracts.ResultStatusFailed) {
+		metrics.Failures = 1
+	}
+	return metrics
+}
+
+// EmbeddedMetrics returns the metrics of an operation in the CloudWatch embedded metric format,
+// with the package name and the action as dimensions
+func EmbeddedMetrics(namespace string, timestamp time.Time, operation Operation, traces []*Trace) ([]byte, error) {
+	metrics := NewOperationMetrics(operation, traces)
+	type metricDefinition struct {
+		Name string
+		Unit string
+	}
+	type metricDirective struct {
+		Namespace  string
+		Dimensions [][]string
+		Metrics    []metricDefinition
+	}
+	type metadata struct {
+		Timestamp         int64
+		CloudWatchMetrics []metricDirective
+	}
+	document := map[string]interface{}{
+		"_aws": metadata{
+			Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+			CloudWatchMetrics: []metricDirective{{
+				Namespace:  namespace,
+				Dimensions: [][]string{{"PackageName", "Action"}},
+				Metrics: []metricDefinition{
+					{Name: "DownloadTime", Unit: "Milliseconds"},
+					{Name: "InstallTime", Unit: "Milliseconds"},
+					{Name: "UninstallTime", Unit: "Milliseconds"},
+					{Name: "Failures", Unit: "Count"},
+				},
+			}},
+		},
+		"PackageName":   operation.PackageName,
+		"Action":        operation.Action,
+		"Version":       operation.Version,
+		"DownloadTime":  metrics.DownloadTime,
+		"InstallTime":   metrics.InstallTime,
+		"UninstallTime": metrics.UninstallTime,
+		"Failures":      metrics.Failures,
+	}
+	return json.Marshal(document)
+}
+
+// sectionDuration returns the duration of a section, traces loaded after a reboot only have their wall clock times
+func sectionDuration(trace *Trace) time.Duration {
+	if trace.Duration > 0 {
+		return trace.Duration
+	}
+	if trace.Start != 0 && trace.Stop > trace.Start {
+		return time.Duration(trace.Stop - trace.Start)
+	}
+	return 0
+}
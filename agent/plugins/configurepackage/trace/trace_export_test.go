@@ -0,0 +1,103 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/stretchr/testify/assert"
+)
+
+func exportTestTraces() []*Trace {
+	return []*Trace{
+		{Operation: "download manifest", Start: 1000000000, Duration: 100 * time.Millisecond},
+		{Operation: "download artifact", Start: 1100000000, Duration: 2 * time.Second},
+		{Operation: "install dep/1.0 - rollback: false", Duration: 300 * time.Millisecond},
+		{Operation: "install dependency dep 1.0", Duration: 400 * time.Millisecond},
+		{Operation: "install pkg/2.0 - rollback: false", Start: 4000000000, Stop: 5500000000, Exitcode: 1,
+			Error: "install failed", ErrorCode: contracts.ErrorCode("InstallFailed")},
+	}
+}
+
+type exporterStub struct {
+	operations []Operation
+	err        error
+}
+
+func (e *exporterStub) Export(operation Operation, traces []*Trace) error {
+	e.operations = append(e.operations, operation)
+	return e.err
+}
+
+func TestNewOperationRecord(t *testing.T) {
+	operation := Operation{PackageName: "pkg", Version: "2.0", Action: "Install", Status: "Failed"}
+	record := NewOperationRecord(operation, exportTestTraces())
+
+	assert.Equal(t, "pkg", record.PackageName)
+	assert.Equal(t, "Failed", record.Status)
+	assert.Len(t, record.Sections, 5)
+	assert.Equal(t, SectionRecord{Operation: "download artifact", Start: "1970-01-01T00:00:01Z", DurationMs: 2000}, record.Sections[1])
+	assert.Equal(t, SectionRecord{
+		Operation:  "install pkg/2.0 - rollback: false",
+		Start:      "1970-01-01T00:00:04Z",
+		DurationMs: 1500,
+		Exitcode:   1,
+		Error:      "install failed",
+		ErrorCode:  "InstallFailed",
+	}, record.Sections[4])
+}
+
+func TestNewOperationMetrics(t *testing.T) {
+	metrics := NewOperationMetrics(Operation{Status: string(contracts.ResultStatusFailed)}, exportTestTraces())
+	assert.Equal(t, OperationMetrics{DownloadTime: 2100, InstallTime: 1800, Failures: 1}, metrics)
+
+	metrics = NewOperationMetrics(Operation{Status: string(contracts.ResultStatusSuccess)}, exportTestTraces())
+	assert.Equal(t, int64(0), metrics.Failures)
+}
+
+func TestEmbeddedMetrics(t *testing.T) {
+	operation := Operation{PackageName: "pkg", Version: "2.0", Action: "Install", Status: "Success"}
+	data, err := EmbeddedMetrics("Namespace", time.Unix(10, 0), operation, exportTestTraces())
+	assert.NoError(t, err)
+
+	var document map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &document))
+	assert.Equal(t, "pkg", document["PackageName"])
+	assert.Equal(t, float64(2100), document["DownloadTime"])
+	assert.Equal(t, float64(0), document["Failures"])
+	metadata := document["_aws"].(map[string]interface{})
+	assert.Equal(t, float64(10000), metadata["Timestamp"])
+	directive := metadata["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Namespace", directive["Namespace"])
+	assert.Equal(t, []interface{}{[]interface{}{"PackageName", "Action"}}, directive["Dimensions"])
+	assert.Len(t, directive["Metrics"], 4)
+}
+
+func TestTracerExport(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	tracer.BeginSection("test").End()
+	failing := &exporterStub{err: errors.New("unavailable")}
+	exporter := &exporterStub{}
+	tracer.AddExporter(failing)
+	tracer.AddExporter(exporter)
+
+	tracer.Export(Operation{PackageName: "pkg", Action: "Install"})
+
+	assert.Len(t, failing.operations, 1)
+	assert.Equal(t, []Operation{{PackageName: "pkg", Action: "Install"}}, exporter.operations)
+}
@@ -20,9 +20,10 @@ import (
 )
 
 type PluginOutputTrace struct {
-	Tracer   Tracer
-	exitCode int
-	status   contracts.ResultStatus
+	Tracer    Tracer
+	exitCode  int
+	status    contracts.ResultStatus
+	errorCode contracts.ErrorCode
 }
 
 // Getter/Setter
@@ -34,6 +35,20 @@ func (po *PluginOutputTrace) GetStderr() string                 { return po.Trac
 
 func (po *PluginOutputTrace) SetStatus(status contracts.ResultStatus) { po.status = status }
 func (po *PluginOutputTrace) SetExitCode(exitCode int)                { po.exitCode = exitCode }
+func (po *PluginOutputTrace) SetErrorCode(code contracts.ErrorCode)   { po.errorCode = code }
+
+// GetErrorCode returns the error code set on the output or else the code of the first failed trace
+func (po *PluginOutputTrace) GetErrorCode() contracts.ErrorCode {
+	if po.errorCode != "" {
+		return po.errorCode
+	}
+	for _, trace := range po.Tracer.Traces() {
+		if trace.ErrorCode != "" {
+			return trace.ErrorCode
+		}
+	}
+	return ""
+}
 
 // Compatibility functions with Plugin Output
 
@@ -46,6 +61,9 @@ func (po *PluginOutputTrace) MarkAsFailed(log log.T, err error) {
 
 	if err != nil {
 		po.Tracer.CurrentTrace().Error = err.Error()
+		if po.errorCode == "" {
+			po.errorCode = contracts.ErrorCodeOf(err)
+		}
 	}
 }
 
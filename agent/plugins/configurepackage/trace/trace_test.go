@@ -17,7 +17,9 @@ package trace
 import (
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 
 	"github.com/stretchr/testify/assert"
@@ -35,6 +37,16 @@ func (t *TimeMock) NowUnixNano() int64 {
 	return int64(args.Int(0))
 }
 
+func (t *TimeMock) MonotonicNano() int64 {
+	args := t.Called()
+	return int64(args.Int(0))
+}
+
+func (t *TimeMock) AnchorUnixNano() int64 {
+	args := t.Called()
+	return int64(args.Int(0))
+}
+
 func TestSimpleTrace(t *testing.T) {
 	tracer := NewTracer(loggerMock)
 
@@ -71,6 +83,19 @@ func TestDefer(t *testing.T) {
 	assert.True(t, trace.Start < trace.Stop)
 }
 
+func TestErrorCode(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	out := PluginOutputTrace{Tracer: tracer}
+
+	tracer.BeginSection("download").WithError(contracts.NewCodedError(contracts.ErrorCodeChecksumMismatch, errors.New("hash mismatch"))).End()
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, tracer.Traces()[0].ErrorCode)
+	assert.Equal(t, contracts.ErrorCodeChecksumMismatch, out.GetErrorCode())
+
+	tracer.BeginSection("install")
+	out.MarkAsFailed(loggerMock, contracts.NewCodedError(contracts.ErrorCodeScriptFailure, errors.New("install failed")))
+	assert.Equal(t, contracts.ErrorCodeScriptFailure, out.GetErrorCode())
+}
+
 func TestInvalidEndSection(t *testing.T) {
 	tracer := NewTracer(loggerMock)
 	// No start time provided
@@ -162,14 +187,20 @@ func TestCorrectEndTime(t *testing.T) {
 	timemock := &TimeMock{}
 	tracer := &TracerImpl{timeProvider: timemock, logger: loggerMock}
 
+	timemock.On("AnchorUnixNano").Return(1)
 	timemock.On("NowUnixNano").Return(42).Once()
+	timemock.On("MonotonicNano").Return(10).Once()
 	trace := tracer.BeginSection("anothertrace")
 
 	timemock.On("NowUnixNano").Return(142).Once()
+	timemock.On("MonotonicNano").Return(110).Once()
 	trace.End()
 
 	assert.Equal(t, int64(42), tracer.Traces()[0].Start)
 	assert.Equal(t, int64(142), tracer.Traces()[0].Stop)
+	assert.Equal(t, int64(10), tracer.Traces()[0].MonotonicStart)
+	assert.Equal(t, int64(110), tracer.Traces()[0].MonotonicStop)
+	assert.Equal(t, time.Duration(100), tracer.Traces()[0].Duration)
 }
 
 func TestToPluginOutput(t *testing.T) {
@@ -215,3 +246,17 @@ func TestPrependTraces(t *testing.T) {
 	assert.Equal(t, traces[1].Tracer, traces[0].Tracer)
 	assert.Equal(t, traces[1].Logger, traces[0].Logger)
 }
+
+func TestSetLogger(t *testing.T) {
+	tracer := NewTracer(loggerMock)
+	outer := tracer.BeginSection("outer")
+	tracer.BeginSection("closed").End()
+
+	packageLogger := log.NewMockLogWithContext("[packageArn=foo]")
+	tracer.SetLogger(packageLogger)
+	inner := tracer.BeginSection("inner")
+
+	assert.Equal(t, packageLogger, outer.Logger)
+	assert.Equal(t, packageLogger, inner.Logger)
+	assert.Equal(t, loggerMock, tracer.Traces()[0].Logger)
+}
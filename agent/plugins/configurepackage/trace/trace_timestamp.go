@@ -0,0 +1,74 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// TimestampOptions select what the timing summary of the traces includes besides the UTC timestamps
+type TimestampOptions struct {
+	// LocalTime annotates every timestamp with the local time of the instance
+	LocalTime bool
+	// Durations lists the duration of every section, measured with the monotonic clock
+	Durations bool
+}
+
+// FormatTimestamp formats a time in nanoseconds since the epoch as RFC3339 in UTC,
+// the format of all the timestamps of the results and the traces
+func FormatTimestamp(unixNano int64) string {
+	return time.Unix(0, unixNano).UTC().Format(time.RFC3339)
+}
+
+// formatTimestamp formats a timestamp, annotated with the local time when selected
+func (options TimestampOptions) formatTimestamp(unixNano int64) string {
+	timestamp := FormatTimestamp(unixNano)
+	if options.LocalTime {
+		timestamp = fmt.Sprintf("%v (local %v)", timestamp, time.Unix(0, unixNano).Local().Format(time.RFC3339))
+	}
+	return timestamp
+}
+
+// TimingSummary returns when the traces started and ended, and the duration of each section when selected
+func TimingSummary(traces []*Trace, options TimestampOptions) string {
+	var start, stop int64
+	for _, trace := range traces {
+		if trace.Start != 0 && (start == 0 || trace.Start < start) {
+			start = trace.Start
+		}
+		if trace.Stop > stop {
+			stop = trace.Stop
+		}
+	}
+	if start == 0 {
+		return ""
+	}
+
+	var summary bytes.Buffer
+	if stop < start {
+		summary.WriteString(fmt.Sprintf("Started at %v\n", options.formatTimestamp(start)))
+	} else {
+		summary.WriteString(fmt.Sprintf("Started at %v, ended at %v\n", options.formatTimestamp(start), options.formatTimestamp(stop)))
+	}
+	if options.Durations {
+		for _, trace := range traces {
+			if trace.Duration > 0 {
+				summary.WriteString(fmt.Sprintf("%v took %v\n", trace.Operation, trace.Duration))
+			}
+		}
+	}
+	return summary.String()
+}
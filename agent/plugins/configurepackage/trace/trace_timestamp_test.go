@@ -0,0 +1,97 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package trace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 2018-03-01T10:00:00Z
+const testStart = int64(1519898400) * int64(time.Second)
+
+func TestFormatTimestamp(t *testing.T) {
+	assert.Equal(t, "2018-03-01T10:00:00Z", FormatTimestamp(testStart))
+}
+
+func TestTimingSummary(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("test", 2*60*60)
+	defer func() { time.Local = local }()
+	traces := []*Trace{
+		{Operation: "download", Start: testStart, Stop: testStart + int64(2*time.Second), Duration: 2 * time.Second},
+		{Operation: "install", Start: testStart + int64(2*time.Second), Stop: testStart + int64(5*time.Second), Duration: 3 * time.Second},
+		{Operation: "single trace", Start: testStart + int64(time.Second)},
+	}
+
+	assert.Equal(t, "Started at 2018-03-01T10:00:00Z, ended at 2018-03-01T10:00:05Z\n",
+		TimingSummary(traces, TimestampOptions{}))
+	assert.Equal(t, "Started at 2018-03-01T10:00:00Z (local 2018-03-01T12:00:00+02:00), "+
+		"ended at 2018-03-01T10:00:05Z (local 2018-03-01T12:00:05+02:00)\n",
+		TimingSummary(traces, TimestampOptions{LocalTime: true}))
+	assert.Equal(t, "Started at 2018-03-01T10:00:00Z, ended at 2018-03-01T10:00:05Z\ndownload took 2s\ninstall took 3s\n",
+		TimingSummary(traces, TimestampOptions{Durations: true}))
+}
+
+func TestTimingSummaryWithoutTraces(t *testing.T) {
+	assert.Equal(t, "", TimingSummary(nil, TimestampOptions{Durations: true}))
+	assert.Equal(t, "Started at 2018-03-01T10:00:00Z\n", TimingSummary([]*Trace{{Operation: "single trace", Start: testStart}}, TimestampOptions{}))
+}
+
+func TestSectionDurationIsMonotonic(t *testing.T) {
+	timemock := &TimeMock{}
+	// the wall clock goes back while the section runs
+	timemock.On("NowUnixNano").Return(2000).Once()
+	timemock.On("NowUnixNano").Return(1000).Once()
+	timemock.On("AnchorUnixNano").Return(1)
+	timemock.On("MonotonicNano").Return(5000).Once()
+	timemock.On("MonotonicNano").Return(int(5000 + time.Millisecond)).Once()
+	tracer := &TracerImpl{timeProvider: timemock, logger: loggerMock}
+
+	trace := tracer.BeginSection("install")
+	trace.End()
+
+	assert.Equal(t, int64(1000), trace.Stop)
+	assert.Equal(t, time.Millisecond, trace.Duration)
+}
+
+func TestSectionBegunByAnotherProcessHasNoDuration(t *testing.T) {
+	timemock := &TimeMock{}
+	timemock.On("NowUnixNano").Return(3000)
+	timemock.On("AnchorUnixNano").Return(2)
+	tracer := &TracerImpl{timeProvider: timemock, logger: loggerMock}
+	// the section was persisted before a reboot, its monotonic times are from the clock of the previous process
+	trace := &Trace{Tracer: tracer, Logger: loggerMock, Operation: "install", Start: 1000, Anchor: 1, MonotonicStart: 5000}
+	tracer.tracestack = append(tracer.tracestack, trace)
+
+	trace.End()
+
+	assert.Equal(t, int64(3000), trace.Stop)
+	assert.Equal(t, int64(0), trace.MonotonicStop)
+	assert.Equal(t, time.Duration(0), trace.Duration)
+}
+
+func TestCompactSumsDurations(t *testing.T) {
+	traces := []*Trace{
+		{Operation: "poll", Start: 1, Stop: 2, Duration: time.Second},
+		{Operation: "poll", Start: 3, Stop: 4, Duration: 2 * time.Second},
+	}
+
+	compacted := Compact(traces, Limits{CollapseRepeated: true})
+
+	assert.Len(t, compacted, 1)
+	assert.Equal(t, 3*time.Second, compacted[0].Duration)
+}
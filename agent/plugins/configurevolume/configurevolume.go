@@ -0,0 +1,311 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package configurevolume implements the aws:configureVolume plugin, it formats, labels, mounts and persists
+// in fstab a newly attached EBS volume, and never formats a device that holds data.
+package configurevolume
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// FileSystemExt4 formats the volume with ext4
+	FileSystemExt4 = "ext4"
+	// FileSystemXfs formats the volume with xfs
+	FileSystemXfs = "xfs"
+
+	defaultMountOptions = "defaults,nofail"
+)
+
+// maxLabelLength is the longest label of each file system
+var maxLabelLength = map[string]int{
+	FileSystemExt4: 16,
+	FileSystemXfs:  12,
+}
+
+var (
+	volumeIDPattern     = regexp.MustCompile(`^vol-[0-9a-f]{8,17}$`)
+	labelPattern        = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	mountOptionsPattern = regexp.MustCompile(`^[A-Za-z0-9_.,=:/@+-]+$`)
+)
+
+// Plugin is the type for the configureVolume plugin.
+type Plugin struct {
+	// CommandExecuter runs the commands listing, formatting and mounting the block devices.
+	CommandExecuter executers.T
+}
+
+// ConfigureVolumePluginInput represents the volume a step configures and where it is mounted.
+type ConfigureVolumePluginInput struct {
+	contracts.PluginInput
+	ID string
+	// VolumeId finds the NVMe device of the volume, DeviceName finds the device of volumes of Xen instances
+	VolumeId     string
+	DeviceName   string
+	FileSystem   string
+	Label        string
+	MountPoint   string
+	MountOptions string
+	// Persist adds the volume to /etc/fstab, it defaults to true
+	Persist        *bool
+	TimeoutSeconds interface{}
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsConfigureVolume
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput ConfigureVolumePluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	p.run(log, pluginInput, cancelFlag, output)
+}
+
+// validateInput checks the input and sets the defaults of the fields left out
+func validateInput(pluginInput *ConfigureVolumePluginInput) error {
+	if pluginInput.VolumeId == "" && pluginInput.DeviceName == "" {
+		return fmt.Errorf("VolumeId or DeviceName is required")
+	}
+	if pluginInput.VolumeId != "" && !volumeIDPattern.MatchString(pluginInput.VolumeId) {
+		return fmt.Errorf("VolumeId %v is not a valid volume id", pluginInput.VolumeId)
+	}
+	if pluginInput.DeviceName != "" && !strings.HasPrefix(pluginInput.DeviceName, "/dev/") {
+		return fmt.Errorf("DeviceName %v is not a device path", pluginInput.DeviceName)
+	}
+
+	if pluginInput.FileSystem == "" {
+		pluginInput.FileSystem = FileSystemExt4
+	}
+	maxLength, ok := maxLabelLength[pluginInput.FileSystem]
+	if !ok {
+		return fmt.Errorf("FileSystem is set to unsupported value %v, expected %v or %v", pluginInput.FileSystem, FileSystemExt4, FileSystemXfs)
+	}
+	if pluginInput.Label != "" && (!labelPattern.MatchString(pluginInput.Label) || len(pluginInput.Label) > maxLength) {
+		return fmt.Errorf("Label %v is not valid, %v labels have up to %v letters, digits and _ . -", pluginInput.Label, pluginInput.FileSystem, maxLength)
+	}
+
+	if pluginInput.MountPoint == "" {
+		return fmt.Errorf("MountPoint is required")
+	}
+	pluginInput.MountPoint = filepath.Clean(pluginInput.MountPoint)
+	if !filepath.IsAbs(pluginInput.MountPoint) || pluginInput.MountPoint == "/" {
+		return fmt.Errorf("MountPoint %v is not an absolute path other than /", pluginInput.MountPoint)
+	}
+	if pluginInput.MountOptions == "" {
+		pluginInput.MountOptions = defaultMountOptions
+	}
+	if !mountOptionsPattern.MatchString(pluginInput.MountOptions) {
+		return fmt.Errorf("MountOptions %v are not valid", pluginInput.MountOptions)
+	}
+	return nil
+}
+
+func (p *Plugin) run(log log.T, pluginInput ConfigureVolumePluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+	command := func(name string, args ...string) (string, int, error) {
+		return p.command(log, cancelFlag, timeout, name, args...)
+	}
+
+	device, err := findDevice(command, pluginInput.VolumeId, pluginInput.DeviceName)
+	if err != nil {
+		markAsFailed(cancelFlag, output, err)
+		return
+	}
+	changed, err := configure(command, device, pluginInput, output)
+	if err != nil {
+		markAsFailed(cancelFlag, output, err)
+		return
+	}
+	if !changed {
+		output.AppendInfof("%v is already formatted with %v and mounted on %v, no change", device.Name, pluginInput.FileSystem, pluginInput.MountPoint)
+	}
+	output.MarkAsSucceeded()
+}
+
+// configure formats, labels, mounts and persists the device as needed, it refuses to format a device with
+// partitions, a file system or any other signature
+func configure(command commandFunc, device blockDevice, pluginInput ConfigureVolumePluginInput, output iohandler.IOHandler) (changed bool, err error) {
+	if len(device.Children) > 0 {
+		return false, fmt.Errorf("%v has partitions, not formatting it", device.Name)
+	}
+	if device.MountPoint != "" && device.MountPoint != pluginInput.MountPoint {
+		return false, fmt.Errorf("%v is mounted on %v, not on %v", device.Name, device.MountPoint, pluginInput.MountPoint)
+	}
+
+	probed, err := probe(command, device.Name)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case probed["PTTYPE"] != "":
+		return false, fmt.Errorf("%v has a %v partition table, not formatting it", device.Name, probed["PTTYPE"])
+	case probed["TYPE"] == "":
+		args := []string{device.Name}
+		if pluginInput.Label != "" {
+			args = append([]string{"-L", pluginInput.Label}, args...)
+		}
+		if _, _, err = command("mkfs."+pluginInput.FileSystem, args...); err != nil {
+			return false, fmt.Errorf("failed to format %v with %v: %v", device.Name, pluginInput.FileSystem, err)
+		}
+		output.AppendInfof("Formatted %v with %v", device.Name, pluginInput.FileSystem)
+		changed = true
+		if probed, err = probe(command, device.Name); err != nil {
+			return changed, err
+		}
+	case probed["TYPE"] != pluginInput.FileSystem:
+		return false, fmt.Errorf("%v has a %v file system, not formatting it with %v", device.Name, probed["TYPE"], pluginInput.FileSystem)
+	case pluginInput.Label != "" && probed["LABEL"] != pluginInput.Label:
+		if err = relabel(command, device, pluginInput); err != nil {
+			return changed, err
+		}
+		output.AppendInfof("Labeled %v %v", device.Name, pluginInput.Label)
+		changed = true
+	}
+	uuid := probed["UUID"]
+	if uuid == "" {
+		return changed, fmt.Errorf("%v has no file system uuid", device.Name)
+	}
+
+	if device.MountPoint == "" {
+		if err = prepareMountPoint(pluginInput.MountPoint); err != nil {
+			return changed, err
+		}
+		if _, _, err = command("mount", "-t", pluginInput.FileSystem, "-o", pluginInput.MountOptions, device.Name, pluginInput.MountPoint); err != nil {
+			return changed, fmt.Errorf("failed to mount %v on %v: %v", device.Name, pluginInput.MountPoint, err)
+		}
+		output.AppendInfof("Mounted %v on %v", device.Name, pluginInput.MountPoint)
+		changed = true
+	}
+
+	if pluginInput.Persist == nil || *pluginInput.Persist {
+		tag := pluginInput.VolumeId
+		if tag == "" {
+			tag = pluginInput.DeviceName
+		}
+		entry := fstabEntry{spec: "UUID=" + uuid, file: pluginInput.MountPoint, vfsType: pluginInput.FileSystem, options: pluginInput.MountOptions}
+		updated, err := updateFstab(fstabPath, tag, entry, device.Name)
+		if err != nil {
+			return changed, fmt.Errorf("failed to persist the mount of %v in %v: %v", device.Name, fstabPath, err)
+		}
+		if updated {
+			output.AppendInfof("Persisted the mount of %v in %v", device.Name, fstabPath)
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// relabel changes the label of the file system, xfs labels only change while the file system is unmounted
+func relabel(command commandFunc, device blockDevice, pluginInput ConfigureVolumePluginInput) (err error) {
+	if pluginInput.FileSystem == FileSystemXfs {
+		if device.MountPoint != "" {
+			return fmt.Errorf("%v is mounted, unmount it to change its xfs label to %v", device.Name, pluginInput.Label)
+		}
+		_, _, err = command("xfs_admin", "-L", pluginInput.Label, device.Name)
+	} else {
+		_, _, err = command("e2label", device.Name, pluginInput.Label)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to label %v %v: %v", device.Name, pluginInput.Label, err)
+	}
+	return nil
+}
+
+// prepareMountPoint creates the mount point, mounting on a directory with files would hide them
+func prepareMountPoint(mountPoint string) error {
+	if err := os.MkdirAll(mountPoint, 0755); err != nil {
+		return fmt.Errorf("failed to create mount point %v: %v", mountPoint, err)
+	}
+	dir, err := os.Open(mountPoint)
+	if err != nil {
+		return fmt.Errorf("failed to open mount point %v: %v", mountPoint, err)
+	}
+	defer dir.Close()
+	if names, _ := dir.Readdirnames(1); len(names) > 0 {
+		return fmt.Errorf("mount point %v is not empty, not mounting over its files", mountPoint)
+	}
+	return nil
+}
+
+// commandFunc runs a command and returns its output and exit code
+type commandFunc func(name string, args ...string) (string, int, error)
+
+func (p *Plugin) command(log log.T, cancelFlag task.CancelFlag, timeout int, name string, args ...string) (string, int, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := p.CommandExecuter.NewExecute(log, "", &stdout, &stderr, cancelFlag, timeout, name, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return stdout.String(), exitCode, fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), exitCode, nil
+}
+
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
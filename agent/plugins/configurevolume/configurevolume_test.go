@@ -0,0 +1,220 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurevolume
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testVolumeID = "vol-0123456789abcdef0"
+	blankVolume  = `{"blockdevices": [
+		{"name": "/dev/nvme0n1", "serial": "vol0aaaaaaaaaaaaaaaa", "type": "disk", "mountpoint": null,
+			"children": [{"name": "/dev/nvme0n1p1", "serial": null, "type": "part", "mountpoint": "/"}]},
+		{"name": "/dev/nvme1n1", "serial": "vol0123456789abcdef0", "type": "disk", "mountpoint": null}]}`
+	ext4Signature = "DEVNAME=/dev/nvme1n1\nUUID=0b8e2d1c-5a1f-4c3e-9a4e-2f6d8b1c7e90\nTYPE=ext4\nLABEL=data\n"
+)
+
+// setup points fstab to a file of the test and returns a mount point, they are removed by the cleanup
+func setup(t *testing.T, fstab string) (string, func()) {
+	dir, err := ioutil.TempDir("", "configurevolume")
+	assert.NoError(t, err)
+	previous := fstabPath
+	fstabPath = filepath.Join(dir, "fstab")
+	assert.NoError(t, ioutil.WriteFile(fstabPath, []byte(fstab), 0644))
+	return filepath.Join(dir, "data"), func() {
+		fstabPath = previous
+		os.RemoveAll(dir)
+	}
+}
+
+func readFstab(t *testing.T) string {
+	content, err := ioutil.ReadFile(fstabPath)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestValidateInput(t *testing.T) {
+	for _, input := range []ConfigureVolumePluginInput{
+		{MountPoint: "/data"},
+		{VolumeId: "vol-xyz", MountPoint: "/data"},
+		{DeviceName: "sdf", MountPoint: "/data"},
+		{VolumeId: testVolumeID},
+		{VolumeId: testVolumeID, MountPoint: "data"},
+		{VolumeId: testVolumeID, MountPoint: "/data/.."},
+		{VolumeId: testVolumeID, MountPoint: "/data", FileSystem: "ntfs"},
+		{VolumeId: testVolumeID, MountPoint: "/data", FileSystem: FileSystemXfs, Label: "thirteen-char"},
+		{VolumeId: testVolumeID, MountPoint: "/data", Label: "a b"},
+		{VolumeId: testVolumeID, MountPoint: "/data", MountOptions: "defaults 0 0"},
+	} {
+		assert.Error(t, validateInput(&input), "%v", input)
+	}
+
+	input := ConfigureVolumePluginInput{VolumeId: testVolumeID, MountPoint: "/data/"}
+	assert.NoError(t, validateInput(&input))
+	assert.Equal(t, FileSystemExt4, input.FileSystem)
+	assert.Equal(t, "/data", input.MountPoint)
+	assert.Equal(t, defaultMountOptions, input.MountOptions)
+}
+
+func TestFormatAndMountBlankVolume(t *testing.T) {
+	mountPoint, cleanup := setup(t, "UUID=root / xfs defaults 0 0\n")
+	defer cleanup()
+	formatted := false
+	executer, commands := pluginutil.MockCommandsWithExitCode(func(command string, _ []string) (string, int) {
+		switch {
+		case strings.HasPrefix(command, "lsblk"):
+			return blankVolume, 0
+		case strings.HasPrefix(command, "blkid") && !formatted:
+			return "", 2
+		case strings.HasPrefix(command, "blkid"):
+			return ext4Signature, 0
+		case strings.HasPrefix(command, "mkfs"):
+			formatted = true
+		}
+		return "", 0
+	})
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"VolumeId": testVolumeID, "MountPoint": mountPoint, "Label": "data"})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{
+		"lsblk -J -p -o NAME,SERIAL,TYPE,MOUNTPOINT",
+		"blkid -p -o export /dev/nvme1n1",
+		"mkfs.ext4 -L data /dev/nvme1n1",
+		"blkid -p -o export /dev/nvme1n1",
+		"mount -t ext4 -o defaults,nofail /dev/nvme1n1 " + mountPoint,
+	}, *commands)
+	assert.Equal(t, "UUID=root / xfs defaults 0 0\n"+
+		"# aws:configureVolume "+testVolumeID+"\n"+
+		"UUID=0b8e2d1c-5a1f-4c3e-9a4e-2f6d8b1c7e90 "+mountPoint+" ext4 defaults,nofail 0 2\n", readFstab(t))
+	_, err := os.Stat(mountPoint)
+	assert.NoError(t, err)
+}
+
+func TestConfiguredVolumeIsNotChanged(t *testing.T) {
+	mountPoint, cleanup := setup(t, "")
+	defer cleanup()
+	fstab := "# aws:configureVolume " + testVolumeID + "\nUUID=0b8e2d1c-5a1f-4c3e-9a4e-2f6d8b1c7e90 " + mountPoint + " ext4 defaults,nofail 0 2\n"
+	assert.NoError(t, ioutil.WriteFile(fstabPath, []byte(fstab), 0644))
+	executer, commands := pluginutil.MockCommandsWithExitCode(func(command string, _ []string) (string, int) {
+		if strings.HasPrefix(command, "lsblk") {
+			return strings.Replace(blankVolume, `"type": "disk", "mountpoint": null}]}`, `"type": "disk", "mountpoint": "`+mountPoint+`"}]}`, 1), 0
+		}
+		return ext4Signature, 0
+	})
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"VolumeId": testVolumeID, "MountPoint": mountPoint, "Label": "data"})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Contains(t, output.GetStdout(), "no change")
+	assert.Len(t, *commands, 2)
+	assert.Equal(t, fstab, readFstab(t))
+}
+
+func TestVolumesWithDataAreNotFormatted(t *testing.T) {
+	testCases := []struct {
+		name      string
+		devices   string
+		signature string
+		err       string
+	}{
+		{"partitions", strings.Replace(blankVolume, `"mountpoint": null}]}`, `"mountpoint": null, "children": [{"name": "/dev/nvme1n1p1", "type": "part"}]}]}`, 1), "",
+			"/dev/nvme1n1 has partitions, not formatting it"},
+		{"partition table", blankVolume, "PTUUID=1234\nPTTYPE=gpt\n", "/dev/nvme1n1 has a gpt partition table, not formatting it"},
+		{"other file system", blankVolume, "UUID=1234\nTYPE=xfs\n", "/dev/nvme1n1 has a xfs file system, not formatting it with ext4"},
+		{"mounted elsewhere", strings.Replace(blankVolume, `"type": "disk", "mountpoint": null}]}`, `"type": "disk", "mountpoint": "/mnt"}]}`, 1), "",
+			"/dev/nvme1n1 is mounted on /mnt"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			mountPoint, cleanup := setup(t, "")
+			defer cleanup()
+			executer, commands := pluginutil.MockCommandsWithExitCode(func(command string, _ []string) (string, int) {
+				if strings.HasPrefix(command, "lsblk") {
+					return testCase.devices, 0
+				}
+				return testCase.signature, 0
+			})
+
+			output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"VolumeId": testVolumeID, "MountPoint": mountPoint})
+
+			assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+			assert.Contains(t, output.GetStderr(), testCase.err)
+			for _, command := range *commands {
+				assert.False(t, strings.HasPrefix(command, "mkfs") || strings.HasPrefix(command, "mount"), command)
+			}
+			assert.Empty(t, readFstab(t))
+		})
+	}
+}
+
+func TestMountPointWithFilesIsNotMounted(t *testing.T) {
+	mountPoint, cleanup := setup(t, "")
+	defer cleanup()
+	assert.NoError(t, os.MkdirAll(mountPoint, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(mountPoint, "file"), nil, 0644))
+	executer, commands := pluginutil.MockCommandsWithExitCode(func(command string, _ []string) (string, int) {
+		if strings.HasPrefix(command, "lsblk") {
+			return blankVolume, 0
+		}
+		return ext4Signature, 0
+	})
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"VolumeId": testVolumeID, "MountPoint": mountPoint})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "is not empty")
+	assert.Len(t, *commands, 2)
+}
+
+func TestFindDevice(t *testing.T) {
+	defer func(resolve func(string) (string, error)) { resolveDevice = resolve }(resolveDevice)
+	resolveDevice = func(path string) (string, error) {
+		if path == "/dev/sdg" {
+			return "/dev/nvme1n1", nil
+		}
+		return "", os.ErrNotExist
+	}
+	command := func(name string, args ...string) (string, int, error) {
+		return `{"blockdevices": [{"name": "/dev/xvda", "type": "disk"}, {"name": "/dev/xvdf", "type": "disk"},
+			{"name": "/dev/nvme1n1", "serial": "vol0123456789abcdef0  ", "type": "disk"}]}`, 0, nil
+	}
+
+	device, err := findDevice(command, testVolumeID, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/nvme1n1", device.Name)
+
+	device, err = findDevice(command, "", "/dev/sdf")
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/xvdf", device.Name)
+
+	device, err = findDevice(command, "", "/dev/sdg")
+	assert.NoError(t, err)
+	assert.Equal(t, "/dev/nvme1n1", device.Name)
+
+	_, err = findDevice(command, "vol-0fedcba9876543210", "/dev/sdg")
+	assert.EqualError(t, err, "/dev/nvme1n1 is volume vol0123456789abcdef0, not vol-0fedcba9876543210")
+
+	_, err = findDevice(command, "vol-0fedcba9876543210", "")
+	assert.EqualError(t, err, "volume vol-0fedcba9876543210 is not attached to the instance")
+}
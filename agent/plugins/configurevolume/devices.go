@@ -0,0 +1,103 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurevolume
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// blockDevice is a device of the output of lsblk, null columns are read as empty strings
+type blockDevice struct {
+	Name       string        `json:"name"`
+	Serial     string        `json:"serial"`
+	Type       string        `json:"type"`
+	MountPoint string        `json:"mountpoint"`
+	Children   []blockDevice `json:"children"`
+}
+
+// resolveDevice follows the udev links of device names, such as /dev/sdf linking to an NVMe device
+var resolveDevice = filepath.EvalSymlinks
+
+// findDevice returns the disk of the volume. NVMe devices of EBS volumes have the volume id without dash as
+// serial number, devices of Xen instances are found by name, /dev/sdf being attached as /dev/xvdf.
+func findDevice(command commandFunc, volumeID string, deviceName string) (blockDevice, error) {
+	stdout, _, err := command("lsblk", "-J", "-p", "-o", "NAME,SERIAL,TYPE,MOUNTPOINT")
+	if err != nil {
+		return blockDevice{}, fmt.Errorf("failed to list block devices: %v", err)
+	}
+	var listing struct {
+		BlockDevices []blockDevice `json:"blockdevices"`
+	}
+	if err = json.Unmarshal([]byte(stdout), &listing); err != nil {
+		return blockDevice{}, fmt.Errorf("failed to parse block devices: %v", err)
+	}
+
+	serial := strings.Replace(volumeID, "-", "", 1)
+	var names []string
+	if deviceName != "" {
+		names = append(names, deviceName)
+		if resolved, err := resolveDevice(deviceName); err == nil && resolved != deviceName {
+			names = append(names, resolved)
+		}
+		if strings.HasPrefix(deviceName, "/dev/sd") {
+			names = append(names, "/dev/xvd"+strings.TrimPrefix(deviceName, "/dev/sd"))
+		}
+	}
+
+	for _, device := range listing.BlockDevices {
+		if device.Type != "disk" {
+			continue
+		}
+		deviceSerial := strings.TrimSpace(device.Serial)
+		if volumeID != "" && strings.EqualFold(deviceSerial, serial) {
+			return device, nil
+		}
+		for _, name := range names {
+			if device.Name != name {
+				continue
+			}
+			// the serial numbers of NVMe devices tell whether the device name is the one of the volume
+			if volumeID != "" && strings.HasPrefix(deviceSerial, "vol") {
+				return blockDevice{}, fmt.Errorf("%v is volume %v, not %v", name, deviceSerial, volumeID)
+			}
+			return device, nil
+		}
+	}
+	if volumeID != "" {
+		return blockDevice{}, fmt.Errorf("volume %v is not attached to the instance", volumeID)
+	}
+	return blockDevice{}, fmt.Errorf("device %v is not attached to the instance", deviceName)
+}
+
+// probe returns the signatures blkid finds on the device, it reads the device rather than the blkid cache
+func probe(command commandFunc, device string) (map[string]string, error) {
+	stdout, exitCode, err := command("blkid", "-p", "-o", "export", device)
+	probed := map[string]string{}
+	if exitCode == 2 {
+		// blkid exits with 2 when the device has no signature
+		return probed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe %v: %v", device, err)
+	}
+	for _, line := range strings.Split(stdout, "\n") {
+		if i := strings.Index(line, "="); i > 0 {
+			probed[line[:i]] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return probed, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurevolume
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fstabMarker precedes the fstab entries of the plugin, followed by the volume id or device name of the entry
+const fstabMarker = "# aws:configureVolume "
+
+// fstabPath is the table of the file systems mounted at boot
+var fstabPath = "/etc/fstab"
+
+// fstabEntry is a line of fstab, volumes are checked at boot after the root file system
+type fstabEntry struct {
+	spec    string
+	file    string
+	vfsType string
+	options string
+}
+
+func (entry fstabEntry) String() string {
+	return fmt.Sprintf("%v %v %v %v 0 2", entry.spec, entry.file, entry.vfsType, entry.options)
+}
+
+// updateFstab adds the entry of the volume to fstab or replaces the entry following its marker. Untagged
+// entries of the mount point are replaced when they mount the same file system or device, and are an error
+// otherwise. It returns false when fstab already has the entry.
+func updateFstab(path string, tag string, entry fstabEntry, deviceName string) (bool, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	var lines []string
+	if trimmed := strings.TrimRight(string(content), "\n"); trimmed != "" {
+		lines = strings.Split(trimmed, "\n")
+	}
+
+	marker := fstabMarker + tag
+	var result []string
+	found := false
+	replace := func() {
+		if !found {
+			result = append(result, marker, entry.String())
+			found = true
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == marker {
+			// the entry of the volume follows its marker
+			i++
+			replace()
+			continue
+		}
+		fields := strings.Fields(lines[i])
+		if len(fields) >= 2 && !strings.HasPrefix(fields[0], "#") && filepath.Clean(fields[1]) == entry.file {
+			if fields[0] != entry.spec && fields[0] != deviceName {
+				return false, fmt.Errorf("%v already mounts %v on %v", path, fields[0], entry.file)
+			}
+			replace()
+			continue
+		}
+		result = append(result, lines[i])
+	}
+	replace()
+
+	updated := strings.Join(result, "\n") + "\n"
+	if updated == string(content) {
+		return false, nil
+	}
+	return true, writeFile(path, []byte(updated))
+}
+
+// writeFile replaces the file with a new file renamed over it, so a failed write leaves it untouched
+func writeFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(content); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
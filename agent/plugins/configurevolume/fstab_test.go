@@ -0,0 +1,69 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package configurevolume
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateFstab(t *testing.T) {
+	entry := fstabEntry{spec: "UUID=1234", file: "/data", vfsType: "xfs", options: "defaults,nofail"}
+	testCases := []struct {
+		name     string
+		fstab    string
+		expected string
+		updated  bool
+	}{
+		{"empty", "", "# aws:configureVolume vol-1\nUUID=1234 /data xfs defaults,nofail 0 2\n", true},
+		{"up to date", "/dev/xvda / ext4 defaults 0 0\n# aws:configureVolume vol-1\nUUID=1234 /data xfs defaults,nofail 0 2\n", "", false},
+		{"moved", "# aws:configureVolume vol-1\nUUID=1234 /old xfs defaults 0 2\n/dev/xvda / ext4 defaults 0 0\n",
+			"# aws:configureVolume vol-1\nUUID=1234 /data xfs defaults,nofail 0 2\n/dev/xvda / ext4 defaults 0 0\n", true},
+		{"untagged entry of the device", "/dev/xvdf /data/ xfs defaults 0 0\n",
+			"# aws:configureVolume vol-1\nUUID=1234 /data xfs defaults,nofail 0 2\n", true},
+		{"commented entry", "#UUID=5678 /data xfs defaults 0 0",
+			"#UUID=5678 /data xfs defaults 0 0\n# aws:configureVolume vol-1\nUUID=1234 /data xfs defaults,nofail 0 2\n", true},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, cleanup := setup(t, testCase.fstab)
+			defer cleanup()
+
+			updated, err := updateFstab(fstabPath, "vol-1", entry, "/dev/xvdf")
+
+			assert.NoError(t, err)
+			assert.Equal(t, testCase.updated, updated)
+			content, _ := ioutil.ReadFile(fstabPath)
+			if testCase.updated {
+				assert.Equal(t, testCase.expected, string(content))
+			} else {
+				assert.Equal(t, testCase.fstab, string(content))
+			}
+		})
+	}
+}
+
+func TestUpdateFstabKeepsOtherMounts(t *testing.T) {
+	fstab := "UUID=5678 /data xfs defaults 0 0\n"
+	_, cleanup := setup(t, fstab)
+	defer cleanup()
+
+	_, err := updateFstab(fstabPath, "vol-1", fstabEntry{spec: "UUID=1234", file: "/data", vfsType: "xfs", options: "defaults"}, "/dev/xvdf")
+
+	assert.Error(t, err)
+	content, _ := ioutil.ReadFile(fstabPath)
+	assert.Equal(t, fstab, string(content))
+}
@@ -0,0 +1,397 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package defender implements the aws:configureDefender plugin, it manages the exclusions and scan settings
+// of Windows Defender declaratively and reports their drift.
+package defender
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// ActionGet reports the drift of the Defender configuration from the plugin input
+	ActionGet = "Get"
+	// ActionSet applies the plugin input to the Defender configuration
+	ActionSet = "Set"
+
+	// EnsurePresent makes sure the exclusions of the input exist
+	EnsurePresent = "Present"
+	// EnsureAbsent makes sure the exclusions of the input don't exist
+	EnsureAbsent = "Absent"
+
+	// Exclusion preferences of Defender
+	ExclusionPath      = "ExclusionPath"
+	ExclusionProcess   = "ExclusionProcess"
+	ExclusionExtension = "ExclusionExtension"
+
+	// Operations of a change
+	OperationAdd    = "Add"
+	OperationRemove = "Remove"
+	OperationSet    = "Set"
+)
+
+// scanSettings are the scan settings the plugin manages and whether their value is a boolean or an integer
+var scanSettings = map[string]bool{
+	"CheckForSignaturesBeforeRunningScan": true,
+	"DisableArchiveScanning":              true,
+	"DisableRealtimeMonitoring":           true,
+	"DisableRemovableDriveScanning":       true,
+	"DisableScanningNetworkFiles":         true,
+	"ScanOnlyIfIdleEnabled":               true,
+	"ScanAvgCPULoadFactor":                false,
+	"ScanScheduleDay":                     false,
+}
+
+// Plugin is the type for the configureDefender plugin.
+type Plugin struct {
+	// CommandExecuter runs the PowerShell commands reading and changing the Defender preferences.
+	CommandExecuter executers.T
+}
+
+// DefenderPluginInput represents the Defender configuration a step gets or sets.
+type DefenderPluginInput struct {
+	contracts.PluginInput
+	ID                  string
+	Action              string
+	Ensure              string
+	ExclusionPaths      []string
+	ExclusionProcesses  []string
+	ExclusionExtensions []string
+	ScanSettings        map[string]interface{}
+	TimeoutSeconds      interface{}
+}
+
+// State is the part of the Defender configuration managed by the plugin.
+type State struct {
+	ExclusionPath      []string
+	ExclusionProcess   []string
+	ExclusionExtension []string
+	Settings           map[string]interface{}
+}
+
+// Change is a difference between the Defender configuration and the plugin input.
+type Change struct {
+	Preference string
+	Operation  string
+	Value      interface{}
+	Current    interface{} `json:",omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Operation {
+	case OperationAdd:
+		return fmt.Sprintf("add %v %v", c.Preference, c.Value)
+	case OperationRemove:
+		return fmt.Sprintf("remove %v %v", c.Preference, c.Value)
+	default:
+		return fmt.Sprintf("set %v to %v (was %v)", c.Preference, c.Value, c.Current)
+	}
+}
+
+// Report is the audit output of a step, the changes made by Set and the drift left after them.
+type Report struct {
+	Action    string
+	Compliant bool
+	Applied   []Change `json:",omitempty"`
+	Drift     []Change
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin
+func Name() string {
+	return appconfig.PluginNameAwsConfigureDefender
+}
+
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, cancelFlag, output)
+	}
+	return
+}
+
+// runRawInput gets or sets the Defender configuration of one set of properties.
+// The input is in the default json unmarshal format (e.g. map[string]interface{}).
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput DefenderPluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	p.run(log, pluginInput, cancelFlag, output)
+}
+
+// validateInput checks the action and the scan settings of the input and sets their defaults
+func validateInput(pluginInput *DefenderPluginInput) error {
+	if pluginInput.Action == "" {
+		pluginInput.Action = ActionGet
+	}
+	if pluginInput.Action != ActionGet && pluginInput.Action != ActionSet {
+		return fmt.Errorf("Action is set to unsupported value %v, expected %v or %v", pluginInput.Action, ActionGet, ActionSet)
+	}
+	if pluginInput.Ensure == "" {
+		pluginInput.Ensure = EnsurePresent
+	}
+	if pluginInput.Ensure != EnsurePresent && pluginInput.Ensure != EnsureAbsent {
+		return fmt.Errorf("Ensure is set to unsupported value %v, expected %v or %v", pluginInput.Ensure, EnsurePresent, EnsureAbsent)
+	}
+	for name, value := range pluginInput.ScanSettings {
+		normalized, err := settingValue(name, value)
+		if err != nil {
+			return err
+		}
+		pluginInput.ScanSettings[name] = normalized
+	}
+	return nil
+}
+
+// settingValue returns the value of a scan setting as a bool or an int
+func settingValue(name string, value interface{}) (interface{}, error) {
+	isBool, known := scanSettings[name]
+	if !known {
+		return nil, fmt.Errorf("scan setting %v is not supported", name)
+	}
+	switch typed := value.(type) {
+	case bool:
+		if isBool {
+			return typed, nil
+		}
+	case float64:
+		if !isBool && typed == math.Trunc(typed) {
+			return int(typed), nil
+		}
+	case int:
+		if !isBool {
+			return typed, nil
+		}
+	}
+	if isBool {
+		return nil, fmt.Errorf("scan setting %v must be true or false, not %v", name, value)
+	}
+	return nil, fmt.Errorf("scan setting %v must be an integer, not %v", name, value)
+}
+
+// run reports the drift of the Defender configuration from the input, and fixes it when the action is Set
+func (p *Plugin) run(log log.T, pluginInput DefenderPluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+
+	current, err := p.getState(log, cancelFlag, timeout)
+	if err != nil {
+		markAsFailed(cancelFlag, output, err)
+		return
+	}
+	report := Report{Action: pluginInput.Action, Drift: drift(pluginInput, current)}
+
+	if pluginInput.Action == ActionSet && len(report.Drift) > 0 {
+		if _, err = p.powerShell(log, setScript(report.Drift), cancelFlag, timeout); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to change the Defender preferences: %v", err))
+			return
+		}
+		report.Applied = report.Drift
+		for _, change := range report.Applied {
+			output.AppendInfof("Changed Defender preferences: %v", change)
+		}
+		// the preferences can be enforced by group policy or tamper protection, report what did not change
+		if current, err = p.getState(log, cancelFlag, timeout); err != nil {
+			markAsFailed(cancelFlag, output, err)
+			return
+		}
+		report.Drift = drift(pluginInput, current)
+	}
+	for _, change := range report.Drift {
+		output.AppendInfof("Drift of Defender preferences: %v", change)
+	}
+	report.Compliant = len(report.Drift) == 0
+
+	reportJson, _ := jsonutil.Marshal(report)
+	output.AppendInfo(jsonutil.Indent(reportJson))
+	if pluginInput.Action == ActionSet && !report.Compliant {
+		output.MarkAsFailed(fmt.Errorf("%v changes of the Defender preferences did not apply, they may be managed by group policy or tamper protection", len(report.Drift)))
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+// markAsFailed fails the step, unless the command failed because the step was canceled
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
+
+// drift returns the changes making the Defender configuration match the input
+func drift(pluginInput DefenderPluginInput, current State) []Change {
+	var changes []Change
+	changes = append(changes, exclusionDrift(ExclusionPath, pluginInput.Ensure, pluginInput.ExclusionPaths, current.ExclusionPath)...)
+	changes = append(changes, exclusionDrift(ExclusionProcess, pluginInput.Ensure, pluginInput.ExclusionProcesses, current.ExclusionProcess)...)
+	changes = append(changes, exclusionDrift(ExclusionExtension, pluginInput.Ensure, pluginInput.ExclusionExtensions, current.ExclusionExtension)...)
+
+	names := make([]string, 0, len(pluginInput.ScanSettings))
+	for name := range pluginInput.ScanSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		desired := pluginInput.ScanSettings[name]
+		currentValue, err := settingValue(name, current.Settings[name])
+		if err != nil || currentValue != desired {
+			changes = append(changes, Change{Preference: name, Operation: OperationSet, Value: desired, Current: current.Settings[name]})
+		}
+	}
+	return changes
+}
+
+// exclusionDrift returns the exclusions to add or remove, exclusions are compared ignoring case like Windows paths
+func exclusionDrift(preference string, ensure string, desired []string, current []string) []Change {
+	existing := make(map[string]bool, len(current))
+	for _, exclusion := range current {
+		existing[strings.ToLower(exclusion)] = true
+	}
+	var changes []Change
+	for _, exclusion := range desired {
+		if ensure == EnsurePresent && !existing[strings.ToLower(exclusion)] {
+			changes = append(changes, Change{Preference: preference, Operation: OperationAdd, Value: exclusion})
+		} else if ensure == EnsureAbsent && existing[strings.ToLower(exclusion)] {
+			changes = append(changes, Change{Preference: preference, Operation: OperationRemove, Value: exclusion})
+		}
+	}
+	return changes
+}
+
+// getStateScript prints the exclusions and the managed scan settings as json
+func getStateScript() string {
+	names := make([]string, 0, len(scanSettings))
+	for name := range scanSettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var settings []string
+	for _, name := range names {
+		settings = append(settings, fmt.Sprintf("%v = $p.%v", name, name))
+	}
+	return strings.Join([]string{
+		"$ErrorActionPreference = 'Stop'",
+		"$p = Get-MpPreference",
+		"[pscustomobject]@{" +
+			"ExclusionPath = @($p.ExclusionPath | Where-Object { $_ }); " +
+			"ExclusionProcess = @($p.ExclusionProcess | Where-Object { $_ }); " +
+			"ExclusionExtension = @($p.ExclusionExtension | Where-Object { $_ }); " +
+			"Settings = [pscustomobject]@{" + strings.Join(settings, "; ") + "}" +
+			"} | ConvertTo-Json -Compress",
+	}, "\n")
+}
+
+// setScript returns the commands making the changes
+func setScript(changes []Change) string {
+	lines := []string{"$ErrorActionPreference = 'Stop'"}
+	exclusions := map[string][]string{}
+	var order []string
+	var settings []string
+	for _, change := range changes {
+		if change.Operation == OperationSet {
+			settings = append(settings, fmt.Sprintf("-%v %v", change.Preference, powerShellValue(change.Value)))
+			continue
+		}
+		key := change.Operation + "-MpPreference -" + change.Preference
+		if _, ok := exclusions[key]; !ok {
+			order = append(order, key)
+		}
+		exclusions[key] = append(exclusions[key], powerShellValue(change.Value))
+	}
+	for _, key := range order {
+		lines = append(lines, fmt.Sprintf("%v %v", key, strings.Join(exclusions[key], ",")))
+	}
+	if len(settings) > 0 {
+		lines = append(lines, "Set-MpPreference "+strings.Join(settings, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// powerShellValue returns the PowerShell literal of a value
+func powerShellValue(value interface{}) string {
+	switch typed := value.(type) {
+	case bool:
+		if typed {
+			return "$true"
+		}
+		return "$false"
+	case int:
+		return strconv.Itoa(typed)
+	default:
+		return "'" + strings.Replace(fmt.Sprint(value), "'", "''", -1) + "'"
+	}
+}
+
+// getState reads the Defender configuration managed by the plugin
+func (p *Plugin) getState(log log.T, cancelFlag task.CancelFlag, timeout int) (state State, err error) {
+	stdout, err := p.powerShell(log, getStateScript(), cancelFlag, timeout)
+	if err != nil {
+		return state, fmt.Errorf("failed to get the Defender preferences: %v", err)
+	}
+	if err = jsonutil.Unmarshal(stdout, &state); err != nil {
+		return state, fmt.Errorf("failed to parse the Defender preferences %v: %v", stdout, err)
+	}
+	return state, nil
+}
+
+// powerShell runs the script and returns its output
+func (p *Plugin) powerShell(log log.T, script string, cancelFlag task.CancelFlag, timeout int) (string, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := p.CommandExecuter.NewExecute(log, "", &stdout, &stderr, cancelFlag, timeout,
+		appconfig.PowerShellPluginCommandName, []string{"-NoProfile", "-NonInteractive", "-Command", script})
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package defender
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const currentState = `{"ExclusionPath":["C:\\Program Files\\App"],"ExclusionProcess":["app.exe"],"ExclusionExtension":[],` +
+	`"Settings":{"DisableArchiveScanning":false,"ScanAvgCPULoadFactor":50}}`
+
+// mockPowerShell returns an executer printing the outputs in order, one for each script it runs
+func mockPowerShell(outputs ...string) *executers.MockCommandExecuter {
+	executer, _ := pluginutil.MockCommands(func(string, []string) string {
+		output := outputs[0]
+		outputs = outputs[1:]
+		return output
+	})
+	return executer
+}
+
+// scripts returns the scripts run by the executer
+func scripts(executer *executers.MockCommandExecuter) []string {
+	var scripts []string
+	for _, call := range executer.Calls {
+		arguments := call.Arguments.Get(7).([]string)
+		scripts = append(scripts, arguments[len(arguments)-1])
+	}
+	return scripts
+}
+
+func TestGetReportsDrift(t *testing.T) {
+	executer := mockPowerShell(currentState)
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"ExclusionPaths":     []interface{}{"c:\\program files\\app", "D:\\data"},
+		"ExclusionProcesses": []interface{}{"app.exe"},
+		"ScanSettings":       map[string]interface{}{"ScanAvgCPULoadFactor": 30.0, "DisableArchiveScanning": false},
+	})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Len(t, scripts(executer), 1)
+	assert.Contains(t, output.GetStdout(), "Drift of Defender preferences: add ExclusionPath D:\\data")
+	assert.Contains(t, output.GetStdout(), "Drift of Defender preferences: set ScanAvgCPULoadFactor to 30 (was 50)")
+	assert.Contains(t, output.GetStdout(), `"Compliant": false`)
+}
+
+func TestSetAppliesDrift(t *testing.T) {
+	fixed := `{"ExclusionPath":["C:\\Program Files\\App","D:\\data"],"ExclusionProcess":[],"ExclusionExtension":[],` +
+		`"Settings":{"DisableArchiveScanning":true,"ScanAvgCPULoadFactor":50}}`
+	executer := mockPowerShell(currentState, "", fixed)
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"Action":         ActionSet,
+		"ExclusionPaths": []interface{}{"D:\\data", "C:\\it's"},
+		"ScanSettings":   map[string]interface{}{"DisableArchiveScanning": true},
+	})
+
+	assert.Equal(t, 3, len(scripts(executer)))
+	assert.Equal(t, "$ErrorActionPreference = 'Stop'\n"+
+		"Add-MpPreference -ExclusionPath 'D:\\data','C:\\it''s'\n"+
+		"Set-MpPreference -DisableArchiveScanning $true", scripts(executer)[1])
+	assert.Contains(t, output.GetStdout(), "Changed Defender preferences: add ExclusionPath D:\\data")
+	// the path that could not be added is reported as drift
+	assert.Contains(t, output.GetStdout(), "Drift of Defender preferences: add ExclusionPath C:\\it's")
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+}
+
+func TestSetRemovesExclusions(t *testing.T) {
+	removed := `{"ExclusionPath":["C:\\Program Files\\App"],"ExclusionProcess":[],"ExclusionExtension":[],"Settings":{}}`
+	executer := mockPowerShell(currentState, "", removed)
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"Action":             ActionSet,
+		"Ensure":             EnsureAbsent,
+		"ExclusionProcesses": []interface{}{"APP.exe", "other.exe"},
+	})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, "$ErrorActionPreference = 'Stop'\nRemove-MpPreference -ExclusionProcess 'APP.exe'", scripts(executer)[1])
+	assert.Contains(t, output.GetStdout(), `"Compliant": true`)
+}
+
+func TestSetCompliantMakesNoChange(t *testing.T) {
+	executer := mockPowerShell(currentState)
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"Action":         ActionSet,
+		"ExclusionPaths": []interface{}{"C:\\Program Files\\App"},
+	})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Len(t, scripts(executer), 1)
+	assert.NotContains(t, output.GetStdout(), "Changed Defender preferences")
+}
+
+func TestPowerShellFailure(t *testing.T) {
+	executer := new(executers.MockCommandExecuter)
+	executer.On("NewExecute", mock.Anything, "", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			io.WriteString(args.Get(3).(io.Writer), "Get-MpPreference : The term 'Get-MpPreference' is not recognized")
+		}).Return(1, nil)
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "failed to get the Defender preferences: exit code 1 Get-MpPreference")
+}
+
+func TestInvalidInput(t *testing.T) {
+	for _, properties := range []map[string]interface{}{
+		{"Action": "Remove"},
+		{"Ensure": "Missing"},
+		{"ScanSettings": map[string]interface{}{"ExclusionPath": "C:\\"}},
+		{"ScanSettings": map[string]interface{}{"DisableRealtimeMonitoring": "yes"}},
+		{"ScanSettings": map[string]interface{}{"ScanScheduleDay": 1.5}},
+	} {
+		executer := new(executers.MockCommandExecuter)
+
+		output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, properties)
+
+		assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus(), fmt.Sprint(properties))
+		executer.AssertNotCalled(t, "NewExecute", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestGetStateScript(t *testing.T) {
+	script := getStateScript()
+
+	assert.True(t, strings.HasPrefix(script, "$ErrorActionPreference = 'Stop'\n$p = Get-MpPreference\n"))
+	assert.Contains(t, script, "CheckForSignaturesBeforeRunningScan = $p.CheckForSignaturesBeforeRunningScan; DisableArchiveScanning")
+	assert.True(t, strings.HasSuffix(script, "} | ConvertTo-Json -Compress"))
+}
@@ -0,0 +1,411 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// BackendSystemdResolved manages a drop-in of systemd-resolved, it overrides the settings of resolved.conf
+	BackendSystemdResolved = "SystemdResolved"
+	// BackendResolvConf manages the directives of resolv.conf
+	BackendResolvConf = "ResolvConf"
+	// BackendWindowsDnsClient manages the settings of the Windows DNS client
+	BackendWindowsDnsClient = "WindowsDnsClient"
+
+	// nrptComment identifies the NRPT rules the plugin added
+	nrptComment = "aws:configureDns"
+	// maxResolvConfNameservers is the number of nameservers the resolver of glibc reads from resolv.conf
+	maxResolvConfNameservers = 3
+)
+
+// resolvConfPath is the configuration of the resolver, a link to a file of systemd-resolved when it manages it
+var resolvConfPath = "/etc/resolv.conf"
+
+// resolvedDropInPath is the drop-in of systemd-resolved the plugin writes
+var resolvedDropInPath = "/etc/systemd/resolved.conf.d/amazon-ssm-agent.conf"
+
+// backend is a resolver the plugin manages the settings of
+type backend interface {
+	// read returns the current values of the settings the configuration declares, and the NRPT rules the plugin added
+	read(config Config) ([]Setting, error)
+	// write changes the settings which differ from the configuration
+	write(config Config, differences []Difference) error
+}
+
+func newBackend(name string, runner commandRunner) backend {
+	switch name {
+	case BackendWindowsDnsClient:
+		return &windowsDnsClient{commandRunner: runner}
+	case BackendSystemdResolved:
+		return &systemdResolved{commandRunner: runner}
+	default:
+		return &resolvConf{}
+	}
+}
+
+// commandRunner runs the commands of the backends
+type commandRunner struct {
+	log             log.T
+	commandExecuter executers.T
+	cancelFlag      task.CancelFlag
+	timeout         int
+}
+
+// run runs the command and returns its standard output
+func (r commandRunner) run(command string, args ...string) (string, error) {
+	return r.execute(executers.ExecuteOptions{}, command, args)
+}
+
+// runElevated runs the command of an operation needing root, which is elevated when the agent does not run as root
+func (r commandRunner) runElevated(operation string, command string, args ...string) (string, error) {
+	return r.execute(executers.ExecuteOptions{Elevation: operation}, command, args)
+}
+
+func (r commandRunner) execute(options executers.ExecuteOptions, command string, args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := r.commandExecuter.NewExecuteWithOptions(r.log, "", &stdout, &stderr, r.cancelFlag, r.timeout, options, command, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// declaredSettings returns the settings of the configuration read from the values of a file
+func declaredSettings(config Config, nameservers []string, searchDomains []string) []Setting {
+	var settings []Setting
+	if config.Nameservers != nil {
+		settings = append(settings, Setting{Name: SettingNameservers, Values: nameservers})
+	}
+	if config.SearchDomains != nil {
+		settings = append(settings, Setting{Name: SettingSearchDomains, Values: searchDomains})
+	}
+	return settings
+}
+
+// changed returns true when the setting is among the differences
+func changed(differences []Difference, setting string) bool {
+	for _, difference := range differences {
+		if difference.Setting == setting {
+			return true
+		}
+	}
+	return false
+}
+
+// resolvConf manages the nameserver and search directives of resolv.conf, the other directives are kept
+type resolvConf struct{}
+
+func (r *resolvConf) read(config Config) ([]Setting, error) {
+	content, err := ioutil.ReadFile(resolvConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	nameservers, searchDomains := parseResolvConf(string(content))
+	return declaredSettings(config, nameservers, searchDomains), nil
+}
+
+func (r *resolvConf) write(config Config, differences []Difference) error {
+	if len(config.Nameservers) > maxResolvConfNameservers {
+		return fmt.Errorf("resolv.conf takes at most %v nameservers", maxResolvConfNameservers)
+	}
+	content, err := ioutil.ReadFile(resolvConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var nameservers, searchDomains []string
+	if changed(differences, SettingNameservers) {
+		nameservers = config.Nameservers
+		if nameservers == nil {
+			nameservers = []string{}
+		}
+	}
+	if changed(differences, SettingSearchDomains) {
+		searchDomains = config.SearchDomains
+		if searchDomains == nil {
+			searchDomains = []string{}
+		}
+	}
+	return writeFile(resolvConfPath, []byte(rewriteResolvConf(string(content), nameservers, searchDomains)))
+}
+
+// parseResolvConf returns the nameservers and the search domains of resolv.conf, the last search or domain
+// directive applies
+func parseResolvConf(content string) (nameservers []string, searchDomains []string) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			nameservers = append(nameservers, fields[1])
+		case "search", "domain":
+			searchDomains = fields[1:]
+		}
+	}
+	return nameservers, searchDomains
+}
+
+// rewriteResolvConf replaces the nameserver directives with the nameservers and the search and domain directives
+// with the search domains where the first of them was, the directives are kept when the values are nil
+func rewriteResolvConf(content string, nameservers []string, searchDomains []string) string {
+	var lines []string
+	nameserversWritten, searchWritten := nameservers == nil, searchDomains == nil
+	writeNameservers := func() {
+		for _, nameserver := range nameservers {
+			lines = append(lines, "nameserver "+nameserver)
+		}
+		nameserversWritten = true
+	}
+	writeSearch := func() {
+		if len(searchDomains) > 0 {
+			lines = append(lines, "search "+strings.Join(searchDomains, " "))
+		}
+		searchWritten = true
+	}
+	if content = strings.TrimRight(content, "\n"); content != "" {
+		for _, line := range strings.Split(content, "\n") {
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) > 0 && fields[0] == "nameserver" && nameservers != nil:
+				if !nameserversWritten {
+					writeNameservers()
+				}
+			case len(fields) > 0 && (fields[0] == "search" || fields[0] == "domain") && searchDomains != nil:
+				if !searchWritten {
+					writeSearch()
+				}
+			default:
+				lines = append(lines, line)
+			}
+		}
+	}
+	if !searchWritten {
+		writeSearch()
+	}
+	if !nameserversWritten {
+		writeNameservers()
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// systemdResolved manages the DNS and Domains settings of a drop-in of systemd-resolved
+type systemdResolved struct {
+	commandRunner
+}
+
+func (s *systemdResolved) read(config Config) ([]Setting, error) {
+	values, err := readDropIn()
+	if err != nil {
+		return nil, err
+	}
+	return declaredSettings(config, values["DNS"], values["Domains"]), nil
+}
+
+func (s *systemdResolved) write(config Config, differences []Difference) error {
+	values, err := readDropIn()
+	if err != nil {
+		return err
+	}
+	if changed(differences, SettingNameservers) {
+		values["DNS"] = config.Nameservers
+	}
+	if changed(differences, SettingSearchDomains) {
+		values["Domains"] = config.SearchDomains
+	}
+	content := "# Written by the aws:configureDns plugin of the SSM Agent, changes are overwritten\n[Resolve]\n"
+	for _, key := range []string{"DNS", "Domains"} {
+		if value, ok := values[key]; ok {
+			content += key + "=" + strings.Join(value, " ") + "\n"
+		}
+	}
+	if err = os.MkdirAll(filepath.Dir(resolvedDropInPath), 0755); err != nil {
+		return err
+	}
+	if err = writeFile(resolvedDropInPath, []byte(content)); err != nil {
+		return err
+	}
+	_, err = s.runElevated(appconfig.ElevationOperationServiceControl, "systemctl", "restart", "systemd-resolved")
+	return err
+}
+
+// readDropIn returns the values of the settings of the drop-in, an empty value clears the setting
+func readDropIn() (map[string][]string, error) {
+	values := map[string][]string{}
+	content, err := ioutil.ReadFile(resolvedDropInPath)
+	if os.IsNotExist(err) {
+		return values, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(parts) == 2 && (parts[0] == "DNS" || parts[0] == "Domains") {
+			values[parts[0]] = strings.Fields(parts[1])
+			if values[parts[0]] == nil {
+				values[parts[0]] = []string{}
+			}
+		}
+	}
+	return values, nil
+}
+
+// writeFile replaces the file with the content, keeping its permissions
+func writeFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(content); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// windowsDnsClient sets the nameservers of the interfaces, the global suffix search list and the NRPT rules of the
+// Windows DNS client with PowerShell
+type windowsDnsClient struct {
+	commandRunner
+}
+
+func (w *windowsDnsClient) read(config Config) ([]Setting, error) {
+	var settings []Setting
+	if config.Nameservers != nil {
+		interfaces := config.InterfaceAliases
+		if len(interfaces) == 0 {
+			stdout, err := w.powerShell("Get-NetIPConfiguration | Where-Object { $_.IPv4DefaultGateway -or $_.IPv6DefaultGateway } | ForEach-Object { $_.InterfaceAlias }")
+			if err != nil {
+				return nil, err
+			}
+			interfaces = lines(stdout)
+			if len(interfaces) == 0 {
+				return nil, fmt.Errorf("no interface has a default gateway")
+			}
+		}
+		for _, alias := range interfaces {
+			stdout, err := w.powerShell(fmt.Sprintf("Get-DnsClientServerAddress -InterfaceAlias %v | ForEach-Object { $_.ServerAddresses }", quote(alias)))
+			if err != nil {
+				return nil, err
+			}
+			settings = append(settings, Setting{Name: SettingNameservers, Scope: alias, Values: lines(stdout)})
+		}
+	}
+	if config.SearchDomains != nil {
+		stdout, err := w.powerShell("(Get-DnsClientGlobalSetting).SuffixSearchList")
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, Setting{Name: SettingSearchDomains, Values: lines(stdout)})
+	}
+	// the rules the plugin added are read even when none are declared anymore, they are removed then
+	stdout, err := w.powerShell(fmt.Sprintf("Get-DnsClientNrptRule | Where-Object { $_.Comment -eq %v } | ForEach-Object { ($_.Namespace -join ',') + '|' + ($_.NameServers -join ',') }",
+		quote(nrptComment)))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines(stdout) {
+		parts := strings.SplitN(line, "|", 2)
+		if len(parts) == 2 {
+			settings = append(settings, Setting{Name: SettingNrptRule, Scope: strings.ToLower(parts[0]), Values: strings.Split(parts[1], ",")})
+		}
+	}
+	return settings, nil
+}
+
+func (w *windowsDnsClient) write(config Config, differences []Difference) error {
+	for _, difference := range differences {
+		var script string
+		switch difference.Setting {
+		case SettingNameservers:
+			if len(difference.Expected) == 0 {
+				script = fmt.Sprintf("Set-DnsClientServerAddress -InterfaceAlias %v -ResetServerAddresses", quote(difference.Scope))
+			} else {
+				script = fmt.Sprintf("Set-DnsClientServerAddress -InterfaceAlias %v -ServerAddresses %v", quote(difference.Scope), quoteAll(difference.Expected))
+			}
+		case SettingSearchDomains:
+			script = fmt.Sprintf("Set-DnsClientGlobalSetting -SuffixSearchList @(%v)", quoteAll(difference.Expected))
+		case SettingNrptRule:
+			if difference.Current != nil {
+				script = fmt.Sprintf("Get-DnsClientNrptRule | Where-Object { $_.Comment -eq %v -and $_.Namespace -contains %v } | ForEach-Object { Remove-DnsClientNrptRule -Name $_.Name -Force }\n",
+					quote(nrptComment), quote(difference.Scope))
+			}
+			if difference.Expected != nil {
+				script += fmt.Sprintf("Add-DnsClientNrptRule -Namespace %v -NameServers %v -Comment %v",
+					quote(difference.Scope), quoteAll(difference.Expected), quote(nrptComment))
+			}
+		}
+		if _, err := w.powerShell(script); err != nil {
+			return fmt.Errorf("%v: %v", difference, err)
+		}
+	}
+	_, err := w.powerShell("Clear-DnsClientCache")
+	return err
+}
+
+func (w *windowsDnsClient) powerShell(script string) (string, error) {
+	return w.run(appconfig.PowerShellPluginCommandName, "-NoProfile", "-NonInteractive", "-Command", "$ErrorActionPreference = 'Stop'\n"+script)
+}
+
+// lines returns the lines of the output which aren't empty
+func lines(stdout string) []string {
+	var values []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+// quote returns the value as a PowerShell literal string
+func quote(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+func quoteAll(values []string) string {
+	var quoted []string
+	for _, value := range values {
+		quoted = append(quoted, quote(value))
+	}
+	return strings.Join(quoted, ",")
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dns
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRunner(run func(commandLine string, args []string) string) (commandRunner, *[]string) {
+	executer, commands := pluginutil.MockCommands(run)
+	return commandRunner{log: log.NewMockLog(), commandExecuter: executer, cancelFlag: task.NewChanneledCancelFlag()}, commands
+}
+
+func TestRewriteResolvConf(t *testing.T) {
+	content := "domain ec2.internal\nnameserver 10.0.0.2\nsearch a.example.com\nnameserver 10.0.0.3\n"
+
+	assert.Equal(t, content, rewriteResolvConf(content, nil, nil))
+	assert.Equal(t, "domain ec2.internal\nnameserver 10.1.0.10\nsearch a.example.com\n", rewriteResolvConf(content, []string{"10.1.0.10"}, nil))
+	assert.Equal(t, "nameserver 10.0.0.2\nnameserver 10.0.0.3\n", rewriteResolvConf(content, nil, []string{}))
+	assert.Equal(t, "search corp.example.com\nnameserver 10.1.0.10\n", rewriteResolvConf("", []string{"10.1.0.10"}, []string{"corp.example.com"}))
+
+	nameservers, searchDomains := parseResolvConf(content)
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, nameservers)
+	assert.Equal(t, []string{"a.example.com"}, searchDomains)
+}
+
+func TestResolvConfTakesThreeNameservers(t *testing.T) {
+	defer useTempFiles(t, "")()
+	config := Config{Nameservers: []string{"10.1.0.10", "10.1.0.11", "10.1.0.12", "10.1.0.13"}}
+
+	err := newBackend(BackendResolvConf, commandRunner{}).write(config, []Difference{{Setting: SettingNameservers}})
+
+	assert.Error(t, err)
+}
+
+func TestSystemdResolved(t *testing.T) {
+	defer useTempFiles(t, "")()
+	runner, commands := newRunner(func(command string, _ []string) string { return "" })
+	resolved := newBackend(BackendSystemdResolved, runner)
+	config := Config{Nameservers: []string{"10.1.0.10"}, SearchDomains: []string{}}
+
+	settings, err := resolved.read(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []Setting{{Name: SettingNameservers}, {Name: SettingSearchDomains}}, settings)
+	differences := drift(config, settings)
+	assert.Len(t, differences, 1)
+
+	assert.NoError(t, resolved.write(config, differences))
+	content, _ := ioutil.ReadFile(resolvedDropInPath)
+	assert.Equal(t, "# Written by the aws:configureDns plugin of the SSM Agent, changes are overwritten\n[Resolve]\nDNS=10.1.0.10\n", string(content))
+	assert.Equal(t, []string{"systemctl restart systemd-resolved"}, *commands)
+
+	// clearing the search domains overrides those of resolved.conf
+	assert.NoError(t, resolved.write(Config{SearchDomains: []string{}}, []Difference{{Setting: SettingSearchDomains}}))
+	content, _ = ioutil.ReadFile(resolvedDropInPath)
+	assert.Equal(t, "# Written by the aws:configureDns plugin of the SSM Agent, changes are overwritten\n[Resolve]\nDNS=10.1.0.10\nDomains=\n", string(content))
+	settings, err = resolved.read(Config{Nameservers: []string{}, SearchDomains: []string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, []Setting{{Name: SettingNameservers, Values: []string{"10.1.0.10"}}, {Name: SettingSearchDomains, Values: []string{}}}, settings)
+}
+
+func TestWindowsDnsClient(t *testing.T) {
+	runner, commands := newRunner(func(command string, _ []string) string {
+		switch {
+		case strings.Contains(command, "Get-NetIPConfiguration"):
+			return "Ethernet\r\n"
+		case strings.Contains(command, "Get-DnsClientServerAddress"):
+			return "10.0.0.2\r\n"
+		case strings.Contains(command, "Get-DnsClientNrptRule |"):
+			return ".old.example.com|10.3.0.10\r\n"
+		}
+		return ""
+	})
+	client := newBackend(BackendWindowsDnsClient, runner)
+	config := Config{
+		Nameservers: []string{"10.1.0.10", "10.1.0.11"},
+		NrptRules:   []NrptRule{{Namespace: ".corp.example.com", Nameservers: []string{"10.1.0.10"}}},
+	}
+
+	settings, err := client.read(config)
+	assert.NoError(t, err)
+	assert.Equal(t, []Setting{
+		{Name: SettingNameservers, Scope: "Ethernet", Values: []string{"10.0.0.2"}},
+		{Name: SettingNrptRule, Scope: ".old.example.com", Values: []string{"10.3.0.10"}},
+	}, settings)
+	assert.NoError(t, client.write(config, drift(config, settings)))
+
+	assert.Len(t, *commands, 7)
+	assert.Contains(t, (*commands)[1], "Get-DnsClientServerAddress -InterfaceAlias 'Ethernet'")
+	assert.Contains(t, (*commands)[3], "Set-DnsClientServerAddress -InterfaceAlias 'Ethernet' -ServerAddresses '10.1.0.10','10.1.0.11'")
+	assert.Contains(t, (*commands)[4], "Add-DnsClientNrptRule -Namespace '.corp.example.com' -NameServers '10.1.0.10' -Comment 'aws:configureDns'")
+	assert.Contains(t, (*commands)[5], "$_.Namespace -contains '.old.example.com' } | ForEach-Object { Remove-DnsClientNrptRule -Name $_.Name -Force }")
+	assert.NotContains(t, (*commands)[5], "Add-DnsClientNrptRule")
+	assert.Contains(t, (*commands)[6], "Clear-DnsClientCache")
+}
@@ -0,0 +1,363 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package dns implements the aws:configureDns plugin, which manages the nameservers, the search domains and the
+// Windows NRPT rules of the instance declaratively with systemd-resolved, resolv.conf or the Windows DNS client and
+// reports their drift.
+package dns
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// ActionGet reports the drift of the DNS settings from the plugin input
+	ActionGet = "Get"
+	// ActionSet applies the plugin input to the DNS settings
+	ActionSet = "Set"
+
+	// Settings the plugin manages
+	SettingNameservers   = "Nameservers"
+	SettingSearchDomains = "SearchDomains"
+	SettingNrptRule      = "NrptRule"
+)
+
+// domainPattern matches domain names, NRPT namespaces start with a dot to match the subdomains of the domain
+var domainPattern = regexp.MustCompile(`^\.?[A-Za-z0-9_]([A-Za-z0-9_-]*[A-Za-z0-9_])?(\.[A-Za-z0-9_]([A-Za-z0-9_-]*[A-Za-z0-9_])?)*\.?$`)
+
+// Plugin is the type for the configureDns plugin.
+type Plugin struct {
+	// CommandExecuter runs the commands reading and changing the DNS settings.
+	CommandExecuter executers.T
+}
+
+// Config is the DNS configuration of the instance, a setting which is not declared is not managed and an empty
+// list clears the setting
+type Config struct {
+	Nameservers   []string
+	SearchDomains []string
+	// NrptRules are the Name Resolution Policy Table rules of Windows, the rules the plugin added and which are
+	// not declared anymore are removed
+	NrptRules []NrptRule
+	// InterfaceAliases are the Windows interfaces the nameservers are set on, the interfaces with a default
+	// gateway when none are declared
+	InterfaceAliases []string
+}
+
+// NrptRule sends the queries of a namespace to its nameservers
+type NrptRule struct {
+	Namespace   string
+	Nameservers []string
+}
+
+// DnsPluginInput represents the DNS configuration a step gets or sets.
+type DnsPluginInput struct {
+	contracts.PluginInput
+	Config
+	ID      string
+	Action  string
+	DryRun  bool
+	Backend string
+	// TimeoutSeconds bounds each command run against the DNS settings
+	TimeoutSeconds interface{}
+}
+
+// Setting is the current value of a setting the plugin manages, Scope is the interface of the nameservers on
+// Windows and the namespace of NRPT rules
+type Setting struct {
+	Name   string
+	Scope  string `json:",omitempty"`
+	Values []string
+}
+
+// Difference is a setting which differs from the plugin input, NRPT rules to add have no current value and
+// rules to remove have no expected value
+type Difference struct {
+	Setting  string
+	Scope    string `json:",omitempty"`
+	Current  []string
+	Expected []string
+}
+
+// String returns the difference as a line of a diff
+func (d Difference) String() string {
+	name := d.Setting
+	if d.Scope != "" {
+		name += " " + d.Scope
+	}
+	switch {
+	case d.Setting == SettingNrptRule && d.Current == nil:
+		return fmt.Sprintf("+ %v: [%v]", name, strings.Join(d.Expected, ", "))
+	case d.Setting == SettingNrptRule && d.Expected == nil:
+		return fmt.Sprintf("- %v: [%v]", name, strings.Join(d.Current, ", "))
+	default:
+		return fmt.Sprintf("~ %v: [%v] -> [%v]", name, strings.Join(d.Current, ", "), strings.Join(d.Expected, ", "))
+	}
+}
+
+// Report is the output of the plugin
+type Report struct {
+	Action    string
+	Backend   string
+	DryRun    bool `json:",omitempty"`
+	Compliant bool
+	Applied   []Difference `json:",omitempty"`
+	Drift     []Difference
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsConfigureDns
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, config.CheckMode, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, checkMode bool, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput DnsPluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	// a step run in check mode is a dry run whatever its input
+	if checkMode {
+		pluginInput.DryRun = true
+	}
+	p.run(log, pluginInput, cancelFlag, output)
+}
+
+// validateInput checks the input and normalizes the names so they compare equal whatever their case
+func validateInput(pluginInput *DnsPluginInput) error {
+	if pluginInput.Action == "" {
+		pluginInput.Action = ActionGet
+	}
+	if pluginInput.Action != ActionGet && pluginInput.Action != ActionSet {
+		return fmt.Errorf("Action is set to unsupported value %v, expected %v or %v", pluginInput.Action, ActionGet, ActionSet)
+	}
+	if pluginInput.Backend != "" && !isSupported(pluginInput.Backend) {
+		return fmt.Errorf("Backend is set to unsupported value %v, expected one of %v", pluginInput.Backend, strings.Join(supportedBackends, ", "))
+	}
+	if pluginInput.Nameservers == nil && pluginInput.SearchDomains == nil && pluginInput.NrptRules == nil {
+		return fmt.Errorf("at least one of Nameservers, SearchDomains and NrptRules must be declared")
+	}
+	if err := normalizeNameservers(pluginInput.Nameservers); err != nil {
+		return err
+	}
+	for i, domain := range pluginInput.SearchDomains {
+		if pluginInput.SearchDomains[i] = strings.ToLower(strings.TrimSpace(domain)); !domainPattern.MatchString(pluginInput.SearchDomains[i]) {
+			return fmt.Errorf("search domain %q is not a valid domain name", domain)
+		}
+	}
+	namespaces := map[string]bool{}
+	for i := range pluginInput.NrptRules {
+		rule := &pluginInput.NrptRules[i]
+		rule.Namespace = strings.ToLower(strings.TrimSpace(rule.Namespace))
+		if !domainPattern.MatchString(rule.Namespace) {
+			return fmt.Errorf("NRPT namespace %q is not valid, expected a domain name or a suffix starting with a dot", rule.Namespace)
+		}
+		if namespaces[rule.Namespace] {
+			return fmt.Errorf("NRPT namespace %v is declared twice", rule.Namespace)
+		}
+		namespaces[rule.Namespace] = true
+		if len(rule.Nameservers) == 0 {
+			return fmt.Errorf("NRPT rule %v must have at least one nameserver", rule.Namespace)
+		}
+		if err := normalizeNameservers(rule.Nameservers); err != nil {
+			return fmt.Errorf("NRPT rule %v: %v", rule.Namespace, err)
+		}
+	}
+	return nil
+}
+
+func normalizeNameservers(nameservers []string) error {
+	for i, nameserver := range nameservers {
+		ip := net.ParseIP(strings.TrimSpace(nameserver))
+		if ip == nil {
+			return fmt.Errorf("nameserver %q is not an IP address", nameserver)
+		}
+		nameservers[i] = ip.String()
+	}
+	return nil
+}
+
+func isSupported(backend string) bool {
+	for _, supported := range supportedBackends {
+		if backend == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plugin) run(log log.T, pluginInput DnsPluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+	runner := commandRunner{log: log, commandExecuter: p.CommandExecuter, cancelFlag: cancelFlag, timeout: timeout}
+
+	backendName := pluginInput.Backend
+	if backendName == "" {
+		backendName = detectBackend()
+	}
+	if backendName != BackendWindowsDnsClient && (pluginInput.NrptRules != nil || pluginInput.InterfaceAliases != nil) {
+		output.MarkAsFailed(fmt.Errorf("NrptRules and InterfaceAliases are only supported by %v", BackendWindowsDnsClient))
+		return
+	}
+	resolver := newBackend(backendName, runner)
+
+	settings, err := resolver.read(pluginInput.Config)
+	if err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to read the %v settings: %v", backendName, err))
+		return
+	}
+	report := Report{
+		Action:  pluginInput.Action,
+		Backend: backendName,
+		DryRun:  pluginInput.DryRun,
+		Drift:   drift(pluginInput.Config, settings),
+	}
+
+	if pluginInput.Action == ActionSet && pluginInput.DryRun {
+		output.AppendInfof("Dry run of the DNS settings on %v, no change is made:", backendName)
+		for _, difference := range report.Drift {
+			output.AppendInfo(difference.String())
+			output.AppendCheckChanges(difference.String())
+		}
+	} else if pluginInput.Action == ActionSet && len(report.Drift) > 0 {
+		if err = resolver.write(pluginInput.Config, report.Drift); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to change the %v settings: %v", backendName, err))
+			return
+		}
+		report.Applied = report.Drift
+		for _, difference := range report.Applied {
+			output.AppendInfof("Changed DNS settings: %v", difference)
+		}
+		if settings, err = resolver.read(pluginInput.Config); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to read the %v settings: %v", backendName, err))
+			return
+		}
+		report.Drift = drift(pluginInput.Config, settings)
+	}
+	if pluginInput.Action == ActionGet || !pluginInput.DryRun {
+		for _, difference := range report.Drift {
+			output.AppendInfof("Drift of DNS settings: %v", difference)
+		}
+	}
+	report.Compliant = len(report.Drift) == 0
+
+	reportJson, _ := jsonutil.Marshal(report)
+	output.AppendInfo(jsonutil.Indent(reportJson))
+	if pluginInput.Action == ActionSet && !pluginInput.DryRun && !report.Compliant {
+		output.MarkAsFailed(fmt.Errorf("%v changes of the DNS settings did not apply", len(report.Drift)))
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+// drift returns the differences between the current settings and the configuration, the NRPT rules of the
+// configuration which aren't among the settings are added
+func drift(config Config, settings []Setting) []Difference {
+	rules := map[string][]string{}
+	for _, rule := range config.NrptRules {
+		rules[rule.Namespace] = rule.Nameservers
+	}
+	found := map[string]bool{}
+	var differences []Difference
+	for _, setting := range settings {
+		var expected []string
+		switch setting.Name {
+		case SettingNameservers:
+			expected = config.Nameservers
+		case SettingSearchDomains:
+			expected = config.SearchDomains
+		case SettingNrptRule:
+			expected = rules[setting.Scope]
+			found[setting.Scope] = true
+		}
+		if !equalValues(setting.Values, expected) {
+			differences = append(differences, Difference{Setting: setting.Name, Scope: setting.Scope, Current: setting.Values, Expected: expected})
+		}
+	}
+	for _, rule := range config.NrptRules {
+		if !found[rule.Namespace] {
+			differences = append(differences, Difference{Setting: SettingNrptRule, Scope: rule.Namespace, Expected: rule.Nameservers})
+		}
+	}
+	sort.SliceStable(differences, func(i, j int) bool {
+		if differences[i].Setting != differences[j].Setting {
+			return differences[i].Setting < differences[j].Setting
+		}
+		return differences[i].Scope < differences[j].Scope
+	})
+	return differences
+}
+
+// equalValues compares the values in order, the order of nameservers and search domains matters
+func equalValues(current []string, expected []string) bool {
+	if len(current) != len(expected) {
+		return false
+	}
+	for i := range expected {
+		if !strings.EqualFold(current[i], expected[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
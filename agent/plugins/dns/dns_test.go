@@ -0,0 +1,158 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dns
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const ec2ResolvConf = `; generated by /usr/sbin/dhclient-script
+search ec2.internal
+options timeout:2 attempts:5
+nameserver 10.0.0.2
+`
+
+// useTempFiles points resolv.conf and the drop-in of systemd-resolved at files of a temporary directory, resolv.conf
+// has the content
+func useTempFiles(t *testing.T, content string) func() {
+	directory, err := ioutil.TempDir("", "dns")
+	assert.NoError(t, err)
+	savedResolvConf, savedDropIn := resolvConfPath, resolvedDropInPath
+	resolvConfPath = filepath.Join(directory, "resolv.conf")
+	resolvedDropInPath = filepath.Join(directory, "resolved.conf.d", "amazon-ssm-agent.conf")
+	assert.NoError(t, ioutil.WriteFile(resolvConfPath, []byte(content), 0644))
+	return func() {
+		resolvConfPath, resolvedDropInPath = savedResolvConf, savedDropIn
+		os.RemoveAll(directory)
+	}
+}
+
+func TestValidateInputNormalizesNames(t *testing.T) {
+	pluginInput := DnsPluginInput{Config: Config{
+		Nameservers:   []string{" 10.0.0.2", "FD00:EC2:0:0::253"},
+		SearchDomains: []string{"Corp.Example.com"},
+		NrptRules:     []NrptRule{{Namespace: ".Corp.Example.com", Nameservers: []string{"10.1.0.10"}}},
+	}}
+
+	assert.NoError(t, validateInput(&pluginInput))
+	assert.Equal(t, ActionGet, pluginInput.Action)
+	assert.Equal(t, []string{"10.0.0.2", "fd00:ec2::253"}, pluginInput.Nameservers)
+	assert.Equal(t, []string{"corp.example.com"}, pluginInput.SearchDomains)
+	assert.Equal(t, ".corp.example.com", pluginInput.NrptRules[0].Namespace)
+}
+
+func TestValidateInputRejectsInvalidInput(t *testing.T) {
+	for name, pluginInput := range map[string]DnsPluginInput{
+		"action":         {Action: "Flush", Config: Config{Nameservers: []string{}}},
+		"backend":        {Backend: "Dnsmasq", Config: Config{Nameservers: []string{}}},
+		"nothing":        {},
+		"nameserver":     {Config: Config{Nameservers: []string{"dns.example.com"}}},
+		"search domain":  {Config: Config{SearchDomains: []string{"corp example"}}},
+		"namespace":      {Config: Config{NrptRules: []NrptRule{{Namespace: "*.corp", Nameservers: []string{"10.1.0.10"}}}}},
+		"duplicate rule": {Config: Config{NrptRules: []NrptRule{{Namespace: ".corp", Nameservers: []string{"10.1.0.10"}}, {Namespace: ".CORP", Nameservers: []string{"10.1.0.11"}}}}},
+		"empty rule":     {Config: Config{NrptRules: []NrptRule{{Namespace: ".corp"}}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, validateInput(&pluginInput))
+		})
+	}
+}
+
+func TestDrift(t *testing.T) {
+	config := Config{
+		Nameservers: []string{"10.1.0.10", "10.1.0.11"},
+		NrptRules:   []NrptRule{{Namespace: ".corp.example.com", Nameservers: []string{"10.1.0.10"}}, {Namespace: ".lab.example.com", Nameservers: []string{"10.2.0.10"}}},
+	}
+	settings := []Setting{
+		{Name: SettingNameservers, Scope: "Ethernet", Values: []string{"10.1.0.10", "10.1.0.11"}},
+		{Name: SettingNameservers, Scope: "Ethernet 2", Values: []string{"10.1.0.11", "10.1.0.10"}},
+		{Name: SettingNrptRule, Scope: ".corp.example.com", Values: []string{"10.1.0.10"}},
+		{Name: SettingNrptRule, Scope: ".old.example.com", Values: []string{"10.3.0.10"}},
+	}
+
+	differences := drift(config, settings)
+
+	var lines []string
+	for _, difference := range differences {
+		lines = append(lines, difference.String())
+	}
+	assert.Equal(t, []string{
+		"~ Nameservers Ethernet 2: [10.1.0.11, 10.1.0.10] -> [10.1.0.10, 10.1.0.11]",
+		"+ NrptRule .lab.example.com: [10.2.0.10]",
+		"- NrptRule .old.example.com: [10.3.0.10]",
+	}, lines)
+}
+
+func TestGetReportsDrift(t *testing.T) {
+	defer useTempFiles(t, ec2ResolvConf)()
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string { return "" })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Backend": BackendResolvConf, "Nameservers": []string{"10.1.0.10"}})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Empty(t, *commands)
+	assert.Contains(t, output.GetStdout(), "Drift of DNS settings: ~ Nameservers: [10.0.0.2] -> [10.1.0.10]")
+	assert.Contains(t, output.GetStdout(), `"Compliant": false`)
+	content, _ := ioutil.ReadFile(resolvConfPath)
+	assert.Equal(t, ec2ResolvConf, string(content))
+}
+
+func TestSetDryRunChangesNothing(t *testing.T) {
+	defer useTempFiles(t, ec2ResolvConf)()
+	executer, _ := pluginutil.MockCommands(func(command string, _ []string) string { return "" })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionSet, "DryRun": true, "Backend": BackendResolvConf, "SearchDomains": []string{}})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Contains(t, output.GetStdout(), "Dry run of the DNS settings on ResolvConf, no change is made:\n~ SearchDomains: [ec2.internal] -> []")
+	assert.Equal(t, []string{"~ SearchDomains: [ec2.internal] -> []"}, output.GetCheckChanges())
+	content, _ := ioutil.ReadFile(resolvConfPath)
+	assert.Equal(t, ec2ResolvConf, string(content))
+}
+
+func TestSetResolvConf(t *testing.T) {
+	defer useTempFiles(t, ec2ResolvConf)()
+	executer, _ := pluginutil.MockCommands(func(command string, _ []string) string { return "" })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionSet, "Backend": BackendResolvConf,
+		"Nameservers": []string{"10.1.0.10", "10.1.0.11"}, "SearchDomains": []string{"corp.example.com", "ec2.internal"}})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Contains(t, output.GetStdout(), "Changed DNS settings: ~ Nameservers: [10.0.0.2] -> [10.1.0.10, 10.1.0.11]")
+	assert.Contains(t, output.GetStdout(), `"Compliant": true`)
+	content, _ := ioutil.ReadFile(resolvConfPath)
+	assert.Equal(t, `; generated by /usr/sbin/dhclient-script
+search corp.example.com ec2.internal
+options timeout:2 attempts:5
+nameserver 10.1.0.10
+nameserver 10.1.0.11
+`, string(content))
+}
+
+func TestSetRejectsNrptRulesOnLinux(t *testing.T) {
+	executer, _ := pluginutil.MockCommands(func(command string, _ []string) string { return "" })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionSet, "Backend": BackendSystemdResolved,
+		"NrptRules": []map[string]interface{}{{"Namespace": ".corp", "Nameservers": []string{"10.1.0.10"}}}})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "NrptRules and InterfaceAliases are only supported by WindowsDnsClient")
+}
@@ -0,0 +1,31 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package dns
+
+import (
+	"os"
+	"strings"
+)
+
+var supportedBackends = []string{BackendSystemdResolved, BackendResolvConf}
+
+// detectBackend returns systemd-resolved when resolv.conf links to one of its files, resolv.conf otherwise
+func detectBackend() string {
+	if target, err := os.Readlink(resolvConfPath); err == nil && strings.Contains(target, "systemd/resolve") {
+		return BackendSystemdResolved
+	}
+	return BackendResolvConf
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/s3resource"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/ssmdocresource"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
 	"github.com/aws/amazon-ssm-agent/agent/task"
 
 	"errors"
@@ -147,6 +148,11 @@ func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config
 		return
 	}
 
+	if config.Simulation {
+		p.simulateCopyContent(log, remoteResource, destinationPath, config, output)
+		return
+	}
+
 	var result *remoteresource.DownloadResult
 	log.Debug("Downloading resource")
 	if err, result = remoteResource.DownloadRemoteResource(log, p.filesys, destinationPath); err != nil {
@@ -154,11 +160,27 @@ func (p *Plugin) runCopyContent(log log.T, input *DownloadContentPlugin, config
 		return
 	}
 
+	// scripts and configuration files authored on another platform are adapted to the one of the instance
+	for _, path := range result.Files {
+		if err := pluginutil.NormalizeContent(path, config.Normalize); err != nil {
+			output.MarkAsFailed(fmt.Errorf("Failed to normalize the content. Error - %v", err))
+			return
+		}
+	}
+
 	if err := setPermissions(log, result); err != nil {
 		output.MarkAsFailed(fmt.Errorf("Failed to set right permissions to the content. Error - %v", err))
 		return
 	}
 
+	// the content may be downloaded outside of the orchestration directory the policy of the document applies to
+	for _, path := range result.Files {
+		if err := pluginutil.ApplyFilePermissions(path, config.FilePermissions); err != nil {
+			output.MarkAsFailed(fmt.Errorf("Failed to apply the file permissions of the document to the content. Error - %v", err))
+			return
+		}
+	}
+
 	output.AppendInfof("Content downloaded to %v", destinationPath)
 	output.MarkAsSucceeded()
 	return
@@ -43,6 +43,9 @@ type S3Resource struct {
 // S3Info represents the sourceInfo type sent by runcommand
 type S3Info struct {
 	Path string `json:"path"`
+	// Sha256 is the checksum of the file of the path, a file with a checksum is verified and reused from the
+	// artifact cache. It is not supported for the download of a folder.
+	Sha256 string `json:"sha256,omitempty"`
 }
 
 // NewS3Resource is a constructor of type GitResource
@@ -69,6 +72,7 @@ func parseSourceInfo(sourceInfo string) (s3Info S3Info, err error) {
 
 	// Trimming the path in URL to remove any unnecessary spaces
 	s3Info.Path = strings.TrimSpace(s3Info.Path)
+	s3Info.Sha256 = strings.TrimSpace(s3Info.Sha256)
 
 	return
 }
@@ -112,6 +116,8 @@ func (s3 *S3Resource) DownloadRemoteResource(log log.T, filesys filemanager.File
 		// In case of a file download, append the filename to folders
 		isDirTypeDownloaded = false
 		folders = append(folders, s3.s3Object.Key)
+	} else if s3.Info.Sha256 != "" {
+		return fmt.Errorf("sha256 is only supported for the download of a file, %v is a folder", s3.Info.Path), nil
 	}
 
 	// The URL till the bucket name will be concatenated with the prefix in the loop
@@ -163,10 +169,17 @@ func (s3 *S3Resource) DownloadRemoteResource(log log.T, filesys filemanager.File
 				}
 			}
 			input.DestinationDirectory = localFilePath
+			if s3.Info.Sha256 != "" {
+				input.SourceChecksums = map[string]string{"sha256": s3.Info.Sha256}
+				input.Cached = true
+			}
 			downloadOutput, err := dep.Download(log, input)
 			if err != nil {
 				return err, nil
 			}
+			if input.Cached && !downloadOutput.IsHashMatched {
+				return fmt.Errorf("the sha256 checksum of %v does not match", s3.Info.Path), nil
+			}
 
 			if err = system.RenameFile(log, filesys, downloadOutput.LocalFilePath, destinationFile); err != nil {
 				return fmt.Errorf("Something went wrong when trying to access downloaded content. It is "+
@@ -186,6 +199,11 @@ func (s3 *S3Resource) ValidateLocationInfo() (valid bool, err error) {
 	if s3.Info.Path == "" {
 		return false, errors.New("S3 source path in SourceInfo must be specified")
 	}
+	if s3.Info.Sha256 != "" {
+		if err = artifact.ValidateChecksum("sha256", s3.Info.Sha256); err != nil {
+			return false, err
+		}
+	}
 
 	return true, nil
 }
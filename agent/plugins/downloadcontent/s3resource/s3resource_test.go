@@ -55,6 +55,26 @@ func TestS3Resource_ValidateLocationInfoNoPath(t *testing.T) {
 	assert.Equal(t, err.Error(), "S3 source path in SourceInfo must be specified")
 }
 
+func TestS3Resource_ValidateLocationInfoSha256(t *testing.T) {
+
+	locationInfo := `{
+		"path": "https://s3.amazonaws.com/my-bucket/file.rb",
+		"sha256": " 9a0b1bbc5e1f6e8d3c0ba0b2c7cb2d0cf03f29d0b6c8a2a8f5e3b1d1a2c4e6f8 "
+	}`
+
+	s3resource, _ := NewS3Resource(logMock, locationInfo)
+	valid, err := s3resource.ValidateLocationInfo()
+
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	s3resource, _ = NewS3Resource(logMock, `{"path": "https://s3.amazonaws.com/my-bucket/file.rb", "sha256": "abc"}`)
+	valid, err = s3resource.ValidateLocationInfo()
+
+	assert.Error(t, err)
+	assert.False(t, valid)
+}
+
 func TestIsFolder_JSON(t *testing.T) {
 	res := isPathType("nameOfFolder/nameOfFile.json")
 
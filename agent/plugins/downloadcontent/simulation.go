@@ -0,0 +1,118 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package downloadcontent
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+)
+
+// simulationDir is the directory under the orchestration directory the content is downloaded to in simulation
+const simulationDir = "simulation"
+
+// simulateCopyContent downloads the content to the orchestration directory and reports the files the download would
+// create or modify at the destination, which is left untouched
+func (p *Plugin) simulateCopyContent(log log.T, remoteResource remoteresource.RemoteResource, destinationPath string, config contracts.Configuration, output iohandler.IOHandler) {
+	stagingRoot := filepath.Join(config.OrchestrationDirectory, simulationDir)
+	defer p.filesys.DeleteDirectory(stagingRoot)
+
+	// the staged path keeps the last element and the trailing separator of the destination, which tell whether the
+	// content is downloaded as a file or into a directory
+	destination := strings.TrimRight(destinationPath, `/\`)
+	stagingPath := filepath.Join(stagingRoot, filepath.Base(destination))
+	if len(destination) < len(destinationPath) {
+		stagingPath += string(os.PathSeparator)
+	}
+
+	log.Debugf("Downloading resource to %v in simulation", stagingPath)
+	err, result := remoteResource.DownloadRemoteResource(log, p.filesys, stagingPath)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
+	var changes []string
+	for _, staged := range result.Files {
+		if err := pluginutil.NormalizeContent(staged, config.Normalize); err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+		relative, err := filepath.Rel(stagingRoot, staged)
+		if err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+		change, err := compareFile(staged, filepath.Join(filepath.Dir(destination), relative))
+		if err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+		if change != "" {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		output.AppendInfof("The content at %v is up to date", destinationPath)
+	}
+	output.AppendSimulationTrace(changes...)
+	output.MarkAsSucceeded()
+}
+
+// compareFile returns the change writing the staged file to the target would make, empty when they are the same
+func compareFile(staged string, target string) (string, error) {
+	content, err := ioutil.ReadFile(staged)
+	if err != nil {
+		return "", err
+	}
+	current, err := ioutil.ReadFile(target)
+	if os.IsNotExist(err) {
+		return fmt.Sprintf("Create %v (%v bytes)", target, len(content)), nil
+	} else if err != nil {
+		return "", err
+	}
+	if bytes.Equal(content, current) {
+		return "", nil
+	}
+	added, removed := lineDiff(string(current), string(content))
+	return fmt.Sprintf("Modify %v (%v lines added, %v lines removed)", target, added, removed), nil
+}
+
+// lineDiff counts the lines which are only in the new content and the lines which are only in the old content
+func lineDiff(old string, new string) (added int, removed int) {
+	counts := make(map[string]int)
+	for _, line := range strings.Split(old, "\n") {
+		counts[line]++
+	}
+	for _, line := range strings.Split(new, "\n") {
+		if counts[line] > 0 {
+			counts[line]--
+		} else {
+			added++
+		}
+	}
+	for _, count := range counts {
+		removed += count
+	}
+	return added, removed
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package downloadcontent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/filemanager"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/downloadcontent/remoteresource"
+	"github.com/stretchr/testify/assert"
+)
+
+// stagedResource writes its files into the directory it is downloaded to
+type stagedResource struct {
+	files map[string]string
+}
+
+func (r stagedResource) DownloadRemoteResource(log log.T, filesys filemanager.FileSystem, destinationDir string) (error, *remoteresource.DownloadResult) {
+	result := &remoteresource.DownloadResult{}
+	for name, content := range r.files {
+		path := filepath.Join(destinationDir, name)
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			return err, nil
+		}
+		result.Files = append(result.Files, path)
+	}
+	return nil, result
+}
+
+func (r stagedResource) ValidateLocationInfo() (bool, error) {
+	return true, nil
+}
+
+func TestSimulateCopyContent(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "downloadcontent")
+	defer os.RemoveAll(dir)
+	destination := filepath.Join(dir, "app")
+	os.MkdirAll(destination, 0700)
+	ioutil.WriteFile(filepath.Join(destination, "same.conf"), []byte("a\n"), 0600)
+	ioutil.WriteFile(filepath.Join(destination, "changed.conf"), []byte("a\nb\nc\n"), 0600)
+
+	resource := stagedResource{files: map[string]string{
+		"same.conf":    "a\n",
+		"changed.conf": "a\nc\nd\ne\n",
+		"new.conf":     "1234",
+	}}
+	config := contracts.Configuration{OrchestrationDirectory: filepath.Join(dir, "orchestration"), Simulation: true}
+	output := iohandler.NewDefaultIOHandler(logger, contracts.IOConfiguration{})
+	p := Plugin{filesys: filemanager.FileSystemImpl{}}
+
+	p.simulateCopyContent(logger, resource, destination+string(os.PathSeparator), config, output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	trace := output.GetSimulationTrace()
+	assert.Len(t, trace, 2)
+	assert.Contains(t, trace, "Modify "+filepath.Join(destination, "changed.conf")+" (2 lines added, 1 lines removed)")
+	assert.Contains(t, trace, "Create "+filepath.Join(destination, "new.conf")+" (4 bytes)")
+	content, _ := ioutil.ReadFile(filepath.Join(destination, "changed.conf"))
+	assert.Equal(t, "a\nb\nc\n", string(content), "the destination is not written in simulation")
+	_, err := os.Stat(filepath.Join(destination, "new.conf"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(config.OrchestrationDirectory, simulationDir))
+	assert.True(t, os.IsNotExist(err), "the staged content is removed")
+}
+
+func TestLineDiff(t *testing.T) {
+	added, removed := lineDiff("a\nb\nb\n", "b\nc\n")
+
+	assert.Equal(t, 1, added)
+	assert.Equal(t, 2, removed)
+}
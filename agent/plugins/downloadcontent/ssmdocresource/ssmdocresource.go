@@ -53,7 +53,7 @@ func NewSSMDocResource(info string) (*SSMDocResource, error) {
 	return &SSMDocResource{
 		Info: ssmDocInfo,
 		ssmdocdep: &ssmDocDepImpl{
-			ssmSvc: ssmsvc.NewService(),
+			ssmSvc: docparser.NewDocumentCachingService(ssmsvc.NewService()),
 		},
 	}, nil
 }
@@ -0,0 +1,116 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package execplugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// reservedPrefix starts the names of the plugins of the agent, exec plugins can not use it
+const reservedPrefix = "aws:"
+
+// pluginNamePattern is the format of the names of the exec plugins, a namespace and a name such as acme:configureThing
+var pluginNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*:[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Descriptor is an exec plugin found by Discover
+type Descriptor struct {
+	Name         string
+	Path         string
+	Capabilities map[string]bool
+}
+
+// Discover runs the handshake of the binaries of the configured directory and returns the plugins they implement.
+// The binaries that are not trusted, fail their handshake or implement a plugin already found are skipped.
+func Discover(log log.T, config appconfig.ExecPluginsCfg) []Descriptor {
+	if config.Directory == "" {
+		return nil
+	}
+	if err := CheckTrusted(config.Directory, true); err != nil {
+		log.Warnf("Exec plugins of %v are not loaded: %v", config.Directory, err)
+		return nil
+	}
+	entries, err := ioutil.ReadDir(config.Directory)
+	if err != nil {
+		log.Warnf("Exec plugins of %v are not loaded: %v", config.Directory, err)
+		return nil
+	}
+	var descriptors []Descriptor
+	found := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(config.Directory, entry.Name())
+		descriptor, err := describe(path, time.Duration(config.HandshakeTimeoutSeconds)*time.Second)
+		if err != nil {
+			log.Warnf("Exec plugin %v is not loaded: %v", path, err)
+			continue
+		}
+		if other, duplicate := found[descriptor.Name]; duplicate {
+			log.Warnf("Exec plugin %v is not loaded: %v already implements %v", path, other, descriptor.Name)
+			continue
+		}
+		found[descriptor.Name] = path
+		log.Infof("Loaded exec plugin %v from %v", descriptor.Name, path)
+		descriptors = append(descriptors, descriptor)
+	}
+	return descriptors
+}
+
+// describe runs the handshake of a binary
+func describe(path string, timeout time.Duration) (descriptor Descriptor, err error) {
+	if err = CheckTrusted(path, false); err != nil {
+		return descriptor, err
+	}
+	s, err := startSession(path, filepath.Dir(path))
+	if err != nil {
+		return descriptor, err
+	}
+	handshake, err := s.handshake(timeout)
+	if err != nil {
+		s.kill()
+		s.wait()
+		return descriptor, fmt.Errorf("handshake failed: %v", err)
+	}
+	if err = s.close(timeout); err != nil {
+		return descriptor, fmt.Errorf("plugin failed after its handshake: %v", err)
+	}
+	if err = validateName(handshake.Name); err != nil {
+		return descriptor, err
+	}
+	descriptor = Descriptor{Name: handshake.Name, Path: path, Capabilities: make(map[string]bool)}
+	for _, capability := range handshake.Capabilities {
+		descriptor.Capabilities[capability] = true
+	}
+	return descriptor, nil
+}
+
+// validateName checks the name of the plugin of a binary
+func validateName(name string) error {
+	if !pluginNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid plugin name %q, expected a name such as acme:configureThing", name)
+	}
+	if strings.HasPrefix(strings.ToLower(name), reservedPrefix) {
+		return fmt.Errorf("plugin name %v uses the reserved prefix %v", name, reservedPrefix)
+	}
+	return nil
+}
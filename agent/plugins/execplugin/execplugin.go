@@ -0,0 +1,201 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package execplugin
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// cancelPollInterval is how often a running plugin checks whether its step is canceled
+var cancelPollInterval = time.Second
+
+// exitTimeout is how long a plugin has to exit once it sent its result
+var exitTimeout = 10 * time.Second
+
+// Plugin runs the steps of an exec plugin with its binary
+type Plugin struct {
+	descriptor       Descriptor
+	handshakeTimeout time.Duration
+	heartbeatTimeout time.Duration
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin(descriptor Descriptor, config appconfig.ExecPluginsCfg) (*Plugin, error) {
+	return &Plugin{
+		descriptor:       descriptor,
+		handshakeTimeout: time.Duration(config.HandshakeTimeoutSeconds) * time.Second,
+		heartbeatTimeout: time.Duration(config.HeartbeatTimeoutSeconds) * time.Second,
+	}, nil
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", p.descriptor.Name, config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.run(log, config, cancelFlag, output)
+	}
+	return
+}
+
+// run runs a step with the binary of the plugin
+func (p *Plugin) run(log log.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	if err := CheckTrusted(p.descriptor.Path, false); err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodePermissionDenied, err))
+		return
+	}
+	dir := config.WorkspaceDirectory
+	if dir == "" {
+		dir = filepath.Dir(p.descriptor.Path)
+	}
+	s, err := startSession(p.descriptor.Path, dir)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to start exec plugin %v: %v", p.descriptor.Path, err))
+		return
+	}
+	defer func() {
+		if stderr := strings.TrimSpace(s.stderr.String()); stderr != "" {
+			output.AppendError(stderr)
+		}
+	}()
+
+	handshake, err := s.handshake(p.handshakeTimeout)
+	if err == nil && handshake.Name != p.descriptor.Name {
+		err = fmt.Errorf("binary now implements %v", handshake.Name)
+	}
+	if err == nil {
+		err = s.send(message{
+			Type:                   messageExecute,
+			PluginID:               config.PluginID,
+			Properties:             config.Properties,
+			OrchestrationDirectory: config.OrchestrationDirectory,
+			WorkspaceDirectory:     config.WorkspaceDirectory,
+			RebootCount:            config.RebootCount,
+		})
+	}
+	if err != nil {
+		s.kill()
+		s.wait()
+		output.MarkAsFailed(fmt.Errorf("exec plugin %v failed its handshake: %v", p.descriptor.Path, err))
+		return
+	}
+
+	result, err := p.receiveResult(log, s, cancelFlag, output)
+	if err != nil {
+		s.kill()
+		s.wait()
+		if cancelFlag.Canceled() {
+			// the plugin was stopped or stopped by itself without a result
+			p.setResult(result, cancelFlag, output)
+		} else {
+			output.MarkAsFailed(err)
+		}
+		return
+	}
+	if err = s.close(exitTimeout); err != nil {
+		log.Debugf("exec plugin %v exited after its result with %v", p.descriptor.Path, err)
+	}
+	p.setResult(result, cancelFlag, output)
+}
+
+// receiveResult handles the messages of the plugin until its result. The plugin is asked to stop, or stopped, when
+// the step is canceled.
+func (p *Plugin) receiveResult(log log.T, s *session, cancelFlag task.CancelFlag, output iohandler.IOHandler) (message, error) {
+	ticker := time.NewTicker(cancelPollInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(p.heartbeatTimeout)
+	canceling := false
+	for {
+		select {
+		case m, ok := <-s.messages:
+			if !ok {
+				return m, s.closedError()
+			}
+			deadline = time.Now().Add(p.heartbeatTimeout)
+			switch m.Type {
+			case messageResult:
+				return m, nil
+			case messageOutput:
+				if m.Stream == streamStderr {
+					output.AppendError(m.Data)
+				} else {
+					output.AppendInfo(m.Data)
+				}
+			case messageHeartbeat:
+			default:
+				log.Debugf("Ignoring %v message of exec plugin %v", m.Type, p.descriptor.Name)
+			}
+		case <-ticker.C:
+			if time.Now().After(deadline) {
+				return message{}, contracts.NewCodedError(contracts.ErrorCodeTimeout,
+					fmt.Errorf("exec plugin %v sent no heartbeat for %v", p.descriptor.Name, p.heartbeatTimeout))
+			}
+			if !canceling && cancelFlag.Canceled() {
+				canceling = true
+				if !p.descriptor.Capabilities[CapabilityCancel] {
+					log.Infof("Stopping exec plugin %v, the step is canceled", p.descriptor.Name)
+					s.kill()
+				} else if err := s.send(message{Type: messageCancel}); err != nil {
+					s.kill()
+				}
+			}
+		}
+	}
+}
+
+// setResult sets the status of the step from the result of the plugin, a canceled step stays canceled
+func (p *Plugin) setResult(result message, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+		return
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+		return
+	}
+	switch contracts.ResultStatus(result.Status) {
+	case contracts.ResultStatusSuccess:
+		output.MarkAsSucceeded()
+		output.SetExitCode(result.ExitCode)
+	case contracts.ResultStatusSuccessAndReboot:
+		if !p.descriptor.Capabilities[CapabilityReboot] {
+			output.MarkAsFailed(fmt.Errorf("exec plugin %v requested a reboot without the %v capability", p.descriptor.Name, CapabilityReboot))
+			return
+		}
+		output.MarkAsSuccessWithReboot()
+	case contracts.ResultStatusFailed:
+		output.SetExitCode(result.ExitCode)
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("exec plugin %v failed", p.descriptor.Name)
+		}
+		output.MarkAsFailed(errors.New(result.Error))
+	default:
+		output.MarkAsFailed(fmt.Errorf("exec plugin %v returned the unsupported status %q", p.descriptor.Name, result.Status))
+	}
+}
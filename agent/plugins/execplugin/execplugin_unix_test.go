@@ -0,0 +1,206 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+package execplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = appconfig.ExecPluginsCfg{HandshakeTimeoutSeconds: 5, HeartbeatTimeoutSeconds: 10}
+
+// writeHelperPlugin writes a plugin binary running TestExecPluginHelperProcess with the behavior of the mode
+func writeHelperPlugin(t *testing.T, dir string, name string, mode string) string {
+	path := filepath.Join(dir, name)
+	script := fmt.Sprintf("#!/bin/sh\nGO_WANT_HELPER_PROCESS=1 exec %q -test.run=TestExecPluginHelperProcess -- %v\n", os.Args[0], mode)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(script), 0755))
+	return path
+}
+
+func tempDir(t *testing.T) (dir string, remove func()) {
+	dir, err := ioutil.TempDir("", "execplugin")
+	assert.NoError(t, err)
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// TestExecPluginHelperProcess is not a real test, it's the plugin binary of the other tests
+func TestExecPluginHelperProcess(*testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+	mode := os.Args[len(os.Args)-1]
+	input := bufio.NewScanner(os.Stdin)
+	output := json.NewEncoder(os.Stdout)
+	var m message
+	if !input.Scan() || json.Unmarshal(input.Bytes(), &m) != nil || m.Type != messageHandshake {
+		os.Exit(2)
+	}
+	switch mode {
+	case "reserved":
+		output.Encode(message{Type: messageHandshake, ProtocolVersion: ProtocolVersion, Name: "aws:runShellScript"})
+		return
+	case "version":
+		output.Encode(message{Type: messageHandshake, ProtocolVersion: ProtocolVersion + 1, Name: "test:future"})
+		return
+	}
+	output.Encode(message{Type: messageHandshake, ProtocolVersion: ProtocolVersion, Name: "test:" + mode, Capabilities: []string{CapabilityCancel}})
+	if !input.Scan() {
+		return
+	}
+	json.Unmarshal(input.Bytes(), &m)
+	switch mode {
+	case "echo":
+		properties, _ := m.Properties.(map[string]interface{})
+		output.Encode(message{Type: messageHeartbeat})
+		output.Encode(message{Type: messageOutput, Stream: streamStdout, Data: fmt.Sprintf("%v %v", m.PluginID, properties["message"])})
+		output.Encode(message{Type: messageOutput, Stream: streamStderr, Data: "warning"})
+		fmt.Fprint(os.Stderr, "diagnostics")
+		output.Encode(message{Type: messageResult, Status: string(contracts.ResultStatusSuccess)})
+	case "fail":
+		output.Encode(message{Type: messageResult, Status: string(contracts.ResultStatusFailed), ExitCode: 4, Error: "thing is broken"})
+	case "reboot":
+		output.Encode(message{Type: messageResult, Status: string(contracts.ResultStatusSuccessAndReboot)})
+	case "crash":
+		os.Exit(3)
+	case "silent":
+		time.Sleep(time.Minute)
+	case "cancel":
+		if input.Scan() && json.Unmarshal(input.Bytes(), &m) == nil && m.Type == messageCancel {
+			output.Encode(message{Type: messageResult, Status: string(contracts.ResultStatusFailed)})
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir, remove := tempDir(t)
+	defer remove()
+	echo := writeHelperPlugin(t, dir, "echo", "echo")
+	writeHelperPlugin(t, dir, "echo-copy", "echo")
+	writeHelperPlugin(t, dir, "reserved", "reserved")
+	writeHelperPlugin(t, dir, "version", "version")
+	writable := writeHelperPlugin(t, dir, "writable", "fail")
+	assert.NoError(t, os.Chmod(writable, 0777))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("plugins"), 0644))
+
+	config := testConfig
+	config.Directory = dir
+	descriptors := Discover(log.NewMockLog(), config)
+
+	assert.Equal(t, []Descriptor{{Name: "test:echo", Path: echo, Capabilities: map[string]bool{CapabilityCancel: true}}}, descriptors)
+}
+
+func TestDiscover_Disabled(t *testing.T) {
+	assert.Empty(t, Discover(log.NewMockLog(), testConfig))
+}
+
+func TestValidateName(t *testing.T) {
+	assert.NoError(t, validateName("acme:configureThing"))
+	assert.Error(t, validateName("configureThing"))
+	assert.Error(t, validateName("acme:configure thing"))
+	assert.Error(t, validateName("AWS:configureThing"))
+}
+
+func runHelperPlugin(t *testing.T, mode string, capabilities map[string]bool, cancelFlag task.CancelFlag) *iohandler.DefaultIOHandler {
+	dir, remove := tempDir(t)
+	defer remove()
+	path := writeHelperPlugin(t, dir, mode, mode)
+	plugin, _ := NewPlugin(Descriptor{Name: "test:" + mode, Path: path, Capabilities: capabilities}, testConfig)
+	output := &iohandler.DefaultIOHandler{}
+	plugin.Execute(context.NewMockDefault(), contracts.Configuration{
+		PluginID:   "step1",
+		Properties: map[string]interface{}{"message": "hello"},
+	}, cancelFlag, output)
+	return output
+}
+
+func TestExecute(t *testing.T) {
+	output := runHelperPlugin(t, "echo", nil, task.NewChanneledCancelFlag())
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, 0, output.GetExitCode())
+	assert.Equal(t, "step1 hello", output.GetStdout())
+	assert.Contains(t, output.GetStderr(), "warning\n")
+	assert.Contains(t, output.GetStderr(), "diagnostics")
+}
+
+func TestExecute_Failures(t *testing.T) {
+	cancelPollInterval = 10 * time.Millisecond
+	defer func() { cancelPollInterval = time.Second }()
+
+	output := runHelperPlugin(t, "fail", nil, task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, 4, output.GetExitCode())
+	assert.Contains(t, output.GetStderr(), "thing is broken")
+
+	output = runHelperPlugin(t, "reboot", nil, task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "without the reboot capability")
+
+	output = runHelperPlugin(t, "reboot", map[string]bool{CapabilityReboot: true}, task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, output.GetStatus())
+
+	output = runHelperPlugin(t, "crash", nil, task.NewChanneledCancelFlag())
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "plugin exited before its result: exit status 3")
+}
+
+func TestExecute_HeartbeatTimeout(t *testing.T) {
+	cancelPollInterval = 10 * time.Millisecond
+	defer func() { cancelPollInterval = time.Second }()
+	dir, remove := tempDir(t)
+	defer remove()
+	plugin := &Plugin{
+		descriptor:       Descriptor{Name: "test:silent", Path: writeHelperPlugin(t, dir, "silent", "silent")},
+		handshakeTimeout: 5 * time.Second,
+		heartbeatTimeout: 200 * time.Millisecond,
+	}
+	output := &iohandler.DefaultIOHandler{}
+
+	plugin.Execute(context.NewMockDefault(), contracts.Configuration{}, task.NewChanneledCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, contracts.ErrorCodeTimeout, output.GetErrorCode())
+	assert.Contains(t, output.GetStderr(), "sent no heartbeat")
+}
+
+func TestExecute_Cancel(t *testing.T) {
+	cancelPollInterval = 10 * time.Millisecond
+	defer func() { cancelPollInterval = time.Second }()
+
+	for _, capabilities := range []map[string]bool{{CapabilityCancel: true}, nil} {
+		cancelFlag := task.NewChanneledCancelFlag()
+		time.AfterFunc(200*time.Millisecond, func() { cancelFlag.Set(task.Canceled) })
+
+		output := runHelperPlugin(t, "cancel", capabilities, cancelFlag)
+
+		assert.Equal(t, contracts.ResultStatusCancelled, output.GetStatus())
+	}
+}
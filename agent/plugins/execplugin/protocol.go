@@ -0,0 +1,281 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package execplugin runs out-of-tree document plugins, binaries that speak the exec plugin protocol, so custom
+// plugins can be added without building them into the agent.
+//
+// The agent and a plugin binary exchange JSON messages, one per line, over the stdin and the stdout of the binary.
+// Every run of a binary starts with a handshake: the agent sends
+//
+//	{"type":"handshake","protocolVersion":1,"agentVersion":"3.0.0.0"}
+//
+// and the binary answers with the name of its plugin and the optional capabilities it supports
+//
+//	{"type":"handshake","protocolVersion":1,"name":"acme:configureThing","capabilities":["cancel","reboot"]}
+//
+// When the agent discovers the plugins it closes stdin after the handshake and the binary exits. To run a step
+// the agent then sends the execute message with the properties of the step
+//
+//	{"type":"execute","pluginId":"configure","properties":{...},"orchestrationDirectory":"...","workspaceDirectory":"...","rebootCount":0}
+//
+// and the binary answers with any number of heartbeat and output messages, followed by one result message
+//
+//	{"type":"heartbeat"}
+//	{"type":"output","stream":"stdout","data":"configured thing"}
+//	{"type":"result","status":"Success","exitCode":0}
+//
+// The status of the result is Success, Failed or, with the reboot capability, SuccessAndReboot, which reboots the
+// instance and runs the step again. A step whose binary stays silent for longer than the heartbeat timeout is stopped
+// and fails. When the step is canceled the agent sends a cancel message to the binaries with the cancel capability
+// and stops the other binaries. The stderr of the binary is added to the error output of the step.
+package execplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+// ProtocolVersion is the version of the exec plugin protocol spoken by the agent
+const ProtocolVersion = 1
+
+// Types of the messages
+const (
+	messageHandshake = "handshake"
+	messageExecute   = "execute"
+	messageCancel    = "cancel"
+	messageHeartbeat = "heartbeat"
+	messageOutput    = "output"
+	messageResult    = "result"
+)
+
+// Capabilities a plugin declares in its handshake
+const (
+	// CapabilityCancel is declared by the plugins that stop by themselves when they receive a cancel message
+	CapabilityCancel = "cancel"
+	// CapabilityReboot is declared by the plugins that can request a reboot of the instance
+	CapabilityReboot = "reboot"
+)
+
+// Streams of the output messages
+const (
+	streamStdout = "stdout"
+	streamStderr = "stderr"
+)
+
+// maxMessageSize is the largest message accepted from a plugin
+const maxMessageSize = 1024 * 1024
+
+// message is any message of the protocol, its type tells which of the fields are set
+type message struct {
+	Type string `json:"type"`
+
+	// handshake
+	ProtocolVersion int      `json:"protocolVersion,omitempty"`
+	AgentVersion    string   `json:"agentVersion,omitempty"`
+	Name            string   `json:"name,omitempty"`
+	Capabilities    []string `json:"capabilities,omitempty"`
+
+	// execute
+	PluginID               string      `json:"pluginId,omitempty"`
+	Properties             interface{} `json:"properties,omitempty"`
+	OrchestrationDirectory string      `json:"orchestrationDirectory,omitempty"`
+	WorkspaceDirectory     string      `json:"workspaceDirectory,omitempty"`
+	RebootCount            int         `json:"rebootCount,omitempty"`
+
+	// output
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+
+	// result
+	Status   string `json:"status,omitempty"`
+	ExitCode int    `json:"exitCode,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// errTimeout is returned when a plugin stays silent for longer than expected
+var errTimeout = errors.New("timed out waiting for the plugin")
+
+// session is a running plugin binary
+type session struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	encoder  *json.Encoder
+	messages chan message
+	stderr   *limitedBuffer
+
+	readErr  error
+	waitOnce sync.Once
+	waitErr  error
+}
+
+// startSession starts a plugin binary and reads its messages in the background
+func startSession(path string, dir string) (*session, error) {
+	cmd := exec.Command(path)
+	cmd.Dir = dir
+	cmd.Env = network.AppendProxyEnvironment(os.Environ())
+	s := &session{
+		cmd:      cmd,
+		messages: make(chan message),
+		stderr:   &limitedBuffer{limit: maxMessageSize},
+	}
+	cmd.Stderr = s.stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if s.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	s.encoder = json.NewEncoder(s.stdin)
+	go s.read(stdout)
+	return s, nil
+}
+
+// read forwards the messages of the plugin until its stdout closes or holds an invalid message
+func (s *session) read(stdout io.Reader) {
+	defer close(s.messages)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			s.readErr = fmt.Errorf("invalid message from the plugin: %v", err)
+			io.Copy(ioutil.Discard, stdout)
+			return
+		}
+		s.messages <- m
+	}
+	s.readErr = scanner.Err()
+}
+
+// send writes a message to the plugin
+func (s *session) send(m message) error {
+	return s.encoder.Encode(m)
+}
+
+// receive returns the next message of the plugin, an error when the plugin closes its stdout or stays silent
+func (s *session) receive(timeout time.Duration) (message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case m, ok := <-s.messages:
+		if !ok {
+			return m, s.closedError()
+		}
+		return m, nil
+	case <-timer.C:
+		return message{}, errTimeout
+	}
+}
+
+// closedError describes why the plugin stopped sending messages
+func (s *session) closedError() error {
+	if s.readErr != nil {
+		return s.readErr
+	}
+	if err := s.wait(); err != nil {
+		return fmt.Errorf("plugin exited before its result: %v", err)
+	}
+	return errors.New("plugin exited before its result")
+}
+
+// handshake exchanges the handshake messages and returns the handshake of the plugin
+func (s *session) handshake(timeout time.Duration) (message, error) {
+	if err := s.send(message{Type: messageHandshake, ProtocolVersion: ProtocolVersion, AgentVersion: version.Version}); err != nil {
+		return message{}, err
+	}
+	m, err := s.receive(timeout)
+	if err != nil {
+		return m, err
+	}
+	if m.Type != messageHandshake {
+		return m, fmt.Errorf("expected a handshake message, received %v", m.Type)
+	}
+	if m.ProtocolVersion != ProtocolVersion {
+		return m, fmt.Errorf("unsupported protocol version %v, the agent speaks version %v", m.ProtocolVersion, ProtocolVersion)
+	}
+	return m, nil
+}
+
+// close closes the stdin of the plugin and waits for it to exit, it is stopped when it is still running after the timeout
+func (s *session) close(timeout time.Duration) error {
+	s.stdin.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- s.wait() }()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(timeout):
+		s.kill()
+		return <-exited
+	}
+}
+
+// kill stops the plugin
+func (s *session) kill() {
+	if s.cmd.Process != nil {
+		s.cmd.Process.Kill()
+	}
+}
+
+// wait waits for the plugin to exit, the messages not read yet are discarded
+func (s *session) wait() error {
+	s.waitOnce.Do(func() {
+		go func() {
+			for range s.messages {
+			}
+		}()
+		s.waitErr = s.cmd.Wait()
+	})
+	return s.waitErr
+}
+
+// limitedBuffer keeps the beginning of what is written to it
+type limitedBuffer struct {
+	lock  sync.Mutex
+	data  []byte
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if room := b.limit - len(b.data); room > 0 {
+		if len(p) > room {
+			b.data = append(b.data, p[:room]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return string(b.data)
+}
@@ -0,0 +1,44 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd linux netbsd openbsd
+
+package execplugin
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// CheckTrusted checks that a directory or binary run by the agent is owned by root or the user of the agent and that
+// other users can not modify it
+func CheckTrusted(path string, isDir bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if isDir != info.IsDir() || (!isDir && !info.Mode().IsRegular()) {
+		return fmt.Errorf("%v is not a regular file or directory", path)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%v is owned by user %v instead of root or the user of the agent", path, stat.Uid)
+	}
+	if info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%v is writable by other users, its permissions are %v", path, info.Mode().Perm())
+	}
+	if !isDir && info.Mode().Perm()&0100 == 0 {
+		return fmt.Errorf("%v is not executable", path)
+	}
+	return nil
+}
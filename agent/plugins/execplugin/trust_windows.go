@@ -0,0 +1,33 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build windows
+
+package execplugin
+
+import (
+	"fmt"
+	"os"
+)
+
+// CheckTrusted checks that a directory or binary run by the agent exists, on Windows the directory is protected by its ACL
+func CheckTrusted(path string, isDir bool) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if isDir != info.IsDir() || (!isDir && !info.Mode().IsRegular()) {
+		return fmt.Errorf("%v is not a regular file or directory", path)
+	}
+	return nil
+}
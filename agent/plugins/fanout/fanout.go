@@ -0,0 +1,356 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package fanout implements the aws:fanOut plugin, which runs the script steps of a sub-document against the local
+// targets of the agent, such as the containers and the virtual machines of an edge gateway, and aggregates the results
+// of every target into the output of the step.
+package fanout
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/rundocument"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+// Plugin is the type for the fanOut plugin.
+type Plugin struct {
+	CommandExecuter executers.T
+}
+
+// FanOutPluginInput represents the sub-document a step runs and the targets it runs it against.
+type FanOutPluginInput struct {
+	contracts.PluginInput
+	ID string
+	// Targets are the names of the configured targets the sub-document runs against, all of them when it is empty
+	Targets            []string
+	DocumentType       string
+	DocumentPath       string
+	DocumentParameters interface{}
+}
+
+// TargetResult is the result of the sub-document on a target
+type TargetResult struct {
+	Target   string
+	Status   contracts.ResultStatus
+	ExitCode int
+	Error    string `json:",omitempty"`
+}
+
+// scriptStep holds the properties of the script steps of the sub-document the plugin runs on the targets
+type scriptStep struct {
+	RunCommand     []string
+	TimeoutSeconds interface{}
+}
+
+// fanOutConfig returns the targets configured for the agent
+var fanOutConfig = func() appconfig.FanOutCfg {
+	appConfig, _ := appconfig.Config(false)
+	return appConfig.FanOut
+}
+
+// loadSubDocument returns the steps of the sub-document
+var loadSubDocument = rundocument.LoadSubDocument
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	return &Plugin{CommandExecuter: executers.ShellCommandExecuter{}}, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsFanOut
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput FanOutPluginInput
+	err := jsonutil.Remarshal(config.Properties, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", config.Properties, err))
+		return
+	}
+	fanOut := fanOutConfig()
+	targets, err := selectTargets(fanOut.Targets, pluginInput.Targets)
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+	pluginsInfo, err := loadSubDocument(log, config, pluginInput.DocumentType, pluginInput.DocumentPath, pluginInput.DocumentParameters)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	if err = validateSteps(pluginsInfo); err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+	p.run(log, targets, pluginsInfo, fanOut.MaxConcurrency, cancelFlag, output)
+}
+
+// selectTargets returns the configured targets named by the step, or all of them when the step names none
+func selectTargets(configured []appconfig.FanOutTargetCfg, names []string) ([]appconfig.FanOutTargetCfg, error) {
+	if len(configured) == 0 {
+		return nil, fmt.Errorf("No fan-out targets are configured for the agent")
+	}
+	if len(names) == 0 {
+		return configured, nil
+	}
+	byName := make(map[string]appconfig.FanOutTargetCfg, len(configured))
+	for _, target := range configured {
+		byName[target.Name] = target
+	}
+	var targets []appconfig.FanOutTargetCfg
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		target, found := byName[name]
+		if !found {
+			return nil, fmt.Errorf("Target %v is not configured for the agent", name)
+		}
+		if !selected[name] {
+			selected[name] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets, nil
+}
+
+// validateSteps checks the sub-document only holds script steps, the only steps the plugin can run on a target
+func validateSteps(pluginsInfo []contracts.PluginState) error {
+	if len(pluginsInfo) == 0 {
+		return fmt.Errorf("The sub-document has no steps")
+	}
+	for _, pluginInfo := range pluginsInfo {
+		if pluginInfo.Name != appconfig.PluginNameAwsRunShellScript && pluginInfo.Name != appconfig.PluginNameAwsRunPowerShellScript {
+			return fmt.Errorf("Step %v uses %v, the sub-document can only use %v and %v", pluginInfo.Id, pluginInfo.Name,
+				appconfig.PluginNameAwsRunShellScript, appconfig.PluginNameAwsRunPowerShellScript)
+		}
+	}
+	return nil
+}
+
+// run runs the sub-document on the targets, at most maxConcurrency at a time, and aggregates their results in the order
+// of the targets
+func (p *Plugin) run(log log.T, targets []appconfig.FanOutTargetCfg, pluginsInfo []contracts.PluginState, maxConcurrency int, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+	results := make([]TargetResult, len(targets))
+	stdouts := make([]bytes.Buffer, len(targets))
+	stderrs := make([]bytes.Buffer, len(targets))
+	slots := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = p.runOnTarget(log, targets[i], pluginsInfo, cancelFlag, &stdouts[i], &stderrs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	status := contracts.ResultStatusSuccess
+	for i, result := range results {
+		appendPrefixed(output.AppendInfo, result.Target, stdouts[i].String())
+		appendPrefixed(output.AppendError, result.Target, stderrs[i].String())
+		if result.Error != "" {
+			output.AppendErrorf("[%v] %v", result.Target, result.Error)
+		}
+		status = contracts.MergeResultStatus(status, result.Status)
+	}
+	report, _ := jsonutil.Marshal(results)
+	output.AppendInfo(jsonutil.Indent(report))
+	switch status {
+	case contracts.ResultStatusSuccess:
+		output.MarkAsSucceeded()
+	case contracts.ResultStatusCancelled:
+		output.MarkAsCancelled()
+	default:
+		output.SetExitCode(1)
+		output.SetStatus(contracts.ResultStatusFailed)
+	}
+}
+
+// runOnTarget runs the steps of the sub-document on the target one after the other, until a step fails
+func (p *Plugin) runOnTarget(log log.T, target appconfig.FanOutTargetCfg, pluginsInfo []contracts.PluginState, cancelFlag task.CancelFlag, stdout, stderr *bytes.Buffer) TargetResult {
+	result := TargetResult{Target: target.Name, Status: contracts.ResultStatusSuccess}
+	for _, pluginInfo := range pluginsInfo {
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			result.Status = contracts.ResultStatusCancelled
+			return result
+		}
+		var step scriptStep
+		if err := jsonutil.Remarshal(pluginInfo.Configuration.Properties, &step); err != nil {
+			result.Status, result.Error = contracts.ResultStatusFailed, fmt.Sprintf("step %v: invalid properties: %v", pluginInfo.Id, err)
+			return result
+		}
+		powerShell := pluginInfo.Name == appconfig.PluginNameAwsRunPowerShellScript
+		script := strings.Join(step.RunCommand, "\n") + "\n"
+		commandName, commandArguments, stdin, err := targetCommand(target, powerShell, script)
+		if err != nil {
+			result.Status, result.Error = contracts.ResultStatusFailed, fmt.Sprintf("step %v: %v", pluginInfo.Id, err)
+			return result
+		}
+		log.Debugf("Running step %v on target %v with %v %v", pluginInfo.Id, target.Name, commandName, commandArguments)
+		options := executers.ExecuteOptions{}
+		if stdin != "" {
+			options.Stdin = strings.NewReader(stdin)
+		}
+		timeout := pluginutil.ValidateExecutionTimeout(log, step.TimeoutSeconds)
+		exitCode, err := p.CommandExecuter.NewExecuteWithOptions(log, "", stdout, stderr, cancelFlag, timeout, options, commandName, commandArguments)
+		result.ExitCode = exitCode
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			result.Status = contracts.ResultStatusCancelled
+			return result
+		}
+		if err != nil || exitCode != 0 {
+			result.Status = contracts.ResultStatusFailed
+			if err != nil {
+				result.Error = fmt.Sprintf("step %v: %v", pluginInfo.Id, err)
+			} else {
+				result.Error = fmt.Sprintf("step %v exited with code %v", pluginInfo.Id, exitCode)
+			}
+			return result
+		}
+	}
+	return result
+}
+
+// appendPrefixed appends the lines of a target to the output, each prefixed with the name of the target
+func appendPrefixed(appendFunc func(string), target string, text string) {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "[" + target + "] " + line
+	}
+	appendFunc(strings.Join(lines, "\n"))
+}
+
+// targetCommand returns the command running the script on the target and the input streamed to it
+func targetCommand(target appconfig.FanOutTargetCfg, powerShell bool, script string) (commandName string, commandArguments []string, stdin string, err error) {
+	switch target.Type {
+	case appconfig.FanOutTargetSsh:
+		commandName, commandArguments = sshCommand(target, powerShell)
+		return commandName, commandArguments, script, nil
+	case appconfig.FanOutTargetContainer:
+		commandName, commandArguments = containerCommand(target, powerShell)
+		return commandName, commandArguments, script, nil
+	case appconfig.FanOutTargetWinRm:
+		if !powerShell {
+			return "", nil, "", fmt.Errorf("%v targets only run %v steps", target.Type, appconfig.PluginNameAwsRunPowerShellScript)
+		}
+		commandName, commandArguments, err = winRmCommand(target, script)
+		return commandName, commandArguments, "", err
+	}
+	return "", nil, "", fmt.Errorf("unsupported target type %v", target.Type)
+}
+
+// remoteShell returns the command reading the script from its standard input on the target
+func remoteShell(powerShell bool) []string {
+	if powerShell {
+		return []string{"pwsh", "-NoProfile", "-NonInteractive", "-Command", "-"}
+	}
+	return []string{"sh", "-s"}
+}
+
+// sshCommand returns the ssh command running the script on the target, which only connects to hosts whose keys are known
+func sshCommand(target appconfig.FanOutTargetCfg, powerShell bool) (string, []string) {
+	args := []string{"-o", "BatchMode=yes", "-o", "StrictHostKeyChecking=yes"}
+	if target.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(target.Port))
+	}
+	if target.IdentityFile != "" {
+		args = append(args, "-i", target.IdentityFile)
+	}
+	if target.User != "" {
+		args = append(args, "-l", target.User)
+	}
+	args = append(args, "--", target.Address, strings.Join(remoteShell(powerShell), " "))
+	return "ssh", args
+}
+
+// containerCommand returns the command running the script in the container
+func containerCommand(target appconfig.FanOutTargetCfg, powerShell bool) (string, []string) {
+	runtime := strings.Fields(target.ContainerRuntime)
+	if len(runtime) == 0 {
+		runtime = []string{"docker"}
+	}
+	args := append(runtime[1:], "exec", "-i")
+	if target.User != "" {
+		args = append(args, "-u", target.User)
+	}
+	args = append(args, target.Address)
+	return runtime[0], append(args, remoteShell(powerShell)...)
+}
+
+// winRmCommand returns the PowerShell command running the script on the target through PowerShell remoting, as the
+// identity of the agent
+func winRmCommand(target appconfig.FanOutTargetCfg, script string) (string, []string, error) {
+	if strings.ContainsAny(target.Address, "'\"`$;") {
+		return "", nil, fmt.Errorf("invalid address %v", target.Address)
+	}
+	command := fmt.Sprintf("Invoke-Command -ComputerName '%v'", target.Address)
+	if target.Port != 0 {
+		command += fmt.Sprintf(" -Port %v", target.Port)
+	}
+	command += fmt.Sprintf(" -ErrorAction Stop -ScriptBlock ([scriptblock]::Create([Text.Encoding]::UTF8.GetString([Convert]::FromBase64String('%v'))))",
+		base64.StdEncoding.EncodeToString([]byte(script)))
+	return appconfig.PowerShellPluginCommandName, []string{"-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowerShell(command)}, nil
+}
+
+// encodePowerShell encodes a command for the -EncodedCommand argument of PowerShell, base64 of its UTF-16LE bytes
+func encodePowerShell(command string) string {
+	units := utf16.Encode([]rune(command))
+	encoded := make([]byte, 0, 2*len(units))
+	for _, unit := range units {
+		encoded = append(encoded, byte(unit), byte(unit>>8))
+	}
+	return base64.StdEncoding.EncodeToString(encoded)
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package fanout
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// executerStub runs no command, it records the commands and answers with the exit code of the target
+type executerStub struct {
+	executers.T
+	mu        sync.Mutex
+	commands  []string
+	scripts   []string
+	exitCodes map[string]int
+}
+
+func (e *executerStub) NewExecuteWithOptions(log log.T, workingDir string, stdout io.Writer, stderr io.Writer, cancelFlag task.CancelFlag, timeout int, options executers.ExecuteOptions, commandName string, commandArguments []string) (int, error) {
+	command := commandName + " " + strings.Join(commandArguments, " ")
+	var script string
+	if options.Stdin != nil {
+		data, _ := ioutil.ReadAll(options.Stdin)
+		script = string(data)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.commands = append(e.commands, command)
+	e.scripts = append(e.scripts, script)
+	for address, exitCode := range e.exitCodes {
+		if strings.Contains(command, address) {
+			fmt.Fprintf(stderr, "failed on %v\n", address)
+			return exitCode, nil
+		}
+	}
+	fmt.Fprintf(stdout, "ran %v\n", strings.TrimSpace(script))
+	return 0, nil
+}
+
+var testTargets = []appconfig.FanOutTargetCfg{
+	{Name: "web", Type: appconfig.FanOutTargetContainer, Address: "web-1"},
+	{Name: "db", Type: appconfig.FanOutTargetSsh, Address: "10.0.0.5", Port: 2222, User: "admin", IdentityFile: "/etc/ssm/id"},
+	{Name: "win", Type: appconfig.FanOutTargetWinRm, Address: "win-1"},
+}
+
+func shellStep(id string, commands ...string) contracts.PluginState {
+	return contracts.PluginState{
+		Id:            id,
+		Name:          appconfig.PluginNameAwsRunShellScript,
+		Configuration: contracts.Configuration{Properties: map[string]interface{}{"runCommand": commands}},
+	}
+}
+
+func runPlugin(executer *executerStub, targets []appconfig.FanOutTargetCfg, steps []contracts.PluginState, properties map[string]interface{}) *iohandler.DefaultIOHandler {
+	defer func(config func() appconfig.FanOutCfg, load func(log.T, contracts.Configuration, string, string, interface{}) ([]contracts.PluginState, error)) {
+		fanOutConfig, loadSubDocument = config, load
+	}(fanOutConfig, loadSubDocument)
+	fanOutConfig = func() appconfig.FanOutCfg { return appconfig.FanOutCfg{Targets: targets, MaxConcurrency: 2} }
+	loadSubDocument = func(log.T, contracts.Configuration, string, string, interface{}) ([]contracts.PluginState, error) {
+		return steps, nil
+	}
+
+	output := &iohandler.DefaultIOHandler{}
+	(&Plugin{CommandExecuter: executer}).runRawInput(log.NewMockLog(), contracts.Configuration{Properties: properties}, task.NewChanneledCancelFlag(), output)
+	return output
+}
+
+func TestFanOutAggregatesTheTargets(t *testing.T) {
+	executer := &executerStub{}
+
+	output := runPlugin(executer, testTargets[:2], []contracts.PluginState{shellStep("a", "hostname"), shellStep("b", "uptime")}, map[string]interface{}{})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Len(t, executer.commands, 4)
+	assert.Contains(t, output.GetStdout(), "[web] ran hostname")
+	assert.Contains(t, output.GetStdout(), "[db] ran uptime")
+	assert.Contains(t, output.GetStdout(), `"Target": "db"`)
+}
+
+func TestFanOutFailsWhenATargetFails(t *testing.T) {
+	executer := &executerStub{exitCodes: map[string]int{"web-1": 3}}
+
+	output := runPlugin(executer, testTargets[:2], []contracts.PluginState{shellStep("a", "hostname"), shellStep("b", "uptime")}, map[string]interface{}{})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	// the target stops at its failed step, the other target runs both steps
+	assert.Len(t, executer.commands, 3)
+	assert.Contains(t, output.GetStderr(), "[web] failed on web-1")
+	assert.Contains(t, output.GetStderr(), "[web] step a exited with code 3")
+	assert.Contains(t, output.GetStdout(), "[db] ran uptime")
+}
+
+func TestFanOutSelectsTheNamedTargets(t *testing.T) {
+	executer := &executerStub{}
+
+	output := runPlugin(executer, testTargets, []contracts.PluginState{shellStep("a", "hostname")}, map[string]interface{}{"Targets": []string{"db"}})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{"ssh -o BatchMode=yes -o StrictHostKeyChecking=yes -p 2222 -i /etc/ssm/id -l admin -- 10.0.0.5 sh -s"}, executer.commands)
+	assert.Equal(t, []string{"hostname\n"}, executer.scripts)
+
+	output = runPlugin(executer, testTargets, []contracts.PluginState{shellStep("a", "hostname")}, map[string]interface{}{"Targets": []string{"cache"}})
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "Target cache is not configured")
+}
+
+func TestFanOutRejectsUnsupportedSteps(t *testing.T) {
+	step := contracts.PluginState{Id: "copy", Name: appconfig.PluginNameAwsLifecycleAction}
+
+	output := runPlugin(&executerStub{}, testTargets, []contracts.PluginState{step}, map[string]interface{}{})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "can only use")
+}
+
+func TestContainerCommand(t *testing.T) {
+	name, args := containerCommand(appconfig.FanOutTargetCfg{Address: "web-1"}, false)
+	assert.Equal(t, "docker", name)
+	assert.Equal(t, []string{"exec", "-i", "web-1", "sh", "-s"}, args)
+
+	name, args = containerCommand(appconfig.FanOutTargetCfg{Address: "web-1", User: "app", ContainerRuntime: "podman --remote"}, true)
+	assert.Equal(t, "podman", name)
+	assert.Equal(t, []string{"--remote", "exec", "-i", "-u", "app", "web-1", "pwsh", "-NoProfile", "-NonInteractive", "-Command", "-"}, args)
+}
+
+func TestWinRmCommand(t *testing.T) {
+	_, _, _, err := targetCommand(testTargets[2], false, "hostname\n")
+	assert.Error(t, err)
+
+	_, args, stdin, err := targetCommand(appconfig.FanOutTargetCfg{Type: appconfig.FanOutTargetWinRm, Address: "win-1", Port: 5986}, true, "Get-Service\n")
+	assert.NoError(t, err)
+	assert.Empty(t, stdin)
+	assert.Equal(t, "-EncodedCommand", args[2])
+	command := decodePowerShell(t, args[3])
+	assert.Contains(t, command, "Invoke-Command -ComputerName 'win-1' -Port 5986")
+	assert.Contains(t, command, base64.StdEncoding.EncodeToString([]byte("Get-Service\n")))
+
+	_, _, err = winRmCommand(appconfig.FanOutTargetCfg{Address: "win'; Remove-Item C:\\ -Recurse; '"}, "Get-Service\n")
+	assert.Error(t, err)
+}
+
+func decodePowerShell(t *testing.T, encoded string) string {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	assert.NoError(t, err)
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,442 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package firewall
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// BackendWindowsFirewall manages the rules of Windows Firewall
+	BackendWindowsFirewall = "WindowsFirewall"
+	// BackendFirewalld manages direct rules of firewalld, they are the firewalld rules which take a comment
+	BackendFirewalld = "Firewalld"
+	// BackendUfw manages the rules of ufw
+	BackendUfw = "Ufw"
+	// BackendNftables manages the rules of a table of its own in nftables
+	BackendNftables = "Nftables"
+
+	// nftablesTable is the table holding the rules of all the rule sets with nftables
+	nftablesTable = "amazon_ssm"
+)
+
+// backend is a firewall the plugin manages the rules of, the rules are identified by their tag
+type backend interface {
+	// tags returns the tags of the rules of the rule set in the firewall, a tag is repeated when a rule has
+	// several entries in the firewall
+	tags(ruleSet string) ([]string, error)
+	// add adds the rule with the tag to the firewall
+	add(ruleSet string, rule Rule, tag string) error
+	// remove removes the rules with the tag from the firewall, tags must list the rules first
+	remove(ruleSet string, tag string) error
+	// commit makes the changes effective for the firewalls which stage them
+	commit() error
+}
+
+func newBackend(name string, runner commandRunner) backend {
+	switch name {
+	case BackendWindowsFirewall:
+		return &windowsFirewall{commandRunner: runner}
+	case BackendFirewalld:
+		return &firewalld{commandRunner: runner}
+	case BackendUfw:
+		return &ufw{commandRunner: runner}
+	default:
+		return &nftables{commandRunner: runner}
+	}
+}
+
+// commandRunner runs the commands of the backends
+type commandRunner struct {
+	log             log.T
+	commandExecuter executers.T
+	cancelFlag      task.CancelFlag
+	timeout         int
+}
+
+// run runs the command and returns its standard output
+func (r commandRunner) run(command string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := r.commandExecuter.NewExecute(r.log, "", &stdout, &stderr, r.cancelFlag, r.timeout, command, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// windowsFirewall groups the rules of a rule set in a Windows Firewall group named after their tag prefix
+type windowsFirewall struct {
+	commandRunner
+}
+
+func (w *windowsFirewall) tags(ruleSet string) ([]string, error) {
+	stdout, err := w.powerShell(fmt.Sprintf("Get-NetFirewallRule -Group %v -ErrorAction SilentlyContinue | ForEach-Object { $_.Name }",
+		quote(tagPrefix+ruleSet)))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(stdout), nil
+}
+
+func (w *windowsFirewall) add(ruleSet string, rule Rule, tag string) error {
+	protocol := rule.Protocol
+	if protocol == ProtocolICMP {
+		protocol = "ICMPv4"
+	}
+	command := fmt.Sprintf("New-NetFirewallRule -Name %v -DisplayName %v -Group %v -Direction %v -Action %v -Protocol %v",
+		quote(tag), quote(ruleSet+" "+rule.Name), quote(tagPrefix+ruleSet), rule.Direction, rule.Action, protocol)
+	if len(rule.Ports) > 0 {
+		portParameter := "-LocalPort"
+		if rule.Direction == DirectionOutbound {
+			portParameter = "-RemotePort"
+		}
+		command += fmt.Sprintf(" %v %v", portParameter, quoteAll(rule.Ports))
+	}
+	if len(rule.RemoteAddresses) > 0 {
+		command += " -RemoteAddress " + quoteAll(rule.RemoteAddresses)
+	}
+	_, err := w.powerShell(command + " | Out-Null")
+	return err
+}
+
+func (w *windowsFirewall) remove(ruleSet string, tag string) error {
+	_, err := w.powerShell("Remove-NetFirewallRule -Name " + quote(tag))
+	return err
+}
+
+func (w *windowsFirewall) commit() error {
+	return nil
+}
+
+func (w *windowsFirewall) powerShell(script string) (string, error) {
+	return w.run(appconfig.PowerShellPluginCommandName, "-NoProfile", "-NonInteractive", "-Command", "$ErrorActionPreference = 'Stop'\n"+script)
+}
+
+// quote returns the value as a PowerShell literal string
+func quote(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+func quoteAll(values []string) string {
+	var quoted []string
+	for _, value := range values {
+		quoted = append(quoted, quote(value))
+	}
+	return strings.Join(quoted, ",")
+}
+
+// firewalld adds one iptables rule with a comment for each address family and remote address of a rule
+type firewalld struct {
+	commandRunner
+	// rules are the direct rules of each tag, as firewalld prints and removes them
+	rules map[string][][]string
+}
+
+func (f *firewalld) tags(ruleSet string) ([]string, error) {
+	stdout, err := f.run("firewall-cmd", "--permanent", "--direct", "--get-all-rules")
+	if err != nil {
+		return nil, err
+	}
+	f.rules = map[string][][]string{}
+	var tags []string
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "--comment" {
+				t := strings.Trim(fields[i+1], `"'`)
+				if _, ok := ruleName(ruleSet, t); ok {
+					f.rules[t] = append(f.rules[t], fields)
+					tags = append(tags, t)
+				}
+			}
+		}
+	}
+	return tags, nil
+}
+
+func (f *firewalld) add(ruleSet string, rule Rule, tag string) error {
+	chain, addressFlag := "INPUT", "-s"
+	if rule.Direction == DirectionOutbound {
+		chain, addressFlag = "OUTPUT", "-d"
+	}
+	for _, target := range addressTargets(rule) {
+		args := []string{"--permanent", "--direct", "--add-rule", target.family, "filter", chain, "0"}
+		switch rule.Protocol {
+		case ProtocolTCP, ProtocolUDP:
+			args = append(args, "-p", strings.ToLower(rule.Protocol))
+		case ProtocolICMP:
+			if target.family == "ipv4" {
+				args = append(args, "-p", "icmp")
+			} else {
+				args = append(args, "-p", "ipv6-icmp")
+			}
+		}
+		if len(rule.Ports) > 0 {
+			args = append(args, "-m", "multiport", "--dports", strings.Replace(strings.Join(rule.Ports, ","), "-", ":", -1))
+		}
+		if target.address != "" {
+			args = append(args, addressFlag, target.address)
+		}
+		args = append(args, "-m", "comment", "--comment", tag, "-j", iptablesTarget(rule))
+		if _, err := f.run("firewall-cmd", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *firewalld) remove(ruleSet string, tag string) error {
+	for _, rule := range f.rules[tag] {
+		args := append([]string{"--permanent", "--direct", "--remove-rule"}, rule...)
+		if _, err := f.run("firewall-cmd", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *firewalld) commit() error {
+	_, err := f.run("firewall-cmd", "--reload")
+	return err
+}
+
+func iptablesTarget(rule Rule) string {
+	if rule.Action == RuleBlock {
+		return "DROP"
+	}
+	return "ACCEPT"
+}
+
+// addressTarget is an address family and a remote address of it, or any address of the family
+type addressTarget struct {
+	family  string
+	address string
+}
+
+// addressTargets returns the targets of the rule, the firewalls which filter each address family separately
+// need an entry for each of them
+func addressTargets(rule Rule) []addressTarget {
+	if len(rule.RemoteAddresses) == 0 {
+		return []addressTarget{{family: "ipv4"}, {family: "ipv6"}}
+	}
+	var targets []addressTarget
+	for _, address := range rule.RemoteAddresses {
+		if strings.Contains(address, ":") {
+			targets = append(targets, addressTarget{family: "ipv6", address: address})
+		} else {
+			targets = append(targets, addressTarget{family: "ipv4", address: address})
+		}
+	}
+	return targets
+}
+
+// ufw adds one ufw rule with a comment for each remote address of a rule
+type ufw struct {
+	commandRunner
+	// rules are the ufw arguments of the rules of each tag
+	rules map[string][][]string
+}
+
+// ufwRulePattern matches the rules with a comment listed by ufw show added
+var ufwRulePattern = regexp.MustCompile(`^ufw (.*) comment '([^']*)'$`)
+
+func (u *ufw) tags(ruleSet string) ([]string, error) {
+	stdout, err := u.run("ufw", "show", "added")
+	if err != nil {
+		return nil, err
+	}
+	u.rules = map[string][][]string{}
+	var tags []string
+	for _, line := range strings.Split(stdout, "\n") {
+		match := ufwRulePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		if _, ok := ruleName(ruleSet, match[2]); ok {
+			u.rules[match[2]] = append(u.rules[match[2]], strings.Fields(match[1]))
+			tags = append(tags, match[2])
+		}
+	}
+	return tags, nil
+}
+
+func (u *ufw) add(ruleSet string, rule Rule, tag string) error {
+	if rule.Protocol == ProtocolICMP {
+		return fmt.Errorf("ufw rules don't filter %v", ProtocolICMP)
+	}
+	addresses := rule.RemoteAddresses
+	if len(addresses) == 0 {
+		addresses = []string{"any"}
+	}
+	for _, address := range addresses {
+		args := []string{strings.ToLower(rule.Action), "in"}
+		if rule.Action == RuleBlock {
+			args[0] = "deny"
+		}
+		if rule.Direction == DirectionOutbound {
+			args[1] = "out"
+		}
+		if rule.Protocol != ProtocolAny {
+			args = append(args, "proto", strings.ToLower(rule.Protocol))
+		}
+		ports := strings.Replace(strings.Join(rule.Ports, ","), "-", ":", -1)
+		if rule.Direction == DirectionInbound {
+			args = append(args, "from", address, "to", "any")
+		} else {
+			args = append(args, "to", address)
+		}
+		if ports != "" {
+			args = append(args, "port", ports)
+		}
+		args = append(args, "comment", tag)
+		if _, err := u.run("ufw", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *ufw) remove(ruleSet string, tag string) error {
+	for _, rule := range u.rules[tag] {
+		if _, err := u.run("ufw", append([]string{"delete"}, rule...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *ufw) commit() error {
+	return nil
+}
+
+// nftables adds the rules to base chains of a table the plugin owns, with one rule for each remote address
+type nftables struct {
+	commandRunner
+	// rules are the chain and handle of the rules of each tag
+	rules        map[string][][2]string
+	tableCreated bool
+}
+
+// nftablesRulePattern matches the rules with a comment listed by nft -a
+var nftablesRulePattern = regexp.MustCompile(`comment "([^"]*)" # handle ([0-9]+)$`)
+
+func (n *nftables) tags(ruleSet string) ([]string, error) {
+	stdout, err := n.run("nft", "-a", "list", "table", "inet", nftablesTable)
+	if err != nil {
+		if strings.Contains(err.Error(), "No such file or directory") {
+			// the table is created with the first rule
+			return nil, nil
+		}
+		return nil, err
+	}
+	n.rules = map[string][][2]string{}
+	var tags []string
+	chain := ""
+	for _, line := range strings.Split(stdout, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "chain ") {
+			chain = strings.Fields(line)[1]
+			continue
+		}
+		match := nftablesRulePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if _, ok := ruleName(ruleSet, match[1]); ok {
+			n.rules[match[1]] = append(n.rules[match[1]], [2]string{chain, match[2]})
+			tags = append(tags, match[1])
+		}
+	}
+	return tags, nil
+}
+
+func (n *nftables) add(ruleSet string, rule Rule, tag string) error {
+	if err := n.createTable(); err != nil {
+		return err
+	}
+	chain, addressSelector := "input", "saddr"
+	if rule.Direction == DirectionOutbound {
+		chain, addressSelector = "output", "daddr"
+	}
+	targets := []addressTarget{{}}
+	if len(rule.RemoteAddresses) > 0 {
+		targets = addressTargets(rule)
+	}
+	for _, target := range targets {
+		args := []string{"add", "rule", "inet", nftablesTable, chain}
+		if target.address != "" {
+			args = append(args, map[string]string{"ipv4": "ip", "ipv6": "ip6"}[target.family], addressSelector, target.address)
+		}
+		switch {
+		case len(rule.Ports) > 0:
+			args = append(args, strings.ToLower(rule.Protocol), "dport", "{", strings.Join(rule.Ports, ", "), "}")
+		case rule.Protocol == ProtocolICMP:
+			args = append(args, "meta", "l4proto", "{", "icmp,", "ipv6-icmp", "}")
+		case rule.Protocol != ProtocolAny:
+			args = append(args, "meta", "l4proto", strings.ToLower(rule.Protocol))
+		}
+		verdict := "accept"
+		if rule.Action == RuleBlock {
+			verdict = "drop"
+		}
+		args = append(args, verdict, "comment", `"`+tag+`"`)
+		if _, err := n.run("nft", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTable creates the table and its base chains, nft doesn't fail when they exist
+func (n *nftables) createTable() error {
+	if n.tableCreated {
+		return nil
+	}
+	commands := [][]string{
+		{"add", "table", "inet", nftablesTable},
+		{"add", "chain", "inet", nftablesTable, "input", "{", "type", "filter", "hook", "input", "priority", "0", ";", "policy", "accept", ";", "}"},
+		{"add", "chain", "inet", nftablesTable, "output", "{", "type", "filter", "hook", "output", "priority", "0", ";", "policy", "accept", ";", "}"},
+	}
+	for _, args := range commands {
+		if _, err := n.run("nft", args...); err != nil {
+			return err
+		}
+	}
+	n.tableCreated = true
+	return nil
+}
+
+func (n *nftables) remove(ruleSet string, tag string) error {
+	for _, rule := range n.rules[tag] {
+		if _, err := n.run("nft", "delete", "rule", "inet", nftablesTable, rule[0], "handle", rule[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *nftables) commit() error {
+	return nil
+}
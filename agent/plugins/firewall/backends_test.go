@@ -0,0 +1,111 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package firewall
+
+import (
+	"io"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newRunner(run func(commandLine string, args []string) string) (commandRunner, *[]string) {
+	executer, commands := pluginutil.MockCommands(run)
+	return commandRunner{log: log.NewMockLog(), commandExecuter: executer, cancelFlag: task.NewChanneledCancelFlag()}, commands
+}
+
+func TestWindowsFirewall(t *testing.T) {
+	runner, commands := newRunner(func(command string, _ []string) string { return "ssm:web:https:0a1b2c3d\r\n" })
+	firewall := newBackend(BackendWindowsFirewall, runner)
+	rule := Rule{Name: "api", Direction: DirectionOutbound, Action: RuleBlock, Protocol: ProtocolUDP, Ports: []string{"53"}, RemoteAddresses: []string{"10.0.0.2"}}
+
+	tags, err := firewall.tags("web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssm:web:https:0a1b2c3d"}, tags)
+	assert.NoError(t, firewall.add("web", rule, "ssm:web:api:00"))
+	assert.NoError(t, firewall.remove("web", "ssm:web:https:0a1b2c3d"))
+
+	assert.Contains(t, (*commands)[0], "Get-NetFirewallRule -Group 'ssm:web'")
+	assert.Contains(t, (*commands)[1], "New-NetFirewallRule -Name 'ssm:web:api:00' -DisplayName 'web api' -Group 'ssm:web' "+
+		"-Direction Outbound -Action Block -Protocol UDP -RemotePort '53' -RemoteAddress '10.0.0.2' | Out-Null")
+	assert.Contains(t, (*commands)[2], "Remove-NetFirewallRule -Name 'ssm:web:https:0a1b2c3d'")
+}
+
+func TestFirewalld(t *testing.T) {
+	runner, commands := newRunner(func(command string, _ []string) string {
+		return "ipv4 filter INPUT 0 -p tcp -m comment --comment ssm:web:old:00 -j ACCEPT\n" +
+			"ipv4 filter INPUT 0 -p tcp -m comment --comment ssm:other:x:00 -j ACCEPT\n"
+	})
+	firewall := newBackend(BackendFirewalld, runner)
+	rule := Rule{Name: "https", Direction: DirectionInbound, Action: RuleAllow, Protocol: ProtocolTCP, Ports: []string{"443", "8000-8100"}}
+
+	tags, err := firewall.tags("web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssm:web:old:00"}, tags)
+	assert.NoError(t, firewall.remove("web", "ssm:web:old:00"))
+	assert.NoError(t, firewall.add("web", rule, "ssm:web:https:00"))
+	assert.NoError(t, firewall.commit())
+
+	assert.Equal(t, []string{
+		"firewall-cmd --permanent --direct --get-all-rules",
+		"firewall-cmd --permanent --direct --remove-rule ipv4 filter INPUT 0 -p tcp -m comment --comment ssm:web:old:00 -j ACCEPT",
+		"firewall-cmd --permanent --direct --add-rule ipv4 filter INPUT 0 -p tcp -m multiport --dports 443,8000:8100 -m comment --comment ssm:web:https:00 -j ACCEPT",
+		"firewall-cmd --permanent --direct --add-rule ipv6 filter INPUT 0 -p tcp -m multiport --dports 443,8000:8100 -m comment --comment ssm:web:https:00 -j ACCEPT",
+		"firewall-cmd --reload",
+	}, *commands)
+}
+
+func TestUfw(t *testing.T) {
+	runner, commands := newRunner(func(command string, _ []string) string {
+		return "Added user rules (see 'ufw status' for running firewall):\n" +
+			"ufw allow 22/tcp comment 'ssm:web:old:00'\n" +
+			"ufw allow 80/tcp\n"
+	})
+	firewall := newBackend(BackendUfw, runner)
+	rule := Rule{Name: "https", Direction: DirectionInbound, Action: RuleAllow, Protocol: ProtocolTCP, Ports: []string{"443"},
+		RemoteAddresses: []string{"10.0.0.0/8", "fd00::/8"}}
+
+	tags, err := firewall.tags("web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ssm:web:old:00"}, tags)
+	assert.NoError(t, firewall.remove("web", "ssm:web:old:00"))
+	assert.NoError(t, firewall.add("web", rule, "ssm:web:https:00"))
+	assert.Error(t, firewall.add("web", Rule{Name: "ping", Protocol: ProtocolICMP}, "ssm:web:ping:00"))
+
+	assert.Equal(t, []string{
+		"ufw show added",
+		"ufw delete allow 22/tcp",
+		"ufw allow in proto tcp from 10.0.0.0/8 to any port 443 comment ssm:web:https:00",
+		"ufw allow in proto tcp from fd00::/8 to any port 443 comment ssm:web:https:00",
+	}, *commands)
+}
+
+func TestNftablesMissingTable(t *testing.T) {
+	executer := new(executers.MockCommandExecuter)
+	executer.On("NewExecute", mock.Anything, "", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			io.WriteString(args.Get(3).(io.Writer), "Error: No such file or directory\nlist table inet amazon_ssm\n")
+		}).Return(1, nil)
+	runner := commandRunner{log: log.NewMockLog(), commandExecuter: executer, cancelFlag: task.NewChanneledCancelFlag()}
+
+	tags, err := newBackend(BackendNftables, runner).tags("web")
+
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+}
@@ -0,0 +1,452 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package firewall implements the aws:configureFirewall plugin, it manages named sets of host firewall rules
+// declaratively with Windows Firewall, firewalld, ufw or nftables and reports their drift.
+package firewall
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// ActionGet reports the drift of the rule set from the plugin input
+	ActionGet = "Get"
+	// ActionSet applies the plugin input to the rule set
+	ActionSet = "Set"
+
+	// Directions of a rule
+	DirectionInbound  = "Inbound"
+	DirectionOutbound = "Outbound"
+
+	// Actions of a rule
+	RuleAllow = "Allow"
+	RuleBlock = "Block"
+
+	// Protocols of a rule
+	ProtocolAny  = "Any"
+	ProtocolTCP  = "TCP"
+	ProtocolUDP  = "UDP"
+	ProtocolICMP = "ICMP"
+
+	// Operations of a change
+	OperationAdd     = "Add"
+	OperationRemove  = "Remove"
+	OperationReplace = "Replace"
+
+	// tagPrefix prefixes the tags identifying the rules the plugin manages in the firewall
+	tagPrefix = "ssm:"
+)
+
+// namePattern restricts the names of rule sets and rules to what every firewall accepts in a rule tag
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Plugin is the type for the configureFirewall plugin.
+type Plugin struct {
+	// CommandExecuter runs the commands reading and changing the firewall rules.
+	CommandExecuter executers.T
+}
+
+// FirewallPluginInput represents the rule set a step gets or sets.
+type FirewallPluginInput struct {
+	contracts.PluginInput
+	ID      string
+	Action  string
+	DryRun  bool
+	Backend string
+	RuleSet string
+	Rules   []Rule
+	// TimeoutSeconds bounds each command run against the firewall
+	TimeoutSeconds interface{}
+}
+
+// Rule is a firewall rule, ports are the local ports of inbound rules and the remote ports of outbound rules.
+type Rule struct {
+	Name            string
+	Direction       string
+	Action          string
+	Protocol        string
+	Ports           []string `json:",omitempty"`
+	RemoteAddresses []string `json:",omitempty"`
+}
+
+// String describes the rule for the output of the plugin
+func (r Rule) String() string {
+	description := fmt.Sprintf("%v %v %v", r.Direction, r.Action, r.Protocol)
+	if len(r.Ports) > 0 {
+		description += " port " + strings.Join(r.Ports, ",")
+	}
+	if len(r.RemoteAddresses) > 0 {
+		description += " remote " + strings.Join(r.RemoteAddresses, ",")
+	}
+	return description
+}
+
+// Change is a rule to add to or remove from the firewall, a replaced rule is removed and added again
+type Change struct {
+	Operation string
+	Name      string
+	Rule      *Rule    `json:",omitempty"`
+	Tags      []string `json:",omitempty"`
+}
+
+// String returns the change as a line of a diff
+func (c Change) String() string {
+	switch c.Operation {
+	case OperationAdd:
+		return fmt.Sprintf("+ %v: %v", c.Name, c.Rule)
+	case OperationRemove:
+		return fmt.Sprintf("- %v", c.Name)
+	default:
+		return fmt.Sprintf("~ %v: %v", c.Name, c.Rule)
+	}
+}
+
+// Report is the output of the plugin
+type Report struct {
+	Action    string
+	Backend   string
+	RuleSet   string
+	DryRun    bool `json:",omitempty"`
+	Compliant bool
+	Applied   []Change `json:",omitempty"`
+	Drift     []Change
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsConfigureFirewall
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, config.CheckMode, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, checkMode bool, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput FirewallPluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	// a step run in check mode is a dry run whatever its input
+	if checkMode {
+		pluginInput.DryRun = true
+	}
+	p.run(log, pluginInput, cancelFlag, output)
+}
+
+// validateInput checks the input and normalizes the rules so they compare equal whatever their spelling
+func validateInput(pluginInput *FirewallPluginInput) error {
+	if pluginInput.Action == "" {
+		pluginInput.Action = ActionGet
+	}
+	if pluginInput.Action != ActionGet && pluginInput.Action != ActionSet {
+		return fmt.Errorf("Action is set to unsupported value %v, expected %v or %v", pluginInput.Action, ActionGet, ActionSet)
+	}
+	if pluginInput.Backend != "" && !isSupported(pluginInput.Backend) {
+		return fmt.Errorf("Backend is set to unsupported value %v, expected one of %v", pluginInput.Backend, strings.Join(supportedBackends, ", "))
+	}
+	if !namePattern.MatchString(pluginInput.RuleSet) {
+		return fmt.Errorf("RuleSet %q is not valid, it must be letters, digits, '_', '.' and '-'", pluginInput.RuleSet)
+	}
+	names := map[string]bool{}
+	for i := range pluginInput.Rules {
+		rule := &pluginInput.Rules[i]
+		if err := normalizeRule(rule); err != nil {
+			return fmt.Errorf("rule %v: %v", rule.Name, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %v is declared twice", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+func normalizeRule(rule *Rule) (err error) {
+	if !namePattern.MatchString(rule.Name) {
+		return fmt.Errorf("Name %q is not valid, it must be letters, digits, '_', '.' and '-'", rule.Name)
+	}
+	if rule.Direction, err = oneOf("Direction", rule.Direction, DirectionInbound, DirectionInbound, DirectionOutbound); err != nil {
+		return err
+	}
+	if rule.Action, err = oneOf("Action", rule.Action, RuleAllow, RuleAllow, RuleBlock); err != nil {
+		return err
+	}
+	if rule.Protocol, err = oneOf("Protocol", rule.Protocol, ProtocolAny, ProtocolAny, ProtocolTCP, ProtocolUDP, ProtocolICMP); err != nil {
+		return err
+	}
+	if len(rule.Ports) > 0 && rule.Protocol != ProtocolTCP && rule.Protocol != ProtocolUDP {
+		return fmt.Errorf("Ports require the %v or %v protocol", ProtocolTCP, ProtocolUDP)
+	}
+	for i, port := range rule.Ports {
+		if rule.Ports[i], err = normalizePort(port); err != nil {
+			return err
+		}
+	}
+	for i, address := range rule.RemoteAddresses {
+		if rule.RemoteAddresses[i], err = normalizeAddress(address); err != nil {
+			return err
+		}
+	}
+	sort.Strings(rule.Ports)
+	sort.Strings(rule.RemoteAddresses)
+	return nil
+}
+
+// oneOf returns the allowed value matching the value case insensitively, or the default of an empty value
+func oneOf(field string, value string, defaultValue string, allowed ...string) (string, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	for _, candidate := range allowed {
+		if strings.EqualFold(value, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%v is set to unsupported value %v, expected one of %v", field, value, strings.Join(allowed, ", "))
+}
+
+// normalizePort checks a port or a range of ports, written 8000-8100
+func normalizePort(port string) (string, error) {
+	bounds := strings.Split(strings.TrimSpace(port), "-")
+	if len(bounds) > 2 {
+		return "", fmt.Errorf("port %v is not valid", port)
+	}
+	var numbers []int
+	for _, bound := range bounds {
+		number, err := strconv.Atoi(strings.TrimSpace(bound))
+		if err != nil || number < 1 || number > 65535 {
+			return "", fmt.Errorf("port %v is not valid", port)
+		}
+		numbers = append(numbers, number)
+	}
+	if len(numbers) == 2 && numbers[0] >= numbers[1] {
+		return "", fmt.Errorf("port range %v is not valid", port)
+	}
+	if len(numbers) == 2 {
+		return fmt.Sprintf("%v-%v", numbers[0], numbers[1]), nil
+	}
+	return strconv.Itoa(numbers[0]), nil
+}
+
+// addressPattern matches the IPv4 and IPv6 addresses and networks, the firewalls check them further
+var addressPattern = regexp.MustCompile(`^[0-9A-Fa-f.:]+(/[0-9]{1,3})?$`)
+
+func normalizeAddress(address string) (string, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !addressPattern.MatchString(address) {
+		return "", fmt.Errorf("remote address %v is not valid, expected an IP address or network", address)
+	}
+	return address, nil
+}
+
+// tag returns the tag of the rule in the firewall, it changes with the rule so changed rules are found without
+// reading them back from the firewall
+func tag(ruleSet string, rule Rule) string {
+	ruleJson, _ := jsonutil.Marshal(rule)
+	sum := sha256.Sum256([]byte(ruleJson))
+	return fmt.Sprintf("%v%v:%v:%v", tagPrefix, ruleSet, rule.Name, hex.EncodeToString(sum[:4]))
+}
+
+// ruleName returns the name of the rule of the rule set with the tag, or false for the tags of other rules
+func ruleName(ruleSet string, tag string) (string, bool) {
+	parts := strings.Split(tag, ":")
+	if len(parts) != 4 || parts[0]+":" != tagPrefix || parts[1] != ruleSet || parts[2] == "" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// drift returns the changes making the rules in the firewall match the rule set, the tags are those of the rules
+// of the rule set found in the firewall
+func drift(ruleSet string, rules []Rule, tags []string) []Change {
+	current := map[string][]string{}
+	for _, t := range tags {
+		if name, ok := ruleName(ruleSet, t); ok {
+			current[name] = appendUnique(current[name], t)
+		}
+	}
+	var changes []Change
+	declared := map[string]bool{}
+	for i := range rules {
+		rule := rules[i]
+		declared[rule.Name] = true
+		existing := current[rule.Name]
+		switch {
+		case len(existing) == 0:
+			changes = append(changes, Change{Operation: OperationAdd, Name: rule.Name, Rule: &rule})
+		case len(existing) > 1 || existing[0] != tag(ruleSet, rule):
+			changes = append(changes, Change{Operation: OperationReplace, Name: rule.Name, Rule: &rule, Tags: existing})
+		}
+	}
+	for name, existing := range current {
+		if !declared[name] {
+			changes = append(changes, Change{Operation: OperationRemove, Name: name, Tags: existing})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func isSupported(backend string) bool {
+	for _, supported := range supportedBackends {
+		if backend == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plugin) run(log log.T, pluginInput FirewallPluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+	runner := commandRunner{log: log, commandExecuter: p.CommandExecuter, cancelFlag: cancelFlag, timeout: timeout}
+
+	backendName := pluginInput.Backend
+	if backendName == "" {
+		backendName = detectBackend(runner)
+		if backendName == "" {
+			output.MarkAsFailed(fmt.Errorf("no supported firewall is running, expected one of %v", strings.Join(supportedBackends, ", ")))
+			return
+		}
+	}
+	firewall := newBackend(backendName, runner)
+
+	tags, err := firewall.tags(pluginInput.RuleSet)
+	if err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to list the %v rules: %v", backendName, err))
+		return
+	}
+	report := Report{
+		Action:  pluginInput.Action,
+		Backend: backendName,
+		RuleSet: pluginInput.RuleSet,
+		DryRun:  pluginInput.DryRun,
+		Drift:   drift(pluginInput.RuleSet, pluginInput.Rules, tags),
+	}
+
+	if pluginInput.Action == ActionSet && pluginInput.DryRun {
+		output.AppendInfof("Dry run of rule set %v on %v, no change is made:", pluginInput.RuleSet, backendName)
+		for _, change := range report.Drift {
+			output.AppendInfo(change.String())
+			output.AppendCheckChanges(change.String())
+		}
+	} else if pluginInput.Action == ActionSet && len(report.Drift) > 0 {
+		if err = apply(firewall, pluginInput.RuleSet, report.Drift); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to change the %v rules: %v", backendName, err))
+			return
+		}
+		report.Applied = report.Drift
+		for _, change := range report.Applied {
+			output.AppendInfof("Changed firewall rules: %v", change)
+		}
+		if tags, err = firewall.tags(pluginInput.RuleSet); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to list the %v rules: %v", backendName, err))
+			return
+		}
+		report.Drift = drift(pluginInput.RuleSet, pluginInput.Rules, tags)
+	}
+	if pluginInput.Action == ActionGet || !pluginInput.DryRun {
+		for _, change := range report.Drift {
+			output.AppendInfof("Drift of firewall rules: %v", change)
+		}
+	}
+	report.Compliant = len(report.Drift) == 0
+
+	reportJson, _ := jsonutil.Marshal(report)
+	output.AppendInfo(jsonutil.Indent(reportJson))
+	if pluginInput.Action == ActionSet && !pluginInput.DryRun && !report.Compliant {
+		output.MarkAsFailed(fmt.Errorf("%v changes of rule set %v did not apply", len(report.Drift), pluginInput.RuleSet))
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+// apply removes the rules that changed or aren't declared anymore before it adds the new rules
+func apply(firewall backend, ruleSet string, changes []Change) error {
+	for _, change := range changes {
+		for _, t := range change.Tags {
+			if err := firewall.remove(ruleSet, t); err != nil {
+				return fmt.Errorf("%v: %v", change.Name, err)
+			}
+		}
+	}
+	for _, change := range changes {
+		if change.Rule == nil {
+			continue
+		}
+		if err := firewall.add(ruleSet, *change.Rule, tag(ruleSet, *change.Rule)); err != nil {
+			return fmt.Errorf("%v: %v", change.Name, err)
+		}
+	}
+	return firewall.commit()
+}
+
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package firewall
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+var web = Rule{Name: "https", Direction: DirectionInbound, Action: RuleAllow, Protocol: ProtocolTCP, Ports: []string{"443"}}
+
+// nftablesListing returns the table of the plugin with a rule for each tag
+func nftablesListing(tags ...string) string {
+	listing := "table inet amazon_ssm { # handle 3\n\tchain input { # handle 1\n\t\ttype filter hook input priority filter; policy accept;\n"
+	for i, t := range tags {
+		listing += fmt.Sprintf("\t\ttcp dport { 443 } accept comment \"%v\" # handle %v\n", t, i+10)
+	}
+	return listing + "\t}\n}\n"
+}
+
+func TestValidateInputNormalizesRules(t *testing.T) {
+	pluginInput := FirewallPluginInput{
+		RuleSet: "web",
+		Rules:   []Rule{{Name: "app", Protocol: "tcp", Action: "block", Ports: []string{"9000 - 9100", "80"}, RemoteAddresses: []string{"FD00::/8"}}},
+	}
+
+	assert.NoError(t, validateInput(&pluginInput))
+	assert.Equal(t, ActionGet, pluginInput.Action)
+	assert.Equal(t, Rule{
+		Name:            "app",
+		Direction:       DirectionInbound,
+		Action:          RuleBlock,
+		Protocol:        ProtocolTCP,
+		Ports:           []string{"80", "9000-9100"},
+		RemoteAddresses: []string{"fd00::/8"},
+	}, pluginInput.Rules[0])
+}
+
+func TestValidateInputRejectsInvalidRules(t *testing.T) {
+	for _, test := range []struct {
+		pluginInput FirewallPluginInput
+		err         string
+	}{
+		{FirewallPluginInput{}, `RuleSet "" is not valid`},
+		{FirewallPluginInput{RuleSet: "web", Action: "Apply"}, "Action is set to unsupported value Apply"},
+		{FirewallPluginInput{RuleSet: "web", Backend: "pf"}, "Backend is set to unsupported value pf"},
+		{FirewallPluginInput{RuleSet: "web", Rules: []Rule{{Name: "a b"}}}, `Name "a b" is not valid`},
+		{FirewallPluginInput{RuleSet: "web", Rules: []Rule{{Name: "a", Ports: []string{"80"}}}}, "Ports require the TCP or UDP protocol"},
+		{FirewallPluginInput{RuleSet: "web", Rules: []Rule{{Name: "a", Protocol: "UDP", Ports: []string{"70000"}}}}, "port 70000 is not valid"},
+		{FirewallPluginInput{RuleSet: "web", Rules: []Rule{{Name: "a", Protocol: "UDP", Ports: []string{"90-80"}}}}, "port range 90-80 is not valid"},
+		{FirewallPluginInput{RuleSet: "web", Rules: []Rule{{Name: "a", RemoteAddresses: []string{"example.com"}}}}, "remote address example.com is not valid"},
+		{FirewallPluginInput{RuleSet: "web", Rules: []Rule{{Name: "a"}, {Name: "a"}}}, "rule a is declared twice"},
+	} {
+		err := validateInput(&test.pluginInput)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestDrift(t *testing.T) {
+	ssh := Rule{Name: "ssh", Direction: DirectionInbound, Action: RuleAllow, Protocol: ProtocolTCP, Ports: []string{"22"}}
+	changedSSH := ssh
+	changedSSH.RemoteAddresses = []string{"10.0.0.0/8"}
+	tags := []string{tag("web", web), tag("web", ssh), tag("web", ssh), tag("web", Rule{Name: "old"}), tag("other", Rule{Name: "x"})}
+
+	changes := drift("web", []Rule{web, changedSSH, {Name: "dns"}}, tags)
+
+	assert.Equal(t, []string{"+ dns: " + Rule{}.String(), "- old", "~ ssh: " + changedSSH.String()},
+		[]string{changes[0].String(), changes[1].String(), changes[2].String()})
+	assert.Equal(t, []string{tag("web", ssh)}, changes[2].Tags)
+	assert.Empty(t, drift("web", []Rule{web}, []string{tag("web", web)}))
+}
+
+func TestRuleName(t *testing.T) {
+	name, ok := ruleName("web", tag("web", web))
+	assert.True(t, ok)
+	assert.Equal(t, "https", name)
+
+	_, ok = ruleName("web", tag("web-prod", web))
+	assert.False(t, ok)
+	_, ok = ruleName("web", "ssm:web:https")
+	assert.False(t, ok)
+}
+
+func TestSetDryRunReportsDiff(t *testing.T) {
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string {
+		return nftablesListing(tag("web", Rule{Name: "old"}))
+	})
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"Action":  ActionSet,
+		"DryRun":  true,
+		"Backend": BackendNftables,
+		"RuleSet": "web",
+		"Rules":   []interface{}{map[string]interface{}{"Name": "https", "Protocol": "TCP", "Ports": []interface{}{"443"}}},
+	})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{"nft -a list table inet amazon_ssm"}, *commands)
+	assert.Contains(t, output.GetStdout(), "Dry run of rule set web on Nftables, no change is made:\n+ https: Inbound Allow TCP port 443\n- old\n")
+	assert.Contains(t, output.GetStdout(), `"Compliant": false`)
+}
+
+func TestSetInCheckModeIsDryRun(t *testing.T) {
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string {
+		return nftablesListing(tag("web", Rule{Name: "old"}))
+	})
+	plugin := &Plugin{CommandExecuter: executer}
+	output := &iohandler.DefaultIOHandler{}
+
+	plugin.runRawInput(log.NewMockLog(), map[string]interface{}{
+		"Action":  ActionSet,
+		"Backend": BackendNftables,
+		"RuleSet": "web",
+		"Rules":   []interface{}{map[string]interface{}{"Name": "https", "Protocol": "TCP", "Ports": []interface{}{"443"}}},
+	}, true, task.NewChanneledCancelFlag(), output)
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{"nft -a list table inet amazon_ssm"}, *commands)
+	assert.Equal(t, []string{"+ https: Inbound Allow TCP port 443", "- old"}, output.GetCheckChanges())
+}
+
+func TestSetAppliesChangesWithNftables(t *testing.T) {
+	listings := []string{nftablesListing(tag("web", Rule{Name: "old"})), nftablesListing(tag("web", web))}
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string {
+		if strings.HasPrefix(command, "nft -a list") {
+			listing := listings[0]
+			listings = listings[1:]
+			return listing
+		}
+		return ""
+	})
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"Action":  ActionSet,
+		"Backend": BackendNftables,
+		"RuleSet": "web",
+		"Rules":   []interface{}{map[string]interface{}{"Name": "https", "Protocol": "TCP", "Ports": []interface{}{"443"}}},
+	})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{
+		"nft -a list table inet amazon_ssm",
+		"nft delete rule inet amazon_ssm input handle 10",
+		"nft add table inet amazon_ssm",
+		"nft add chain inet amazon_ssm input { type filter hook input priority 0 ; policy accept ; }",
+		"nft add chain inet amazon_ssm output { type filter hook output priority 0 ; policy accept ; }",
+		"nft add rule inet amazon_ssm input tcp dport { 443 } accept comment \"" + tag("web", web) + "\"",
+		"nft -a list table inet amazon_ssm",
+	}, *commands)
+	assert.Contains(t, output.GetStdout(), "Changed firewall rules: - old")
+	assert.Contains(t, output.GetStdout(), `"Compliant": true`)
+}
+
+func TestSetFailsWhenDriftRemains(t *testing.T) {
+	executer, _ := pluginutil.MockCommands(func(command string, _ []string) string { return nftablesListing() })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{
+		"Action":  ActionSet,
+		"Backend": BackendNftables,
+		"RuleSet": "web",
+		"Rules":   []interface{}{map[string]interface{}{"Name": "https", "Protocol": "TCP", "Ports": []interface{}{"443"}}},
+	})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "Drift of firewall rules: + https")
+}
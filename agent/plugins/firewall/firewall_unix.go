@@ -0,0 +1,35 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package firewall
+
+import "strings"
+
+var supportedBackends = []string{BackendFirewalld, BackendUfw, BackendNftables}
+
+// detectBackend returns the firewall running on the instance, firewalld and ufw manage nftables when they run
+// so they come first
+func detectBackend(runner commandRunner) string {
+	if _, err := runner.run("firewall-cmd", "--state"); err == nil {
+		return BackendFirewalld
+	}
+	if stdout, err := runner.run("ufw", "status"); err == nil && strings.Contains(stdout, "Status: active") {
+		return BackendUfw
+	}
+	if _, err := runner.run("nft", "list", "tables"); err == nil {
+		return BackendNftables
+	}
+	return ""
+}
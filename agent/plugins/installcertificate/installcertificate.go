@@ -0,0 +1,322 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package installcertificate implements the aws:installCertificate plugin, it installs a certificate from the
+// plugin input, Secrets Manager or AWS Certificate Manager into a certificate store of the instance.
+package installcertificate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// Windows certificate store locations
+	StoreLocationLocalMachine = "LocalMachine"
+	StoreLocationCurrentUser  = "CurrentUser"
+
+	defaultStoreName = "My"
+	defaultKeytool   = "keytool"
+)
+
+// Plugin is the type for the installCertificate plugin.
+type Plugin struct {
+	// CommandExecuter runs the commands adding the certificate to the stores.
+	CommandExecuter executers.T
+}
+
+// InstallCertificatePluginInput represents the certificate a step installs and the store it installs it into.
+// Passwords and private keys should be given as Parameter Store secure string references.
+type InstallCertificatePluginInput struct {
+	contracts.PluginInput
+	ID     string
+	Source string
+
+	// Inline source, the certificate may be followed by its chain
+	Certificate          string
+	CertificateChain     string
+	PrivateKey           string
+	PrivateKeyPassphrase string
+
+	// SecretsManager source
+	SecretId string
+
+	// ACM source, the private key can only be exported for private certificates
+	CertificateArn   string
+	ExportPrivateKey bool
+
+	Store string
+
+	// Windows store
+	StoreLocation string
+	StoreName     string
+	Exportable    bool
+
+	// Jks store, the alias defaults to the thumbprint of the certificate
+	KeystorePath     string
+	KeystoreType     string
+	KeystorePassword string
+	Alias            string
+	KeytoolPath      string
+
+	// PemFiles store, the chain follows the certificate when it has no file of its own
+	CertificatePath string
+	PrivateKeyPath  string
+	ChainPath       string
+
+	SkipChainVerification bool
+	TimeoutSeconds        interface{}
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsInstallCertificate
+}
+
+// Execute runs the plugin, the configuration isn't logged since it may hold a private key.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started", Name())
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, config.OrchestrationDirectory, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, orchestrationDir string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput InstallCertificatePluginInput
+	if err := jsonutil.Remarshal(rawPluginInput, &pluginInput); err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties: %v", err))
+		return
+	}
+	if err := validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	p.run(log, pluginInput, orchestrationDir, cancelFlag, output)
+}
+
+// validateInput checks the input and sets the defaults of the fields left out
+func validateInput(pluginInput *InstallCertificatePluginInput) error {
+	switch pluginInput.Source {
+	case "", SourceInline:
+		pluginInput.Source = SourceInline
+		if strings.TrimSpace(pluginInput.Certificate) == "" {
+			return errors.New("Certificate is required when Source is " + SourceInline)
+		}
+	case SourceSecretsManager:
+		if pluginInput.SecretId == "" {
+			return errors.New("SecretId is required when Source is " + SourceSecretsManager)
+		}
+	case SourceACM:
+		if pluginInput.CertificateArn == "" {
+			return errors.New("CertificateArn is required when Source is " + SourceACM)
+		}
+	default:
+		return fmt.Errorf("Source is set to unsupported value %v, expected %v, %v or %v",
+			pluginInput.Source, SourceInline, SourceSecretsManager, SourceACM)
+	}
+
+	if pluginInput.Store == "" {
+		pluginInput.Store = defaultStore
+	}
+	if !isSupported(pluginInput.Store) {
+		return fmt.Errorf("Store is set to unsupported value %v, expected one of %v", pluginInput.Store, strings.Join(supportedStores, ", "))
+	}
+	switch pluginInput.Store {
+	case StoreWindows:
+		if pluginInput.StoreLocation == "" {
+			pluginInput.StoreLocation = StoreLocationLocalMachine
+		}
+		if pluginInput.StoreLocation != StoreLocationLocalMachine && pluginInput.StoreLocation != StoreLocationCurrentUser {
+			return fmt.Errorf("StoreLocation is set to unsupported value %v, expected %v or %v",
+				pluginInput.StoreLocation, StoreLocationLocalMachine, StoreLocationCurrentUser)
+		}
+		if pluginInput.StoreName == "" {
+			pluginInput.StoreName = defaultStoreName
+		}
+		if strings.ContainsAny(pluginInput.StoreName, `\/`) {
+			return fmt.Errorf("StoreName %v is not valid", pluginInput.StoreName)
+		}
+	case StoreJks:
+		if pluginInput.KeystorePath == "" || pluginInput.KeystorePassword == "" {
+			return errors.New("KeystorePath and KeystorePassword are required when Store is " + StoreJks)
+		}
+		if pluginInput.KeytoolPath == "" {
+			pluginInput.KeytoolPath = defaultKeytool
+		}
+	case StorePemFiles:
+		if pluginInput.CertificatePath == "" {
+			return errors.New("CertificatePath is required when Store is " + StorePemFiles)
+		}
+	}
+	return nil
+}
+
+func isSupported(store string) bool {
+	for _, supported := range supportedStores {
+		if store == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plugin) run(log log.T, pluginInput InstallCertificatePluginInput, orchestrationDir string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+
+	m, err := loadMaterial(log, pluginInput)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	certificateThumbprint := thumbprint(m.certificate)
+	if pluginInput.Store == StoreJks && pluginInput.Alias == "" {
+		pluginInput.Alias = strings.ToLower(certificateThumbprint)
+	}
+	if err = checkPrivateKey(pluginInput, m); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	if pluginInput.SkipChainVerification {
+		output.AppendInfof("Skipped the verification of the certificate chain of %v", certificateThumbprint)
+	} else if err = m.verify(); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+
+	if err = fileutil.MakeDirsWithExecuteAccess(orchestrationDir); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	store := p.newStore(log, pluginInput, orchestrationDir, cancelFlag, timeout)
+	location := storeLocation(pluginInput)
+	installed, err := store.installed(m)
+	if err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to look for certificate %v in %v: %v", certificateThumbprint, location, err))
+		return
+	}
+	if installed {
+		output.AppendInfof("Certificate %v with thumbprint %v is already installed in %v, no change", m.certificate.Subject, certificateThumbprint, location)
+		output.MarkAsSucceeded()
+		return
+	}
+
+	if err = store.install(m); err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to install certificate %v in %v: %v", certificateThumbprint, location, err))
+		return
+	}
+	if installed, err = store.installed(m); err != nil || !installed {
+		markAsFailed(cancelFlag, output, fmt.Errorf("certificate %v is not found in %v after it was installed %v", certificateThumbprint, location, err))
+		return
+	}
+	output.AppendInfof("Installed certificate %v with thumbprint %v, valid until %v, in %v (private key: %v)",
+		m.certificate.Subject, certificateThumbprint, m.certificate.NotAfter.UTC().Format("2006-01-02T15:04:05Z"), location, m.key != nil)
+	output.MarkAsSucceeded()
+}
+
+// checkPrivateKey checks the store can hold the private key of the certificate
+func checkPrivateKey(pluginInput InstallCertificatePluginInput, m material) error {
+	if m.key == nil {
+		return nil
+	}
+	switch pluginInput.Store {
+	case StoreCaTrust:
+		return errors.New("the certificate has a private key, the " + StoreCaTrust + " store only holds certificates")
+	case StorePemFiles:
+		if pluginInput.PrivateKeyPath == "" {
+			return errors.New("PrivateKeyPath is required to install a certificate with its private key")
+		}
+	}
+	return nil
+}
+
+func (p *Plugin) newStore(log log.T, pluginInput InstallCertificatePluginInput, tempDir string, cancelFlag task.CancelFlag, timeout int) certificateStore {
+	runner := commandRunner{log: log, commandExecuter: p.CommandExecuter, cancelFlag: cancelFlag, timeout: timeout}
+	switch pluginInput.Store {
+	case StoreWindows:
+		return windowsStore{
+			commandRunner: runner,
+			location:      pluginInput.StoreLocation,
+			name:          pluginInput.StoreName,
+			exportable:    pluginInput.Exportable,
+			tempDir:       tempDir,
+		}
+	case StoreJks:
+		return jksStore{
+			commandRunner: runner,
+			keytool:       pluginInput.KeytoolPath,
+			keystorePath:  pluginInput.KeystorePath,
+			keystoreType:  pluginInput.KeystoreType,
+			password:      pluginInput.KeystorePassword,
+			alias:         pluginInput.Alias,
+			tempDir:       tempDir,
+		}
+	case StorePemFiles:
+		return pemFilesStore{
+			certificatePath: pluginInput.CertificatePath,
+			privateKeyPath:  pluginInput.PrivateKeyPath,
+			chainPath:       pluginInput.ChainPath,
+		}
+	default:
+		return caTrustStore{commandRunner: runner}
+	}
+}
+
+// storeLocation describes where the certificate goes for the output of the plugin
+func storeLocation(pluginInput InstallCertificatePluginInput) string {
+	switch pluginInput.Store {
+	case StoreWindows:
+		return fmt.Sprintf(`Cert:\%v\%v`, pluginInput.StoreLocation, pluginInput.StoreName)
+	case StoreJks:
+		return "keystore " + pluginInput.KeystorePath
+	case StorePemFiles:
+		return pluginInput.CertificatePath
+	default:
+		return "the trust store of the instance"
+	}
+}
+
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
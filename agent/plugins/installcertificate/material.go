@@ -0,0 +1,187 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installcertificate
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// material is a certificate with its chain and, when it's installed for a service, its private key
+type material struct {
+	certificate *x509.Certificate
+	chain       []*x509.Certificate
+	key         crypto.PrivateKey
+}
+
+// parseMaterial parses the PEM encoded certificate, chain and private key, the certificate may be followed by its chain
+func parseMaterial(certificatePEM string, chainPEM string, keyPEM string, passphrase string) (m material, err error) {
+	certificates, err := parseCertificates(certificatePEM + "\n" + chainPEM)
+	if err != nil {
+		return m, err
+	}
+	if len(certificates) == 0 {
+		return m, errors.New("no PEM encoded certificate found")
+	}
+	m.certificate, m.chain = certificates[0], certificates[1:]
+	if strings.TrimSpace(keyPEM) == "" {
+		return m, nil
+	}
+	if m.key, err = parsePrivateKey(keyPEM, passphrase); err != nil {
+		return m, err
+	}
+	if !keyMatches(m.certificate, m.key) {
+		return m, errors.New("the private key does not belong to the certificate")
+	}
+	return m, nil
+}
+
+// parseCertificates returns the certificates of the PEM blocks
+func parseCertificates(data string) (certificates []*x509.Certificate, err error) {
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return certificates, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block %v in the certificate chain", block.Type)
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the certificate: %v", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+}
+
+// parsePrivateKey parses a PKCS #1, SEC 1 or PKCS #8 private key, encrypted private keys are decrypted with the passphrase
+func parsePrivateKey(keyPEM string, passphrase string) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+	if x509.IsEncryptedPEMBlock(block) {
+		return nil, errors.New("legacy encrypted private keys are not supported, use an encrypted PKCS #8 private key")
+	}
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		if passphrase == "" {
+			return nil, errors.New("the private key is encrypted but no passphrase is provided")
+		}
+		return decryptPKCS8(block.Bytes, []byte(passphrase))
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unexpected PEM block %v for the private key", block.Type)
+}
+
+// keyMatches returns whether the private key is the key of the certificate public key
+func keyMatches(certificate *x509.Certificate, key crypto.PrivateKey) bool {
+	var public crypto.PublicKey
+	switch typed := key.(type) {
+	case *rsa.PrivateKey:
+		public = typed.Public()
+	case *ecdsa.PrivateKey:
+		public = typed.Public()
+	default:
+		return false
+	}
+	expected, err := x509.MarshalPKIXPublicKey(certificate.PublicKey)
+	if err != nil {
+		return false
+	}
+	actual, err := x509.MarshalPKIXPublicKey(public)
+	return err == nil && bytes.Equal(expected, actual)
+}
+
+// thumbprint returns the SHA-1 hash of a certificate the way the Windows certificate store names it
+func thumbprint(certificate *x509.Certificate) string {
+	sum := sha1.Sum(certificate.Raw)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// verify checks the certificate chains to a root trusted by the instance, or to a root of the chain
+func (m material) verify() error {
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range m.chain {
+		if isSelfSigned(certificate) {
+			roots.AddCert(certificate)
+		} else {
+			intermediates.AddCert(certificate)
+		}
+	}
+	if isSelfSigned(m.certificate) {
+		roots.AddCert(m.certificate)
+	}
+	_, err = m.certificate.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("the certificate chain of %v is not valid: %v", m.certificate.Subject, err)
+	}
+	return nil
+}
+
+// isSelfSigned returns whether the certificate is signed by its own key
+func isSelfSigned(certificate *x509.Certificate) bool {
+	return bytes.Equal(certificate.RawIssuer, certificate.RawSubject) && certificate.CheckSignatureFrom(certificate) == nil
+}
+
+// certificatePEM returns the certificate followed by its chain in PEM
+func (m material) certificatePEM(withChain bool) []byte {
+	var buffer bytes.Buffer
+	pem.Encode(&buffer, &pem.Block{Type: "CERTIFICATE", Bytes: m.certificate.Raw})
+	if withChain {
+		buffer.Write(m.chainPEM())
+	}
+	return buffer.Bytes()
+}
+
+// chainPEM returns the chain of the certificate in PEM
+func (m material) chainPEM() []byte {
+	var buffer bytes.Buffer
+	for _, certificate := range m.chain {
+		pem.Encode(&buffer, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	}
+	return buffer.Bytes()
+}
+
+// privateKeyPEM returns the private key as an unencrypted PKCS #8 PEM block
+func (m material) privateKeyPEM() ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(m.key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installcertificate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCertificate is a certificate generated for the tests and its private key in PEM
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         string
+	keyPEM      string
+}
+
+// newTestCertificate returns a certificate signed by the issuer, or a self signed CA without issuer
+func newTestCertificate(t *testing.T, commonName string, issuer *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  issuer == nil,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	parent, signer := template, crypto.Signer(key)
+	if issuer != nil {
+		parent, signer = issuer.certificate, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return testCertificate{
+		certificate: certificate,
+		key:         key,
+		pem:         string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPEM:      string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	leaf := newTestCertificate(t, "leaf", &ca)
+
+	m, err := parseMaterial(leaf.pem, ca.pem, leaf.keyPEM, "")
+	assert.NoError(t, err)
+	assert.Len(t, m.chain, 1)
+	assert.NoError(t, m.verify())
+
+	// the root is unknown without the chain
+	m, err = parseMaterial(leaf.pem, "", "", "")
+	assert.NoError(t, err)
+	assert.Error(t, m.verify())
+}
+
+func TestVerifySelfSigned(t *testing.T) {
+	m, err := parseMaterial(selfSignedPEM, "", "", "")
+
+	assert.NoError(t, err)
+	assert.NoError(t, m.verify())
+	assert.Equal(t, "CBF90310577A2B5ED5810C0AFF70AA46CFC9667E", thumbprint(m.certificate))
+}
+
+func TestParseMaterialKeyMismatch(t *testing.T) {
+	ca := newTestCertificate(t, "ca", nil)
+	leaf := newTestCertificate(t, "leaf", &ca)
+
+	_, err := parseMaterial(leaf.pem, "", ca.keyPEM, "")
+
+	assert.EqualError(t, err, "the private key does not belong to the certificate")
+}
+
+func TestParseMaterialEncryptedKey(t *testing.T) {
+	_, err := parseMaterial(selfSignedPEM, "", encryptedKeyPEM, "")
+	assert.Error(t, err)
+
+	m, err := parseMaterial(selfSignedPEM, "", encryptedKeyPEM, "secret")
+	assert.NoError(t, err)
+	assert.NotNil(t, m.key)
+}
+
+func TestParseMaterialWithoutCertificate(t *testing.T) {
+	_, err := parseMaterial("not a certificate", "", "", "")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,272 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installcertificate
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"unicode/utf16"
+)
+
+// The PFX files are encrypted with the algorithms every version of Windows and keytool import,
+// they only live while the certificate is imported
+const pfxIterations = 2048
+
+var (
+	oidDataContentType               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCertBag                       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS8ShroudedKeyBag           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertTypeX509                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidSHA1                          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// encodePFX returns the certificate, its chain and its private key as a PKCS #12 file protected by the password,
+// the certificate and its key are named with the friendly name
+func encodePFX(m material, friendlyName string, password string) ([]byte, error) {
+	encodedPassword := bmpString(password, true)
+	localKeyID := sha1.Sum(m.certificate.Raw)
+	attributes, err := bagAttributes(friendlyName, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var bags []safeBag
+	for i, certificate := range append([]*x509.Certificate{m.certificate}, m.chain...) {
+		bag, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: certificate.Raw})
+		if err != nil {
+			return nil, err
+		}
+		certificateBag := safeBag{ID: oidCertBag, Value: explicit(bag)}
+		if i == 0 {
+			certificateBag.Attributes = attributes
+		}
+		bags = append(bags, certificateBag)
+	}
+	certificates, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := x509.MarshalPKCS8PrivateKey(m.key)
+	if err != nil {
+		return nil, err
+	}
+	shroudedKey, err := encryptPBE(privateKey, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := asn1.Marshal([]safeBag{{ID: oidPKCS8ShroudedKeyBag, Value: explicit(shroudedKey), Attributes: attributes}})
+	if err != nil {
+		return nil, err
+	}
+
+	var authenticatedSafe []contentInfo
+	for _, safeContents := range [][]byte{certificates, keys} {
+		content, err := dataContentInfo(safeContents)
+		if err != nil {
+			return nil, err
+		}
+		authenticatedSafe = append(authenticatedSafe, content)
+	}
+	authenticatedSafeBytes, err := asn1.Marshal(authenticatedSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	pfx := pfxPdu{Version: 3}
+	if pfx.AuthSafe, err = dataContentInfo(authenticatedSafeBytes); err != nil {
+		return nil, err
+	}
+	pfx.MacData.Iterations = pfxIterations
+	if pfx.MacData.MacSalt, err = randomBytes(8); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha1.New, pkcs12KDF(encodedPassword, pfx.MacData.MacSalt, pfxIterations, 3, sha1.Size))
+	mac.Write(authenticatedSafeBytes)
+	pfx.MacData.Mac = digestInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+		Digest:    mac.Sum(nil),
+	}
+	return asn1.Marshal(pfx)
+}
+
+// bagAttributes returns the attributes binding the certificate to its key
+func bagAttributes(friendlyName string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	keyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	name := asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpString(friendlyName, false)}
+	nameBytes, err := asn1.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+	return []pkcs12Attribute{
+		{ID: oidFriendlyName, Value: set(nameBytes)},
+		{ID: oidLocalKeyID, Value: set(keyID)},
+	}, nil
+}
+
+// encryptPBE returns the EncryptedPrivateKeyInfo of the key encrypted with pbeWithSHAAnd3-KeyTripleDES-CBC
+func encryptPBE(privateKey []byte, encodedPassword []byte) ([]byte, error) {
+	salt, err := randomBytes(8)
+	if err != nil {
+		return nil, err
+	}
+	block, err := des.NewTripleDESCipher(pkcs12KDF(encodedPassword, salt, pfxIterations, 1, 24))
+	if err != nil {
+		return nil, err
+	}
+	iv := pkcs12KDF(encodedPassword, salt, pfxIterations, 2, block.BlockSize())
+	data := pad(append([]byte(nil), privateKey...), block.BlockSize())
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: pfxIterations})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTripleDESCBC, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+}
+
+// pkcs12KDF derives key material from the password as described in RFC 7292 appendix B
+func pkcs12KDF(password []byte, salt []byte, iterations int, id byte, size int) []byte {
+	const v = 64 // block size of sha1
+	fill := func(data []byte) []byte {
+		filled := make([]byte, v*((len(data)+v-1)/v))
+		for i := range filled {
+			filled[i] = data[i%len(data)]
+		}
+		return filled
+	}
+	diversifier := bytes.Repeat([]byte{id}, v)
+	input := append(fill(salt), fill(password)...)
+
+	var derived []byte
+	for len(derived) < size {
+		hash := sha1.New()
+		hash.Write(diversifier)
+		hash.Write(input)
+		a := hash.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			sum := sha1.Sum(a)
+			a = sum[:]
+		}
+		derived = append(derived, a...)
+
+		// each block of the input becomes (block + b + 1) mod 2^(v*8)
+		b := fill(a)
+		for j := 0; j < len(input); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(input[j+k]) + int(b[k]) + carry
+				input[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return derived[:size]
+}
+
+// bmpString encodes the string in UTF-16 big endian, PKCS #12 passwords end with a null character
+func bmpString(s string, terminated bool) []byte {
+	var encoded []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		encoded = append(encoded, byte(r>>8), byte(r))
+	}
+	if terminated {
+		encoded = append(encoded, 0, 0)
+	}
+	return encoded
+}
+
+// dataContentInfo returns a ContentInfo of the data content type
+func dataContentInfo(content []byte) (contentInfo, error) {
+	octets, err := asn1.Marshal(content)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicit(octets)}, nil
+}
+
+// explicit returns the value tagged with [0] EXPLICIT, encoding/asn1 ignores the tags of raw values
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// set returns a SET OF the value
+func set(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der}
+}
+
+func randomBytes(size int) ([]byte, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	return b, err
+}
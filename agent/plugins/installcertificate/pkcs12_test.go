@@ -0,0 +1,81 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installcertificate
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodePFX(t *testing.T) {
+	m, err := parseMaterial(selfSignedPEM, "", encryptedKeyPEM, "secret")
+	assert.NoError(t, err)
+
+	der, err := encodePFX(m, "alias", "password")
+	assert.NoError(t, err)
+
+	var pfx pfxPdu
+	_, err = asn1.Unmarshal(der, &pfx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pfx.Version)
+	var authenticatedSafe []byte
+	_, err = asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authenticatedSafe)
+	assert.NoError(t, err)
+
+	// the mac is keyed with the password
+	mac := hmac.New(sha1.New, pkcs12KDF(bmpString("password", true), pfx.MacData.MacSalt, pfx.MacData.Iterations, 3, 20))
+	mac.Write(authenticatedSafe)
+	assert.Equal(t, mac.Sum(nil), pfx.MacData.Mac.Digest)
+
+	var contents []contentInfo
+	_, err = asn1.Unmarshal(authenticatedSafe, &contents)
+	assert.NoError(t, err)
+	assert.Len(t, contents, 2)
+	var keys []byte
+	_, err = asn1.Unmarshal(contents[1].Content.Bytes, &keys)
+	assert.NoError(t, err)
+	var bags []safeBag
+	_, err = asn1.Unmarshal(keys, &bags)
+	assert.NoError(t, err)
+	assert.True(t, bags[0].ID.Equal(oidPKCS8ShroudedKeyBag))
+	assert.Len(t, bags[0].Attributes, 2)
+
+	// the private key decrypts with the password
+	var info encryptedPrivateKeyInfo
+	_, err = asn1.Unmarshal(bags[0].Value.Bytes, &info)
+	assert.NoError(t, err)
+	var params pbeParams
+	_, err = asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params)
+	assert.NoError(t, err)
+	block, _ := des.NewTripleDESCipher(pkcs12KDF(bmpString("password", true), params.Salt, params.Iterations, 1, 24))
+	iv := pkcs12KDF(bmpString("password", true), params.Salt, params.Iterations, 2, 8)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(info.EncryptedData, info.EncryptedData)
+	plain, err := unpad(info.EncryptedData, 8)
+	assert.NoError(t, err)
+	key, err := x509.ParsePKCS8PrivateKey(plain)
+	assert.NoError(t, err)
+	assert.True(t, keyMatches(m.certificate, key))
+}
+
+func TestBMPString(t *testing.T) {
+	assert.Equal(t, []byte{0, 'a', 0x20, 0xac, 0, 0}, bmpString("a€", true))
+	assert.Equal(t, []byte{0, 'a'}, bmpString("a", false))
+}
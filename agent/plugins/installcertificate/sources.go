@@ -0,0 +1,166 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installcertificate
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/ssm"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+)
+
+const (
+	// SourceInline reads the certificate from the plugin input
+	SourceInline = "Inline"
+	// SourceSecretsManager reads the certificate from a Secrets Manager secret
+	SourceSecretsManager = "SecretsManager"
+	// SourceACM reads the certificate from AWS Certificate Manager
+	SourceACM = "ACM"
+
+	// secretsManagerReferencePrefix is the Parameter Store path referencing Secrets Manager secrets
+	secretsManagerReferencePrefix = "/aws/reference/secretsmanager/"
+)
+
+// certificateSecret is the JSON a Secrets Manager secret holding a certificate can have,
+// otherwise the secret is the PEM encoded certificate, chain and private key
+type certificateSecret struct {
+	Certificate          string
+	CertificateChain     string
+	PrivateKey           string
+	PrivateKeyPassphrase string
+}
+
+// exportCertificateInput is the input of the ACM ExportCertificate API, which the sdk of the agent predates
+type exportCertificateInput struct {
+	_              struct{} `type:"structure"`
+	CertificateArn *string  `min:"20" type:"string" required:"true"`
+	Passphrase     []byte   `min:"4" type:"blob" required:"true"`
+}
+
+// exportCertificateOutput is the output of the ACM ExportCertificate API
+type exportCertificateOutput struct {
+	_                struct{} `type:"structure"`
+	Certificate      *string  `min:"1" type:"string"`
+	CertificateChain *string  `min:"1" type:"string"`
+	PrivateKey       *string  `min:"1" type:"string"`
+}
+
+var getSecret = func(log log.T, secretID string) (string, error) {
+	output, err := ssm.NewService().GetDecryptedParameters(log, []string{secretsManagerReferencePrefix + secretID})
+	if err != nil {
+		return "", err
+	}
+	if len(output.Parameters) == 0 || output.Parameters[0].Value == nil {
+		return "", fmt.Errorf("secret %v is not found", secretID)
+	}
+	return *output.Parameters[0].Value, nil
+}
+
+var getACMCertificate = func(certificateArn string) (certificate string, chain string, err error) {
+	output, err := newACMClient().GetCertificate(&acm.GetCertificateInput{CertificateArn: aws.String(certificateArn)})
+	if err != nil {
+		return "", "", err
+	}
+	return aws.StringValue(output.Certificate), aws.StringValue(output.CertificateChain), nil
+}
+
+var exportACMCertificate = func(certificateArn string, passphrase []byte) (certificate string, chain string, privateKey string, err error) {
+	client := newACMClient()
+	output := &exportCertificateOutput{}
+	operation := &request.Operation{Name: "ExportCertificate", HTTPMethod: "POST", HTTPPath: "/"}
+	input := &exportCertificateInput{CertificateArn: aws.String(certificateArn), Passphrase: passphrase}
+	if err = client.NewRequest(operation, input, output).Send(); err != nil {
+		return "", "", "", err
+	}
+	return aws.StringValue(output.Certificate), aws.StringValue(output.CertificateChain), aws.StringValue(output.PrivateKey), nil
+}
+
+func newACMClient() *acm.ACM {
+	appConfig, _ := appconfig.Config(false)
+	sess := session.New(sdkutil.AwsConfig())
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
+	return acm.New(sess)
+}
+
+// loadMaterial reads the certificate of the plugin input from its source
+func loadMaterial(log log.T, pluginInput InstallCertificatePluginInput) (material, error) {
+	switch pluginInput.Source {
+	case SourceSecretsManager:
+		secret, err := getSecret(log, pluginInput.SecretId)
+		if err != nil {
+			return material{}, fmt.Errorf("failed to get secret %v: %v", pluginInput.SecretId, err)
+		}
+		return parseSecret(secret, pluginInput.PrivateKeyPassphrase)
+	case SourceACM:
+		if !pluginInput.ExportPrivateKey {
+			certificate, chain, err := getACMCertificate(pluginInput.CertificateArn)
+			if err != nil {
+				return material{}, fmt.Errorf("failed to get certificate %v: %v", pluginInput.CertificateArn, err)
+			}
+			return parseMaterial(certificate, chain, "", "")
+		}
+		// the exported key is encrypted with a passphrase that never leaves the plugin
+		passphrase, err := randomBytes(24)
+		if err != nil {
+			return material{}, err
+		}
+		encoded := base64.RawURLEncoding.EncodeToString(passphrase)
+		certificate, chain, privateKey, err := exportACMCertificate(pluginInput.CertificateArn, []byte(encoded))
+		if err != nil {
+			return material{}, fmt.Errorf("failed to export certificate %v: %v", pluginInput.CertificateArn, err)
+		}
+		return parseMaterial(certificate, chain, privateKey, encoded)
+	default:
+		return parseMaterial(pluginInput.Certificate, pluginInput.CertificateChain, pluginInput.PrivateKey, pluginInput.PrivateKeyPassphrase)
+	}
+}
+
+// parseSecret parses a secret holding a certificate as JSON or as PEM
+func parseSecret(secret string, passphrase string) (material, error) {
+	if strings.HasPrefix(strings.TrimSpace(secret), "{") {
+		var parsed certificateSecret
+		if err := jsonutil.Unmarshal(secret, &parsed); err != nil {
+			return material{}, errors.New("the secret is not a JSON object with a Certificate")
+		}
+		if parsed.PrivateKeyPassphrase == "" {
+			parsed.PrivateKeyPassphrase = passphrase
+		}
+		return parseMaterial(parsed.Certificate, parsed.CertificateChain, parsed.PrivateKey, parsed.PrivateKeyPassphrase)
+	}
+	var certificatesPEM, keyPEM []byte
+	rest := []byte(secret)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			keyPEM = pem.EncodeToMemory(block)
+		} else {
+			certificatesPEM = append(certificatesPEM, pem.EncodeToMemory(block)...)
+		}
+	}
+	return parseMaterial(string(certificatesPEM), "", string(keyPEM), passphrase)
+}
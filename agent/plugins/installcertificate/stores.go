@@ -0,0 +1,394 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package installcertificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// StoreWindows is the Windows certificate store
+	StoreWindows = "Windows"
+	// StoreCaTrust is the trust store of the operating system on Linux
+	StoreCaTrust = "CaTrust"
+	// StoreJks is a Java keystore managed with keytool
+	StoreJks = "Jks"
+	// StorePemFiles writes the certificate, chain and private key to PEM files
+	StorePemFiles = "PemFiles"
+
+	// anchorPrefix prefixes the anchors the plugin adds to the trust store of the operating system
+	anchorPrefix = "amazon-ssm-"
+
+	// publicFileMode is the mode of the files holding certificates, which anybody may read
+	publicFileMode = 0644
+)
+
+// trustStore is a directory of trust anchors and the command which rebuilds the trust store from it
+type trustStore struct {
+	anchorDir string
+	command   string
+	args      []string
+}
+
+// trustStores are the trust stores of the Red Hat and the Debian families, the first one present is used
+var trustStores = []trustStore{
+	{anchorDir: "/etc/pki/ca-trust/source/anchors", command: "update-ca-trust", args: []string{"extract"}},
+	{anchorDir: "/usr/local/share/ca-certificates", command: "update-ca-certificates"},
+}
+
+// certificateStore is a store of the operating system or of an application the plugin installs certificates into
+type certificateStore interface {
+	// installed returns whether the certificate, and its private key when it has one, are in the store
+	installed(m material) (bool, error)
+	// install adds the certificate to the store, replacing the certificate it had under the same name
+	install(m material) error
+}
+
+// commandRunner runs the commands of the stores
+type commandRunner struct {
+	log             log.T
+	commandExecuter executers.T
+	cancelFlag      task.CancelFlag
+	timeout         int
+}
+
+// run runs the command and returns its standard output
+func (r commandRunner) run(command string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := r.commandExecuter.NewExecute(r.log, "", &stdout, &stderr, r.cancelFlag, r.timeout, command, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", command, err, strings.TrimSpace(stderr.String()+" "+stdout.String()))
+	}
+	return stdout.String(), nil
+}
+
+// caTrustStore trusts the certificate for the whole instance
+type caTrustStore struct {
+	commandRunner
+}
+
+func (s caTrustStore) trustStore() (trustStore, error) {
+	for _, store := range trustStores {
+		if fileutil.IsDirectory(store.anchorDir) {
+			return store, nil
+		}
+	}
+	return trustStore{}, fmt.Errorf("no trust store found, expected one of %v", trustStoreDirs())
+}
+
+func (s caTrustStore) installed(m material) (bool, error) {
+	store, err := s.trustStore()
+	if err != nil {
+		return false, err
+	}
+	return fileThumbprint(filepath.Join(store.anchorDir, anchorName(m))) == thumbprint(m.certificate), nil
+}
+
+func (s caTrustStore) install(m material) error {
+	store, err := s.trustStore()
+	if err != nil {
+		return err
+	}
+	if err = writeFile(filepath.Join(store.anchorDir, anchorName(m)), m.certificatePEM(false), publicFileMode); err != nil {
+		return err
+	}
+	_, err = s.run(store.command, store.args...)
+	return err
+}
+
+func anchorName(m material) string {
+	return anchorPrefix + thumbprint(m.certificate) + ".crt"
+}
+
+func trustStoreDirs() []string {
+	var dirs []string
+	for _, store := range trustStores {
+		dirs = append(dirs, store.anchorDir)
+	}
+	return dirs
+}
+
+// windowsStore imports the certificate into a store of the Windows certificate store
+type windowsStore struct {
+	commandRunner
+	location   string
+	name       string
+	exportable bool
+	tempDir    string
+}
+
+func (s windowsStore) path(m material) string {
+	return fmt.Sprintf(`Cert:\%v\%v\%v`, s.location, s.name, thumbprint(m.certificate))
+}
+
+func (s windowsStore) installed(m material) (bool, error) {
+	script := fmt.Sprintf("$c = Get-Item -LiteralPath %v -ErrorAction SilentlyContinue\n[bool]($c -and ($c.HasPrivateKey -or -not $%v))",
+		quote(s.path(m)), m.key != nil)
+	stdout, err := s.powerShell(script)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(strings.TrimSpace(stdout), "true"), nil
+}
+
+func (s windowsStore) install(m material) error {
+	storePath := fmt.Sprintf(`Cert:\%v\%v`, s.location, s.name)
+	if m.key == nil {
+		certificatePath := filepath.Join(s.tempDir, thumbprint(m.certificate)+".cer")
+		if err := writeFile(certificatePath, m.certificate.Raw, appconfig.ReadWriteAccess); err != nil {
+			return err
+		}
+		defer os.Remove(certificatePath)
+		_, err := s.powerShell(fmt.Sprintf("Import-Certificate -FilePath %v -CertStoreLocation %v | Out-Null", quote(certificatePath), quote(storePath)))
+		return err
+	}
+
+	pfxPath, passwordPath, err := writePFX(m, s.tempDir, thumbprint(m.certificate))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(pfxPath)
+	defer os.Remove(passwordPath)
+	exportable := ""
+	if s.exportable {
+		exportable = " -Exportable"
+	}
+	_, err = s.powerShell(strings.Join([]string{
+		fmt.Sprintf("$password = ConvertTo-SecureString -String (Get-Content -LiteralPath %v -Raw) -AsPlainText -Force", quote(passwordPath)),
+		fmt.Sprintf("Import-PfxCertificate -FilePath %v -CertStoreLocation %v -Password $password%v | Out-Null", quote(pfxPath), quote(storePath), exportable),
+	}, "\n"))
+	return err
+}
+
+func (s windowsStore) powerShell(script string) (string, error) {
+	return s.run(appconfig.PowerShellPluginCommandName, "-NoProfile", "-NonInteractive", "-Command", "$ErrorActionPreference = 'Stop'\n"+script)
+}
+
+// quote returns the value as a PowerShell literal string
+func quote(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// jksStore adds the certificate to a Java keystore with keytool
+type jksStore struct {
+	commandRunner
+	keytool      string
+	keystorePath string
+	keystoreType string
+	password     string
+	alias        string
+	tempDir      string
+}
+
+// keystoreArgs returns the arguments selecting the keystore, the password is read from a file so it doesn't show
+// in the command line of keytool
+func (s jksStore) keystoreArgs(passwordPath string, prefix string) []string {
+	args := []string{"-" + prefix + "keystore", s.keystorePath, "-" + prefix + "storepass:file", passwordPath}
+	if s.keystoreType != "" {
+		args = append(args, "-"+prefix+"storetype", s.keystoreType)
+	}
+	return args
+}
+
+func (s jksStore) installed(m material) (bool, error) {
+	if !fileutil.Exists(s.keystorePath) {
+		return false, nil
+	}
+	passwordPath, err := writePassword(s.tempDir, "keystore", s.password)
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(passwordPath)
+	stdout, err := s.run(s.keytool, append([]string{"-list", "-rfc", "-alias", s.alias}, s.keystoreArgs(passwordPath, "")...)...)
+	if err != nil {
+		// keytool fails when the alias doesn't exist
+		return false, nil
+	}
+	if m.key != nil && !strings.Contains(stdout, "PrivateKeyEntry") {
+		return false, nil
+	}
+	certificates := certificatesIn(stdout)
+	return len(certificates) > 0 && thumbprint(certificates[0]) == thumbprint(m.certificate), nil
+}
+
+func (s jksStore) install(m material) error {
+	passwordPath, err := writePassword(s.tempDir, "keystore", s.password)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(passwordPath)
+	if err = fileutil.MakeDirsWithExecuteAccess(filepath.Dir(s.keystorePath)); err != nil {
+		return err
+	}
+	if fileutil.Exists(s.keystorePath) {
+		// the alias may not exist yet, the import reports the errors that matter
+		s.run(s.keytool, append([]string{"-delete", "-alias", s.alias}, s.keystoreArgs(passwordPath, "")...)...)
+	}
+
+	if m.key == nil {
+		certificatePath := filepath.Join(s.tempDir, thumbprint(m.certificate)+".pem")
+		if err = writeFile(certificatePath, m.certificatePEM(false), appconfig.ReadWriteAccess); err != nil {
+			return err
+		}
+		defer os.Remove(certificatePath)
+		args := append([]string{"-importcert", "-noprompt", "-trustcacerts", "-alias", s.alias, "-file", certificatePath},
+			s.keystoreArgs(passwordPath, "")...)
+		_, err = s.run(s.keytool, args...)
+		return err
+	}
+
+	pfxPath, pfxPasswordPath, err := writePFX(m, s.tempDir, s.alias)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(pfxPath)
+	defer os.Remove(pfxPasswordPath)
+	args := []string{"-importkeystore", "-noprompt",
+		"-srckeystore", pfxPath, "-srcstoretype", "PKCS12", "-srcstorepass:file", pfxPasswordPath, "-srcalias", s.alias,
+		"-destalias", s.alias}
+	args = append(args, s.keystoreArgs(passwordPath, "dest")...)
+	// the key of the entry is protected by the password of the keystore, as keytool expects it
+	args = append(args, "-destkeypass:file", passwordPath)
+	_, err = s.run(s.keytool, args...)
+	return err
+}
+
+// pemFilesStore writes the certificate for the applications which read their certificate from files
+type pemFilesStore struct {
+	certificatePath string
+	privateKeyPath  string
+	chainPath       string
+}
+
+func (s pemFilesStore) installed(m material) (bool, error) {
+	if fileThumbprint(s.certificatePath) != thumbprint(m.certificate) {
+		return false, nil
+	}
+	if m.key == nil {
+		return true, nil
+	}
+	keyPEM, err := ioutil.ReadFile(s.privateKeyPath)
+	if err != nil {
+		return false, nil
+	}
+	key, err := parsePrivateKey(string(keyPEM), "")
+	return err == nil && keyMatches(m.certificate, key), nil
+}
+
+func (s pemFilesStore) install(m material) error {
+	// the private key is written first so the certificate never refers to a key which isn't there
+	if m.key != nil {
+		keyPEM, err := m.privateKeyPEM()
+		if err != nil {
+			return err
+		}
+		if err = writeFile(s.privateKeyPath, keyPEM, appconfig.ReadWriteAccess); err != nil {
+			return err
+		}
+	}
+	if s.chainPath != "" {
+		if err := writeFile(s.chainPath, m.chainPEM(), publicFileMode); err != nil {
+			return err
+		}
+	}
+	return writeFile(s.certificatePath, m.certificatePEM(s.chainPath == ""), publicFileMode)
+}
+
+// writePFX writes the certificate as a PKCS #12 file and its random password to a file next to it
+func writePFX(m material, dir string, friendlyName string) (pfxPath string, passwordPath string, err error) {
+	password, err := randomBytes(24)
+	if err != nil {
+		return "", "", err
+	}
+	encodedPassword := fmt.Sprintf("%x", password)
+	pfx, err := encodePFX(m, friendlyName, encodedPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode the certificate as PKCS #12: %v", err)
+	}
+	if passwordPath, err = writePassword(dir, "pfx", encodedPassword); err != nil {
+		return "", "", err
+	}
+	pfxPath = filepath.Join(dir, thumbprint(m.certificate)+".pfx")
+	if err = writeFile(pfxPath, pfx, appconfig.ReadWriteAccess); err != nil {
+		os.Remove(passwordPath)
+		return "", "", err
+	}
+	return pfxPath, passwordPath, nil
+}
+
+// writePassword writes the password to a file only the agent can read
+func writePassword(dir string, name string, password string) (string, error) {
+	passwordPath := filepath.Join(dir, name+".password")
+	return passwordPath, writeFile(passwordPath, []byte(password), appconfig.ReadWriteAccess)
+}
+
+// writeFile replaces the file with the data, the file is renamed into place so it's never partially written
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	if err := fileutil.MakeDirsWithExecuteAccess(filepath.Dir(path)); err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	os.Remove(tempPath)
+	if err := ioutil.WriteFile(tempPath, data, perm); err != nil {
+		return fmt.Errorf("failed to write %v: %v", path, err)
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write %v: %v", path, err)
+	}
+	return nil
+}
+
+// fileThumbprint returns the thumbprint of the first certificate of the file, or nothing when it has none
+func fileThumbprint(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	certificates := certificatesIn(string(data))
+	if len(certificates) == 0 {
+		return ""
+	}
+	return thumbprint(certificates[0])
+}
+
+// certificatesIn returns the PEM encoded certificates found in the text
+func certificatesIn(text string) (certificates []*x509.Certificate) {
+	rest := []byte(text)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return certificates
+		}
+		if certificate, err := x509.ParseCertificate(block.Bytes); err == nil && block.Type == "CERTIFICATE" {
+			certificates = append(certificates, certificate)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -30,6 +31,7 @@ import (
 var (
 	lock             sync.RWMutex
 	contentHashStore map[string]string
+	checkpointStore  map[string]Checkpoint
 )
 
 //TODO: add unit tests
@@ -45,12 +47,25 @@ func machineInfoProvider() (name string, err error) {
 type Optimizer interface {
 	UpdateContentHash(inventoryItemName, hash string) (err error)
 	GetContentHash(inventoryItemName string) (hash string)
+	UpdateCheckpoint(inventoryItemName string, checkpoint Checkpoint) (err error)
+	GetCheckpoint(inventoryItemName string) (checkpoint Checkpoint, found bool)
+	RemoveCheckpoint(inventoryItemName string) (err error)
+}
+
+// Checkpoint is the content of an inventory type the service acknowledged, which the delta uploads are relative to
+type Checkpoint struct {
+	ContentHash string
+	// Entries are the checksums of the entries of the content
+	Entries []string
+	// FullUploadTime is when all the entries of the type were last uploaded
+	FullUploadTime time.Time
 }
 
 // Impl implements content hash optimizations for inventory plugin
 type Impl struct {
-	log      log.T
-	location string //where the content hash data is persisted in file-systems
+	log                log.T
+	location           string //where the content hash data is persisted in file-systems
+	checkpointLocation string //where the checkpoints are persisted in file-systems
 }
 
 func NewOptimizerImpl(context context.T) (*Impl, error) {
@@ -76,7 +91,20 @@ func NewOptimizerImplWithLocation(log log.T, rootDir string, fileName string) (*
 		rootDir,
 		fileName)
 
+	optimizer.checkpointLocation = filepath.Join(filepath.Dir(optimizer.location), appconfig.InventoryCheckpointFileName)
+
 	contentHashStore = make(map[string]string)
+	checkpointStore = make(map[string]Checkpoint)
+
+	//read the checkpoints acknowledged before - the delta uploads start over with full uploads without them
+	if fileutil.Exists(optimizer.checkpointLocation) {
+		if content, err = fileutil.ReadAllText(optimizer.checkpointLocation); err == nil {
+			if err = json.Unmarshal([]byte(content), &checkpointStore); err != nil {
+				optimizer.log.Debugf("Unable to read checkpoints of inventory plugin - thereby ignoring any older values")
+				checkpointStore = make(map[string]Checkpoint)
+			}
+		}
+	}
 
 	//read old content hash values from file
 	if fileutil.Exists(optimizer.location) {
@@ -127,3 +155,40 @@ func (i *Impl) GetContentHash(inventoryItemName string) (hash string) {
 
 	return
 }
+
+func (i *Impl) UpdateCheckpoint(inventoryItemName string, checkpoint Checkpoint) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	checkpointStore[inventoryItemName] = checkpoint
+	return i.saveCheckpoints()
+}
+
+func (i *Impl) GetCheckpoint(inventoryItemName string) (checkpoint Checkpoint, found bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	checkpoint, found = checkpointStore[inventoryItemName]
+	return
+}
+
+func (i *Impl) RemoveCheckpoint(inventoryItemName string) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, found := checkpointStore[inventoryItemName]; !found {
+		return nil
+	}
+	delete(checkpointStore, inventoryItemName)
+	return i.saveCheckpoints()
+}
+
+// saveCheckpoints persists the checkpoints in file system, the caller holds the lock
+func (i *Impl) saveCheckpoints() (err error) {
+	dataB, _ := json.Marshal(checkpointStore)
+
+	if _, err = fileutil.WriteIntoFileWithPermissions(i.checkpointLocation, string(dataB), appconfig.ReadWriteAccess); err != nil {
+		err = fmt.Errorf("Unable to update checkpoints in file - %v because - %v", i.checkpointLocation, err.Error())
+	}
+	return
+}
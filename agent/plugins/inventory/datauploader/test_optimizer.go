@@ -37,3 +37,18 @@ func (m *MockOptimizer) GetContentHash(inventoryItemName string) (hash string) {
 	args := m.Called(inventoryItemName)
 	return args.String(0)
 }
+
+func (m *MockOptimizer) UpdateCheckpoint(inventoryItemName string, checkpoint Checkpoint) (err error) {
+	args := m.Called(inventoryItemName, checkpoint)
+	return args.Error(0)
+}
+
+func (m *MockOptimizer) GetCheckpoint(inventoryItemName string) (checkpoint Checkpoint, found bool) {
+	args := m.Called(inventoryItemName)
+	return args.Get(0).(Checkpoint), args.Bool(1)
+}
+
+func (m *MockOptimizer) RemoveCheckpoint(inventoryItemName string) (err error) {
+	args := m.Called(inventoryItemName)
+	return args.Error(0)
+}
@@ -21,14 +21,17 @@ import (
 	"fmt"
 	"hash/fnv"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
-	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
@@ -38,6 +41,18 @@ const (
 	Name = "InventoryUploader"
 	// The maximum time window range for random back off before call PutInventory API
 	Max_Time_TO_Back_Off = 30
+	// kindPutInventory are the PutInventory calls queued in the upload queue
+	kindPutInventory = "putInventory"
+
+	// ContextUploadType is the context key telling whether an inventory item holds all the entries of its type or a
+	// delta, ContextBaseContentHash is the content hash of the checkpoint a delta applies to and ContextRemovedEntries
+	// are the checksums of the entries the delta removes, as a JSON array. A delta holds the entries it adds, and the
+	// content hash of the item is the hash of the content once the delta is applied.
+	ContextUploadType      = "UploadType"
+	ContextBaseContentHash = "BaseContentHash"
+	ContextRemovedEntries  = "RemovedEntries"
+	// UploadTypeDelta is the upload type of the delta items
+	UploadTypeDelta = "Delta"
 )
 
 // T represents contracts for SSM Inventory data uploader
@@ -54,6 +69,12 @@ type SSMCaller interface {
 type InventoryUploader struct {
 	ssm       SSMCaller
 	optimizer Optimizer //helps inventory plugin to optimize PutInventory calls
+	// deltaUploads uploads the entries changed since the checkpoint of a type, until its full refresh is due
+	deltaUploads        bool
+	fullRefreshInterval time.Duration
+	// pending are the checkpoints of the items being uploaded, saved once the service acknowledges them
+	pending     map[string]Checkpoint
+	pendingLock sync.Mutex
 }
 
 // NewInventoryUploader creates a new InventoryUploader (which sends data to SSM Inventory)
@@ -85,9 +106,11 @@ func NewInventoryUploader(context context.T) (*InventoryUploader, error) {
 		if appCfg.Agent.Region != "" {
 			cfg.Region = &appCfg.Agent.Region
 		}
+		uploader.deltaUploads = appCfg.Ssm.Inventory.DeltaUploads
+		uploader.fullRefreshInterval = time.Duration(appCfg.Ssm.Inventory.FullRefreshHours) * time.Hour
 	}
 	sess := session.New(cfg)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appCfg.Agent.Name, appCfg.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appCfg.Agent))
 
 	uploader.ssm = ssm.New(sess)
 
@@ -119,18 +142,29 @@ func (u *InventoryUploader) SendDataToSSM(context context.T, items []*ssm.Invent
 		InstanceId: &instanceID,
 		Items:      items,
 	}
-	var resp *ssm.PutInventoryOutput
 
 	// random back off before call PutInventory API
 	time.Sleep(time.Duration(getRandomBackOffTime(context, instanceID)) * time.Second)
 	log.Debugf("Calling PutInventory API with parameters - %v", params)
 	if u.ssm != nil {
-		resp, err = u.ssm.PutInventory(params)
+		// the calls queued before the agent restarted are sent by this uploader as well
+		uploadqueue.RegisterHandler(kindPutInventory, u.putInventory)
+
+		var payload []byte
+		if payload, err = json.Marshal(params); err != nil {
+			return
+		}
+		err = uploadqueue.Do(log, uploadqueue.Upload{
+			Kind:        kindPutInventory,
+			Priority:    uploadqueue.PriorityInventory,
+			Description: fmt.Sprintf("%v inventory items", len(items)),
+			Size:        int64(len(payload)),
+			Payload:     payload,
+		})
 
 		if err != nil {
 			log.Errorf("the following error occured while calling PutInventory API: %v", err)
 		} else {
-			log.Debugf("PutInventory was called successfully with response - %v", resp)
 			u.updateContentHash(context, items)
 		}
 	}
@@ -138,6 +172,149 @@ func (u *InventoryUploader) SendDataToSSM(context context.T, items []*ssm.Invent
 	return
 }
 
+// putInventory calls PutInventory with the input queued by SendDataToSSM
+func (u *InventoryUploader) putInventory(log log.T, payload []byte) error {
+	var params ssm.PutInventoryInput
+	if err := json.Unmarshal(payload, &params); err != nil {
+		return err
+	}
+	resp, err := u.ssm.PutInventory(&params)
+	if err == nil {
+		log.Debugf("PutInventory was called successfully with response - %v", resp)
+	}
+	u.acknowledgeCheckpoints(log, params.Items, err)
+	return err
+}
+
+// acknowledgeCheckpoints saves the checkpoints of the items the service acknowledged. The checkpoints of the deltas
+// the service rejects are removed, so the next upload of their types holds all their entries.
+func (u *InventoryUploader) acknowledgeCheckpoints(log log.T, items []*ssm.InventoryItem, err error) {
+	if u.optimizer == nil {
+		return
+	}
+	u.pendingLock.Lock()
+	defer u.pendingLock.Unlock()
+	for _, item := range items {
+		if item.TypeName == nil || item.ContentHash == nil {
+			continue
+		}
+		delta := isDelta(item)
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && delta &&
+				(awsErr.Code() == "ItemContentMismatchException" || awsErr.Code() == "InvalidItemContentException") {
+				log.Debugf("The delta of %v was rejected, its next upload holds all its entries", *item.TypeName)
+				if removeErr := u.optimizer.RemoveCheckpoint(*item.TypeName); removeErr != nil {
+					log.Error(removeErr.Error())
+				}
+			}
+			continue
+		}
+		checkpoint, found := u.pending[*item.TypeName]
+		if !found || checkpoint.ContentHash != *item.ContentHash {
+			continue
+		}
+		if !delta {
+			checkpoint.FullUploadTime = time.Now()
+		}
+		if updateErr := u.optimizer.UpdateCheckpoint(*item.TypeName, checkpoint); updateErr != nil {
+			log.Errorf("failed to update the checkpoint of %v because of - %v", *item.TypeName, updateErr)
+			continue
+		}
+		delete(u.pending, *item.TypeName)
+	}
+}
+
+// isDelta returns true if the item holds the entries changed since the checkpoint of its type
+func isDelta(item *ssm.InventoryItem) bool {
+	uploadType, found := item.Context[ContextUploadType]
+	return found && uploadType != nil && *uploadType == UploadTypeDelta
+}
+
+// entryChecksums returns the checksums of the entries of the content of an item
+func entryChecksums(content []map[string]*string) (checksums []string, err error) {
+	checksums = make([]string, 0, len(content))
+	for _, entry := range content {
+		var dataB []byte
+		if dataB, err = json.Marshal(entry); err != nil {
+			return
+		}
+		checksums = append(checksums, calculateCheckSum(dataB))
+	}
+	return
+}
+
+// deltaItem returns the item holding the changes of the content of an item since its checkpoint, or nil when uploading
+// the changes is not smaller than uploading all the entries
+func deltaItem(item *ssm.InventoryItem, checksums []string, checkpoint Checkpoint) (*ssm.InventoryItem, error) {
+	previous := make(map[string]bool, len(checkpoint.Entries))
+	for _, checksum := range checkpoint.Entries {
+		previous[checksum] = true
+	}
+	current := make(map[string]bool, len(checksums))
+	added := []map[string]*string{}
+	for i, checksum := range checksums {
+		current[checksum] = true
+		if !previous[checksum] {
+			added = append(added, item.Content[i])
+		}
+	}
+	removed := []string{}
+	for _, checksum := range checkpoint.Entries {
+		if !current[checksum] {
+			removed = append(removed, checksum)
+		}
+	}
+	if len(added)+len(removed) >= len(checksums) {
+		return nil, nil
+	}
+	removedB, err := json.Marshal(removed)
+	if err != nil {
+		return nil, err
+	}
+	uploadType, baseContentHash, removedEntries := UploadTypeDelta, checkpoint.ContentHash, string(removedB)
+	return &ssm.InventoryItem{
+		CaptureTime:   item.CaptureTime,
+		TypeName:      item.TypeName,
+		SchemaVersion: item.SchemaVersion,
+		ContentHash:   item.ContentHash,
+		Content:       added,
+		Context: map[string]*string{
+			ContextUploadType:      &uploadType,
+			ContextBaseContentHash: &baseContentHash,
+			ContextRemovedEntries:  &removedEntries,
+		},
+	}, nil
+}
+
+// trackCheckpoint records the checkpoint of the changed content of an item, and returns the delta of the item when its
+// checkpoint is the content the service holds and its full refresh is not due
+func (u *InventoryUploader) trackCheckpoint(log log.T, item *ssm.InventoryItem, oldHash string) (delta *ssm.InventoryItem, err error) {
+	var checksums []string
+	if checksums, err = entryChecksums(item.Content); err != nil {
+		return
+	}
+	pending := Checkpoint{ContentHash: *item.ContentHash, Entries: checksums}
+	checkpoint, found := u.optimizer.GetCheckpoint(*item.TypeName)
+	if found && checkpoint.ContentHash == oldHash && time.Since(checkpoint.FullUploadTime) < u.fullRefreshInterval {
+		if delta, err = deltaItem(item, checksums, checkpoint); err != nil {
+			return
+		}
+		if delta != nil {
+			pending.FullUploadTime = checkpoint.FullUploadTime
+			log.Debugf("Uploading the changes of %v since its checkpoint, %v of its %v entries are added", *item.TypeName,
+				len(delta.Content), len(checksums))
+		}
+	}
+
+	u.pendingLock.Lock()
+	defer u.pendingLock.Unlock()
+	if u.pending == nil {
+		u.pending = make(map[string]Checkpoint)
+	}
+	u.pending[*item.TypeName] = pending
+	return
+}
+
 // Get one random jitter time before calling PutInventory API to prevent huge number of request come to
 // the backend service in the same time.
 // Use current Time stamp + Hashcode of instance ID as random key
@@ -173,7 +350,8 @@ func calculateCheckSum(data []byte) (checkSum string) {
 // ConvertToSsmInventoryItems converts given array of inventory.Item into an array of *ssm.InventoryItem. It returns 2 such arrays - one is optimized array
 // which contains only contentHash for those inventory types where the dataset hasn't changed from previous collection. The other array is non-optimized array
 // which contains both contentHash & content. This is done to avoid iterating over the inventory data twice. It throws error when it encounters error during
-// conversion process.
+// conversion process. When the delta uploads are enabled, the optimized array holds the changes of the inventory types
+// whose dataset changed since their checkpoint instead of their whole dataset.
 func (u *InventoryUploader) ConvertToSsmInventoryItems(context context.T, items []model.Item) (optimizedInventoryItems, nonOptimizedInventoryItems []*ssm.InventoryItem, err error) {
 
 	log := context.Log()
@@ -236,6 +414,17 @@ func (u *InventoryUploader) ConvertToSsmInventoryItems(context context.T, items
 
 			optimizedInventoryItems = append(optimizedInventoryItems, optimizedItem)
 
+		} else if u.deltaUploads {
+			var delta *ssm.InventoryItem
+			if delta, err = u.trackCheckpoint(log, nonOptimizedItem, oldHash); err != nil {
+				err = fmt.Errorf("computing the changes of %v failed due to %v", itemName, err.Error())
+				return
+			}
+			if delta == nil {
+				log.Debugf("Adding item - %v to the optimizedItems (since its new data)", nonOptimizedItem)
+				delta = nonOptimizedItem
+			}
+			optimizedInventoryItems = append(optimizedInventoryItems, delta)
 		} else {
 			log.Debugf("New inventory data for %v has been detected - can't optimize here", itemName)
 			log.Debugf("Adding item - %v to the optimizedItems (since its new data)", nonOptimizedItem)
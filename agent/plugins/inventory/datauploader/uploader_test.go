@@ -18,9 +18,12 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -205,3 +208,95 @@ func testSendData(t *testing.T, putInventorySucceeds bool) {
 	mockSSM.AssertExpectations(t)
 	mockOptimizer.AssertExpectations(t)
 }
+
+func applicationsItem(names ...string) model.Item {
+	var applications []model.ApplicationData
+	for _, name := range names {
+		applications = append(applications, model.ApplicationData{Name: name, Version: "1.0"})
+	}
+	return model.Item{Name: "AWS:Application", Content: applications, SchemaVersion: "1.0", CaptureTime: "time"}
+}
+
+func checkpointOf(t *testing.T, item model.Item) Checkpoint {
+	inventoryItem, err := ConvertToSSMInventoryItem(item)
+	assert.NoError(t, err)
+	checksums, err := entryChecksums(inventoryItem.Content)
+	assert.NoError(t, err)
+	dataB, _ := json.Marshal(item.Content)
+	return Checkpoint{ContentHash: calculateCheckSum(dataB), Entries: checksums, FullUploadTime: time.Now()}
+}
+
+func TestConvertToSsmInventoryItemsUploadsDeltas(t *testing.T) {
+	previous := checkpointOf(t, applicationsItem("a", "b", "c", "d"))
+	optimizer := NewMockDefault()
+	optimizer.On("GetContentHash", "AWS:Application").Return(previous.ContentHash)
+	optimizer.On("GetCheckpoint", "AWS:Application").Return(previous, true)
+	u := &InventoryUploader{optimizer: optimizer, deltaUploads: true, fullRefreshInterval: time.Hour}
+
+	optimized, nonOptimized, err := u.ConvertToSsmInventoryItems(context.NewMockDefault(), []model.Item{applicationsItem("a", "b", "c", "e")})
+
+	assert.NoError(t, err)
+	assert.Len(t, nonOptimized[0].Content, 4)
+	delta := optimized[0]
+	assert.True(t, isDelta(delta))
+	assert.Len(t, delta.Content, 1)
+	assert.Equal(t, "e", *delta.Content[0]["Name"])
+	assert.Equal(t, previous.ContentHash, *delta.Context[ContextBaseContentHash])
+	assert.Equal(t, `["`+previous.Entries[3]+`"]`, *delta.Context[ContextRemovedEntries])
+	assert.Equal(t, *nonOptimized[0].ContentHash, *delta.ContentHash)
+	assert.Equal(t, previous.FullUploadTime, u.pending["AWS:Application"].FullUploadTime)
+
+	// the full refresh is due
+	previous.FullUploadTime = time.Now().Add(-2 * time.Hour)
+	optimizer = NewMockDefault()
+	optimizer.On("GetContentHash", "AWS:Application").Return(previous.ContentHash)
+	optimizer.On("GetCheckpoint", "AWS:Application").Return(previous, true)
+	u.optimizer = optimizer
+	optimized, _, err = u.ConvertToSsmInventoryItems(context.NewMockDefault(), []model.Item{applicationsItem("a", "b", "c", "e")})
+	assert.NoError(t, err)
+	assert.False(t, isDelta(optimized[0]))
+	assert.Len(t, optimized[0].Content, 4)
+
+	// most of the entries changed
+	previous.FullUploadTime = time.Now()
+	optimizer = NewMockDefault()
+	optimizer.On("GetContentHash", "AWS:Application").Return(previous.ContentHash)
+	optimizer.On("GetCheckpoint", "AWS:Application").Return(previous, true)
+	u.optimizer = optimizer
+	optimized, _, err = u.ConvertToSsmInventoryItems(context.NewMockDefault(), []model.Item{applicationsItem("a", "x", "y", "z")})
+	assert.NoError(t, err)
+	assert.False(t, isDelta(optimized[0]))
+}
+
+func TestPutInventoryAcknowledgesCheckpoints(t *testing.T) {
+	item := applicationsItem("a", "b")
+	checkpoint := checkpointOf(t, item)
+	checkpoint.FullUploadTime = time.Time{}
+	inventoryItem, _ := ConvertToSSMInventoryItem(item)
+	inventoryItem.ContentHash = &checkpoint.ContentHash
+	payload, _ := json.Marshal(&ssm.PutInventoryInput{Items: []*ssm.InventoryItem{inventoryItem}})
+
+	// a full upload the service acknowledged is the new checkpoint of the type
+	mockSSM := NewMockSSMCaller()
+	mockSSM.On("PutInventory", mock.AnythingOfType("*ssm.PutInventoryInput")).Return(&ssm.PutInventoryOutput{}, nil)
+	optimizer := NewMockDefault()
+	optimizer.On("UpdateCheckpoint", "AWS:Application", mock.MatchedBy(func(saved Checkpoint) bool {
+		return saved.ContentHash == checkpoint.ContentHash && !saved.FullUploadTime.IsZero()
+	})).Return(nil)
+	u := &InventoryUploader{ssm: mockSSM, optimizer: optimizer, pending: map[string]Checkpoint{"AWS:Application": checkpoint}}
+	assert.NoError(t, u.putInventory(log.NewMockLog(), payload))
+	optimizer.AssertExpectations(t)
+	assert.Empty(t, u.pending)
+
+	// a delta the service rejects removes the checkpoint of the type
+	uploadType := UploadTypeDelta
+	inventoryItem.Context = map[string]*string{ContextUploadType: &uploadType}
+	payload, _ = json.Marshal(&ssm.PutInventoryInput{Items: []*ssm.InventoryItem{inventoryItem}})
+	mockSSM = NewMockSSMCaller()
+	mockSSM.On("PutInventory", mock.AnythingOfType("*ssm.PutInventoryInput")).Return(&ssm.PutInventoryOutput{}, awserr.New("ItemContentMismatchException", "mismatch", nil))
+	optimizer = NewMockDefault()
+	optimizer.On("RemoveCheckpoint", "AWS:Application").Return(nil)
+	u = &InventoryUploader{ssm: mockSSM, optimizer: optimizer, pending: map[string]Checkpoint{"AWS:Application": checkpoint}}
+	assert.Error(t, u.putInventory(log.NewMockLog(), payload))
+	optimizer.AssertExpectations(t)
+}
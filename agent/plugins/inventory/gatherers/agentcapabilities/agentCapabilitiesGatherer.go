@@ -0,0 +1,79 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package agentcapabilities contains a gatherer for the capabilities of the agent, i.e. the document schema
+// versions, plugins and preconditions it supports.
+package agentcapabilities
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/runpluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	// GathererName captures name of agent capabilities gatherer
+	GathererName = "AWS:AgentCapabilities"
+	// SchemaVersionOfAgentCapabilities represents schema version of agent capabilities gatherer
+	SchemaVersionOfAgentCapabilities = "1.0"
+)
+
+// T represents agent capabilities gatherer which implements all contracts for gatherers.
+type T struct{}
+
+// decoupling runpluginutil.AgentCapabilities for easy testability
+var agentCapabilities = runpluginutil.AgentCapabilities
+
+// Gatherer returns new agent capabilities gatherer
+func Gatherer(context context.T) *T {
+	return new(T)
+}
+
+// Name returns name of agent capabilities gatherer
+func (t *T) Name() string {
+	return GathererName
+}
+
+// Run executes agent capabilities gatherer and returns list of inventory.Item containing the capabilities of the agent
+func (t *T) Run(context context.T, configuration model.Config) (items []model.Item, err error) {
+	log := context.Log()
+
+	//CaptureTime must comply with format: 2016-07-30T18:15:37Z to comply with regex at SSM.
+	currentTime := time.Now().UTC()
+	captureTime := currentTime.Format(time.RFC3339)
+
+	data := []model.AgentCapabilityData{}
+	for _, capability := range agentCapabilities() {
+		data = append(data, model.AgentCapabilityData{Capability: capability, AgentVersion: version.Version})
+	}
+	log.Infof("Number of capabilities detected by %v - %v", GathererName, len(data))
+	log.Debugf("Capabilities detected by %v:\n%v", GathererName, data)
+
+	items = append(items, model.Item{
+		Name:          t.Name(),
+		SchemaVersion: SchemaVersionOfAgentCapabilities,
+		Content:       data,
+		CaptureTime:   captureTime,
+	})
+	return
+}
+
+// RequestStop stops the execution of agent capabilities gatherer.
+func (t *T) RequestStop(stopType contracts.StopType) error {
+	var err error
+	return err
+}
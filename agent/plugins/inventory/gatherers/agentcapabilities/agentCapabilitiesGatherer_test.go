@@ -0,0 +1,45 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package agentcapabilities contains a gatherer for the capabilities of the agent, i.e. the document schema
+// versions, plugins and preconditions it supports.
+package agentcapabilities
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatherer(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	saved := agentCapabilities
+	agentCapabilities = func() []string { return []string{"plugin:aws:runShellScript", "schemaVersion:2.2"} }
+	defer func() { agentCapabilities = saved }()
+
+	gatherer := Gatherer(contextMock)
+	items, err := gatherer.Run(contextMock, model.Config{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, GathererName, items[0].Name)
+	assert.Equal(t, SchemaVersionOfAgentCapabilities, items[0].SchemaVersion)
+	assert.Equal(t, []model.AgentCapabilityData{
+		{Capability: "plugin:aws:runShellScript", AgentVersion: version.Version},
+		{Capability: "schemaVersion:2.2", AgentVersion: version.Version},
+	}, items[0].Content)
+	assert.NotEmpty(t, items[0].CaptureTime)
+}
@@ -17,6 +17,7 @@ package gatherers
 import (
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/agentcapabilities"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/custom"
@@ -26,6 +27,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/service"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/ssmpackage"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/windowsUpdate"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 )
@@ -54,6 +56,7 @@ func InitializeGatherers(context context.T) (SupportedGatherer, InstalledGathere
 	var installedGathererNames []string
 
 	installedGatherer := InstalledGatherer{
+		agentcapabilities.GathererName:           agentcapabilities.Gatherer(context),
 		application.GathererName:                 application.Gatherer(context),
 		awscomponent.GathererName:                awscomponent.Gatherer(context),
 		custom.GathererName:                      custom.Gatherer(context),
@@ -64,6 +67,7 @@ func InitializeGatherers(context context.T) (SupportedGatherer, InstalledGathere
 		role.GathererName:                        role.Gatherer(context),
 		service.GathererName:                     service.Gatherer(context),
 		registry.GathererName:                    registry.Gatherer(context),
+		ssmpackage.GathererName:                  ssmpackage.Gatherer(context),
 	}
 
 	for key := range installedGatherer {
@@ -17,19 +17,23 @@
 package gatherers
 
 import (
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/agentcapabilities"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/custom"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/file"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/instancedetailedinformation"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/network"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/ssmpackage"
 )
 
 var supportedGathererNames = []string{
+	agentcapabilities.GathererName,
 	application.GathererName,
 	awscomponent.GathererName,
 	custom.GathererName,
 	network.GathererName,
 	file.GathererName,
 	instancedetailedinformation.GathererName,
+	ssmpackage.GathererName,
 }
@@ -18,6 +18,7 @@
 package gatherers
 
 import (
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/agentcapabilities"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/custom"
@@ -27,10 +28,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/service"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/ssmpackage"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/windowsUpdate"
 )
 
 var supportedGathererNames = []string{
+	agentcapabilities.GathererName,
 	application.GathererName,
 	awscomponent.GathererName,
 	custom.GathererName,
@@ -41,4 +44,5 @@ var supportedGathererNames = []string{
 	role.GathererName,
 	service.GathererName,
 	registry.GathererName,
+	ssmpackage.GathererName,
 }
@@ -0,0 +1,74 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ssmpackage contains a gatherer for packages installed by configurePackage.
+package ssmpackage
+
+import (
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+)
+
+const (
+	// GathererName captures name of ssm package gatherer
+	GathererName = "AWS:SSMPackage"
+	// SchemaVersionOfSSMPackage represents schema version of ssm package gatherer
+	SchemaVersionOfSSMPackage = "1.0"
+)
+
+// T represents ssm package gatherer which implements all contracts for gatherers.
+type T struct{}
+
+// decoupling package repository for easy testability
+var packageRepository = localpackages.NewRepository()
+
+// Gatherer returns new ssm package gatherer
+func Gatherer(context context.T) *T {
+	return new(T)
+}
+
+// Name returns name of ssm package gatherer
+func (t *T) Name() string {
+	return GathererName
+}
+
+// Run executes ssm package gatherer and returns list of inventory.Item containing the state of packages in the local package repository
+func (t *T) Run(context context.T, configuration model.Config) (items []model.Item, err error) {
+	log := context.Log()
+
+	//CaptureTime must comply with format: 2016-07-30T18:15:37Z to comply with regex at SSM.
+	currentTime := time.Now().UTC()
+	captureTime := currentTime.Format(time.RFC3339)
+
+	data := packageRepository.GetPackageInventoryData(log)
+	log.Infof("Number of packages detected by %v - %v", GathererName, len(data))
+	log.Debugf("Packages detected by %v:\n%v", GathererName, data)
+
+	items = append(items, model.Item{
+		Name:          t.Name(),
+		SchemaVersion: SchemaVersionOfSSMPackage,
+		Content:       data,
+		CaptureTime:   captureTime,
+	})
+	return
+}
+
+// RequestStop stops the execution of ssm package gatherer.
+func (t *T) RequestStop(stopType contracts.StopType) error {
+	var err error
+	return err
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package ssmpackage contains a gatherer for packages installed by configurePackage.
+package ssmpackage
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	repomock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var samplePackageData = []model.SSMPackageData{
+	{
+		PackageArn:          "arn:aws:ssm:us-east-1:123456789012:package/ExamplePackage",
+		Version:             "1.0.2",
+		InstallState:        "Installed",
+		InstalledVersion:    "1.0.2",
+		InstalledTime:       "2018-01-02T03:04:05Z",
+		LastOperationResult: "Success",
+		LastOperationTime:   "2018-01-02T03:04:05Z",
+	},
+	{
+		PackageArn:          "AWSPVDriver",
+		Version:             "8.2.1",
+		InstallState:        "Failed",
+		InstalledVersion:    "8.2.0",
+		LastOperationResult: "Failed",
+		LastOperationTime:   "2018-02-03T04:05:06Z",
+	},
+}
+
+func withRepository(repo localpackages.Repository, f func()) {
+	saved := packageRepository
+	packageRepository = repo
+	defer func() { packageRepository = saved }()
+	f()
+}
+
+func TestGatherer(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	repoMock := new(repomock.MockedRepository)
+	repoMock.On("GetPackageInventoryData", mock.Anything).Return(samplePackageData)
+
+	withRepository(repoMock, func() {
+		gatherer := Gatherer(contextMock)
+		items, err := gatherer.Run(contextMock, model.Config{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(items))
+		assert.Equal(t, GathererName, items[0].Name)
+		assert.Equal(t, SchemaVersionOfSSMPackage, items[0].SchemaVersion)
+		assert.Equal(t, samplePackageData, items[0].Content)
+		assert.NotEmpty(t, items[0].CaptureTime)
+	})
+	repoMock.AssertExpectations(t)
+}
+
+func TestGathererEmptyRepository(t *testing.T) {
+	contextMock := context.NewMockDefault()
+	repoMock := new(repomock.MockedRepository)
+	repoMock.On("GetPackageInventoryData", mock.Anything).Return([]model.SSMPackageData{})
+
+	withRepository(repoMock, func() {
+		items, err := Gatherer(contextMock).Run(contextMock, model.Config{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(items))
+		assert.Equal(t, []model.SSMPackageData{}, items[0].Content)
+	})
+}
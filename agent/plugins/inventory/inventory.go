@@ -31,6 +31,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/datauploader"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/agentcapabilities"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/application"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/awscomponent"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/custom"
@@ -40,10 +41,12 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/registry"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/role"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/service"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/ssmpackage"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/gatherers/windowsUpdate"
 	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
@@ -58,8 +61,12 @@ const (
 	errorMsgForInabilityToSendDataToSSM       = "inventory data could not be uploaded to Systems Manager. Additional troubleshooting information - %v"
 	msgWhenNoDataToReturnForInventoryPlugin   = "Inventory policy has been successfully applied but there is no inventory data to upload to SSM"
 	successfulMsgForInventoryPlugin           = "Inventory policy has been successfully applied and collected inventory data has been uploaded to SSM"
+	msgWhenDeferredByAPIThrottling            = "Inventory collection has been skipped while the api calls of the agent are throttled - %v"
 )
 
+// collectionStretcher skips inventory collections while the api calls of the agent are throttled
+var collectionStretcher throttle.Stretcher
+
 // PluginInput represents configuration which is applied to inventory plugin during execution.
 type PluginInput struct {
 	contracts.PluginInput
@@ -72,6 +79,8 @@ type PluginInput struct {
 	WindowsRegistry             string
 	WindowsUpdates              string
 	InstanceDetailedInformation string
+	SSMPackages                 string
+	AgentCapabilities           string
 	CustomInventory             string
 	CustomInventoryDirectory    string
 }
@@ -320,6 +329,8 @@ func (p *Plugin) ValidateInventoryInput(context context.T, input PluginInput) (c
 		network.GathererName:                     input.NetworkConfig,
 		windowsUpdate.GathererName:               input.WindowsUpdates,
 		instancedetailedinformation.GathererName: input.InstanceDetailedInformation,
+		ssmpackage.GathererName:                  input.SSMPackages,
+		agentcapabilities.GathererName:           input.AgentCapabilities,
 	}
 
 	predefinedGatherersWithFilters := map[string]string{
@@ -576,6 +587,15 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 	dataB, _ = json.Marshal(inventoryInput)
 	log.Infof("Inventory configuration after parsing - %v", string(dataB))
 
+	if !collectionStretcher.Due(context.AppConfig().Throttle) {
+		msg := fmt.Sprintf(msgWhenDeferredByAPIThrottling, throttle.FormatAPIThrottlingStats(throttle.APIThrottlingStats()))
+		log.Info(msg)
+		output.SetExitCode(0)
+		output.SetStatus(contracts.ResultStatusSuccess)
+		output.AppendInfo(msg)
+		return
+	}
+
 	p.ApplyInventoryPolicy(context, inventoryInput, output)
 
 	//check inventory plugin output
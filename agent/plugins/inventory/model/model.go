@@ -169,6 +169,23 @@ type InstanceDetailedInformation struct {
 	OSServicePack         string
 }
 
+// SSMPackageData captures all attributes present in AWS:SSMPackage inventory type
+type SSMPackageData struct {
+	PackageArn          string
+	Version             string
+	InstallState        string
+	InstalledVersion    string `json:",omitempty"`
+	InstalledTime       string `json:",omitempty"`
+	LastOperationResult string
+	LastOperationTime   string
+}
+
+// AgentCapabilityData captures all attributes present in AWS:AgentCapabilities inventory type
+type AgentCapabilityData struct {
+	Capability   string
+	AgentVersion string
+}
+
 // Config captures all various properties (including optional) that can be supplied to a gatherer.
 // NOTE: Not all properties will be applicable to all gatherers.
 // E.g: Applications gatherer uses Collection, Files use Filters, Custom uses Collection & Location.
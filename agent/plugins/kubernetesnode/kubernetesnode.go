@@ -0,0 +1,280 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kubernetesnode implements the aws:kubernetesNode plugin, it cordons, drains, uncordons and labels
+// the Kubernetes node of the instance with kubectl so node maintenance can be orchestrated with documents.
+package kubernetesnode
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// ActionCordon marks the node unschedulable
+	ActionCordon = "Cordon"
+	// ActionDrain cordons the node and evicts its pods
+	ActionDrain = "Drain"
+	// ActionUncordon marks the node schedulable
+	ActionUncordon = "Uncordon"
+	// ActionLabel sets and removes labels of the node
+	ActionLabel = "Label"
+
+	defaultKubectl = "kubectl"
+)
+
+// labelKeyPattern and labelValuePattern are the label syntax of Kubernetes, they keep the labels from being
+// taken as options by kubectl
+var (
+	labelKeyPattern   = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+)
+
+// hostname returns the name of the instance, the node name defaults to it
+var hostname = os.Hostname
+
+// Plugin is the type for the kubernetesNode plugin.
+type Plugin struct {
+	// CommandExecuter runs kubectl.
+	CommandExecuter executers.T
+}
+
+// KubernetesNodePluginInput represents the action a step takes on the node of the instance.
+type KubernetesNodePluginInput struct {
+	contracts.PluginInput
+	ID     string
+	Action string
+	// NodeName defaults to the host name of the instance
+	NodeName string
+	// Kubeconfig defaults to the kubeconfig of the kubelet
+	Kubeconfig  string
+	KubectlPath string
+
+	// Label
+	Labels       map[string]string
+	RemoveLabels []string
+
+	// Drain, GracePeriodSeconds defaults to the grace period of each pod
+	IgnoreDaemonSets   *bool
+	DeleteEmptyDirData bool
+	Force              bool
+	GracePeriodSeconds *int
+
+	TimeoutSeconds interface{}
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsKubernetesNode
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput KubernetesNodePluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	p.run(log, pluginInput, cancelFlag, output)
+}
+
+// validateInput checks the input and sets the defaults of the fields left out
+func validateInput(pluginInput *KubernetesNodePluginInput) error {
+	switch pluginInput.Action {
+	case ActionCordon, ActionDrain, ActionUncordon:
+	case ActionLabel:
+		if len(pluginInput.Labels) == 0 && len(pluginInput.RemoveLabels) == 0 {
+			return fmt.Errorf("Labels or RemoveLabels are required when Action is %v", ActionLabel)
+		}
+		for key, value := range pluginInput.Labels {
+			if !labelKeyPattern.MatchString(key) || !labelValuePattern.MatchString(value) {
+				return fmt.Errorf("label %v=%v is not valid", key, value)
+			}
+		}
+		for _, key := range pluginInput.RemoveLabels {
+			if !labelKeyPattern.MatchString(key) {
+				return fmt.Errorf("label %v is not valid", key)
+			}
+		}
+	default:
+		return fmt.Errorf("Action is set to unsupported value %v, expected one of %v, %v, %v or %v",
+			pluginInput.Action, ActionCordon, ActionDrain, ActionUncordon, ActionLabel)
+	}
+	if strings.HasPrefix(pluginInput.NodeName, "-") {
+		return fmt.Errorf("NodeName %v is not valid", pluginInput.NodeName)
+	}
+	if pluginInput.GracePeriodSeconds != nil && *pluginInput.GracePeriodSeconds < 0 {
+		return fmt.Errorf("GracePeriodSeconds must not be negative")
+	}
+	if pluginInput.KubectlPath == "" {
+		pluginInput.KubectlPath = defaultKubectl
+	}
+	return nil
+}
+
+func (p *Plugin) run(log log.T, pluginInput KubernetesNodePluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+
+	kubeconfig := pluginInput.Kubeconfig
+	if kubeconfig == "" {
+		if kubeconfig = kubeletKubeconfig(); kubeconfig == "" {
+			output.MarkAsFailed(fmt.Errorf("no kubelet kubeconfig found in %v, set Kubeconfig", strings.Join(kubeletKubeconfigs, ", ")))
+			return
+		}
+	}
+	nodeName := pluginInput.NodeName
+	if nodeName == "" {
+		name, err := hostname()
+		if err != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to get the host name of the instance, set NodeName: %v", err))
+			return
+		}
+		nodeName = strings.ToLower(name)
+	}
+
+	kubectl := func(args ...string) (string, error) {
+		return p.kubectl(log, pluginInput.KubectlPath, kubeconfig, cancelFlag, timeout, args...)
+	}
+	if _, err := kubectl("get", "node", nodeName, "-o", "name"); err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("node %v is not found, set NodeName to the name of the node of the instance: %v", nodeName, err))
+		return
+	}
+
+	stdout, err := kubectl(actionArgs(pluginInput, nodeName, timeout)...)
+	if stdout != "" {
+		output.AppendInfo(stdout)
+	}
+	if err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to %v node %v: %v", strings.ToLower(pluginInput.Action), nodeName, err))
+		return
+	}
+
+	unschedulable, err := kubectl("get", "node", nodeName, "-o", "jsonpath={.spec.unschedulable}")
+	if err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to get node %v: %v", nodeName, err))
+		return
+	}
+	output.AppendInfof("Node %v is schedulable: %v", nodeName, unschedulable != "true")
+	output.MarkAsSucceeded()
+}
+
+// actionArgs returns the kubectl arguments of the action
+func actionArgs(pluginInput KubernetesNodePluginInput, nodeName string, timeout int) []string {
+	switch pluginInput.Action {
+	case ActionCordon:
+		return []string{"cordon", nodeName}
+	case ActionUncordon:
+		return []string{"uncordon", nodeName}
+	case ActionDrain:
+		args := []string{"drain", nodeName, "--timeout", strconv.Itoa(timeout) + "s"}
+		if pluginInput.IgnoreDaemonSets == nil || *pluginInput.IgnoreDaemonSets {
+			args = append(args, "--ignore-daemonsets")
+		}
+		if pluginInput.DeleteEmptyDirData {
+			args = append(args, "--delete-emptydir-data")
+		}
+		if pluginInput.Force {
+			args = append(args, "--force")
+		}
+		if pluginInput.GracePeriodSeconds != nil {
+			args = append(args, "--grace-period", strconv.Itoa(*pluginInput.GracePeriodSeconds))
+		}
+		return args
+	default:
+		var labels []string
+		for key, value := range pluginInput.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		for _, key := range pluginInput.RemoveLabels {
+			labels = append(labels, key+"-")
+		}
+		sort.Strings(labels)
+		return append([]string{"label", "node", nodeName, "--overwrite"}, labels...)
+	}
+}
+
+// kubeletKubeconfig returns the first kubeconfig of the kubelet found on the instance
+func kubeletKubeconfig() string {
+	for _, path := range kubeletKubeconfigs {
+		if fileutil.Exists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+func (p *Plugin) kubectl(log log.T, kubectl string, kubeconfig string, cancelFlag task.CancelFlag, timeout int, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"--kubeconfig", kubeconfig}, args...)
+	exitCode, err := p.CommandExecuter.NewExecute(log, "", &stdout, &stderr, cancelFlag, timeout, kubectl, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return strings.TrimSpace(stdout.String()), fmt.Errorf("%v %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
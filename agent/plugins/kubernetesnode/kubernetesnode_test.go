@@ -0,0 +1,136 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kubernetesnode
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKubectl answers each kubectl command with the output and exit code of run, and records the commands
+// it ran without the kubeconfig
+type fakeKubectl struct {
+	executers.MockCommandExecuter
+	run      func(command string) (string, int)
+	commands []string
+}
+
+func (f *fakeKubectl) NewExecute(log log.T, workingDir string, stdout io.Writer, stderr io.Writer, cancelFlag task.CancelFlag, timeout int, commandName string, commandArguments []string) (int, error) {
+	command := strings.Join(commandArguments[2:], " ")
+	f.commands = append(f.commands, command)
+	output, exitCode := f.run(command)
+	io.WriteString(stdout, output)
+	return exitCode, nil
+}
+
+func TestValidateInput(t *testing.T) {
+	for _, input := range []KubernetesNodePluginInput{
+		{Action: "Reboot"},
+		{Action: ActionLabel},
+		{Action: ActionLabel, Labels: map[string]string{"--all": "true"}},
+		{Action: ActionLabel, Labels: map[string]string{"role": "a b"}},
+		{Action: ActionLabel, RemoveLabels: []string{"-role"}},
+		{Action: ActionCordon, NodeName: "--all"},
+	} {
+		assert.Error(t, validateInput(&input), "%v", input)
+	}
+
+	input := KubernetesNodePluginInput{Action: ActionLabel, Labels: map[string]string{"example.com/maintenance": "", "role": "web-1"}}
+	assert.NoError(t, validateInput(&input))
+	assert.Equal(t, defaultKubectl, input.KubectlPath)
+}
+
+func TestActionArgs(t *testing.T) {
+	ignoreDaemonSets, gracePeriod := false, 30
+	assert.Equal(t, []string{"drain", "node-1", "--timeout", "600s", "--ignore-daemonsets"},
+		actionArgs(KubernetesNodePluginInput{Action: ActionDrain}, "node-1", 600))
+	assert.Equal(t, []string{"drain", "node-1", "--timeout", "600s", "--delete-emptydir-data", "--force", "--grace-period", "30"},
+		actionArgs(KubernetesNodePluginInput{Action: ActionDrain, IgnoreDaemonSets: &ignoreDaemonSets, DeleteEmptyDirData: true, Force: true, GracePeriodSeconds: &gracePeriod}, "node-1", 600))
+	assert.Equal(t, []string{"label", "node", "node-1", "--overwrite", "maintenance-", "role=web"},
+		actionArgs(KubernetesNodePluginInput{Action: ActionLabel, Labels: map[string]string{"role": "web"}, RemoveLabels: []string{"maintenance"}}, "node-1", 600))
+}
+
+func TestCordon(t *testing.T) {
+	executer := &fakeKubectl{run: func(command string) (string, int) {
+		switch {
+		case strings.HasPrefix(command, "get node node-1 -o name"):
+			return "node/node-1", 0
+		case strings.HasPrefix(command, "cordon"):
+			return "node/node-1 cordoned", 0
+		default:
+			return "true", 0
+		}
+	}}
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionCordon, "NodeName": "node-1", "Kubeconfig": "/etc/kubeconfig"})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "node/node-1 cordoned")
+	assert.Contains(t, output.GetStdout(), "Node node-1 is schedulable: false")
+	assert.Equal(t, []string{"get node node-1 -o name", "cordon node-1", "get node node-1 -o jsonpath={.spec.unschedulable}"}, executer.commands)
+}
+
+func TestNodeNameDefaultsToHostname(t *testing.T) {
+	defer func(h func() (string, error)) { hostname = h }(hostname)
+	hostname = func() (string, error) { return "IP-10-0-0-1.ec2.internal", nil }
+	executer := &fakeKubectl{run: func(command string) (string, int) {
+		return "", 0
+	}}
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionUncordon, "Kubeconfig": "/etc/kubeconfig"})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus())
+	assert.Equal(t, "uncordon ip-10-0-0-1.ec2.internal", (executer.commands)[1])
+	assert.Contains(t, output.GetStdout(), "is schedulable: true")
+}
+
+func TestNodeNotFound(t *testing.T) {
+	executer := &fakeKubectl{run: func(command string) (string, int) {
+		return "", 1
+	}}
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionDrain, "NodeName": "node-1", "Kubeconfig": "/etc/kubeconfig"})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "node node-1 is not found")
+	assert.Len(t, executer.commands, 1)
+}
+
+func TestDrainFailure(t *testing.T) {
+	executer := &fakeKubectl{run: func(command string) (string, int) {
+		if strings.HasPrefix(command, "drain") {
+			return "evicting pod default/web", 1
+		}
+		return "", 0
+	}}
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionDrain, "NodeName": "node-1", "Kubeconfig": "/etc/kubeconfig"})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStdout(), "evicting pod default/web")
+	assert.Contains(t, output.GetStderr(), "failed to drain node node-1")
+}
+
+func TestMissingKubeconfig(t *testing.T) {
+	defer func(paths []string) { kubeletKubeconfigs = paths }(kubeletKubeconfigs)
+	kubeletKubeconfigs = []string{"/nonexistent/kubeconfig"}
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: &fakeKubectl{}}, map[string]interface{}{"Action": ActionCordon, "NodeName": "node-1"})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "no kubelet kubeconfig found in /nonexistent/kubeconfig")
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package lifecycleaction implements the aws:lifecycleAction plugin, which records heartbeats for and completes the
+// Auto Scaling lifecycle hook action of the instance, so a document can drain, patch and then signal an instance
+// held by a lifecycle hook during an instance refresh.
+package lifecycleaction
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+)
+
+const (
+	// ActionComplete completes the lifecycle action, which lets Auto Scaling carry on with the launch or the
+	// termination of the instance
+	ActionComplete = "Complete"
+	// ActionHeartbeat records a heartbeat, which restarts the timeout of the lifecycle hook
+	ActionHeartbeat = "Heartbeat"
+
+	// ResultContinue lets the launch or the termination of the instance go on
+	ResultContinue = "CONTINUE"
+	// ResultAbandon terminates an instance being launched, and lets the termination of an instance go on
+	ResultAbandon = "ABANDON"
+
+	// waitStateSuffix ends the lifecycle states of instances held by a lifecycle hook, such as Pending:Wait
+	waitStateSuffix = ":Wait"
+)
+
+// Plugin is the type for the lifecycleAction plugin.
+type Plugin struct{}
+
+// LifecycleActionPluginInput represents the lifecycle action a step records or completes.
+type LifecycleActionPluginInput struct {
+	contracts.PluginInput
+	ID                string
+	Action            string
+	LifecycleHookName string
+	// AutoScalingGroupName is the group of the instance, looked up when it isn't declared
+	AutoScalingGroupName string
+	// LifecycleActionToken identifies the lifecycle action, the instance identifies it when it isn't declared
+	LifecycleActionToken string
+	// Result is how the lifecycle action is completed, CONTINUE or ABANDON
+	Result string
+}
+
+// Report is the output of the plugin
+type Report struct {
+	Action               string
+	InstanceId           string
+	AutoScalingGroupName string
+	LifecycleHookName    string
+	// LifecycleState is the state of the instance before the action, such as Pending:Wait or Terminating:Wait
+	LifecycleState string
+	Result         string `json:",omitempty"`
+}
+
+// instanceID returns the id of the instance whose lifecycle action is recorded or completed
+var instanceID = platform.InstanceID
+
+// newAutoScalingClient returns the client of the Auto Scaling API
+var newAutoScalingClient = func() autoscalingiface.AutoScalingAPI {
+	appConfig, _ := appconfig.Config(false)
+	sess := session.New(sdkutil.AwsConfig())
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
+	return autoscaling.New(sess)
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	return &Plugin{}, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsLifecycleAction
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, output iohandler.IOHandler) {
+	var pluginInput LifecycleActionPluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+	p.run(log, pluginInput, output)
+}
+
+// validateInput checks the input and sets the defaults, which complete the lifecycle action with CONTINUE
+func validateInput(pluginInput *LifecycleActionPluginInput) error {
+	if pluginInput.Action == "" {
+		pluginInput.Action = ActionComplete
+	}
+	if pluginInput.Action != ActionComplete && pluginInput.Action != ActionHeartbeat {
+		return fmt.Errorf("Action is set to unsupported value %v, expected %v or %v", pluginInput.Action, ActionComplete, ActionHeartbeat)
+	}
+	if pluginInput.LifecycleHookName = strings.TrimSpace(pluginInput.LifecycleHookName); pluginInput.LifecycleHookName == "" {
+		return fmt.Errorf("LifecycleHookName must be declared")
+	}
+	pluginInput.AutoScalingGroupName = strings.TrimSpace(pluginInput.AutoScalingGroupName)
+	pluginInput.LifecycleActionToken = strings.TrimSpace(pluginInput.LifecycleActionToken)
+	pluginInput.Result = strings.ToUpper(strings.TrimSpace(pluginInput.Result))
+	if pluginInput.Action == ActionHeartbeat {
+		if pluginInput.Result != "" {
+			return fmt.Errorf("Result is only supported by the %v action", ActionComplete)
+		}
+		return nil
+	}
+	if pluginInput.Result == "" {
+		pluginInput.Result = ResultContinue
+	}
+	if pluginInput.Result != ResultContinue && pluginInput.Result != ResultAbandon {
+		return fmt.Errorf("Result is set to unsupported value %v, expected %v or %v", pluginInput.Result, ResultContinue, ResultAbandon)
+	}
+	return nil
+}
+
+func (p *Plugin) run(log log.T, pluginInput LifecycleActionPluginInput, output iohandler.IOHandler) {
+	instance, err := instanceID()
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("failed to get the id of the instance: %v", err))
+		return
+	}
+	client := newAutoScalingClient()
+
+	details, err := describeInstance(client, instance)
+	if err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	report := Report{
+		Action:               pluginInput.Action,
+		InstanceId:           instance,
+		AutoScalingGroupName: aws.StringValue(details.AutoScalingGroupName),
+		LifecycleHookName:    pluginInput.LifecycleHookName,
+		LifecycleState:       aws.StringValue(details.LifecycleState),
+		Result:               pluginInput.Result,
+	}
+	if pluginInput.AutoScalingGroupName != "" && pluginInput.AutoScalingGroupName != report.AutoScalingGroupName {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput,
+			fmt.Errorf("instance %v belongs to Auto Scaling group %v, not %v", instance, report.AutoScalingGroupName, pluginInput.AutoScalingGroupName)))
+		return
+	}
+	if !strings.HasSuffix(report.LifecycleState, waitStateSuffix) {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeNotReady,
+			fmt.Errorf("instance %v is in lifecycle state %v, no lifecycle action is pending", instance, report.LifecycleState)))
+		return
+	}
+
+	var token *string
+	if pluginInput.LifecycleActionToken != "" {
+		token = aws.String(pluginInput.LifecycleActionToken)
+	}
+	switch pluginInput.Action {
+	case ActionHeartbeat:
+		_, err = client.RecordLifecycleActionHeartbeat(&autoscaling.RecordLifecycleActionHeartbeatInput{
+			AutoScalingGroupName: aws.String(report.AutoScalingGroupName),
+			LifecycleHookName:    aws.String(report.LifecycleHookName),
+			InstanceId:           aws.String(instance),
+			LifecycleActionToken: token,
+		})
+		if err != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to record a heartbeat for lifecycle hook %v: %v", report.LifecycleHookName, err))
+			return
+		}
+		output.AppendInfof("Recorded a heartbeat for lifecycle hook %v of Auto Scaling group %v, its timeout restarted",
+			report.LifecycleHookName, report.AutoScalingGroupName)
+	case ActionComplete:
+		_, err = client.CompleteLifecycleAction(&autoscaling.CompleteLifecycleActionInput{
+			AutoScalingGroupName:  aws.String(report.AutoScalingGroupName),
+			LifecycleHookName:     aws.String(report.LifecycleHookName),
+			InstanceId:            aws.String(instance),
+			LifecycleActionToken:  token,
+			LifecycleActionResult: aws.String(report.Result),
+		})
+		if err != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to complete the action of lifecycle hook %v: %v", report.LifecycleHookName, err))
+			return
+		}
+		output.AppendInfof("Completed the action of lifecycle hook %v of Auto Scaling group %v with %v",
+			report.LifecycleHookName, report.AutoScalingGroupName, report.Result)
+	}
+
+	reportJson, _ := jsonutil.Marshal(report)
+	output.AppendInfo(jsonutil.Indent(reportJson))
+	output.MarkAsSucceeded()
+}
+
+// describeInstance returns the Auto Scaling group and the lifecycle state of the instance
+func describeInstance(client autoscalingiface.AutoScalingAPI, instance string) (*autoscaling.InstanceDetails, error) {
+	described, err := client.DescribeAutoScalingInstances(&autoscaling.DescribeAutoScalingInstancesInput{
+		InstanceIds: []*string{aws.String(instance)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe Auto Scaling instance %v: %v", instance, err)
+	}
+	if len(described.AutoScalingInstances) == 0 {
+		return nil, contracts.NewCodedError(contracts.ErrorCodeNotFound,
+			fmt.Errorf("instance %v is not part of an Auto Scaling group", instance))
+	}
+	return described.AutoScalingInstances[0], nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package lifecycleaction
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/stretchr/testify/assert"
+)
+
+const testInstanceID = "i-0123456789abcdef0"
+
+// autoScalingStub answers the Auto Scaling calls of the plugin and records the lifecycle actions
+type autoScalingStub struct {
+	autoscalingiface.AutoScalingAPI
+	instances  []*autoscaling.InstanceDetails
+	heartbeats []*autoscaling.RecordLifecycleActionHeartbeatInput
+	completed  []*autoscaling.CompleteLifecycleActionInput
+	err        error
+}
+
+func (s *autoScalingStub) DescribeAutoScalingInstances(*autoscaling.DescribeAutoScalingInstancesInput) (*autoscaling.DescribeAutoScalingInstancesOutput, error) {
+	return &autoscaling.DescribeAutoScalingInstancesOutput{AutoScalingInstances: s.instances}, nil
+}
+
+func (s *autoScalingStub) RecordLifecycleActionHeartbeat(input *autoscaling.RecordLifecycleActionHeartbeatInput) (*autoscaling.RecordLifecycleActionHeartbeatOutput, error) {
+	s.heartbeats = append(s.heartbeats, input)
+	return &autoscaling.RecordLifecycleActionHeartbeatOutput{}, s.err
+}
+
+func (s *autoScalingStub) CompleteLifecycleAction(input *autoscaling.CompleteLifecycleActionInput) (*autoscaling.CompleteLifecycleActionOutput, error) {
+	s.completed = append(s.completed, input)
+	return &autoscaling.CompleteLifecycleActionOutput{}, s.err
+}
+
+func waitingInstance(state string) []*autoscaling.InstanceDetails {
+	return []*autoscaling.InstanceDetails{{
+		InstanceId:           aws.String(testInstanceID),
+		AutoScalingGroupName: aws.String("web"),
+		LifecycleState:       aws.String(state),
+	}}
+}
+
+func runPlugin(stub *autoScalingStub, properties map[string]interface{}) *iohandler.DefaultIOHandler {
+	defer func(id func() (string, error), client func() autoscalingiface.AutoScalingAPI) {
+		instanceID, newAutoScalingClient = id, client
+	}(instanceID, newAutoScalingClient)
+	instanceID = func() (string, error) { return testInstanceID, nil }
+	newAutoScalingClient = func() autoscalingiface.AutoScalingAPI { return stub }
+
+	output := &iohandler.DefaultIOHandler{}
+	(&Plugin{}).runRawInput(log.NewMockLog(), properties, output)
+	return output
+}
+
+func TestCompleteLifecycleAction(t *testing.T) {
+	stub := &autoScalingStub{instances: waitingInstance("Terminating:Wait")}
+
+	output := runPlugin(stub, map[string]interface{}{"LifecycleHookName": "drain", "Result": "abandon"})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Len(t, stub.completed, 1)
+	assert.Equal(t, "web", aws.StringValue(stub.completed[0].AutoScalingGroupName))
+	assert.Equal(t, "drain", aws.StringValue(stub.completed[0].LifecycleHookName))
+	assert.Equal(t, testInstanceID, aws.StringValue(stub.completed[0].InstanceId))
+	assert.Equal(t, ResultAbandon, aws.StringValue(stub.completed[0].LifecycleActionResult))
+	assert.Nil(t, stub.completed[0].LifecycleActionToken)
+	assert.Contains(t, output.GetStdout(), `"LifecycleState": "Terminating:Wait"`)
+}
+
+func TestRecordLifecycleActionHeartbeat(t *testing.T) {
+	stub := &autoScalingStub{instances: waitingInstance("Pending:Wait")}
+	token := "bcd2f1b8-9a78-44d3-8a7a-4dd07d7cf635"
+
+	output := runPlugin(stub, map[string]interface{}{
+		"Action":               ActionHeartbeat,
+		"LifecycleHookName":    "patch",
+		"AutoScalingGroupName": "web",
+		"LifecycleActionToken": token,
+	})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Len(t, stub.heartbeats, 1)
+	assert.Equal(t, token, aws.StringValue(stub.heartbeats[0].LifecycleActionToken))
+	assert.Empty(t, stub.completed)
+}
+
+func TestLifecycleActionFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		stub       *autoScalingStub
+		properties map[string]interface{}
+		errorCode  contracts.ErrorCode
+	}{
+		{"no hook", &autoScalingStub{}, map[string]interface{}{}, contracts.ErrorCodeInvalidInput},
+		{"unsupported action", &autoScalingStub{}, map[string]interface{}{"Action": "Abandon", "LifecycleHookName": "drain"}, contracts.ErrorCodeInvalidInput},
+		{"unsupported result", &autoScalingStub{}, map[string]interface{}{"LifecycleHookName": "drain", "Result": "RETRY"}, contracts.ErrorCodeInvalidInput},
+		{"heartbeat result", &autoScalingStub{}, map[string]interface{}{"Action": ActionHeartbeat, "LifecycleHookName": "drain", "Result": "CONTINUE"}, contracts.ErrorCodeInvalidInput},
+		{"not in a group", &autoScalingStub{}, map[string]interface{}{"LifecycleHookName": "drain"}, contracts.ErrorCodeNotFound},
+		{
+			"other group",
+			&autoScalingStub{instances: waitingInstance("Pending:Wait")},
+			map[string]interface{}{"LifecycleHookName": "drain", "AutoScalingGroupName": "api"},
+			contracts.ErrorCodeInvalidInput,
+		},
+		{
+			"no pending action",
+			&autoScalingStub{instances: waitingInstance("InService")},
+			map[string]interface{}{"LifecycleHookName": "drain"},
+			contracts.ErrorCodeNotReady,
+		},
+		{
+			"api failure",
+			&autoScalingStub{instances: waitingInstance("Pending:Wait"), err: errors.New("no active lifecycle action")},
+			map[string]interface{}{"LifecycleHookName": "drain"},
+			contracts.ErrorCodeInternal,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := runPlugin(test.stub, test.properties)
+			assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+			assert.Equal(t, test.errorCode, output.GetErrorCode())
+		})
+	}
+}
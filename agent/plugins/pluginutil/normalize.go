@@ -0,0 +1,111 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package pluginutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+// binarySniffLength is how much of a file is searched for a NUL byte to tell binary files from text files
+const binarySniffLength = 8000
+
+// pathVariable matches the path variables expanded in the content:
+// {{path:<path>}} is the path with the separators of the platform,
+// {{pathSeparator}} and {{pathListSeparator}} are the separators of the platform and {{tempDir}} its temporary directory
+var pathVariable = regexp.MustCompile(`\{\{\s*(path:[^}]*|pathSeparator|pathListSeparator|tempDir)\s*\}\}`)
+
+// NormalizeContent applies the content normalization of the step to the file or to the files under the directory,
+// binary files are left untouched.
+func NormalizeContent(path string, policy contracts.ContentNormalization) error {
+	if policy.IsEmpty() || !fileutil.Exists(path) {
+		return nil
+	}
+	return filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if isBinary(content) {
+			return nil
+		}
+		normalized := NormalizeText(content, policy)
+		if bytes.Equal(content, normalized) {
+			return nil
+		}
+		// the file exists, WriteFile keeps its permissions
+		return ioutil.WriteFile(file, normalized, info.Mode().Perm())
+	})
+}
+
+// NormalizeText expands the path variables and converts the line endings of the content.
+func NormalizeText(content []byte, policy contracts.ContentNormalization) []byte {
+	if policy.ExpandPaths {
+		content = pathVariable.ReplaceAllFunc(content, expandPathVariable)
+	}
+	lineEndings := policy.LineEndings
+	if lineEndings == contracts.LineEndingsNative {
+		lineEndings = contracts.LineEndingsLF
+		if runtime.GOOS == "windows" {
+			lineEndings = contracts.LineEndingsCRLF
+		}
+	}
+	switch lineEndings {
+	case contracts.LineEndingsLF:
+		content = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+	case contracts.LineEndingsCRLF:
+		content = bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+		content = bytes.Replace(content, []byte("\n"), []byte("\r\n"), -1)
+	}
+	return content
+}
+
+// expandPathVariable returns the value of the path variable on the platform
+func expandPathVariable(variable []byte) []byte {
+	name := strings.TrimSpace(string(variable[2 : len(variable)-2]))
+	switch {
+	case strings.HasPrefix(name, "path:"):
+		path := strings.TrimSpace(strings.TrimPrefix(name, "path:"))
+		path = strings.Replace(path, "/", string(os.PathSeparator), -1)
+		return []byte(strings.Replace(path, `\`, string(os.PathSeparator), -1))
+	case name == "pathSeparator":
+		return []byte{os.PathSeparator}
+	case name == "pathListSeparator":
+		return []byte{os.PathListSeparator}
+	default:
+		return []byte(os.TempDir())
+	}
+}
+
+// isBinary returns true if the beginning of the content has a NUL byte, which text files don't have
+func isBinary(content []byte) bool {
+	if len(content) > binarySniffLength {
+		content = content[:binarySniffLength]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -89,6 +89,18 @@ func CreateScriptFile(log log.T, scriptPath string, runCommand []string, byteOrd
 	return
 }
 
+// ApplyFilePermissions applies the file permission policy of the document to the path and everything under it.
+func ApplyFilePermissions(path string, policy contracts.FilePermissionPolicy) error {
+	if policy.IsEmpty() || !fileutil.Exists(path) {
+		return nil
+	}
+	umask, err := policy.Mask()
+	if err != nil {
+		return err
+	}
+	return fileutil.ApplyFilePermissions(path, umask, policy.RestrictAcl)
+}
+
 // DownloadFileFromSource downloads file from source
 func DownloadFileFromSource(log log.T, source string, sourceHash string, sourceHashType string) (artifact.DownloadOutput, error) {
 	// download source and verify its integrity
@@ -112,6 +124,7 @@ func LoadParametersAsList(log log.T, prop interface{}, res *contracts.PluginResu
 			res.Output = "Execution failed because agent is unable to parse plugin configuration"
 			res.Code = 1
 			res.Status = contracts.ResultStatusFailed
+			res.ErrorCode = contracts.ErrorCodeInvalidInput
 		}
 	default:
 		properties = append(properties, prop)
@@ -127,6 +140,7 @@ func LoadParametersAsMap(log log.T, prop interface{}, out iohandler.IOHandler) (
 		out.AppendError("Execution failed because agent is unable to parse plugin configuration")
 		out.SetExitCode(1)
 		out.SetStatus(contracts.ResultStatusFailed)
+		out.SetErrorCode(contracts.ErrorCodeInvalidInput)
 	}
 	return
 }
@@ -16,9 +16,13 @@ package pluginutil
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -190,3 +194,30 @@ func TestCleanupJSONField(t *testing.T) {
 		assert.Equal(t, output, result)
 	}
 }
+
+func TestNormalizeText(t *testing.T) {
+	content := []byte("cd {{path:/opt/app\\bin}}\r\nexport PATH=$PATH{{ pathListSeparator }}bin\n{{unknown}}\n")
+
+	normalized := NormalizeText(content, contracts.ContentNormalization{LineEndings: contracts.LineEndingsLF, ExpandPaths: true})
+
+	separator := string(os.PathSeparator)
+	expected := "cd " + separator + "opt" + separator + "app" + separator + "bin\nexport PATH=$PATH" + string(os.PathListSeparator) + "bin\n{{unknown}}\n"
+	assert.Equal(t, expected, string(normalized))
+	assert.Equal(t, "a\r\nb\r\n", string(NormalizeText([]byte("a\r\nb\n"), contracts.ContentNormalization{LineEndings: contracts.LineEndingsCRLF})))
+	assert.Equal(t, string(content), string(NormalizeText(content, contracts.ContentNormalization{})))
+}
+
+func TestNormalizeContentSkipsBinaryFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "normalize")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "script.sh"), []byte("echo a\r\n"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "tool.bin"), []byte("\x00\r\n"), 0600)
+
+	err := NormalizeContent(dir, contracts.ContentNormalization{LineEndings: contracts.LineEndingsLF})
+
+	assert.NoError(t, err)
+	script, _ := ioutil.ReadFile(filepath.Join(dir, "script.sh"))
+	assert.Equal(t, "echo a\n", string(script))
+	binary, _ := ioutil.ReadFile(filepath.Join(dir, "tool.bin"))
+	assert.Equal(t, "\x00\r\n", string(binary))
+}
@@ -14,7 +14,18 @@
 package pluginutil
 
 import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -32,3 +43,53 @@ func (m *MockDefaultPlugin) UploadOutputToS3Bucket(log log.T, pluginID string, o
 	log.Infof("args are %v", args)
 	return args.Get(0).([]string)
 }
+
+// ExecutablePlugin is a plugin run by RunPlugin
+type ExecutablePlugin interface {
+	Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler)
+}
+
+// RunPlugin executes the plugin with the properties in a temporary orchestration directory, removed once the plugin
+// returns, and returns its output.
+func RunPlugin(t assert.TestingT, plugin ExecutablePlugin, properties interface{}) *iohandler.DefaultIOHandler {
+	orchestrationDir, err := ioutil.TempDir("", "plugin")
+	assert.NoError(t, err)
+	defer os.RemoveAll(orchestrationDir)
+
+	output := &iohandler.DefaultIOHandler{}
+	config := contracts.Configuration{Properties: properties, OrchestrationDirectory: orchestrationDir}
+	plugin.Execute(context.NewMockDefault(), config, task.NewChanneledCancelFlag(), output)
+	return output
+}
+
+// MockCommands returns a command executer answering each command run with NewExecute or NewExecuteWithOptions with
+// the output of the function, and the command lines it ran. The function is given the command line, the name of the
+// command and its arguments joined by spaces, and the arguments.
+func MockCommands(run func(commandLine string, args []string) string) (*executers.MockCommandExecuter, *[]string) {
+	return MockCommandsWithExitCode(func(commandLine string, args []string) (string, int) {
+		return run(commandLine, args), 0
+	})
+}
+
+// MockCommandsWithExitCode is MockCommands for a function also returning the exit code of each command
+func MockCommandsWithExitCode(run func(commandLine string, args []string) (string, int)) (*executers.MockCommandExecuter, *[]string) {
+	var commands []string
+	answer := func(stdout io.Writer, name string, args []string) mock.Arguments {
+		commandLine := strings.Join(append([]string{name}, args...), " ")
+		commands = append(commands, commandLine)
+		output, exitCode := run(commandLine, args)
+		io.WriteString(stdout, output)
+		return mock.Arguments{exitCode, nil}
+	}
+	executer := new(executers.MockCommandExecuter)
+	// the mock returns the return arguments of the call once its run function returns
+	newExecute := executer.On("NewExecute", mock.Anything, "", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	newExecute.Run(func(args mock.Arguments) {
+		newExecute.ReturnArguments = answer(args.Get(2).(io.Writer), args.Get(6).(string), args.Get(7).([]string))
+	})
+	newExecuteWithOptions := executer.On("NewExecuteWithOptions", mock.Anything, "", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	newExecuteWithOptions.Run(func(args mock.Arguments) {
+		newExecuteWithOptions.ReturnArguments = answer(args.Get(2).(io.Writer), args.Get(7).(string), args.Get(8).([]string))
+	})
+	return executer, &commands
+}
@@ -87,7 +87,7 @@ type ExecutePluginDepth struct {
 // res.Output will contain a slice of RunCommandPluginOutput.
 func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	p.filesys = filemanager.FileSystemImpl{}
-	p.ssmSvc = ssmsvc.NewService()
+	p.ssmSvc = docparser.NewDocumentCachingService(ssmsvc.NewService())
 	exec := basicexecuter.NewBasicExecuter(context)
 	p.execDoc = ExecDocumentImpl{
 		DocExecutor: exec,
@@ -138,18 +138,8 @@ func (p *Plugin) runDocument(context context.T, input *RunDocumentPluginInput, c
 	}
 	log.Info("Depth of execution - ", execDepth)
 
-	if input.DocumentType == SSMDocumentType {
-		if documentPath, err = p.downloadDocumentFromSSM(log, config, input); err != nil {
-			output.MarkAsFailed(err)
-		}
-	} else {
-		if filepath.IsAbs(input.DocumentPath) {
-			documentPath = input.DocumentPath
-		} else {
-			orchestrationDir := strings.TrimSuffix(config.OrchestrationDirectory, config.PluginID)
-			// The Document path is expected to have the name of the document
-			documentPath = filepath.Join(orchestrationDir, downloadsDir, input.DocumentPath)
-		}
+	if documentPath, err = p.resolveDocumentPath(log, config, input); err != nil {
+		output.MarkAsFailed(err)
 	}
 	if pluginsInfo, err = p.prepareDocumentForExecution(log, documentPath, config, input.DocumentParameters); err != nil {
 		output.MarkAsFailed(fmt.Errorf("There was an error while preparing documents - %v", err.Error()))
@@ -191,6 +181,38 @@ func (p *Plugin) runDocument(context context.T, input *RunDocumentPluginInput, c
 	}
 }
 
+// resolveDocumentPath returns the path of the sub-document, which is downloaded first when it is an SSM document
+func (p *Plugin) resolveDocumentPath(log log.T, config contracts.Configuration, input *RunDocumentPluginInput) (string, error) {
+	if input.DocumentType == SSMDocumentType {
+		return p.downloadDocumentFromSSM(log, config, input)
+	}
+	if filepath.IsAbs(input.DocumentPath) {
+		return input.DocumentPath, nil
+	}
+	orchestrationDir := strings.TrimSuffix(config.OrchestrationDirectory, config.PluginID)
+	// The Document path is expected to have the name of the document
+	return filepath.Join(orchestrationDir, downloadsDir, input.DocumentPath), nil
+}
+
+// LoadSubDocument reads a sub-document like aws:runDocument does and returns its steps with their parameters replaced,
+// for the plugins that run the steps of a sub-document themselves.
+func LoadSubDocument(log log.T, config contracts.Configuration, documentType string, documentPath string, documentParameters interface{}) ([]contracts.PluginState, error) {
+	input := &RunDocumentPluginInput{DocumentType: documentType, DocumentPath: documentPath, DocumentParameters: documentParameters}
+	if valid, err := validateInput(input); !valid {
+		return nil, err
+	}
+	p := &Plugin{
+		filesys: filemanager.FileSystemImpl{},
+		ssmSvc:  docparser.NewDocumentCachingService(ssmsvc.NewService()),
+		execDoc: ExecDocumentImpl{},
+	}
+	path, err := p.resolveDocumentPath(log, config, input)
+	if err != nil {
+		return nil, err
+	}
+	return p.prepareDocumentForExecution(log, path, config, input.DocumentParameters)
+}
+
 func (p *Plugin) downloadDocumentFromSSM(log log.T, config contracts.Configuration, input *RunDocumentPluginInput) (string, error) {
 	var err error
 	// Downloads folder for download path
@@ -0,0 +1,87 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runscript
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+)
+
+const screenshotFileName = "console-screenshot.png" //Screenshot of the console captured when a step run in the console session fails
+
+var captureConsoleScreenshot = executers.CaptureConsoleScreenshot
+
+var uploadScreenshot = func(log log.T, bucketName string, objectKey string, filePath string) error {
+	return s3util.NewAmazonS3Util(log, bucketName).S3Upload(log, bucketName, objectKey, filePath)
+}
+
+// consoleSession is the console session configuration of the agent applied to the steps of a document.
+type consoleSession struct {
+	appconfig.ConsoleSessionCfg
+	// messageID identifies the document in the s3 key of the screenshots
+	messageID string
+}
+
+// newScreenshot returns the screenshot captured when the step fails, nil when the agent doesn't capture screenshots.
+func (c consoleSession) newScreenshot(log log.T, pluginID string, stepID string, orchestrationDir string) *consoleScreenshot {
+	if !c.CaptureScreenshot {
+		return nil
+	}
+	return &consoleScreenshot{
+		log:        log,
+		path:       filepath.Join(orchestrationDir, screenshotFileName),
+		bucketName: c.ScreenshotS3BucketName,
+		s3Key:      fileutil.BuildS3Path(c.ScreenshotS3KeyPrefix, c.messageID, pluginID, stepID, screenshotFileName),
+	}
+}
+
+// consoleScreenshot is the screenshot of the console of a failed step, captured before the step is stopped when it
+// times out so that the dialog it waits on is visible, or after it ended otherwise.
+type consoleScreenshot struct {
+	log        log.T
+	path       string
+	bucketName string
+	s3Key      string
+	captured   bool
+	err        error
+}
+
+// capture saves the screenshot of the console the first time it is called.
+func (s *consoleScreenshot) capture() {
+	if s.captured {
+		return
+	}
+	s.captured = true
+	s.log.Infof("Capturing the screenshot of the console to %v", s.path)
+	s.err = captureConsoleScreenshot(s.log, s.path)
+}
+
+// upload uploads the screenshot, capturing it first if needed, and returns its s3 url.
+func (s *consoleScreenshot) upload() (string, error) {
+	s.capture()
+	if s.err != nil {
+		return "", s.err
+	}
+	s.log.Debugf("Uploading the screenshot of the console to s3://%v/%v", s.bucketName, s.s3Key)
+	if err := uploadScreenshot(s.log, s.bucketName, s.s3Key, s.path); err != nil {
+		return "", fmt.Errorf("failed to upload the console screenshot %v", err)
+	}
+	return fmt.Sprintf("s3://%v/%v", s.bucketName, s.s3Key), nil
+}
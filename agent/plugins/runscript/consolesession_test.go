@@ -0,0 +1,85 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runscript
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommandsInDisabledConsoleSession(t *testing.T) {
+	mockExecuter := new(executers.MockCommandExecuter)
+	p := &Plugin{CommandExecuter: mockExecuter}
+	output := iohandler.DefaultIOHandler{}
+
+	p.runCommands(log.NewMockLog(), pluginID, RunScriptPluginInput{RunCommand: []string{"setup.exe"}, RunInConsoleSession: true}, orchestrationDirectory, defaultWorkingDirectory, executers.ExecuteOptions{}, consoleSession{}, contracts.FilePermissionPolicy{}, task.NewChanneledCancelFlag(), &output)
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Equal(t, contracts.ErrorCodeInvalidInput, output.GetErrorCode())
+	mockExecuter.AssertNotCalled(t, "NewExecuteWithOptions")
+}
+
+func TestNewScreenshot(t *testing.T) {
+	console := consoleSession{ConsoleSessionCfg: appconfig.ConsoleSessionCfg{Enabled: true}, messageID: "aws.ssm.command.instance"}
+	assert.Nil(t, console.newScreenshot(log.NewMockLog(), pluginID, "0.aws:runPowerShellScript", "orchestration"))
+
+	console.CaptureScreenshot = true
+	console.ScreenshotS3BucketName = "bucket"
+	console.ScreenshotS3KeyPrefix = "screenshots"
+	screenshot := console.newScreenshot(log.NewMockLog(), pluginID, "0.aws:runPowerShellScript", "orchestration")
+	assert.Equal(t, "bucket", screenshot.bucketName)
+	assert.Equal(t, "screenshots/aws.ssm.command.instance/awsrunScript1/0.awsrunPowerShellScript/console-screenshot.png", screenshot.s3Key)
+}
+
+func TestScreenshotUpload(t *testing.T) {
+	defer func(capture func(log.T, string) error, upload func(log.T, string, string, string) error) {
+		captureConsoleScreenshot = capture
+		uploadScreenshot = upload
+	}(captureConsoleScreenshot, uploadScreenshot)
+
+	captures := 0
+	captureConsoleScreenshot = func(log log.T, screenshotPath string) error {
+		captures++
+		return nil
+	}
+	var uploaded []string
+	uploadScreenshot = func(log log.T, bucketName string, objectKey string, filePath string) error {
+		uploaded = append(uploaded, filePath)
+		return nil
+	}
+
+	screenshot := &consoleScreenshot{log: log.NewMockLog(), path: "console-screenshot.png", bucketName: "bucket", s3Key: "key/console-screenshot.png"}
+	screenshot.capture()
+	location, err := screenshot.upload()
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://bucket/key/console-screenshot.png", location)
+	assert.Equal(t, 1, captures)
+	assert.Equal(t, []string{"console-screenshot.png"}, uploaded)
+
+	captureConsoleScreenshot = func(log log.T, screenshotPath string) error {
+		return fmt.Errorf("no user is logged on to the console session")
+	}
+	screenshot = &consoleScreenshot{log: log.NewMockLog(), path: "console-screenshot.png", bucketName: "bucket", s3Key: "key/console-screenshot.png"}
+	_, err = screenshot.upload()
+	assert.Error(t, err)
+	assert.Len(t, uploaded, 1)
+}
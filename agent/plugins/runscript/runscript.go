@@ -16,7 +16,10 @@ package runscript
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strconv"
 
 	"strings"
 
@@ -34,6 +37,10 @@ import (
 
 const (
 	downloadsDir = "downloads" //Directory under the orchestration directory where the downloaded resource resides
+
+	rebootMarkerFileName = "reboot-requested" //File created by a script to request a reboot after which it runs again
+
+	checkChangesFileName = "check-changes" //File a script run in check mode writes the changes it would make to
 )
 
 // Plugin is the type for the runscript plugin.
@@ -55,6 +62,30 @@ type RunScriptPluginInput struct {
 	ID               string
 	WorkingDirectory string
 	TimeoutSeconds   interface{}
+	CpuAffinity      string
+	NiceLevel        interface{}
+	IoPriorityClass  string
+	PriorityClass    string
+	OutputEncoding   string
+	// StdinPayload is streamed to the stdin of the script
+	StdinPayload string
+	// StdinPayloadSource is the parameter or the s3 object streamed to the stdin of the script
+	StdinPayloadSource string
+	// RunInConsoleSession runs the script as the user logged on to the console of a Windows instance, in their
+	// desktop, for GUI installers that refuse to run silently. The agent configuration must enable it
+	RunInConsoleSession bool
+	// EgressPolicy limits the network destinations the script and the processes it starts connect to
+	EgressPolicy EgressPolicyInput
+	// ScopedCredentials gives the script temporary credentials restricted by a session policy
+	ScopedCredentials ScopedCredentialsInput
+}
+
+// EgressPolicyInput is the egress policy of a script: the denied CIDRs are always refused, and when the allowed
+// CIDRs or the ports are set, the connections to any other destination are refused.
+type EgressPolicyInput struct {
+	Allow []string
+	Deny  []string
+	Ports []interface{}
 }
 
 // Execute runs multiple sets of commands and returns their outputs.
@@ -69,28 +100,48 @@ func (p *Plugin) Execute(context context.T, config contracts.Configuration, canc
 	} else if cancelFlag.Canceled() {
 		output.MarkAsCancelled()
 	} else {
-		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, cancelFlag, output)
+		promptPolicy, err := executers.NewPromptPolicy(context.AppConfig().Ssm.InteractivePrompt)
+		if err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+		options := executers.ExecuteOptions{
+			PromptPolicy: promptPolicy,
+			Workspace:    config.WorkspaceDirectory,
+			RebootCount:  config.RebootCount,
+			CheckMode:    config.CheckMode,
+			Simulation:   config.Simulation,
+			Elevation:    config.Elevation,
+		}
+		console := consoleSession{ConsoleSessionCfg: context.AppConfig().Ssm.ConsoleSession, messageID: config.MessageId}
+		p.runCommandsRawInput(log, config.PluginID, config.Properties, config.OrchestrationDirectory, config.DefaultWorkingDirectory, options, console, config.FilePermissions, cancelFlag, output)
 	}
 }
 
 // runCommandsRawInput executes one set of commands and returns their output.
 // The input is in the default json unmarshal format (e.g. map[string]interface{}).
-func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+func (p *Plugin) runCommandsRawInput(log log.T, pluginID string, rawPluginInput interface{}, orchestrationDirectory string, defaultWorkingDirectory string, options executers.ExecuteOptions, console consoleSession, filePermissions contracts.FilePermissionPolicy, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var pluginInput RunScriptPluginInput
 	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
 	if err != nil {
 		errorString := fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err)
-		output.MarkAsFailed(errorString)
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, errorString))
 		return
 	}
-	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, cancelFlag, output)
+	p.runCommands(log, pluginID, pluginInput, orchestrationDirectory, defaultWorkingDirectory, options, console, filePermissions, cancelFlag, output)
 }
 
 // runCommands executes one set of commands and returns their output.
-func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPluginInput, orchestrationDirectory string, defaultWorkingDirectory string, options executers.ExecuteOptions, console consoleSession, filePermissions contracts.FilePermissionPolicy, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
 	var err error
 	var workingDir string
 
+	// The script runs in the console session only when the agent configuration allows it
+	if pluginInput.RunInConsoleSession && !console.Enabled {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, fmt.Errorf("running in the console session is disabled in the agent configuration")))
+		return
+	}
+
 	if filepath.IsAbs(pluginInput.WorkingDirectory) {
 		workingDir = pluginInput.WorkingDirectory
 	} else {
@@ -129,12 +180,132 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 	commandName := p.ShellCommand
 	commandArguments := append(p.ShellArguments, scriptPath, appconfig.ExitCodeTrap)
 
+	// Apply the requested cpu affinity and scheduling priority
+	priority, err := buildProcessPriority(pluginInput)
+	if err == nil {
+		commandName, commandArguments, err = executers.WithProcessPriority(priority, commandName, commandArguments)
+	}
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, fmt.Errorf("invalid process priority settings: %v", err)))
+		return
+	}
+	options.Priority = priority
+
+	// Apply the umask of the document to the files created by the script
+	umask, err := filePermissions.Mask()
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+	commandName, commandArguments = executers.WithUmask(umask, commandName, commandArguments)
+
+	// Confine the network connections of the script and its children to the egress policy of the step
+	egressRules, err := buildEgressRules(pluginInput.EgressPolicy)
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, fmt.Errorf("invalid egress policy: %v", err)))
+		return
+	}
+
+	// In simulation the script is validated and echoed but not run
+	if options.Simulation {
+		output.AppendSimulationTrace(simulationTrace(workingDir, executionTimeout, commandName, commandArguments, pluginInput.RunCommand)...)
+		output.MarkAsSucceeded()
+		return
+	}
+	if !egressRules.IsEmpty() {
+		egress, err := executers.NewEgressFilter(log, orchestrationDir, egressRules)
+		if err != nil {
+			output.MarkAsFailed(err)
+			return
+		}
+		defer closeEgressFilter(log, egress, output)
+		commandName, commandArguments = egress.Wrap(commandName, commandArguments)
+	}
+
+	// Give the script least privilege credentials in place of the credentials of the instance
+	if !pluginInput.ScopedCredentials.IsEmpty() {
+		sessionName := console.messageID
+		if sessionName == "" {
+			sessionName = pluginInput.ID
+		}
+		if options.Credentials, err = scopedCredentials(log, pluginInput.ScopedCredentials, sessionName); err != nil {
+			output.MarkAsFailed(fmt.Errorf("failed to get the scoped credentials of the script: %v", err))
+			return
+		}
+	}
+
+	// Convert the output of the command to UTF-8
+	stdoutWriter, flushStdout, err := executers.TranscodeOutput(output.GetStdoutWriter(), pluginInput.OutputEncoding)
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+	stderrWriter, flushStderr, err := executers.TranscodeOutput(output.GetStderrWriter(), pluginInput.OutputEncoding)
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+
+	// Open the payload streamed to the stdin of the script
+	stdinPayload, err := openStdinPayload(log, pluginInput)
+	if err != nil {
+		output.MarkAsFailed(contracts.NewCodedError(contracts.ErrorCodeInvalidInput, err))
+		return
+	}
+	if stdinPayload != nil {
+		defer stdinPayload.Close()
+		options.Stdin = stdinPayload
+	}
+
+	// The script requests a reboot after which it runs again by exiting with the reboot exit code or by
+	// creating the reboot marker file, which is kept in the orchestration directory across the reboot
+	options.RebootMarker = filepath.Join(orchestrationDir, rebootMarkerFileName)
+	if err = fileutil.DeleteFile(options.RebootMarker); err != nil && !os.IsNotExist(err) {
+		output.MarkAsFailed(fmt.Errorf("failed to delete the reboot marker %v: %v", options.RebootMarker, err))
+		return
+	}
+
+	// In check mode the script writes the changes it would make to the check changes file instead of making them
+	if options.CheckMode {
+		options.CheckChanges = filepath.Join(orchestrationDir, checkChangesFileName)
+		if err = fileutil.DeleteFile(options.CheckChanges); err != nil && !os.IsNotExist(err) {
+			output.MarkAsFailed(fmt.Errorf("failed to delete the check changes file %v: %v", options.CheckChanges, err))
+			return
+		}
+	}
+
+	// Run the script in the desktop of the console session, capturing the console when it fails
+	var screenshot *consoleScreenshot
+	if pluginInput.RunInConsoleSession {
+		options.ConsoleSession = true
+		if screenshot = console.newScreenshot(log, pluginID, pluginInput.ID, orchestrationDir); screenshot != nil {
+			options.OnTimeout = screenshot.capture
+		}
+	}
+
 	// Execute Command
-	exitCode, err := p.CommandExecuter.NewExecute(log, workingDir, output.GetStdoutWriter(), output.GetStderrWriter(), cancelFlag, executionTimeout, commandName, commandArguments)
+	exitCode, err := p.CommandExecuter.NewExecuteWithOptions(log, workingDir, stdoutWriter, stderrWriter, cancelFlag, executionTimeout, options, commandName, commandArguments)
+	flushStdout()
+	flushStderr()
+	if options.CheckMode {
+		output.AppendCheckChanges(readCheckChanges(log, options.CheckChanges)...)
+	}
 
 	// Set output status
 	output.SetExitCode(exitCode)
-	output.SetStatus(pluginutil.GetStatus(exitCode, cancelFlag))
+	status := rebootStatus(log, options.RebootMarker, pluginutil.GetStatus(exitCode, cancelFlag))
+	output.SetStatus(status)
+	if _, isExitError := err.(*exec.ExitError); (err == nil || isExitError) && status == contracts.ResultStatusFailed {
+		// the script ran but returned a failure exit code
+		output.SetErrorCode(contracts.ErrorCodeScriptFailure)
+	}
+	if screenshot != nil && (status == contracts.ResultStatusFailed || status == contracts.ResultStatusTimedOut) {
+		if location, err := screenshot.upload(); err != nil {
+			output.AppendError(err.Error())
+		} else {
+			output.AppendInfof("The screenshot of the console is uploaded to %v", location)
+		}
+	}
 
 	if err != nil {
 		status := output.GetStatus()
@@ -145,3 +316,116 @@ func (p *Plugin) runCommands(log log.T, pluginID string, pluginInput RunScriptPl
 		}
 	}
 }
+
+// simulationTrace describes the script a step run in simulation would run, followed by its commands
+func simulationTrace(workingDir string, executionTimeout int, commandName string, commandArguments []string, commands []string) []string {
+	trace := []string{fmt.Sprintf("Run %v %v in %v with a timeout of %v seconds", commandName, strings.Join(commandArguments, " "), workingDir, executionTimeout)}
+	for _, command := range commands {
+		for _, line := range strings.Split(strings.TrimRight(command, "\r\n"), "\n") {
+			trace = append(trace, "  "+strings.TrimRight(line, "\r"))
+		}
+	}
+	return trace
+}
+
+// rebootStatus turns the success of a script that created the reboot marker into a success that requires a reboot.
+// The marker is deleted so that the script runs again after the reboot without it.
+func rebootStatus(log log.T, rebootMarker string, status contracts.ResultStatus) contracts.ResultStatus {
+	if !fileutil.Exists(rebootMarker) {
+		return status
+	}
+	if err := fileutil.DeleteFile(rebootMarker); err != nil {
+		log.Warnf("failed to delete the reboot marker %v: %v", rebootMarker, err)
+	}
+	if status != contracts.ResultStatusSuccess {
+		log.Infof("Ignoring the reboot requested by the script which ended with status %v", status)
+		return status
+	}
+	log.Info("The script requested a reboot with its reboot marker")
+	return contracts.ResultStatusSuccessAndReboot
+}
+
+// readCheckChanges returns the non empty lines of the check changes file, there is no change when the script didn't create it
+func readCheckChanges(log log.T, checkChanges string) (changes []string) {
+	if !fileutil.Exists(checkChanges) {
+		return
+	}
+	content, err := fileutil.ReadAllText(checkChanges)
+	if err != nil {
+		log.Warnf("failed to read the check changes file %v: %v", checkChanges, err)
+		return
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			changes = append(changes, line)
+		}
+	}
+	return
+}
+
+// buildEgressRules converts the egress policy of the plugin into executers.EgressRules, the ports are numbers or strings.
+func buildEgressRules(policy EgressPolicyInput) (rules executers.EgressRules, err error) {
+	var ports []int
+	for _, value := range policy.Ports {
+		var port int
+		switch value := value.(type) {
+		case float64:
+			port = int(value)
+			if float64(port) != value {
+				return rules, fmt.Errorf("port %v is not an integer", value)
+			}
+		case string:
+			if port, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return rules, fmt.Errorf("port %v is not an integer", value)
+			}
+		default:
+			return rules, fmt.Errorf("unsupported port %v", value)
+		}
+		ports = append(ports, port)
+	}
+	return executers.ParseEgressRules(policy.Allow, policy.Deny, ports)
+}
+
+// closeEgressFilter removes the egress policy of the script and reports the connections it refused.
+func closeEgressFilter(log log.T, egress *executers.EgressFilter, output iohandler.IOHandler) {
+	denied, err := egress.Close(log)
+	if err != nil {
+		log.Error(err)
+		output.AppendError(err.Error())
+	}
+	if denied > 0 {
+		log.Warnf("The egress policy refused %v packets of the script", denied)
+		output.AppendErrorf("The egress policy refused %v packets, the refused destinations are logged to the kernel log with the prefix %v", denied, executers.EgressLogPrefix)
+	}
+}
+
+// buildProcessPriority converts the scheduling inputs of the plugin into executers.ProcessPriority.
+func buildProcessPriority(pluginInput RunScriptPluginInput) (priority executers.ProcessPriority, err error) {
+	if priority.CpuAffinity, err = executers.ParseCpuAffinity(pluginInput.CpuAffinity); err != nil {
+		return
+	}
+	priority.IoPriorityClass = strings.TrimSpace(pluginInput.IoPriorityClass)
+	priority.PriorityClass = strings.TrimSpace(pluginInput.PriorityClass)
+
+	var niceLevel int
+	switch value := pluginInput.NiceLevel.(type) {
+	case nil:
+		return
+	case float64:
+		niceLevel = int(value)
+		if float64(niceLevel) != value {
+			return priority, fmt.Errorf("nice level %v is not an integer", value)
+		}
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return
+		}
+		if niceLevel, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			return priority, fmt.Errorf("nice level %v is not an integer", value)
+		}
+	default:
+		return priority, fmt.Errorf("unsupported nice level %v", value)
+	}
+	priority.NiceLevel = &niceLevel
+	return
+}
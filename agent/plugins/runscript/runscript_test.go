@@ -15,6 +15,9 @@ package runscript
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aws/amazon-ssm-agent/agent/context"
@@ -140,9 +143,9 @@ func testRunScripts(t *testing.T, testCase TestCase, rawInput bool) {
 			err := jsonutil.Remarshal(testCase.Input, &rawPluginInput)
 			assert.Nil(t, err)
 
-			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+			p.runCommandsRawInput(logger, pluginID, rawPluginInput, orchestrationDirectory, defaultWorkingDirectory, executers.ExecuteOptions{}, consoleSession{}, contracts.FilePermissionPolicy{}, mockCancelFlag, mockIOHandler)
 		} else {
-			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+			p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, executers.ExecuteOptions{}, consoleSession{}, contracts.FilePermissionPolicy{}, mockCancelFlag, mockIOHandler)
 		}
 	}
 
@@ -167,7 +170,7 @@ func testBucketsInDifferentRegions(t *testing.T, testCase TestCase, testingBucke
 		setIOHandlerExpectations(mockIOHandler, testCase)
 
 		// call method under test
-		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, mockCancelFlag, mockIOHandler)
+		p.runCommands(logger, pluginID, testCase.Input, orchestrationDirectory, defaultWorkingDirectory, executers.ExecuteOptions{}, consoleSession{}, contracts.FilePermissionPolicy{}, mockCancelFlag, mockIOHandler)
 	}
 
 	testExecution(t, runScriptTester)
@@ -323,7 +326,7 @@ func testExecution(t *testing.T, commandtester CommandTester) {
 }
 
 func setExecuterExpectations(mockExecuter *executers.MockCommandExecuter, t TestCase, cancelFlag task.CancelFlag, p *Plugin) {
-	mockExecuter.On("NewExecute", mock.Anything, t.Input.WorkingDirectory, t.Output.StdoutWriter, t.Output.StderrWriter, cancelFlag, mock.Anything, mock.Anything, mock.Anything).Return(
+	mockExecuter.On("NewExecuteWithOptions", mock.Anything, t.Input.WorkingDirectory, t.Output.StdoutWriter, t.Output.StderrWriter, cancelFlag, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
 		t.Output.ExitCode, t.ExecuterError)
 }
 
@@ -343,3 +346,85 @@ func setCancelFlagExpectations(mockCancelFlag *task.MockCancelFlag, times int) {
 	mockCancelFlag.On("Canceled").Return(false).Times(times)
 	mockCancelFlag.On("ShutDown").Return(false).Times(times)
 }
+
+func TestBuildProcessPriority(t *testing.T) {
+	priority, err := buildProcessPriority(RunScriptPluginInput{})
+	assert.NoError(t, err)
+	assert.True(t, priority.IsEmpty())
+
+	priority, err = buildProcessPriority(RunScriptPluginInput{CpuAffinity: "0-1", NiceLevel: "5", IoPriorityClass: "idle"})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 1}, priority.CpuAffinity)
+	assert.Equal(t, 5, *priority.NiceLevel)
+	assert.Equal(t, executers.IoPriorityClassIdle, priority.IoPriorityClass)
+
+	priority, err = buildProcessPriority(RunScriptPluginInput{NiceLevel: float64(-3)})
+	assert.NoError(t, err)
+	assert.Equal(t, -3, *priority.NiceLevel)
+
+	_, err = buildProcessPriority(RunScriptPluginInput{NiceLevel: 1.5})
+	assert.Error(t, err)
+	_, err = buildProcessPriority(RunScriptPluginInput{CpuAffinity: "x"})
+	assert.Error(t, err)
+}
+
+func TestBuildEgressRules(t *testing.T) {
+	rules, err := buildEgressRules(EgressPolicyInput{})
+	assert.NoError(t, err)
+	assert.True(t, rules.IsEmpty())
+
+	rules, err = buildEgressRules(EgressPolicyInput{Allow: []string{"10.0.0.0/8"}, Deny: []string{"169.254.169.254"}, Ports: []interface{}{float64(443), "53"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", rules.Allow[0].String())
+	assert.Equal(t, "169.254.169.254/32", rules.Deny[0].String())
+	assert.Equal(t, []int{443, 53}, rules.Ports)
+
+	_, err = buildEgressRules(EgressPolicyInput{Ports: []interface{}{1.5}})
+	assert.Error(t, err)
+	_, err = buildEgressRules(EgressPolicyInput{Allow: []string{"10.0.0.0/33"}})
+	assert.Error(t, err)
+}
+
+func TestRebootStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rebootmarker")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, rebootMarkerFileName)
+	logger := log.NewMockLog()
+
+	assert.Equal(t, contracts.ResultStatusSuccess, rebootStatus(logger, marker, contracts.ResultStatusSuccess))
+
+	assert.NoError(t, ioutil.WriteFile(marker, nil, 0600))
+	assert.Equal(t, contracts.ResultStatusSuccessAndReboot, rebootStatus(logger, marker, contracts.ResultStatusSuccess))
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, ioutil.WriteFile(marker, nil, 0600))
+	assert.Equal(t, contracts.ResultStatusFailed, rebootStatus(logger, marker, contracts.ResultStatusFailed))
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadCheckChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkchanges")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	checkChanges := filepath.Join(dir, checkChangesFileName)
+	logger := log.NewMockLog()
+
+	assert.Empty(t, readCheckChanges(logger, checkChanges))
+
+	assert.NoError(t, ioutil.WriteFile(checkChanges, []byte("install nginx\r\n\n  restart nginx \n"), 0600))
+	assert.Equal(t, []string{"install nginx", "restart nginx"}, readCheckChanges(logger, checkChanges))
+}
+
+func TestSimulationTrace(t *testing.T) {
+	trace := simulationTrace("/tmp/work", 3600, "sh", []string{"-c", "_script.sh"}, []string{"echo a\r\necho b\n", "reboot"})
+
+	assert.Equal(t, []string{
+		"Run sh -c _script.sh in /tmp/work with a timeout of 3600 seconds",
+		"  echo a",
+		"  echo b",
+		"  reboot",
+	}, trace)
+}
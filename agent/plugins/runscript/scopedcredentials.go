@@ -0,0 +1,190 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runscript implements the runscript plugin.
+package runscript
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+const (
+	// defaultCredentialsDurationSeconds is the lifetime of the scoped credentials, the longest one allowed when a
+	// role assumes another role
+	defaultCredentialsDurationSeconds = 3600
+	minCredentialsDurationSeconds     = 900
+	maxCredentialsDurationSeconds     = 43200
+	// maxRoleSessionNameLength is the longest role session name accepted by sts
+	maxRoleSessionNameLength = 64
+)
+
+// invalidRoleSessionNameCharacters are the characters not allowed in a role session name
+var invalidRoleSessionNameCharacters = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// assumedRoleArnPattern matches the arn of an assumed role session, arn:aws:sts::account:assumed-role/name/session
+var assumedRoleArnPattern = regexp.MustCompile(`^arn:([^:]+):sts::(\d+):assumed-role/([^/]+)/`)
+
+// ScopedCredentialsInput asks for temporary credentials given to the script instead of the credentials of the
+// instance. The credentials assume RoleArn, the role of the instance by default, and the session policy further
+// restricts them to the actions and resources it allows. Assuming the role of the instance requires its trust policy
+// to trust the role itself.
+type ScopedCredentialsInput struct {
+	// SessionPolicy is an IAM policy document, as a JSON object or string
+	SessionPolicy interface{}
+	RoleArn       string
+	// DurationSeconds is the lifetime of the credentials, from 900 to 43200 seconds, 3600 by default
+	DurationSeconds interface{}
+}
+
+// IsEmpty tells whether the script runs with the credentials of the instance
+func (input ScopedCredentialsInput) IsEmpty() bool {
+	return input.SessionPolicy == nil && strings.TrimSpace(input.RoleArn) == ""
+}
+
+// assumeRole calls sts AssumeRole with the credentials of the agent, replaced by the tests
+var assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	return sts.New(session.New(sdkutil.AwsConfig())).AssumeRole(input)
+}
+
+// getCallerIdentity calls sts GetCallerIdentity with the credentials of the agent, replaced by the tests
+var getCallerIdentity = func() (*sts.GetCallerIdentityOutput, error) {
+	return sts.New(session.New(sdkutil.AwsConfig())).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+}
+
+// scopedCredentials vends the credentials of the script, the session is named after the command so CloudTrail
+// attributes the calls of the script to it
+func scopedCredentials(log log.T, input ScopedCredentialsInput, sessionName string) (*executers.Credentials, error) {
+	duration, err := credentialsDuration(input.DurationSeconds)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := sessionPolicy(input.SessionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	roleArn := strings.TrimSpace(input.RoleArn)
+	if roleArn == "" {
+		if roleArn, err = instanceRoleArn(); err != nil {
+			return nil, err
+		}
+	}
+
+	assumeRoleInput := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(roleArn),
+		RoleSessionName: aws.String(roleSessionName(sessionName)),
+		DurationSeconds: aws.Int64(int64(duration)),
+	}
+	if policy != "" {
+		assumeRoleInput.Policy = aws.String(policy)
+	}
+	log.Infof("Assuming role %v with a session policy of %v characters for the credentials of the script", roleArn, len(policy))
+	output, err := assumeRole(assumeRoleInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assume role %v: %v", roleArn, err)
+	}
+	if output.Credentials == nil {
+		return nil, fmt.Errorf("assuming role %v returned no credentials", roleArn)
+	}
+	return &executers.Credentials{
+		AccessKeyID:     aws.StringValue(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(output.Credentials.SessionToken),
+	}, nil
+}
+
+// credentialsDuration returns the lifetime of the credentials in seconds, a number or a string
+func credentialsDuration(value interface{}) (int, error) {
+	var duration int
+	switch value := value.(type) {
+	case nil:
+		return defaultCredentialsDurationSeconds, nil
+	case float64:
+		duration = int(value)
+		if float64(duration) != value {
+			return 0, fmt.Errorf("credentials duration %v is not an integer", value)
+		}
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return defaultCredentialsDurationSeconds, nil
+		}
+		var err error
+		if duration, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+			return 0, fmt.Errorf("credentials duration %v is not an integer", value)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported credentials duration %v", value)
+	}
+	if duration < minCredentialsDurationSeconds || duration > maxCredentialsDurationSeconds {
+		return 0, fmt.Errorf("credentials duration %v is not between %v and %v seconds", duration, minCredentialsDurationSeconds, maxCredentialsDurationSeconds)
+	}
+	return duration, nil
+}
+
+// sessionPolicy returns the session policy as a JSON string, empty when the credentials have none
+func sessionPolicy(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		value = strings.TrimSpace(value)
+		if value != "" && !json.Valid([]byte(value)) {
+			return "", fmt.Errorf("session policy is not a JSON document")
+		}
+		return value, nil
+	case map[string]interface{}:
+		policy, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid session policy: %v", err)
+		}
+		return string(policy), nil
+	default:
+		return "", fmt.Errorf("session policy is not a JSON document")
+	}
+}
+
+// instanceRoleArn returns the arn of the role the agent runs with, from the arn of its session
+func instanceRoleArn() (string, error) {
+	identity, err := getCallerIdentity()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the role of the instance: %v", err)
+	}
+	match := assumedRoleArnPattern.FindStringSubmatch(aws.StringValue(identity.Arn))
+	if match == nil {
+		return "", fmt.Errorf("the agent does not run with a role but as %v, the scoped credentials need a RoleArn", aws.StringValue(identity.Arn))
+	}
+	// the path of the role is not part of the session arn, the roles with a path are given by RoleArn
+	return fmt.Sprintf("arn:%v:iam::%v:role/%v", match[1], match[2], match[3]), nil
+}
+
+// roleSessionName returns a valid role session name for name
+func roleSessionName(name string) string {
+	name = invalidRoleSessionNameCharacters.ReplaceAllString(name, "-")
+	if len(name) < 2 {
+		name = "ssm-script"
+	}
+	if len(name) > maxRoleSessionNameLength {
+		name = name[:maxRoleSessionNameLength]
+	}
+	return name
+}
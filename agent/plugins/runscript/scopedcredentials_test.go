@@ -0,0 +1,98 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runscript
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+// useSts replaces the sts calls, the caller identity is the session of the instance role
+func useSts(callerArn string) (assumed *[]*sts.AssumeRoleInput, restore func()) {
+	savedAssumeRole, savedGetCallerIdentity := assumeRole, getCallerIdentity
+	inputs := []*sts.AssumeRoleInput{}
+	assumeRole = func(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+		inputs = append(inputs, input)
+		return &sts.AssumeRoleOutput{Credentials: &sts.Credentials{
+			AccessKeyId:     aws.String("ASIAEXAMPLE"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+		}}, nil
+	}
+	getCallerIdentity = func() (*sts.GetCallerIdentityOutput, error) {
+		if callerArn == "" {
+			return nil, fmt.Errorf("no credentials")
+		}
+		return &sts.GetCallerIdentityOutput{Arn: aws.String(callerArn)}, nil
+	}
+	return &inputs, func() { assumeRole, getCallerIdentity = savedAssumeRole, savedGetCallerIdentity }
+}
+
+func TestScopedCredentials(t *testing.T) {
+	assumed, restore := useSts("arn:aws:sts::123456789012:assumed-role/InstanceRole/i-0123456789abcdef0")
+	defer restore()
+	policy := map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": []interface{}{map[string]interface{}{"Effect": "Allow", "Action": "s3:GetObject", "Resource": "*"}},
+	}
+
+	credentials, err := scopedCredentials(log.NewMockLog(), ScopedCredentialsInput{SessionPolicy: policy}, "aws.ssm.1f0e2d3c-4b5a.i-0123456789abcdef0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ASIAEXAMPLE", credentials.AccessKeyID)
+	assert.Equal(t, "secret", credentials.SecretAccessKey)
+	assert.Equal(t, "token", credentials.SessionToken)
+	input := (*assumed)[0]
+	assert.Equal(t, "arn:aws:iam::123456789012:role/InstanceRole", *input.RoleArn)
+	assert.Equal(t, "aws.ssm.1f0e2d3c-4b5a.i-0123456789abcdef0", *input.RoleSessionName)
+	assert.Equal(t, int64(defaultCredentialsDurationSeconds), *input.DurationSeconds)
+	assert.JSONEq(t, `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`, *input.Policy)
+
+	_, err = scopedCredentials(log.NewMockLog(), ScopedCredentialsInput{RoleArn: "arn:aws:iam::123456789012:role/path/ScriptRole", DurationSeconds: "900"}, "0.aws:runShellScript")
+	assert.NoError(t, err)
+	input = (*assumed)[1]
+	assert.Equal(t, "arn:aws:iam::123456789012:role/path/ScriptRole", *input.RoleArn)
+	assert.Equal(t, "0.aws-runShellScript", *input.RoleSessionName)
+	assert.Equal(t, int64(900), *input.DurationSeconds)
+	assert.Nil(t, input.Policy)
+}
+
+func TestScopedCredentialsErrors(t *testing.T) {
+	assumed, restore := useSts("arn:aws:iam::123456789012:user/admin")
+	defer restore()
+
+	_, err := scopedCredentials(log.NewMockLog(), ScopedCredentialsInput{SessionPolicy: "{}"}, "session")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "need a RoleArn")
+
+	_, err = scopedCredentials(log.NewMockLog(), ScopedCredentialsInput{SessionPolicy: "not json", RoleArn: "arn:aws:iam::123456789012:role/ScriptRole"}, "session")
+	assert.Error(t, err)
+	_, err = scopedCredentials(log.NewMockLog(), ScopedCredentialsInput{RoleArn: "arn:aws:iam::123456789012:role/ScriptRole", DurationSeconds: float64(60)}, "session")
+	assert.Error(t, err)
+	_, err = scopedCredentials(log.NewMockLog(), ScopedCredentialsInput{RoleArn: "arn:aws:iam::123456789012:role/ScriptRole", DurationSeconds: 1.5}, "session")
+	assert.Error(t, err)
+	assert.Empty(t, *assumed)
+}
+
+func TestScopedCredentialsIsEmpty(t *testing.T) {
+	assert.True(t, ScopedCredentialsInput{}.IsEmpty())
+	assert.True(t, ScopedCredentialsInput{DurationSeconds: "900"}.IsEmpty())
+	assert.False(t, ScopedCredentialsInput{SessionPolicy: "{}"}.IsEmpty())
+	assert.False(t, ScopedCredentialsInput{RoleArn: "arn:aws:iam::123456789012:role/ScriptRole"}.IsEmpty())
+}
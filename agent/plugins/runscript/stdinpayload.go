@@ -0,0 +1,92 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package runscript implements the runscript plugin.
+package runscript
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+	"github.com/aws/amazon-ssm-agent/agent/ssmparameterresolver"
+)
+
+const (
+	parameterSourcePrefix       = "ssm:"
+	secureParameterSourcePrefix = "ssm-secure:"
+	s3SourceScheme              = "s3"
+)
+
+// getStdinParameter resolves a reference to a parameter of Parameter Store, such as ssm-secure:name
+var getStdinParameter = func(log log.T, reference string) (string, error) {
+	service := ssmparameterresolver.NewService()
+	parameters, err := ssmparameterresolver.ResolveParameterReferenceList(&service, log, []string{reference}, ssmparameterresolver.ResolveOptions{})
+	if err != nil {
+		return "", err
+	}
+	parameter, ok := parameters[reference]
+	if !ok {
+		return "", fmt.Errorf("parameter %v not found", reference)
+	}
+	return parameter.Value, nil
+}
+
+// openStdinObject streams an s3 object
+var openStdinObject = func(log log.T, bucketName string, objectKey string) (io.ReadCloser, error) {
+	return s3util.NewAmazonS3Util(log, bucketName).S3OpenObject(log, bucketName, objectKey)
+}
+
+// openStdinPayload returns the payload streamed to the stdin of the script, nil when the script has none.
+// The payload is given inline or by its source, which is a parameter reference (ssm:name or ssm-secure:name)
+// or an s3 object (s3://bucket/key or the https url of the object). The payload is never written to disk.
+func openStdinPayload(log log.T, pluginInput RunScriptPluginInput) (io.ReadCloser, error) {
+	source := strings.TrimSpace(pluginInput.StdinPayloadSource)
+	switch {
+	case pluginInput.StdinPayload != "" && source != "":
+		return nil, fmt.Errorf("StdinPayload and StdinPayloadSource can't be both specified")
+	case pluginInput.StdinPayload != "":
+		return ioutil.NopCloser(strings.NewReader(pluginInput.StdinPayload)), nil
+	case source == "":
+		return nil, nil
+	case strings.HasPrefix(source, parameterSourcePrefix) || strings.HasPrefix(source, secureParameterSourcePrefix):
+		value, err := getStdinParameter(log, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the stdin payload %v: %v", source, err)
+		}
+		return ioutil.NopCloser(strings.NewReader(value)), nil
+	}
+
+	sourceURL, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stdin payload source %v: %v", source, err)
+	}
+	var bucketName, objectKey string
+	if sourceURL.Scheme == s3SourceScheme {
+		bucketName, objectKey = sourceURL.Host, strings.TrimPrefix(sourceURL.Path, "/")
+	} else if s3URL := s3util.ParseAmazonS3URL(log, sourceURL); s3URL.IsBucketAndKeyPresent() {
+		bucketName, objectKey = s3URL.Bucket, s3URL.Key
+	}
+	if bucketName == "" || objectKey == "" {
+		return nil, fmt.Errorf("stdin payload source %v is neither a parameter nor an s3 object", source)
+	}
+	payload, err := openStdinObject(log, bucketName, objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the stdin payload %v: %v", source, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package runscript
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// useStdinSources stubs the parameter and s3 sources of the stdin payload
+func useStdinSources(parameters map[string]string, objects map[string]string) func() {
+	getParameter, openObject := getStdinParameter, openStdinObject
+	getStdinParameter = func(log log.T, reference string) (string, error) {
+		if value, ok := parameters[reference]; ok {
+			return value, nil
+		}
+		return "", errors.New("parameter not found")
+	}
+	openStdinObject = func(log log.T, bucketName string, objectKey string) (io.ReadCloser, error) {
+		if content, ok := objects[bucketName+"/"+objectKey]; ok {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		}
+		return nil, errors.New("object not found")
+	}
+	return func() { getStdinParameter, openStdinObject = getParameter, openObject }
+}
+
+func readStdinPayload(t *testing.T, pluginInput RunScriptPluginInput) string {
+	payload, err := openStdinPayload(log.NewMockLog(), pluginInput)
+	assert.NoError(t, err)
+	if payload == nil {
+		return ""
+	}
+	defer payload.Close()
+	content, err := ioutil.ReadAll(payload)
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestOpenStdinPayload(t *testing.T) {
+	defer useStdinSources(
+		map[string]string{"ssm-secure:/app/token": "secret"},
+		map[string]string{"bucket/path/input.json": `{"key": "value"}`},
+	)()
+
+	payload, err := openStdinPayload(log.NewMockLog(), RunScriptPluginInput{})
+	assert.NoError(t, err)
+	assert.Nil(t, payload)
+
+	assert.Equal(t, "inline", readStdinPayload(t, RunScriptPluginInput{StdinPayload: "inline"}))
+	assert.Equal(t, "secret", readStdinPayload(t, RunScriptPluginInput{StdinPayloadSource: "ssm-secure:/app/token"}))
+	assert.Equal(t, `{"key": "value"}`, readStdinPayload(t, RunScriptPluginInput{StdinPayloadSource: "s3://bucket/path/input.json"}))
+	assert.Equal(t, `{"key": "value"}`, readStdinPayload(t, RunScriptPluginInput{StdinPayloadSource: "https://s3.us-west-2.amazonaws.com/bucket/path/input.json"}))
+}
+
+func TestOpenStdinPayloadErrors(t *testing.T) {
+	defer useStdinSources(nil, nil)()
+
+	for _, pluginInput := range []RunScriptPluginInput{
+		{StdinPayload: "inline", StdinPayloadSource: "s3://bucket/key"},
+		{StdinPayloadSource: "ssm:/missing"},
+		{StdinPayloadSource: "s3://bucket/missing"},
+		{StdinPayloadSource: "s3://bucket"},
+		{StdinPayloadSource: "https://example.com/input.json"},
+	} {
+		_, err := openStdinPayload(log.NewMockLog(), pluginInput)
+		assert.Error(t, err, "%v", pluginInput.StdinPayloadSource)
+	}
+}
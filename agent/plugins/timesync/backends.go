@@ -0,0 +1,426 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package timesync
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// BackendChrony configures the servers of chrony in its configuration file
+	BackendChrony = "Chrony"
+	// BackendNtpd configures the servers of ntpd in its configuration file
+	BackendNtpd = "Ntpd"
+	// BackendW32Time configures the manual peers of the Windows Time service
+	BackendW32Time = "W32Time"
+
+	// configBegin and configEnd delimit the servers the plugin writes in the configuration files of chrony and ntpd
+	configBegin = "# BEGIN aws:configureTimeSync"
+	configEnd   = "# END aws:configureTimeSync"
+	// disabledPrefix comments out the servers of the configuration file the plugin replaced
+	disabledPrefix = "# disabled by aws:configureTimeSync: "
+
+	// w32timePeerFlags polls the peer at the special interval in client mode
+	w32timePeerFlags = "0x9"
+)
+
+// chronyConfigPaths and ntpConfigPaths are the configuration files of the distributions, with the services reading them
+var chronyConfigPaths = []configFile{
+	{path: "/etc/chrony.conf", services: []string{"chronyd"}},
+	{path: "/etc/chrony/chrony.conf", services: []string{"chrony", "chronyd"}},
+}
+var ntpConfigPaths = []configFile{
+	{path: "/etc/ntpsec/ntp.conf", services: []string{"ntpsec"}},
+	{path: "/etc/ntp.conf", services: []string{"ntpd", "ntp"}},
+}
+
+// backend is a time service the plugin configures the servers of
+type backend interface {
+	// servers returns the configured servers in order of preference
+	servers() ([]string, error)
+	// configure replaces the servers and makes the time service use them
+	configure(servers []string) error
+	// status reads the synchronization of the clock
+	status() (Status, error)
+}
+
+func newBackend(name string, runner commandRunner) backend {
+	switch name {
+	case BackendW32Time:
+		return &w32time{commandRunner: runner}
+	case BackendNtpd:
+		return &configFileBackend{commandRunner: runner, name: name, files: ntpConfigPaths, readStatus: ntpdStatus}
+	default:
+		return &configFileBackend{commandRunner: runner, name: name, files: chronyConfigPaths, readStatus: chronyStatus}
+	}
+}
+
+// commandRunner runs the commands of the backends
+type commandRunner struct {
+	log             log.T
+	commandExecuter executers.T
+	cancelFlag      task.CancelFlag
+	timeout         int
+}
+
+// run runs the command and returns its standard output
+func (r commandRunner) run(command string, args ...string) (string, error) {
+	return r.execute(executers.ExecuteOptions{}, command, args)
+}
+
+// runElevated runs the command of an operation needing root, which is elevated when the agent does not run as root
+func (r commandRunner) runElevated(operation string, command string, args ...string) (string, error) {
+	return r.execute(executers.ExecuteOptions{Elevation: operation}, command, args)
+}
+
+func (r commandRunner) execute(options executers.ExecuteOptions, command string, args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	exitCode, err := r.commandExecuter.NewExecuteWithOptions(r.log, "", &stdout, &stderr, r.cancelFlag, r.timeout, options, command, args)
+	if err == nil && exitCode != 0 {
+		err = fmt.Errorf("exit code %v", exitCode)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v failed: %v %v", command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// configFile is a configuration file of chrony or ntpd and the names of the service reading it
+type configFile struct {
+	path     string
+	services []string
+}
+
+// findConfigFile returns the first of the configuration files which exists
+func findConfigFile(files []configFile) (configFile, bool) {
+	for _, file := range files {
+		if _, err := os.Stat(file.path); err == nil {
+			return file, true
+		}
+	}
+	return configFile{}, false
+}
+
+// configFileBackend configures chrony or ntpd, which share the server directives of their configuration files
+type configFileBackend struct {
+	commandRunner
+	name       string
+	files      []configFile
+	readStatus func(runner commandRunner) (Status, error)
+}
+
+func (c *configFileBackend) configFile() (configFile, error) {
+	file, ok := findConfigFile(c.files)
+	if !ok {
+		var paths []string
+		for _, f := range c.files {
+			paths = append(paths, f.path)
+		}
+		return configFile{}, fmt.Errorf("no %v configuration file in %v", c.name, strings.Join(paths, ", "))
+	}
+	return file, nil
+}
+
+func (c *configFileBackend) servers() ([]string, error) {
+	file, err := c.configFile()
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+	return readServers(string(content)), nil
+}
+
+func (c *configFileBackend) configure(servers []string) error {
+	file, err := c.configFile()
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(file.path)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for i, server := range servers {
+		lines = append(lines, serverDirective(server, i == 0))
+	}
+	if err = writeFile(file.path, []byte(replaceServers(string(content), lines))); err != nil {
+		return err
+	}
+	// the name of the service depends on the distribution
+	for _, service := range file.services {
+		if _, err = c.runElevated(appconfig.ElevationOperationServiceControl, "systemctl", "restart", service); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *configFileBackend) status() (Status, error) {
+	return c.readStatus(c.commandRunner)
+}
+
+// serverDirective returns the server directive of chrony and ntpd, the Amazon Time Sync Service is polled every
+// 16 seconds as AWS recommends
+func serverDirective(server string, preferred bool) string {
+	directive := "server " + server
+	if preferred {
+		directive += " prefer"
+	}
+	directive += " iburst"
+	if server == AmazonTimeSyncServer || server == AmazonTimeSyncServerIPv6 {
+		directive += " minpoll 4 maxpoll 4"
+	}
+	return directive
+}
+
+// isServerDirective returns true for the directives adding time sources to chrony and ntpd
+func isServerDirective(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) >= 2 && (fields[0] == "server" || fields[0] == "pool" || fields[0] == "peer")
+}
+
+// readServers returns the time sources of a configuration file of chrony or ntpd
+func readServers(content string) []string {
+	var servers []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if isServerDirective(line) {
+			servers = append(servers, strings.ToLower(strings.Fields(line)[1]))
+		}
+	}
+	return servers
+}
+
+// replaceServers writes the directives between the markers of the plugin, in place of the previous ones or the first
+// time source of the file, and comments out the other time sources
+func replaceServers(content string, directives []string) string {
+	block := append(append([]string{configBegin}, directives...), configEnd)
+	var lines []string
+	inserted, inBlock := false, false
+	insert := func() {
+		if !inserted {
+			lines = append(lines, block...)
+			inserted = true
+		}
+	}
+	if content = strings.TrimRight(content, "\n"); content == "" {
+		return strings.Join(block, "\n") + "\n"
+	}
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == configBegin:
+			inBlock = true
+			insert()
+		case trimmed == configEnd:
+			inBlock = false
+		case inBlock:
+		case isServerDirective(trimmed):
+			insert()
+			lines = append(lines, disabledPrefix+line)
+		default:
+			lines = append(lines, line)
+		}
+	}
+	insert()
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writeFile replaces the file with the content, keeping its permissions
+func writeFile(path string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = file.Write(content); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// chronyStatus reads the tracking report of chrony, which is in CSV with the system time offset in seconds and the
+// leap status last
+func chronyStatus(runner commandRunner) (Status, error) {
+	stdout, err := runner.run("chronyc", "-c", "tracking")
+	if err != nil {
+		return Status{}, err
+	}
+	return parseChronyTracking(stdout)
+}
+
+func parseChronyTracking(stdout string) (Status, error) {
+	fields := strings.Split(strings.TrimSpace(stdout), ",")
+	if len(fields) < 14 {
+		return Status{}, fmt.Errorf("unexpected tracking report %q", strings.TrimSpace(stdout))
+	}
+	stratum, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return Status{}, fmt.Errorf("unexpected stratum %q", fields[2])
+	}
+	offset, err := strconv.ParseFloat(fields[4], 64)
+	if err != nil {
+		return Status{}, fmt.Errorf("unexpected system time offset %q", fields[4])
+	}
+	status := Status{Stratum: stratum, OffsetMilliseconds: offset * 1000}
+	if fields[13] != "Not synchronised" && stratum > 0 && stratum < 16 {
+		status.Synchronized = true
+		status.ReferenceServer = fields[1]
+	}
+	return status, nil
+}
+
+// ntpdStatus reads the peers of ntpd, the peer the clock synchronizes to is marked with a * and its offset is in
+// milliseconds
+func ntpdStatus(runner commandRunner) (Status, error) {
+	stdout, err := runner.run("ntpq", "-pn")
+	if err != nil {
+		return Status{}, err
+	}
+	return parseNtpqPeers(stdout)
+}
+
+func parseNtpqPeers(stdout string) (Status, error) {
+	for _, line := range strings.Split(stdout, "\n") {
+		if !strings.HasPrefix(line, "*") {
+			continue
+		}
+		// remote refid st t when poll reach delay offset jitter
+		fields := strings.Fields(line[1:])
+		if len(fields) < 10 {
+			return Status{}, fmt.Errorf("unexpected peer %q", strings.TrimSpace(line))
+		}
+		stratum, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return Status{}, fmt.Errorf("unexpected stratum %q", fields[2])
+		}
+		offset, err := strconv.ParseFloat(fields[8], 64)
+		if err != nil {
+			return Status{}, fmt.Errorf("unexpected offset %q", fields[8])
+		}
+		return Status{Synchronized: true, ReferenceServer: fields[0], Stratum: stratum + 1, OffsetMilliseconds: offset}, nil
+	}
+	return Status{}, nil
+}
+
+// w32time configures the manual peers of the Windows Time service with w32tm
+type w32time struct {
+	commandRunner
+}
+
+func (w *w32time) servers() ([]string, error) {
+	stdout, err := w.run("w32tm", "/query", "/configuration")
+	if err != nil {
+		return nil, err
+	}
+	return parseW32tmPeers(stdout), nil
+}
+
+func (w *w32time) configure(servers []string) error {
+	var peers []string
+	for _, server := range servers {
+		peers = append(peers, server+","+w32timePeerFlags)
+	}
+	if _, err := w.run("w32tm", "/config", "/manualpeerlist:"+strings.Join(peers, " "), "/syncfromflags:manual", "/update"); err != nil {
+		return err
+	}
+	// the clock synchronizes on the next poll when the service can't reach the peers yet
+	if _, err := w.run("w32tm", "/resync", "/rediscover"); err != nil {
+		w.log.Debugf("Windows Time did not synchronize with the new peers yet: %v", err)
+	}
+	return nil
+}
+
+func (w *w32time) status() (Status, error) {
+	stdout, err := w.run("w32tm", "/query", "/status", "/verbose")
+	if err != nil {
+		return Status{}, err
+	}
+	return parseW32tmStatus(stdout)
+}
+
+// w32tmValue returns the value of the field of the output of w32tm
+func w32tmValue(stdout string, field string) (string, bool) {
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), field+":") {
+			return strings.TrimSpace(strings.TrimSpace(line)[len(field)+1:]), true
+		}
+	}
+	return "", false
+}
+
+// parseW32tmPeers returns the peers of the NtpServer of the configuration, without their flags
+func parseW32tmPeers(stdout string) []string {
+	value, _ := w32tmValue(stdout, "NtpServer")
+	var servers []string
+	for _, peer := range strings.Fields(value) {
+		if strings.HasPrefix(peer, "(") {
+			// the source of the setting, (Local) or (Policy)
+			continue
+		}
+		servers = append(servers, strings.ToLower(strings.Split(peer, ",")[0]))
+	}
+	return servers
+}
+
+// parseW32tmStatus reads the status of the Windows Time service, the clock is not synchronized while it runs from
+// its own clock or the leap indicator is 3
+func parseW32tmStatus(stdout string) (Status, error) {
+	var status Status
+	source, _ := w32tmValue(stdout, "Source")
+	leap, _ := w32tmValue(stdout, "Leap Indicator")
+	if source == "" || strings.Contains(source, "Local CMOS Clock") || strings.Contains(source, "Free-running System Clock") || strings.HasPrefix(leap, "3") {
+		return status, nil
+	}
+	status.Synchronized = true
+	status.ReferenceServer = strings.Split(source, ",")[0]
+	if stratum, ok := w32tmValue(stdout, "Stratum"); ok {
+		status.Stratum, _ = strconv.Atoi(strings.Fields(stratum + " ")[0])
+	}
+	if offset, ok := w32tmValue(stdout, "Phase Offset"); ok {
+		seconds, err := strconv.ParseFloat(strings.TrimSuffix(offset, "s"), 64)
+		if err != nil {
+			return Status{}, fmt.Errorf("unexpected phase offset %q", offset)
+		}
+		status.OffsetMilliseconds = seconds * 1000
+	}
+	return status, nil
+}
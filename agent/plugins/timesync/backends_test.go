@@ -0,0 +1,120 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package timesync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRunner(run func(commandLine string, args []string) string) (commandRunner, *[]string) {
+	executer, commands := pluginutil.MockCommands(run)
+	return commandRunner{log: log.NewMockLog(), commandExecuter: executer, cancelFlag: task.NewChanneledCancelFlag()}, commands
+}
+
+func TestReplaceServers(t *testing.T) {
+	content := "pool 2.amazon.pool.ntp.org iburst\nserver 10.0.0.2\n# server 10.0.0.3\nrtcsync\n"
+
+	replaced := replaceServers(content, []string{serverDirective("169.254.169.123", true), serverDirective("time.example.com", false)})
+	assert.Equal(t, "# BEGIN aws:configureTimeSync\n"+
+		"server 169.254.169.123 prefer iburst minpoll 4 maxpoll 4\n"+
+		"server time.example.com iburst\n"+
+		"# END aws:configureTimeSync\n"+
+		"# disabled by aws:configureTimeSync: pool 2.amazon.pool.ntp.org iburst\n"+
+		"# disabled by aws:configureTimeSync: server 10.0.0.2\n"+
+		"# server 10.0.0.3\n"+
+		"rtcsync\n", replaced)
+	assert.Equal(t, []string{"169.254.169.123", "time.example.com"}, readServers(replaced))
+
+	// the servers of the plugin are replaced in place
+	replaced = replaceServers(replaced, []string{serverDirective("fd00:ec2::123", true)})
+	assert.Equal(t, []string{"fd00:ec2::123"}, readServers(replaced))
+	assert.Contains(t, replaced, "# BEGIN aws:configureTimeSync\nserver fd00:ec2::123 prefer iburst minpoll 4 maxpoll 4\n# END aws:configureTimeSync\n# disabled")
+
+	assert.Equal(t, "rtcsync\n# BEGIN aws:configureTimeSync\nserver 10.0.0.2 prefer iburst\n# END aws:configureTimeSync\n",
+		replaceServers("rtcsync", []string{serverDirective("10.0.0.2", true)}))
+	assert.Equal(t, "# BEGIN aws:configureTimeSync\nserver 10.0.0.2 prefer iburst\n# END aws:configureTimeSync\n",
+		replaceServers("", []string{serverDirective("10.0.0.2", true)}))
+}
+
+func TestParseChronyTracking(t *testing.T) {
+	status, err := parseChronyTracking(syncedTracking)
+	assert.NoError(t, err)
+	assert.Equal(t, Status{Synchronized: true, ReferenceServer: "169.254.169.123", Stratum: 4, OffsetMilliseconds: 0.012345}, status)
+
+	status, err = parseChronyTracking(unsyncedTracking)
+	assert.NoError(t, err)
+	assert.False(t, status.Synchronized)
+
+	_, err = parseChronyTracking("506 Cannot talk to daemon")
+	assert.Error(t, err)
+}
+
+func TestNtpd(t *testing.T) {
+	runner, commands := newRunner(func(command string, _ []string) string {
+		return "     remote           refid      st t when poll reach   delay   offset  jitter\n" +
+			"==============================================================================\n" +
+			" 10.0.0.2        .INIT.          16 u    -   64    0    0.000    0.000   0.000\n" +
+			"*169.254.169.123 .GNSS.           3 u   12   16  377    0.312   -0.215   0.010\n"
+	})
+
+	status, err := newBackend(BackendNtpd, runner).status()
+
+	assert.NoError(t, err)
+	assert.Equal(t, Status{Synchronized: true, ReferenceServer: "169.254.169.123", Stratum: 4, OffsetMilliseconds: -0.215}, status)
+	assert.Equal(t, []string{"ntpq -pn"}, *commands)
+
+	status, err = parseNtpqPeers("No association ID's returned\n")
+	assert.NoError(t, err)
+	assert.False(t, status.Synchronized)
+}
+
+func TestW32Time(t *testing.T) {
+	runner, commands := newRunner(func(command string, _ []string) string {
+		switch {
+		case strings.HasPrefix(command, "w32tm /query /configuration"):
+			return "[TimeProviders]\r\n\r\nNtpClient (Local)\r\nDllName: C:\\Windows\\system32\\w32time.dll (Local)\r\n" +
+				"NtpServer: time.windows.com,0x9 (Local)\r\nType: NTP (Local)\r\n"
+		case strings.HasPrefix(command, "w32tm /query /status"):
+			return "Leap Indicator: 0(no warning)\r\nStratum: 4 (secondary reference - syncd by (S)NTP)\r\n" +
+				"Source: 169.254.169.123,0x9\r\nPhase Offset: -0.0001234s\r\n"
+		}
+		return ""
+	})
+	w32time := newBackend(BackendW32Time, runner)
+
+	servers, err := w32time.servers()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"time.windows.com"}, servers)
+	assert.NoError(t, w32time.configure([]string{"169.254.169.123", "time.example.com"}))
+	status, err := w32time.status()
+	assert.NoError(t, err)
+	assert.Equal(t, Status{Synchronized: true, ReferenceServer: "169.254.169.123", Stratum: 4, OffsetMilliseconds: -0.1234}, status)
+
+	assert.Equal(t, []string{
+		"w32tm /query /configuration",
+		"w32tm /config /manualpeerlist:169.254.169.123,0x9 time.example.com,0x9 /syncfromflags:manual /update",
+		"w32tm /resync /rediscover",
+		"w32tm /query /status /verbose",
+	}, *commands)
+
+	status, err = parseW32tmStatus("Leap Indicator: 3(not synchronized)\r\nStratum: 0 (unspecified)\r\nSource: Local CMOS Clock\r\n")
+	assert.NoError(t, err)
+	assert.False(t, status.Synchronized)
+}
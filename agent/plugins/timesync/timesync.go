@@ -0,0 +1,305 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package timesync implements the aws:configureTimeSync plugin, which points the time service of the instance at
+// the Amazon Time Sync Service or custom servers with chrony, ntpd or the Windows Time service and reports the
+// offset of the clock.
+package timesync
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/executers"
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	// ActionGet reports the servers and the synchronization of the clock
+	ActionGet = "Get"
+	// ActionSet configures the servers and waits for the clock to synchronize
+	ActionSet = "Set"
+
+	// AmazonTimeSyncServer is the address of the Amazon Time Sync Service, reachable from every instance
+	AmazonTimeSyncServer = "169.254.169.123"
+	// AmazonTimeSyncServerIPv6 is the address of the Amazon Time Sync Service on Nitro instances with IPv6
+	AmazonTimeSyncServerIPv6 = "fd00:ec2::123"
+
+	defaultMaxOffsetMilliseconds = 100
+	defaultSyncTimeoutSeconds    = 60
+)
+
+// serverPattern matches host names and IPv4 and IPv6 addresses, the time services check them further
+var serverPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.:_-]*$`)
+
+// syncPollInterval is the time between two reads of the synchronization while waiting for the clock to synchronize
+var syncPollInterval = 5 * time.Second
+
+// Plugin is the type for the configureTimeSync plugin.
+type Plugin struct {
+	// CommandExecuter runs the commands reading and configuring the time service.
+	CommandExecuter executers.T
+}
+
+// TimeSyncPluginInput represents the time servers a step gets or sets.
+type TimeSyncPluginInput struct {
+	contracts.PluginInput
+	ID      string
+	Action  string
+	Backend string
+	// Servers are the time servers in order of preference, the Amazon Time Sync Service when none are set
+	Servers []string
+	// MaxOffsetMilliseconds is the largest offset of a compliant clock
+	MaxOffsetMilliseconds float64
+	// SyncTimeoutSeconds bounds the wait of Set for the clock to synchronize
+	SyncTimeoutSeconds int
+	// TimeoutSeconds bounds each command run against the time service
+	TimeoutSeconds interface{}
+}
+
+// Status is the synchronization of the clock read from the time service
+type Status struct {
+	Synchronized    bool
+	ReferenceServer string `json:",omitempty"`
+	Stratum         int    `json:",omitempty"`
+	// OffsetMilliseconds is the offset of the system clock from the reference server
+	OffsetMilliseconds float64
+}
+
+// Report is the output of the plugin
+type Report struct {
+	Action            string
+	Backend           string
+	Servers           []string
+	ConfiguredServers []string
+	Changed           bool `json:",omitempty"`
+	Status
+	MaxOffsetMilliseconds float64
+	Compliant             bool
+}
+
+// NewPlugin returns a new instance of the plugin.
+func NewPlugin() (*Plugin, error) {
+	var plugin Plugin
+	plugin.CommandExecuter = executers.ShellCommandExecuter{}
+	return &plugin, nil
+}
+
+// Name returns the name of the plugin.
+func Name() string {
+	return appconfig.PluginNameAwsConfigureTimeSync
+}
+
+// Execute runs the plugin.
+func (p *Plugin) Execute(context context.T, config contracts.Configuration, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	log := context.Log()
+	log.Infof("%v started with configuration %v", Name(), config)
+
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		p.runRawInput(log, config.Properties, cancelFlag, output)
+	}
+	return
+}
+
+func (p *Plugin) runRawInput(log log.T, rawPluginInput interface{}, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	var pluginInput TimeSyncPluginInput
+	err := jsonutil.Remarshal(rawPluginInput, &pluginInput)
+	log.Debugf("Plugin input %v", pluginInput)
+	if err != nil {
+		output.MarkAsFailed(fmt.Errorf("Invalid format in plugin properties %v;\nerror %v", rawPluginInput, err))
+		return
+	}
+	if err = validateInput(&pluginInput); err != nil {
+		output.MarkAsFailed(err)
+		return
+	}
+	p.run(log, pluginInput, cancelFlag, output)
+}
+
+// validateInput checks the input and sets the defaults of the fields which aren't set
+func validateInput(pluginInput *TimeSyncPluginInput) error {
+	if pluginInput.Action == "" {
+		pluginInput.Action = ActionGet
+	}
+	if pluginInput.Action != ActionGet && pluginInput.Action != ActionSet {
+		return fmt.Errorf("Action is set to unsupported value %v, expected %v or %v", pluginInput.Action, ActionGet, ActionSet)
+	}
+	if pluginInput.Backend != "" && !isSupported(pluginInput.Backend) {
+		return fmt.Errorf("Backend is set to unsupported value %v, expected one of %v", pluginInput.Backend, strings.Join(supportedBackends, ", "))
+	}
+	if len(pluginInput.Servers) == 0 {
+		pluginInput.Servers = []string{AmazonTimeSyncServer}
+	}
+	seen := map[string]bool{}
+	for i, server := range pluginInput.Servers {
+		server = strings.ToLower(strings.TrimSpace(server))
+		if !serverPattern.MatchString(server) {
+			return fmt.Errorf("server %q is not valid, expected a host name or an IP address", server)
+		}
+		if seen[server] {
+			return fmt.Errorf("server %v is declared twice", server)
+		}
+		seen[server] = true
+		pluginInput.Servers[i] = server
+	}
+	if pluginInput.MaxOffsetMilliseconds < 0 {
+		return fmt.Errorf("MaxOffsetMilliseconds must not be negative")
+	} else if pluginInput.MaxOffsetMilliseconds == 0 {
+		pluginInput.MaxOffsetMilliseconds = defaultMaxOffsetMilliseconds
+	}
+	if pluginInput.SyncTimeoutSeconds < 0 {
+		return fmt.Errorf("SyncTimeoutSeconds must not be negative")
+	} else if pluginInput.SyncTimeoutSeconds == 0 {
+		pluginInput.SyncTimeoutSeconds = defaultSyncTimeoutSeconds
+	}
+	return nil
+}
+
+func isSupported(backend string) bool {
+	for _, supported := range supportedBackends {
+		if backend == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plugin) run(log log.T, pluginInput TimeSyncPluginInput, cancelFlag task.CancelFlag, output iohandler.IOHandler) {
+	timeout := pluginutil.ValidateExecutionTimeout(log, pluginInput.TimeoutSeconds)
+	runner := commandRunner{log: log, commandExecuter: p.CommandExecuter, cancelFlag: cancelFlag, timeout: timeout}
+
+	backendName := pluginInput.Backend
+	if backendName == "" {
+		backendName = detectBackend(runner)
+		if backendName == "" {
+			output.MarkAsFailed(fmt.Errorf("no supported time service is installed, expected one of %v", strings.Join(supportedBackends, ", ")))
+			return
+		}
+	}
+	service := newBackend(backendName, runner)
+
+	configured, err := service.servers()
+	if err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to read the %v servers: %v", backendName, err))
+		return
+	}
+	report := Report{
+		Action:                pluginInput.Action,
+		Backend:               backendName,
+		Servers:               pluginInput.Servers,
+		MaxOffsetMilliseconds: pluginInput.MaxOffsetMilliseconds,
+	}
+	if pluginInput.Action == ActionSet && !equalServers(configured, pluginInput.Servers) {
+		if err = service.configure(pluginInput.Servers); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to configure the %v servers: %v", backendName, err))
+			return
+		}
+		report.Changed = true
+		output.AppendInfof("Changed the %v servers from %v to %v", backendName, strings.Join(configured, ", "), strings.Join(pluginInput.Servers, ", "))
+		if configured, err = service.servers(); err != nil {
+			markAsFailed(cancelFlag, output, fmt.Errorf("failed to read the %v servers: %v", backendName, err))
+			return
+		}
+	}
+	report.ConfiguredServers = configured
+
+	waitSeconds := 0
+	if pluginInput.Action == ActionSet {
+		waitSeconds = pluginInput.SyncTimeoutSeconds
+	}
+	if report.Status, err = waitForSync(service, cancelFlag, pluginInput.MaxOffsetMilliseconds, waitSeconds); err != nil {
+		markAsFailed(cancelFlag, output, fmt.Errorf("failed to read the %v synchronization: %v", backendName, err))
+		return
+	}
+	drift := driftOf(report)
+	for _, d := range drift {
+		output.AppendInfof("Drift of time sync: %v", d)
+	}
+	report.Compliant = len(drift) == 0
+
+	reportJson, _ := jsonutil.Marshal(report)
+	output.AppendInfo(jsonutil.Indent(reportJson))
+	if pluginInput.Action == ActionSet && !report.Compliant {
+		markAsFailed(cancelFlag, output, fmt.Errorf("time sync is not compliant after %v seconds: %v", waitSeconds, strings.Join(drift, "; ")))
+		return
+	}
+	output.MarkAsSucceeded()
+}
+
+// waitForSync reads the synchronization until the clock is within the offset or the wait is over, it is read once
+// without a wait
+func waitForSync(service backend, cancelFlag task.CancelFlag, maxOffsetMilliseconds float64, waitSeconds int) (Status, error) {
+	deadline := time.Now().Add(time.Duration(waitSeconds) * time.Second)
+	for {
+		status, err := service.status()
+		if err != nil || isInSync(status, maxOffsetMilliseconds) || !time.Now().Before(deadline) || cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			return status, err
+		}
+		time.Sleep(syncPollInterval)
+	}
+}
+
+func isInSync(status Status, maxOffsetMilliseconds float64) bool {
+	return status.Synchronized && math.Abs(status.OffsetMilliseconds) <= maxOffsetMilliseconds
+}
+
+// driftOf describes how the servers and the clock differ from the input of the report
+func driftOf(report Report) []string {
+	var drift []string
+	if !equalServers(report.ConfiguredServers, report.Servers) {
+		drift = append(drift, fmt.Sprintf("the servers are [%v], expected [%v]", strings.Join(report.ConfiguredServers, ", "), strings.Join(report.Servers, ", ")))
+	}
+	if !report.Synchronized {
+		drift = append(drift, "the clock is not synchronized")
+	} else if math.Abs(report.OffsetMilliseconds) > report.MaxOffsetMilliseconds {
+		drift = append(drift, fmt.Sprintf("the clock is off by %.3f ms, more than %v ms", report.OffsetMilliseconds, report.MaxOffsetMilliseconds))
+	}
+	return drift
+}
+
+// equalServers compares the servers in order, the first server is the preferred one
+func equalServers(configured []string, servers []string) bool {
+	if len(configured) != len(servers) {
+		return false
+	}
+	for i := range servers {
+		if !strings.EqualFold(configured[i], servers[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func markAsFailed(cancelFlag task.CancelFlag, output iohandler.IOHandler, err error) {
+	if cancelFlag.ShutDown() {
+		output.MarkAsShutdown()
+	} else if cancelFlag.Canceled() {
+		output.MarkAsCancelled()
+	} else {
+		output.MarkAsFailed(err)
+	}
+}
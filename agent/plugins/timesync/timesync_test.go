@@ -0,0 +1,154 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package timesync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/pluginutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	syncedTracking    = "A9FEA97B,169.254.169.123,4,1792166400.123456789,0.000012345,0.000001,0.000002,-12.3,0.001,0.01,0.0004,0.0001,16.1,Normal\n"
+	unsyncedTracking  = "00000000,,0,0.000000000,0.000000000,0.0,0.0,0.0,0.0,0.0,1.0,1.0,0.0,Not synchronised\n"
+	amazonLinuxChrony = `# Use public servers from the pool.ntp.org project.
+pool 2.amazon.pool.ntp.org iburst
+driftfile /var/lib/chrony/drift
+makestep 1.0 3
+`
+)
+
+// useChronyConfig points chrony at a configuration file with the content in a temporary directory
+func useChronyConfig(t *testing.T, content string) (string, func()) {
+	directory, err := ioutil.TempDir("", "timesync")
+	assert.NoError(t, err)
+	path := filepath.Join(directory, "chrony.conf")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0640))
+	saved := chronyConfigPaths
+	chronyConfigPaths = []configFile{{path: path, services: []string{"chronyd"}}}
+	return path, func() {
+		chronyConfigPaths = saved
+		os.RemoveAll(directory)
+	}
+}
+
+func TestValidateInputSetsDefaults(t *testing.T) {
+	pluginInput := TimeSyncPluginInput{}
+
+	assert.NoError(t, validateInput(&pluginInput))
+	assert.Equal(t, ActionGet, pluginInput.Action)
+	assert.Equal(t, []string{AmazonTimeSyncServer}, pluginInput.Servers)
+	assert.Equal(t, float64(defaultMaxOffsetMilliseconds), pluginInput.MaxOffsetMilliseconds)
+	assert.Equal(t, defaultSyncTimeoutSeconds, pluginInput.SyncTimeoutSeconds)
+
+	pluginInput = TimeSyncPluginInput{Action: ActionSet, Servers: []string{" Time.Example.com ", "FD00:EC2::123"}}
+	assert.NoError(t, validateInput(&pluginInput))
+	assert.Equal(t, []string{"time.example.com", "fd00:ec2::123"}, pluginInput.Servers)
+}
+
+func TestValidateInputRejectsInvalidInput(t *testing.T) {
+	for name, pluginInput := range map[string]TimeSyncPluginInput{
+		"action":     {Action: "Sync"},
+		"backend":    {Backend: "Timesyncd"},
+		"server":     {Servers: []string{"time.example.com iburst"}},
+		"duplicate":  {Servers: []string{"169.254.169.123", "169.254.169.123"}},
+		"max offset": {MaxOffsetMilliseconds: -1},
+		"timeout":    {SyncTimeoutSeconds: -1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, validateInput(&pluginInput))
+		})
+	}
+}
+
+func TestGetReportsDrift(t *testing.T) {
+	path, cleanup := useChronyConfig(t, amazonLinuxChrony)
+	defer cleanup()
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string { return unsyncedTracking })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Backend": BackendChrony})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{"chronyc -c tracking"}, *commands)
+	assert.Contains(t, output.GetStdout(), "Drift of time sync: the servers are [2.amazon.pool.ntp.org], expected [169.254.169.123]")
+	assert.Contains(t, output.GetStdout(), "Drift of time sync: the clock is not synchronized")
+	assert.Contains(t, output.GetStdout(), `"Compliant": false`)
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, amazonLinuxChrony, string(content))
+}
+
+func TestSetConfiguresChrony(t *testing.T) {
+	path, cleanup := useChronyConfig(t, amazonLinuxChrony)
+	defer cleanup()
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string { return syncedTracking })
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionSet, "Backend": BackendChrony})
+
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{"systemctl restart chronyd", "chronyc -c tracking"}, *commands)
+	assert.Contains(t, output.GetStdout(), "Changed the Chrony servers from 2.amazon.pool.ntp.org to 169.254.169.123")
+	assert.Contains(t, output.GetStdout(), `"Changed": true`)
+	assert.Contains(t, output.GetStdout(), `"OffsetMilliseconds": 0.012345`)
+	assert.Contains(t, output.GetStdout(), `"Compliant": true`)
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `# Use public servers from the pool.ntp.org project.
+# BEGIN aws:configureTimeSync
+server 169.254.169.123 prefer iburst minpoll 4 maxpoll 4
+# END aws:configureTimeSync
+# disabled by aws:configureTimeSync: pool 2.amazon.pool.ntp.org iburst
+driftfile /var/lib/chrony/drift
+makestep 1.0 3
+`, string(content))
+
+	// the servers are configured already
+	executer, commands = pluginutil.MockCommands(func(command string, _ []string) string { return syncedTracking })
+	output = pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionSet, "Backend": BackendChrony})
+	assert.Equal(t, contracts.ResultStatusSuccess, output.GetStatus(), output.GetStderr())
+	assert.Equal(t, []string{"chronyc -c tracking"}, *commands)
+	assert.NotContains(t, output.GetStdout(), `"Changed"`)
+}
+
+func TestSetFailsWhenClockDoesNotSynchronize(t *testing.T) {
+	_, cleanup := useChronyConfig(t, amazonLinuxChrony)
+	defer cleanup()
+	savedInterval := syncPollInterval
+	syncPollInterval = 100 * time.Millisecond
+	defer func() { syncPollInterval = savedInterval }()
+	executer, commands := pluginutil.MockCommands(func(command string, _ []string) string {
+		if strings.HasPrefix(command, "chronyc") {
+			// a second off
+			return strings.Replace(syncedTracking, "0.000012345", "1.000012345", 1)
+		}
+		return ""
+	})
+
+	output := pluginutil.RunPlugin(t, &Plugin{CommandExecuter: executer}, map[string]interface{}{"Action": ActionSet, "Backend": BackendChrony, "SyncTimeoutSeconds": 1})
+
+	assert.Equal(t, contracts.ResultStatusFailed, output.GetStatus())
+	assert.Contains(t, output.GetStderr(), "time sync is not compliant after 1 seconds: the clock is off by 1000.012 ms, more than 100 ms")
+	assert.True(t, len(*commands) > 2, "the synchronization is read until the wait is over")
+}
+
+func TestEqualServers(t *testing.T) {
+	assert.True(t, equalServers([]string{"169.254.169.123", "Time.Example.com"}, []string{"169.254.169.123", "time.example.com"}))
+	assert.False(t, equalServers([]string{"time.example.com", "169.254.169.123"}, []string{"169.254.169.123", "time.example.com"}))
+	assert.False(t, equalServers(nil, []string{"169.254.169.123"}))
+}
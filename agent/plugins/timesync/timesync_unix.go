@@ -0,0 +1,30 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package timesync
+
+var supportedBackends = []string{BackendChrony, BackendNtpd}
+
+// detectBackend returns the time service with a configuration file, chrony replaces ntpd on the recent
+// distributions so it comes first
+func detectBackend(runner commandRunner) string {
+	if _, ok := findConfigFile(chronyConfigPaths); ok {
+		return BackendChrony
+	}
+	if _, ok := findConfigFile(ntpConfigPaths); ok {
+		return BackendNtpd
+	}
+	return ""
+}
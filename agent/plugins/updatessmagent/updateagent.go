@@ -131,6 +131,14 @@ func runUpdateAgent(
 		return
 	}
 
+	//Leave the update to the package manager that owns the agent installation
+	if !context.IsSelfUpdateSupported() {
+		log.Infof("Agent is installed using %v, skipping self update", context.InstallSource)
+		output.AppendInfof("%v\n", context.SelfUpdateGuidance(pluginInput.AgentName))
+		output.SetStatus(contracts.ResultStatusSkipped)
+		return
+	}
+
 	//Use default manifest location is the override is not present
 	if len(pluginInput.Source) == 0 {
 		pluginInput.Source = p.ManifestLocation
@@ -330,6 +338,7 @@ func (m *updateManager) downloadUpdater(log log.T,
 			updateutil.HashType: hash,
 		},
 		DestinationDirectory: updateDownloadFolder,
+		Cached:               true,
 	}
 	downloadOutput, downloadErr := fileDownload(log, downloadInput)
 	if downloadErr != nil ||
@@ -287,6 +287,24 @@ func TestUpdateAgent(t *testing.T) {
 	}
 }
 
+func TestUpdateAgent_DefersToPackageManager(t *testing.T) {
+	pluginInput := createStubPluginInput()
+	config := contracts.Configuration{}
+	plugin := &Plugin{}
+	mockCancelFlag := new(task.MockCancelFlag)
+	manager := &fakeUpdateManager{
+		downloadManifestError: fmt.Errorf("manifest should not be downloaded"),
+	}
+	util := &fakeUtility{installSource: updateutil.InstallSourceBrew}
+	out := iohandler.DefaultIOHandler{}
+
+	updateAgent(plugin, config, logger, manager, util, pluginInput, mockCancelFlag, &out, time.Now())
+
+	assert.Equal(t, contracts.ResultStatusSkipped, out.GetStatus())
+	assert.Empty(t, out.GetStderr())
+	assert.Contains(t, out.GetStdout(), "update it through brew")
+}
+
 func TestUpdateAgent_NegativeTestCases(t *testing.T) {
 	pluginInput := createStubPluginInput()
 	pluginInput.TargetVersion = ""
@@ -416,13 +434,20 @@ func createStubInstanceContext() *updateutil.InstanceContext {
 	context.InstallerName = updateutil.PlatformLinux
 	context.Platform = updateutil.PlatformLinux
 	context.PlatformVersion = "2015.9"
+	context.InstallSource = updateutil.InstallSourceRpm
 	return &context
 }
 
-type fakeUtility struct{}
+type fakeUtility struct {
+	installSource string
+}
 
 func (u *fakeUtility) CreateInstanceContext(log log.T) (context *updateutil.InstanceContext, err error) {
-	return createStubInstanceContext(), nil
+	context = createStubInstanceContext()
+	if u.installSource != "" {
+		context.InstallSource = u.installSource
+	}
+	return context, nil
 }
 
 func (u *fakeUtility) IsServiceRunning(log log.T, i *updateutil.InstanceContext) (result bool, err error) {
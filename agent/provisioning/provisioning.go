@@ -0,0 +1,223 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package provisioning applies the agent configuration overrides supplied at instance launch,
+// so images don't need a templated amazon-ssm-agent.json.
+// The overrides are read from a well known file, which cloud-init can write, or from the amazon-ssm-agent
+// section of json EC2 user data.
+package provisioning
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/managedInstances/registration"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+const (
+	// UserDataKey is the key of the agent overrides in json user data
+	UserDataKey = "amazon-ssm-agent"
+
+	// TagsTypeName is the custom inventory type the provisioning tags are reported as
+	TagsTypeName = "Custom:AgentProvisioningTags"
+
+	tagsFileName      = "AgentProvisioningTags.json"
+	tagsSchemaVersion = "1.0"
+	userDataTimeout   = 2 * time.Second
+)
+
+// Overrides represents the agent configuration supplied at instance launch.
+type Overrides struct {
+	Region   string
+	LogLevel string
+	Proxy    ProxyCfg
+	// Tags are reported to inventory as facts about the instance
+	Tags map[string]string
+}
+
+// ProxyCfg represents the proxy used by the agent and the processes it starts.
+type ProxyCfg struct {
+	HttpProxy  string
+	HttpsProxy string
+	NoProxy    string
+}
+
+var configPath = appconfig.ProvisioningConfigPath
+
+var getUserData = fetchUserData
+
+var isManagedInstance = func() bool { return registration.InstanceID() != "" }
+
+var setRegion = platform.SetRegion
+
+var setLogLevel = ssmlog.SetLogLevel
+
+var getInstanceID = platform.InstanceID
+
+var dataStorePath = appconfig.DefaultDataStorePath
+
+// Apply ingests the overrides found in the user data and applies the provisioning overrides to the agent.
+// It runs at startup before registration, so registration can default to the provisioned region.
+func Apply(log log.T) (overrides Overrides) {
+	// managed instances don't run on EC2, skip waiting on instance metadata
+	if !isManagedInstance() {
+		ingestUserData(log)
+	}
+
+	var err error
+	if overrides, err = load(); err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("failed to read provisioning overrides from %v, %v", configPath, err)
+		}
+		return Overrides{}
+	}
+	log.Infof("Applying provisioning overrides from %v", configPath)
+
+	if overrides.Region != "" {
+		if err = setRegion(overrides.Region); err != nil {
+			log.Errorf("failed to set the provisioned region, %v", err)
+		}
+	}
+	if overrides.LogLevel != "" {
+		if err = setLogLevel(overrides.LogLevel); err != nil {
+			log.Errorf("failed to set the provisioned log level, %v", err)
+		}
+	}
+	applyProxy(overrides.Proxy)
+	if err = writeTags(overrides.Tags); err != nil {
+		log.Errorf("failed to record the provisioning tags, %v", err)
+	}
+	return overrides
+}
+
+// ingestUserData saves the amazon-ssm-agent section of json user data as the provisioning overrides.
+// User data that is not json or has no agent section is ignored.
+func ingestUserData(log log.T) {
+	userData, err := getUserData()
+	if err != nil {
+		log.Debugf("no user data available, %v", err)
+		return
+	}
+
+	var sections map[string]json.RawMessage
+	if err = json.Unmarshal([]byte(userData), &sections); err != nil {
+		return
+	}
+	section, ok := sections[UserDataKey]
+	if !ok {
+		return
+	}
+
+	var overrides Overrides
+	if err = json.Unmarshal(section, &overrides); err != nil {
+		log.Errorf("invalid %v section in user data, %v", UserDataKey, err)
+		return
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(configPath)); err != nil {
+		log.Errorf("failed to create directory for provisioning overrides, %v", err)
+		return
+	}
+	if _, err = fileutil.WriteIntoFileWithPermissions(configPath, string(section), appconfig.ReadWriteAccess); err != nil {
+		log.Errorf("failed to save provisioning overrides from user data, %v", err)
+		return
+	}
+	log.Infof("Saved provisioning overrides from user data to %v", configPath)
+}
+
+// load reads the provisioning overrides file.
+func load() (overrides Overrides, err error) {
+	if _, err = os.Stat(configPath); err != nil {
+		return
+	}
+	err = jsonutil.UnmarshalFile(configPath, &overrides)
+	if err == nil && overrides.LogLevel != "" {
+		overrides.LogLevel = strings.ToLower(overrides.LogLevel)
+	}
+	return
+}
+
+// applyProxy sets the proxy environment variables for the agent and the processes it starts.
+func applyProxy(proxy ProxyCfg) {
+	for name, value := range map[string]string{
+		"http_proxy":  proxy.HttpProxy,
+		"https_proxy": proxy.HttpsProxy,
+		"no_proxy":    proxy.NoProxy,
+	} {
+		if value != "" {
+			os.Setenv(name, value)
+			os.Setenv(strings.ToUpper(name), value)
+		}
+	}
+}
+
+// writeTags records the provisioning tags as a custom inventory item, removing the item when there are no tags.
+func writeTags(tags map[string]string) (err error) {
+	// same folder the custom inventory gatherer reads when no location is given
+	var instanceID string
+	if instanceID, err = getInstanceID(); err != nil {
+		return
+	}
+	path := filepath.Join(dataStorePath,
+		instanceID,
+		appconfig.InventoryRootDirName,
+		appconfig.CustomInventoryRootDirName,
+		tagsFileName)
+	if len(tags) == 0 {
+		if err = os.Remove(path); os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	item := model.CustomInventoryItem{
+		TypeName:      TagsTypeName,
+		SchemaVersion: tagsSchemaVersion,
+		Content:       tags,
+	}
+	var content []byte
+	if content, err = json.Marshal(item); err != nil {
+		return
+	}
+	if err = fileutil.MakeDirs(filepath.Dir(path)); err != nil {
+		return
+	}
+	_, err = fileutil.WriteIntoFileWithPermissions(path, string(content), appconfig.ReadWriteAccess)
+	return
+}
+
+// fetchUserData reads the EC2 user data, giving up quickly when instance metadata is not reachable.
+func fetchUserData() (string, error) {
+	client := ec2metadata.New(session.New(aws.NewConfig().
+		WithMaxRetries(0).
+		WithHTTPClient(&http.Client{Timeout: userDataTimeout})))
+	userData, err := client.GetUserData()
+	if err != nil {
+		return "", fmt.Errorf("failed to get user data, %v", err)
+	}
+	return userData, nil
+}
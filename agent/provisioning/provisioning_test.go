@@ -0,0 +1,147 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package provisioning
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/stretchr/testify/assert"
+)
+
+const testUserData = `{
+	"amazon-ssm-agent": {
+		"Region": "us-west-2",
+		"LogLevel": "Debug",
+		"Proxy": {"HttpsProxy": "http://proxy.local:3128", "NoProxy": "169.254.169.254"},
+		"Tags": {"Environment": "test"}
+	}
+}`
+
+type provisioningStubs struct {
+	region   string
+	logLevel string
+}
+
+func setupTest(t *testing.T, userData string, userDataErr error) (*provisioningStubs, string, func()) {
+	dir, err := ioutil.TempDir("", "provisioning")
+	assert.NoError(t, err)
+	stubs := &provisioningStubs{}
+
+	origConfigPath, origGetUserData, origIsManagedInstance := configPath, getUserData, isManagedInstance
+	origSetRegion, origSetLogLevel := setRegion, setLogLevel
+	origGetInstanceID, origDataStorePath := getInstanceID, dataStorePath
+	origEnv := map[string]string{}
+	for _, name := range []string{"http_proxy", "https_proxy", "no_proxy", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		origEnv[name] = os.Getenv(name)
+	}
+
+	configPath = filepath.Join(dir, appconfig.ProvisioningConfigFileName)
+	getUserData = func() (string, error) { return userData, userDataErr }
+	isManagedInstance = func() bool { return false }
+	setRegion = func(region string) error { stubs.region = region; return nil }
+	setLogLevel = func(level string) error { stubs.logLevel = level; return nil }
+	getInstanceID = func() (string, error) { return "i-1234567890", nil }
+	dataStorePath = dir
+
+	return stubs, dir, func() {
+		configPath, getUserData, isManagedInstance = origConfigPath, origGetUserData, origIsManagedInstance
+		setRegion, setLogLevel = origSetRegion, origSetLogLevel
+		getInstanceID, dataStorePath = origGetInstanceID, origDataStorePath
+		for name, value := range origEnv {
+			os.Setenv(name, value)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func tagsPath(dir string) string {
+	return filepath.Join(dir, "i-1234567890", appconfig.InventoryRootDirName, appconfig.CustomInventoryRootDirName, tagsFileName)
+}
+
+func TestApplyFromUserData(t *testing.T) {
+	stubs, dir, cleanup := setupTest(t, testUserData, nil)
+	defer cleanup()
+
+	overrides := Apply(log.NewMockLog())
+
+	assert.Equal(t, "us-west-2", overrides.Region)
+	assert.Equal(t, "us-west-2", stubs.region)
+	assert.Equal(t, "debug", stubs.logLevel)
+	assert.Equal(t, "http://proxy.local:3128", os.Getenv("https_proxy"))
+	assert.Equal(t, "http://proxy.local:3128", os.Getenv("HTTPS_PROXY"))
+	assert.Equal(t, "169.254.169.254", os.Getenv("no_proxy"))
+
+	_, err := os.Stat(configPath)
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(tagsPath(dir))
+	assert.NoError(t, err)
+	var item model.CustomInventoryItem
+	assert.NoError(t, json.Unmarshal(content, &item))
+	assert.Equal(t, TagsTypeName, item.TypeName)
+	assert.Equal(t, map[string]interface{}{"Environment": "test"}, item.Content)
+}
+
+func TestApplyFromFile(t *testing.T) {
+	stubs, dir, cleanup := setupTest(t, "#!/bin/bash\necho hello", nil)
+	defer cleanup()
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(`{"Region": "eu-west-1"}`), 0600))
+	assert.NoError(t, os.MkdirAll(filepath.Dir(tagsPath(dir)), 0700))
+	assert.NoError(t, ioutil.WriteFile(tagsPath(dir), []byte("{}"), 0600))
+
+	overrides := Apply(log.NewMockLog())
+
+	assert.Equal(t, Overrides{Region: "eu-west-1"}, overrides)
+	assert.Equal(t, "eu-west-1", stubs.region)
+	assert.Empty(t, stubs.logLevel)
+	_, err := os.Stat(tagsPath(dir))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestApplyWithoutOverrides(t *testing.T) {
+	stubs, _, cleanup := setupTest(t, "", errors.New("not found"))
+	defer cleanup()
+
+	assert.Equal(t, Overrides{}, Apply(log.NewMockLog()))
+	assert.Empty(t, stubs.region)
+}
+
+func TestApplySkipsUserDataOnManagedInstance(t *testing.T) {
+	stubs, _, cleanup := setupTest(t, testUserData, nil)
+	defer cleanup()
+	isManagedInstance = func() bool { return true }
+
+	assert.Equal(t, Overrides{}, Apply(log.NewMockLog()))
+	assert.Empty(t, stubs.region)
+	_, err := os.Stat(configPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestIngestUserDataInvalidSection(t *testing.T) {
+	_, _, cleanup := setupTest(t, `{"amazon-ssm-agent": {"Region": 1}}`, nil)
+	defer cleanup()
+
+	ingestUserData(log.NewMockLog())
+
+	_, err := os.Stat(configPath)
+	assert.True(t, os.IsNotExist(err))
+}
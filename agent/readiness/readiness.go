@@ -0,0 +1,135 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package readiness evaluates the gates a host must pass before a document runs its first step.
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// GateDiskSpace requires MinFreeDiskMB free on the agent data volume
+	GateDiskSpace = "DiskSpace"
+	// GateLoad requires a load per cpu below MaxLoadPerCpu
+	GateLoad = "Load"
+	// GatePendingReboot requires that the operating system is not waiting for a reboot
+	GatePendingReboot = "PendingReboot"
+	// GateProbe requires that the probe command exits with 0
+	GateProbe = "Probe"
+
+	// maxProbeOutput bounds the probe output kept in the gate reason
+	maxProbeOutput = 256
+)
+
+// GateError reports the first gate the host did not pass
+type GateError struct {
+	Gate   string
+	Reason string
+}
+
+func (e *GateError) Error() string {
+	return fmt.Sprintf("host is not ready, gate %v: %v", e.Gate, e.Reason)
+}
+
+// the host information is read through these functions so that the tests can replace them
+var (
+	diskSpaceInfo  = fileutil.GetDiskSpaceInfoOfPath
+	loadPerCpu     = platformLoadPerCpu
+	pendingReboot  = platformPendingReboot
+	probeCommand   = platformProbeCommand
+	dataVolumePath = appconfig.DefaultDataStorePath
+)
+
+// Enabled returns true if any gate is configured
+func Enabled(cfg appconfig.ReadinessCfg) bool {
+	return cfg.MinFreeDiskMB > 0 || cfg.MaxLoadPerCpu > 0 || cfg.NoPendingReboot || cfg.ProbeCommand != ""
+}
+
+// Evaluate evaluates the configured gates in order and returns the first one the host does not pass, nil if it is ready.
+// A gate whose host information can't be read is skipped, it does not block every document of the host.
+func Evaluate(log log.T, cfg appconfig.ReadinessCfg) *GateError {
+	if cfg.MinFreeDiskMB > 0 {
+		if info, err := diskSpaceInfo(dataVolumePath); err != nil {
+			log.Warnf("Skipping readiness gate %v, failed to read the free disk space: %v", GateDiskSpace, err)
+		} else if freeMB := info.AvailBytes / (1024 * 1024); freeMB < int64(cfg.MinFreeDiskMB) {
+			return &GateError{
+				Gate:   GateDiskSpace,
+				Reason: fmt.Sprintf("%v MB free on %v, %v MB required", freeMB, dataVolumePath, cfg.MinFreeDiskMB),
+			}
+		}
+	}
+	if cfg.MaxLoadPerCpu > 0 {
+		if load, err := loadPerCpu(); err != nil {
+			log.Warnf("Skipping readiness gate %v, failed to read the load: %v", GateLoad, err)
+		} else if load > cfg.MaxLoadPerCpu {
+			return &GateError{
+				Gate:   GateLoad,
+				Reason: fmt.Sprintf("load per cpu is %.2f, above %.2f", load, cfg.MaxLoadPerCpu),
+			}
+		}
+	}
+	if cfg.NoPendingReboot {
+		if pending, err := pendingReboot(); err != nil {
+			log.Warnf("Skipping readiness gate %v, failed to check for a pending reboot: %v", GatePendingReboot, err)
+		} else if pending != "" {
+			return &GateError{
+				Gate:   GatePendingReboot,
+				Reason: fmt.Sprintf("a reboot is pending, %v", pending),
+			}
+		}
+	}
+	if cfg.ProbeCommand != "" {
+		if reason := runProbe(cfg); reason != "" {
+			return &GateError{Gate: GateProbe, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// runProbe runs the probe command with the shell of the platform, it returns why the probe failed or an empty string
+func runProbe(cfg appconfig.ReadinessCfg) string {
+	timeout := time.Duration(cfg.ProbeTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	name, args := probeCommand(cfg.ProbeCommand)
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err == nil {
+		return ""
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("probe did not complete in %v", timeout)
+	}
+	reason := fmt.Sprintf("probe failed, %v", err)
+	if text := strings.TrimSpace(string(output)); text != "" {
+		if len(text) > maxProbeOutput {
+			text = text[:maxProbeOutput] + "..."
+		}
+		reason = fmt.Sprintf("%v: %v", reason, text)
+	}
+	return reason
+}
+
+// cpus is the number of cpus the load is divided by
+func cpus() float64 {
+	return float64(runtime.NumCPU())
+}
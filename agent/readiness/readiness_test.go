@@ -0,0 +1,91 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package readiness
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubHost replaces the host information and returns a function restoring it
+func stubHost(availMB int64, load float64, reboot string) func() {
+	savedDisk, savedLoad, savedReboot, savedProbe := diskSpaceInfo, loadPerCpu, pendingReboot, probeCommand
+	diskSpaceInfo = func(path string) (fileutil.DiskSpaceInfo, error) {
+		return fileutil.DiskSpaceInfo{AvailBytes: availMB * 1024 * 1024}, nil
+	}
+	loadPerCpu = func() (float64, error) { return load, nil }
+	pendingReboot = func() (string, error) { return reboot, nil }
+	return func() {
+		diskSpaceInfo, loadPerCpu, pendingReboot, probeCommand = savedDisk, savedLoad, savedReboot, savedProbe
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	assert.False(t, Enabled(appconfig.ReadinessCfg{OnFailure: appconfig.ReadinessOnFailureDefer, ProbeTimeoutSeconds: 30}))
+	assert.True(t, Enabled(appconfig.ReadinessCfg{MinFreeDiskMB: 100}))
+	assert.True(t, Enabled(appconfig.ReadinessCfg{MaxLoadPerCpu: 0.5}))
+	assert.True(t, Enabled(appconfig.ReadinessCfg{NoPendingReboot: true}))
+	assert.True(t, Enabled(appconfig.ReadinessCfg{ProbeCommand: "exit 0"}))
+}
+
+func TestEvaluateReady(t *testing.T) {
+	defer stubHost(2048, 0.2, "")()
+	cfg := appconfig.ReadinessCfg{MinFreeDiskMB: 1024, MaxLoadPerCpu: 0.8, NoPendingReboot: true}
+
+	assert.Nil(t, Evaluate(log.NewMockLog(), cfg))
+}
+
+func TestEvaluateDiskSpace(t *testing.T) {
+	defer stubHost(512, 0.2, "")()
+
+	gateErr := Evaluate(log.NewMockLog(), appconfig.ReadinessCfg{MinFreeDiskMB: 1024})
+
+	assert.Equal(t, GateDiskSpace, gateErr.Gate)
+	assert.Contains(t, gateErr.Reason, "512 MB free")
+	assert.Contains(t, gateErr.Error(), "gate DiskSpace")
+}
+
+func TestEvaluateLoad(t *testing.T) {
+	defer stubHost(2048, 1.5, "")()
+
+	gateErr := Evaluate(log.NewMockLog(), appconfig.ReadinessCfg{MinFreeDiskMB: 1024, MaxLoadPerCpu: 0.8})
+
+	assert.Equal(t, GateLoad, gateErr.Gate)
+	assert.Equal(t, "load per cpu is 1.50, above 0.80", gateErr.Reason)
+}
+
+func TestEvaluatePendingReboot(t *testing.T) {
+	defer stubHost(2048, 0.2, "/var/run/reboot-required exists")()
+
+	gateErr := Evaluate(log.NewMockLog(), appconfig.ReadinessCfg{NoPendingReboot: true})
+
+	assert.Equal(t, GatePendingReboot, gateErr.Gate)
+	assert.Contains(t, gateErr.Reason, "reboot-required")
+}
+
+func TestEvaluateSkipsUnreadableGates(t *testing.T) {
+	defer stubHost(0, 0, "")()
+	diskSpaceInfo = func(path string) (fileutil.DiskSpaceInfo, error) {
+		return fileutil.DiskSpaceInfo{}, errors.New("no such volume")
+	}
+	loadPerCpu = func() (float64, error) { return 0, errors.New("unsupported") }
+	pendingReboot = func() (string, error) { return "", errors.New("access denied") }
+
+	assert.Nil(t, Evaluate(log.NewMockLog(), appconfig.ReadinessCfg{MinFreeDiskMB: 1024, MaxLoadPerCpu: 0.8, NoPendingReboot: true}))
+}
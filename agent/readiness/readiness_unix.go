@@ -0,0 +1,77 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package readiness
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+// rebootRequiredFiles are created by the package managers when an update needs a reboot
+var rebootRequiredFiles = []string{"/var/run/reboot-required", "/run/reboot-required"}
+
+// platformLoadPerCpu returns the one minute load average divided by the number of cpus
+func platformLoadPerCpu() (float64, error) {
+	var content string
+	if runtime.GOOS == "linux" {
+		text, err := fileutil.ReadAllText("/proc/loadavg")
+		if err != nil {
+			return 0, err
+		}
+		content = text
+	} else {
+		// "{ 1.52 1.38 1.30 }"
+		output, err := exec.Command("sysctl", "-n", "vm.loadavg").Output()
+		if err != nil {
+			return 0, err
+		}
+		content = strings.Trim(strings.TrimSpace(string(output)), "{} ")
+	}
+	return parseLoadAverage(content)
+}
+
+// parseLoadAverage returns the first load average of content divided by the number of cpus
+func parseLoadAverage(content string) (float64, error) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("unexpected load average %q", content)
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	return load / cpus(), nil
+}
+
+// platformPendingReboot returns why a reboot is pending, or an empty string
+func platformPendingReboot() (string, error) {
+	for _, path := range rebootRequiredFiles {
+		if fileutil.Exists(path) {
+			return fmt.Sprintf("%v exists", path), nil
+		}
+	}
+	return "", nil
+}
+
+// platformProbeCommand runs the probe with the shell
+func platformProbeCommand(command string) (string, []string) {
+	return "sh", []string{"-c", command}
+}
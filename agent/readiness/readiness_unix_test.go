@@ -0,0 +1,49 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package readiness
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLoadAverage(t *testing.T) {
+	load, err := parseLoadAverage("1.50 1.20 0.90 2/345 6789")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.5/cpus(), load, 0.0001)
+
+	_, err = parseLoadAverage("")
+	assert.Error(t, err)
+}
+
+func TestEvaluateProbe(t *testing.T) {
+	cfg := appconfig.ReadinessCfg{ProbeCommand: "exit 0", ProbeTimeoutSeconds: 10}
+	assert.Nil(t, Evaluate(log.NewMockLog(), cfg))
+
+	cfg.ProbeCommand = "echo database is in maintenance; exit 3"
+	gateErr := Evaluate(log.NewMockLog(), cfg)
+	assert.Equal(t, GateProbe, gateErr.Gate)
+	assert.Contains(t, gateErr.Reason, "exit status 3")
+	assert.Contains(t, gateErr.Reason, "database is in maintenance")
+
+	cfg.ProbeCommand = "exec sleep 5"
+	cfg.ProbeTimeoutSeconds = 1
+	gateErr = Evaluate(log.NewMockLog(), cfg)
+	assert.Equal(t, "probe did not complete in 1s", gateErr.Reason)
+}
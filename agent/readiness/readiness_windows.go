@@ -0,0 +1,65 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+package readiness
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// rebootPendingKeys are created by Windows servicing and Windows Update when a reboot is needed to complete an update
+var rebootPendingKeys = []string{
+	`SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending`,
+	`SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired`,
+}
+
+// platformLoadPerCpu returns the average utilization of the cpus, 1 being fully used, Windows has no load average
+func platformLoadPerCpu() (float64, error) {
+	output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command",
+		"(Get-CimInstance Win32_Processor | Measure-Object -Property LoadPercentage -Average).Average").Output()
+	if err != nil {
+		return 0, err
+	}
+	percentage, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected cpu load %q", strings.TrimSpace(string(output)))
+	}
+	return percentage / 100, nil
+}
+
+// platformPendingReboot returns why a reboot is pending, or an empty string
+func platformPendingReboot() (string, error) {
+	for _, path := range rebootPendingKeys {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, path, registry.QUERY_VALUE)
+		if err == nil {
+			key.Close()
+			return fmt.Sprintf(`registry key HKLM\%v exists`, path), nil
+		}
+		if err != registry.ErrNotExist {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// platformProbeCommand runs the probe with PowerShell
+func platformProbeCommand(command string) (string, []string) {
+	return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", command}
+}
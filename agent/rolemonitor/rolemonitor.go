@@ -0,0 +1,193 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package rolemonitor detects the instance profile or the role of an EC2 instance changing while the agent runs.
+// Without it the clients of the agent keep signing with the cached credentials of the old role until they expire and
+// fail with confusing authorization errors. On a change the shared credentials are expired at once, so the next call
+// gets the credentials of the new role, and the clients holding long requests are rebuilt.
+package rolemonitor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// securityCredentialsResource lists the role of the instance profile in the instance metadata
+const securityCredentialsResource = "iam/security-credentials/"
+
+// Change is a change of the role of the instance
+type Change struct {
+	From string
+	To   string
+	Time time.Time
+}
+
+// metadataClient reads the instance profile and the role from the instance metadata
+type metadataClient interface {
+	IAMInfo() (ec2metadata.EC2IAMInfo, error)
+	GetMetadata(path string) (string, error)
+}
+
+var (
+	lock       sync.Mutex
+	listeners  = map[string]func(){}
+	lastChange *Change
+	current    *monitor
+)
+
+// newMetadataClient returns the client of the instance metadata, replaced in tests
+var newMetadataClient = func() metadataClient {
+	return ec2metadata.New(session.New(aws.NewConfig().WithMaxRetries(0)))
+}
+
+// expireCredentials expires the credentials shared by the clients of the agent, replaced in tests
+var expireCredentials = func() {
+	sdkutil.InstanceCredentials().Expire()
+}
+
+// Start begins reading the role of the instance when it is enabled by the configuration.
+func Start(log log.T, config appconfig.RoleMonitorCfg) {
+	if !config.Enabled {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if current != nil {
+		return
+	}
+	current = &monitor{
+		log:      log,
+		client:   newMetadataClient(),
+		interval: time.Duration(config.IntervalSeconds) * time.Second,
+		stop:     make(chan struct{}),
+	}
+	log.Debugf("Checking the role of the instance every %v", current.interval)
+	go current.run()
+}
+
+// Stop stops reading the role of the instance
+func Stop() {
+	lock.Lock()
+	stopped := current
+	current = nil
+	lock.Unlock()
+	if stopped != nil {
+		close(stopped.stop)
+	}
+}
+
+// OnChange registers the rebuild of a client holding long requests, called once the role of the instance changed
+func OnChange(name string, rebuild func()) {
+	lock.Lock()
+	defer lock.Unlock()
+	listeners[name] = rebuild
+}
+
+// RemoveListener unregisters a rebuild, such as the rebuild of a client of a stopped core module
+func RemoveListener(name string) {
+	lock.Lock()
+	defer lock.Unlock()
+	delete(listeners, name)
+}
+
+// LastChange returns the last change of the role of the instance since the agent started
+func LastChange() (Change, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if lastChange == nil {
+		return Change{}, false
+	}
+	return *lastChange, true
+}
+
+// monitor reads the role of the instance periodically
+type monitor struct {
+	log      log.T
+	client   metadataClient
+	interval time.Duration
+	stop     chan struct{}
+	// role is the instance profile and the role last read, empty until they are read
+	role string
+}
+
+func (m *monitor) run() {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	m.check()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check reads the role of the instance and renews the credentials if it changed, it returns true on a change
+func (m *monitor) check() bool {
+	role, err := m.readRole()
+	if err != nil {
+		// instances without a role and the instances outside EC2 have nothing to detect
+		m.log.Debugf("Failed to read the role of the instance: %v", err)
+		return false
+	}
+	previous := m.role
+	m.role = role
+	if previous == "" || previous == role {
+		return false
+	}
+
+	m.log.Warnf("The role of the instance changed from %v to %v, renewing the credentials of the agent", previous, role)
+	expireCredentials()
+
+	lock.Lock()
+	lastChange = &Change{From: previous, To: role, Time: time.Now()}
+	rebuilds := make(map[string]func(), len(listeners))
+	for name, rebuild := range listeners {
+		rebuilds[name] = rebuild
+	}
+	lock.Unlock()
+
+	for name, rebuild := range rebuilds {
+		m.log.Infof("Rebuilding the client of %v with the credentials of the new role", name)
+		rebuild()
+	}
+	return true
+}
+
+// readRole returns the instance profile and the name of its role
+func (m *monitor) readRole() (string, error) {
+	info, err := m.client.IAMInfo()
+	if err != nil {
+		return "", err
+	}
+	roles, err := m.client.GetMetadata(securityCredentialsResource)
+	if err != nil {
+		return "", err
+	}
+	roleName := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if roleName == "" {
+		return "", fmt.Errorf("instance profile %v has no role", info.InstanceProfileArn)
+	}
+	return fmt.Sprintf("%v (role %v)", info.InstanceProfileArn, roleName), nil
+}
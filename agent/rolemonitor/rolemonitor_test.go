@@ -0,0 +1,73 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package rolemonitor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMetadataClient returns the instance profile and the role it is set to, err fails the reads
+type fakeMetadataClient struct {
+	profileArn string
+	roles      string
+	err        error
+}
+
+func (c *fakeMetadataClient) IAMInfo() (ec2metadata.EC2IAMInfo, error) {
+	return ec2metadata.EC2IAMInfo{Code: "Success", InstanceProfileArn: c.profileArn}, c.err
+}
+
+func (c *fakeMetadataClient) GetMetadata(path string) (string, error) {
+	return c.roles, c.err
+}
+
+func TestCheckDetectsRoleChange(t *testing.T) {
+	expired := 0
+	expireCredentials = func() { expired++ }
+	rebuilt := 0
+	OnChange("poller", func() { rebuilt++ })
+	defer RemoveListener("poller")
+
+	client := &fakeMetadataClient{profileArn: "arn:aws:iam::123456789012:instance-profile/web", roles: "web-role"}
+	m := &monitor{log: log.NewMockLog(), client: client}
+
+	assert.False(t, m.check(), "the first role read is not a change")
+	assert.False(t, m.check())
+
+	client.err = errors.New("metadata unavailable")
+	assert.False(t, m.check(), "a failed read is not a change")
+
+	client.err = nil
+	client.roles = "web-role-v2\n"
+	assert.True(t, m.check())
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 1, rebuilt)
+	change, changed := LastChange()
+	assert.True(t, changed)
+	assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web (role web-role)", change.From)
+	assert.Equal(t, "arn:aws:iam::123456789012:instance-profile/web (role web-role-v2)", change.To)
+}
+
+func TestReadRoleWithoutRole(t *testing.T) {
+	m := &monitor{log: log.NewMockLog(), client: &fakeMetadataClient{profileArn: "arn:aws:iam::123456789012:instance-profile/web"}}
+
+	_, err := m.readRole()
+
+	assert.Error(t, err)
+}
@@ -25,7 +25,9 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/docmanager"
+	"github.com/aws/amazon-ssm-agent/agent/liveness"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/amazon-ssm-agent/agent/rolemonitor"
 	messageContracts "github.com/aws/amazon-ssm-agent/agent/runcommand/contracts"
 	mdsService "github.com/aws/amazon-ssm-agent/agent/runcommand/mds"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
@@ -76,6 +78,11 @@ func (s *RunCommandService) ModuleExecute(context context.T) (err error) {
 	if s.messagePollJob, err = scheduler.Every(pollMessageFrequencyMinutes).Minutes().Run(s.messagePollLoop); err != nil {
 		context.Log().Errorf("unable to schedule message poll job. %v", err)
 	}
+	if s.name == mdsName {
+		liveness.Register(s.name, pollMessageFrequencyMinutes*time.Minute, s.restartMessagePolling)
+		// the long poll signed with the credentials of the old role is replaced once the role of the instance changes
+		rolemonitor.OnChange(s.name, s.restartMessagePolling)
+	}
 
 	log.Info("Starting send replies to MDS")
 	if s.sendReplyJob, err = scheduler.Every(sendReplyFrequencyMinutes).Minutes().Run(s.sendReplyLoop); err != nil {
@@ -91,6 +98,8 @@ func (s *RunCommandService) ModuleExecute(context context.T) (err error) {
 
 func (s *RunCommandService) ModuleRequestStop(stopType contracts.StopType) (err error) {
 	//first stop sending failed replies to the service and the message poller
+	liveness.Unregister(s.name)
+	rolemonitor.RemoveListener(s.name)
 	s.stop()
 	//second stop the message processor
 	s.processor.Stop(stopType)
@@ -106,6 +115,12 @@ func (s *RunCommandService) listenReply(resultChan chan contracts.DocumentResult
 	log := s.context.Log()
 	//processor guarantees to close this channel upon stop
 	for res := range resultChan {
+		if res.QueuePosition > 0 {
+			log.Infof("command: %v is queued in concurrency group %v", res.MessageID, res.ConcurrencyGroup)
+			s.sendDocLevelResponse(res.MessageID, contracts.ResultStatusInProgress,
+				fmt.Sprintf("Waiting for %v documents ahead in concurrency group %v", res.QueuePosition, res.ConcurrencyGroup))
+			continue
+		}
 		//cloudwatch and refresh association needs to trigger the in-memory component, adding filter here
 		s.handleSpecialPlugin(res.LastPlugin, res.PluginResults, res.MessageID)
 
@@ -218,19 +233,25 @@ func (s *RunCommandService) sendFailedReplies() {
 		log.Infof("Found document replies that need to be sent to the service")
 		for _, reply := range replies {
 			log.Debug("Loading reply ", reply)
-			if isValidReplyRequest(reply) == false {
-				log.Debug("Reply is old, document execution must have timed out. Deleting the reply")
-				s.service.DeleteFailedReply(log, reply)
-				continue
-			}
 			sendReplyRequest, err := s.service.GetFailedReply(log, reply)
 			if err != nil {
 				log.Error("Couldn't load the reply from disk ", err)
+				if isValidReplyRequest(reply) == false {
+					s.service.DeleteFailedReply(log, reply)
+				}
+				continue
+			}
+			if isValidReplyRequest(reply) == false {
+				log.Debug("Reply is old, document execution must have timed out. Moving the reply to the dead letters")
+				reason := fmt.Sprintf("the reply was not delivered within %v hours", documentLevelTimeOutDurationHour)
+				s.deadLetterFailedReply(reply, sendReplyRequest, reason)
 				continue
 			}
 
 			log.Info("Sending reply ", reply)
-			if err = s.service.SendReplyWithInput(log, sendReplyRequest); err != nil {
+			if err = s.service.SendReplyWithInput(log, sendReplyRequest); mdsService.IsPermanentReplyError(err) {
+				s.deadLetterFailedReply(reply, sendReplyRequest, err.Error())
+			} else if err != nil {
 				sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
 				break
 			} else {
@@ -243,6 +264,15 @@ func (s *RunCommandService) sendFailedReplies() {
 	}
 }
 
+// deadLetterFailedReply moves a failed reply which can't be delivered to the dead letters
+func (s *RunCommandService) deadLetterFailedReply(reply string, sendReplyRequest *ssmmds.SendReplyInput, reason string) {
+	log := s.context.Log()
+	if err := persistDeadLetter(log, mdsService.NewDeadLetter(*sendReplyRequest, reason)); err != nil {
+		log.Errorf("Failed to save reply %v to the dead letters: %v", reply, err)
+	}
+	s.service.DeleteFailedReply(log, reply)
+}
+
 // isValidReplyRequest checks if the sendReply request is older than 2 hours
 // If so it is considered as not valid anymore as the document must have timed out
 func isValidReplyRequest(filename string) bool {
@@ -0,0 +1,218 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+	"github.com/twinj/uuid"
+)
+
+const (
+	// deadLetterExtension is the extension of the files of the dead letters
+	deadLetterExtension = ".json"
+
+	// maxDeadLetters is how many dead letters are kept, the oldest are removed first
+	maxDeadLetters = 100
+)
+
+// permanentReplyErrorCodes are the errors of the service which fail the same way when the reply is sent again
+var permanentReplyErrorCodes = []string{
+	ssmmds.ErrCodeInvalidDestinationException,
+	ssmmds.ErrCodeInvalidMessageIdException,
+	ssmmds.ErrCodeUnsupportedMessageOperationException,
+	"SerializationException",
+	"ValidationException",
+}
+
+// deadLetterDirectory returns the directory of the dead letters, replaced in tests
+var deadLetterDirectory = GetDeadLetterDirectory
+
+// DeadLetter is a result the agent could not deliver, kept with the reason for the operator to inspect and replay.
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	MessageID string    `json:"messageId"`
+	Reason    string    `json:"reason"`
+	Time      time.Time `json:"time"`
+	// Reply is the reply to send, nil when the result could not be serialized
+	Reply *ssmmds.SendReplyInput `json:"reply,omitempty"`
+	// Detail is the content of the result which could not be serialized
+	Detail string `json:"detail,omitempty"`
+}
+
+// PermanentReplyError is returned when the service rejects a reply, which fails the same way when it is sent again.
+type PermanentReplyError struct {
+	Err error
+}
+
+// Error returns the error of the service
+func (e *PermanentReplyError) Error() string {
+	return fmt.Sprintf("SendReply Error: %v", e.Err)
+}
+
+// IsPermanentReplyError returns true if the reply was rejected by the service and sending it again is pointless.
+func IsPermanentReplyError(err error) bool {
+	_, ok := err.(*PermanentReplyError)
+	return ok
+}
+
+// isPermanentReplyFailure returns true if the error of the service is caused by the reply, such as a payload too large
+func isPermanentReplyFailure(err error) bool {
+	if failure, ok := err.(awserr.RequestFailure); ok && failure.StatusCode() == http.StatusRequestEntityTooLarge {
+		return true
+	}
+	if aErr, ok := err.(awserr.Error); ok {
+		for _, code := range permanentReplyErrorCodes {
+			if aErr.Code() == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewDeadLetter returns the dead letter of a reply the service rejected or the agent could not deliver.
+func NewDeadLetter(reply ssmmds.SendReplyInput, reason string) DeadLetter {
+	letter := DeadLetter{
+		Reason: reason,
+		Time:   time.Now().UTC(),
+		Reply:  &reply,
+	}
+	if reply.ReplyId != nil {
+		letter.ID = *reply.ReplyId
+	}
+	if reply.MessageId != nil {
+		letter.MessageID = *reply.MessageId
+	}
+	return letter
+}
+
+// PersistDeadLetter saves the dead letter to the dead letter folder on disk.
+func PersistDeadLetter(log log.T, letter DeadLetter) error {
+	if letter.ID == "" {
+		uuid.SwitchFormat(uuid.CleanHyphen)
+		letter.ID = uuid.NewV4().String()
+	}
+	dir := deadLetterDirectory()
+	if err := fileutil.MakeDirs(dir); err != nil {
+		return err
+	}
+	removeOldestDeadLetters(log, maxDeadLetters-1)
+
+	content, err := jsonutil.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	fileName := path.Join(dir, letter.ID+deadLetterExtension)
+	if _, err = fileutil.WriteIntoFileWithPermissions(fileName, jsonutil.Indent(content), os.FileMode(int(appconfig.ReadWriteAccess))); err != nil {
+		return err
+	}
+	log.Warnf("Result of message %v can't be delivered (%v), it is kept in %v", letter.MessageID, letter.Reason, fileName)
+	return nil
+}
+
+// LoadDeadLetters returns the dead letters on disk, the oldest first.
+func LoadDeadLetters(log log.T) []DeadLetter {
+	files, err := fileutil.GetFileNames(deadLetterDirectory())
+	if err != nil {
+		return nil
+	}
+	var letters []DeadLetter
+	for _, file := range files {
+		if !strings.HasSuffix(file, deadLetterExtension) {
+			continue
+		}
+		letter, err := GetDeadLetter(strings.TrimSuffix(file, deadLetterExtension))
+		if err != nil {
+			log.Warnf("Failed to read the dead letter %v: %v", file, err)
+			continue
+		}
+		letters = append(letters, letter)
+	}
+	sort.SliceStable(letters, func(i, j int) bool {
+		return letters[i].Time.Before(letters[j].Time)
+	})
+	return letters
+}
+
+// GetDeadLetter loads the dead letter given its id.
+func GetDeadLetter(id string) (letter DeadLetter, err error) {
+	if id == "" || id != filepath.Base(id) {
+		return letter, fmt.Errorf("invalid dead letter id %q", id)
+	}
+	fileName := path.Join(deadLetterDirectory(), id+deadLetterExtension)
+	if !fileutil.Exists(fileName) {
+		return letter, fmt.Errorf("dead letter %v does not exist", id)
+	}
+	err = jsonutil.UnmarshalFile(fileName, &letter)
+	return letter, err
+}
+
+// DeleteDeadLetter deletes the dead letter given its id.
+func DeleteDeadLetter(id string) error {
+	if _, err := GetDeadLetter(id); err != nil {
+		return err
+	}
+	return fileutil.DeleteFile(path.Join(deadLetterDirectory(), id+deadLetterExtension))
+}
+
+// ReplayDeadLetter moves the reply of the dead letter back to the replies folder, which the agent sends again.
+func ReplayDeadLetter(log log.T, id string) error {
+	letter, err := GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	if letter.Reply == nil || letter.Reply.Payload == nil || letter.Reply.ReplyId == nil {
+		return fmt.Errorf("dead letter %v has no reply to send, the result could not be serialized", id)
+	}
+	if err = fileutil.MakeDirs(failedReplyDirectory()); err != nil {
+		return err
+	}
+	// the file name carries the time of the reply, a fresh time keeps the agent from discarding it as expired
+	if persisted, err := persistFailedReply(log, *letter.Reply); !persisted {
+		return fmt.Errorf("failed to save the reply of dead letter %v: %v", id, err)
+	}
+	return DeleteDeadLetter(id)
+}
+
+// removeOldestDeadLetters removes the oldest dead letters above the limit
+func removeOldestDeadLetters(log log.T, limit int) {
+	letters := LoadDeadLetters(log)
+	for i := 0; i < len(letters)-limit; i++ {
+		log.Warnf("Too many dead letters, removing the oldest %v of message %v", letters[i].ID, letters[i].MessageID)
+		DeleteDeadLetter(letters[i].ID)
+	}
+}
+
+// GetDeadLetterDirectory returns path to dead letter folder
+func GetDeadLetterDirectory() string {
+	instanceID, _ := platform.InstanceID()
+	return path.Join(appconfig.DefaultDataStorePath,
+		instanceID,
+		appconfig.DeadLetterRootDirName)
+}
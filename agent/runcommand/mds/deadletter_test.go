@@ -0,0 +1,97 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
+	"github.com/stretchr/testify/assert"
+)
+
+// useTestDirectories points the dead letters and the failed replies to a temporary directory
+func useTestDirectories(t *testing.T) (restore func()) {
+	root, err := ioutil.TempDir("", "deadletter")
+	assert.NoError(t, err)
+	deadLetterDirectory = func() string { return filepath.Join(root, "deadletter") }
+	failedReplyDirectory = func() string { return filepath.Join(root, "replies") }
+	return func() {
+		deadLetterDirectory = GetDeadLetterDirectory
+		failedReplyDirectory = GetFailedReplyDirectory
+		os.RemoveAll(root)
+	}
+}
+
+func TestIsPermanentReplyFailure(t *testing.T) {
+	assert.True(t, isPermanentReplyFailure(awserr.New(ssmmds.ErrCodeInvalidMessageIdException, "expired", nil)))
+	assert.True(t, isPermanentReplyFailure(awserr.NewRequestFailure(awserr.New("RequestEntityTooLarge", "too large", nil), 413, "id")))
+	assert.False(t, isPermanentReplyFailure(awserr.New(ssmmds.ErrCodeTooManyRequestsException, "throttled", nil)))
+	assert.False(t, isPermanentReplyFailure(errors.New("connection reset")))
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	restore := useTestDirectories(t)
+	defer restore()
+	reply := ssmmds.SendReplyInput{MessageId: aws.String("aws.ssm.command.i-1"), Payload: aws.String("{}"), ReplyId: aws.String("reply-1")}
+
+	assert.NoError(t, PersistDeadLetter(logger, NewDeadLetter(reply, "rejected")))
+	letters := LoadDeadLetters(logger)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "reply-1", letters[0].ID)
+	assert.Equal(t, "aws.ssm.command.i-1", letters[0].MessageID)
+
+	assert.NoError(t, ReplayDeadLetter(logger, "reply-1"))
+	assert.Len(t, LoadDeadLetters(logger), 0)
+	replies, _ := fileutil.GetFileNames(failedReplyDirectory())
+	assert.Len(t, replies, 1)
+	assert.True(t, strings.HasPrefix(replies[0], "reply-1_"))
+}
+
+func TestDeadLetterWithoutReplyIsNotReplayed(t *testing.T) {
+	restore := useTestDirectories(t)
+	defer restore()
+
+	assert.NoError(t, PersistDeadLetter(logger, DeadLetter{MessageID: "m", Reason: "could not marshal", Time: time.Now()}))
+	letters := LoadDeadLetters(logger)
+	assert.Len(t, letters, 1)
+	assert.NotEmpty(t, letters[0].ID)
+
+	assert.Error(t, ReplayDeadLetter(logger, letters[0].ID))
+	assert.Error(t, ReplayDeadLetter(logger, "../replies"))
+	assert.Len(t, LoadDeadLetters(logger), 1)
+}
+
+func TestPersistDeadLetterRemovesOldest(t *testing.T) {
+	restore := useTestDirectories(t)
+	defer restore()
+	start := time.Now()
+	for i := 0; i <= maxDeadLetters; i++ {
+		letter := DeadLetter{ID: fmt.Sprintf("letter-%03d", i), Time: start.Add(time.Duration(i) * time.Second)}
+		assert.NoError(t, PersistDeadLetter(logger, letter))
+	}
+
+	letters := LoadDeadLetters(logger)
+	assert.Len(t, letters, maxDeadLetters)
+	assert.Equal(t, start.Add(time.Second).Unix(), letters[0].Time.Unix())
+}
@@ -28,8 +28,10 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/jsonutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/status"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -114,12 +116,13 @@ func NewService(region string, endpoint string, creds *credentials.Credentials,
 			KeepAlive: 0,
 		}).Dial,
 		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     network.GetDefaultTLSConfig(),
 	}
 	config.HTTPClient = &http.Client{Transport: tr, Timeout: connectionTimeout}
 
 	appConfig, _ := appconfig.Config(false)
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	msgSvc := ssmmds.New(sess)
 
@@ -167,6 +170,7 @@ func (mds *sdkService) GetMessages(log log.T, instanceID string) (messages *ssmm
 		}
 	} else {
 		log.Debug("GetMessages Response", messages)
+		status.RecordContact(status.ServiceMDS)
 	}
 	return
 }
@@ -236,7 +240,11 @@ func (mds *sdkService) SendReplyWithInput(log log.T, sendReply *ssmmds.SendReply
 	log.Debug("Calling SendReply with params", sendReply)
 	req, resp := mds.sdk.SendReplyRequest(sendReply)
 	if err = mds.sendRequest(req); err != nil {
-		err = fmt.Errorf("SendReply Error: %v", err)
+		if isPermanentReplyFailure(err) {
+			err = &PermanentReplyError{Err: err}
+		} else {
+			err = fmt.Errorf("SendReply Error: %v", err)
+		}
 		log.Debug(err)
 	} else {
 		log.Info("SendReply Response", resp)
@@ -253,7 +261,9 @@ func (mds *sdkService) SendReply(log log.T, messageID string, payload string) (e
 		Payload:   aws.String(payload),   // Required
 		ReplyId:   aws.String(replyID),   // Required
 	}
-	if err = mds.SendReplyWithInput(log, &replyInput); err != nil {
+	if err = mds.SendReplyWithInput(log, &replyInput); IsPermanentReplyError(err) {
+		PersistDeadLetter(log, NewDeadLetter(replyInput, err.Error()))
+	} else if err != nil {
 		log.Infof("Saving reply %v to local disk", replyID)
 		mds.PersistFailedReply(log, replyInput)
 	}
@@ -323,15 +333,21 @@ func (mds *sdkService) DeleteFailedReply(log log.T, fileName string) {
 
 // PersistFailedReply saves SendReplyInput object to local replies folder on disk
 func (mds *sdkService) PersistFailedReply(log log.T, sendReply ssmmds.SendReplyInput) (err error) {
+	_, err = persistFailedReply(log, sendReply)
+	return err
+}
+
+// persistFailedReply saves SendReplyInput object to local replies folder on disk, the result tells if the reply is saved
+func persistFailedReply(log log.T, sendReply ssmmds.SendReplyInput) (persisted bool, err error) {
 	content, err := jsonutil.Marshal(sendReply)
 	if err != nil {
 		log.Errorf("encountered error with message %v while marshalling %v to string", err, sendReply)
 	} else {
-		files, _ := fileutil.GetFileNames(GetFailedReplyDirectory())
+		files, _ := fileutil.GetFileNames(failedReplyDirectory())
 		for _, file := range files {
 			if strings.HasPrefix(file, *sendReply.ReplyId) {
 				log.Debugf("Reply %v already saved in file %v, skipping", *sendReply.ReplyId, file)
-				return
+				return true, nil
 			}
 		}
 		t := time.Now().UTC()
@@ -341,11 +357,12 @@ func (mds *sdkService) PersistFailedReply(log log.T, sendReply ssmmds.SendReplyI
 		log.Tracef("persisting reply %v in file %v", jsonutil.Indent(content), absoluteFileName)
 		if s, err := fileutil.WriteIntoFileWithPermissions(absoluteFileName, jsonutil.Indent(content), os.FileMode(int(appconfig.ReadWriteAccess))); s && err == nil {
 			log.Debugf("successfully persisted reply in %v", absoluteFileName)
+			persisted = true
 		} else {
 			log.Debugf("persisting reply in %v failed with error %v", absoluteFileName, err)
 		}
 	}
-	return err
+	return persisted, err
 }
 
 // GetFailedReply load SendReplyInput object from replies folder given the reply id of the object
@@ -395,9 +412,12 @@ func (mds *sdkService) clearRequest() {
 	mds.storeRequest(nil)
 }
 
+// failedReplyDirectory returns the directory of the failed replies, replaced in tests
+var failedReplyDirectory = GetFailedReplyDirectory
+
 // getFailedReplyLocation returns path to reply file
 func getFailedReplyLocation(fileName string) string {
-	return path.Join(GetFailedReplyDirectory(), fileName)
+	return path.Join(failedReplyDirectory(), fileName)
 }
 
 // getFailedReplyDirectory returns path to replies folder
@@ -20,8 +20,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/liveness"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
+	"github.com/aws/amazon-ssm-agent/agent/telemetry"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
+	"github.com/aws/aws-sdk-go/service/ssmmds"
 	"github.com/carlescere/scheduler"
 )
 
@@ -62,6 +66,7 @@ func (s *RunCommandService) messagePollLoop() {
 	// this is extra insurance to prevent any race condition
 	pollStartTime := time.Now()
 	updateLastPollTime(s.name, pollStartTime)
+	defer liveness.Beat(s.name)
 
 	log := s.context.Log()
 	if err := s.checkStopPolicy(log); err != nil {
@@ -80,6 +85,12 @@ func (s *RunCommandService) messagePollLoop() {
 		time.Sleep(time.Duration(2000+rand.Intn(500)) * time.Millisecond)
 	}
 
+	// Keep the polling rate down during the configured peak hours
+	if delay := throttle.PollDelay(s.context.AppConfig().Throttle); delay > 0 {
+		log.Debugf("Peak hours throttling, waiting %v before the next poll", delay)
+		time.Sleep(delay)
+	}
+
 	// check if any other poll loop has started in the meantime
 	// to prevent any possible race condition due to the scheduler
 	if getLastPollTime(s.name) == pollStartTime {
@@ -124,6 +135,23 @@ func (s *RunCommandService) reset() {
 	}
 }
 
+// restartMessagePolling replaces a stuck message poller with a new mds service object and poll job
+func (s *RunCommandService) restartMessagePolling() {
+	log := s.context.Log()
+	log.Warnf("Restarting the message polling of %v", s.name)
+	// stopping the service cancels the request the stuck poll is blocked on
+	s.service.Stop()
+	s.service = newMdsService(s.context.AppConfig())
+
+	if s.messagePollJob != nil {
+		s.messagePollJob.Quit <- true
+	}
+	var err error
+	if s.messagePollJob, err = scheduler.Every(pollMessageFrequencyMinutes).Minutes().Run(s.messagePollLoop); err != nil {
+		log.Errorf("unable to schedule message poll job. %v", err)
+	}
+}
+
 // Stop stops the message poller.
 func (s *RunCommandService) stop() {
 	log := s.context.Log()
@@ -138,13 +166,28 @@ func (s *RunCommandService) stop() {
 	}
 }
 
+// recordPollTelemetry counts a call to GetMessages by outcome and the messages it returned
+func recordPollTelemetry(serviceName string, messages *ssmmds.GetMessagesOutput, err error) {
+	outcome := "Success"
+	if err != nil {
+		outcome = "Failed"
+	}
+	telemetry.AddCount("ssm.polls", 1, map[string]string{"ssm.service": serviceName, "ssm.outcome": outcome})
+	if err == nil && messages != nil && len(messages.Messages) > 0 {
+		telemetry.AddCount("ssm.poll.messages", int64(len(messages.Messages)), map[string]string{"ssm.service": serviceName})
+	}
+}
+
 // pollOnce calls GetMessages once and processes the result.
 func (s *RunCommandService) pollOnce() {
 	log := s.context.Log()
 	if s.name == mdsName {
 		log.Debugf("Polling for messages")
 	}
+	span := telemetry.StartSpan("poll "+s.name, nil, map[string]string{"ssm.service": s.name})
 	messages, err := s.service.GetMessages(log, s.config.InstanceID)
+	span.End(err)
+	recordPollTelemetry(s.name, messages, err)
 	if err != nil {
 		sdkutil.HandleAwsError(log, err, s.processorStopPolicy)
 		return
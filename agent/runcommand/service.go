@@ -16,6 +16,7 @@ package runcommand
 
 import (
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"time"
 
@@ -204,6 +205,8 @@ func processSendReply(log log.T, messageID string, mdsService mdsService.Service
 	payloadB, err := json.Marshal(payloadDoc)
 	if err != nil {
 		log.Error("could not marshal reply payload!", err)
+		persistUnserializableReply(log, messageID, payloadDoc, err)
+		return
 	}
 	payload := string(payloadB)
 	log.Info("Sending reply ", jsonutil.Indent(payload))
@@ -213,6 +216,21 @@ func processSendReply(log log.T, messageID string, mdsService mdsService.Service
 	}
 }
 
+var persistDeadLetter = mdsService.PersistDeadLetter
+
+// persistUnserializableReply keeps the reply payload which can't be serialized in the dead letters
+func persistUnserializableReply(log log.T, messageID string, payloadDoc messageContracts.SendReplyPayload, err error) {
+	letter := mdsService.DeadLetter{
+		MessageID: messageID,
+		Reason:    fmt.Sprintf("could not marshal reply payload: %v", err),
+		Time:      time.Now().UTC(),
+		Detail:    fmt.Sprintf("%+v", payloadDoc),
+	}
+	if err = persistDeadLetter(log, letter); err != nil {
+		log.Errorf("Failed to save the reply of message %v to the dead letters: %v", messageID, err)
+	}
+}
+
 var newOfflineService = func(log log.T) (mdsService.Service, error) {
 	return mdsService.NewOfflineService(log, string(SendCommandTopicPrefixOffline))
 }
@@ -36,6 +36,10 @@ import (
 	"github.com/gabs"
 )
 
+// SimulationParameter is the command parameter which runs the document in simulation when it is set to true, the
+// steps then report what they would do without side effects
+const SimulationParameter = "Simulation"
+
 // empty returns true if string is empty
 func empty(s *string) bool {
 	return s == nil || *s == ""
@@ -191,16 +195,23 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 	}
 
 	docContent := &docparser.DocContent{
-		SchemaVersion: parsedMessage.DocumentContent.SchemaVersion,
-		Description:   parsedMessage.DocumentContent.Description,
-		RuntimeConfig: parsedMessage.DocumentContent.RuntimeConfig,
-		MainSteps:     parsedMessage.DocumentContent.MainSteps,
-		Parameters:    parsedMessage.DocumentContent.Parameters}
+		SchemaVersion:       parsedMessage.DocumentContent.SchemaVersion,
+		Description:         parsedMessage.DocumentContent.Description,
+		RuntimeConfig:       parsedMessage.DocumentContent.RuntimeConfig,
+		MainSteps:           parsedMessage.DocumentContent.MainSteps,
+		Parameters:          parsedMessage.DocumentContent.Parameters,
+		MinimumAgentVersion: parsedMessage.DocumentContent.MinimumAgentVersion}
 	//Data format persisted in Current Folder is defined by the struct - CommandState
 	docState, err := docparser.InitializeDocState(log, documentType, docContent, documentInfo, parserInfo, parsedMessage.Parameters)
 	if err != nil {
 		return nil, err
 	}
+	if isSimulation(parsedMessage.Parameters) {
+		log.Infof("Command %v runs in simulation", commandID)
+		for i := range docState.InstancePluginsInformation {
+			docState.InstancePluginsInformation[i].Configuration.Simulation = true
+		}
+	}
 	parsedMessageContent, _ := jsonutil.Marshal(parsedMessage)
 
 	var parsedContentJson *gabs.Container
@@ -248,6 +259,21 @@ func parseSendCommandMessage(context context.T, msg *ssmmds.Message, messagesOrc
 	return &docState, nil
 }
 
+// isSimulation returns true when the command sets its simulation parameter
+func isSimulation(parameters map[string]interface{}) bool {
+	value := parameters[SimulationParameter]
+	if values, isList := value.([]interface{}); isList && len(values) > 0 {
+		value = values[0]
+	}
+	switch value := value.(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(strings.TrimSpace(value), "true")
+	}
+	return false
+}
+
 func isUpdatePlugin(plugins map[string]*contracts.PluginResult) bool {
 	for name, _ := range plugins {
 		if name == appconfig.PluginEC2ConfigUpdate || name == appconfig.PluginNameAwsAgentUpdate {
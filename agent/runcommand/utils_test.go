@@ -94,3 +94,10 @@ func getSampleParsedMessage(logGroupName string, outputEnabled string) messageCo
 		CloudWatchOutputEnabled: outputEnabled,
 	}
 }
+
+func TestIsSimulation(t *testing.T) {
+	assert.True(t, isSimulation(map[string]interface{}{SimulationParameter: []interface{}{"True"}}))
+	assert.True(t, isSimulation(map[string]interface{}{SimulationParameter: true}))
+	assert.False(t, isSimulation(map[string]interface{}{SimulationParameter: []interface{}{"false"}}))
+	assert.False(t, isSimulation(map[string]interface{}{"commands": []interface{}{"true"}}))
+}
@@ -50,6 +50,10 @@ func GetS3Endpoint(region string) (s3Endpoint string) {
 		}
 	}
 
+	if region != "" && appconfig.UseDualStackEndpoints() {
+		return appconfig.GetDualStackEndPoint(region, "s3")
+	}
+
 	if s3Endpoint, ok := awsS3EndpointMap[region]; ok {
 		return s3Endpoint
 	}
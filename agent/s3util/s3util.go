@@ -17,6 +17,7 @@ package s3util
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"time"
@@ -26,7 +27,6 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -60,6 +60,9 @@ func NewAmazonS3Util(log log.T, bucketName string) *AmazonS3Util {
 	} else {
 		if appConfig.S3.Endpoint != "" {
 			config.Endpoint = &appConfig.S3.Endpoint
+		} else if appconfig.UseDualStackEndpoints() {
+			// let the sdk resolve the dualstack endpoint of the bucket region
+			config.UseDualStack = aws.Bool(true)
 		} else {
 			if region, err := platform.Region(); err == nil {
 				if defaultEndpoint := appconfig.GetDefaultEndPoint(region, "s3"); defaultEndpoint != "" {
@@ -73,7 +76,7 @@ func NewAmazonS3Util(log log.T, bucketName string) *AmazonS3Util {
 	config.Region = &bucketRegion
 
 	sess := session.New(config)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	return &AmazonS3Util{
 		myUploader: s3manager.NewUploader(sess),
@@ -90,28 +93,49 @@ func (u *AmazonS3Util) S3Upload(log log.T, bucketName string, objectKey string,
 	defer file.Close()
 
 	log.Infof("Uploading %v to s3://%v/%v", filePath, bucketName, objectKey)
+	if err = u.S3UploadFromReader(log, bucketName, objectKey, file); err != nil {
+		log.Errorf("Failed uploading %v to s3://%v/%v err:%v", filePath, bucketName, objectKey, err)
+	}
+	return err
+}
+
+// S3UploadFromReader uploads the content of the reader to s3.
+func (u *AmazonS3Util) S3UploadFromReader(log log.T, bucketName string, objectKey string, reader io.Reader) (err error) {
 	params := &s3manager.UploadInput{
 		Bucket:      aws.String(bucketName),
 		Key:         aws.String(objectKey),
-		Body:        file,
+		Body:        reader,
 		ContentType: aws.String("text/plain"),
 	}
-	if result, err := u.myUploader.Upload(params); err == nil {
-		log.Infof("Successfully uploaded file to ", result.Location)
-		if _, aclErr := u.myUploader.S3.PutObjectAcl(&s3.PutObjectAclInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(objectKey),
-			ACL:    aws.String("bucket-owner-full-control"),
-		}); aclErr == nil {
-			log.Infof("PutAcl: bucket-owner-full-control succeeded.")
-		} else {
-			// gracefully ignore the error, since the S3 putAcl policy may not be set
-			log.Debugf("PutAcl: bucket-owner-full-control failed, error: %v", aclErr)
-		}
+	result, err := u.myUploader.Upload(params)
+	if err != nil {
+		return err
+	}
+	log.Infof("Successfully uploaded file to ", result.Location)
+	if _, aclErr := u.myUploader.S3.PutObjectAcl(&s3.PutObjectAclInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+		ACL:    aws.String("bucket-owner-full-control"),
+	}); aclErr == nil {
+		log.Infof("PutAcl: bucket-owner-full-control succeeded.")
 	} else {
-		log.Errorf("Failed uploading %v to s3://%v/%v err:%v", filePath, bucketName, objectKey, err)
+		// gracefully ignore the error, since the S3 putAcl policy may not be set
+		log.Debugf("PutAcl: bucket-owner-full-control failed, error: %v", aclErr)
 	}
-	return err
+	return nil
+}
+
+// S3OpenObject returns a reader streaming the content of an s3 object, the caller closes it.
+func (u *AmazonS3Util) S3OpenObject(log log.T, bucketName string, objectKey string) (io.ReadCloser, error) {
+	log.Debugf("Reading s3://%v/%v", bucketName, objectKey)
+	output, err := u.myUploader.S3.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
 }
 
 // This function returns the Amazon S3 Bucket region based on its name and the EC2 instance region.
@@ -15,6 +15,7 @@
 package sdkutil
 
 import (
+	"sync"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
@@ -24,6 +25,13 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil/retryer"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+)
+
+var (
+	instanceCredentialsOnce sync.Once
+	instanceCredentials     *credentials.Credentials
 )
 
 // AwsConfig returns the default aws.Config object while the appropriate
@@ -55,12 +63,24 @@ func AwsConfig() (awsConfig *aws.Config) {
 		creds, _ := appConfig.ProfileCredentials()
 		if creds != nil {
 			awsConfig.Credentials = creds
+			return
 		}
 	}
 
+	// the clients share the credentials of the default chain, such as the credentials of the role of the instance
+	awsConfig.Credentials = InstanceCredentials()
 	return
 }
 
+// InstanceCredentials returns the credentials of the default provider chain shared by the clients of the agent, so
+// that they can all be expired at once when the role of the instance changes.
+func InstanceCredentials() *credentials.Credentials {
+	instanceCredentialsOnce.Do(func() {
+		instanceCredentials = defaults.CredChain(defaults.Config(), defaults.Handlers())
+	})
+	return instanceCredentials
+}
+
 var newRetryer = func() aws.RequestRetryer {
 	r := retryer.SsmRetryer{}
 	r.NumMaxRetries = 3
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/request"
 )
@@ -28,6 +29,14 @@ type SsmRetryer struct {
 	client.DefaultRetryer
 }
 
+// ShouldRetry records the throttled calls before deciding whether the request is retried
+func (s SsmRetryer) ShouldRetry(r *request.Request) bool {
+	if r.IsErrorThrottle() {
+		throttle.RecordAPIThrottled(r.ClientInfo.ServiceName, r.Operation.Name)
+	}
+	return s.DefaultRetryer.ShouldRetry(r)
+}
+
 // RetryRules returns the delay duration before retrying this request again
 func (s SsmRetryer) RetryRules(r *request.Request) time.Duration {
 	// Handle GetMessages Client.Timeout error
@@ -0,0 +1,198 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package selfintegrity verifies at startup that the binaries of the agent match the signed manifest installed with
+// them, so that a binary replaced on a long-lived host is detected. Depending on the configuration a mismatch is
+// reported, or the agent refuses to start.
+package selfintegrity
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// SignatureExtension is appended to the manifest path to name its detached signature
+const SignatureExtension = ".sig"
+
+// manifest lists the sha256 of the agent binaries by their slash separated path relative to the manifest folder
+type manifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// executable is replaced by the tests
+var executable = os.Executable
+
+// Check verifies the agent binaries when it is enabled by the configuration. A mismatch is logged and published as
+// an agent event, and the error is returned in enforce mode only.
+func Check(log log.T, config appconfig.SelfIntegrityCfg) error {
+	if config.Mode != appconfig.SelfIntegrityAlert && config.Mode != appconfig.SelfIntegrityEnforce {
+		return nil
+	}
+	count, err := Verify(config)
+	if err == nil {
+		log.Infof("Verified %v agent binaries against their signed manifest", count)
+		return nil
+	}
+
+	log.Errorf("Agent binaries failed the integrity check: %v", err)
+	agentevents.Publish(agentevents.IntegrityViolation, map[string]string{
+		"mode":   config.Mode,
+		"reason": err.Error(),
+	})
+	if config.Mode == appconfig.SelfIntegrityEnforce {
+		return fmt.Errorf("agent binaries failed the integrity check: %v", err)
+	}
+	return nil
+}
+
+// ManifestPath returns the configured manifest, or the manifest next to the agent executable
+func ManifestPath(config appconfig.SelfIntegrityCfg) (string, error) {
+	if config.ManifestPath != "" {
+		return config.ManifestPath, nil
+	}
+	path, err := executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the agent executable: %v", err)
+	}
+	return filepath.Join(filepath.Dir(path), appconfig.SelfIntegrityManifestFileName), nil
+}
+
+// Verify checks the signature of the manifest and the hash of every binary it lists.
+// It returns the number of verified binaries, or an error naming every binary that does not match.
+func Verify(config appconfig.SelfIntegrityCfg) (int, error) {
+	manifestPath, err := ManifestPath(config)
+	if err != nil {
+		return 0, err
+	}
+	content, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	if err = verifySignature(config.PublicKeyPath, content, manifestPath+SignatureExtension); err != nil {
+		return 0, err
+	}
+
+	var parsed manifest
+	if err = json.Unmarshal(content, &parsed); err != nil {
+		return 0, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	if len(parsed.Files) == 0 {
+		return 0, errors.New("manifest does not list any binary")
+	}
+
+	names := make([]string, 0, len(parsed.Files))
+	for name := range parsed.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	root := filepath.Dir(manifestPath)
+	var mismatches []string
+	for _, name := range names {
+		if err = verifyFile(root, name, parsed.Files[name]); err != nil {
+			mismatches = append(mismatches, err.Error())
+		}
+	}
+	if len(mismatches) > 0 {
+		return 0, errors.New(strings.Join(mismatches, "; "))
+	}
+	return len(names), nil
+}
+
+// verifyFile compares the sha256 of a binary with its expected hex encoded hash
+func verifyFile(root string, name string, expected string) error {
+	relative := filepath.FromSlash(name)
+	if filepath.IsAbs(relative) || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%v is outside of the manifest folder", name)
+	}
+	file, err := os.Open(filepath.Join(root, filepath.Clean(relative)))
+	if err != nil {
+		return fmt.Errorf("%v cannot be read: %v", name, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return fmt.Errorf("%v cannot be read: %v", name, err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("%v has hash %v instead of %v", name, actual, expected)
+	}
+	return nil
+}
+
+// verifySignature verifies the detached signature of the manifest against a PEM encoded PKIX public key,
+// the signature is ed25519, or RSA or ECDSA over the sha256 of the manifest, either raw or base64 encoded
+func verifySignature(publicKeyPath string, content []byte, signaturePath string) error {
+	if publicKeyPath == "" {
+		return errors.New("no public key is configured to verify the manifest with")
+	}
+	keyContent, err := ioutil.ReadFile(publicKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %v", err)
+	}
+	block, _ := pem.Decode(keyContent)
+	if block == nil {
+		return fmt.Errorf("%v does not contain a PEM encoded public key", publicKeyPath)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key: %v", err)
+	}
+
+	signature, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		return fmt.Errorf("failed to read manifest signature: %v", err)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = decoded
+	}
+
+	digest := sha256.Sum256(content)
+	matched := false
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		matched = ed25519.Verify(key, content, signature)
+	case *rsa.PublicKey:
+		matched = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil ||
+			rsa.VerifyPSS(key, crypto.SHA256, digest[:], signature, nil) == nil
+	case *ecdsa.PublicKey:
+		matched = ecdsa.VerifyASN1(key, digest[:], signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	if !matched {
+		return errors.New("signature does not match the manifest")
+	}
+	return nil
+}
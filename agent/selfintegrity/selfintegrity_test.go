@@ -0,0 +1,140 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package selfintegrity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// installTestAgent writes binaries, their signed manifest and the public key to a temporary folder,
+// the folder of the manifest is removed by the caller
+func installTestAgent(t *testing.T, files map[string]string) (config appconfig.SelfIntegrityCfg, privateKey ed25519.PrivateKey) {
+	dir, err := ioutil.TempDir("", "selfintegrity")
+	assert.NoError(t, err)
+
+	hashes := manifest{Files: map[string]string{}}
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, filepath.FromSlash(name))), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0755))
+		sum := sha256.Sum256([]byte(content))
+		hashes.Files[name] = hex.EncodeToString(sum[:])
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(t, err)
+	config = appconfig.SelfIntegrityCfg{
+		Mode:          appconfig.SelfIntegrityAlert,
+		ManifestPath:  filepath.Join(dir, appconfig.SelfIntegrityManifestFileName),
+		PublicKeyPath: filepath.Join(dir, "key.pem"),
+	}
+	assert.NoError(t, ioutil.WriteFile(config.PublicKeyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	writeManifest(t, config, privateKey, hashes)
+	return config, privateKey
+}
+
+func writeManifest(t *testing.T, config appconfig.SelfIntegrityCfg, privateKey ed25519.PrivateKey, hashes manifest) {
+	content, err := json.Marshal(hashes)
+	assert.NoError(t, err)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, content))
+	assert.NoError(t, ioutil.WriteFile(config.ManifestPath, content, 0644))
+	assert.NoError(t, ioutil.WriteFile(config.ManifestPath+SignatureExtension, []byte(signature), 0644))
+}
+
+func TestVerifyMatchingBinaries(t *testing.T) {
+	config, _ := installTestAgent(t, map[string]string{"amazon-ssm-agent": "agent", "bin/ssm-document-worker": "worker"})
+	defer os.RemoveAll(filepath.Dir(config.ManifestPath))
+
+	count, err := Verify(config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
+
+func TestVerifyTamperedBinary(t *testing.T) {
+	config, _ := installTestAgent(t, map[string]string{"amazon-ssm-agent": "agent", "ssm-session-worker": "worker"})
+	defer os.RemoveAll(filepath.Dir(config.ManifestPath))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(filepath.Dir(config.ManifestPath), "ssm-session-worker"), []byte("tampered"), 0755))
+
+	_, err := Verify(config)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ssm-session-worker has hash")
+	assert.NotContains(t, err.Error(), "amazon-ssm-agent")
+}
+
+func TestVerifyRejectsManifestWithoutValidSignature(t *testing.T) {
+	config, _ := installTestAgent(t, map[string]string{"amazon-ssm-agent": "agent"})
+	defer os.RemoveAll(filepath.Dir(config.ManifestPath))
+	content, _ := ioutil.ReadFile(config.ManifestPath)
+	assert.NoError(t, ioutil.WriteFile(config.ManifestPath, append(content, ' '), 0644))
+
+	_, err := Verify(config)
+	assert.EqualError(t, err, "signature does not match the manifest")
+
+	config.PublicKeyPath = ""
+	_, err = Verify(config)
+	assert.Error(t, err)
+}
+
+func TestVerifyRejectsFilesOutsideOfManifestFolder(t *testing.T) {
+	config, privateKey := installTestAgent(t, map[string]string{"amazon-ssm-agent": "agent"})
+	defer os.RemoveAll(filepath.Dir(config.ManifestPath))
+	writeManifest(t, config, privateKey, manifest{Files: map[string]string{"../amazon-ssm-agent": "00"}})
+
+	_, err := Verify(config)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outside of the manifest folder")
+}
+
+func TestManifestPathDefaultsToExecutableFolder(t *testing.T) {
+	defer func() { executable = os.Executable }()
+	executable = func() (string, error) { return filepath.Join("opt", "ssm", "amazon-ssm-agent"), nil }
+
+	path, err := ManifestPath(appconfig.SelfIntegrityCfg{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("opt", "ssm", appconfig.SelfIntegrityManifestFileName), path)
+}
+
+func TestCheckRefusesToStartInEnforceModeOnly(t *testing.T) {
+	config, _ := installTestAgent(t, map[string]string{"amazon-ssm-agent": "agent"})
+	defer os.RemoveAll(filepath.Dir(config.ManifestPath))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(filepath.Dir(config.ManifestPath), "amazon-ssm-agent"), []byte("tampered"), 0755))
+
+	assert.NoError(t, Check(log.NewMockLog(), config))
+
+	config.Mode = appconfig.SelfIntegrityEnforce
+	assert.Error(t, Check(log.NewMockLog(), config))
+
+	config.Mode = appconfig.SelfIntegrityOff
+	assert.NoError(t, Check(log.NewMockLog(), config))
+}
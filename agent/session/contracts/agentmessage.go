@@ -292,6 +292,7 @@ func (agentMessage *AgentMessage) ParseAgentMessage(context context.T,
 		S3Prefix:            sessionInputs.S3KeyPrefix,
 		S3EncryptionEnabled: sessionInputs.S3EncryptionEnabled,
 		CloudWatchConfig:    contracts.CloudWatchConfiguration{LogGroupName: sessionInputs.CloudWatchLogGroupName, LogGroupEncryptionEnabled: sessionInputs.CloudWatchEncryptionEnabled},
+		ReadOnlySession:     sessionInputs.ReadOnly,
 	}
 	docContent := &docparser.SessionDocContent{
 		SchemaVersion: parsedMessagePayload.DocumentContent.SchemaVersion,
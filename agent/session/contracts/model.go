@@ -110,6 +110,8 @@ type AgentSessionStateContent struct {
 	SchemaVersion int    `json:"SchemaVersion"`
 	SessionState  string `json:"SessionState"`
 	SessionId     string `json:"SessionId"`
+	// TerminationReason tells why the agent terminates the session, empty unless the agent terminates it
+	TerminationReason string `json:"TerminationReason,omitempty"`
 }
 
 // Deserialize parses AcknowledgeContent message from payload of AgentMessage.
@@ -195,6 +197,8 @@ type SessionPluginResultOutput struct {
 	S3UrlSuffix string
 	CwlGroup    string
 	CwlStream   string
+	// TerminationReason tells why the agent terminated the session, empty when the session ended otherwise
+	TerminationReason TerminationReason `json:",omitempty"`
 }
 
 type PayloadType uint32
@@ -213,6 +217,14 @@ const (
 	Terminating SessionStatus = "Terminating"
 )
 
+// TerminationReason tells why the agent terminates a session
+type TerminationReason string
+
+const (
+	// TerminationReasonIdle terminates a session that exchanged no data while its client was gone for too long
+	TerminationReasonIdle TerminationReason = "IdleWorkerReaped"
+)
+
 type SizeData struct {
 	Cols uint32 `json:"cols"`
 	Rows uint32 `json:"rows"`
@@ -20,7 +20,9 @@ import (
 	"math/rand"
 	"path/filepath"
 
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/auditexport"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor"
@@ -30,6 +32,8 @@ import (
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/session/retry"
 	"github.com/aws/amazon-ssm-agent/agent/session/service"
+	"github.com/aws/amazon-ssm-agent/agent/status"
+	"github.com/aws/amazon-ssm-agent/agent/telemetry"
 	"github.com/aws/amazon-ssm-agent/agent/times"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/gorilla/websocket"
@@ -167,7 +171,17 @@ func (controlChannel *ControlChannel) Close(log log.T) error {
 }
 
 // Open opens a websocket connection and sends the token for service to acknowledge the connection.
-func (controlChannel *ControlChannel) Open(log log.T) error {
+func (controlChannel *ControlChannel) Open(log log.T) (err error) {
+	span := telemetry.StartSpan("open mgs controlchannel", nil, nil)
+	defer func() {
+		span.End(err)
+		outcome := "Success"
+		if err != nil {
+			outcome = "Failed"
+		}
+		telemetry.AddCount("ssm.mgs.connects", 1, map[string]string{"ssm.outcome": outcome})
+	}()
+
 	if err := controlChannel.wsChannel.Open(log); err != nil {
 		return fmt.Errorf("failed to connect controlchannel with error: %s", err)
 	}
@@ -186,7 +200,11 @@ func (controlChannel *ControlChannel) Open(log log.T) error {
 		return fmt.Errorf("error serializing openControlChannelInput: %s", err)
 	}
 
-	return controlChannel.SendMessage(log, jsonValue, websocket.TextMessage)
+	if err = controlChannel.SendMessage(log, jsonValue, websocket.TextMessage); err != nil {
+		return err
+	}
+	status.RecordContact(status.ServiceMGS)
+	return nil
 }
 
 // controlChannelIncomingMessageHandler handles the incoming messages coming to the agent.
@@ -197,6 +215,7 @@ func controlChannelIncomingMessageHandler(context context.T,
 	instanceId string) error {
 
 	log := context.Log()
+	status.RecordContact(status.ServiceMGS)
 
 	agentMessage := &mgsContracts.AgentMessage{}
 	if err := agentMessage.Deserialize(log, rawMessage); err != nil {
@@ -208,6 +227,7 @@ func controlChannelIncomingMessageHandler(context context.T,
 		log.Debugf("Invalid AgentMessage: %s, err: %v.", agentMessage.MessageId, err)
 		return err
 	}
+	telemetry.AddCount("ssm.mgs.messages", 1, map[string]string{"ssm.message.type": agentMessage.MessageType})
 
 	if agentMessage.MessageType == mgsContracts.InteractiveShellMessage {
 		uuid.SwitchFormat(uuid.CleanHyphen)
@@ -240,6 +260,17 @@ func sendStartSessionMessageToProcessor(
 
 	// Submit message to processor
 	processor.Submit(*docState)
+	agentevents.Publish(agentevents.SessionStarted, map[string]string{
+		"sessionId":    docState.DocumentInformation.DocumentID,
+		"documentName": docState.DocumentInformation.DocumentName,
+		"clientId":     clientId,
+	})
+	auditexport.Record(auditexport.Event{
+		Name:         auditexport.SessionStarted,
+		SessionID:    docState.DocumentInformation.DocumentID,
+		DocumentName: docState.DocumentInformation.DocumentName,
+		ClientID:     clientId,
+	})
 	return nil
 }
 
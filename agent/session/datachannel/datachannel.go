@@ -22,8 +22,10 @@ import (
 	"math"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/activitymetrics"
 	"github.com/aws/amazon-ssm-agent/agent/context"
 	"github.com/aws/amazon-ssm-agent/agent/log"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
@@ -57,6 +59,8 @@ type IDataChannel interface {
 	ProcessAcknowledgedMessage(log log.T, acknowledgeMessageContent mgsContracts.AcknowledgeContent)
 	SendAcknowledgeMessage(log log.T, agentMessage mgsContracts.AgentMessage) error
 	SendAgentSessionStateMessage(log log.T, sessionStatus mgsContracts.SessionStatus) error
+	SendAgentSessionTerminatingMessage(log log.T, reason mgsContracts.TerminationReason) error
+	Activity() (lastData time.Time, clientGone bool)
 	AddDataToOutgoingMessageBuffer(streamMessage StreamingMessage)
 	RemoveDataFromOutgoingMessageBuffer(streamMessageElement *list.Element)
 	AddDataToIncomingMessageBuffer(streamMessage StreamingMessage)
@@ -89,6 +93,8 @@ type DataChannel struct {
 	RoundTripTimeVariation float64
 	//timeout used for resending unacknowledged message
 	RetransmissionTimeout time.Duration
+	//unix time in nanoseconds of the last stream data message sent or received, read and written atomically
+	lastDataTime int64
 }
 
 type ListMessageBuffer struct {
@@ -185,6 +191,7 @@ func (dataChannel *DataChannel) Initialize(context context.T,
 	dataChannel.RoundTripTimeVariation = mgsConfig.DefaultRoundTripTimeVariation
 	dataChannel.RetransmissionTimeout = mgsConfig.DefaultTransmissionTimeout
 	dataChannel.wsChannel = &communicator.WebSocketChannel{}
+	dataChannel.recordData()
 }
 
 // SetWebSocket populates webchannel object.
@@ -346,6 +353,8 @@ func (dataChannel *DataChannel) SendStreamDataMessage(log log.T, payloadType mgs
 	log.Tracef("Add stream data to OutgoingMessageBuffer. Sequence Number: %d", streamingMessage.SequenceNumber)
 	dataChannel.AddDataToOutgoingMessageBuffer(streamingMessage)
 	dataChannel.StreamDataSequenceNumber = dataChannel.StreamDataSequenceNumber + 1
+	dataChannel.recordData()
+	activitymetrics.Add(activitymetrics.BytesForwarded, int64(len(inputData)))
 	return nil
 }
 
@@ -420,12 +429,45 @@ func (dataChannel *DataChannel) SendAcknowledgeMessage(log log.T, streamDataMess
 
 // SendAgentSessionStateMessage sends agent session state to MGS
 func (dataChannel *DataChannel) SendAgentSessionStateMessage(log log.T, sessionStatus mgsContracts.SessionStatus) error {
-	agentSessionStateContent := &mgsContracts.AgentSessionStateContent{
+	return dataChannel.sendAgentSessionState(log, &mgsContracts.AgentSessionStateContent{
 		SchemaVersion: schemaVersion,
 		SessionState:  string(sessionStatus),
 		SessionId:     dataChannel.ChannelId,
+	})
+}
+
+// SendAgentSessionTerminatingMessage sends the terminating state to MGS with the reason the agent terminates the session
+func (dataChannel *DataChannel) SendAgentSessionTerminatingMessage(log log.T, reason mgsContracts.TerminationReason) error {
+	return dataChannel.sendAgentSessionState(log, &mgsContracts.AgentSessionStateContent{
+		SchemaVersion:     schemaVersion,
+		SessionState:      string(mgsContracts.Terminating),
+		SessionId:         dataChannel.ChannelId,
+		TerminationReason: string(reason),
+	})
+}
+
+// Activity returns the time of the last stream data message sent or received, and whether the client is gone: the
+// publication is paused or the client stopped acknowledging the messages sent to it.
+func (dataChannel *DataChannel) Activity() (lastData time.Time, clientGone bool) {
+	lastData = time.Unix(0, atomic.LoadInt64(&dataChannel.lastDataTime))
+	if dataChannel.Pause {
+		return lastData, true
 	}
+	if dataChannel.OutgoingMessageBuffer.Messages != nil {
+		dataChannel.OutgoingMessageBuffer.Mutex.Lock()
+		defer dataChannel.OutgoingMessageBuffer.Mutex.Unlock()
+		clientGone = dataChannel.OutgoingMessageBuffer.Messages.Len() > 0
+	}
+	return lastData, clientGone
+}
+
+// recordData records that a stream data message was sent or received
+func (dataChannel *DataChannel) recordData() {
+	atomic.StoreInt64(&dataChannel.lastDataTime, time.Now().UnixNano())
+}
 
+// sendAgentSessionState sends the agent session state content to MGS
+func (dataChannel *DataChannel) sendAgentSessionState(log log.T, agentSessionStateContent *mgsContracts.AgentSessionStateContent) error {
 	var agentSessionStateContentBytes []byte
 	var err error
 	if agentSessionStateContentBytes, err = json.Marshal(agentSessionStateContent); err != nil {
@@ -433,7 +475,7 @@ func (dataChannel *DataChannel) SendAgentSessionStateMessage(log log.T, sessionS
 		return err
 	}
 
-	log.Tracef("Send %s message with session status %s", mgsContracts.AgentSessionState, string(sessionStatus))
+	log.Tracef("Send %s message with session status %s", mgsContracts.AgentSessionState, agentSessionStateContent.SessionState)
 	if err := dataChannel.sendAgentMessage(log, mgsContracts.AgentSessionState, agentSessionStateContentBytes); err != nil {
 		return err
 	}
@@ -575,6 +617,7 @@ func (dataChannel *DataChannel) handleStreamDataMessage(log log.T,
 	rawMessage []byte) (err error) {
 
 	dataChannel.Pause = false
+	dataChannel.recordData()
 	// On receiving expected stream data message, send acknowledgement, process it and increment expected sequence number by 1.
 	// Further process messages from IncomingMessageBuffer
 	if streamDataMessage.SequenceNumber == dataChannel.ExpectedSequenceNumber {
@@ -587,6 +630,7 @@ func (dataChannel *DataChannel) handleStreamDataMessage(log log.T,
 			log.Errorf("Unable to process stream data payload, err: %v.", err)
 			return err
 		}
+		activitymetrics.Add(activitymetrics.BytesForwarded, int64(len(streamDataMessage.Payload)))
 
 		dataChannel.ExpectedSequenceNumber = dataChannel.ExpectedSequenceNumber + 1
 		return dataChannel.processIncomingMessageBufferItems(log, streamMessageHandler)
@@ -674,6 +718,7 @@ func (dataChannel *DataChannel) processIncomingMessageBufferItems(log log.T, str
 				log.Errorf("Unable to process stream data payload, err: %v.", err)
 				return err
 			}
+			activitymetrics.Add(activitymetrics.BytesForwarded, int64(len(streamDataMessage.Payload)))
 
 			dataChannel.ExpectedSequenceNumber = dataChannel.ExpectedSequenceNumber + 1
 			log.Debugf("Delete stream data from IncomingMessageBuffer. Sequence Number: %d", bufferedStreamMessage.SequenceNumber)
@@ -227,6 +227,24 @@ func TestSendAgentSessionStateMessage(t *testing.T) {
 	mockWsChannel.AssertExpectations(t)
 }
 
+func TestActivity(t *testing.T) {
+	dataChannel := getDataChannel()
+	initialized, clientGone := dataChannel.Activity()
+	assert.False(t, clientGone)
+
+	mockWsChannel.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	dataChannel.SendStreamDataMessage(mockLog, mgsContracts.Output, payload)
+
+	lastData, clientGone := dataChannel.Activity()
+	assert.True(t, clientGone, "the message sent is not acknowledged")
+	assert.False(t, lastData.Before(initialized))
+
+	dataChannel.OutgoingMessageBuffer.Messages.Init()
+	dataChannel.Pause = true
+	_, clientGone = dataChannel.Activity()
+	assert.True(t, clientGone)
+}
+
 func TestAddDataToOutgoingMessageBuffer(t *testing.T) {
 	dataChannel := getDataChannel()
 	dataChannel.OutgoingMessageBuffer.Capacity = 2
@@ -22,12 +22,34 @@ import log "github.com/aws/amazon-ssm-agent/agent/log"
 import mock "github.com/stretchr/testify/mock"
 import service "github.com/aws/amazon-ssm-agent/agent/session/service"
 import task "github.com/aws/amazon-ssm-agent/agent/task"
+import time "time"
 
 // IDataChannel is an autogenerated mock type for the IDataChannel type
 type IDataChannel struct {
 	mock.Mock
 }
 
+// Activity provides a mock function with given fields:
+func (_m *IDataChannel) Activity() (time.Time, bool) {
+	ret := _m.Called()
+
+	var r0 time.Time
+	if rf, ok := ret.Get(0).(func() time.Time); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(time.Time)
+	}
+
+	var r1 bool
+	if rf, ok := ret.Get(1).(func() bool); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+
+	return r0, r1
+}
+
 // AddDataToIncomingMessageBuffer provides a mock function with given fields: streamMessage
 func (_m *IDataChannel) AddDataToIncomingMessageBuffer(streamMessage datachannel.StreamingMessage) {
 	_m.Called(streamMessage)
@@ -156,6 +178,20 @@ func (_m *IDataChannel) SendAgentSessionStateMessage(_a0 log.T, sessionStatus co
 	return r0
 }
 
+// SendAgentSessionTerminatingMessage provides a mock function with given fields: _a0, reason
+func (_m *IDataChannel) SendAgentSessionTerminatingMessage(_a0 log.T, reason contracts.TerminationReason) error {
+	ret := _m.Called(_a0, reason)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(log.T, contracts.TerminationReason) error); ok {
+		r0 = rf(_a0, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // SendMessage provides a mock function with given fields: _a0, input, inputType
 func (_m *IDataChannel) SendMessage(_a0 log.T, input []byte, inputType int) error {
 	ret := _m.Called(_a0, input, inputType)
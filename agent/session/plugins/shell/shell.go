@@ -33,11 +33,15 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/iohandler"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/s3util"
 	mgsConfig "github.com/aws/amazon-ssm-agent/agent/session/config"
 	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
+	"github.com/aws/amazon-ssm-agent/agent/session/reaper"
+	"github.com/aws/amazon-ssm-agent/agent/session/sessionhook"
 	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
 )
 
 var ShellPluginCommandName = "sh"
@@ -129,8 +133,23 @@ func (p *ShellPlugin) GetOnMessageHandler(log log.T, cancelFlag task.CancelFlag)
 	}
 }
 
-var startPty = func(log log.T, isSessionShell bool) (stdin *os.File, stdout *os.File, err error) {
-	return StartPty(log, isSessionShell)
+var startPty = func(log log.T, isSessionShell bool, runAsUser string, readOnly bool) (stdin *os.File, stdout *os.File, err error) {
+	return StartPty(log, isSessionShell, runAsUser, readOnly)
+}
+
+var ephemeralSessionUsersEnabled = func() bool {
+	agentConfig, _ := appconfig.Config(false)
+	return agentConfig.Mgs.EphemeralSessionUsers
+}
+
+var idleWorkerTimeout = func() time.Duration {
+	agentConfig, _ := appconfig.Config(false)
+	return time.Duration(agentConfig.Mgs.IdleWorkerTimeoutMinutes) * time.Minute
+}
+
+var getSessionStartHook = func() sessionhook.Hook {
+	agentConfig, _ := appconfig.Config(false)
+	return sessionhook.NewHook(agentConfig.Mgs.SessionStartHook)
 }
 
 // execute starts pseudo terminal.
@@ -151,7 +170,9 @@ func (p *ShellPlugin) execute(context context.T,
 		s3Util = s3util.NewAmazonS3Util(log, config.OutputS3BucketName)
 	}
 	if config.CloudWatchLogGroup != "" {
-		cwl = cloudwatchlogspublisher.NewCloudWatchLogsService()
+		cwlService := cloudwatchlogspublisher.NewCloudWatchLogsService()
+		cwlService.UploadPriority = uploadqueue.PrioritySessionLogs
+		cwl = cwlService
 	}
 	if err = p.Validate(context, config, cwl, s3Util); err != nil {
 		output.SetExitCode(appconfig.ErrorExitCode)
@@ -162,7 +183,37 @@ func (p *ShellPlugin) execute(context context.T,
 		return
 	}
 
-	p.stdin, p.stdout, err = startPty(log, true)
+	runAsUser := appconfig.DefaultRunAsUserName
+	if ephemeralSessionUsersEnabled() {
+		runAsUser = EphemeralUserName(config.SessionId)
+	}
+
+	if hook := getSessionStartHook(); hook != nil {
+		if err = hook.Authorize(log, buildSessionInfo(config, runAsUser)); err != nil {
+			log.Errorf("Session start hook rejected session %s: %v", config.SessionId, err)
+			output.MarkAsFailed(fmt.Errorf("Session rejected: %s", err))
+			return
+		}
+	}
+
+	if ephemeralSessionUsersEnabled() {
+		if err = createEphemeralUser(log, runAsUser); err != nil {
+			errorString := fmt.Errorf("Unable to create session user %s: %s", runAsUser, err)
+			log.Error(errorString)
+			output.MarkAsFailed(errorString)
+			return
+		}
+		defer func() {
+			if err := deleteEphemeralUser(log, runAsUser); err != nil {
+				log.Errorf("Failed to delete session user %s: %v", runAsUser, err)
+			}
+		}()
+	}
+
+	if config.ReadOnlySession {
+		log.Infof("Starting session %s with a read-only view of the filesystem", config.SessionId)
+	}
+	p.stdin, p.stdout, err = startPty(log, true, runAsUser, config.ReadOnlySession)
 	if err != nil {
 		errorString := fmt.Errorf("Unable to start shell: %s", err)
 		log.Error(errorString)
@@ -193,9 +244,23 @@ func (p *ShellPlugin) execute(context context.T,
 		done <- p.writePump(log)
 	}()
 
+	reaped := make(chan bool, 1)
+	if timeout := idleWorkerTimeout(); timeout > 0 {
+		stopReaper := reaper.Watch(log, config.SessionId, p.dataChannel, timeout, config.OrchestrationDirectory,
+			func() interface{} { return p.idleSnapshot(runAsUser, config.ReadOnlySession) },
+			func() { reaped <- true })
+		defer stopReaper()
+	}
+
 	log.Infof("Plugin %s started", p.Name())
 
 	select {
+	case <-reaped:
+		output.SetExitCode(appconfig.ErrorExitCode)
+		output.SetStatus(agentContracts.ResultStatusTimedOut)
+		sessionPluginResultOutput.TerminationReason = mgsContracts.TerminationReasonIdle
+		log.Info("The session was terminated, it was idle while its client was gone")
+
 	case <-cancelled:
 		log.Debug("Session cancelled. Attempting to stop pty.")
 		errorCode := 0
@@ -230,7 +295,7 @@ func (p *ShellPlugin) execute(context context.T,
 		log.Debug("Starting S3 logging")
 		if config.OutputS3BucketName != "" {
 			s3KeyPrefix := fileutil.BuildS3Path(config.OutputS3KeyPrefix, logFileName)
-			p.uploadShellSessionLogsToS3(log, s3Util, config, s3KeyPrefix)
+			p.uploadShellSessionLogsToS3(log, config, s3KeyPrefix)
 			sessionPluginResultOutput.S3Bucket = config.OutputS3BucketName
 			sessionPluginResultOutput.S3UrlSuffix = s3KeyPrefix
 		}
@@ -247,11 +312,43 @@ func (p *ShellPlugin) execute(context context.T,
 	log.Debug("Shell session execution complete")
 }
 
-// uploadShellSessionLogsToS3 uploads shell session logs to S3 bucket specified.
-func (p *ShellPlugin) uploadShellSessionLogsToS3(log log.T, s3UploaderUtil s3util.IAmazonS3Util, config agentContracts.Configuration, s3KeyPrefix string) {
+// shellSnapshot is the state of a shell saved when its session is terminated for being idle
+type shellSnapshot struct {
+	RunAsUser string
+	ReadOnly  bool
+	LogFile   string
+	LogBytes  int64
+}
+
+// idleSnapshot returns the state of the shell of a session terminated for being idle
+func (p *ShellPlugin) idleSnapshot(runAsUser string, readOnly bool) interface{} {
+	snapshot := shellSnapshot{RunAsUser: runAsUser, ReadOnly: readOnly, LogFile: p.ipcFilePath}
+	if info, err := os.Stat(p.ipcFilePath); err == nil {
+		snapshot.LogBytes = info.Size()
+	}
+	return snapshot
+}
+
+// buildSessionInfo builds the session metadata handed to the session start hooks.
+func buildSessionInfo(config agentContracts.Configuration, runAsUser string) sessionhook.SessionInfo {
+	info := sessionhook.SessionInfo{
+		SessionId: config.SessionId,
+		ClientId:  config.ClientId,
+		RunAsUser: runAsUser,
+	}
+	info.InstanceId, _ = platform.InstanceID()
+	if properties, ok := config.Properties.(map[string]interface{}); ok {
+		info.Parameters = properties
+	}
+	return info
+}
+
+// uploadShellSessionLogsToS3 uploads shell session logs to S3 bucket specified through the upload queue.
+func (p *ShellPlugin) uploadShellSessionLogsToS3(log log.T, config agentContracts.Configuration, s3KeyPrefix string) {
 	log.Debugf("Preparing to upload session logs to S3 bucket %s and prefix %s", config.OutputS3BucketName, s3KeyPrefix)
 
-	if err := s3UploaderUtil.S3Upload(log, config.OutputS3BucketName, s3KeyPrefix, p.logFilePath); err != nil {
+	object := uploadqueue.S3Object{Bucket: config.OutputS3BucketName, Key: s3KeyPrefix, FilePath: p.logFilePath}
+	if err := uploadqueue.UploadFile(log, uploadqueue.PrioritySessionLogs, object); err != nil {
 		log.Errorf("Failed to upload shell session logs to S3: %s", err)
 	}
 }
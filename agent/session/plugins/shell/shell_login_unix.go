@@ -0,0 +1,98 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	defaultShell = "sh"
+	// zsh marks the output not ending with a new line with a reverse video %, which ends up in the session output
+	zshPromptEolMarkEnvVariable = "PROMPT_EOL_MARK="
+	// zsh doesn't edit multibyte characters without a UTF-8 locale, which the launchd services don't have
+	utf8LangEnvVariable = "LANG=en_US.UTF-8"
+)
+
+var lookupHomeDirectory = func(userName string) (string, error) {
+	u, err := user.Lookup(userName)
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// lookupLoginShell reads the login shell of the user from the directory service of macOS.
+var lookupLoginShell = func(userName string) (string, error) {
+	out, err := exec.Command("dscl", ".", "-read", "/Users/"+userName, "UserShell").Output()
+	if err != nil {
+		return "", err
+	}
+	return parseUserShell(string(out))
+}
+
+// parseUserShell returns the shell of the "UserShell: /bin/zsh" output of dscl.
+func parseUserShell(output string) (string, error) {
+	shell := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(output), "UserShell:"))
+	if !filepath.IsAbs(shell) {
+		return "", fmt.Errorf("unexpected user shell %q", output)
+	}
+	return shell, nil
+}
+
+// homeDirectory returns the home directory of the user, /home/<user> when it is not known
+func homeDirectory(log log.T, userName string) string {
+	home, err := lookupHomeDirectory(userName)
+	if err != nil || home == "" {
+		log.Debugf("Using %v as the home of %v: %v", defaultHomeRoot+userName, userName, err)
+		return defaultHomeRoot + userName
+	}
+	return home
+}
+
+// newShellCommand returns the shell started in the pty of the session. On macOS it is the login shell of the user,
+// zsh by default, started as a login shell so that path_helper sets the PATH like in Terminal.
+func newShellCommand(log log.T, runAsUser string, goos string) *exec.Cmd {
+	cmd := exec.Command(defaultShell)
+	//TERM is set as linux by pty which has an issue where vi editor screen does not get cleared.
+	//Setting TERM as xterm-256color as used by standard terminals to fix this issue
+	env := []string{termEnvVariable, homeEnvVariable + homeDirectory(log, runAsUser)}
+	if goos == "darwin" {
+		if shell, err := lookupLoginShell(runAsUser); err != nil {
+			log.Warnf("Starting %v, failed to read the login shell of %v: %v", defaultShell, runAsUser, err)
+		} else {
+			cmd = exec.Command(shell)
+			// a leading dash in the name of the shell starts it as a login shell
+			cmd.Args[0] = "-" + filepath.Base(shell)
+			env = append(env, "SHELL="+shell)
+			if filepath.Base(shell) == "zsh" {
+				env = append(env, zshPromptEolMarkEnvVariable)
+			}
+		}
+		if os.Getenv("LANG") == "" {
+			env = append(env, utf8LangEnvVariable)
+		}
+	}
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package shell
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func stubUserLookups(home string, shell string) func() {
+	homeLookup, shellLookup := lookupHomeDirectory, lookupLoginShell
+	lookupHomeDirectory = func(string) (string, error) {
+		if home == "" {
+			return "", fmt.Errorf("unknown user")
+		}
+		return home, nil
+	}
+	lookupLoginShell = func(string) (string, error) {
+		if shell == "" {
+			return "", fmt.Errorf("unknown user")
+		}
+		return shell, nil
+	}
+	return func() {
+		lookupHomeDirectory, lookupLoginShell = homeLookup, shellLookup
+	}
+}
+
+func TestParseUserShell(t *testing.T) {
+	shell, err := parseUserShell("UserShell: /bin/zsh\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "/bin/zsh", shell)
+
+	_, err = parseUserShell("No such key: UserShell")
+	assert.Error(t, err)
+}
+
+func TestNewShellCommandOnDarwin(t *testing.T) {
+	defer stubUserLookups("/Users/ssm-user", "/bin/zsh")()
+
+	cmd := newShellCommand(log.NewMockLog(), "ssm-user", "darwin")
+
+	assert.Equal(t, "/bin/zsh", cmd.Path)
+	assert.Equal(t, []string{"-zsh"}, cmd.Args)
+	assert.Contains(t, cmd.Env, "HOME=/Users/ssm-user")
+	assert.Contains(t, cmd.Env, "SHELL=/bin/zsh")
+	assert.Contains(t, cmd.Env, zshPromptEolMarkEnvVariable)
+	assert.Contains(t, cmd.Env, termEnvVariable)
+}
+
+func TestNewShellCommandOnLinux(t *testing.T) {
+	defer stubUserLookups("", "/bin/zsh")()
+
+	cmd := newShellCommand(log.NewMockLog(), "ssm-user", "linux")
+
+	assert.Equal(t, []string{defaultShell}, cmd.Args)
+	assert.Contains(t, cmd.Env, "HOME=/home/ssm-user")
+	assert.NotContains(t, cmd.Env, zshPromptEolMarkEnvVariable)
+}
+
+func TestNewShellCommandWithoutLoginShell(t *testing.T) {
+	defer stubUserLookups("/var/root", "")()
+
+	cmd := newShellCommand(log.NewMockLog(), "root", "darwin")
+
+	assert.Equal(t, []string{defaultShell}, cmd.Args)
+	assert.Contains(t, cmd.Env, "HOME=/var/root")
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build linux
+
+package shell
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// readOnlyMountsScript remounts every mount of a private mount namespace read-only, keeping its nosuid, nodev and
+// noexec flags, then starts the shell given as arguments as the user of the session. The session fails when a mount
+// cannot be made read-only rather than giving a writable view of it.
+const readOnlyMountsScript = `awk '{
+	flags = ""
+	n = split($4, options, ",")
+	for (i = 1; i <= n; i++) if (options[i] ~ /^(nosuid|nodev|noexec)$/) flags = flags "," options[i]
+	print $2, flags
+}' /proc/self/mounts | sort -u | while read -r mountPoint flags; do
+	mountPoint=$(printf '%%b' "$mountPoint")
+	mount -o "remount,bind,ro$flags" "$mountPoint" 2>/dev/null || { echo "failed to make $mountPoint read-only" >&2; exit 1; }
+done || exit 1
+exec setpriv --reuid=%d --regid=%d --clear-groups -- "$@"`
+
+// readOnlyTools are the util-linux commands the read-only shell is started with
+var readOnlyTools = []string{"unshare", "mount", "setpriv"}
+
+var lookPath = exec.LookPath
+
+// newReadOnlyShellCommand wraps the session shell so that it runs as uid and gid in a private mount namespace where
+// the filesystem is read-only. The wrapper itself is started as root to remount the filesystems.
+func newReadOnlyShellCommand(cmd *exec.Cmd, uid int, gid int) (*exec.Cmd, error) {
+	for _, tool := range readOnlyTools {
+		if _, err := lookPath(tool); err != nil {
+			return nil, fmt.Errorf("read-only sessions require %s: %v", tool, err)
+		}
+	}
+	args := []string{"--mount", "--propagation", "private", "--", "sh", "-c", fmt.Sprintf(readOnlyMountsScript, uid, gid), "sh", cmd.Path}
+	wrapped := exec.Command("unshare", append(args, cmd.Args[1:]...)...)
+	wrapped.Env = cmd.Env
+	return wrapped, nil
+}
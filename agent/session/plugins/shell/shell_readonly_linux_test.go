@@ -0,0 +1,54 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build linux
+
+package shell
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReadOnlyShellCommand(t *testing.T) {
+	defer func() { lookPath = exec.LookPath }()
+	lookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	cmd := exec.Command("sh", "-l")
+	cmd.Env = []string{termEnvVariable}
+
+	wrapped, err := newReadOnlyShellCommand(cmd, 1001, 1002)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{termEnvVariable}, wrapped.Env)
+	assert.Equal(t, []string{"unshare", "--mount", "--propagation", "private", "--", "sh", "-c"}, wrapped.Args[:7])
+	assert.Contains(t, wrapped.Args[7], "setpriv --reuid=1001 --regid=1002 --clear-groups")
+	assert.Contains(t, wrapped.Args[7], "printf '%b'")
+	assert.Equal(t, []string{"sh", cmd.Path, "-l"}, wrapped.Args[8:])
+}
+
+func TestNewReadOnlyShellCommandRequiresUtilLinux(t *testing.T) {
+	defer func() { lookPath = exec.LookPath }()
+	lookPath = func(file string) (string, error) {
+		if file == "setpriv" {
+			return "", errors.New("not found")
+		}
+		return "/usr/bin/" + file, nil
+	}
+
+	_, err := newReadOnlyShellCommand(exec.Command("sh"), 1001, 1002)
+
+	assert.EqualError(t, err, "read-only sessions require setpriv: not found")
+}
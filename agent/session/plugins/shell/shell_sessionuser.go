@@ -0,0 +1,66 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package shell implements session shell plugin.
+package shell
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// ephemeralUserPrefix is the name prefix of the local users created per session.
+	// Local user names are limited to 20 characters on Windows, hence the short prefix and hash.
+	ephemeralUserPrefix     = "ssm-s-"
+	ephemeralUserHashLength = 12
+	// ephemeralUserComment marks a local user as created by the agent, only users carrying it are ever removed.
+	ephemeralUserComment = "SSM Agent ephemeral session user"
+)
+
+// EphemeralUserName returns the name of the dedicated local user for the given session.
+func EphemeralUserName(sessionId string) string {
+	hash := fmt.Sprintf("%x", sha1.Sum([]byte(sessionId)))
+	return ephemeralUserPrefix + hash[:ephemeralUserHashLength]
+}
+
+// isEphemeralUserName returns true if the name follows the ephemeral session user naming.
+func isEphemeralUserName(name string) bool {
+	return strings.HasPrefix(name, ephemeralUserPrefix) && len(name) == len(ephemeralUserPrefix)+ephemeralUserHashLength
+}
+
+// CleanupEphemeralUsers removes ephemeral session users left behind by session workers that did not exit cleanly
+// and returns the removed users. Users that still own running processes belong to a live session and are kept.
+func CleanupEphemeralUsers(log log.T) (removed []string) {
+	users, err := listEphemeralUsers(log)
+	if err != nil {
+		log.Errorf("Failed to list ephemeral session users: %v", err)
+		return
+	}
+	for _, user := range users {
+		if isUserActive(log, user) {
+			log.Debugf("Ephemeral session user %s is still in use", user)
+			continue
+		}
+		log.Infof("Removing stale ephemeral session user %s", user)
+		if err = deleteEphemeralUser(log, user); err != nil {
+			log.Errorf("Failed to remove stale ephemeral session user %s: %v", user, err)
+			continue
+		}
+		removed = append(removed, user)
+	}
+	return
+}
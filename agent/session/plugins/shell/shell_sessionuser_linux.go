@@ -0,0 +1,86 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build linux
+
+// Package shell implements session shell plugin.
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const passwdFile = "/etc/passwd"
+
+var execCommand = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// createEphemeralUser creates a local user with its own home directory for a single session.
+func createEphemeralUser(log log.T, userName string) error {
+	log.Infof("Creating ephemeral session user %s", userName)
+	if out, err := execCommand("useradd", "-m", "-c", ephemeralUserComment, userName); err != nil {
+		return fmt.Errorf("useradd failed: %v, %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// deleteEphemeralUser terminates the remaining processes of the session user and removes it together with its home directory.
+func deleteEphemeralUser(log log.T, userName string) error {
+	if !isEphemeralUserName(userName) {
+		return fmt.Errorf("%s is not an ephemeral session user", userName)
+	}
+	log.Infof("Deleting ephemeral session user %s", userName)
+	// pkill returns non-zero when there is no process to kill, which is expected
+	execCommand("pkill", "-KILL", "-u", userName)
+	if out, err := execCommand("userdel", "-r", userName); err != nil {
+		return fmt.Errorf("userdel failed: %v, %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// listEphemeralUsers returns the local users created by the agent for sessions.
+func listEphemeralUsers(log log.T) (users []string, err error) {
+	file, err := os.Open(passwdFile)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	return parseEphemeralUsers(bufio.NewScanner(file)), nil
+}
+
+// parseEphemeralUsers picks the ephemeral session users out of passwd formatted lines.
+func parseEphemeralUsers(scanner *bufio.Scanner) (users []string) {
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 5 {
+			continue
+		}
+		if isEphemeralUserName(fields[0]) && fields[4] == ephemeralUserComment {
+			users = append(users, fields[0])
+		}
+	}
+	return
+}
+
+// isUserActive returns true if the user owns any running process.
+func isUserActive(log log.T, userName string) bool {
+	_, err := execCommand("pgrep", "-u", userName)
+	return err == nil
+}
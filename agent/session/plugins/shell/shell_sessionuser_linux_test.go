@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build linux
+
+// Package shell implements session shell plugin.
+package shell
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEphemeralUserName(t *testing.T) {
+	name := EphemeralUserName("user-012345678901234567")
+	assert.True(t, isEphemeralUserName(name))
+	assert.True(t, len(name) <= 20)
+	assert.Equal(t, name, EphemeralUserName("user-012345678901234567"))
+	assert.NotEqual(t, name, EphemeralUserName("user-765432109876543210"))
+	assert.False(t, isEphemeralUserName("ssm-user"))
+}
+
+func TestParseEphemeralUsers(t *testing.T) {
+	passwd := strings.Join([]string{
+		"root:x:0:0:root:/root:/bin/bash",
+		"ssm-user:x:1001:1001::/home/ssm-user:/bin/sh",
+		"ssm-s-0123456789ab:x:1002:1002:" + ephemeralUserComment + ":/home/ssm-s-0123456789ab:/bin/sh",
+		"ssm-s-ba9876543210:x:1003:1003:someone else:/home/ssm-s-ba9876543210:/bin/sh",
+		"malformed",
+	}, "\n")
+	users := parseEphemeralUsers(bufio.NewScanner(strings.NewReader(passwd)))
+	assert.Equal(t, []string{"ssm-s-0123456789ab"}, users)
+}
+
+func TestDeleteEphemeralUserRefusesOtherUsers(t *testing.T) {
+	called := false
+	defer func(original func(string, ...string) ([]byte, error)) { execCommand = original }(execCommand)
+	execCommand = func(name string, args ...string) ([]byte, error) {
+		called = true
+		return nil, nil
+	}
+	assert.Error(t, deleteEphemeralUser(log.NewMockLog(), "ssm-user"))
+	assert.False(t, called)
+}
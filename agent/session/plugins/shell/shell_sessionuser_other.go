@@ -0,0 +1,45 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build darwin freebsd netbsd openbsd
+
+// Package shell implements session shell plugin.
+package shell
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// createEphemeralUser fails, ephemeral session users are only supported on Linux and Windows so the session is
+// rejected rather than run as the shared session user.
+func createEphemeralUser(log log.T, userName string) error {
+	return fmt.Errorf("ephemeral session users are not supported on %s", runtime.GOOS)
+}
+
+// deleteEphemeralUser fails, no ephemeral session user is ever created on this platform.
+func deleteEphemeralUser(log log.T, userName string) error {
+	return fmt.Errorf("ephemeral session users are not supported on %s", runtime.GOOS)
+}
+
+// listEphemeralUsers returns no user, no ephemeral session user is ever created on this platform.
+func listEphemeralUsers(log log.T) (users []string, err error) {
+	return nil, nil
+}
+
+// isUserActive returns false, no ephemeral session user is ever created on this platform.
+func isUserActive(log log.T, userName string) bool {
+	return false
+}
@@ -0,0 +1,87 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+//
+// +build windows
+
+// Package shell implements session shell plugin.
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	listEphemeralUsersCmd = "Get-WmiObject -Class Win32_UserAccount -Filter \"LocalAccount=True and Description='%s'\" | ForEach-Object { $_.Name }"
+	noTasksFound          = "No tasks"
+)
+
+var execCommand = func(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// createEphemeralUser creates a local user for a single session, its password is reset again when the shell starts.
+func createEphemeralUser(log log.T, userName string) error {
+	log.Infof("Creating ephemeral session user %s", userName)
+	password, err := u.GeneratePasswordForDefaultUser()
+	if err != nil {
+		return err
+	}
+	if out, err := execCommand("net", "user", userName, password, "/add", "/comment:"+ephemeralUserComment); err != nil {
+		return fmt.Errorf("net user failed: %v, %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// deleteEphemeralUser terminates the remaining processes of the session user, removes it and its profile directory.
+func deleteEphemeralUser(log log.T, userName string) error {
+	if !isEphemeralUserName(userName) {
+		return fmt.Errorf("%s is not an ephemeral session user", userName)
+	}
+	log.Infof("Deleting ephemeral session user %s", userName)
+	execCommand("taskkill", "/F", "/FI", "USERNAME eq "+userName)
+	if out, err := execCommand("net", "user", userName, "/delete"); err != nil {
+		return fmt.Errorf("net user failed: %v, %s", err, strings.TrimSpace(string(out)))
+	}
+	profileDir := filepath.Join(os.Getenv("SystemDrive")+"\\", "Users", userName)
+	if err := os.RemoveAll(profileDir); err != nil {
+		log.Warnf("Failed to remove profile directory %s: %v", profileDir, err)
+	}
+	return nil
+}
+
+// listEphemeralUsers returns the local users created by the agent for sessions.
+func listEphemeralUsers(log log.T) (users []string, err error) {
+	out, err := execCommand(appconfig.PowerShellPluginCommandName, "-Command", fmt.Sprintf(listEphemeralUsersCmd, ephemeralUserComment))
+	if err != nil {
+		return nil, fmt.Errorf("%v, %s", err, strings.TrimSpace(string(out)))
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if name := strings.TrimSpace(line); isEphemeralUserName(name) {
+			users = append(users, name)
+		}
+	}
+	return
+}
+
+// isUserActive returns true if the user owns any running process.
+func isUserActive(log log.T, userName string) bool {
+	out, err := execCommand("tasklist", "/FI", "USERNAME eq "+userName)
+	return err == nil && !strings.Contains(string(out), noTasksFound)
+}
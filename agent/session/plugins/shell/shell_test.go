@@ -143,7 +143,9 @@ func (suite *ShellTestSuite) TestExecute() {
 
 	stdout, stdin, _ := os.Pipe()
 	stdin.Write(payload)
-	startPty = func(log log.T, isSessionShell bool) (stdin *os.File, stdout *os.File, err error) {
+	var startedReadOnly bool
+	startPty = func(log log.T, isSessionShell bool, runAsUser string, readOnly bool) (stdin *os.File, stdout *os.File, err error) {
+		startedReadOnly = readOnly
 		return stdin, stdout, nil
 	}
 	plugin := &ShellPlugin{
@@ -152,13 +154,14 @@ func (suite *ShellTestSuite) TestExecute() {
 	}
 
 	plugin.Execute(suite.mockContext,
-		contracts.Configuration{},
+		contracts.Configuration{ReadOnlySession: true},
 		suite.mockCancelFlag,
 		suite.mockIohandler,
 		suite.mockDataChannel)
 
 	suite.mockCancelFlag.AssertExpectations(suite.T())
 	suite.mockIohandler.AssertExpectations(suite.T())
+	assert.True(suite.T(), startedReadOnly)
 
 	stdin.Close()
 	stdout.Close()
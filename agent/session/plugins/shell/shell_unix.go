@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
@@ -39,35 +40,40 @@ const (
 	startRecordSessionCmd = "script"
 	newLineCharacter      = "\n"
 	screenBufferSizeCmd   = "screen -h %d%s"
-	homeEnvVariable       = "HOME=/home/" + appconfig.DefaultRunAsUserName
+	homeEnvVariable       = "HOME="
+	defaultHomeRoot       = "/home/"
 )
 
-var getUserAndGroupIdCall = func(log log.T) (uid int, gid int, err error) {
-	return getUserAndGroupId(log)
+var getUserAndGroupIdCall = func(log log.T, userName string) (uid int, gid int, err error) {
+	return getUserAndGroupId(log, userName)
 }
 
 //StartPty starts pty and provides handles to stdin and stdout
-func StartPty(log log.T, isSessionShell bool) (stdin *os.File, stdout *os.File, err error) {
+//When isSessionShell is true, the shell is started as runAsUser, with a read-only view of the filesystem when readOnly is true.
+func StartPty(log log.T, isSessionShell bool, runAsUser string, readOnly bool) (stdin *os.File, stdout *os.File, err error) {
 	log.Info("Starting pty")
+	if runAsUser == "" {
+		runAsUser = appconfig.DefaultRunAsUserName
+	}
 	//Start the command with a pty
-	cmd := exec.Command("sh")
-
-	//TERM is set as linux by pty which has an issue where vi editor screen does not get cleared.
-	//Setting TERM as xterm-256color as used by standard terminals to fix this issue
-	cmd.Env = append(os.Environ(),
-		termEnvVariable,
-		homeEnvVariable,
-	)
+	cmd := newShellCommand(log, runAsUser, runtime.GOOS)
 
 	// Get the uid and gid of the runas user.
 	if isSessionShell {
 		log.Info("Starting pty")
-		uid, gid, err := getUserAndGroupIdCall(log)
+		uid, gid, err := getUserAndGroupIdCall(log, runAsUser)
 		if err != nil {
 			return nil, nil, err
 		}
-		cmd.SysProcAttr = &syscall.SysProcAttr{}
-		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		if readOnly {
+			// the mounts are made read-only as root before the shell drops to the user
+			if cmd, err = newReadOnlyShellCommand(cmd, uid, gid); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+			cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		}
 	}
 
 	ptyFile, err = pty.Start(cmd)
@@ -102,31 +108,31 @@ func SetSize(log log.T, ws_col, ws_row uint32) (err error) {
 }
 
 //getUserAndGroupId returns the uid and gid of the runas user.
-func getUserAndGroupId(log log.T) (uid int, gid int, err error) {
-	shellCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("id -u %s", appconfig.DefaultRunAsUserName))
+func getUserAndGroupId(log log.T, userName string) (uid int, gid int, err error) {
+	shellCmdArgs := append(ShellPluginCommandArgs, fmt.Sprintf("id -u %s", userName))
 	cmd := exec.Command(ShellPluginCommandName, shellCmdArgs...)
 	out, err := cmd.Output()
 	if err != nil {
-		log.Errorf("Failed retrieve uid for %s: %v", appconfig.DefaultRunAsUserName, err)
+		log.Errorf("Failed retrieve uid for %s: %v", userName, err)
 		return
 	}
 
 	u, err := strconv.Atoi(strings.TrimSpace(string(out)))
 	if err != nil {
-		log.Errorf("%s not found: %v", appconfig.DefaultRunAsUserName, err)
+		log.Errorf("%s not found: %v", userName, err)
 	}
 
-	shellCmdArgs = append(ShellPluginCommandArgs, fmt.Sprintf("id -g %s", appconfig.DefaultRunAsUserName))
+	shellCmdArgs = append(ShellPluginCommandArgs, fmt.Sprintf("id -g %s", userName))
 	cmd = exec.Command(ShellPluginCommandName, shellCmdArgs...)
 	out, err = cmd.Output()
 	if err != nil {
-		log.Errorf("Failed retrieve gid for %s: %v", appconfig.DefaultRunAsUserName, err)
+		log.Errorf("Failed retrieve gid for %s: %v", userName, err)
 		return
 	}
 
 	g, err := strconv.Atoi(strings.TrimSpace(string(out)))
 	if err != nil {
-		log.Errorf("%s not found: %v", appconfig.DefaultRunAsUserName, err)
+		log.Errorf("%s not found: %v", userName, err)
 	}
 
 	// Make sure they are non-zero valid positive ids
@@ -138,7 +144,7 @@ func getUserAndGroupId(log log.T) (uid int, gid int, err error) {
 
 // generateLogData generates a log file with the executed commands.
 func (p *ShellPlugin) generateLogData(log log.T, config agentContracts.Configuration) error {
-	shadowShellInput, _, err := StartPty(log, false)
+	shadowShellInput, _, err := StartPty(log, false, "", false)
 	if err != nil {
 		return err
 	}
@@ -18,6 +18,7 @@ package shell
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -54,8 +55,18 @@ const (
 	screenBufferSizeCmd    = "$host.UI.RawUI.BufferSize = New-Object System.Management.Automation.Host.Size($host.UI.RawUI.BufferSize.Width,%d)%s"
 	logon32LogonNetwork    = uintptr(3)
 	logon32ProviderDefault = uintptr(0)
+	disableMaxPrivilege    = uintptr(0x1)
+	tokenIntegrityLevel    = uintptr(25)
+	seGroupIntegrity       = uint32(0x20)
+	lowIntegritySid        = "S-1-16-4096"
 )
 
+// tokenMandatoryLabel is the TOKEN_MANDATORY_LABEL structure setting the integrity level of a token
+type tokenMandatoryLabel struct {
+	Sid        *syscall.SID
+	Attributes uint32
+}
+
 var (
 	advapi32          = syscall.NewLazyDLL("advapi32.dll")
 	logonProc         = advapi32.NewProc("LogonUserW")
@@ -65,22 +76,31 @@ var (
 	winptyDllFilePath = filepath.Join(winptyDllDir, winptyDllName)
 )
 
+var (
+	createRestrictedTokenProc = advapi32.NewProc("CreateRestrictedToken")
+	setTokenInformationProc   = advapi32.NewProc("SetTokenInformation")
+)
+
 //StartPty starts winpty agent and provides handles to stdin and stdout.
-func StartPty(log log.T, isSessionShell bool) (stdin *os.File, stdout *os.File, err error) {
+//When isSessionShell is true, winpty is started as runAsUser, with a restricted low integrity token when readOnly is true.
+func StartPty(log log.T, isSessionShell bool, runAsUser string, readOnly bool) (stdin *os.File, stdout *os.File, err error) {
 	log.Info("Starting winpty")
 	if _, err := os.Stat(winptyDllFilePath); os.IsNotExist(err) {
 		return nil, nil, fmt.Errorf("Missing %s file.", winptyDllFilePath)
 	}
 
 	if isSessionShell {
-		// Reset password for default ssm user
+		if runAsUser == "" {
+			runAsUser = appconfig.DefaultRunAsUserName
+		}
+		// Reset password for the runas user
 		var newPassword string
 		newPassword, err = u.GeneratePasswordForDefaultUser()
 		if err != nil {
 			return nil, nil, err
 		}
-		if err = u.ChangePassword(appconfig.DefaultRunAsUserName, newPassword); err != nil {
-			log.Errorf("Failed to generate new password for %s: %v", appconfig.DefaultRunAsUserName, err)
+		if err = u.ChangePassword(runAsUser, newPassword); err != nil {
+			log.Errorf("Failed to generate new password for %s: %v", runAsUser, err)
 			return
 		}
 
@@ -88,10 +108,13 @@ func StartPty(log log.T, isSessionShell bool) (stdin *os.File, stdout *os.File,
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err = startPtyAsUser(log, appconfig.DefaultRunAsUserName, newPassword)
+			err = startPtyAsUser(log, runAsUser, newPassword, readOnly)
 		}()
 		wg.Wait()
 	} else {
+		if readOnly {
+			return nil, nil, errors.New("read-only sessions require a session shell")
+		}
 		pty, err = winpty.Start(winptyDllFilePath, winptyCmd, defaultConsoleCol, defaultConsoleRow, winpty.DEFAULT_WINPTY_FLAGS)
 	}
 
@@ -122,12 +145,12 @@ func SetSize(log log.T, ws_col, ws_row uint32) (err error) {
 }
 
 //startPtyAsUser starts a winpty process in runas user context.
-func startPtyAsUser(log log.T, user string, pass string) (err error) {
+func startPtyAsUser(log log.T, user string, pass string, readOnly bool) (err error) {
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	log.Debugf("Impersonating %s", appconfig.DefaultRunAsUserName)
-	if err = impersonate(log, user, pass); err != nil {
+	log.Debugf("Impersonating %s", user)
+	if err = impersonate(log, user, pass, readOnly); err != nil {
 		log.Error(err)
 		return
 	}
@@ -148,13 +171,23 @@ func startPtyAsUser(log log.T, user string, pass string) (err error) {
 }
 
 //impersonate attempts to impersonate the user.
-func impersonate(log log.T, user string, pass string) error {
+//When readOnly is true, the user is impersonated with a restricted low integrity token.
+func impersonate(log log.T, user string, pass string, readOnly bool) error {
 	token, err := logonUser(user, pass)
 	if err != nil {
 		return err
 	}
 	defer mustCloseHandle(log, token)
 
+	if readOnly {
+		restricted, err := restrictToken(log, token)
+		if err != nil {
+			return fmt.Errorf("failed to restrict the session token: %v", err)
+		}
+		defer mustCloseHandle(log, restricted)
+		token = restricted
+	}
+
 	if rc, _, ec := syscall.Syscall(impersonateProc.Addr(), 1, uintptr(token), 0, 0); rc == 0 {
 		return error(ec)
 	}
@@ -186,6 +219,36 @@ func logonUser(user, pass string) (token syscall.Handle, err error) {
 	return
 }
 
+//restrictToken returns a copy of the token without its privileges and at low integrity level.
+//The mandatory integrity policy denies a low integrity process to write the files, folders and registry keys of
+//higher integrity, which are all of them except the few locations labelled low, such as LocalLow.
+func restrictToken(log log.T, token syscall.Handle) (restricted syscall.Handle, err error) {
+	if rc, _, ec := syscall.Syscall9(createRestrictedTokenProc.Addr(), 9,
+		uintptr(token),
+		disableMaxPrivilege,
+		0, 0, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&restricted))); rc == 0 {
+		return 0, error(ec)
+	}
+
+	sid, err := syscall.StringToSid(lowIntegritySid)
+	if err != nil {
+		mustCloseHandle(log, restricted)
+		return 0, err
+	}
+	label := tokenMandatoryLabel{Sid: sid, Attributes: seGroupIntegrity}
+	if rc, _, ec := syscall.Syscall6(setTokenInformationProc.Addr(), 4,
+		uintptr(restricted),
+		tokenIntegrityLevel,
+		uintptr(unsafe.Pointer(&label)),
+		unsafe.Sizeof(label)+uintptr(syscall.GetLengthSid(sid)),
+		0, 0); rc == 0 {
+		mustCloseHandle(log, restricted)
+		return 0, error(ec)
+	}
+	return restricted, nil
+}
+
 //revertToSelf reverts the impersonation process.
 func revertToSelf() error {
 	if rc, _, ec := syscall.Syscall(revertSelfProc.Addr(), 0, 0, 0, 0); rc == 0 {
@@ -242,7 +305,7 @@ func (p *ShellPlugin) generateLogData(log log.T, config agentContracts.Configura
 
 // generateTranscriptFile generates a transcript file using PowerShell
 func generateTranscriptFile(log log.T, transcriptFile string, loggerFile string, enableVirtualTerminalProcessingForWindows bool) error {
-	shadowShellInput, _, err := StartPty(log, false)
+	shadowShellInput, _, err := StartPty(log, false, "", false)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,115 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package reaper terminates the session workers abandoned by their client. A worker whose client disappeared without
+// closing the session holds its memory and its file descriptors until the session times out. Once it exchanged no data
+// for the idle timeout while its client is gone, its state is saved for investigation, the service is told why the
+// session ends and the worker is stopped.
+package reaper
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/session/datachannel"
+)
+
+// SnapshotFileName is the file of the orchestration directory of the session the state of a reaped worker is saved to
+const SnapshotFileName = "idle-snapshot.json"
+
+// checkInterval is how often the activity of the session is checked, replaced in tests
+var checkInterval = 30 * time.Second
+
+// Snapshot is the state of a reaped worker
+type Snapshot struct {
+	SessionId   string
+	Reason      mgsContracts.TerminationReason
+	LastData    time.Time
+	ReapedAt    time.Time
+	IdleTimeout string
+	// State is the state of the worker itself, such as the user and the log of a shell
+	State interface{} `json:",omitempty"`
+}
+
+// Watch checks the activity of the session until stop is called. Once the worker exchanged no data for the timeout while
+// its client is gone, the state returned by snapshot is saved to the directory, the service is told the session
+// terminates because it is idle, and terminate is called.
+func Watch(log log.T,
+	sessionId string,
+	dataChannel datachannel.IDataChannel,
+	timeout time.Duration,
+	directory string,
+	snapshot func() interface{},
+	terminate func()) (stop func()) {
+
+	stopped := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ticker.C:
+				lastData, clientGone := dataChannel.Activity()
+				if !clientGone || time.Since(lastData) < timeout {
+					continue
+				}
+				log.Warnf("Session %s exchanged no data since %v and its client is gone, terminating its worker", sessionId, lastData)
+				reap(log, sessionId, dataChannel, timeout, directory, lastData, snapshot)
+				terminate()
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(stopped) })
+	}
+}
+
+// reap saves the state of the worker and tells the service why the session terminates
+func reap(log log.T,
+	sessionId string,
+	dataChannel datachannel.IDataChannel,
+	timeout time.Duration,
+	directory string,
+	lastData time.Time,
+	snapshot func() interface{}) {
+
+	state := Snapshot{
+		SessionId:   sessionId,
+		Reason:      mgsContracts.TerminationReasonIdle,
+		LastData:    lastData,
+		ReapedAt:    time.Now(),
+		IdleTimeout: timeout.String(),
+	}
+	if snapshot != nil {
+		state.State = snapshot()
+	}
+	if content, err := json.MarshalIndent(state, "", "  "); err != nil {
+		log.Errorf("Failed to serialize the state of the idle worker of session %s: %v", sessionId, err)
+	} else if _, err = fileutil.WriteIntoFileWithPermissions(filepath.Join(directory, SnapshotFileName), string(content), appconfig.ReadWriteAccess); err != nil {
+		log.Errorf("Failed to save the state of the idle worker of session %s: %v", sessionId, err)
+	}
+
+	if err := dataChannel.SendAgentSessionTerminatingMessage(log, mgsContracts.TerminationReasonIdle); err != nil {
+		log.Errorf("Unable to send AgentSessionState message with termination reason %s. %v", mgsContracts.TerminationReasonIdle, err)
+	}
+}
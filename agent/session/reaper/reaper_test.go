@@ -0,0 +1,76 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package reaper
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	mgsContracts "github.com/aws/amazon-ssm-agent/agent/session/contracts"
+	dataChannelMock "github.com/aws/amazon-ssm-agent/agent/session/datachannel/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWatchReapsIdleWorkerWithoutClient(t *testing.T) {
+	checkInterval = 10 * time.Millisecond
+	defer func() { checkInterval = 30 * time.Second }()
+	directory, _ := ioutil.TempDir("", "reaper")
+	defer os.RemoveAll(directory)
+	lastData := time.Now().Add(-time.Hour)
+	dataChannel := &dataChannelMock.IDataChannel{}
+	dataChannel.On("Activity").Return(lastData, true)
+	dataChannel.On("SendAgentSessionTerminatingMessage", mock.Anything, mgsContracts.TerminationReasonIdle).Return(nil)
+	terminated := make(chan bool, 1)
+
+	stop := Watch(log.NewMockLog(), "session-1", dataChannel, time.Minute, directory,
+		func() interface{} { return map[string]string{"RunAsUser": "ssm-user"} },
+		func() { terminated <- true })
+	defer stop()
+
+	select {
+	case <-terminated:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "the idle worker was not terminated")
+	}
+	dataChannel.AssertExpectations(t)
+	content, err := ioutil.ReadFile(filepath.Join(directory, SnapshotFileName))
+	assert.NoError(t, err)
+	var snapshot Snapshot
+	assert.NoError(t, json.Unmarshal(content, &snapshot))
+	assert.Equal(t, "session-1", snapshot.SessionId)
+	assert.Equal(t, mgsContracts.TerminationReasonIdle, snapshot.Reason)
+	assert.Equal(t, lastData.Unix(), snapshot.LastData.Unix())
+	assert.Equal(t, map[string]interface{}{"RunAsUser": "ssm-user"}, snapshot.State)
+}
+
+func TestWatchKeepsWorkerWithClient(t *testing.T) {
+	checkInterval = 10 * time.Millisecond
+	defer func() { checkInterval = 30 * time.Second }()
+	dataChannel := &dataChannelMock.IDataChannel{}
+	dataChannel.On("Activity").Return(time.Now().Add(-time.Hour), false)
+	terminated := make(chan bool, 1)
+
+	stop := Watch(log.NewMockLog(), "session-1", dataChannel, time.Minute, "", nil, func() { terminated <- true })
+	time.Sleep(100 * time.Millisecond)
+	stop()
+
+	assert.Len(t, terminated, 0)
+	dataChannel.AssertNotCalled(t, "SendAgentSessionTerminatingMessage", mock.Anything, mock.Anything)
+}
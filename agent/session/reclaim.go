@@ -0,0 +1,35 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package session
+
+import (
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/framework/processor/executer/outofproc/channel"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/session/plugins/shell"
+)
+
+// ReclaimStaleResources cleans up what the sessions and documents of an agent that did not exit cleanly left
+// behind: the ipc sockets that block their channels from listening again and the ephemeral session users without
+// running processes. It runs when the agent starts, before the core modules create any channel.
+func ReclaimStaleResources(log log.T) {
+	if sockets := channel.RemoveStaleSockets(log); len(sockets) > 0 {
+		log.Infof("Reclaimed %v stale ipc sockets of an earlier run: %v", len(sockets), strings.Join(sockets, ", "))
+	}
+	// the users are looked for even when ephemeral session users are disabled, they may have been enabled before
+	if users := shell.CleanupEphemeralUsers(log); len(users) > 0 {
+		log.Infof("Reclaimed %v stale ephemeral session users of an earlier run: %v", len(users), strings.Join(users, ", "))
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sessionhook implements the local authorization hooks invoked before a session is started.
+package sessionhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// JustificationParameterName is the session document parameter carrying the operator provided justification.
+	JustificationParameterName = "justification"
+
+	// maxHookOutputLength bounds the hook output included in the rejection reason.
+	maxHookOutputLength = 1024
+)
+
+// SessionInfo is the session metadata handed over to the hooks.
+type SessionInfo struct {
+	SessionId  string                 `json:"sessionId"`
+	ClientId   string                 `json:"clientId"`
+	InstanceId string                 `json:"instanceId"`
+	RunAsUser  string                 `json:"runAsUser"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+// Hook authorizes the start of a session, a non nil error rejects the session.
+type Hook interface {
+	Authorize(log log.T, info SessionInfo) error
+}
+
+// NewHook builds the hook chain from the agent configuration, it returns nil if no hook is configured.
+func NewHook(config appconfig.SessionStartHookCfg) Hook {
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	var hooks chain
+	if config.RequireJustification {
+		hooks = append(hooks, justificationHook{})
+	}
+	if config.ScriptPath != "" {
+		hooks = append(hooks, scriptHook{path: config.ScriptPath, timeout: timeout})
+	}
+	if config.WebhookUrl != "" {
+		hooks = append(hooks, webhookHook{url: config.WebhookUrl, client: &http.Client{Timeout: timeout}})
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	return hooks
+}
+
+// chain runs the hooks in order and stops at the first rejection.
+type chain []Hook
+
+// Authorize implements Hook.
+func (c chain) Authorize(log log.T, info SessionInfo) error {
+	for _, hook := range c {
+		if err := hook.Authorize(log, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// justificationHook rejects sessions that were not started with a justification.
+type justificationHook struct{}
+
+// Authorize implements Hook.
+func (justificationHook) Authorize(log log.T, info SessionInfo) error {
+	if value, ok := info.Parameters[JustificationParameterName]; ok {
+		if justification, ok := value.(string); ok && strings.TrimSpace(justification) != "" {
+			log.Infof("Session %s justification: %s", info.SessionId, justification)
+			return nil
+		}
+	}
+	return fmt.Errorf("session must be started with a non-empty %s parameter", JustificationParameterName)
+}
+
+// scriptHook runs a local executable with the session info as json on stdin, exit code 0 approves the session.
+type scriptHook struct {
+	path    string
+	timeout time.Duration
+}
+
+// Authorize implements Hook.
+func (h scriptHook) Authorize(log log.T, info SessionInfo) error {
+	input, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(h.path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start session hook %s: %v", h.path, err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err = <-done:
+	case <-time.After(h.timeout):
+		cmd.Process.Kill()
+		<-done
+		return fmt.Errorf("session hook %s timed out after %v", h.path, h.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("session rejected by hook %s: %s", h.path, truncate(output.String()))
+	}
+	log.Debugf("Session %s approved by hook %s", info.SessionId, h.path)
+	return nil
+}
+
+// webhookHook posts the session info as json to an url, a 2xx response approves the session.
+type webhookHook struct {
+	url    string
+	client *http.Client
+}
+
+// Authorize implements Hook.
+func (h webhookHook) Authorize(log log.T, info SessionInfo) error {
+	input, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(input))
+	if err != nil {
+		return fmt.Errorf("session approval request to %s failed: %v", h.url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("session rejected by %s with status %d: %s", h.url, resp.StatusCode, truncate(string(body)))
+	}
+	log.Debugf("Session %s approved by %s", info.SessionId, h.url)
+	return nil
+}
+
+func truncate(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxHookOutputLength {
+		return output[:maxHookOutputLength]
+	}
+	return output
+}
@@ -0,0 +1,76 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package sessionhook implements the local authorization hooks invoked before a session is started.
+package sessionhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+func TestNewHookNotConfigured(t *testing.T) {
+	assert.Nil(t, NewHook(appconfig.SessionStartHookCfg{TimeoutSeconds: 30}))
+}
+
+func TestJustificationHook(t *testing.T) {
+	hook := NewHook(appconfig.SessionStartHookCfg{RequireJustification: true, TimeoutSeconds: 30})
+	assert.Error(t, hook.Authorize(logger, SessionInfo{SessionId: "s1"}))
+	assert.Error(t, hook.Authorize(logger, SessionInfo{SessionId: "s1", Parameters: map[string]interface{}{JustificationParameterName: "  "}}))
+	assert.NoError(t, hook.Authorize(logger, SessionInfo{SessionId: "s1", Parameters: map[string]interface{}{JustificationParameterName: "INC-1234"}}))
+}
+
+func TestWebhookHook(t *testing.T) {
+	var received SessionInfo
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		if received.SessionId != "approved" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("no approval found"))
+		}
+	}))
+	defer server.Close()
+
+	hook := NewHook(appconfig.SessionStartHookCfg{WebhookUrl: server.URL, TimeoutSeconds: 5})
+	assert.NoError(t, hook.Authorize(logger, SessionInfo{SessionId: "approved", RunAsUser: "ssm-user"}))
+	assert.Equal(t, "ssm-user", received.RunAsUser)
+
+	err := hook.Authorize(logger, SessionInfo{SessionId: "denied"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no approval found")
+}
+
+func TestChainStopsAtFirstRejection(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	hook := NewHook(appconfig.SessionStartHookCfg{RequireJustification: true, WebhookUrl: server.URL, TimeoutSeconds: 5})
+	assert.Error(t, hook.Authorize(logger, SessionInfo{SessionId: "s1"}))
+	assert.False(t, called)
+}
+
+func TestScriptHookMissingScript(t *testing.T) {
+	hook := NewHook(appconfig.SessionStartHookCfg{ScriptPath: "/path/does/not/exist", TimeoutSeconds: 5})
+	assert.Error(t, hook.Authorize(logger, SessionInfo{SessionId: "s1"}))
+}
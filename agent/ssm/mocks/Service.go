@@ -118,6 +118,29 @@ func (_m *Service) DescribeAssociation(_a0 log.T, instanceID string, docName str
 	return r0, r1
 }
 
+// DescribeDocument provides a mock function with given fields: _a0, docName, docVersion
+func (_m *Service) DescribeDocument(_a0 log.T, docName string, docVersion string) (*ssm.DescribeDocumentOutput, error) {
+	ret := _m.Called(_a0, docName, docVersion)
+
+	var r0 *ssm.DescribeDocumentOutput
+	if rf, ok := ret.Get(0).(func(log.T, string, string) *ssm.DescribeDocumentOutput); ok {
+		r0 = rf(_a0, docName, docVersion)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ssm.DescribeDocumentOutput)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(log.T, string, string) error); ok {
+		r1 = rf(_a0, docName, docVersion)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDecryptedParameters provides a mock function with given fields: _a0, paramNames
 func (_m *Service) GetDecryptedParameters(_a0 log.T, paramNames []string) (*ssm.GetParametersOutput, error) {
 	ret := _m.Called(_a0, paramNames)
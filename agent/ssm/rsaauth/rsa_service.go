@@ -19,7 +19,6 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/ssm/util"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
 	"github.com/aws/aws-sdk-go/service/ssm"
@@ -46,7 +45,7 @@ func NewRsaService(serverId string, region string, encodedPrivateKey string) Rsa
 	appConfig, _ := appconfig.Config(false)
 	// Create a session to share service client config and handlers with
 	ssmSess, _ := session.NewSession(awsConfig)
-	ssmSess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	ssmSess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	ssmService := ssm.New(ssmSess)
 
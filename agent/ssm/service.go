@@ -14,7 +14,6 @@
 package ssm
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -22,10 +21,10 @@ import (
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
@@ -66,6 +65,7 @@ type Service interface {
 	CancelCommand(log log.T, commandID string, instanceIDs []string) (response *ssm.CancelCommandOutput, err error)
 	CreateDocument(log log.T, docName string, docContent string) (response *ssm.CreateDocumentOutput, err error)
 	GetDocument(log log.T, docName string, docVersion string) (response *ssm.GetDocumentOutput, err error)
+	DescribeDocument(log log.T, docName string, docVersion string) (response *ssm.DescribeDocumentOutput, err error)
 	DeleteDocument(log log.T, instanceID string) (response *ssm.DeleteDocumentOutput, err error)
 	DescribeAssociation(log log.T, instanceID string, docName string) (response *ssm.DescribeAssociationOutput, err error)
 	UpdateInstanceInformation(log log.T, agentVersion, agentStatus, agentName string) (response *ssm.UpdateInstanceInformationOutput, err error)
@@ -108,14 +108,17 @@ func NewService() Service {
 		// TODO: test hook, can be removed before release
 		// this is to skip ssl verification for the beta self signed certs
 		if appConfig.Ssm.InsecureSkipVerify {
+			tlsConfig := network.GetDefaultTLSConfig()
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyConnection = nil
 			tr := &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				TLSClientConfig: tlsConfig,
 			}
 			awsConfig.HTTPClient = &http.Client{Transport: tr}
 		}
 	}
 	sess := session.New(awsConfig)
-	sess.Handlers.Build.PushBack(request.MakeAddToUserAgentHandler(appConfig.Agent.Name, appConfig.Agent.Version))
+	sess.Handlers.Build.PushBack(appconfig.MakeUserAgentHandler(appConfig.Agent))
 
 	ssmService := ssm.New(sess)
 	return NewSSMService(ssmService)
@@ -362,6 +365,25 @@ func (svc *sdkService) GetDocument(log log.T, docName string, docVersion string)
 	return
 }
 
+//DescribeDocument calls the DescribeDocument SSM API to retrieve the version and the hash of the document with given document name
+func (svc *sdkService) DescribeDocument(log log.T, docName string, docVersion string) (response *ssm.DescribeDocumentOutput, err error) {
+	params := ssm.DescribeDocumentInput{
+		Name: aws.String(docName),
+	}
+
+	if docVersion != "" {
+		params.DocumentVersion = aws.String(docVersion)
+	}
+
+	response, err = svc.sdk.DescribeDocument(&params)
+	if err != nil {
+		sdkutil.HandleAwsError(log, err, ssmStopPolicy)
+		return
+	}
+	log.Debug("DescribeDocument Response", response)
+	return
+}
+
 //DescribeAssociation calls the DescribeAssociation SSM API to retrieve parameters information
 func (svc *sdkService) DescribeAssociation(log log.T, instanceID string, docName string) (response *ssm.DescribeAssociationOutput, err error) {
 	params := ssm.DescribeAssociationInput{
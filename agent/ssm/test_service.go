@@ -109,6 +109,12 @@ func (m *Mock) GetDocument(log log.T, docName string, docVersion string) (respon
 	return args.Get(0).(*ssm.GetDocumentOutput), args.Error(1)
 }
 
+// DescribeDocument mocks the DescribeDocument function.
+func (m *Mock) DescribeDocument(log log.T, docName string, docVersion string) (response *ssm.DescribeDocumentOutput, err error) {
+	args := m.Called(log, docName, docVersion)
+	return args.Get(0).(*ssm.DescribeDocumentOutput), args.Error(1)
+}
+
 // DeleteDocument mocks the DeleteDocument function.
 func (m *Mock) DeleteDocument(log log.T, instanceID string) (response *ssm.DeleteDocumentOutput, err error) {
 	args := m.Called(log, instanceID)
@@ -15,11 +15,11 @@
 package util
 
 import (
-	"crypto/tls"
 	"net/http"
 	"time"
 
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/sdkutil/retryer"
 	"github.com/aws/aws-sdk-go/aws"
@@ -52,8 +52,11 @@ func AwsConfig() *aws.Config {
 	// TODO: test hook, can be removed before release
 	// this is to skip ssl verification for the beta self signed certs
 	if appConfig.Ssm.InsecureSkipVerify {
+		tlsConfig := network.GetDefaultTLSConfig()
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyConnection = nil
 		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig: tlsConfig,
 		}
 		awsConfig.HTTPClient = &http.Client{Transport: tr}
 	}
@@ -0,0 +1,188 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package endpoint implements the local status endpoint of the agent, an HTTP server on a unix socket or a named pipe
+// that node-level monitoring agents can scrape instead of parsing the logs of the agent.
+package endpoint
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/amazon-ssm-agent/agent/status"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	name = "StatusEndpoint"
+
+	// HealthHealthy means the agent reached MDS or MGS recently
+	HealthHealthy = "Healthy"
+	// HealthStarting means the agent has not reached MDS or MGS yet since it started
+	HealthStarting = "Starting"
+	// HealthDisconnected means the agent has not reached MDS or MGS for longer than the stale contact threshold
+	HealthDisconnected = "Disconnected"
+
+	// requestTimeout bounds the time spent on a connection on Linux and macOS, a scraper that stops reading or writing
+	// can not hold a connection open. Named pipes do not support deadlines.
+	requestTimeout = 30 * time.Second
+)
+
+// Report is the state of the agent returned by the status endpoint.
+type Report struct {
+	AgentVersion       string                      `json:"agentVersion"`
+	Health             string                      `json:"health"`
+	StartTime          time.Time                   `json:"startTime"`
+	UptimeSeconds      int64                       `json:"uptimeSeconds"`
+	LastContact        map[string]time.Time        `json:"lastContact"`
+	InFlightExecutions []status.Execution          `json:"inFlightExecutions"`
+	Packages           []model.SSMPackageData      `json:"packages"`
+	ThrottledApis      []throttle.APIThrottling    `json:"throttledApis"`
+	Uploads            []uploadqueue.PriorityStats `json:"uploads"`
+}
+
+// healthReport is the summary returned by the health path of the endpoint
+type healthReport struct {
+	AgentVersion string `json:"agentVersion"`
+	Health       string `json:"health"`
+}
+
+// Endpoint is the core module serving the status endpoint.
+type Endpoint struct {
+	context   context.T
+	startTime time.Time
+	listener  net.Listener
+}
+
+// decoupling package repository and listen for easy testability
+var packageRepository = localpackages.NewRepository()
+var listenFunc = listen
+
+// NewEndpoint creates a new status endpoint core module.
+func NewEndpoint(context context.T) *Endpoint {
+	return &Endpoint{
+		context:   context.With("[" + name + "]"),
+		startTime: time.Now(),
+	}
+}
+
+// ICoreModule implementation
+
+// ModuleName returns the module name
+func (e *Endpoint) ModuleName() string {
+	return name
+}
+
+// ModuleExecute starts serving the status endpoint when it is enabled
+func (e *Endpoint) ModuleExecute(context context.T) (err error) {
+	log := e.context.Log()
+	config := e.context.AppConfig().Status
+	if !config.Enabled {
+		log.Debug("Status endpoint is disabled")
+		return nil
+	}
+
+	if e.listener, err = listenFunc(config.Path); err != nil {
+		log.Errorf("Failed to listen on %v: %v", config.Path, err)
+		return err
+	}
+	log.Infof("Serving the status endpoint on %v", config.Path)
+	go serve(log, e.listener, e.handler())
+	return nil
+}
+
+// ModuleRequestStop stops serving the status endpoint
+func (e *Endpoint) ModuleRequestStop(stopType contracts.StopType) (err error) {
+	if e.listener != nil {
+		e.context.Log().Info("Stopping the status endpoint")
+		return e.listener.Close()
+	}
+	return nil
+}
+
+// handler routes the requests of the endpoint, /status returns the full report and /health the health of the agent
+// with the 503 status code when it is disconnected, so that it can be used as a liveness probe
+func (e *Endpoint) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJson(e.context.Log(), w, http.StatusOK, e.report(time.Now()))
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		health := e.health(status.LastContacts(), time.Now())
+		statusCode := http.StatusOK
+		if health == HealthDisconnected {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeJson(e.context.Log(), w, statusCode, healthReport{AgentVersion: version.Version, Health: health})
+	})
+	return mux
+}
+
+// report collects the state of the agent
+func (e *Endpoint) report(now time.Time) Report {
+	contacts := status.LastContacts()
+	return Report{
+		AgentVersion:       version.Version,
+		Health:             e.health(contacts, now),
+		StartTime:          e.startTime,
+		UptimeSeconds:      int64(now.Sub(e.startTime) / time.Second),
+		LastContact:        contacts,
+		InFlightExecutions: status.InFlightExecutions(),
+		Packages:           packageRepository.GetPackageInventoryData(e.context.Log()),
+		ThrottledApis:      throttle.APIThrottlingStats(),
+		Uploads:            uploadqueue.Stats(),
+	}
+}
+
+// health returns the health of the agent given its last contacts with MDS and MGS
+func (e *Endpoint) health(contacts map[string]time.Time, now time.Time) string {
+	staleAfter := time.Duration(e.context.AppConfig().Status.StaleContactSeconds) * time.Second
+	for _, at := range contacts {
+		if now.Sub(at) <= staleAfter {
+			return HealthHealthy
+		}
+	}
+	if len(contacts) == 0 && now.Sub(e.startTime) <= staleAfter {
+		return HealthStarting
+	}
+	return HealthDisconnected
+}
+
+func writeJson(log log.T, w http.ResponseWriter, statusCode int, body interface{}) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		log.Errorf("Failed to serialize the status: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(content)
+}
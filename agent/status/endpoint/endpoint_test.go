@@ -0,0 +1,147 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package endpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/context"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	repomock "github.com/aws/amazon-ssm-agent/agent/plugins/configurepackage/localpackages/mock"
+	"github.com/aws/amazon-ssm-agent/agent/plugins/inventory/model"
+	"github.com/aws/amazon-ssm-agent/agent/status"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newEndpoint(startTime time.Time) *Endpoint {
+	config := appconfig.DefaultConfig()
+	config.Status.Enabled = true
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(config)
+	return &Endpoint{context: ctx, startTime: startTime}
+}
+
+// get sends a GET request for path to handler through serveConn and returns the response
+func get(t *testing.T, handler http.Handler, path string) (*http.Response, []byte) {
+	client, server := net.Pipe()
+	go serveConn(log.NewMockLog(), server, handler)
+
+	request, _ := http.NewRequest(http.MethodGet, "http://localhost"+path, nil)
+	assert.NoError(t, request.Write(client))
+	response, err := http.ReadResponse(bufio.NewReader(client), request)
+	assert.NoError(t, err)
+	var body []byte
+	decoder := json.NewDecoder(response.Body)
+	var raw json.RawMessage
+	if decoder.Decode(&raw) == nil {
+		body = raw
+	}
+	client.Close()
+	return response, body
+}
+
+func TestHealth(t *testing.T) {
+	now := time.Now()
+	staleAfter := time.Duration(appconfig.DefaultStatusEndpointStaleContactSeconds) * time.Second
+
+	testCases := []struct {
+		name      string
+		startTime time.Time
+		contacts  map[string]time.Time
+		expected  string
+	}{
+		{"just started", now.Add(-time.Minute), map[string]time.Time{}, HealthStarting},
+		{"never reached", now.Add(-2 * staleAfter), map[string]time.Time{}, HealthDisconnected},
+		{"recent mds contact", now.Add(-2 * staleAfter), map[string]time.Time{status.ServiceMDS: now.Add(-time.Minute)}, HealthHealthy},
+		{"recent mgs contact", now.Add(-2 * staleAfter), map[string]time.Time{
+			status.ServiceMDS: now.Add(-2 * staleAfter),
+			status.ServiceMGS: now.Add(-time.Minute),
+		}, HealthHealthy},
+		{"stale contact", now.Add(-time.Minute), map[string]time.Time{status.ServiceMDS: now.Add(-2 * staleAfter)}, HealthDisconnected},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, newEndpoint(tc.startTime).health(tc.contacts, now), tc.name)
+	}
+}
+
+func TestStatusPath(t *testing.T) {
+	packages := []model.SSMPackageData{{PackageArn: "AWSPVDriver", Version: "8.2.1", InstallState: "Installed"}}
+	repoMock := new(repomock.MockedRepository)
+	repoMock.On("GetPackageInventoryData", mock.Anything).Return(packages)
+	saved := packageRepository
+	packageRepository = repoMock
+	defer func() { packageRepository = saved }()
+
+	status.ExecutionStarted("commandId", "messageId", "AWS-RunShellScript", "SendCommand")
+	defer status.ExecutionEnded("commandId")
+
+	response, body := get(t, newEndpoint(time.Now()).handler(), "/status")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	var report Report
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, version.Version, report.AgentVersion)
+	assert.Equal(t, packages, report.Packages)
+	assert.Len(t, report.InFlightExecutions, 1)
+	assert.Equal(t, "AWS-RunShellScript", report.InFlightExecutions[0].DocumentName)
+	repoMock.AssertExpectations(t)
+}
+
+func TestHealthPath(t *testing.T) {
+	endpoint := newEndpoint(time.Now().Add(-time.Duration(2*appconfig.DefaultStatusEndpointStaleContactSeconds) * time.Second))
+
+	status.RecordContact(status.ServiceMDS)
+	response, body := get(t, endpoint.handler(), "/health")
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	var health healthReport
+	assert.NoError(t, json.Unmarshal(body, &health))
+	assert.Equal(t, HealthHealthy, health.Health)
+	assert.Equal(t, version.Version, health.AgentVersion)
+}
+
+func TestUnknownPath(t *testing.T) {
+	response, _ := get(t, newEndpoint(time.Now()).handler(), "/metrics")
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestModuleExecuteDisabled(t *testing.T) {
+	ctx := new(context.Mock)
+	ctx.On("Log").Return(log.NewMockLog())
+	ctx.On("AppConfig").Return(appconfig.DefaultConfig())
+	endpoint := &Endpoint{context: ctx, startTime: time.Now()}
+
+	listened := false
+	savedListen := listenFunc
+	listenFunc = func(path string) (net.Listener, error) {
+		listened = true
+		return nil, nil
+	}
+	defer func() { listenFunc = savedListen }()
+
+	assert.NoError(t, endpoint.ModuleExecute(ctx))
+	assert.False(t, listened)
+	assert.NoError(t, endpoint.ModuleRequestStop(""))
+}
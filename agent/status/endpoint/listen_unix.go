@@ -0,0 +1,46 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+// Package endpoint implements the local status endpoint of the agent, an HTTP server on a unix socket or a named pipe
+// that node-level monitoring agents can scrape instead of parsing the logs of the agent.
+package endpoint
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// socketFileMode restricts the socket to root, connecting to a unix socket requires write access to it
+const socketFileMode = 0600
+
+// listen listens on the unix socket at path, replacing the socket left behind by a previous agent process
+func listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), appconfig.ReadWriteExecuteAccess); err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(path, socketFileMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build windows
+
+// Package endpoint implements the local status endpoint of the agent, an HTTP server on a unix socket or a named pipe
+// that node-level monitoring agents can scrape instead of parsing the logs of the agent.
+package endpoint
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	pipeAccessDuplex          = 0x3
+	fileFlagFirstPipeInstance = 0x80000
+	pipeTypeByteWait          = 0x0
+	pipeUnlimitedInstances    = 255
+	pipeBufferSize            = 4096
+	errorPipeConnected        = syscall.Errno(535)
+	errorNoData               = syscall.Errno(232)
+	invalidHandleValue        = ^uintptr(0)
+	pipeNetwork               = "pipe"
+)
+
+// Windows APIs
+var (
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipeW    = kernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe    = kernel32.NewProc("ConnectNamedPipe")
+	procDisconnectNamedPipe = kernel32.NewProc("DisconnectNamedPipe")
+)
+
+var errListenerClosed = errors.New("status endpoint pipe is closed")
+
+// pipeListener accepts the connections to a named pipe, the pipe keeps the default security descriptor which
+// only lets SYSTEM, the administrators and the owner write to it, i.e. send requests.
+type pipeListener struct {
+	path   string
+	mu     sync.Mutex
+	handle syscall.Handle
+	closed bool
+}
+
+// pipeConn is a connected instance of the named pipe
+type pipeConn struct {
+	*os.File
+	path string
+}
+
+// pipeAddr is the address of the named pipe
+type pipeAddr string
+
+// listen creates the named pipe at path, it fails if the pipe is already owned by another process
+func listen(path string) (net.Listener, error) {
+	handle, err := createPipe(path, true)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeListener{path: path, handle: handle}, nil
+}
+
+// createPipe creates an instance of the named pipe waiting for a client
+func createPipe(path string, first bool) (syscall.Handle, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return syscall.InvalidHandle, err
+	}
+	openMode := uintptr(pipeAccessDuplex)
+	if first {
+		openMode |= fileFlagFirstPipeInstance
+	}
+	handle, _, err := procCreateNamedPipeW.Call(
+		uintptr(unsafe.Pointer(name)),
+		openMode,
+		pipeTypeByteWait,
+		pipeUnlimitedInstances,
+		pipeBufferSize,
+		pipeBufferSize,
+		0,
+		0)
+	if handle == invalidHandleValue {
+		return syscall.InvalidHandle, err
+	}
+	return syscall.Handle(handle), nil
+}
+
+// Accept waits for a client to connect to the pending instance of the pipe and creates the next instance
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.closeHandle()
+		l.mu.Unlock()
+		return nil, errListenerClosed
+	}
+	handle := l.handle
+	l.mu.Unlock()
+
+	connected, _, err := procConnectNamedPipe.Call(uintptr(handle), 0)
+	for connected == 0 && err == errorNoData {
+		// the client closed its end before the connection was accepted, the instance is reused for the next client
+		procDisconnectNamedPipe.Call(uintptr(handle))
+		connected, _, err = procConnectNamedPipe.Call(uintptr(handle), 0)
+	}
+	if connected == 0 && err != errorPipeConnected {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		l.closeHandle()
+		return nil, errListenerClosed
+	}
+	next, err := createPipe(l.path, false)
+	if err != nil {
+		return nil, err
+	}
+	l.handle = next
+	return &pipeConn{File: os.NewFile(uintptr(handle), l.path), path: l.path}, nil
+}
+
+// Close stops accepting connections, the pending ConnectNamedPipe is released by connecting to the pipe
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	name, err := syscall.UTF16PtrFromString(l.path)
+	if err != nil {
+		return err
+	}
+	client, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE, 0, nil, syscall.OPEN_EXISTING, 0, 0)
+	if err == nil {
+		syscall.CloseHandle(client)
+	}
+	return nil
+}
+
+// Addr returns the address of the named pipe
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr(l.path)
+}
+
+// closeHandle closes the pending instance of the pipe, the caller holds the lock
+func (l *pipeListener) closeHandle() {
+	if l.handle != syscall.InvalidHandle {
+		syscall.CloseHandle(l.handle)
+		l.handle = syscall.InvalidHandle
+	}
+}
+
+// Close flushes the response to the client before disconnecting it and closing the instance of the pipe
+func (c *pipeConn) Close() error {
+	handle := c.Fd()
+	syscall.FlushFileBuffers(syscall.Handle(handle))
+	procDisconnectNamedPipe.Call(handle)
+	return c.File.Close()
+}
+
+// LocalAddr returns the address of the named pipe
+func (c *pipeConn) LocalAddr() net.Addr {
+	return pipeAddr(c.path)
+}
+
+// RemoteAddr returns the address of the named pipe, the clients of a pipe do not have an address
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return pipeAddr(c.path)
+}
+
+func (a pipeAddr) Network() string {
+	return pipeNetwork
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
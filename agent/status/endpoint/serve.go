@@ -0,0 +1,93 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package endpoint
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// acceptRetryDelay is how long the endpoint waits before accepting connections again after a temporary failure,
+// such as running out of file descriptors
+const acceptRetryDelay = time.Second
+
+// serve answers the requests received on the listener until it is closed. Every connection carries a single request,
+// so that reading and writing never overlap on the synchronous named pipes used on Windows.
+func serve(log log.T, listener net.Listener, handler http.Handler) {
+	for {
+		conn, err := listener.Accept()
+		if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+			log.Debugf("Status endpoint failed to accept a connection: %v", err)
+			time.Sleep(acceptRetryDelay)
+			continue
+		} else if err != nil {
+			log.Debugf("Status endpoint stopped accepting connections: %v", err)
+			return
+		}
+		go serveConn(log, conn, handler)
+	}
+}
+
+// serveConn reads a request from conn, writes the response of handler and closes conn
+func serveConn(log log.T, conn net.Conn, handler http.Handler) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	request, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		log.Debugf("Failed to read status endpoint request: %v", err)
+		return
+	}
+	writer := &responseWriter{header: http.Header{}, statusCode: http.StatusOK}
+	handler.ServeHTTP(writer, request)
+
+	response := http.Response{
+		StatusCode:    writer.statusCode,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        writer.header,
+		Body:          ioutil.NopCloser(&writer.body),
+		ContentLength: int64(writer.body.Len()),
+		Close:         true,
+		Request:       request,
+	}
+	if err = response.Write(conn); err != nil {
+		log.Debugf("Failed to write status endpoint response: %v", err)
+	}
+}
+
+// responseWriter buffers the response of a handler so that it is written once the request has been fully handled
+type responseWriter struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) Write(content []byte) (int, error) {
+	return w.body.Write(content)
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
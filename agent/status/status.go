@@ -0,0 +1,141 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package status tracks the state of the agent core reported by the local status endpoint,
+// i.e. the last contact with the services the agent polls and the documents running.
+package status
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ServiceMDS is the message delivery service polled for Run Command documents
+	ServiceMDS = "MDS"
+	// ServiceMGS is the message gateway service the sessions are received from
+	ServiceMGS = "MGS"
+)
+
+// Execution is a document the agent is running.
+type Execution struct {
+	DocumentID   string    `json:"documentId"`
+	MessageID    string    `json:"messageId"`
+	DocumentName string    `json:"documentName"`
+	DocumentType string    `json:"documentType"`
+	StartTime    time.Time `json:"startTime"`
+	// LastStep is the last step reported by the document, empty until the first step completes
+	LastStep string `json:"lastStep,omitempty"`
+}
+
+// tracker records the contacts and the executions, it is shared by the core modules of the agent
+type tracker struct {
+	mu         sync.Mutex
+	contacts   map[string]time.Time
+	executions map[string]*Execution
+}
+
+var agentTracker = newTracker()
+
+func newTracker() *tracker {
+	return &tracker{
+		contacts:   map[string]time.Time{},
+		executions: map[string]*Execution{},
+	}
+}
+
+// RecordContact records a successful call to the given service.
+func RecordContact(service string) {
+	agentTracker.recordContact(service, time.Now())
+}
+
+// LastContacts returns the time of the last successful call to each service, indexed by service.
+func LastContacts() map[string]time.Time {
+	return agentTracker.lastContacts()
+}
+
+// ExecutionStarted records that the agent started running the given document.
+func ExecutionStarted(documentID, messageID, documentName, documentType string) {
+	agentTracker.executionStarted(Execution{
+		DocumentID:   documentID,
+		MessageID:    messageID,
+		DocumentName: documentName,
+		DocumentType: documentType,
+		StartTime:    time.Now(),
+	})
+}
+
+// ExecutionProgressed records the last step reported by a running document.
+func ExecutionProgressed(documentID, step string) {
+	agentTracker.executionProgressed(documentID, step)
+}
+
+// ExecutionEnded records that the agent is no longer running the given document.
+func ExecutionEnded(documentID string) {
+	agentTracker.executionEnded(documentID)
+}
+
+// InFlightExecutions returns the documents the agent is running, oldest first.
+func InFlightExecutions() []Execution {
+	return agentTracker.inFlightExecutions()
+}
+
+func (t *tracker) recordContact(service string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.contacts[service] = at
+}
+
+func (t *tracker) lastContacts() map[string]time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	contacts := make(map[string]time.Time, len(t.contacts))
+	for service, at := range t.contacts {
+		contacts[service] = at
+	}
+	return contacts
+}
+
+func (t *tracker) executionStarted(execution Execution) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.executions[execution.DocumentID] = &execution
+}
+
+func (t *tracker) executionProgressed(documentID, step string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if execution, found := t.executions[documentID]; found {
+		execution.LastStep = step
+	}
+}
+
+func (t *tracker) executionEnded(documentID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.executions, documentID)
+}
+
+func (t *tracker) inFlightExecutions() []Execution {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	executions := make([]Execution, 0, len(t.executions))
+	for _, execution := range t.executions {
+		executions = append(executions, *execution)
+	}
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].StartTime.Before(executions[j].StartTime)
+	})
+	return executions
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackerContacts(t *testing.T) {
+	tracker := newTracker()
+	at := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	tracker.recordContact(ServiceMDS, at)
+	tracker.recordContact(ServiceMDS, at.Add(time.Minute))
+
+	contacts := tracker.lastContacts()
+	assert.Equal(t, map[string]time.Time{ServiceMDS: at.Add(time.Minute)}, contacts)
+
+	// the returned map is a copy
+	contacts[ServiceMGS] = at
+	assert.Len(t, tracker.lastContacts(), 1)
+}
+
+func TestTrackerExecutions(t *testing.T) {
+	tracker := newTracker()
+	start := time.Now()
+	tracker.executionStarted(Execution{DocumentID: "second", StartTime: start.Add(time.Second)})
+	tracker.executionStarted(Execution{DocumentID: "first", StartTime: start})
+	tracker.executionProgressed("first", "runShellScript")
+	tracker.executionProgressed("unknown", "runShellScript")
+
+	executions := tracker.inFlightExecutions()
+	assert.Len(t, executions, 2)
+	assert.Equal(t, "first", executions[0].DocumentID)
+	assert.Equal(t, "runShellScript", executions[0].LastStep)
+	assert.Equal(t, "second", executions[1].DocumentID)
+
+	tracker.executionEnded("first")
+	executions = tracker.inFlightExecutions()
+	assert.Len(t, executions, 1)
+	assert.Equal(t, "second", executions[0].DocumentID)
+}
@@ -25,7 +25,7 @@ import (
 // Pool is a pool of jobs.
 type Pool interface {
 	// Submit schedules a job to be executed in the associated worker pool.
-	// Returns an error if a job with the same name already exists.
+	// Returns an error if a job with the same name already exists or the pool is shut down.
 	Submit(log log.T, jobID string, job Job) error
 
 	// Cancel cancels the given job. Jobs that have not started yet will never be started.
@@ -51,6 +51,7 @@ type Pool interface {
 type pool struct {
 	log            log.T
 	jobQueue       chan JobToken
+	shutdownChan   chan struct{}
 	nWorkers       int
 	doneWorker     chan struct{}
 	isShutdown     bool
@@ -75,6 +76,7 @@ func NewPool(log log.T, maxParallel int, cancelWaitDuration time.Duration, clock
 	p := &pool{
 		log:            log,
 		jobQueue:       make(chan JobToken),
+		shutdownChan:   make(chan struct{}),
 		nWorkers:       maxParallel,
 		doneWorker:     make(chan struct{}),
 		clock:          clock,
@@ -103,10 +105,9 @@ func (p *pool) Shutdown() {
 	p.mut.Lock()
 	defer p.mut.Unlock()
 	if !p.isShutdown {
-		// close the channel to makes all workers terminate once the pending
-		// jobs have been consumed (the pending jobs are in the Canceled state
-		// so they will simply be discarded)
-		close(p.jobQueue)
+		// close the channel to makes all workers terminate and the jobs still
+		// being submitted fail, the jobs are not queued so none is left pending
+		close(p.shutdownChan)
 		p.isShutdown = true
 	}
 }
@@ -148,7 +149,7 @@ func (p *pool) start(jobProcessor func(JobToken)) {
 		workerName := fmt.Sprintf("worker-%d", i)
 		go func() {
 			defer p.workerDone()
-			worker(workerName, p.jobQueue, jobProcessor)
+			worker(workerName, p.jobQueue, p.shutdownChan, jobProcessor)
 		}()
 	}
 }
@@ -159,10 +160,15 @@ func (p *pool) workerDone() {
 }
 
 // worker processes jobs from a channel.
-func worker(workerName string, queue chan JobToken, processor func(JobToken)) {
-	for token := range queue {
-		if !token.cancelFlag.Canceled() {
-			processor(token)
+func worker(workerName string, queue chan JobToken, shutdown chan struct{}, processor func(JobToken)) {
+	for {
+		select {
+		case token := <-queue:
+			if !token.cancelFlag.Canceled() {
+				processor(token)
+			}
+		case <-shutdown:
+			return
 		}
 	}
 }
@@ -179,7 +185,12 @@ func (p *pool) Submit(log log.T, jobID string, job Job) (err error) {
 	if err != nil {
 		return
 	}
-	p.jobQueue <- token
+	select {
+	case p.jobQueue <- token:
+	case <-p.shutdownChan:
+		p.jobStore.DeleteJob(jobID)
+		err = fmt.Errorf("job %v was not submitted, the pool is shut down", jobID)
+	}
 	return
 }
 
@@ -119,3 +119,15 @@ func exercisePool(t *testing.T, pool Pool, jobID string, shouldCancel bool) {
 	// see that job completes
 	assert.True(t, <-jobState)
 }
+
+func TestSubmitAfterShutdown(t *testing.T) {
+	pool := NewPool(logger, 1, 100*time.Millisecond, times.DefaultClock)
+	pool.Shutdown()
+
+	err := pool.Submit(logger, "job", func(cancelFlag CancelFlag) {
+		t.Fatal("the job runs after the pool is shut down")
+	})
+
+	assert.Error(t, err)
+	assert.False(t, pool.HasJob("job"))
+}
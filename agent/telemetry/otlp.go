@@ -0,0 +1,350 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/errordedup"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/uploadqueue"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+const (
+	serviceName   = "amazon-ssm-agent"
+	tracesPath    = "/v1/traces"
+	metricsPath   = "/v1/metrics"
+	exportTimeout = 5 * time.Second
+
+	// spanKindInternal, statusCodeError and temporalityCumulative are the OTLP enum values used by the agent
+	spanKindInternal      = 1
+	statusCodeError       = 2
+	temporalityCumulative = 2
+
+	// suppressedErrorsSpanName is the name of the spans summarizing the errors beyond the reports of the window
+	suppressedErrorsSpanName = "suppressed errors"
+
+	// KindOtlp are the spooled posts of telemetry to the collector
+	KindOtlp = "otlp"
+)
+
+func init() {
+	uploadqueue.RegisterHandler(KindOtlp, postSpooled)
+}
+
+// spooledPost is the payload of a post of telemetry spooled on a disconnected instance
+type spooledPost struct {
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+}
+
+// otlpExporter periodically posts the ended spans and the counters to the collector, encoded as OTLP/JSON
+type otlpExporter struct {
+	log       log.T
+	client    *http.Client
+	endpoint  string
+	interval  time.Duration
+	resource  otlpResource
+	startTime time.Time
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+	done      chan struct{}
+	failing   bool
+	// errors limits the spans exported with the same error
+	errors *errordedup.Deduplicator
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpSum struct {
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name string  `json:"name"`
+	Unit string  `json:"unit"`
+	Sum  otlpSum `json:"sum"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetrics struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+func newOtlpExporter(log log.T, config appconfig.TelemetryCfg, instanceID string) *otlpExporter {
+	resource := map[string]string{
+		"service.name":    serviceName,
+		"service.version": version.Version,
+	}
+	if instanceID != "" {
+		resource["host.id"] = instanceID
+	}
+	return &otlpExporter{
+		log:       log,
+		client:    &http.Client{Timeout: exportTimeout},
+		endpoint:  config.Endpoint,
+		interval:  time.Duration(config.ExportIntervalSeconds) * time.Second,
+		resource:  otlpResource{Attributes: keyValues(resource)},
+		startTime: time.Now(),
+		stopChan:  make(chan struct{}),
+		done:      make(chan struct{}),
+		errors:    errordedup.New(),
+	}
+}
+
+// run exports at every interval until the exporter is stopped, then exports one last time
+func (e *otlpExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.export()
+		case <-e.stopChan:
+			e.export()
+			return
+		}
+	}
+}
+
+func (e *otlpExporter) stop() {
+	e.stopOnce.Do(func() { close(e.stopChan) })
+	<-e.done
+}
+
+// export posts the spans and the counters, a failed export is logged once until an export succeeds again
+func (e *otlpExporter) export() {
+	ended, sums, droppedSpans := takeSpans()
+	if droppedSpans > 0 {
+		e.log.Warnf("Dropped %v spans, more than %v spans ended between two exports", droppedSpans, maxBufferedSpans)
+	}
+	ended = append(ended, e.suppressedErrorSpans()...)
+	var err error
+	if len(ended) > 0 {
+		err = e.post(tracesPath, e.tracesPayload(ended))
+	}
+	if len(sums) > 0 && err == nil {
+		err = e.post(metricsPath, e.metricsPayload(sums, time.Now()))
+	}
+	if err != nil && !e.failing {
+		e.log.Warnf("Failed to export telemetry to %v: %v", e.endpoint, err)
+	} else if err == nil && e.failing {
+		e.log.Infof("Exporting telemetry to %v again", e.endpoint)
+	}
+	e.failing = err != nil
+}
+
+// post sends a payload to the collector through the upload queue, behind the other uploads of the agent.
+// While the uploads are spooled, the payload is saved with them.
+func (e *otlpExporter) post(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	description := "telemetry to " + e.endpoint + path
+	if uploadqueue.Spooling() {
+		spooled, err := json.Marshal(spooledPost{URL: e.endpoint + path, Body: body})
+		if err != nil {
+			return err
+		}
+		return uploadqueue.Do(e.log, uploadqueue.Upload{
+			Kind:        KindOtlp,
+			Priority:    uploadqueue.PriorityTelemetry,
+			Description: description,
+			Size:        int64(len(body)),
+			Payload:     spooled,
+		})
+	}
+	return uploadqueue.Run(e.log, uploadqueue.PriorityTelemetry, description, int64(len(body)), func() error {
+		return postBody(e.client, e.endpoint+path, body)
+	})
+}
+
+// postSpooled posts telemetry spooled on a disconnected instance, for example when the ssm-cli imports a bundle
+func postSpooled(log log.T, payload []byte) error {
+	var spooled spooledPost
+	if err := json.Unmarshal(payload, &spooled); err != nil {
+		return err
+	}
+	log.Infof("Posting telemetry to %v", spooled.URL)
+	return postBody(&http.Client{Timeout: exportTimeout}, spooled.URL, spooled.Body)
+}
+
+func postBody(client *http.Client, url string, body []byte) error {
+	response, err := client.Post(url, "application/json", uploadqueue.NewReader(bytes.NewReader(body)))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%v returned status %v", url, response.Status)
+	}
+	return nil
+}
+
+// suppressedErrorSpans returns a span for every error whose spans were suppressed since the last export, it lasts
+// from the first to the last suppressed span and counts them
+func (e *otlpExporter) suppressedErrorSpans() []*Span {
+	var summaries []*Span
+	for _, summary := range e.errors.Flush() {
+		span := newSpan(suppressedErrorsSpanName, nil, summary.First, map[string]string{
+			"ssm.error.fingerprint":   summary.Fingerprint,
+			"ssm.error.count":         strconv.FormatInt(summary.Count, 10),
+			"ssm.error.first_message": summary.FirstMessage,
+		})
+		span.end = summary.Last
+		span.err = summary.LastMessage
+		summaries = append(summaries, span)
+	}
+	return summaries
+}
+
+func (e *otlpExporter) tracesPayload(ended []*Span) otlpTraces {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: serviceName, Version: version.Version}}
+	for _, span := range ended {
+		converted := otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: unixNano(span.start),
+			EndTimeUnixNano:   unixNano(span.end),
+			Attributes:        keyValues(span.attributes),
+		}
+		if span.err != "" {
+			converted.Status = otlpStatus{Code: statusCodeError, Message: span.err}
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, converted)
+	}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{{Resource: e.resource, ScopeSpans: []otlpScopeSpans{scopeSpans}}}}
+}
+
+func (e *otlpExporter) metricsPayload(sums []counter, now time.Time) otlpMetrics {
+	scopeMetrics := otlpScopeMetrics{Scope: otlpScope{Name: serviceName, Version: version.Version}}
+
+	// the data points of a metric are grouped, the sums are sorted by metric name
+	for _, sum := range sums {
+		point := otlpDataPoint{
+			Attributes:        keyValues(sum.attributes),
+			StartTimeUnixNano: unixNano(e.startTime),
+			TimeUnixNano:      unixNano(now),
+			AsInt:             strconv.FormatInt(sum.value, 10),
+		}
+		metrics := scopeMetrics.Metrics
+		if len(metrics) > 0 && metrics[len(metrics)-1].Name == sum.name {
+			metrics[len(metrics)-1].Sum.DataPoints = append(metrics[len(metrics)-1].Sum.DataPoints, point)
+			continue
+		}
+		scopeMetrics.Metrics = append(metrics, otlpMetric{
+			Name: sum.name,
+			Unit: "1",
+			Sum: otlpSum{
+				AggregationTemporality: temporalityCumulative,
+				IsMonotonic:            true,
+				DataPoints:             []otlpDataPoint{point},
+			},
+		})
+	}
+	return otlpMetrics{ResourceMetrics: []otlpResourceMetrics{{Resource: e.resource, ScopeMetrics: []otlpScopeMetrics{scopeMetrics}}}}
+}
+
+// keyValues converts attributes to OTLP key values sorted by key
+func keyValues(attributes map[string]string) []otlpKeyValue {
+	result := make([]otlpKeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		result = append(result, otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
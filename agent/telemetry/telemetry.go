@@ -0,0 +1,227 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package telemetry exports the traces and metrics of the agent subsystems to an OpenTelemetry collector over OTLP/HTTP.
+// The export is disabled unless it is enabled in the agent configuration, then every function of the package is a no-op.
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// maxBufferedSpans is the number of ended spans kept between two exports, further spans are dropped
+const maxBufferedSpans = 2048
+
+// Span is a timed operation exported as an OpenTelemetry span.
+// A nil span, returned while the export is disabled, can be used and ended like any other.
+type Span struct {
+	traceID    string
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        string
+}
+
+// counter is a cumulative sum of a metric for one set of attributes
+type counter struct {
+	name       string
+	attributes map[string]string
+	value      int64
+}
+
+var (
+	lock     sync.Mutex
+	exporter *otlpExporter
+	spans    []*Span
+	dropped  int64
+	counters = make(map[string]*counter)
+)
+
+// Start begins exporting the telemetry of the agent when it is enabled by the configuration
+func Start(log log.T, config appconfig.TelemetryCfg, instanceID string) {
+	if !config.Enabled {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if exporter != nil {
+		return
+	}
+	exporter = newOtlpExporter(log, config, instanceID)
+	log.Infof("Exporting telemetry to %v every %v seconds", config.Endpoint, config.ExportIntervalSeconds)
+	go exporter.run()
+}
+
+// Stop exports the remaining telemetry and stops the export
+func Stop() {
+	lock.Lock()
+	current := exporter
+	exporter = nil
+	lock.Unlock()
+	if current != nil {
+		current.stop()
+	}
+}
+
+// Enabled returns whether telemetry is exported
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return exporter != nil
+}
+
+// StartSpan starts a span, in the trace of the parent span when there is one
+func StartSpan(name string, parent *Span, attributes map[string]string) *Span {
+	if !Enabled() {
+		return nil
+	}
+	return newSpan(name, parent, time.Now(), attributes)
+}
+
+// RecordSpan records a span that already ended, such as an operation whose times are reported by another process
+func RecordSpan(name string, parent *Span, start time.Time, end time.Time, attributes map[string]string, err error) {
+	if !Enabled() {
+		return
+	}
+	span := newSpan(name, parent, start, attributes)
+	span.end = end
+	if err != nil {
+		span.err = err.Error()
+	}
+	addSpan(span)
+}
+
+// End ends the span, an error marks it as failed
+func (span *Span) End(err error) {
+	if span == nil {
+		return
+	}
+	span.end = time.Now()
+	if err != nil {
+		span.err = err.Error()
+	}
+	addSpan(span)
+}
+
+// SetAttribute adds an attribute to the span before it ends
+func (span *Span) SetAttribute(key string, value string) {
+	if span == nil {
+		return
+	}
+	span.attributes[key] = value
+}
+
+// AddCount adds to the cumulative sum of a metric for the given attributes
+func AddCount(name string, value int64, attributes map[string]string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if exporter == nil {
+		return
+	}
+	key := counterKey(name, attributes)
+	if c, found := counters[key]; found {
+		c.value += value
+	} else {
+		counters[key] = &counter{name: name, attributes: copyAttributes(attributes), value: value}
+	}
+}
+
+func newSpan(name string, parent *Span, start time.Time, attributes map[string]string) *Span {
+	span := &Span{
+		spanID:     randomID(8),
+		name:       name,
+		start:      start,
+		attributes: copyAttributes(attributes),
+	}
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		span.traceID = randomID(16)
+	}
+	return span
+}
+
+// addSpan buffers an ended span, the failed spans beyond the reports of their error are summarized at the export
+func addSpan(span *Span) {
+	lock.Lock()
+	defer lock.Unlock()
+	if exporter == nil {
+		return
+	}
+	if span.err != "" && !exporter.errors.Report(span.err, span.end) {
+		return
+	}
+	if len(spans) >= maxBufferedSpans {
+		dropped++
+		return
+	}
+	spans = append(spans, span)
+}
+
+// takeSpans returns the ended spans and the counters to export, and the number of spans dropped since the last export
+func takeSpans() (ended []*Span, sums []counter, droppedSpans int64) {
+	lock.Lock()
+	defer lock.Unlock()
+	ended, spans = spans, nil
+	droppedSpans, dropped = dropped, 0
+	sums = make([]counter, 0, len(counters))
+	for _, c := range counters {
+		sums = append(sums, *c)
+	}
+	sort.Slice(sums, func(i, j int) bool {
+		return counterKey(sums[i].name, sums[i].attributes) < counterKey(sums[j].name, sums[j].attributes)
+	})
+	return ended, sums, droppedSpans
+}
+
+// counterKey identifies the counter of a metric and a set of attributes
+func counterKey(name string, attributes map[string]string) string {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var builder strings.Builder
+	builder.WriteString(name)
+	for _, key := range keys {
+		builder.WriteString("|" + key + "=" + attributes[key])
+	}
+	return builder.String()
+}
+
+func copyAttributes(attributes map[string]string) map[string]string {
+	result := make(map[string]string, len(attributes))
+	for key, value := range attributes {
+		result[key] = value
+	}
+	return result
+}
+
+// randomID returns a random trace or span id of the given number of bytes, hex encoded
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,168 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// collector records the payloads posted to it by path
+type collector struct {
+	lock     sync.Mutex
+	payloads map[string][][]byte
+}
+
+func newCollector() (*collector, *httptest.Server) {
+	c := &collector{payloads: make(map[string][][]byte)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		c.lock.Lock()
+		c.payloads[r.URL.Path] = append(c.payloads[r.URL.Path], body)
+		c.lock.Unlock()
+	}))
+	return c, server
+}
+
+func startTestTelemetry(endpoint string) {
+	spans, dropped, counters = nil, 0, make(map[string]*counter)
+	Start(log.NewMockLog(), appconfig.TelemetryCfg{
+		Enabled:               true,
+		Endpoint:              endpoint,
+		ExportIntervalSeconds: 300,
+	}, "i-123")
+}
+
+func TestDisabled(t *testing.T) {
+	Start(log.NewMockLog(), appconfig.TelemetryCfg{Endpoint: "http://localhost:4318", ExportIntervalSeconds: 1}, "i-123")
+
+	span := StartSpan("document", nil, nil)
+	assert.Nil(t, span)
+	span.SetAttribute("key", "value")
+	span.End(nil)
+	AddCount("ssm.documents", 1, nil)
+	assert.False(t, Enabled())
+	assert.Empty(t, counters)
+}
+
+func TestExportTraces(t *testing.T) {
+	c, server := newCollector()
+	defer server.Close()
+	startTestTelemetry(server.URL)
+
+	parent := StartSpan("document", nil, map[string]string{"ssm.document.name": "AWS-RunShellScript"})
+	end := time.Now()
+	RecordSpan("plugin aws:runShellScript", parent, end.Add(-time.Second), end, nil, errors.New("exit status 1"))
+	parent.SetAttribute("ssm.status", "Failed")
+	parent.End(nil)
+	Stop()
+
+	assert.Len(t, c.payloads[tracesPath], 1)
+	var payload otlpTraces
+	assert.NoError(t, json.Unmarshal(c.payloads[tracesPath][0], &payload))
+	resource := payload.ResourceSpans[0].Resource.Attributes
+	assert.Contains(t, resource, otlpKeyValue{Key: "host.id", Value: otlpAnyValue{StringValue: "i-123"}})
+	exported := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, exported, 2)
+	plugin, document := exported[0], exported[1]
+	assert.Equal(t, "plugin aws:runShellScript", plugin.Name)
+	assert.Equal(t, document.TraceID, plugin.TraceID)
+	assert.Equal(t, document.SpanID, plugin.ParentSpanID)
+	assert.Equal(t, otlpStatus{Code: statusCodeError, Message: "exit status 1"}, plugin.Status)
+	assert.Len(t, document.TraceID, 32)
+	assert.Len(t, document.SpanID, 16)
+	assert.Equal(t, []otlpKeyValue{
+		{Key: "ssm.document.name", Value: otlpAnyValue{StringValue: "AWS-RunShellScript"}},
+		{Key: "ssm.status", Value: otlpAnyValue{StringValue: "Failed"}},
+	}, document.Attributes)
+	assert.Empty(t, c.payloads[metricsPath])
+}
+
+func TestExportMetrics(t *testing.T) {
+	c, server := newCollector()
+	defer server.Close()
+	startTestTelemetry(server.URL)
+
+	AddCount("ssm.polls", 1, map[string]string{"ssm.outcome": "Success"})
+	AddCount("ssm.polls", 1, map[string]string{"ssm.outcome": "Success"})
+	AddCount("ssm.polls", 1, map[string]string{"ssm.outcome": "Failed"})
+	AddCount("ssm.documents", 1, nil)
+	Stop()
+
+	assert.Empty(t, c.payloads[tracesPath])
+	assert.Len(t, c.payloads[metricsPath], 1)
+	var payload otlpMetrics
+	assert.NoError(t, json.Unmarshal(c.payloads[metricsPath][0], &payload))
+	metrics := payload.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, "ssm.documents", metrics[0].Name)
+	assert.Equal(t, "ssm.polls", metrics[1].Name)
+	assert.Equal(t, temporalityCumulative, metrics[1].Sum.AggregationTemporality)
+	assert.True(t, metrics[1].Sum.IsMonotonic)
+	points := metrics[1].Sum.DataPoints
+	assert.Len(t, points, 2)
+	assert.Equal(t, "1", points[0].AsInt)
+	assert.Equal(t, "Failed", points[0].Attributes[0].Value.StringValue)
+	assert.Equal(t, "2", points[1].AsInt)
+}
+
+func TestSpansAboveBufferAreDropped(t *testing.T) {
+	c, server := newCollector()
+	defer server.Close()
+	startTestTelemetry(server.URL)
+
+	for i := 0; i < maxBufferedSpans+5; i++ {
+		StartSpan("poll", nil, nil).End(nil)
+	}
+	assert.Equal(t, int64(5), dropped)
+	Stop()
+
+	var payload otlpTraces
+	assert.NoError(t, json.Unmarshal(c.payloads[tracesPath][0], &payload))
+	assert.Len(t, payload.ResourceSpans[0].ScopeSpans[0].Spans, maxBufferedSpans)
+}
+
+func TestRepeatedErrorsAreSummarized(t *testing.T) {
+	c, server := newCollector()
+	defer server.Close()
+	startTestTelemetry(server.URL)
+
+	end := time.Now()
+	for i := 0; i < appconfig.DefaultErrorReportsPerWindow+3; i++ {
+		RecordSpan("plugin aws:runShellScript", nil, end.Add(-time.Second), end, nil, fmt.Errorf("failed to upload cmd-%08d: AccessDenied", i))
+	}
+	assert.Len(t, spans, appconfig.DefaultErrorReportsPerWindow)
+	Stop()
+
+	var payload otlpTraces
+	assert.NoError(t, json.Unmarshal(c.payloads[tracesPath][0], &payload))
+	exported := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Len(t, exported, appconfig.DefaultErrorReportsPerWindow+1)
+	summary := exported[len(exported)-1]
+	assert.Equal(t, suppressedErrorsSpanName, summary.Name)
+	assert.Equal(t, otlpStatus{Code: statusCodeError, Message: "failed to upload cmd-00000012: AccessDenied"}, summary.Status)
+	assert.Contains(t, summary.Attributes, otlpKeyValue{Key: "ssm.error.count", Value: otlpAnyValue{StringValue: "3"}})
+	assert.Contains(t, summary.Attributes, otlpKeyValue{Key: "ssm.error.first_message", Value: otlpAnyValue{StringValue: "failed to upload cmd-00000010: AccessDenied"}})
+}
@@ -0,0 +1,142 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package throttle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// apiThrottlingWindow is how far back the throttled api calls are counted
+const apiThrottlingWindow = 5 * time.Minute
+
+// apiThrottling tracks the ThrottlingException responses of the AWS api calls made by the agent
+var apiThrottling = newThrottlingTracker()
+
+type throttlingTracker struct {
+	mutex sync.Mutex
+	// events are the times of the throttled calls within the window by api
+	events map[string][]time.Time
+	// totals are the throttled calls since the agent started by api
+	totals map[string]int
+}
+
+func newThrottlingTracker() *throttlingTracker {
+	return &throttlingTracker{events: make(map[string][]time.Time), totals: make(map[string]int)}
+}
+
+// APIThrottling is the number of throttled calls of an api.
+type APIThrottling struct {
+	API string
+	// Recent is the number of throttled calls within the last five minutes
+	Recent int
+	// Total is the number of throttled calls since the agent started
+	Total int
+}
+
+// RecordAPIThrottled counts a call of the api of the service that was throttled.
+func RecordAPIThrottled(service string, operation string) {
+	apiThrottling.record(service+"."+operation, now())
+}
+
+// APIThrottlingStats returns the throttled calls by api, sorted by api.
+func APIThrottlingStats() []APIThrottling {
+	return apiThrottling.stats(now())
+}
+
+// FormatAPIThrottlingStats describes the apis that were throttled within the last five minutes.
+func FormatAPIThrottlingStats(stats []APIThrottling) string {
+	var parts []string
+	for _, stat := range stats {
+		if stat.Recent > 0 {
+			parts = append(parts, fmt.Sprintf("%v: %v (%v total)", stat.API, stat.Recent, stat.Total))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// BackoffFactor returns how much non-critical schedules are stretched because of sustained api throttling,
+// 1 meaning they run as configured. The factor grows with the number of throttled calls within the last five
+// minutes and goes back to 1 once the throttling clears.
+func BackoffFactor(cfg appconfig.ThrottleCfg) int {
+	if cfg.ApiThrottlingThreshold <= 0 {
+		return 1
+	}
+	recent := 0
+	for _, stat := range APIThrottlingStats() {
+		recent += stat.Recent
+	}
+	factor := 1 + recent/cfg.ApiThrottlingThreshold
+	if factor > cfg.MaxBackoffFactor {
+		factor = cfg.MaxBackoffFactor
+	}
+	if factor < 1 {
+		factor = 1
+	}
+	return factor
+}
+
+func (t *throttlingTracker) record(api string, at time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events[api] = append(t.prune(api, at), at)
+	t.totals[api]++
+}
+
+func (t *throttlingTracker) stats(at time.Time) []APIThrottling {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	stats := make([]APIThrottling, 0, len(t.totals))
+	for api, total := range t.totals {
+		stats = append(stats, APIThrottling{API: api, Recent: len(t.prune(api, at)), Total: total})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].API < stats[j].API })
+	return stats
+}
+
+// prune drops the events of the api that are older than the window, the caller holds the mutex.
+func (t *throttlingTracker) prune(api string, at time.Time) []time.Time {
+	events := t.events[api]
+	i := 0
+	for i < len(events) && at.Sub(events[i]) >= apiThrottlingWindow {
+		i++
+	}
+	events = events[i:]
+	t.events[api] = events
+	return events
+}
+
+// Stretcher skips runs of a recurring job while the api calls of the agent are throttled.
+type Stretcher struct {
+	mutex   sync.Mutex
+	skipped int
+}
+
+// Due returns whether the current run of the job should proceed. With a backoff factor of n only every nth
+// run proceeds, so the job effectively runs n times less often until the throttling clears.
+func (s *Stretcher) Due(cfg appconfig.ThrottleCfg) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.skipped+1 >= BackoffFactor(cfg) {
+		s.skipped = 0
+		return true
+	}
+	s.skipped++
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+var apiThrottlingCfg = appconfig.ThrottleCfg{ApiThrottlingThreshold: 2, MaxBackoffFactor: 3}
+
+func resetAPIThrottling() {
+	apiThrottling = newThrottlingTracker()
+}
+
+func TestAPIThrottlingStats(t *testing.T) {
+	clock, restore := useFakeClock()
+	defer restore()
+	resetAPIThrottling()
+	defer resetAPIThrottling()
+
+	RecordAPIThrottled("ssm", "ListInstanceAssociations")
+	RecordAPIThrottled("ec2messages", "GetMessages")
+	clock.sleep(4 * time.Minute)
+	RecordAPIThrottled("ssm", "ListInstanceAssociations")
+
+	assert.Equal(t, []APIThrottling{
+		{API: "ec2messages.GetMessages", Recent: 1, Total: 1},
+		{API: "ssm.ListInstanceAssociations", Recent: 2, Total: 2},
+	}, APIThrottlingStats())
+
+	clock.sleep(2 * time.Minute)
+	stats := APIThrottlingStats()
+	assert.Equal(t, []APIThrottling{
+		{API: "ec2messages.GetMessages", Recent: 0, Total: 1},
+		{API: "ssm.ListInstanceAssociations", Recent: 1, Total: 2},
+	}, stats)
+	assert.Equal(t, "ssm.ListInstanceAssociations: 1 (2 total)", FormatAPIThrottlingStats(stats))
+}
+
+func TestBackoffFactor(t *testing.T) {
+	clock, restore := useFakeClock()
+	defer restore()
+	resetAPIThrottling()
+	defer resetAPIThrottling()
+
+	assert.Equal(t, 1, BackoffFactor(apiThrottlingCfg))
+
+	RecordAPIThrottled("ssm", "PutInventory")
+	assert.Equal(t, 1, BackoffFactor(apiThrottlingCfg))
+
+	RecordAPIThrottled("ssm", "PutInventory")
+	assert.Equal(t, 2, BackoffFactor(apiThrottlingCfg))
+
+	for i := 0; i < 10; i++ {
+		RecordAPIThrottled("ssm", "UpdateInstanceInformation")
+	}
+	assert.Equal(t, 3, BackoffFactor(apiThrottlingCfg), "the factor is capped")
+	assert.Equal(t, 1, BackoffFactor(appconfig.ThrottleCfg{MaxBackoffFactor: 3}), "a threshold of 0 disables the backoff")
+
+	clock.sleep(apiThrottlingWindow)
+	assert.Equal(t, 1, BackoffFactor(apiThrottlingCfg), "the factor recovers once the throttling clears")
+}
+
+func TestStretcher(t *testing.T) {
+	clock, restore := useFakeClock()
+	defer restore()
+	resetAPIThrottling()
+	defer resetAPIThrottling()
+
+	var stretcher Stretcher
+	assert.True(t, stretcher.Due(apiThrottlingCfg))
+	assert.True(t, stretcher.Due(apiThrottlingCfg))
+
+	for i := 0; i < 4; i++ {
+		RecordAPIThrottled("ssm", "ListInstanceAssociations")
+	}
+	var runs []bool
+	for i := 0; i < 6; i++ {
+		runs = append(runs, stretcher.Due(apiThrottlingCfg))
+	}
+	assert.Equal(t, []bool{false, false, true, false, false, true}, runs)
+
+	clock.sleep(apiThrottlingWindow)
+	assert.True(t, stretcher.Due(apiThrottlingCfg))
+	assert.True(t, stretcher.Due(apiThrottlingCfg))
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package throttle
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+)
+
+// sleep is replaced in unit tests
+var sleep = time.Sleep
+
+// RateLimiter caps the combined throughput of the readers sharing it, it is safe for concurrent use.
+type RateLimiter struct {
+	mutex          sync.Mutex
+	bytesPerSecond int64
+	// next is when the bytes read so far are within the rate
+	next time.Time
+}
+
+// NewRateLimiter returns a limiter of the given throughput.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	return &RateLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// reserve accounts for n bytes read and returns how long the reader waits for them to be within the rate.
+// The time the limiter was idle is not saved up, so readers can't burst above the rate after a pause
+func (l *RateLimiter) reserve(n int) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	current := now()
+	if l.next.Before(current) {
+		l.next = current
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+	return l.next.Sub(current)
+}
+
+// rateLimitedReader reads through a RateLimiter.
+type rateLimitedReader struct {
+	limiter *RateLimiter
+	reader  io.Reader
+}
+
+// NewRateLimitedReader wraps the reader so that the readers sharing the limiter don't exceed its throughput,
+// it returns the reader as is without a limiter.
+func NewRateLimitedReader(limiter *RateLimiter, reader io.Reader) io.Reader {
+	if limiter == nil {
+		return reader
+	}
+	return &rateLimitedReader{limiter: limiter, reader: reader}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (n int, err error) {
+	// read at most a tenth of a second worth of data to keep the throughput smooth
+	if chunk := r.limiter.bytesPerSecond / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err = r.reader.Read(p)
+	if n > 0 {
+		sleep(r.limiter.reserve(n))
+	}
+	return
+}
+
+var (
+	downloadLimiter     *RateLimiter
+	downloadLimiterOnce sync.Once
+)
+
+// NewDownloadReader wraps the reader of an artifact download. The downloads of the agent don't exceed
+// Ssm.DownloadRateLimitBytesPerSecond together, and each of them is slowed down during the peak hours.
+func NewDownloadReader(reader io.Reader) io.Reader {
+	config, err := appconfig.Config(false)
+	if err != nil {
+		return reader
+	}
+	downloadLimiterOnce.Do(func() {
+		if config.Ssm.DownloadRateLimitBytesPerSecond > 0 {
+			downloadLimiter = NewRateLimiter(config.Ssm.DownloadRateLimitBytesPerSecond)
+		}
+	})
+	return NewReader(config.Throttle, NewRateLimitedReader(downloadLimiter, reader))
+}
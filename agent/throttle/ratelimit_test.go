@@ -0,0 +1,89 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package throttle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock advances the time by the durations slept
+type fakeClock struct {
+	mutex   sync.Mutex
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.current
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.current = c.current.Add(d)
+}
+
+func useFakeClock() (*fakeClock, func()) {
+	clock := &fakeClock{current: testTime(4, 10, 0)}
+	now, sleep = clock.now, clock.sleep
+	return clock, func() { now, sleep = time.Now, time.Sleep }
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	clock, restore := useFakeClock()
+	defer restore()
+	data := bytes.Repeat([]byte("a"), 10000)
+	start := clock.now()
+
+	read, err := ioutil.ReadAll(NewRateLimitedReader(NewRateLimiter(1000), bytes.NewReader(data)))
+
+	assert.NoError(t, err)
+	assert.Equal(t, data, read)
+	assert.Equal(t, 10*time.Second, clock.now().Sub(start))
+}
+
+func TestRateLimiterIsShared(t *testing.T) {
+	_, restore := useFakeClock()
+	defer restore()
+	limiter := NewRateLimiter(1000)
+
+	// readers reading at the same time wait for the bytes read by the others
+	assert.Equal(t, 100*time.Millisecond, limiter.reserve(100))
+	assert.Equal(t, 300*time.Millisecond, limiter.reserve(200))
+	assert.Equal(t, 400*time.Millisecond, limiter.reserve(100))
+}
+
+func TestRateLimiterDoesNotSaveIdleTime(t *testing.T) {
+	clock, restore := useFakeClock()
+	defer restore()
+	limiter := NewRateLimiter(1000)
+
+	assert.Equal(t, time.Second, limiter.reserve(1000))
+	clock.sleep(time.Minute)
+
+	assert.Equal(t, 500*time.Millisecond, limiter.reserve(500))
+}
+
+func TestNewRateLimitedReaderWithoutLimiter(t *testing.T) {
+	reader := bytes.NewReader(nil)
+
+	assert.Equal(t, reader, NewRateLimitedReader(nil, reader))
+}
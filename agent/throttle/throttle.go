@@ -0,0 +1,259 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package throttle implements the schedule based self throttling of the agent during configured peak hours.
+package throttle
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+)
+
+const (
+	timeOfDayLayout = "15:04"
+
+	// gateRetryInterval is how often a blocked execution checks again whether it may start
+	gateRetryInterval = 1 * time.Second
+)
+
+// now is replaced in unit tests
+var now = time.Now
+
+// Validate checks the peak hours and peak days of the throttle configuration.
+func Validate(cfg appconfig.ThrottleCfg) error {
+	if cfg.PeakHours == "" {
+		return nil
+	}
+	if _, _, err := parsePeakHours(cfg.PeakHours); err != nil {
+		return err
+	}
+	_, err := parsePeakDays(cfg.PeakDays)
+	return err
+}
+
+// IsPeak returns true if t is within the configured peak hours.
+// An invalid configuration never throttles.
+func IsPeak(cfg appconfig.ThrottleCfg, t time.Time) bool {
+	if cfg.PeakHours == "" {
+		return false
+	}
+	start, end, err := parsePeakHours(cfg.PeakHours)
+	if err != nil {
+		return false
+	}
+	days, err := parsePeakDays(cfg.PeakDays)
+	if err != nil {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if start <= end {
+		return days[day] && minute >= start && minute < end
+	}
+	// the window spans midnight, the early morning part belongs to the window started the day before
+	if minute >= start {
+		return days[day]
+	}
+	return minute < end && days[(day+6)%7]
+}
+
+// PollDelay returns the extra delay to wait between two message polls.
+func PollDelay(cfg appconfig.ThrottleCfg) time.Duration {
+	if cfg.PollDelaySeconds <= 0 || !IsPeak(cfg, now()) {
+		return 0
+	}
+	return time.Duration(cfg.PollDelaySeconds) * time.Second
+}
+
+// ExecutionLimit returns the maximum number of concurrent executions, 0 meaning unlimited.
+func ExecutionLimit(cfg appconfig.ThrottleCfg) int {
+	if cfg.MaxConcurrentExecutions <= 0 || !IsPeak(cfg, now()) {
+		return 0
+	}
+	return cfg.MaxConcurrentExecutions
+}
+
+// parsePeakHours returns the start and end of the window in minutes since midnight.
+func parsePeakHours(peakHours string) (start int, end int, err error) {
+	parts := strings.Split(peakHours, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("peak hours %q should be in the HH:MM-HH:MM format", peakHours)
+	}
+	if start, err = parseTimeOfDay(parts[0]); err != nil {
+		return
+	}
+	if end, err = parseTimeOfDay(parts[1]); err != nil {
+		return
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("peak hours %q should not be empty", peakHours)
+	}
+	return
+}
+
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse(timeOfDayLayout, strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parsePeakDays returns the days of the week that have peak hours.
+func parsePeakDays(peakDays string) (days [7]bool, err error) {
+	if strings.TrimSpace(peakDays) == "" {
+		for i := range days {
+			days[i] = true
+		}
+		return
+	}
+	for _, part := range strings.Split(peakDays, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		var first, last int
+		if first, err = parseWeekday(bounds[0]); err != nil {
+			return
+		}
+		last = first
+		if len(bounds) == 2 {
+			if last, err = parseWeekday(bounds[1]); err != nil {
+				return
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return
+}
+
+func parseWeekday(value string) (int, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		fullName := strings.ToLower(day.String())
+		if name == fullName || name == fullName[:3] {
+			return int(day), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day of week %q", value)
+}
+
+// Gate limits the number of executions running concurrently during peak hours.
+type Gate struct {
+	cfg     appconfig.ThrottleCfg
+	mutex   sync.Mutex
+	running int
+}
+
+var (
+	sharedGate     *Gate
+	sharedGateOnce sync.Once
+)
+
+// NewGate creates a Gate for the given throttle configuration.
+func NewGate(cfg appconfig.ThrottleCfg) *Gate {
+	return &Gate{cfg: cfg}
+}
+
+// SharedGate returns the Gate shared by the document processors of the agent, so the execution limit applies to all
+// of its executions. The Gate is created for the throttle configuration of the first caller.
+func SharedGate(cfg appconfig.ThrottleCfg) *Gate {
+	sharedGateOnce.Do(func() {
+		sharedGate = NewGate(cfg)
+	})
+	return sharedGate
+}
+
+// Limited returns true if the configuration limits the number of executions during valid peak hours, executions
+// never wait at the Gate otherwise.
+func (g *Gate) Limited() bool {
+	return g.cfg.MaxConcurrentExecutions > 0 && g.cfg.PeakHours != "" && Validate(g.cfg) == nil
+}
+
+// Acquire blocks until the execution may start or the cancel flag is set, and returns whether it may start.
+// Every successful Acquire must be followed by a Release.
+func (g *Gate) Acquire(cancelFlag task.CancelFlag) bool {
+	for {
+		if g.tryAcquire() {
+			return true
+		}
+		if cancelFlag.Canceled() || cancelFlag.ShutDown() {
+			return false
+		}
+		time.Sleep(gateRetryInterval)
+	}
+}
+
+func (g *Gate) tryAcquire() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if limit := ExecutionLimit(g.cfg); limit > 0 && g.running >= limit {
+		return false
+	}
+	g.running++
+	return true
+}
+
+// Release marks an execution as finished.
+func (g *Gate) Release() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.running--
+}
+
+// limitedReader caps the read throughput while in peak hours.
+type limitedReader struct {
+	cfg    appconfig.ThrottleCfg
+	reader io.Reader
+	start  time.Time
+	read   int64
+}
+
+// NewReader wraps the reader so that it does not exceed the download bandwidth during peak hours.
+func NewReader(cfg appconfig.ThrottleCfg, reader io.Reader) io.Reader {
+	if cfg.DownloadBandwidthKBps <= 0 || cfg.PeakHours == "" {
+		return reader
+	}
+	return &limitedReader{cfg: cfg, reader: reader}
+}
+
+func (r *limitedReader) Read(p []byte) (n int, err error) {
+	if !IsPeak(r.cfg, now()) {
+		r.read = 0
+		return r.reader.Read(p)
+	}
+	bytesPerSecond := int64(r.cfg.DownloadBandwidthKBps) * 1024
+	if r.read == 0 {
+		r.start = now()
+	}
+	// read at most a tenth of a second worth of data to keep the throughput smooth
+	if chunk := bytesPerSecond / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err = r.reader.Read(p)
+	r.read += int64(n)
+	expected := time.Duration(r.read * int64(time.Second) / bytesPerSecond)
+	if wait := expected - now().Sub(r.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package throttle
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/task"
+	"github.com/stretchr/testify/assert"
+)
+
+// 2018-06-04 is a Monday
+func testTime(day int, hour int, minute int) time.Time {
+	return time.Date(2018, time.June, day, hour, minute, 0, 0, time.Local)
+}
+
+func TestIsPeak(t *testing.T) {
+	cfg := appconfig.ThrottleCfg{PeakHours: "09:00-17:30", PeakDays: "Mon-Fri"}
+	assert.True(t, IsPeak(cfg, testTime(4, 9, 0)))
+	assert.True(t, IsPeak(cfg, testTime(8, 17, 29)))
+	assert.False(t, IsPeak(cfg, testTime(4, 17, 30)))
+	assert.False(t, IsPeak(cfg, testTime(4, 8, 59)))
+	assert.False(t, IsPeak(cfg, testTime(9, 12, 0)))
+
+	assert.False(t, IsPeak(appconfig.ThrottleCfg{}, testTime(4, 12, 0)))
+	assert.False(t, IsPeak(appconfig.ThrottleCfg{PeakHours: "9-17"}, testTime(4, 12, 0)))
+}
+
+func TestIsPeakOverMidnight(t *testing.T) {
+	cfg := appconfig.ThrottleCfg{PeakHours: "22:00-02:00", PeakDays: "Friday"}
+	assert.True(t, IsPeak(cfg, testTime(8, 23, 0)))
+	assert.True(t, IsPeak(cfg, testTime(9, 1, 0)))
+	assert.False(t, IsPeak(cfg, testTime(8, 1, 0)))
+	assert.False(t, IsPeak(cfg, testTime(9, 23, 0)))
+}
+
+func TestParsePeakDays(t *testing.T) {
+	days, err := parsePeakDays("Sat-Mon, wed")
+	assert.NoError(t, err)
+	assert.Equal(t, [7]bool{true, true, false, true, false, false, true}, days)
+
+	_, err = parsePeakDays("Someday")
+	assert.Error(t, err)
+	assert.Error(t, Validate(appconfig.ThrottleCfg{PeakHours: "10:00-10:00"}))
+	assert.NoError(t, Validate(appconfig.ThrottleCfg{PeakHours: "10:00-11:00", PeakDays: "Mon"}))
+}
+
+func TestPollDelayAndExecutionLimit(t *testing.T) {
+	defer func() { now = time.Now }()
+	cfg := appconfig.ThrottleCfg{PeakHours: "09:00-17:00", PollDelaySeconds: 60, MaxConcurrentExecutions: 2}
+
+	now = func() time.Time { return testTime(4, 10, 0) }
+	assert.Equal(t, 60*time.Second, PollDelay(cfg))
+	assert.Equal(t, 2, ExecutionLimit(cfg))
+
+	now = func() time.Time { return testTime(4, 20, 0) }
+	assert.Equal(t, time.Duration(0), PollDelay(cfg))
+	assert.Equal(t, 0, ExecutionLimit(cfg))
+}
+
+func TestGate(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return testTime(4, 10, 0) }
+	gate := NewGate(appconfig.ThrottleCfg{PeakHours: "09:00-17:00", MaxConcurrentExecutions: 1})
+	cancelFlag := task.NewChanneledCancelFlag()
+
+	assert.True(t, gate.Acquire(cancelFlag))
+	assert.False(t, gate.tryAcquire())
+	cancelFlag.Set(task.Canceled)
+	assert.False(t, gate.Acquire(cancelFlag))
+
+	gate.Release()
+	assert.True(t, gate.tryAcquire())
+	assert.True(t, gate.Limited())
+	assert.False(t, NewGate(appconfig.ThrottleCfg{MaxConcurrentExecutions: 1}).Limited())
+	assert.False(t, NewGate(appconfig.ThrottleCfg{PeakHours: "9-17", MaxConcurrentExecutions: 1}).Limited())
+}
+
+func TestSharedGate(t *testing.T) {
+	gate := SharedGate(appconfig.ThrottleCfg{PeakHours: "09:00-17:00", MaxConcurrentExecutions: 1})
+
+	assert.True(t, gate == SharedGate(appconfig.ThrottleCfg{}))
+}
+
+func TestNewReader(t *testing.T) {
+	defer func() { now = time.Now }()
+	data := bytes.Repeat([]byte("a"), 4096)
+
+	// outside of the peak hours the data is not slowed down
+	now = func() time.Time { return testTime(4, 20, 0) }
+	reader := NewReader(appconfig.ThrottleCfg{PeakHours: "09:00-17:00", DownloadBandwidthKBps: 1}, bytes.NewReader(data))
+	start := time.Now()
+	read, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, data, read)
+	assert.True(t, time.Since(start) < time.Second)
+
+	// 40 KB/s bandwidth, reading 4KB takes about 100ms
+	base := time.Now()
+	now = func() time.Time { return testTime(4, 10, 0).Add(time.Since(base)) }
+	reader = NewReader(appconfig.ThrottleCfg{PeakHours: "09:00-17:00", DownloadBandwidthKBps: 40}, bytes.NewReader(data))
+	start = time.Now()
+	read, err = ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, data, read)
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+}
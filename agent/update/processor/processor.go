@@ -17,10 +17,12 @@ package processor
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
 
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -33,6 +35,7 @@ var once sync.Once
 var (
 	downloadArtifact = artifact.Download
 	uncompress       = fileutil.Uncompress
+	agentInstallRoot = appconfig.AgentInstallRoot
 )
 
 // NewUpdater creates an instance of Updater and other services it requires
@@ -168,6 +171,7 @@ func prepareInstallationPackages(mgr *updateManager, log log.T, context *UpdateC
 			updateutil.HashType: context.Current.SourceHash,
 		},
 		DestinationDirectory: updateDownload,
+		Cached:               true,
 	}
 
 	if err = mgr.download(mgr, log, downloadInput, context, context.Current.SourceVersion); err != nil {
@@ -181,6 +185,7 @@ func prepareInstallationPackages(mgr *updateManager, log log.T, context *UpdateC
 			updateutil.HashType: context.Current.TargetHash,
 		},
 		DestinationDirectory: updateDownload,
+		Cached:               true,
 	}
 
 	if err = mgr.download(mgr, log, downloadInput, context, context.Current.TargetVersion); err != nil {
@@ -297,6 +302,9 @@ func verifyInstallation(mgr *updateManager, log log.T, context *UpdateContext, i
 
 	log.Infof("%v is running", context.Current.PackageName)
 	if !isRollback {
+		if updateutil.IsSideBySideLayout(agentInstallRoot) {
+			updateutil.PruneVersions(log, agentInstallRoot, context.Current.SourceVersion, context.Current.TargetVersion)
+		}
 		return mgr.succeeded(context, log)
 	}
 
@@ -335,6 +343,12 @@ func rollbackInstallation(mgr *updateManager, log log.T, context *UpdateContext)
 
 // uninstall executes the uninstall script for the specific version of agent
 func uninstallAgent(mgr *updateManager, log log.T, version string, context *UpdateContext) (err error) {
+	// side-by-side installations keep every version in its own directory, nothing to remove
+	if updateutil.IsSideBySideLayout(agentInstallRoot) {
+		log.Infof("Keeping %v %v installed side-by-side", context.Current.PackageName, version)
+		return nil
+	}
+
 	log.Infof("Initiating %v %v uninstallation", context.Current.PackageName, version)
 
 	// find the path for the uninstall script
@@ -379,6 +393,16 @@ func installAgent(mgr *updateManager, log log.T, version string, context *Update
 		context.Current.PackageName,
 		version)
 
+	// side-by-side installations switch the current link to the staged version,
+	// the install script of that version then only has to restart the agent from it
+	if updateutil.IsSideBySideLayout(agentInstallRoot) {
+		if err = updateutil.SwitchCurrentVersion(log, agentInstallRoot, version); err != nil {
+			return err
+		}
+		workDir = updateutil.VersionInstallFolder(agentInstallRoot, version)
+		installerPath = filepath.Join(workDir, updateutil.Installer)
+	}
+
 	// Install version
 	if err = mgr.util.ExeCommand(
 		log,
@@ -427,5 +451,14 @@ func downloadAndUnzipArtifact(
 		return fmt.Errorf("failed to uncompress installation package, %v", err.Error())
 	}
 
+	// stage the version next to the installed ones so switching to it never touches the running agent
+	if updateutil.IsSideBySideLayout(agentInstallRoot) {
+		if err = updateutil.StageVersion(log, agentInstallRoot, version, func(dest string) error {
+			return uncompress(log, downloadOutput.LocalFilePath, dest)
+		}); err != nil {
+			return fmt.Errorf("failed to stage installation package, %v", err.Error())
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,108 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package processor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil/artifact"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/updateutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupSideBySideLayout creates an install root with the given versions staged and the first one current
+func setupSideBySideLayout(t *testing.T, versions ...string) func() {
+	root, err := ioutil.TempDir("", "sidebyside")
+	assert.NoError(t, err)
+	for _, version := range versions {
+		assert.NoError(t, updateutil.StageVersion(logger, root, version, func(dest string) error { return nil }))
+	}
+	assert.NoError(t, updateutil.SwitchCurrentVersion(logger, root, versions[0]))
+	agentInstallRoot = root
+	return func() {
+		agentInstallRoot = appconfig.AgentInstallRoot
+		os.RemoveAll(root)
+	}
+}
+
+func TestDownloadAndUnzipArtifactStagesSideBySide(t *testing.T) {
+	// setup
+	context := createUpdateContext(Initialized)
+	defer setupSideBySideLayout(t, context.Current.SourceVersion)()
+	updater := createUpdaterStubs(&stubControl{})
+
+	downloadArtifact = func(log log.T, input artifact.DownloadInput) (output artifact.DownloadOutput, err error) {
+		return artifact.DownloadOutput{IsHashMatched: true, LocalFilePath: "filepath"}, nil
+	}
+	uncompress = func(log log.T, src, dest string) error {
+		if !strings.HasPrefix(dest, agentInstallRoot) {
+			return nil
+		}
+		return ioutil.WriteFile(filepath.Join(dest, "amazon-ssm-agent"), []byte{}, 0755)
+	}
+
+	// action
+	err := downloadAndUnzipArtifact(updater.mgr, logger, artifact.DownloadInput{}, context, context.Current.TargetVersion)
+
+	// assert
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(updateutil.VersionInstallFolder(agentInstallRoot, context.Current.TargetVersion), "amazon-ssm-agent"))
+	assert.NoError(t, err)
+	current, _ := updateutil.CurrentVersion(agentInstallRoot)
+	assert.Equal(t, context.Current.SourceVersion, current)
+}
+
+func TestInstallAndRollbackSideBySide(t *testing.T) {
+	// setup
+	context := createUpdateContext(Installed)
+	defer setupSideBySideLayout(t, context.Current.SourceVersion, context.Current.TargetVersion)()
+	updater := createUpdaterStubs(&stubControl{})
+	updater.mgr.verify = func(mgr *updateManager, log log.T, context *UpdateContext, isRollback bool) (err error) {
+		return nil
+	}
+
+	// action and assert
+	assert.NoError(t, installAgent(updater.mgr, logger, context.Current.TargetVersion, context))
+	current, _ := updateutil.CurrentVersion(agentInstallRoot)
+	assert.Equal(t, context.Current.TargetVersion, current)
+
+	assert.NoError(t, rollbackInstallation(updater.mgr, logger, context))
+	current, _ = updateutil.CurrentVersion(agentInstallRoot)
+	assert.Equal(t, context.Current.SourceVersion, current)
+	_, err := os.Stat(updateutil.VersionInstallFolder(agentInstallRoot, context.Current.TargetVersion))
+	assert.NoError(t, err)
+}
+
+func TestInstallAgentSideBySideVersionNotStaged(t *testing.T) {
+	// setup
+	context := createUpdateContext(Installed)
+	defer setupSideBySideLayout(t, context.Current.SourceVersion)()
+	updater := createUpdaterStubs(&stubControl{})
+
+	// action
+	err := installAgent(updater.mgr, logger, context.Current.TargetVersion, context)
+
+	// assert
+	assert.Error(t, err)
+	current, _ := updateutil.CurrentVersion(agentInstallRoot)
+	assert.Equal(t, context.Current.SourceVersion, current)
+}
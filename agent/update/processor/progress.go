@@ -18,6 +18,7 @@ package processor
 import (
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
@@ -60,6 +61,12 @@ func (u *updateManager) succeeded(context *UpdateContext, log log.T) (err error)
 		"%v updated successfully to %v",
 		update.PackageName,
 		update.TargetVersion)
+	agentevents.Publish(agentevents.UpdateApplied, map[string]string{
+		"packageName":   update.PackageName,
+		"sourceVersion": update.SourceVersion,
+		"targetVersion": update.TargetVersion,
+		"messageId":     update.MessageID,
+	})
 
 	return u.finalizeUpdateAndSendReply(log, context, "")
 }
@@ -19,9 +19,13 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/aws/amazon-ssm-agent/agent/agentevents"
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/contracts"
+	"github.com/aws/amazon-ssm-agent/agent/errordedup"
 	logger "github.com/aws/amazon-ssm-agent/agent/log"
 	ssmlog "github.com/aws/amazon-ssm-agent/agent/log/ssmlog"
+	"github.com/aws/amazon-ssm-agent/agent/network"
 	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/aws/amazon-ssm-agent/agent/update/processor"
 	"github.com/aws/amazon-ssm-agent/agent/updateutil"
@@ -60,6 +64,7 @@ func init() {
 	// Sleep 2 seconds to allow agent to finishing up it's work
 	time.Sleep(defaultWaitTimeForAgentToFinish * time.Second)
 
+	network.ApplyTLSPolicy(log)
 	updater = processor.NewUpdater()
 
 	// Load update detail from command line
@@ -142,6 +147,14 @@ func main() {
 		return
 	}
 
+	// the events of the update are published by the updater, the agent is stopped while it is replaced
+	if appConfig, err := appconfig.Config(false); err == nil {
+		instanceID, _ := platform.InstanceID()
+		errordedup.Configure(appConfig.ErrorReporting)
+		agentevents.Start(log, appConfig.Events, instanceID)
+		defer agentevents.Stop()
+	}
+
 	// Recover updater if panic occurs and fail the updater
 	defer recoverUpdaterFromPanic(context)
 
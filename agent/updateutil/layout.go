@@ -0,0 +1,138 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package updateutil contains updater specific utilities.
+package updateutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// The side-by-side layout keeps every installed version of the agent in its own directory
+// under <installRoot>/versions and points <installRoot>/current at the active one, so that
+// switching versions or rolling back never overwrites files of the running installation.
+const (
+	// VersionsFolderName is the folder holding one directory per installed version
+	VersionsFolderName = "versions"
+
+	// CurrentVersionLinkName is the link pointing at the active version
+	CurrentVersionLinkName = "current"
+
+	stagingSuffix = ".staging"
+	switchSuffix  = ".switch"
+)
+
+// VersionInstallFolder returns the directory holding the given version in the side-by-side layout
+func VersionInstallFolder(installRoot string, version string) string {
+	return filepath.Join(installRoot, VersionsFolderName, version)
+}
+
+// CurrentVersionLink returns the path of the link pointing at the active version
+func CurrentVersionLink(installRoot string) string {
+	return filepath.Join(installRoot, CurrentVersionLinkName)
+}
+
+// IsSideBySideLayout returns true when the agent under installRoot is installed side-by-side
+func IsSideBySideLayout(installRoot string) bool {
+	_, err := CurrentVersion(installRoot)
+	return err == nil
+}
+
+// CurrentVersion returns the version the current link points at
+func CurrentVersion(installRoot string) (version string, err error) {
+	var target string
+	if target, err = os.Readlink(CurrentVersionLink(installRoot)); err != nil {
+		return "", err
+	}
+	return filepath.Base(target), nil
+}
+
+// StageVersion extracts a version into its own directory unless it is already there.
+// The files are extracted into a staging directory first and renamed into place once complete,
+// so an interrupted update never leaves a partially populated version directory behind.
+func StageVersion(log log.T, installRoot string, version string, extract func(dest string) error) (err error) {
+	versionFolder := VersionInstallFolder(installRoot, version)
+	if fileutil.Exists(versionFolder) {
+		log.Infof("Version %v is already staged in %v", version, versionFolder)
+		return nil
+	}
+
+	stagingFolder := versionFolder + stagingSuffix
+	if err = os.RemoveAll(stagingFolder); err != nil {
+		return fmt.Errorf("failed to clean up staging folder %v, %v", stagingFolder, err)
+	}
+	if err = fileutil.MakeDirsWithExecuteAccess(stagingFolder); err != nil {
+		return fmt.Errorf("failed to create staging folder %v, %v", stagingFolder, err)
+	}
+	if err = extract(stagingFolder); err != nil {
+		os.RemoveAll(stagingFolder)
+		return err
+	}
+	if err = os.Rename(stagingFolder, versionFolder); err != nil {
+		os.RemoveAll(stagingFolder)
+		return fmt.Errorf("failed to move %v into place, %v", versionFolder, err)
+	}
+	log.Infof("Staged version %v in %v", version, versionFolder)
+	return nil
+}
+
+// SwitchCurrentVersion points the current link at a staged version.
+// The new link is created next to the current one and renamed over it.
+func SwitchCurrentVersion(log log.T, installRoot string, version string) (err error) {
+	versionFolder := VersionInstallFolder(installRoot, version)
+	if !fileutil.Exists(versionFolder) {
+		return fmt.Errorf("version %v is not staged in %v", version, installRoot)
+	}
+
+	link := CurrentVersionLink(installRoot)
+	newLink := link + switchSuffix
+	if err = os.Remove(newLink); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clean up %v, %v", newLink, err)
+	}
+	if err = os.Symlink(versionFolder, newLink); err != nil {
+		return fmt.Errorf("failed to link %v to %v, %v", newLink, versionFolder, err)
+	}
+	if err = replaceLink(newLink, link); err != nil {
+		os.Remove(newLink)
+		return fmt.Errorf("failed to switch %v to %v, %v", link, versionFolder, err)
+	}
+	log.Infof("Switched %v to version %v", link, version)
+	return nil
+}
+
+// PruneVersions deletes every staged version other than the ones to keep
+func PruneVersions(log log.T, installRoot string, keep ...string) {
+	versions, err := fileutil.GetDirectoryNames(filepath.Join(installRoot, VersionsFolderName))
+	if err != nil {
+		log.Warnf("Failed to list installed versions, %v", err)
+		return
+	}
+	kept := make(map[string]bool)
+	for _, version := range keep {
+		kept[version] = true
+	}
+	for _, version := range versions {
+		if kept[version] {
+			continue
+		}
+		log.Infof("Removing version %v", version)
+		if err = os.RemoveAll(VersionInstallFolder(installRoot, version)); err != nil {
+			log.Warnf("Failed to remove version %v, %v", version, err)
+		}
+	}
+}
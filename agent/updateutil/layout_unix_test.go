@@ -0,0 +1,110 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// +build darwin freebsd linux netbsd openbsd
+
+package updateutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestInstallRoot(t *testing.T) (string, func()) {
+	installRoot, err := ioutil.TempDir("", "layout")
+	assert.NoError(t, err)
+	return installRoot, func() { os.RemoveAll(installRoot) }
+}
+
+func extractTo(file string) func(dest string) error {
+	return func(dest string) error {
+		return ioutil.WriteFile(filepath.Join(dest, file), []byte(file), 0644)
+	}
+}
+
+func TestStageAndSwitchVersion(t *testing.T) {
+	installRoot, cleanup := newTestInstallRoot(t)
+	defer cleanup()
+
+	assert.False(t, IsSideBySideLayout(installRoot))
+	assert.NoError(t, StageVersion(logger, installRoot, "2.0.0.0", extractTo("a")))
+	assert.NoError(t, StageVersion(logger, installRoot, "2.1.0.0", extractTo("b")))
+	assert.Error(t, SwitchCurrentVersion(logger, installRoot, "3.0.0.0"))
+
+	assert.NoError(t, SwitchCurrentVersion(logger, installRoot, "2.0.0.0"))
+	assert.True(t, IsSideBySideLayout(installRoot))
+	assert.True(t, fileExistsUnderCurrent(installRoot, "a"))
+
+	assert.NoError(t, SwitchCurrentVersion(logger, installRoot, "2.1.0.0"))
+	version, err := CurrentVersion(installRoot)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.1.0.0", version)
+	assert.True(t, fileExistsUnderCurrent(installRoot, "b"))
+	assert.False(t, fileExistsUnderCurrent(installRoot, "a"))
+}
+
+func TestStageVersionKeepsExistingVersion(t *testing.T) {
+	installRoot, cleanup := newTestInstallRoot(t)
+	defer cleanup()
+
+	assert.NoError(t, StageVersion(logger, installRoot, "2.0.0.0", extractTo("a")))
+	assert.NoError(t, StageVersion(logger, installRoot, "2.0.0.0", extractTo("b")))
+	_, err := os.Stat(filepath.Join(VersionInstallFolder(installRoot, "2.0.0.0"), "b"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStageVersionFailedExtractLeavesNothingBehind(t *testing.T) {
+	installRoot, cleanup := newTestInstallRoot(t)
+	defer cleanup()
+
+	err := StageVersion(logger, installRoot, "2.0.0.0", func(dest string) error {
+		extractTo("a")(dest)
+		return errors.New("interrupted")
+	})
+	assert.Error(t, err)
+	names, _ := installedVersions(installRoot)
+	assert.Empty(t, names)
+}
+
+func TestPruneVersions(t *testing.T) {
+	installRoot, cleanup := newTestInstallRoot(t)
+	defer cleanup()
+
+	for _, version := range []string{"1.0.0.0", "2.0.0.0", "2.1.0.0"} {
+		assert.NoError(t, StageVersion(logger, installRoot, version, extractTo("a")))
+	}
+	PruneVersions(logger, installRoot, "2.0.0.0", "2.1.0.0")
+
+	names, err := installedVersions(installRoot)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2.0.0.0", "2.1.0.0"}, names)
+}
+
+func fileExistsUnderCurrent(installRoot string, file string) bool {
+	_, err := os.Stat(filepath.Join(CurrentVersionLink(installRoot), file))
+	return err == nil
+}
+
+func installedVersions(installRoot string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(installRoot, VersionsFolderName))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, err
+}
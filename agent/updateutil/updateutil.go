@@ -106,6 +106,9 @@ const (
 	// PlatformSuse represents Raspbian
 	PlatformRaspbian = "raspbian"
 
+	// PlatformDarwin represents macOS
+	PlatformDarwin = "darwin"
+
 	// PlatformWindows represents windows
 	PlatformWindows = "windows"
 
@@ -119,6 +122,32 @@ const (
 	PipelineTestVersion = "255.0.0.0"
 )
 
+const (
+	// InstallSourceSnap represents an agent installed from the snap store
+	InstallSourceSnap = "snap"
+
+	// InstallSourceFlatpak represents an agent running inside a flatpak sandbox
+	InstallSourceFlatpak = "flatpak"
+
+	// InstallSourceDeb represents an agent installed from a debian package
+	InstallSourceDeb = "deb"
+
+	// InstallSourceRpm represents an agent installed from an rpm package
+	InstallSourceRpm = "rpm"
+
+	// InstallSourceMsi represents an agent installed from the windows installer
+	InstallSourceMsi = "msi"
+
+	// InstallSourceBrew represents an agent installed with homebrew
+	InstallSourceBrew = "brew"
+
+	// InstallSourceTarball represents an agent that is not owned by any known package manager
+	InstallSourceTarball = "tarball"
+
+	// flatpakInfoPath is present in the root of every flatpak sandbox
+	flatpakInfoPath = "/.flatpak-info"
+)
+
 //ErrorCode is types of Error Codes
 type ErrorCode string
 
@@ -191,6 +220,9 @@ type InstanceContext struct {
 	InstallerName   string
 	Arch            string
 	CompressFormat  string
+	InstallSource   string
+	// ImmutableOS is the read-only host the agent runs on, nil when the agent can write to its installation
+	ImmutableOS *platform.ImmutableOS
 }
 
 // T represents the interface for Update utility
@@ -218,6 +250,10 @@ var openFile = os.OpenFile
 var execCommand = exec.Command
 var cmdStart = (*exec.Cmd).Start
 var cmdOutput = (*exec.Cmd).Output
+var executablePath = os.Executable
+var fileExists = fileutil.Exists
+var detectImmutableOS = platform.DetectImmutableOS
+var isReadOnlyPath = platform.IsReadOnlyPath
 var isUsingSystemD map[string]string
 var once sync.Once
 
@@ -256,6 +292,7 @@ func (util *Utility) CreateInstanceContext(log log.T) (context *InstanceContext,
 	if platformName, err = getPlatformName(log); err != nil {
 		return
 	}
+	installSource := detectInstallSource(log)
 	// TODO: Change this structure to a switch and inject the platform name from another method.
 	platformName = strings.ToLower(platformName)
 	if strings.Contains(platformName, PlatformAmazonLinux) {
@@ -270,15 +307,9 @@ func (util *Utility) CreateInstanceContext(log log.T) (context *InstanceContext,
 		UnInstaller = UninstallScript
 	} else if strings.Contains(platformName, PlatformUbuntu) {
 		platformName = PlatformUbuntu
-		if isSnap, err := isAgentInstalledUsingSnap(log); err == nil && isSnap {
-			installerName = PlatformUbuntuSnap
-			Installer = SnapInstaller
-			UnInstaller = SnapUnInstaller
-		} else {
-			installerName = PlatformUbuntu
-			Installer = DebInstaller
-			UnInstaller = DebUnInstaller
-		}
+		installerName = PlatformUbuntu
+		Installer = DebInstaller
+		UnInstaller = DebUnInstaller
 	} else if strings.Contains(platformName, PlatformCentOS) {
 		platformName = PlatformCentOS
 		installerName = PlatformLinux
@@ -294,6 +325,12 @@ func (util *Utility) CreateInstanceContext(log log.T) (context *InstanceContext,
 		installerName = PlatformUbuntu
 		Installer = InstallScript
 		UnInstaller = UninstallScript
+	} else if runtime.GOOS == "darwin" {
+		// sw_vers reports macOS or Mac OS X depending on the version
+		platformName = PlatformDarwin
+		installerName = PlatformDarwin
+		Installer = InstallScript
+		UnInstaller = UninstallScript
 	} else if isNano, _ := platform.IsPlatformNanoServer(log); isNano {
 		//TODO move this logic to instance context
 		platformName = PlatformWindowsNano
@@ -307,6 +344,13 @@ func (util *Utility) CreateInstanceContext(log log.T) (context *InstanceContext,
 		UnInstaller = UninstallScript
 	}
 
+	// a snap install can only be updated by replacing the snap, whatever the distribution
+	if installSource == InstallSourceSnap {
+		installerName = PlatformUbuntuSnap
+		Installer = SnapInstaller
+		UnInstaller = SnapUnInstaller
+	}
+
 	if platformVersion, err = getPlatformVersion(log); err != nil {
 		return
 	}
@@ -317,11 +361,85 @@ func (util *Utility) CreateInstanceContext(log log.T) (context *InstanceContext,
 		InstallerName:   installerName,
 		Arch:            runtime.GOARCH,
 		CompressFormat:  CompressFormat,
+		InstallSource:   installSource,
+		ImmutableOS:     detectReadOnlyInstallation(log),
 	}
 
 	return context, nil
 }
 
+// IsSelfUpdateSupported returns false when the agent is owned by a package manager that
+// the updater cannot drive, in which case updates must go through that package manager,
+// or when the agent is installed on the read-only root filesystem of an immutable OS
+func (i *InstanceContext) IsSelfUpdateSupported() bool {
+	return i.InstallSource != InstallSourceBrew && i.InstallSource != InstallSourceFlatpak && i.ImmutableOS == nil
+}
+
+// SelfUpdateGuidance tells how to update the agent when it cannot update itself
+func (i *InstanceContext) SelfUpdateGuidance(agentName string) string {
+	if i.ImmutableOS != nil {
+		return i.ImmutableOS.Guidance(fmt.Sprintf("Updating %v in place", agentName))
+	}
+	return fmt.Sprintf("%v was installed using %v and cannot update itself, update it through %v instead", agentName, i.InstallSource, i.InstallSource)
+}
+
+// detectReadOnlyInstallation returns the immutable OS of the host when the agent can't replace its own binaries,
+// software under /var or bind mounted from it is still writable on hosts whose root is only mounted read-only
+func detectReadOnlyInstallation(log log.T) *platform.ImmutableOS {
+	immutable, ok := detectImmutableOS()
+	if !ok {
+		return nil
+	}
+	if immutable.Mechanism == platform.ImmutableOSMechanismVarBindMount {
+		if binaryPath, err := executablePath(); err == nil && !isReadOnlyPath(binaryPath) {
+			log.Debugf("Agent binary %v is writable on the read-only host", binaryPath)
+			return nil
+		}
+	}
+	log.Infof("Agent runs on the immutable OS %v installing software with %v", immutable.Name, immutable.Mechanism)
+	return &immutable
+}
+
+// detectInstallSource returns the mechanism that was used to install the running agent
+func detectInstallSource(log log.T) string {
+	if runtime.GOOS == "windows" {
+		return InstallSourceMsi
+	}
+	if fileExists(flatpakInfoPath) {
+		log.Debug("Agent is running inside a flatpak sandbox")
+		return InstallSourceFlatpak
+	}
+	if isSnap, err := isAgentInstalledUsingSnap(log); err == nil && isSnap {
+		return InstallSourceSnap
+	}
+
+	binaryPath, err := executablePath()
+	if err != nil {
+		log.Debugf("Failed to locate agent binary - %v", err)
+		return InstallSourceTarball
+	}
+	if resolved, err := filepath.EvalSymlinks(binaryPath); err == nil {
+		binaryPath = resolved
+	}
+	if runtime.GOOS == "darwin" {
+		if _, err := execCommand("brew", "list", "--versions", "amazon-ssm-agent").Output(); err == nil {
+			log.Debug("Agent is installed using brew")
+			return InstallSourceBrew
+		}
+		return InstallSourceTarball
+	}
+	if _, err := execCommand("dpkg", "-S", binaryPath).Output(); err == nil {
+		log.Debug("Agent is installed using dpkg")
+		return InstallSourceDeb
+	}
+	if _, err := execCommand("rpm", "-qf", binaryPath).Output(); err == nil {
+		log.Debug("Agent is installed using rpm")
+		return InstallSourceRpm
+	}
+	log.Debugf("%v is not owned by any known package manager", binaryPath)
+	return InstallSourceTarball
+}
+
 // isAgentInstalledUsingSnap returns if snap is used to install the snap
 func isAgentInstalledUsingSnap(log log.T) (result bool, err error) {
 
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/aws/amazon-ssm-agent/agent/appconfig"
 	"github.com/aws/amazon-ssm-agent/agent/fileutil"
 	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -123,6 +125,121 @@ func TestCreateInstanceContext(t *testing.T) {
 	}
 }
 
+func TestDetectInstallSource(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("package manager detection is only exercised on linux")
+	}
+	testCases := []struct {
+		flatpak   bool
+		installed string
+		expected  string
+	}{
+		{true, "snap", InstallSourceFlatpak},
+		{false, "snap", InstallSourceSnap},
+		{false, "dpkg", InstallSourceDeb},
+		{false, "rpm", InstallSourceRpm},
+		{false, "", InstallSourceTarball},
+	}
+	defer func() {
+		execCommand = exec.Command
+		fileExists = fileutil.Exists
+		executablePath = os.Executable
+	}()
+	executablePath = func() (string, error) { return "/usr/bin/amazon-ssm-agent", nil }
+
+	for _, test := range testCases {
+		installed := test.installed
+		fileExists = func(string) bool { return test.flatpak }
+		execCommand = func(command string, args ...string) *exec.Cmd {
+			if command == installed {
+				return exec.Command("true")
+			}
+			return exec.Command("false")
+		}
+		assert.Equal(t, test.expected, detectInstallSource(logger))
+	}
+}
+
+func TestCreateInstanceContextRoutesSnapInstall(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("package manager detection is only exercised on linux")
+	}
+	defer func() {
+		execCommand = exec.Command
+		fileExists = fileutil.Exists
+	}()
+	fileExists = func(string) bool { return false }
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		if command == "snap" {
+			return exec.Command("true")
+		}
+		return exec.Command("false")
+	}
+	getRegion = RegionStub
+	getPlatformName = PlatformNameStub
+	getPlatformVersion = PlatformVersionStub
+	context = testInstanceContext{"us-east-1", PlatformCentOS, nil, "7.1", nil, PlatformCentOS, PlatformUbuntuSnap, false}
+	util := Utility{}
+
+	instanceContext, err := util.CreateInstanceContext(logger)
+
+	assert.NoError(t, err)
+	assert.Equal(t, InstallSourceSnap, instanceContext.InstallSource)
+	assert.Equal(t, PlatformUbuntuSnap, instanceContext.InstallerName)
+	assert.Equal(t, SnapInstaller, Installer)
+	assert.True(t, instanceContext.IsSelfUpdateSupported())
+}
+
+func TestIsSelfUpdateSupported(t *testing.T) {
+	testCases := map[string]bool{
+		InstallSourceSnap:    true,
+		InstallSourceDeb:     true,
+		InstallSourceRpm:     true,
+		InstallSourceMsi:     true,
+		InstallSourceTarball: true,
+		InstallSourceBrew:    false,
+		InstallSourceFlatpak: false,
+	}
+	for source, expected := range testCases {
+		instanceContext := InstanceContext{InstallSource: source}
+		assert.Equal(t, expected, instanceContext.IsSelfUpdateSupported(), source)
+	}
+
+	instanceContext := InstanceContext{
+		InstallSource: InstallSourceRpm,
+		ImmutableOS:   &platform.ImmutableOS{Name: "fedora-coreos", Mechanism: platform.ImmutableOSMechanismRpmOstree},
+	}
+	assert.False(t, instanceContext.IsSelfUpdateSupported())
+	assert.Contains(t, instanceContext.SelfUpdateGuidance("amazon-ssm-agent"), "rpm-ostree install")
+}
+
+func TestDetectReadOnlyInstallation(t *testing.T) {
+	defer func() {
+		detectImmutableOS = platform.DetectImmutableOS
+		isReadOnlyPath = platform.IsReadOnlyPath
+		executablePath = os.Executable
+	}()
+	executablePath = func() (string, error) { return "/var/lib/amazon-ssm-agent/amazon-ssm-agent", nil }
+	readOnly := false
+	isReadOnlyPath = func(string) bool { return readOnly }
+
+	detectImmutableOS = func() (platform.ImmutableOS, bool) { return platform.ImmutableOS{}, false }
+	assert.Nil(t, detectReadOnlyInstallation(logger))
+
+	detectImmutableOS = func() (platform.ImmutableOS, bool) {
+		return platform.ImmutableOS{Name: "flatcar", Mechanism: platform.ImmutableOSMechanismVarBindMount}, true
+	}
+	assert.Nil(t, detectReadOnlyInstallation(logger))
+	readOnly = true
+	assert.Equal(t, "flatcar", detectReadOnlyInstallation(logger).Name)
+
+	detectImmutableOS = func() (platform.ImmutableOS, bool) {
+		return platform.ImmutableOS{Name: "bottlerocket", Mechanism: platform.ImmutableOSMechanismHostContainer}, true
+	}
+	readOnly = false
+	assert.Equal(t, platform.ImmutableOSMechanismHostContainer, detectReadOnlyInstallation(logger).Mechanism)
+}
+
 var context testInstanceContext
 
 func PlatformVersionStub(log log.T) (version string, err error) {
@@ -144,9 +261,9 @@ func TestFileNameConstruction(t *testing.T) {
 		context InstanceContext
 		result  string
 	}{
-		{InstanceContext{"us-east-1", "linux", "2015.9", "linux", "amd64", "tar.gz"}, "amazon-ssm-agent-linux-amd64.tar.gz"},
-		{InstanceContext{"us-east-1", "linux", "2015.9", "linux", "386", "tar.gz"}, "amazon-ssm-agent-linux-386.tar.gz"},
-		{InstanceContext{"us-west-1", "ubuntu", "12", "ubuntu", "386", "tar.gz"}, "amazon-ssm-agent-ubuntu-386.tar.gz"},
+		{InstanceContext{"us-east-1", "linux", "2015.9", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, "amazon-ssm-agent-linux-amd64.tar.gz"},
+		{InstanceContext{"us-east-1", "linux", "2015.9", "linux", "386", "tar.gz", InstallSourceRpm, nil}, "amazon-ssm-agent-linux-386.tar.gz"},
+		{InstanceContext{"us-west-1", "ubuntu", "12", "ubuntu", "386", "tar.gz", InstallSourceDeb, nil}, "amazon-ssm-agent-ubuntu-386.tar.gz"},
 	}
 
 	for _, test := range testCases {
@@ -325,11 +442,11 @@ func TestIsPlatformUsingSystemD(t *testing.T) {
 		context InstanceContext
 		result  bool
 	}{
-		{InstanceContext{"us-east-1", PlatformRedHat, "6.5", "linux", "amd64", "tar.gz"}, false},
-		{InstanceContext{"us-east-1", PlatformRedHat, "7.0", "linux", "amd64", "tar.gz"}, true},
-		{InstanceContext{"us-west-1", PlatformCentOS, "6.1", "linux", "amd64", "tar.gz"}, false},
-		{InstanceContext{"us-east-1", PlatformSuseOS, "12", "linux", "amd64", "tar.gz"}, true},
-		{InstanceContext{"us-west-1", PlatformCentOS, "7", "linux", "amd64", "tar.gz"}, true},
+		{InstanceContext{"us-east-1", PlatformRedHat, "6.5", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, false},
+		{InstanceContext{"us-east-1", PlatformRedHat, "7.0", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, true},
+		{InstanceContext{"us-west-1", PlatformCentOS, "6.1", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, false},
+		{InstanceContext{"us-east-1", PlatformSuseOS, "12", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, true},
+		{InstanceContext{"us-west-1", PlatformCentOS, "7", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, true},
 	}
 
 	for _, test := range testCases {
@@ -344,7 +461,7 @@ func TestIsPlatformUsingSystemDWithInvalidVersionNumber(t *testing.T) {
 		context InstanceContext
 		result  bool
 	}{
-		{InstanceContext{"us-east-1", PlatformRedHat, "wrong version", "linux", "amd64", "tar.gz"}, false},
+		{InstanceContext{"us-east-1", PlatformRedHat, "wrong version", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, false},
 	}
 
 	for _, test := range testCases {
@@ -358,7 +475,7 @@ func TestIsPlatformUsingSystemDWithPossiblyUsingSystemD(t *testing.T) {
 		context InstanceContext
 		result  bool
 	}{
-		{InstanceContext{"us-east-1", PlatformRaspbian, "8", "linux", "amd64", "tar.gz"}, true},
+		{InstanceContext{"us-east-1", PlatformRaspbian, "8", "linux", "amd64", "tar.gz", InstallSourceDeb, nil}, true},
 	}
 
 	// Stub exec.Command
@@ -378,9 +495,9 @@ func TestIsServiceRunning(t *testing.T) {
 		result  bool
 	}{
 		// test system with upstart
-		{InstanceContext{"us-east-1", PlatformRedHat, "6.5", "linux", "amd64", "tar.gz"}, true},
+		{InstanceContext{"us-east-1", PlatformRedHat, "6.5", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, true},
 		// test system with systemD
-		{InstanceContext{"us-east-1", PlatformRedHat, "7.1", "linux", "amd64", "tar.gz"}, true},
+		{InstanceContext{"us-east-1", PlatformRedHat, "7.1", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}, true},
 	}
 
 	// Stub exec.Command
@@ -398,9 +515,9 @@ func TestIsServiceRunningWithErrorMessageFromCommandExec(t *testing.T) {
 		context InstanceContext
 	}{
 		// test system with upstart
-		{InstanceContext{"us-east-1", PlatformRedHat, "6.5", "linux", "amd64", "tar.gz"}},
+		{InstanceContext{"us-east-1", PlatformRedHat, "6.5", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}},
 		// test system with systemD
-		{InstanceContext{"us-east-1", PlatformRedHat, "7.1", "linux", "amd64", "tar.gz"}},
+		{InstanceContext{"us-east-1", PlatformRedHat, "7.1", "linux", "amd64", "tar.gz", InstallSourceRpm, nil}},
 	}
 
 	// Stub exec.Command
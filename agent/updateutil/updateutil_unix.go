@@ -17,7 +17,9 @@
 package updateutil
 
 import (
+	"os"
 	"os/exec"
+	"runtime"
 	"syscall"
 )
 
@@ -62,6 +64,11 @@ const (
 	OutputBucketNameCmd = "output.bucket"
 )
 
+const (
+	// AgentLaunchdService is the launchd service of the agent on macOS, in the system domain
+	AgentLaunchdService = "system/com.amazon.aws.ssm"
+)
+
 const (
 	// CompressFormat represents the compress format for linux platform
 	CompressFormat = "tar.gz"
@@ -80,13 +87,24 @@ func prepareProcess(command *exec.Cmd) {
 }
 
 func agentStatusOutput() ([]byte, error) {
+	if runtime.GOOS == "darwin" {
+		return execCommand("launchctl", "print", AgentLaunchdService).Output()
+	}
 	return execCommand("status", "amazon-ssm-agent").Output()
 }
 
 func agentExpectedStatus() string {
+	if runtime.GOOS == "darwin" {
+		return "state = running"
+	}
 	return "amazon-ssm-agent start/running"
 }
 
 func setPlatformSpecificCommand(parts []string) []string {
 	return parts
 }
+
+// replaceLink atomically renames newLink over link
+func replaceLink(newLink string, link string) error {
+	return os.Rename(newLink, link)
+}
@@ -96,3 +96,12 @@ func setPlatformSpecificCommand(parts []string) []string {
 	cmd := filepath.Join(os.Getenv("SystemRoot"), "System32", "WindowsPowerShell", "v1.0", "powershell.exe") + " -ExecutionPolicy unrestricted"
 	return append(strings.Split(cmd, " "), parts...)
 }
+
+// replaceLink renames newLink over link, windows cannot rename over a directory link
+// so the old link is removed first
+func replaceLink(newLink string, link string) error {
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(newLink, link)
+}
@@ -0,0 +1,326 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+const (
+	// bundleSequenceFileName is the file of the store with the sequence of the last bundle exported by the instance
+	bundleSequenceFileName = "spool.sequence"
+	// importLedgerDirName is the folder of the data of the agent with the sequence of the last bundle imported
+	// from every instance
+	importLedgerDirName  = "spool"
+	importLedgerFileName = "imported.json"
+	// minSigningKeyLength is the shortest secret the bundles are signed with
+	minSigningKeyLength = 16
+)
+
+// bundle is the content of an export of the spool of an instance
+type bundle struct {
+	InstanceID string `json:"instanceId"`
+	// Sequence increases with every export of the instance, a bundle is imported once and after the bundles before it
+	Sequence    uint64          `json:"sequence"`
+	CreatedTime time.Time       `json:"createdTime"`
+	Uploads     []bundledUpload `json:"uploads"`
+}
+
+// bundledUpload is a spooled upload with the content of its file for the uploads to s3
+type bundledUpload struct {
+	Upload
+	Content []byte `json:"content,omitempty"`
+}
+
+// signedBundle is the gzip compressed json file of an export, the signature is the HMAC-SHA256 of the bundle
+type signedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"`
+}
+
+// BundleSummary describes a bundle exported by ExportSpool or imported by ImportSpool
+type BundleSummary struct {
+	InstanceID string
+	Sequence   uint64
+	Uploads    int
+	// Skipped are the imported uploads of a kind the ssm-cli can't do, for example the inventory of the instance
+	Skipped int
+}
+
+// ExportSpool writes the uploads spooled by an instance to a signed bundle and removes them from the spool
+func ExportSpool(log log.T, config appconfig.SpoolCfg, instanceID string, path string) (result BundleSummary, err error) {
+	key, err := readSigningKey(config.SigningKeyPath)
+	if err != nil {
+		return result, err
+	}
+	s := &store{dir: storeDir(instanceID)}
+	uploads := s.load(log)
+	if len(uploads) == 0 {
+		return result, errors.New("there are no spooled uploads to export")
+	}
+	sequence, err := s.lastSequence()
+	if err != nil {
+		return result, err
+	}
+	content := bundle{InstanceID: instanceID, Sequence: sequence + 1, CreatedTime: time.Now().UTC()}
+	for _, upload := range uploads {
+		bundled := bundledUpload{Upload: upload}
+		if upload.Kind == KindS3Object {
+			if bundled.Content, err = readObjectFile(upload.Payload); err != nil {
+				return result, fmt.Errorf("failed to read the file of the upload of %v: %v", upload.Description, err)
+			}
+		}
+		content.Uploads = append(content.Uploads, bundled)
+	}
+	if err = writeBundle(path, content, key); err != nil {
+		return result, err
+	}
+	if err = s.saveSequence(content.Sequence); err != nil {
+		os.Remove(path)
+		return result, err
+	}
+	for _, upload := range uploads {
+		if err := s.remove(upload.ID); err != nil {
+			log.Warnf("Failed to remove the exported upload of %v: %v", upload.Description, err)
+		}
+		os.Remove(filepath.Join(s.dir, spoolFilesDirName, upload.ID))
+	}
+	return BundleSummary{InstanceID: instanceID, Sequence: content.Sequence, Uploads: len(uploads)}, nil
+}
+
+// ImportSpool verifies the signature of a bundle and does its uploads. A bundle is rejected when the agent already
+// imported it, or a later bundle, of the same instance. The sequence of the bundle is recorded once its uploads
+// are done, an import that fails can be retried and sends again the uploads done before the failure.
+func ImportSpool(log log.T, config appconfig.SpoolCfg, path string) (result BundleSummary, err error) {
+	key, err := readSigningKey(config.SigningKeyPath)
+	if err != nil {
+		return result, err
+	}
+	content, err := readBundle(path, key)
+	if err != nil {
+		return result, err
+	}
+	result = BundleSummary{InstanceID: content.InstanceID, Sequence: content.Sequence}
+	ledger, err := loadLedger()
+	if err != nil {
+		return result, err
+	}
+	if last := ledger[content.InstanceID]; content.Sequence <= last {
+		return result, fmt.Errorf("bundle %v of %v was already imported, the last imported bundle is %v",
+			content.Sequence, content.InstanceID, last)
+	}
+
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		return result, err
+	}
+	defer os.RemoveAll(dir)
+	for _, upload := range content.Uploads {
+		handler := handlerOf(upload.Kind)
+		if handler == nil {
+			log.Warnf("Skipping the upload of %v, the ssm-cli does not do the %v uploads", upload.Description, upload.Kind)
+			result.Skipped++
+			continue
+		}
+		payload := []byte(upload.Payload)
+		if upload.Kind == KindS3Object {
+			if payload, err = restoreObjectFile(dir, upload); err != nil {
+				return result, err
+			}
+		}
+		if err = handler(log, payload); err != nil {
+			return result, fmt.Errorf("failed to upload %v: %v", upload.Description, err)
+		}
+		result.Uploads++
+	}
+
+	ledger[content.InstanceID] = content.Sequence
+	return result, saveLedger(ledger)
+}
+
+// readSigningKey reads the secret the bundles are signed with
+func readSigningKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("the signing key of the spool is not set in the agent configuration")
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the signing key of the spool: %v", err)
+	}
+	key := bytes.TrimSpace(content)
+	if len(key) < minSigningKeyLength {
+		return nil, fmt.Errorf("the signing key of the spool is shorter than %v bytes", minSigningKeyLength)
+	}
+	return key, nil
+}
+
+func sign(content []byte, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeBundle writes a signed bundle to a temporary file renamed over the file of the export
+func writeBundle(path string, content bundle, key []byte) error {
+	raw, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	signed, err := json.Marshal(signedBundle{Bundle: raw, Signature: sign(raw, key)})
+	if err != nil {
+		return err
+	}
+	temporary := path + ".tmp"
+	file, err := os.OpenFile(temporary, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(file)
+	_, err = writer.Write(signed)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return os.Rename(temporary, path)
+}
+
+// readBundle reads a bundle and verifies its signature
+func readBundle(path string, key []byte) (content bundle, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return content, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return content, fmt.Errorf("%v is not a spool bundle: %v", path, err)
+	}
+	var signed signedBundle
+	if err = json.NewDecoder(reader).Decode(&signed); err != nil {
+		return content, fmt.Errorf("%v is not a spool bundle: %v", path, err)
+	}
+	if !hmac.Equal([]byte(signed.Signature), []byte(sign(signed.Bundle, key))) {
+		return content, fmt.Errorf("the signature of %v does not match the signing key of the spool", path)
+	}
+	err = json.Unmarshal(signed.Bundle, &content)
+	return content, err
+}
+
+// readObjectFile returns the content of the file of a spooled upload to s3
+func readObjectFile(payload []byte) ([]byte, error) {
+	var object S3Object
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(object.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err = file.Seek(object.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if object.Length > 0 {
+		return ioutil.ReadAll(io.LimitReader(file, object.Length))
+	}
+	return ioutil.ReadAll(file)
+}
+
+// restoreObjectFile writes the content of an imported upload to s3 to a file and returns the payload of the upload
+func restoreObjectFile(dir string, upload bundledUpload) ([]byte, error) {
+	var object S3Object
+	if err := json.Unmarshal(upload.Payload, &object); err != nil {
+		return nil, err
+	}
+	object.FilePath = filepath.Join(dir, filepath.Base(upload.ID))
+	object.Offset, object.Length = 0, 0
+	if err := ioutil.WriteFile(object.FilePath, upload.Content, appconfig.ReadWriteAccess); err != nil {
+		return nil, err
+	}
+	return json.Marshal(object)
+}
+
+// lastSequence returns the sequence of the last bundle exported from the store, 0 before the first export
+func (s *store) lastSequence() (uint64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(s.dir, bundleSequenceFileName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+}
+
+func (s *store) saveSequence(sequence uint64) error {
+	return ioutil.WriteFile(filepath.Join(s.dir, bundleSequenceFileName), []byte(strconv.FormatUint(sequence, 10)), appconfig.ReadWriteAccess)
+}
+
+func ledgerPath() string {
+	return filepath.Join(dataStorePath, importLedgerDirName, importLedgerFileName)
+}
+
+// loadLedger returns the sequence of the last bundle imported from every instance
+func loadLedger() (map[string]uint64, error) {
+	ledger := make(map[string]uint64)
+	content, err := ioutil.ReadFile(ledgerPath())
+	if os.IsNotExist(err) {
+		return ledger, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &ledger); err != nil {
+		return nil, fmt.Errorf("failed to read the imported bundles: %v", err)
+	}
+	return ledger, nil
+}
+
+// saveLedger writes the ledger to a temporary file renamed over its file
+func saveLedger(ledger map[string]uint64) error {
+	content, err := json.Marshal(ledger)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirsWithExecuteAccess(filepath.Dir(ledgerPath())); err != nil {
+		return err
+	}
+	temporary := ledgerPath() + ".tmp"
+	if err = ioutil.WriteFile(temporary, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(temporary, ledgerPath())
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/platform"
+)
+
+// presignTimeout bounds the request of a presigned url, the upload itself is not bounded
+const presignTimeout = 30 * time.Second
+
+// presignRequest is the body posted to the signer endpoint
+type presignRequest struct {
+	Bucket        string `json:"bucket"`
+	Key           string `json:"key"`
+	ContentLength int64  `json:"contentLength"`
+	InstanceID    string `json:"instanceId"`
+	// IdentityDocument is the pkcs7 signature of the identity document of an EC2 instance, which authenticates it to
+	// the signer. Managed instances have none.
+	IdentityDocument string `json:"identityDocument,omitempty"`
+}
+
+// presignResponse is the answer of the signer endpoint
+type presignResponse struct {
+	URL string `json:"url"`
+	// Headers are the headers the url is signed with, they are sent with the upload
+	Headers map[string]string `json:"headers"`
+}
+
+// presignedUploader uploads to s3 through the urls presigned by a signer endpoint
+type presignedUploader struct {
+	signerEndpoint string
+	client         *http.Client
+}
+
+// newPresignedUploader returns the uploader through presigned urls when the configuration enables it, it is
+// replaced in unit tests
+var newPresignedUploader = func() (*presignedUploader, bool) {
+	config, err := appconfig.Config(false)
+	if err != nil || !config.Uploads.Presigned.Enabled {
+		return nil, false
+	}
+	return &presignedUploader{signerEndpoint: config.Uploads.Presigned.SignerEndpoint, client: &http.Client{}}, true
+}
+
+// instanceIdentity returns the id of the instance and the signature of its identity document, it is replaced in
+// unit tests
+var instanceIdentity = func() (instanceID string, identityDocument string, err error) {
+	if instanceID, err = platform.InstanceID(); err != nil {
+		return "", "", err
+	}
+	if signature, err := platform.NewEC2MetadataClient().ReadResource(platform.SignedInstanceIdentityDocumentResource); err == nil {
+		identityDocument = strings.TrimSpace(string(signature))
+	}
+	return instanceID, identityDocument, nil
+}
+
+// upload requests a presigned url for the object and puts the content of length bytes to it
+func (u *presignedUploader) upload(log log.T, bucketName string, objectKey string, reader io.Reader, length int64) error {
+	presigned, err := u.presign(bucketName, objectKey, length)
+	if err != nil {
+		return fmt.Errorf("failed to presign the upload to s3://%v/%v: %v", bucketName, objectKey, err)
+	}
+	request, err := http.NewRequest(http.MethodPut, presigned.URL, reader)
+	if err != nil {
+		return err
+	}
+	// s3 does not accept chunked uploads to presigned urls
+	request.ContentLength = length
+	if length == 0 {
+		request.Body = http.NoBody
+	}
+	for name, value := range presigned.Headers {
+		request.Header.Set(name, value)
+	}
+	log.Debugf("Uploading to s3://%v/%v through a presigned url", bucketName, objectKey)
+	response, err := u.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("upload to s3://%v/%v through a presigned url failed with %v: %v", bucketName, objectKey, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// presign requests the url of an upload from the signer endpoint
+func (u *presignedUploader) presign(bucketName string, objectKey string, length int64) (*presignResponse, error) {
+	if endpoint, err := url.Parse(u.signerEndpoint); err != nil || endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("signer endpoint %v is not an https url", u.signerEndpoint)
+	}
+	instanceID, identityDocument, err := instanceIdentity()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(presignRequest{
+		Bucket:           bucketName,
+		Key:              objectKey,
+		ContentLength:    length,
+		InstanceID:       instanceID,
+		IdentityDocument: identityDocument,
+	})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, u.signerEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	client := *u.client
+	client.Timeout = presignTimeout
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signer endpoint returned %v: %v", response.Status, strings.TrimSpace(string(content)))
+	}
+	var presigned presignResponse
+	if err = json.Unmarshal(content, &presigned); err != nil {
+		return nil, fmt.Errorf("invalid answer of the signer endpoint: %v", err)
+	}
+	if presignedURL, err := url.Parse(presigned.URL); err != nil || presignedURL.Scheme != "https" {
+		return nil, fmt.Errorf("signer endpoint returned %q, which is not an https url", presigned.URL)
+	}
+	return &presigned, nil
+}
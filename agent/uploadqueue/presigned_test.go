@@ -0,0 +1,92 @@
+// Copyright 2024 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startPresignTest serves a signer endpoint presigning the uploads to the /s3 path of the same server, which stores
+// the uploaded objects
+func startPresignTest(t *testing.T) (server *httptest.Server, requests *[]presignRequest, objects map[string]string, restore func()) {
+	requests = &[]presignRequest{}
+	objects = make(map[string]string)
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/presign":
+			var request presignRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			*requests = append(*requests, request)
+			if request.Bucket == "denied" {
+				http.Error(w, "not allowed", http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(presignResponse{
+				URL:     "https://" + r.Host + "/s3/" + request.Bucket + "/" + request.Key + "?X-Amz-Signature=abc",
+				Headers: map[string]string{"x-amz-server-side-encryption": "AES256"},
+			})
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/s3/"):
+			assert.Equal(t, "AES256", r.Header.Get("x-amz-server-side-encryption"))
+			assert.Empty(t, r.TransferEncoding)
+			content, _ := ioutil.ReadAll(r.Body)
+			objects[strings.TrimPrefix(r.URL.Path, "/s3/")] = string(content)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	savedUploader, savedIdentity := newPresignedUploader, instanceIdentity
+	newPresignedUploader = func() (*presignedUploader, bool) {
+		return &presignedUploader{signerEndpoint: server.URL + "/presign", client: server.Client()}, true
+	}
+	instanceIdentity = func() (string, string, error) { return "i-0123456789abcdef0", "MIAGCSqGSIb3", nil }
+	return server, requests, objects, func() {
+		newPresignedUploader, instanceIdentity = savedUploader, savedIdentity
+		server.Close()
+	}
+}
+
+func TestUploadFileThroughPresignedUrl(t *testing.T) {
+	_, requests, objects, restore := startPresignTest(t)
+	defer restore()
+	file, err := ioutil.TempFile("", "stdout")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("0123456789abcdef")
+	file.Close()
+
+	assert.NoError(t, UploadFile(logger, PriorityResults, S3Object{Bucket: "bucket", Key: "prefix/stdout", FilePath: file.Name()}))
+	assert.NoError(t, UploadFile(logger, PriorityResults, S3Object{Bucket: "bucket", Key: "prefix/part-2", FilePath: file.Name(), Offset: 10}))
+
+	assert.Equal(t, map[string]string{"bucket/prefix/stdout": "0123456789abcdef", "bucket/prefix/part-2": "abcdef"}, objects)
+	assert.Equal(t, presignRequest{Bucket: "bucket", Key: "prefix/stdout", ContentLength: 16, InstanceID: "i-0123456789abcdef0", IdentityDocument: "MIAGCSqGSIb3"}, (*requests)[0])
+	assert.Equal(t, int64(6), (*requests)[1].ContentLength)
+
+	err = UploadFile(logger, PriorityResults, S3Object{Bucket: "denied", Key: "stdout", FilePath: file.Name()})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not allowed")
+}
+
+func TestPresignRequiresHttps(t *testing.T) {
+	uploader := &presignedUploader{signerEndpoint: "http://signer.example.com/presign", client: &http.Client{}}
+	_, err := uploader.presign("bucket", "key", 1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,339 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
+)
+
+// retryDelay is the wait before the first retry of a failed upload, doubled at every retry
+var retryDelay = 5 * time.Second
+
+// stopTimeout is how long Stop waits for the running uploads, the saved uploads that don't complete resume after a restart
+var stopTimeout = 30 * time.Second
+
+var (
+	errStopped   = errors.New("the upload queue stopped before the upload completed")
+	errQueueFull = errors.New("the upload queue is full")
+)
+
+// PriorityStats are the metrics of the uploads of a priority
+type PriorityStats struct {
+	Priority  string `json:"priority"`
+	Queued    int    `json:"queued"`
+	Running   int    `json:"running"`
+	Completed int64  `json:"completed"`
+	Retried   int64  `json:"retried"`
+	Failed    int64  `json:"failed"`
+	// Dropped are the uploads rejected, or replaced by an upload of a higher priority, because the queue was full
+	Dropped int64 `json:"dropped"`
+	// Bytes is the size of the completed uploads
+	Bytes int64 `json:"bytes"`
+	// Spooled are the uploads saved for an export while the agent is disconnected
+	Spooled int64 `json:"spooled,omitempty"`
+}
+
+// entry is an upload in the queue
+type entry struct {
+	upload Upload
+	// run does the uploads that are not saved, the saved uploads are done by the handler of their kind
+	run func() error
+	seq uint64
+	// notBefore delays the retry of a failed upload
+	notBefore time.Time
+	// done receives the outcome of the upload, it is nil for the uploads resumed after a restart
+	done chan error
+}
+
+// before returns whether the entry goes before the other one
+func (e *entry) before(other *entry) bool {
+	if e.upload.Priority != other.upload.Priority {
+		return e.upload.Priority < other.upload.Priority
+	}
+	return e.seq < other.seq
+}
+
+// ready returns whether the entry can be uploaded now
+func (e *entry) ready(now time.Time) bool {
+	return !e.notBefore.After(now) && (e.run != nil || handlerOf(e.upload.Kind) != nil)
+}
+
+type queue struct {
+	log     log.T
+	config  appconfig.UploadsCfg
+	store   *store
+	limiter *throttle.RateLimiter
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	pending []*entry
+	seq     uint64
+	stopped bool
+	metrics []PriorityStats
+	workers sync.WaitGroup
+}
+
+func newQueue(log log.T, config appconfig.UploadsCfg, store *store) *queue {
+	q := &queue{
+		log:     log,
+		config:  config,
+		store:   store,
+		metrics: make([]PriorityStats, len(priorityNames)),
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	if config.BandwidthKBps > 0 {
+		q.limiter = throttle.NewRateLimiter(int64(config.BandwidthKBps) * 1024)
+	}
+	return q
+}
+
+// resume queues the uploads saved before the agent stopped, in the order they were queued
+func (q *queue) resume() {
+	uploads := q.store.load(q.log)
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, upload := range uploads {
+		q.seq++
+		q.pending = append(q.pending, &entry{upload: upload, seq: q.seq})
+		q.metrics[upload.Priority.index()].Queued++
+	}
+	if len(uploads) > 0 {
+		q.log.Infof("Resuming %v uploads queued before the agent stopped", len(uploads))
+	}
+}
+
+func (q *queue) start() {
+	for i := 0; i < q.config.MaxConcurrentUploads; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+}
+
+// stop stops the workers and fails the queued uploads, whose saved copy resumes after a restart
+func (q *queue) stop() {
+	q.mutex.Lock()
+	q.stopped = true
+	for _, e := range q.pending {
+		q.metrics[e.upload.Priority.index()].Queued--
+		if e.done != nil {
+			e.done <- errStopped
+		}
+	}
+	q.pending = nil
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		q.log.Warnf("Stopped the upload queue before the running uploads completed")
+	}
+}
+
+// enqueue adds an entry to the queue and returns the channel of its outcome. When the queue is full, the entry
+// replaces the last queued entry of the lowest priority if it has a higher priority, or it is rejected.
+func (q *queue) enqueue(e *entry) (chan error, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.stopped {
+		return nil, errStopped
+	}
+	metrics := &q.metrics[e.upload.Priority.index()]
+	if len(q.pending) >= q.config.MaxQueuedUploads {
+		last := q.lowest()
+		if last < 0 || q.pending[last].upload.Priority <= e.upload.Priority {
+			metrics.Dropped++
+			return nil, errQueueFull
+		}
+		q.drop(last)
+	}
+	if e.upload.ID == "" {
+		e.upload.ID = fmt.Sprintf("%v-%v", time.Now().UnixNano(), q.seq+1)
+	}
+	if e.upload.QueuedTime.IsZero() {
+		e.upload.QueuedTime = time.Now().UTC()
+	}
+	if e.run == nil {
+		if err := q.store.save(e.upload); err != nil {
+			q.log.Warnf("Failed to save the upload of %v, it does not resume after a restart: %v", e.upload.Description, err)
+		}
+	}
+	q.seq++
+	e.seq = q.seq
+	e.done = make(chan error, 1)
+	q.pending = append(q.pending, e)
+	metrics.Queued++
+	q.cond.Signal()
+	return e.done, nil
+}
+
+// wait returns the outcome of an entry added by enqueue
+func (q *queue) wait(done chan error, err error) error {
+	if err != nil {
+		return err
+	}
+	return <-done
+}
+
+// lowest returns the index of the queued entry that goes last, -1 when the queue is empty
+func (q *queue) lowest() int {
+	last := -1
+	for i, e := range q.pending {
+		if last < 0 || q.pending[last].before(e) {
+			last = i
+		}
+	}
+	return last
+}
+
+// drop removes a queued entry to make room for an upload of a higher priority
+func (q *queue) drop(i int) {
+	e := q.pending[i]
+	q.pending = append(q.pending[:i], q.pending[i+1:]...)
+	metrics := &q.metrics[e.upload.Priority.index()]
+	metrics.Queued--
+	metrics.Dropped++
+	q.log.Warnf("Dropped the upload of %v, the upload queue is full", e.upload.Description)
+	q.finish(e, errQueueFull)
+}
+
+// finish removes the saved copy of an entry and hands over its outcome
+func (q *queue) finish(e *entry, err error) {
+	if e.run == nil {
+		if removeErr := q.store.remove(e.upload.ID); removeErr != nil {
+			q.log.Warnf("Failed to remove the saved upload of %v: %v", e.upload.Description, removeErr)
+		}
+	}
+	if e.done != nil {
+		e.done <- err
+	}
+}
+
+// wake lets the workers look for an entry whose retry is due or whose handler was registered
+func (q *queue) wake() {
+	q.mutex.Lock()
+	q.cond.Broadcast()
+	q.mutex.Unlock()
+}
+
+func (q *queue) work() {
+	defer q.workers.Done()
+	for {
+		e := q.next()
+		if e == nil {
+			return
+		}
+		q.complete(e, q.upload(e))
+	}
+}
+
+// next waits for the first entry that is ready to upload, it returns nil once the queue is stopped
+func (q *queue) next() *entry {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for !q.stopped {
+		now := time.Now()
+		first := -1
+		for i, e := range q.pending {
+			if e.ready(now) && (first < 0 || e.before(q.pending[first])) {
+				first = i
+			}
+		}
+		if first >= 0 {
+			e := q.pending[first]
+			q.pending = append(q.pending[:first], q.pending[first+1:]...)
+			metrics := &q.metrics[e.upload.Priority.index()]
+			metrics.Queued--
+			metrics.Running++
+			return e
+		}
+		q.cond.Wait()
+	}
+	return nil
+}
+
+// upload does an entry with its handler
+func (q *queue) upload(e *entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("upload panicked: %v", r)
+		}
+	}()
+	e.upload.Attempts++
+	if e.run != nil {
+		return e.run()
+	}
+	return handlerOf(e.upload.Kind)(q.log, e.upload.Payload)
+}
+
+// complete records the outcome of an upload, the failed saved uploads are retried with a backoff until they
+// run out of attempts
+func (q *queue) complete(e *entry, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	metrics := &q.metrics[e.upload.Priority.index()]
+	metrics.Running--
+	switch {
+	case err == nil:
+		metrics.Completed++
+		metrics.Bytes += e.upload.Size
+		q.finish(e, nil)
+	case e.run == nil && e.upload.Attempts < q.config.MaxAttempts && q.stopped:
+		// the saved copy resumes after a restart
+		if e.done != nil {
+			e.done <- err
+		}
+	case e.run == nil && e.upload.Attempts < q.config.MaxAttempts:
+		q.log.Warnf("Failed to upload %v, retrying: %v", e.upload.Description, err)
+		metrics.Retried++
+		metrics.Queued++
+		delay := retryDelay << uint(e.upload.Attempts-1)
+		e.notBefore = time.Now().Add(delay)
+		if saveErr := q.store.save(e.upload); saveErr != nil {
+			q.log.Warnf("Failed to save the upload of %v: %v", e.upload.Description, saveErr)
+		}
+		q.pending = append(q.pending, e)
+		time.AfterFunc(delay, q.wake)
+	default:
+		metrics.Failed++
+		if e.run == nil {
+			q.log.Errorf("Failed to upload %v after %v attempts: %v", e.upload.Description, e.upload.Attempts, err)
+		}
+		q.finish(e, err)
+	}
+}
+
+// stats returns a copy of the metrics
+func (q *queue) stats() []PriorityStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	stats := make([]PriorityStats, len(q.metrics))
+	copy(stats, q.metrics)
+	for i := range stats {
+		stats[i].Priority = priorityNames[i]
+	}
+	return stats
+}
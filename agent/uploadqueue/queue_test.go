@@ -0,0 +1,223 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var logger = log.NewMockLog()
+
+var testConfig = appconfig.UploadsCfg{MaxConcurrentUploads: 1, MaxAttempts: 2, MaxQueuedUploads: 10}
+
+// recorder records the uploads done by its handler and fails the first attempts of some of them
+type recorder struct {
+	mutex    sync.Mutex
+	uploaded []string
+	failures map[string]int
+}
+
+func (r *recorder) handle(log log.T, payload []byte) error {
+	var name string
+	if err := json.Unmarshal(payload, &name); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.failures[name] > 0 {
+		r.failures[name]--
+		return errors.New("upload failed")
+	}
+	r.uploaded = append(r.uploaded, name)
+	return nil
+}
+
+func (r *recorder) done() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.uploaded...)
+}
+
+func newTestQueue(t *testing.T, config appconfig.UploadsCfg) (*queue, *recorder, func()) {
+	dir, err := ioutil.TempDir("", "uploadqueue")
+	assert.NoError(t, err)
+	r := &recorder{failures: make(map[string]int)}
+	RegisterHandler("test", r.handle)
+	savedDelay := retryDelay
+	retryDelay = time.Millisecond
+	return newQueue(logger, config, &store{dir: dir}), r, func() {
+		retryDelay = savedDelay
+		os.RemoveAll(dir)
+	}
+}
+
+func testUpload(name string, priority Priority) *entry {
+	payload, _ := json.Marshal(name)
+	return &entry{upload: Upload{Kind: "test", Priority: priority, Description: name, Size: int64(len(name)), Payload: payload}}
+}
+
+func TestUploadsByPriority(t *testing.T) {
+	q, r, cleanup := newTestQueue(t, testConfig)
+	defer cleanup()
+
+	var outcomes []chan error
+	for _, e := range []*entry{
+		testUpload("telemetry", PriorityTelemetry),
+		testUpload("inventory", PriorityInventory),
+		testUpload("result-1", PriorityResults),
+		testUpload("session", PrioritySessionLogs),
+		testUpload("result-2", PriorityResults),
+	} {
+		done, err := q.enqueue(e)
+		assert.NoError(t, err)
+		outcomes = append(outcomes, done)
+	}
+	q.start()
+	for _, done := range outcomes {
+		assert.NoError(t, <-done)
+	}
+	q.stop()
+
+	assert.Equal(t, []string{"result-1", "result-2", "session", "inventory", "telemetry"}, r.done())
+	stats := q.stats()
+	assert.Equal(t, PriorityStats{Priority: "Results", Completed: 2, Bytes: 16}, stats[PriorityResults])
+	assert.Equal(t, PriorityStats{Priority: "Telemetry", Completed: 1, Bytes: 9}, stats[PriorityTelemetry])
+}
+
+func TestRetryFailedUpload(t *testing.T) {
+	q, r, cleanup := newTestQueue(t, testConfig)
+	defer cleanup()
+	r.failures["flaky"] = 1
+	r.failures["broken"] = 2
+	q.start()
+	defer q.stop()
+
+	assert.NoError(t, q.wait(q.enqueue(testUpload("flaky", PriorityResults))))
+	assert.Error(t, q.wait(q.enqueue(testUpload("broken", PriorityResults))))
+
+	assert.Equal(t, []string{"flaky"}, r.done())
+	assert.Equal(t, PriorityStats{Priority: "Results", Completed: 1, Retried: 2, Failed: 1, Bytes: 5}, q.stats()[PriorityResults])
+	assert.Empty(t, q.store.load(logger), "the saved copies are removed once the uploads are done")
+}
+
+func TestRunIsTriedOnce(t *testing.T) {
+	q, _, cleanup := newTestQueue(t, testConfig)
+	defer cleanup()
+	q.start()
+	defer q.stop()
+
+	attempts := 0
+	err := q.wait(q.enqueue(&entry{upload: Upload{Priority: PriorityTelemetry}, run: func() error {
+		attempts++
+		return errors.New("export failed")
+	}}))
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestQueueFull(t *testing.T) {
+	config := testConfig
+	config.MaxQueuedUploads = 2
+	q, r, cleanup := newTestQueue(t, config)
+	defer cleanup()
+
+	telemetry, err := q.enqueue(testUpload("telemetry", PriorityTelemetry))
+	assert.NoError(t, err)
+	inventory, err := q.enqueue(testUpload("inventory", PriorityInventory))
+	assert.NoError(t, err)
+
+	_, err = q.enqueue(testUpload("more telemetry", PriorityTelemetry))
+	assert.Equal(t, errQueueFull, err, "an upload is rejected when the queued uploads don't have a lower priority")
+
+	result, err := q.enqueue(testUpload("result", PriorityResults))
+	assert.NoError(t, err)
+	assert.Equal(t, errQueueFull, <-telemetry, "the last upload of the lowest priority makes room for an upload of a higher priority")
+
+	q.start()
+	assert.NoError(t, <-result)
+	assert.NoError(t, <-inventory)
+	q.stop()
+
+	assert.Equal(t, []string{"result", "inventory"}, r.done())
+	assert.Equal(t, int64(2), q.stats()[PriorityTelemetry].Dropped)
+}
+
+func TestResumeSavedUploads(t *testing.T) {
+	q, r, cleanup := newTestQueue(t, testConfig)
+	defer cleanup()
+
+	first, err := q.enqueue(testUpload("inventory", PriorityInventory))
+	assert.NoError(t, err)
+	second, err := q.enqueue(testUpload("result", PriorityResults))
+	assert.NoError(t, err)
+	q.stop()
+	assert.Equal(t, errStopped, <-first)
+	assert.Equal(t, errStopped, <-second)
+
+	restarted := newQueue(logger, testConfig, q.store)
+	restarted.resume()
+	assert.Equal(t, 1, restarted.stats()[PriorityResults].Queued)
+	restarted.start()
+	for deadline := time.Now().Add(time.Second); len(r.done()) < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	restarted.stop()
+
+	assert.Equal(t, []string{"result", "inventory"}, r.done())
+	assert.Empty(t, restarted.store.load(logger))
+}
+
+func TestUploadWithoutHandlerWaits(t *testing.T) {
+	q, _, cleanup := newTestQueue(t, testConfig)
+	defer cleanup()
+	q.start()
+	defer q.stop()
+
+	payload, _ := json.Marshal("late")
+	done, err := q.enqueue(&entry{upload: Upload{Kind: "late", Payload: payload}})
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+		assert.Fail(t, "the upload is done before its handler is registered")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	current = q
+	defer func() { current = nil }()
+	late := &recorder{}
+	RegisterHandler("late", late.handle)
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"late"}, late.done())
+}
+
+func TestDoWithoutQueue(t *testing.T) {
+	r := &recorder{}
+	RegisterHandler("test", r.handle)
+
+	assert.NoError(t, Do(logger, testUpload("now", PriorityResults).upload))
+	assert.Error(t, Do(logger, Upload{Kind: "unknown"}))
+	assert.Equal(t, []string{"now"}, r.done())
+}
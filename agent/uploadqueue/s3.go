@@ -0,0 +1,100 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/s3util"
+)
+
+// KindS3Object are the uploads of a file, or of a part of it, to an s3 object
+const KindS3Object = "s3Object"
+
+// S3Object is the payload of an upload to s3
+type S3Object struct {
+	Bucket   string `json:"bucket"`
+	Key      string `json:"key"`
+	FilePath string `json:"filePath"`
+	// Offset and Length select the part of the file that is uploaded, a Length of 0 uploads the file up to its end
+	Offset int64 `json:"offset,omitempty"`
+	Length int64 `json:"length,omitempty"`
+}
+
+// s3Uploader uploads content to s3
+type s3Uploader interface {
+	S3UploadFromReader(log log.T, bucketName string, objectKey string, reader io.Reader) error
+}
+
+// newS3Uploader is replaced in unit tests
+var newS3Uploader = func(log log.T, bucketName string) s3Uploader {
+	return s3util.NewAmazonS3Util(log, bucketName)
+}
+
+func init() {
+	RegisterHandler(KindS3Object, uploadS3Object)
+}
+
+// UploadFile queues the upload of a file, or of a part of it, to s3 and waits until it is done
+func UploadFile(log log.T, priority Priority, object S3Object) error {
+	payload, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	size := object.Length
+	if fileInfo, err := os.Stat(object.FilePath); err == nil && size == 0 {
+		size = fileInfo.Size() - object.Offset
+	}
+	return Do(log, Upload{
+		Kind:        KindS3Object,
+		Priority:    priority,
+		Description: fmt.Sprintf("%v to s3://%v/%v", object.FilePath, object.Bucket, object.Key),
+		Size:        size,
+		Payload:     payload,
+	})
+}
+
+func uploadS3Object(log log.T, payload []byte) error {
+	var object S3Object
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return err
+	}
+	file, err := os.Open(object.FilePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	length := object.Length
+	if length == 0 {
+		fileInfo, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		length = fileInfo.Size() - object.Offset
+	}
+	var reader io.Reader = file
+	if object.Offset > 0 || object.Length > 0 {
+		reader = io.NewSectionReader(file, object.Offset, length)
+	}
+	log.Infof("Uploading %v to s3://%v/%v", object.FilePath, object.Bucket, object.Key)
+	if presigned, ok := newPresignedUploader(); ok {
+		return presigned.upload(log, object.Bucket, object.Key, NewReader(reader), length)
+	}
+	return newS3Uploader(log, object.Bucket).S3UploadFromReader(log, object.Bucket, object.Key, NewReader(reader))
+}
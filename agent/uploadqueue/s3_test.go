@@ -0,0 +1,58 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeS3Uploader struct {
+	objects map[string]string
+}
+
+func (u *fakeS3Uploader) S3UploadFromReader(log log.T, bucketName string, objectKey string, reader io.Reader) error {
+	content, err := ioutil.ReadAll(reader)
+	u.objects[bucketName+"/"+objectKey] = string(content)
+	return err
+}
+
+func TestUploadFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "stdout")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("0123456789abcdef")
+	file.Close()
+
+	uploader := &fakeS3Uploader{objects: make(map[string]string)}
+	savedUploader := newS3Uploader
+	newS3Uploader = func(log log.T, bucketName string) s3Uploader { return uploader }
+	defer func() { newS3Uploader = savedUploader }()
+
+	assert.NoError(t, UploadFile(logger, PriorityResults, S3Object{Bucket: "bucket", Key: "stdout", FilePath: file.Name()}))
+	assert.NoError(t, UploadFile(logger, PriorityResults, S3Object{Bucket: "bucket", Key: "part-1", FilePath: file.Name(), Length: 10}))
+	assert.NoError(t, UploadFile(logger, PriorityResults, S3Object{Bucket: "bucket", Key: "part-2", FilePath: file.Name(), Offset: 10}))
+	assert.Error(t, UploadFile(logger, PriorityResults, S3Object{Bucket: "bucket", Key: "missing", FilePath: file.Name() + ".missing"}))
+
+	assert.Equal(t, map[string]string{
+		"bucket/stdout": "0123456789abcdef",
+		"bucket/part-1": "0123456789",
+		"bucket/part-2": "abcdef",
+	}, uploader.objects)
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+)
+
+// spoolFilesDirName is the folder of the store the files of the spooled uploads to s3 are copied to
+const spoolFilesDirName = "files"
+
+var errSpoolFull = errors.New("the upload spool is full, export the spooled uploads with the ssm-cli")
+
+// spool saves an upload for an export. The part of the file of an upload to s3 is copied into the store, the
+// file itself is usually removed once the upload returns.
+func (q *queue) spool(upload Upload) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	metrics := &q.metrics[upload.Priority.index()]
+	if size, _ := dirSize(q.store.dir); size+upload.Size > int64(q.config.Spool.MaxSizeMB)*1024*1024 {
+		metrics.Dropped++
+		return errSpoolFull
+	}
+	q.seq++
+	if upload.ID == "" {
+		upload.ID = fmt.Sprintf("%v-%v", time.Now().UnixNano(), q.seq)
+	}
+	if upload.QueuedTime.IsZero() {
+		upload.QueuedTime = time.Now().UTC()
+	}
+	if upload.Kind == KindS3Object {
+		payload, err := q.store.copyObjectFile(upload.ID, upload.Payload)
+		if err != nil {
+			return err
+		}
+		upload.Payload = payload
+	}
+	if err := q.store.save(upload); err != nil {
+		return err
+	}
+	metrics.Spooled++
+	return nil
+}
+
+// copyObjectFile copies the part of the file of an upload to s3 into the store and returns the payload of the copy
+func (s *store) copyObjectFile(id string, payload []byte) ([]byte, error) {
+	var object S3Object
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(s.dir, spoolFilesDirName)
+	if err := fileutil.MakeDirsWithExecuteAccess(dir); err != nil {
+		return nil, err
+	}
+	source, err := os.Open(object.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	if _, err = source.Seek(object.Offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var reader io.Reader = source
+	if object.Length > 0 {
+		reader = io.LimitReader(source, object.Length)
+	}
+	object.FilePath = filepath.Join(dir, id)
+	copied, err := os.OpenFile(object.FilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, appconfig.ReadWriteAccess)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(copied, reader)
+	if closeErr := copied.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(object.FilePath)
+		return nil, err
+	}
+	object.Offset, object.Length = 0, 0
+	return json.Marshal(object)
+}
+
+// dirSize returns the size of the files below a folder
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
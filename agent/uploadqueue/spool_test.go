@@ -0,0 +1,157 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+var spoolConfig = appconfig.UploadsCfg{
+	MaxConcurrentUploads: 1,
+	MaxAttempts:          2,
+	MaxQueuedUploads:     10,
+	Spool:                appconfig.SpoolCfg{Enabled: true, MaxSizeMB: 1},
+}
+
+// newSpoolTest returns a queue spooling to the store of an instance in a temporary data folder, and a signing key
+func newSpoolTest(t *testing.T, instanceID string) (*queue, *recorder, appconfig.SpoolCfg, func()) {
+	q, r, cleanup := newTestQueue(t, spoolConfig)
+	savedPath := dataStorePath
+	dataStorePath = q.store.dir
+	q.store.dir = storeDir(instanceID)
+	config := spoolConfig.Spool
+	config.SigningKeyPath = filepath.Join(dataStorePath, "signing.key")
+	assert.NoError(t, ioutil.WriteFile(config.SigningKeyPath, []byte("0123456789abcdef0123456789abcdef\n"), 0600))
+	return q, r, config, func() {
+		dataStorePath = savedPath
+		cleanup()
+	}
+}
+
+func writeTempFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "stdout")
+	assert.NoError(t, err)
+	file.WriteString(content)
+	file.Close()
+	return file.Name()
+}
+
+func TestSpoolCopiesTheFilesOfUploadsToS3(t *testing.T) {
+	q, _, _, cleanup := newSpoolTest(t, "i-1")
+	defer cleanup()
+	path := writeTempFile(t, "0123456789abcdef")
+	payload, _ := json.Marshal(S3Object{Bucket: "bucket", Key: "part-2", FilePath: path, Offset: 10})
+	assert.NoError(t, q.spool(Upload{Kind: KindS3Object, Priority: PriorityResults, Description: "part-2", Size: 6, Payload: payload}))
+	assert.NoError(t, q.spool(testUpload("telemetry", PriorityTelemetry).upload))
+	os.Remove(path)
+
+	uploads := q.store.load(logger)
+	assert.Len(t, uploads, 2)
+	var object S3Object
+	assert.NoError(t, json.Unmarshal(uploads[0].Payload, &object))
+	assert.Equal(t, filepath.Join(q.store.dir, spoolFilesDirName, uploads[0].ID), object.FilePath)
+	assert.Equal(t, int64(0), object.Offset)
+	content, err := ioutil.ReadFile(object.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef", string(content))
+
+	stats := q.stats()
+	assert.Equal(t, int64(1), stats[PriorityResults].Spooled)
+	assert.Equal(t, int64(1), stats[PriorityTelemetry].Spooled)
+	assert.Equal(t, 0, stats[PriorityResults].Queued)
+}
+
+func TestSpoolRejectsUploadsOnceFull(t *testing.T) {
+	q, _, _, cleanup := newSpoolTest(t, "i-1")
+	defer cleanup()
+	upload := testUpload("large", PriorityInventory).upload
+	upload.Size = 2 * 1024 * 1024
+
+	assert.Equal(t, errSpoolFull, q.spool(upload))
+	assert.Empty(t, q.store.load(logger))
+	assert.Equal(t, int64(1), q.stats()[PriorityInventory].Dropped)
+}
+
+func TestExportAndImportSpool(t *testing.T) {
+	q, r, config, cleanup := newSpoolTest(t, "i-1")
+	defer cleanup()
+	uploader := &fakeS3Uploader{objects: make(map[string]string)}
+	savedUploader := newS3Uploader
+	newS3Uploader = func(log log.T, bucketName string) s3Uploader { return uploader }
+	defer func() { newS3Uploader = savedUploader }()
+
+	path := writeTempFile(t, "stdout of the command")
+	defer os.Remove(path)
+	payload, _ := json.Marshal(S3Object{Bucket: "bucket", Key: "stdout", FilePath: path})
+	assert.NoError(t, q.spool(Upload{Kind: KindS3Object, Priority: PriorityResults, Description: "stdout", Payload: payload}))
+	assert.NoError(t, q.spool(testUpload("telemetry", PriorityTelemetry).upload))
+	assert.NoError(t, q.spool(Upload{Kind: "inventory", Priority: PriorityInventory, Description: "inventory"}))
+
+	bundlePath := filepath.Join(dataStorePath, "spool-1.gz")
+	summary, err := ExportSpool(logger, config, "i-1", bundlePath)
+	assert.NoError(t, err)
+	assert.Equal(t, BundleSummary{InstanceID: "i-1", Sequence: 1, Uploads: 3}, summary)
+	assert.Empty(t, q.store.load(logger))
+	files, _ := ioutil.ReadDir(filepath.Join(q.store.dir, spoolFilesDirName))
+	assert.Empty(t, files)
+	_, err = ExportSpool(logger, config, "i-1", bundlePath+".empty")
+	assert.Error(t, err)
+
+	summary, err = ImportSpool(logger, config, bundlePath)
+	assert.NoError(t, err)
+	assert.Equal(t, BundleSummary{InstanceID: "i-1", Sequence: 1, Uploads: 2, Skipped: 1}, summary)
+	assert.Equal(t, map[string]string{"bucket/stdout": "stdout of the command"}, uploader.objects)
+	assert.Equal(t, []string{"telemetry"}, r.done())
+
+	_, err = ImportSpool(logger, config, bundlePath)
+	assert.Error(t, err, "a bundle is imported once")
+	assert.Equal(t, []string{"telemetry"}, r.done())
+
+	assert.NoError(t, q.spool(testUpload("next", PriorityTelemetry).upload))
+	summary, err = ExportSpool(logger, config, "i-1", bundlePath+".2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), summary.Sequence)
+	_, err = ImportSpool(logger, config, bundlePath+".2")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"telemetry", "next"}, r.done())
+}
+
+func TestImportSpoolVerifiesTheSignature(t *testing.T) {
+	q, r, config, cleanup := newSpoolTest(t, "i-1")
+	defer cleanup()
+	assert.NoError(t, q.spool(testUpload("telemetry", PriorityTelemetry).upload))
+	bundlePath := filepath.Join(dataStorePath, "spool-1.gz")
+	_, err := ExportSpool(logger, config, "i-1", bundlePath)
+	assert.NoError(t, err)
+
+	otherKey := config
+	otherKey.SigningKeyPath = filepath.Join(dataStorePath, "other.key")
+	assert.NoError(t, ioutil.WriteFile(otherKey.SigningKeyPath, []byte("fedcba9876543210fedcba9876543210"), 0600))
+	_, err = ImportSpool(logger, otherKey, bundlePath)
+	assert.Error(t, err)
+	assert.Empty(t, r.done())
+
+	noKey := config
+	noKey.SigningKeyPath = ""
+	_, err = ImportSpool(logger, noKey, bundlePath)
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package uploadqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/fileutil"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// uploadFileExtension is the extension of the files of the saved uploads
+const uploadFileExtension = ".json"
+
+// store saves the queued uploads in a folder, one file by upload
+type store struct {
+	dir string
+}
+
+func (s *store) path(id string) string {
+	return filepath.Join(s.dir, id+uploadFileExtension)
+}
+
+// save writes the upload to a temporary file renamed over its file, so that a crash doesn't leave a partial file
+func (s *store) save(upload Upload) error {
+	content, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.MakeDirsWithExecuteAccess(s.dir); err != nil {
+		return err
+	}
+	temporary := s.path(upload.ID) + ".tmp"
+	if err = ioutil.WriteFile(temporary, content, appconfig.ReadWriteAccess); err != nil {
+		return err
+	}
+	return os.Rename(temporary, s.path(upload.ID))
+}
+
+func (s *store) remove(id string) error {
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// load returns the saved uploads in the order they were queued, the files that can't be read are removed
+func (s *store) load(log log.T) []Upload {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read the saved uploads: %v", err)
+		}
+		return nil
+	}
+	var uploads []Upload
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), uploadFileExtension) {
+			continue
+		}
+		path := filepath.Join(s.dir, file.Name())
+		var upload Upload
+		content, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(content, &upload)
+		}
+		if err != nil || upload.ID+uploadFileExtension != file.Name() {
+			log.Warnf("Removing the saved upload %v, it can not be read: %v", file.Name(), err)
+			os.Remove(path)
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	sort.SliceStable(uploads, func(i, j int) bool { return uploads[i].QueuedTime.Before(uploads[j].QueuedTime) })
+	return uploads
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package uploadqueue runs the uploads of the agent through one queue, which orders them by priority and caps
+// their concurrency and combined bandwidth. Command results go first, then session logs, inventory and telemetry.
+// The queued uploads of a kind are saved in the data folder of the instance and resume after the agent restarts.
+// On a disconnected instance the saved uploads are spooled instead, and carried to a connected network in the
+// signed bundles of ExportSpool and ImportSpool.
+package uploadqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/throttle"
+)
+
+// storeDirName is the folder of the data of the instance the queued uploads are saved in
+const storeDirName = "uploads"
+
+// dataStorePath is the data folder of the agent, it is replaced in unit tests
+var dataStorePath = appconfig.DefaultDataStorePath
+
+// Priority orders the uploads, the uploads of a lower value go first
+type Priority int
+
+const (
+	PriorityResults Priority = iota
+	PrioritySessionLogs
+	PriorityInventory
+	PriorityTelemetry
+)
+
+// priorityNames are the names of the priorities in the metrics
+var priorityNames = []string{"Results", "SessionLogs", "Inventory", "Telemetry"}
+
+// index returns the index of the priority in the metrics, unknown priorities are counted as telemetry
+func (p Priority) index() int {
+	if p < PriorityResults || int(p) >= len(priorityNames) {
+		return int(PriorityTelemetry)
+	}
+	return int(p)
+}
+
+func (p Priority) String() string {
+	return priorityNames[p.index()]
+}
+
+// Upload is an upload saved in the queue, its payload is handed to the handler of its kind
+type Upload struct {
+	ID       string   `json:"id"`
+	Kind     string   `json:"kind"`
+	Priority Priority `json:"priority"`
+	// Description names the upload in the logs
+	Description string `json:"description"`
+	// Size is the number of bytes uploaded, it is counted by the metrics
+	Size       int64           `json:"size"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	QueuedTime time.Time       `json:"queuedTime"`
+}
+
+// Handler does an upload of a kind with its payload
+type Handler func(log log.T, payload []byte) error
+
+var (
+	handlersLock sync.RWMutex
+	handlers     = make(map[string]Handler)
+)
+
+// RegisterHandler sets the handler of the uploads of a kind. The uploads saved before a restart wait in the queue
+// until the handler of their kind is registered.
+func RegisterHandler(kind string, handler Handler) {
+	handlersLock.Lock()
+	handlers[kind] = handler
+	handlersLock.Unlock()
+
+	if q := running(); q != nil {
+		q.wake()
+	}
+}
+
+func handlerOf(kind string) Handler {
+	handlersLock.RLock()
+	defer handlersLock.RUnlock()
+	return handlers[kind]
+}
+
+var (
+	lock    sync.Mutex
+	current *queue
+)
+
+func running() *queue {
+	lock.Lock()
+	defer lock.Unlock()
+	return current
+}
+
+// Start starts the upload workers and resumes the uploads saved before the agent stopped
+func Start(log log.T, config appconfig.UploadsCfg, instanceID string) {
+	lock.Lock()
+	defer lock.Unlock()
+	if current != nil {
+		return
+	}
+	current = newQueue(log, config, &store{dir: storeDir(instanceID)})
+	if config.Spool.Enabled {
+		log.Infof("Spooling the uploads for an export with the ssm-cli, the agent does not send them")
+	} else {
+		current.resume()
+	}
+	current.start()
+}
+
+// storeDir returns the folder the uploads of an instance are saved in
+func storeDir(instanceID string) string {
+	return filepath.Join(dataStorePath, instanceID, storeDirName)
+}
+
+// Spooling returns true if the saved uploads are spooled for an export instead of being sent
+func Spooling() bool {
+	q := running()
+	return q != nil && q.config.Spool.Enabled
+}
+
+// Stop stops the upload workers once the running uploads complete, the queued uploads resume after a restart
+func Stop() {
+	lock.Lock()
+	q := current
+	current = nil
+	lock.Unlock()
+	if q != nil {
+		q.stop()
+	}
+}
+
+// Do saves an upload in the queue and waits until it is done. Until the queue is started, for example in the ssm-cli
+// and the unit tests, the upload is done right away. While the uploads are spooled, Do returns once the upload
+// is saved.
+func Do(log log.T, upload Upload) error {
+	q := running()
+	if q == nil {
+		handler := handlerOf(upload.Kind)
+		if handler == nil {
+			return fmt.Errorf("no handler of the %v uploads", upload.Kind)
+		}
+		return handler(log, upload.Payload)
+	}
+	if q.config.Spool.Enabled {
+		return q.spool(upload)
+	}
+	return q.wait(q.enqueue(&entry{upload: upload}))
+}
+
+// Run queues an upload that is not saved and waits until it is done. The upload is tried once, the callers that
+// stream data retry on their own schedule.
+func Run(log log.T, priority Priority, description string, size int64, upload func() error) error {
+	q := running()
+	if q == nil {
+		return upload()
+	}
+	return q.wait(q.enqueue(&entry{upload: Upload{Priority: priority, Description: description, Size: size}, run: upload}))
+}
+
+// NewReader wraps the reader of an upload so that the uploads don't exceed the bandwidth of the configuration together
+func NewReader(reader io.Reader) io.Reader {
+	q := running()
+	if q == nil {
+		return reader
+	}
+	return throttle.NewRateLimitedReader(q.limiter, reader)
+}
+
+// Stats returns the metrics of the uploads by priority since the queue started, nil until it is started
+func Stats() []PriorityStats {
+	q := running()
+	if q == nil {
+		return nil
+	}
+	return q.stats()
+}
@@ -0,0 +1,266 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/aws/amazon-ssm-agent/agent/network"
+	"github.com/aws/amazon-ssm-agent/agent/version"
+)
+
+// Headers of the callbacks
+const (
+	eventHeader     = "X-SSM-Agent-Event"
+	timestampHeader = "X-SSM-Agent-Timestamp"
+	// signatureHeader is sha256= followed by the hex HMAC-SHA256 of the timestamp header, a dot and the body
+	signatureHeader = "X-SSM-Agent-Signature"
+)
+
+// newClient returns the client of the callbacks, which goes through the artifact proxy and trusts the artifact servers.
+// It is replaced by the tests.
+var newClient = func(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: network.NewArtifactTransport(network.ArtifactEndpoint), Timeout: timeout}
+}
+
+// retryDelay is the wait before the first retry of a failed callback, doubled at every retry
+var retryDelay = time.Second
+
+// endpoint is a webhook of the configuration with its signing key
+type endpoint struct {
+	urlTemplate string
+	events      map[string]bool
+	secret      []byte
+}
+
+// accepts returns whether the endpoint subscribes to the event, all events are without a filter
+func (e *endpoint) accepts(event string) bool {
+	return e.events == nil || e.events[event]
+}
+
+// eventSender calls back the endpoints with the queued events one at a time
+type eventSender struct {
+	log         log.T
+	client      *http.Client
+	endpoints   []*endpoint
+	instanceID  string
+	maxAttempts int
+	queue       chan Event
+	dropped     int64
+	stopChan    chan struct{}
+	stopOnce    sync.Once
+	done        chan struct{}
+}
+
+// newEventSender loads the keys of the endpoints, the endpoints whose key can not be read are left out
+// rather than called back unsigned
+func newEventSender(log log.T, config appconfig.WebhooksCfg, instanceID string) *eventSender {
+	s := &eventSender{
+		log:         log,
+		client:      newClient(time.Duration(config.TimeoutSeconds) * time.Second),
+		instanceID:  instanceID,
+		maxAttempts: config.MaxAttempts,
+		queue:       make(chan Event, maxQueuedEvents),
+		stopChan:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	for _, endpointCfg := range config.Endpoints {
+		e := &endpoint{urlTemplate: endpointCfg.UrlTemplate}
+		if len(endpointCfg.Events) > 0 {
+			e.events = make(map[string]bool)
+			for _, event := range endpointCfg.Events {
+				e.events[event] = true
+			}
+		}
+		if endpointCfg.SecretPath != "" {
+			secret, err := ioutil.ReadFile(endpointCfg.SecretPath)
+			if err != nil {
+				log.Warnf("Webhook %v is not called back, its secret can not be read: %v", endpointHost(e.urlTemplate), err)
+				continue
+			}
+			if e.secret = bytes.TrimSpace(secret); len(e.secret) == 0 {
+				log.Warnf("Webhook %v is not called back, its secret %v is empty", endpointHost(e.urlTemplate), endpointCfg.SecretPath)
+				continue
+			}
+		}
+		s.endpoints = append(s.endpoints, e)
+	}
+	return s
+}
+
+// subscribed returns whether an endpoint subscribes to the event
+func (s *eventSender) subscribed(event string) bool {
+	for _, e := range s.endpoints {
+		if e.accepts(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue queues an event without blocking the document, it is called under the lock of the package
+func (s *eventSender) enqueue(event Event) {
+	event.Time = time.Now().UTC()
+	event.InstanceID = s.instanceID
+	event.AgentVersion = version.Version
+	select {
+	case s.queue <- event:
+	default:
+		s.dropped++
+	}
+}
+
+// takeDropped returns the number of events dropped since the last call
+func (s *eventSender) takeDropped() int64 {
+	lock.Lock()
+	defer lock.Unlock()
+	dropped := s.dropped
+	s.dropped = 0
+	return dropped
+}
+
+// run calls back the queued events until the sender is stopped, then calls back the remaining events once without retries
+func (s *eventSender) run() {
+	defer close(s.done)
+	for {
+		select {
+		case event := <-s.queue:
+			s.send(event, s.maxAttempts)
+		case <-s.stopChan:
+			for {
+				select {
+				case event := <-s.queue:
+					s.send(event, 1)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *eventSender) stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+	<-s.done
+}
+
+// send calls back the endpoints subscribed to the event
+func (s *eventSender) send(event Event, maxAttempts int) {
+	if dropped := s.takeDropped(); dropped > 0 {
+		s.log.Warnf("Dropped %v webhook events, more than %v events were queued", dropped, maxQueuedEvents)
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		s.log.Warnf("Failed to serialize the %v webhook event: %v", event.Event, err)
+		return
+	}
+	for _, e := range s.endpoints {
+		if !e.accepts(event.Event) {
+			continue
+		}
+		target := expandURL(e.urlTemplate, event)
+		if err := s.post(e, target, event.Event, body, maxAttempts); err != nil {
+			s.log.Warnf("Failed to call back webhook %v with the %v event of %v: %v", endpointHost(target), event.Event, event.DocumentName, err)
+		}
+	}
+}
+
+// post sends a callback, the network errors, throttling and server errors are retried with a backoff
+func (s *eventSender) post(e *endpoint, target string, event string, body []byte, maxAttempts int) (err error) {
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		if retryable, err = s.postOnce(e, target, event, body); err == nil || !retryable || attempt >= maxAttempts {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-s.stopChan:
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// postOnce sends a callback and returns whether its failure is worth retrying
+func (s *eventSender) postOnce(e *endpoint, target string, event string, body []byte) (retryable bool, err error) {
+	request, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "amazon-ssm-agent/"+version.Version)
+	request.Header.Set(eventHeader, event)
+	request.Header.Set(timestampHeader, timestamp)
+	if e.secret != nil {
+		request.Header.Set(signatureHeader, sign(e.secret, timestamp, body))
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64*1024))
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retryable, fmt.Errorf("unexpected status %v", response.Status)
+}
+
+// sign returns the signature header of a callback
+func sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// expandURL replaces the placeholders of a url template with the escaped values of the event
+func expandURL(urlTemplate string, event Event) string {
+	return strings.NewReplacer(
+		"{Event}", url.PathEscape(event.Event),
+		"{InstanceId}", url.PathEscape(event.InstanceID),
+		"{DocumentName}", url.PathEscape(event.DocumentName),
+		"{CommandId}", url.PathEscape(event.CommandID),
+		"{AssociationId}", url.PathEscape(event.AssociationID),
+		"{StepName}", url.PathEscape(event.StepName),
+		"{Status}", url.PathEscape(event.Status),
+	).Replace(urlTemplate)
+}
+
+// endpointHost returns the host of a webhook url for the logs, which leaves out the path and query that may hold tokens
+func endpointHost(target string) string {
+	if parsed, err := url.Parse(target); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return "with an invalid url"
+}
@@ -0,0 +1,97 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package webhooks calls back HTTPS endpoints of the configuration when documents and their steps complete,
+// for the orchestration systems that can not consume the AWS events. The callbacks are disabled unless they are
+// enabled in the agent configuration, then every function of the package is a no-op.
+package webhooks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+)
+
+// maxQueuedEvents is the number of events waiting to be called back, further events are dropped
+const maxQueuedEvents = 1000
+
+// Event is the JSON body of a callback
+type Event struct {
+	Event           string    `json:"event"`
+	Time            time.Time `json:"time"`
+	InstanceID      string    `json:"instanceId"`
+	AgentVersion    string    `json:"agentVersion"`
+	DocumentName    string    `json:"documentName"`
+	DocumentVersion string    `json:"documentVersion,omitempty"`
+	CommandID       string    `json:"commandId,omitempty"`
+	AssociationID   string    `json:"associationId,omitempty"`
+	StepName        string    `json:"stepName,omitempty"`
+	Action          string    `json:"action,omitempty"`
+	Status          string    `json:"status"`
+	Code            int       `json:"code"`
+}
+
+var (
+	lock   sync.Mutex
+	sender *eventSender
+)
+
+// Start begins calling back the endpoints of the configuration when it is enabled
+func Start(log log.T, config appconfig.WebhooksCfg, instanceID string) {
+	if !config.Enabled || len(config.Endpoints) == 0 {
+		return
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if sender != nil {
+		return
+	}
+	current := newEventSender(log, config, instanceID)
+	if len(current.endpoints) == 0 {
+		return
+	}
+	sender = current
+	log.Infof("Calling back %v webhook endpoints on document completion", len(current.endpoints))
+	go sender.run()
+}
+
+// Stop sends the queued events and stops the callbacks
+func Stop() {
+	lock.Lock()
+	current := sender
+	sender = nil
+	lock.Unlock()
+	if current != nil {
+		current.stop()
+	}
+}
+
+// Enabled returns whether webhooks are called back
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return sender != nil
+}
+
+// Notify queues an event for the endpoints subscribed to it, the event is dropped when the queue is full.
+// The time, the instance id and the agent version are filled in.
+func Notify(event Event) {
+	lock.Lock()
+	defer lock.Unlock()
+	if sender == nil || !sender.subscribed(event.Event) {
+		return
+	}
+	sender.enqueue(event)
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may not
+// use this file except in compliance with the License. A copy of the
+// License is located at
+//
+// http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+// either express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ssm-agent/agent/appconfig"
+	"github.com/aws/amazon-ssm-agent/agent/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// callback is a request received by the test endpoint
+type callback struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// testEndpoint records the callbacks it receives and answers with the queued statuses, then with 204
+type testEndpoint struct {
+	server    *httptest.Server
+	lock      sync.Mutex
+	callbacks []callback
+	statuses  []int
+}
+
+func newTestEndpoint(statuses ...int) *testEndpoint {
+	endpoint := &testEndpoint{statuses: statuses}
+	endpoint.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		endpoint.lock.Lock()
+		defer endpoint.lock.Unlock()
+		endpoint.callbacks = append(endpoint.callbacks, callback{path: r.URL.EscapedPath(), header: r.Header, body: body})
+		status := http.StatusNoContent
+		if len(endpoint.statuses) > 0 {
+			status, endpoint.statuses = endpoint.statuses[0], endpoint.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	newClient = func(timeout time.Duration) *http.Client {
+		client := endpoint.server.Client()
+		client.Timeout = timeout
+		return client
+	}
+	retryDelay = time.Millisecond
+	return endpoint
+}
+
+func startTestWebhooks(endpoints ...appconfig.WebhookEndpointCfg) {
+	Start(log.NewMockLog(), appconfig.WebhooksCfg{
+		Enabled:        true,
+		Endpoints:      endpoints,
+		TimeoutSeconds: 5,
+		MaxAttempts:    3,
+	}, "i-123")
+}
+
+func TestDisabled(t *testing.T) {
+	Start(log.NewMockLog(), appconfig.WebhooksCfg{Endpoints: []appconfig.WebhookEndpointCfg{{UrlTemplate: "https://example.com"}}}, "i-123")
+
+	Notify(Event{Event: appconfig.WebhookEventDocumentCompleted})
+	assert.False(t, Enabled())
+}
+
+func TestNotifySignsCallbacks(t *testing.T) {
+	endpoint := newTestEndpoint()
+	defer endpoint.server.Close()
+	dir, err := ioutil.TempDir("", "webhooks")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	secretPath := filepath.Join(dir, "secret")
+	assert.NoError(t, ioutil.WriteFile(secretPath, []byte("s3cret\n"), 0600))
+	startTestWebhooks(appconfig.WebhookEndpointCfg{
+		UrlTemplate: endpoint.server.URL + "/{InstanceId}/{DocumentName}/{StepName}",
+		SecretPath:  secretPath,
+	})
+
+	Notify(Event{
+		Event:        appconfig.WebhookEventStepCompleted,
+		DocumentName: "Patch Baseline",
+		CommandID:    "c-1",
+		StepName:     "run/script",
+		Action:       "aws:runShellScript",
+		Status:       "Success",
+	})
+	Stop()
+
+	assert.Len(t, endpoint.callbacks, 1)
+	received := endpoint.callbacks[0]
+	assert.Equal(t, "/i-123/Patch%20Baseline/run%2Fscript", received.path)
+	assert.Equal(t, appconfig.WebhookEventStepCompleted, received.header.Get(eventHeader))
+	timestamp := received.header.Get(timestampHeader)
+	assert.NotEmpty(t, timestamp)
+	assert.Equal(t, sign([]byte("s3cret"), timestamp, received.body), received.header.Get(signatureHeader))
+	var event Event
+	assert.NoError(t, json.Unmarshal(received.body, &event))
+	assert.Equal(t, "i-123", event.InstanceID)
+	assert.Equal(t, "c-1", event.CommandID)
+	assert.Equal(t, "aws:runShellScript", event.Action)
+	assert.NotEmpty(t, event.AgentVersion)
+	assert.False(t, event.Time.IsZero())
+}
+
+func TestNotifyFiltersEvents(t *testing.T) {
+	endpoint := newTestEndpoint()
+	defer endpoint.server.Close()
+	startTestWebhooks(appconfig.WebhookEndpointCfg{
+		UrlTemplate: endpoint.server.URL + "/{Event}",
+		Events:      []string{appconfig.WebhookEventDocumentCompleted},
+	})
+
+	Notify(Event{Event: appconfig.WebhookEventStepCompleted})
+	Notify(Event{Event: appconfig.WebhookEventDocumentCompleted, Status: "Failed"})
+	Stop()
+
+	assert.Len(t, endpoint.callbacks, 1)
+	assert.Equal(t, "/"+appconfig.WebhookEventDocumentCompleted, endpoint.callbacks[0].path)
+	assert.Empty(t, endpoint.callbacks[0].header.Get(signatureHeader))
+}
+
+func TestFailedCallbacksAreRetried(t *testing.T) {
+	endpoint := newTestEndpoint(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer endpoint.server.Close()
+	startTestWebhooks(appconfig.WebhookEndpointCfg{UrlTemplate: endpoint.server.URL})
+
+	sender.send(Event{Event: appconfig.WebhookEventDocumentCompleted}, sender.maxAttempts)
+	assert.Len(t, endpoint.callbacks, 3)
+
+	endpoint.statuses = []int{http.StatusBadRequest}
+	sender.send(Event{Event: appconfig.WebhookEventDocumentCompleted}, sender.maxAttempts)
+	assert.Len(t, endpoint.callbacks, 4)
+	Stop()
+}
+
+func TestEndpointWithUnreadableSecretIsSkipped(t *testing.T) {
+	startTestWebhooks(appconfig.WebhookEndpointCfg{
+		UrlTemplate: "https://example.com",
+		SecretPath:  filepath.Join(os.TempDir(), "webhooks-missing-secret"),
+	})
+
+	assert.False(t, Enabled())
+}